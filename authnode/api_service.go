@@ -83,6 +83,203 @@ func (m *Server) getTicket(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (m *Server) getTicketByIdentity(w http.ResponseWriter, r *http.Request) {
+	var (
+		plaintext []byte
+		err       error
+		jobj      proto.AuthIdentityTicketReq
+		ts        int64
+		key       []byte
+		keyInfo   *keystore.KeyInfo
+		message   string
+	)
+
+	if m.cluster.identity == nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "no identity backend configured"})
+		return
+	}
+
+	if plaintext, err = m.extractClientReqInfo(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = json.Unmarshal([]byte(plaintext), &jobj); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if jobj.Type != proto.MsgAuthIdentityTicketReq {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("invalid request messge type %x", int32(jobj.Type)).Error()})
+		return
+	}
+
+	if err = proto.IsValidServiceID(jobj.ServiceID); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	key = cryptoutil.GenIdentityKey(jobj.Principal, jobj.Password)
+	if ts, err = proto.ParseVerifier(jobj.Verifier, key); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if keyInfo, err = m.cluster.LoginIdentity(jobj.Principal, jobj.Password); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeIdentityAuthFailed, Msg: err.Error()})
+		return
+	}
+
+	if message, err = m.genIdentityTicketResp(&jobj, keyInfo, key, ts, r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAuthAPIAccessGenRespError, Msg: err.Error()})
+		return
+	}
+
+	sendOkReply(w, r, newSuccessHTTPAuthReply(message))
+	return
+}
+
+func (m *Server) getRevocations(w http.ResponseWriter, r *http.Request) {
+	var (
+		plaintext []byte
+		err       error
+		jobj      proto.AuthRevocationsReq
+		ts        int64
+		key       []byte
+		jresp     []byte
+		message   string
+	)
+
+	if plaintext, err = m.extractClientReqInfo(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = json.Unmarshal([]byte(plaintext), &jobj); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "Unmarshal AuthRevocationsReq failed: " + err.Error()})
+		return
+	}
+
+	if jobj.Type != proto.MsgAuthRevocationsReq {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("invalid request messge type %x", int32(jobj.Type)).Error()})
+		return
+	}
+
+	if err = proto.IsValidServiceID(jobj.ServiceID); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	// the service proves its identity by the verifier encrypted with its key
+	if key, err = m.getSecretKey(jobj.ServiceID); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if ts, err = proto.ParseVerifier(jobj.Verifier, key); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	resp := proto.AuthRevocationsResp{
+		Type:        jobj.Type + 1,
+		ServiceID:   jobj.ServiceID,
+		Verifier:    ts + 1,
+		Revocations: m.cluster.Revocations(),
+	}
+	if jresp, err = json.Marshal(resp); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAuthAPIAccessGenRespError, Msg: err.Error()})
+		return
+	}
+
+	if message, err = cryptoutil.EncodeMessage(jresp, key); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAuthAPIAccessGenRespError, Msg: err.Error()})
+		return
+	}
+
+	sendOkReply(w, r, newSuccessHTTPAuthReply(message))
+	return
+}
+
+// getSecretKeyByAccessKey returns the secret key and the caps of an access key to the objectnode,
+// which verifies the S3 signatures of the requests by it.
+func (m *Server) getSecretKeyByAccessKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		plaintext []byte
+		err       error
+		jobj      proto.AuthSecretKeyReq
+		ts        int64
+		key       []byte
+		akInfo    *keystore.AccessKeyInfo
+		keyInfo   *keystore.KeyInfo
+		jresp     []byte
+		message   string
+	)
+
+	if plaintext, err = m.extractClientReqInfo(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = json.Unmarshal([]byte(plaintext), &jobj); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "Unmarshal AuthSecretKeyReq failed: " + err.Error()})
+		return
+	}
+
+	if jobj.Type != proto.MsgAuthSecretKeyReq {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("invalid request messge type %x", int32(jobj.Type)).Error()})
+		return
+	}
+
+	// only the objectnode verifies the signatures by the secret keys
+	if jobj.ServiceID != proto.ObjectServiceID {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("invalid service ID [%s]", jobj.ServiceID).Error()})
+		return
+	}
+
+	// the service proves its identity by the verifier encrypted with its key
+	if key, err = m.getSecretKey(jobj.ServiceID); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if ts, err = proto.ParseVerifier(jobj.Verifier, key); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if akInfo, err = m.cluster.GetAKInfo(jobj.AccessKey); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAccessKeyNotExists, Msg: err.Error()})
+		return
+	}
+
+	if keyInfo, err = m.getSecretKeyInfo(akInfo.ID); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAuthKeyStoreError, Msg: err.Error()})
+		return
+	}
+
+	resp := proto.AuthSecretKeyResp{
+		Type:      jobj.Type + 1,
+		ServiceID: jobj.ServiceID,
+		Verifier:  ts + 1,
+		AccessKey: keyInfo.AccessKey,
+		SecretKey: keyInfo.SecretKey,
+		Caps:      keyInfo.Caps,
+	}
+	if jresp, err = json.Marshal(resp); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAuthAPIAccessGenRespError, Msg: err.Error()})
+		return
+	}
+
+	if message, err = cryptoutil.EncodeMessage(jresp, key); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeAuthAPIAccessGenRespError, Msg: err.Error()})
+		return
+	}
+
+	sendOkReply(w, r, newSuccessHTTPAuthReply(message))
+	return
+}
+
 func (m *Server) raftNodeOp(w http.ResponseWriter, r *http.Request) {
 	var (
 		plaintext []byte
@@ -111,7 +308,7 @@ func (m *Server) raftNodeOp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ticket, ts, err = proto.ExtractAPIAccessTicket(&apiReq, m.cluster.AuthSecretKey); err != nil {
+	if ticket, ts, err = m.extractAPIAccessTicket(&apiReq); err != nil {
 		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "ExtractAPIAccessTicket failed: " + err.Error()})
 		return
 	}
@@ -226,6 +423,7 @@ func (m *Server) apiAccessEntry(w http.ResponseWriter, r *http.Request) {
 		}
 	case proto.MsgAuthDeleteKeyReq:
 	case proto.MsgAuthGetKeyReq:
+	case proto.MsgAuthRevokeKeyReq:
 	case proto.MsgAuthAddCapsReq:
 		fallthrough
 	case proto.MsgAuthDeleteCapsReq:
@@ -243,7 +441,7 @@ func (m *Server) apiAccessEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ticket, ts, err = proto.ExtractAPIAccessTicket(&apiReq, m.cluster.AuthSecretKey); err != nil {
+	if ticket, ts, err = m.extractAPIAccessTicket(&apiReq); err != nil {
 		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "ExtractAPIAccessTicket failed: " + err.Error()})
 		return
 	}
@@ -264,6 +462,8 @@ func (m *Server) apiAccessEntry(w http.ResponseWriter, r *http.Request) {
 		newKeyInfo, err = m.handleAddCaps(&keyInfo)
 	case proto.MsgAuthDeleteCapsReq:
 		newKeyInfo, err = m.handleDeleteCaps(&keyInfo)
+	case proto.MsgAuthRevokeKeyReq:
+		newKeyInfo, err = m.handleRevokeKey(&keyInfo)
 	}
 
 	if err != nil {
@@ -309,6 +509,21 @@ func (m *Server) handleDeleteCaps(keyInfo *keystore.KeyInfo) (res *keystore.KeyI
 	return m.cluster.DeleteCaps(keyInfo.ID, keyInfo)
 }
 
+func (m *Server) handleRevokeKey(keyInfo *keystore.KeyInfo) (res *keystore.KeyInfo, err error) {
+	return m.cluster.RevokeKey(keyInfo.ID)
+}
+
+// extractAPIAccessTicket verifies the ticket of the request, and rejects it if revoked
+func (m *Server) extractAPIAccessTicket(req *proto.APIAccessReq) (ticket cryptoutil.Ticket, ts int64, err error) {
+	if ticket, ts, err = proto.ExtractAPIAccessTicket(req, m.cluster.AuthSecretKey); err != nil {
+		return
+	}
+	if m.cluster.isTicketRevoked(&ticket) {
+		err = proto.ErrRevokedTicket
+	}
+	return
+}
+
 func (m *Server) extractClientReqInfo(r *http.Request) (plaintext []byte, err error) {
 	var (
 		message string
@@ -377,7 +592,7 @@ func (m *Server) osCapsOp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ticket, ts, err = proto.ExtractAPIAccessTicket(&apiReq, m.cluster.AuthSecretKey); err != nil {
+	if ticket, ts, err = m.extractAPIAccessTicket(&apiReq); err != nil {
 		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "ExtractAPIAccessTicket failed: " + err.Error()})
 		return
 	}
@@ -410,15 +625,106 @@ func (m *Server) osCapsOp(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func (m *Server) genTicket(key []byte, serviceID string, IP string, caps []byte) (ticket cryptoutil.Ticket) {
+func (m *Server) issueCert(w http.ResponseWriter, r *http.Request) {
+	var (
+		plaintext []byte
+		err       error
+		jobj      proto.AuthIssueCertReq
+		ticket    cryptoutil.Ticket
+		ts        int64
+		cert      []byte
+		message   string
+	)
+
+	if m.cluster.certIssuer == nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "tls CA is not configured"})
+		return
+	}
+
+	if plaintext, err = m.extractClientReqInfo(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = json.Unmarshal([]byte(plaintext), &jobj); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "Unmarshal AuthIssueCertReq failed: " + err.Error()})
+		return
+	}
+
+	apiReq := jobj.APIReq
+
+	if apiReq.Type != proto.MsgAuthIssueCertReq {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("invalid request messge type %x", int32(apiReq.Type)).Error()})
+		return
+	}
+
+	if err = proto.VerifyAPIAccessReqIDs(&apiReq); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "VerifyAPIAccessReqIDs failed: " + err.Error()})
+		return
+	}
+
+	if ticket, ts, err = m.extractAPIAccessTicket(&apiReq); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "ExtractAPIAccessTicket failed: " + err.Error()})
+		return
+	}
+
+	if err = proto.CheckAPIAccessCaps(&ticket, proto.APIRsc, apiReq.Type, proto.APIAccess); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "CheckAPIAccessCaps failed: " + err.Error()})
+		return
+	}
+
+	if cert, err = m.cluster.certIssuer.issue(jobj.CSR); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "issue cert failed: " + err.Error()})
+		return
+	}
+	log.LogInfof("action[issueCert] client[%v] issued a tls certificate", apiReq.ClientID)
+
+	if message, err = genAuthIssueCertResp(&apiReq, cert, m.cluster.certIssuer.caPEM, ts, ticket.SessionKey.Key); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	sendOkReply(w, r, newSuccessHTTPAuthReply(message))
+	return
+}
+
+func genAuthIssueCertResp(req *proto.APIAccessReq, cert, caCert []byte, ts int64, key []byte) (message string, err error) {
+	var (
+		jresp []byte
+		resp  proto.AuthIssueCertResp
+	)
+
+	resp.APIResp.Type = req.Type + 1
+	resp.APIResp.ClientID = req.ClientID
+	resp.APIResp.ServiceID = req.ServiceID
+	resp.APIResp.Verifier = ts + 1 // increase ts by one for client verify server
+
+	resp.Cert = cert
+	resp.CACert = caCert
+
+	if jresp, err = json.Marshal(resp); err != nil {
+		err = fmt.Errorf("json marshal for response failed %s", err.Error())
+		return
+	}
+
+	if message, err = cryptoutil.EncodeMessage(jresp, key); err != nil {
+		err = fmt.Errorf("encode message for response failed %s", err.Error())
+		return
+	}
+
+	return
+}
+
+func (m *Server) genTicket(key []byte, clientID string, serviceID string, IP string, caps []byte) (ticket cryptoutil.Ticket) {
 	currentTime := time.Now().Unix()
 	ticket.Version = cryptoutil.TicketVersion
 	ticket.ServiceID = serviceID
 	ticket.SessionKey.Ctime = currentTime
 	ticket.SessionKey.Key = cryptoutil.AuthGenSessionKeyTS(key)
-	ticket.Exp = currentTime + cryptoutil.TicketAge
+	ticket.Exp = currentTime + m.cluster.ticketAge
 	ticket.IP = IP
 	ticket.Caps = caps
+	ticket.ClientID = clientID
 	return
 }
 
@@ -473,8 +779,9 @@ func (m *Server) genGetTicketAuthResp(req *proto.AuthGetTicketReq, ts int64, r *
 		return
 	}
 
-	ticket := m.genTicket(serviceKey, resp.ServiceID, iputil.RealIP(r), caps)
+	ticket := m.genTicket(serviceKey, resp.ClientID, resp.ServiceID, iputil.RealIP(r), caps)
 	resp.SessionKey = ticket.SessionKey
+	resp.Exp = ticket.Exp
 
 	if jticket, err = json.Marshal(ticket); err != nil {
 		return
@@ -500,6 +807,45 @@ func (m *Server) genGetTicketAuthResp(req *proto.AuthGetTicketReq, ts int64, r *
 	return
 }
 
+// genIdentityTicketResp issues the ticket of the user a principal is mapped to, and encrypts the
+// response with the identity key of the principal
+func (m *Server) genIdentityTicketResp(req *proto.AuthIdentityTicketReq, keyInfo *keystore.KeyInfo, key []byte, ts int64, r *http.Request) (message string, err error) {
+	var (
+		jticket    []byte
+		jresp      []byte
+		resp       proto.AuthGetTicketResp
+		serviceKey []byte
+	)
+
+	resp.Type = req.Type + 1
+	resp.ClientID = keyInfo.ID
+	resp.ServiceID = req.ServiceID
+	resp.Verifier = ts + 1
+
+	if serviceKey, err = m.getSecretKey(req.ServiceID); err != nil {
+		return
+	}
+
+	ticket := m.genTicket(serviceKey, resp.ClientID, resp.ServiceID, iputil.RealIP(r), keyInfo.Caps)
+	resp.SessionKey = ticket.SessionKey
+	resp.Exp = ticket.Exp
+
+	if jticket, err = json.Marshal(ticket); err != nil {
+		return
+	}
+
+	if resp.Ticket, err = cryptoutil.EncodeMessage(jticket, serviceKey); err != nil {
+		return
+	}
+
+	if jresp, err = json.Marshal(resp); err != nil {
+		return
+	}
+
+	message, err = cryptoutil.EncodeMessage(jresp, key)
+	return
+}
+
 func validateGetTicketReqFormat(req *proto.AuthGetTicketReq) (err error) {
 	if err = proto.IsValidClientID(req.ClientID); err != nil {
 		return
@@ -0,0 +1,98 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+)
+
+const (
+	defaultCertValidDays = 365
+)
+
+// certIssuer signs the TLS certificates of the nodes and the clients with the CA of the cluster,
+// for the mutual TLS between them.
+type certIssuer struct {
+	ca        *x509.Certificate
+	caPEM     []byte
+	key       interface{}
+	validDays int64
+}
+
+func newCertIssuer(certFile, keyFile string, validDays int64) (issuer *certIssuer, err error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return
+	}
+	if !ca.IsCA {
+		return nil, fmt.Errorf("%v is not a CA certificate", certFile)
+	}
+	caPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return
+	}
+	if validDays <= 0 {
+		validDays = defaultCertValidDays
+	}
+	issuer = &certIssuer{ca: ca, caPEM: caPEM, key: pair.PrivateKey, validDays: validDays}
+	return
+}
+
+// issue signs the PEM certificate signing request, and returns the PEM certificate usable by
+// both the server and the client sides of a connection.
+func (issuer *certIssuer) issue(csrPEM []byte) (certPEM []byte, err error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("no certificate request in the csr")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		IPAddresses:  csr.IPAddresses,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Duration(issuer.validDays) * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer.ca, csr.PublicKey, issuer.key)
+	if err != nil {
+		return
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
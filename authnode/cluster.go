@@ -48,6 +48,9 @@ type Cluster struct {
 	AuthSecretKey       []byte
 	AuthRootKey         []byte
 	PKIKey              PKIKey
+	certIssuer          *certIssuer
+	identity            *identityProvider
+	ticketAge           int64
 }
 
 func newCluster(name string, leaderInfo *LeaderInfo, fsm *KeystoreFsm, partition raftstore.Partition, cfg *clusterConfig) (c *Cluster) {
@@ -248,3 +251,57 @@ errHandler:
 	log.LogError(errors.Stack(err))
 	return
 }
+
+// RevokeKey rotates the key of a client and revokes the tickets issued to it until now
+func (c *Cluster) RevokeKey(id string) (res *keystore.KeyInfo, err error) {
+	var cur *keystore.KeyInfo
+	c.fsm.opKeyMutex.Lock()
+	defer c.fsm.opKeyMutex.Unlock()
+	if cur, err = c.fsm.GetKey(id); err != nil {
+		err = proto.ErrKeyNotExists
+		goto errHandler
+	}
+	res = new(keystore.KeyInfo)
+	*res = *cur
+	res.RevokeTs = time.Now().Unix()
+	res.Ts = res.RevokeTs
+	res.AuthKey = cryptoutil.GenSecretKey([]byte(c.AuthRootKey), res.Ts, id)
+	if err = c.syncRevokeKey(res); err != nil {
+		goto errHandler
+	}
+	c.fsm.DeleteKey(id)
+	c.fsm.PutKey(res)
+	log.LogWarnf("action[RevokeKey], clusterID[%v] ID:%v revoked the tickets until %v", c.Name, id, res.RevokeTs)
+	return
+errHandler:
+	err = fmt.Errorf("action[RevokeKey], clusterID[%v] ID:%v, err:%v ", c.Name, id, err.Error())
+	log.LogError(errors.Stack(err))
+	return
+}
+
+// Revocations returns the revocations of the clients whose revoked tickets may not expire yet
+func (c *Cluster) Revocations() (revocations []proto.Revocation) {
+	age := c.ticketAge
+	if age < cryptoutil.TicketAge {
+		// the tickets issued before the age is shortened live longer
+		age = cryptoutil.TicketAge
+	}
+	since := time.Now().Unix() - age
+	revocations = make([]proto.Revocation, 0)
+	c.fsm.ksMutex.RLock()
+	defer c.fsm.ksMutex.RUnlock()
+	for _, k := range c.fsm.keystore {
+		if k.RevokeTs >= since {
+			revocations = append(revocations, proto.Revocation{ClientID: k.ID, RevokeTs: k.RevokeTs})
+		}
+	}
+	return
+}
+
+func (c *Cluster) isTicketRevoked(ticket *cryptoutil.Ticket) bool {
+	if ticket.ClientID == "" {
+		return false
+	}
+	k, err := c.fsm.GetKey(ticket.ClientID)
+	return err == nil && ticket.SessionKey.Ctime <= k.RevokeTs
+}
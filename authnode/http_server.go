@@ -76,6 +76,8 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case proto.ClientGetTicket:
 		m.getTicket(w, r)
+	case proto.ClientGetTicketByIdentity:
+		m.getTicketByIdentity(w, r)
 	case proto.AdminCreateKey:
 		fallthrough
 	case proto.AdminGetKey:
@@ -87,11 +89,19 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case proto.AdminDeleteCaps:
 		fallthrough
 	case proto.AdminGetCaps:
+		fallthrough
+	case proto.AdminRevokeKey:
 		m.apiAccessEntry(w, r)
+	case proto.ServiceGetRevocations:
+		m.getRevocations(w, r)
+	case proto.ServiceGetSecretKey:
+		m.getSecretKeyByAccessKey(w, r)
 	case proto.AdminAddRaftNode:
 		fallthrough
 	case proto.AdminRemoveRaftNode:
 		m.raftNodeOp(w, r)
+	case proto.AdminIssueCert:
+		m.issueCert(w, r)
 	case proto.OSAddCaps:
 		fallthrough
 	case proto.OSDeleteCaps:
@@ -105,6 +115,7 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (m *Server) handleFunctions() {
 	http.HandleFunc(proto.ClientGetTicket, m.getTicket)
+	http.Handle(proto.ClientGetTicketByIdentity, m.handlerWithInterceptor())
 	http.Handle(proto.AdminCreateKey, m.handlerWithInterceptor())
 	http.Handle(proto.AdminGetKey, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteKey, m.handlerWithInterceptor())
@@ -113,6 +124,10 @@ func (m *Server) handleFunctions() {
 	http.Handle(proto.AdminGetCaps, m.handlerWithInterceptor())
 	http.Handle(proto.AdminAddRaftNode, m.handlerWithInterceptor())
 	http.Handle(proto.AdminRemoveRaftNode, m.handlerWithInterceptor())
+	http.Handle(proto.AdminIssueCert, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRevokeKey, m.handlerWithInterceptor())
+	http.Handle(proto.ServiceGetRevocations, m.handlerWithInterceptor())
+	http.Handle(proto.ServiceGetSecretKey, m.handlerWithInterceptor())
 	http.Handle(proto.OSAddCaps, m.handlerWithInterceptor())
 	http.Handle(proto.OSDeleteCaps, m.handlerWithInterceptor())
 	http.Handle(proto.OSGetCaps, m.handlerWithInterceptor())
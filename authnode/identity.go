@@ -0,0 +1,199 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/caps"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/keystore"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	identityBackendLDAP     = "ldap"
+	identityBackendKerberos = "kerberos"
+
+	defaultIdentityRole = "client"
+)
+
+var principalRegexp = regexp.MustCompile("^[A-Za-z0-9][A-Za-z0-9._@/-]{0,255}$")
+
+// identityBackend authenticates a principal by its password against an external identity store,
+// and returns the groups of the principal.
+type identityBackend interface {
+	authenticate(principal, password string) (groups []string, err error)
+}
+
+// identityUser is the user of chubaofs a principal or a group is mapped to.
+type identityUser struct {
+	ID   string          `json:"id"`
+	Role string          `json:"role"`
+	Caps json.RawMessage `json:"caps"`
+}
+
+// identityMap maps the principals to the users of chubaofs. A principal is mapped to the user
+// entry of its name, with or without the realm, and takes the caps of all its entries and groups.
+// The principals mapped to nothing take the default entry, or are refused without it.
+type identityMap struct {
+	Users   map[string]*identityUser `json:"users"`
+	Groups  map[string]*identityUser `json:"groups"`
+	Default *identityUser            `json:"default"`
+}
+
+// identityProvider issues the tickets to the principals of LDAP or Kerberos, so that their
+// credentials are not managed by the keystore.
+type identityProvider struct {
+	backend identityBackend
+	mapping identityMap
+}
+
+func newIdentityProvider(cfg *config.Config) (provider *identityProvider, err error) {
+	provider = new(identityProvider)
+	switch name := cfg.GetString(IdentityBackend); name {
+	case identityBackendLDAP:
+		provider.backend, err = newLDAPBackend(cfg)
+	case identityBackendKerberos:
+		provider.backend, err = newKerberosBackend(cfg)
+	default:
+		err = fmt.Errorf("unknown identity backend %v", name)
+	}
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(cfg.GetString(IdentityMapFile))
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &provider.mapping); err != nil {
+		return
+	}
+	err = provider.mapping.check()
+	return
+}
+
+func (im *identityMap) check() (err error) {
+	entries := []*identityUser{im.Default}
+	for _, u := range im.Users {
+		entries = append(entries, u)
+	}
+	for _, g := range im.Groups {
+		entries = append(entries, g)
+	}
+	for _, e := range entries {
+		if e == nil || len(e.Caps) == 0 {
+			continue
+		}
+		c := new(caps.Caps)
+		if err = c.Init(e.Caps); err != nil {
+			return fmt.Errorf("invalid caps %v: %v", string(e.Caps), err)
+		}
+	}
+	return
+}
+
+// lookup returns the user of chubaofs the principal of the groups is mapped to.
+func (im *identityMap) lookup(principal string, groups []string) (user *identityUser, err error) {
+	name := principal
+	if i := strings.LastIndex(principal, "@"); i > 0 {
+		name = principal[:i]
+	}
+	// the ID and the role are taken from the user entry, the groups only grant caps
+	var primary *identityUser
+	var entries []*identityUser
+	if u, ok := im.Users[principal]; ok {
+		primary = u
+	} else if u, ok := im.Users[name]; ok {
+		primary = u
+	}
+	for key, g := range im.Groups {
+		for _, group := range groups {
+			if strings.EqualFold(key, group) {
+				entries = append(entries, g)
+				break
+			}
+		}
+	}
+	if primary == nil && len(entries) == 0 {
+		if im.Default == nil {
+			return nil, proto.ErrIdentityNotMapped
+		}
+		primary = im.Default
+	}
+
+	user = &identityUser{ID: name, Role: defaultIdentityRole}
+	if primary != nil {
+		entries = append(entries, primary)
+		if primary.ID != "" {
+			user.ID = primary.ID
+		}
+		if primary.Role != "" {
+			user.Role = primary.Role
+		}
+	}
+	all := new(caps.Caps)
+	for _, e := range entries {
+		if len(e.Caps) == 0 {
+			continue
+		}
+		c := new(caps.Caps)
+		if err = c.Init(e.Caps); err != nil {
+			return
+		}
+		all.Union(c)
+	}
+	if user.Caps, err = json.Marshal(all); err != nil {
+		return
+	}
+	if err = proto.IsValidClientID(user.ID); err != nil {
+		return nil, proto.ErrIdentityNotMapped
+	}
+	return
+}
+
+// LoginIdentity authenticates the principal, and returns the key of the user it is mapped to, with
+// the caps of the mapping. The key of the user is created on its first login.
+func (c *Cluster) LoginIdentity(principal, password string) (keyInfo *keystore.KeyInfo, err error) {
+	var (
+		groups []string
+		user   *identityUser
+	)
+	if !principalRegexp.MatchString(principal) || password == "" {
+		return nil, proto.ErrIdentityAuthFailed
+	}
+	if groups, err = c.identity.backend.authenticate(principal, password); err != nil {
+		log.LogWarnf("action[LoginIdentity] principal[%v] authenticate failed: %v", principal, err)
+		return nil, proto.ErrIdentityAuthFailed
+	}
+	if user, err = c.identity.mapping.lookup(principal, groups); err != nil {
+		log.LogWarnf("action[LoginIdentity] principal[%v] groups%v: %v", principal, groups, err)
+		return
+	}
+	keyInfo = &keystore.KeyInfo{ID: user.ID, Role: user.Role, Caps: user.Caps}
+	if _, err = c.GetKey(user.ID); err != nil {
+		if _, err = c.CreateNewKey(user.ID, &keystore.KeyInfo{ID: user.ID, Role: user.Role, Caps: user.Caps}); err != nil {
+			return nil, err
+		}
+		log.LogInfof("action[LoginIdentity] principal[%v] created user[%v]", principal, user.ID)
+	}
+	log.LogInfof("action[LoginIdentity] principal[%v] logged in as user[%v]", principal, user.ID)
+	return keyInfo, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+)
+
+const (
+	kerberosTimeout = 10 * time.Second
+)
+
+// kerberosBackend authenticates the principals by the kinit and kvno of the MIT Kerberos client.
+// The password gets a TGT of the principal from the KDC, then the ticket of the service is
+// decrypted by the keytab of the authnode, which proves the KDC is not spoofed.
+type kerberosBackend struct {
+	keytab  string
+	service string
+}
+
+func newKerberosBackend(cfg *config.Config) (b *kerberosBackend, err error) {
+	b = &kerberosBackend{
+		keytab:  cfg.GetString(KerberosKeytab),
+		service: cfg.GetString(KerberosService),
+	}
+	if b.keytab == "" || b.service == "" {
+		return nil, fmt.Errorf("%v and %v are required by the kerberos backend", KerberosKeytab, KerberosService)
+	}
+	if _, err = os.Stat(b.keytab); err != nil {
+		return
+	}
+	for _, cmd := range []string{"kinit", "kvno"} {
+		if _, err = exec.LookPath(cmd); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *kerberosBackend) authenticate(principal, password string) (groups []string, err error) {
+	ccache, err := ioutil.TempFile("", "cfs-krb5cc-")
+	if err != nil {
+		return
+	}
+	ccache.Close()
+	defer os.Remove(ccache.Name())
+	cc := "FILE:" + ccache.Name()
+
+	if err = runKerberosCmd(password+"\n", "kinit", "-c", cc, principal); err != nil {
+		return
+	}
+	err = runKerberosCmd("", "kvno", "-c", cc, "-k", b.keytab, b.service)
+	return
+}
+
+func runKerberosCmd(stdin string, name string, args ...string) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kerberosTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %v %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/ldap"
+)
+
+const (
+	defaultLDAPUserAttr  = "uid"
+	defaultLDAPGroupAttr = "memberOf"
+	ldapTimeout          = 10 * time.Second
+)
+
+// ldapBackend authenticates the principals by binding to an LDAP server or Active Directory as
+// them, and takes their groups from the group attribute of their entries.
+type ldapBackend struct {
+	url       string
+	userDN    string // the bind DN with %s for the principal, e.g. uid=%s,ou=people,dc=example,dc=com
+	baseDN    string // where the entries of the principals are searched for their groups
+	userAttr  string
+	groupAttr string
+	tlsConfig *tls.Config
+}
+
+func newLDAPBackend(cfg *config.Config) (b *ldapBackend, err error) {
+	b = &ldapBackend{
+		url:       cfg.GetString(LDAPURL),
+		userDN:    cfg.GetString(LDAPUserDN),
+		baseDN:    cfg.GetString(LDAPBaseDN),
+		userAttr:  cfg.GetString(LDAPUserAttr),
+		groupAttr: cfg.GetString(LDAPGroupAttr),
+	}
+	if b.url == "" || strings.Count(b.userDN, "%s") != 1 {
+		return nil, fmt.Errorf("%v and %v with a %%s are required by the ldap backend", LDAPURL, LDAPUserDN)
+	}
+	if b.userAttr == "" {
+		b.userAttr = defaultLDAPUserAttr
+	}
+	if b.groupAttr == "" {
+		b.groupAttr = defaultLDAPGroupAttr
+	}
+	if caFile := cfg.GetString(LDAPCAFile); caFile != "" {
+		var caPEM []byte
+		if caPEM, err = ioutil.ReadFile(caFile); err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no CA certificate in %v", caFile)
+		}
+		b.tlsConfig = &tls.Config{RootCAs: pool}
+	}
+	return
+}
+
+func (b *ldapBackend) authenticate(principal, password string) (groups []string, err error) {
+	conn, err := ldap.Dial(b.url, b.tlsConfig, ldapTimeout)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err = conn.Bind(fmt.Sprintf(b.userDN, principal), password); err != nil {
+		return
+	}
+	if b.baseDN == "" {
+		return
+	}
+	entries, err := conn.Search(b.baseDN, b.userAttr, principal, []string{b.groupAttr})
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		groups = append(groups, e.Values(b.groupAttr)...)
+	}
+	return
+}
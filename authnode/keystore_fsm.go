@@ -152,6 +152,18 @@ func (mf *KeystoreFsm) Apply(command []byte, index uint64) (resp interface{}, er
 		} else {
 			log.LogInfof("action[Apply], Already delete key in node[%d]", mf.id)
 		}
+	case opSyncRevokeKey:
+		if err = mf.batchPut(cmdMap); err != nil {
+			panic(err)
+		}
+		// the rotated key replaces the cached one, which PutKey keeps
+		if mf.id != leader {
+			mf.DeleteKey(keyInfo.ID)
+			mf.PutKey(&keyInfo)
+			log.LogInfof("action[Apply], Successfully revoke key in node[%d]", mf.id)
+		} else {
+			log.LogInfof("action[Apply], Already revoke key in node[%d]", mf.id)
+		}
 	default:
 		if err = mf.batchPut(cmdMap); err != nil {
 			panic(err)
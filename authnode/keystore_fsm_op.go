@@ -95,6 +95,10 @@ func (c *Cluster) syncDeleteCaps(keyInfo *keystore.KeyInfo) (err error) {
 	return c.syncPutKeyInfo(opSyncDeleteCaps, keyInfo)
 }
 
+func (c *Cluster) syncRevokeKey(keyInfo *keystore.KeyInfo) (err error) {
+	return c.syncPutKeyInfo(opSyncRevokeKey, keyInfo)
+}
+
 func (c *Cluster) syncPutKeyInfo(opType uint32, keyInfo *keystore.KeyInfo) (err error) {
 	keydata := new(RaftCmd)
 	keydata.Op = opType
@@ -87,6 +87,20 @@ const (
 	AuthSecretKey     = "authServiceKey"
 	AuthRootKey       = "authRootKey"
 	EnableHTTPS       = "enableHTTPS"
+	TLSCACertFile     = "tlsCACertFile"
+	TLSCAKeyFile      = "tlsCAKeyFile"
+	TLSCertValidDays  = "tlsCertValidDays"
+	TicketAge         = "ticketAge"
+	IdentityBackend   = "identityBackend"
+	IdentityMapFile   = "identityMapFile"
+	LDAPURL           = "ldapURL"
+	LDAPUserDN        = "ldapUserDN"
+	LDAPBaseDN        = "ldapBaseDN"
+	LDAPUserAttr      = "ldapUserAttr"
+	LDAPGroupAttr     = "ldapGroupAttr"
+	LDAPCAFile        = "ldapCAFile"
+	KerberosKeytab    = "kerberosKeytab"
+	KerberosService   = "kerberosService"
 )
 
 // NewServer creates a new server
@@ -210,6 +224,10 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 		return fmt.Errorf("action[Start] failed %v,err: auth root Key invalid=%s", proto.ErrInvalidCfg, AuthRootKey)
 	}
 
+	if m.cluster.ticketAge = cfg.GetInt64(TicketAge); m.cluster.ticketAge <= 0 {
+		m.cluster.ticketAge = cryptoutil.TicketAge
+	}
+
 	if cfg.GetBool(EnableHTTPS) == true {
 		m.cluster.PKIKey.EnableHTTPS = true
 		if m.cluster.PKIKey.AuthRootPublicKey, err = ioutil.ReadFile("/app/server.crt"); err != nil {
@@ -222,6 +240,16 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 	} else {
 		m.cluster.PKIKey.EnableHTTPS = false
 	}
+	if caCertFile, caKeyFile := cfg.GetString(TLSCACertFile), cfg.GetString(TLSCAKeyFile); caCertFile != "" || caKeyFile != "" {
+		if m.cluster.certIssuer, err = newCertIssuer(caCertFile, caKeyFile, cfg.GetInt64(TLSCertValidDays)); err != nil {
+			return fmt.Errorf("action[Start] failed %v,err: load tls CA failed: %v", proto.ErrInvalidCfg, err)
+		}
+	}
+	if cfg.GetString(IdentityBackend) != "" {
+		if m.cluster.identity, err = newIdentityProvider(cfg); err != nil {
+			return fmt.Errorf("action[Start] failed %v,err: init identity backend failed: %v", proto.ErrInvalidCfg, err)
+		}
+	}
 	m.authProxy = m.newAuthProxy()
 
 	m.cluster.scheduleTask()
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
@@ -21,6 +29,7 @@ import (
 // requst path
 const (
 	GetTicket      = "getticket"
+	GetTicketByID  = "getticketbyidentity"
 	CreateKey      = "createkey"
 	DeleteKey      = "deletekey"
 	GetKey         = "getkey"
@@ -31,6 +40,8 @@ const (
 	OSAddCaps      = "osaddcaps"
 	OSDeleteCaps   = "osdeletecaps"
 	OSGetCaps      = "osgetcaps"
+	IssueCert      = "issuecert"
+	RevokeKey      = "revokekey"
 	HTTP           = "http://"
 	HTTPS          = "https://"
 )
@@ -42,10 +53,15 @@ const (
 	AccessKey  = "access_key"
 	AuthKey    = "auth_key"
 	SessionKey = "session_key"
+	IPs        = "ips"
+	CertFile   = "cert_file"
+	KeyFile    = "key_file"
+	CAFile     = "ca_file"
 )
 
 var action2PathMap = map[string]string{
 	GetTicket:      proto.ClientGetTicket,
+	GetTicketByID:  proto.ClientGetTicketByIdentity,
 	CreateKey:      proto.AdminCreateKey,
 	DeleteKey:      proto.AdminDeleteKey,
 	GetKey:         proto.AdminGetKey,
@@ -56,6 +72,8 @@ var action2PathMap = map[string]string{
 	OSAddCaps:      proto.OSAddCaps,
 	OSDeleteCaps:   proto.OSDeleteCaps,
 	OSGetCaps:      proto.OSGetCaps,
+	IssueCert:      proto.AdminIssueCert,
+	RevokeKey:      proto.AdminRevokeKey,
 }
 
 var (
@@ -64,11 +82,12 @@ var (
 )
 
 type ticketFlag struct {
-	key     string
-	host    string
-	output  string
-	request string
-	service string
+	key       string
+	host      string
+	output    string
+	request   string
+	service   string
+	principal string
 }
 
 type apiFlag struct {
@@ -191,7 +210,70 @@ func getTicketFromAuth(keyring *keyRing) (ticketfile ticketFile) {
 	return
 }
 
+// getTicketByIdentity gets the ticket of the user the LDAP or Kerberos principal is mapped to,
+// with the password read from the stdin.
+func getTicketByIdentity() (ticketfile ticketFile) {
+	var (
+		err      error
+		ts       int64
+		password string
+		msgResp  proto.AuthGetTicketResp
+		body     []byte
+	)
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", flaginfo.ticket.principal)
+	if password, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil && err != io.EOF {
+		panic(err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	key := cryptoutil.GenIdentityKey(flaginfo.ticket.principal, password)
+
+	message := proto.AuthIdentityTicketReq{
+		Type:      proto.MsgAuthIdentityTicketReq,
+		Principal: flaginfo.ticket.principal,
+		Password:  password,
+		ServiceID: flaginfo.ticket.service,
+	}
+
+	if message.Verifier, ts, err = cryptoutil.GenVerifier(key); err != nil {
+		panic(err)
+	}
+
+	url := flaginfo.ticket.host + action2PathMap[flaginfo.ticket.request]
+
+	if flaginfo.https.enable {
+		body, err = sendReqX(url, message, &flaginfo.https.cert)
+	} else {
+		body, err = sendReq(url, message)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	if msgResp, err = proto.ParseAuthGetTicketResp(body, key); err != nil {
+		panic(err)
+	}
+
+	if err = proto.VerifyTicketRespComm(&msgResp, proto.MsgAuthIdentityTicketReq, msgResp.ClientID, flaginfo.ticket.service, ts); err != nil {
+		panic(err)
+	}
+
+	ticketfile.Ticket = msgResp.Ticket
+	ticketfile.ServiceID = msgResp.ServiceID
+	ticketfile.Key = cryptoutil.Base64Encode(msgResp.SessionKey.Key)
+	ticketfile.ID = msgResp.ClientID
+
+	return
+}
+
 func getTicket() {
+	if flaginfo.ticket.request == GetTicketByID {
+		ticketfile := getTicketByIdentity()
+		ticketfile.dumpJSONFile(flaginfo.ticket.output)
+		return
+	}
+
 	cfg, err1 := config.LoadConfigFile(flaginfo.ticket.key)
 	if err1 != nil {
 		panic(err1)
@@ -220,6 +302,7 @@ func accessAuthServer() {
 		ts         int64
 		res        string
 		body       []byte
+		keyPEM     []byte
 	)
 
 	switch flaginfo.api.request {
@@ -243,6 +326,10 @@ func accessAuthServer() {
 		msg = proto.MsgAuthOSDeleteCapsReq
 	case OSGetCaps:
 		msg = proto.MsgAuthOSGetCapsReq
+	case IssueCert:
+		msg = proto.MsgAuthIssueCertReq
+	case RevokeKey:
+		msg = proto.MsgAuthRevokeKeyReq
 	default:
 		panic(fmt.Errorf("wrong requst [%s]", flaginfo.api.request))
 	}
@@ -284,6 +371,8 @@ func accessAuthServer() {
 		}
 	case DeleteKey:
 		fallthrough
+	case RevokeKey:
+		fallthrough
 	case GetKey:
 		message = proto.AuthAPIAccessReq{
 			APIReq: *apiReq,
@@ -328,6 +417,15 @@ func accessAuthServer() {
 				AccessKey: dataCFG.GetString(AccessKey),
 			},
 		}
+	case IssueCert:
+		var csrPEM []byte
+		if csrPEM, keyPEM, err = genCertRequest(dataCFG.GetString(ID), dataCFG.GetString(IPs)); err != nil {
+			panic(err)
+		}
+		message = proto.AuthIssueCertReq{
+			APIReq: *apiReq,
+			CSR:    csrPEM,
+		}
 	default:
 		panic(fmt.Errorf("wrong action [%s]", flaginfo.api.request))
 	}
@@ -351,6 +449,8 @@ func accessAuthServer() {
 		fallthrough
 	case DeleteKey:
 		fallthrough
+	case RevokeKey:
+		fallthrough
 	case GetKey:
 		fallthrough
 	case AddCaps:
@@ -365,7 +465,8 @@ func accessAuthServer() {
 			panic(err)
 		}
 
-		if flaginfo.api.request == CreateKey {
+		// the new key of the created or revoked client is dumped for it
+		if flaginfo.api.request == CreateKey || flaginfo.api.request == RevokeKey {
 			if err = resp.KeyInfo.DumpJSONFile(flaginfo.api.output); err != nil {
 				panic(err)
 			}
@@ -405,12 +506,74 @@ func accessAuthServer() {
 			panic(err)
 		}
 		fmt.Printf(res + "\n")
+	case IssueCert:
+		var resp proto.AuthIssueCertResp
+		if resp, err = proto.ParseAuthIssueCertResp(body, sessionKey); err != nil {
+			panic(err)
+		}
+		if err = proto.VerifyAPIRespComm(&resp.APIResp, msg, ticketCFG.GetString(ID), proto.AuthServiceID, ts); err != nil {
+			panic(err)
+		}
+		// the key is written first, the nodes keep the former certificate until the new pair matches
+		if err = writeFileAtomic(dataCFG.GetString(KeyFile), keyPEM, 0600); err != nil {
+			panic(err)
+		}
+		if err = writeFileAtomic(dataCFG.GetString(CertFile), resp.Cert, 0644); err != nil {
+			panic(err)
+		}
+		if err = writeFileAtomic(dataCFG.GetString(CAFile), resp.CACert, 0644); err != nil {
+			panic(err)
+		}
 	}
 
 	return
 
 }
 
+// genCertRequest generates a private key, and the PEM certificate signing request of it for the
+// common name and the comma separated IPs.
+func genCertRequest(commonName, ips string) (csrPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}
+	for _, s := range strings.Split(ips, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid ip [%s]", s)
+		}
+		template.IPAddresses = append(template.IPAddresses, ip)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+	csrPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	return
+}
+
+// writeFileAtomic writes the file by renaming a temporary one, so that the file is never read
+// half written.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	if filename == "" {
+		return fmt.Errorf("output file name needed")
+	}
+	tmp := filename + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, perm); err != nil {
+		return
+	}
+	return os.Rename(tmp, filename)
+}
+
 func accessAPI() {
 	switch flaginfo.api.service {
 	case proto.AuthServiceID:
@@ -452,8 +615,10 @@ func main() {
 		file := ticketCmd.String("output", "ticket.json", "output path to ticket file")
 		https := ticketCmd.Bool("https", false, "enable https")
 		certfile := ticketCmd.String("certfile", "server.crt", "path to cert file")
+		principal := ticketCmd.String("principal", "", "LDAP or Kerberos principal to get ticket by identity")
 		ticketCmd.Parse(os.Args[2:])
 		flaginfo.ticket.key = *key
+		flaginfo.ticket.principal = *principal
 		flaginfo.ticket.host = *host
 		flaginfo.ticket.output = *file
 		flaginfo.https.enable = *https
@@ -0,0 +1,266 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+)
+
+// snapshotPrefix is the prefix of the names of the snapshots taken by the backups.
+const snapshotPrefix = "cfs-backup-"
+
+// volumeClient is the meta wrapper and the extent client of a volume, or of a snapshot of it.
+type volumeClient struct {
+	mw *meta.MetaWrapper
+	ec *stream.ExtentClient
+}
+
+func openVolume(master, vol, snapshot string) (v *volumeClient, err error) {
+	opt := &proto.MountOptions{Volname: vol, Master: master, Snapshot: snapshot, Rdonly: snapshot != ""}
+	v = &volumeClient{}
+	if v.mw, err = meta.NewMetaWrapper(opt, false); err != nil {
+		return nil, fmt.Errorf("create meta wrapper of %v: %v", vol, err)
+	}
+	if v.ec, err = stream.NewExtentClient(opt, v.mw.AppendExtentKey, v.mw.GetExtents, v.mw.Truncate); err != nil {
+		v.mw.Close()
+		return nil, fmt.Errorf("create extent client of %v: %v", vol, err)
+	}
+	return
+}
+
+func (v *volumeClient) close() {
+	v.ec.Close()
+	v.mw.Close()
+}
+
+// backuper takes the backup sets of a volume. Each set is taken from a new snapshot of the
+// volume, and the snapshot of the latest set is kept until the next set is complete, so the
+// extents it refers to are copied on write and the next set uploads only the changed ones.
+type backuper struct {
+	mc       *masterSDK.MasterClient
+	master   string
+	vol      string
+	authKey  string
+	store    objectStore
+	root     string
+	timeout  time.Duration // of the snapshot to be ready
+	out      io.Writer
+	manifest *Manifest
+	index    map[string]string // the sets holding the extents of the base set and the uploaded ones
+	inodes   map[uint64]string // the first paths of the hard linked inodes
+	src      *volumeClient
+}
+
+// backup takes a full set if base is nil, otherwise an incremental set based on it.
+func (b *backuper) backup(base *Manifest) (m *Manifest, err error) {
+	now := time.Now().UTC()
+	m = &Manifest{
+		ID:         now.Format(setIDFormat),
+		Volume:     b.vol,
+		Snapshot:   snapshotPrefix + now.Format(setIDFormat),
+		Full:       base == nil,
+		CreateTime: now.Unix(),
+	}
+	b.manifest = m
+	b.index = make(map[string]string)
+	b.inodes = make(map[uint64]string)
+	if base != nil && !b.snapshotKept(base.Snapshot) {
+		// the extents of the base set may have been overwritten in place since
+		fmt.Fprintf(b.out, "snapshot %v of the base set %v is gone, take a full set\n", base.Snapshot, base.ID)
+		base, m.Full = nil, true
+	}
+	if base != nil {
+		if m.ID <= base.ID {
+			return nil, fmt.Errorf("set %v is not after the base set %v", m.ID, base.ID)
+		}
+		m.Base = base.ID
+		for _, f := range base.Files {
+			for _, e := range f.Extents {
+				b.index[e.Key] = e.Set
+			}
+		}
+	}
+
+	if err = b.takeSnapshot(m.Snapshot); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if e := b.mc.AdminAPI().DeleteVolumeSnapshot(b.vol, b.authKey, m.Snapshot); e != nil {
+				fmt.Fprintf(b.out, "delete snapshot %v: %v\n", m.Snapshot, e)
+			}
+		}
+	}()
+	if b.src, err = openVolume(b.master, b.vol, m.Snapshot); err != nil {
+		return nil, err
+	}
+	defer b.src.close()
+
+	root, err := b.src.mw.InodeGet_ll(proto.RootIno)
+	if err != nil {
+		return nil, fmt.Errorf("get root inode: %v", err)
+	}
+	if err = b.backupInode(root, "/"); err != nil {
+		return nil, err
+	}
+	if err = saveManifest(b.store, b.root, m); err != nil {
+		return nil, fmt.Errorf("save manifest: %v", err)
+	}
+	b.deleteOldSnapshots(m.Snapshot)
+	return m, nil
+}
+
+// takeSnapshot takes the snapshot of the volume and waits until it is ready.
+func (b *backuper) takeSnapshot(name string) error {
+	if _, err := b.mc.AdminAPI().CreateVolumeSnapshot(b.vol, b.authKey, name); err != nil {
+		return fmt.Errorf("create snapshot %v: %v", name, err)
+	}
+	deadline := time.Now().Add(b.timeout)
+	for {
+		snapshots, err := b.mc.AdminAPI().ListVolumeSnapshots(b.vol)
+		if err != nil {
+			return fmt.Errorf("list snapshots: %v", err)
+		}
+		for _, snapshot := range snapshots {
+			if snapshot.Name == name && snapshot.Status == proto.VolSnapshotReady {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			b.mc.AdminAPI().DeleteVolumeSnapshot(b.vol, b.authKey, name)
+			return fmt.Errorf("snapshot %v is not ready in %v", name, b.timeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (b *backuper) snapshotKept(name string) bool {
+	snapshots, err := b.mc.AdminAPI().ListVolumeSnapshots(b.vol)
+	if err != nil {
+		return false
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteOldSnapshots deletes the snapshots of the previous backups, which are not needed as
+// the bases once the set of the current one is complete.
+func (b *backuper) deleteOldSnapshots(current string) {
+	snapshots, err := b.mc.AdminAPI().ListVolumeSnapshots(b.vol)
+	if err != nil {
+		fmt.Fprintf(b.out, "list snapshots: %v\n", err)
+		return
+	}
+	for _, snapshot := range snapshots {
+		if !strings.HasPrefix(snapshot.Name, snapshotPrefix) || snapshot.Name == current {
+			continue
+		}
+		if err = b.mc.AdminAPI().DeleteVolumeSnapshot(b.vol, b.authKey, snapshot.Name); err != nil {
+			fmt.Fprintf(b.out, "delete snapshot %v: %v\n", snapshot.Name, err)
+		}
+	}
+}
+
+func (b *backuper) backupInode(info *proto.InodeInfo, p string) (err error) {
+	entry := &FileEntry{Path: p, Inode: info.Inode, Mode: info.Mode, Uid: info.Uid, Gid: info.Gid}
+	if !proto.IsDir(info.Mode) && info.Nlink > 1 {
+		if first, ok := b.inodes[info.Inode]; ok {
+			entry.Link = first
+			b.manifest.Files = append(b.manifest.Files, entry)
+			return
+		}
+		b.inodes[info.Inode] = p
+	}
+	switch {
+	case proto.IsDir(info.Mode):
+		b.manifest.Files = append(b.manifest.Files, entry)
+		return b.backupDir(info, p)
+	case proto.IsSymlink(info.Mode):
+		entry.Target = info.Target
+	case proto.IsRegular(info.Mode):
+		if err = b.backupFile(entry); err != nil {
+			return
+		}
+	default:
+		fmt.Fprintf(b.out, "skip %v: not a regular file, a directory or a symlink\n", p)
+		delete(b.inodes, info.Inode)
+		return
+	}
+	b.manifest.Files = append(b.manifest.Files, entry)
+	return
+}
+
+func (b *backuper) backupDir(info *proto.InodeInfo, p string) error {
+	children, err := b.src.mw.ReadDir_ll(info.Inode)
+	if err != nil {
+		return fmt.Errorf("read directory %v: %v", p, err)
+	}
+	for _, child := range children {
+		childPath := path.Join(p, child.Name)
+		childInfo, err := b.src.mw.InodeGet_ll(child.Inode)
+		if err != nil {
+			return fmt.Errorf("get inode of %v: %v", childPath, err)
+		}
+		if err = b.backupInode(childInfo, childPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupFile uploads the extents of the file not held by the base sets yet.
+func (b *backuper) backupFile(entry *FileEntry) (err error) {
+	_, size, extents, err := b.src.mw.GetExtents(entry.Inode)
+	if err != nil {
+		return fmt.Errorf("get extents of %v: %v", entry.Path, err)
+	}
+	entry.Size = size
+	if err = b.src.ec.OpenStream(entry.Inode); err != nil {
+		return fmt.Errorf("open %v: %v", entry.Path, err)
+	}
+	defer b.src.ec.CloseStream(entry.Inode)
+	for _, ek := range extents {
+		e := &ExtentEntry{FileOffset: ek.FileOffset, Size: ek.Size, Key: extentObjectKey(ek)}
+		if e.Set = b.index[e.Key]; e.Set == "" {
+			data := make([]byte, ek.Size)
+			read, err := b.src.ec.Read(entry.Inode, data, int(ek.FileOffset), int(ek.Size))
+			if err != nil || read != int(ek.Size) {
+				return fmt.Errorf("read %v at %v: read(%v) err(%v)", entry.Path, ek.FileOffset, read, err)
+			}
+			if err = b.store.Put(extentObjectPath(b.root, b.manifest.ID, e.Key), data); err != nil {
+				return fmt.Errorf("upload extent of %v at %v: %v", entry.Path, ek.FileOffset, err)
+			}
+			e.Set = b.manifest.ID
+			b.index[e.Key] = e.Set
+			b.manifest.Uploaded += uint64(ek.Size)
+		}
+		b.manifest.Bytes += uint64(ek.Size)
+		entry.Extents = append(entry.Extents, e)
+	}
+	return
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-backup backs up the snapshots of a volume to an S3 bucket as the full and incremental
+// backup sets, and restores a set into a new volume. The incremental sets upload only the
+// extents changed since the set they are based on.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Commands
+const (
+	CmdBackup  = "backup"
+	CmdRestore = "restore"
+	CmdList    = "list"
+	CmdPrune   = "prune"
+)
+
+var (
+	masterAddr = flag.String("master", "", "master addresses separated by comma")
+	volName    = flag.String("vol", "", "volume name")
+	authKey    = flag.String("authKey", "", "the md5 of the owner of the volume, to take the snapshots")
+	endpoint   = flag.String("endpoint", "", "endpoint of the S3 service, such as http://127.0.0.1:9000")
+	bucket     = flag.String("bucket", "", "bucket of the backups")
+	region     = flag.String("region", "us-east-1", "region of the bucket")
+	prefix     = flag.String("prefix", "", "prefix of the backups in the bucket, the ones of a volume are under <prefix>/<vol>")
+	full       = flag.Bool("full", false, "take a full set regardless of -fullEvery")
+	fullEvery  = flag.Int("fullEvery", 7, "take a full set after this many incrementals, 0 for never but the first")
+	keep       = flag.Int("keep", 0, "keep the latest this many full sets and their incrementals, 0 for all")
+	interval   = flag.Duration("interval", 0, "take a set at each interval until killed, 0 for once")
+	timeout    = flag.Duration("snapshotTimeout", 10*time.Minute, "timeout of the snapshot to be ready")
+	setID      = flag.String("set", "", "set to restore, default the latest one")
+	target     = flag.String("to", "", "volume to restore into, which must be empty")
+	logDir     = flag.String("logDir", "/tmp/cfs-backup", "log directory of the sdk")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %v [flags] backup|restore|list|prune\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 || *masterAddr == "" && flag.Arg(0) != CmdList && flag.Arg(0) != CmdPrune ||
+		*volName == "" || *endpoint == "" || *bucket == "" {
+		usage()
+	}
+	if _, err := log.InitLog(*logDir, "backup", log.WarnLevel, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "init log failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer log.LogFlush()
+
+	// the credentials are taken from the environment like the aws cli does
+	store, err := newS3Store(*endpoint, *bucket, *region, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	root := path.Join(*prefix, *volName)
+
+	switch flag.Arg(0) {
+	case CmdBackup:
+		err = runBackup(store, root)
+	case CmdRestore:
+		err = runRestore(store, root)
+	case CmdList:
+		err = runList(store, root)
+	case CmdPrune:
+		err = prune(store, root, *keep)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v failed: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func runBackup(store objectStore, root string) error {
+	b := &backuper{
+		mc:      masterSDK.NewMasterClient(strings.Split(*masterAddr, ","), false),
+		master:  *masterAddr,
+		vol:     *volName,
+		authKey: *authKey,
+		store:   store,
+		root:    root,
+		timeout: *timeout,
+		out:     os.Stderr,
+	}
+	for {
+		manifests, err := loadManifests(store, root)
+		if err != nil {
+			return err
+		}
+		base := nextBase(manifests, *fullEvery)
+		if *full {
+			base = nil
+		}
+		start := time.Now()
+		m, err := b.backup(base)
+		if err != nil {
+			// a scheduled backup retries at the next interval
+			if *interval == 0 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		} else {
+			fmt.Printf("set %v(%v) of %v files and %v bytes, uploaded %v bytes in %v\n", m.ID, setType(m),
+				len(m.Files), m.Bytes, m.Uploaded, time.Since(start))
+			if err = prune(store, root, *keep); err != nil {
+				fmt.Fprintf(os.Stderr, "prune failed: %v\n", err)
+			}
+		}
+		if *interval == 0 {
+			return nil
+		}
+		time.Sleep(*interval - time.Since(start)%*interval)
+	}
+}
+
+func runRestore(store objectStore, root string) (err error) {
+	if *target == "" {
+		usage()
+	}
+	var m *Manifest
+	if *setID != "" {
+		if m, err = loadManifest(store, root, *setID); err == errNotFound {
+			return fmt.Errorf("set %v not found or incomplete", *setID)
+		}
+	} else {
+		var manifests []*Manifest
+		if manifests, err = loadManifests(store, root); err == nil && len(manifests) == 0 {
+			return fmt.Errorf("no complete set")
+		} else if err == nil {
+			m = manifests[len(manifests)-1]
+		}
+	}
+	if err != nil {
+		return
+	}
+	dst, err := openVolume(*masterAddr, *target, "")
+	if err != nil {
+		return
+	}
+	defer dst.close()
+	r := &restorer{store: store, root: root, dst: dst}
+	if err = r.restore(m); err != nil {
+		return
+	}
+	fmt.Printf("restored set %v of %v files and %v bytes into %v\n", m.ID, len(m.Files), m.Bytes, *target)
+	return
+}
+
+func runList(store objectStore, root string) error {
+	manifests, err := loadManifests(store, root)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-18v %-12v %-18v %-10v %-14v %v\n", "SET", "TYPE", "BASE", "FILES", "BYTES", "UPLOADED")
+	for _, m := range manifests {
+		fmt.Printf("%-18v %-12v %-18v %-10v %-14v %v\n", m.ID, setType(m), m.Base, len(m.Files), m.Bytes, m.Uploaded)
+	}
+	return nil
+}
+
+// prune deletes the sets expired by the retention.
+func prune(store objectStore, root string, keep int) error {
+	ids, err := setIDs(store, root)
+	if err != nil {
+		return err
+	}
+	manifests, err := loadManifests(store, root)
+	if err != nil {
+		return err
+	}
+	for _, id := range expiredSets(ids, manifests, keep) {
+		if err = deleteSet(store, root, id); err != nil {
+			return fmt.Errorf("delete set %v: %v", id, err)
+		}
+		fmt.Printf("deleted set %v\n", id)
+	}
+	return nil
+}
+
+func setType(m *Manifest) string {
+	if m.Full {
+		return "full"
+	}
+	return "incremental"
+}
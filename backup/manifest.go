@@ -0,0 +1,207 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The layout of the backups of a volume under the prefix in the object store. Each backup set
+// has a directory, which holds the data of the extents uploaded by the set and the manifest.
+// The manifest is written at last, a set without it is incomplete.
+const (
+	setsDir      = "sets"
+	extentsDir   = "extents"
+	manifestName = "manifest.json"
+	setIDFormat  = "20060102T150405Z"
+)
+
+// Manifest is the metadata of a backup set, which lists all the files of the snapshot with
+// the sets holding the data of their extents, so a set is restored by its manifest alone.
+type Manifest struct {
+	ID         string       `json:"id"`
+	Volume     string       `json:"vol"`
+	Snapshot   string       `json:"snapshot"`
+	Full       bool         `json:"full"`
+	Base       string       `json:"base,omitempty"` // the set the incremental is based on
+	CreateTime int64        `json:"createTime"`
+	Files      []*FileEntry `json:"files"`
+	// Uploaded is the bytes of the extents uploaded by the set, the rest are held by the base sets.
+	Uploaded uint64 `json:"uploaded"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// FileEntry is a file, a directory or a symlink of a backup set, the parents are listed
+// before their children.
+type FileEntry struct {
+	Path   string `json:"path"`
+	Inode  uint64 `json:"ino"`
+	Mode   uint32 `json:"mode"`
+	Uid    uint32 `json:"uid"`
+	Gid    uint32 `json:"gid"`
+	Size   uint64 `json:"size,omitempty"`
+	Target []byte `json:"target,omitempty"`
+	// Link is the path of the first entry of the inode if the entry is another hard link to it.
+	Link    string         `json:"link,omitempty"`
+	Extents []*ExtentEntry `json:"extents,omitempty"`
+}
+
+// ExtentEntry is the range of a file held by an extent, whose data is kept in the object
+// of the key under the set.
+type ExtentEntry struct {
+	FileOffset uint64 `json:"offset"`
+	Size       uint32 `json:"size"`
+	Key        string `json:"key"`
+	Set        string `json:"set"`
+}
+
+// extentObjectKey returns the key of the data of the extent key. The extents are frozen by
+// the snapshots and copied on write, so the data of the same key stays unchanged as long as
+// the snapshot of the base set is kept.
+func extentObjectKey(ek proto.ExtentKey) string {
+	return fmt.Sprintf("%v_%v_%v_%v", ek.PartitionId, ek.ExtentId, ek.ExtentOffset, ek.Size)
+}
+
+func setDir(root, id string) string {
+	return path.Join(root, setsDir, id) + "/"
+}
+
+func extentObjectPath(root, set, key string) string {
+	return path.Join(root, setsDir, set, extentsDir, key)
+}
+
+func manifestPath(root, id string) string {
+	return path.Join(root, setsDir, id, manifestName)
+}
+
+// setIDs returns the IDs of all the sets under the root in the order of the creation,
+// complete or not.
+func setIDs(store objectStore, root string) ([]string, error) {
+	_, prefixes, err := store.List(path.Join(root, setsDir)+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		ids = append(ids, path.Base(strings.TrimSuffix(prefix, "/")))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// loadManifests returns the manifests of the complete sets in the order of the creation.
+func loadManifests(store objectStore, root string) (manifests []*Manifest, err error) {
+	ids, err := setIDs(store, root)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		var m *Manifest
+		if m, err = loadManifest(store, root, id); err == errNotFound {
+			continue
+		} else if err != nil {
+			return
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+func loadManifest(store objectStore, root, id string) (*Manifest, error) {
+	data, err := store.Get(manifestPath(root, id))
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest of %v: %v", id, err)
+	}
+	return m, nil
+}
+
+func saveManifest(store objectStore, root string, m *Manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return store.Put(manifestPath(root, m.ID), data)
+}
+
+// nextBase returns the set the next backup is based on, or nil if the next one must be a full
+// set, which is taken when there is no complete set yet or the latest full set already has
+// fullEvery incrementals after it. A fullEvery of zero never takes a full set but the first.
+func nextBase(manifests []*Manifest, fullEvery int) *Manifest {
+	if len(manifests) == 0 {
+		return nil
+	}
+	incrementals := 0
+	for i := len(manifests) - 1; i >= 0 && !manifests[i].Full; i-- {
+		incrementals++
+	}
+	if fullEvery > 0 && incrementals >= fullEvery {
+		return nil
+	}
+	return manifests[len(manifests)-1]
+}
+
+// expiredSets returns the sets to delete to keep the latest keep full sets and the incrementals
+// after them, which are all the sets created before the oldest full set kept, complete or not.
+// The incrementals only refer to the sets after the full set they are based on.
+func expiredSets(ids []string, manifests []*Manifest, keep int) []string {
+	if keep <= 0 {
+		return nil
+	}
+	var oldest string
+	for i := len(manifests) - 1; i >= 0 && keep > 0; i-- {
+		if manifests[i].Full {
+			oldest = manifests[i].ID
+			keep--
+		}
+	}
+	if keep > 0 {
+		return nil
+	}
+	var expired []string
+	for _, id := range ids {
+		if id < oldest {
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
+
+// deleteSet deletes the objects of the set, the manifest first so an interrupted deletion
+// leaves an incomplete set.
+func deleteSet(store objectStore, root, id string) error {
+	if err := store.Delete(manifestPath(root, id)); err != nil {
+		return err
+	}
+	keys, _, err := store.List(setDir(root, id), "")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err = store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// memStore is an object store in the memory.
+type memStore map[string][]byte
+
+func (s memStore) Put(key string, data []byte) error {
+	s[key] = data
+	return nil
+}
+
+func (s memStore) Get(key string) ([]byte, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, errNotFound
+	}
+	return data, nil
+}
+
+func (s memStore) Delete(key string) error {
+	delete(s, key)
+	return nil
+}
+
+func (s memStore) List(prefix, delimiter string) (keys, prefixes []string, err error) {
+	seen := make(map[string]bool)
+	for key := range s {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := key[len(prefix):]
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			if p := prefix + rest[:i+len(delimiter)]; !seen[p] {
+				seen[p] = true
+				prefixes = append(prefixes, p)
+			}
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sort.Strings(prefixes)
+	return
+}
+
+func putTestSet(t *testing.T, store memStore, id string, full, complete bool) {
+	store.Put(extentObjectPath("vol", id, "1_1_0_4096"), make([]byte, 4096))
+	if complete {
+		if err := saveManifest(store, "vol", &Manifest{ID: id, Full: full}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNextBase(t *testing.T) {
+	sets := func(types string) (manifests []*Manifest) {
+		for i, c := range types {
+			manifests = append(manifests, &Manifest{ID: string('a' + rune(i)), Full: c == 'F'})
+		}
+		return
+	}
+	for _, tt := range []struct {
+		types     string
+		fullEvery int
+		base      string
+	}{
+		{"", 3, ""},
+		{"F", 3, "a"},
+		{"FII", 3, "c"},
+		{"FIII", 3, ""},
+		{"FIIIF", 3, "e"},
+		{"FIIII", 0, "e"},
+	} {
+		id := ""
+		if base := nextBase(sets(tt.types), tt.fullEvery); base != nil {
+			id = base.ID
+		}
+		if id != tt.base {
+			t.Fatalf("sets %v full every %v: base %q, expected %q", tt.types, tt.fullEvery, id, tt.base)
+		}
+	}
+}
+
+func TestPruneExpiredSets(t *testing.T) {
+	store := memStore{}
+	putTestSet(t, store, "20200101T000000Z", true, true)
+	putTestSet(t, store, "20200102T000000Z", false, true)
+	putTestSet(t, store, "20200103T000000Z", false, false) // an interrupted incremental
+	putTestSet(t, store, "20200104T000000Z", true, true)
+	putTestSet(t, store, "20200105T000000Z", false, true)
+	putTestSet(t, store, "20200106T000000Z", false, false) // the one being taken
+
+	ids, err := setIDs(store, "vol")
+	if err != nil || len(ids) != 6 {
+		t.Fatalf("set ids %v err(%v)", ids, err)
+	}
+	manifests, err := loadManifests(store, "vol")
+	if err != nil || len(manifests) != 4 {
+		t.Fatalf("%v manifests err(%v), expected the 4 complete ones", len(manifests), err)
+	}
+	if expired := expiredSets(ids, manifests, 0); len(expired) != 0 {
+		t.Fatalf("keep all: expired %v", expired)
+	}
+	if expired := expiredSets(ids, manifests, 3); len(expired) != 0 {
+		t.Fatalf("keep 3 of 2 full sets: expired %v", expired)
+	}
+
+	if err = prune(store, "vol", 1); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ = setIDs(store, "vol"); strings.Join(ids, ",") != "20200104T000000Z,20200105T000000Z,20200106T000000Z" {
+		t.Fatalf("sets %v after keeping the latest full set", ids)
+	}
+	for key := range store {
+		if strings.Contains(key, "20200101") || strings.Contains(key, "20200102") || strings.Contains(key, "20200103") {
+			t.Fatalf("object %v of an expired set is left", key)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// restorer restores a backup set into an empty volume.
+type restorer struct {
+	store objectStore
+	root  string
+	dst   *volumeClient
+	inos  map[string]uint64 // the inodes restored by the paths
+}
+
+func (r *restorer) restore(m *Manifest) (err error) {
+	children, err := r.dst.mw.ReadDir_ll(proto.RootIno)
+	if err != nil {
+		return fmt.Errorf("read root directory: %v", err)
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("volume is not empty")
+	}
+	r.inos = map[string]uint64{"/": proto.RootIno}
+	for _, f := range m.Files {
+		if err = r.restoreEntry(f); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *restorer) restoreEntry(f *FileEntry) (err error) {
+	if f.Path == "/" {
+		if err = r.dst.mw.Setattr(proto.RootIno, proto.AttrMode|proto.AttrUid|proto.AttrGid, f.Mode, f.Uid, f.Gid); err != nil {
+			return fmt.Errorf("set attributes of /: %v", err)
+		}
+		return
+	}
+	dir, name := path.Split(f.Path)
+	parentID, ok := r.inos[path.Clean(dir)]
+	if !ok {
+		return fmt.Errorf("restore %v: parent not restored", f.Path)
+	}
+	if f.Link != "" {
+		ino, ok := r.inos[f.Link]
+		if !ok {
+			return fmt.Errorf("restore %v: link to %v not restored", f.Path, f.Link)
+		}
+		if _, err = r.dst.mw.Link(parentID, name, ino); err != nil {
+			return fmt.Errorf("link %v to %v: %v", f.Path, f.Link, err)
+		}
+		return
+	}
+	info, err := r.dst.mw.Create_ll(parentID, name, f.Mode, f.Uid, f.Gid, f.Target)
+	if err != nil {
+		return fmt.Errorf("create %v: %v", f.Path, err)
+	}
+	r.inos[f.Path] = info.Inode
+	if proto.IsRegular(f.Mode) {
+		return r.restoreFile(f, info.Inode)
+	}
+	return
+}
+
+// restoreFile writes the extents of the file at their offsets, the holes between them are
+// left unwritten.
+func (r *restorer) restoreFile(f *FileEntry, ino uint64) (err error) {
+	if err = r.dst.ec.OpenStream(ino); err != nil {
+		return fmt.Errorf("open %v: %v", f.Path, err)
+	}
+	defer r.dst.ec.CloseStream(ino)
+	var end uint64
+	for _, e := range f.Extents {
+		data, err := r.store.Get(extentObjectPath(r.root, e.Set, e.Key))
+		if err != nil {
+			return fmt.Errorf("download extent of %v at %v from set %v: %v", f.Path, e.FileOffset, e.Set, err)
+		}
+		if len(data) != int(e.Size) {
+			return fmt.Errorf("extent of %v at %v from set %v: %v bytes, expected %v", f.Path, e.FileOffset,
+				e.Set, len(data), e.Size)
+		}
+		if _, err = r.dst.ec.Write(ino, int(e.FileOffset), data, false); err != nil {
+			return fmt.Errorf("write %v at %v: %v", f.Path, e.FileOffset, err)
+		}
+		if e.FileOffset+uint64(e.Size) > end {
+			end = e.FileOffset + uint64(e.Size)
+		}
+	}
+	if err = r.dst.ec.Flush(ino); err != nil {
+		return fmt.Errorf("flush %v: %v", f.Path, err)
+	}
+	if f.Size > end {
+		if err = r.dst.ec.Truncate(ino, int(f.Size)); err != nil {
+			return fmt.Errorf("truncate %v to %v: %v", f.Path, f.Size, err)
+		}
+	}
+	return
+}
@@ -0,0 +1,250 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var errNotFound = errors.New("object not found")
+
+// objectStore is the external target the backup sets are kept in.
+type objectStore interface {
+	Put(key string, data []byte) error
+	// Get returns errNotFound if the object does not exist.
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	// List returns the keys under the prefix, and the common prefixes up to the delimiter after
+	// the prefix if the delimiter is not empty.
+	List(prefix, delimiter string) (keys, prefixes []string, err error)
+}
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	amzShortFormat  = "20060102"
+	amzAlgorithm    = "AWS4-HMAC-SHA256"
+	emptyPayloadSHA = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Store is an S3 bucket accessed in the path style, whose requests are signed by the
+// signature version 4.
+type s3Store struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3Store(endpoint, bucket, region, accessKey, secretKey string) (*s3Store, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint %v: %v", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %v", endpoint)
+	}
+	return &s3Store{
+		endpoint:  u,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3Store) Put(key string, data []byte) error {
+	_, err := s.do(http.MethodPut, key, nil, data)
+	return err
+}
+
+func (s *s3Store) Get(key string) ([]byte, error) {
+	return s.do(http.MethodGet, key, nil, nil)
+}
+
+func (s *s3Store) Delete(key string) error {
+	_, err := s.do(http.MethodDelete, key, nil, nil)
+	if err == errNotFound {
+		return nil
+	}
+	return err
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *s3Store) List(prefix, delimiter string) (keys, prefixes []string, err error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	for {
+		var data []byte
+		if data, err = s.do(http.MethodGet, "", query, nil); err != nil {
+			return
+		}
+		result := &listBucketResult{}
+		if err = xml.Unmarshal(data, result); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal list result: %v", err)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !result.IsTruncated {
+			return
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *s3Store) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	u := *s.endpoint
+	u.Path = "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("%v %v: status(%v) %v", method, u.Path, resp.StatusCode, string(data))
+	}
+	return data, nil
+}
+
+// sign signs the request by the signature version 4, with the host and all the headers
+// of the request.
+func (s *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	payloadSHA := emptyPayloadSHA
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadSHA = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadSHA)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadSHA,
+	}, "\n")
+	scope := strings.Join([]string{now.Format(amzShortFormat), s.region, "s3", "aws4_request"}, "/")
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{amzAlgorithm, now.Format(amzDateFormat), scope,
+		hex.EncodeToString(requestSum[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format(amzShortFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%v Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		amzAlgorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes all the bytes but the unreserved characters, and the slashes as well
+// if encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-bench runs the standardized metadata and data workloads against a volume through the
+// SDK, and reports the latency percentiles and the throughput of each operation, so that
+// the results of different releases can be compared.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Workload names
+const (
+	WorkloadMeta    = "meta"
+	WorkloadLargeIO = "large"
+	WorkloadSmallIO = "small"
+)
+
+var (
+	masterAddr  = flag.String("master", "", "master addresses separated by comma")
+	volName     = flag.String("vol", "", "volume name")
+	owner       = flag.String("owner", "", "owner of the volume")
+	workload    = flag.String("workload", WorkloadMeta, "workload: meta, large or small")
+	threads     = flag.Int("threads", 4, "number of the concurrent threads")
+	files       = flag.Int("files", 1000, "number of the files created by each thread of the meta workload")
+	fileSize    = flag.Int("size", 0, "file size of each thread of the data workloads, default 1GB for large and 64MB for small")
+	blockSize   = flag.Int("bs", 0, "block size of the data workloads, default 1MB for large and 4KB for small")
+	workDir     = flag.String("dir", "cfs-bench", "working directory under the root of the volume")
+	keep        = flag.Bool("keep", false, "keep the files written by the data workloads")
+	serverStats = flag.Bool("serverStats", false, "report the server side latency of the metanodes and datanodes")
+	metaProf    = flag.String("metaProf", "9092", "prof port of the metanodes")
+	dataProf    = flag.String("dataProf", "17320", "prof port of the datanodes")
+	logDir      = flag.String("logDir", "/tmp/cfs-bench", "log directory of the sdk")
+)
+
+func main() {
+	flag.Parse()
+	if *masterAddr == "" || *volName == "" || *threads <= 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if _, err := log.InitLog(*logDir, "bench", log.WarnLevel, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "init log failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer log.LogFlush()
+
+	opt := &proto.MountOptions{
+		Volname: *volName,
+		Owner:   *owner,
+		Master:  *masterAddr,
+	}
+	mw, err := meta.NewMetaWrapper(opt, *owner != "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init meta wrapper failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer mw.Close()
+	ec, err := stream.NewExtentClient(opt, mw.AppendExtentKey, mw.GetExtents, mw.Truncate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init extent client failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer ec.Close()
+
+	ino, err := ensureWorkDir(mw, *workDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create working directory failed: %v\n", err)
+		os.Exit(1)
+	}
+	env := &benchEnv{mw: mw, ec: ec, workDir: ino, threads: *threads}
+
+	var w Workload
+	switch *workload {
+	case WorkloadMeta:
+		w = &MetaWorkload{benchEnv: env, files: *files}
+	case WorkloadLargeIO:
+		w = &DataWorkload{benchEnv: env, fileSize: sizeOr(*fileSize, 1<<30), blockSize: sizeOr(*blockSize, 1<<20), keep: *keep}
+	case WorkloadSmallIO:
+		w = &DataWorkload{benchEnv: env, fileSize: sizeOr(*fileSize, 64<<20), blockSize: sizeOr(*blockSize, 4<<10), random: true, keep: *keep}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown workload %v\n", *workload)
+		os.Exit(1)
+	}
+
+	var servers *ServerStats
+	if *serverStats {
+		mc := masterSDK.NewMasterClient(strings.Split(*masterAddr, ","), false)
+		if servers, err = NewServerStats(mc, *metaProf, *dataProf); err != nil {
+			fmt.Fprintf(os.Stderr, "get server stats failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	r := NewRecorder()
+	start := time.Now()
+	if err = w.Run(r); err != nil {
+		fmt.Fprintf(os.Stderr, "run workload failed: %v\n", err)
+		os.Exit(1)
+	}
+	elapsed := time.Since(start)
+
+	fmt.Printf("workload: %v, threads: %v, elapsed: %v\n", *workload, *threads, elapsed)
+	PrintResults(os.Stdout, r.Results(elapsed))
+	if servers != nil {
+		fmt.Println()
+		if err = servers.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "get server stats failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func sizeOr(size, def int) int {
+	if size > 0 {
+		return size
+	}
+	return def
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+// ServerStats keeps the op counters of the metanodes and datanodes taken before the workload,
+// to report the server side latency of the operations done by the workload.
+type ServerStats struct {
+	metaNodes []string
+	dataNodes []string
+	before    map[string][]metrics.OpStat
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewServerStats takes the op counters of all the metanodes and datanodes of the cluster.
+func NewServerStats(mc *masterSDK.MasterClient, metaProf, dataProf string) (s *ServerStats, err error) {
+	cv, err := mc.AdminAPI().GetCluster()
+	if err != nil {
+		return
+	}
+	s = &ServerStats{before: make(map[string][]metrics.OpStat)}
+	for _, node := range cv.MetaNodes {
+		s.metaNodes = append(s.metaNodes, fmt.Sprintf("http://%v/getOpStats", util.ProfAddr(node.Addr, metaProf)))
+	}
+	for _, node := range cv.DataNodes {
+		s.dataNodes = append(s.dataNodes, fmt.Sprintf("http://%v/opStats", util.ProfAddr(node.Addr, dataProf)))
+	}
+	for _, url := range append(s.metaNodes, s.dataNodes...) {
+		if s.before[url], err = getOpStats(url); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Print writes the sum of the op counters of the metanodes and the datanodes since the
+// counters were taken.
+func (s *ServerStats) Print(w io.Writer) (err error) {
+	for _, group := range []struct {
+		name string
+		urls []string
+	}{{"metanode", s.metaNodes}, {"datanode", s.dataNodes}} {
+		sum := make(map[string]*metrics.OpStat)
+		for _, url := range group.urls {
+			var after []metrics.OpStat
+			if after, err = getOpStats(url); err != nil {
+				return
+			}
+			for _, stat := range metrics.Sub(after, s.before[url]) {
+				total, ok := sum[stat.Op]
+				if !ok {
+					total = &metrics.OpStat{Op: stat.Op}
+					sum[stat.Op] = total
+				}
+				total.Count += stat.Count
+				total.Errors += stat.Errors
+				total.TotalTime += stat.TotalTime
+				if stat.MaxLatency > total.MaxLatency {
+					total.MaxLatency = stat.MaxLatency
+				}
+			}
+		}
+		ops := make([]string, 0, len(sum))
+		for op := range sum {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		fmt.Fprintf(w, "%-32s %10s %8s %10s\n", group.name+" OP", "COUNT", "ERRORS", "AVG(us)")
+		for _, op := range ops {
+			stat := sum[op]
+			fmt.Fprintf(w, "%-32s %10d %8d %10d\n", op, stat.Count, stat.Errors, micros(stat.AvgLatency()))
+		}
+	}
+	return
+}
+
+func getOpStats(url string) (stats []metrics.OpStat, err error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	body := &struct {
+		Code int              `json:"code"`
+		Msg  string           `json:"msg"`
+		Data []metrics.OpStat `json:"data"`
+	}{}
+	if err = json.Unmarshal(data, body); err != nil {
+		return
+	}
+	if body.Code != http.StatusOK {
+		return nil, fmt.Errorf("%v: code(%v) msg(%v)", url, body.Code, body.Msg)
+	}
+	return body.Data, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// histogramSubBits is the bits of the linear sub-buckets of each power of two microseconds, the
+// 64 sub-buckets keep the percentiles within 1.6%.
+const (
+	histogramSubBits = 6
+	histogramSubs    = 1 << histogramSubBits
+)
+
+// histogram counts the latencies in the buckets, so the memory of a long workload is bounded by
+// the range of the latencies instead of the count of the operations.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	max    time.Duration
+}
+
+func histogramBucket(us uint64) int {
+	if us < histogramSubs {
+		return int(us)
+	}
+	shift := bits.Len64(us) - histogramSubBits - 1
+	return (shift+1)*histogramSubs + int(us>>uint(shift)) - histogramSubs
+}
+
+// histogramBound returns the least latency in microseconds of the bucket.
+func histogramBound(bucket int) uint64 {
+	if bucket < histogramSubs {
+		return uint64(bucket)
+	}
+	shift := uint(bucket/histogramSubs - 1)
+	return uint64(bucket%histogramSubs+histogramSubs) << shift
+}
+
+func (h *histogram) record(d time.Duration) {
+	b := histogramBucket(uint64(d / time.Microsecond))
+	if b >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, b+1-len(h.counts))...)
+	}
+	h.counts[b]++
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile returns the upper bound of the bucket of the latency at the percentile, which is
+// at most the max.
+func (h *histogram) percentile(p int) time.Duration {
+	rank := (h.count-1)*uint64(p)/100 + 1
+	var seen uint64
+	for b, c := range h.counts {
+		if seen += c; seen >= rank {
+			d := time.Duration(histogramBound(b+1)-1) * time.Microsecond
+			if d > h.max {
+				d = h.max
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+// Recorder records the latency of every operation of a workload.
+type Recorder struct {
+	mu        sync.Mutex
+	latencies map[string]*histogram
+	bytes     map[string]uint64
+	errors    map[string]uint64
+	order     []string
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		latencies: make(map[string]*histogram),
+		bytes:     make(map[string]uint64),
+		errors:    make(map[string]uint64),
+	}
+}
+
+// Record records an operation of the given size started at the given time.
+func (r *Recorder) Record(op string, start time.Time, size int, err error) {
+	elapsed := time.Since(start)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.latencies[op]; !ok {
+		r.order = append(r.order, op)
+		r.latencies[op] = &histogram{}
+	}
+	if err != nil {
+		r.errors[op]++
+		return
+	}
+	r.latencies[op].record(elapsed)
+	r.bytes[op] += uint64(size)
+}
+
+// Result is the summary of an operation.
+type Result struct {
+	Op         string
+	Count      int
+	Errors     uint64
+	Bytes      uint64
+	OpsPerSec  float64
+	MBPerSec   float64
+	Percentile map[int]time.Duration
+	Max        time.Duration
+}
+
+// Percentiles reported in the results.
+var Percentiles = []int{50, 90, 99}
+
+// Results returns the summary of the operations, in the order they were first recorded.
+// The throughput is computed over the given elapsed time of the workload.
+func (r *Recorder) Results(elapsed time.Duration) []*Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]*Result, 0, len(r.order))
+	for _, op := range r.order {
+		h := r.latencies[op]
+		result := &Result{
+			Op:         op,
+			Count:      int(h.count),
+			Errors:     r.errors[op],
+			Bytes:      r.bytes[op],
+			Percentile: make(map[int]time.Duration),
+		}
+		if elapsed > 0 {
+			result.OpsPerSec = float64(result.Count) / elapsed.Seconds()
+			result.MBPerSec = float64(result.Bytes) / elapsed.Seconds() / (1 << 20)
+		}
+		if h.count > 0 {
+			for _, p := range Percentiles {
+				result.Percentile[p] = h.percentile(p)
+			}
+			result.Max = h.max
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// PrintResults writes the results as a table.
+func PrintResults(w io.Writer, results []*Result) {
+	fmt.Fprintf(w, "%-12s %10s %8s %12s %10s %10s %10s %10s %10s\n",
+		"OP", "COUNT", "ERRORS", "OPS/S", "MB/S", "P50(us)", "P90(us)", "P99(us)", "MAX(us)")
+	for _, r := range results {
+		fmt.Fprintf(w, "%-12s %10d %8d %12.1f %10.2f %10d %10d %10d %10d\n",
+			r.Op, r.Count, r.Errors, r.OpsPerSec, r.MBPerSec,
+			micros(r.Percentile[50]), micros(r.Percentile[90]), micros(r.Percentile[99]), micros(r.Max))
+	}
+}
+
+func micros(d time.Duration) int64 {
+	return d.Nanoseconds() / 1000
+}
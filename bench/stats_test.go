@@ -0,0 +1,73 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		r.Record(OpWrite, now.Add(-time.Duration(i)*time.Millisecond), 4096, nil)
+	}
+	r.Record(OpWrite, now, 4096, errors.New("failed"))
+	r.Record(OpRead, now, 4096, errors.New("failed"))
+
+	results := r.Results(time.Second)
+	if len(results) != 2 || results[0].Op != OpWrite || results[1].Op != OpRead {
+		t.Fatalf("unexpected results %v", results)
+	}
+	w := results[0]
+	if w.Count != 100 || w.Errors != 1 || w.Bytes != 100*4096 || w.OpsPerSec != 100 {
+		t.Fatalf("unexpected write result %+v", w)
+	}
+	if w.Percentile[50] < 50*time.Millisecond || w.Percentile[50] >= 52*time.Millisecond {
+		t.Fatalf("unexpected p50 %v", w.Percentile[50])
+	}
+	if w.Percentile[99] < 99*time.Millisecond || w.Max < 100*time.Millisecond {
+		t.Fatalf("unexpected p99 %v max %v", w.Percentile[99], w.Max)
+	}
+	if rd := results[1]; rd.Count != 0 || rd.Errors != 1 || rd.Max != 0 {
+		t.Fatalf("unexpected read result %+v", rd)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	// the buckets are contiguous and each covers its bound
+	for us := uint64(0); us < 1<<20; us++ {
+		b := histogramBucket(us)
+		if histogramBound(b) > us || histogramBound(b+1) <= us {
+			t.Fatalf("%vus in bucket %v of [%v, %v)", us, b, histogramBound(b), histogramBound(b+1))
+		}
+	}
+
+	h := &histogram{}
+	for i := 1; i <= 1000000; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+	if len(h.counts) > 1024 || h.count != 1000000 || h.max != time.Second {
+		t.Fatalf("%v buckets count %v max %v", len(h.counts), h.count, h.max)
+	}
+	for _, p := range []int{1, 50, 90, 99, 100} {
+		exact := time.Duration(p*10000) * time.Microsecond
+		if d := h.percentile(p); d < exact || float64(d-exact) > float64(exact)/histogramSubs {
+			t.Fatalf("p%v %v, expected %v within 1/%v", p, d, exact, histogramSubs)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+)
+
+// Bench ops
+const (
+	OpMkdir   = "mkdir"
+	OpCreate  = "create"
+	OpStat    = "stat"
+	OpReadDir = "readdir"
+	OpRemove  = "remove"
+	OpRmdir   = "rmdir"
+	OpWrite   = "write"
+	OpRead    = "read"
+)
+
+// Workload runs a benchmark in a working directory of the volume.
+type Workload interface {
+	Run(r *Recorder) error
+}
+
+type benchEnv struct {
+	mw      *meta.MetaWrapper
+	ec      *stream.ExtentClient
+	workDir uint64
+	threads int
+}
+
+// mkdirs creates a directory for each thread under the working directory.
+func (env *benchEnv) mkdirs(r *Recorder, prefix string) (dirs []uint64, err error) {
+	dirs = make([]uint64, env.threads)
+	for i := range dirs {
+		start := time.Now()
+		info, e := env.mw.Create_ll(env.workDir, fmt.Sprintf("%v.%v", prefix, i), proto.Mode(os.ModeDir|0755), 0, 0, nil)
+		r.Record(OpMkdir, start, 0, e)
+		if e != nil {
+			return nil, e
+		}
+		dirs[i] = info.Inode
+	}
+	return
+}
+
+func (env *benchEnv) rmdirs(r *Recorder, prefix string, dirs []uint64) {
+	for i := range dirs {
+		start := time.Now()
+		_, err := env.mw.Delete_ll(env.workDir, fmt.Sprintf("%v.%v", prefix, i), true)
+		r.Record(OpRmdir, start, 0, err)
+	}
+}
+
+// parallel runs f for each thread and waits for all of them.
+func (env *benchEnv) parallel(f func(thread int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < env.threads; i++ {
+		wg.Add(1)
+		go func(thread int) {
+			defer wg.Done()
+			f(thread)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// MetaWorkload is an mdtest like workload, each thread creates, stats, lists and removes
+// files in its own directory, phase by phase.
+type MetaWorkload struct {
+	*benchEnv
+	files int
+}
+
+// Run runs the workload.
+func (w *MetaWorkload) Run(r *Recorder) (err error) {
+	dirs, err := w.mkdirs(r, "meta")
+	if err != nil {
+		return
+	}
+	name := func(i int) string { return fmt.Sprintf("file.%v", i) }
+	inodes := make([][]uint64, w.threads)
+	w.parallel(func(thread int) {
+		inodes[thread] = make([]uint64, 0, w.files)
+		for i := 0; i < w.files; i++ {
+			start := time.Now()
+			info, err := w.mw.Create_ll(dirs[thread], name(i), proto.Mode(0644), 0, 0, nil)
+			r.Record(OpCreate, start, 0, err)
+			if err == nil {
+				inodes[thread] = append(inodes[thread], info.Inode)
+			}
+		}
+	})
+	w.parallel(func(thread int) {
+		for _, ino := range inodes[thread] {
+			start := time.Now()
+			_, err := w.mw.InodeGet_ll(ino)
+			r.Record(OpStat, start, 0, err)
+		}
+	})
+	w.parallel(func(thread int) {
+		start := time.Now()
+		_, err := w.mw.ReadDir_ll(dirs[thread])
+		r.Record(OpReadDir, start, 0, err)
+	})
+	w.parallel(func(thread int) {
+		for i := 0; i < w.files; i++ {
+			start := time.Now()
+			info, err := w.mw.Delete_ll(dirs[thread], name(i), false)
+			r.Record(OpRemove, start, 0, err)
+			if err == nil && info != nil {
+				w.mw.Evict(info.Inode)
+			}
+		}
+	})
+	w.rmdirs(r, "meta", dirs)
+	return
+}
+
+// DataWorkload writes a file in each thread with the given block size and reads it back,
+// sequentially for the large IO or at random offsets for the small IO.
+type DataWorkload struct {
+	*benchEnv
+	fileSize  int
+	blockSize int
+	random    bool
+	keep      bool
+}
+
+// Run runs the workload.
+func (w *DataWorkload) Run(r *Recorder) (err error) {
+	dirs, err := w.mkdirs(r, "data")
+	if err != nil {
+		return
+	}
+	blocks := w.fileSize / w.blockSize
+	w.parallel(func(thread int) {
+		start := time.Now()
+		info, err := w.mw.Create_ll(dirs[thread], "file", proto.Mode(0644), 0, 0, nil)
+		r.Record(OpCreate, start, 0, err)
+		if err != nil {
+			return
+		}
+		ino := info.Inode
+		if err = w.ec.OpenStream(ino); err != nil {
+			return
+		}
+		defer w.ec.CloseStream(ino)
+
+		data := make([]byte, w.blockSize)
+		rand.Read(data)
+		for _, off := range w.offsets(blocks) {
+			start := time.Now()
+			n, err := w.ec.Write(ino, off, data, false)
+			r.Record(OpWrite, start, n, err)
+		}
+		if err = w.ec.Flush(ino); err != nil {
+			return
+		}
+		for _, off := range w.offsets(blocks) {
+			start := time.Now()
+			n, err := w.ec.Read(ino, data, off, w.blockSize)
+			r.Record(OpRead, start, n, err)
+		}
+	})
+	if w.keep {
+		return
+	}
+	w.parallel(func(thread int) {
+		start := time.Now()
+		info, err := w.mw.Delete_ll(dirs[thread], "file", false)
+		r.Record(OpRemove, start, 0, err)
+		if err == nil && info != nil {
+			w.ec.EvictStream(info.Inode)
+			w.mw.Evict(info.Inode)
+		}
+	})
+	w.rmdirs(r, "data", dirs)
+	return
+}
+
+func (w *DataWorkload) offsets(blocks int) []int {
+	offsets := make([]int, blocks)
+	for i := range offsets {
+		offsets[i] = i * w.blockSize
+	}
+	if w.random {
+		rand.Shuffle(len(offsets), func(i, j int) { offsets[i], offsets[j] = offsets[j], offsets[i] })
+	}
+	return offsets
+}
+
+// ensureWorkDir returns the inode of the working directory under the root, creating it if needed.
+func ensureWorkDir(mw *meta.MetaWrapper, name string) (ino uint64, err error) {
+	ino, _, err = mw.Lookup_ll(proto.RootIno, name)
+	if err == nil {
+		return
+	}
+	info, err := mw.Create_ll(proto.RootIno, name, proto.Mode(os.ModeDir|0755), 0, 0, nil)
+	if err != nil {
+		return
+	}
+	return info.Inode, nil
+}
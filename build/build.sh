@@ -11,6 +11,7 @@ CommitID=$(git rev-parse HEAD)
 BuildTime=$(date +%Y-%m-%d\ %H:%M)
 LDFlags="-X main.CommitID=${CommitID} -X main.BranchName=${BranchName} -X 'main.BuildTime=${BuildTime}'"
 MODFLAGS=""
+BuildTags=${BUILD_TAGS:-""}
 
 NPROC=$(nproc 2>/dev/null)
 NPROC=${NPROC:-"1"}
@@ -102,7 +103,7 @@ build_server() {
     pre_build
     pushd $SrcPath >/dev/null
     echo -n "build cfs-server "
-    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-server ${SrcPath}/cmd/*.go && echo "success" || echo "failed"
+    go build $MODFLAGS -tags "${BuildTags}" -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-server ${SrcPath}/cmd/*.go && echo "success" || echo "failed"
     popd >/dev/null
 }
 
@@ -130,6 +131,62 @@ build_authtool() {
     popd >/dev/null
 }
 
+build_fsck() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-fsck "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-fsck ${SrcPath}/fsck/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_recovery() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-recovery "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-recovery ${SrcPath}/recovery/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_backup() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-backup "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-backup ${SrcPath}/backup/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_walmigrate() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-walmigrate "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-walmigrate ${SrcPath}/walmigrate/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_metatool() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-metatool "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-metatool ${SrcPath}/metatool/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_bench() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-bench "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-bench ${SrcPath}/bench/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_cli() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-cli "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-cli ${SrcPath}/cli/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
 clean() {
     rm -rf ${BuildBinPath}
 }
@@ -161,6 +218,27 @@ case "$cmd" in
     "authtool")
         build_authtool
         ;;
+    "fsck")
+        build_fsck
+        ;;
+    "cli")
+        build_cli
+        ;;
+    "bench")
+        build_bench
+        ;;
+    "metatool")
+        build_metatool
+        ;;
+    "recovery")
+        build_recovery
+        ;;
+    "backup")
+        build_backup
+        ;;
+    "walmigrate")
+        build_walmigrate
+        ;;
     "clean")
         clean
         ;;
@@ -0,0 +1,295 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The xattrs of the root inode keeping the bucket ACL and policy, the same as the objectnode.
+const (
+	xattrKeyOSSACL    = "oss:acl"
+	xattrKeyOSSPolicy = "oss:ply"
+)
+
+// Sources of the access
+const (
+	AccessOwner  = "owner"
+	AccessKey    = "accessKey"
+	AccessACL    = "acl"
+	AccessPolicy = "policy"
+)
+
+const (
+	fullControl      = "FULL_CONTROL"
+	s3ResourcePrefix = "arn:aws:s3:::"
+)
+
+func newVolumeAccessCmd() *Command {
+	cmd := &Command{
+		Name: "access",
+		Args: "<vol> [prefix]",
+		Short: "audit the owner, the access keys, the bucket ACL grants and the bucket policy statements " +
+			"giving access to the volume, or to the keys under the prefix",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume, to list the access keys")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return ErrUsage
+		}
+		vol, prefix := args[0], argAt(args, 1)
+		view, err := ctx.MasterClient().ClientAPI().GetVolumeWithoutAuthKey(vol)
+		if err != nil {
+			return err
+		}
+		mw, err := ctx.MetaWrapper(vol)
+		if err != nil {
+			return err
+		}
+		info, err := mw.XAttrsList_ll(proto.RootIno)
+		if err != nil {
+			return fmt.Errorf("list xattrs of the root: %v", err)
+		}
+		var keys []*proto.VolAccessKey
+		if *authKey != "" {
+			if keys, err = ctx.MasterClient().AdminAPI().ListVolumeAccessKeys(vol, *authKey); err != nil {
+				return fmt.Errorf("list access keys: %v", err)
+			}
+		}
+		report, err := AuditAccess(view, keys, *authKey != "", info.XAttrs, prefix)
+		if err != nil {
+			return err
+		}
+		return ctx.Print(report, func(w io.Writer) { report.Print(w) })
+	}
+	return cmd
+}
+
+// AccessEntry is a principal given or denied access by a source.
+type AccessEntry struct {
+	Source      string   `json:"source"`
+	Principals  []string `json:"principals"`
+	Effect      string   `json:"effect"`
+	Permissions []string `json:"permissions"`
+	Resources   []string `json:"resources,omitempty"`
+	// Conditional is set if the policy statement has conditions, which are not evaluated.
+	Conditional bool `json:"conditional,omitempty"`
+}
+
+// AccessReport is the access to a volume or to the keys under a prefix of it.
+type AccessReport struct {
+	Volume  string         `json:"volume"`
+	Prefix  string         `json:"prefix"`
+	Entries []*AccessEntry `json:"entries"`
+	// KeysUnlisted is set if the access keys besides the one of the owner are not listed, which
+	// needs the auth key of the owner.
+	KeysUnlisted bool `json:"keysUnlisted,omitempty"`
+}
+
+// AuditAccess collects the access from the volume view, the access keys of the volume if listed
+// and the xattrs of its root inode. The access keys and the policy statements are listed if
+// their prefixes or resources may cover a key under the prefix, the expired keys are not.
+func AuditAccess(view *proto.VolView, keys []*proto.VolAccessKey, keysListed bool, xattrs map[string]string,
+	prefix string) (report *AccessReport, err error) {
+	report = &AccessReport{Volume: view.Name, Prefix: prefix, KeysUnlisted: !keysListed}
+	allow := func(source string, principal string) {
+		report.Entries = append(report.Entries, &AccessEntry{
+			Source:      source,
+			Principals:  []string{principal},
+			Effect:      "Allow",
+			Permissions: []string{fullControl},
+		})
+	}
+	allow(AccessOwner, view.Owner)
+	if view.OSSSecure != nil && view.OSSSecure.AccessKey != "" {
+		allow(AccessKey, view.OSSSecure.AccessKey)
+	}
+	now := time.Now().Unix()
+	for _, key := range keys {
+		if key.Expired(now) || !prefixesMayCover(key.Prefixes, prefix) {
+			continue
+		}
+		report.Entries = append(report.Entries, &AccessEntry{
+			Source:      AccessKey,
+			Principals:  []string{key.AccessKey},
+			Effect:      "Allow",
+			Permissions: []string{key.Permission},
+			Resources:   key.Prefixes,
+		})
+	}
+
+	if data := xattrs[xattrKeyOSSACL]; data != "" {
+		acl := &struct {
+			Grants []struct {
+				Grantee struct {
+					ID          string `xml:"ID"`
+					URI         string `xml:"URI"`
+					DisplayName string `xml:"DisplayName"`
+				} `xml:"Grantee"`
+				Permission string `xml:"Permission"`
+			} `xml:"AccessControlList>Grant"`
+		}{}
+		if err = xml.Unmarshal([]byte(data), acl); err != nil {
+			return nil, fmt.Errorf("parse bucket acl: %v", err)
+		}
+		for _, grant := range acl.Grants {
+			principal := grant.Grantee.ID
+			if principal == "" {
+				principal = grant.Grantee.URI
+			}
+			if principal == "" {
+				principal = grant.Grantee.DisplayName
+			}
+			report.Entries = append(report.Entries, &AccessEntry{
+				Source:      AccessACL,
+				Principals:  []string{principal},
+				Effect:      "Allow",
+				Permissions: []string{grant.Permission},
+			})
+		}
+	}
+
+	if data := xattrs[xattrKeyOSSPolicy]; data != "" {
+		policy := &struct {
+			Statements []*policyStatement `json:"Statement"`
+		}{}
+		if err = json.Unmarshal([]byte(data), policy); err != nil {
+			return nil, fmt.Errorf("parse bucket policy: %v", err)
+		}
+		target := view.Name + "/" + prefix
+		for _, s := range policy.Statements {
+			if !s.mayCover(target) {
+				continue
+			}
+			entry := &AccessEntry{
+				Source:      AccessPolicy,
+				Principals:  s.principals(),
+				Effect:      s.Effect,
+				Permissions: s.Action,
+				Resources:   s.Resource,
+				Conditional: len(s.Condition) > 0 && string(s.Condition) != "null",
+			}
+			for _, action := range s.NotAction {
+				entry.Permissions = append(entry.Permissions, "NOT "+action)
+			}
+			for _, resource := range s.NotResource {
+				entry.Resources = append(entry.Resources, "NOT "+resource)
+			}
+			report.Entries = append(report.Entries, entry)
+		}
+	}
+	return report, nil
+}
+
+// policyStatement is a statement of the bucket policy, in which the values may be either a
+// string or a list of strings.
+type policyStatement struct {
+	Effect      string          `json:"Effect"`
+	Principal   json.RawMessage `json:"Principal"`
+	Action      stringList      `json:"Action"`
+	NotAction   stringList      `json:"NotAction"`
+	Resource    stringList      `json:"Resource"`
+	NotResource stringList      `json:"NotResource"`
+	Condition   json.RawMessage `json:"Condition"`
+}
+
+func (s *policyStatement) principals() []string {
+	var principal string
+	if json.Unmarshal(s.Principal, &principal) == nil {
+		return []string{principal}
+	}
+	typed := make(map[string]stringList)
+	json.Unmarshal(s.Principal, &typed)
+	principals := make([]string, 0)
+	for _, values := range typed {
+		principals = append(principals, values...)
+	}
+	sort.Strings(principals)
+	return principals
+}
+
+// mayCover returns true if the statement may apply to the bucket or to a key under the target,
+// which is the bucket name followed by a slash and the prefix.
+func (s *policyStatement) mayCover(target string) bool {
+	if len(s.Resource) == 0 {
+		return true
+	}
+	bucket := target[:strings.Index(target, "/")]
+	for _, resource := range s.Resource {
+		pattern := strings.TrimPrefix(resource, s3ResourcePrefix)
+		if pattern == bucket {
+			return true
+		}
+		literal := pattern
+		if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+			literal = pattern[:i]
+		}
+		if strings.HasPrefix(target, literal) || strings.HasPrefix(literal, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixesMayCover returns true if a key under the prefix may be under one of the prefixes,
+// or there are no prefixes at all.
+func prefixesMayCover(prefixes []string, prefix string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(prefix, p) || strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = []string{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// Print writes the entries as a table.
+func (r *AccessReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "volume: %v\nprefix: %v\n", r.Volume, r.Prefix)
+	if r.KeysUnlisted {
+		fmt.Fprintf(w, "the access keys besides the one of the owner are not listed without -authKey\n")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%-10s %-6s %-40s %-32s %v\n", "SOURCE", "EFFECT", "PRINCIPALS", "PERMISSIONS", "RESOURCES")
+	for _, e := range r.Entries {
+		resources := strings.Join(e.Resources, ",")
+		if e.Conditional {
+			resources += " (conditional)"
+		}
+		fmt.Fprintf(w, "%-10s %-6s %-40s %-32s %v\n", e.Source, e.Effect, strings.Join(e.Principals, ","),
+			strings.Join(e.Permissions, ","), resources)
+	}
+}
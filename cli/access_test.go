@@ -0,0 +1,82 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+const testACL = `<AccessControlPolicy xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+<Owner><ID>owner</ID><DisplayName>owner</DisplayName></Owner>
+<AccessControlList>
+<Grant><Grantee xsi:type="Group"><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee><Permission>READ</Permission></Grant>
+<Grant><Grantee xsi:type="CanonicalUser"><ID>alice</ID></Grantee><Permission>WRITE</Permission></Grant>
+</AccessControlList>
+</AccessControlPolicy>`
+
+const testPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::vol/public/*"},
+    {"Effect": "Deny", "Principal": {"AWS": ["bob", "carol"]}, "Action": ["s3:PutObject", "s3:DeleteObject"],
+     "Resource": ["arn:aws:s3:::vol/logs/*"], "Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}},
+    {"Effect": "Allow", "Principal": {"AWS": "dave"}, "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::vol"}
+  ]
+}`
+
+func TestAuditAccess(t *testing.T) {
+	view := &proto.VolView{Name: "vol", Owner: "owner"}
+	view.SetOSSSecure("AK", "SK")
+	xattrs := map[string]string{xattrKeyOSSACL: testACL, xattrKeyOSSPolicy: testPolicy}
+	keys := []*proto.VolAccessKey{
+		{AccessKey: "AK1", Permission: proto.AccessKeyReadWrite},
+		{AccessKey: "AK2", Permission: proto.AccessKeyReadOnly, Prefixes: []string{"public/"}},
+		{AccessKey: "AK3", Permission: proto.AccessKeyReadWrite, ExpireTime: 1},
+	}
+
+	report, err := AuditAccess(view, keys, true, xattrs, "logs/2019")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var principals [][]string
+	for _, e := range report.Entries {
+		principals = append(principals, e.Principals)
+	}
+	expected := [][]string{
+		{"owner"}, {"AK"}, {"AK1"},
+		{"http://acs.amazonaws.com/groups/global/AllUsers"}, {"alice"},
+		{"bob", "carol"}, {"dave"},
+	}
+	if !reflect.DeepEqual(principals, expected) {
+		t.Fatalf("unexpected principals %v", principals)
+	}
+	if key := report.Entries[2]; key.Source != AccessKey || key.Permissions[0] != proto.AccessKeyReadWrite {
+		t.Fatalf("unexpected entry %+v", key)
+	}
+	deny := report.Entries[5]
+	if deny.Effect != "Deny" || !deny.Conditional || len(deny.Permissions) != 2 {
+		t.Fatalf("unexpected entry %+v", deny)
+	}
+
+	if report, err = AuditAccess(view, keys, true, xattrs, ""); err != nil || len(report.Entries) != 9 {
+		t.Fatalf("all the unexpired keys and the statements cover the volume, got %v %v", len(report.Entries), err)
+	}
+	if report, err = AuditAccess(view, nil, false, xattrs, ""); err != nil || !report.KeysUnlisted {
+		t.Fatalf("keys unlisted without the auth key, got %+v %v", report, err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func newAccessKeyCmd() *Command {
+	cmd := &Command{Name: "accesskey", Short: "manage the access keys of the volumes besides the ones of the owners"}
+	cmd.AddCommand(
+		newAccessKeyCreateCmd(),
+		newAccessKeyListCmd(),
+		newAccessKeyRevokeCmd(),
+	)
+	return cmd
+}
+
+func newAccessKeyCreateCmd() *Command {
+	cmd := &Command{Name: "create", Args: "<vol>", Short: "create an access key of the volume"}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	readOnly := cmd.Flags().Bool("readOnly", false, "only allow the access key to read")
+	prefixes := cmd.Flags().String("prefixes", "", "the comma separated prefixes of the object keys the access key is scoped to")
+	expire := cmd.Flags().Duration("expire", 0, "expire the access key after this duration, never if 0")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		permission := proto.AccessKeyReadWrite
+		if *readOnly {
+			permission = proto.AccessKeyReadOnly
+		}
+		var scope []string
+		if *prefixes != "" {
+			scope = strings.Split(*prefixes, ",")
+		}
+		key, err := ctx.MasterClient().AdminAPI().CreateVolumeAccessKey(args[0], *authKey, permission, scope,
+			int64(expire.Seconds()))
+		if err != nil {
+			return err
+		}
+		return ctx.Print(key, func(w io.Writer) {
+			fmt.Fprintf(w, "access key: %v\nsecret key: %v\n", key.AccessKey, key.SecretKey)
+		})
+	}
+	return cmd
+}
+
+func newAccessKeyListCmd() *Command {
+	cmd := &Command{Name: "list", Args: "<vol>", Short: "list the access keys of the volume"}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		keys, err := ctx.MasterClient().AdminAPI().ListVolumeAccessKeys(args[0], *authKey)
+		if err != nil {
+			return err
+		}
+		return ctx.Print(keys, func(w io.Writer) {
+			fmt.Fprintf(w, "%-18v %-10v %-20v %-20v %v\n", "ACCESS KEY", "PERMISSION", "CREATED", "EXPIRES", "PREFIXES")
+			for _, key := range keys {
+				expires := "never"
+				if key.ExpireTime > 0 {
+					expires = formatTime(time.Unix(key.ExpireTime, 0))
+				}
+				fmt.Fprintf(w, "%-18v %-10v %-20v %-20v %v\n", key.AccessKey, key.Permission,
+					formatTime(time.Unix(key.CreateTime, 0)), expires, strings.Join(key.Prefixes, ","))
+			}
+		})
+	}
+	return cmd
+}
+
+func newAccessKeyRevokeCmd() *Command {
+	cmd := &Command{Name: "revoke", Args: "<vol> <access key>", Short: "revoke the access key of the volume"}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	grace := cmd.Flags().Duration("grace", 0, "keep the access key valid for this duration to rotate it, revoked at once if 0")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		return ctx.MasterClient().AdminAPI().RevokeVolumeAccessKey(args[0], *authKey, args[1], int64(grace.Seconds()))
+	}
+	return cmd
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func newClusterBalanceCmd() *Command {
+	cmd := &Command{Name: "balance", Short: "preview, pause and resume the moves of the data partitions by the balancer"}
+	cmd.AddCommand(
+		newClusterBalancePlanCmd(),
+		newClusterBalancePauseCmd(true),
+		newClusterBalancePauseCmd(false),
+	)
+	return cmd
+}
+
+func newClusterBalancePlanCmd() *Command {
+	cmd := &Command{
+		Name:  "plan",
+		Short: "preview the moves of the replicas the balancer plans for the next round",
+	}
+	limit := cmd.Flags().Int("limit", 0, "plan at most this number of the moves regardless of the partitions recovering")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 0 || *limit < 0 {
+			return ErrUsage
+		}
+		view, err := ctx.MasterClient().AdminAPI().GetBalancePlan(*limit)
+		if err != nil {
+			return fmt.Errorf("get balance plan: %v", err)
+		}
+		return ctx.Print(view, func(w io.Writer) {
+			fmt.Fprintf(w, "paused: %v, recovering: %v, mean usage: %.2f, mean partitions: %.1f\n",
+				view.Paused, view.Recovering, view.MeanUsage, view.MeanCount)
+			fmt.Fprintf(w, "%-12v %-16v %-22v %-22v %-12v %v\n", "PARTITION", "VOL", "SOURCE", "TARGET", "SIZE", "REASON")
+			for _, move := range view.Moves {
+				fmt.Fprintf(w, "%-12v %-16v %-22v %-22v %-12v %v\n", move.PartitionID, move.VolName,
+					move.Source, move.Target, move.Size, move.Reason)
+			}
+		})
+	}
+	return cmd
+}
+
+func newClusterBalancePauseCmd(pause bool) *Command {
+	cmd := &Command{Name: "resume", Short: "resume the balancer"}
+	if pause {
+		cmd = &Command{Name: "pause", Short: "pause the balancer, the moves in progress are not stopped"}
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 0 {
+			return ErrUsage
+		}
+		if pause {
+			return ctx.MasterClient().AdminAPI().PauseBalance()
+		}
+		return ctx.MasterClient().AdminAPI().ResumeBalance()
+	}
+	return cmd
+}
@@ -0,0 +1,399 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/health"
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+// Node types
+const (
+	NodeMeta = "metanode"
+	NodeData = "datanode"
+)
+
+const opStatsPathMeta, opStatsPathData = "/getOpStats", "/opStats"
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+func newClusterCmd() *Command {
+	cmd := &Command{Name: "cluster", Short: "inspect the cluster"}
+	cmd.AddCommand(
+		newClusterReportCmd(),
+		newClusterRotateKeyCmd(),
+		newClusterBalanceCmd(),
+	)
+	return cmd
+}
+
+func newClusterRotateKeyCmd() *Command {
+	cmd := &Command{
+		Name:  "rotate-key",
+		Short: "create a new encryption key for the data keys of the encrypted objects, keeping the old keys",
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 0 {
+			return ErrUsage
+		}
+		return ctx.MasterClient().AdminAPI().RotateEncryptionKey()
+	}
+	return cmd
+}
+
+func newClusterReportCmd() *Command {
+	cmd := &Command{
+		Name: "report",
+		Short: "collect the capacity, the health of the nodes and the abnormal partitions of the " +
+			"cluster into one report",
+	}
+	metaProf := cmd.Flags().String("metaProf", "9092", "prof port of the metanodes")
+	dataProf := cmd.Flags().String("dataProf", "17320", "prof port of the datanodes")
+	slowFactor := cmd.Flags().Float64("slowFactor", 3, "a node is slow if its average op latency is this times the median of its type")
+	baseline := cmd.Flags().String("baseline", "", "JSON report of an earlier run, to report the capacity changes since then")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) > 0 || *slowFactor <= 1 {
+			return ErrUsage
+		}
+		var prev *ClusterReport
+		if *baseline != "" {
+			var err error
+			if prev, err = loadReport(*baseline); err != nil {
+				return fmt.Errorf("load baseline %v: %v", *baseline, err)
+			}
+		}
+		cv, err := ctx.MasterClient().AdminAPI().GetCluster()
+		if err != nil {
+			return err
+		}
+		report := NewClusterReport(cv, time.Now())
+		report.probeNodes(*metaProf, *dataProf)
+		report.markSlowNodes(*slowFactor)
+		if prev != nil {
+			report.Trend = capacityTrend(prev, report)
+		}
+		report.summarize()
+		return ctx.Print(report, func(w io.Writer) { report.Print(w) })
+	}
+	return cmd
+}
+
+// ClusterReport is the summary of the cluster for the daily review of the operators.
+type ClusterReport struct {
+	Cluster string    `json:"cluster"`
+	Leader  string    `json:"leader"`
+	Time    time.Time `json:"time"`
+	// Problems are the lines worth a look of the operators, empty if the cluster is healthy.
+	Problems []string       `json:"problems"`
+	Capacity *Capacity      `json:"capacity"`
+	Trend    *CapacityTrend `json:"trend,omitempty"`
+	Nodes    []*NodeHealth  `json:"nodes"`
+	// BadDataPartitions and BadMetaPartitions are the partitions reported unavailable by the
+	// master, grouped by the disks or the nodes.
+	BadDataPartitions []proto.BadPartitionView `json:"badDataPartitions"`
+	BadMetaPartitions []proto.BadPartitionView `json:"badMetaPartitions"`
+}
+
+// Capacity is the space of the datanodes, the memory of the metanodes and the usage of the volumes.
+type Capacity struct {
+	Data    *proto.NodeStatInfo  `json:"data"`
+	Meta    *proto.NodeStatInfo  `json:"meta"`
+	Volumes []*proto.VolStatInfo `json:"volumes"`
+}
+
+// CapacityTrend is the change of the capacity since the baseline report.
+type CapacityTrend struct {
+	Since      time.Time `json:"since"`
+	DataUsedGB int64     `json:"dataUsedGB"`
+	MetaUsedGB int64     `json:"metaUsedGB"`
+	// Volumes maps the volume names to the change of the used size in bytes.
+	Volumes map[string]int64 `json:"volumes"`
+}
+
+// NodeHealth is the health of a metanode or a datanode.
+type NodeHealth struct {
+	Type     string `json:"type"`
+	Addr     string `json:"addr"`
+	Active   bool   `json:"active"`
+	Writable bool   `json:"writable"`
+	// Ready is the readiness status of the node, or the error probing it.
+	Ready  string            `json:"ready"`
+	Checks map[string]string `json:"checks,omitempty"`
+	// AvgLatencyUs is the average latency of all the operations served since the node started.
+	AvgLatencyUs int64  `json:"avgLatencyUs"`
+	Ops          uint64 `json:"ops"`
+	Slow         bool   `json:"slow"`
+}
+
+// NewClusterReport returns the report of the cluster view, without probing the nodes.
+func NewClusterReport(cv *proto.ClusterView, now time.Time) *ClusterReport {
+	report := &ClusterReport{
+		Cluster: cv.Name,
+		Leader:  cv.LeaderAddr,
+		Time:    now,
+		Capacity: &Capacity{
+			Data:    cv.DataNodeStatInfo,
+			Meta:    cv.MetaNodeStatInfo,
+			Volumes: cv.VolStatInfo,
+		},
+		Nodes:             make([]*NodeHealth, 0, len(cv.MetaNodes)+len(cv.DataNodes)),
+		BadDataPartitions: cv.BadPartitionIDs,
+		BadMetaPartitions: cv.BadMetaPartitionIDs,
+	}
+	sort.Slice(report.Capacity.Volumes, func(i, j int) bool {
+		return report.Capacity.Volumes[i].Name < report.Capacity.Volumes[j].Name
+	})
+	for _, group := range []struct {
+		typ   string
+		nodes []proto.NodeView
+	}{{NodeMeta, cv.MetaNodes}, {NodeData, cv.DataNodes}} {
+		for _, node := range group.nodes {
+			report.Nodes = append(report.Nodes, &NodeHealth{
+				Type:     group.typ,
+				Addr:     node.Addr,
+				Active:   node.Status,
+				Writable: node.IsWritable,
+			})
+		}
+	}
+	return report
+}
+
+func loadReport(path string) (report *ClusterReport, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	report = &ClusterReport{}
+	err = json.Unmarshal(data, report)
+	return
+}
+
+// probeNodes fetches the readiness and the op counters of the nodes from their prof ports.
+func (r *ClusterReport) probeNodes(metaProf, dataProf string) {
+	var wg sync.WaitGroup
+	for _, node := range r.Nodes {
+		port, path := metaProf, opStatsPathMeta
+		if node.Type == NodeData {
+			port, path = dataProf, opStatsPathData
+		}
+		wg.Add(1)
+		go func(node *NodeHealth, base, path string) {
+			defer wg.Done()
+			result, err := getReadiness(base + health.ReadinessPath)
+			if err != nil {
+				node.Ready = err.Error()
+				return
+			}
+			node.Ready, node.Checks = result.Status, result.Checks
+			stats, err := getOpStats(base + path)
+			if err != nil {
+				return
+			}
+			var total metrics.OpStat
+			for _, stat := range stats {
+				total.Count += stat.Count
+				total.TotalTime += stat.TotalTime
+			}
+			node.Ops = total.Count
+			node.AvgLatencyUs = int64(total.AvgLatency() / time.Microsecond)
+		}(node, "http://"+util.ProfAddr(node.Addr, port), path)
+	}
+	wg.Wait()
+}
+
+// markSlowNodes marks the nodes whose average latency is factor times the median of the
+// nodes of the same type, if there are at least three nodes of the type serving operations.
+func (r *ClusterReport) markSlowNodes(factor float64) {
+	for _, typ := range []string{NodeMeta, NodeData} {
+		latencies := make([]int64, 0)
+		for _, node := range r.Nodes {
+			if node.Type == typ && node.Ops > 0 {
+				latencies = append(latencies, node.AvgLatencyUs)
+			}
+		}
+		if len(latencies) < 3 {
+			continue
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		median := latencies[len(latencies)/2]
+		for _, node := range r.Nodes {
+			if node.Type == typ && node.Ops > 0 && float64(node.AvgLatencyUs) > factor*float64(median) {
+				node.Slow = true
+			}
+		}
+	}
+}
+
+func capacityTrend(prev, cur *ClusterReport) *CapacityTrend {
+	trend := &CapacityTrend{Since: prev.Time, Volumes: make(map[string]int64)}
+	if prev.Capacity == nil || cur.Capacity == nil {
+		return trend
+	}
+	if prev.Capacity.Data != nil && cur.Capacity.Data != nil {
+		trend.DataUsedGB = int64(cur.Capacity.Data.UsedGB) - int64(prev.Capacity.Data.UsedGB)
+	}
+	if prev.Capacity.Meta != nil && cur.Capacity.Meta != nil {
+		trend.MetaUsedGB = int64(cur.Capacity.Meta.UsedGB) - int64(prev.Capacity.Meta.UsedGB)
+	}
+	used := make(map[string]uint64)
+	for _, vol := range prev.Capacity.Volumes {
+		used[vol.Name] = vol.UsedSize
+	}
+	for _, vol := range cur.Capacity.Volumes {
+		trend.Volumes[vol.Name] = int64(vol.UsedSize) - int64(used[vol.Name])
+	}
+	return trend
+}
+
+// summarize collects the problems found in the report.
+func (r *ClusterReport) summarize() {
+	r.Problems = make([]string, 0)
+	for _, node := range r.Nodes {
+		switch {
+		case !node.Active:
+			r.Problems = append(r.Problems, fmt.Sprintf("%v %v is inactive", node.Type, node.Addr))
+		case node.Ready != health.StatusOK:
+			r.Problems = append(r.Problems, fmt.Sprintf("%v %v is not ready: %v", node.Type, node.Addr, node.readiness()))
+		case !node.Writable:
+			r.Problems = append(r.Problems, fmt.Sprintf("%v %v is not writable", node.Type, node.Addr))
+		}
+		if node.Slow {
+			r.Problems = append(r.Problems, fmt.Sprintf("%v %v is slow, average latency %vus",
+				node.Type, node.Addr, node.AvgLatencyUs))
+		}
+	}
+	for _, bad := range r.BadDataPartitions {
+		r.Problems = append(r.Problems, fmt.Sprintf("%v bad data partitions on %v", len(bad.PartitionIDs), bad.Path))
+	}
+	for _, bad := range r.BadMetaPartitions {
+		r.Problems = append(r.Problems, fmt.Sprintf("%v bad meta partitions on %v", len(bad.PartitionIDs), bad.Path))
+	}
+}
+
+func (n *NodeHealth) readiness() string {
+	if len(n.Checks) == 0 {
+		return n.Ready
+	}
+	failed := make(map[string]string)
+	for name, status := range n.Checks {
+		if status != health.StatusOK {
+			failed[name] = status
+		}
+	}
+	return fmt.Sprint(failed)
+}
+
+// Print writes the report for the operators.
+func (r *ClusterReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "cluster: %v\nleader: %v\ntime: %v\n\n", r.Cluster, r.Leader, formatTime(r.Time))
+
+	fmt.Fprintf(w, "%-10s %10s %10s %12s %8s\n", "CAPACITY", "TOTAL(GB)", "USED(GB)", "INCREASED", "RATIO")
+	for _, c := range []struct {
+		name string
+		info *proto.NodeStatInfo
+	}{{"data", r.Capacity.Data}, {"meta", r.Capacity.Meta}} {
+		if c.info != nil {
+			fmt.Fprintf(w, "%-10s %10d %10d %12d %8s\n", c.name, c.info.TotalGB, c.info.UsedGB, c.info.IncreasedGB, c.info.UsedRatio)
+		}
+	}
+	if r.Trend != nil {
+		fmt.Fprintf(w, "since %v: data %+dGB, meta %+dGB\n", formatTime(r.Trend.Since), r.Trend.DataUsedGB, r.Trend.MetaUsedGB)
+	}
+
+	fmt.Fprintf(w, "\n%-32s %16s %16s %8s", "VOLUME", "TOTAL", "USED", "RATIO")
+	if r.Trend != nil {
+		fmt.Fprintf(w, " %16s", "CHANGE")
+	}
+	fmt.Fprintln(w)
+	for _, vol := range r.Capacity.Volumes {
+		fmt.Fprintf(w, "%-32s %16d %16d %8s", vol.Name, vol.TotalSize, vol.UsedSize, vol.UsedRatio)
+		if r.Trend != nil {
+			fmt.Fprintf(w, " %+16d", r.Trend.Volumes[vol.Name])
+		}
+		fmt.Fprintln(w)
+	}
+
+	counts := make(map[string][2]int)
+	for _, node := range r.Nodes {
+		c := counts[node.Type]
+		c[0]++
+		if node.Active && node.Ready == health.StatusOK {
+			c[1]++
+		}
+		counts[node.Type] = c
+	}
+	fmt.Fprintf(w, "\nmetanodes: %v/%v healthy, datanodes: %v/%v healthy\n",
+		counts[NodeMeta][1], counts[NodeMeta][0], counts[NodeData][1], counts[NodeData][0])
+
+	if len(r.Problems) == 0 {
+		fmt.Fprintln(w, "no problems found")
+		return
+	}
+	fmt.Fprintf(w, "\n%v problems:\n", len(r.Problems))
+	for _, problem := range r.Problems {
+		fmt.Fprintf(w, "  %v\n", problem)
+	}
+}
+
+func getReadiness(url string) (result *health.Result, err error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	result = &health.Result{}
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("%v: %v", url, err)
+	}
+	return
+}
+
+func getOpStats(url string) (stats []metrics.OpStat, err error) {
+	err = getNodeData(url, &stats)
+	return
+}
+
+// getNodeData gets the data of the reply from the HTTP API of a metanode or a datanode.
+func getNodeData(url string, data interface{}) (err error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body := &struct {
+		Code int         `json:"code"`
+		Msg  string      `json:"msg"`
+		Data interface{} `json:"data"`
+	}{Data: data}
+	if err = json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return fmt.Errorf("%v: %v", url, err)
+	}
+	if body.Code != http.StatusOK {
+		return fmt.Errorf("%v: code(%v) msg(%v)", url, body.Code, body.Msg)
+	}
+	return
+}
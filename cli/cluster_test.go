@@ -0,0 +1,89 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/health"
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+func TestClusterReport(t *testing.T) {
+	mux := http.NewServeMux()
+	checker := health.NewChecker()
+	mux.HandleFunc(health.ReadinessPath, checker.ReadinessHandler)
+	mux.HandleFunc(opStatsPathData, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": http.StatusOK,
+			"data": []metrics.OpStat{{Op: "write", Count: 10, TotalTime: 10 * time.Millisecond}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	cv := &proto.ClusterView{
+		Name:             "test",
+		DataNodeStatInfo: &proto.NodeStatInfo{TotalGB: 100, UsedGB: 40},
+		VolStatInfo:      []*proto.VolStatInfo{{Name: "b", UsedSize: 30}, {Name: "a", UsedSize: 10}},
+		DataNodes: []proto.NodeView{
+			{Addr: "127.0.0.1:17310", Status: true, IsWritable: true},
+			{Addr: "127.0.0.1:17311", Status: false},
+		},
+		BadPartitionIDs: []proto.BadPartitionView{{Path: "127.0.0.1:17311:/data0", PartitionIDs: []uint64{1, 2}}},
+	}
+	report := NewClusterReport(cv, time.Now())
+	report.probeNodes("0", port)
+	report.summarize()
+	if node := report.Nodes[0]; node.Ready != health.StatusOK || node.Ops != 10 || node.AvgLatencyUs != 1000 {
+		t.Fatalf("unexpected node %+v", node)
+	}
+	if report.Capacity.Volumes[0].Name != "a" || len(report.Problems) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	prev := &ClusterReport{
+		Time: report.Time.Add(-24 * time.Hour),
+		Capacity: &Capacity{
+			Data:    &proto.NodeStatInfo{UsedGB: 45},
+			Volumes: []*proto.VolStatInfo{{Name: "a", UsedSize: 4}},
+		},
+	}
+	trend := capacityTrend(prev, report)
+	if trend.DataUsedGB != -5 || trend.Volumes["a"] != 6 || trend.Volumes["b"] != 30 {
+		t.Fatalf("unexpected trend %+v", trend)
+	}
+}
+
+func TestMarkSlowNodes(t *testing.T) {
+	report := &ClusterReport{}
+	for _, latency := range []int64{100, 120, 110, 500} {
+		report.Nodes = append(report.Nodes, &NodeHealth{Type: NodeData, Ops: 1, AvgLatencyUs: latency})
+	}
+	report.Nodes = append(report.Nodes, &NodeHealth{Type: NodeMeta, Ops: 1, AvgLatencyUs: 1000})
+	report.markSlowNodes(3)
+	for i, node := range report.Nodes {
+		if node.Slow != (i == 3) {
+			t.Fatalf("node %v: unexpected slow %v", i, node.Slow)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+)
+
+// ErrUsage is returned by a command if its arguments are invalid, the usage of the command
+// is printed for it.
+var ErrUsage = errors.New("invalid arguments")
+
+// Command is a node of the command tree, either a group of the sub commands or a command
+// which runs with the arguments left after its flags.
+type Command struct {
+	Name  string
+	Args  string
+	Short string
+	Run   func(ctx *Context, args []string) error
+
+	subs  []*Command
+	flags *flag.FlagSet
+}
+
+// AddCommand adds the sub commands.
+func (c *Command) AddCommand(subs ...*Command) {
+	c.subs = append(c.subs, subs...)
+	sort.Slice(c.subs, func(i, j int) bool { return c.subs[i].Name < c.subs[j].Name })
+}
+
+// Flags returns the flags of the command.
+func (c *Command) Flags() *flag.FlagSet {
+	if c.flags == nil {
+		c.flags = flag.NewFlagSet(c.Name, flag.ContinueOnError)
+		c.flags.SetOutput(ioutil.Discard)
+	}
+	return c.flags
+}
+
+func (c *Command) find(name string) *Command {
+	for _, sub := range c.subs {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Execute finds the command of the arguments under c, parses its flags and runs it.
+func (c *Command) Execute(ctx *Context, args []string) error {
+	cmd, path := c, []string{}
+	for len(args) > 0 && len(cmd.subs) > 0 {
+		sub := cmd.find(args[0])
+		if sub == nil {
+			break
+		}
+		cmd, path, args = sub, append(path, sub.Name), args[1:]
+	}
+	if cmd.Run == nil {
+		if len(args) > 0 {
+			fmt.Fprintf(ctx.Err, "unknown command %q\n", strings.Join(append(path, args[0]), " "))
+		}
+		cmd.usage(ctx.Err, path)
+		return ErrUsage
+	}
+	if err := cmd.Flags().Parse(args); err != nil {
+		fmt.Fprintln(ctx.Err, err)
+		cmd.usage(ctx.Err, path)
+		return ErrUsage
+	}
+	args = cmd.Flags().Args()
+	if ctx.Volume != "" && strings.HasPrefix(cmd.Args, "<vol>") {
+		args = append([]string{ctx.Volume}, args...)
+	}
+	err := cmd.Run(ctx, args)
+	if err == ErrUsage {
+		cmd.usage(ctx.Err, path)
+	}
+	return err
+}
+
+func (c *Command) usage(w io.Writer, path []string) {
+	if c.Run != nil {
+		fmt.Fprintf(w, "usage: %v %v", ProgramName, strings.Join(path, " "))
+		if c.flags != nil {
+			fmt.Fprint(w, " [flags]")
+		}
+		fmt.Fprintf(w, " %v\n\n%v\n", c.Args, c.Short)
+		if c.flags != nil {
+			fmt.Fprintln(w, "\nflags:")
+			c.flags.SetOutput(w)
+			c.flags.PrintDefaults()
+			c.flags.SetOutput(ioutil.Discard)
+		}
+		return
+	}
+	fmt.Fprintf(w, "usage: %v %v <command>\n\ncommands:\n", ProgramName, strings.Join(path, " "))
+	for _, sub := range c.subs {
+		fmt.Fprintf(w, "  %-16s %v\n", sub.Name, sub.Short)
+	}
+}
+
+// Context keeps the connections shared by the commands.
+type Context struct {
+	Master string
+	Output string
+	// Volume is the current volume of the interactive shell, passed as the <vol> argument
+	// of the commands.
+	Volume string
+	Out    io.Writer
+	Err    io.Writer
+
+	mc       *masterSDK.MasterClient
+	wrappers map[string]*meta.MetaWrapper
+}
+
+// MasterClient returns the client of the master.
+func (ctx *Context) MasterClient() *masterSDK.MasterClient {
+	if ctx.mc == nil {
+		ctx.mc = masterSDK.NewMasterClient(strings.Split(ctx.Master, ","), false)
+	}
+	return ctx.mc
+}
+
+// MetaWrapper returns the meta wrapper of the volume, without validating the owner.
+func (ctx *Context) MetaWrapper(vol string) (mw *meta.MetaWrapper, err error) {
+	if mw = ctx.wrappers[vol]; mw != nil {
+		return
+	}
+	opt := &proto.MountOptions{Volname: vol, Master: ctx.Master}
+	if mw, err = meta.NewMetaWrapper(opt, false); err != nil {
+		return
+	}
+	if ctx.wrappers == nil {
+		ctx.wrappers = make(map[string]*meta.MetaWrapper)
+	}
+	ctx.wrappers[vol] = mw
+	return
+}
+
+// UseMaster closes the connections and switches to the cluster of the master addresses.
+func (ctx *Context) UseMaster(master string) {
+	ctx.Close()
+	ctx.Master = master
+	ctx.mc = nil
+}
+
+// Close closes the connections.
+func (ctx *Context) Close() {
+	for _, mw := range ctx.wrappers {
+		mw.Close()
+	}
+	ctx.wrappers = nil
+}
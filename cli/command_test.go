@@ -0,0 +1,83 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestExecute(t *testing.T) {
+	var got []string
+	var verbose *bool
+	leaf := &Command{Name: "leaf", Args: "<arg>", Short: "a leaf"}
+	verbose = leaf.Flags().Bool("v", false, "verbose")
+	leaf.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		got = args
+		return nil
+	}
+	group := &Command{Name: "group", Short: "a group"}
+	group.AddCommand(leaf)
+	root := &Command{Name: ProgramName}
+	root.AddCommand(group)
+
+	var stderr bytes.Buffer
+	ctx := &Context{Out: &bytes.Buffer{}, Err: &stderr}
+	if err := root.Execute(ctx, []string{"group", "leaf", "-v", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose || len(got) != 1 || got[0] != "x" {
+		t.Fatalf("unexpected flags(%v) args(%v)", *verbose, got)
+	}
+
+	for _, args := range [][]string{
+		{"group"},
+		{"group", "unknown"},
+		{"group", "leaf"},
+		{"group", "leaf", "-unknown", "x"},
+	} {
+		stderr.Reset()
+		if err := root.Execute(ctx, args); err != ErrUsage {
+			t.Fatalf("%v: expected ErrUsage, got %v", args, err)
+		}
+		if !strings.Contains(stderr.String(), "usage: "+ProgramName+" group") {
+			t.Fatalf("%v: usage not printed: %v", args, stderr.String())
+		}
+	}
+}
+
+func TestFilterUploads(t *testing.T) {
+	now := time.Now()
+	uploads := []*proto.MultipartInfo{
+		{ID: "1", Path: "a/1", InitTime: now.Add(-time.Hour)},
+		{ID: "2", Path: "a/2", InitTime: now.Add(-3 * time.Hour), Parts: []*proto.MultipartPartInfo{{Size: 5}, {Size: 7}}},
+		{ID: "3", Path: "b/3", InitTime: now.Add(-5 * time.Hour)},
+	}
+	matches := filterUploads(uploads, "a/", 2*time.Hour, now)
+	if len(matches) != 1 || matches[0].ID != "2" || uploadSize(matches[0]) != 12 {
+		t.Fatalf("unexpected matches %v", matches)
+	}
+	matches = filterUploads(uploads, "", 0, now)
+	if len(matches) != 3 || matches[0].ID != "3" || matches[2].ID != "1" {
+		t.Fatalf("uploads should be sorted by the initiated time: %v", matches)
+	}
+}
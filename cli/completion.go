@@ -0,0 +1,104 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const bashCompletionHead = `# bash completion of %[1]v, generated by "%[1]v completion bash"
+
+_cfs_cli_words() {
+    case "$1" in
+`
+
+const bashCompletionTail = `    esac
+}
+
+_cfs_cli() {
+    local cur word path i
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    path=""
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ "$word" != -* && " $(_cfs_cli_words "$path") " == *" $word "* ]]; then
+            path="${path:+$path }$word"
+        fi
+    done
+    COMPREPLY=($(compgen -W "$(_cfs_cli_words "$path")" -- "$cur"))
+}
+
+complete -F _cfs_cli %[1]v
+`
+
+// zsh runs the bash completion through bashcompinit.
+const zshCompletionHead = `#compdef %v
+
+autoload -U +X bashcompinit && bashcompinit
+
+`
+
+func newCompletionCmd() *Command {
+	cmd := &Command{
+		Name:  "completion",
+		Args:  "bash|zsh",
+		Short: "print the shell completion script, e.g. source <(cfs-cli -master x completion bash)",
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		switch args[0] {
+		case "bash":
+		case "zsh":
+			fmt.Fprintf(ctx.Out, zshCompletionHead, ProgramName)
+		default:
+			return ErrUsage
+		}
+		writeBashCompletion(ctx.Out, newRootCmd(), flag.CommandLine)
+		return nil
+	}
+	return cmd
+}
+
+// writeBashCompletion writes the completion of the command tree, the words completed after
+// a path of the commands are the sub commands of a group or the flags of a command.
+func writeBashCompletion(w io.Writer, root *Command, global *flag.FlagSet) {
+	fmt.Fprintf(w, bashCompletionHead, ProgramName)
+	var visit func(cmd *Command, path []string)
+	visit = func(cmd *Command, path []string) {
+		var words []string
+		for _, sub := range cmd.subs {
+			words = append(words, sub.Name)
+		}
+		if cmd.flags != nil {
+			cmd.flags.VisitAll(func(f *flag.Flag) { words = append(words, "-"+f.Name) })
+		}
+		if len(path) == 0 && global != nil {
+			global.VisitAll(func(f *flag.Flag) { words = append(words, "-"+f.Name) })
+		}
+		if len(words) > 0 {
+			fmt.Fprintf(w, "        %q) echo %q ;;\n", strings.Join(path, " "), strings.Join(words, " "))
+		}
+		for _, sub := range cmd.subs {
+			visit(sub, append(path, sub.Name))
+		}
+	}
+	visit(root, nil)
+	fmt.Fprintf(w, bashCompletionTail, ProgramName)
+}
@@ -0,0 +1,216 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+)
+
+func newCopyCmd() *Command {
+	cmd := &Command{
+		Name:  "cp",
+		Args:  "<src vol> <src path> <dst vol> <dst path>",
+		Short: "copy the files between the paths or the volumes by the data nodes",
+	}
+	recursive := cmd.Flags().Bool("r", false, "copy the directories recursively")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 4 {
+			return ErrUsage
+		}
+		c := &volumeCopier{ctx: ctx, recursive: *recursive}
+		defer c.close()
+		if err := c.copy(args[0], args[1], args[2], args[3]); err != nil {
+			return err
+		}
+		return ctx.Print(&c.progress, func(w io.Writer) {
+			fmt.Fprintf(w, "copied %v files, %v directories and %v bytes, skipped %v\n",
+				c.progress.Files, c.progress.Dirs, c.progress.Bytes, c.progress.Skipped)
+		})
+	}
+	return cmd
+}
+
+// CopyProgress is the result of a copy.
+type CopyProgress struct {
+	Files   int
+	Dirs    int
+	Bytes   uint64
+	Skipped int // the inodes neither a regular file, a directory nor a symlink
+}
+
+// copyVolume is the meta wrapper and the extent client of a volume of the copy.
+type copyVolume struct {
+	name string
+	mw   *meta.MetaWrapper
+	ec   *stream.ExtentClient
+}
+
+// volumeCopier copies the files of the source volume to the destination volume, whose data is
+// copied by the data nodes from each other instead of through the cli.
+type volumeCopier struct {
+	ctx       *Context
+	recursive bool
+	src       *copyVolume
+	dst       *copyVolume
+	ecs       []*stream.ExtentClient
+	progress  CopyProgress
+}
+
+func (c *volumeCopier) copy(srcVol, srcPath, dstVol, dstPath string) (err error) {
+	if c.src, err = c.volume(srcVol); err != nil {
+		return
+	}
+	if c.dst, err = c.volume(dstVol); err != nil {
+		return
+	}
+	srcIno, err := lookupPath(c.src.mw, srcPath)
+	if err != nil {
+		return fmt.Errorf("lookup %v: %v", srcPath, err)
+	}
+	info, err := c.src.mw.InodeGet_ll(srcIno)
+	if err != nil {
+		return fmt.Errorf("get inode of %v: %v", srcPath, err)
+	}
+	if proto.IsDir(info.Mode) && !c.recursive {
+		return fmt.Errorf("%v is a directory, copy it with -r", srcPath)
+	}
+	parentID, name, err := c.target(path.Clean("/"+srcPath), path.Clean("/"+dstPath))
+	if err != nil {
+		return
+	}
+	return c.copyInode(info, srcPath, parentID, name)
+}
+
+// volume returns the meta wrapper and the extent client of the volume, the extent client is
+// shared by a copy in the same volume.
+func (c *volumeCopier) volume(vol string) (v *copyVolume, err error) {
+	if c.src != nil && c.src.name == vol {
+		return c.src, nil
+	}
+	v = &copyVolume{name: vol}
+	if v.mw, err = c.ctx.MetaWrapper(vol); err != nil {
+		return
+	}
+	opt := &proto.MountOptions{Volname: vol, Master: c.ctx.Master}
+	if v.ec, err = stream.NewExtentClient(opt, v.mw.AppendExtentKey, v.mw.GetExtents, v.mw.Truncate); err != nil {
+		return nil, fmt.Errorf("create extent client of %v: %v", vol, err)
+	}
+	c.ecs = append(c.ecs, v.ec)
+	return
+}
+
+// target returns the parent and the name of the copy, which is put under the destination
+// if it is a directory like cp does.
+func (c *volumeCopier) target(srcPath, dstPath string) (parentID uint64, name string, err error) {
+	ino, err := lookupPath(c.dst.mw, dstPath)
+	switch {
+	case err == syscall.ENOENT:
+		dir, base := path.Split(dstPath)
+		if parentID, err = lookupPath(c.dst.mw, dir); err != nil {
+			return 0, "", fmt.Errorf("lookup %v: %v", dir, err)
+		}
+		return parentID, base, nil
+	case err != nil:
+		return 0, "", fmt.Errorf("lookup %v: %v", dstPath, err)
+	}
+	info, err := c.dst.mw.InodeGet_ll(ino)
+	if err != nil {
+		return 0, "", fmt.Errorf("get inode of %v: %v", dstPath, err)
+	}
+	if !proto.IsDir(info.Mode) || srcPath == "/" {
+		return 0, "", fmt.Errorf("%v exists", dstPath)
+	}
+	return ino, path.Base(srcPath), nil
+}
+
+func (c *volumeCopier) copyInode(info *proto.InodeInfo, srcPath string, parentID uint64, name string) (err error) {
+	switch {
+	case proto.IsDir(info.Mode):
+		return c.copyDir(info, srcPath, parentID, name)
+	case proto.IsSymlink(info.Mode):
+		if _, err = c.dst.mw.Create_ll(parentID, name, info.Mode, info.Uid, info.Gid, info.Target); err != nil {
+			return fmt.Errorf("create symlink of %v: %v", srcPath, err)
+		}
+		c.progress.Files++
+	case proto.IsRegular(info.Mode):
+		return c.copyFile(info, srcPath, parentID, name)
+	default:
+		fmt.Fprintf(c.ctx.Err, "skip %v: not a regular file, a directory or a symlink\n", srcPath)
+		c.progress.Skipped++
+	}
+	return
+}
+
+func (c *volumeCopier) copyDir(info *proto.InodeInfo, srcPath string, parentID uint64, name string) (err error) {
+	dir, err := c.dst.mw.Create_ll(parentID, name, info.Mode, info.Uid, info.Gid, nil)
+	if err != nil {
+		return fmt.Errorf("create directory of %v: %v", srcPath, err)
+	}
+	c.progress.Dirs++
+	children, err := c.src.mw.ReadDir_ll(info.Inode)
+	if err != nil {
+		return fmt.Errorf("read directory %v: %v", srcPath, err)
+	}
+	for _, child := range children {
+		childPath := path.Join(srcPath, child.Name)
+		childInfo, err := c.src.mw.InodeGet_ll(child.Inode)
+		if err != nil {
+			return fmt.Errorf("get inode of %v: %v", childPath, err)
+		}
+		if err = c.copyInode(childInfo, childPath, dir.Inode, child.Name); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// copyFile copies the extents of the file and creates the copy with them, which is linked
+// to the parent at last, so a failed copy leaves no file behind.
+func (c *volumeCopier) copyFile(info *proto.InodeInfo, srcPath string, parentID uint64, name string) (err error) {
+	_, size, extents, err := c.src.mw.GetExtents(info.Inode)
+	if err != nil {
+		return fmt.Errorf("get extents of %v: %v", srcPath, err)
+	}
+	copies, err := c.dst.ec.CopyExtents(c.src.ec, extents)
+	if err != nil {
+		return fmt.Errorf("copy extents of %v: %v", srcPath, err)
+	}
+	file, err := c.dst.mw.InodeCloneExtents_ll(info.Mode, info.Uid, info.Gid, copies)
+	if err != nil {
+		return fmt.Errorf("create file of %v: %v", srcPath, err)
+	}
+	if err = c.dst.mw.DentryCreate_ll(parentID, name, file.Inode, file.Mode); err != nil {
+		if _, e := c.dst.mw.InodeUnlink_ll(file.Inode); e == nil {
+			c.dst.mw.Evict(file.Inode)
+		}
+		return fmt.Errorf("link copy of %v: %v", srcPath, err)
+	}
+	c.progress.Files++
+	c.progress.Bytes += size
+	return
+}
+
+func (c *volumeCopier) close() {
+	for _, ec := range c.ecs {
+		ec.Close()
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/tiglabs/raft"
+)
+
+func newDataPartitionCmd() *Command {
+	cmd := &Command{Name: "datapartition", Short: "inspect the data partitions"}
+	cmd.AddCommand(
+		newDataPartitionDiffCmd(),
+		newDataPartitionInfoCmd(),
+	)
+	return cmd
+}
+
+func newDataPartitionDiffCmd() *Command {
+	cmd := &Command{
+		Name:  "diff",
+		Args:  "<partition id>",
+		Short: "compare the extents, the used size and the raft indexes of the replicas of the data partition",
+	}
+	dataProf := cmd.Flags().String("dataProf", "17320", "prof port of the datanodes")
+	settle := cmd.Flags().Duration("settle", time.Minute, "skip the extents modified within this duration")
+	all := cmd.Flags().Bool("all", false, "list all the extents instead of only the different ones")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return ErrUsage
+		}
+		dp, err := ctx.MasterClient().AdminAPI().GetDataPartition("", id)
+		if err != nil {
+			return err
+		}
+		reports := getReplicaReports(dp.Hosts, id, *dataProf)
+		diff := DiffReplicas(id, dp.Hosts, reports, time.Now().Add(-*settle).Unix(), *all)
+		diff.Volume = dp.VolName
+		return ctx.Print(diff, func(w io.Writer) { diff.Print(w) })
+	}
+	return cmd
+}
+
+func newDataPartitionInfoCmd() *Command {
+	cmd := &Command{
+		Name:  "info",
+		Args:  "<partition id>",
+		Short: "show the replicas of the data partition with their disk usage and raft lag",
+	}
+	dataProf := cmd.Flags().String("dataProf", "17320", "prof port of the datanodes")
+	interval := cmd.Flags().Duration("watch", 0, "refresh the info every interval, e.g. 2s, until interrupted")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return ErrUsage
+		}
+		return watch(ctx, *interval, func() (interface{}, func(w io.Writer), error) {
+			info, err := getDataPartitionInfo(ctx, id, *dataProf)
+			if err != nil {
+				return nil, nil, err
+			}
+			return info, info.Print, nil
+		})
+	}
+	return cmd
+}
+
+func getDataPartitionInfo(ctx *Context, id uint64, dataProf string) (info *PartitionInfo, err error) {
+	dp, err := ctx.MasterClient().AdminAPI().GetDataPartition("", id)
+	if err != nil {
+		return
+	}
+	info = &PartitionInfo{Type: PartitionData, PartitionID: id, Volume: dp.VolName, Status: dp.Status}
+	replicas := make(map[string]*proto.DataReplica, len(dp.Replicas))
+	for _, replica := range dp.Replicas {
+		replicas[replica.Addr] = replica
+	}
+	for _, host := range dp.Hosts {
+		r := &ReplicaInfo{Addr: host}
+		if replica := replicas[host]; replica != nil {
+			r.Leader, r.Status, r.Used, r.Total, r.DiskPath = replica.IsLeader, replica.Status, replica.Used, replica.Total, replica.DiskPath
+		}
+		info.Replicas = append(info.Replicas, r)
+	}
+	info.setRaftStatuses(getRaftStatuses(dp.Hosts, func(host string) (status *raft.Status, err error) {
+		err = getNodeData(fmt.Sprintf("http://%v/raftStatus?raftID=%v", util.ProfAddr(host, dataProf), id), &status)
+		return
+	}))
+	return
+}
+
+// replicaReport is the reply of the partition API of the datanodes.
+type replicaReport struct {
+	VolName    string                `json:"volName"`
+	ID         uint64                `json:"id"`
+	Used       int                   `json:"used"`
+	Status     int                   `json:"status"`
+	Path       string                `json:"path"`
+	Extents    []*storage.ExtentInfo `json:"extents"`
+	RaftStatus *raft.Status          `json:"raftStatus"`
+	err        error
+}
+
+func getReplicaReports(hosts []string, id uint64, dataProf string) map[string]*replicaReport {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	reports := make(map[string]*replicaReport, len(hosts))
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			report := &replicaReport{}
+			report.err = getNodeData(fmt.Sprintf("http://%v/partition?id=%v", util.ProfAddr(host, dataProf), id), report)
+			mu.Lock()
+			reports[host] = report
+			mu.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return reports
+}
+
+// ReplicaSummary is the summary of a replica of a data partition.
+type ReplicaSummary struct {
+	Addr        string `json:"addr"`
+	Error       string `json:"error,omitempty"`
+	Status      int    `json:"status"`
+	Path        string `json:"path"`
+	Used        int    `json:"used"`
+	Extents     int    `json:"extents"`
+	ExtentBytes uint64 `json:"extentBytes"`
+	// CrcSummary is the crc of the ids, sizes and crcs of the settled extents, equal between
+	// the replicas having the same extents.
+	CrcSummary uint32 `json:"crcSummary"`
+	RaftState  string `json:"raftState"`
+	Commit     uint64 `json:"commit"`
+	Applied    uint64 `json:"applied"`
+}
+
+// ExtentDiff is an extent on the replicas, each as "<size>/<crc>", "deleted" or "missing".
+type ExtentDiff struct {
+	Extent   uint64            `json:"extent"`
+	Same     bool              `json:"same"`
+	Replicas map[string]string `json:"replicas"`
+}
+
+// ReplicaDiff is the side by side comparison of the replicas of a data partition.
+type ReplicaDiff struct {
+	PartitionID uint64            `json:"partitionId"`
+	Volume      string            `json:"volume"`
+	Diverged    bool              `json:"diverged"`
+	Replicas    []*ReplicaSummary `json:"replicas"`
+	Extents     []*ExtentDiff     `json:"extents"`
+}
+
+const (
+	extentMissing = "missing"
+	extentDeleted = "deleted"
+)
+
+// DiffReplicas compares the reports of the replicas in the order of the hosts. The extents
+// modified after settled are left out, and only the different extents are listed unless all.
+func DiffReplicas(id uint64, hosts []string, reports map[string]*replicaReport, settled int64, all bool) *ReplicaDiff {
+	diff := &ReplicaDiff{PartitionID: id, Extents: make([]*ExtentDiff, 0)}
+	extents := make(map[uint64]*ExtentDiff)
+	var loaded []string
+	for _, host := range hosts {
+		report := reports[host]
+		summary := &ReplicaSummary{Addr: host}
+		diff.Replicas = append(diff.Replicas, summary)
+		if report == nil {
+			report = &replicaReport{err: fmt.Errorf("no report")}
+		}
+		if report.err != nil {
+			summary.Error = report.err.Error()
+			diff.Diverged = true
+			continue
+		}
+		loaded = append(loaded, host)
+		summary.Status, summary.Path, summary.Used = report.Status, report.Path, report.Used
+		if report.RaftStatus != nil {
+			summary.RaftState, summary.Commit, summary.Applied = report.RaftStatus.State, report.RaftStatus.Commit, report.RaftStatus.Applied
+		}
+		sort.Slice(report.Extents, func(i, j int) bool { return report.Extents[i].FileID < report.Extents[j].FileID })
+		hash := crc32.NewIEEE()
+		for _, ei := range report.Extents {
+			if ei.ModifyTime > settled {
+				continue
+			}
+			value := extentDeleted
+			if !ei.IsDeleted {
+				value = fmt.Sprintf("%v/%v", ei.Size, ei.Crc)
+				summary.Extents++
+				summary.ExtentBytes += ei.Size
+				fmt.Fprintf(hash, "%v:%v\n", ei.FileID, value)
+			}
+			ed, ok := extents[ei.FileID]
+			if !ok {
+				ed = &ExtentDiff{Extent: ei.FileID, Replicas: make(map[string]string)}
+				extents[ei.FileID] = ed
+			}
+			ed.Replicas[host] = value
+		}
+		summary.CrcSummary = hash.Sum32()
+	}
+
+	ids := make([]uint64, 0, len(extents))
+	for id := range extents {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		ed := extents[id]
+		for _, host := range loaded {
+			if _, ok := ed.Replicas[host]; !ok {
+				ed.Replicas[host] = extentMissing
+			}
+		}
+		ed.Same = distinct(ed.Replicas) == 1
+		if !ed.Same {
+			diff.Diverged = true
+		}
+		if all || !ed.Same {
+			diff.Extents = append(diff.Extents, ed)
+		}
+	}
+	return diff
+}
+
+// Print writes the replicas side by side.
+func (d *ReplicaDiff) Print(w io.Writer) {
+	fmt.Fprintf(w, "partition: %v\nvolume: %v\ndiverged: %v\n\n", d.PartitionID, d.Volume, d.Diverged)
+	// the rows expected to be equal between the replicas are marked if they differ
+	row := func(name string, compare bool, value func(r *ReplicaSummary) string) {
+		values := make(map[string]string)
+		line := fmt.Sprintf("%-14s", name)
+		for _, r := range d.Replicas {
+			v := "-"
+			if r.Error == "" {
+				v = value(r)
+				values[r.Addr] = v
+			}
+			line += fmt.Sprintf(" %24s", v)
+		}
+		mark := " "
+		if compare && distinct(values) > 1 {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%v %v\n", mark, line)
+	}
+	row("", false, func(r *ReplicaSummary) string { return r.Addr })
+	row("raft state", false, func(r *ReplicaSummary) string { return r.RaftState })
+	row("status", false, func(r *ReplicaSummary) string { return strconv.Itoa(r.Status) })
+	row("used", true, func(r *ReplicaSummary) string { return strconv.Itoa(r.Used) })
+	row("extents", true, func(r *ReplicaSummary) string { return strconv.Itoa(r.Extents) })
+	row("extent bytes", true, func(r *ReplicaSummary) string { return strconv.FormatUint(r.ExtentBytes, 10) })
+	row("crc summary", true, func(r *ReplicaSummary) string { return fmt.Sprintf("%08x", r.CrcSummary) })
+	row("commit", true, func(r *ReplicaSummary) string { return strconv.FormatUint(r.Commit, 10) })
+	row("applied", true, func(r *ReplicaSummary) string { return strconv.FormatUint(r.Applied, 10) })
+	for _, r := range d.Replicas {
+		if r.Error != "" {
+			fmt.Fprintf(w, "%v: %v\n", r.Addr, r.Error)
+		}
+	}
+	if len(d.Extents) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n  %-14s", "EXTENT")
+	for _, r := range d.Replicas {
+		fmt.Fprintf(w, " %24s", r.Addr)
+	}
+	fmt.Fprintln(w)
+	for _, ed := range d.Extents {
+		mark := "*"
+		if ed.Same {
+			mark = " "
+		}
+		fmt.Fprintf(w, "%v %-14d", mark, ed.Extent)
+		for _, r := range d.Replicas {
+			v, ok := ed.Replicas[r.Addr]
+			if !ok {
+				v = "-"
+			}
+			fmt.Fprintf(w, " %24s", v)
+		}
+		fmt.Fprintln(w)
+	}
+}
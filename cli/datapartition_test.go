@@ -0,0 +1,63 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/tiglabs/raft"
+)
+
+func TestDiffReplicas(t *testing.T) {
+	report := func(extents ...*storage.ExtentInfo) *replicaReport {
+		return &replicaReport{Used: 100, Extents: extents, RaftStatus: &raft.Status{Applied: 10, Commit: 10}}
+	}
+	extent := func(id, size uint64, crc uint32) *storage.ExtentInfo {
+		return &storage.ExtentInfo{FileID: id, Size: size, Crc: crc}
+	}
+	hosts := []string{"a", "b", "c", "d"}
+	reports := map[string]*replicaReport{
+		"a": report(extent(1025, 8, 1), extent(1026, 8, 2)),
+		"b": report(extent(1026, 8, 2), extent(1025, 8, 1)),
+		"c": report(extent(1025, 8, 1), &storage.ExtentInfo{FileID: 1027, Size: 8, ModifyTime: 200}),
+		"d": {err: fmt.Errorf("connection refused")},
+	}
+
+	diff := DiffReplicas(1, hosts, reports, 100, false)
+	if !diff.Diverged || len(diff.Extents) != 1 || diff.Extents[0].Extent != 1026 ||
+		diff.Extents[0].Replicas["c"] != extentMissing {
+		t.Fatalf("unexpected diff %+v", diff.Extents)
+	}
+	a, b, c := diff.Replicas[0], diff.Replicas[1], diff.Replicas[2]
+	if a.CrcSummary != b.CrcSummary || a.CrcSummary == c.CrcSummary || a.Extents != 2 || c.Extents != 1 {
+		t.Fatalf("unexpected summaries %+v %+v %+v", a, b, c)
+	}
+	if diff.Replicas[3].Error == "" {
+		t.Fatalf("the error of replica d is lost")
+	}
+	if diff = DiffReplicas(1, hosts, reports, 100, true); len(diff.Extents) != 2 {
+		t.Fatalf("all the settled extents should be listed, got %+v", diff.Extents)
+	}
+
+	var buf bytes.Buffer
+	diff.Print(&buf)
+	if !strings.Contains(buf.String(), "* extents") || !strings.Contains(buf.String(), "  applied") {
+		t.Fatalf("unexpected output:\n%v", buf.String())
+	}
+}
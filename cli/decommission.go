@@ -0,0 +1,591 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+)
+
+// Decommission target kinds
+const (
+	TargetDataNode = "datanode"
+	TargetMetaNode = "metanode"
+	TargetDisk     = "disk"
+)
+
+// Migration kinds and states
+const (
+	MigrationData = "data"
+	MigrationMeta = "meta"
+
+	StatePending = "pending"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+func newDecommissionCmd() *Command {
+	cmd := &Command{Name: "decommission", Short: "decommission datanodes, metanodes and disks as a batch"}
+	cmd.AddCommand(
+		newDecommissionPlanCmd(),
+		newDecommissionRunCmd(),
+		newDecommissionProgressCmd(),
+		newDecommissionJobCmd(),
+	)
+	return cmd
+}
+
+// addTargetFlags adds the flags of the decommission targets to the command, and returns
+// the function parsing them.
+func addTargetFlags(cmd *Command) func() ([]*DecommissionTarget, error) {
+	dataNodes := cmd.Flags().String("datanodes", "", "datanode addresses separated by comma")
+	metaNodes := cmd.Flags().String("metanodes", "", "metanode addresses separated by comma")
+	disks := cmd.Flags().String("disks", "", "disks separated by comma, each as <datanode address>:<disk path>")
+	return func() ([]*DecommissionTarget, error) {
+		return parseTargets(*dataNodes, *metaNodes, *disks)
+	}
+}
+
+func newDecommissionPlanCmd() *Command {
+	cmd := &Command{
+		Name:  "plan",
+		Short: "preview the partitions migrated by decommissioning the targets",
+	}
+	targets := addTargetFlags(cmd)
+	cmd.Run = func(ctx *Context, args []string) error {
+		ts, err := targets()
+		if err != nil || len(args) > 0 {
+			return ErrUsage
+		}
+		plan, err := BuildPlan(&masterDecommissionAPI{ctx.MasterClient()}, ts)
+		if err != nil {
+			return err
+		}
+		return ctx.Print(plan, func(w io.Writer) { plan.Print(w) })
+	}
+	return cmd
+}
+
+func newDecommissionRunCmd() *Command {
+	cmd := &Command{
+		Name: "run",
+		Short: "migrate the partitions of the targets with limited concurrency, then remove the " +
+			"decommissioned nodes from the cluster",
+	}
+	targets := addTargetFlags(cmd)
+	concurrency := cmd.Flags().Int("concurrency", 2, "number of the partitions migrated at the same time")
+	yes := cmd.Flags().Bool("yes", false, "run the plan instead of only printing it")
+	cmd.Run = func(ctx *Context, args []string) error {
+		ts, err := targets()
+		if err != nil || len(args) > 0 || *concurrency <= 0 {
+			return ErrUsage
+		}
+		api := &masterDecommissionAPI{ctx.MasterClient()}
+		plan, err := BuildPlan(api, ts)
+		if err != nil {
+			return err
+		}
+		if !*yes {
+			return ctx.Print(plan, func(w io.Writer) {
+				plan.Print(w)
+				fmt.Fprintln(w, "run with -yes to execute the plan")
+			})
+		}
+		var progress func(done, total int, m *Migration)
+		if ctx.Output == OutputTable {
+			progress = func(done, total int, m *Migration) {
+				fmt.Fprintf(ctx.Out, "[%v/%v] %v\n", done, total, m)
+			}
+		}
+		plan.Run(api, *concurrency, progress)
+		err = ctx.Print(plan, func(w io.Writer) { plan.printTargets(w) })
+		if err == nil && plan.Failed() {
+			err = fmt.Errorf("decommission is not finished, run it again to retry the failed migrations")
+		}
+		return err
+	}
+	return cmd
+}
+
+func newDecommissionProgressCmd() *Command {
+	cmd := &Command{
+		Name:  "progress",
+		Short: "show the number of the partitions left on the targets",
+	}
+	targets := addTargetFlags(cmd)
+	watch := cmd.Flags().Duration("watch", 0, "refresh the progress with this interval until all the partitions are migrated")
+	cmd.Run = func(ctx *Context, args []string) error {
+		ts, err := targets()
+		if err != nil || len(args) > 0 {
+			return ErrUsage
+		}
+		api := &masterDecommissionAPI{ctx.MasterClient()}
+		for {
+			progress, err := GetProgress(api, ts)
+			if err != nil {
+				return err
+			}
+			if err = ctx.Print(progress, func(w io.Writer) { printProgress(w, progress) }); err != nil {
+				return err
+			}
+			if *watch <= 0 || progress.Finished() {
+				return nil
+			}
+			time.Sleep(*watch)
+		}
+	}
+	return cmd
+}
+
+// DecommissionTarget is a node or a disk to decommission.
+type DecommissionTarget struct {
+	Kind string `json:"kind"`
+	Addr string `json:"addr"`
+	Disk string `json:"disk,omitempty"`
+	// State is set once all the migrations of the target are run.
+	State string `json:"state,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (t *DecommissionTarget) String() string {
+	if t.Kind == TargetDisk {
+		return fmt.Sprintf("%v %v:%v", t.Kind, t.Addr, t.Disk)
+	}
+	return fmt.Sprintf("%v %v", t.Kind, t.Addr)
+}
+
+func parseTargets(dataNodes, metaNodes, disks string) (targets []*DecommissionTarget, err error) {
+	for _, addr := range splitList(dataNodes) {
+		targets = append(targets, &DecommissionTarget{Kind: TargetDataNode, Addr: addr})
+	}
+	for _, addr := range splitList(metaNodes) {
+		targets = append(targets, &DecommissionTarget{Kind: TargetMetaNode, Addr: addr})
+	}
+	for _, disk := range splitList(disks) {
+		// the disk path is absolute while the address has a port
+		i := strings.Index(disk, ":/")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid disk %v", disk)
+		}
+		targets = append(targets, &DecommissionTarget{Kind: TargetDisk, Addr: disk[:i], Disk: disk[i+1:]})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no target")
+	}
+	return
+}
+
+func splitList(s string) (items []string) {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// Migration moves the replica of a partition off a decommissioned node or disk, to a node
+// chosen by the master.
+type Migration struct {
+	Kind      string   `json:"kind"`
+	Partition uint64   `json:"partition"`
+	Vol       string   `json:"vol,omitempty"`
+	From      string   `json:"from"`
+	Hosts     []string `json:"hosts"`
+	State     string   `json:"state"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func (m *Migration) String() string {
+	s := fmt.Sprintf("%v partition %v off %v %v", m.Kind, m.Partition, m.From, m.State)
+	if m.Error != "" {
+		s += ": " + m.Error
+	}
+	return s
+}
+
+// DecommissionPlan is the migrations of a batch of targets.
+type DecommissionPlan struct {
+	Targets    []*DecommissionTarget `json:"targets"`
+	Migrations []*Migration          `json:"migrations"`
+	// Warnings are the partitions which lose the majority of their replicas in the batch.
+	Warnings []string `json:"warnings"`
+}
+
+// DecommissionAPI is the part of the master API used by the decommission commands.
+type DecommissionAPI interface {
+	GetDataNode(addr string) (*proto.DataNodeInfo, error)
+	GetMetaNode(addr string) (*proto.MetaNodeInfo, error)
+	GetDataPartition(id uint64) (*proto.DataPartitionInfo, error)
+	GetMetaPartition(id uint64) (*proto.MetaPartitionInfo, error)
+	DecommissionDataPartition(id uint64, addr string) error
+	DecommissionMetaPartition(id uint64, addr string) error
+	DecommissionDataNode(addr string) error
+	DecommissionMetaNode(addr string) error
+}
+
+type masterDecommissionAPI struct {
+	mc *masterSDK.MasterClient
+}
+
+func (api *masterDecommissionAPI) GetDataNode(addr string) (*proto.DataNodeInfo, error) {
+	return api.mc.NodeAPI().GetDataNode(addr)
+}
+
+func (api *masterDecommissionAPI) GetMetaNode(addr string) (*proto.MetaNodeInfo, error) {
+	return api.mc.NodeAPI().GetMetaNode(addr)
+}
+
+func (api *masterDecommissionAPI) GetDataPartition(id uint64) (*proto.DataPartitionInfo, error) {
+	return api.mc.AdminAPI().GetDataPartition("", id)
+}
+
+func (api *masterDecommissionAPI) GetMetaPartition(id uint64) (*proto.MetaPartitionInfo, error) {
+	return api.mc.ClientAPI().GetMetaPartition(id)
+}
+
+func (api *masterDecommissionAPI) DecommissionDataPartition(id uint64, addr string) error {
+	return api.mc.AdminAPI().DecommissionDataPartition(id, addr)
+}
+
+func (api *masterDecommissionAPI) DecommissionMetaPartition(id uint64, addr string) error {
+	return api.mc.AdminAPI().DecommissionMetaPartition(id, addr)
+}
+
+func (api *masterDecommissionAPI) DecommissionDataNode(addr string) error {
+	return api.mc.NodeAPI().DataNodeDecommission(addr)
+}
+
+func (api *masterDecommissionAPI) DecommissionMetaNode(addr string) error {
+	return api.mc.NodeAPI().MetaNodeDecommission(addr)
+}
+
+// BuildPlan lists the partitions with a replica on the targets.
+func BuildPlan(api DecommissionAPI, targets []*DecommissionTarget) (plan *DecommissionPlan, err error) {
+	plan = &DecommissionPlan{Targets: targets, Migrations: make([]*Migration, 0), Warnings: make([]string, 0)}
+	dps := make(map[uint64]*proto.DataPartitionInfo)
+	mps := make(map[uint64]*proto.MetaPartitionInfo)
+	for _, t := range targets {
+		switch t.Kind {
+		case TargetDataNode, TargetDisk:
+			var node *proto.DataNodeInfo
+			if node, err = api.GetDataNode(t.Addr); err != nil {
+				return nil, fmt.Errorf("get datanode %v: %v", t.Addr, err)
+			}
+			for _, id := range node.PersistenceDataPartitions {
+				dp, ok := dps[id]
+				if !ok {
+					if dp, err = api.GetDataPartition(id); err != nil {
+						return nil, fmt.Errorf("get data partition %v: %v", id, err)
+					}
+					dps[id] = dp
+				}
+				if t.Kind == TargetDisk && !onDisk(dp, t.Addr, t.Disk) {
+					continue
+				}
+				plan.add(&Migration{Kind: MigrationData, Partition: id, Vol: dp.VolName, From: t.Addr, Hosts: dp.Hosts})
+			}
+		case TargetMetaNode:
+			var node *proto.MetaNodeInfo
+			if node, err = api.GetMetaNode(t.Addr); err != nil {
+				return nil, fmt.Errorf("get metanode %v: %v", t.Addr, err)
+			}
+			for _, id := range node.PersistenceMetaPartitions {
+				mp, ok := mps[id]
+				if !ok {
+					if mp, err = api.GetMetaPartition(id); err != nil {
+						return nil, fmt.Errorf("get meta partition %v: %v", id, err)
+					}
+					mps[id] = mp
+				}
+				plan.add(&Migration{Kind: MigrationMeta, Partition: id, From: t.Addr, Hosts: mp.Hosts})
+			}
+		}
+	}
+	plan.check()
+	return
+}
+
+func onDisk(dp *proto.DataPartitionInfo, addr, disk string) bool {
+	for _, replica := range dp.Replicas {
+		if replica.Addr == addr && replica.DiskPath == disk {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds a migration unless the replica is already migrated by the plan, e.g. both its
+// disk and its node are targets.
+func (plan *DecommissionPlan) add(m *Migration) {
+	for _, other := range plan.Migrations {
+		if other.Kind == m.Kind && other.Partition == m.Partition && other.From == m.From {
+			return
+		}
+	}
+	m.State = StatePending
+	plan.Migrations = append(plan.Migrations, m)
+}
+
+// check warns about the partitions whose replicas left are less than the majority.
+func (plan *DecommissionPlan) check() {
+	for _, group := range plan.groups() {
+		m := group[0]
+		hosts := len(m.Hosts)
+		if hosts == 0 {
+			continue
+		}
+		if left := hosts - len(group); left < hosts/2+1 {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"%v partition %v: %v of %v replicas are decommissioned, they are migrated one at a time",
+				m.Kind, m.Partition, len(group), hosts))
+		}
+	}
+}
+
+// groups returns the migrations grouped by partition, the migrations of a partition have to
+// run one at a time as the master refuses to decommission a partition being decommissioned.
+func (plan *DecommissionPlan) groups() [][]*Migration {
+	index := make(map[string]int)
+	var groups [][]*Migration
+	for _, m := range plan.Migrations {
+		key := fmt.Sprintf("%v/%v", m.Kind, m.Partition)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], m)
+	}
+	return groups
+}
+
+// Run runs the pending migrations with the given concurrency, then removes the datanodes and
+// metanodes whose migrations are all done. progress is called after each migration if it is
+// not nil.
+func (plan *DecommissionPlan) Run(api DecommissionAPI, concurrency int, progress func(done, total int, m *Migration)) {
+	var (
+		mu    sync.Mutex
+		done  int
+		wg    sync.WaitGroup
+		total = len(plan.Migrations)
+		ch    = make(chan []*Migration)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range ch {
+				var failed bool
+				for _, m := range group {
+					switch {
+					case failed:
+						m.State, m.Error = StateFailed, "skipped after a failed migration of the partition"
+					case m.State == StatePending:
+						migrate(api, m)
+					}
+					failed = failed || m.State == StateFailed
+					mu.Lock()
+					done++
+					if progress != nil {
+						progress(done, total, m)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, group := range plan.groups() {
+		ch <- group
+	}
+	close(ch)
+	wg.Wait()
+
+	for _, t := range plan.Targets {
+		t.State, t.Error = StateDone, ""
+		for _, m := range plan.Migrations {
+			if m.From == t.Addr && m.State != StateDone && (t.Kind == TargetMetaNode) == (m.Kind == MigrationMeta) {
+				t.State, t.Error = StateFailed, "migrations failed"
+				break
+			}
+		}
+		if t.State != StateDone {
+			continue
+		}
+		var err error
+		switch t.Kind {
+		case TargetDataNode:
+			err = api.DecommissionDataNode(t.Addr)
+		case TargetMetaNode:
+			err = api.DecommissionMetaNode(t.Addr)
+		}
+		if err != nil {
+			t.State, t.Error = StateFailed, err.Error()
+		}
+	}
+}
+
+func migrate(api DecommissionAPI, m *Migration) {
+	var err error
+	if m.Kind == MigrationMeta {
+		err = api.DecommissionMetaPartition(m.Partition, m.From)
+	} else {
+		err = api.DecommissionDataPartition(m.Partition, m.From)
+	}
+	if err != nil {
+		m.State, m.Error = StateFailed, err.Error()
+		return
+	}
+	m.State = StateDone
+}
+
+// Failed reports whether any target is not decommissioned.
+func (plan *DecommissionPlan) Failed() bool {
+	for _, t := range plan.Targets {
+		if t.State != StateDone {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the plan as tables.
+func (plan *DecommissionPlan) Print(w io.Writer) {
+	counts := make(map[*DecommissionTarget]int)
+	for _, t := range plan.Targets {
+		for _, m := range plan.Migrations {
+			if m.From == t.Addr && (t.Kind == TargetMetaNode) == (m.Kind == MigrationMeta) {
+				counts[t]++
+			}
+		}
+	}
+	fmt.Fprintf(w, "%-48s %10s\n", "TARGET", "PARTITIONS")
+	for _, t := range plan.Targets {
+		fmt.Fprintf(w, "%-48s %10d\n", t, counts[t])
+	}
+	fmt.Fprintf(w, "\n%-6s %12s %-20s %-22s %v\n", "KIND", "PARTITION", "VOLUME", "FROM", "HOSTS")
+	for _, m := range plan.Migrations {
+		fmt.Fprintf(w, "%-6s %12d %-20s %-22s %v\n", m.Kind, m.Partition, m.Vol, m.From, strings.Join(m.Hosts, ","))
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(w, "warning: %v\n", warning)
+	}
+}
+
+func (plan *DecommissionPlan) printTargets(w io.Writer) {
+	for _, t := range plan.Targets {
+		fmt.Fprintf(w, "%-48s %v", t, t.State)
+		if t.Error != "" {
+			fmt.Fprintf(w, ": %v", t.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// TargetProgress is the number of the partitions left on a target.
+type TargetProgress struct {
+	*DecommissionTarget
+	Partitions int  `json:"partitions"`
+	Removed    bool `json:"removed"`
+}
+
+// DecommissionProgress is the progress of a batch of targets.
+type DecommissionProgress struct {
+	Time    time.Time         `json:"time"`
+	Targets []*TargetProgress `json:"targets"`
+}
+
+// Finished reports whether all the partitions are migrated off the targets.
+func (p *DecommissionProgress) Finished() bool {
+	for _, t := range p.Targets {
+		if t.Partitions > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetProgress counts the partitions left on the targets, a node removed from the cluster has
+// none left.
+func GetProgress(api DecommissionAPI, targets []*DecommissionTarget) (progress *DecommissionProgress, err error) {
+	progress = &DecommissionProgress{Time: time.Now()}
+	for _, t := range targets {
+		tp := &TargetProgress{DecommissionTarget: t}
+		progress.Targets = append(progress.Targets, tp)
+		switch t.Kind {
+		case TargetDataNode, TargetDisk:
+			node, e := api.GetDataNode(t.Addr)
+			if e != nil {
+				if isNotExists(e) {
+					tp.Removed = true
+					continue
+				}
+				return nil, e
+			}
+			if t.Kind == TargetDataNode {
+				tp.Partitions = len(node.PersistenceDataPartitions)
+				continue
+			}
+			for _, id := range node.PersistenceDataPartitions {
+				var dp *proto.DataPartitionInfo
+				if dp, err = api.GetDataPartition(id); err != nil {
+					return nil, err
+				}
+				if onDisk(dp, t.Addr, t.Disk) {
+					tp.Partitions++
+				}
+			}
+		case TargetMetaNode:
+			node, e := api.GetMetaNode(t.Addr)
+			if e != nil {
+				if isNotExists(e) {
+					tp.Removed = true
+					continue
+				}
+				return nil, e
+			}
+			tp.Partitions = len(node.PersistenceMetaPartitions)
+		}
+	}
+	sort.SliceStable(progress.Targets, func(i, j int) bool {
+		return progress.Targets[i].Partitions > progress.Targets[j].Partitions
+	})
+	return
+}
+
+func isNotExists(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, proto.ErrDataNodeNotExists.Error()) ||
+		strings.Contains(msg, proto.ErrMetaNodeNotExists.Error())
+}
+
+func printProgress(w io.Writer, progress *DecommissionProgress) {
+	fmt.Fprintf(w, "%v\n", progress.Time.Format("2006-01-02 15:04:05"))
+	for _, t := range progress.Targets {
+		state := fmt.Sprintf("%v partitions left", t.Partitions)
+		if t.Removed {
+			state = "removed"
+		}
+		fmt.Fprintf(w, "  %-48s %v\n", t.DecommissionTarget, state)
+	}
+}
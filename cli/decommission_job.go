@@ -0,0 +1,173 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func newDecommissionJobCmd() *Command {
+	cmd := &Command{Name: "job", Short: "drain a datanode or a metanode by a job tracked and run by the master"}
+	cmd.AddCommand(
+		newDecommissionJobStartCmd(),
+		newDecommissionJobListCmd(),
+		newDecommissionJobStatusCmd(),
+		newDecommissionJobUpdateCmd("pause", "stop starting the migrations, the ones in progress go on"),
+		newDecommissionJobUpdateCmd("resume", "resume a paused job, or a failed one retrying the failed partitions"),
+		newDecommissionJobUpdateCmd("cancel", "cancel the job and keep the node in the cluster"),
+	)
+	return cmd
+}
+
+func newDecommissionJobStartCmd() *Command {
+	cmd := &Command{
+		Name:  "start",
+		Args:  "<node addr>",
+		Short: "create a job migrating the partitions off the node, which is removed from the cluster once drained",
+	}
+	concurrency := cmd.Flags().Int("concurrency", 2, "number of the partitions migrated at the same time")
+	rate := cmd.Flags().Int("rate", 0, "MB per second of the data partitions migrated on average, no limit if 0")
+	retries := cmd.Flags().Int("retries", 3, "number of the retries of a partition failed to migrate")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 || *concurrency <= 0 || *rate < 0 || *retries < 0 {
+			return ErrUsage
+		}
+		nodeType, err := getNodeType(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		job, err := ctx.MasterClient().AdminAPI().CreateDecommissionJob(nodeType, args[0], *concurrency, *rate, *retries)
+		if err != nil {
+			return fmt.Errorf("create decommission job of %v: %v", args[0], err)
+		}
+		return ctx.Print(job, func(w io.Writer) { printDecommissionJobs(w, job) })
+	}
+	return cmd
+}
+
+func newDecommissionJobListCmd() *Command {
+	cmd := &Command{Name: "list", Short: "list the decommission jobs and their progress"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 0 {
+			return ErrUsage
+		}
+		jobs, err := ctx.MasterClient().AdminAPI().ListDecommissionJobs()
+		if err != nil {
+			return fmt.Errorf("list decommission jobs: %v", err)
+		}
+		return ctx.Print(jobs, func(w io.Writer) { printDecommissionJobs(w, jobs...) })
+	}
+	return cmd
+}
+
+func newDecommissionJobStatusCmd() *Command {
+	cmd := &Command{
+		Name:  "status",
+		Args:  "<job id>",
+		Short: "show the progress of the job and the partitions not migrated yet",
+	}
+	interval := cmd.Flags().Duration("watch", 0, "refresh the status every interval, e.g. 10s, until interrupted")
+	all := cmd.Flags().Bool("all", false, "show the partitions migrated too")
+	cmd.Run = func(ctx *Context, args []string) error {
+		id, err := parseDecommissionJobID(args)
+		if err != nil {
+			return err
+		}
+		return watch(ctx, *interval, func() (interface{}, func(w io.Writer), error) {
+			job, err := ctx.MasterClient().AdminAPI().GetDecommissionJob(id)
+			if err != nil {
+				return nil, nil, fmt.Errorf("get decommission job %v: %v", id, err)
+			}
+			return job, func(w io.Writer) {
+				printDecommissionJobs(w, job)
+				fmt.Fprintln(w)
+				printDecommissionPartitions(w, job.Partitions, *all)
+			}, nil
+		})
+	}
+	return cmd
+}
+
+func newDecommissionJobUpdateCmd(action, short string) *Command {
+	cmd := &Command{Name: action, Args: "<job id>", Short: short}
+	cmd.Run = func(ctx *Context, args []string) error {
+		id, err := parseDecommissionJobID(args)
+		if err != nil {
+			return err
+		}
+		api := ctx.MasterClient().AdminAPI()
+		switch action {
+		case "pause":
+			err = api.PauseDecommissionJob(id)
+		case "resume":
+			err = api.ResumeDecommissionJob(id)
+		default:
+			err = api.CancelDecommissionJob(id)
+		}
+		if err != nil {
+			return fmt.Errorf("%v decommission job %v: %v", action, id, err)
+		}
+		fmt.Fprintf(ctx.Out, "decommission job %v: %v\n", id, action)
+		return nil
+	}
+	return cmd
+}
+
+func parseDecommissionJobID(args []string) (id uint64, err error) {
+	if len(args) != 1 {
+		return 0, ErrUsage
+	}
+	if id, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+		return 0, ErrUsage
+	}
+	return
+}
+
+// decommissionProgress returns the progress of the job, e.g. "12/40 (30%)".
+func decommissionProgress(job *proto.DecommissionJob) string {
+	if job.Total == 0 {
+		return "0/0"
+	}
+	return fmt.Sprintf("%v/%v (%v%%)", job.Done, job.Total, job.Done*100/job.Total)
+}
+
+func printDecommissionJobs(w io.Writer, jobs ...*proto.DecommissionJob) {
+	fmt.Fprintf(w, "%-8v %-9v %-22v %-10v %-16v %-7v %-12v %-8v %v\n",
+		"ID", "KIND", "ADDR", "STATE", "PROGRESS", "FAILED", "CONCURRENCY", "RATE", "UPDATED")
+	for _, job := range jobs {
+		rate := "-"
+		if job.Rate > 0 {
+			rate = fmt.Sprintf("%vMB/s", job.Rate>>20)
+		}
+		fmt.Fprintf(w, "%-8v %-9v %-22v %-10v %-16v %-7v %-12v %-8v %v\n", job.ID, job.Kind, job.Addr, job.State,
+			decommissionProgress(job), job.Failed, job.Concurrency, rate, formatTime(time.Unix(job.UpdateTime, 0)))
+	}
+}
+
+// printDecommissionPartitions prints the partitions not migrated yet, or all of them.
+func printDecommissionPartitions(w io.Writer, partitions []*proto.DecommissionPartition, all bool) {
+	fmt.Fprintf(w, "%-12v %-16v %-10v %-12v %-8v %v\n", "PARTITION", "VOL", "STATE", "SIZE", "RETRIES", "ERROR")
+	for _, p := range partitions {
+		if !all && p.State == proto.DecommissionDone {
+			continue
+		}
+		fmt.Fprintf(w, "%-12v %-16v %-10v %-12v %-8v %v\n", p.PartitionID, p.VolName, p.State, p.Size, p.Retries, p.Error)
+	}
+}
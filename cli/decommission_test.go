@@ -0,0 +1,197 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+type fakeDecommissionAPI struct {
+	mu        sync.Mutex
+	dataNodes map[string]*proto.DataNodeInfo
+	metaNodes map[string]*proto.MetaNodeInfo
+	dps       map[uint64]*proto.DataPartitionInfo
+	mps       map[uint64]*proto.MetaPartitionInfo
+	failDP    uint64
+	migrated  []string
+	removed   []string
+}
+
+func (api *fakeDecommissionAPI) GetDataNode(addr string) (*proto.DataNodeInfo, error) {
+	if node, ok := api.dataNodes[addr]; ok {
+		return node, nil
+	}
+	return nil, proto.ErrDataNodeNotExists
+}
+
+func (api *fakeDecommissionAPI) GetMetaNode(addr string) (*proto.MetaNodeInfo, error) {
+	if node, ok := api.metaNodes[addr]; ok {
+		return node, nil
+	}
+	return nil, proto.ErrMetaNodeNotExists
+}
+
+func (api *fakeDecommissionAPI) GetDataPartition(id uint64) (*proto.DataPartitionInfo, error) {
+	return api.dps[id], nil
+}
+
+func (api *fakeDecommissionAPI) GetMetaPartition(id uint64) (*proto.MetaPartitionInfo, error) {
+	return api.mps[id], nil
+}
+
+func (api *fakeDecommissionAPI) DecommissionDataPartition(id uint64, addr string) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if id == api.failDP {
+		return fmt.Errorf("no available data node")
+	}
+	api.migrated = append(api.migrated, fmt.Sprintf("dp%v@%v", id, addr))
+	return nil
+}
+
+func (api *fakeDecommissionAPI) DecommissionMetaPartition(id uint64, addr string) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.migrated = append(api.migrated, fmt.Sprintf("mp%v@%v", id, addr))
+	return nil
+}
+
+func (api *fakeDecommissionAPI) DecommissionDataNode(addr string) error {
+	api.removed = append(api.removed, addr)
+	return nil
+}
+
+func (api *fakeDecommissionAPI) DecommissionMetaNode(addr string) error {
+	api.removed = append(api.removed, addr)
+	return nil
+}
+
+func newFakeDecommissionAPI() *fakeDecommissionAPI {
+	replicas := func(disks ...string) (rs []*proto.DataReplica) {
+		for i, disk := range disks {
+			rs = append(rs, &proto.DataReplica{Addr: fmt.Sprintf("d%v", i+1), DiskPath: disk})
+		}
+		return
+	}
+	hosts := []string{"d1", "d2", "d3"}
+	return &fakeDecommissionAPI{
+		dataNodes: map[string]*proto.DataNodeInfo{
+			"d1": {Addr: "d1", PersistenceDataPartitions: []uint64{1, 2}},
+			"d2": {Addr: "d2", PersistenceDataPartitions: []uint64{1, 2}},
+			"d3": {Addr: "d3", PersistenceDataPartitions: []uint64{1, 2}},
+		},
+		metaNodes: map[string]*proto.MetaNodeInfo{
+			"m1": {Addr: "m1", PersistenceMetaPartitions: []uint64{10}},
+		},
+		dps: map[uint64]*proto.DataPartitionInfo{
+			1: {PartitionID: 1, VolName: "vol", Hosts: hosts, Replicas: replicas("/a", "/a", "/a")},
+			2: {PartitionID: 2, VolName: "vol", Hosts: hosts, Replicas: replicas("/b", "/b", "/b")},
+		},
+		mps: map[uint64]*proto.MetaPartitionInfo{
+			10: {PartitionID: 10, Hosts: []string{"m1", "m2", "m3"}},
+		},
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := parseTargets("10.0.0.1:17310", "", "10.0.0.2:17310:/data0, 10.0.0.2:17310:/data1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 || targets[2].Addr != "10.0.0.2:17310" || targets[2].Disk != "/data1" {
+		t.Fatalf("unexpected targets %v", targets)
+	}
+	if _, err = parseTargets("", "", "10.0.0.2:17310"); err == nil {
+		t.Fatalf("disk without path should be invalid")
+	}
+	if _, err = parseTargets("", "", ""); err == nil {
+		t.Fatalf("no target should be invalid")
+	}
+}
+
+func TestDecommissionPlan(t *testing.T) {
+	api := newFakeDecommissionAPI()
+	targets := []*DecommissionTarget{
+		{Kind: TargetDataNode, Addr: "d1"},
+		{Kind: TargetDisk, Addr: "d1", Disk: "/a"},
+		{Kind: TargetDisk, Addr: "d2", Disk: "/a"},
+		{Kind: TargetMetaNode, Addr: "m1"},
+	}
+	plan, err := BuildPlan(api, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// dp1 and dp2 off d1, dp1 off d2, mp10 off m1; the disk of d1 adds nothing
+	if len(plan.Migrations) != 4 {
+		t.Fatalf("unexpected migrations %v", plan.Migrations)
+	}
+	if len(plan.Warnings) != 1 {
+		t.Fatalf("dp1 loses its majority, got warnings %v", plan.Warnings)
+	}
+
+	api.failDP = 2
+	plan.Run(api, 4, nil)
+	if len(api.migrated) != 3 {
+		t.Fatalf("unexpected migrated %v", api.migrated)
+	}
+	for _, target := range plan.Targets {
+		expected := StateDone
+		if target.Addr == "d1" {
+			expected = StateFailed
+		}
+		if target.State != expected {
+			t.Fatalf("target %v: expected %v, got %v", target, expected, target.State)
+		}
+	}
+	if len(api.removed) != 1 || api.removed[0] != "m1" || !plan.Failed() {
+		t.Fatalf("only m1 should be removed, got %v", api.removed)
+	}
+}
+
+func TestDecommissionProgress(t *testing.T) {
+	api := newFakeDecommissionAPI()
+	delete(api.metaNodes, "m1")
+	progress, err := GetProgress(api, []*DecommissionTarget{
+		{Kind: TargetDisk, Addr: "d2", Disk: "/b"},
+		{Kind: TargetMetaNode, Addr: "m1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Targets[0].Partitions != 1 || !progress.Targets[1].Removed || progress.Finished() {
+		t.Fatalf("unexpected progress %+v %+v", progress.Targets[0], progress.Targets[1])
+	}
+}
+
+func TestDecommissionJobArgs(t *testing.T) {
+	if id, err := parseDecommissionJobID([]string{"12"}); err != nil || id != 12 {
+		t.Fatalf("parse: id(%v) err(%v)", id, err)
+	}
+	for _, args := range [][]string{{}, {"x"}, {"12", "13"}} {
+		if _, err := parseDecommissionJobID(args); err != ErrUsage {
+			t.Fatalf("args(%v): err(%v), expect usage", args, err)
+		}
+	}
+	if progress := decommissionProgress(&proto.DecommissionJob{Done: 12, Total: 40}); progress != "12/40 (30%)" {
+		t.Fatalf("progress %v", progress)
+	}
+	if progress := decommissionProgress(&proto.DecommissionJob{}); progress != "0/0" {
+		t.Fatalf("empty progress %v", progress)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+func newDeleteTreeCmd() *Command {
+	cmd := &Command{Name: "deltree", Short: "delete the directory subtrees of the volumes in the background"}
+	cmd.AddCommand(
+		newDeleteTreeStartCmd(),
+		newDeleteTreeListCmd(),
+	)
+	return cmd
+}
+
+func newDeleteTreeStartCmd() *Command {
+	cmd := &Command{
+		Name:  "start",
+		Args:  "<vol> <path>",
+		Short: "detach the directory and delete its subtree by the meta node, bypassing the trash",
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		dir, name, err := splitDirPath(args[1])
+		if err != nil {
+			return err
+		}
+		mw, parentID, err := lookupVolumePath(ctx, args[0], dir)
+		if err != nil {
+			return err
+		}
+		ino, err := mw.DeleteTree_ll(parentID, name)
+		if err != nil {
+			return fmt.Errorf("delete tree %v: %v", args[1], err)
+		}
+		fmt.Fprintf(ctx.Out, "directory %v of inode %v is detached to be deleted\n", args[1], ino)
+		return nil
+	}
+	return cmd
+}
+
+func newDeleteTreeListCmd() *Command {
+	cmd := &Command{Name: "list", Args: "<vol>", Short: "list the subtree deletions of the volume with their progress"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		mw, err := ctx.MetaWrapper(args[0])
+		if err != nil {
+			return err
+		}
+		jobs, err := mw.ListDeleteTrees_ll()
+		if err != nil {
+			return fmt.Errorf("list delete trees of %v: %v", args[0], err)
+		}
+		return ctx.Print(jobs, func(w io.Writer) {
+			fmt.Fprintf(w, "%-20v %-12v %-12v %-10v %-10v %-20v %v\n", "DETACHED", "PARENT", "INODE", "FILES", "DIRS", "NAME", "ERROR")
+			for _, job := range jobs {
+				fmt.Fprintf(w, "%-20v %-12v %-12v %-10v %-10v %-20v %v\n", formatTime(time.Unix(0, job.MarkTime)),
+					job.ParentID, job.Inode, job.DeletedFiles, job.DeletedDirs, job.Name, job.Error)
+			}
+		})
+	}
+	return cmd
+}
+
+// splitDirPath splits the path of the directory into its parent and its name. The root of the
+// volume can not be deleted.
+func splitDirPath(p string) (dir, name string, err error) {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return "", "", fmt.Errorf("the root of the volume can not be deleted")
+	}
+	idx := strings.LastIndex(p, "/")
+	return p[:idx], p[idx+1:], nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSplitDirPath(t *testing.T) {
+	cases := []struct {
+		path string
+		dir  string
+		name string
+	}{
+		{"a", "", "a"},
+		{"/a/b/", "/a", "b"},
+		{"a//b/../c", "/a", "c"},
+	}
+	for _, c := range cases {
+		dir, name, err := splitDirPath(c.path)
+		if err != nil || dir != c.dir || name != c.name {
+			t.Fatalf("split(%v): dir(%v) name(%v) err(%v), expect dir(%v) name(%v)", c.path, dir, name, err, c.dir, c.name)
+		}
+	}
+	for _, p := range []string{"", "/", "a/.."} {
+		if _, _, err := splitDirPath(p); err == nil {
+			t.Fatalf("split(%v): the root is split", p)
+		}
+	}
+}
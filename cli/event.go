@@ -0,0 +1,91 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+const eventPollInterval = 5 * time.Second
+
+func newEventCmd() *Command {
+	cmd := &Command{Name: "event", Short: "view the events of the cluster"}
+	cmd.AddCommand(
+		newEventListCmd(),
+	)
+	return cmd
+}
+
+func newEventListCmd() *Command {
+	cmd := &Command{Name: "list", Short: "list the events of the cluster, e.g. NodeOffline, DiskFailed or VolumeCreated"}
+	from := cmd.Flags().Uint64("from", 0, "list the events from the ID")
+	eventType := cmd.Flags().String("type", "", "list the events of the type only")
+	limit := cmd.Flags().Int("limit", 100, "list at most the number of events each time")
+	follow := cmd.Flags().Bool("follow", false, "poll and print the new events until interrupted")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 0 {
+			return ErrUsage
+		}
+		view, err := ctx.MasterClient().AdminAPI().ListEvents(*from, *eventType, *limit)
+		if err != nil {
+			return err
+		}
+		if err = ctx.Print(view.Events, func(w io.Writer) { printEvents(w, view.Events, true) }); err != nil || !*follow {
+			return err
+		}
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		ticker := time.NewTicker(eventPollInterval)
+		defer ticker.Stop()
+		next := view.Next
+		for {
+			select {
+			case <-ticker.C:
+			case <-interrupt:
+				return nil
+			}
+			// a failed poll is shown and retried, as the master may be switching the leader
+			if view, err = ctx.MasterClient().AdminAPI().ListEvents(next, *eventType, *limit); err != nil {
+				fmt.Fprintf(ctx.Err, "error: %v\n", err)
+				continue
+			}
+			next = view.Next
+			if len(view.Events) == 0 {
+				continue
+			}
+			if err = ctx.Print(view.Events, func(w io.Writer) { printEvents(w, view.Events, false) }); err != nil {
+				return err
+			}
+		}
+	}
+	return cmd
+}
+
+func printEvents(w io.Writer, events []*proto.ClusterEvent, header bool) {
+	if header {
+		fmt.Fprintf(w, "%-20v %-20v %-22v %-32v %v\n", "ID", "TIME", "TYPE", "TARGET", "MESSAGE")
+	}
+	for _, e := range events {
+		fmt.Fprintf(w, "%-20v %-20v %-22v %-32v %v\n", e.ID, formatTime(time.Unix(e.Time, 0)), e.Type, e.Target, e.Msg)
+	}
+}
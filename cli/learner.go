@@ -0,0 +1,108 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// learnerPromoteInterval is the interval of retrying the promotion of a learner not caught up.
+const learnerPromoteInterval = 5 * time.Second
+
+func newLearnerCmd() *Command {
+	cmd := &Command{Name: "learner", Short: "add the replicas of the partitions as the raft learners and promote them"}
+	cmd.AddCommand(
+		newLearnerAddCmd(),
+		newLearnerPromoteCmd(),
+	)
+	return cmd
+}
+
+func newLearnerAddCmd() *Command {
+	cmd := &Command{
+		Name:  "add",
+		Args:  "<data|meta> <partition id> <addr>",
+		Short: "add the replica as a learner catching up with the raft log without counting in the quorum",
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		partitionType, id, addr, err := parseLearnerArgs(args)
+		if err != nil {
+			return err
+		}
+		if partitionType == PartitionMeta {
+			err = ctx.MasterClient().AdminAPI().AddMetaLearner(id, addr)
+		} else {
+			err = ctx.MasterClient().AdminAPI().AddDataLearner(id, addr)
+		}
+		if err != nil {
+			return fmt.Errorf("add learner %v to %v partition %v: %v", addr, partitionType, id, err)
+		}
+		fmt.Fprintf(ctx.Out, "learner %v is added to %v partition %v\n", addr, partitionType, id)
+		return nil
+	}
+	return cmd
+}
+
+func newLearnerPromoteCmd() *Command {
+	cmd := &Command{
+		Name:  "promote",
+		Args:  "<data|meta> <partition id> <addr>",
+		Short: "promote the learner to a voter once it has caught up with the raft log",
+	}
+	wait := cmd.Flags().Duration("wait", 0, "retry the promotion until the learner has caught up or this duration elapses")
+	cmd.Run = func(ctx *Context, args []string) error {
+		partitionType, id, addr, err := parseLearnerArgs(args)
+		if err != nil {
+			return err
+		}
+		err = retryUntil(*wait, learnerPromoteInterval, func() error {
+			if partitionType == PartitionMeta {
+				return ctx.MasterClient().AdminAPI().PromoteMetaLearner(id, addr)
+			}
+			return ctx.MasterClient().AdminAPI().PromoteDataLearner(id, addr)
+		})
+		if err != nil {
+			return fmt.Errorf("promote learner %v of %v partition %v: %v", addr, partitionType, id, err)
+		}
+		fmt.Fprintf(ctx.Out, "learner %v of %v partition %v is promoted\n", addr, partitionType, id)
+		return nil
+	}
+	return cmd
+}
+
+func parseLearnerArgs(args []string) (partitionType string, id uint64, addr string, err error) {
+	if len(args) != 3 || (args[0] != PartitionData && args[0] != PartitionMeta) || args[2] == "" {
+		err = ErrUsage
+		return
+	}
+	if id, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+		err = ErrUsage
+		return
+	}
+	return args[0], id, args[2], nil
+}
+
+// retryUntil runs the function until it succeeds or the wait elapses, and returns its last error.
+func retryUntil(wait, interval time.Duration, f func() error) (err error) {
+	deadline := time.Now().Add(wait)
+	for {
+		if err = f(); err == nil || !time.Now().Add(interval).Before(deadline) {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLearnerArgs(t *testing.T) {
+	partitionType, id, addr, err := parseLearnerArgs([]string{"meta", "12", "192.168.0.31:17210"})
+	if err != nil || partitionType != PartitionMeta || id != 12 || addr != "192.168.0.31:17210" {
+		t.Fatalf("parse: type(%v) id(%v) addr(%v) err(%v)", partitionType, id, addr, err)
+	}
+	for _, args := range [][]string{
+		{"data", "12"},
+		{"ec", "12", "192.168.0.31:17310"},
+		{"data", "x", "192.168.0.31:17310"},
+		{"data", "12", ""},
+	} {
+		if _, _, _, err = parseLearnerArgs(args); err != ErrUsage {
+			t.Fatalf("args(%v): err(%v), expect usage", args, err)
+		}
+	}
+}
+
+func TestRetryUntil(t *testing.T) {
+	errNotCaughtUp := errors.New("learner not caught up")
+	var calls int
+	err := retryUntil(time.Second, time.Millisecond, func() error {
+		if calls++; calls < 3 {
+			return errNotCaughtUp
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("retry: calls(%v) err(%v)", calls, err)
+	}
+	// without the wait the function runs once
+	calls = 0
+	if err = retryUntil(0, time.Millisecond, func() error {
+		calls++
+		return errNotCaughtUp
+	}); err != errNotCaughtUp || calls != 1 {
+		t.Fatalf("no wait: calls(%v) err(%v)", calls, err)
+	}
+}
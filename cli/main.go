@@ -0,0 +1,89 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-cli is the command line tool of the operators to manage the resources of a cluster
+// through the master and the metanodes, instead of calling the HTTP APIs by hand.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/chubaofs/chubaofs/util/mtls"
+)
+
+// ProgramName is the name of the tool in the usages.
+const ProgramName = "cfs-cli"
+
+var (
+	masterAddr = flag.String("master", "", "master addresses separated by comma")
+	output     = flag.String("output", OutputTable, "output format: table, json or yaml")
+	tlsCert    = flag.String("tls-cert", "", "PEM certificate of mutual TLS, if the cluster enables it")
+	tlsKey     = flag.String("tls-key", "", "PEM private key of the certificate")
+	tlsCA      = flag.String("tls-ca", "", "PEM CAs issuing the certificates of the cluster")
+)
+
+func newRootCmd() *Command {
+	root := &Command{Name: ProgramName}
+	root.AddCommand(
+		newMultipartCmd(),
+		newAccessKeyCmd(),
+		newClusterCmd(),
+		newCompletionCmd(),
+		newCopyCmd(),
+		newDataPartitionCmd(),
+		newDecommissionCmd(),
+		newDeleteTreeCmd(),
+		newEventCmd(),
+		newLearnerCmd(),
+		newMetaPartitionCmd(),
+		newNodeCmd(),
+		newQuotaCmd(),
+		newRateLimitCmd(),
+		newShellCmd(),
+		newSnapshotCmd(),
+		newTrashCmd(),
+		newVolumeCmd(),
+		newXAttrCmd(),
+	)
+	return root
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %v [flags] <command>\n\nflags:\n", ProgramName)
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+		newRootCmd().usage(os.Stderr, nil)
+	}
+	flag.Parse()
+	if *masterAddr == "" || !validOutput(*output) {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := mtls.InitFiles(*tlsCert, *tlsKey, *tlsCA); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	ctx := &Context{Master: *masterAddr, Output: *output, Out: os.Stdout, Err: os.Stderr}
+	err := newRootCmd().Execute(ctx, flag.Args())
+	ctx.Close()
+	if err != nil {
+		if err != ErrUsage {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
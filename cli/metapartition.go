@@ -0,0 +1,94 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/tiglabs/raft"
+)
+
+func newMetaPartitionCmd() *Command {
+	cmd := &Command{Name: "metapartition", Short: "inspect the meta partitions"}
+	cmd.AddCommand(
+		newMetaPartitionInfoCmd(),
+	)
+	return cmd
+}
+
+func newMetaPartitionInfoCmd() *Command {
+	cmd := &Command{
+		Name:  "info",
+		Args:  "<partition id>",
+		Short: "show the inode range and the replicas of the meta partition with their raft lag",
+	}
+	metaProf := cmd.Flags().String("metaProf", "9092", "prof port of the metanodes")
+	interval := cmd.Flags().Duration("watch", 0, "refresh the info every interval, e.g. 2s, until interrupted")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return ErrUsage
+		}
+		return watch(ctx, *interval, func() (interface{}, func(w io.Writer), error) {
+			info, err := getMetaPartitionInfo(ctx, id, *metaProf)
+			if err != nil {
+				return nil, nil, err
+			}
+			return info, info.Print, nil
+		})
+	}
+	return cmd
+}
+
+func getMetaPartitionInfo(ctx *Context, id uint64, metaProf string) (info *PartitionInfo, err error) {
+	mp, err := ctx.MasterClient().ClientAPI().GetMetaPartition(id)
+	if err != nil {
+		return
+	}
+	info = &PartitionInfo{
+		Type:        PartitionMeta,
+		PartitionID: id,
+		Status:      mp.Status,
+		Start:       mp.Start,
+		End:         mp.End,
+		MaxInodeID:  mp.MaxInodeID,
+	}
+	replicas := make(map[string]*proto.MetaReplicaInfo, len(mp.Replicas))
+	for _, replica := range mp.Replicas {
+		replicas[replica.Addr] = replica
+	}
+	for _, host := range mp.Hosts {
+		r := &ReplicaInfo{Addr: host}
+		if replica := replicas[host]; replica != nil {
+			r.Leader, r.Status = replica.IsLeader, replica.Status
+		}
+		info.Replicas = append(info.Replicas, r)
+	}
+	info.setRaftStatuses(getRaftStatuses(mp.Hosts, func(host string) (*raft.Status, error) {
+		reply := &struct {
+			RaftStatus *raft.Status `json:"raftStatus"`
+		}{}
+		err := getNodeData(fmt.Sprintf("http://%v/getPartitionById?pid=%v", util.ProfAddr(host, metaProf), id), reply)
+		return reply.RaftStatus, err
+	}))
+	return
+}
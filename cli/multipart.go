@@ -0,0 +1,320 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+)
+
+const defaultMaxUploads = 10000
+
+func newMultipartCmd() *Command {
+	cmd := &Command{Name: "multipart", Short: "manage the in-progress multipart uploads of a volume"}
+	cmd.AddCommand(
+		newMultipartListCmd(),
+		newMultipartInfoCmd(),
+		newMultipartAbortCmd(),
+	)
+	return cmd
+}
+
+func newMultipartListCmd() *Command {
+	cmd := &Command{
+		Name:  "list",
+		Args:  "<vol> [prefix]",
+		Short: "list the in-progress multipart uploads of the volume, optionally under the key prefix",
+	}
+	olderThan := cmd.Flags().Duration("olderThan", 0, "only list the uploads initiated before this duration")
+	max := cmd.Flags().Uint64("max", defaultMaxUploads, "max number of the uploads listed from each meta partition")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return ErrUsage
+		}
+		prefix := argAt(args, 1)
+		uploads, err := listUploads(ctx, args[0], prefix, *max)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		views := newUploadViews(filterUploads(uploads, prefix, *olderThan, now), now)
+		return ctx.Print(views, func(w io.Writer) { printUploads(w, views) })
+	}
+	return cmd
+}
+
+func newMultipartInfoCmd() *Command {
+	cmd := &Command{
+		Name:  "info",
+		Args:  "<vol> <key> <upload id>",
+		Short: "show the parts of a multipart upload",
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 3 {
+			return ErrUsage
+		}
+		mw, err := ctx.MetaWrapper(args[0])
+		if err != nil {
+			return err
+		}
+		parent, err := lookupParent(mw, args[1])
+		if err != nil {
+			return fmt.Errorf("lookup parent of %v: %v", args[1], err)
+		}
+		info, err := mw.GetMultipart_ll(args[2], parent)
+		if err != nil {
+			return fmt.Errorf("get upload %v: %v", args[2], err)
+		}
+		view := newUploadView(info, time.Now(), true)
+		return ctx.Print(view, func(w io.Writer) { printUpload(w, view) })
+	}
+	return cmd
+}
+
+func newMultipartAbortCmd() *Command {
+	cmd := &Command{
+		Name: "abort",
+		Args: "<vol> [<key> <upload id>]",
+		Short: "abort a multipart upload and release its parts, or with -olderThan all the uploads " +
+			"initiated before the duration",
+	}
+	olderThan := cmd.Flags().Duration("olderThan", 0, "abort all the uploads initiated before this duration")
+	prefix := cmd.Flags().String("prefix", "", "only abort the uploads under the key prefix, with -olderThan")
+	max := cmd.Flags().Uint64("max", defaultMaxUploads, "max number of the uploads listed from each meta partition")
+	yes := cmd.Flags().Bool("yes", false, "abort the uploads instead of only listing them, with -olderThan")
+	cmd.Run = func(ctx *Context, args []string) error {
+		switch {
+		case len(args) == 3 && *olderThan == 0:
+			mw, err := ctx.MetaWrapper(args[0])
+			if err != nil {
+				return err
+			}
+			if err = abortUpload(mw, args[1], args[2]); err != nil {
+				return fmt.Errorf("abort upload %v of %v: %v", args[2], args[1], err)
+			}
+			result := &abortResult{Aborted: []string{args[2]}}
+			return ctx.Print(result, func(w io.Writer) { fmt.Fprintf(w, "aborted %v %v\n", args[2], args[1]) })
+		case len(args) == 1 && *olderThan > 0:
+			return abortUploads(ctx, args[0], *prefix, *olderThan, *max, *yes)
+		default:
+			return ErrUsage
+		}
+	}
+	return cmd
+}
+
+func listUploads(ctx *Context, vol, prefix string, max uint64) ([]*proto.MultipartInfo, error) {
+	mw, err := ctx.MetaWrapper(vol)
+	if err != nil {
+		return nil, err
+	}
+	return mw.ListMultipart_ll(prefix, "", "", "", max)
+}
+
+// filterUploads returns the uploads under the prefix initiated before olderThan, sorted by
+// the initiated time.
+func filterUploads(uploads []*proto.MultipartInfo, prefix string, olderThan time.Duration, now time.Time) []*proto.MultipartInfo {
+	matches := make([]*proto.MultipartInfo, 0, len(uploads))
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload.Path, prefix) {
+			continue
+		}
+		if olderThan > 0 && now.Sub(upload.InitTime) < olderThan {
+			continue
+		}
+		matches = append(matches, upload)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].InitTime.Before(matches[j].InitTime) })
+	return matches
+}
+
+func uploadSize(upload *proto.MultipartInfo) (size uint64) {
+	for _, part := range upload.Parts {
+		size += part.Size
+	}
+	return
+}
+
+// abortResult is the result of the abort command.
+type abortResult struct {
+	// Uploads are the uploads matched by a bulk abort.
+	Uploads []*uploadView `json:"uploads,omitempty"`
+	DryRun  bool          `json:"dryRun"`
+	Aborted []string      `json:"aborted"`
+	// Failed maps the upload ids failed to abort to the errors.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+func abortUploads(ctx *Context, vol, prefix string, olderThan time.Duration, max uint64, yes bool) error {
+	uploads, err := listUploads(ctx, vol, prefix, max)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	uploads = filterUploads(uploads, prefix, olderThan, now)
+	result := &abortResult{Uploads: newUploadViews(uploads, now), DryRun: !yes, Aborted: make([]string, 0)}
+	if yes {
+		mw, err := ctx.MetaWrapper(vol)
+		if err != nil {
+			return err
+		}
+		for _, upload := range uploads {
+			if err = abortUpload(mw, upload.Path, upload.ID); err != nil {
+				if result.Failed == nil {
+					result.Failed = make(map[string]string)
+				}
+				result.Failed[upload.ID] = err.Error()
+				continue
+			}
+			result.Aborted = append(result.Aborted, upload.ID)
+		}
+	}
+	err = ctx.Print(result, func(w io.Writer) {
+		printUploads(w, result.Uploads)
+		if result.DryRun {
+			fmt.Fprintf(w, "%v uploads to abort, run with -yes to abort them\n", len(result.Uploads))
+			return
+		}
+		for id, msg := range result.Failed {
+			fmt.Fprintf(w, "abort upload %v failed: %v\n", id, msg)
+		}
+		fmt.Fprintf(w, "%v uploads aborted, %v failed\n", len(result.Aborted), len(result.Failed))
+	})
+	if err == nil && len(result.Failed) > 0 {
+		err = fmt.Errorf("%v uploads failed to abort", len(result.Failed))
+	}
+	return err
+}
+
+// abortUpload releases the parts of the upload and removes the session, the same as the
+// AbortMultipartUpload of the objectnode.
+func abortUpload(mw *meta.MetaWrapper, key, uploadID string) error {
+	parent, err := lookupParent(mw, key)
+	if err != nil {
+		return err
+	}
+	info, err := mw.GetMultipart_ll(uploadID, parent)
+	if err != nil {
+		return err
+	}
+	for _, part := range info.Parts {
+		if _, err = mw.InodeUnlink_ll(part.Inode); err != nil {
+			return fmt.Errorf("unlink part %v inode %v: %v", part.ID, part.Inode, err)
+		}
+		mw.Evict(part.Inode)
+	}
+	return mw.RemoveMultipart_ll(uploadID, parent)
+}
+
+// lookupParent returns the inode of the parent directory of the key, where its upload
+// session is kept.
+func lookupParent(mw *meta.MetaWrapper, key string) (ino uint64, err error) {
+	ino = proto.RootIno
+	dirs := strings.Split(key, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if ino, _, err = mw.Lookup_ll(ino, dir); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// uploadView is the output schema of an upload.
+type uploadView struct {
+	ID         string      `json:"id"`
+	Key        string      `json:"key"`
+	Initiated  time.Time   `json:"initiated"`
+	AgeSeconds int64       `json:"ageSeconds"`
+	PartCount  int         `json:"partCount"`
+	Size       uint64      `json:"size"`
+	Parts      []*partView `json:"parts,omitempty"`
+}
+
+// partView is the output schema of a part of an upload.
+type partView struct {
+	ID       uint16    `json:"id"`
+	Inode    uint64    `json:"inode"`
+	Size     uint64    `json:"size"`
+	MD5      string    `json:"md5"`
+	Uploaded time.Time `json:"uploaded"`
+}
+
+func newUploadView(upload *proto.MultipartInfo, now time.Time, withParts bool) *uploadView {
+	view := &uploadView{
+		ID:         upload.ID,
+		Key:        upload.Path,
+		Initiated:  upload.InitTime,
+		AgeSeconds: int64(now.Sub(upload.InitTime) / time.Second),
+		PartCount:  len(upload.Parts),
+		Size:       uploadSize(upload),
+	}
+	if withParts {
+		view.Parts = make([]*partView, 0, len(upload.Parts))
+		for _, part := range upload.Parts {
+			view.Parts = append(view.Parts, &partView{
+				ID:       part.ID,
+				Inode:    part.Inode,
+				Size:     part.Size,
+				MD5:      part.MD5,
+				Uploaded: part.UploadTime,
+			})
+		}
+		sort.Slice(view.Parts, func(i, j int) bool { return view.Parts[i].ID < view.Parts[j].ID })
+	}
+	return view
+}
+
+func newUploadViews(uploads []*proto.MultipartInfo, now time.Time) []*uploadView {
+	views := make([]*uploadView, 0, len(uploads))
+	for _, upload := range uploads {
+		views = append(views, newUploadView(upload, now, false))
+	}
+	return views
+}
+
+func printUploads(w io.Writer, uploads []*uploadView) {
+	fmt.Fprintf(w, "%-36s %-20s %-12s %6s %14s  %v\n", "UPLOAD ID", "INITIATED", "AGE", "PARTS", "SIZE", "KEY")
+	for _, upload := range uploads {
+		fmt.Fprintf(w, "%-36s %-20s %-12s %6d %14d  %v\n", upload.ID, formatTime(upload.Initiated),
+			time.Duration(upload.AgeSeconds)*time.Second, upload.PartCount, upload.Size, upload.Key)
+	}
+}
+
+func printUpload(w io.Writer, upload *uploadView) {
+	fmt.Fprintf(w, "upload id: %v\nkey: %v\ninitiated: %v\nage: %v\nparts: %v\nsize: %v\n\n",
+		upload.ID, upload.Key, formatTime(upload.Initiated), time.Duration(upload.AgeSeconds)*time.Second,
+		upload.PartCount, upload.Size)
+	fmt.Fprintf(w, "%6s %20s %14s %-32s %v\n", "PART", "INODE", "SIZE", "MD5", "UPLOADED")
+	for _, part := range upload.Parts {
+		fmt.Fprintf(w, "%6d %20d %14d %-32s %v\n", part.ID, part.Inode, part.Size, part.MD5, formatTime(part.Uploaded))
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func argAt(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util"
+)
+
+func newNodeCmd() *Command {
+	cmd := &Command{Name: "node", Short: "inspect the metanodes and the datanodes"}
+	cmd.AddCommand(
+		newNodeInfoCmd(),
+	)
+	return cmd
+}
+
+// DiskInfo is the usage of a disk of a datanode.
+type DiskInfo struct {
+	Path       string `json:"path"`
+	Total      uint64 `json:"total"`
+	Used       uint64 `json:"used"`
+	Available  uint64 `json:"available"`
+	Status     int    `json:"status"`
+	Partitions int    `json:"partitions"`
+}
+
+// NodeInfo is the state of a node reported by the master, with the disks and the op rates
+// reported by the node itself.
+type NodeInfo struct {
+	Addr       string      `json:"addr"`
+	Type       string      `json:"type"`
+	Active     bool        `json:"active"`
+	Total      uint64      `json:"total"`
+	Used       uint64      `json:"used"`
+	Partitions int         `json:"partitions"`
+	ReportTime time.Time   `json:"reportTime"`
+	Disks      []*DiskInfo `json:"disks,omitempty"`
+	// Ops are the rates since the previous refresh, empty without the watch mode.
+	Ops   []*OpRate `json:"ops"`
+	Error string    `json:"error,omitempty"`
+}
+
+func newNodeInfoCmd() *Command {
+	cmd := &Command{
+		Name:  "info",
+		Args:  "<node addr>",
+		Short: "show the capacity, the disks and the op rates of the metanode or datanode",
+	}
+	metaProf := cmd.Flags().String("metaProf", "9092", "prof port of the metanodes")
+	dataProf := cmd.Flags().String("dataProf", "17320", "prof port of the datanodes")
+	interval := cmd.Flags().Duration("watch", 0, "refresh the info every interval, e.g. 2s, until interrupted")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		addr := args[0]
+		nodeType, err := getNodeType(ctx, addr)
+		if err != nil {
+			return err
+		}
+		poller := &opStatsPoller{url: "http://" + util.ProfAddr(addr, *metaProf) + opStatsPathMeta}
+		if nodeType == NodeData {
+			poller.url = "http://" + util.ProfAddr(addr, *dataProf) + opStatsPathData
+		}
+		return watch(ctx, *interval, func() (interface{}, func(w io.Writer), error) {
+			info, err := getNodeInfo(ctx, addr, nodeType, *dataProf, poller)
+			if err != nil {
+				return nil, nil, err
+			}
+			return info, info.Print, nil
+		})
+	}
+	return cmd
+}
+
+// getNodeType returns the type of the node registered in the master at the address.
+func getNodeType(ctx *Context, addr string) (nodeType string, err error) {
+	if _, err = ctx.MasterClient().NodeAPI().GetDataNode(addr); err == nil {
+		return NodeData, nil
+	}
+	if _, err = ctx.MasterClient().NodeAPI().GetMetaNode(addr); err == nil {
+		return NodeMeta, nil
+	}
+	return "", fmt.Errorf("%v is neither a datanode nor a metanode: %v", addr, err)
+}
+
+func getNodeInfo(ctx *Context, addr, nodeType, dataProf string, poller *opStatsPoller) (info *NodeInfo, err error) {
+	info = &NodeInfo{Addr: addr, Type: nodeType}
+	if nodeType == NodeData {
+		node, err := ctx.MasterClient().NodeAPI().GetDataNode(addr)
+		if err != nil {
+			return nil, err
+		}
+		info.Active, info.Total, info.Used = node.IsActive, node.Total, node.Used
+		info.Partitions, info.ReportTime = int(node.DataPartitionCount), node.ReportTime
+		disks := &struct {
+			Disks []*DiskInfo `json:"disks"`
+		}{}
+		if err = getNodeData(fmt.Sprintf("http://%v/disks", util.ProfAddr(addr, dataProf)), disks); err != nil {
+			info.Error = fmt.Sprintf("get disks: %v", err)
+		}
+		info.Disks = disks.Disks
+	} else {
+		node, err := ctx.MasterClient().NodeAPI().GetMetaNode(addr)
+		if err != nil {
+			return nil, err
+		}
+		info.Active, info.Total, info.Used = node.IsActive, node.Total, node.Used
+		info.Partitions, info.ReportTime = node.MetaPartitionCount, node.ReportTime
+	}
+	if info.Ops, err = poller.poll(); err != nil {
+		info.Error = fmt.Sprintf("get op stats: %v", err)
+		err = nil
+	}
+	return
+}
+
+// Print writes the node, its disks and its op rates as a table.
+func (n *NodeInfo) Print(w io.Writer) {
+	fmt.Fprintf(w, "%v: %v\nactive: %v\nreport time: %v\npartitions: %v\n", n.Type, n.Addr, n.Active,
+		formatTime(n.ReportTime), n.Partitions)
+	fmt.Fprintf(w, "total: %v\nused: %v\n", n.Total, n.Used)
+	if n.Error != "" {
+		fmt.Fprintf(w, "error: %v\n", n.Error)
+	}
+	if len(n.Disks) > 0 {
+		fmt.Fprintf(w, "\n%-32s %16s %16s %16s %8s %10s\n", "DISK", "TOTAL", "USED", "AVAILABLE", "STATUS", "PARTITIONS")
+		for _, d := range n.Disks {
+			fmt.Fprintf(w, "%-32s %16d %16d %16d %8d %10d\n", d.Path, d.Total, d.Used, d.Available, d.Status, d.Partitions)
+		}
+	}
+	fmt.Fprintln(w)
+	printOpRates(w, n.Ops)
+}
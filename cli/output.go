@@ -0,0 +1,179 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+func validOutput(output string) bool {
+	switch output {
+	case OutputTable, OutputJSON, OutputYAML:
+		return true
+	}
+	return false
+}
+
+// Print writes the result of a command in the output format of the context. The JSON and
+// YAML documents are encoded from the json tags of v, which are the stable schema of the
+// command, while table writes the human readable form.
+func (ctx *Context) Print(v interface{}, table func(w io.Writer)) error {
+	switch ctx.Output {
+	case OutputJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(ctx.Out, "%s\n", data)
+		return err
+	case OutputYAML:
+		return encodeYAML(ctx.Out, v)
+	default:
+		table(ctx.Out)
+		return nil
+	}
+}
+
+// encodeYAML writes v as a YAML document. The value is converted through its JSON form
+// first, so that both formats share the same schema; the keys of the objects are sorted.
+func encodeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err = dec.Decode(&value); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		if isEmpty(v) {
+			bw.WriteString(emptyYAML(v) + "\n")
+			break
+		}
+		writeYAML(bw, v, "", false)
+	default:
+		bw.WriteString(yamlScalar(v) + "\n")
+	}
+	return bw.Flush()
+}
+
+// writeYAML writes a non empty object or list with the given indent. inline is set for an
+// object in a list, whose first key follows the dash.
+func writeYAML(w *bufio.Writer, value interface{}, pad string, inline bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i > 0 || !inline {
+				w.WriteString(pad)
+			}
+			w.WriteString(yamlScalar(key) + ":")
+			switch child := v[key].(type) {
+			case map[string]interface{}, []interface{}:
+				if isEmpty(child) {
+					w.WriteString(" " + emptyYAML(child) + "\n")
+					continue
+				}
+				w.WriteString("\n")
+				writeYAML(w, child, pad+"  ", false)
+			default:
+				w.WriteString(" " + yamlScalar(child) + "\n")
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			w.WriteString(pad + "- ")
+			switch child := item.(type) {
+			case map[string]interface{}, []interface{}:
+				if isEmpty(child) {
+					w.WriteString(emptyYAML(child) + "\n")
+					continue
+				}
+				if _, ok := child.([]interface{}); ok {
+					w.WriteString("\n")
+					writeYAML(w, child, pad+"  ", false)
+					continue
+				}
+				writeYAML(w, child, pad+"  ", true)
+			default:
+				w.WriteString(yamlScalar(child) + "\n")
+			}
+		}
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func emptyYAML(value interface{}) string {
+	if _, ok := value.(map[string]interface{}); ok {
+		return "{}"
+	}
+	return "[]"
+}
+
+var plainScalar = regexp.MustCompile(`^[A-Za-z_/.][A-Za-z0-9_./-]*$`)
+
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("%v", v)
+	case json.Number:
+		return v.String()
+	case string:
+		switch strings.ToLower(v) {
+		case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		default:
+			if plainScalar.MatchString(v) {
+				return v
+			}
+		}
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
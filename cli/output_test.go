@@ -0,0 +1,100 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+type testView struct {
+	Name   string            `json:"name"`
+	Count  int               `json:"count"`
+	OK     bool              `json:"ok"`
+	Tags   []string          `json:"tags"`
+	Labels map[string]string `json:"labels"`
+	Items  []*testItem       `json:"items"`
+}
+
+type testItem struct {
+	ID   uint64 `json:"id"`
+	Path string `json:"path"`
+}
+
+func TestEncodeYAML(t *testing.T) {
+	v := &testView{
+		Name:   "vol-1",
+		Count:  3,
+		OK:     true,
+		Tags:   []string{"a", "yes", "1.5"},
+		Labels: map[string]string{},
+		Items:  []*testItem{{ID: 1, Path: "a/b"}, {ID: 2, Path: "key: value"}},
+	}
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	expected := `count: 3
+items:
+  - id: 1
+    path: a/b
+  - id: 2
+    path: "key: value"
+labels: {}
+name: vol-1
+ok: true
+tags:
+  - a
+  - "yes"
+  - "1.5"
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected yaml:\n%v\nexpected:\n%v", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := encodeYAML(&buf, []string{}); err != nil || buf.String() != "[]\n" {
+		t.Fatalf("empty list: %q %v", buf.String(), err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	v := &testItem{ID: 7, Path: "x"}
+	table := func(w io.Writer) { io.WriteString(w, "table\n") }
+
+	var buf bytes.Buffer
+	ctx := &Context{Output: OutputTable, Out: &buf}
+	if err := ctx.Print(v, table); err != nil || buf.String() != "table\n" {
+		t.Fatalf("table: %q %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	ctx.Output = OutputJSON
+	if err := ctx.Print(v, table); err != nil {
+		t.Fatal(err)
+	}
+	item := &testItem{}
+	if err := json.Unmarshal(buf.Bytes(), item); err != nil || *item != *v {
+		t.Fatalf("json: %q %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	ctx.Output = OutputYAML
+	if err := ctx.Print(v, table); err != nil || buf.String() != "id: 7\npath: x\n" {
+		t.Fatalf("yaml: %q %v", buf.String(), err)
+	}
+}
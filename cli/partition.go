@@ -0,0 +1,132 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tiglabs/raft"
+)
+
+const raftStateLeader = "StateLeader"
+
+// ReplicaInfo is the state of a replica of a partition, reported by the master and by the
+// node of the replica.
+type ReplicaInfo struct {
+	Addr     string `json:"addr"`
+	Leader   bool   `json:"leader"`
+	Status   int8   `json:"status"`
+	Used     uint64 `json:"used,omitempty"`
+	Total    uint64 `json:"total,omitempty"`
+	DiskPath string `json:"diskPath,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// the raft state reported by the node
+	RaftState string `json:"raftState"`
+	Commit    uint64 `json:"commit"`
+	Applied   uint64 `json:"applied"`
+	// Lag is the number of the entries committed by the leader but not applied by the replica.
+	Lag uint64 `json:"lag"`
+}
+
+// PartitionInfo is the state of a data or meta partition and of its replicas.
+type PartitionInfo struct {
+	Type        string         `json:"type"`
+	PartitionID uint64         `json:"partitionId"`
+	Volume      string         `json:"volume,omitempty"`
+	Status      int8           `json:"status"`
+	Start       uint64         `json:"start,omitempty"`
+	End         uint64         `json:"end,omitempty"`
+	MaxInodeID  uint64         `json:"maxInodeId,omitempty"`
+	Replicas    []*ReplicaInfo `json:"replicas"`
+}
+
+// getRaftStatuses gets the raft status of the replicas on the hosts in parallel. The replicas
+// failing to report are missing from the statuses, with their errors in the errs.
+func getRaftStatuses(hosts []string, get func(host string) (*raft.Status, error)) (statuses map[string]*raft.Status, errs map[string]error) {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	statuses = make(map[string]*raft.Status, len(hosts))
+	errs = make(map[string]error)
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			status, err := get(host)
+			if err == nil && status == nil {
+				err = fmt.Errorf("raft partition not found")
+			}
+			mu.Lock()
+			if err != nil {
+				errs[host] = err
+			} else {
+				statuses[host] = status
+			}
+			mu.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return
+}
+
+// setRaftStatuses fills the raft states of the replicas and computes their lags from the
+// commit index of the leader, or the largest one if the leader did not report.
+func (p *PartitionInfo) setRaftStatuses(statuses map[string]*raft.Status, errs map[string]error) {
+	var commit uint64
+	for _, status := range statuses {
+		if status.State == raftStateLeader {
+			commit = status.Commit
+			break
+		}
+		if status.Commit > commit {
+			commit = status.Commit
+		}
+	}
+	for _, r := range p.Replicas {
+		if err := errs[r.Addr]; err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		status := statuses[r.Addr]
+		if status == nil {
+			continue
+		}
+		r.RaftState, r.Commit, r.Applied = status.State, status.Commit, status.Applied
+		if commit > status.Applied {
+			r.Lag = commit - status.Applied
+		}
+	}
+}
+
+// Print writes the partition and its replicas as a table.
+func (p *PartitionInfo) Print(w io.Writer) {
+	fmt.Fprintf(w, "%v partition: %v\n", p.Type, p.PartitionID)
+	if p.Volume != "" {
+		fmt.Fprintf(w, "volume: %v\n", p.Volume)
+	}
+	fmt.Fprintf(w, "status: %v\n", p.Status)
+	if p.Type == PartitionMeta {
+		fmt.Fprintf(w, "range: [%v, %v]\nmax inode: %v\n", p.Start, p.End, p.MaxInodeID)
+	}
+	fmt.Fprintf(w, "\n%-24s %-6s %-6s %-16s %14s %14s %12s %12s %8s %-16s %v\n", "REPLICA", "LEADER", "STATUS",
+		"RAFT STATE", "USED", "TOTAL", "COMMIT", "APPLIED", "LAG", "DISK", "ERROR")
+	for _, r := range p.Replicas {
+		fmt.Fprintf(w, "%-24s %-6v %-6v %-16s %14d %14d %12d %12d %8d %-16s %v\n", r.Addr, r.Leader, r.Status,
+			r.RaftState, r.Used, r.Total, r.Commit, r.Applied, r.Lag, r.DiskPath, r.Error)
+	}
+}
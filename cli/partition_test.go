@@ -0,0 +1,41 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tiglabs/raft"
+)
+
+func TestRaftLag(t *testing.T) {
+	info := &PartitionInfo{Type: PartitionData, Replicas: []*ReplicaInfo{{Addr: "a"}, {Addr: "b"}, {Addr: "c"}}}
+	statuses := map[string]*raft.Status{
+		"a": {State: "StateFollower", Commit: 120, Applied: 120},
+		"b": {State: raftStateLeader, Commit: 100, Applied: 100},
+	}
+	info.setRaftStatuses(statuses, map[string]error{"c": io.EOF})
+	if a, b, c := info.Replicas[0], info.Replicas[1], info.Replicas[2]; a.Lag != 0 || b.Lag != 0 || c.Error != io.EOF.Error() {
+		t.Fatalf("unexpected replicas %+v %+v %+v", a, b, c)
+	}
+
+	// the largest commit is taken without the leader
+	statuses["b"].State = "StateCandidate"
+	info.setRaftStatuses(statuses, nil)
+	if lag := info.Replicas[1].Lag; lag != 20 {
+		t.Fatalf("unexpected lag %v", lag)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func newVolumePlacementCmd() *Command {
+	cmd := &Command{
+		Name: "placement",
+		Args: "<vol> <zones> <racks>",
+		Short: "require the replicas of each partition of the volume spread across the numbers of the zones " +
+			"and the racks, which is disabled if 0 or 1",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 3 {
+			return ErrUsage
+		}
+		zones, err := strconv.ParseUint(args[1], 10, 8)
+		if err != nil {
+			return ErrUsage
+		}
+		racks, err := strconv.ParseUint(args[2], 10, 8)
+		if err != nil {
+			return ErrUsage
+		}
+		if err = ctx.MasterClient().AdminAPI().SetVolumePlacement(args[0], *authKey, uint8(zones), uint8(racks)); err != nil {
+			return fmt.Errorf("set placement of %v: %v", args[0], err)
+		}
+		fmt.Fprintf(ctx.Out, "the replicas of %v spread across %v zones and %v racks\n", args[0], zones, racks)
+		return nil
+	}
+	return cmd
+}
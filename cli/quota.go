@@ -0,0 +1,179 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func newQuotaCmd() *Command {
+	cmd := &Command{Name: "quota", Short: "view and set the quotas of the directories of the volumes"}
+	cmd.AddCommand(
+		newQuotaListCmd(),
+		newQuotaSetCmd(),
+		newQuotaDeleteCmd(),
+	)
+	return cmd
+}
+
+func newQuotaListCmd() *Command {
+	cmd := &Command{Name: "list", Args: "<vol>", Short: "list the quotas of the volume with the usage"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		quotas, err := ctx.MasterClient().AdminAPI().ListVolumeQuotas(args[0])
+		if err != nil {
+			return err
+		}
+		return ctx.Print(quotas, func(w io.Writer) {
+			fmt.Fprintf(w, "%-6v %-12v %-24v %-24v %v\n", "ID", "INODE", "FILES", "BYTES", "PATH")
+			for _, q := range quotas {
+				fmt.Fprintf(w, "%-6v %-12v %-24v %-24v %v\n", q.QuotaID, q.RootInode,
+					quotaUsage(q.UsedFiles, q.MaxFiles, q.FilesExceeded),
+					quotaUsage(q.UsedBytes, q.MaxBytes, q.BytesExceeded), q.Path)
+			}
+		})
+	}
+	return cmd
+}
+
+func newQuotaSetCmd() *Command {
+	cmd := &Command{
+		Name: "set",
+		Args: "<vol> <path>",
+		Short: "limit the inodes and the bytes of the subtree under the directory, whose inodes are " +
+			"tagged with the quota, or update the limits",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	maxFiles := cmd.Flags().Uint64("maxFiles", 0, "the max inodes of the subtree, unlimited if 0")
+	maxBytes := cmd.Flags().Uint64("maxBytes", 0, "the max bytes of the files of the subtree, unlimited if 0")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 || *maxFiles == 0 && *maxBytes == 0 {
+			return ErrUsage
+		}
+		mw, ino, err := lookupVolumePath(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		quota, err := ctx.MasterClient().AdminAPI().SetVolumeQuota(args[0], *authKey, args[1], ino, *maxFiles, *maxBytes)
+		if err != nil {
+			return err
+		}
+		tagged, err := tagQuota(mw, ino, quota.QuotaID)
+		if err != nil {
+			return fmt.Errorf("tag quota %v under %v: %v", quota.QuotaID, args[1], err)
+		}
+		fmt.Fprintf(ctx.Out, "quota %v of %v is set, %v inodes tagged\n", quota.QuotaID, args[1], tagged)
+		return nil
+	}
+	return cmd
+}
+
+func newQuotaDeleteCmd() *Command {
+	cmd := &Command{
+		Name:  "delete",
+		Args:  "<vol> <quotaId>",
+		Short: "delete the quota, whose tags left on the inodes are ignored",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return ErrUsage
+		}
+		return ctx.MasterClient().AdminAPI().DeleteVolumeQuota(args[0], *authKey, uint32(id))
+	}
+	return cmd
+}
+
+func quotaUsage(used, max uint64, exceeded bool) string {
+	if max == 0 {
+		return fmt.Sprintf("%v/-", used)
+	}
+	if exceeded {
+		return fmt.Sprintf("%v/%v!", used, max)
+	}
+	return fmt.Sprintf("%v/%v", used, max)
+}
+
+// QuotaTagger is the part of the meta API used to tag the inodes with the quotas.
+type QuotaTagger interface {
+	ReadDir_ll(parentID uint64) ([]proto.Dentry, error)
+	XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo, error)
+	XAttrSet_ll(inode uint64, name, value []byte) error
+}
+
+// tagQuota adds the quota ID to the tags of the inodes of the subtree under the root, including
+// the root, and returns the number of the inodes tagged. The inodes tagged already are skipped,
+// so that it is resumed by running again if it fails.
+func tagQuota(mt QuotaTagger, root uint64, id uint32) (tagged int, err error) {
+	var dirs = []uint64{root}
+	var visited = map[uint64]bool{root: true}
+	if tagged, err = addQuotaTag(mt, root, id); err != nil {
+		return
+	}
+	for len(dirs) > 0 {
+		var dentries []proto.Dentry
+		if dentries, err = mt.ReadDir_ll(dirs[0]); err != nil {
+			return
+		}
+		dirs = dirs[1:]
+		for _, dentry := range dentries {
+			// the files of several hard links are tagged once
+			if visited[dentry.Inode] {
+				continue
+			}
+			visited[dentry.Inode] = true
+			var n int
+			if n, err = addQuotaTag(mt, dentry.Inode, id); err != nil {
+				return
+			}
+			tagged += n
+			if proto.IsDir(dentry.Type) {
+				dirs = append(dirs, dentry.Inode)
+			}
+		}
+	}
+	return
+}
+
+func addQuotaTag(mt QuotaTagger, ino uint64, id uint32) (tagged int, err error) {
+	var info *proto.XAttrInfo
+	if info, err = mt.XAttrGet_ll(ino, proto.QuotaXAttrKey); err != nil {
+		return
+	}
+	var ids []uint32
+	if ids, err = proto.ParseQuotaIDs(info.XAttrs[proto.QuotaXAttrKey]); err != nil {
+		return
+	}
+	for _, tag := range ids {
+		if tag == id {
+			return
+		}
+	}
+	ids = append(ids, id)
+	if err = mt.XAttrSet_ll(ino, []byte(proto.QuotaXAttrKey), []byte(proto.FormatQuotaIDs(ids))); err != nil {
+		return
+	}
+	return 1, nil
+}
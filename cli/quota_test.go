@@ -0,0 +1,66 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+type fakeQuotaTagger struct {
+	dirs map[uint64][]proto.Dentry
+	tags map[uint64]string
+}
+
+func (mt *fakeQuotaTagger) ReadDir_ll(parentID uint64) ([]proto.Dentry, error) {
+	return mt.dirs[parentID], nil
+}
+
+func (mt *fakeQuotaTagger) XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo, error) {
+	return &proto.XAttrInfo{Inode: inode, XAttrs: map[string]string{name: mt.tags[inode]}}, nil
+}
+
+func (mt *fakeQuotaTagger) XAttrSet_ll(inode uint64, name, value []byte) error {
+	mt.tags[inode] = string(value)
+	return nil
+}
+
+func TestTagQuota(t *testing.T) {
+	dir, file := proto.Mode(os.ModeDir|0755), proto.Mode(0644)
+	mt := &fakeQuotaTagger{
+		dirs: map[uint64][]proto.Dentry{
+			10: {{Name: "a", Inode: 11, Type: dir}, {Name: "f", Inode: 12, Type: file}},
+			11: {{Name: "g", Inode: 13, Type: file}, {Name: "link", Inode: 12, Type: file}},
+		},
+		tags: map[uint64]string{11: "1", 13: "2"},
+	}
+	tagged, err := tagQuota(mt, 10, 2)
+	if err != nil || tagged != 3 {
+		t.Fatalf("tag quota: tagged(%v) err(%v)", tagged, err)
+	}
+	expected := map[uint64]string{10: "2", 11: "1,2", 12: "2", 13: "2"}
+	for ino, tag := range expected {
+		if mt.tags[ino] != tag {
+			t.Fatalf("inode %v tagged %q, expected %q", ino, mt.tags[ino], tag)
+		}
+	}
+
+	// the inodes tagged already are skipped
+	if tagged, err = tagQuota(mt, 10, 2); err != nil || tagged != 0 {
+		t.Fatalf("tag quota again: tagged(%v) err(%v)", tagged, err)
+	}
+}
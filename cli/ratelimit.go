@@ -0,0 +1,101 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+func newRateLimitCmd() *Command {
+	cmd := &Command{Name: "ratelimit", Short: "view and set the rate limits of the cluster"}
+	cmd.AddCommand(
+		newRateLimitListCmd(),
+		newRateLimitSetCmd(),
+	)
+	return cmd
+}
+
+func newRateLimitListCmd() *Command {
+	cmd := &Command{Name: "list", Short: "list the rate limits of all the modules"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 0 {
+			return ErrUsage
+		}
+		rules, err := ctx.MasterClient().AdminAPI().GetRateLimits()
+		if err != nil {
+			return err
+		}
+		return ctx.Print(rules, func(w io.Writer) {
+			fmt.Fprintf(w, "%-12v %-16v %-32v %-16v %-10v %-8v %v\n", "MODULE", "VOL", "OP", "CLIENT", "RATE", "BURST", "BANDWIDTH")
+			for _, r := range rules {
+				fmt.Fprintf(w, "%-12v %-16v %-32v %-16v %-10v %-8v %v\n",
+					r.Module, orAll(r.Vol), orAll(r.Op), orAll(r.Client), r.Rate, r.Burst, formatBandwidth(r.Bytes))
+			}
+		})
+	}
+	return cmd
+}
+
+func newRateLimitSetCmd() *Command {
+	cmd := &Command{
+		Name: "set",
+		Args: "<module> <rate>",
+		Short: "limit the ops per second of master, metanode, datanode, objectnode or client, or remove the limit with " +
+			"the rate 0 and no bandwidth",
+	}
+	vol := cmd.Flags().String("vol", "", "limit the ops of the volume only")
+	op := cmd.Flags().String("op", "", "limit the op only, e.g. OpMetaCreateInode, OpWrite, PUT_object or /admin/getVol")
+	client := cmd.Flags().String("client", "", "limit the client IP only, or * to limit each client separately")
+	burst := cmd.Flags().Int("burst", 0, "the ops allowed at once, the rate by default")
+	bandwidth := cmd.Flags().Float64("bandwidth", 0, "limit the MB per second read and written too, no limit if 0")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 || *bandwidth < 0 {
+			return ErrUsage
+		}
+		rate, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return ErrUsage
+		}
+		return ctx.MasterClient().AdminAPI().SetRateLimit(&proto.RateLimitRule{
+			Module: args[0],
+			Vol:    *vol,
+			Op:     *op,
+			Client: *client,
+			Rate:   rate,
+			Burst:  *burst,
+			Bytes:  *bandwidth * util.MB,
+		})
+	}
+	return cmd
+}
+
+func formatBandwidth(bytes float64) string {
+	if bytes == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%vMB/s", strconv.FormatFloat(bytes/util.MB, 'f', -1, 64))
+}
+
+func orAll(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,251 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	historyFile = ".cfs-cli_history"
+	maxHistory  = 1000
+)
+
+const shellHelp = `builtin commands:
+  use master <addresses>   switch to the cluster of the master addresses
+  use volume [<vol>]       set the volume passed as the <vol> argument, or clear it
+  output <format>          switch the output format: table, json or yaml
+  history                  list the command history
+  !<n>, !!                 run the command <n> of the history, or the last one
+  help                     print this help and the commands
+  exit, quit               leave the shell
+`
+
+func newShellCmd() *Command {
+	cmd := &Command{
+		Name:  "shell",
+		Short: "run the commands interactively, with the command history and the current volume",
+	}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) > 0 {
+			return ErrUsage
+		}
+		shell := &Shell{ctx: ctx, in: bufio.NewScanner(os.Stdin)}
+		if home, err := os.UserHomeDir(); err == nil {
+			shell.historyPath = filepath.Join(home, historyFile)
+		}
+		shell.loadHistory()
+		return shell.Run()
+	}
+	return cmd
+}
+
+// Shell reads the commands line by line and runs them with the same context. Line editing
+// is left to the terminal or a wrapper like rlwrap.
+type Shell struct {
+	ctx         *Context
+	in          *bufio.Scanner
+	history     []string
+	historyPath string
+}
+
+// Run runs the commands until the input ends or the shell is exited.
+func (s *Shell) Run() error {
+	for {
+		fmt.Fprint(s.ctx.Err, s.prompt())
+		if !s.in.Scan() {
+			fmt.Fprintln(s.ctx.Err)
+			return s.in.Err()
+		}
+		line := strings.TrimSpace(s.in.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			var err error
+			if line, err = s.recall(line); err != nil {
+				fmt.Fprintf(s.ctx.Err, "error: %v\n", err)
+				continue
+			}
+			fmt.Fprintln(s.ctx.Err, line)
+		}
+		s.addHistory(line)
+		if exit := s.runLine(line); exit {
+			return nil
+		}
+	}
+}
+
+func (s *Shell) prompt() string {
+	if s.ctx.Volume != "" {
+		return fmt.Sprintf("%v(%v/%v)> ", ProgramName, s.ctx.Master, s.ctx.Volume)
+	}
+	return fmt.Sprintf("%v(%v)> ", ProgramName, s.ctx.Master)
+}
+
+// runLine runs the line, and returns true if the shell is exited.
+func (s *Shell) runLine(line string) (exit bool) {
+	args, err := splitLine(line)
+	if err != nil {
+		fmt.Fprintf(s.ctx.Err, "error: %v\n", err)
+		return
+	}
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "help":
+		fmt.Fprint(s.ctx.Out, shellHelp)
+		fmt.Fprintln(s.ctx.Out)
+		newRootCmd().usage(s.ctx.Out, nil)
+	case "history":
+		for i, h := range s.history {
+			fmt.Fprintf(s.ctx.Out, "%5d  %v\n", i+1, h)
+		}
+	case "output":
+		if len(args) != 2 || !validOutput(args[1]) {
+			fmt.Fprintln(s.ctx.Err, "usage: output table|json|yaml")
+			return
+		}
+		s.ctx.Output = args[1]
+	case "use":
+		s.use(args[1:])
+	case "shell":
+		fmt.Fprintln(s.ctx.Err, "error: already in the shell")
+	default:
+		// the flags of the commands keep their values, so a new command tree is built for each line
+		if err = newRootCmd().Execute(s.ctx, args); err != nil && err != ErrUsage {
+			fmt.Fprintf(s.ctx.Err, "error: %v\n", err)
+		}
+	}
+	return
+}
+
+func (s *Shell) use(args []string) {
+	switch {
+	case len(args) == 2 && args[0] == "master":
+		s.ctx.UseMaster(args[1])
+		s.ctx.Volume = ""
+	case len(args) == 2 && args[0] == "volume":
+		s.ctx.Volume = args[1]
+	case len(args) == 1 && args[0] == "volume":
+		s.ctx.Volume = ""
+	default:
+		fmt.Fprintln(s.ctx.Err, "usage: use master <addresses> | use volume [<vol>]")
+	}
+}
+
+// recall returns the line of the history referred by !<n> or !!.
+func (s *Shell) recall(line string) (string, error) {
+	if len(s.history) == 0 {
+		return "", fmt.Errorf("no history")
+	}
+	if line == "!!" {
+		return s.history[len(s.history)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > len(s.history) {
+		return "", fmt.Errorf("%v: event not found", line)
+	}
+	return s.history[n-1], nil
+}
+
+func (s *Shell) loadHistory() {
+	if s.historyPath == "" {
+		return
+	}
+	f, err := os.Open(s.historyPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			s.history = append(s.history, line)
+		}
+	}
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+}
+
+func (s *Shell) addHistory(line string) {
+	if len(s.history) > 0 && s.history[len(s.history)-1] == line {
+		return
+	}
+	s.history = append(s.history, line)
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+	if s.historyPath == "" {
+		return
+	}
+	f, err := os.OpenFile(s.historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	io.WriteString(f, line+"\n")
+	f.Close()
+}
+
+// splitLine splits the line into the arguments at the spaces out of the quotes, a backslash
+// escapes the next character out of the single quotes.
+func splitLine(line string) (args []string, err error) {
+	var (
+		sb      strings.Builder
+		quote   rune
+		escaped bool
+		inArg   bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	for line, expected := range map[string][]string{
+		`multipart list  vol a/b`:     {"multipart", "list", "vol", "a/b"},
+		`info vol "a b/c" 'x\y'`:      {"info", "vol", "a b/c", `x\y`},
+		`info vol a\ b ""`:            {"info", "vol", "a b", ""},
+		`list -olderThan=1h "vol"x`:   {"list", "-olderThan=1h", "volx"},
+		`abort vol "it's" '"quoted"'`: {"abort", "vol", "it's", `"quoted"`},
+	} {
+		args, err := splitLine(line)
+		if err != nil || !reflect.DeepEqual(args, expected) {
+			t.Fatalf("%v: expected %q, got %q %v", line, expected, args, err)
+		}
+	}
+	if _, err := splitLine(`list "vol`); err == nil {
+		t.Fatalf("unterminated quote should be invalid")
+	}
+}
+
+func TestShell(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	ctx := &Context{Master: "m1", Output: OutputTable, Out: &stdout, Err: &stderr}
+	input := "use volume vol\noutput json\nhistory\n!1\nuse master m2\nexit\nhistory\n"
+	shell := &Shell{ctx: ctx, in: bufio.NewScanner(strings.NewReader(input))}
+	if err := shell.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Master != "m2" || ctx.Volume != "" || ctx.Output != OutputJSON {
+		t.Fatalf("unexpected context %+v", ctx)
+	}
+	if len(shell.history) != 6 || shell.history[3] != "use volume vol" {
+		t.Fatalf("unexpected history %q", shell.history)
+	}
+	if !strings.Contains(stdout.String(), "    2  output json") {
+		t.Fatalf("unexpected output %v", stdout.String())
+	}
+	if _, err := shell.recall("!9"); err == nil {
+		t.Fatalf("recall out of the history should fail")
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	leaf := &Command{Name: "leaf"}
+	leaf.Flags().Bool("v", false, "verbose")
+	group := &Command{Name: "group"}
+	group.AddCommand(leaf)
+	root := &Command{Name: ProgramName}
+	root.AddCommand(group)
+	global := flag.NewFlagSet("", flag.ContinueOnError)
+	global.String("master", "", "")
+
+	var buf bytes.Buffer
+	writeBashCompletion(&buf, root, global)
+	for _, expected := range []string{
+		`"") echo "group -master" ;;`,
+		`"group") echo "leaf" ;;`,
+		`"group leaf") echo "-v" ;;`,
+		"complete -F _cfs_cli " + ProgramName,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("%v not found in:\n%v", expected, buf.String())
+		}
+	}
+}
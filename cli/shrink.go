@@ -0,0 +1,271 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+func newVolumeShrinkCmd() *Command {
+	cmd := &Command{
+		Name: "shrink",
+		Args: "<vol> <capacity GB>",
+		Short: "lower the capacity of the volume, and mark the data partitions beyond the ones the capacity " +
+			"needs as releasing",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		capacity, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil || capacity == 0 {
+			return ErrUsage
+		}
+		releasing, err := ctx.MasterClient().AdminAPI().ShrinkVolume(args[0], *authKey, capacity)
+		if err != nil {
+			return fmt.Errorf("shrink %v: %v", args[0], err)
+		}
+		return ctx.Print(releasing, func(w io.Writer) {
+			fmt.Fprintf(w, "capacity of %v lowered to %vGB, %v data partitions releasing: %v\n",
+				args[0], capacity, len(releasing), releasing)
+		})
+	}
+	return cmd
+}
+
+func newVolumeReleaseCmd() *Command {
+	cmd := &Command{
+		Name: "release",
+		Args: "<vol>",
+		Short: "move the extents of the files off the releasing data partitions of the volume, and delete " +
+			"the data partitions no file refers to any more",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	metaProf := cmd.Flags().String("metaProf", "9092", "prof port of the metanodes")
+	dryRun := cmd.Flags().Bool("dry", false, "only count the extents referring to the releasing data partitions")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		view, err := ctx.MasterClient().AdminAPI().GetVolumeSimpleInfo(args[0])
+		if err != nil {
+			return fmt.Errorf("get volume %v: %v", args[0], err)
+		}
+		if len(view.ReleasingDps) == 0 {
+			fmt.Fprintf(ctx.Out, "no data partitions of %v are releasing\n", args[0])
+			return nil
+		}
+		r := &volumeReleaser{ctx: ctx, vol: args[0], metaProf: *metaProf, progress: make(map[uint64]*ReleaseProgress)}
+		for _, id := range view.ReleasingDps {
+			r.progress[id] = &ReleaseProgress{PartitionID: id}
+		}
+		if !*dryRun {
+			if err = r.moveExtents(); err != nil {
+				return err
+			}
+		}
+		// the partitions are released only if no file refers to them after the move
+		if err = r.countReferences(); err != nil {
+			return err
+		}
+		if !*dryRun {
+			r.release(*authKey)
+		}
+		progress := r.sortedProgress()
+		return ctx.Print(progress, func(w io.Writer) { printReleaseProgress(w, progress) })
+	}
+	return cmd
+}
+
+// ReleaseProgress is the result of releasing a data partition.
+type ReleaseProgress struct {
+	PartitionID uint64
+	Moved       int // the extent keys moved to the other data partitions
+	References  int // the extent keys still referring to the data partition
+	Released    bool
+	Error       string `json:",omitempty"`
+}
+
+// volumeReleaser scans the inodes of the volume on the leaders of the meta partitions.
+type volumeReleaser struct {
+	ctx      *Context
+	vol      string
+	metaProf string
+	progress map[uint64]*ReleaseProgress
+	mw       *meta.MetaWrapper
+	ec       *stream.ExtentClient
+}
+
+// scanInode is the part of the inode reported by the metanode.
+type scanInode struct {
+	Inode   uint64
+	Extents []proto.ExtentKey
+}
+
+// scanInodes calls the function with each inode of the volume having the extents on the
+// releasing data partitions.
+func (r *volumeReleaser) scanInodes(fn func(inode *scanInode) error) (err error) {
+	views, err := r.ctx.MasterClient().ClientAPI().GetMetaPartitions(r.vol)
+	if err != nil {
+		return fmt.Errorf("get meta partitions of %v: %v", r.vol, err)
+	}
+	for _, mp := range views {
+		if mp.LeaderAddr == "" {
+			return fmt.Errorf("meta partition %v has no leader", mp.PartitionID)
+		}
+		url := fmt.Sprintf("http://%v/getAllInodes?pid=%v", util.ProfAddr(mp.LeaderAddr, r.metaProf), mp.PartitionID)
+		if err = r.scanURL(url, fn); err != nil {
+			return fmt.Errorf("scan inodes of meta partition %v: %v", mp.PartitionID, err)
+		}
+	}
+	return
+}
+
+func (r *volumeReleaser) scanURL(url string, fn func(inode *scanInode) error) (err error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	for dec.More() {
+		inode := new(scanInode)
+		if err = dec.Decode(inode); err != nil {
+			return
+		}
+		if len(extentsOnPartitions(inode.Extents, r.progress)) == 0 {
+			continue
+		}
+		if err = fn(inode); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// moveExtents copies the extents on the releasing data partitions to the writable ones, and
+// replaces the extent keys of the files, whose old extents are freed by the metanodes.
+func (r *volumeReleaser) moveExtents() (err error) {
+	if r.mw, err = r.ctx.MetaWrapper(r.vol); err != nil {
+		return
+	}
+	opt := &proto.MountOptions{Volname: r.vol, Master: r.ctx.Master}
+	if r.ec, err = stream.NewExtentClient(opt, r.mw.AppendExtentKey, r.mw.GetExtents, r.mw.Truncate); err != nil {
+		return fmt.Errorf("create extent client of %v: %v", r.vol, err)
+	}
+	defer r.ec.Close()
+	return r.scanInodes(func(inode *scanInode) error {
+		r.moveInode(inode.Inode)
+		return nil
+	})
+}
+
+// moveInode moves the extents of the inode, which is skipped if modified during the copy. The
+// metanode appends the copies only if the generation of the inode is unchanged, so the copies
+// are left as garbage of the new partitions, rather than overwriting the data written meanwhile.
+func (r *volumeReleaser) moveInode(ino uint64) {
+	gen, _, extents, err := r.mw.GetExtents(ino)
+	if err != nil {
+		return
+	}
+	extents = extentsOnPartitions(extents, r.progress)
+	if len(extents) == 0 {
+		return
+	}
+	fail := func(err error) {
+		for _, ek := range extents {
+			r.progress[ek.PartitionId].Error = fmt.Sprintf("inode %v: %v", ino, err)
+		}
+	}
+	copies, err := r.ec.CopyExtents(r.ec, extents)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err = r.mw.AppendExtentKeysIfGeneration(ino, gen, copies); err == syscall.ESTALE {
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	for _, ek := range extents {
+		r.progress[ek.PartitionId].Moved++
+	}
+}
+
+func (r *volumeReleaser) countReferences() error {
+	for _, p := range r.progress {
+		p.References = 0
+	}
+	return r.scanInodes(func(inode *scanInode) error {
+		for _, ek := range extentsOnPartitions(inode.Extents, r.progress) {
+			r.progress[ek.PartitionId].References++
+		}
+		return nil
+	})
+}
+
+// release deletes the releasing data partitions no file refers to.
+func (r *volumeReleaser) release(authKey string) {
+	for id, p := range r.progress {
+		if p.References > 0 {
+			continue
+		}
+		if err := r.ctx.MasterClient().AdminAPI().ReleaseDataPartition(r.vol, authKey, id); err != nil {
+			p.Error = err.Error()
+			continue
+		}
+		p.Released, p.Error = true, ""
+	}
+}
+
+func (r *volumeReleaser) sortedProgress() (progress []*ReleaseProgress) {
+	for _, p := range r.progress {
+		progress = append(progress, p)
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].PartitionID < progress[j].PartitionID })
+	return
+}
+
+// extentsOnPartitions returns the extent keys on the given data partitions.
+func extentsOnPartitions(extents []proto.ExtentKey, partitions map[uint64]*ReleaseProgress) (found []proto.ExtentKey) {
+	for _, ek := range extents {
+		if _, ok := partitions[ek.PartitionId]; ok {
+			found = append(found, ek)
+		}
+	}
+	return
+}
+
+func printReleaseProgress(w io.Writer, progress []*ReleaseProgress) {
+	fmt.Fprintf(w, "%-12v %-8v %-11v %-9v %v\n", "PARTITION", "MOVED", "REFERENCES", "RELEASED", "ERROR")
+	for _, p := range progress {
+		fmt.Fprintf(w, "%-12v %-8v %-11v %-9v %v\n", p.PartitionID, p.Moved, p.References, p.Released, p.Error)
+	}
+}
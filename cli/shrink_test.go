@@ -0,0 +1,51 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVolumeReleaserScan(t *testing.T) {
+	// the metanode reports the inodes one JSON object per line
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Inode":1,"Extents":[{"PartitionId":5,"ExtentId":1},{"PartitionId":6,"ExtentId":2}]}`)
+		fmt.Fprintln(w, `{"Inode":2,"Extents":[{"PartitionId":6,"ExtentId":3}]}`)
+		fmt.Fprintln(w, `{"Inode":3,"Extents":[{"PartitionId":7,"ExtentId":4},{"PartitionId":7,"ExtentId":5}]}`)
+	}))
+	defer server.Close()
+
+	r := &volumeReleaser{progress: map[uint64]*ReleaseProgress{5: {PartitionID: 5}, 7: {PartitionID: 7}}}
+	var inodes []uint64
+	err := r.scanURL(server.URL, func(inode *scanInode) error {
+		inodes = append(inodes, inode.Inode)
+		for _, ek := range extentsOnPartitions(inode.Extents, r.progress) {
+			r.progress[ek.PartitionId].References++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inodes) != 2 || inodes[0] != 1 || inodes[1] != 3 {
+		t.Fatalf("inodes %v, expect [1 3]", inodes)
+	}
+	if r.progress[5].References != 1 || r.progress[7].References != 2 {
+		t.Fatalf("references dp5(%v) dp7(%v)", r.progress[5].References, r.progress[7].References)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+func newSnapshotCmd() *Command {
+	cmd := &Command{Name: "snapshot", Short: "manage the read-only snapshots of the volumes"}
+	cmd.AddCommand(
+		newSnapshotCreateCmd(),
+		newSnapshotDeleteCmd(),
+		newSnapshotListCmd(),
+	)
+	return cmd
+}
+
+func newSnapshotCreateCmd() *Command {
+	cmd := &Command{Name: "create", Args: "<vol> <name>", Short: "take a snapshot of the volume"}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		snapshot, err := ctx.MasterClient().AdminAPI().CreateVolumeSnapshot(args[0], *authKey, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(ctx.Out, "snapshot %v(%v) of %v is being taken\n", snapshot.Name, snapshot.ID, args[0])
+		return nil
+	}
+	return cmd
+}
+
+func newSnapshotDeleteCmd() *Command {
+	cmd := &Command{Name: "delete", Args: "<vol> <name>", Short: "delete the snapshot of the volume"}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		return ctx.MasterClient().AdminAPI().DeleteVolumeSnapshot(args[0], *authKey, args[1])
+	}
+	return cmd
+}
+
+func newSnapshotListCmd() *Command {
+	cmd := &Command{Name: "list", Args: "<vol>", Short: "list the snapshots of the volume"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		snapshots, err := ctx.MasterClient().AdminAPI().ListVolumeSnapshots(args[0])
+		if err != nil {
+			return err
+		}
+		return ctx.Print(snapshots, func(w io.Writer) {
+			fmt.Fprintf(w, "%-6v %-20v %-14v %v\n", "ID", "CREATED", "STATUS", "NAME")
+			for _, snapshot := range snapshots {
+				fmt.Fprintf(w, "%-6v %-20v %-14v %v\n", snapshot.ID, formatTime(time.Unix(snapshot.CreateTime, 0)),
+					snapshot.Status, snapshot.Name)
+			}
+		})
+	}
+	return cmd
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func newTrashCmd() *Command {
+	cmd := &Command{Name: "trash", Short: "view and restore the files deleted into the trash of the volumes"}
+	cmd.AddCommand(
+		newTrashListCmd(),
+		newTrashSetCmd(),
+		newTrashRestoreCmd(),
+	)
+	return cmd
+}
+
+func newTrashListCmd() *Command {
+	cmd := &Command{Name: "list", Args: "<vol>", Short: "list the dentries in the trash of the volume"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		mw, err := ctx.MetaWrapper(args[0])
+		if err != nil {
+			return err
+		}
+		items, err := mw.ListTrash_ll()
+		if err != nil {
+			return fmt.Errorf("list trash of %v: %v", args[0], err)
+		}
+		return ctx.Print(items, func(w io.Writer) {
+			fmt.Fprintf(w, "%-20v %-12v %-12v %-5v %v\n", "DELETED", "PARENT", "INODE", "DIR", "NAME")
+			for _, item := range items {
+				fmt.Fprintf(w, "%-20v %-12v %-12v %-5v %v\n", formatTime(time.Unix(0, item.DeleteTime)),
+					item.ParentID, item.Inode, proto.IsDir(item.Type), item.Name)
+			}
+		})
+	}
+	return cmd
+}
+
+func newTrashSetCmd() *Command {
+	cmd := &Command{
+		Name:  "set",
+		Args:  "<vol> <hours>",
+		Short: "set the hours the deleted files are kept in the trash of the volume, which is disabled if 0",
+	}
+	authKey := cmd.Flags().String("authKey", "", "the md5 of the owner of the volume")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		hours, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return ErrUsage
+		}
+		return ctx.MasterClient().AdminAPI().SetVolumeTrash(args[0], *authKey, uint32(hours))
+	}
+	return cmd
+}
+
+func newTrashRestoreCmd() *Command {
+	cmd := &Command{
+		Name:  "restore",
+		Args:  "<vol> <inode>",
+		Short: "restore the dentry of the inode deleted last into the trash under its parent",
+	}
+	name := cmd.Flags().String("name", "", "the new name of the dentry, the original name if empty")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		ino, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return ErrUsage
+		}
+		mw, err := ctx.MetaWrapper(args[0])
+		if err != nil {
+			return err
+		}
+		item, err := restoreTrash(mw, ino, *name)
+		if err != nil {
+			return fmt.Errorf("restore inode %v: %v", ino, err)
+		}
+		restored := item.Name
+		if *name != "" {
+			restored = *name
+		}
+		fmt.Fprintf(ctx.Out, "inode %v is restored as %v under %v\n", ino, restored, item.ParentID)
+		return nil
+	}
+	return cmd
+}
+
+// TrashRestorer is the part of the meta API used to restore the dentries in the trash.
+type TrashRestorer interface {
+	ListTrash_ll() ([]*proto.TrashItem, error)
+	RestoreTrash_ll(parentID uint64, trashName, name string) error
+}
+
+// restoreTrash restores the dentry of the inode deleted last, since the files of several hard
+// links may have several dentries in the trash.
+func restoreTrash(tr TrashRestorer, ino uint64, name string) (item *proto.TrashItem, err error) {
+	items, err := tr.ListTrash_ll()
+	if err != nil {
+		return
+	}
+	for _, i := range items {
+		if i.Inode == ino && (item == nil || i.DeleteTime > item.DeleteTime) {
+			item = i
+		}
+	}
+	if item == nil {
+		return nil, fmt.Errorf("not in the trash")
+	}
+	err = tr.RestoreTrash_ll(item.ParentID, item.TrashName, name)
+	return
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+type fakeTrashRestorer struct {
+	items    []*proto.TrashItem
+	restored []string
+}
+
+func (tr *fakeTrashRestorer) ListTrash_ll() ([]*proto.TrashItem, error) {
+	return tr.items, nil
+}
+
+func (tr *fakeTrashRestorer) RestoreTrash_ll(parentID uint64, trashName, name string) error {
+	tr.restored = append(tr.restored, trashName+":"+name)
+	return nil
+}
+
+func TestRestoreTrash(t *testing.T) {
+	tr := &fakeTrashRestorer{
+		items: []*proto.TrashItem{
+			{ParentID: 1, Name: "a", TrashName: proto.TrashName("a", 200), Inode: 10, DeleteTime: 200},
+			{ParentID: 2, Name: "b", TrashName: proto.TrashName("b", 300), Inode: 10, DeleteTime: 300},
+			{ParentID: 1, Name: "c", TrashName: proto.TrashName("c", 400), Inode: 11, DeleteTime: 400},
+		},
+	}
+	item, err := restoreTrash(tr, 10, "")
+	if err != nil || item.ParentID != 2 {
+		t.Fatalf("restore trash: item(%v) err(%v)", item, err)
+	}
+	if len(tr.restored) != 1 || tr.restored[0] != proto.TrashName("b", 300)+":" {
+		t.Fatalf("restored: %v", tr.restored)
+	}
+	if _, err = restoreTrash(tr, 12, "x"); err == nil {
+		t.Fatalf("inode not in the trash is restored")
+	}
+}
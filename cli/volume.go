@@ -0,0 +1,552 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/storage"
+)
+
+// Partition types
+const (
+	PartitionData = "data"
+	PartitionMeta = "meta"
+)
+
+// Check results of a partition
+const (
+	CheckConsistent = "consistent"
+	CheckDiverged   = "diverged"
+	CheckSkipped    = "skipped"
+	CheckFailed     = "failed"
+)
+
+// Divergence kinds
+const (
+	DivergenceMissingReplica = "missingReplica"
+	DivergenceNoResponse     = "noResponse"
+	DivergenceMissingExtent  = "missingExtent"
+	DivergenceExtentSize     = "extentSize"
+	DivergenceExtentCrc      = "extentCrc"
+	DivergenceMaxInode       = "maxInode"
+	DivergenceDentryCount    = "dentryCount"
+)
+
+// emptyCrc is the crc of an extent without data, which is never compared, the same as the master.
+const emptyCrc uint32 = 4045511210
+
+func newVolumeCmd() *Command {
+	cmd := &Command{Name: "volume", Short: "inspect the volumes"}
+	cmd.AddCommand(
+		newVolumeCheckCmd(),
+		newVolumeAccessCmd(),
+		newVolumePlacementCmd(),
+		newVolumeShrinkCmd(),
+		newVolumeReleaseCmd(),
+	)
+	return cmd
+}
+
+func newVolumeCheckCmd() *Command {
+	cmd := &Command{
+		Name: "check",
+		Args: "<vol>",
+		Short: "load the replicas of the partitions of the volume through the master, and report " +
+			"the divergences between them",
+	}
+	typ := cmd.Flags().String("type", "all", "type of the partitions to check: all, data or meta")
+	concurrency := cmd.Flags().Int("concurrency", 4, "number of the partitions checked at the same time")
+	timeout := cmd.Flags().Duration("timeout", 2*time.Minute, "time to wait for the replicas of a partition to respond")
+	settle := cmd.Flags().Duration("settle", 20*time.Minute, "skip the extents modified within this duration")
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 1 || *concurrency <= 0 || (*typ != "all" && *typ != PartitionData && *typ != PartitionMeta) {
+			return ErrUsage
+		}
+		checker := &VolumeChecker{
+			API:         &masterCheckAPI{ctx.MasterClient()},
+			Data:        *typ != PartitionMeta,
+			Meta:        *typ != PartitionData,
+			Concurrency: *concurrency,
+			Timeout:     *timeout,
+			Settle:      *settle,
+			Interval:    time.Second,
+		}
+		var progress func(*PartitionCheck)
+		if ctx.Output == OutputTable {
+			fmt.Fprintf(ctx.Out, "%-5s %10s %-11s %v\n", "TYPE", "ID", "RESULT", "DETAIL")
+			progress = func(pc *PartitionCheck) { pc.Print(ctx.Out) }
+		}
+		check, err := checker.Check(args[0], progress)
+		if err != nil {
+			return err
+		}
+		err = ctx.Print(check, func(w io.Writer) {
+			fmt.Fprintf(w, "\n%v partitions: %v consistent, %v diverged, %v skipped, %v failed\n",
+				len(check.Partitions), check.Consistent, check.Diverged, check.Skipped, check.Failed)
+		})
+		if err == nil && check.Diverged+check.Failed > 0 {
+			err = fmt.Errorf("%v partitions diverged, %v failed to check", check.Diverged, check.Failed)
+		}
+		return err
+	}
+	return cmd
+}
+
+// CheckAPI is the part of the master API used by the volume check.
+type CheckAPI interface {
+	DataPartitions(vol string) ([]uint64, error)
+	MetaPartitions(vol string) ([]uint64, error)
+	LoadDataPartition(vol string, id uint64) error
+	GetDataPartition(vol string, id uint64) (*proto.DataPartitionInfo, error)
+	LoadMetaPartition(id uint64) error
+	GetMetaPartition(id uint64) (*proto.MetaPartitionInfo, error)
+}
+
+type masterCheckAPI struct {
+	mc *masterSDK.MasterClient
+}
+
+func (api *masterCheckAPI) DataPartitions(vol string) (ids []uint64, err error) {
+	view, err := api.mc.ClientAPI().GetDataPartitions(vol)
+	if err != nil {
+		return
+	}
+	for _, dp := range view.DataPartitions {
+		ids = append(ids, dp.PartitionID)
+	}
+	return
+}
+
+func (api *masterCheckAPI) MetaPartitions(vol string) (ids []uint64, err error) {
+	views, err := api.mc.ClientAPI().GetMetaPartitions(vol)
+	if err != nil {
+		return
+	}
+	for _, mp := range views {
+		ids = append(ids, mp.PartitionID)
+	}
+	return
+}
+
+func (api *masterCheckAPI) LoadDataPartition(vol string, id uint64) error {
+	return api.mc.AdminAPI().LoadDataPartition(vol, id)
+}
+
+func (api *masterCheckAPI) GetDataPartition(vol string, id uint64) (*proto.DataPartitionInfo, error) {
+	return api.mc.AdminAPI().GetDataPartition(vol, id)
+}
+
+func (api *masterCheckAPI) LoadMetaPartition(id uint64) error {
+	return api.mc.AdminAPI().LoadMetaPartition(id)
+}
+
+func (api *masterCheckAPI) GetMetaPartition(id uint64) (*proto.MetaPartitionInfo, error) {
+	return api.mc.ClientAPI().GetMetaPartition(id)
+}
+
+// Divergence is a difference between the replicas of a partition.
+type Divergence struct {
+	Kind   string `json:"kind"`
+	Extent uint64 `json:"extent,omitempty"`
+	// Replicas maps the replica addresses to the values reported by them.
+	Replicas map[string]string `json:"replicas"`
+	// Suspects are the replicas differing from the majority, or missing the extent.
+	Suspects []string `json:"suspects,omitempty"`
+}
+
+func (d *Divergence) String() string {
+	var sb strings.Builder
+	sb.WriteString(d.Kind)
+	if d.Extent > 0 {
+		fmt.Fprintf(&sb, " extent %v", d.Extent)
+	}
+	addrs := make([]string, 0, len(d.Replicas))
+	for addr := range d.Replicas {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		fmt.Fprintf(&sb, " %v=%v", addr, d.Replicas[addr])
+	}
+	if len(d.Suspects) > 0 {
+		fmt.Fprintf(&sb, " suspects %v", strings.Join(d.Suspects, ","))
+	}
+	return sb.String()
+}
+
+// PartitionCheck is the result of checking a partition.
+type PartitionCheck struct {
+	Type        string        `json:"type"`
+	ID          uint64        `json:"id"`
+	Result      string        `json:"result"`
+	Reason      string        `json:"reason,omitempty"`
+	Divergences []*Divergence `json:"divergences,omitempty"`
+}
+
+func (pc *PartitionCheck) diverge(d *Divergence) {
+	pc.Result = CheckDiverged
+	pc.Divergences = append(pc.Divergences, d)
+}
+
+func (pc *PartitionCheck) skip(reason string) *PartitionCheck {
+	pc.Result = CheckSkipped
+	pc.Reason = reason
+	return pc
+}
+
+func (pc *PartitionCheck) fail(err error) *PartitionCheck {
+	pc.Result = CheckFailed
+	pc.Reason = err.Error()
+	return pc
+}
+
+// Print writes the result as the lines of a table.
+func (pc *PartitionCheck) Print(w io.Writer) {
+	fmt.Fprintf(w, "%-5s %10d %-11s %v\n", pc.Type, pc.ID, pc.Result, pc.Reason)
+	for _, d := range pc.Divergences {
+		fmt.Fprintf(w, "%-5s %10s %-11s %v\n", "", "", "", d)
+	}
+}
+
+// VolumeCheck is the result of checking a volume.
+type VolumeCheck struct {
+	Volume     string            `json:"volume"`
+	Partitions []*PartitionCheck `json:"partitions"`
+	Consistent int               `json:"consistent"`
+	Diverged   int               `json:"diverged"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+}
+
+// VolumeChecker checks the partitions of a volume with the load operations of the master,
+// which make the replicas report their extents or inode and dentry counts, and compares the
+// reports. Nothing is repaired by the check; the datanodes repair the extents by themselves
+// and the suspects of a divergence can be decommissioned.
+type VolumeChecker struct {
+	API         CheckAPI
+	Data        bool
+	Meta        bool
+	Concurrency int
+	// Timeout is the time to wait for the replicas of a partition to report.
+	Timeout time.Duration
+	// Settle skips the extents modified recently, which may be still replicating.
+	Settle   time.Duration
+	Interval time.Duration
+}
+
+// Check checks the partitions of the volume, and calls progress with the result of each
+// partition as it is checked.
+func (c *VolumeChecker) Check(vol string, progress func(*PartitionCheck)) (check *VolumeCheck, err error) {
+	var pending []*PartitionCheck
+	if c.Data {
+		ids, err := c.API.DataPartitions(vol)
+		if err != nil {
+			return nil, fmt.Errorf("get data partitions of %v: %v", vol, err)
+		}
+		for _, id := range ids {
+			pending = append(pending, &PartitionCheck{Type: PartitionData, ID: id})
+		}
+	}
+	if c.Meta {
+		ids, err := c.API.MetaPartitions(vol)
+		if err != nil {
+			return nil, fmt.Errorf("get meta partitions of %v: %v", vol, err)
+		}
+		for _, id := range ids {
+			pending = append(pending, &PartitionCheck{Type: PartitionMeta, ID: id})
+		}
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	queue := make(chan *PartitionCheck)
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pc := range queue {
+				if pc.Type == PartitionData {
+					c.checkDataPartition(vol, pc)
+				} else {
+					c.checkMetaPartition(pc)
+				}
+				if progress != nil {
+					mu.Lock()
+					progress(pc)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, pc := range pending {
+		queue <- pc
+	}
+	close(queue)
+	wg.Wait()
+
+	check = &VolumeCheck{Volume: vol, Partitions: pending}
+	for _, pc := range pending {
+		switch pc.Result {
+		case CheckConsistent:
+			check.Consistent++
+		case CheckDiverged:
+			check.Diverged++
+		case CheckSkipped:
+			check.Skipped++
+		default:
+			check.Failed++
+		}
+	}
+	return
+}
+
+func (c *VolumeChecker) checkDataPartition(vol string, pc *PartitionCheck) *PartitionCheck {
+	dp, err := c.API.GetDataPartition(vol, pc.ID)
+	if err != nil {
+		return pc.fail(err)
+	}
+	lastLoaded := dp.LastLoadedTime
+	if err = c.API.LoadDataPartition(vol, pc.ID); err != nil {
+		return pc.fail(err)
+	}
+	// the master loads the partition asynchronously, and refuses to load it while it is
+	// recovering, in which case the loaded time is left unchanged.
+	for deadline := time.Now().Add(c.Timeout); ; {
+		time.Sleep(c.Interval)
+		if dp, err = c.API.GetDataPartition(vol, pc.ID); err != nil {
+			return pc.fail(err)
+		}
+		if dp.LastLoadedTime != lastLoaded && allLoaded(dp) {
+			break
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+	}
+	if dp.LastLoadedTime == lastLoaded {
+		return pc.skip("not loaded by the master, the partition may be recovering")
+	}
+	return compareDataReplicas(pc, dp, time.Now().Add(-c.Settle).Unix())
+}
+
+func allLoaded(dp *proto.DataPartitionInfo) bool {
+	for _, replica := range dp.Replicas {
+		if !replica.HasLoadResponse {
+			return false
+		}
+	}
+	return true
+}
+
+// compareDataReplicas compares the extents reported by the replicas of the data partition,
+// except the ones modified after settled.
+func compareDataReplicas(pc *PartitionCheck, dp *proto.DataPartitionInfo, settled int64) *PartitionCheck {
+	pc.Result = CheckConsistent
+	addMissingNodes(pc, dp.MissingNodes)
+
+	loaded := make([]string, 0, len(dp.Replicas))
+	noResponse := make(map[string]string)
+	for _, replica := range dp.Replicas {
+		if replica.HasLoadResponse {
+			loaded = append(loaded, replica.Addr)
+		} else {
+			noResponse[replica.Addr] = "noResponse"
+		}
+	}
+	if len(noResponse) > 0 {
+		pc.diverge(&Divergence{Kind: DivergenceNoResponse, Replicas: noResponse, Suspects: sortedKeys(noResponse)})
+	}
+	if len(loaded) < 2 {
+		return pc
+	}
+
+	names := make([]string, 0, len(dp.FileInCoreMap))
+	for name := range dp.FileInCoreMap {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return extentID(names[i]) < extentID(names[j]) })
+	for _, name := range names {
+		fc := dp.FileInCoreMap[name]
+		id := extentID(name)
+		if id == 0 || fc.LastModify > settled {
+			continue
+		}
+		metas := make(map[string]*proto.FileMetadata)
+		for _, fm := range fc.MetadataArray {
+			metas[fm.LocAddr] = fm
+		}
+		sizes := make(map[string]string)
+		crcs := make(map[string]string)
+		var missing []string
+		comparable := true
+		for _, addr := range loaded {
+			fm, ok := metas[addr]
+			if !ok {
+				missing = append(missing, addr)
+				continue
+			}
+			sizes[addr] = strconv.FormatUint(uint64(fm.Size), 10)
+			crcs[addr] = strconv.FormatUint(uint64(fm.Crc), 10)
+			if fm.Crc == 0 || fm.Crc == emptyCrc {
+				comparable = false
+			}
+		}
+		switch {
+		case len(missing) > 0 && !storage.IsTinyExtent(id):
+			pc.diverge(&Divergence{Kind: DivergenceMissingExtent, Extent: id, Replicas: sizes, Suspects: missing})
+		case len(missing) > 0:
+			// tiny extents are created on each replica as needed
+		case distinct(sizes) > 1:
+			pc.diverge(&Divergence{Kind: DivergenceExtentSize, Extent: id, Replicas: sizes, Suspects: minority(sizes)})
+		case comparable && distinct(crcs) > 1:
+			pc.diverge(&Divergence{Kind: DivergenceExtentCrc, Extent: id, Replicas: crcs, Suspects: minority(crcs)})
+		}
+	}
+	return pc
+}
+
+func (c *VolumeChecker) checkMetaPartition(pc *PartitionCheck) *PartitionCheck {
+	if err := c.API.LoadMetaPartition(pc.ID); err != nil {
+		return pc.fail(err)
+	}
+	var (
+		mp  *proto.MetaPartitionInfo
+		err error
+	)
+	// the responses of the last load are cleared by the master before loading again.
+	for deadline := time.Now().Add(c.Timeout); ; {
+		time.Sleep(c.Interval)
+		if mp, err = c.API.GetMetaPartition(pc.ID); err != nil {
+			return pc.fail(err)
+		}
+		if len(mp.LoadResponse) >= len(mp.Hosts) || time.Now().After(deadline) {
+			break
+		}
+	}
+	return compareMetaReplicas(pc, mp)
+}
+
+// compareMetaReplicas compares the max inode and the dentry count reported by the replicas
+// of the meta partition, which are only comparable at the same apply id.
+func compareMetaReplicas(pc *PartitionCheck, mp *proto.MetaPartitionInfo) *PartitionCheck {
+	if mp.IsRecover {
+		return pc.skip("the partition is recovering")
+	}
+	pc.Result = CheckConsistent
+	addMissingNodes(pc, mp.MissNodes)
+
+	responses := make(map[string]*proto.MetaPartitionLoadResponse)
+	for _, lr := range mp.LoadResponse {
+		responses[lr.Addr] = lr
+	}
+	noResponse := make(map[string]string)
+	applyIDs := make(map[string]string)
+	inodes := make(map[string]string)
+	dentries := make(map[string]string)
+	for _, host := range mp.Hosts {
+		lr, ok := responses[host]
+		if !ok {
+			noResponse[host] = "noResponse"
+			continue
+		}
+		if !lr.DoCompare {
+			return pc.skip(fmt.Sprintf("replica %v is not ready to compare", host))
+		}
+		applyIDs[host] = strconv.FormatUint(lr.ApplyID, 10)
+		inodes[host] = strconv.FormatUint(lr.MaxInode, 10)
+		dentries[host] = strconv.FormatUint(lr.DentryCount, 10)
+	}
+	if len(noResponse) > 0 {
+		pc.diverge(&Divergence{Kind: DivergenceNoResponse, Replicas: noResponse, Suspects: sortedKeys(noResponse)})
+	}
+	if distinct(applyIDs) > 1 {
+		if pc.Result == CheckConsistent {
+			pc.skip("the replicas are at different apply ids, retry when the partition is idle")
+		}
+		return pc
+	}
+	if distinct(inodes) > 1 {
+		pc.diverge(&Divergence{Kind: DivergenceMaxInode, Replicas: inodes, Suspects: minority(inodes)})
+	}
+	if distinct(dentries) > 1 {
+		pc.diverge(&Divergence{Kind: DivergenceDentryCount, Replicas: dentries, Suspects: minority(dentries)})
+	}
+	return pc
+}
+
+func addMissingNodes(pc *PartitionCheck, missing map[string]int64) {
+	if len(missing) == 0 {
+		return
+	}
+	replicas := make(map[string]string)
+	for addr, since := range missing {
+		replicas[addr] = "missing since " + formatTime(time.Unix(since, 0))
+	}
+	pc.diverge(&Divergence{Kind: DivergenceMissingReplica, Replicas: replicas, Suspects: sortedKeys(replicas)})
+}
+
+func extentID(name string) uint64 {
+	id, _ := strconv.ParseUint(name, 10, 64)
+	return id
+}
+
+func distinct(values map[string]string) int {
+	set := make(map[string]bool)
+	for _, v := range values {
+		set[v] = true
+	}
+	return len(set)
+}
+
+// minority returns the replicas whose values differ from the value of the majority, or nil
+// if there is no majority.
+func minority(values map[string]string) (suspects []string) {
+	counts := make(map[string]int)
+	for _, v := range values {
+		counts[v]++
+	}
+	for major, count := range counts {
+		if count*2 <= len(values) {
+			continue
+		}
+		for addr, v := range values {
+			if v != major {
+				suspects = append(suspects, addr)
+			}
+		}
+		sort.Strings(suspects)
+	}
+	return
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,174 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+type fakeCheckAPI struct {
+	mu  sync.Mutex
+	dps map[uint64]*proto.DataPartitionInfo
+	mps map[uint64]*proto.MetaPartitionInfo
+	// loaded is the data partition info returned after loading.
+	loaded map[uint64]*proto.DataPartitionInfo
+}
+
+func (api *fakeCheckAPI) DataPartitions(vol string) (ids []uint64, err error) {
+	for id := range api.dps {
+		ids = append(ids, id)
+	}
+	return
+}
+
+func (api *fakeCheckAPI) MetaPartitions(vol string) (ids []uint64, err error) {
+	for id := range api.mps {
+		ids = append(ids, id)
+	}
+	return
+}
+
+func (api *fakeCheckAPI) LoadDataPartition(vol string, id uint64) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if loaded, ok := api.loaded[id]; ok {
+		api.dps[id] = loaded
+	}
+	return nil
+}
+
+func (api *fakeCheckAPI) GetDataPartition(vol string, id uint64) (*proto.DataPartitionInfo, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.dps[id], nil
+}
+
+func (api *fakeCheckAPI) LoadMetaPartition(id uint64) error {
+	return nil
+}
+
+func (api *fakeCheckAPI) GetMetaPartition(id uint64) (*proto.MetaPartitionInfo, error) {
+	return api.mps[id], nil
+}
+
+func loadedReplicas(addrs ...string) (replicas []*proto.DataReplica) {
+	for _, addr := range addrs {
+		replicas = append(replicas, &proto.DataReplica{Addr: addr, HasLoadResponse: true})
+	}
+	return
+}
+
+func extent(name string, metas ...*proto.FileMetadata) *proto.FileInCore {
+	return &proto.FileInCore{Name: name, MetadataArray: metas}
+}
+
+func TestCompareDataReplicas(t *testing.T) {
+	dp := &proto.DataPartitionInfo{
+		PartitionID: 1,
+		Replicas:    loadedReplicas("a", "b", "c"),
+		FileInCoreMap: map[string]*proto.FileInCore{
+			"1025": extent("1025", &proto.FileMetadata{LocAddr: "a", Crc: 1, Size: 8},
+				&proto.FileMetadata{LocAddr: "b", Crc: 1, Size: 8}, &proto.FileMetadata{LocAddr: "c", Crc: 2, Size: 8}),
+			"1026": extent("1026", &proto.FileMetadata{LocAddr: "a", Crc: 1, Size: 8},
+				&proto.FileMetadata{LocAddr: "b", Crc: 1, Size: 8}),
+			"1027": extent("1027", &proto.FileMetadata{LocAddr: "a", Crc: 1, Size: 8},
+				&proto.FileMetadata{LocAddr: "b", Crc: 1, Size: 9}, &proto.FileMetadata{LocAddr: "c", Crc: 1, Size: 10}),
+			"1028": extent("1028", &proto.FileMetadata{LocAddr: "a", Crc: emptyCrc, Size: 8},
+				&proto.FileMetadata{LocAddr: "b", Crc: 1, Size: 8}, &proto.FileMetadata{LocAddr: "c", Crc: 1, Size: 8}),
+			"1": extent("1", &proto.FileMetadata{LocAddr: "a", Crc: 1, Size: 8}),
+		},
+	}
+	dp.FileInCoreMap["1029"] = extent("1029", &proto.FileMetadata{LocAddr: "a", Crc: 1, Size: 8})
+	dp.FileInCoreMap["1029"].LastModify = 100
+
+	pc := compareDataReplicas(&PartitionCheck{Type: PartitionData, ID: 1}, dp, 99)
+	if pc.Result != CheckDiverged || len(pc.Divergences) != 3 {
+		t.Fatalf("unexpected check %+v", pc)
+	}
+	expected := []struct {
+		kind     string
+		extent   uint64
+		suspects []string
+	}{
+		{DivergenceExtentCrc, 1025, []string{"c"}},
+		{DivergenceMissingExtent, 1026, []string{"c"}},
+		{DivergenceExtentSize, 1027, nil},
+	}
+	for i, e := range expected {
+		d := pc.Divergences[i]
+		if d.Kind != e.kind || d.Extent != e.extent || !reflect.DeepEqual(d.Suspects, e.suspects) {
+			t.Fatalf("divergence %v: unexpected %v", i, d)
+		}
+	}
+}
+
+func TestCompareMetaReplicas(t *testing.T) {
+	response := func(addr string, applyID, maxInode, dentries uint64) *proto.MetaPartitionLoadResponse {
+		return &proto.MetaPartitionLoadResponse{Addr: addr, DoCompare: true, ApplyID: applyID, MaxInode: maxInode, DentryCount: dentries}
+	}
+	mp := &proto.MetaPartitionInfo{
+		PartitionID: 1,
+		Hosts:       []string{"a", "b", "c"},
+		LoadResponse: []*proto.MetaPartitionLoadResponse{
+			response("a", 10, 100, 7), response("b", 10, 100, 7), response("c", 10, 101, 7),
+		},
+	}
+	pc := compareMetaReplicas(&PartitionCheck{}, mp)
+	if pc.Result != CheckDiverged || len(pc.Divergences) != 1 || pc.Divergences[0].Kind != DivergenceMaxInode ||
+		!reflect.DeepEqual(pc.Divergences[0].Suspects, []string{"c"}) {
+		t.Fatalf("unexpected check %+v", pc)
+	}
+
+	mp.LoadResponse[2] = response("c", 11, 101, 8)
+	if pc = compareMetaReplicas(&PartitionCheck{}, mp); pc.Result != CheckSkipped {
+		t.Fatalf("different apply ids should be skipped, got %+v", pc)
+	}
+
+	mp.LoadResponse = mp.LoadResponse[:2]
+	pc = compareMetaReplicas(&PartitionCheck{}, mp)
+	if pc.Result != CheckDiverged || pc.Divergences[0].Kind != DivergenceNoResponse {
+		t.Fatalf("unexpected check %+v", pc)
+	}
+}
+
+func TestVolumeCheck(t *testing.T) {
+	api := &fakeCheckAPI{
+		dps: map[uint64]*proto.DataPartitionInfo{
+			1: {PartitionID: 1, Replicas: loadedReplicas("a", "b")},
+			2: {PartitionID: 2, Replicas: loadedReplicas("a", "b")},
+		},
+		mps: map[uint64]*proto.MetaPartitionInfo{
+			3: {PartitionID: 3, IsRecover: true},
+		},
+		loaded: map[uint64]*proto.DataPartitionInfo{
+			1: {PartitionID: 1, LastLoadedTime: 1, Replicas: loadedReplicas("a", "b"),
+				MissingNodes: map[string]int64{"c": 1}},
+		},
+	}
+	checker := &VolumeChecker{API: api, Data: true, Meta: true, Concurrency: 2, Timeout: 10 * time.Millisecond}
+	var checked []*PartitionCheck
+	check, err := checker.Check("vol", func(pc *PartitionCheck) { checked = append(checked, pc) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checked) != 3 || check.Diverged != 1 || check.Skipped != 2 {
+		t.Fatalf("unexpected check %+v", check)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+const clearScreen = "\033[H\033[2J"
+
+// refreshFunc returns the view to print and the function printing it as a table.
+type refreshFunc func() (view interface{}, table func(w io.Writer), err error)
+
+// watch prints the view once if the interval is not positive. Otherwise it refreshes the
+// view every interval until interrupted, redrawing the screen for the table output and
+// printing one document per refresh for the others.
+func watch(ctx *Context, interval time.Duration, refresh refreshFunc) error {
+	if interval <= 0 {
+		view, table, err := refresh()
+		if err != nil {
+			return err
+		}
+		return ctx.Print(view, table)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		view, table, err := refresh()
+		if ctx.Output == OutputTable {
+			fmt.Fprint(ctx.Out, clearScreen)
+			fmt.Fprintf(ctx.Out, "every %v: %v\n\n", interval, formatTime(time.Now()))
+		}
+		// a failed refresh is shown and retried, as the nodes may come back
+		if err != nil {
+			fmt.Fprintf(ctx.Err, "error: %v\n", err)
+		} else if err = ctx.Print(view, table); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// OpRate is the throughput and the latency of an operation between two polls of the op stats.
+type OpRate struct {
+	Op         string        `json:"op"`
+	OpsPerSec  float64       `json:"opsPerSec"`
+	Errors     uint64        `json:"errors"`
+	AvgLatency time.Duration `json:"avgLatency"`
+	MaxLatency time.Duration `json:"maxLatency"`
+}
+
+// opRates returns the rates of the operations done in the elapsed time between the two polls.
+func opRates(after, before []metrics.OpStat, elapsed time.Duration) []*OpRate {
+	rates := make([]*OpRate, 0)
+	if elapsed <= 0 {
+		return rates
+	}
+	for _, stat := range metrics.Sub(after, before) {
+		rates = append(rates, &OpRate{
+			Op:         stat.Op,
+			OpsPerSec:  float64(stat.Count) / elapsed.Seconds(),
+			Errors:     stat.Errors,
+			AvgLatency: stat.AvgLatency(),
+			MaxLatency: stat.MaxLatency,
+		})
+	}
+	return rates
+}
+
+// opStatsPoller keeps the last poll of the op stats of a node to compute the rates.
+type opStatsPoller struct {
+	url    string
+	last   []metrics.OpStat
+	polled time.Time
+}
+
+// poll returns the rates since the last poll, which are empty for the first one.
+func (p *opStatsPoller) poll() (rates []*OpRate, err error) {
+	stats, err := getOpStats(p.url)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if p.last == nil {
+		rates = make([]*OpRate, 0)
+	} else {
+		rates = opRates(stats, p.last, now.Sub(p.polled))
+	}
+	p.last, p.polled = stats, now
+	return
+}
+
+func printOpRates(w io.Writer, rates []*OpRate) {
+	fmt.Fprintf(w, "%-24s %10s %8s %12s %12s\n", "OP", "OPS/S", "ERRORS", "AVG LATENCY", "MAX LATENCY")
+	for _, r := range rates {
+		fmt.Fprintf(w, "%-24s %10.1f %8d %12v %12v\n", r.Op, r.OpsPerSec, r.Errors, r.AvgLatency, r.MaxLatency)
+	}
+}
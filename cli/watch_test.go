@@ -0,0 +1,71 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+func TestOpStatsPoller(t *testing.T) {
+	count := uint64(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count += 20
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": http.StatusOK,
+			"data": []metrics.OpStat{
+				{Op: "read", Count: count, Errors: 1, TotalTime: time.Duration(count) * time.Millisecond},
+				{Op: "write", Count: 5},
+			},
+		})
+	}))
+	defer server.Close()
+
+	poller := &opStatsPoller{url: server.URL}
+	rates, err := poller.poll()
+	if err != nil || len(rates) != 0 {
+		t.Fatalf("first poll: rates %v err %v", rates, err)
+	}
+	poller.polled = poller.polled.Add(-2 * time.Second)
+	if rates, err = poller.poll(); err != nil {
+		t.Fatal(err)
+	}
+	// only the read ops were done since the first poll
+	if len(rates) != 1 || rates[0].Op != "read" || rates[0].Errors != 0 || rates[0].AvgLatency != time.Millisecond {
+		t.Fatalf("unexpected rates %+v", rates)
+	}
+	if rates[0].OpsPerSec < 9 || rates[0].OpsPerSec > 10 {
+		t.Fatalf("unexpected ops per second %v", rates[0].OpsPerSec)
+	}
+}
+
+func TestWatchOnce(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := &Context{Output: OutputTable, Out: out, Err: out}
+	err := watch(ctx, 0, func() (interface{}, func(w io.Writer), error) {
+		return nil, func(w io.Writer) { io.WriteString(w, "view\n") }, nil
+	})
+	if err != nil || strings.Contains(out.String(), clearScreen) || out.String() != "view\n" {
+		t.Fatalf("unexpected output %q err %v", out.String(), err)
+	}
+}
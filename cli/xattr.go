@@ -0,0 +1,297 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+)
+
+func newXAttrCmd() *Command {
+	cmd := &Command{Name: "xattr", Short: "view and edit the extended attributes of a path without mounting the volume"}
+	cmd.AddCommand(
+		newXAttrListCmd(),
+		newXAttrGetCmd(),
+		newXAttrSetCmd(),
+		newXAttrDelCmd(),
+	)
+	return cmd
+}
+
+// xattrView is the output schema of the extended attributes of a path.
+type xattrView struct {
+	Path   string            `json:"path"`
+	Inode  uint64            `json:"inode"`
+	XAttrs map[string]string `json:"xattrs"`
+}
+
+func newXAttrListCmd() *Command {
+	cmd := &Command{Name: "list", Args: "<vol> <path>", Short: "list the extended attributes of the path"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		mw, ino, err := lookupVolumePath(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		info, err := mw.XAttrsList_ll(ino)
+		if err != nil {
+			return fmt.Errorf("list xattrs of %v: %v", args[1], err)
+		}
+		for name, value := range info.XAttrs {
+			if isACLXAttr(name) {
+				if acl, err := proto.DecodeACL([]byte(value)); err == nil {
+					info.XAttrs[name] = formatACL(acl)
+				}
+			}
+		}
+		return printXAttrs(ctx, args[1], info)
+	}
+	return cmd
+}
+
+func newXAttrGetCmd() *Command {
+	cmd := &Command{Name: "get", Args: "<vol> <path> <name>", Short: "get an extended attribute of the path"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 3 {
+			return ErrUsage
+		}
+		mw, ino, err := lookupVolumePath(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		var info *proto.XAttrInfo
+		if isACLXAttr(args[2]) {
+			info, err = getACLXAttr(mw, ino, args[2])
+		} else {
+			info, err = mw.XAttrGet_ll(ino, args[2])
+		}
+		if err != nil {
+			return fmt.Errorf("get xattr %v of %v: %v", args[2], args[1], err)
+		}
+		return printXAttrs(ctx, args[1], info)
+	}
+	return cmd
+}
+
+func newXAttrSetCmd() *Command {
+	cmd := &Command{Name: "set", Args: "<vol> <path> <name> <value>", Short: "set an extended attribute of the path"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 4 {
+			return ErrUsage
+		}
+		mw, ino, err := lookupVolumePath(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if isACLXAttr(args[2]) {
+			acl, err := parseACL(args[3])
+			if err != nil {
+				return fmt.Errorf("parse acl %v: %v", args[3], err)
+			}
+			err = mw.SetACL_ll(ino, args[2] == proto.XAttrPosixACLDefault, acl)
+		} else {
+			err = mw.XAttrSet_ll(ino, []byte(args[2]), []byte(args[3]))
+		}
+		if err != nil {
+			return fmt.Errorf("set xattr %v of %v: %v", args[2], args[1], err)
+		}
+		return nil
+	}
+	return cmd
+}
+
+func newXAttrDelCmd() *Command {
+	cmd := &Command{Name: "del", Args: "<vol> <path> <name>", Short: "delete an extended attribute of the path"}
+	cmd.Run = func(ctx *Context, args []string) error {
+		if len(args) != 3 {
+			return ErrUsage
+		}
+		mw, ino, err := lookupVolumePath(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if isACLXAttr(args[2]) {
+			err = mw.SetACL_ll(ino, args[2] == proto.XAttrPosixACLDefault, nil)
+		} else {
+			err = mw.XAttrDel_ll(ino, args[2])
+		}
+		if err != nil {
+			return fmt.Errorf("delete xattr %v of %v: %v", args[2], args[1], err)
+		}
+		return nil
+	}
+	return cmd
+}
+
+// isACLXAttr returns true if the xattr is a POSIX ACL, which is read and written through the ACL
+// APIs of the metanodes, so that the mode of the inode is kept in sync and the ACL is validated.
+func isACLXAttr(name string) bool {
+	return name == proto.XAttrPosixACLAccess || name == proto.XAttrPosixACLDefault
+}
+
+func getACLXAttr(mw *meta.MetaWrapper, ino uint64, name string) (*proto.XAttrInfo, error) {
+	resp, err := mw.GetACL_ll(ino)
+	if err != nil {
+		return nil, err
+	}
+	acl := resp.Access
+	if name == proto.XAttrPosixACLDefault {
+		acl = resp.Default
+	}
+	if len(acl) == 0 {
+		return nil, syscall.ENODATA
+	}
+	return &proto.XAttrInfo{Inode: ino, XAttrs: map[string]string{name: formatACL(acl)}}, nil
+}
+
+var aclTagNames = map[uint16]string{
+	proto.ACLUserObj:  "user",
+	proto.ACLUser:     "user",
+	proto.ACLGroupObj: "group",
+	proto.ACLGroup:    "group",
+	proto.ACLMask:     "mask",
+	proto.ACLOther:    "other",
+}
+
+// formatACL formats the ACL in the short text form of getfacl, such as
+// user::rw-,user:1000:r--,group::r--,mask::r--,other::---.
+func formatACL(acl proto.ACL) string {
+	entries := make([]string, 0, len(acl))
+	for _, e := range acl {
+		var qualifier string
+		if e.Tag == proto.ACLUser || e.Tag == proto.ACLGroup {
+			qualifier = strconv.FormatUint(uint64(e.ID), 10)
+		}
+		perm := []byte("---")
+		if e.Perm&proto.ACLRead != 0 {
+			perm[0] = 'r'
+		}
+		if e.Perm&proto.ACLWrite != 0 {
+			perm[1] = 'w'
+		}
+		if e.Perm&proto.ACLExecute != 0 {
+			perm[2] = 'x'
+		}
+		entries = append(entries, aclTagNames[e.Tag]+":"+qualifier+":"+string(perm))
+	}
+	return strings.Join(entries, ",")
+}
+
+// parseACL parses the ACL in the short text form of setfacl, whose tags may be abbreviated to
+// u, g, m and o, and whose named users and groups are given by the numeric IDs.
+func parseACL(text string) (acl proto.ACL, err error) {
+	for _, entry := range strings.Split(text, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid entry %q", entry)
+		}
+		e := proto.ACLEntry{}
+		named := fields[1] != ""
+		switch fields[0] {
+		case "u", "user":
+			e.Tag = proto.ACLUserObj
+			if named {
+				e.Tag = proto.ACLUser
+			}
+		case "g", "group":
+			e.Tag = proto.ACLGroupObj
+			if named {
+				e.Tag = proto.ACLGroup
+			}
+		case "m", "mask":
+			e.Tag = proto.ACLMask
+		case "o", "other":
+			e.Tag = proto.ACLOther
+		default:
+			return nil, fmt.Errorf("invalid tag of entry %q", entry)
+		}
+		if named {
+			if e.Tag != proto.ACLUser && e.Tag != proto.ACLGroup {
+				return nil, fmt.Errorf("qualifier of entry %q", entry)
+			}
+			id, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid id of entry %q", entry)
+			}
+			e.ID = uint32(id)
+		}
+		for _, c := range fields[2] {
+			switch c {
+			case 'r':
+				e.Perm |= proto.ACLRead
+			case 'w':
+				e.Perm |= proto.ACLWrite
+			case 'x':
+				e.Perm |= proto.ACLExecute
+			case '-':
+			default:
+				return nil, fmt.Errorf("invalid permission of entry %q", entry)
+			}
+		}
+		acl = append(acl, e)
+	}
+	if err = acl.Validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func printXAttrs(ctx *Context, path string, info *proto.XAttrInfo) error {
+	view := &xattrView{Path: path, Inode: info.Inode, XAttrs: info.XAttrs}
+	return ctx.Print(view, func(w io.Writer) {
+		names := make([]string, 0, len(view.XAttrs))
+		for name := range view.XAttrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "# path: %v\n# inode: %v\n", view.Path, view.Inode)
+		for _, name := range names {
+			fmt.Fprintf(w, "%v=%q\n", name, view.XAttrs[name])
+		}
+	})
+}
+
+func lookupVolumePath(ctx *Context, vol, path string) (mw *meta.MetaWrapper, ino uint64, err error) {
+	if mw, err = ctx.MetaWrapper(vol); err != nil {
+		return
+	}
+	if ino, err = lookupPath(mw, path); err != nil {
+		err = fmt.Errorf("lookup %v: %v", path, err)
+	}
+	return
+}
+
+// lookupPath returns the inode of the path, relative to the root of the volume.
+func lookupPath(mw *meta.MetaWrapper, path string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if ino, _, err = mw.Lookup_ll(ino, name); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestParseACL(t *testing.T) {
+	acl, err := parseACL("u::rw-,u:1000:r--,g::r-x,m::rwx,o::---")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := proto.ACL{
+		{Tag: proto.ACLUserObj, Perm: proto.ACLRead | proto.ACLWrite},
+		{Tag: proto.ACLUser, Perm: proto.ACLRead, ID: 1000},
+		{Tag: proto.ACLGroupObj, Perm: proto.ACLRead | proto.ACLExecute},
+		{Tag: proto.ACLMask, Perm: proto.ACLRead | proto.ACLWrite | proto.ACLExecute},
+		{Tag: proto.ACLOther},
+	}
+	if !reflect.DeepEqual(acl, expected) {
+		t.Fatalf("parsed %v, expected %v", acl, expected)
+	}
+	text := formatACL(acl)
+	if text != "user::rw-,user:1000:r--,group::r-x,mask::rwx,other::---" {
+		t.Fatalf("formatted %v", text)
+	}
+	if again, err := parseACL(text); err != nil || !reflect.DeepEqual(again, acl) {
+		t.Fatalf("parse the formatted %v: %v %v", text, again, err)
+	}
+
+	for _, invalid := range []string{
+		"u::rw-,g::r--",                   // no other
+		"u::rw-,u:1000:r--,g::r--,o::---", // no mask
+		"u::rw-,g::r--,o::rwz",
+		"u::rw-,g::r--,o:1:---",
+		"u::rw-,u:bob:r--,g::r--,m::r--,o::---",
+		"x::rw-,g::r--,o::---",
+	} {
+		if _, err = parseACL(invalid); err == nil {
+			t.Fatalf("parse %v: no error", invalid)
+		}
+	}
+}
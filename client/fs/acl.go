@@ -0,0 +1,171 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"os"
+	"syscall"
+
+	"bazil.org/fuse"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The POSIX ACLs are enforced by the kernel, which checks the permissions by the ACLs served as
+// the extended attributes, so that the supplementary groups of the callers are checked as well.
+// The client only serves the ACLs, and makes the inodes created inherit the default ACLs.
+//
+// Other extended attributes are not supported, but the errors other than ENOSYS are returned
+// once the POSIX ACLs are enabled, since the kernel stops sending any xattr requests of the kind
+// once ENOSYS is returned.
+
+// InodeGetACL returns the inode with the ACLs, which are cached with the inode.
+func (s *Super) InodeGetACL(ino uint64) (*Inode, error) {
+	inode := s.ic.Get(ino)
+	if inode != nil && inode.aclLoaded {
+		return inode, nil
+	}
+	resp, err := s.mw.GetACL_ll(ino)
+	if err != nil {
+		log.LogErrorf("InodeGetACL: ino(%v) err(%v)", ino, err)
+		return nil, ParseError(err)
+	}
+	if inode == nil {
+		s.ec.RefreshExtentsCache(ino)
+		s.ec.SetModifyTime(ino, resp.Info.ModifyTime)
+	}
+	inode = NewInode(resp.Info)
+	inode.aclLoaded, inode.acl, inode.defaultACL = true, resp.Access, resp.Default
+	s.ic.Put(inode)
+	return inode, nil
+}
+
+// inheritACL returns the mode of the inode created under the directory, and the ACLs inherited
+// from the default ACL of the directory if any. The umask is applied otherwise, which is not
+// applied by the kernel once the POSIX ACLs are enabled.
+func (d *Dir) inheritACL(mode, umask os.FileMode) (os.FileMode, proto.ACL, proto.ACL, error) {
+	if !d.super.posixACL {
+		return mode, nil, nil, nil
+	}
+	parent, err := d.super.InodeGetACL(d.inode.ino)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if len(parent.defaultACL) == 0 {
+		return mode &^ umask, nil, nil, nil
+	}
+	access := parent.defaultACL.Inherit(proto.Mode(mode))
+	var defaultACL proto.ACL
+	if mode.IsDir() {
+		defaultACL = parent.defaultACL
+	}
+	return mode&^os.ModePerm | proto.OsMode(access.Mode()), access, defaultACL, nil
+}
+
+// setInheritedACL sets the ACLs inherited by the inode created, the access ACL equivalent to
+// the mode is not set.
+func (s *Super) setInheritedACL(ino uint64, access, defaultACL proto.ACL) error {
+	if len(access) > 0 && !access.IsMinimal() {
+		if err := s.mw.SetACL_ll(ino, false, access); err != nil {
+			log.LogErrorf("setInheritedACL: ino(%v) acl(%v) err(%v)", ino, access, err)
+			return ParseError(err)
+		}
+	}
+	if len(defaultACL) > 0 {
+		if err := s.mw.SetACL_ll(ino, true, defaultACL); err != nil {
+			log.LogErrorf("setInheritedACL: ino(%v) default acl(%v) err(%v)", ino, defaultACL, err)
+			return ParseError(err)
+		}
+	}
+	return nil
+}
+
+func (s *Super) getxattr(ino uint64, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	if !s.posixACL {
+		return fuse.ENOSYS
+	}
+	if req.Name != proto.XAttrPosixACLAccess && req.Name != proto.XAttrPosixACLDefault {
+		return fuse.ErrNoXattr
+	}
+	inode, err := s.InodeGetACL(ino)
+	if err != nil {
+		return err
+	}
+	acl := inode.acl
+	if req.Name == proto.XAttrPosixACLDefault {
+		acl = inode.defaultACL
+	}
+	if len(acl) == 0 {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = acl.Encode()
+	return nil
+}
+
+func (s *Super) listxattr(ino uint64, resp *fuse.ListxattrResponse) error {
+	if !s.posixACL {
+		return fuse.ENOSYS
+	}
+	inode, err := s.InodeGetACL(ino)
+	if err != nil {
+		return err
+	}
+	if len(inode.acl) > 0 {
+		resp.Append(proto.XAttrPosixACLAccess)
+	}
+	if len(inode.defaultACL) > 0 {
+		resp.Append(proto.XAttrPosixACLDefault)
+	}
+	return nil
+}
+
+// setxattr sets the ACL, whose permission is checked by the kernel.
+func (s *Super) setxattr(ino uint64, req *fuse.SetxattrRequest) error {
+	if !s.posixACL {
+		return fuse.ENOSYS
+	}
+	if req.Name != proto.XAttrPosixACLAccess && req.Name != proto.XAttrPosixACLDefault {
+		return fuse.ENOTSUP
+	}
+	acl, err := proto.DecodeACL(req.Xattr)
+	if err != nil {
+		log.LogErrorf("setxattr: ino(%v) name(%v) err(%v)", ino, req.Name, err)
+		return fuse.Errno(syscall.EINVAL)
+	}
+	err = s.mw.SetACL_ll(ino, req.Name == proto.XAttrPosixACLDefault, acl)
+	s.ic.Delete(ino)
+	if err != nil {
+		log.LogErrorf("setxattr: ino(%v) name(%v) err(%v)", ino, req.Name, err)
+		return ParseError(err)
+	}
+	return nil
+}
+
+func (s *Super) removexattr(ino uint64, req *fuse.RemovexattrRequest) error {
+	if !s.posixACL {
+		return fuse.ENOSYS
+	}
+	if req.Name != proto.XAttrPosixACLAccess && req.Name != proto.XAttrPosixACLDefault {
+		return fuse.ErrNoXattr
+	}
+	err := s.mw.SetACL_ll(ino, req.Name == proto.XAttrPosixACLDefault, nil)
+	s.ic.Delete(ino)
+	if err != nil {
+		log.LogErrorf("removexattr: ino(%v) name(%v) err(%v)", ino, req.Name, err)
+		return ParseError(err)
+	}
+	return nil
+}
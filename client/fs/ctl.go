@@ -0,0 +1,220 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+// The control directory is a read-only virtual directory under the root of the mount,
+// which reports the runtime statistics of the client like /proc. It is not listed in
+// the root directory and is only reachable by its name.
+const (
+	CtlDirName = ".cfs"
+
+	CtlFileStats     = "stats"
+	CtlFileCache     = "cache"
+	CtlFileConfig    = "config"
+	CtlFileStreams   = "streams"
+	CtlFileReadAhead = "readahead"
+
+	// The inode numbers of the control files are taken from the top of the inode space,
+	// which is never allocated by the metanodes.
+	ctlDirIno = ^uint64(0) - 0xff
+)
+
+var ctlFiles = []string{CtlFileCache, CtlFileConfig, CtlFileReadAhead, CtlFileStats, CtlFileStreams}
+
+// CtlDir is the control directory.
+type CtlDir struct {
+	super *Super
+}
+
+// Functions that CtlDir needs to implement
+var (
+	_ fs.Node               = (*CtlDir)(nil)
+	_ fs.NodeStringLookuper = (*CtlDir)(nil)
+	_ fs.HandleReadDirAller = (*CtlDir)(nil)
+)
+
+// Attr sets the attributes of the control directory.
+func (d *CtlDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = ctlDirIno
+	a.Mode = os.ModeDir | 0555
+	a.Nlink = 2
+	a.Valid = AttrValidDuration
+	return nil
+}
+
+// Lookup returns the control file of the given name.
+func (d *CtlDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	for i, f := range ctlFiles {
+		if f == name {
+			return &CtlFile{super: d.super, name: name, ino: ctlDirIno + uint64(i) + 1}, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+// ReadDirAll lists the control files.
+func (d *CtlDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	dirents := make([]fuse.Dirent, 0, len(ctlFiles))
+	for i, f := range ctlFiles {
+		dirents = append(dirents, fuse.Dirent{Inode: ctlDirIno + uint64(i) + 1, Type: fuse.DT_File, Name: f})
+	}
+	return dirents, nil
+}
+
+// CtlFile is a read-only file in the control directory, whose content is generated on open.
+type CtlFile struct {
+	super *Super
+	name  string
+	ino   uint64
+}
+
+// Functions that CtlFile needs to implement
+var (
+	_ fs.Node            = (*CtlFile)(nil)
+	_ fs.NodeOpener      = (*CtlFile)(nil)
+	_ fs.HandleReadAller = (*CtlFile)(nil)
+)
+
+// Attr sets the attributes of the control file. The size is unknown until the file is read,
+// so the file is opened in direct IO mode.
+func (f *CtlFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = f.ino
+	a.Mode = 0444
+	a.Nlink = 1
+	a.Mtime = time.Now()
+	return nil
+}
+
+// Open opens the control file.
+func (f *CtlFile) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.Errno(fuse.EPERM)
+	}
+	resp.Flags |= fuse.OpenDirectIO
+	return f, nil
+}
+
+// ReadAll generates the content of the control file.
+func (f *CtlFile) ReadAll(ctx context.Context) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	switch f.name {
+	case CtlFileStats:
+		f.super.writeOpStats(buf)
+	case CtlFileCache:
+		f.super.writeCacheStats(buf)
+	case CtlFileConfig:
+		if err := f.super.writeConfig(buf); err != nil {
+			return nil, fuse.EIO
+		}
+	case CtlFileStreams:
+		f.super.writeStreamStats(buf)
+	case CtlFileReadAhead:
+		f.super.writeReadAheadStats(buf)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Super) writeOpStats(buf *bytes.Buffer) {
+	write := func(stats []metrics.OpStat) {
+		for _, stat := range stats {
+			fmt.Fprintf(buf, "%-32s %12d %8d %12d %12d\n", stat.Op, stat.Count, stat.Errors,
+				stat.AvgLatency().Nanoseconds()/1000, stat.MaxLatency.Nanoseconds()/1000)
+		}
+	}
+	fmt.Fprintf(buf, "%-32s %12s %8s %12s %12s\n", "OP", "COUNT", "ERRORS", "AVG(us)", "MAX(us)")
+	write(s.ec.OpStats())
+	write(s.mw.OpStats())
+}
+
+func (s *Super) writeCacheStats(buf *bytes.Buffer) {
+	entries, hits, misses := s.ic.Stats()
+	fmt.Fprintf(buf, "inode_cache_entries %v\n", entries)
+	fmt.Fprintf(buf, "inode_cache_hits %v\n", hits)
+	fmt.Fprintf(buf, "inode_cache_misses %v\n", misses)
+	fmt.Fprintf(buf, "inode_cache_expiration %v\n", s.ic.expiration)
+	s.orphan.RLock()
+	fmt.Fprintf(buf, "orphan_inodes %v\n", s.orphan.list.Len())
+	s.orphan.RUnlock()
+	s.fslock.Lock()
+	fmt.Fprintf(buf, "fuse_nodes %v\n", len(s.nodeCache))
+	s.fslock.Unlock()
+}
+
+// writeConfig writes the mount options except the credentials.
+func (s *Super) writeConfig(buf *bytes.Buffer) error {
+	opt := s.opt
+	config := map[string]interface{}{
+		"cluster":         s.cluster,
+		"mountPoint":      opt.MountPoint,
+		"volName":         opt.Volname,
+		"owner":           opt.Owner,
+		"masterAddr":      opt.Master,
+		"logDir":          opt.Logpath,
+		"logLevel":        opt.Loglvl,
+		"icacheTimeout":   opt.IcacheTimeout,
+		"lookupValid":     LookupValidDuration.String(),
+		"attrValid":       AttrValidDuration.String(),
+		"readRate":        opt.ReadRate,
+		"writeRate":       opt.WriteRate,
+		"enSyncWrite":     s.enSyncWrite,
+		"autoInvalData":   opt.AutoInvalData,
+		"rdonly":          opt.Rdonly,
+		"writecache":      opt.WriteCache,
+		"keepcache":       s.keepCache,
+		"followerRead":    opt.FollowerRead,
+		"integrityDigest": opt.IntegrityDigest,
+		"readAheadMax":    opt.ReadAheadMax,
+		"readAheadTotal":  opt.ReadAheadTotal,
+		"authenticate":    opt.Authenticate,
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+func (s *Super) writeStreamStats(buf *bytes.Buffer) {
+	stats := s.ec.StreamStats()
+	fmt.Fprintf(buf, "%-20s %8s %8s\n", "INODE", "REFCNT", "DIRTY")
+	for _, stat := range stats {
+		fmt.Fprintf(buf, "%-20d %8d %8d\n", stat.Inode, stat.RefCount, stat.DirtyExtent)
+	}
+}
+
+func (s *Super) writeReadAheadStats(buf *bytes.Buffer) {
+	stats := s.ec.ReadAheadStats()
+	fmt.Fprintf(buf, "%-20s %-20s %10s %10s %10s %14s %14s\n", "INODE", "HANDLE", "WINDOW", "SEQ", "RANDOM", "PREFETCHED", "HITS")
+	for _, stat := range stats {
+		fmt.Fprintf(buf, "%-20d %-20d %10d %10d %10d %14d %14d\n", stat.Inode, stat.Handle, stat.Window,
+			stat.SeqReads, stat.RandReads, stat.Prefetched, stat.Hits)
+	}
+}
@@ -77,12 +77,22 @@ func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) error {
 
 // Create handles the create request.
 func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	if d.isCtlDir(req.Name) {
+		return nil, nil, fuse.EEXIST
+	}
 	start := time.Now()
-	info, err := d.super.mw.Create_ll(d.inode.ino, req.Name, proto.Mode(req.Mode.Perm()), req.Uid, req.Gid, nil)
+	mode, acl, _, err := d.inheritACL(req.Mode.Perm(), req.Umask)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := d.super.mw.Create_ll(d.inode.ino, req.Name, proto.Mode(mode), req.Uid, req.Gid, nil)
 	if err != nil {
 		log.LogErrorf("Create: parent(%v) req(%v) err(%v)", d.inode.ino, req, err)
 		return nil, nil, ParseError(err)
 	}
+	if err = d.super.setInheritedACL(info.Inode, acl, nil); err != nil {
+		return nil, nil, err
+	}
 
 	inode := NewInode(info)
 	d.super.ic.Put(inode)
@@ -118,12 +128,22 @@ func (d *Dir) Forget() {
 
 // Mkdir handles the mkdir request.
 func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	if d.isCtlDir(req.Name) {
+		return nil, fuse.EEXIST
+	}
 	start := time.Now()
-	info, err := d.super.mw.Create_ll(d.inode.ino, req.Name, proto.Mode(os.ModeDir|req.Mode.Perm()), req.Uid, req.Gid, nil)
+	mode, acl, defaultACL, err := d.inheritACL(os.ModeDir|req.Mode.Perm(), req.Umask)
+	if err != nil {
+		return nil, err
+	}
+	info, err := d.super.mw.Create_ll(d.inode.ino, req.Name, proto.Mode(mode), req.Uid, req.Gid, nil)
 	if err != nil {
 		log.LogErrorf("Mkdir: parent(%v) req(%v) err(%v)", d.inode.ino, req, err)
 		return nil, ParseError(err)
 	}
+	if err = d.super.setInheritedACL(info.Inode, acl, defaultACL); err != nil {
+		return nil, err
+	}
 
 	inode := NewInode(info)
 	d.super.ic.Put(inode)
@@ -167,6 +187,12 @@ func (d *Dir) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
 	return nil
 }
 
+// isCtlDir returns true if the name in the directory is the control directory, which hides
+// any real entry of the name, so no entry of the name is created or renamed from the mount.
+func (d *Dir) isCtlDir(name string) bool {
+	return d.inode.ino == RootInode && name == CtlDirName
+}
+
 // Lookup handles the lookup request.
 func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
 	var (
@@ -176,6 +202,10 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 
 	log.LogDebugf("TRACE Lookup: parent(%v) req(%v)", d.inode.ino, req)
 
+	if d.isCtlDir(req.Name) {
+		return &CtlDir{super: d.super}, nil
+	}
+
 	ino, ok := d.dcache.Get(req.Name)
 	if !ok {
 		ino, _, err = d.super.mw.Lookup_ll(d.inode.ino, req.Name)
@@ -254,6 +284,9 @@ func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Nod
 		log.LogErrorf("Rename: NOT DIR, parent(%v) req(%v)", d.inode.ino, req)
 		return fuse.ENOTSUP
 	}
+	if d.isCtlDir(req.OldName) || dstDir.isCtlDir(req.NewName) {
+		return fuse.EPERM
+	}
 	start := time.Now()
 	d.dcache.Delete(req.OldName)
 	err := d.super.mw.Rename_ll(d.inode.ino, req.OldName, dstDir.inode.ino, req.NewName)
@@ -299,13 +332,23 @@ func (d *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, error
 	if (req.Mode&os.ModeNamedPipe == 0 && req.Mode&os.ModeSocket == 0) || req.Rdev != 0 {
 		return nil, fuse.ENOSYS
 	}
+	if d.isCtlDir(req.Name) {
+		return nil, fuse.EEXIST
+	}
 
 	start := time.Now()
-	info, err := d.super.mw.Create_ll(d.inode.ino, req.Name, proto.Mode(req.Mode), req.Uid, req.Gid, nil)
+	mode, acl, _, err := d.inheritACL(req.Mode, req.Umask)
+	if err != nil {
+		return nil, err
+	}
+	info, err := d.super.mw.Create_ll(d.inode.ino, req.Name, proto.Mode(mode), req.Uid, req.Gid, nil)
 	if err != nil {
 		log.LogErrorf("Mknod: parent(%v) req(%v) err(%v)", d.inode.ino, req, err)
 		return nil, ParseError(err)
 	}
+	if err = d.super.setInheritedACL(info.Inode, acl, nil); err != nil {
+		return nil, err
+	}
 
 	inode := NewInode(info)
 	d.super.ic.Put(inode)
@@ -322,6 +365,9 @@ func (d *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, error
 
 // Symlink handles the symlink request.
 func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	if d.isCtlDir(req.NewName) {
+		return nil, fuse.EEXIST
+	}
 	parentIno := d.inode.ino
 	start := time.Now()
 	info, err := d.super.mw.Create_ll(parentIno, req.NewName, proto.Mode(os.ModeSymlink|os.ModePerm), req.Uid, req.Gid, []byte(req.Target))
@@ -345,6 +391,9 @@ func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, e
 
 // Link handles the link request.
 func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
+	if d.isCtlDir(req.NewName) {
+		return nil, fuse.EEXIST
+	}
 	var oldInode *Inode
 	switch old := old.(type) {
 	case *File:
@@ -382,22 +431,22 @@ func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.
 	return newFile, nil
 }
 
-// Getxattr has not been implemented yet.
+// Getxattr handles the getxattr request, only the POSIX ACLs are supported.
 func (d *Dir) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return fuse.ENOSYS
+	return d.super.getxattr(d.inode.ino, req, resp)
 }
 
-// Listxattr has not been implemented yet.
+// Listxattr handles the listxattr request, only the POSIX ACLs are supported.
 func (d *Dir) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return fuse.ENOSYS
+	return d.super.listxattr(d.inode.ino, resp)
 }
 
-// Setxattr has not been implemented yet.
+// Setxattr handles the setxattr request, only the POSIX ACLs are supported.
 func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	return fuse.ENOSYS
+	return d.super.setxattr(d.inode.ino, req)
 }
 
-// Removexattr has not been implemented yet.
+// Removexattr handles the removexattr request, only the POSIX ACLs are supported.
 func (d *Dir) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
-	return fuse.ENOSYS
+	return d.super.removexattr(d.inode.ino, req)
 }
@@ -33,6 +33,8 @@ import (
 type File struct {
 	super *Super
 	inode *Inode
+	// locked is set once a byte-range lock of the file is set by the client
+	locked int32
 	sync.RWMutex
 }
 
@@ -53,6 +55,7 @@ var (
 	_ fs.NodeListxattrer   = (*File)(nil)
 	_ fs.NodeSetxattrer    = (*File)(nil)
 	_ fs.NodeRemovexattrer = (*File)(nil)
+	_ fs.HandleLocker      = (*File)(nil)
 )
 
 // NewFile returns a new file.
@@ -115,6 +118,7 @@ func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenR
 	start := time.Now()
 
 	f.super.ec.OpenStream(ino)
+	f.super.ec.SetModifyTime(ino, f.inode.mtime)
 
 	if f.super.keepCache {
 		resp.Flags |= fuse.OpenKeepCache
@@ -134,6 +138,7 @@ func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error
 
 	//log.LogDebugf("TRACE Release close stream: ino(%v) req(%v)", ino, req)
 
+	f.super.ec.CloseReadAhead(ino, uint64(req.Handle))
 	err = f.super.ec.CloseStream(ino)
 	if err != nil {
 		log.LogErrorf("Release: close writer failed, ino(%v) req(%v) err(%v)", ino, req, err)
@@ -152,7 +157,7 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 
 	start := time.Now()
 
-	size, err := f.super.ec.Read(f.inode.ino, resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
+	size, err := f.super.ec.ReadHandle(f.inode.ino, uint64(req.Handle), resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
 	if err != nil && err != io.EOF {
 		msg := fmt.Sprintf("Read: ino(%v) req(%v) err(%v) size(%v)", f.inode.ino, req, err, size)
 		f.super.handleError("Read", msg)
@@ -185,6 +190,14 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 
 	log.LogDebugf("TRACE Write enter: ino(%v) offset(%v) len(%v) filesize(%v) flags(%v) fileflags(%v) req(%v)", ino, req.Offset, reqlen, filesize, req.Flags, req.FileFlags, req)
 
+	// the file can be overwritten but not extended if the bytes of its quotas are exceeded
+	if req.Offset+int64(reqlen) > int64(filesize) {
+		if err = f.super.mw.CheckWriteQuota(ino); err != nil {
+			log.LogWarnf("Write: ino(%v) offset(%v) len(%v) filesize(%v) err(%v)", ino, req.Offset, reqlen, filesize, err)
+			return ParseError(err)
+		}
+	}
+
 	if req.Offset > int64(filesize) && reqlen == 1 && req.Data[0] == 0 {
 		// workaround: posix_fallocate would write 1 byte if fallocate is not supported.
 		err = f.super.ec.Truncate(ino, int(req.Offset)+reqlen)
@@ -234,9 +247,13 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 	return nil
 }
 
-// Flush has not been implemented.
+// Flush releases the byte-range locks of the lock owner if the POSIX locks are enabled, which has
+// not been implemented otherwise.
 func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
-	return fuse.ENOSYS
+	if !f.super.posixLocks {
+		return fuse.ENOSYS
+	}
+	return f.releaseLocks(req.LockOwner)
 }
 
 // Fsync hanldes the fsync request.
@@ -311,24 +328,24 @@ func (f *File) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string,
 	return string(inode.target), nil
 }
 
-// Getxattr has not been implemented yet.
+// Getxattr handles the getxattr request, only the POSIX ACLs are supported.
 func (f *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return fuse.ENOSYS
+	return f.super.getxattr(f.inode.ino, req, resp)
 }
 
-// Listxattr has not been implemented yet.
+// Listxattr handles the listxattr request, only the POSIX ACLs are supported.
 func (f *File) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return fuse.ENOSYS
+	return f.super.listxattr(f.inode.ino, resp)
 }
 
-// Setxattr has not been implemented yet.
+// Setxattr handles the setxattr request, only the POSIX ACLs are supported.
 func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	return fuse.ENOSYS
+	return f.super.setxattr(f.inode.ino, req)
 }
 
-// Removexattr has not been implemented yet.
+// Removexattr handles the removexattr request, only the POSIX ACLs are supported.
 func (f *File) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
-	return fuse.ENOSYS
+	return f.super.removexattr(f.inode.ino, req)
 }
 
 func (f *File) fileSize(ino uint64) (size int, gen uint64) {
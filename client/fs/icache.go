@@ -17,6 +17,7 @@ package fs
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,6 +38,9 @@ type InodeCache struct {
 	lruList     *list.List
 	expiration  time.Duration
 	maxElements int
+
+	hits   uint64
+	misses uint64
 }
 
 // NewInodeCache returns a new inode cache.
@@ -78,19 +82,30 @@ func (ic *InodeCache) Get(ino uint64) *Inode {
 	element, ok := ic.cache[ino]
 	if !ok {
 		ic.RUnlock()
+		atomic.AddUint64(&ic.misses, 1)
 		return nil
 	}
 
 	inode := element.Value.(*Inode)
 	if inode.expired() {
 		ic.RUnlock()
+		atomic.AddUint64(&ic.misses, 1)
 		//log.LogDebugf("InodeCache GetConnect expired: now(%v) inode(%v)", time.Now().Format(LogTimeFormat), inode)
 		return nil
 	}
 	ic.RUnlock()
+	atomic.AddUint64(&ic.hits, 1)
 	return inode
 }
 
+// Stats returns the number of the cached inodes, and the hits and misses of the cache.
+func (ic *InodeCache) Stats() (entries int, hits, misses uint64) {
+	ic.RLock()
+	entries = ic.lruList.Len()
+	ic.RUnlock()
+	return entries, atomic.LoadUint64(&ic.hits), atomic.LoadUint64(&ic.misses)
+}
+
 // Delete deletes the inode based on the given inode ID.
 func (ic *InodeCache) Delete(ino uint64) {
 	//log.LogDebugf("InodeCache Delete: ino(%v)", ino)
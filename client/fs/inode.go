@@ -43,6 +43,11 @@ type Inode struct {
 	mode   os.FileMode
 	target []byte
 
+	// the ACLs are loaded on demand if the POSIX ACLs are enabled
+	aclLoaded  bool
+	acl        proto.ACL
+	defaultACL proto.ACL
+
 	// protected under the inode cache lock
 	expiration int64
 }
@@ -74,6 +79,7 @@ func (s *Super) InodeGet(ino uint64) (*Inode, error) {
 	inode = NewInode(info)
 	s.ic.Put(inode)
 	s.ec.RefreshExtentsCache(ino)
+	s.ec.SetModifyTime(ino, info.ModifyTime)
 	return inode, nil
 }
 
@@ -85,6 +91,7 @@ func (inode *Inode) String() string {
 func (inode *Inode) setattr(req *fuse.SetattrRequest) (valid uint32) {
 	if req.Valid.Mode() {
 		inode.mode = req.Mode
+		inode.aclLoaded = false // chmod changes the access ACL as well
 		valid |= proto.AttrMode
 	}
 
@@ -0,0 +1,139 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"math"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	"golang.org/x/net/context"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The POSIX byte-range locks are coordinated by the meta partitions of the inodes, so that the
+// locks are seen by all the clients. The blocking requests poll the meta partition until the lock
+// is acquired or the request is interrupted, and no deadlock is detected.
+
+const (
+	lockRetryMinInterval = 10 * time.Millisecond
+	lockRetryMaxInterval = time.Second
+)
+
+// Lock acquires or releases a byte-range lock of the file.
+func (f *File) Lock(ctx context.Context, req *fuse.LockRequest) error {
+	ino := f.inode.ino
+	lock := proto.RangeLock{
+		Start: req.Lock.Start,
+		End:   req.Lock.End,
+		Type:  lockTypeToProto(req.Lock.Type),
+		Owner: req.LockOwner,
+		Pid:   req.Lock.PID,
+	}
+	if lock.Type != proto.LockUnlock {
+		atomic.StoreInt32(&f.locked, 1)
+	}
+
+	interval := lockRetryMinInterval
+	for {
+		err := f.super.mw.SetLock_ll(ino, lock)
+		if err == nil {
+			return nil
+		}
+		if err != syscall.EAGAIN || !req.Wait {
+			log.LogDebugf("Lock: ino(%v) req(%v) err(%v)", ino, req, err)
+			return ParseError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return fuse.EINTR
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > lockRetryMaxInterval {
+			interval = lockRetryMaxInterval
+		}
+	}
+}
+
+// QueryLock returns a lock conflicting with the lock queried, or the lock queried of the type
+// LockUnlock if there is none.
+func (f *File) QueryLock(ctx context.Context, req *fuse.QueryLockRequest, resp *fuse.QueryLockResponse) error {
+	ino := f.inode.ino
+	lock := proto.RangeLock{
+		Start: req.Lock.Start,
+		End:   req.Lock.End,
+		Type:  lockTypeToProto(req.Lock.Type),
+		Owner: req.LockOwner,
+		Pid:   req.Lock.PID,
+	}
+	conflict, err := f.super.mw.GetLock_ll(ino, lock)
+	if err != nil {
+		log.LogErrorf("QueryLock: ino(%v) req(%v) err(%v)", ino, req, err)
+		return ParseError(err)
+	}
+	if conflict == nil {
+		resp.Lock = req.Lock
+		resp.Lock.Type = fuse.LockUnlock
+		return nil
+	}
+	resp.Lock = fuse.FileLock{
+		Start: conflict.Start,
+		End:   conflict.End,
+		Type:  lockTypeFromProto(conflict.Type),
+		PID:   conflict.Pid,
+	}
+	return nil
+}
+
+// releaseLocks releases all the locks of the lock owner on the file, which is done on every close
+// as POSIX requires.
+func (f *File) releaseLocks(owner uint64) error {
+	if atomic.LoadInt32(&f.locked) == 0 {
+		return nil
+	}
+	ino := f.inode.ino
+	lock := proto.RangeLock{Start: 0, End: math.MaxUint64, Type: proto.LockUnlock, Owner: owner}
+	if err := f.super.mw.SetLock_ll(ino, lock); err != nil {
+		log.LogErrorf("releaseLocks: ino(%v) owner(%v) err(%v)", ino, owner, err)
+		return ParseError(err)
+	}
+	return nil
+}
+
+func lockTypeToProto(t fuse.LockType) uint32 {
+	switch t {
+	case fuse.LockRead:
+		return proto.LockRead
+	case fuse.LockWrite:
+		return proto.LockWrite
+	default:
+		return proto.LockUnlock
+	}
+}
+
+func lockTypeFromProto(t uint32) fuse.LockType {
+	switch t {
+	case proto.LockRead:
+		return fuse.LockRead
+	case proto.LockWrite:
+		return fuse.LockWrite
+	default:
+		return fuse.LockUnlock
+	}
+}
@@ -44,6 +44,9 @@ type Super struct {
 	orphan      *OrphanInodeList
 	enSyncWrite bool
 	keepCache   bool
+	posixACL    bool
+	posixLocks  bool
+	opt         *proto.MountOptions
 
 	nodeCache map[uint64]fs.Node
 	fslock    sync.Mutex
@@ -85,6 +88,9 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 		s.enSyncWrite = true
 	}
 	s.keepCache = opt.KeepCache
+	s.posixACL = opt.EnablePosixACL
+	s.posixLocks = opt.EnablePosixLock
+	s.opt = opt
 	s.ic = NewInodeCache(inodeExpiration, MaxInodeCache)
 	s.orphan = NewOrphanInodeList()
 	s.nodeCache = make(map[uint64]fs.Node)
@@ -23,6 +23,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	syslog "log"
 	"net/http"
 	_ "net/http/pprof"
@@ -43,6 +44,8 @@ import (
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
+	"github.com/chubaofs/chubaofs/util/tracing"
 	"github.com/chubaofs/chubaofs/util/ump"
 	"github.com/jacobsa/daemonize"
 )
@@ -127,6 +130,21 @@ func main() {
 	}
 	defer log.LogFlush()
 
+	if err = log.SetLogFormat(opt.Logfmt); err != nil {
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	if err = log.SetLogRemote(opt.LogRemote, int(opt.LogRemoteBuf)); err != nil {
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
+	tracing.Init(ModuleName, cfg)
+	if err = mtls.Init(cfg); err != nil {
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
 	outputFilePath := path.Join(opt.Logpath, LoggerPrefix, LoggerOutput)
 	outputFile, err := os.OpenFile(outputFilePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
 	if err != nil {
@@ -235,6 +253,14 @@ func mount(opt *proto.MountOptions) (fsConn *fuse.Conn, super *cfs.Super, err er
 		options = append(options, fuse.WritebackCache())
 	}
 
+	if opt.EnablePosixACL {
+		options = append(options, fuse.DefaultPermissions(), fuse.PosixACL())
+	}
+
+	if opt.EnablePosixLock {
+		options = append(options, fuse.PosixLocks())
+	}
+
 	fsConn, err = fuse.Mount(opt.MountPoint, options...)
 	return
 }
@@ -263,6 +289,9 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.Master = cfg.GetString(proto.MasterAddr)
 	opt.Logpath = cfg.GetString(proto.LogDir)
 	opt.Loglvl = cfg.GetString(proto.LogLevel)
+	opt.Logfmt = cfg.GetString(proto.LogFormat)
+	opt.LogRemote = cfg.GetString(proto.LogRemote)
+	opt.LogRemoteBuf = cfg.GetInt64(proto.LogRemoteBuf)
 	opt.Profport = cfg.GetString(proto.ProfPort)
 	opt.IcacheTimeout = parseConfigString(cfg, proto.IcacheTimeout)
 	opt.LookupValid = parseConfigString(cfg, proto.LookupValid)
@@ -276,6 +305,22 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.WriteCache = cfg.GetBool(proto.WriteCache)
 	opt.KeepCache = cfg.GetBool(proto.KeepCache)
 	opt.FollowerRead = cfg.GetBool(proto.FollowerRead)
+	opt.CompressReply = cfg.GetBool(proto.CompressReply)
+	opt.IntegrityDigest = cfg.GetBool(proto.IntegrityDigest)
+	opt.ReadCacheDir = cfg.GetString(proto.ReadCacheDir)
+	opt.ReadCacheSize = cfg.GetInt64(proto.ReadCacheSize)
+	opt.ReadCacheExpire = cfg.GetInt64(proto.ReadCacheExpire)
+	opt.WriteBack = cfg.GetBool(proto.WriteBack)
+	opt.WriteBackDirty = cfg.GetInt64(proto.WriteBackDirty)
+	opt.WriteBackDelay = cfg.GetInt64(proto.WriteBackDelay)
+	opt.ReadAheadMax = cfg.GetInt64(proto.ReadAheadMax)
+	opt.ReadAheadTotal = cfg.GetInt64(proto.ReadAheadTotal)
+	opt.EnablePosixACL = cfg.GetBool(proto.EnablePosixACL)
+	opt.EnablePosixLock = cfg.GetBool(proto.EnablePosixLock)
+	// the snapshots of the volume are always mounted read-only
+	if opt.Snapshot = cfg.GetString(proto.Snapshot); opt.Snapshot != "" {
+		opt.Rdonly = true
+	}
 	opt.Authenticate = cfg.GetBool(proto.Authenticate)
 	if opt.Authenticate {
 		opt.TicketMess.ClientKey = cfg.GetString(proto.ClientKey)
@@ -284,6 +329,13 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 		if opt.TicketMess.EnableHTTPS {
 			opt.TicketMess.CertFile = cfg.GetString(proto.CertFile)
 		}
+		if opt.TicketMess.Principal = cfg.GetString(proto.Principal); opt.TicketMess.Principal != "" {
+			var password []byte
+			if password, err = ioutil.ReadFile(cfg.GetString(proto.PasswordFile)); err != nil {
+				return nil, errors.Trace(err, "read password file failed")
+			}
+			opt.TicketMess.Password = strings.TrimRight(string(password), "\r\n")
+		}
 	}
 
 	if opt.MountPoint == "" || opt.Volname == "" || opt.Owner == "" || opt.Master == "" {
@@ -35,7 +35,7 @@ func (s *Super) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
 	pino := uint64(op.Parent)
 	desc := fuse.OpDescription(op)
 
-	metric := exporter.NewTPCnt("mkdir")
+	metric := exporter.NewVolumeTPCnt("mkdir", s.volname)
 	defer metric.Set(err)
 
 	info, err := s.mw.Create_ll(pino, op.Name, proto.Mode(os.ModeDir|op.Mode.Perm()), op.Uid, op.Gid, nil)
@@ -62,7 +62,7 @@ func (s *Super) MkNode(ctx context.Context, op *fuseops.MkNodeOp) error {
 	pino := uint64(op.Parent)
 	desc := fuse.OpDescription(op)
 
-	metric := exporter.NewTPCnt("mknod")
+	metric := exporter.NewVolumeTPCnt("mknod", s.volname)
 	defer metric.Set(err)
 
 	info, err := s.mw.Create_ll(pino, op.Name, proto.Mode(op.Mode|os.ModePerm), op.Uid, op.Gid, nil)
@@ -85,7 +85,7 @@ func (s *Super) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error
 	pino := uint64(op.Parent)
 	desc := fuse.OpDescription(op)
 
-	metric := exporter.NewTPCnt("filecreate")
+	metric := exporter.NewVolumeTPCnt("filecreate", s.volname)
 	defer metric.Set(err)
 
 	info, err := s.mw.Create_ll(pino, op.Name, proto.Mode(op.Mode.Perm()), op.Uid, op.Gid, nil)
@@ -112,7 +112,7 @@ func (s *Super) CreateLink(ctx context.Context, op *fuseops.CreateLinkOp) error
 	desc := fuse.OpDescription(op)
 	ino := uint64(op.Target)
 
-	metric := exporter.NewTPCnt("link")
+	metric := exporter.NewVolumeTPCnt("link", s.volname)
 	defer metric.Set(err)
 
 	inode, err := s.InodeGet(ino)
@@ -147,7 +147,7 @@ func (s *Super) CreateSymlink(ctx context.Context, op *fuseops.CreateSymlinkOp)
 	pino := uint64(op.Parent)
 	desc := fuse.OpDescription(op)
 
-	metric := exporter.NewTPCnt("symlink")
+	metric := exporter.NewVolumeTPCnt("symlink", s.volname)
 	defer metric.Set(err)
 
 	info, err := s.mw.Create_ll(pino, op.Name, proto.Mode(os.ModeSymlink|os.ModePerm), op.Uid, op.Gid, nil)
@@ -215,7 +215,7 @@ func (s *Super) RmDir(ctx context.Context, op *fuseops.RmDirOp) error {
 
 	log.LogDebugf("TRACE enter %v:", desc)
 
-	metric := exporter.NewTPCnt("rmdir")
+	metric := exporter.NewVolumeTPCnt("rmdir", s.volname)
 	defer metric.Set(err)
 
 	if pinode, _ := s.InodeGet(pino); pinode != nil {
@@ -244,7 +244,7 @@ func (s *Super) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
 
 	log.LogDebugf("TRACE enter %v:", desc)
 
-	metric := exporter.NewTPCnt("unlink")
+	metric := exporter.NewVolumeTPCnt("unlink", s.volname)
 	defer metric.Set(err)
 
 	info, err := s.mw.Delete_ll(pino, op.Name, false)
@@ -283,7 +283,7 @@ func (s *Super) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
 
 	log.LogDebugf("TRACE enter %v: offset(%v)", desc, op.Offset)
 
-	metric := exporter.NewTPCnt("readdir")
+	metric := exporter.NewVolumeTPCnt("readdir", s.volname)
 	defer metric.Set(err)
 
 	handle := s.hc.Get(op.Handle)
@@ -365,7 +365,7 @@ func (s *Super) Rename(ctx context.Context, op *fuseops.RenameOp) error {
 
 	log.LogDebugf("TRACE enter %v: ", desc)
 
-	metric := exporter.NewTPCnt("rename")
+	metric := exporter.NewVolumeTPCnt("rename", s.volname)
 	defer metric.Set(err)
 
 	if oldPinode, _ := s.InodeGet(oldPino); oldPinode != nil {
@@ -72,7 +72,7 @@ func (s *Super) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
 
 	log.LogDebugf("TRACE Read enter: op(%v)", desc)
 
-	metric := exporter.NewTPCnt("fileread")
+	metric := exporter.NewVolumeTPCnt("fileread", s.volname)
 	defer metric.Set(err)
 
 	size, err := s.ec.Read(ino, op.Dst, offset, reqlen)
@@ -117,6 +117,14 @@ func (s *Super) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
 
 	log.LogDebugf("TRACE Write enter: op(%v) filesize(%v)", desc, filesize)
 
+	// the file can be overwritten but not extended if the bytes of its quotas are exceeded
+	if offset+reqlen > filesize {
+		if err = s.mw.CheckWriteQuota(ino); err != nil {
+			log.LogWarnf("WriteFile: op(%v) filesize(%v) err(%v)", desc, filesize, err)
+			return ParseError(err)
+		}
+	}
+
 	if offset > filesize && reqlen == 1 && op.Data[0] == 0 {
 		// workaround: posix_fallocate would write 1 byte if fallocate is not supported.
 		err = s.ec.Truncate(ino, offset+reqlen)
@@ -139,7 +147,7 @@ func (s *Super) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
 		enSyncWrite = s.enSyncWrite
 	}
 
-	metric := exporter.NewTPCnt("filewrite")
+	metric := exporter.NewVolumeTPCnt("filewrite", s.volname)
 	defer metric.Set(err)
 
 	size, err := s.ec.Write(ino, offset, op.Data, enSyncWrite)
@@ -180,7 +188,7 @@ func (s *Super) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
 
 	log.LogDebugf("TRACE Fsync enter: op(%v)", desc)
 
-	metric := exporter.NewTPCnt("filesync")
+	metric := exporter.NewVolumeTPCnt("filesync", s.volname)
 	defer metric.Set(err)
 
 	err = s.ec.Flush(ino)
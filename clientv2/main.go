@@ -24,6 +24,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	syslog "log"
 	"net/http"
 	_ "net/http/pprof"
@@ -46,6 +47,8 @@ import (
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 const (
@@ -128,6 +131,21 @@ func main() {
 	}
 	defer log.LogFlush()
 
+	if err = log.SetLogFormat(opt.Logfmt); err != nil {
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	if err = log.SetLogRemote(opt.LogRemote, int(opt.LogRemoteBuf)); err != nil {
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
+	tracing.Init(ModuleName, opt.Config)
+	if err = mtls.Init(opt.Config); err != nil {
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
 	outputFilePath := path.Join(opt.Logpath, LoggerPrefix, LoggerOutput)
 	outputFile, err := os.OpenFile(outputFilePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
 	if err != nil {
@@ -255,6 +273,9 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.Master = cfg.GetString(proto.MasterAddr)
 	opt.Logpath = cfg.GetString(proto.LogDir)
 	opt.Loglvl = cfg.GetString(proto.LogLevel)
+	opt.Logfmt = cfg.GetString(proto.LogFormat)
+	opt.LogRemote = cfg.GetString(proto.LogRemote)
+	opt.LogRemoteBuf = cfg.GetInt64(proto.LogRemoteBuf)
 	opt.Profport = cfg.GetString(proto.ProfPort)
 	opt.IcacheTimeout = parseConfigString(cfg, proto.IcacheTimeout)
 	opt.LookupValid = parseConfigString(cfg, proto.LookupValid)
@@ -275,6 +296,13 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 		if opt.TicketMess.EnableHTTPS {
 			opt.TicketMess.CertFile = cfg.GetString(proto.CertFile)
 		}
+		if opt.TicketMess.Principal = cfg.GetString(proto.Principal); opt.TicketMess.Principal != "" {
+			var password []byte
+			if password, err = ioutil.ReadFile(cfg.GetString(proto.PasswordFile)); err != nil {
+				return nil, errors.Trace(err, "read password file failed")
+			}
+			opt.TicketMess.Password = strings.TrimRight(string(password), "\r\n")
+		}
 	}
 
 	if opt.MountPoint == "" || opt.Volname == "" || opt.Owner == "" || opt.Master == "" {
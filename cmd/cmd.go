@@ -27,6 +27,7 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/chubaofs/chubaofs/objectnode"
 
@@ -34,11 +35,16 @@ import (
 
 	"github.com/chubaofs/chubaofs/authnode"
 	"github.com/chubaofs/chubaofs/cmd/common"
+	"github.com/chubaofs/chubaofs/console"
 	"github.com/chubaofs/chubaofs/datanode"
+	"github.com/chubaofs/chubaofs/ecnode"
 	"github.com/chubaofs/chubaofs/master"
 	"github.com/chubaofs/chubaofs/metanode"
 	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/health"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
+	"github.com/chubaofs/chubaofs/util/tracing"
 	"github.com/chubaofs/chubaofs/util/ump"
 )
 
@@ -49,27 +55,36 @@ var (
 )
 
 const (
-	ConfigKeyRole       = "role"
-	ConfigKeyLogDir     = "logDir"
-	ConfigKeyLogLevel   = "logLevel"
-	ConfigKeyProfPort   = "prof"
-	ConfigKeyWarnLogDir = "warnLogDir"
+	ConfigKeyRole         = "role"
+	ConfigKeyLogDir       = "logDir"
+	ConfigKeyLogLevel     = "logLevel"
+	ConfigKeyLogFormat    = "logFormat"
+	ConfigKeyLogRemote    = "logRemote"
+	ConfigKeyLogRemoteBuf = "logRemoteBufferSize"
+	ConfigKeySlowOp       = "slowOpThreshold"  // milliseconds, applies to all the operations
+	ConfigKeySlowOps      = "slowOpThresholds" // milliseconds by operation, e.g. {"OpWrite": 100}
+	ConfigKeyProfPort     = "prof"
+	ConfigKeyWarnLogDir   = "warnLogDir"
 )
 
 const (
-	RoleMaster = "master"
-	RoleMeta   = "metanode"
-	RoleData   = "datanode"
-	RoleAuth   = "authnode"
-	RoleObject = "objectnode"
+	RoleMaster  = "master"
+	RoleMeta    = "metanode"
+	RoleData    = "datanode"
+	RoleAuth    = "authnode"
+	RoleObject  = "objectnode"
+	RoleConsole = "console"
+	RoleEC      = "ecnode"
 )
 
 const (
-	ModuleMaster = "master"
-	ModuleMeta   = "metaNode"
-	ModuleData   = "dataNode"
-	ModuleAuth   = "authNode"
-	ModuleObject = "objectNode"
+	ModuleMaster  = "master"
+	ModuleMeta    = "metaNode"
+	ModuleData    = "dataNode"
+	ModuleAuth    = "authNode"
+	ModuleObject  = "objectNode"
+	ModuleConsole = "console"
+	ModuleEC      = "ecNode"
 )
 
 const (
@@ -174,6 +189,12 @@ func main() {
 	case RoleObject:
 		server = objectnode.NewServer()
 		module = ModuleObject
+	case RoleConsole:
+		server = console.NewServer()
+		module = ModuleConsole
+	case RoleEC:
+		server = ecnode.NewServer()
+		module = ModuleEC
 	default:
 		daemonize.SignalOutcome(fmt.Errorf("Fatal: role mismatch: %v", role))
 		os.Exit(1)
@@ -203,6 +224,22 @@ func main() {
 	}
 	defer log.LogFlush()
 
+	if err = log.SetLogFormat(cfg.GetString(ConfigKeyLogFormat)); err != nil {
+		daemonize.SignalOutcome(fmt.Errorf("Fatal: failed to set log format - %v", err))
+		os.Exit(1)
+	}
+	if err = log.SetLogRemote(cfg.GetString(ConfigKeyLogRemote), int(cfg.GetInt64(ConfigKeyLogRemoteBuf))); err != nil {
+		daemonize.SignalOutcome(fmt.Errorf("Fatal: failed to set remote log - %v", err))
+		os.Exit(1)
+	}
+	log.SetSlowOpThresholds(parseSlowOpThresholds(cfg))
+
+	tracing.Init(module, cfg)
+	if err = mtls.Init(cfg); err != nil {
+		daemonize.SignalOutcome(fmt.Errorf("Fatal: failed to init mutual tls - %v", err))
+		os.Exit(1)
+	}
+
 	// Init output file
 	outputFilePath := path.Join(logDir, module, LoggerOutput)
 	outputFile, err := os.OpenFile(outputFilePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
@@ -240,6 +277,7 @@ func main() {
 	if profPort != "" {
 		go func() {
 			http.HandleFunc(log.SetLogLevelPath, log.SetLogLevel)
+			health.RegisterHTTP()
 			e := http.ListenAndServe(fmt.Sprintf(":%v", profPort), nil)
 			if e != nil {
 				log.LogFlush()
@@ -291,3 +329,17 @@ func startDaemon() error {
 
 	return nil
 }
+
+func parseSlowOpThresholds(cfg *config.Config) (threshold time.Duration, perOp map[string]time.Duration) {
+	threshold = log.DefaultSlowOpThreshold
+	if ms := cfg.GetInt64(ConfigKeySlowOp); ms != 0 {
+		threshold = time.Duration(ms) * time.Millisecond
+	}
+	perOp = make(map[string]time.Duration)
+	for op, v := range cfg.GetMap(ConfigKeySlowOps) {
+		if ms, ok := v.(float64); ok {
+			perOp[op] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return
+}
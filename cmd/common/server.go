@@ -64,3 +64,8 @@ func (c *Control) Sync() {
 		c.wg.Wait()
 	}
 }
+
+// IsRunning returns whether the server has been started and not shut down.
+func (c *Control) IsRunning() bool {
+	return atomic.LoadUint32(&c.state) == StateRunning
+}
@@ -0,0 +1,323 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package console
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// APIs of the console
+const (
+	ConsoleGetCluster = "/api/cluster"
+	ConsoleListVols   = "/api/vol/list"
+	ConsoleCreateVol  = "/api/vol/create"
+	ConsoleUpdateVol  = "/api/vol/update"
+	ConsoleDeleteVol  = "/api/vol/delete"
+	ConsoleListUsers  = "/api/user/list"
+	ConsoleListAlerts = "/api/alert/list"
+)
+
+const (
+	defaultMpCount  = 3
+	defaultReplicas = 3
+)
+
+// Levels of the alerts
+const (
+	AlertCritical = "critical"
+	AlertWarning  = "warning"
+)
+
+// Alert is a problem of the cluster derived from the cluster view.
+type Alert struct {
+	Level  string `json:"level"`
+	Target string `json:"target"`
+	Msg    string `json:"msg"`
+}
+
+// User is an owner of the volumes, with the access keys of its volumes for the object storage.
+type User struct {
+	Owner   string     `json:"owner"`
+	Volumes []*UserVol `json:"volumes"`
+}
+
+type UserVol struct {
+	Name      string `json:"name"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+func (c *Console) registerHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/", c.serveDashboard)
+	mux.HandleFunc(ConsoleGetCluster, c.getCluster)
+	mux.HandleFunc(ConsoleListVols, c.listVols)
+	mux.HandleFunc(ConsoleCreateVol, c.createVol)
+	mux.HandleFunc(ConsoleUpdateVol, c.updateVol)
+	mux.HandleFunc(ConsoleDeleteVol, c.deleteVol)
+	mux.HandleFunc(ConsoleListUsers, c.listUsers)
+	mux.HandleFunc(ConsoleListAlerts, c.listAlerts)
+}
+
+func (c *Console) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write([]byte(dashboardPage)); err != nil {
+		log.LogErrorf("serveDashboard: write response fail: remote(%v) err(%v)", r.RemoteAddr, err)
+	}
+}
+
+func (c *Console) getCluster(w http.ResponseWriter, r *http.Request) {
+	cv, err := c.mc.AdminAPI().GetCluster()
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	sendOkReply(w, r, cv)
+}
+
+func (c *Console) listVols(w http.ResponseWriter, r *http.Request) {
+	cv, err := c.mc.AdminAPI().GetCluster()
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	vols := make([]*proto.SimpleVolView, 0, len(cv.VolStatInfo))
+	for _, stat := range cv.VolStatInfo {
+		vv, err := c.mc.AdminAPI().GetVolumeSimpleInfo(stat.Name)
+		if err != nil {
+			log.LogWarnf("listVols: get volume fail: vol(%v) err(%v)", stat.Name, err)
+			continue
+		}
+		vols = append(vols, vv)
+	}
+	sendOkReply(w, r, vols)
+}
+
+func (c *Console) createVol(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrReply(w, r, proto.ErrCodeParamError, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	name, owner := r.FormValue("name"), r.FormValue("owner")
+	if name == "" || owner == "" {
+		sendErrReply(w, r, proto.ErrCodeParamError, proto.ErrParamError)
+		return
+	}
+	capacity, err := parseUint(r, "capacity", 0)
+	if err != nil || capacity == 0 {
+		sendErrReply(w, r, proto.ErrCodeParamError, proto.ErrParamError)
+		return
+	}
+	mpCount, err := parseUint(r, "mpCount", defaultMpCount)
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeParamError, err)
+		return
+	}
+	followerRead := r.FormValue("followerRead") == "true"
+	if err = c.mc.AdminAPI().CreateVolume(name, owner, int(mpCount), 0, capacity, defaultReplicas, followerRead); err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	log.LogInfof("createVol: vol(%v) owner(%v) capacity(%v) remote(%v)", name, owner, capacity, r.RemoteAddr)
+	sendOkReply(w, r, nil)
+}
+
+func (c *Console) updateVol(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrReply(w, r, proto.ErrCodeParamError, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	vv, err := c.mc.AdminAPI().GetVolumeSimpleInfo(r.FormValue("name"))
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	capacity, err := parseUint(r, "capacity", vv.Capacity)
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeParamError, err)
+		return
+	}
+	replicas, err := parseUint(r, "replicaNum", uint64(vv.DpReplicaNum))
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeParamError, err)
+		return
+	}
+	followerRead := vv.FollowerRead
+	if value := r.FormValue("followerRead"); value != "" {
+		followerRead = value == "true"
+	}
+	if err = c.mc.AdminAPI().UpdateVolume(vv.Name, capacity, int(replicas), followerRead, authKey(vv.Owner)); err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	log.LogInfof("updateVol: vol(%v) capacity(%v) replicas(%v) followerRead(%v) remote(%v)",
+		vv.Name, capacity, replicas, followerRead, r.RemoteAddr)
+	sendOkReply(w, r, nil)
+}
+
+func (c *Console) deleteVol(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrReply(w, r, proto.ErrCodeParamError, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	vv, err := c.mc.AdminAPI().GetVolumeSimpleInfo(r.FormValue("name"))
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	if err = c.mc.AdminAPI().DeleteVolume(vv.Name, authKey(vv.Owner)); err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	log.LogInfof("deleteVol: vol(%v) remote(%v)", vv.Name, r.RemoteAddr)
+	sendOkReply(w, r, nil)
+}
+
+// listUsers lists the owners of the volumes, since the access keys are generated by the master
+// along with the volumes.
+func (c *Console) listUsers(w http.ResponseWriter, r *http.Request) {
+	cv, err := c.mc.AdminAPI().GetCluster()
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	users := make(map[string]*User)
+	for _, stat := range cv.VolStatInfo {
+		vv, err := c.mc.ClientAPI().GetVolumeWithoutAuthKey(stat.Name)
+		if err != nil {
+			log.LogWarnf("listUsers: get volume fail: vol(%v) err(%v)", stat.Name, err)
+			continue
+		}
+		user, ok := users[vv.Owner]
+		if !ok {
+			user = &User{Owner: vv.Owner}
+			users[vv.Owner] = user
+		}
+		vol := &UserVol{Name: vv.Name}
+		if vv.OSSSecure != nil {
+			vol.AccessKey, vol.SecretKey = vv.OSSSecure.AccessKey, vv.OSSSecure.SecretKey
+		}
+		user.Volumes = append(user.Volumes, vol)
+	}
+	list := make([]*User, 0, len(users))
+	for _, user := range users {
+		list = append(list, user)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Owner < list[j].Owner })
+	sendOkReply(w, r, list)
+}
+
+func (c *Console) listAlerts(w http.ResponseWriter, r *http.Request) {
+	cv, err := c.mc.AdminAPI().GetCluster()
+	if err != nil {
+		sendErrReply(w, r, proto.ErrCodeInternalError, err)
+		return
+	}
+	sendOkReply(w, r, collectAlerts(cv, c.alertUsedRatio))
+}
+
+// collectAlerts returns the alerts of the inactive or read-only nodes, the bad partitions and
+// the nodes and the volumes whose used ratio exceeds the given ratio.
+func collectAlerts(cv *proto.ClusterView, usedRatio float64) (alerts []*Alert) {
+	alerts = make([]*Alert, 0)
+	add := func(level, target, format string, a ...interface{}) {
+		alerts = append(alerts, &Alert{Level: level, Target: target, Msg: fmt.Sprintf(format, a...)})
+	}
+	for _, node := range cv.MetaNodes {
+		if !node.Status {
+			add(AlertCritical, node.Addr, "meta node is inactive")
+		} else if !node.IsWritable {
+			add(AlertWarning, node.Addr, "meta node is not writable")
+		}
+	}
+	for _, node := range cv.DataNodes {
+		if !node.Status {
+			add(AlertCritical, node.Addr, "data node is inactive")
+		} else if !node.IsWritable {
+			add(AlertWarning, node.Addr, "data node is not writable")
+		}
+	}
+	for _, bad := range cv.BadPartitionIDs {
+		add(AlertCritical, bad.Path, "bad data partitions %v", bad.PartitionIDs)
+	}
+	for _, bad := range cv.BadMetaPartitionIDs {
+		add(AlertCritical, bad.Path, "bad meta partitions %v", bad.PartitionIDs)
+	}
+	if stat := cv.DataNodeStatInfo; stat != nil && stat.TotalGB > 0 && float64(stat.UsedGB)/float64(stat.TotalGB) >= usedRatio {
+		add(AlertWarning, "data nodes", "used %vGB of %vGB", stat.UsedGB, stat.TotalGB)
+	}
+	if stat := cv.MetaNodeStatInfo; stat != nil && stat.TotalGB > 0 && float64(stat.UsedGB)/float64(stat.TotalGB) >= usedRatio {
+		add(AlertWarning, "meta nodes", "used %vGB of %vGB", stat.UsedGB, stat.TotalGB)
+	}
+	for _, stat := range cv.VolStatInfo {
+		if stat.TotalSize > 0 && float64(stat.UsedSize)/float64(stat.TotalSize) >= usedRatio {
+			add(AlertWarning, stat.Name, "volume used %v of %v bytes", stat.UsedSize, stat.TotalSize)
+		}
+	}
+	return
+}
+
+// authKey returns the key of the owner, which the master requires to update or delete a volume.
+func authKey(owner string) string {
+	sum := md5.Sum([]byte(owner))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseUint(r *http.Request, key string, defaultValue uint64) (uint64, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v: %v", key, value)
+	}
+	return v, nil
+}
+
+func sendOkReply(w http.ResponseWriter, r *http.Request, data interface{}) {
+	send(w, r, &proto.HTTPReply{Code: proto.ErrCodeSuccess, Msg: proto.ErrSuc.Error(), Data: data})
+}
+
+func sendErrReply(w http.ResponseWriter, r *http.Request, code int32, err error) {
+	log.LogWarnf("URL[%v],remoteAddr[%v],response err[%v]", r.URL, r.RemoteAddr, err)
+	send(w, r, &proto.HTTPReply{Code: code, Msg: err.Error()})
+}
+
+func send(w http.ResponseWriter, r *http.Request, reply *proto.HTTPReply) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.LogErrorf("fail to marshal http reply[%v]. URL[%v],remoteAddr[%v] err:[%v]", reply, r.URL, r.RemoteAddr, err)
+		http.Error(w, "fail to marshal http reply", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("fail to write http reply len[%d].URL[%v],remoteAddr[%v] err:[%v]", len(data), r.URL, r.RemoteAddr, err)
+	}
+}
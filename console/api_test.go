@@ -0,0 +1,93 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package console
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestCollectAlerts(t *testing.T) {
+	cv := &proto.ClusterView{
+		MetaNodes: []proto.NodeView{
+			{Addr: "meta1", Status: true, IsWritable: true},
+			{Addr: "meta2", Status: false},
+		},
+		DataNodes: []proto.NodeView{
+			{Addr: "data1", Status: true, IsWritable: false},
+		},
+		BadPartitionIDs:  []proto.BadPartitionView{{Path: "data1:/disk1", PartitionIDs: []uint64{1, 2}}},
+		DataNodeStatInfo: &proto.NodeStatInfo{TotalGB: 100, UsedGB: 95},
+		MetaNodeStatInfo: &proto.NodeStatInfo{TotalGB: 100, UsedGB: 10},
+		VolStatInfo: []*proto.VolStatInfo{
+			{Name: "full", TotalSize: 100, UsedSize: 90},
+			{Name: "empty", TotalSize: 100},
+		},
+	}
+	alerts := collectAlerts(cv, 0.9)
+	expects := []Alert{
+		{Level: AlertCritical, Target: "meta2"},
+		{Level: AlertWarning, Target: "data1"},
+		{Level: AlertCritical, Target: "data1:/disk1"},
+		{Level: AlertWarning, Target: "data nodes"},
+		{Level: AlertWarning, Target: "full"},
+	}
+	if len(alerts) != len(expects) {
+		t.Fatalf("alerts %v, expect %v", len(alerts), len(expects))
+	}
+	for i, expect := range expects {
+		if alerts[i].Level != expect.Level || alerts[i].Target != expect.Target {
+			t.Fatalf("alert %v: %+v, expect %+v", i, *alerts[i], expect)
+		}
+	}
+}
+
+func TestAuthHandler(t *testing.T) {
+	c := &Console{username: "admin", password: "secret"}
+	handler := c.authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		method, username, password string
+		xhr                        bool
+		code                       int
+	}{
+		{http.MethodGet, "", "", false, http.StatusUnauthorized},
+		{http.MethodGet, "admin", "wrong", false, http.StatusUnauthorized},
+		{http.MethodGet, "admin", "secret", false, http.StatusOK},
+		{http.MethodPost, "admin", "secret", false, http.StatusForbidden},
+		{http.MethodPost, "admin", "secret", true, http.StatusOK},
+	} {
+		r := httptest.NewRequest(tc.method, ConsoleCreateVol, nil)
+		if tc.username != "" {
+			r.SetBasicAuth(tc.username, tc.password)
+		}
+		if tc.xhr {
+			r.Header.Set("X-Requested-With", "XMLHttpRequest")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Fatalf("%+v: code %v", tc, w.Code)
+		}
+	}
+}
+
+func TestAuthKey(t *testing.T) {
+	if key := authKey("cfs"); key != "7b2f1bf38b87d32470c4557c7ff02e75" {
+		t.Fatalf("auth key %v", key)
+	}
+}
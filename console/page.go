@@ -0,0 +1,210 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package console
+
+// dashboardPage is the single page of the dashboard, which renders the JSON APIs of the console
+// without any external assets, so that it works in the isolated networks too.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ChubaoFS Console</title>
+<style>
+body { font-family: sans-serif; margin: 0; color: #333; }
+header { background: #2d3e50; color: #fff; padding: 12px 24px; }
+header h1 { display: inline; font-size: 20px; margin-right: 32px; }
+nav a { color: #ccd; margin-right: 16px; cursor: pointer; text-decoration: none; }
+nav a.active { color: #fff; font-weight: bold; }
+main { padding: 16px 24px; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 16px; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px 8px; text-align: left; font-size: 14px; }
+th { background: #f4f4f4; }
+.bar { background: #eee; width: 200px; height: 12px; display: inline-block; }
+.bar div { background: #4a90d9; height: 12px; }
+.critical { color: #c0392b; font-weight: bold; }
+.warning { color: #d68910; }
+.error { color: #c0392b; }
+form input { margin-right: 8px; }
+</style>
+</head>
+<body>
+<header>
+<h1>ChubaoFS Console</h1>
+<nav>
+<a data-view="overview">Overview</a>
+<a data-view="volumes">Volumes</a>
+<a data-view="users">Users</a>
+<a data-view="capacity">Capacity</a>
+<a data-view="alerts">Alerts</a>
+</nav>
+</header>
+<main id="main"></main>
+<script>
+function esc(s) {
+  return String(s).replace(/[&<>"']/g, function (c) {
+    return {'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c];
+  });
+}
+
+function api(method, path, params) {
+  var opts = {method: method, credentials: 'same-origin', headers: {'X-Requested-With': 'XMLHttpRequest'}};
+  if (params) {
+    opts.body = new URLSearchParams(params);
+  }
+  return fetch(path, opts).then(function (resp) { return resp.json(); }).then(function (reply) {
+    if (reply.code !== 0) {
+      throw new Error(reply.msg);
+    }
+    return reply.data;
+  });
+}
+
+function table(headers, rows) {
+  var html = '<table><tr>' + headers.map(function (h) { return '<th>' + esc(h) + '</th>'; }).join('') + '</tr>';
+  rows.forEach(function (row) {
+    html += '<tr>' + row.map(function (cell) { return '<td>' + cell + '</td>'; }).join('') + '</tr>';
+  });
+  return html + '</table>';
+}
+
+function bar(used, total) {
+  var ratio = total > 0 ? Math.min(used / total, 1) : 0;
+  return '<span class="bar"><div style="width:' + (ratio * 100).toFixed(1) + '%"></div></span> ' + (ratio * 100).toFixed(1) + '%';
+}
+
+function nodes(list) {
+  return table(['Address', 'ID', 'Active', 'Writable'], (list || []).map(function (n) {
+    return [esc(n.Addr), esc(n.ID), esc(n.Status), esc(n.IsWritable)];
+  }));
+}
+
+var views = {
+  overview: function () {
+    return api('GET', '/api/cluster').then(function (cv) {
+      return '<h2>Cluster ' + esc(cv.Name) + '</h2>' +
+        table(['Leader', 'Applied', 'Auto Allocation', 'Volumes', 'Meta Nodes', 'Data Nodes'], [[
+          esc(cv.LeaderAddr), esc(cv.Applied), esc(!cv.DisableAutoAlloc), esc((cv.VolStatInfo || []).length),
+          esc((cv.MetaNodes || []).length), esc((cv.DataNodes || []).length)]]) +
+        '<h3>Meta Nodes</h3>' + nodes(cv.MetaNodes) + '<h3>Data Nodes</h3>' + nodes(cv.DataNodes);
+    });
+  },
+  volumes: function () {
+    return api('GET', '/api/vol/list').then(function (vols) {
+      return '<h2>Volumes</h2>' +
+        '<form id="create"><input name="name" placeholder="name" required><input name="owner" placeholder="owner" required>' +
+        '<input name="capacity" placeholder="capacity (GB)" required><label><input type="checkbox" name="followerRead">follower read</label>' +
+        '<button>Create</button></form><br>' +
+        table(['Name', 'Owner', 'Capacity (GB)', 'Replicas', 'Meta Partitions', 'Data Partitions', 'Writable DPs', 'Follower Read', ''],
+          vols.map(function (v) {
+            return [esc(v.Name), esc(v.Owner),
+              '<input size="8" value="' + esc(v.Capacity) + '" data-capacity="' + esc(v.Name) + '">',
+              esc(v.DpReplicaNum), esc(v.MpCnt), esc(v.DpCnt), esc(v.RwDpCnt), esc(v.FollowerRead),
+              '<button data-update="' + esc(v.Name) + '">Update</button> <button data-delete="' + esc(v.Name) + '">Delete</button>'];
+          }));
+    });
+  },
+  users: function () {
+    return api('GET', '/api/user/list').then(function (users) {
+      var rows = [];
+      users.forEach(function (u) {
+        u.volumes.forEach(function (v) {
+          rows.push([esc(u.owner), esc(v.name), esc(v.accessKey),
+            '<span data-secret="' + esc(v.secretKey) + '">******</span> <a href="#" data-show>show</a>']);
+        });
+      });
+      return '<h2>Users</h2><p>The access keys are generated by the master along with the volumes.</p>' +
+        table(['Owner', 'Volume', 'Access Key', 'Secret Key'], rows);
+    });
+  },
+  capacity: function () {
+    return api('GET', '/api/cluster').then(function (cv) {
+      var dn = cv.DataNodeStatInfo || {}, mn = cv.MetaNodeStatInfo || {};
+      return '<h2>Capacity</h2>' +
+        table(['Nodes', 'Total (GB)', 'Used (GB)', 'Increased (GB)', 'Used'], [
+          ['Data', esc(dn.TotalGB), esc(dn.UsedGB), esc(dn.IncreasedGB), bar(dn.UsedGB, dn.TotalGB)],
+          ['Meta', esc(mn.TotalGB), esc(mn.UsedGB), esc(mn.IncreasedGB), bar(mn.UsedGB, mn.TotalGB)]]) +
+        table(['Volume', 'Total (GB)', 'Used (GB)', 'Used'], (cv.VolStatInfo || []).map(function (v) {
+          return [esc(v.Name), esc((v.TotalSize / 1073741824).toFixed(2)), esc((v.UsedSize / 1073741824).toFixed(2)), bar(v.UsedSize, v.TotalSize)];
+        }));
+    });
+  },
+  alerts: function () {
+    return api('GET', '/api/alert/list').then(function (alerts) {
+      if (alerts.length === 0) {
+        return '<h2>Alerts</h2><p>No alerts.</p>';
+      }
+      return '<h2>Alerts</h2>' + table(['Level', 'Target', 'Message'], alerts.map(function (a) {
+        return ['<span class="' + esc(a.level) + '">' + esc(a.level) + '</span>', esc(a.target), esc(a.msg)];
+      }));
+    });
+  }
+};
+
+var current = 'overview';
+
+function show(view) {
+  current = view;
+  document.querySelectorAll('nav a').forEach(function (a) {
+    a.className = a.dataset.view === view ? 'active' : '';
+  });
+  views[view]().then(function (html) {
+    document.getElementById('main').innerHTML = html;
+  }).catch(function (err) {
+    document.getElementById('main').innerHTML = '<p class="error">' + esc(err.message) + '</p>';
+  });
+}
+
+function act(method, path, params) {
+  api(method, path, params).then(function () { show(current); }).catch(function (err) { alert(err.message); });
+}
+
+document.querySelector('nav').addEventListener('click', function (e) {
+  if (e.target.dataset.view) {
+    show(e.target.dataset.view);
+  }
+});
+
+document.getElementById('main').addEventListener('submit', function (e) {
+  e.preventDefault();
+  var form = e.target;
+  act('POST', '/api/vol/create', {
+    name: form.elements.name.value, owner: form.elements.owner.value, capacity: form.elements.capacity.value,
+    followerRead: form.elements.followerRead.checked
+  });
+});
+
+document.getElementById('main').addEventListener('click', function (e) {
+  var d = e.target.dataset;
+  if (d.update) {
+    act('POST', '/api/vol/update', {name: d.update, capacity: document.querySelector('[data-capacity="' + d.update + '"]').value});
+  } else if (d.delete && confirm('Delete volume ' + d.delete + '?')) {
+    act('POST', '/api/vol/delete', {name: d.delete});
+  } else if (d.show !== undefined) {
+    e.preventDefault();
+    var secret = e.target.previousElementSibling;
+    secret.textContent = secret.dataset.secret;
+  }
+});
+
+show(current);
+setInterval(function () {
+  if (current !== 'volumes') {
+    show(current);
+  }
+}, 30000);
+</script>
+</body>
+</html>
+`
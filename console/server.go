@@ -0,0 +1,194 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package console serves a web dashboard of the cluster, including the overview, the volumes,
+// the owners with their access keys, the capacity and the alerts, backed by the master APIs.
+package console
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/cmd/common"
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/health"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	ModuleName = "console"
+)
+
+const (
+	configUsername       = "username"
+	configPassword       = "password"
+	configAlertUsedRatio = "alertUsedRatio"
+)
+
+const (
+	defaultListen         = ":8080"
+	defaultAlertUsedRatio = 0.9
+)
+
+var (
+	regexpListen = regexp.MustCompile("^(([0-9]{1,3}.){3}([0-9]{1,3}))?:(\\d)+$")
+)
+
+// Console serves the dashboard and its JSON APIs, which are authenticated by HTTP basic auth
+// since the volumes can be created and deleted, and the secret keys are shown.
+type Console struct {
+	listen         string
+	username       string
+	password       string
+	alertUsedRatio float64
+	mc             *masterSDK.MasterClient
+	httpServer     *http.Server
+
+	control common.Control
+}
+
+func NewServer() *Console {
+	return &Console{}
+}
+
+func (c *Console) Start(cfg *config.Config) (err error) {
+	return c.control.Start(c, cfg, handleStart)
+}
+
+func (c *Console) Shutdown() {
+	c.control.Shutdown(c, handleShutdown)
+}
+
+func (c *Console) Sync() {
+	c.control.Sync()
+}
+
+func (c *Console) parseConfig(cfg *config.Config) (err error) {
+	listen := cfg.GetString(proto.ListenPort)
+	if len(listen) == 0 {
+		listen = defaultListen
+	}
+	if match := regexpListen.MatchString(listen); !match {
+		return errors.New("invalid listen configuration")
+	}
+	c.listen = listen
+
+	c.username = cfg.GetString(configUsername)
+	c.password = cfg.GetString(configPassword)
+	if len(c.username) == 0 || len(c.password) == 0 {
+		return errors.New("username and password are required")
+	}
+
+	c.alertUsedRatio = defaultAlertUsedRatio
+	if ratio := cfg.GetString(configAlertUsedRatio); len(ratio) != 0 {
+		if c.alertUsedRatio, err = strconv.ParseFloat(ratio, 64); err != nil || c.alertUsedRatio <= 0 {
+			return errors.New("invalid alertUsedRatio configuration")
+		}
+	}
+
+	masterCfgs := cfg.GetArray(proto.MasterAddr)
+	masters := make([]string, len(masterCfgs))
+	for i, masterCfg := range masterCfgs {
+		masters[i] = masterCfg.(string)
+	}
+	if len(masters) == 0 {
+		return errors.New("masterAddr is required")
+	}
+	c.mc = masterSDK.NewMasterClient(masters, false)
+	return
+}
+
+func handleStart(s common.Server, cfg *config.Config) (err error) {
+	c, ok := s.(*Console)
+	if !ok {
+		return errors.New("Invalid Node Type!")
+	}
+	if err = c.parseConfig(cfg); err != nil {
+		return
+	}
+
+	health.AddCheck("state", c.checkState)
+
+	mux := http.NewServeMux()
+	c.registerHandlers(mux)
+	server := &http.Server{
+		Addr:    c.listen,
+		Handler: c.healthHandler(c.authHandler(mux)),
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.LogErrorf("handleStart: start http server fail, err(%v)", err)
+		}
+	}()
+	c.httpServer = server
+	log.LogInfof("console start success, listen(%v)", c.listen)
+	return
+}
+
+func handleShutdown(s common.Server) {
+	c, ok := s.(*Console)
+	if !ok {
+		return
+	}
+	if c.httpServer != nil {
+		_ = c.httpServer.Shutdown(context.Background())
+		c.httpServer = nil
+	}
+}
+
+func (c *Console) checkState() error {
+	if !c.control.IsRunning() {
+		return errors.New("not running")
+	}
+	return nil
+}
+
+// healthHandler serves the health endpoints without authentication.
+func (c *Console) healthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case health.LivenessPath:
+			health.Default().LivenessHandler(w, r)
+			return
+		case health.ReadinessPath:
+			health.Default().ReadinessHandler(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Console) authHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(c.username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(c.password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ChubaoFS Console"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		// the browsers send the credentials with the cross-site forms too, but not the custom headers
+		if r.Method != http.MethodGet && r.Header.Get("X-Requested-With") != "XMLHttpRequest" {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -43,17 +43,21 @@ const (
 
 // Action description
 const (
-	ActionNotifyFollowerToRepair        = "ActionNotifyFollowerRepair"
-	ActionStreamRead                    = "ActionStreamRead"
-	ActionCreateExtent                  = "ActionCreateExtent:"
-	ActionMarkDelete                    = "ActionMarkDelete:"
-	ActionGetAllExtentWatermarks        = "ActionGetAllExtentWatermarks:"
-	ActionWrite                         = "ActionWrite:"
-	ActionRepair                        = "ActionRepair:"
-	ActionDecommissionPartition         = "ActionDecommissionPartition"
-	ActionAddDataPartitionRaftMember    = "ActionAddDataPartitionRaftMember"
-	ActionRemoveDataPartitionRaftMember = "ActionRemoveDataPartitionRaftMember"
-	ActionDataPartitionTryToLeader      = "ActionDataPartitionTryToLeader"
+	ActionNotifyFollowerToRepair          = "ActionNotifyFollowerRepair"
+	ActionStreamRead                      = "ActionStreamRead"
+	ActionCreateExtent                    = "ActionCreateExtent:"
+	ActionCopyExtent                      = "ActionCopyExtent:"
+	ActionMarkDelete                      = "ActionMarkDelete:"
+	ActionGetAllExtentWatermarks          = "ActionGetAllExtentWatermarks:"
+	ActionWrite                           = "ActionWrite:"
+	ActionSealPartition                   = "ActionSealPartition:"
+	ActionRepair                          = "ActionRepair:"
+	ActionDecommissionPartition           = "ActionDecommissionPartition"
+	ActionAddDataPartitionRaftMember      = "ActionAddDataPartitionRaftMember"
+	ActionRemoveDataPartitionRaftMember   = "ActionRemoveDataPartitionRaftMember"
+	ActionDataPartitionTryToLeader        = "ActionDataPartitionTryToLeader"
+	ActionAddDataPartitionRaftLearner     = "ActionAddDataPartitionRaftLearner"
+	ActionPromoteDataPartitionRaftLearner = "ActionPromoteDataPartitionRaftLearner"
 
 	ActionCreateDataPartition        = "ActionCreateDataPartition"
 	ActionLoadDataPartition          = "ActionLoadDataPartition"
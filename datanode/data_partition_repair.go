@@ -192,7 +192,7 @@ func (dp *DataPartition) getRemoteExtentInfo(extentType uint8, tinyExtents []uin
 		}
 		p.Size = uint32(len(p.Data))
 	}
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = gConnPool.GetConnect(target) // get remote connection
 	if err != nil {
 		err = errors.Trace(err, "getRemoteExtentInfo DataPartition(%v) get host(%v) connect", dp.partitionID, target)
@@ -374,7 +374,7 @@ func (dp *DataPartition) buildExtentRepairTasks(repairTasks []*DataPartitionRepa
 
 func (dp *DataPartition) notifyFollower(wg *sync.WaitGroup, index int, members []*DataPartitionRepairTask) (err error) {
 	p := repl.NewPacketToNotifyExtentRepair(dp.partitionID) // notify all the followers to repair
-	var conn *net.TCPConn
+	var conn net.Conn
 	target := dp.getReplicaAddr(index)
 	p.Data, _ = json.Marshal(members[index])
 	p.Size = uint32(len(p.Data))
@@ -452,7 +452,7 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 	if storage.IsTinyExtent(remoteExtentInfo.FileID) {
 		request = repl.NewTinyExtentRepairReadPacket(dp.partitionID, remoteExtentInfo.FileID, int(localExtentInfo.Size), int(sizeDiff))
 	}
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = gConnPool.GetConnect(remoteExtentInfo.Source)
 	if err != nil {
 		return errors.Trace(err, "streamRepairExtent get conn from host(%v) error", remoteExtentInfo.Source)
@@ -551,3 +551,60 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 	return
 
 }
+
+// copyExtent fills the new extent with the data of the source extent key, which is read from the
+// hosts of the source data partition in turn, resumed from the data copied if a host fails.
+func (dp *DataPartition) copyExtent(extentID uint64, source *proto.CopyExtentRequest) (err error) {
+	if len(source.Hosts) == 0 {
+		return fmt.Errorf("copyExtent no host of the source partition(%v)", source.PartitionId)
+	}
+	var copied uint32
+	for _, host := range source.Hosts {
+		if copied, err = dp.copyExtentFromHost(host, extentID, source, copied); err == nil {
+			return
+		}
+		log.LogWarnf("action[copyExtent] copy extent(%v_%v) from host(%v) source(%v_%v) copied(%v) err(%v).",
+			dp.partitionID, extentID, host, source.PartitionId, source.ExtentId, copied, err)
+	}
+	return
+}
+
+func (dp *DataPartition) copyExtentFromHost(host string, extentID uint64, source *proto.CopyExtentRequest, copied uint32) (uint32, error) {
+	store := dp.ExtentStore()
+	request := repl.NewExtentCopyReadPacket(source.PartitionId, source.ExtentId,
+		int(source.ExtentOffset)+int(copied), int(source.Size-copied))
+	conn, err := gConnPool.GetConnect(host)
+	if err != nil {
+		return copied, errors.Trace(err, "copyExtent get conn from host(%v) error", host)
+	}
+	defer gConnPool.PutConnect(conn, true)
+
+	if err = request.WriteToConn(conn); err != nil {
+		return copied, errors.Trace(err, "copyExtent send read to host(%v) error", host)
+	}
+	for copied < source.Size {
+		reply := repl.NewPacket()
+		if err = reply.ReadFromConn(conn, proto.CopyExtentDeadLineTime); err != nil {
+			return copied, errors.Trace(err, "copyExtent receive data error, copied(%v) size(%v)", copied, source.Size)
+		}
+		if reply.ResultCode != proto.OpOk {
+			return copied, errors.Trace(fmt.Errorf("unknow result code"),
+				"copyExtent receive opcode error(%v), copied(%v) size(%v)", string(reply.Data[:reply.Size]), copied, source.Size)
+		}
+		if reply.ReqID != request.ReqID || reply.PartitionID != request.PartitionID || reply.ExtentID != request.ExtentID ||
+			reply.Size == 0 || reply.ExtentOffset != int64(source.ExtentOffset)+int64(copied) {
+			return copied, errors.Trace(fmt.Errorf("unavali reply"), "copyExtent receive unavalid "+
+				"request(%v) reply(%v), copied(%v) size(%v)", request.GetUniqueLogId(), reply.GetUniqueLogId(), copied, source.Size)
+		}
+		if actualCrc := crc32.ChecksumIEEE(reply.Data[:reply.Size]); reply.CRC != actualCrc {
+			return copied, fmt.Errorf("copyExtent crc mismatch expectCrc(%v) actualCrc(%v) request(%v) reply(%v)",
+				reply.CRC, actualCrc, request.GetUniqueLogId(), reply.GetUniqueLogId())
+		}
+		if err = store.Write(extentID, int64(copied), int64(reply.Size), reply.Data, reply.CRC, storage.AppendWriteType, BufferWrite); err != nil {
+			dp.checkIsDiskError(err)
+			return copied, errors.Trace(err, "copyExtent write data error")
+		}
+		copied += reply.Size
+	}
+	return copied, nil
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
 	"os"
@@ -57,17 +58,19 @@ type Disk struct {
 	RejectWrite  bool
 	partitionMap map[uint64]*DataPartition
 	space        *SpaceManager
+	ioEngine     storage.IOEngine // of the extent stores on the disk
 }
 
 type PartitionVisitor func(dp *DataPartition)
 
-func NewDisk(path string, reservedSpace uint64, maxErrCnt int, space *SpaceManager) (d *Disk) {
+func NewDisk(path string, reservedSpace uint64, maxErrCnt int, ioEngine storage.IOEngine, space *SpaceManager) (d *Disk) {
 	d = new(Disk)
 	d.Path = path
 	d.ReservedSpace = reservedSpace
 	d.MaxErrCnt = maxErrCnt
 	d.RejectWrite = false
 	d.space = space
+	d.ioEngine = ioEngine
 	d.partitionMap = make(map[uint64]*DataPartition)
 	d.computeUsage()
 	d.updateSpaceInfo()
@@ -0,0 +1,210 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/grpc"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// startGRPCService serves the service chubaofs.DataNode of proto/chubaofs.proto if the port is
+// configured, whose calls are forwarded to the TCP service as packets.
+func (s *DataNode) startGRPCService() (err error) {
+	if s.grpcPort == "" {
+		return
+	}
+	gateway := proto.NewGRPCGateway(fmt.Sprintf("127.0.0.1:%v", s.port))
+	s.grpcServer = grpc.NewServer()
+	s.grpcServer.Handle(proto.GRPCDataNodeService, "Call", grpc.Unary(gateway.Call))
+	s.grpcServer.Handle(proto.GRPCDataNodeService, "Read", func(stream *grpc.Stream) error {
+		return grpcRead(gateway, stream)
+	})
+	s.grpcServer.Handle(proto.GRPCDataNodeService, "Write", func(stream *grpc.Stream) error {
+		return grpcWrite(gateway, stream)
+	})
+	if err = s.grpcServer.Start(s.grpcPort); err != nil {
+		log.LogErrorf("action[startGRPCService] failed to listen port(%v), err:%v", s.grpcPort, err)
+	}
+	return
+}
+
+func (s *DataNode) stopGRPCService() {
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+		log.LogDebugf("action[stopGRPCService] stop grpc service.")
+	}
+}
+
+// grpcRead streams the data of the extent read by a stream read packet, which is replied by a
+// packet per read block.
+func grpcRead(gateway *proto.GRPCGateway, stream *grpc.Stream) (err error) {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return grpc.Errorf(grpc.InvalidArgument, "no request message")
+	}
+	if err != nil {
+		return
+	}
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpStreamRead
+	p.ExtentType = proto.NormalExtentType
+	if err = grpc.Decode(req, func(f *grpc.Field) error {
+		switch f.Number {
+		case 1:
+			p.PartitionID = f.Varint
+		case 2:
+			p.ExtentID = f.Varint
+		case 3:
+			p.ExtentOffset = int64(f.Varint)
+		case 4:
+			p.Size = uint32(f.Varint)
+		case 5:
+			if f.Varint != 0 {
+				p.Opcode = proto.OpStreamFollowerRead
+			}
+		}
+		return nil
+	}); err != nil {
+		return grpc.Errorf(grpc.InvalidArgument, "%v", err)
+	}
+	if p.Size == 0 {
+		return
+	}
+
+	return gateway.Forward(stream.Context(), p, func(conn net.Conn) (err error) {
+		for read := uint32(0); read < p.Size; {
+			reply := proto.NewPacket()
+			if err = reply.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+				return grpc.Errorf(grpc.Unavailable, "read reply: %v", err)
+			}
+			if reply.ReqID != p.ReqID {
+				return grpc.Errorf(grpc.Internal, "reply ReqID(%v) mismatches request ReqID(%v)", reply.ReqID, p.ReqID)
+			}
+			if err = reply.GRPCStatus(); err != nil {
+				return
+			}
+			if reply.Size == 0 || crc32.ChecksumIEEE(reply.Data[:reply.Size]) != reply.CRC {
+				return grpc.Errorf(grpc.DataLoss, "reply of offset(%v) size(%v) mismatches its crc", reply.ExtentOffset, reply.Size)
+			}
+			e := &grpc.Encoder{}
+			e.Uint64(1, uint64(reply.ExtentOffset))
+			e.Bytes(2, reply.Data[:reply.Size])
+			e.Uint64(3, uint64(reply.CRC))
+			err = stream.Send(e.Data())
+			proto.Buffers.Put(reply.Data)
+			if err != nil {
+				return
+			}
+			read += reply.Size
+		}
+		return
+	})
+}
+
+// grpcWrite appends the data of the requests to the extent by the write packets of the block size
+// at most, which are replicated to the followers by the leader as the ones of the clients.
+func grpcWrite(gateway *proto.GRPCGateway, stream *grpc.Stream) (err error) {
+	var (
+		partitionID, extentID, offset, written uint64
+		hosts                                  []string
+		received                               bool
+	)
+	for {
+		var req, data []byte
+		if req, err = stream.Recv(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+		if err = grpc.Decode(req, func(f *grpc.Field) error {
+			if f.Number == 4 {
+				data = f.Bytes
+				return nil
+			}
+			if received {
+				// the extent is given by the first request
+				return nil
+			}
+			switch f.Number {
+			case 1:
+				partitionID = f.Varint
+			case 2:
+				extentID = f.Varint
+			case 3:
+				offset = f.Varint
+			case 5:
+				hosts = append(hosts, string(f.Bytes))
+			}
+			return nil
+		}); err != nil {
+			return grpc.Errorf(grpc.InvalidArgument, "%v", err)
+		}
+		if !received {
+			if partitionID == 0 || extentID == 0 || len(hosts) == 0 || len(hosts) > 255 {
+				return grpc.Errorf(grpc.InvalidArgument, "invalid partition(%v) extent(%v) hosts(%v)", partitionID, extentID, hosts)
+			}
+			received = true
+		}
+
+		for len(data) > 0 {
+			size := util.Min(len(data), util.BlockSize)
+			p := proto.NewPacketReqID()
+			p.Opcode = proto.OpWrite
+			p.ExtentType = proto.NormalExtentType
+			p.PartitionID = partitionID
+			p.ExtentID = extentID
+			p.ExtentOffset = int64(offset + written)
+			p.Arg = []byte(strings.Join(hosts[1:], proto.AddrSplit) + proto.AddrSplit)
+			p.ArgLen = uint32(len(p.Arg))
+			p.RemainingFollowers = uint8(len(hosts) - 1)
+			p.Data = data[:size]
+			p.Size = uint32(size)
+			p.CRC = crc32.ChecksumIEEE(p.Data)
+			if err = gateway.Forward(stream.Context(), p, func(conn net.Conn) (err error) {
+				reply := proto.NewPacket()
+				if err = reply.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+					return grpc.Errorf(grpc.Unavailable, "read reply: %v", err)
+				}
+				if reply.ReqID != p.ReqID {
+					return grpc.Errorf(grpc.Internal, "reply ReqID(%v) mismatches request ReqID(%v)", reply.ReqID, p.ReqID)
+				}
+				err = reply.GRPCStatus()
+				proto.Buffers.Put(reply.Data)
+				return
+			}); err != nil {
+				log.LogWarnf("grpcWrite: partition(%v) extent(%v) offset(%v) size(%v) err(%v)",
+					partitionID, extentID, p.ExtentOffset, size, err)
+				return
+			}
+			written += uint64(size)
+			data = data[size:]
+		}
+	}
+	if !received {
+		return grpc.Errorf(grpc.InvalidArgument, "no request message")
+	}
+	e := &grpc.Encoder{}
+	e.Uint64(1, written)
+	return stream.Send(e.Data())
+}
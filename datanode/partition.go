@@ -24,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"hash/crc32"
@@ -59,6 +60,10 @@ type DataPartitionMetadata struct {
 	Hosts                   []string
 	DataPartitionCreateType int
 	LastTruncateID          uint64
+	FrozenSnapshotID        uint32
+	FrozenExtentID          uint64
+	FreezingSnapshotID      uint32
+	Sealed                  bool
 }
 
 type sortedPeers []proto.Peer
@@ -115,6 +120,23 @@ type DataPartition struct {
 	loadExtentHeaderStatus        int
 	FullSyncTinyDeleteTime        int64
 	DataPartitionCreateType       int
+
+	// the extents not larger than frozenExtentID are copied on write for the snapshots of the
+	// volume, the latest of which is frozenSnapshotID, and none are frozen if it is 0. All the
+	// extents are copied on write while the snapshot freezingSnapshotID is being taken.
+	frozenSnapshotID   uint32
+	frozenExtentID     uint64
+	freezingSnapshotID uint32
+	frozenLock         sync.RWMutex
+
+	// the partition is sealed against the writes by the migration to the erasure coding, and the
+	// writes in progress hold the read lock, so that none is left once it is sealed.
+	sealed   bool
+	sealLock sync.RWMutex
+
+	// the last time the partition was read or written by the clients, which is the time it is
+	// loaded since started, for the master to find the cold partitions of the tiering.
+	accessTime int64
 }
 
 func CreateDataPartition(dpCfg *dataPartitionCfg, disk *Disk, request *proto.CreateDataPartitionRequest) (dp *DataPartition, err error) {
@@ -178,6 +200,9 @@ func LoadDataPartition(partitionDir string, disk *Disk) (dp *DataPartition, err
 	log.LogInfof("Action(LoadDataPartition) PartitionID(%v) meta(%v)", dp.partitionID, meta)
 	dp.DataPartitionCreateType = meta.DataPartitionCreateType
 	dp.lastTruncateID = meta.LastTruncateID
+	dp.frozenSnapshotID, dp.frozenExtentID = meta.FrozenSnapshotID, meta.FrozenExtentID
+	dp.freezingSnapshotID = meta.FreezingSnapshotID
+	dp.sealed = meta.Sealed
 	if meta.DataPartitionCreateType == proto.NormalCreateDataPartition {
 		err = dp.StartRaft()
 	} else {
@@ -211,9 +236,10 @@ func newDataPartition(dpCfg *dataPartitionCfg, disk *Disk) (dp *DataPartition, e
 		snapshot:        make([]*proto.File, 0),
 		partitionStatus: proto.ReadWrite,
 		config:          dpCfg,
+		accessTime:      time.Now().Unix(),
 	}
 	partition.replicasInit()
-	partition.extentStore, err = storage.NewExtentStore(partition.path, dpCfg.PartitionID, dpCfg.PartitionSize)
+	partition.extentStore, err = storage.NewExtentStore(partition.path, dpCfg.PartitionID, dpCfg.PartitionSize, disk.ioEngine)
 	if err != nil {
 		return
 	}
@@ -251,6 +277,14 @@ func (dp *DataPartition) Path() string {
 	return dp.path
 }
 
+// RaftHealth returns the health of the raft group of the data partition on this node.
+func (dp *DataPartition) RaftHealth() *proto.RaftHealth {
+	if dp.raftPartition == nil {
+		return nil
+	}
+	return raftstore.NewRaftHealth(dp.raftPartition.Status())
+}
+
 // IsRaftLeader tells if the given address belongs to the raft leader.
 func (dp *DataPartition) IsRaftLeader() (addr string, ok bool) {
 	if dp.raftPartition == nil {
@@ -339,6 +373,16 @@ func (dp *DataPartition) IsRejectWrite() bool {
 	return dp.Disk().RejectWrite
 }
 
+// touch records the access of the clients to the partition.
+func (dp *DataPartition) touch() {
+	atomic.StoreInt64(&dp.accessTime, time.Now().Unix())
+}
+
+// AccessTime returns the last time the partition was read or written by the clients.
+func (dp *DataPartition) AccessTime() int64 {
+	return atomic.LoadInt64(&dp.accessTime)
+}
+
 // Status returns the partition status.
 func (dp *DataPartition) Status() int {
 	return dp.partitionStatus
@@ -392,6 +436,9 @@ func (dp *DataPartition) PersistMetadata() (err error) {
 		CreateTime:              time.Now().Format(TimeLayout),
 		LastTruncateID:          dp.lastTruncateID,
 	}
+	md.FrozenSnapshotID, md.FrozenExtentID = dp.frozenWatermark()
+	md.FreezingSnapshotID = dp.freezingSnapshot()
+	md.Sealed = dp.isSealed()
 	if metaData, err = json.Marshal(md); err != nil {
 		return
 	}
@@ -439,6 +486,9 @@ func (dp *DataPartition) statusUpdate() {
 	if dp.extentStore.GetExtentCount() >= storage.MaxExtentCount {
 		status = proto.ReadOnly
 	}
+	if dp.isSealed() {
+		status = proto.ReadOnly
+	}
 	if dp.Status() == proto.Unavailable {
 		status = proto.Unavailable
 	}
@@ -672,7 +722,7 @@ func (dp *DataPartition) doStreamFixTinyDeleteRecord(repairTask *DataPartitionRe
 	var (
 		localTinyDeleteFileSize int64
 		err                     error
-		conn                    *net.TCPConn
+		conn                    net.Conn
 	)
 	if !isFullSync {
 		localTinyDeleteFileSize = dp.extentStore.LoadTinyDeleteFileOffset()
@@ -0,0 +1,56 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+const MetricExtentCompressionRatio = "extent_compression_ratio"
+
+// setVolCompressions sets the compression of the extents of the partitions to the one of the volumes
+// pushed by the master, and reports the compression ratios of the volumes.
+func (s *DataNode) setVolCompressions(volCompressions map[string]string) {
+	rawSizes, storedSizes := make(map[string]int64), make(map[string]int64)
+	s.space.RangePartitions(func(partition *DataPartition) bool {
+		codec, err := storage.ParseCompression(volCompressions[partition.volumeID])
+		if err != nil {
+			log.LogWarnf("action[setVolCompressions] partition(%v) err(%v)", partition.partitionID, err)
+			codec = storage.CompressNone
+		}
+		store := partition.ExtentStore()
+		if store.Compression() != codec {
+			store.SetCompression(codec)
+			log.LogInfof("action[setVolCompressions] partition(%v) compression(%v)",
+				partition.partitionID, volCompressions[partition.volumeID])
+		}
+		rawSize, storedSize := store.CompressionStat()
+		rawSizes[partition.volumeID] += rawSize
+		storedSizes[partition.volumeID] += storedSize
+		return true
+	})
+	if !metrics.Enabled() {
+		return
+	}
+	compressionRatio := metrics.GaugeVec(MetricExtentCompressionRatio,
+		"Ratio of the size of the data to the stored one of the blocks compressed.", metrics.LabelVolume)
+	for volName, rawSize := range rawSizes {
+		if storedSize := storedSizes[volName]; storedSize > 0 {
+			compressionRatio.WithLabelValues(metrics.Cluster(), volName).Set(float64(rawSize) / float64(storedSize))
+		}
+	}
+}
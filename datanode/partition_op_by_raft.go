@@ -24,6 +24,7 @@ import (
 	"github.com/chubaofs/chubaofs/storage"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/tracing"
 	"github.com/tiglabs/raft"
 	"net"
 	"strings"
@@ -175,7 +176,7 @@ func (dp *DataPartition) CheckLeader(request *repl.Packet, connect net.Conn) (er
 	if !ok {
 		err = raft.ErrNotLeader
 		logContent := fmt.Sprintf("action[ReadCheck] %v.", request.LogMessage(request.GetOpMsg(), connect.RemoteAddr().String(), request.StartT, err))
-		log.LogWarnf(logContent)
+		log.WithPartition(request.PartitionID).WithReqID(request.ReqID).LogWarnf(logContent)
 		return
 	}
 
@@ -259,7 +260,11 @@ func (dp *DataPartition) RandomWriteSubmit(pkg *repl.Packet) (err error) {
 	var (
 		resp interface{}
 	)
-	if resp, err = dp.Put(nil, val); err != nil {
+	span := tracing.StartSpan("datanode.raftSubmit", pkg.Span.Context())
+	span.SetTag("partition", dp.partitionID)
+	resp, err = dp.Put(nil, val)
+	span.Finish(err)
+	if err != nil {
 		return
 	}
 
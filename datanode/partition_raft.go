@@ -90,6 +90,9 @@ func (dp *DataPartition) StartRaft() (err error) {
 			HeartbeatPort: heartbeatPort,
 			ReplicaPort:   replicaPort,
 		}
+		if peer.Learner {
+			rp.Type = raftproto.PeerLearner
+		}
 		peers = append(peers, rp)
 	}
 	log.LogDebugf("start partition(%v) raft peers: %s path: %s",
@@ -121,6 +124,10 @@ func (dp *DataPartition) CanRemoveRaftMember(peer proto.Peer) error {
 	hasExsit := false
 	for _, p := range dp.config.Peers {
 		if p.ID == peer.ID {
+			if p.Learner {
+				// the learners are not counted in the quorum
+				return nil
+			}
 			hasExsit = true
 			break
 		}
@@ -129,15 +136,21 @@ func (dp *DataPartition) CanRemoveRaftMember(peer proto.Peer) error {
 		return fmt.Errorf("peer(%v) not exsit hasDownReplicasExcludePeer(%v)", peer, downReplicas)
 	}
 
+	learners := make(map[uint64]bool)
+	for _, p := range dp.config.Peers {
+		if p.Learner {
+			learners[p.ID] = true
+		}
+	}
 	hasDownReplicasExcludePeer := make([]uint64, 0)
 	for _, nodeID := range downReplicas {
-		if nodeID.NodeID == peer.ID {
+		if nodeID.NodeID == peer.ID || learners[nodeID.NodeID] {
 			continue
 		}
 		hasDownReplicasExcludePeer = append(hasDownReplicasExcludePeer, nodeID.NodeID)
 	}
 
-	sumReplicas := len(dp.config.Peers)
+	sumReplicas := len(dp.config.Peers) - len(learners)
 	if sumReplicas%2 == 1 {
 		if sumReplicas-len(hasDownReplicasExcludePeer) > (sumReplicas/2 + 1) {
 			return nil
@@ -151,6 +164,24 @@ func (dp *DataPartition) CanRemoveRaftMember(peer proto.Peer) error {
 	return fmt.Errorf("hasDownReplicasExcludePeer(%v) too much,so donnot offline (%v)", downReplicas, peer)
 }
 
+// CanPromoteRaftLearner returns true if the peer is a learner caught up with the leader, or false
+// if it has been promoted already.
+func (dp *DataPartition) CanPromoteRaftLearner(peer proto.Peer) (promote bool, err error) {
+	for _, p := range dp.config.Peers {
+		if p.ID != peer.ID {
+			continue
+		}
+		if !p.Learner {
+			return false, nil
+		}
+		if err = dp.raftPartition.CheckLearnerCaughtUp(peer.ID); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("peer(%v) not exsit", peer)
+}
+
 // StartRaftLoggingSchedule starts the task schedule as follows:
 // 1. write the raft applied id into disk.
 // 2. collect the applied ids from raft members.
@@ -318,6 +349,20 @@ func (dp *DataPartition) addRaftNode(req *proto.DataPartitionDecommissionRequest
 	return
 }
 
+// Promote a raft learner to a voter.
+func (dp *DataPartition) promoteRaftLearner(req *proto.DataPartitionDecommissionRequest, index uint64) (isUpdated bool, err error) {
+	for i, peer := range dp.config.Peers {
+		if peer.ID == req.AddPeer.ID && peer.Learner {
+			dp.config.Peers[i].Learner = false
+			isUpdated = true
+			break
+		}
+	}
+	log.LogInfof("promoteRaftLearner: partitionID(%v) nodeID(%v) index(%v) learner(%v) updated(%v)",
+		req.PartitionId, dp.config.NodeID, index, req.AddPeer, isUpdated)
+	return
+}
+
 // Delete a raft node.
 func (dp *DataPartition) removeRaftNode(req *proto.DataPartitionDecommissionRequest, index uint64) (isUpdated bool, err error) {
 	peerIndex := -1
@@ -425,9 +470,25 @@ func (s *DataNode) parseRaftConfig(cfg *config.Config) (err error) {
 	}
 	s.raftHeartbeat = cfg.GetString(ConfigKeyRaftHeartbeat)
 	s.raftReplica = cfg.GetString(ConfigKeyRaftReplica)
+	s.raftPreVote = cfg.GetBool(ConfigKeyRaftPreVote)
+	s.raftWalCompression = cfg.GetString(ConfigKeyRaftWalCompression)
+	s.raftWalPreAllocate = cfg.GetBool(ConfigKeyRaftWalPreAllocate)
+	s.raftWalRecycleFiles = int(cfg.GetInt(ConfigKeyRaftWalRecycleFiles))
+	s.raftSnapshotRate = int(cfg.GetInt(ConfigKeyRaftSnapshotRate))
+	s.raftSnapshotResumeTimeout = int(cfg.GetInt(ConfigKeyRaftSnapshotResumeTimeout))
+	s.raftSendLinger = int(cfg.GetInt(ConfigKeyRaftSendLinger))
+	s.raftWalDir = cfg.GetString(ConfigKeyRaftWalDir)
 	log.LogDebugf("[parseRaftConfig] load raftDir(%v).", s.raftDir)
 	log.LogDebugf("[parseRaftConfig] load raftHearbeat(%v).", s.raftHeartbeat)
 	log.LogDebugf("[parseRaftConfig] load raftReplica(%v).", s.raftReplica)
+	log.LogDebugf("[parseRaftConfig] load raftPreVote(%v).", s.raftPreVote)
+	log.LogDebugf("[parseRaftConfig] load raftWalCompression(%v).", s.raftWalCompression)
+	log.LogDebugf("[parseRaftConfig] load raftWalPreAllocate(%v).", s.raftWalPreAllocate)
+	log.LogDebugf("[parseRaftConfig] load raftWalRecycleFiles(%v).", s.raftWalRecycleFiles)
+	log.LogDebugf("[parseRaftConfig] load raftSnapshotRate(%v).", s.raftSnapshotRate)
+	log.LogDebugf("[parseRaftConfig] load raftSnapshotResumeTimeout(%v).", s.raftSnapshotResumeTimeout)
+	log.LogDebugf("[parseRaftConfig] load raftSendLinger(%v).", s.raftSendLinger)
+	log.LogDebugf("[parseRaftConfig] load raftWalDir(%v).", s.raftWalDir)
 	return
 }
 
@@ -456,12 +517,20 @@ func (s *DataNode) startRaftServer(cfg *config.Config) (err error) {
 	}
 
 	raftConf := &raftstore.Config{
-		NodeID:            s.nodeID,
-		RaftPath:          s.raftDir,
-		IPAddr:            LocalIP,
-		HeartbeatPort:     heartbeatPort,
-		ReplicaPort:       replicatePort,
-		NumOfLogsToRetain: DefaultRaftLogsToRetain,
+		NodeID:                s.nodeID,
+		RaftPath:              s.raftDir,
+		IPAddr:                LocalIP,
+		HeartbeatPort:         heartbeatPort,
+		ReplicaPort:           replicatePort,
+		NumOfLogsToRetain:     DefaultRaftLogsToRetain,
+		PreVote:               s.raftPreVote,
+		WalCompression:        s.raftWalCompression,
+		WalPreAllocate:        s.raftWalPreAllocate,
+		WalRecycleFiles:       s.raftWalRecycleFiles,
+		SnapshotRate:          s.raftSnapshotRate,
+		SnapshotResumeTimeout: s.raftSnapshotResumeTimeout,
+		SendLinger:            s.raftSendLinger,
+		WalDir:                s.raftWalDir,
 	}
 	s.raftStore, err = raftstore.NewRaftStore(raftConf)
 	if err != nil {
@@ -546,7 +615,7 @@ func (dp *DataPartition) findMaxAppliedID(allAppliedIDs []uint64) (maxAppliedID
 // Get the partition size from the leader.
 func (dp *DataPartition) getLeaderPartitionSize(maxExtentID uint64) (size uint64, err error) {
 	var (
-		conn *net.TCPConn
+		conn net.Conn
 	)
 
 	p := NewPacketToGetPartitionSize(dp.partitionID)
@@ -582,7 +651,7 @@ func (dp *DataPartition) getLeaderPartitionSize(maxExtentID uint64) (size uint64
 // Get the MaxExtentID partition  from the leader.
 func (dp *DataPartition) getLeaderMaxExtentIDAndPartitionSize() (maxExtentID, PartitionSize uint64, err error) {
 	var (
-		conn *net.TCPConn
+		conn net.Conn
 	)
 
 	p := NewPacketToGetMaxExtentIDAndPartitionSIze(dp.partitionID)
@@ -629,7 +698,7 @@ func (dp *DataPartition) broadcastMinAppliedID(minAppliedID uint64) (err error)
 			continue
 		}
 		target := dp.getReplicaAddr(i)
-		var conn *net.TCPConn
+		var conn net.Conn
 		conn, err = gConnPool.GetConnect(target)
 		if err != nil {
 			return
@@ -684,7 +753,7 @@ func (dp *DataPartition) getAllReplicaAppliedID() (allAppliedID []uint64, replyN
 
 // Get target members' applied id
 func (dp *DataPartition) getRemoteAppliedID(target string, p *repl.Packet) (appliedID uint64, err error) {
-	var conn *net.TCPConn
+	var conn net.Conn
 	start := time.Now().UnixNano()
 	defer func() {
 		if err != nil {
@@ -58,6 +58,11 @@ func (dp *DataPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 		isUpdated, err = dp.removeRaftNode(req, index)
 	case raftproto.ConfUpdateNode:
 		isUpdated, err = dp.updateRaftNode(req, index)
+	case raftproto.ConfAddLearner:
+		req.AddPeer.Learner = true
+		isUpdated, err = dp.addRaftNode(req, index)
+	case raftproto.ConfPromoteLearner:
+		isUpdated, err = dp.promoteRaftLearner(req, index)
 	}
 	if err != nil {
 		log.LogErrorf("action[ApplyMemberChange] dp(%v) type(%v) err(%v).", dp.partitionID, confChange.Type, err)
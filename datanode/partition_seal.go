@@ -0,0 +1,73 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"github.com/chubaofs/chubaofs/repl"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// A data partition is sealed by the ec node migrating it before the last check of its extents,
+// so that no extent is modified after the check. It is kept sealed, even across the restarts,
+// until the replicas are deleted by the master once the erasure coded partition is committed,
+// or unsealed by the ec node if the migration fails.
+
+// Handle OpECSealDataPartition and OpECUnsealDataPartition packets.
+func (s *DataNode) handlePacketToSealDataPartition(p *repl.Packet, sealed bool) {
+	partition := p.Object.(*DataPartition)
+	if err := partition.seal(sealed); err != nil {
+		p.PackErrorBody(ActionSealPartition, err.Error())
+		return
+	}
+	p.PacketOkReply()
+}
+
+// seal seals or unseals the partition, which waits for the writes in progress.
+func (dp *DataPartition) seal(sealed bool) (err error) {
+	dp.sealLock.Lock()
+	changed := dp.sealed != sealed
+	dp.sealed = sealed
+	dp.sealLock.Unlock()
+	if !changed {
+		return
+	}
+	if err = dp.PersistMetadata(); err != nil {
+		log.LogErrorf("action[seal] partition(%v) sealed(%v) persist metadata err(%v)", dp.partitionID, sealed, err)
+		return
+	}
+	log.LogInfof("action[seal] partition(%v) sealed(%v)", dp.partitionID, sealed)
+	return
+}
+
+func (dp *DataPartition) isSealed() bool {
+	dp.sealLock.RLock()
+	defer dp.sealLock.RUnlock()
+	return dp.sealed
+}
+
+// beginWrite fails if the partition is sealed, otherwise the partition is not sealed until
+// endWrite is called.
+func (dp *DataPartition) beginWrite() error {
+	dp.sealLock.RLock()
+	if dp.sealed {
+		dp.sealLock.RUnlock()
+		return ErrDataPartitionSealed
+	}
+	return nil
+}
+
+func (dp *DataPartition) endWrite() {
+	dp.sealLock.RUnlock()
+}
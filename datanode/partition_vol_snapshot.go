@@ -0,0 +1,109 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The extents of a data partition are frozen for the snapshots of the volume. While a snapshot is
+// being taken all the extents are frozen, as the meta partitions clone their trees after the data
+// partitions have seen the snapshot, and the extents created meanwhile are referred by the clones.
+// Once the meta partitions have cloned, the extents are frozen by the watermark of the extent IDs
+// allocated so far. The frozen extents are never written randomly, the clients write the data to
+// the new extents instead, so that the extents referred by the snapshots are kept as they are. The
+// tiny extents are always frozen since they are shared.
+
+// freezeVolSnapshots freezes the extents of the partitions for the snapshots of the volumes pushed by
+// the master, which is nil if the master does not support the snapshots.
+func (s *DataNode) freezeVolSnapshots(volSnapshots map[string][]*proto.VolSnapshot) {
+	if volSnapshots == nil {
+		return
+	}
+	s.space.RangePartitions(func(partition *DataPartition) bool {
+		partition.freezeVolSnapshots(volSnapshots[partition.volumeID])
+		return true
+	})
+}
+
+func (dp *DataPartition) freezeVolSnapshots(snapshots []*proto.VolSnapshot) {
+	// the extents created before are the ones of the snapshots marked, the meta partitions
+	// have cloned their trees for them
+	if !dp.applyVolSnapshots(snapshots, dp.extentStore.BaseExtentID()) {
+		return
+	}
+	if err := dp.PersistMetadata(); err != nil {
+		log.LogErrorf("action[freezeVolSnapshots] partition(%v) persist metadata err(%v)", dp.partitionID, err)
+	}
+	frozenSnapshotID, frozenExtentID := dp.frozenWatermark()
+	log.LogInfof("action[freezeVolSnapshots] partition(%v) snapshot(%v) frozen extent(%v) freezing snapshot(%v)",
+		dp.partitionID, frozenSnapshotID, frozenExtentID, dp.freezingSnapshot())
+}
+
+// applyVolSnapshots freezes all the extents for the snapshot being taken until the meta partitions
+// have cloned for it, and then raises the watermark to the base extent ID, returning whether the
+// frozen extents are changed.
+func (dp *DataPartition) applyVolSnapshots(snapshots []*proto.VolSnapshot, baseExtentID uint64) (changed bool) {
+	var marked, freezing uint32
+	for _, snapshot := range snapshots {
+		switch snapshot.Status {
+		case proto.VolSnapshotFreezingData, proto.VolSnapshotFreezingMeta:
+			if snapshot.ID > freezing {
+				freezing = snapshot.ID
+			}
+		default:
+			if snapshot.ID > marked {
+				marked = snapshot.ID
+			}
+		}
+	}
+	dp.frozenLock.Lock()
+	defer dp.frozenLock.Unlock()
+	switch {
+	case marked == 0 && dp.frozenExtentID != 0:
+		// all the snapshots are deleted, the snapshot ID is kept since the IDs are never reused
+		dp.frozenExtentID, changed = 0, true
+	case marked > dp.frozenSnapshotID:
+		dp.frozenSnapshotID, dp.frozenExtentID, changed = marked, baseExtentID, true
+	}
+	// the watermark is raised before the extents are unfrozen
+	if freezing != dp.freezingSnapshotID {
+		dp.freezingSnapshotID, changed = freezing, true
+	}
+	return
+}
+
+// frozenWatermark returns the latest snapshot the extents are frozen for and the largest extent frozen.
+func (dp *DataPartition) frozenWatermark() (snapshotID uint32, extentID uint64) {
+	dp.frozenLock.RLock()
+	defer dp.frozenLock.RUnlock()
+	return dp.frozenSnapshotID, dp.frozenExtentID
+}
+
+// freezingSnapshot returns the snapshot being taken all the extents are frozen for, 0 if none.
+func (dp *DataPartition) freezingSnapshot() uint32 {
+	dp.frozenLock.RLock()
+	defer dp.frozenLock.RUnlock()
+	return dp.freezingSnapshotID
+}
+
+// isExtentFrozen tells whether the extent must be copied on write, the tiny extents are frozen
+// as well since their IDs are less than the watermark.
+func (dp *DataPartition) isExtentFrozen(extentID uint64) bool {
+	dp.frozenLock.RLock()
+	defer dp.frozenLock.RUnlock()
+	return dp.freezingSnapshotID != 0 || extentID <= dp.frozenExtentID
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestFreezeVolSnapshotWindow(t *testing.T) {
+	dp := new(DataPartition)
+	snapshot := &proto.VolSnapshot{ID: 1, Status: proto.VolSnapshotFreezingData}
+	if !dp.applyVolSnapshots([]*proto.VolSnapshot{snapshot}, 100) || !dp.isExtentFrozen(200) {
+		t.Fatalf("all the extents should be frozen while freezing the data")
+	}
+	// the extent 110 is created before the meta partitions clone their trees, so it is
+	// referred by the snapshot
+	snapshot.Status = proto.VolSnapshotFreezingMeta
+	dp.applyVolSnapshots([]*proto.VolSnapshot{snapshot}, 110)
+	if !dp.isExtentFrozen(110) {
+		t.Fatalf("extent created between the phases should be frozen")
+	}
+	snapshot.Status = proto.VolSnapshotMarkingData
+	if !dp.applyVolSnapshots([]*proto.VolSnapshot{snapshot}, 120) {
+		t.Fatalf("watermark should be raised once the meta partitions have cloned")
+	}
+	if id, extentID := dp.frozenWatermark(); id != 1 || extentID != 120 || dp.freezingSnapshot() != 0 {
+		t.Fatalf("unexpected watermark snapshot(%v) extent(%v) freezing(%v)", id, extentID, dp.freezingSnapshot())
+	}
+	if !dp.isExtentFrozen(110) || dp.isExtentFrozen(121) {
+		t.Fatalf("unexpected frozen extents after the watermark")
+	}
+	snapshot.Status = proto.VolSnapshotReady
+	if dp.applyVolSnapshots([]*proto.VolSnapshot{snapshot}, 130) {
+		t.Fatalf("ready snapshot should not raise the watermark again")
+	}
+	// the next snapshot freezes all the extents again, the watermark of the first is kept
+	next := &proto.VolSnapshot{ID: 2, Status: proto.VolSnapshotFreezingData}
+	dp.applyVolSnapshots([]*proto.VolSnapshot{snapshot, next}, 140)
+	if !dp.isExtentFrozen(135) {
+		t.Fatalf("all the extents should be frozen while taking the next snapshot")
+	}
+	if dp.applyVolSnapshots(nil, 150); dp.isExtentFrozen(110) {
+		t.Fatalf("no extent should be frozen without snapshots")
+	}
+}
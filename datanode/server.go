@@ -16,6 +16,7 @@ package datanode
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"regexp"
@@ -34,16 +35,24 @@ import (
 	"github.com/chubaofs/chubaofs/raftstore"
 	"github.com/chubaofs/chubaofs/repl"
 	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/storage"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/fault"
+	"github.com/chubaofs/chubaofs/util/grpc"
+	"github.com/chubaofs/chubaofs/util/health"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
+	"github.com/chubaofs/chubaofs/util/mtls"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
 )
 
 var (
 	ErrIncorrectStoreType       = errors.New("Incorrect store type")
 	ErrNoSpaceToCreatePartition = errors.New("No disk space to create a data partition")
 	ErrNewSpaceManagerFailed    = errors.New("Creater new space manager failed")
+	ErrDataPartitionSealed      = errors.New("Data partition is sealed for the erasure coding")
 
 	LocalIP, serverPort string
 	gConnPool           = util.NewConnectPool()
@@ -64,38 +73,69 @@ const (
 )
 
 const (
-	ConfigKeyLocalIP       = "localIP"       // string
-	ConfigKeyPort          = "port"          // int
-	ConfigKeyMasterAddr    = "masterAddr"    // array
-	ConfigKeyCell          = "cell"          // string
-	ConfigKeyDisks         = "disks"         // array
-	ConfigKeyRaftDir       = "raftDir"       // string
-	ConfigKeyRaftHeartbeat = "raftHeartbeat" // string
-	ConfigKeyRaftReplica   = "raftReplica"   // string
+	ConfigKeyLocalIP                   = "localIP"                   // string
+	ConfigKeyPort                      = "port"                      // int
+	ConfigKeyMasterAddr                = "masterAddr"                // array
+	ConfigKeyCell                      = "cell"                      // string
+	ConfigKeyDisks                     = "disks"                     // array
+	ConfigKeyRaftDir                   = "raftDir"                   // string
+	ConfigKeyRaftHeartbeat             = "raftHeartbeat"             // string
+	ConfigKeyRaftReplica               = "raftReplica"               // string
+	ConfigKeyRaftPreVote               = "raftPreVote"               // bool
+	ConfigKeyRaftWalCompression        = "raftWalCompression"        // string
+	ConfigKeyRaftWalPreAllocate        = "raftWalPreAllocate"        // bool
+	ConfigKeyRaftWalRecycleFiles       = "raftWalRecycleFiles"       // int
+	ConfigKeyRaftSnapshotRate          = "raftSnapshotRate"          // int
+	ConfigKeyRaftSnapshotResumeTimeout = "raftSnapshotResumeTimeout" // int
+	ConfigKeyRaftSendLinger            = "raftSendLinger"            // int
+	ConfigKeyRaftWalDir                = "raftWalDir"                // string
+	ConfigKeyMinClientVersion          = "minClientVersion"          // int
+	ConfigKeyMediaType                 = "mediaType"                 // string
+	ConfigKeyZone                      = "zone"                      // string
+	ConfigKeyRack                      = "rack"                      // string
+	ConfigKeyDisableZeroCopyRead       = "disableZeroCopyRead"       // bool
+	ConfigKeyGRPCPort                  = "grpcPort"                  // string
 )
 
 // DataNode defines the structure of a data node.
 type DataNode struct {
-	space           *SpaceManager
-	port            string
-	cellName        string
-	clusterID       string
-	localIP         string
-	localServerAddr string
-	nodeID          uint64
-	raftDir         string
-	raftHeartbeat   string
-	raftReplica     string
-	raftStore       raftstore.RaftStore
+	space                     *SpaceManager
+	port                      string
+	cellName                  string
+	clusterID                 string
+	localIP                   string
+	localServerAddr           string
+	nodeID                    uint64
+	minClientVersion          uint32
+	mediaType                 string
+	zoneName                  string
+	rackName                  string
+	raftDir                   string
+	raftHeartbeat             string
+	raftReplica               string
+	raftPreVote               bool
+	raftWalCompression        string
+	raftWalPreAllocate        bool
+	raftWalRecycleFiles       int
+	raftSnapshotRate          int
+	raftSnapshotResumeTimeout int
+	raftSendLinger            int
+	raftWalDir                string
+	raftStore                 raftstore.RaftStore
+	zeroCopyRead              bool // sends the blocks read by sendfile(2)
+	grpcPort                  string
 
 	tcpListener net.Listener
+	grpcServer  *grpc.Server
 	stopC       chan bool
 
 	control common.Control
+	opStats *metrics.OpStats
+	limiter *ratelimit.Limiter
 }
 
 func NewServer() *DataNode {
-	return &DataNode{}
+	return &DataNode{opStats: metrics.NewOpStats(), limiter: ratelimit.NewLimiter(ratelimit.ModuleDataNode)}
 }
 
 func (s *DataNode) Start(cfg *config.Config) (err error) {
@@ -151,6 +191,9 @@ func doStart(server common.Server, cfg *config.Config) (err error) {
 	if err = s.startTCPService(); err != nil {
 		return
 	}
+	if err = s.startGRPCService(); err != nil {
+		return
+	}
 	go s.registerHandler()
 
 	return
@@ -162,6 +205,7 @@ func doShutdown(server common.Server) {
 		return
 	}
 	close(s.stopC)
+	s.stopGRPCService()
 	s.stopTCPService()
 	s.stopRaftServer()
 }
@@ -191,9 +235,25 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 	if s.cellName == "" {
 		s.cellName = DefaultCellName
 	}
+	s.minClientVersion = uint32(cfg.GetInt(ConfigKeyMinClientVersion))
+	s.mediaType = cfg.GetString(ConfigKeyMediaType)
+	if s.mediaType != "" && !proto.IsValidMedia(s.mediaType) {
+		return fmt.Errorf("Err:mediaType %v unavalid", s.mediaType)
+	}
+	s.zoneName = cfg.GetString(ConfigKeyZone)
+	s.rackName = cfg.GetString(ConfigKeyRack)
+	s.zeroCopyRead = !cfg.GetBool(ConfigKeyDisableZeroCopyRead)
+	if s.grpcPort = cfg.GetString(ConfigKeyGRPCPort); s.grpcPort != "" && (!regexpPort.MatchString(s.grpcPort) || s.grpcPort == s.port) {
+		return fmt.Errorf("Err:grpcPort %v unavalid", s.grpcPort)
+	}
 	log.LogDebugf("action[parseConfig] load masterAddrs(%v).", MasterClient.Nodes())
 	log.LogDebugf("action[parseConfig] load port(%v).", s.port)
 	log.LogDebugf("action[parseConfig] load cellName(%v).", s.cellName)
+	log.LogDebugf("action[parseConfig] load minClientVersion(%v).", s.minClientVersion)
+	log.LogDebugf("action[parseConfig] load mediaType(%v).", s.mediaType)
+	log.LogDebugf("action[parseConfig] load zone(%v) rack(%v).", s.zoneName, s.rackName)
+	log.LogDebugf("action[parseConfig] load zeroCopyRead(%v).", s.zeroCopyRead)
+	log.LogDebugf("action[parseConfig] load grpcPort(%v).", s.grpcPort)
 	return
 }
 
@@ -212,10 +272,10 @@ func (s *DataNode) startSpaceManager(cfg *config.Config) (err error) {
 	for _, d := range cfg.GetArray(ConfigKeyDisks) {
 		log.LogDebugf("action[startSpaceManager] load disk raw config(%v).", d)
 
-		// format "PATH:RESET_SIZE
+		// format "PATH:RESET_SIZE[:IO_ENGINE]
 		arr := strings.Split(d.(string), ":")
-		if len(arr) != 2 {
-			return errors.New("Invalid disk configuration. Example: PATH:RESERVE_SIZE")
+		if len(arr) != 2 && len(arr) != 3 {
+			return errors.New("Invalid disk configuration. Example: PATH:RESERVE_SIZE[:IO_ENGINE]")
 		}
 		path := arr[0]
 		fileInfo, err := os.Stat(path)
@@ -233,12 +293,19 @@ func (s *DataNode) startSpaceManager(cfg *config.Config) (err error) {
 		if reservedSpace < DefaultDiskRetainMin {
 			reservedSpace = DefaultDiskRetainMin
 		}
+		var ioEngine string
+		if len(arr) == 3 {
+			if ioEngine = arr[2]; ioEngine != storage.IOEngineSync && ioEngine != storage.IOEngineIOUring {
+				return errors.New(fmt.Sprintf("Invalid disk io engine %v, which is %v or %v",
+					ioEngine, storage.IOEngineSync, storage.IOEngineIOUring))
+			}
+		}
 
 		wg.Add(1)
-		go func(wg *sync.WaitGroup, path string, reservedSpace uint64) {
+		go func(wg *sync.WaitGroup, path string, reservedSpace uint64, ioEngine string) {
 			defer wg.Done()
-			s.space.LoadDisk(path, reservedSpace, DefaultDiskMaxErr)
-		}(&wg, path, reservedSpace)
+			s.space.LoadDisk(path, reservedSpace, DefaultDiskMaxErr, ioEngine)
+		}(&wg, path, reservedSpace, ioEngine)
 	}
 	wg.Wait()
 	return nil
@@ -343,6 +410,40 @@ func (s *DataNode) registerHandler() {
 	http.HandleFunc("/block", s.getBlockCrcAPI)
 	http.HandleFunc("/stats", s.getStatAPI)
 	http.HandleFunc("/raftStatus", s.getRaftStatus)
+	http.HandleFunc("/opStats", s.getOpStatsAPI)
+
+	health.AddCheck("state", s.checkState)
+	health.AddCheck("disk", s.checkDisks)
+	health.RegisterHTTP()
+	fault.RegisterHTTP()
+}
+
+func (s *DataNode) checkState() error {
+	if !s.control.IsRunning() {
+		return errors.New("not running")
+	}
+	if s.raftStore == nil {
+		return errors.New("raft is not started")
+	}
+	return nil
+}
+
+// checkDisks reports the datanode unavailable if none of the disks is usable.
+func (s *DataNode) checkDisks() error {
+	if s.space == nil {
+		return errors.New("disks are not loaded")
+	}
+	disks := s.space.GetDisks()
+	var unavailable int
+	for _, d := range disks {
+		if d.Status == proto.Unavailable {
+			unavailable++
+		}
+	}
+	if unavailable == len(disks) {
+		return fmt.Errorf("no disk is available: total(%v) unavailable(%v)", len(disks), unavailable)
+	}
+	return nil
 }
 
 func (s *DataNode) startTCPService() (err error) {
@@ -379,12 +480,20 @@ func (s *DataNode) stopTCPService() (err error) {
 }
 
 func (s *DataNode) serveConn(conn net.Conn) {
-	space := s.space
-	space.Stats().AddConnection()
 	c, _ := conn.(*net.TCPConn)
 	c.SetKeepAlive(true)
 	c.SetNoDelay(true)
-	packetProcessor := repl.NewReplProtocol(c, s.Prepare, s.OperatePacket, s.Post)
+	conn, err := mtls.Server(c)
+	if err != nil {
+		if err != io.EOF {
+			log.LogWarnf("action[serveConn] tls handshake with %v failed: %v", c.RemoteAddr(), err)
+		}
+		c.Close()
+		return
+	}
+	space := s.space
+	space.Stats().AddConnection()
+	packetProcessor := repl.NewReplProtocol(conn, s.Prepare, s.OperatePacket, s.Post)
 	packetProcessor.ServerConn()
 }
 
@@ -68,6 +68,10 @@ func (s *DataNode) getStatAPI(w http.ResponseWriter, r *http.Request) {
 	s.buildSuccessResp(w, response)
 }
 
+func (s *DataNode) getOpStatsAPI(w http.ResponseWriter, r *http.Request) {
+	s.buildSuccessResp(w, s.opStats.Snapshot())
+}
+
 func (s *DataNode) getRaftStatus(w http.ResponseWriter, r *http.Request) {
 	const (
 		paramRaftID = "raftID"
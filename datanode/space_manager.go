@@ -21,6 +21,7 @@ import (
 
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
+	"github.com/chubaofs/chubaofs/storage"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 	"math"
@@ -120,7 +121,7 @@ func (manager *SpaceManager) Stats() *Stats {
 	return manager.stats
 }
 
-func (manager *SpaceManager) LoadDisk(path string, reservedSpace uint64, maxErrCnt int) (err error) {
+func (manager *SpaceManager) LoadDisk(path string, reservedSpace uint64, maxErrCnt int, ioEngine string) (err error) {
 	var (
 		disk    *Disk
 		visitor PartitionVisitor
@@ -135,7 +136,13 @@ func (manager *SpaceManager) LoadDisk(path string, reservedSpace uint64, maxErrC
 		}
 	}
 	if _, err = manager.GetDisk(path); err != nil {
-		disk = NewDisk(path, reservedSpace, maxErrCnt, manager)
+		var engine storage.IOEngine
+		if engine, err = storage.NewIOEngine(ioEngine); err != nil {
+			log.LogWarnf("action[LoadDisk] disk(%v) falls back to the sync io engine: %v", path, err)
+			engine, _ = storage.NewIOEngine(storage.IOEngineSync)
+		}
+		log.LogInfof("action[LoadDisk] disk(%v) io engine(%v).", path, engine.Name())
+		disk = NewDisk(path, reservedSpace, maxErrCnt, engine, manager)
 		disk.RestorePartition(visitor)
 		manager.putDisk(disk)
 		err = nil
@@ -297,6 +304,8 @@ func (manager *SpaceManager) DeletePartition(dpID uint64) {
 
 func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatResponse) {
 	response.Status = proto.TaskSucceeds
+	response.ProtocolVersion = proto.ProtocolVersion
+	response.MinClientVersion = s.minClientVersion
 	stat := s.space.Stats()
 	stat.Lock()
 	response.Used = stat.Used
@@ -310,6 +319,9 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 	stat.Unlock()
 
 	response.CellName = s.cellName
+	response.MediaType = s.mediaType
+	response.ZoneName = s.zoneName
+	response.RackName = s.rackName
 	response.PartitionReports = make([]*proto.PartitionReport, 0)
 	space := s.space
 	space.RangePartitions(func(partition *DataPartition) bool {
@@ -324,7 +336,11 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 			IsLeader:        isLeader,
 			ExtentCount:     partition.GetExtentCount(),
 			NeedCompare:     true,
+			RaftHealth:      partition.RaftHealth(),
 		}
+		vr.FrozenSnapshotID, _ = partition.frozenWatermark()
+		vr.FreezingSnapshotID = partition.freezingSnapshot()
+		vr.AccessTime = partition.AccessTime()
 		log.LogDebugf("action[Heartbeats] dpid(%v), status(%v) total(%v) used(%v) leader(%v) b(%v).", vr.PartitionID, vr.PartitionStatus, vr.Total, vr.Used, leaderAddr, vr.IsLeader)
 		response.PartitionReports = append(response.PartitionReports, vr)
 		return true
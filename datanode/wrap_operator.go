@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"strconv"
 	"time"
 
@@ -32,42 +33,63 @@ import (
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/fault"
 	"github.com/chubaofs/chubaofs/util/log"
 	"github.com/tiglabs/raft"
 	raftProto "github.com/tiglabs/raft/proto"
 )
 
-func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
+func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 	sz := p.Size
 	tpObject := exporter.NewTPCnt(p.GetOpMsg())
 	start := time.Now().UnixNano()
 	defer func() {
 		resultSize := p.Size
 		p.Size = sz
+		entry := log.WithPartition(p.PartitionID).WithReqID(p.ReqID)
 		if p.IsErrPacket() {
 			err = fmt.Errorf("op(%v) error(%v)", p.GetOpMsg(), string(p.Data[:resultSize]))
 			logContent := fmt.Sprintf("action[OperatePacket] %v.",
 				p.LogMessage(p.GetOpMsg(), c.RemoteAddr().String(), start, err))
-			log.LogErrorf(logContent)
+			entry.LogErrorf(logContent)
 		} else {
 			logContent := fmt.Sprintf("action[OperatePacket] %v.",
 				p.LogMessage(p.GetOpMsg(), c.RemoteAddr().String(), start, nil))
 			switch p.Opcode {
 			case proto.OpStreamRead, proto.OpRead, proto.OpExtentRepairRead, proto.OpStreamFollowerRead:
 			case proto.OpReadTinyDeleteRecord:
-				log.LogRead(logContent)
+				entry.LogReadf(logContent)
 			case proto.OpWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite, proto.OpSyncWrite, proto.OpMarkDelete:
-				log.LogWrite(logContent)
+				entry.LogWritef(logContent)
 			default:
-				log.LogInfo(logContent)
+				entry.LogInfof(logContent)
 			}
 		}
 		p.Size = resultSize
 		tpObject.Set(err)
 	}()
+	if fault.Enabled {
+		if err = fault.Inject(fault.DataOp(p.GetOpMsg())); err != nil {
+			// the reply of a packet goes through the replication pipeline, so a dropped
+			// packet closes the connection instead of being left without any reply
+			if err == fault.ErrDropped {
+				c.Close()
+			}
+			p.PackErrorBody("FaultInjection", err.Error())
+			return
+		}
+	}
+	switch p.Opcode {
+	case proto.OpWrite, proto.OpSyncWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite, proto.OpStreamRead, proto.OpStreamFollowerRead:
+		if partition, ok := p.Object.(*DataPartition); ok {
+			partition.touch()
+		}
+	}
 	switch p.Opcode {
 	case proto.OpCreateExtent:
 		s.handlePacketToCreateExtent(p)
+	case proto.OpCopyExtent:
+		s.handlePacketToCopyExtent(p)
 	case proto.OpWrite, proto.OpSyncWrite:
 		s.handleWritePacket(p)
 	case proto.OpStreamRead:
@@ -86,6 +108,10 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
 		s.handlePacketToNotifyExtentRepair(p)
 	case proto.OpGetAllWatermarks:
 		s.handlePacketToGetAllWatermarks(p)
+	case proto.OpECSealDataPartition:
+		s.handlePacketToSealDataPartition(p, true)
+	case proto.OpECUnsealDataPartition:
+		s.handlePacketToSealDataPartition(p, false)
 	case proto.OpCreateDataPartition:
 		s.handlePacketToCreateDataPartition(p)
 	case proto.OpLoadDataPartition:
@@ -104,6 +130,10 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
 		s.handlePacketToRemoveDataPartitionRaftMember(p)
 	case proto.OpDataPartitionTryToLeader:
 		s.handlePacketToDataPartitionTryToLeaderrr(p)
+	case proto.OpAddDataPartitionRaftLearner:
+		s.handlePacketToAddDataPartitionRaftLearner(p)
+	case proto.OpPromoteDataPartitionRaftLearner:
+		s.handlePacketToPromoteDataPartitionRaftLearner(p)
 	case proto.OpGetPartitionSize:
 		s.handlePacketToGetPartitionSize(p)
 	case proto.OpGetMaxExtentIDAndPartitionSize:
@@ -112,6 +142,8 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
 		s.handlePacketToReadTinyDeleteRecordFile(p, c)
 	case proto.OpBroadcastMinAppliedID:
 		s.handleBroadcastMinAppliedID(p)
+	case proto.OpProtoHandshake:
+		p.PacketHandshakeReply(s.minClientVersion)
 	default:
 		p.PackErrorBody(repl.ErrorUnknownOp.Error(), repl.ErrorUnknownOp.Error()+strconv.Itoa(int(p.Opcode)))
 	}
@@ -130,6 +162,10 @@ func (s *DataNode) handlePacketToCreateExtent(p *repl.Packet) {
 		}
 	}()
 	partition := p.Object.(*DataPartition)
+	if err = partition.beginWrite(); err != nil {
+		return
+	}
+	defer partition.endWrite()
 	if partition.Available() <= 0 || partition.disk.Status == proto.ReadOnly || partition.IsRejectWrite() {
 		err = storage.NoSpaceError
 		return
@@ -142,6 +178,48 @@ func (s *DataNode) handlePacketToCreateExtent(p *repl.Packet) {
 	return
 }
 
+// Handle OpCopyExtent packet, which creates the extent and fills it with the data of the source
+// extent key on each replica.
+func (s *DataNode) handlePacketToCopyExtent(p *repl.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionCopyExtent, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	partition := p.Object.(*DataPartition)
+	if err = partition.beginWrite(); err != nil {
+		return
+	}
+	defer partition.endWrite()
+	if partition.Available() <= 0 || partition.disk.Status == proto.ReadOnly || partition.IsRejectWrite() {
+		err = storage.NoSpaceError
+		return
+	} else if partition.disk.Status == proto.Unavailable {
+		err = storage.BrokenDiskError
+		return
+	}
+	source := &proto.CopyExtentRequest{}
+	if err = json.Unmarshal(p.Data[:p.Size], source); err != nil {
+		return
+	}
+	store := partition.ExtentStore()
+	if err = store.Create(p.ExtentID); err != nil {
+		return
+	}
+	if err = partition.copyExtent(p.ExtentID, source); err != nil {
+		// the extent partly copied is never referred to by any file
+		if deleteErr := store.MarkDelete(p.ExtentID, 0, 0, 0); deleteErr != nil {
+			log.LogWarnf("action[handlePacketToCopyExtent] partition(%v) extent(%v) mark delete err(%v).",
+				p.PartitionID, p.ExtentID, deleteErr)
+		}
+	}
+	s.incDiskErrCnt(p.PartitionID, err, WriteFlag)
+	return
+}
+
 // Handle OpCreateDataPartition packet.
 func (s *DataNode) handlePacketToCreateDataPartition(p *repl.Packet) {
 	var (
@@ -204,13 +282,18 @@ func (s *DataNode) handleHeartbeatPacket(p *repl.Packet) {
 	go func() {
 		request := &proto.HeartBeatRequest{}
 		response := &proto.DataNodeHeartbeatResponse{}
-		s.buildHeartBeatResponse(response)
 
 		if task.OpCode == proto.OpDataNodeHeartbeat {
 			marshaled, _ := json.Marshal(task.Request)
 			_ = json.Unmarshal(marshaled, request)
+			s.limiter.Update(request.RateLimits)
+			// the extents are frozen before the report, so that the master sees it at once
+			s.freezeVolSnapshots(request.VolSnapshots)
+			s.setVolCompressions(request.VolCompressions)
+			s.buildHeartBeatResponse(response)
 			response.Status = proto.TaskSucceeds
 		} else {
+			s.buildHeartBeatResponse(response)
 			response.Status = proto.TaskFailed
 			err = fmt.Errorf("illegal opcode")
 			response.Result = err.Error()
@@ -346,6 +429,10 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 		}
 	}()
 	partition := p.Object.(*DataPartition)
+	if err = partition.beginWrite(); err != nil {
+		return
+	}
+	defer partition.endWrite()
 	if partition.Available() <= 0 || partition.disk.Status == proto.ReadOnly || partition.IsRejectWrite() {
 		err = storage.NoSpaceError
 		return
@@ -401,6 +488,14 @@ func (s *DataNode) handleRandomWritePacket(p *repl.Packet) {
 		err = raft.ErrNotLeader
 		return
 	}
+	if partition.isExtentFrozen(p.ExtentID) {
+		err = storage.ExtentFrozenError
+		return
+	}
+	if err = partition.beginWrite(); err != nil {
+		return
+	}
+	defer partition.endWrite()
 	err = partition.RandomWriteSubmit(p)
 	if err != nil && strings.Contains(err.Error(), raft.ErrNotLeader.Error()) {
 		err = raft.ErrNotLeader
@@ -456,16 +551,21 @@ func (s *DataNode) handleExtentRepaiReadPacket(p *repl.Packet, connect net.Conn,
 		reply := repl.NewStreamReadResponsePacket(p.ReqID, p.PartitionID, p.ExtentID)
 		reply.StartT = p.StartT
 		currReadSize := uint32(util.Min(int(needReplySize), util.ReadBlockSize))
-		if currReadSize == util.ReadBlockSize {
-			reply.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
-		} else {
-			reply.Data = make([]byte, currReadSize)
-		}
 		tpObject := exporter.NewTPCnt(p.GetOpMsg())
 		reply.ExtentOffset = offset
 		p.Size = uint32(currReadSize)
 		p.ExtentOffset = offset
-		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead)
+		file, crc, zeroCopy := s.sendableBlock(store, connect, reply.ExtentID, offset, currReadSize)
+		if zeroCopy {
+			reply.CRC = crc
+		} else {
+			if currReadSize == util.ReadBlockSize {
+				reply.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
+			} else {
+				reply.Data = make([]byte, currReadSize)
+			}
+			reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead)
+		}
 		partition.checkIsDiskError(err)
 		tpObject.Set(err)
 		p.CRC = reply.CRC
@@ -476,23 +576,39 @@ func (s *DataNode) handleExtentRepaiReadPacket(p *repl.Packet, connect net.Conn,
 		reply.ResultCode = proto.OpOk
 		reply.Opcode = p.Opcode
 		p.ResultCode = proto.OpOk
-		if err = reply.WriteToConn(connect); err != nil {
+		if zeroCopy {
+			if err = reply.WriteHeaderToConn(connect); err == nil {
+				_, err = util.SendFile(connect, file, offset, int(currReadSize))
+			}
+		} else {
+			err = reply.WriteToConn(connect)
+		}
+		if err != nil {
 			return
 		}
 		needReplySize -= currReadSize
 		offset += int64(currReadSize)
-		if currReadSize == util.ReadBlockSize {
+		if !zeroCopy && currReadSize == util.ReadBlockSize {
 			proto.Buffers.Put(reply.Data)
 		}
 		logContent := fmt.Sprintf("action[operatePacket] %v.",
 			reply.LogMessage(reply.GetOpMsg(), connect.RemoteAddr().String(), reply.StartT, err))
-		log.LogReadf(logContent)
+		log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogReadf(logContent)
 	}
 	p.PacketOkReply()
 
 	return
 }
 
+// sendableBlock tells whether the block can be sent to the connection by sendfile(2), whose CRC is
+// known without reading the data.
+func (s *DataNode) sendableBlock(store *storage.ExtentStore, conn net.Conn, extentID uint64, offset int64, size uint32) (*os.File, uint32, bool) {
+	if !s.zeroCopyRead || !util.CanSendFile(conn) {
+		return nil, 0, false
+	}
+	return store.SendableBlock(extentID, offset, int64(size))
+}
+
 func (s *DataNode) handlePacketToGetAllWatermarks(p *repl.Packet) {
 	var (
 		buf       []byte
@@ -532,7 +648,7 @@ func (s *DataNode) writeEmptyPacketOnTinyExtentRepairRead(reply *repl.Packet, ne
 	reply.Size = uint32(replySize)
 	logContent := fmt.Sprintf("action[operatePacket] %v.",
 		reply.LogMessage(reply.GetOpMsg(), connect.RemoteAddr().String(), reply.StartT, err))
-	log.LogReadf(logContent)
+	log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogReadf(logContent)
 
 	return
 }
@@ -624,14 +740,14 @@ func (s *DataNode) handleTinyExtentRepairRead(request *repl.Packet, connect net.
 		}
 		logContent := fmt.Sprintf("action[operatePacket] %v.",
 			reply.LogMessage(reply.GetOpMsg(), connect.RemoteAddr().String(), reply.StartT, err))
-		log.LogReadf(logContent)
+		log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogReadf(logContent)
 	}
 
 	request.PacketOkReply()
 	return
 }
 
-func (s *DataNode) handlePacketToReadTinyDeleteRecordFile(p *repl.Packet, connect *net.TCPConn) {
+func (s *DataNode) handlePacketToReadTinyDeleteRecordFile(p *repl.Packet, connect net.Conn) {
 	var (
 		err error
 	)
@@ -915,6 +1031,124 @@ func (s *DataNode) handlePacketToRemoveDataPartitionRaftMember(p *repl.Packet) {
 	return
 }
 
+func (s *DataNode) handlePacketToAddDataPartitionRaftLearner(p *repl.Packet) {
+	var (
+		err          error
+		reqData      []byte
+		isRaftLeader bool
+		req          = &proto.AddDataPartitionRaftLearnerRequest{}
+	)
+
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionAddDataPartitionRaftLearner, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+
+	adminTask := &proto.AdminTask{}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		return
+	}
+
+	reqData, err = json.Marshal(adminTask.Request)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(reqData, req); err != nil {
+		return
+	}
+	p.AddMesgLog(string(reqData))
+	dp := s.space.Partition(req.PartitionId)
+	if dp == nil {
+		err = proto.ErrDataPartitionNotExists
+		return
+	}
+	p.PartitionID = req.PartitionId
+	if dp.IsExsitReplica(req.AddLearner.Addr) {
+		log.LogInfof("handlePacketToAddDataPartitionRaftLearner recive MasterCommand: %v "+
+			"addRaftAddr(%v) has exsit", string(reqData), req.AddLearner.Addr)
+		return
+	}
+	isRaftLeader, err = s.forwardToRaftLeader(dp, p)
+	if !isRaftLeader {
+		return
+	}
+
+	if req.AddLearner.ID != 0 {
+		req.AddLearner.Learner = true
+		if reqData, err = json.Marshal(&proto.DataPartitionDecommissionRequest{
+			PartitionId: req.PartitionId,
+			AddPeer:     req.AddLearner,
+		}); err != nil {
+			return
+		}
+		_, err = dp.ChangeRaftMember(raftProto.ConfAddLearner, raftProto.Peer{ID: req.AddLearner.ID}, reqData)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *DataNode) handlePacketToPromoteDataPartitionRaftLearner(p *repl.Packet) {
+	var (
+		err          error
+		reqData      []byte
+		isRaftLeader bool
+		promote      bool
+		req          = &proto.PromoteDataPartitionRaftLearnerRequest{}
+	)
+
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionPromoteDataPartitionRaftLearner, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+
+	adminTask := &proto.AdminTask{}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		return
+	}
+
+	reqData, err = json.Marshal(adminTask.Request)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(reqData, req); err != nil {
+		return
+	}
+	p.AddMesgLog(string(reqData))
+	dp := s.space.Partition(req.PartitionId)
+	if dp == nil {
+		err = proto.ErrDataPartitionNotExists
+		return
+	}
+	p.PartitionID = req.PartitionId
+	isRaftLeader, err = s.forwardToRaftLeader(dp, p)
+	if !isRaftLeader {
+		return
+	}
+	if promote, err = dp.CanPromoteRaftLearner(req.PromoteLearner); err != nil || !promote {
+		return
+	}
+	if reqData, err = json.Marshal(&proto.DataPartitionDecommissionRequest{
+		PartitionId: req.PartitionId,
+		AddPeer:     req.PromoteLearner,
+	}); err != nil {
+		return
+	}
+	_, err = dp.ChangeRaftMember(raftProto.ConfPromoteLearner, raftProto.Peer{ID: req.PromoteLearner.ID}, reqData)
+	return
+}
+
 func (s *DataNode) handlePacketToDataPartitionTryToLeaderrr(p *repl.Packet) {
 	var (
 		err error
@@ -943,7 +1177,7 @@ func (s *DataNode) handlePacketToDataPartitionTryToLeaderrr(p *repl.Packet) {
 
 func (s *DataNode) forwardToRaftLeader(dp *DataPartition, p *repl.Packet) (ok bool, err error) {
 	var (
-		conn       *net.TCPConn
+		conn       net.Conn
 		leaderAddr string
 	)
 
@@ -15,9 +15,14 @@
 package datanode
 
 import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/chubaofs/chubaofs/repl"
 	"github.com/chubaofs/chubaofs/storage"
-	"sync/atomic"
+	"github.com/chubaofs/chubaofs/util/log"
 )
 
 func (s *DataNode) Post(p *repl.Packet) error {
@@ -67,6 +72,14 @@ func (s *DataNode) addMetrics(p *repl.Packet) {
 		return
 	}
 	p.AfterTp()
+	s.logSlowOp(p)
+	if !p.RecvT.IsZero() {
+		var err error
+		if p.IsErrPacket() {
+			err = errors.New(p.GetResultMsg())
+		}
+		s.opStats.Record(p.GetOpMsg(), p.RecvT, err)
+	}
 	if p.Object == nil {
 		return
 	}
@@ -75,3 +88,28 @@ func (s *DataNode) addMetrics(p *repl.Packet) {
 		return
 	}
 }
+
+func (s *DataNode) logSlowOp(p *repl.Packet) {
+	if p.RecvT.IsZero() {
+		return
+	}
+	op := &log.SlowOp{
+		Op:        p.GetOpMsg(),
+		Partition: p.PartitionID,
+		ReqID:     p.ReqID,
+		Exec:      time.Since(p.RecvT),
+	}
+	if !p.ProcessT.IsZero() {
+		op.Wait = p.ProcessT.Sub(p.RecvT)
+		op.Exec -= op.Wait
+	}
+	if p.IsForwardPkt() {
+		op.Detail = fmt.Sprintf("ExtentID(%v) Size(%v) Followers(%v)", p.ExtentID, p.Size, p.RemainingFollowers)
+	} else {
+		op.Detail = fmt.Sprintf("ExtentID(%v) Size(%v)", p.ExtentID, p.Size)
+	}
+	if p.IsErrPacket() {
+		op.Err = errors.New(p.GetResultMsg())
+	}
+	log.LogSlowOp(op)
+}
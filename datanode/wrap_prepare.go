@@ -21,6 +21,7 @@ import (
 	"github.com/chubaofs/chubaofs/repl"
 	"github.com/chubaofs/chubaofs/storage"
 	"hash/crc32"
+	"net"
 )
 
 func (s *DataNode) Prepare(p *repl.Packet) (err error) {
@@ -34,6 +35,10 @@ func (s *DataNode) Prepare(p *repl.Packet) (err error) {
 		return
 	}
 	p.BeforeTp(s.clusterID)
+	// the handshake is not bound to any partition
+	if p.Opcode == proto.OpProtoHandshake {
+		return
+	}
 	err = s.checkStoreMode(p)
 	if err != nil {
 		return
@@ -44,6 +49,9 @@ func (s *DataNode) Prepare(p *repl.Packet) (err error) {
 	if err = s.checkPartition(p); err != nil {
 		return
 	}
+	if err = s.checkRateLimit(p); err != nil {
+		return
+	}
 
 	// For certain packet, we meed to add some additional extent information.
 	if err = s.addExtentInfo(p); err != nil {
@@ -79,7 +87,7 @@ func (s *DataNode) checkPartition(p *repl.Packet) (err error) {
 		return
 	}
 	p.Object = dp
-	if p.IsWriteOperation() || p.IsCreateExtentOperation() {
+	if p.IsWriteOperation() || p.IsCreateExtentOperation() || p.IsCopyExtentOperation() {
 		if dp.Available() <= 0 {
 			err = storage.NoSpaceError
 			return
@@ -88,6 +96,34 @@ func (s *DataNode) checkPartition(p *repl.Packet) (err error) {
 	return
 }
 
+// checkRateLimit limits the ops and the bytes of the clients by the rate limits of the datanode
+// module, the bytes are the ones read or written. The packets of the leaders to the followers are
+// never limited, nor the repairs.
+func (s *DataNode) checkRateLimit(p *repl.Packet) (err error) {
+	dp := p.Object.(*DataPartition)
+	var bytes int
+	switch {
+	case p.Opcode == proto.OpStreamRead, p.Opcode == proto.OpRead, p.Opcode == proto.OpStreamFollowerRead,
+		p.IsRandomWrite():
+		bytes = int(p.Size)
+	case p.IsWriteOperation(), p.IsCreateExtentOperation(), p.IsCopyExtentOperation(), p.IsMarkDeleteExtentOperation():
+		// the packets of the leader have no remaining followers
+		if !p.IsForwardPacket() && dp.getReplicaLen() > 1 {
+			return
+		}
+		if p.IsWriteOperation() {
+			bytes = int(p.Size)
+		}
+	default:
+		return
+	}
+	client, _, e := net.SplitHostPort(p.RemoteAddr)
+	if e != nil {
+		client = p.RemoteAddr
+	}
+	return s.limiter.AllowBytes(dp.volumeID, p.GetOpMsg(), client, bytes)
+}
+
 func (s *DataNode) addExtentInfo(p *repl.Packet) error {
 	partition := p.Object.(*DataPartition)
 	store := p.Object.(*DataPartition).ExtentStore()
@@ -105,7 +141,7 @@ func (s *DataNode) addExtentInfo(p *repl.Packet) error {
 		if err != nil {
 			return fmt.Errorf("addExtentInfo partition %v  %v GetTinyExtentOffset error %v", p.PartitionID, extentID, err.Error())
 		}
-	} else if p.IsLeaderPacket() && p.IsCreateExtentOperation() {
+	} else if p.IsLeaderPacket() && (p.IsCreateExtentOperation() || p.IsCopyExtentOperation()) {
 		if partition.GetExtentCount() >= storage.MaxExtentCount*3 {
 			return fmt.Errorf("addExtentInfo partition %v has reached maxExtentId", p.PartitionID)
 		}
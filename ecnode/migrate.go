@@ -0,0 +1,339 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/ec"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Handle OpECMigrateDataPartition packet. The migration runs asynchronously and its result is
+// sent to the master, the task resent by the master during the migration is ignored.
+func (s *ECNode) handleMigratePacket(p *proto.Packet) {
+	task := &proto.AdminTask{}
+	request := &proto.ECMigrateRequest{}
+	err := json.Unmarshal(p.Data[:p.Size], task)
+	if err == nil {
+		var marshaled []byte
+		if marshaled, err = json.Marshal(task.Request); err == nil {
+			err = json.Unmarshal(marshaled, request)
+		}
+	}
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkReply()
+	if !s.startMigration(request.PartitionID) {
+		return
+	}
+	go func() {
+		defer s.finishMigration(request.PartitionID)
+		response := &proto.ECMigrateResponse{PartitionID: request.PartitionID}
+		if err := s.migrate(request, response); err != nil {
+			log.LogErrorf("action[migrate] partition(%v) of vol(%v) failed: %v", request.PartitionID, request.VolName, err)
+			response.Status = proto.TaskFailed
+			response.Result = err.Error()
+		} else {
+			response.Status = proto.TaskSucceeds
+		}
+		task.Response = response
+		if err := MasterClient.NodeAPI().ResponseECNodeTask(task); err != nil {
+			log.LogErrorf("action[migrate] partition(%v) response to master failed: %v", request.PartitionID, err)
+		}
+	}()
+}
+
+func (s *ECNode) startMigration(partitionID uint64) bool {
+	s.migrateMux.Lock()
+	defer s.migrateMux.Unlock()
+	if s.migrating[partitionID] {
+		return false
+	}
+	s.migrating[partitionID] = true
+	return true
+}
+
+func (s *ECNode) finishMigration(partitionID uint64) {
+	s.migrateMux.Lock()
+	defer s.migrateMux.Unlock()
+	delete(s.migrating, partitionID)
+}
+
+// migrate copies the extents of the data partition from a replica, encodes them and writes the
+// shards to the ec nodes, then commits the partition on the ec nodes. The migration fails if any
+// extent is modified within the cold age or during the migration. The replicas are sealed against
+// the writes before the last check, and unsealed only if the migration fails, so that no extent
+// is modified after the check until the replicas are deleted.
+func (s *ECNode) migrate(request *proto.ECMigrateRequest, response *proto.ECMigrateResponse) (err error) {
+	if ep := s.partition(request.PartitionID); ep != nil {
+		meta := ep.copyMeta()
+		response.ExtentCount = len(meta.Extents)
+		for _, size := range meta.Extents {
+			response.Size += size
+		}
+		return
+	}
+	encoder, err := ec.NewEncoder(request.DataShards, request.ParityShards)
+	if err != nil {
+		return
+	}
+	if len(request.ECHosts) != encoder.TotalShards() || request.StripeUnit == 0 {
+		return fmt.Errorf("%v ec hosts and stripe unit %v for scheme %v", len(request.ECHosts), request.StripeUnit,
+			ec.Scheme(request.DataShards, request.ParityShards))
+	}
+	var (
+		source  string
+		extents map[uint64]*storage.ExtentInfo
+	)
+	for _, host := range request.DataHosts {
+		if extents, err = getExtents(host, request.PartitionID); err == nil {
+			source = host
+			break
+		}
+		log.LogWarnf("action[migrate] get extents of partition(%v) from %v: %v", request.PartitionID, host, err)
+	}
+	if source == "" {
+		return fmt.Errorf("no replica available: %v", err)
+	}
+	now := time.Now().Unix()
+	for _, extent := range extents {
+		if now-extent.ModifyTime < request.ColdAge {
+			return fmt.Errorf("extent(%v) modified at %v is not cold", extent.FileID, time.Unix(extent.ModifyTime, 0))
+		}
+	}
+
+	meta := &ecPartitionMeta{
+		PartitionID:  request.PartitionID,
+		VolName:      request.VolName,
+		DataShards:   request.DataShards,
+		ParityShards: request.ParityShards,
+		StripeUnit:   request.StripeUnit,
+		Hosts:        request.ECHosts,
+		Extents:      make(map[uint64]uint64, len(extents)),
+	}
+	for _, extent := range extents {
+		if err = s.migrateExtent(request, encoder, source, extent); err != nil {
+			return
+		}
+		meta.Extents[extent.FileID] = extent.Size
+		response.Size += extent.Size
+	}
+	response.ExtentCount = len(meta.Extents)
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		if unsealErr := sealReplicas(request, proto.OpECUnsealDataPartition); unsealErr != nil {
+			log.LogWarnf("action[migrate] unseal partition(%v): %v", request.PartitionID, unsealErr)
+		}
+	}()
+	if err = sealReplicas(request, proto.OpECSealDataPartition); err != nil {
+		return
+	}
+	// the extents written by the clients holding them during the migration are not migrated, which
+	// are checked on all the replicas since a follower may not have applied a random write yet
+	for _, host := range request.DataHosts {
+		var latest map[uint64]*storage.ExtentInfo
+		if latest, err = getExtents(host, request.PartitionID); err != nil {
+			return
+		}
+		for extentID, extent := range latest {
+			if origin, ok := extents[extentID]; !ok || origin.Size != extent.Size || origin.ModifyTime != extent.ModifyTime {
+				return fmt.Errorf("extent(%v) on %v is modified during the migration", extentID, host)
+			}
+		}
+	}
+
+	for i, host := range request.ECHosts {
+		meta.ShardIndex = i
+		p := proto.NewPacketReqID()
+		p.Opcode = proto.OpECCommitPartition
+		p.PartitionID = request.PartitionID
+		p.ExtentType = proto.NormalExtentType
+		if err = p.MarshalData(meta); err != nil {
+			return
+		}
+		if host == s.localServerAddr {
+			s.handleCommitPartitionPacket(p)
+			if p.ResultCode != proto.OpOk {
+				err = fmt.Errorf("commit: %v", string(p.Data[:p.Size]))
+			}
+		} else {
+			_, err = sendToHost(host, p, proto.SyncSendTaskDeadlineTime)
+		}
+		if err != nil {
+			return
+		}
+	}
+	log.LogInfof("action[migrate] partition(%v) of vol(%v) migrated from %v to %v, extents(%v) size(%v)",
+		request.PartitionID, request.VolName, source, request.ECHosts, response.ExtentCount, response.Size)
+	return
+}
+
+// sealReplicas seals or unseals all the replicas of the data partition by the opcode, which waits
+// for the writes in progress on the replicas.
+func sealReplicas(request *proto.ECMigrateRequest, opcode uint8) (err error) {
+	for _, host := range request.DataHosts {
+		p := proto.NewPacketReqID()
+		p.Opcode = opcode
+		p.PartitionID = request.PartitionID
+		p.ExtentType = proto.NormalExtentType
+		if _, hostErr := sendToHost(host, p, proto.SyncSendTaskDeadlineTime); hostErr != nil {
+			err = hostErr
+		}
+	}
+	return
+}
+
+// getExtents returns the extents not deleted of the data partition on the replica.
+func getExtents(host string, partitionID uint64) (extents map[uint64]*storage.ExtentInfo, err error) {
+	tinyExtents := make([]uint64, 0, storage.TinyExtentCount)
+	for extentID := uint64(storage.TinyExtentStartID); extentID < storage.TinyExtentStartID+storage.TinyExtentCount; extentID++ {
+		tinyExtents = append(tinyExtents, extentID)
+	}
+	extents = make(map[uint64]*storage.ExtentInfo)
+	for _, extentType := range []uint8{proto.NormalExtentType, proto.TinyExtentType} {
+		p := proto.NewPacketReqID()
+		p.Opcode = proto.OpGetAllWatermarks
+		p.PartitionID = partitionID
+		p.ExtentType = extentType
+		if extentType == proto.TinyExtentType {
+			if err = p.MarshalData(tinyExtents); err != nil {
+				return
+			}
+		}
+		var reply *proto.Packet
+		if reply, err = sendToHost(host, p, proto.GetAllWatermarksDeadLineTime); err != nil {
+			return
+		}
+		infos := make([]*storage.ExtentInfo, 0)
+		if err = json.Unmarshal(reply.Data[:reply.Size], &infos); err != nil {
+			return
+		}
+		for _, info := range infos {
+			if !info.IsDeleted {
+				extents[info.FileID] = info
+			}
+		}
+	}
+	return
+}
+
+// migrateExtent reads the extent by the stripes, and writes the shards of each stripe.
+func (s *ECNode) migrateExtent(request *proto.ECMigrateRequest, encoder *ec.Encoder, source string,
+	extent *storage.ExtentInfo) (err error) {
+	unitSize := int64(request.StripeUnit)
+	stripeSize := int64(request.DataShards) * unitSize
+	for stripe := int64(0); stripe*stripeSize < int64(extent.Size); stripe++ {
+		data := make([]byte, stripeSize)
+		size := util.Min(int(stripeSize), int(int64(extent.Size)-stripe*stripeSize))
+		if err = readExtent(source, request.PartitionID, extent.FileID, stripe*stripeSize, data[:size]); err != nil {
+			return
+		}
+		shards := make([][]byte, encoder.TotalShards())
+		for i := 0; i < request.DataShards; i++ {
+			shards[i] = data[int64(i)*unitSize : int64(i+1)*unitSize]
+		}
+		if err = encoder.Encode(shards); err != nil {
+			return
+		}
+		if err = s.writeShards(request, extent.FileID, stripe, shards); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// readExtent reads the data of the extent from the replica of the data partition.
+func readExtent(host string, partitionID, extentID uint64, offset int64, data []byte) (err error) {
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpExtentRepairRead
+	p.PartitionID = partitionID
+	p.ExtentID = extentID
+	p.ExtentType = proto.NormalExtentType
+	if storage.IsTinyExtent(extentID) {
+		p.ExtentType = proto.TinyExtentType
+	}
+	p.ExtentOffset = offset
+	p.Size = uint32(len(data))
+	conn, err := gConnPool.GetConnect(host)
+	if err != nil {
+		return
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	for read := 0; read < len(data); {
+		reply := proto.NewPacket()
+		if err = reply.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+			return
+		}
+		if reply.ResultCode != proto.OpOk {
+			return fmt.Errorf("read extent(%v) from %v: %v %v", extentID, host, reply.GetResultMsg(), string(reply.Data[:reply.Size]))
+		}
+		if reply.Size == 0 || reply.CRC != crc32.ChecksumIEEE(reply.Data[:reply.Size]) {
+			return fmt.Errorf("read extent(%v) from %v: broken reply of size %v crc %v", extentID, host, reply.Size, reply.CRC)
+		}
+		read += copy(data[read:], reply.Data[:reply.Size])
+	}
+	return
+}
+
+// writeShards writes the shards of the stripe to the ec nodes in parallel.
+func (s *ECNode) writeShards(request *proto.ECMigrateRequest, extentID uint64, stripe int64, shards [][]byte) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(shards))
+	for i, host := range request.ECHosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			if host == s.localServerAddr {
+				errs[i] = writeShardUnit(s.partitionDir(request.PartitionID), extentID, stripe, shards[i])
+				return
+			}
+			p := proto.NewPacketReqID()
+			p.Opcode = proto.OpECWriteShard
+			p.PartitionID = request.PartitionID
+			p.ExtentID = extentID
+			p.ExtentType = proto.NormalExtentType
+			p.ExtentOffset = stripe
+			p.Data = shards[i]
+			p.Size = uint32(len(shards[i]))
+			p.CRC = crc32.ChecksumIEEE(shards[i])
+			_, errs[i] = sendToHost(host, p, proto.ReadDeadlineTime)
+		}(i, host)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("write shard(%v) of extent(%v) stripe(%v) to %v: %v", i, extentID, stripe, request.ECHosts[i], err)
+		}
+	}
+	return nil
+}
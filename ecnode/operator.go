@@ -0,0 +1,368 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+func (s *ECNode) operatePacket(p *proto.Packet, c net.Conn) (err error) {
+	switch p.Opcode {
+	case proto.OpStreamRead, proto.OpStreamFollowerRead, proto.OpExtentRepairRead:
+		return s.handleStreamReadPacket(p, c)
+	case proto.OpECReadShard:
+		s.handleReadShardPacket(p)
+	case proto.OpECWriteShard:
+		s.handleWriteShardPacket(p)
+	case proto.OpECCommitPartition:
+		s.handleCommitPartitionPacket(p)
+	case proto.OpMarkDelete:
+		s.handleMarkDeletePacket(p)
+	case proto.OpECNodeHeartbeat:
+		s.handleHeartbeatPacket(p)
+	case proto.OpECMigrateDataPartition:
+		s.handleMigratePacket(p)
+	case proto.OpProtoHandshake:
+		p.PacketHandshakeReply(0)
+	default:
+		p.PacketErrorWithBody(proto.OpErr, []byte(fmt.Sprintf("%v is not supported by the erasure coded partitions", p.GetOpMsg())))
+	}
+	return p.WriteToConn(c)
+}
+
+// handleStreamReadPacket serves the read of an extent of an erasure coded partition, the data is
+// gathered from the data shards of the stripes and replied in blocks as the data nodes do. Any
+// ec node of the partition serves the read, and the shards unavailable are reconstructed.
+func (s *ECNode) handleStreamReadPacket(p *proto.Packet, c net.Conn) (err error) {
+	ep := s.partition(p.PartitionID)
+	if ep == nil {
+		p.PacketErrorWithBody(proto.OpTryOtherAddr, []byte(fmt.Sprintf("partition(%v) not found", p.PartitionID)))
+		return p.WriteToConn(c)
+	}
+	extentSize, ok := ep.extentSize(p.ExtentID)
+	if !ok {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(fmt.Sprintf("extent(%v) not found", p.ExtentID)))
+		return p.WriteToConn(c)
+	}
+	if p.ExtentOffset < 0 || uint64(p.ExtentOffset)+uint64(p.Size) > extentSize {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(fmt.Sprintf("read [%v, %v) beyond the size %v of extent(%v)",
+			p.ExtentOffset, p.ExtentOffset+int64(p.Size), extentSize, p.ExtentID)))
+		return p.WriteToConn(c)
+	}
+	reader := &stripeReader{s: s, ep: ep, extentID: p.ExtentID, stripe: -1}
+	offset, remaining := p.ExtentOffset, int(p.Size)
+	for remaining > 0 {
+		size := util.Min(remaining, util.ReadBlockSize)
+		reply := proto.NewPacket()
+		reply.Opcode = p.Opcode
+		reply.ReqID = p.ReqID
+		reply.PartitionID = p.PartitionID
+		reply.ExtentID = p.ExtentID
+		reply.ExtentType = p.ExtentType
+		reply.ExtentOffset = offset
+		reply.Data = make([]byte, size)
+		if err = reader.read(reply.Data, offset); err != nil {
+			log.LogErrorf("action[handleStreamReadPacket] partition(%v) extent(%v) offset(%v) size(%v): %v",
+				p.PartitionID, p.ExtentID, offset, size, err)
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return p.WriteToConn(c)
+		}
+		reply.Size = uint32(size)
+		reply.CRC = crc32.ChecksumIEEE(reply.Data)
+		reply.ResultCode = proto.OpOk
+		if err = reply.WriteToConn(c); err != nil {
+			return
+		}
+		offset += int64(size)
+		remaining -= size
+	}
+	return
+}
+
+// stripeReader reads the data of an extent by the stripes, and keeps the shards of the last
+// stripe read.
+type stripeReader struct {
+	s        *ECNode
+	ep       *ECPartition
+	extentID uint64
+	stripe   int64
+	shards   [][]byte
+}
+
+func (r *stripeReader) read(data []byte, offset int64) (err error) {
+	unitSize := int64(r.ep.meta.StripeUnit)
+	stripeSize := r.ep.stripeSize()
+	for len(data) > 0 {
+		stripe, pos := offset/stripeSize, offset%stripeSize
+		var unit []byte
+		if unit, err = r.unit(stripe, int(pos/unitSize)); err != nil {
+			return
+		}
+		n := copy(data, unit[pos%unitSize:])
+		data = data[n:]
+		offset += int64(n)
+	}
+	return
+}
+
+func (r *stripeReader) unit(stripe int64, index int) (unit []byte, err error) {
+	if stripe != r.stripe {
+		r.stripe, r.shards = stripe, make([][]byte, r.ep.encoder.TotalShards())
+	}
+	if r.shards[index] != nil {
+		return r.shards[index], nil
+	}
+	if r.shards[index], err = r.s.readShard(r.ep, r.extentID, index, stripe); err == nil {
+		return r.shards[index], nil
+	}
+	log.LogWarnf("action[readShard] partition(%v) extent(%v) stripe(%v) shard(%v) to be reconstructed: %v",
+		r.ep.meta.PartitionID, r.extentID, stripe, index, err)
+	r.shards[index] = nil
+	if err = r.reconstruct(index); err != nil {
+		return
+	}
+	return r.shards[index], nil
+}
+
+// reconstruct reads the other shards of the stripe in parallel and rebuilds the missing ones.
+func (r *stripeReader) reconstruct(failed int) error {
+	var wg sync.WaitGroup
+	for i := range r.shards {
+		if r.shards[i] != nil || i == failed {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if data, err := r.s.readShard(r.ep, r.extentID, i, r.stripe); err == nil {
+				r.shards[i] = data
+			}
+		}(i)
+	}
+	wg.Wait()
+	return r.ep.encoder.Reconstruct(r.shards)
+}
+
+// readShard reads the unit of a stripe from the ec node of the shard.
+func (s *ECNode) readShard(ep *ECPartition, extentID uint64, index int, stripe int64) (data []byte, err error) {
+	host := ep.meta.Hosts[index]
+	if host == s.localServerAddr {
+		data, _, err = readShardUnit(ep.dir, extentID, stripe, ep.meta.StripeUnit)
+		return
+	}
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpECReadShard
+	p.PartitionID = ep.meta.PartitionID
+	p.ExtentID = extentID
+	p.ExtentType = proto.NormalExtentType
+	p.ExtentOffset = stripe
+	p.Size = ep.meta.StripeUnit
+	reply, err := sendToHost(host, p, proto.ReadDeadlineTime)
+	if err != nil {
+		return
+	}
+	if reply.Size != ep.meta.StripeUnit || reply.CRC != crc32.ChecksumIEEE(reply.Data[:reply.Size]) {
+		return nil, fmt.Errorf("shard from %v of size %v crc %v is broken", host, reply.Size, reply.CRC)
+	}
+	return reply.Data[:reply.Size], nil
+}
+
+// sendToHost sends the packet to the host and returns the reply, the reply of an error is
+// returned as the error.
+func sendToHost(host string, p *proto.Packet, timeoutSec int) (reply *proto.Packet, err error) {
+	conn, err := gConnPool.GetConnect(host)
+	if err != nil {
+		return
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	reply = proto.NewPacket()
+	if err = reply.ReadFromConn(conn, timeoutSec); err != nil {
+		return
+	}
+	if reply.ResultCode != proto.OpOk {
+		err = fmt.Errorf("%v to %v: %v %v", p.GetOpMsg(), host, reply.GetResultMsg(), string(reply.Data[:reply.Size]))
+	}
+	return
+}
+
+// Handle OpECReadShard packet, the extent offset of which is the index of the stripe.
+func (s *ECNode) handleReadShardPacket(p *proto.Packet) {
+	data, crc, err := readShardUnit(s.partitionDir(p.PartitionID), p.ExtentID, p.ExtentOffset, p.Size)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(data)
+	p.CRC = crc
+}
+
+// Handle OpECWriteShard packet, the extent offset of which is the index of the stripe.
+func (s *ECNode) handleWriteShardPacket(p *proto.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	if s.partition(p.PartitionID) != nil {
+		err = fmt.Errorf("partition(%v) is committed", p.PartitionID)
+		return
+	}
+	if crc := crc32.ChecksumIEEE(p.Data[:p.Size]); crc != p.CRC {
+		err = fmt.Errorf("shard crc(%v) mismatch with %v", crc, p.CRC)
+		return
+	}
+	err = writeShardUnit(s.partitionDir(p.PartitionID), p.ExtentID, p.ExtentOffset, p.Data[:p.Size])
+}
+
+// Handle OpECCommitPartition packet, which makes the shards written the partition to serve.
+func (s *ECNode) handleCommitPartitionPacket(p *proto.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	meta := new(ecPartitionMeta)
+	if err = json.Unmarshal(p.Data[:p.Size], meta); err != nil {
+		return
+	}
+	s.partitionMux.Lock()
+	defer s.partitionMux.Unlock()
+	if _, ok := s.partitions[meta.PartitionID]; ok {
+		return
+	}
+	dir := s.partitionDir(meta.PartitionID)
+	ep, err := newECPartition(dir, meta)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	if err = ep.removeStrayFiles(); err != nil {
+		return
+	}
+	if err = ep.persistMeta(); err != nil {
+		return
+	}
+	s.partitions[meta.PartitionID] = ep
+	log.LogInfof("action[commitPartition] partition(%v) of vol(%v) shard(%v) committed, extents(%v)",
+		meta.PartitionID, meta.VolName, meta.ShardIndex, len(meta.Extents))
+}
+
+// Handle OpMarkDelete packet. The shards of a normal extent are deleted on all the ec nodes, the
+// space of the tiny extents is not reclaimed.
+func (s *ECNode) handleMarkDeletePacket(p *proto.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	if storage.IsTinyExtent(p.ExtentID) {
+		return
+	}
+	if ep := s.partition(p.PartitionID); ep != nil {
+		if err = ep.deleteExtent(p.ExtentID); err != nil {
+			return
+		}
+	}
+	if p.RemainingFollowers == 0 {
+		return
+	}
+	for _, host := range strings.Split(string(p.Arg[:p.ArgLen]), proto.AddrSplit) {
+		if host == "" || host == s.localServerAddr {
+			continue
+		}
+		follower := proto.NewPacketReqID()
+		follower.Opcode = proto.OpMarkDelete
+		follower.PartitionID = p.PartitionID
+		follower.ExtentID = p.ExtentID
+		follower.ExtentType = proto.NormalExtentType
+		if _, err = sendToHost(host, follower, proto.ReadDeadlineTime); err != nil {
+			return
+		}
+	}
+	log.LogInfof("action[markDelete] partition(%v) extent(%v) deleted", p.PartitionID, p.ExtentID)
+}
+
+// Handle OpECNodeHeartbeat packet, the response is sent to the master asynchronously.
+func (s *ECNode) handleHeartbeatPacket(p *proto.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data[:p.Size], task); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkReply()
+	go func() {
+		response := &proto.ECNodeHeartbeatResponse{}
+		s.buildHeartbeatResponse(response)
+		task.Response = response
+		if err := MasterClient.NodeAPI().ResponseECNodeTask(task); err != nil {
+			log.LogErrorf("action[heartbeat] response to master failed: %v", err)
+		}
+	}()
+}
+
+func (s *ECNode) buildHeartbeatResponse(response *proto.ECNodeHeartbeatResponse) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(s.dataDir, &fs); err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		return
+	}
+	response.Total = fs.Blocks * uint64(fs.Bsize)
+	response.Available = fs.Bavail * uint64(fs.Bsize)
+	response.Used = response.Total - fs.Bfree*uint64(fs.Bsize)
+	s.partitionMux.RLock()
+	for _, ep := range s.partitions {
+		ep.RLock()
+		report := &proto.ECPartitionReport{
+			VolName:     ep.meta.VolName,
+			PartitionID: ep.meta.PartitionID,
+			ShardIndex:  ep.meta.ShardIndex,
+			ExtentCount: len(ep.meta.Extents),
+		}
+		ep.RUnlock()
+		report.Used = ep.used()
+		response.PartitionReports = append(response.PartitionReports, report)
+	}
+	s.partitionMux.RUnlock()
+	response.Status = proto.TaskSucceeds
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ecnode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chubaofs/chubaofs/util/ec"
+)
+
+// The shards of an extent are striped by the stripe unit. The stripe j of an extent covers the
+// bytes [j*k*unit, (j+1)*k*unit) of it, the unit i of the stripe is stored at the offset j*unit
+// of the shard file of the extent on the ec node of the shard i, and its crc at the offset j*4
+// of the crc file. The last stripe of an extent is padded with zeros.
+const (
+	PartitionPrefix  = "ecpartition_"
+	MetaFileName     = "META"
+	TempMetaFileName = ".meta"
+	CrcFileSuffix    = ".crc"
+	crcSize          = 4
+)
+
+type ecPartitionMeta struct {
+	PartitionID  uint64
+	VolName      string
+	DataShards   int
+	ParityShards int
+	StripeUnit   uint32
+	Hosts        []string
+	ShardIndex   int
+	Extents      map[uint64]uint64 // the sizes of the extents by id
+}
+
+// ECPartition stores a shard of the extents of an erasure coded partition.
+type ECPartition struct {
+	dir     string
+	meta    *ecPartitionMeta
+	encoder *ec.Encoder
+	sync.RWMutex
+}
+
+func newECPartition(dir string, meta *ecPartitionMeta) (ep *ECPartition, err error) {
+	encoder, err := ec.NewEncoder(meta.DataShards, meta.ParityShards)
+	if err != nil {
+		return
+	}
+	if len(meta.Hosts) != encoder.TotalShards() || meta.StripeUnit == 0 {
+		return nil, fmt.Errorf("partition(%v) has %v hosts and stripe unit %v for scheme %v",
+			meta.PartitionID, len(meta.Hosts), meta.StripeUnit, ec.Scheme(meta.DataShards, meta.ParityShards))
+	}
+	if meta.Extents == nil {
+		meta.Extents = make(map[uint64]uint64)
+	}
+	ep = &ECPartition{dir: dir, meta: meta, encoder: encoder}
+	return
+}
+
+func loadECPartition(dir string) (ep *ECPartition, err error) {
+	data, err := ioutil.ReadFile(path.Join(dir, MetaFileName))
+	if err != nil {
+		return
+	}
+	meta := new(ecPartitionMeta)
+	if err = json.Unmarshal(data, meta); err != nil {
+		return
+	}
+	return newECPartition(dir, meta)
+}
+
+// persistMeta writes the meta file, the lock of the partition must be held.
+func (ep *ECPartition) persistMeta() (err error) {
+	data, err := json.Marshal(ep.meta)
+	if err != nil {
+		return
+	}
+	tempFile := path.Join(ep.dir, TempMetaFileName)
+	if err = ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		return
+	}
+	return os.Rename(tempFile, path.Join(ep.dir, MetaFileName))
+}
+
+// removeStrayFiles removes the shard files of the extents not in the partition, which are left
+// by the migrations failed before.
+func (ep *ECPartition) removeStrayFiles() (err error) {
+	fileInfos, err := ioutil.ReadDir(ep.dir)
+	if err != nil {
+		return
+	}
+	for _, fileInfo := range fileInfos {
+		extentID, err := strconv.ParseUint(strings.TrimSuffix(fileInfo.Name(), CrcFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := ep.meta.Extents[extentID]; !ok {
+			os.Remove(path.Join(ep.dir, fileInfo.Name()))
+		}
+	}
+	return nil
+}
+
+func (ep *ECPartition) copyMeta() *ecPartitionMeta {
+	ep.RLock()
+	defer ep.RUnlock()
+	meta := *ep.meta
+	meta.Extents = make(map[uint64]uint64, len(ep.meta.Extents))
+	for extentID, size := range ep.meta.Extents {
+		meta.Extents[extentID] = size
+	}
+	return &meta
+}
+
+func (ep *ECPartition) extentSize(extentID uint64) (size uint64, ok bool) {
+	ep.RLock()
+	defer ep.RUnlock()
+	size, ok = ep.meta.Extents[extentID]
+	return
+}
+
+// stripeSize returns the size of the data of a stripe.
+func (ep *ECPartition) stripeSize() int64 {
+	return int64(ep.meta.DataShards) * int64(ep.meta.StripeUnit)
+}
+
+// used returns the size of the shards stored.
+func (ep *ECPartition) used() (used uint64) {
+	ep.RLock()
+	defer ep.RUnlock()
+	stripeSize := uint64(ep.stripeSize())
+	for _, size := range ep.meta.Extents {
+		used += (size + stripeSize - 1) / stripeSize * uint64(ep.meta.StripeUnit)
+	}
+	return
+}
+
+func (ep *ECPartition) deleteExtent(extentID uint64) (err error) {
+	ep.Lock()
+	defer ep.Unlock()
+	if _, ok := ep.meta.Extents[extentID]; !ok {
+		return
+	}
+	delete(ep.meta.Extents, extentID)
+	if err = ep.persistMeta(); err != nil {
+		return
+	}
+	shardFile := path.Join(ep.dir, strconv.FormatUint(extentID, 10))
+	os.Remove(shardFile)
+	os.Remove(shardFile + CrcFileSuffix)
+	return
+}
+
+// writeShardUnit writes the unit of a stripe to the shard file of the extent in the dir.
+func writeShardUnit(dir string, extentID uint64, stripe int64, data []byte) (err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	shardFile := path.Join(dir, strconv.FormatUint(extentID, 10))
+	f, err := os.OpenFile(shardFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err = f.WriteAt(data, stripe*int64(len(data))); err != nil {
+		return
+	}
+	crcFile, err := os.OpenFile(shardFile+CrcFileSuffix, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return
+	}
+	defer crcFile.Close()
+	crc := make([]byte, crcSize)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(data))
+	_, err = crcFile.WriteAt(crc, stripe*crcSize)
+	return
+}
+
+// readShardUnit reads the unit of a stripe from the shard file of the extent in the dir, and
+// verifies it by the crc written with it.
+func readShardUnit(dir string, extentID uint64, stripe int64, unitSize uint32) (data []byte, crc uint32, err error) {
+	shardFile := path.Join(dir, strconv.FormatUint(extentID, 10))
+	f, err := os.Open(shardFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	data = make([]byte, unitSize)
+	if _, err = f.ReadAt(data, stripe*int64(unitSize)); err != nil {
+		return
+	}
+	crcFile, err := os.Open(shardFile + CrcFileSuffix)
+	if err != nil {
+		return
+	}
+	defer crcFile.Close()
+	crcData := make([]byte, crcSize)
+	if _, err = crcFile.ReadAt(crcData, stripe*crcSize); err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(data)
+	if expect := binary.BigEndian.Uint32(crcData); crc != expect {
+		err = fmt.Errorf("shard of extent(%v) stripe(%v) crc(%v) mismatch with %v", extentID, stripe, crc, expect)
+	}
+	return
+}
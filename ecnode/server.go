@@ -0,0 +1,336 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ecnode implements the ec node, which stores the shards of the erasure coded partitions
+// migrated from the cold data partitions, and serves the reads of the extents in them.
+package ecnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/cmd/common"
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/health"
+	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
+)
+
+var (
+	LocalIP      string
+	gConnPool    = util.NewConnectPool()
+	MasterClient = masterSDK.NewMasterClient(nil, false)
+)
+
+const (
+	ModuleName = "ecNode"
+
+	NetworkProtocol = "tcp"
+)
+
+const (
+	ConfigKeyLocalIP    = "localIP"    // string
+	ConfigKeyMasterAddr = "masterAddr" // array
+	ConfigKeyDataDir    = "dataDir"    // string
+)
+
+// ECNode defines the structure of an ec node.
+type ECNode struct {
+	port            string
+	clusterID       string
+	localServerAddr string
+	nodeID          uint64
+	dataDir         string
+
+	partitions   map[uint64]*ECPartition
+	partitionMux sync.RWMutex
+	migrating    map[uint64]bool
+	migrateMux   sync.Mutex
+
+	tcpListener net.Listener
+	stopC       chan bool
+
+	control common.Control
+}
+
+func NewServer() *ECNode {
+	return &ECNode{}
+}
+
+func (s *ECNode) Start(cfg *config.Config) (err error) {
+	return s.control.Start(s, cfg, doStart)
+}
+
+// Shutdown shuts down the current ec node.
+func (s *ECNode) Shutdown() {
+	s.control.Shutdown(s, doShutdown)
+}
+
+// Sync keeps ec node in sync.
+func (s *ECNode) Sync() {
+	s.control.Sync()
+}
+
+// Workflow of starting up an ec node.
+func doStart(server common.Server, cfg *config.Config) (err error) {
+	s, ok := server.(*ECNode)
+	if !ok {
+		return errors.New("Invalid Node Type!")
+	}
+
+	s.stopC = make(chan bool, 0)
+	s.partitions = make(map[uint64]*ECPartition)
+	s.migrating = make(map[uint64]bool)
+
+	if err = s.parseConfig(cfg); err != nil {
+		return
+	}
+	if err = s.loadPartitions(); err != nil {
+		return
+	}
+
+	exporter.Init(ModuleName, cfg)
+	s.register(cfg)
+
+	if err = s.startTCPService(); err != nil {
+		return
+	}
+	go s.registerHandler()
+	return
+}
+
+func doShutdown(server common.Server) {
+	s, ok := server.(*ECNode)
+	if !ok {
+		return
+	}
+	close(s.stopC)
+	s.stopTCPService()
+}
+
+func (s *ECNode) parseConfig(cfg *config.Config) (err error) {
+	LocalIP = cfg.GetString(ConfigKeyLocalIP)
+	port := cfg.GetString(proto.ListenPort)
+	regexpPort, err := regexp.Compile("^(\\d)+$")
+	if err != nil {
+		return fmt.Errorf("Err:no port")
+	}
+	if !regexpPort.MatchString(port) {
+		return fmt.Errorf("Err:port must string")
+	}
+	s.port = port
+	if len(cfg.GetArray(ConfigKeyMasterAddr)) == 0 {
+		return fmt.Errorf("Err:masterAddr unavalid")
+	}
+	for _, ip := range cfg.GetArray(ConfigKeyMasterAddr) {
+		MasterClient.AddNode(ip.(string))
+	}
+	s.dataDir = cfg.GetString(ConfigKeyDataDir)
+	if s.dataDir == "" {
+		return fmt.Errorf("Err:dataDir unavalid")
+	}
+	if err = os.MkdirAll(s.dataDir, 0755); err != nil {
+		return
+	}
+	log.LogDebugf("action[parseConfig] load masterAddrs(%v).", MasterClient.Nodes())
+	log.LogDebugf("action[parseConfig] load port(%v).", s.port)
+	log.LogDebugf("action[parseConfig] load dataDir(%v).", s.dataDir)
+	return
+}
+
+// loadPartitions loads the partitions committed in the data dir, the partitions without the meta
+// file are left by the migrations not finished and are removed once they are committed again.
+func (s *ECNode) loadPartitions() (err error) {
+	fileInfos, err := ioutil.ReadDir(s.dataDir)
+	if err != nil {
+		return
+	}
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() || !strings.HasPrefix(fileInfo.Name(), PartitionPrefix) {
+			continue
+		}
+		partitionID, err := strconv.ParseUint(strings.TrimPrefix(fileInfo.Name(), PartitionPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ep, err := loadECPartition(path.Join(s.dataDir, fileInfo.Name()))
+		if err != nil {
+			log.LogWarnf("action[loadPartitions] partition(%v) not loaded: %v", partitionID, err)
+			continue
+		}
+		s.partitions[partitionID] = ep
+		log.LogInfof("action[loadPartitions] partition(%v) loaded, extents(%v)", partitionID, len(ep.meta.Extents))
+	}
+	return nil
+}
+
+func (s *ECNode) partition(partitionID uint64) *ECPartition {
+	s.partitionMux.RLock()
+	defer s.partitionMux.RUnlock()
+	return s.partitions[partitionID]
+}
+
+func (s *ECNode) partitionDir(partitionID uint64) string {
+	return path.Join(s.dataDir, fmt.Sprintf("%v%v", PartitionPrefix, partitionID))
+}
+
+// registers the ec node on the master, the startup is blocked until the registration succeeds.
+func (s *ECNode) register(cfg *config.Config) {
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-timer.C:
+			ci, err := MasterClient.AdminAPI().GetClusterInfo()
+			if err != nil {
+				log.LogErrorf("action[registerToMaster] cannot get ip from master(%v) err(%v).",
+					MasterClient.Leader(), err)
+				timer.Reset(2 * time.Second)
+				continue
+			}
+			s.clusterID = ci.Cluster
+			if LocalIP == "" {
+				LocalIP = string(ci.Ip)
+			}
+			s.localServerAddr = fmt.Sprintf("%s:%v", LocalIP, s.port)
+			if !util.IsIPV4(LocalIP) {
+				log.LogErrorf("action[registerToMaster] got an invalid local ip(%v) from master(%v).",
+					LocalIP, MasterClient.Leader())
+				timer.Reset(2 * time.Second)
+				continue
+			}
+			nodeID, err := MasterClient.NodeAPI().AddECNode(s.localServerAddr)
+			if err != nil {
+				log.LogErrorf("action[registerToMaster] cannot register this node to master[%v] err(%v).",
+					MasterClient.Leader(), err)
+				timer.Reset(2 * time.Second)
+				continue
+			}
+			exporter.RegistConsul(s.clusterID, ModuleName, cfg)
+			s.nodeID = nodeID
+			log.LogDebugf("register: register ECNode: nodeID(%v)", s.nodeID)
+			return
+		case <-s.stopC:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (s *ECNode) registerHandler() {
+	http.HandleFunc("/partitions", s.getPartitionsAPI)
+	health.AddCheck("state", s.checkState)
+	health.RegisterHTTP()
+}
+
+func (s *ECNode) checkState() error {
+	if !s.control.IsRunning() {
+		return errors.New("not running")
+	}
+	return nil
+}
+
+func (s *ECNode) getPartitionsAPI(w http.ResponseWriter, r *http.Request) {
+	s.partitionMux.RLock()
+	partitions := make([]*ecPartitionMeta, 0, len(s.partitions))
+	for _, ep := range s.partitions {
+		partitions = append(partitions, ep.copyMeta())
+	}
+	s.partitionMux.RUnlock()
+	data, _ := json.Marshal(&proto.HTTPReply{
+		Code: proto.ErrCodeSuccess,
+		Msg:  "success",
+		Data: map[string]interface{}{
+			"partitionCount": len(partitions),
+			"partitions":     partitions,
+		},
+	})
+	w.Write(data)
+}
+
+func (s *ECNode) startTCPService() (err error) {
+	addr := fmt.Sprintf(":%v", s.port)
+	l, err := net.Listen(NetworkProtocol, addr)
+	if err != nil {
+		log.LogError("failed to listen, err:", err)
+		return
+	}
+	s.tcpListener = l
+	go func(ln net.Listener) {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				log.LogErrorf("action[startTCPService] failed to accept, err:%s", err.Error())
+				break
+			}
+			go s.serveConn(conn)
+		}
+	}(l)
+	return
+}
+
+func (s *ECNode) stopTCPService() {
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+		log.LogDebugf("action[stopTCPService] stop tcp service.")
+	}
+}
+
+func (s *ECNode) serveConn(conn net.Conn) {
+	c, _ := conn.(*net.TCPConn)
+	c.SetKeepAlive(true)
+	c.SetNoDelay(true)
+	conn, err := mtls.Server(c)
+	if err != nil {
+		if err != io.EOF {
+			log.LogWarnf("action[serveConn] tls handshake with %v failed: %v", c.RemoteAddr(), err)
+		}
+		c.Close()
+		return
+	}
+	defer conn.Close()
+	for {
+		select {
+		case <-s.stopC:
+			return
+		default:
+		}
+		p := proto.NewPacket()
+		if err = p.ReadFromConn(conn, proto.NoReadDeadlineTime); err != nil {
+			if err != io.EOF {
+				log.LogDebugf("action[serveConn] read from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if err = s.operatePacket(p, conn); err != nil {
+			log.LogWarnf("action[serveConn] %v from %v: %v", p.GetOpMsg(), conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
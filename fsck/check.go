@@ -0,0 +1,231 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+)
+
+// Inode is the part of the metanode inode used by the checker.
+type Inode struct {
+	Inode   uint64
+	Type    uint32
+	Size    uint64
+	NLink   uint32
+	Extents []proto.ExtentKey
+}
+
+// Dentry is the dentry reported by the metanode.
+type Dentry struct {
+	ParentId uint64
+	Name     string
+	Inode    uint64
+	Type     uint32
+}
+
+// VolumeData is the metadata and the extent inventories collected from a volume.
+type VolumeData struct {
+	Inodes   map[uint64]*Inode
+	Dentries []*Dentry
+	// Extents maps a data partition to the extents on each of its replicas.
+	Extents map[uint64]map[string]map[uint64]*storage.ExtentInfo
+	// ErasureCoded are the erasure coded partitions, whose ec nodes keep no extent inventory.
+	ErasureCoded map[uint64]bool
+}
+
+// NewVolumeData returns a new VolumeData.
+func NewVolumeData() *VolumeData {
+	return &VolumeData{
+		Inodes:       make(map[uint64]*Inode),
+		Dentries:     make([]*Dentry, 0),
+		Extents:      make(map[uint64]map[string]map[uint64]*storage.ExtentInfo),
+		ErasureCoded: make(map[uint64]bool),
+	}
+}
+
+// AddReplica adds the extent inventory of a data partition replica.
+func (v *VolumeData) AddReplica(partitionID uint64, host string, extents []*storage.ExtentInfo) {
+	replicas, ok := v.Extents[partitionID]
+	if !ok {
+		replicas = make(map[string]map[uint64]*storage.ExtentInfo)
+		v.Extents[partitionID] = replicas
+	}
+	m := make(map[uint64]*storage.ExtentInfo, len(extents))
+	for _, ei := range extents {
+		m[ei.FileID] = ei
+	}
+	replicas[host] = m
+}
+
+// ExtentIssue is an inconsistency between an extent key and an extent on a replica.
+type ExtentIssue struct {
+	Inode       uint64
+	PartitionID uint64
+	ExtentID    uint64
+	Host        string
+	Expected    uint64
+	Actual      uint64
+}
+
+// Report is the result of the check.
+type Report struct {
+	MissingExtents  []*ExtentIssue
+	SizeMismatches  []*ExtentIssue
+	OrphanExtents   []*ExtentIssue
+	DanglingDentry  []*Dentry
+	UnknownReplicas []uint64
+	ErasureCoded    []uint64
+}
+
+// Clean returns true if no inconsistency is found.
+func (r *Report) Clean() bool {
+	return len(r.MissingExtents) == 0 && len(r.SizeMismatches) == 0 &&
+		len(r.OrphanExtents) == 0 && len(r.DanglingDentry) == 0
+}
+
+type extentRef struct {
+	partitionID uint64
+	extentID    uint64
+}
+
+// Check cross-references the extent keys of the inodes with the extent inventories of the
+// data partitions. Extents modified after the given time are not reported as orphans,
+// because the extent keys of the in-flight writes may not be committed to the metanode yet.
+func Check(v *VolumeData, safeTime time.Time) *Report {
+	report := new(Report)
+	referenced := make(map[extentRef]bool)
+
+	inodes := make([]uint64, 0, len(v.Inodes))
+	for ino := range v.Inodes {
+		inodes = append(inodes, ino)
+	}
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+
+	unknown := make(map[uint64]bool)
+	for _, ino := range inodes {
+		inode := v.Inodes[ino]
+		for _, ek := range inode.Extents {
+			referenced[extentRef{ek.PartitionId, ek.ExtentId}] = true
+			if v.ErasureCoded[ek.PartitionId] {
+				if !unknown[ek.PartitionId] {
+					unknown[ek.PartitionId] = true
+					report.ErasureCoded = append(report.ErasureCoded, ek.PartitionId)
+				}
+				continue
+			}
+			replicas, ok := v.Extents[ek.PartitionId]
+			if !ok {
+				if !unknown[ek.PartitionId] {
+					unknown[ek.PartitionId] = true
+					report.UnknownReplicas = append(report.UnknownReplicas, ek.PartitionId)
+				}
+				continue
+			}
+			expected := ek.ExtentOffset + uint64(ek.Size)
+			for _, host := range sortedHosts(replicas) {
+				ei, ok := replicas[host][ek.ExtentId]
+				if !ok || ei.IsDeleted {
+					report.MissingExtents = append(report.MissingExtents, &ExtentIssue{
+						Inode: ino, PartitionID: ek.PartitionId, ExtentID: ek.ExtentId, Host: host, Expected: expected,
+					})
+					continue
+				}
+				if ei.Size < expected {
+					report.SizeMismatches = append(report.SizeMismatches, &ExtentIssue{
+						Inode: ino, PartitionID: ek.PartitionId, ExtentID: ek.ExtentId, Host: host, Expected: expected, Actual: ei.Size,
+					})
+				}
+			}
+		}
+	}
+
+	partitions := make([]uint64, 0, len(v.Extents))
+	for pid := range v.Extents {
+		partitions = append(partitions, pid)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	for _, pid := range partitions {
+		replicas := v.Extents[pid]
+		for _, host := range sortedHosts(replicas) {
+			for _, ei := range sortedExtents(replicas[host]) {
+				// tiny extents are shared by many small files and never deleted as a whole
+				if storage.IsTinyExtent(ei.FileID) || ei.IsDeleted || referenced[extentRef{pid, ei.FileID}] {
+					continue
+				}
+				if time.Unix(ei.ModifyTime, 0).After(safeTime) {
+					continue
+				}
+				report.OrphanExtents = append(report.OrphanExtents, &ExtentIssue{
+					PartitionID: pid, ExtentID: ei.FileID, Host: host, Actual: ei.Size,
+				})
+			}
+		}
+	}
+
+	for _, d := range v.Dentries {
+		if _, ok := v.Inodes[d.Inode]; !ok {
+			report.DanglingDentry = append(report.DanglingDentry, d)
+		}
+	}
+	return report
+}
+
+// Print writes the report in a human readable format.
+func (r *Report) Print(w io.Writer) {
+	for _, i := range r.MissingExtents {
+		fmt.Fprintf(w, "missing extent: ino(%v) dp(%v) extent(%v) host(%v) size(%v)\n", i.Inode, i.PartitionID, i.ExtentID, i.Host, i.Expected)
+	}
+	for _, i := range r.SizeMismatches {
+		fmt.Fprintf(w, "size mismatch: ino(%v) dp(%v) extent(%v) host(%v) expected(%v) actual(%v)\n", i.Inode, i.PartitionID, i.ExtentID, i.Host, i.Expected, i.Actual)
+	}
+	for _, i := range r.OrphanExtents {
+		fmt.Fprintf(w, "orphan extent: dp(%v) extent(%v) host(%v) size(%v)\n", i.PartitionID, i.ExtentID, i.Host, i.Actual)
+	}
+	for _, d := range r.DanglingDentry {
+		fmt.Fprintf(w, "dangling dentry: parent(%v) name(%v) ino(%v)\n", d.ParentId, d.Name, d.Inode)
+	}
+	for _, pid := range r.UnknownReplicas {
+		fmt.Fprintf(w, "unchecked dp(%v): no replica inventory\n", pid)
+	}
+	for _, pid := range r.ErasureCoded {
+		fmt.Fprintf(w, "unchecked dp(%v): erasure coded\n", pid)
+	}
+	fmt.Fprintf(w, "missing extents: %v, size mismatches: %v, orphan extents: %v, dangling dentries: %v\n",
+		len(r.MissingExtents), len(r.SizeMismatches), len(r.OrphanExtents), len(r.DanglingDentry))
+}
+
+func sortedHosts(replicas map[string]map[uint64]*storage.ExtentInfo) []string {
+	hosts := make([]string, 0, len(replicas))
+	for host := range replicas {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func sortedExtents(extents map[uint64]*storage.ExtentInfo) []*storage.ExtentInfo {
+	infos := make([]*storage.ExtentInfo, 0, len(extents))
+	for _, ei := range extents {
+		infos = append(infos, ei)
+	}
+	sort.Sort(storage.ExtentInfoArr(infos))
+	return infos
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+)
+
+func TestCheck(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	data := NewVolumeData()
+	data.Inodes[1] = &Inode{Inode: 1, Type: proto.Mode(os.ModeDir | 0755)}
+	data.Inodes[2] = &Inode{Inode: 2, Size: 300, Extents: []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 100},
+		{FileOffset: 100, PartitionId: 1, ExtentId: 1026, Size: 200},
+	}}
+	data.Dentries = []*Dentry{
+		{ParentId: 1, Name: "a", Inode: 2},
+		{ParentId: 1, Name: "b", Inode: 3},
+	}
+	data.AddReplica(1, "host1", []*storage.ExtentInfo{
+		{FileID: 1, Size: 4096, ModifyTime: old},
+		{FileID: 1025, Size: 100, ModifyTime: old},
+		{FileID: 1026, Size: 200, ModifyTime: old},
+		{FileID: 1027, Size: 10, ModifyTime: old},
+		{FileID: 1028, Size: 10, ModifyTime: time.Now().Unix()},
+	})
+	data.AddReplica(1, "host2", []*storage.ExtentInfo{
+		{FileID: 1025, Size: 100, ModifyTime: old},
+		{FileID: 1026, Size: 150, ModifyTime: old},
+	})
+
+	report := Check(data, time.Now().Add(-time.Hour))
+	if report.Clean() {
+		t.Fatalf("expect inconsistencies")
+	}
+	if len(report.MissingExtents) != 0 {
+		t.Fatalf("unexpected missing extents %v", report.MissingExtents)
+	}
+	if len(report.SizeMismatches) != 1 || report.SizeMismatches[0].Host != "host2" || report.SizeMismatches[0].Actual != 150 {
+		t.Fatalf("unexpected size mismatches %v", report.SizeMismatches)
+	}
+	if len(report.OrphanExtents) != 1 || report.OrphanExtents[0].ExtentID != 1027 {
+		t.Fatalf("unexpected orphan extents %v", report.OrphanExtents)
+	}
+	if len(report.DanglingDentry) != 1 || report.DanglingDentry[0].Name != "b" {
+		t.Fatalf("unexpected dangling dentries %v", report.DanglingDentry)
+	}
+
+	delete(data.Extents[1], "host2")
+	data.Extents[1]["host1"][1026].IsDeleted = true
+	report = Check(data, time.Now().Add(-time.Hour))
+	if len(report.MissingExtents) != 1 || report.MissingExtents[0].ExtentID != 1026 {
+		t.Fatalf("unexpected missing extents %v", report.MissingExtents)
+	}
+
+	data.Inodes[4] = &Inode{Inode: 4, Size: 100, Extents: []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 2, ExtentId: 1025, Size: 100},
+	}}
+	data.ErasureCoded[2] = true
+	report = Check(data, time.Now().Add(-time.Hour))
+	if len(report.MissingExtents) != 1 || len(report.UnknownReplicas) != 0 ||
+		len(report.ErasureCoded) != 1 || report.ErasureCoded[0] != 2 {
+		t.Fatalf("unexpected report of erasure coded partition: missing(%v) unknown(%v) ec(%v)",
+			report.MissingExtents, report.UnknownReplicas, report.ErasureCoded)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-fsck cross-references the extent keys kept by the metanodes with the extent
+// inventories of the datanodes of a volume, and reports the missing extents, orphaned
+// extents, size mismatches and dangling dentries.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+var (
+	masterAddr = flag.String("master", "", "master addresses separated by comma")
+	volName    = flag.String("vol", "", "volume name")
+	metaProf   = flag.String("metaProf", "9092", "prof port of the metanodes")
+	dataProf   = flag.String("dataProf", "17320", "prof port of the datanodes")
+	safeTime   = flag.Duration("safeTime", time.Hour, "extents modified within this duration are not reported as orphans")
+	repair     = flag.Bool("repair", false, "remove the dangling dentries still dangling when checked again")
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+func main() {
+	flag.Parse()
+	if *masterAddr == "" || *volName == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	mc := masterSDK.NewMasterClient(strings.Split(*masterAddr, ","), false)
+	data := NewVolumeData()
+	if err := collectMetadata(mc, data); err != nil {
+		fmt.Fprintf(os.Stderr, "collect metadata failed: %v\n", err)
+		os.Exit(1)
+	}
+	if err := collectExtents(mc, data); err != nil {
+		fmt.Fprintf(os.Stderr, "collect extents failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := Check(data, time.Now().Add(-*safeTime))
+	report.Print(os.Stdout)
+
+	if *repair && len(report.DanglingDentry) > 0 {
+		if err := repairDentries(report.DanglingDentry, time.Now().Add(-*safeTime)); err != nil {
+			fmt.Fprintf(os.Stderr, "repair failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if !report.Clean() {
+		os.Exit(2)
+	}
+}
+
+func collectMetadata(mc *masterSDK.MasterClient, data *VolumeData) (err error) {
+	views, err := mc.ClientAPI().GetMetaPartitions(*volName)
+	if err != nil {
+		return
+	}
+	for _, mp := range views {
+		if mp.LeaderAddr == "" {
+			return fmt.Errorf("meta partition(%v) has no leader", mp.PartitionID)
+		}
+		addr := util.ProfAddr(mp.LeaderAddr, *metaProf)
+		if err = getInodes(addr, mp.PartitionID, data); err != nil {
+			return fmt.Errorf("get inodes of mp(%v) from %v: %v", mp.PartitionID, addr, err)
+		}
+		if err = getDentries(addr, mp.PartitionID, data); err != nil {
+			return fmt.Errorf("get dentries of mp(%v) from %v: %v", mp.PartitionID, addr, err)
+		}
+	}
+	return
+}
+
+// getInodes reads the inodes of a meta partition, which are returned one JSON object per line.
+func getInodes(addr string, pid uint64, data *VolumeData) (err error) {
+	resp, err := httpClient.Get(fmt.Sprintf("http://%v/getAllInodes?pid=%v", addr, pid))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	for dec.More() {
+		inode := new(Inode)
+		if err = dec.Decode(inode); err != nil {
+			return
+		}
+		data.Inodes[inode.Inode] = inode
+	}
+	return
+}
+
+func getDentries(addr string, pid uint64, data *VolumeData) (err error) {
+	body := &struct {
+		Code int       `json:"code"`
+		Msg  string    `json:"msg"`
+		Data []*Dentry `json:"data"`
+	}{}
+	if err = getJSON(fmt.Sprintf("http://%v/getAllDentry?pid=%v", addr, pid), body); err != nil {
+		return
+	}
+	if body.Code != http.StatusOK {
+		return fmt.Errorf("code(%v) msg(%v)", body.Code, body.Msg)
+	}
+	data.Dentries = append(data.Dentries, body.Data...)
+	return
+}
+
+func collectExtents(mc *masterSDK.MasterClient, data *VolumeData) (err error) {
+	view, err := mc.ClientAPI().GetDataPartitions(*volName)
+	if err != nil {
+		return
+	}
+	for _, dp := range view.DataPartitions {
+		if dp.ErasureCoded {
+			// the ec nodes serve no extent inventory, the extent keys on them are left unchecked
+			data.ErasureCoded[dp.PartitionID] = true
+			continue
+		}
+		for _, host := range dp.Hosts {
+			body := &struct {
+				Code int    `json:"code"`
+				Msg  string `json:"msg"`
+				Data struct {
+					Extents []*storage.ExtentInfo `json:"extents"`
+				} `json:"data"`
+			}{}
+			addr := util.ProfAddr(host, *dataProf)
+			if err = getJSON(fmt.Sprintf("http://%v/partition?id=%v", addr, dp.PartitionID), body); err != nil {
+				return fmt.Errorf("get extents of dp(%v) from %v: %v", dp.PartitionID, addr, err)
+			}
+			if body.Code != http.StatusOK {
+				return fmt.Errorf("get extents of dp(%v) from %v: code(%v) msg(%v)", dp.PartitionID, addr, body.Code, body.Msg)
+			}
+			data.AddReplica(dp.PartitionID, host, body.Data.Extents)
+		}
+	}
+	return
+}
+
+// repairDentries removes the dangling dentries, which are collected from the partitions one by one
+// while the volume is in use. So each of them is checked again before removed: the dentry must
+// still refer to the inode, the inode must still not exist, and the parent must not have been
+// modified after the time, otherwise the dentry may be created after the inodes were collected.
+func repairDentries(dentries []*Dentry, before time.Time) (err error) {
+	opt := &proto.MountOptions{
+		Volname: *volName,
+		Master:  *masterAddr,
+	}
+	mw, err := meta.NewMetaWrapper(opt, false)
+	if err != nil {
+		return
+	}
+	defer mw.Close()
+	for _, d := range dentries {
+		var dangling bool
+		if dangling, err = stillDangling(mw, d, before); err != nil {
+			return fmt.Errorf("check dentry parent(%v) name(%v): %v", d.ParentId, d.Name, err)
+		}
+		if !dangling {
+			fmt.Printf("skipped dentry changed since the check: parent(%v) name(%v) ino(%v)\n", d.ParentId, d.Name, d.Inode)
+			continue
+		}
+		if _, err = mw.Delete_ll(d.ParentId, d.Name, false); err != nil {
+			return fmt.Errorf("delete dentry parent(%v) name(%v): %v", d.ParentId, d.Name, err)
+		}
+		fmt.Printf("removed dangling dentry: parent(%v) name(%v) ino(%v)\n", d.ParentId, d.Name, d.Inode)
+	}
+	return
+}
+
+func stillDangling(mw *meta.MetaWrapper, d *Dentry, before time.Time) (bool, error) {
+	ino, _, err := mw.Lookup_ll(d.ParentId, d.Name)
+	if err == syscall.ENOENT {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ino != d.Inode {
+		return false, nil
+	}
+	if _, err = mw.InodeGet_ll(d.Inode); err != syscall.ENOENT {
+		return false, err
+	}
+	parent, err := mw.InodeGet_ll(d.ParentId)
+	if err != nil {
+		return false, err
+	}
+	return parent.ModifyTime.Before(before), nil
+}
+
+func getJSON(url string, v interface{}) (err error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(body, v)
+}
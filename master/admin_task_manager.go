@@ -24,6 +24,7 @@ import (
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
 	"net"
 )
 
@@ -113,21 +114,14 @@ func (sender *AdminTaskManager) doSendTasks() {
 	sender.sendTasks(tasks)
 }
 
-func (sender *AdminTaskManager) getConn() (conn *net.TCPConn, err error) {
+func (sender *AdminTaskManager) getConn() (conn net.Conn, err error) {
 	if useConnPool {
 		return sender.connPool.GetConnect(sender.targetAddr)
 	}
-	var connect net.Conn
-	connect, err = net.Dial("tcp", sender.targetAddr)
-	if err == nil {
-		conn = connect.(*net.TCPConn)
-		conn.SetKeepAlive(true)
-		conn.SetNoDelay(true)
-	}
-	return
+	return mtls.Dial(sender.targetAddr, time.Second)
 }
 
-func (sender *AdminTaskManager) putConn(conn *net.TCPConn, forceClose bool) {
+func (sender *AdminTaskManager) putConn(conn net.Conn, forceClose bool) {
 	if useConnPool {
 		sender.connPool.PutConnect(conn, forceClose)
 	}
@@ -223,6 +217,31 @@ func (sender *AdminTaskManager) syncSendAdminTask(task *proto.AdminTask) (packet
 	return packet, nil
 }
 
+// syncSendPacket sends the packet of a client op to the node and returns the response, for the
+// queries the master makes as a client of the meta partitions.
+func (sender *AdminTaskManager) syncSendPacket(packet *proto.Packet) (resp *proto.Packet, err error) {
+	conn, err := sender.getConn()
+	if err != nil {
+		return nil, errors.Trace(err, "action[syncSendPacket] get conn failed,reqID[%v]", packet.ReqID)
+	}
+	defer func() {
+		sender.putConn(conn, err != nil)
+	}()
+	if err = packet.WriteToConn(conn); err != nil {
+		return nil, errors.Trace(err, "action[syncSendPacket],WriteToConn failed,reqID[%v]", packet.ReqID)
+	}
+	resp = proto.NewPacket()
+	if err = resp.ReadFromConn(conn, proto.SyncSendTaskDeadlineTime); err != nil {
+		return nil, errors.Trace(err, "action[syncSendPacket],ReadFromConn failed,reqID[%v]", packet.ReqID)
+	}
+	if resp.ResultCode != proto.OpOk {
+		err = fmt.Errorf("result code[%v],msg[%v]", resp.ResultCode, string(resp.Data))
+		log.LogErrorf("action[syncSendPacket],op[%v],reqID[%v],err[%v]", packet.GetOpMsg(), packet.ReqID, err)
+		return
+	}
+	return resp, nil
+}
+
 // DelTask deletes the to-be-deleted tasks.
 func (sender *AdminTaskManager) DelTask(t *proto.AdminTask) {
 	sender.Lock()
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"bytes"
+	"io"
 	"io/ioutil"
 	"strings"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/chubaofs/chubaofs/util/cryptoutil"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
 )
 
 // NodeView provides the view of the data or meta node.
@@ -84,6 +86,603 @@ func (m *Server) setMetaNodeThreshold(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set threshold to %v successfully", threshold)))
 }
 
+// Set the rate limit of the ops of a module, which is removed if the rate is 0.
+func (m *Server) setRateLimit(w http.ResponseWriter, r *http.Request) {
+	var (
+		rule *proto.RateLimitRule
+		err  error
+	)
+	if rule, err = parseRateLimitRule(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setRateLimit(rule); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set rate limit %+v successfully", *rule)))
+}
+
+func (m *Server) getRateLimit(w http.ResponseWriter, r *http.Request) {
+	rules := m.cluster.getRateLimits()
+	if rules == nil {
+		rules = make([]*proto.RateLimitRule, 0)
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(rules))
+}
+
+// Get the encryption key of the version, or the latest one without the version.
+func (m *Server) getEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		version uint64
+		key     *proto.EncryptionKey
+		err     error
+	)
+	if err = r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if value := r.FormValue(versionKey); value != "" {
+		if version, err = strconv.ParseUint(value, 10, 32); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+	}
+	if key, err = m.cluster.getEncryptionKey(uint32(version)); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(key))
+}
+
+// Create a new encryption key, which encrypts the data keys of the objects from now on.
+func (m *Server) rotateEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	key, err := m.cluster.rotateEncryptionKey()
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("rotate encryption key to version[%v] successfully", key.Version)))
+}
+
+// Preview the moves of the data partitions the balancer plans for the next round, or at most the
+// limit of them regardless of the data partitions recovering.
+func (m *Server) getBalancePlan(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if value := r.FormValue(limitKey); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: unmatchedKey(limitKey).Error()})
+			return
+		}
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.planBalance(limit)))
+}
+
+func (m *Server) setBalancePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if err := m.cluster.setBalancePaused(paused); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set balance paused to %v successfully", paused)))
+}
+
+// Create a job draining the partitions off a data node or a meta node, which is removed from the
+// cluster once all of them are migrated.
+func (m *Server) createDecommissionJob(w http.ResponseWriter, r *http.Request) {
+	kind, addr, concurrency, rate, maxRetries, err := parseRequestToCreateDecommissionJob(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	job, err := m.cluster.createDecommissionJob(kind, addr, concurrency, rate, maxRetries)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(job))
+}
+
+func (m *Server) getDecommissionJob(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAndExtractDecommissionJobID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	dj, err := m.cluster.getDecommissionJob(id)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(dj.view(true)))
+}
+
+func (m *Server) listDecommissionJobs(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.listDecommissionJobs()))
+}
+
+// Pause, resume or cancel the decommission job by the function of the cluster.
+func (m *Server) updateDecommissionJob(w http.ResponseWriter, r *http.Request, action string, update func(id uint64) error) {
+	id, err := parseAndExtractDecommissionJobID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = update(id); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("%v decommission job[%v] successfully", action, id)))
+}
+
+// Set the lifecycle rules of the volume in the body, which are removed if there is none.
+func (m *Server) setVolLifecycle(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		rules   []*proto.LifecycleRule
+		err     error
+	)
+	if name, authKey, rules, err = parseRequestToSetVolLifecycle(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolLifecycle(name, authKey, rules); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set %v lifecycle rules of vol[%v] successfully", len(rules), name)))
+}
+
+func (m *Server) getVolLifecycle(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		vol  *Vol
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.getVolLifecycle(vol)))
+}
+
+// List the lifecycle configurations of the volumes which have rules, to be executed by the object nodes.
+func (m *Server) listVolLifecycles(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.listVolLifecycles()))
+}
+
+// Set the S3 bucket policy of the volume in the body, which is removed if the body is empty.
+func (m *Server) setVolPolicy(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		policy  string
+		err     error
+	)
+	if name, authKey, policy, err = parseRequestToSetVolPolicy(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolPolicy(name, authKey, policy); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set bucket policy of vol[%v] successfully", name)))
+}
+
+func (m *Server) getVolPolicy(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		vol  *Vol
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.getVolPolicy(vol)))
+}
+
+// Set the quota of the directory of the volume, whose limits are updated if it has been set.
+func (m *Server) setVolQuota(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		quota   *proto.QuotaInfo
+		err     error
+	)
+	if name, authKey, quota, err = parseRequestToSetVolQuota(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolQuota(name, authKey, quota); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(quota))
+}
+
+func (m *Server) deleteVolQuota(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		quotaID uint32
+		err     error
+	)
+	if name, authKey, quotaID, err = parseRequestToDeleteVolQuota(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.deleteVolQuota(name, authKey, quotaID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("delete quota[%v] of vol[%v] successfully", quotaID, name)))
+}
+
+// List the quotas of the volume with the usage reported by the meta partitions, which are
+// enforced by the clients.
+func (m *Server) listVolQuotas(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		vol  *Vol
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(vol.listQuotas()))
+}
+
+// Set the hours the files deleted from the volume are kept in the trash, which is disabled if 0.
+func (m *Server) setVolTrash(w http.ResponseWriter, r *http.Request) {
+	var (
+		name      string
+		authKey   string
+		retention uint32
+		err       error
+	)
+	if name, authKey, retention, err = parseRequestToSetVolTrash(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolTrash(name, authKey, retention); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set trash retention of vol[%v] to %v hours successfully", name, retention)))
+}
+
+// Set the compression of the extents written to the volume afterwards, the data written already is
+// kept as it is.
+func (m *Server) setVolCompression(w http.ResponseWriter, r *http.Request) {
+	var (
+		name        string
+		authKey     string
+		compression string
+		err         error
+	)
+	if name, authKey, compression, err = parseRequestToSetVolCompression(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolCompression(name, authKey, compression); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set compression of vol[%v] to %v successfully", name, compression)))
+}
+
+// Set the erasure coding scheme of the volume, whose data partitions not modified for the cold age
+// are migrated to the erasure coded partitions on the ec nodes.
+func (m *Server) setVolEC(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		scheme  string
+		coldAge int64
+		err     error
+	)
+	if name, authKey, scheme, coldAge, err = parseRequestToSetVolEC(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolEC(name, authKey, scheme, coldAge); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set ec scheme of vol[%v] to %v successfully", name, scheme)))
+}
+
+func (m *Server) setVolTiering(w http.ResponseWriter, r *http.Request) {
+	var (
+		name      string
+		authKey   string
+		hotMedia  string
+		coldMedia string
+		coldAge   int64
+		err       error
+	)
+	if name, authKey, hotMedia, coldMedia, coldAge, err = parseRequestToSetVolTiering(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolTiering(name, authKey, hotMedia, coldMedia, coldAge); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set tiering of vol[%v] to hot media[%v] cold media[%v] successfully",
+		name, hotMedia, coldMedia)))
+}
+
+func (m *Server) setVolPlacement(w http.ResponseWriter, r *http.Request) {
+	var (
+		name       string
+		authKey    string
+		zoneSpread uint8
+		rackSpread uint8
+		err        error
+	)
+	if name, authKey, zoneSpread, rackSpread, err = parseRequestToSetVolPlacement(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolPlacement(name, authKey, zoneSpread, rackSpread); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set placement of vol[%v] to zone spread[%v] rack spread[%v] successfully",
+		name, zoneSpread, rackSpread)))
+}
+
+func (m *Server) shrinkVol(w http.ResponseWriter, r *http.Request) {
+	var (
+		name      string
+		authKey   string
+		capacity  uint64
+		releasing []uint64
+		err       error
+	)
+	if name, authKey, capacity, err = parseRequestToShrinkVol(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if releasing, err = m.cluster.shrinkVol(name, authKey, capacity); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	if releasing == nil {
+		releasing = make([]uint64, 0)
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(releasing))
+}
+
+func (m *Server) releaseDataPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		name        string
+		authKey     string
+		partitionID uint64
+		err         error
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if partitionID, err = extractDataPartitionID(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.releaseDataPartition(name, authKey, partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("release data partition[%v] of vol[%v] successfully", partitionID, name)))
+}
+
+func (m *Server) getECPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		ep          *ECPartition
+		partitionID uint64
+		err         error
+	)
+	if err = r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if partitionID, err = extractDataPartitionID(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if ep, err = m.cluster.getECPartition(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(ep.toInfo()))
+}
+
+// Create a snapshot of the volume, which is ready once the data and the meta partitions have frozen.
+func (m *Server) createVolSnapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		name     string
+		authKey  string
+		snapName string
+		snapshot *proto.VolSnapshot
+		err      error
+	)
+	if name, authKey, snapName, err = parseRequestToVolSnapshot(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if snapshot, err = m.cluster.createVolSnapshot(name, authKey, snapName); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(snapshot))
+}
+
+func (m *Server) deleteVolSnapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		name     string
+		authKey  string
+		snapName string
+		err      error
+	)
+	if name, authKey, snapName, err = parseRequestToVolSnapshot(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.deleteVolSnapshot(name, authKey, snapName); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("delete snapshot[%v] of vol[%v] successfully", snapName, name)))
+}
+
+func (m *Server) listVolSnapshots(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		vol  *Vol
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(vol.listSnapshots()))
+}
+
+// Create an access key of the volume, whose secret key is only replied here.
+func (m *Server) createVolAccessKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		name       string
+		authKey    string
+		permission string
+		prefixes   []string
+		expire     int64
+		key        *proto.VolAccessKey
+		err        error
+	)
+	if name, authKey, permission, prefixes, expire, err = parseRequestToCreateVolAccessKey(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if key, err = m.cluster.createVolAccessKey(name, authKey, permission, prefixes, expire); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(key))
+}
+
+func (m *Server) listVolAccessKeys(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		vol     *Vol
+		err     error
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	if !matchKey(vol.Owner, authKey) {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolAuthKeyNotMatch))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(vol.listAccessKeys()))
+}
+
+func (m *Server) revokeVolAccessKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		name      string
+		authKey   string
+		accessKey string
+		grace     int64
+		err       error
+	)
+	if name, authKey, accessKey, grace, err = parseRequestToRevokeVolAccessKey(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.revokeVolAccessKey(name, authKey, accessKey, grace); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("revoke access key[%v] of vol[%v] successfully", accessKey, name)))
+}
+
+// Find the dentries referring to the inode in all the meta partitions of the volume, along with the
+// link count of the inode, for debugging the leaks of the link counts.
+func (m *Server) reverseLookupInode(w http.ResponseWriter, r *http.Request) {
+	var (
+		name  string
+		ino   uint64
+		links *proto.InodeLinks
+		err   error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if ino, err = strconv.ParseUint(r.FormValue(inodeKey), 10, 64); err != nil || ino == 0 {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("invalid %v", inodeKey)})
+		return
+	}
+	if links, err = m.cluster.reverseLookupInode(name, ino); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(links))
+}
+
+// List the cluster events from the given ID, whose reply contains the ID to list the next events from.
+func (m *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	var (
+		from  uint64
+		limit = defaultMaxEventsToList
+		err   error
+	)
+	if err = r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if value := r.FormValue(fromKey); value != "" {
+		if from, err = strconv.ParseUint(value, 10, 64); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+	}
+	if value := r.FormValue(limitKey); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > defaultMaxEventsToList {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError,
+				Msg: fmt.Sprintf("limit must be between 1 and %v", defaultMaxEventsToList)})
+			return
+		}
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.events.list(from, r.FormValue(typeKey), limit)))
+}
+
 // Turn on or off the automatic allocation of the data partitions.
 // If DisableAutoAllocate == off, then we WILL NOT automatically allocate new data partitions for the volume when:
 // 	1. the used space is below the max capacity,
@@ -140,6 +739,7 @@ func (m *Server) getCluster(w http.ResponseWriter, r *http.Request) {
 		Name:                m.cluster.Name,
 		LeaderAddr:          m.leaderInfo.addr,
 		DisableAutoAlloc:    m.cluster.DisableAutoAllocate,
+		BalancePaused:       m.cluster.BalancePaused,
 		MetaNodeThreshold:   m.cluster.cfg.MetaNodeThreshold,
 		Applied:             m.fsm.applied,
 		MaxDataPartitionID:  m.cluster.idAlloc.dataPartitionID,
@@ -183,7 +783,11 @@ func (m *Server) getCluster(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Server) getIPAddr(w http.ResponseWriter, r *http.Request) {
-	cInfo := &proto.ClusterInfo{Cluster: m.cluster.Name, Ip: strings.Split(r.RemoteAddr, ":")[0]}
+	cInfo := &proto.ClusterInfo{
+		Cluster:          m.cluster.Name,
+		Ip:               strings.Split(r.RemoteAddr, ":")[0],
+		MinClientVersion: m.cluster.minClientVersion(),
+	}
 	sendOkReply(w, r, newSuccessHTTPReply(cInfo))
 }
 
@@ -300,6 +904,34 @@ func (m *Server) loadDataPartition(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Server) addDataReplica(w http.ResponseWriter, r *http.Request) {
+	var (
+		msg         string
+		addr        string
+		dp          *DataPartition
+		partitionID uint64
+		learner     bool
+		err         error
+	)
+
+	if partitionID, addr, learner, err = parseRequestToAddDataReplica(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+
+	if err = m.cluster.addDataReplica(dp, addr, learner); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg = fmt.Sprintf("data partitionID :%v  add replica [%v] learner[%v] successfully", partitionID, addr, learner)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+func (m *Server) promoteDataReplica(w http.ResponseWriter, r *http.Request) {
 	var (
 		msg         string
 		addr        string
@@ -308,7 +940,7 @@ func (m *Server) addDataReplica(w http.ResponseWriter, r *http.Request) {
 		err         error
 	)
 
-	if partitionID, addr, err = parseRequestToAddDataReplica(r); err != nil {
+	if partitionID, addr, err = extractDataPartitionIDAndAddr(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -318,11 +950,11 @@ func (m *Server) addDataReplica(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = m.cluster.addDataReplica(dp, addr); err != nil {
+	if err = m.cluster.promoteDataReplica(dp, addr); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	msg = fmt.Sprintf("data partitionID :%v  add replica [%v] successfully", partitionID, addr)
+	msg = fmt.Sprintf("data partitionID :%v  promote replica [%v] successfully", partitionID, addr)
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
@@ -340,20 +972,48 @@ func (m *Server) deleteDataReplica(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+
+	if err = m.cluster.removeDataReplica(dp, addr, true); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg = fmt.Sprintf("data partitionID :%v  delete replica [%v] successfully", partitionID, addr)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+func (m *Server) addMetaReplica(w http.ResponseWriter, r *http.Request) {
+	var (
+		msg         string
+		addr        string
+		mp          *MetaPartition
+		partitionID uint64
+		learner     bool
+		err         error
+	)
+
+	if partitionID, addr, learner, err = parseRequestToAddMetaReplica(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if mp, err = m.cluster.getMetaPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrMetaPartitionNotExists))
 		return
 	}
 
-	if err = m.cluster.removeDataReplica(dp, addr, true); err != nil {
+	if err = m.cluster.addMetaReplica(mp, addr, learner); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	msg = fmt.Sprintf("data partitionID :%v  delete replica [%v] successfully", partitionID, addr)
+	msg = fmt.Sprintf("meta partitionID :%v  add replica [%v] learner[%v] successfully", partitionID, addr, learner)
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
-func (m *Server) addMetaReplica(w http.ResponseWriter, r *http.Request) {
+func (m *Server) promoteMetaReplica(w http.ResponseWriter, r *http.Request) {
 	var (
 		msg         string
 		addr        string
@@ -362,7 +1022,7 @@ func (m *Server) addMetaReplica(w http.ResponseWriter, r *http.Request) {
 		err         error
 	)
 
-	if partitionID, addr, err = parseRequestToAddMetaReplica(r); err != nil {
+	if partitionID, addr, err = extractMetaPartitionIDAndAddr(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -372,11 +1032,11 @@ func (m *Server) addMetaReplica(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = m.cluster.addMetaReplica(mp, addr); err != nil {
+	if err = m.cluster.promoteMetaReplica(mp, addr); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	msg = fmt.Sprintf("meta partitionID :%v  add replica [%v] successfully", partitionID, addr)
+	msg = fmt.Sprintf("meta partitionID :%v  promote replica [%v] successfully", partitionID, addr)
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
@@ -506,9 +1166,10 @@ func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
 		vol          *Vol
 		followerRead bool
 		authenticate bool
+		storeMode    string
 	)
 
-	if name, owner, mpCount, dpReplicaNum, size, capacity, followerRead, authenticate, err = parseRequestToCreateVol(r); err != nil {
+	if name, owner, mpCount, dpReplicaNum, size, capacity, followerRead, authenticate, storeMode, err = parseRequestToCreateVol(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -517,7 +1178,7 @@ func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-	if vol, err = m.cluster.createVol(name, owner, mpCount, dpReplicaNum, size, capacity, followerRead, authenticate); err != nil {
+	if vol, err = m.cluster.createVol(name, owner, mpCount, dpReplicaNum, size, capacity, followerRead, authenticate, storeMode); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
@@ -556,6 +1217,17 @@ func newSimpleView(vol *Vol) *proto.SimpleVolView {
 		FollowerRead:       vol.FollowerRead,
 		NeedToLowerReplica: vol.NeedToLowerReplica,
 		Authenticate:       vol.authenticate,
+		MetaStoreMode:      vol.metaStoreMode,
+		TrashRetention:     vol.trashRetention,
+		Compression:        vol.compression,
+		ECScheme:           vol.ecScheme,
+		ECColdAge:          vol.ecColdAge,
+		HotMedia:           vol.hotMedia,
+		ColdMedia:          vol.coldMedia,
+		TierColdAge:        vol.tierColdAge,
+		ZoneSpread:         vol.zoneSpread,
+		RackSpread:         vol.rackSpread,
+		ReleasingDps:       vol.releasingDataPartitions(),
 		RwDpCnt:            vol.dataPartitions.readableAndWritableCnt,
 		MpCnt:              len(vol.MetaPartitions),
 		DpCnt:              len(vol.dataPartitions.partitionMap),
@@ -615,6 +1287,11 @@ func (m *Server) getDataNode(w http.ResponseWriter, r *http.Request) {
 		NodeSetID:                 dataNode.NodeSetID,
 		PersistenceDataPartitions: dataNode.PersistenceDataPartitions,
 		BadDisks:                  dataNode.BadDisks,
+		ProtocolVersion:           dataNode.ProtocolVersion,
+		MinClientVersion:          dataNode.MinClientVersion,
+		MediaType:                 dataNode.MediaType,
+		ZoneName:                  dataNode.ZoneName,
+		RackName:                  dataNode.RackName,
 	}
 
 	sendOkReply(w, r, newSuccessHTTPReply(dataNodeInfo))
@@ -696,6 +1373,50 @@ func (m *Server) handleDataNodeTaskResponse(w http.ResponseWriter, r *http.Reque
 	m.cluster.handleDataNodeTaskResponse(tr.OperatorAddr, tr)
 }
 
+func (m *Server) addECNode(w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeAddr string
+		id       uint64
+		err      error
+	)
+	if nodeAddr, err = parseAndExtractNodeAddr(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if id, err = m.cluster.addECNode(nodeAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(id))
+}
+
+func (m *Server) getECNode(w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeAddr string
+		ecNode   *ECNode
+		err      error
+	)
+	if nodeAddr, err = parseAndExtractNodeAddr(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if ecNode, err = m.cluster.ecNode(nodeAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(ecNode.toInfo()))
+}
+
+func (m *Server) handleECNodeTaskResponse(w http.ResponseWriter, r *http.Request) {
+	tr, err := parseRequestToGetTaskResponse(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("%v", http.StatusOK)))
+	m.cluster.handleECNodeTaskResponse(tr.OperatorAddr, tr)
+}
+
 func (m *Server) addMetaNode(w http.ResponseWriter, r *http.Request) {
 	var (
 		nodeAddr string
@@ -736,6 +1457,8 @@ func (m *Server) getMetaNode(w http.ResponseWriter, r *http.Request) {
 		Addr:                      metaNode.Addr,
 		IsActive:                  metaNode.IsActive,
 		CellName:                  metaNode.CellName,
+		ZoneName:                  metaNode.ZoneName,
+		RackName:                  metaNode.RackName,
 		MaxMemAvailWeight:         metaNode.MaxMemAvailWeight,
 		Total:                     metaNode.Total,
 		Used:                      metaNode.Used,
@@ -747,6 +1470,8 @@ func (m *Server) getMetaNode(w http.ResponseWriter, r *http.Request) {
 		MetaPartitionCount:        metaNode.MetaPartitionCount,
 		NodeSetID:                 metaNode.NodeSetID,
 		PersistenceMetaPartitions: metaNode.PersistenceMetaPartitions,
+		ProtocolVersion:           metaNode.ProtocolVersion,
+		MinClientVersion:          metaNode.MinClientVersion,
 	}
 	sendOkReply(w, r, newSuccessHTTPReply(metaNodeInfo))
 }
@@ -981,6 +1706,302 @@ func parseVolNameAndAuthKey(r *http.Request) (name, authKey string, err error) {
 
 }
 
+func parseRequestToSetVolLifecycle(r *http.Request) (name, authKey string, rules []*proto.LifecycleRule, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	var body []byte
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		return
+	}
+	if len(body) == 0 {
+		return
+	}
+	if err = json.Unmarshal(body, &rules); err != nil {
+		return
+	}
+	for _, rule := range rules {
+		if rule == nil {
+			return "", "", nil, errors.New("null lifecycle rule")
+		}
+	}
+	return
+}
+
+func parseRequestToSetVolPolicy(r *http.Request) (name, authKey, policy string, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxBucketPolicySize+1)); err != nil {
+		return
+	}
+	if len(body) > maxBucketPolicySize {
+		return "", "", "", fmt.Errorf("bucket policy larger than %v bytes", maxBucketPolicySize)
+	}
+	if len(body) > 0 && !json.Valid(body) {
+		return "", "", "", errors.New("bucket policy is not valid json")
+	}
+	policy = string(body)
+	return
+}
+
+func parseRequestToSetVolQuota(r *http.Request) (name, authKey string, quota *proto.QuotaInfo, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	quota = &proto.QuotaInfo{Path: r.FormValue(pathKey)}
+	if quota.Path == "" {
+		return "", "", nil, keyNotFound(pathKey)
+	}
+	var value string
+	if value = r.FormValue(inodeKey); value == "" {
+		return "", "", nil, keyNotFound(inodeKey)
+	}
+	if quota.RootInode, err = strconv.ParseUint(value, 10, 64); err != nil {
+		return
+	}
+	if value = r.FormValue(maxFilesKey); value != "" {
+		if quota.MaxFiles, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return
+		}
+	}
+	if value = r.FormValue(maxBytesKey); value != "" {
+		if quota.MaxBytes, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func parseRequestToDeleteVolQuota(r *http.Request) (name, authKey string, quotaID uint32, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	var value string
+	if value = r.FormValue(quotaIDKey); value == "" {
+		err = keyNotFound(quotaIDKey)
+		return
+	}
+	var id uint64
+	if id, err = strconv.ParseUint(value, 10, 32); err != nil {
+		return
+	}
+	quotaID = uint32(id)
+	return
+}
+
+func parseRequestToSetVolTrash(r *http.Request) (name, authKey string, retention uint32, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	var value string
+	if value = r.FormValue(retentionKey); value == "" {
+		err = keyNotFound(retentionKey)
+		return
+	}
+	var hours uint64
+	if hours, err = strconv.ParseUint(value, 10, 32); err != nil {
+		return
+	}
+	if hours > maxTrashRetention {
+		err = fmt.Errorf("trash retention %v hours exceeds %v", hours, maxTrashRetention)
+		return
+	}
+	retention = uint32(hours)
+	return
+}
+
+func parseRequestToSetVolCompression(r *http.Request) (name, authKey, compression string, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if compression = r.FormValue(compressionKey); compression == "" {
+		err = keyNotFound(compressionKey)
+		return
+	}
+	if !proto.IsValidExtentCompression(compression) {
+		err = fmt.Errorf("unsupported compression %v", compression)
+		return
+	}
+	return
+}
+
+func parseRequestToSetVolEC(r *http.Request) (name, authKey, scheme string, coldAge int64, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if scheme = r.FormValue(ecSchemeKey); scheme == "" {
+		err = keyNotFound(ecSchemeKey)
+		return
+	}
+	if value := r.FormValue(ecColdAgeKey); value != "" {
+		if coldAge, err = strconv.ParseInt(value, 10, 64); err != nil || coldAge <= 0 {
+			err = unmatchedKey(ecColdAgeKey)
+			return
+		}
+	}
+	return
+}
+
+func parseRequestToSetVolTiering(r *http.Request) (name, authKey, hotMedia, coldMedia string, coldAge int64, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if hotMedia = r.FormValue(hotMediaKey); hotMedia == "" {
+		err = keyNotFound(hotMediaKey)
+		return
+	}
+	if coldMedia = r.FormValue(coldMediaKey); coldMedia == "" {
+		err = keyNotFound(coldMediaKey)
+		return
+	}
+	if value := r.FormValue(coldAgeKey); value != "" {
+		if coldAge, err = strconv.ParseInt(value, 10, 64); err != nil || coldAge <= 0 {
+			err = unmatchedKey(coldAgeKey)
+			return
+		}
+	}
+	return
+}
+
+func parseRequestToSetVolPlacement(r *http.Request) (name, authKey string, zoneSpread, rackSpread uint8, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	var value uint64
+	if zoneValue := r.FormValue(zoneSpreadKey); zoneValue != "" {
+		if value, err = strconv.ParseUint(zoneValue, 10, 8); err != nil {
+			err = unmatchedKey(zoneSpreadKey)
+			return
+		}
+		zoneSpread = uint8(value)
+	}
+	if rackValue := r.FormValue(rackSpreadKey); rackValue != "" {
+		if value, err = strconv.ParseUint(rackValue, 10, 8); err != nil {
+			err = unmatchedKey(rackSpreadKey)
+			return
+		}
+		rackSpread = uint8(value)
+	}
+	return
+}
+
+func parseRequestToShrinkVol(r *http.Request) (name, authKey string, capacity uint64, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if value := r.FormValue(volCapacityKey); value == "" {
+		err = keyNotFound(volCapacityKey)
+		return
+	} else if capacity, err = strconv.ParseUint(value, 10, 64); err != nil {
+		err = unmatchedKey(volCapacityKey)
+		return
+	}
+	return
+}
+
+func parseRequestToCreateDecommissionJob(r *http.Request) (kind, addr string, concurrency int, rate uint64, maxRetries int, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if kind = r.FormValue(typeKey); kind != proto.DecommissionJobDataNode && kind != proto.DecommissionJobMetaNode {
+		err = unmatchedKey(typeKey)
+		return
+	}
+	if addr, err = extractNodeAddr(r); err != nil {
+		return
+	}
+	concurrency, maxRetries = defaultDecommissionConcurrency, defaultDecommissionMaxRetries
+	if value := r.FormValue(concurrencyKey); value != "" {
+		if concurrency, err = strconv.Atoi(value); err != nil || concurrency <= 0 {
+			err = unmatchedKey(concurrencyKey)
+			return
+		}
+	}
+	// the rate is given in MB per second
+	if value := r.FormValue(rateKey); value != "" {
+		if rate, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = unmatchedKey(rateKey)
+			return
+		}
+		rate *= util.MB
+	}
+	if value := r.FormValue(maxRetriesKey); value != "" {
+		if maxRetries, err = strconv.Atoi(value); err != nil || maxRetries < 0 {
+			err = unmatchedKey(maxRetriesKey)
+			return
+		}
+	}
+	return
+}
+
+func parseAndExtractDecommissionJobID(r *http.Request) (id uint64, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	value := r.FormValue(idKey)
+	if value == "" {
+		err = keyNotFound(idKey)
+		return
+	}
+	if id, err = strconv.ParseUint(value, 10, 64); err != nil {
+		err = unmatchedKey(idKey)
+	}
+	return
+}
+
+func parseRequestToVolSnapshot(r *http.Request) (name, authKey, snapName string, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if snapName = r.FormValue(snapshotKey); snapName == "" {
+		err = keyNotFound(snapshotKey)
+		return
+	}
+	if len(snapName) > maxVolSnapshotNameLength {
+		err = fmt.Errorf("snapshot name exceeds %v bytes", maxVolSnapshotNameLength)
+		return
+	}
+	return
+}
+
+func parseRequestToCreateVolAccessKey(r *http.Request) (name, authKey, permission string, prefixes []string, expire int64, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if permission = r.FormValue(permissionKey); permission == "" {
+		permission = proto.AccessKeyReadWrite
+	}
+	if value := r.FormValue(prefixesKey); value != "" {
+		prefixes = strings.Split(value, ",")
+	}
+	if value := r.FormValue(expireKey); value != "" {
+		if expire, err = strconv.ParseInt(value, 10, 64); err != nil || expire < 0 {
+			err = fmt.Errorf("invalid %v[%v]", expireKey, value)
+			return
+		}
+	}
+	return
+}
+
+func parseRequestToRevokeVolAccessKey(r *http.Request) (name, authKey, accessKey string, grace int64, err error) {
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		return
+	}
+	if accessKey = r.FormValue(accessKeyKey); accessKey == "" {
+		err = keyNotFound(accessKeyKey)
+		return
+	}
+	if value := r.FormValue(graceKey); value != "" {
+		if grace, err = strconv.ParseInt(value, 10, 64); err != nil || grace < 0 {
+			err = fmt.Errorf("invalid %v[%v]", graceKey, value)
+			return
+		}
+	}
+	return
+}
+
 func parseRequestToDeleteVol(r *http.Request) (name, authKey string, err error) {
 	return parseVolNameAndAuthKey(r)
 
@@ -1034,7 +2055,7 @@ func parseBoolFieldToUpdateVol(r *http.Request, vol *Vol) (followerRead, authent
 	return
 }
 
-func parseRequestToCreateVol(r *http.Request) (name, owner string, mpCount, dpReplicaNum, size, capacity int, followerRead, authenticate bool, err error) {
+func parseRequestToCreateVol(r *http.Request) (name, owner string, mpCount, dpReplicaNum, size, capacity int, followerRead, authenticate bool, storeMode string, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
 	}
@@ -1081,6 +2102,11 @@ func parseRequestToCreateVol(r *http.Request) (name, owner string, mpCount, dpRe
 		return
 	}
 
+	if storeMode = r.FormValue(metaStoreModeKey); !proto.IsValidStoreMode(storeMode) {
+		err = unmatchedKey(metaStoreModeKey)
+		return
+	}
+
 	return
 }
 
@@ -1122,8 +2148,12 @@ func parseRequestToLoadDataPartition(r *http.Request) (ID uint64, err error) {
 	return
 }
 
-func parseRequestToAddMetaReplica(r *http.Request) (ID uint64, addr string, err error) {
-	return extractMetaPartitionIDAndAddr(r)
+func parseRequestToAddMetaReplica(r *http.Request) (ID uint64, addr string, learner bool, err error) {
+	if ID, addr, err = extractMetaPartitionIDAndAddr(r); err != nil {
+		return
+	}
+	learner, err = extractLearner(r)
+	return
 }
 
 func parseRequestToRemoveMetaReplica(r *http.Request) (ID uint64, addr string, err error) {
@@ -1143,8 +2173,12 @@ func extractMetaPartitionIDAndAddr(r *http.Request) (ID uint64, addr string, err
 	return
 }
 
-func parseRequestToAddDataReplica(r *http.Request) (ID uint64, addr string, err error) {
-	return extractDataPartitionIDAndAddr(r)
+func parseRequestToAddDataReplica(r *http.Request) (ID uint64, addr string, learner bool, err error) {
+	if ID, addr, err = extractDataPartitionIDAndAddr(r); err != nil {
+		return
+	}
+	learner, err = extractLearner(r)
+	return
 }
 
 func parseRequestToRemoveDataReplica(r *http.Request) (ID uint64, addr string, err error) {
@@ -1239,6 +2273,18 @@ func extractFollowerRead(r *http.Request) (followerRead bool, err error) {
 	return
 }
 
+func extractLearner(r *http.Request) (learner bool, err error) {
+	var value string
+	if value = r.FormValue(learnerKey); value == "" {
+		return
+	}
+	if learner, err = strconv.ParseBool(value); err != nil {
+		err = unmatchedKey(learnerKey)
+		return
+	}
+	return
+}
+
 func extractAuthenticate(r *http.Request) (authenticate bool, err error) {
 	var value string
 	if value = r.FormValue(authenticateKey); value == "" {
@@ -1266,6 +2312,40 @@ func parseAndExtractThreshold(r *http.Request) (threshold float64, err error) {
 	return
 }
 
+func parseRateLimitRule(r *http.Request) (rule *proto.RateLimitRule, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	rule = &proto.RateLimitRule{
+		Module: r.FormValue(moduleKey),
+		Vol:    r.FormValue(volKey),
+		Op:     r.FormValue(opKey),
+		Client: r.FormValue(clientKey),
+	}
+	var value string
+	if r.FormValue(rateKey) == "" && r.FormValue(bytesKey) == "" {
+		err = keyNotFound(rateKey)
+		return
+	}
+	if value = r.FormValue(rateKey); value != "" {
+		if rule.Rate, err = strconv.ParseFloat(value, 64); err != nil {
+			return
+		}
+	}
+	if value = r.FormValue(bytesKey); value != "" {
+		if rule.Bytes, err = strconv.ParseFloat(value, 64); err != nil {
+			return
+		}
+	}
+	if value = r.FormValue(burstKey); value != "" {
+		if rule.Burst, err = strconv.Atoi(value); err != nil {
+			return
+		}
+	}
+	err = ratelimit.Check(rule)
+	return
+}
+
 func validateRequestToCreateMetaPartition(r *http.Request) (volName string, start uint64, err error) {
 	if volName, err = extractName(r); err != nil {
 		return
@@ -1426,7 +2506,10 @@ func (m *Server) getVol(w http.ResponseWriter, r *http.Request) {
 		viewCache = vol.getViewCache()
 	}
 	if vol.authenticate {
-		if jobj, ticket, ts, err = parseAndCheckTicket(r, m.cluster.MasterSecretKey, param.name); err != nil {
+		if jobj, ticket, ts, err = parseAndCheckTicket(r, m.cluster.MasterSecretKey, param.name); err == nil && m.revocations.isRevoked(&ticket) {
+			err = proto.ErrRevokedTicket
+		}
+		if err != nil {
 			if err == proto.ErrExpiredTicket {
 				sendErrReply(w, r, newErrHTTPReply(err))
 				return
@@ -1514,6 +2597,7 @@ func (m *Server) getMetaPartition(w http.ResponseWriter, r *http.Request) {
 				ReportTime: mp.Replicas[i].ReportTime,
 				Status:     mp.Replicas[i].Status,
 				IsLeader:   mp.Replicas[i].IsLeader,
+				RaftHealth: mp.Replicas[i].RaftHealth,
 			}
 		}
 		var mpInfo = &proto.MetaPartitionInfo{
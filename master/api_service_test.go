@@ -94,7 +94,7 @@ func createDefaultMasterServerForTest() *Server {
 	time.Sleep(5 * time.Second)
 	testServer.cluster.scheduleToUpdateStatInfo()
 	fmt.Printf("nodeSet len[%v]\n", len(testServer.cluster.t.nodeSetMap))
-	testServer.cluster.createVol(commonVolName, "cfs", 3, 3, 3, 100, false, false)
+	testServer.cluster.createVol(commonVolName, "cfs", 3, 3, 3, 100, false, false, "")
 	vol, err := testServer.cluster.getVol(commonVolName)
 	if err != nil {
 		panic(err)
@@ -424,6 +424,38 @@ func TestRemoveDataReplica(t *testing.T) {
 	partition.RUnlock()
 }
 
+func TestAddAndPromoteDataLearnerReplica(t *testing.T) {
+	partition := commonVol.dataPartitions.partitions[0]
+	dsAddr := "127.0.0.1:9106"
+	reqURL := fmt.Sprintf("%v%v?id=%v&addr=%v&learner=true", hostAddr, proto.AdminAddDataReplica, partition.PartitionID, dsAddr)
+	process(reqURL, t)
+	var isLearner = func() (learner, found bool) {
+		partition.RLock()
+		defer partition.RUnlock()
+		for _, peer := range partition.Peers {
+			if peer.Addr == dsAddr {
+				return peer.Learner, true
+			}
+		}
+		return false, false
+	}
+	if learner, found := isLearner(); !learner || !found {
+		t.Errorf("peers[%v] should contains learner[%v]", partition.Peers, dsAddr)
+		return
+	}
+	reqURL = fmt.Sprintf("%v%v?id=%v&addr=%v", hostAddr, proto.AdminPromoteDataReplica, partition.PartitionID, dsAddr)
+	process(reqURL, t)
+	if learner, found := isLearner(); learner || !found {
+		t.Errorf("peers[%v] should contains promoted learner[%v]", partition.Peers, dsAddr)
+		return
+	}
+	reqURL = fmt.Sprintf("%v%v?id=%v&addr=%v", hostAddr, proto.AdminDeleteDataReplica, partition.PartitionID, dsAddr)
+	process(reqURL, t)
+	partition.Lock()
+	partition.isRecover = false
+	partition.Unlock()
+}
+
 func TestAddMetaReplica(t *testing.T) {
 	maxPartitionID := commonVol.maxPartitionID()
 	partition := commonVol.MetaPartitions[maxPartitionID]
@@ -0,0 +1,328 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The balancer moves the replicas of the data partitions from the data nodes whose disk usage or
+// number of partitions is well above the mean of the cluster to the ones below it. Each round plans
+// at most one move out of and into each data node, and no more moves than keep the data partitions
+// recovering in the cluster under the limit, since a replica is moved the same way as it is
+// decommissioned. The target of a move has the media of the source, keeps the placement policy of
+// the volume, and stays less loaded than the source after the move, so that the replicas are not
+// moved back and forth. The balancer is paused and resumed by the API, and persisted with the cluster.
+
+// balanceNode is the load of a data node estimated by the moves planned.
+type balanceNode struct {
+	addr     string
+	media    string
+	total    uint64
+	used     uint64
+	count    int
+	writable bool
+}
+
+func (node *balanceNode) usage() float64 {
+	return float64(node.used) / float64(node.total)
+}
+
+// balancePlanner plans the moves of a round from the loads reported by the data nodes.
+type balancePlanner struct {
+	nodes      []*balanceNode
+	meanUsage  float64
+	meanCount  float64
+	partitions map[string][]*DataPartition // the healthy data partitions on each data node
+	planned    map[uint64]bool
+	moved      map[string]bool // the data nodes moved out of or into
+	policy     func(dp *DataPartition) placementPolicy
+	placement  func(hosts []string) []*placementNode
+}
+
+func newBalancePlanner(nodes []*balanceNode, partitions map[string][]*DataPartition) *balancePlanner {
+	p := &balancePlanner{
+		nodes:      nodes,
+		partitions: partitions,
+		planned:    make(map[uint64]bool),
+		moved:      make(map[string]bool),
+	}
+	var total, used uint64
+	var count int
+	for _, node := range nodes {
+		total += node.total
+		used += node.used
+		count += node.count
+	}
+	if len(nodes) > 0 {
+		p.meanUsage = float64(used) / float64(total)
+		p.meanCount = float64(count) / float64(len(nodes))
+	}
+	return p
+}
+
+// plan plans at most the limit of the moves, the most loaded data nodes first.
+func (p *balancePlanner) plan(limit int) (moves []*proto.BalanceMove) {
+	sources := append([]*balanceNode{}, p.nodes...)
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].usage() > sources[j].usage()
+	})
+	for _, source := range sources {
+		if len(moves) >= limit {
+			break
+		}
+		if p.moved[source.addr] {
+			continue
+		}
+		var move *proto.BalanceMove
+		if source.usage() > p.meanUsage+defaultBalanceUsageDiff {
+			move = p.planMove(source, true)
+		} else if float64(source.count) > p.meanCount*(1+defaultBalanceCountDiff)+1 {
+			move = p.planMove(source, false)
+		}
+		if move != nil {
+			moves = append(moves, move)
+		}
+	}
+	return
+}
+
+// planMove plans a move out of the source for its usage, the largest replica first, or for its
+// number of partitions, the smallest replica first.
+func (p *balancePlanner) planMove(source *balanceNode, byUsage bool) *proto.BalanceMove {
+	candidates := p.partitions[source.addr]
+	sizes := make(map[uint64]uint64, len(candidates))
+	for _, dp := range candidates {
+		dp.RLock()
+		if replica, err := dp.getReplica(source.addr); err == nil {
+			sizes[dp.PartitionID] = replica.Used
+		}
+		dp.RUnlock()
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if byUsage {
+			return sizes[candidates[i].PartitionID] > sizes[candidates[j].PartitionID]
+		}
+		return sizes[candidates[i].PartitionID] < sizes[candidates[j].PartitionID]
+	})
+	for _, dp := range candidates {
+		if p.planned[dp.PartitionID] {
+			continue
+		}
+		size := sizes[dp.PartitionID]
+		if size > source.used {
+			continue
+		}
+		if target := p.chooseTarget(source, dp, size, byUsage); target != nil {
+			reason := fmt.Sprintf("usage %.2f above the mean %.2f", source.usage(), p.meanUsage)
+			if !byUsage {
+				reason = fmt.Sprintf("%v partitions above the mean %.1f", source.count, p.meanCount)
+			}
+			p.planned[dp.PartitionID] = true
+			p.moved[source.addr], p.moved[target.addr] = true, true
+			source.used, source.count = source.used-size, source.count-1
+			target.used, target.count = target.used+size, target.count+1
+			return &proto.BalanceMove{
+				PartitionID: dp.PartitionID,
+				VolName:     dp.VolName,
+				Source:      source.addr,
+				Target:      target.addr,
+				Size:        size,
+				Reason:      reason,
+			}
+		}
+	}
+	return nil
+}
+
+// chooseTarget chooses the least loaded data node the replica of the partition on the source can
+// be moved to.
+func (p *balancePlanner) chooseTarget(source *balanceNode, dp *DataPartition, size uint64, byUsage bool) *balanceNode {
+	targets := append([]*balanceNode{}, p.nodes...)
+	sort.Slice(targets, func(i, j int) bool {
+		if byUsage {
+			return targets[i].usage() < targets[j].usage()
+		}
+		return targets[i].count < targets[j].count
+	})
+	dp.RLock()
+	hosts := append([]string{}, dp.Hosts...)
+	dp.RUnlock()
+	var policy placementPolicy
+	if p.policy != nil {
+		policy = p.policy(dp)
+	}
+	for _, target := range targets {
+		if !target.writable || p.moved[target.addr] || target.media != source.media || contains(hosts, target.addr) {
+			continue
+		}
+		if byUsage {
+			targetUsage := float64(target.used+size) / float64(target.total)
+			if target.usage() >= p.meanUsage || targetUsage >= float64(source.used-size)/float64(source.total) {
+				continue
+			}
+		} else if float64(target.count) >= p.meanCount || target.count+1 >= source.count-1 {
+			continue
+		}
+		if len(policy) > 0 && p.placement != nil {
+			if policy.check(p.placement(append(removeHost(hosts, source.addr), target.addr))) != nil {
+				continue
+			}
+		}
+		return target
+	}
+	return nil
+}
+
+// balanceNodes returns the loads of the active data nodes.
+func (c *Cluster) balanceNodes() (nodes []*balanceNode) {
+	c.dataNodes.Range(func(addr, value interface{}) bool {
+		dataNode := value.(*DataNode)
+		writable := dataNode.isWriteAble()
+		dataNode.RLock()
+		if dataNode.isActive && dataNode.Total > 0 {
+			nodes = append(nodes, &balanceNode{
+				addr:     dataNode.Addr,
+				media:    dataNode.MediaType,
+				total:    dataNode.Total,
+				used:     dataNode.Used,
+				count:    int(dataNode.DataPartitionCount),
+				writable: writable,
+			})
+		}
+		dataNode.RUnlock()
+		return true
+	})
+	return
+}
+
+// balancePartitions returns the healthy data partitions on each data node, and the number of the
+// data partitions recovering.
+func (c *Cluster) balancePartitions() (partitions map[string][]*DataPartition, recovering int) {
+	partitions = make(map[string][]*DataPartition)
+	for _, vol := range c.copyVols() {
+		if vol.status() == markDelete {
+			continue
+		}
+		vol.dataPartitions.RLock()
+		for _, dp := range vol.dataPartitions.partitionMap {
+			dp.RLock()
+			if dp.isRecover {
+				recovering++
+			} else if !dp.unavailable && !dp.isMigratingToEC() && !dp.releasing && len(dp.Replicas) == int(dp.ReplicaNum) {
+				for _, host := range dp.Hosts {
+					partitions[host] = append(partitions[host], dp)
+				}
+			}
+			dp.RUnlock()
+		}
+		vol.dataPartitions.RUnlock()
+	}
+	return
+}
+
+// planBalance plans the moves of the data partitions, at most the limit of them, or as many as
+// keep the data partitions recovering under the limit of the cluster if the limit is not positive.
+func (c *Cluster) planBalance(limit int) (view *proto.BalanceView) {
+	partitions, recovering := c.balancePartitions()
+	if limit <= 0 {
+		limit = defaultBalanceMaxRecovering - recovering
+	}
+	planner := newBalancePlanner(c.balanceNodes(), partitions)
+	planner.policy = func(dp *DataPartition) placementPolicy {
+		if vol, err := c.getVol(dp.VolName); err == nil {
+			return vol.placementPolicy()
+		}
+		return nil
+	}
+	planner.placement = c.dataPlacementNodes
+	view = &proto.BalanceView{
+		Paused:     c.BalancePaused,
+		Recovering: recovering,
+		MeanUsage:  planner.meanUsage,
+		MeanCount:  planner.meanCount,
+		Moves:      make([]*proto.BalanceMove, 0),
+	}
+	if limit > 0 {
+		view.Moves = append(view.Moves, planner.plan(limit)...)
+	}
+	return
+}
+
+func (c *Cluster) scheduleToBalanceDataPartitions() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() && !c.BalancePaused {
+				c.balanceDataPartitions()
+			}
+			time.Sleep(time.Second * defaultIntervalToBalance)
+		}
+	}()
+}
+
+func (c *Cluster) balanceDataPartitions() {
+	for _, move := range c.planBalance(0).Moves {
+		dp, err := c.getDataPartitionByID(move.PartitionID)
+		if err == nil {
+			err = c.moveDataReplica(dp, move.Source, move.Target)
+		}
+		if err != nil {
+			log.LogWarnf("action[balanceDataPartitions] dp[%v] from[%v] to[%v] err[%v]", move.PartitionID, move.Source, move.Target, err)
+			continue
+		}
+		log.LogInfof("action[balanceDataPartitions] vol[%v] dp[%v] replica moved from %v to %v for %v",
+			move.VolName, move.PartitionID, move.Source, move.Target, move.Reason)
+	}
+}
+
+// moveDataReplica moves the replica of the partition on the addr to the target data node, the same
+// way as it is decommissioned.
+func (c *Cluster) moveDataReplica(dp *DataPartition, addr, target string) (err error) {
+	dp.RLock()
+	replica, _ := dp.getReplica(addr)
+	dp.RUnlock()
+	if err = c.validateDecommissionDataPartition(dp, addr); err != nil {
+		return
+	}
+	if err = c.removeDataReplica(dp, addr, false); err != nil {
+		return
+	}
+	if err = c.addDataReplica(dp, target, false); err != nil {
+		return
+	}
+	dp.Lock()
+	dp.Status = proto.ReadOnly
+	dp.isRecover = true
+	dp.Unlock()
+	c.putBadDataPartitionIDs(replica, addr, dp.PartitionID)
+	return
+}
+
+func (c *Cluster) setBalancePaused(paused bool) (err error) {
+	oldPaused := c.BalancePaused
+	c.BalancePaused = paused
+	if err = c.syncPutCluster(); err != nil {
+		log.LogErrorf("action[setBalancePaused] err[%v]", err)
+		c.BalancePaused = oldPaused
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
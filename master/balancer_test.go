@@ -0,0 +1,67 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+func newTestBalancePartition(id, size uint64, hosts ...string) *DataPartition {
+	dp := newDataPartition(id, uint8(len(hosts)), "vol", 1)
+	dp.Hosts = hosts
+	for _, host := range hosts {
+		dp.Replicas = append(dp.Replicas, &DataReplica{DataReplica: proto.DataReplica{Addr: host, Used: size}})
+	}
+	return dp
+}
+
+func newTestBalancePlanner() *balancePlanner {
+	nodes := []*balanceNode{
+		{addr: "n1", total: 100 * util.GB, used: 80 * util.GB, count: 2, writable: true},
+		{addr: "n2", total: 100 * util.GB, used: 40 * util.GB, count: 2, writable: true},
+		{addr: "n3", total: 100 * util.GB, used: 30 * util.GB, count: 1, writable: true},
+		{addr: "n4", total: 100 * util.GB, used: 10 * util.GB, count: 1, writable: true},
+	}
+	dp1 := newTestBalancePartition(1, 20*util.GB, "n1", "n2", "n3")
+	dp2 := newTestBalancePartition(2, 10*util.GB, "n1", "n2", "n4")
+	partitions := map[string][]*DataPartition{
+		"n1": {dp1, dp2},
+		"n2": {dp1, dp2},
+		"n3": {dp1},
+		"n4": {dp2},
+	}
+	return newBalancePlanner(nodes, partitions)
+}
+
+func TestBalancePlanner(t *testing.T) {
+	moves := newTestBalancePlanner().plan(3)
+	if len(moves) != 1 {
+		t.Fatalf("moves %v, expect one", len(moves))
+	}
+	if move := moves[0]; move.PartitionID != 1 || move.Source != "n1" || move.Target != "n4" || move.Size != 20*util.GB {
+		t.Fatalf("move %+v, expect dp 1 from n1 to n4", move)
+	}
+	if moves = newTestBalancePlanner().plan(0); len(moves) != 0 {
+		t.Fatalf("moves %v over the limit", len(moves))
+	}
+}
+
+func TestBalancePlanner_Placement(t *testing.T) {
+	zones := map[string]string{"n1": "z1", "n2": "z2", "n3": "z3", "n4": "z2"}
+	p := newTestBalancePlanner()
+	p.policy = func(dp *DataPartition) placementPolicy { return newPlacementPolicy(3, 0) }
+	p.placement = func(hosts []string) (nodes []*placementNode) {
+		for _, host := range hosts {
+			nodes = append(nodes, &placementNode{addr: host, zone: zones[host]})
+		}
+		return
+	}
+	if moves := p.plan(3); len(moves) != 0 {
+		t.Fatalf("move %+v violates the placement", moves[0])
+	}
+	zones["n4"] = "z1"
+	if moves := p.plan(3); len(moves) != 1 || moves[0].Target != "n4" {
+		t.Fatalf("moves %v, expect one to n4", len(moves))
+	}
+}
@@ -15,6 +15,7 @@
 package master
 
 import (
+	"crypto/rand"
 	"fmt"
 	"sync"
 	"time"
@@ -24,6 +25,7 @@ import (
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
 )
 
 // Cluster stores all the cluster-level information.
@@ -32,11 +34,13 @@ type Cluster struct {
 	vols                map[string]*Vol
 	dataNodes           sync.Map
 	metaNodes           sync.Map
+	ecNodes             sync.Map
 	dpMutex             sync.Mutex   // data partition mutex
 	volMutex            sync.RWMutex // volume mutex
 	createVolMutex      sync.RWMutex // create volume mutex
 	mnMutex             sync.RWMutex // meta node mutex
 	dnMutex             sync.RWMutex // data node mutex
+	ecnMutex            sync.Mutex   // ec node mutex
 	leaderInfo          *LeaderInfo
 	cfg                 *clusterConfig
 	retainLogs          uint64
@@ -48,9 +52,19 @@ type Cluster struct {
 	BadDataPartitionIds *sync.Map
 	BadMetaPartitionIds *sync.Map
 	DisableAutoAllocate bool
+	BalancePaused       bool
 	fsm                 *MetadataFsm
 	partition           raftstore.Partition
 	MasterSecretKey     []byte
+	rateLimits          []*proto.RateLimitRule // copied on write
+	rateLimitMutex      sync.RWMutex
+	limiter             *ratelimit.Limiter     // limits the API of the master
+	encryptionKeys      []*proto.EncryptionKey // copied on write, ordered by version
+	encryptionKeyMutex  sync.RWMutex
+	keyRotationMutex    sync.Mutex
+	events              *eventBus
+	decommissionJobs    map[uint64]*decommissionJob
+	decommissionJobLock sync.RWMutex
 }
 
 func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition raftstore.Partition, cfg *clusterConfig) (c *Cluster) {
@@ -62,11 +76,14 @@ func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition
 	c.t = newTopology()
 	c.BadDataPartitionIds = new(sync.Map)
 	c.BadMetaPartitionIds = new(sync.Map)
+	c.events = newEventBus(name, defaultEventCapacity)
+	c.decommissionJobs = make(map[uint64]*decommissionJob)
 	c.dataNodeStatInfo = new(nodeStatInfo)
 	c.metaNodeStatInfo = new(nodeStatInfo)
 	c.fsm = fsm
 	c.partition = partition
 	c.idAlloc = newIDAllocator(c.fsm.store, c.partition)
+	c.limiter = ratelimit.NewLimiter(ratelimit.ModuleMaster)
 	return
 }
 
@@ -83,6 +100,11 @@ func (c *Cluster) scheduleTask() {
 	c.scheduleToCheckMetaPartitionRecoveryProgress()
 	c.scheduleToLoadMetaPartitions()
 	c.scheduleToReduceReplicaNum()
+	c.scheduleToMigrateECPartitions()
+	c.scheduleToMigrateColdReplicas()
+	c.scheduleToRepairPlacement()
+	c.scheduleToBalanceDataPartitions()
+	c.scheduleToRunDecommissionJobs()
 }
 
 func (c *Cluster) masterAddr() (addr string) {
@@ -230,6 +252,7 @@ func (c *Cluster) scheduleToCheckHeartbeat() {
 		for {
 			if c.partition != nil && c.partition.IsRaftLeader() {
 				c.checkMetaNodeHeartbeat()
+				c.checkECNodeHeartbeat()
 			}
 			time.Sleep(time.Second * defaultIntervalToCheckHeartbeat)
 		}
@@ -243,10 +266,14 @@ func (c *Cluster) checkLeaderAddr() {
 
 func (c *Cluster) checkDataNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volSnapshots := c.getVolSnapshots()
+	volCompressions := c.getVolCompressions()
 	c.dataNodes.Range(func(addr, dataNode interface{}) bool {
 		node := dataNode.(*DataNode)
-		node.checkLiveness()
-		task := node.createHeartbeatTask(c.masterAddr())
+		if node.checkLiveness() {
+			c.events.publish(proto.EventNodeOffline, node.Addr, "data node reports no heartbeat in %vs", defaultNodeTimeOutSec)
+		}
+		task := node.createHeartbeatTask(c.masterAddr(), c.getRateLimits(), volSnapshots, volCompressions)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -255,10 +282,13 @@ func (c *Cluster) checkDataNodeHeartbeat() {
 
 func (c *Cluster) checkMetaNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volSnapshots := c.getVolSnapshots()
 	c.metaNodes.Range(func(addr, metaNode interface{}) bool {
 		node := metaNode.(*MetaNode)
-		node.checkHeartbeat()
-		task := node.createHeartbeatTask(c.masterAddr())
+		if node.checkHeartbeat() {
+			c.events.publish(proto.EventNodeOffline, node.Addr, "meta node reports no heartbeat in %vs", defaultNodeTimeOutSec)
+		}
+		task := node.createHeartbeatTask(c.masterAddr(), c.getRateLimits(), volSnapshots)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -449,6 +479,15 @@ func (c *Cluster) getVol(volName string) (vol *Vol, err error) {
 	return
 }
 
+// metaStoreMode returns the store mode of the meta partitions of the given volume.
+func (c *Cluster) metaStoreMode(volName string) string {
+	vol, err := c.getVol(volName)
+	if err != nil {
+		return ""
+	}
+	return vol.metaStoreMode
+}
+
 func (c *Cluster) deleteVol(name string) {
 	c.volMutex.Lock()
 	defer c.volMutex.Unlock()
@@ -475,6 +514,7 @@ func (c *Cluster) markDeleteVol(name, authKey string) (err error) {
 		vol.Status = normal
 		return proto.ErrPersistenceByRaft
 	}
+	c.events.publish(proto.EventVolumeDeleted, name, "owner %v", vol.Owner)
 	return
 }
 
@@ -499,7 +539,14 @@ func (c *Cluster) createDataPartition(volName string) (dp *DataPartition, err er
 	vol.createDpMutex.Lock()
 	defer vol.createDpMutex.Unlock()
 	errChannel := make(chan error, vol.dpReplicaNum)
-	if targetHosts, targetPeers, err = c.chooseTargetDataNodes(nil, nil, nil, int(vol.dpReplicaNum)); err != nil {
+	if policy := vol.placementPolicy(); len(policy) > 0 {
+		targetHosts, targetPeers, err = c.choosePlacedDataNodes(policy, vol.hotMedia, nil, nil, int(vol.dpReplicaNum))
+	} else if vol.hotMedia != "" {
+		targetHosts, targetPeers, err = c.chooseDataNodesByMedia(vol.hotMedia, nil, int(vol.dpReplicaNum))
+	} else {
+		targetHosts, targetPeers, err = c.chooseTargetDataNodes(nil, nil, nil, int(vol.dpReplicaNum))
+	}
+	if err != nil {
 		goto errHandler
 	}
 	if partitionID, err = c.idAlloc.allocateDataPartitionID(); err != nil {
@@ -580,7 +627,7 @@ func (c *Cluster) syncCreateDataPartitionToDataNode(host string, size uint64, dp
 func (c *Cluster) syncCreateMetaPartitionToMetaNode(host string, mp *MetaPartition) (err error) {
 	hosts := make([]string, 0)
 	hosts = append(hosts, host)
-	tasks := mp.buildNewMetaPartitionTasks(hosts, mp.Peers, mp.volName)
+	tasks := mp.buildNewMetaPartitionTasks(hosts, mp.Peers, mp.volName, c.metaStoreMode(mp.volName))
 	metaNode, err := c.metaNode(host)
 	if err != nil {
 		return
@@ -725,23 +772,33 @@ func (c *Cluster) decommissionDataPartition(offlineAddr string, dp *DataPartitio
 	if cell, err = c.t.getCell(dataNode); err != nil {
 		goto errHandler
 	}
-	if targetHosts, _, err = cell.getAvailDataNodeHosts(dp.Hosts, 1); err != nil {
-		if ns, err = c.t.getNodeSet(dataNode.NodeSetID); err != nil {
-			goto errHandler
-		}
-		// select data nodes from the other cell in same node set
-		if targetHosts, _, err = ns.getAvailDataNodeHosts(cell, dp.Hosts, 1); err != nil {
-			// select data nodes from the other node set
-			if targetHosts, _, err = c.chooseTargetDataNodes(ns, cell, dp.Hosts, 1); err != nil {
+	// keep the replicas spread as the placement policy of the volume requires
+	if targetHosts, err = c.chooseDataReplacement(dp, offlineAddr, dataNode.MediaType); err != nil {
+		goto errHandler
+	}
+	// keep the replica on the same media if possible, so that the tiering of the partition is kept
+	if len(targetHosts) == 0 && dataNode.MediaType != "" {
+		targetHosts, _, err = c.chooseDataNodesByMedia(dataNode.MediaType, dp.Hosts, 1)
+	}
+	if len(targetHosts) == 0 {
+		if targetHosts, _, err = cell.getAvailDataNodeHosts(dp.Hosts, 1); err != nil {
+			if ns, err = c.t.getNodeSet(dataNode.NodeSetID); err != nil {
 				goto errHandler
 			}
+			// select data nodes from the other cell in same node set
+			if targetHosts, _, err = ns.getAvailDataNodeHosts(cell, dp.Hosts, 1); err != nil {
+				// select data nodes from the other node set
+				if targetHosts, _, err = c.chooseTargetDataNodes(ns, cell, dp.Hosts, 1); err != nil {
+					goto errHandler
+				}
+			}
 		}
 	}
 	if err = c.removeDataReplica(dp, offlineAddr, false); err != nil {
 		goto errHandler
 	}
 	newAddr = targetHosts[0]
-	if err = c.addDataReplica(dp, newAddr); err != nil {
+	if err = c.addDataReplica(dp, newAddr, false); err != nil {
 		goto errHandler
 	}
 	dp.Status = proto.ReadOnly
@@ -784,7 +841,9 @@ func (c *Cluster) validateDecommissionDataPartition(dp *DataPartition, offlineAd
 	return
 }
 
-func (c *Cluster) addDataReplica(dp *DataPartition, addr string) (err error) {
+// addDataReplica adds the replica on the data node to the data partition. A learner replica
+// catches up with the raft log without voting or counting in the quorum, until it is promoted.
+func (c *Cluster) addDataReplica(dp *DataPartition, addr string, learner bool) (err error) {
 	defer func() {
 		if err != nil {
 			log.LogErrorf("action[addDataReplica],vol[%v],data partition[%v],err[%v]", dp.VolName, dp.PartitionID, err)
@@ -794,7 +853,7 @@ func (c *Cluster) addDataReplica(dp *DataPartition, addr string) (err error) {
 	if err != nil {
 		return
 	}
-	addPeer := proto.Peer{ID: dataNode.ID, Addr: addr}
+	addPeer := proto.Peer{ID: dataNode.ID, Addr: addr, Learner: learner}
 	if err = c.addDataPartitionRaftMember(dp, addPeer); err != nil {
 		return
 	}
@@ -805,6 +864,48 @@ func (c *Cluster) addDataReplica(dp *DataPartition, addr string) (err error) {
 	return
 }
 
+// promoteDataReplica promotes the learner replica on the data node to a voter, which is rejected
+// by the leader until the learner has caught up with the raft log.
+func (c *Cluster) promoteDataReplica(dp *DataPartition, addr string) (err error) {
+	defer func() {
+		if err != nil {
+			log.LogErrorf("action[promoteDataReplica],vol[%v],data partition[%v],err[%v]", dp.VolName, dp.PartitionID, err)
+		}
+	}()
+	dp.Lock()
+	defer dp.Unlock()
+	index := -1
+	for i, peer := range dp.Peers {
+		if peer.Addr == addr {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		err = fmt.Errorf("vol[%v],data partition[%v] has no host[%v]", dp.VolName, dp.PartitionID, addr)
+		return
+	}
+	if !dp.Peers[index].Learner {
+		return
+	}
+	task, err := dp.createTaskToPromoteRaftLearner(dp.Peers[index])
+	if err != nil {
+		return
+	}
+	leaderDataNode, err := c.dataNode(task.OperatorAddr)
+	if err != nil {
+		return
+	}
+	if _, err = leaderDataNode.TaskManager.syncSendAdminTask(task); err != nil {
+		return
+	}
+	newPeers := make([]proto.Peer, len(dp.Peers))
+	copy(newPeers, dp.Peers)
+	newPeers[index].Learner = false
+	err = dp.update("promoteDataReplica", dp.VolName, newPeers, dp.Hosts, c)
+	return
+}
+
 func (c *Cluster) buildAddDataPartitionRaftMemberTaskAndSyncSendTask(dp *DataPartition, addPeer proto.Peer, leaderAddr string) (resp *proto.Packet, err error) {
 	defer func() {
 		var resultCode uint8
@@ -1136,7 +1237,7 @@ errHandler:
 
 // Create a new volume.
 // By default we create 3 meta partitions and 10 data partitions during initialization.
-func (c *Cluster) createVol(name, owner string, mpCount, dpReplicaNum, size, capacity int, followerRead, authenticate bool) (vol *Vol, err error) {
+func (c *Cluster) createVol(name, owner string, mpCount, dpReplicaNum, size, capacity int, followerRead, authenticate bool, metaStoreMode string) (vol *Vol, err error) {
 	var (
 		dataPartitionSize       uint64
 		readWriteDataPartitions int
@@ -1146,7 +1247,7 @@ func (c *Cluster) createVol(name, owner string, mpCount, dpReplicaNum, size, cap
 	} else {
 		dataPartitionSize = uint64(size) * util.GB
 	}
-	if vol, err = c.doCreateVol(name, owner, dataPartitionSize, uint64(capacity), dpReplicaNum, followerRead, authenticate); err != nil {
+	if vol, err = c.doCreateVol(name, owner, dataPartitionSize, uint64(capacity), dpReplicaNum, followerRead, authenticate, metaStoreMode); err != nil {
 		goto errHandler
 	}
 	if err = vol.initMetaPartitions(c, mpCount); err != nil {
@@ -1165,6 +1266,7 @@ func (c *Cluster) createVol(name, owner string, mpCount, dpReplicaNum, size, cap
 	vol.dataPartitions.readableAndWritableCnt = readWriteDataPartitions
 	vol.updateViewCache(c)
 	log.LogInfof("action[createVol] vol[%v],readableAndWritableCnt[%v]", name, readWriteDataPartitions)
+	c.events.publish(proto.EventVolumeCreated, name, "owner %v, capacity %vGB", owner, capacity)
 	return
 
 errHandler:
@@ -1174,7 +1276,7 @@ errHandler:
 	return
 }
 
-func (c *Cluster) doCreateVol(name, owner string, dpSize, capacity uint64, dpReplicaNum int, followerRead, authenticate bool) (vol *Vol, err error) {
+func (c *Cluster) doCreateVol(name, owner string, dpSize, capacity uint64, dpReplicaNum int, followerRead, authenticate bool, metaStoreMode string) (vol *Vol, err error) {
 	var id uint64
 	c.createVolMutex.Lock()
 	defer c.createVolMutex.Unlock()
@@ -1187,6 +1289,7 @@ func (c *Cluster) doCreateVol(name, owner string, dpSize, capacity uint64, dpRep
 		goto errHandler
 	}
 	vol = newVol(id, name, owner, dpSize, capacity, uint8(dpReplicaNum), defaultReplicaNum, followerRead, authenticate)
+	vol.metaStoreMode = metaStoreMode
 	// refresh oss secure
 	vol.refreshOSSSecure()
 	if err = c.syncAddVol(vol); err != nil {
@@ -1307,6 +1410,30 @@ func (c *Cluster) allMetaNodes() (metaNodes []proto.NodeView) {
 	return
 }
 
+// minClientVersion returns the greatest minimum client version reported by the nodes, so that
+// the clients too old for any of the nodes refuse to start.
+func (c *Cluster) minClientVersion() (version uint32) {
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		metaNode := node.(*MetaNode)
+		metaNode.RLock()
+		if metaNode.MinClientVersion > version {
+			version = metaNode.MinClientVersion
+		}
+		metaNode.RUnlock()
+		return true
+	})
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		if dataNode.MinClientVersion > version {
+			version = dataNode.MinClientVersion
+		}
+		dataNode.RUnlock()
+		return true
+	})
+	return
+}
+
 func (c *Cluster) allVolNames() (vols []string) {
 	vols = make([]string, 0)
 	c.volMutex.RLock()
@@ -1373,12 +1500,481 @@ func (c *Cluster) setDisableAutoAllocate(disableAutoAllocate bool) (err error) {
 	return
 }
 
+func (c *Cluster) getRateLimits() []*proto.RateLimitRule {
+	c.rateLimitMutex.RLock()
+	defer c.rateLimitMutex.RUnlock()
+	return c.rateLimits
+}
+
+func (c *Cluster) updateRateLimits(rules []*proto.RateLimitRule) {
+	c.rateLimitMutex.Lock()
+	c.rateLimits = rules
+	c.rateLimitMutex.Unlock()
+	c.limiter.Update(rules)
+}
+
+// setRateLimit adds the rule, or replaces the rule of the same target, or removes it if neither the
+// ops nor the bytes are limited. The rules are pushed to the nodes by the next heartbeats.
+func (c *Cluster) setRateLimit(rule *proto.RateLimitRule) (err error) {
+	oldRules := c.getRateLimits()
+	newRules := make([]*proto.RateLimitRule, 0, len(oldRules)+1)
+	for _, r := range oldRules {
+		if !ratelimit.SameTarget(r, rule) {
+			newRules = append(newRules, r)
+		}
+	}
+	if rule.Rate > 0 || rule.Bytes > 0 {
+		newRules = append(newRules, rule)
+	}
+	c.updateRateLimits(newRules)
+	if err = c.syncPutCluster(); err != nil {
+		log.LogErrorf("action[setRateLimit] err[%v]", err)
+		c.updateRateLimits(oldRules)
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+// setVolLifecycle replaces the lifecycle rules of the volume, which are removed if there is none.
+func (c *Cluster) setVolLifecycle(name, authKey string, rules []*proto.LifecycleRule) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	if err = checkLifecycleRules(rules); err != nil {
+		return
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldRules := vol.lifecycleRules
+	vol.lifecycleRules = rules
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolLifecycle] vol[%v] err[%v]", name, err)
+		vol.lifecycleRules = oldRules
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+// setVolPolicy replaces the bucket policy of the volume, which is removed if it is empty. The
+// policy is validated by the object nodes, and only kept by the master.
+func (c *Cluster) setVolPolicy(name, authKey, policy string) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldPolicy := vol.bucketPolicy
+	vol.bucketPolicy = policy
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolPolicy] vol[%v] err[%v]", name, err)
+		vol.bucketPolicy = oldPolicy
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+func (c *Cluster) getVolPolicy(vol *Vol) *proto.BucketPolicy {
+	vol.RLock()
+	defer vol.RUnlock()
+	return &proto.BucketPolicy{VolName: vol.Name, Policy: vol.bucketPolicy}
+}
+
+func (c *Cluster) getVolLifecycle(vol *Vol) *proto.LifecycleConfiguration {
+	vol.RLock()
+	defer vol.RUnlock()
+	return &proto.LifecycleConfiguration{VolName: vol.Name, Rules: vol.lifecycleRules}
+}
+
+// listVolLifecycles returns the lifecycle configurations of the volumes which have rules.
+func (c *Cluster) listVolLifecycles() (lcs []*proto.LifecycleConfiguration) {
+	lcs = make([]*proto.LifecycleConfiguration, 0)
+	for _, vol := range c.allVols() {
+		if lc := c.getVolLifecycle(vol); len(lc.Rules) > 0 {
+			lcs = append(lcs, lc)
+		}
+	}
+	return
+}
+
+func checkLifecycleRules(rules []*proto.LifecycleRule) (err error) {
+	if len(rules) > maxLifecycleRules {
+		return fmt.Errorf("more than %v lifecycle rules", maxLifecycleRules)
+	}
+	ids := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.ID == "" || len(rule.ID) > maxLifecycleRuleIDLength {
+			return fmt.Errorf("invalid lifecycle rule id[%v]", rule.ID)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("duplicate lifecycle rule id[%v]", rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.ExpirationDays < 0 || rule.AbortIncompleteMultipartUploadDays < 0 ||
+			rule.ExpirationDays == 0 && rule.AbortIncompleteMultipartUploadDays == 0 {
+			return fmt.Errorf("lifecycle rule[%v] has no valid days", rule.ID)
+		}
+	}
+	return
+}
+
+// setVolQuota sets the quota of the root inode, whose limits are updated if it has been set,
+// or a new quota ID is assigned to it.
+func (c *Cluster) setVolQuota(name, authKey string, quota *proto.QuotaInfo) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	if quota.RootInode == 0 || quota.MaxFiles == 0 && quota.MaxBytes == 0 {
+		return fmt.Errorf("quota of path[%v] has no root inode or limits", quota.Path)
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldQuotas, oldMaxQuotaID := vol.quotas, vol.maxQuotaID
+	quotas := make([]*proto.QuotaInfo, 0, len(oldQuotas)+1)
+	for _, q := range oldQuotas {
+		if q.RootInode == quota.RootInode {
+			quota.QuotaID = q.QuotaID
+			continue
+		}
+		quotas = append(quotas, q)
+	}
+	if quota.QuotaID == 0 {
+		if len(quotas) >= maxVolQuotas {
+			return fmt.Errorf("more than %v quotas", maxVolQuotas)
+		}
+		vol.maxQuotaID++
+		quota.QuotaID = vol.maxQuotaID
+	}
+	vol.quotas = append(quotas, quota)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolQuota] vol[%v] err[%v]", name, err)
+		vol.quotas, vol.maxQuotaID = oldQuotas, oldMaxQuotaID
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+func (c *Cluster) deleteVolQuota(name, authKey string, quotaID uint32) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldQuotas := vol.quotas
+	quotas := make([]*proto.QuotaInfo, 0, len(oldQuotas))
+	for _, q := range oldQuotas {
+		if q.QuotaID != quotaID {
+			quotas = append(quotas, q)
+		}
+	}
+	if len(quotas) == len(oldQuotas) {
+		return fmt.Errorf("quota[%v] not exists", quotaID)
+	}
+	vol.quotas = quotas
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[deleteVolQuota] vol[%v] err[%v]", name, err)
+		vol.quotas = oldQuotas
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+func (c *Cluster) setVolTrash(name, authKey string, retention uint32) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldRetention := vol.trashRetention
+	vol.trashRetention = retention
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolTrash] vol[%v] err[%v]", name, err)
+		vol.trashRetention = oldRetention
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+func (c *Cluster) setVolCompression(name, authKey, compression string) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	if compression == proto.ExtentCompressionNone {
+		compression = ""
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldCompression := vol.compression
+	vol.compression = compression
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolCompression] vol[%v] err[%v]", name, err)
+		vol.compression = oldCompression
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+// createVolSnapshot creates a snapshot of the volume, which is taken by the data and the meta
+// partitions through the heartbeats. Only one snapshot is being taken at a time.
+func (c *Cluster) createVolSnapshot(name, authKey, snapName string) (snapshot *proto.VolSnapshot, err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return nil, proto.ErrVolNotExists
+	}
+	if vol.metaStoreMode == proto.StoreModeRocksDB {
+		return nil, fmt.Errorf("snapshots are not supported in the meta store mode[%v]", vol.metaStoreMode)
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return nil, proto.ErrVolAuthKeyNotMatch
+	}
+	oldSnapshots, oldMaxSnapshotID := vol.snapshots, vol.maxSnapshotID
+	for _, s := range oldSnapshots {
+		if s.Name == snapName {
+			return nil, fmt.Errorf("snapshot[%v] exists", snapName)
+		}
+		if s.Status != proto.VolSnapshotReady {
+			return nil, fmt.Errorf("snapshot[%v] is being taken", s.Name)
+		}
+	}
+	if len(oldSnapshots) >= maxVolSnapshots {
+		return nil, fmt.Errorf("more than %v snapshots", maxVolSnapshots)
+	}
+	// the extents moved out of the releasing partitions would be missed by the snapshot
+	if releasing := vol.releasingDataPartitions(); len(releasing) > 0 {
+		return nil, fmt.Errorf("data partitions%v are being released", releasing)
+	}
+	vol.maxSnapshotID++
+	snapshot = &proto.VolSnapshot{
+		ID:         vol.maxSnapshotID,
+		Name:       snapName,
+		CreateTime: time.Now().Unix(),
+		Status:     proto.VolSnapshotFreezingData,
+	}
+	vol.snapshots = append(append(make([]*proto.VolSnapshot, 0, len(oldSnapshots)+1), oldSnapshots...), snapshot)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[createVolSnapshot] vol[%v] err[%v]", name, err)
+		vol.snapshots, vol.maxSnapshotID = oldSnapshots, oldMaxSnapshotID
+		return nil, proto.ErrPersistenceByRaft
+	}
+	return
+}
+
+// deleteVolSnapshot deletes the snapshot of the volume, whose extents are released by the meta
+// partitions unless they are held by the volume or the other snapshots.
+func (c *Cluster) deleteVolSnapshot(name, authKey, snapName string) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldSnapshots := vol.snapshots
+	snapshots := make([]*proto.VolSnapshot, 0, len(oldSnapshots))
+	for _, s := range oldSnapshots {
+		if s.Name != snapName {
+			snapshots = append(snapshots, s)
+		}
+	}
+	if len(snapshots) == len(oldSnapshots) {
+		return fmt.Errorf("snapshot[%v] not exists", snapName)
+	}
+	vol.snapshots = snapshots
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[deleteVolSnapshot] vol[%v] err[%v]", name, err)
+		vol.snapshots = oldSnapshots
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+// getVolSnapshots returns the snapshots of the volumes pushed to the nodes in the heartbeats,
+// which is never nil so that the nodes tell it from the one of the masters without snapshots.
+func (c *Cluster) getVolSnapshots() map[string][]*proto.VolSnapshot {
+	volSnapshots := make(map[string][]*proto.VolSnapshot)
+	for _, vol := range c.copyVols() {
+		vol.RLock()
+		if len(vol.snapshots) > 0 {
+			volSnapshots[vol.Name] = vol.snapshots
+		}
+		vol.RUnlock()
+	}
+	return volSnapshots
+}
+
+// getVolCompressions returns the compressions of the volumes pushed to the data nodes in the heartbeats.
+func (c *Cluster) getVolCompressions() map[string]string {
+	volCompressions := make(map[string]string)
+	for _, vol := range c.copyVols() {
+		vol.RLock()
+		if vol.compression != "" {
+			volCompressions[vol.Name] = vol.compression
+		}
+		vol.RUnlock()
+	}
+	return volCompressions
+}
+
+// reverseLookupInode returns the link count of the inode and the dentries referring to it, which
+// are looked up in all the meta partitions of the volume.
+func (c *Cluster) reverseLookupInode(name string, ino uint64) (links *proto.InodeLinks, err error) {
+	vol, err := c.getVol(name)
+	if err != nil {
+		return nil, proto.ErrVolNotExists
+	}
+	var owner *MetaPartition
+	mps := vol.cloneMetaPartitionMap()
+	for _, mp := range mps {
+		if ino >= mp.Start && ino <= mp.End {
+			owner = mp
+			break
+		}
+	}
+	if owner == nil {
+		return nil, proto.ErrMetaPartitionNotExists
+	}
+	inodeResp := &proto.InodeGetResponse{}
+	inodeReq := &proto.InodeGetRequest{VolName: name, PartitionID: owner.PartitionID, Inode: ino}
+	if err = owner.sendToLeader(proto.OpMetaInodeGet, inodeReq, inodeResp); err != nil {
+		return nil, fmt.Errorf("get inode[%v] from meta partition[%v] failed: %v", ino, owner.PartitionID, err)
+	}
+	links = &proto.InodeLinks{Inode: ino, Nlink: inodeResp.Info.Nlink}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errChan = make(chan error, len(mps))
+	)
+	for _, mp := range mps {
+		wg.Add(1)
+		go func(mp *MetaPartition) {
+			defer wg.Done()
+			resp := &proto.ReverseLookupResponse{}
+			req := &proto.ReverseLookupRequest{VolName: name, PartitionID: mp.PartitionID, Inode: ino}
+			if err := mp.sendToLeader(proto.OpMetaReverseLookup, req, resp); err != nil {
+				errChan <- fmt.Errorf("reverse lookup in meta partition[%v] failed: %v", mp.PartitionID, err)
+				return
+			}
+			mu.Lock()
+			links.Links = append(links.Links, resp.Links...)
+			mu.Unlock()
+		}(mp)
+	}
+	wg.Wait()
+	select {
+	case err = <-errChan:
+		return nil, err
+	default:
+	}
+	return links, nil
+}
+
 func (c *Cluster) clearVols() {
 	c.volMutex.Lock()
 	defer c.volMutex.Unlock()
 	c.vols = make(map[string]*Vol, 0)
 }
 
+func (c *Cluster) getEncryptionKeys() []*proto.EncryptionKey {
+	c.encryptionKeyMutex.RLock()
+	defer c.encryptionKeyMutex.RUnlock()
+	return c.encryptionKeys
+}
+
+func (c *Cluster) updateEncryptionKeys(keys []*proto.EncryptionKey) {
+	c.encryptionKeyMutex.Lock()
+	c.encryptionKeys = keys
+	c.encryptionKeyMutex.Unlock()
+}
+
+// getEncryptionKey returns the encryption key of the version, or the latest one if the version
+// is 0, which is created if the cluster has none yet.
+func (c *Cluster) getEncryptionKey(version uint32) (key *proto.EncryptionKey, err error) {
+	keys := c.getEncryptionKeys()
+	if version == 0 {
+		if len(keys) > 0 {
+			return keys[len(keys)-1], nil
+		}
+		c.keyRotationMutex.Lock()
+		defer c.keyRotationMutex.Unlock()
+		if keys = c.getEncryptionKeys(); len(keys) > 0 {
+			return keys[len(keys)-1], nil
+		}
+		return c.createEncryptionKey()
+	}
+	for _, key = range keys {
+		if key.Version == version {
+			return
+		}
+	}
+	return nil, proto.ErrKeyNotExists
+}
+
+// rotateEncryptionKey creates a new encryption key, which encrypts the data keys from now on.
+// The old keys are kept to decrypt the data keys encrypted by them.
+func (c *Cluster) rotateEncryptionKey() (key *proto.EncryptionKey, err error) {
+	c.keyRotationMutex.Lock()
+	defer c.keyRotationMutex.Unlock()
+	return c.createEncryptionKey()
+}
+
+func (c *Cluster) createEncryptionKey() (key *proto.EncryptionKey, err error) {
+	oldKeys := c.getEncryptionKeys()
+	key = &proto.EncryptionKey{Version: 1, Key: make([]byte, encryptionKeyLength), CreateTime: time.Now().Unix()}
+	if len(oldKeys) > 0 {
+		key.Version = oldKeys[len(oldKeys)-1].Version + 1
+	}
+	if _, err = rand.Read(key.Key); err != nil {
+		return nil, err
+	}
+	c.updateEncryptionKeys(append(append(make([]*proto.EncryptionKey, 0, len(oldKeys)+1), oldKeys...), key))
+	if err = c.syncPutCluster(); err != nil {
+		log.LogErrorf("action[createEncryptionKey] err[%v]", err)
+		c.updateEncryptionKeys(oldKeys)
+		return nil, proto.ErrPersistenceByRaft
+	}
+	log.LogWarnf("action[createEncryptionKey] encryption key of version[%v] created", key.Version)
+	return
+}
+
 func (c *Cluster) clearTopology() {
 	c.t.clear()
 }
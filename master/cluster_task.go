@@ -113,19 +113,25 @@ func (c *Cluster) decommissionMetaPartition(nodeAddr string, mp *MetaPartition)
 	if metaNode, err = c.metaNode(nodeAddr); err != nil {
 		goto errHandler
 	}
-	if ns, err = c.t.getNodeSet(metaNode.NodeSetID); err != nil {
+	// keep the replicas spread as the placement policy of the volume requires
+	if newPeers, err = c.chooseMetaReplacement(mp, nodeAddr); err != nil {
 		goto errHandler
 	}
-	if _, newPeers, err = ns.getAvailMetaNodeHosts(oldHosts, 1); err != nil {
-		// choose a meta node in other node set
-		if _, newPeers, err = c.chooseTargetMetaHosts(ns, oldHosts, 1); err != nil {
+	if len(newPeers) == 0 {
+		if ns, err = c.t.getNodeSet(metaNode.NodeSetID); err != nil {
 			goto errHandler
 		}
+		if _, newPeers, err = ns.getAvailMetaNodeHosts(oldHosts, 1); err != nil {
+			// choose a meta node in other node set
+			if _, newPeers, err = c.chooseTargetMetaHosts(ns, oldHosts, 1); err != nil {
+				goto errHandler
+			}
+		}
 	}
 	if err = c.deleteMetaReplica(mp, nodeAddr, false); err != nil {
 		goto errHandler
 	}
-	if err = c.addMetaReplica(mp, newPeers[0].Addr); err != nil {
+	if err = c.addMetaReplica(mp, newPeers[0].Addr, false); err != nil {
 		goto errHandler
 	}
 	mp.IsRecover = true
@@ -259,7 +265,9 @@ func (c *Cluster) removeMetaPartitionRaftMember(partition *MetaPartition, remove
 	return
 }
 
-func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string) (err error) {
+// addMetaReplica adds the replica on the meta node to the meta partition. A learner replica
+// catches up with the raft log without voting or counting in the quorum, until it is promoted.
+func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string, learner bool) (err error) {
 	defer func() {
 		if err != nil {
 			log.LogErrorf("action[addMetaReplica],vol[%v],data partition[%v],err[%v]", partition.volName, partition.PartitionID, err)
@@ -275,7 +283,7 @@ func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string) (err err
 	if err != nil {
 		return
 	}
-	addPeer := proto.Peer{ID: metaNode.ID, Addr: addr}
+	addPeer := proto.Peer{ID: metaNode.ID, Addr: addr, Learner: learner}
 	if err = c.addMetaPartitionRaftMember(partition, addPeer); err != nil {
 		return
 	}
@@ -295,8 +303,54 @@ func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string) (err err
 	return
 }
 
+// promoteMetaReplica promotes the learner replica on the meta node to a voter, which is rejected
+// by the leader until the learner has caught up with the raft log.
+func (c *Cluster) promoteMetaReplica(partition *MetaPartition, addr string) (err error) {
+	defer func() {
+		if err != nil {
+			log.LogErrorf("action[promoteMetaReplica],vol[%v],meta partition[%v],err[%v]", partition.volName, partition.PartitionID, err)
+		}
+	}()
+	partition.Lock()
+	defer partition.Unlock()
+	index := -1
+	for i, peer := range partition.Peers {
+		if peer.Addr == addr {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		err = fmt.Errorf("vol[%v],mp[%v] has no host[%v]", partition.volName, partition.PartitionID, addr)
+		return
+	}
+	if !partition.Peers[index].Learner {
+		return
+	}
+	mr, err := partition.getMetaReplicaLeader()
+	if err != nil {
+		return
+	}
+	t, err := partition.createTaskToPromoteRaftLearner(partition.Peers[index], mr.Addr)
+	if err != nil {
+		return
+	}
+	leaderMetaNode, err := c.metaNode(mr.Addr)
+	if err != nil {
+		return
+	}
+	if _, err = leaderMetaNode.Sender.syncSendAdminTask(t); err != nil {
+		return
+	}
+	newPeers := make([]proto.Peer, len(partition.Peers))
+	copy(newPeers, partition.Peers)
+	newPeers[index].Learner = false
+	err = partition.persistToRocksDB("promoteMetaReplica", partition.volName, partition.Hosts, newPeers, c)
+	return
+}
+
 func (c *Cluster) createMetaReplica(partition *MetaPartition, addPeer proto.Peer) (err error) {
-	task, err := partition.createTaskToCreateReplica(addPeer.Addr)
+	task, err := partition.createTaskToCreateReplica(addPeer.Addr, c.metaStoreMode(partition.volName))
 	if err != nil {
 		return
 	}
@@ -677,6 +731,11 @@ func (c *Cluster) handleDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataN
 		c.t.replaceDataNode(dataNode)
 	}
 
+	for _, disk := range resp.BadDisks {
+		if !contains(dataNode.BadDisks, disk) {
+			c.events.publish(proto.EventDiskFailed, fmt.Sprintf("%s:%s", nodeAddr, disk), "disk reported bad by data node")
+		}
+	}
 	dataNode.updateNodeMetric(resp)
 
 	if err = c.t.putDataNode(dataNode); err != nil {
@@ -37,6 +37,8 @@ const (
 	cfgMetaNodeReservedMem              = "metaNodeReservedMem"
 	heartbeatPortKey                    = "heartbeatPort"
 	replicaPortKey                      = "replicaPort"
+	revocationRefreshInterval           = "revocationRefreshInterval"
+	cfgEventSinks                       = "eventSinks"
 )
 
 //default value
@@ -85,6 +87,7 @@ type clusterConfig struct {
 	peerAddrs                           []string
 	heartbeatPort                       int64
 	replicaPort                         int64
+	eventSinks                          []string
 }
 
 func newClusterConfig() (cfg *clusterConfig) {
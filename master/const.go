@@ -37,6 +37,41 @@ const (
 	replicaNumKey         = "replicaNum"
 	followerReadKey       = "followerRead"
 	authenticateKey       = "authenticate"
+	metaStoreModeKey      = "metaStore"
+	moduleKey             = "module"
+	volKey                = "vol"
+	opKey                 = "op"
+	clientKey             = "client"
+	rateKey               = "rate"
+	burstKey              = "burst"
+	fromKey               = "from"
+	limitKey              = "limit"
+	typeKey               = "type"
+	versionKey            = "version"
+	pathKey               = "path"
+	inodeKey              = "inode"
+	maxFilesKey           = "maxFiles"
+	maxBytesKey           = "maxBytes"
+	quotaIDKey            = "quotaId"
+	retentionKey          = "retention"
+	snapshotKey           = "snapshot"
+	compressionKey        = "compression"
+	ecSchemeKey           = "ecScheme"
+	ecColdAgeKey          = "ecColdAge"
+	hotMediaKey           = "hotMedia"
+	coldMediaKey          = "coldMedia"
+	coldAgeKey            = "coldAge"
+	learnerKey            = "learner"
+	zoneSpreadKey         = "zoneSpread"
+	rackSpreadKey         = "rackSpread"
+	concurrencyKey        = "concurrency"
+	maxRetriesKey         = "maxRetries"
+	bytesKey              = "bytes"
+	accessKeyKey          = "accessKey"
+	permissionKey         = "permission"
+	prefixesKey           = "prefixes"
+	expireKey             = "expire"
+	graceKey              = "grace"
 )
 
 const (
@@ -80,6 +115,31 @@ const (
 	retrySendSyncTaskInternal                    = 3 * time.Second
 	defaultRangeOfCountDifferencesAllowed        = 50
 	defaultMinusOfMaxInodeID                     = 1000
+	maxLifecycleRules                            = 1000
+	encryptionKeyLength                          = 32
+	maxLifecycleRuleIDLength                     = 255
+	maxVolQuotas                                 = 100
+	maxTrashRetention                            = 24 * 365
+	maxVolSnapshots                              = 32
+	maxBucketPolicySize                          = 20 * 1024
+	maxVolSnapshotNameLength                     = 255
+	maxVolAccessKeys                             = 100
+	maxVolAccessKeyPrefixes                      = 10
+	defaultECColdAge                             = 7 * 24 * 60 * 60
+	defaultIntervalToMigrateEC                   = 60
+	ecMigrateTimeout                             = 6 * 60 * 60
+	ecMigrateRetryInterval                       = 60 * 60
+	defaultTierColdAge                           = 30 * 24 * 60 * 60
+	defaultIntervalToMigrateTier                 = 60
+	defaultIntervalToRepairPlacement             = 60
+	defaultIntervalToBalance                     = 5 * 60
+	defaultBalanceUsageDiff                      = 0.1
+	defaultBalanceCountDiff                      = 0.2
+	defaultBalanceMaxRecovering                  = 3
+	defaultIntervalToRunDecommissionJobs         = 10
+	defaultDecommissionConcurrency               = 2
+	defaultDecommissionMaxRetries                = 3
+	decommissionRetryInterval                    = 60
 )
 
 const (
@@ -108,6 +168,10 @@ const (
 	opSyncAddNodeSet           uint32 = 0x12
 	opSyncUpdateNodeSet        uint32 = 0x13
 	opSyncBatchPut             uint32 = 0x14
+	opSyncAddECNode            uint32 = 0x15
+	opSyncAddECPartition       uint32 = 0x16
+	opSyncPutDecommissionJob   uint32 = 0x17
+	opSyncDelDecommissionJob   uint32 = 0x18
 )
 
 const (
@@ -119,6 +183,9 @@ const (
 	volAcronym            = "vol"
 	clusterAcronym        = "c"
 	nodeSetAcronym        = "s"
+	ecNodeAcronym         = "en"
+	ecPartitionAcronym    = "ep"
+	decommissionAcronym   = "dj"
 	maxDataPartitionIDKey = keySeparator + "max_dp_id"
 	maxMetaPartitionIDKey = keySeparator + "max_mp_id"
 	maxCommonIDKey        = keySeparator + "max_common_id"
@@ -129,4 +196,7 @@ const (
 	metaPartitionPrefix   = keySeparator + metaPartitionAcronym + keySeparator
 	clusterPrefix         = keySeparator + clusterAcronym + keySeparator
 	nodeSetPrefix         = keySeparator + nodeSetAcronym + keySeparator
+	ecNodePrefix          = keySeparator + ecNodeAcronym + keySeparator
+	ecPartitionPrefix     = keySeparator + ecPartitionAcronym + keySeparator
+	decommissionJobPrefix = keySeparator + decommissionAcronym + keySeparator
 )
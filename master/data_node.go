@@ -43,6 +43,11 @@ type DataNode struct {
 	NodeSetID                 uint64
 	PersistenceDataPartitions []uint64
 	BadDisks                  []string
+	ProtocolVersion           uint32
+	MinClientVersion          uint32
+	MediaType                 string
+	ZoneName                  string `json:"Zone"`
+	RackName                  string `json:"Rack"`
 }
 
 func newDataNode(addr, clusterID string) (dataNode *DataNode) {
@@ -54,10 +59,12 @@ func newDataNode(addr, clusterID string) (dataNode *DataNode) {
 	return
 }
 
-func (dataNode *DataNode) checkLiveness() {
+// checkLiveness returns true if the data node goes offline.
+func (dataNode *DataNode) checkLiveness() (offline bool) {
 	dataNode.Lock()
 	defer dataNode.Unlock()
 	if time.Since(dataNode.ReportTime) > time.Second*time.Duration(defaultNodeTimeOutSec) {
+		offline = dataNode.isActive
 		dataNode.isActive = false
 	}
 
@@ -87,6 +94,11 @@ func (dataNode *DataNode) updateNodeMetric(resp *proto.DataNodeHeartbeatResponse
 	dataNode.DataPartitionCount = resp.CreatedPartitionCnt
 	dataNode.DataPartitionReports = resp.PartitionReports
 	dataNode.BadDisks = resp.BadDisks
+	dataNode.ProtocolVersion = resp.ProtocolVersion
+	dataNode.MinClientVersion = resp.MinClientVersion
+	dataNode.MediaType = resp.MediaType
+	dataNode.ZoneName = resp.ZoneName
+	dataNode.RackName = resp.RackName
 	if dataNode.Total == 0 {
 		dataNode.UsageRatio = 0.0
 	} else {
@@ -134,10 +146,14 @@ func (dataNode *DataNode) clean() {
 	dataNode.TaskManager.exitCh <- struct{}{}
 }
 
-func (dataNode *DataNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (dataNode *DataNode) createHeartbeatTask(masterAddr string, rateLimits []*proto.RateLimitRule,
+	volSnapshots map[string][]*proto.VolSnapshot, volCompressions map[string]string) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:        time.Now().Unix(),
+		MasterAddr:      masterAddr,
+		RateLimits:      rateLimits,
+		VolSnapshots:    volSnapshots,
+		VolCompressions: volCompressions,
 	}
 	task = proto.NewAdminTask(proto.OpDataNodeHeartbeat, dataNode.Addr, request)
 	return
@@ -46,8 +46,13 @@ type DataPartition struct {
 	modifyTime              int64
 	createTime              int64
 	lastWarnTime            int64
+	unavailable             bool
 	FileInCoreMap           map[string]*FileInCore
 	FilesWithMissingReplica map[string]int64 // key: file name, value: last time when a missing replica is found
+	ecMigrateTime           int64            // when the migration to the erasure coded partition started, 0 if not migrating
+	ecCheckTime             int64            // when the partition was last found not cold enough to be migrated
+	tierTarget              string           // the replica being filled by the tiering, until the partition recovers
+	releasing               bool             // read-only until deleted, once the volume is shrunk
 }
 
 func newDataPartition(ID uint64, replicaNum uint8, volName string, volID uint64) (partition *DataPartition) {
@@ -103,7 +108,22 @@ func (partition *DataPartition) createTaskToTryToChangeLeader(addr string) (task
 }
 
 func (partition *DataPartition) createTaskToAddRaftMember(addPeer proto.Peer, leaderAddr string) (task *proto.AdminTask, err error) {
-	task = proto.NewAdminTask(proto.OpAddDataPartitionRaftMember, leaderAddr, newAddDataPartitionRaftMemberRequest(partition.PartitionID, addPeer))
+	if addPeer.Learner {
+		task = proto.NewAdminTask(proto.OpAddDataPartitionRaftLearner, leaderAddr, newAddDataPartitionRaftLearnerRequest(partition.PartitionID, addPeer))
+	} else {
+		task = proto.NewAdminTask(proto.OpAddDataPartitionRaftMember, leaderAddr, newAddDataPartitionRaftMemberRequest(partition.PartitionID, addPeer))
+	}
+	partition.resetTaskID(task)
+	return
+}
+
+func (partition *DataPartition) createTaskToPromoteRaftLearner(learner proto.Peer) (task *proto.AdminTask, err error) {
+	leaderAddr := partition.getLeaderAddr()
+	if leaderAddr == "" {
+		err = proto.ErrNoLeader
+		return
+	}
+	task = proto.NewAdminTask(proto.OpPromoteDataPartitionRaftLearner, leaderAddr, newPromoteDataPartitionRaftLearnerRequest(partition.PartitionID, learner))
 	partition.resetTaskID(task)
 	return
 }
@@ -266,6 +286,9 @@ func (partition *DataPartition) convertToDataPartitionResponse() (dpr *proto.Dat
 	dpr.Hosts = make([]string, len(partition.Hosts))
 	copy(dpr.Hosts, partition.Hosts)
 	dpr.LeaderAddr = partition.getLeaderAddr()
+	if partition.isRecover && partition.tierTarget != "" {
+		dpr.MigratingHosts = []string{partition.tierTarget}
+	}
 	return
 }
 
@@ -522,6 +545,10 @@ func (partition *DataPartition) updateMetric(vr *proto.PartitionReport, dataNode
 	replica.setAlive()
 	replica.IsLeader = vr.IsLeader
 	replica.NeedsToCompare = vr.NeedCompare
+	replica.RaftHealth = vr.RaftHealth
+	replica.frozenSnapshotID = vr.FrozenSnapshotID
+	replica.freezingSnapshotID = vr.FreezingSnapshotID
+	replica.accessTime = vr.AccessTime
 	if replica.DiskPath != vr.DiskPath && vr.DiskPath != "" {
 		oldDiskPath := replica.DiskPath
 		replica.DiskPath = vr.DiskPath
@@ -671,5 +698,6 @@ func (partition *DataPartition) ToProto() *proto.DataPartitionInfo {
 		VolID:                   partition.VolID,
 		FileInCoreMap:           fileInCoreMap,
 		FilesWithMissingReplica: partition.FilesWithMissingReplica,
+		Releasing:               partition.releasing,
 	}
 }
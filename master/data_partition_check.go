@@ -22,10 +22,13 @@ import (
 	"time"
 )
 
-func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dpTimeOutSec int64) {
+// checkStatus returns true if the data partition becomes unavailable, i.e. none of its replicas is alive.
+func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dpTimeOutSec int64) (unavailable bool) {
 	partition.Lock()
 	defer partition.Unlock()
 	liveReplicas := partition.getLiveReplicasFromHosts(dpTimeOutSec)
+	unavailable = len(liveReplicas) == 0 && !partition.unavailable
+	partition.unavailable = len(liveReplicas) == 0
 	if len(partition.Replicas) > len(partition.Hosts) {
 		partition.Status = proto.ReadOnly
 		msg := fmt.Sprintf("action[extractStatus],partitionID:%v has exceed repica, replicaNum:%v  liveReplicas:%v   Status:%v  RocksDBHost:%v ",
@@ -37,7 +40,8 @@ func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dp
 	switch len(liveReplicas) {
 	case (int)(partition.ReplicaNum):
 		partition.Status = proto.ReadOnly
-		if partition.checkReplicaStatusOnLiveNode(liveReplicas) == true && partition.isReplicaSizeAligned() && partition.canWrite() {
+		if partition.checkReplicaStatusOnLiveNode(liveReplicas) == true && partition.isReplicaSizeAligned() && partition.canWrite() &&
+			!partition.isMigratingToEC() && !partition.releasing {
 			partition.Status = proto.ReadWrite
 		}
 	default:
@@ -52,6 +56,7 @@ func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dp
 			partition.lastWarnTime = time.Now().Unix()
 		}
 	}
+	return
 }
 
 func (partition *DataPartition) canWrite() bool {
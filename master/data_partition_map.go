@@ -34,6 +34,7 @@ type DataPartitionMap struct {
 	partitions             []*DataPartition
 	responseCache          []byte
 	volName                string
+	ecPartitions           map[uint64]*ECPartition // the data partitions migrated to the erasure coded partitions
 }
 
 func newDataPartitionMap(volName string) (dpMap *DataPartitionMap) {
@@ -42,6 +43,7 @@ func newDataPartitionMap(volName string) (dpMap *DataPartitionMap) {
 	dpMap.partitions = make([]*DataPartition, 0)
 	dpMap.responseCache = make([]byte, 0)
 	dpMap.volName = volName
+	dpMap.ecPartitions = make(map[uint64]*ECPartition)
 	return
 }
 
@@ -78,6 +80,41 @@ func (dpMap *DataPartitionMap) put(dp *DataPartition) {
 	}
 }
 
+// del removes the data partition, which is replaced by the erasure coded partition.
+func (dpMap *DataPartitionMap) del(dp *DataPartition) {
+	dpMap.Lock()
+	defer dpMap.Unlock()
+	if _, ok := dpMap.partitionMap[dp.PartitionID]; !ok {
+		return
+	}
+	delete(dpMap.partitionMap, dp.PartitionID)
+	for index, partition := range dpMap.partitions {
+		if partition.PartitionID == dp.PartitionID {
+			dpMap.partitions = append(dpMap.partitions[:index:index], dpMap.partitions[index+1:]...)
+			break
+		}
+	}
+}
+
+// putECPartition puts the erasure coded partition, and removes the data partition of the same ID.
+func (dpMap *DataPartitionMap) putECPartition(ep *ECPartition) {
+	if dp, err := dpMap.get(ep.PartitionID); err == nil {
+		dpMap.del(dp)
+	}
+	dpMap.Lock()
+	defer dpMap.Unlock()
+	dpMap.ecPartitions[ep.PartitionID] = ep
+}
+
+func (dpMap *DataPartitionMap) getECPartition(ID uint64) (*ECPartition, error) {
+	dpMap.RLock()
+	defer dpMap.RUnlock()
+	if ep, ok := dpMap.ecPartitions[ID]; ok {
+		return ep, nil
+	}
+	return nil, proto.ErrDataPartitionNotExists
+}
+
 func (dpMap *DataPartitionMap) setReadWriteDataPartitions(readWrites int, clusterName string) {
 	dpMap.Lock()
 	defer dpMap.Unlock()
@@ -137,6 +174,12 @@ func (dpMap *DataPartitionMap) getDataPartitionsView(minPartitionID uint64) (dpR
 		dpResp := dp.convertToDataPartitionResponse()
 		dpResps = append(dpResps, dpResp)
 	}
+	for _, ep := range dpMap.ecPartitions {
+		if ep.PartitionID <= minPartitionID {
+			continue
+		}
+		dpResps = append(dpResps, ep.convertToDataPartitionResponse())
+	}
 
 	return
 }
@@ -89,3 +89,14 @@ func loadDataPartitionTest(dp *DataPartition, t *testing.T) {
 	dp.validateCRC(server.cluster.Name)
 	dp.setToNormal()
 }
+
+func TestDataReplicaFrozen(t *testing.T) {
+	replica := &DataReplica{freezingSnapshotID: 3}
+	if !replica.isFrozen(3, true) || replica.isFrozen(3, false) {
+		t.Fatalf("replica freezing all the extents has not marked them")
+	}
+	replica.frozenSnapshotID, replica.freezingSnapshotID = 3, 0
+	if !replica.isFrozen(3, false) || replica.isFrozen(4, true) {
+		t.Fatalf("unexpected frozen state of the replica")
+	}
+}
@@ -25,6 +25,12 @@ type DataReplica struct {
 	proto.DataReplica
 	dataNode *DataNode
 	loc      uint8
+	// frozenSnapshotID is the latest snapshot of the volume the replica has frozen the extents for.
+	frozenSnapshotID uint32
+	// freezingSnapshotID is the snapshot being taken the replica has frozen all the extents for.
+	freezingSnapshotID uint32
+	// accessTime is the last time the replica was read or written by the clients.
+	accessTime int64
 }
 
 func newDataReplica(dataNode *DataNode) (replica *DataReplica) {
@@ -74,3 +80,12 @@ func (replica *DataReplica) isLocationAvailable() (isAvailable bool) {
 
 	return
 }
+
+// isFrozen tells whether the replica has marked the extents for the snapshot by the watermark,
+// or has frozen all the extents for it if freezing.
+func (replica *DataReplica) isFrozen(snapshotID uint32, freezing bool) bool {
+	if replica.frozenSnapshotID >= snapshotID {
+		return true
+	}
+	return freezing && replica.freezingSnapshotID >= snapshotID
+}
@@ -0,0 +1,516 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// A decommission job drains the partitions off a data node or a meta node, and removes the node from
+// the cluster once all of them are migrated. The leader runs the jobs every 10 seconds: it checks
+// whether the partitions migrating have recovered, and starts migrating the pending ones, at most
+// the concurrency of the job at the same time, and the data partitions no faster than the rate of
+// the job on average. A partition failed to start migrating is retried a minute later, at most the
+// max retries of the job, and the job fails once only the failed partitions are left, which are
+// retried when the job is resumed. Pausing a job stops starting the migrations but not the ones in
+// progress, and cancelling it keeps the node in the cluster. The jobs are persisted on each change,
+// so that a new leader goes on running them.
+
+// decommissionDriver checks and starts the migrations of the partitions of a decommission job.
+type decommissionDriver interface {
+	// check returns whether the partition still has a replica on the node, and whether it is recovering.
+	check(p *proto.DecommissionPartition) (onNode, recovering bool)
+	migrate(p *proto.DecommissionPartition) error
+	// partitions returns the partitions with a replica on the node.
+	partitions() []*proto.DecommissionPartition
+	removeNode() error
+}
+
+// decommissionJob is a decommission job with the bytes it may start migrating, refilled by its rate.
+type decommissionJob struct {
+	sync.Mutex
+	*proto.DecommissionJob
+	allowance  float64
+	refillTime time.Time
+}
+
+func newDecommissionJob(job *proto.DecommissionJob) *decommissionJob {
+	return &decommissionJob{DecommissionJob: job, refillTime: time.Now()}
+}
+
+func copyDecommissionJob(job *proto.DecommissionJob, withPartitions bool) *proto.DecommissionJob {
+	jobCopy := *job
+	jobCopy.Partitions = nil
+	if withPartitions {
+		jobCopy.Partitions = make([]*proto.DecommissionPartition, 0, len(job.Partitions))
+		for _, p := range job.Partitions {
+			partition := *p
+			jobCopy.Partitions = append(jobCopy.Partitions, &partition)
+		}
+	}
+	return &jobCopy
+}
+
+func (dj *decommissionJob) view(withPartitions bool) *proto.DecommissionJob {
+	dj.Lock()
+	defer dj.Unlock()
+	return copyDecommissionJob(dj.DecommissionJob, withPartitions)
+}
+
+// refill adds the bytes the job may migrate since the last refill, at most the bytes of an interval,
+// so that a paused job does not start a burst of migrations when it is resumed.
+func (dj *decommissionJob) refill(now time.Time) {
+	if dj.Rate > 0 {
+		dj.allowance += float64(dj.Rate) * now.Sub(dj.refillTime).Seconds()
+		if max := float64(dj.Rate * defaultIntervalToRunDecommissionJobs); dj.allowance > max {
+			dj.allowance = max
+		}
+	}
+	dj.refillTime = now
+}
+
+// throttled returns whether the job migrated more than its rate allows, so that a partition larger
+// than the bytes of an interval is still migrated, and the next one waits longer.
+func (dj *decommissionJob) throttled() bool {
+	return dj.Rate > 0 && dj.allowance < 0
+}
+
+func (dj *decommissionJob) hasState(state string) bool {
+	for _, p := range dj.Partitions {
+		if p.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the partitions not in the job, and the ones migrated but back on the node, as pending.
+func (dj *decommissionJob) add(partitions []*proto.DecommissionPartition, now time.Time) (added int) {
+	existing := make(map[uint64]*proto.DecommissionPartition, len(dj.Partitions))
+	for _, p := range dj.Partitions {
+		existing[p.PartitionID] = p
+	}
+	for _, p := range partitions {
+		if old, ok := existing[p.PartitionID]; !ok {
+			dj.Partitions = append(dj.Partitions, p)
+		} else if old.State == proto.DecommissionDone {
+			p = old
+		} else {
+			continue
+		}
+		p.State, p.Retries, p.Error, p.UpdateTime = proto.DecommissionPending, 0, "", now.Unix()
+		added++
+	}
+	return
+}
+
+func (dj *decommissionJob) count() {
+	dj.Total, dj.Done, dj.Failed = len(dj.Partitions), 0, 0
+	for _, p := range dj.Partitions {
+		switch p.State {
+		case proto.DecommissionDone:
+			dj.Done++
+		case proto.DecommissionFailed:
+			dj.Failed++
+		}
+	}
+}
+
+// step checks the migrations in progress and starts the pending ones, and returns whether the job
+// has changed.
+func (dj *decommissionJob) step(d decommissionDriver, now time.Time) (changed bool) {
+	dj.Lock()
+	defer dj.Unlock()
+	if dj.Ended() {
+		return
+	}
+	var migrating int
+	for _, p := range dj.Partitions {
+		if p.State != proto.DecommissionMigrating {
+			continue
+		}
+		onNode, recovering := d.check(p)
+		if recovering {
+			migrating++
+			continue
+		}
+		p.State, p.UpdateTime, changed = proto.DecommissionDone, now.Unix(), true
+		// the replica is added back to the node
+		if onNode {
+			p.State = proto.DecommissionPending
+		}
+	}
+	if dj.State == proto.DecommissionRunning {
+		dj.refill(now)
+		for _, p := range dj.Partitions {
+			if migrating >= dj.Concurrency || dj.throttled() {
+				break
+			}
+			if p.State != proto.DecommissionPending || (p.Retries > 0 && now.Unix()-p.UpdateTime < decommissionRetryInterval) {
+				continue
+			}
+			p.UpdateTime, changed = now.Unix(), true
+			if err := d.migrate(p); err != nil {
+				if p.Retries, p.Error = p.Retries+1, err.Error(); p.Retries > dj.MaxRetries {
+					p.State = proto.DecommissionFailed
+				}
+				log.LogWarnf("action[runDecommissionJob] job[%v] partition[%v] off %v retries[%v] err[%v]",
+					dj.ID, p.PartitionID, dj.Addr, p.Retries, err)
+				continue
+			}
+			p.State, p.Error = proto.DecommissionMigrating, ""
+			dj.allowance -= float64(p.Size)
+			migrating++
+		}
+	}
+	if dj.State == proto.DecommissionRunning && migrating == 0 && !dj.hasState(proto.DecommissionPending) {
+		if dj.hasState(proto.DecommissionFailed) {
+			dj.State, changed = proto.DecommissionFailed, true
+		} else if dj.add(d.partitions(), now) > 0 {
+			// the partitions created on the node meanwhile
+			changed = true
+		} else if err := d.removeNode(); err != nil {
+			log.LogWarnf("action[runDecommissionJob] job[%v] remove %v err[%v]", dj.ID, dj.Addr, err)
+		} else {
+			dj.State, changed = proto.DecommissionDone, true
+		}
+	}
+	if changed {
+		dj.count()
+		dj.UpdateTime = now.Unix()
+	}
+	return
+}
+
+// dataDecommissioner migrates the replicas of the data partitions off a data node.
+type dataDecommissioner struct {
+	c    *Cluster
+	addr string
+}
+
+func (d *dataDecommissioner) check(p *proto.DecommissionPartition) (onNode, recovering bool) {
+	dp, err := d.c.getDataPartitionByID(p.PartitionID)
+	if err != nil {
+		return
+	}
+	dp.RLock()
+	defer dp.RUnlock()
+	return dp.hasHost(d.addr), dp.isRecover
+}
+
+func (d *dataDecommissioner) migrate(p *proto.DecommissionPartition) error {
+	dp, err := d.c.getDataPartitionByID(p.PartitionID)
+	if err != nil {
+		// the partition is deleted
+		return nil
+	}
+	return d.c.decommissionDataPartition(d.addr, dp, dataNodeOfflineErr)
+}
+
+func (d *dataDecommissioner) partitions() (partitions []*proto.DecommissionPartition) {
+	for _, vol := range d.c.copyVols() {
+		vol.dataPartitions.RLock()
+		for _, dp := range vol.dataPartitions.partitionMap {
+			dp.RLock()
+			if dp.hasHost(d.addr) {
+				p := &proto.DecommissionPartition{PartitionID: dp.PartitionID, VolName: dp.VolName}
+				if replica, err := dp.getReplica(d.addr); err == nil {
+					p.Size = replica.Used
+				}
+				partitions = append(partitions, p)
+			}
+			dp.RUnlock()
+		}
+		vol.dataPartitions.RUnlock()
+	}
+	sortDecommissionPartitions(partitions)
+	return
+}
+
+func (d *dataDecommissioner) removeNode() (err error) {
+	dataNode, err := d.c.dataNode(d.addr)
+	if err != nil {
+		// the node is removed already
+		return nil
+	}
+	if err = d.c.syncDeleteDataNode(dataNode); err != nil {
+		return
+	}
+	d.c.delDataNodeFromCache(dataNode)
+	return
+}
+
+// metaDecommissioner migrates the replicas of the meta partitions off a meta node.
+type metaDecommissioner struct {
+	c    *Cluster
+	addr string
+}
+
+func (d *metaDecommissioner) check(p *proto.DecommissionPartition) (onNode, recovering bool) {
+	mp, err := d.c.getMetaPartitionByID(p.PartitionID)
+	if err != nil {
+		return
+	}
+	mp.RLock()
+	defer mp.RUnlock()
+	return contains(mp.Hosts, d.addr), mp.IsRecover
+}
+
+func (d *metaDecommissioner) migrate(p *proto.DecommissionPartition) error {
+	mp, err := d.c.getMetaPartitionByID(p.PartitionID)
+	if err != nil {
+		// the partition is deleted
+		return nil
+	}
+	return d.c.decommissionMetaPartition(d.addr, mp)
+}
+
+func (d *metaDecommissioner) partitions() (partitions []*proto.DecommissionPartition) {
+	for _, vol := range d.c.copyVols() {
+		vol.mpsLock.RLock()
+		for _, mp := range vol.MetaPartitions {
+			mp.RLock()
+			if contains(mp.Hosts, d.addr) {
+				partitions = append(partitions, &proto.DecommissionPartition{PartitionID: mp.PartitionID, VolName: mp.volName})
+			}
+			mp.RUnlock()
+		}
+		vol.mpsLock.RUnlock()
+	}
+	sortDecommissionPartitions(partitions)
+	return
+}
+
+func (d *metaDecommissioner) removeNode() (err error) {
+	metaNode, err := d.c.metaNode(d.addr)
+	if err != nil {
+		// the node is removed already
+		return nil
+	}
+	if err = d.c.syncDeleteMetaNode(metaNode); err != nil {
+		return
+	}
+	d.c.deleteMetaNodeFromCache(metaNode)
+	return
+}
+
+func sortDecommissionPartitions(partitions []*proto.DecommissionPartition) {
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].PartitionID < partitions[j].PartitionID
+	})
+}
+
+func (c *Cluster) decommissionDriver(kind, addr string) decommissionDriver {
+	if kind == proto.DecommissionJobMetaNode {
+		return &metaDecommissioner{c: c, addr: addr}
+	}
+	return &dataDecommissioner{c: c, addr: addr}
+}
+
+func (c *Cluster) putDecommissionJob(dj *decommissionJob) {
+	c.decommissionJobLock.Lock()
+	defer c.decommissionJobLock.Unlock()
+	c.decommissionJobs[dj.ID] = dj
+}
+
+func (c *Cluster) getDecommissionJob(id uint64) (dj *decommissionJob, err error) {
+	c.decommissionJobLock.RLock()
+	defer c.decommissionJobLock.RUnlock()
+	dj, ok := c.decommissionJobs[id]
+	if !ok {
+		err = fmt.Errorf("decommission job[%v] not found", id)
+	}
+	return
+}
+
+func (c *Cluster) copyDecommissionJobs() (jobs []*decommissionJob) {
+	c.decommissionJobLock.RLock()
+	defer c.decommissionJobLock.RUnlock()
+	jobs = make([]*decommissionJob, 0, len(c.decommissionJobs))
+	for _, dj := range c.decommissionJobs {
+		jobs = append(jobs, dj)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+	return
+}
+
+func (c *Cluster) clearDecommissionJobs() {
+	c.decommissionJobLock.Lock()
+	defer c.decommissionJobLock.Unlock()
+	c.decommissionJobs = make(map[uint64]*decommissionJob)
+}
+
+// listDecommissionJobs returns the jobs without their partitions.
+func (c *Cluster) listDecommissionJobs() (jobs []*proto.DecommissionJob) {
+	jobs = make([]*proto.DecommissionJob, 0)
+	for _, dj := range c.copyDecommissionJobs() {
+		jobs = append(jobs, dj.view(false))
+	}
+	return
+}
+
+// createDecommissionJob creates a job draining the node, which replaces the ended job of the node.
+func (c *Cluster) createDecommissionJob(kind, addr string, concurrency int, rate uint64, maxRetries int) (job *proto.DecommissionJob, err error) {
+	switch kind {
+	case proto.DecommissionJobDataNode:
+		_, err = c.dataNode(addr)
+	case proto.DecommissionJobMetaNode:
+		_, err = c.metaNode(addr)
+	default:
+		err = fmt.Errorf("invalid kind[%v] of the decommission job", kind)
+	}
+	if err != nil {
+		return
+	}
+	c.decommissionJobLock.Lock()
+	defer c.decommissionJobLock.Unlock()
+	var ended []uint64
+	for id, dj := range c.decommissionJobs {
+		if view := dj.view(false); view.Addr == addr {
+			if !view.Ended() {
+				return nil, fmt.Errorf("decommission job[%v] of %v is %v", id, addr, view.State)
+			}
+			ended = append(ended, id)
+		}
+	}
+	var id uint64
+	if id, err = c.idAlloc.allocateCommonID(); err != nil {
+		return
+	}
+	now := time.Now()
+	dj := newDecommissionJob(&proto.DecommissionJob{
+		ID:          id,
+		Kind:        kind,
+		Addr:        addr,
+		State:       proto.DecommissionRunning,
+		Concurrency: concurrency,
+		Rate:        rate,
+		MaxRetries:  maxRetries,
+		CreateTime:  now.Unix(),
+		UpdateTime:  now.Unix(),
+		Partitions:  make([]*proto.DecommissionPartition, 0),
+	})
+	dj.add(c.decommissionDriver(kind, addr).partitions(), now)
+	dj.count()
+	if err = c.syncPutDecommissionJob(dj.DecommissionJob); err != nil {
+		log.LogErrorf("action[createDecommissionJob] addr[%v] err[%v]", addr, err)
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.decommissionJobs[id] = dj
+	for _, endedID := range ended {
+		if err = c.syncDeleteDecommissionJob(endedID); err != nil {
+			log.LogWarnf("action[createDecommissionJob] delete job[%v] err[%v]", endedID, err)
+			continue
+		}
+		delete(c.decommissionJobs, endedID)
+	}
+	log.LogWarnf("action[createDecommissionJob] job[%v] drains %v partitions off %v %v", id, dj.Total, kind, addr)
+	return dj.view(true), nil
+}
+
+// updateDecommissionJob updates a copy of the job, which replaces the job once it is persisted.
+func (c *Cluster) updateDecommissionJob(id uint64, update func(job *proto.DecommissionJob) error) (err error) {
+	dj, err := c.getDecommissionJob(id)
+	if err != nil {
+		return
+	}
+	dj.Lock()
+	defer dj.Unlock()
+	job := copyDecommissionJob(dj.DecommissionJob, true)
+	if err = update(job); err != nil {
+		return
+	}
+	job.UpdateTime = time.Now().Unix()
+	if err = c.syncPutDecommissionJob(job); err != nil {
+		log.LogErrorf("action[updateDecommissionJob] job[%v] err[%v]", id, err)
+		return proto.ErrPersistenceByRaft
+	}
+	dj.DecommissionJob = job
+	return
+}
+
+func (c *Cluster) pauseDecommissionJob(id uint64) error {
+	return c.updateDecommissionJob(id, func(job *proto.DecommissionJob) error {
+		if job.State != proto.DecommissionRunning {
+			return fmt.Errorf("decommission job[%v] is %v", id, job.State)
+		}
+		job.State = proto.DecommissionPaused
+		return nil
+	})
+}
+
+// resumeDecommissionJob resumes a paused or failed job, and retries the failed partitions.
+func (c *Cluster) resumeDecommissionJob(id uint64) error {
+	return c.updateDecommissionJob(id, func(job *proto.DecommissionJob) error {
+		if job.State != proto.DecommissionPaused && job.State != proto.DecommissionFailed {
+			return fmt.Errorf("decommission job[%v] is %v", id, job.State)
+		}
+		job.State, job.Failed = proto.DecommissionRunning, 0
+		for _, p := range job.Partitions {
+			if p.State == proto.DecommissionFailed {
+				p.State, p.Retries, p.Error = proto.DecommissionPending, 0, ""
+			}
+		}
+		return nil
+	})
+}
+
+// cancelDecommissionJob cancels the job and keeps the node in the cluster. The migrations in
+// progress are not stopped.
+func (c *Cluster) cancelDecommissionJob(id uint64) error {
+	return c.updateDecommissionJob(id, func(job *proto.DecommissionJob) error {
+		if job.Ended() {
+			return fmt.Errorf("decommission job[%v] is %v", id, job.State)
+		}
+		job.State = proto.DecommissionCancelled
+		return nil
+	})
+}
+
+func (c *Cluster) scheduleToRunDecommissionJobs() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.runDecommissionJobs()
+			}
+			time.Sleep(time.Second * defaultIntervalToRunDecommissionJobs)
+		}
+	}()
+}
+
+func (c *Cluster) runDecommissionJobs() {
+	for _, dj := range c.copyDecommissionJobs() {
+		view := dj.view(false)
+		if view.Ended() || !dj.step(c.decommissionDriver(view.Kind, view.Addr), time.Now()) {
+			continue
+		}
+		job := dj.view(true)
+		if err := c.syncPutDecommissionJob(job); err != nil {
+			log.LogErrorf("action[runDecommissionJobs] job[%v] err[%v]", job.ID, err)
+		}
+		if job.State == proto.DecommissionDone || job.State == proto.DecommissionFailed {
+			c.events.publish(proto.EventDecommissionJobEnded, job.Addr, "decommission job %v is %v, %v of %v partitions migrated",
+				job.ID, job.State, job.Done, job.Total)
+		}
+	}
+}
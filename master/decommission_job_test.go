@@ -0,0 +1,198 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// fakeDecommissioner migrates the partitions instantly, and keeps them recovering until recovered.
+type fakeDecommissioner struct {
+	onNode     map[uint64]bool
+	recovering map[uint64]bool
+	failing    map[uint64]bool
+	removed    bool
+}
+
+func newFakeDecommissioner(ids ...uint64) *fakeDecommissioner {
+	d := &fakeDecommissioner{onNode: make(map[uint64]bool), recovering: make(map[uint64]bool), failing: make(map[uint64]bool)}
+	for _, id := range ids {
+		d.onNode[id] = true
+	}
+	return d
+}
+
+func (d *fakeDecommissioner) check(p *proto.DecommissionPartition) (onNode, recovering bool) {
+	return d.onNode[p.PartitionID], d.recovering[p.PartitionID]
+}
+
+func (d *fakeDecommissioner) migrate(p *proto.DecommissionPartition) error {
+	if d.failing[p.PartitionID] {
+		return fmt.Errorf("partition %v is missing a replica", p.PartitionID)
+	}
+	delete(d.onNode, p.PartitionID)
+	d.recovering[p.PartitionID] = true
+	return nil
+}
+
+func (d *fakeDecommissioner) partitions() (partitions []*proto.DecommissionPartition) {
+	for id := range d.onNode {
+		partitions = append(partitions, &proto.DecommissionPartition{PartitionID: id, Size: 100})
+	}
+	sortDecommissionPartitions(partitions)
+	return
+}
+
+func (d *fakeDecommissioner) removeNode() error {
+	d.removed = true
+	return nil
+}
+
+func (d *fakeDecommissioner) recoverAll() {
+	d.recovering = make(map[uint64]bool)
+}
+
+func newTestDecommissionJob(d *fakeDecommissioner, concurrency int, rate uint64, now time.Time) *decommissionJob {
+	dj := newDecommissionJob(&proto.DecommissionJob{
+		ID:          1,
+		Kind:        proto.DecommissionJobDataNode,
+		Addr:        "192.168.0.31:6000",
+		State:       proto.DecommissionRunning,
+		Concurrency: concurrency,
+		Rate:        rate,
+		MaxRetries:  1,
+	})
+	dj.refillTime = now
+	dj.add(d.partitions(), now)
+	return dj
+}
+
+func countDecommissionState(dj *decommissionJob, state string) (count int) {
+	for _, p := range dj.Partitions {
+		if p.State == state {
+			count++
+		}
+	}
+	return
+}
+
+func TestDecommissionJob_Concurrency(t *testing.T) {
+	now := time.Now()
+	d := newFakeDecommissioner(1, 2, 3)
+	dj := newTestDecommissionJob(d, 2, 0, now)
+	if !dj.step(d, now) || countDecommissionState(dj, proto.DecommissionMigrating) != 2 {
+		t.Fatalf("step 1: partitions(%v)", dj.view(true).Partitions)
+	}
+	// no more partitions start before the ones migrating recover
+	if dj.step(d, now) || countDecommissionState(dj, proto.DecommissionMigrating) != 2 {
+		t.Fatalf("step 2: partitions(%v)", dj.view(true).Partitions)
+	}
+	d.recoverAll()
+	dj.step(d, now)
+	if dj.Done != 2 || countDecommissionState(dj, proto.DecommissionMigrating) != 1 {
+		t.Fatalf("step 3: done(%v) partitions(%v)", dj.Done, dj.view(true).Partitions)
+	}
+	// a partition created on the node meanwhile is migrated before the node is removed
+	d.onNode[4] = true
+	d.recoverAll()
+	dj.step(d, now)
+	if dj.Total != 4 || d.removed || dj.State != proto.DecommissionRunning {
+		t.Fatalf("step 4: total(%v) removed(%v) state(%v)", dj.Total, d.removed, dj.State)
+	}
+	dj.step(d, now)
+	d.recoverAll()
+	dj.step(d, now)
+	if dj.Done != 4 || !d.removed || dj.State != proto.DecommissionDone {
+		t.Fatalf("step 5: done(%v) removed(%v) state(%v)", dj.Done, d.removed, dj.State)
+	}
+	if dj.step(d, now) {
+		t.Fatalf("ended job changed")
+	}
+}
+
+func TestDecommissionJob_Retry(t *testing.T) {
+	now := time.Now()
+	d := newFakeDecommissioner(1, 2)
+	d.failing[1] = true
+	dj := newTestDecommissionJob(d, 1, 0, now)
+	dj.step(d, now)
+	p := dj.Partitions[0]
+	if p.State != proto.DecommissionPending || p.Retries != 1 || p.Error == "" {
+		t.Fatalf("first failure: partition(%v)", p)
+	}
+	// the failed partition waits for the retry interval, while the next one goes on
+	dj.step(d, now)
+	if p.Retries != 1 || dj.Partitions[1].State != proto.DecommissionMigrating {
+		t.Fatalf("retry too early: partitions(%v)", dj.view(true).Partitions)
+	}
+	d.recoverAll()
+	now = now.Add(decommissionRetryInterval * time.Second)
+	dj.step(d, now)
+	if p.State != proto.DecommissionFailed || dj.State != proto.DecommissionFailed || dj.Failed != 1 || dj.Done != 1 {
+		t.Fatalf("max retries: partition(%v) job(%v)", p, dj.view(false))
+	}
+	// the failed partitions are retried once the job is resumed
+	d.failing[1] = false
+	dj.State, p.State, p.Retries = proto.DecommissionRunning, proto.DecommissionPending, 0
+	dj.step(d, now)
+	d.recoverAll()
+	dj.step(d, now)
+	if dj.State != proto.DecommissionDone || !d.removed {
+		t.Fatalf("resumed: job(%v) removed(%v)", dj.view(true), d.removed)
+	}
+}
+
+func TestDecommissionJob_Pause(t *testing.T) {
+	now := time.Now()
+	d := newFakeDecommissioner(1, 2)
+	dj := newTestDecommissionJob(d, 1, 0, now)
+	dj.step(d, now)
+	dj.State = proto.DecommissionPaused
+	d.recoverAll()
+	// the migration in progress is tracked, but no more partitions start
+	dj.step(d, now)
+	if dj.Partitions[0].State != proto.DecommissionDone || dj.Partitions[1].State != proto.DecommissionPending || d.removed {
+		t.Fatalf("paused: partitions(%v) removed(%v)", dj.view(true).Partitions, d.removed)
+	}
+	dj.State = proto.DecommissionCancelled
+	if dj.step(d, now) || dj.Partitions[1].State != proto.DecommissionPending {
+		t.Fatalf("cancelled: partitions(%v)", dj.view(true).Partitions)
+	}
+}
+
+func TestDecommissionJob_Rate(t *testing.T) {
+	now := time.Now()
+	d := newFakeDecommissioner(1, 2, 3)
+	// 10 bytes per second while each partition has 100 bytes
+	dj := newTestDecommissionJob(d, 3, 10, now)
+	dj.step(d, now)
+	if countDecommissionState(dj, proto.DecommissionMigrating) != 1 {
+		t.Fatalf("first: partitions(%v)", dj.view(true).Partitions)
+	}
+	now = now.Add(5 * time.Second)
+	dj.step(d, now)
+	if countDecommissionState(dj, proto.DecommissionMigrating) != 1 {
+		t.Fatalf("throttled: partitions(%v)", dj.view(true).Partitions)
+	}
+	now = now.Add(5 * time.Second)
+	dj.step(d, now)
+	if countDecommissionState(dj, proto.DecommissionMigrating) != 2 {
+		t.Fatalf("refilled: partitions(%v)", dj.view(true).Partitions)
+	}
+}
@@ -16,6 +16,7 @@ package master
 
 import (
 	"fmt"
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 	"time"
@@ -61,6 +62,7 @@ func (c *Cluster) checkDiskRecoveryProgress() {
 			diff = partition.getMinus()
 			if diff < util.GB {
 				partition.isRecover = false
+				partition.tierTarget = ""
 				Warn(c.Name, fmt.Sprintf("clusterID[%v],partitionID[%v] has recovered success", c.Name, partitionID))
 			} else {
 				newBadDpIds = append(newBadDpIds, partitionID)
@@ -69,6 +71,7 @@ func (c *Cluster) checkDiskRecoveryProgress() {
 
 		if len(newBadDpIds) == 0 {
 			Warn(c.Name, fmt.Sprintf("clusterID[%v],node:disk[%v] has recovered success", c.Name, key))
+			c.events.publish(proto.EventDecommissionFinished, key.(string), "data partitions decommissioned have recovered")
 			c.BadDataPartitionIds.Delete(key)
 		} else {
 			c.BadDataPartitionIds.Store(key, newBadDpIds)
@@ -0,0 +1,220 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// ECNode stores the information of an ec node, which stores the shards of the erasure coded partitions.
+type ECNode struct {
+	ID               uint64
+	Addr             string
+	ReportTime       time.Time
+	isActive         bool
+	Total            uint64
+	Used             uint64
+	AvailableSpace   uint64
+	PartitionReports []*proto.ECPartitionReport
+	TaskManager      *AdminTaskManager
+	sync.RWMutex
+}
+
+func newECNode(addr, clusterID string) (ecNode *ECNode) {
+	ecNode = new(ECNode)
+	ecNode.Addr = addr
+	ecNode.TaskManager = newAdminTaskManager(ecNode.Addr, clusterID)
+	return
+}
+
+// checkLiveness returns true if the ec node goes offline.
+func (ecNode *ECNode) checkLiveness() (offline bool) {
+	ecNode.Lock()
+	defer ecNode.Unlock()
+	if time.Since(ecNode.ReportTime) > time.Second*time.Duration(defaultNodeTimeOutSec) {
+		offline = ecNode.isActive
+		ecNode.isActive = false
+	}
+	return
+}
+
+func (ecNode *ECNode) updateNodeMetric(resp *proto.ECNodeHeartbeatResponse) {
+	ecNode.Lock()
+	defer ecNode.Unlock()
+	ecNode.Total = resp.Total
+	ecNode.Used = resp.Used
+	ecNode.AvailableSpace = resp.Available
+	ecNode.PartitionReports = resp.PartitionReports
+	ecNode.ReportTime = time.Now()
+	ecNode.isActive = true
+}
+
+func (ecNode *ECNode) clean() {
+	ecNode.TaskManager.exitCh <- struct{}{}
+}
+
+func (ecNode *ECNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+	request := &proto.HeartBeatRequest{
+		CurrTime:   time.Now().Unix(),
+		MasterAddr: masterAddr,
+	}
+	task = proto.NewAdminTask(proto.OpECNodeHeartbeat, ecNode.Addr, request)
+	return
+}
+
+func (ecNode *ECNode) toInfo() *proto.ECNodeInfo {
+	ecNode.RLock()
+	defer ecNode.RUnlock()
+	return &proto.ECNodeInfo{
+		ID:               ecNode.ID,
+		Addr:             ecNode.Addr,
+		ReportTime:       ecNode.ReportTime,
+		IsActive:         ecNode.isActive,
+		Total:            ecNode.Total,
+		Used:             ecNode.Used,
+		AvailableSpace:   ecNode.AvailableSpace,
+		PartitionReports: ecNode.PartitionReports,
+	}
+}
+
+func (c *Cluster) addECNode(nodeAddr string) (id uint64, err error) {
+	c.ecnMutex.Lock()
+	defer c.ecnMutex.Unlock()
+	if node, ok := c.ecNodes.Load(nodeAddr); ok {
+		return node.(*ECNode).ID, nil
+	}
+	ecNode := newECNode(nodeAddr, c.Name)
+	if id, err = c.idAlloc.allocateCommonID(); err != nil {
+		goto errHandler
+	}
+	ecNode.ID = id
+	if err = c.syncAddECNode(ecNode); err != nil {
+		goto errHandler
+	}
+	c.ecNodes.Store(nodeAddr, ecNode)
+	log.LogInfof("action[addECNode],clusterID[%v] ecNodeAddr:%v,id[%v]", c.Name, nodeAddr, id)
+	return
+errHandler:
+	ecNode.clean()
+	err = fmt.Errorf("action[addECNode],clusterID[%v] ecNodeAddr:%v err:%v ", c.Name, nodeAddr, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+func (c *Cluster) ecNode(addr string) (ecNode *ECNode, err error) {
+	value, ok := c.ecNodes.Load(addr)
+	if !ok {
+		err = errors.Trace(ecNodeNotFound(addr), "%v not found", addr)
+		return
+	}
+	ecNode = value.(*ECNode)
+	return
+}
+
+func (c *Cluster) clearECNodes() {
+	c.ecNodes.Range(func(key, value interface{}) bool {
+		ecNode := value.(*ECNode)
+		c.ecNodes.Delete(key)
+		ecNode.clean()
+		return true
+	})
+}
+
+func (c *Cluster) checkECNodeHeartbeat() {
+	c.ecNodes.Range(func(addr, value interface{}) bool {
+		node := value.(*ECNode)
+		if node.checkLiveness() {
+			c.events.publish(proto.EventNodeOffline, node.Addr, "ec node reports no heartbeat in %vs", defaultNodeTimeOutSec)
+		}
+		node.TaskManager.AddTask(node.createHeartbeatTask(c.masterAddr()))
+		return true
+	})
+}
+
+// chooseECNodes chooses the active ec nodes with the most available space for the shards.
+func (c *Cluster) chooseECNodes(shards int) (hosts []string, err error) {
+	nodes := make([]*ECNode, 0)
+	c.ecNodes.Range(func(addr, value interface{}) bool {
+		node := value.(*ECNode)
+		node.RLock()
+		if node.isActive {
+			nodes = append(nodes, node)
+		}
+		node.RUnlock()
+		return true
+	})
+	if len(nodes) < shards {
+		return nil, fmt.Errorf("no enough active ec nodes, need %v but %v", shards, len(nodes))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].AvailableSpace > nodes[j].AvailableSpace
+	})
+	for _, node := range nodes[:shards] {
+		hosts = append(hosts, node.Addr)
+	}
+	return
+}
+
+func (c *Cluster) handleECNodeTaskResponse(nodeAddr string, task *proto.AdminTask) {
+	if task == nil {
+		log.LogInfof("action[handleECNodeTaskResponse] receive addr[%v] task response,but task is nil", nodeAddr)
+		return
+	}
+	log.LogDebugf("action[handleECNodeTaskResponse] receive addr[%v] task response:%v", nodeAddr, task.ToString())
+	var (
+		err    error
+		ecNode *ECNode
+	)
+	if ecNode, err = c.ecNode(nodeAddr); err != nil {
+		goto errHandler
+	}
+	ecNode.TaskManager.DelTask(task)
+	if err = unmarshalTaskResponse(task); err != nil {
+		goto errHandler
+	}
+	switch task.OpCode {
+	case proto.OpECNodeHeartbeat:
+		response := task.Response.(*proto.ECNodeHeartbeatResponse)
+		if response.Status != proto.TaskSucceeds {
+			err = fmt.Errorf("heartbeat failed: %v", response.Result)
+			goto errHandler
+		}
+		ecNode.updateNodeMetric(response)
+	case proto.OpECMigrateDataPartition:
+		request := &proto.ECMigrateRequest{}
+		if err = convertTaskRequest(task, request); err != nil {
+			goto errHandler
+		}
+		err = c.handleECMigrateResponse(request, task.Response.(*proto.ECMigrateResponse))
+	default:
+		err = fmt.Errorf("unknown operate code %v", task.OpCode)
+	}
+	if err != nil {
+		goto errHandler
+	}
+	return
+
+errHandler:
+	log.LogErrorf("process task[%v] failed,err:%v", task.ToString(), err)
+	return
+}
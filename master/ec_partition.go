@@ -0,0 +1,265 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/ec"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The cold data partitions of the volumes with the erasure coding enabled are migrated to the
+// erasure coded partitions on the ec nodes, one partition of a volume at a time. The data partition
+// is set read-only during the migration, and the first ec node of the partition copies the extents
+// from the replicas, encodes them into the stripes and writes the shards to the ec nodes. Once the
+// ec nodes commit the partition, the master replaces the data partition with the erasure coded one
+// of the same ID in the view of the clients, and deletes the replicas.
+//
+// The erasure coded partitions are read-only, the extents can only be read or deleted.
+
+// ECPartition represents a data partition migrated to the shards on the ec nodes.
+type ECPartition struct {
+	PartitionID  uint64
+	VolID        uint64
+	VolName      string
+	DataShards   int
+	ParityShards int
+	StripeUnit   uint32
+	Hosts        []string // the ec nodes of the shards in order
+	ExtentCount  int
+	Size         uint64
+	MigrateTime  int64
+}
+
+func (ep *ECPartition) convertToDataPartitionResponse() (dpr *proto.DataPartitionResponse) {
+	dpr = new(proto.DataPartitionResponse)
+	dpr.PartitionID = ep.PartitionID
+	dpr.Status = proto.ReadOnly
+	dpr.ReplicaNum = uint8(len(ep.Hosts))
+	dpr.Hosts = make([]string, len(ep.Hosts))
+	copy(dpr.Hosts, ep.Hosts)
+	dpr.LeaderAddr = ep.Hosts[0]
+	dpr.ErasureCoded = true
+	return
+}
+
+func (ep *ECPartition) toInfo() *proto.ECPartitionInfo {
+	return &proto.ECPartitionInfo{
+		PartitionID:  ep.PartitionID,
+		VolName:      ep.VolName,
+		DataShards:   ep.DataShards,
+		ParityShards: ep.ParityShards,
+		StripeUnit:   ep.StripeUnit,
+		Hosts:        ep.Hosts,
+		ExtentCount:  ep.ExtentCount,
+		Size:         ep.Size,
+		MigrateTime:  ep.MigrateTime,
+	}
+}
+
+// isMigratingToEC tells whether the partition is being migrated, the migration is considered
+// failed if the ec node does not respond in time. The lock of the partition must be held.
+func (partition *DataPartition) isMigratingToEC() bool {
+	return partition.ecMigrateTime > 0 && time.Now().Unix()-partition.ecMigrateTime < ecMigrateTimeout
+}
+
+// isColdToMigrate tells whether the partition is worth trying to migrate, the ec node checks if
+// the extents have not been modified for the cold age. The lock of the partition must be held.
+func (partition *DataPartition) isColdToMigrate(now, coldAge int64) bool {
+	return now-partition.createTime >= coldAge && now-partition.ecCheckTime >= ecMigrateRetryInterval &&
+		!partition.isRecover && !partition.unavailable && !partition.releasing && len(partition.Replicas) == int(partition.ReplicaNum)
+}
+
+func (c *Cluster) scheduleToMigrateECPartitions() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.migrateColdDataPartitions()
+			}
+			time.Sleep(time.Second * defaultIntervalToMigrateEC)
+		}
+	}()
+}
+
+func (c *Cluster) migrateColdDataPartitions() {
+	for _, vol := range c.copyVols() {
+		vol.RLock()
+		scheme, coldAge := vol.ecScheme, vol.ecColdAge
+		vol.RUnlock()
+		if scheme == "" || vol.Status == markDelete {
+			continue
+		}
+		dp := vol.dataPartitionToMigrate(coldAge)
+		if dp == nil {
+			continue
+		}
+		if err := c.migrateDataPartition(vol, dp, scheme, coldAge); err != nil {
+			log.LogWarnf("action[migrateColdDataPartitions] vol[%v] dp[%v] err[%v]", vol.Name, dp.PartitionID, err)
+		}
+	}
+}
+
+// dataPartitionToMigrate returns a data partition to migrate, or nil if one is being migrated.
+func (vol *Vol) dataPartitionToMigrate(coldAge int64) (candidate *DataPartition) {
+	now := time.Now().Unix()
+	vol.dataPartitions.RLock()
+	defer vol.dataPartitions.RUnlock()
+	for _, dp := range vol.dataPartitions.partitionMap {
+		dp.RLock()
+		migrating, cold := dp.isMigratingToEC(), dp.isColdToMigrate(now, coldAge)
+		dp.RUnlock()
+		if migrating {
+			return nil
+		}
+		if cold && candidate == nil {
+			candidate = dp
+		}
+	}
+	return
+}
+
+func (c *Cluster) migrateDataPartition(vol *Vol, dp *DataPartition, scheme string, coldAge int64) (err error) {
+	dataShards, parityShards, err := ec.ParseScheme(scheme)
+	if err != nil {
+		return
+	}
+	hosts, err := c.chooseECNodes(dataShards + parityShards)
+	if err != nil {
+		return
+	}
+	ecNode, err := c.ecNode(hosts[0])
+	if err != nil {
+		return
+	}
+	dp.Lock()
+	dp.ecMigrateTime = time.Now().Unix()
+	dp.Status = proto.ReadOnly
+	dataHosts := make([]string, len(dp.Hosts))
+	copy(dataHosts, dp.Hosts)
+	dp.Unlock()
+	vol.dataPartitions.updateResponseCache(true, 0)
+
+	request := &proto.ECMigrateRequest{
+		PartitionID:  dp.PartitionID,
+		VolName:      vol.Name,
+		DataHosts:    dataHosts,
+		ECHosts:      hosts,
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+		StripeUnit:   util.BlockSize,
+		ColdAge:      coldAge,
+	}
+	task := proto.NewAdminTask(proto.OpECMigrateDataPartition, ecNode.Addr, request)
+	dp.resetTaskID(task)
+	ecNode.TaskManager.AddTask(task)
+	log.LogInfof("action[migrateDataPartition] vol[%v] dp[%v] scheme[%v] ecHosts[%v]", vol.Name, dp.PartitionID, scheme, hosts)
+	return
+}
+
+// handleECMigrateResponse replaces the data partition with the erasure coded partition migrated.
+// The erasure coded partition is persisted before the data partition is deleted, so that it
+// replaces the data partition left when the metadata is loaded.
+func (c *Cluster) handleECMigrateResponse(request *proto.ECMigrateRequest, resp *proto.ECMigrateResponse) (err error) {
+	vol, err := c.getVol(request.VolName)
+	if err != nil {
+		return
+	}
+	dp, err := vol.getDataPartitionByID(request.PartitionID)
+	if err != nil {
+		if _, err1 := vol.dataPartitions.getECPartition(request.PartitionID); err1 == nil {
+			return nil
+		}
+		return
+	}
+	if resp.Status != proto.TaskSucceeds {
+		dp.Lock()
+		dp.ecMigrateTime, dp.ecCheckTime = 0, time.Now().Unix()
+		dp.Unlock()
+		log.LogWarnf("action[handleECMigrateResponse] vol[%v] dp[%v] not migrated: %v", vol.Name, dp.PartitionID, resp.Result)
+		return
+	}
+	ep := &ECPartition{
+		PartitionID:  dp.PartitionID,
+		VolID:        vol.ID,
+		VolName:      vol.Name,
+		DataShards:   request.DataShards,
+		ParityShards: request.ParityShards,
+		StripeUnit:   request.StripeUnit,
+		Hosts:        request.ECHosts,
+		ExtentCount:  resp.ExtentCount,
+		Size:         resp.Size,
+		MigrateTime:  time.Now().Unix(),
+	}
+	if err = c.syncAddECPartition(ep); err != nil {
+		return
+	}
+	vol.dataPartitions.putECPartition(ep)
+	vol.dataPartitions.updateResponseCache(true, 0)
+	if err = c.syncDeleteDataPartition(dp); err != nil {
+		log.LogErrorf("action[handleECMigrateResponse] vol[%v] delete dp[%v] err[%v]", vol.Name, dp.PartitionID, err)
+	}
+	dp.RLock()
+	tasks := make([]*proto.AdminTask, 0, len(dp.Hosts))
+	for _, host := range dp.Hosts {
+		tasks = append(tasks, dp.createTaskToDeleteDataPartition(host))
+	}
+	dp.RUnlock()
+	c.addDataNodeTasks(tasks)
+	log.LogInfof("action[handleECMigrateResponse] vol[%v] dp[%v] migrated to ec partition of %v, extents[%v] size[%v]",
+		vol.Name, dp.PartitionID, ec.Scheme(ep.DataShards, ep.ParityShards), ep.ExtentCount, ep.Size)
+	return
+}
+
+func (c *Cluster) getECPartition(partitionID uint64) (ep *ECPartition, err error) {
+	for _, vol := range c.copyVols() {
+		if ep, err = vol.dataPartitions.getECPartition(partitionID); err == nil {
+			return
+		}
+	}
+	return nil, proto.ErrDataPartitionNotExists
+}
+
+func (c *Cluster) setVolEC(name, authKey, scheme string, coldAge int64) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	if scheme == proto.ECSchemeNone {
+		scheme = ""
+	} else if _, _, err = ec.ParseScheme(scheme); err != nil {
+		return fmt.Errorf("invalid ec scheme %v", scheme)
+	}
+	if coldAge <= 0 {
+		coldAge = defaultECColdAge
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldScheme, oldColdAge := vol.ecScheme, vol.ecColdAge
+	vol.ecScheme, vol.ecColdAge = scheme, coldAge
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolEC] vol[%v] err[%v]", name, err)
+		vol.ecScheme, vol.ecColdAge = oldScheme, oldColdAge
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	defaultEventCapacity   = 10000 // number of the latest events kept in memory
+	defaultMaxEventsToList = 1000
+	eventSinkBufferSize    = 1024
+	eventSinkBatchSize     = 128
+	eventSinkFlushInterval = time.Second
+	eventSinkMaxBackoff    = 30 * time.Second
+	eventWebhookTimeout    = 10 * time.Second
+)
+
+// eventBus keeps the latest cluster events in a ring, and pushes them to the sinks.
+// The events are only emitted by the leader and are not replicated, so the events of the former
+// leaders are lost. The IDs of the events are their timestamps in nanoseconds, which increase
+// across the leaders, so that the subscribers can keep on listing from the last ID they received.
+type eventBus struct {
+	sync.RWMutex
+	cluster string
+	events  []*proto.ClusterEvent
+	start   int // index of the oldest event in the ring
+	count   int
+	lastID  uint64
+	sinks   []*eventSink
+}
+
+func newEventBus(cluster string, capacity int) *eventBus {
+	return &eventBus{
+		cluster: cluster,
+		events:  make([]*proto.ClusterEvent, capacity),
+	}
+}
+
+// publish emits an event, which never blocks on the sinks.
+func (b *eventBus) publish(eventType, target, format string, a ...interface{}) {
+	now := time.Now()
+	event := &proto.ClusterEvent{
+		Time:    now.Unix(),
+		Cluster: b.cluster,
+		Type:    eventType,
+		Target:  target,
+		Msg:     fmt.Sprintf(format, a...),
+	}
+
+	b.Lock()
+	event.ID = uint64(now.UnixNano())
+	if event.ID <= b.lastID {
+		event.ID = b.lastID + 1
+	}
+	b.lastID = event.ID
+	if b.count < len(b.events) {
+		b.events[(b.start+b.count)%len(b.events)] = event
+		b.count++
+	} else {
+		b.events[b.start] = event
+		b.start = (b.start + 1) % len(b.events)
+	}
+	sinks := b.sinks
+	b.Unlock()
+
+	log.LogInfof("action[publishEvent] event[%v] target[%v] msg[%v]", eventType, target, event.Msg)
+	for _, sink := range sinks {
+		sink.add(event)
+	}
+}
+
+// list returns at most limit events whose IDs are not less than from, and of the given type
+// if it is not empty, along with the ID to list the next events from.
+func (b *eventBus) list(from uint64, eventType string, limit int) (view *proto.ClusterEventsView) {
+	b.RLock()
+	defer b.RUnlock()
+	view = &proto.ClusterEventsView{Events: make([]*proto.ClusterEvent, 0), Next: from}
+	for i := 0; i < b.count && len(view.Events) < limit; i++ {
+		event := b.events[(b.start+i)%len(b.events)]
+		if event.ID < from {
+			continue
+		}
+		view.Next = event.ID + 1
+		if eventType == "" || event.Type == eventType {
+			view.Events = append(view.Events, event)
+		}
+	}
+	return
+}
+
+// startSinks starts pushing the events to the sinks, which are the webhook URLs receiving the
+// events in JSON arrays by POST, or the remote log addresses, e.g. kafka://broker1:9092/topic,
+// receiving one event in JSON per line.
+func (b *eventBus) startSinks(addrs []string) (err error) {
+	sinks := make([]*eventSink, 0, len(addrs))
+	for _, addr := range addrs {
+		var send func(events []*proto.ClusterEvent) error
+		if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+			send = newWebhookSender(addr)
+		} else {
+			var remote log.RemoteSink
+			if remote, err = log.NewRemoteSink(addr); err != nil {
+				return
+			}
+			send = newRemoteSender(remote)
+		}
+		sinks = append(sinks, newEventSink(addr, send))
+	}
+	b.Lock()
+	b.sinks = sinks
+	b.Unlock()
+	return
+}
+
+// eventSink buffers the events of a sink and sends them in batches in the background. When the
+// buffer is full the new events are dropped, so that a slow or broken sink never blocks the
+// master, and they can still be listed by the API.
+type eventSink struct {
+	addr    string
+	send    func(events []*proto.ClusterEvent) error
+	queue   chan *proto.ClusterEvent
+	dropped uint64
+}
+
+func newEventSink(addr string, send func(events []*proto.ClusterEvent) error) *eventSink {
+	sink := &eventSink{
+		addr:  addr,
+		send:  send,
+		queue: make(chan *proto.ClusterEvent, eventSinkBufferSize),
+	}
+	go sink.run()
+	return sink
+}
+
+func (s *eventSink) add(event *proto.ClusterEvent) {
+	select {
+	case s.queue <- event:
+	default:
+		if dropped := atomic.AddUint64(&s.dropped, 1); dropped%eventSinkBufferSize == 1 {
+			log.LogWarnf("action[eventSink] sink[%v] buffer is full, %v events dropped", s.addr, dropped)
+		}
+	}
+}
+
+func (s *eventSink) run() {
+	ticker := time.NewTicker(eventSinkFlushInterval)
+	defer ticker.Stop()
+	batch := make([]*proto.ClusterEvent, 0, eventSinkBatchSize)
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) < eventSinkBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		backoff := eventSinkFlushInterval
+		for {
+			err := s.send(batch)
+			if err == nil {
+				break
+			}
+			log.LogWarnf("action[eventSink] send %v events to sink[%v] failed, err[%v]", len(batch), s.addr, err)
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > eventSinkMaxBackoff {
+				backoff = eventSinkMaxBackoff
+			}
+		}
+		batch = batch[:0]
+	}
+}
+
+func newWebhookSender(url string) func(events []*proto.ClusterEvent) error {
+	client := &http.Client{Timeout: eventWebhookTimeout}
+	return func(events []*proto.ClusterEvent) error {
+		data, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("status %v", resp.Status)
+		}
+		return nil
+	}
+}
+
+func newRemoteSender(remote log.RemoteSink) func(events []*proto.ClusterEvent) error {
+	return func(events []*proto.ClusterEvent) error {
+		records := make([]*log.RemoteRecord, 0, len(events))
+		for _, event := range events {
+			line, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			records = append(records, &log.RemoteRecord{
+				Time:   time.Unix(event.Time, 0),
+				Level:  log.InfoLevel,
+				Module: ModuleName,
+				Line:   append(line, '\n'),
+			})
+		}
+		return remote.Send(records)
+	}
+}
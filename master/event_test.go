@@ -0,0 +1,82 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus("test", 3)
+	for i := 0; i < 4; i++ {
+		bus.publish(proto.EventVolumeCreated, "vol", "volume %v", i)
+	}
+	bus.publish(proto.EventNodeOffline, "node", "offline")
+
+	// the oldest events are dropped from the ring
+	view := bus.list(0, "", 10)
+	if len(view.Events) != 3 || view.Events[0].Msg != "volume 2" || view.Events[2].Type != proto.EventNodeOffline {
+		t.Fatalf("list all: %v", view.Events)
+	}
+	for i := 1; i < len(view.Events); i++ {
+		if view.Events[i].ID <= view.Events[i-1].ID {
+			t.Fatalf("event IDs are not increasing: %v", view.Events)
+		}
+	}
+
+	view = bus.list(0, "", 1)
+	if len(view.Events) != 1 || view.Next != view.Events[0].ID+1 {
+		t.Fatalf("list first: %+v", view)
+	}
+	view = bus.list(view.Next, proto.EventNodeOffline, 10)
+	if len(view.Events) != 1 || view.Events[0].Target != "node" {
+		t.Fatalf("list by type: %+v", view)
+	}
+	if next := bus.list(view.Next, "", 10); len(next.Events) != 0 || next.Next != view.Next {
+		t.Fatalf("list after the last: %+v", next)
+	}
+}
+
+func TestEventWebhook(t *testing.T) {
+	received := make(chan []*proto.ClusterEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []*proto.ClusterEvent
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			t.Errorf("decode events: %v", err)
+		}
+		received <- events
+	}))
+	defer server.Close()
+
+	bus := newEventBus("test", 10)
+	if err := bus.startSinks([]string{server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	bus.publish(proto.EventDiskFailed, "127.0.0.1:6000:/disk1", "bad disk")
+	select {
+	case events := <-received:
+		if len(events) != 1 || events[0].Type != proto.EventDiskFailed || events[0].Cluster != "test" {
+			t.Fatalf("received: %v", events)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no events received by the webhook")
+	}
+}
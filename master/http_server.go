@@ -15,17 +15,30 @@
 package master
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/fault"
+	"github.com/chubaofs/chubaofs/util/health"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
 	"net/http/httputil"
 )
 
 func (m *Server) startHTTPService() {
 	go func() {
 		m.handleFunctions()
-		if err := http.ListenAndServe(colonSplit+m.port, nil); err != nil {
+		var err error
+		if mtls.Enabled() {
+			// the clients present their certificates as the peers of the TCP connections
+			server := &http.Server{Addr: colonSplit + m.port, TLSConfig: mtls.ServerConfig()}
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = http.ListenAndServe(colonSplit+m.port, nil)
+		}
+		if err != nil {
 			log.LogErrorf("action[startHTTPService] failed,err[%v]", err)
 			panic(err)
 		}
@@ -42,6 +55,7 @@ func (m *Server) handleFunctions() {
 	http.Handle(proto.AdminDecommissionDataPartition, m.handlerWithInterceptor())
 	http.Handle(proto.AdminAddDataReplica, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteDataReplica, m.handlerWithInterceptor())
+	http.Handle(proto.AdminPromoteDataReplica, m.handlerWithInterceptor())
 	http.Handle(proto.AdminCreateVol, m.handlerWithInterceptor())
 	http.Handle(proto.AdminGetVol, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteVol, m.handlerWithInterceptor())
@@ -58,27 +72,103 @@ func (m *Server) handleFunctions() {
 	http.Handle(proto.AdminDecommissionMetaPartition, m.handlerWithInterceptor())
 	http.Handle(proto.AdminAddMetaReplica, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteMetaReplica, m.handlerWithInterceptor())
+	http.Handle(proto.AdminPromoteMetaReplica, m.handlerWithInterceptor())
 	http.Handle(proto.ClientDataPartitions, m.handlerWithInterceptor())
 	http.Handle(proto.ClientVol, m.handlerWithInterceptor())
 	http.Handle(proto.ClientMetaPartitions, m.handlerWithInterceptor())
 	http.Handle(proto.ClientMetaPartition, m.handlerWithInterceptor())
 	http.Handle(proto.GetDataNodeTaskResponse, m.handlerWithInterceptor())
+	http.Handle(proto.AddECNode, m.handlerWithInterceptor())
+	http.Handle(proto.GetECNode, m.handlerWithInterceptor())
+	http.Handle(proto.GetECNodeTaskResponse, m.handlerWithInterceptor())
 	http.Handle(proto.GetMetaNodeTaskResponse, m.handlerWithInterceptor())
 	http.Handle(proto.AdminCreateMetaPartition, m.handlerWithInterceptor())
 	http.Handle(proto.ClientVolStat, m.handlerWithInterceptor())
 	http.Handle(proto.AddRaftNode, m.handlerWithInterceptor())
 	http.Handle(proto.RemoveRaftNode, m.handlerWithInterceptor())
 	http.Handle(proto.AdminSetMetaNodeThreshold, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetRateLimit, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetRateLimit, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListEvents, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolLifecycle, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetVolLifecycle, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListVolLifecycles, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolPolicy, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetVolPolicy, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolQuota, m.handlerWithInterceptor())
+	http.Handle(proto.AdminDeleteVolQuota, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListVolQuotas, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolTrash, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolCompression, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolEC, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolTiering, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolPlacement, m.handlerWithInterceptor())
+	http.Handle(proto.AdminShrinkVol, m.handlerWithInterceptor())
+	http.Handle(proto.AdminReleaseDataPartition, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetECPartition, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCreateVolSnapshot, m.handlerWithInterceptor())
+	http.Handle(proto.AdminDeleteVolSnapshot, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListVolSnapshots, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCreateVolAccessKey, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListVolAccessKeys, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRevokeVolAccessKey, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetEncryptionKey, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRotateEncryptionKey, m.handlerWithInterceptor())
+	http.Handle(proto.AdminReverseLookupInode, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetBalancePlan, m.handlerWithInterceptor())
+	http.Handle(proto.AdminPauseBalance, m.handlerWithInterceptor())
+	http.Handle(proto.AdminResumeBalance, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCreateDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListDecommissionJobs, m.handlerWithInterceptor())
+	http.Handle(proto.AdminPauseDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminResumeDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCancelDecommissionJob, m.handlerWithInterceptor())
 	http.Handle(proto.GetTopologyView, m.handlerWithInterceptor())
 
+	health.AddCheck("raft", m.checkRaftReady)
+	health.RegisterHTTP()
+	fault.RegisterHTTP()
 	return
 }
 
+// checkRaftReady checks that the master is able to serve the requests, either as the leader
+// with the metadata loaded, or as a follower which knows the leader to proxy to.
+func (m *Server) checkRaftReady() error {
+	if m.partition == nil {
+		return fmt.Errorf("raft is not started")
+	}
+	if m.partition.IsRaftLeader() {
+		if !m.metaReady {
+			return fmt.Errorf("leader meta has not ready")
+		}
+		return nil
+	}
+	if m.leaderInfo.addr == "" {
+		return fmt.Errorf("no leader")
+	}
+	return nil
+}
+
+// limitRate limits the requests by the rate limits of the master module, except the task
+// responses of the nodes, which the heartbeats depend on.
+func (m *Server) limitRate(r *http.Request) error {
+	switch r.URL.Path {
+	case proto.GetDataNodeTaskResponse, proto.GetMetaNodeTaskResponse, proto.GetECNodeTaskResponse:
+		return nil
+	}
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	return m.cluster.limiter.Allow(r.URL.Query().Get(nameKey), r.URL.Path, client)
+}
+
 func (m *Server) newReverseProxy() *httputil.ReverseProxy {
 	return &httputil.ReverseProxy{Director: func(request *http.Request) {
-		request.URL.Scheme = "http"
+		request.URL.Scheme = mtls.Scheme()
 		request.URL.Host = m.leaderInfo.addr
-	}}
+	}, Transport: mtls.HTTPTransport()}
 }
 
 func (m *Server) handlerWithInterceptor() http.Handler {
@@ -108,6 +198,10 @@ func (m *Server) proxy(w http.ResponseWriter, r *http.Request) {
 
 func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.LogInfof("URL[%v],remoteAddr[%v]", r.URL, r.RemoteAddr)
+	if err := m.limitRate(r); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
 	switch r.URL.Path {
 	case proto.AdminGetCluster:
 		m.getCluster(w, r)
@@ -123,6 +217,8 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.addDataReplica(w, r)
 	case proto.AdminDeleteDataReplica:
 		m.deleteDataReplica(w, r)
+	case proto.AdminPromoteDataReplica:
+		m.promoteDataReplica(w, r)
 	case proto.AdminCreateVol:
 		m.createVol(w, r)
 	case proto.AdminGetVol:
@@ -143,6 +239,12 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.decommissionDisk(w, r)
 	case proto.GetDataNodeTaskResponse:
 		m.handleDataNodeTaskResponse(w, r)
+	case proto.AddECNode:
+		m.addECNode(w, r)
+	case proto.GetECNode:
+		m.getECNode(w, r)
+	case proto.GetECNodeTaskResponse:
+		m.handleECNodeTaskResponse(w, r)
 	case proto.AddMetaNode:
 		m.addMetaNode(w, r)
 	case proto.GetMetaNode:
@@ -171,12 +273,88 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.addMetaReplica(w, r)
 	case proto.AdminDeleteMetaReplica:
 		m.deleteMetaReplica(w, r)
+	case proto.AdminPromoteMetaReplica:
+		m.promoteMetaReplica(w, r)
 	case proto.AddRaftNode:
 		m.addRaftNode(w, r)
 	case proto.RemoveRaftNode:
 		m.removeRaftNode(w, r)
 	case proto.AdminSetMetaNodeThreshold:
 		m.setMetaNodeThreshold(w, r)
+	case proto.AdminSetRateLimit:
+		m.setRateLimit(w, r)
+	case proto.AdminGetRateLimit:
+		m.getRateLimit(w, r)
+	case proto.AdminListEvents:
+		m.listEvents(w, r)
+	case proto.AdminSetVolLifecycle:
+		m.setVolLifecycle(w, r)
+	case proto.AdminGetVolLifecycle:
+		m.getVolLifecycle(w, r)
+	case proto.AdminListVolLifecycles:
+		m.listVolLifecycles(w, r)
+	case proto.AdminSetVolPolicy:
+		m.setVolPolicy(w, r)
+	case proto.AdminGetVolPolicy:
+		m.getVolPolicy(w, r)
+	case proto.AdminSetVolQuota:
+		m.setVolQuota(w, r)
+	case proto.AdminDeleteVolQuota:
+		m.deleteVolQuota(w, r)
+	case proto.AdminListVolQuotas:
+		m.listVolQuotas(w, r)
+	case proto.AdminSetVolTrash:
+		m.setVolTrash(w, r)
+	case proto.AdminSetVolCompression:
+		m.setVolCompression(w, r)
+	case proto.AdminSetVolEC:
+		m.setVolEC(w, r)
+	case proto.AdminSetVolTiering:
+		m.setVolTiering(w, r)
+	case proto.AdminSetVolPlacement:
+		m.setVolPlacement(w, r)
+	case proto.AdminShrinkVol:
+		m.shrinkVol(w, r)
+	case proto.AdminReleaseDataPartition:
+		m.releaseDataPartition(w, r)
+	case proto.AdminGetECPartition:
+		m.getECPartition(w, r)
+	case proto.AdminCreateVolSnapshot:
+		m.createVolSnapshot(w, r)
+	case proto.AdminDeleteVolSnapshot:
+		m.deleteVolSnapshot(w, r)
+	case proto.AdminListVolSnapshots:
+		m.listVolSnapshots(w, r)
+	case proto.AdminCreateVolAccessKey:
+		m.createVolAccessKey(w, r)
+	case proto.AdminListVolAccessKeys:
+		m.listVolAccessKeys(w, r)
+	case proto.AdminRevokeVolAccessKey:
+		m.revokeVolAccessKey(w, r)
+	case proto.AdminGetEncryptionKey:
+		m.getEncryptionKey(w, r)
+	case proto.AdminRotateEncryptionKey:
+		m.rotateEncryptionKey(w, r)
+	case proto.AdminReverseLookupInode:
+		m.reverseLookupInode(w, r)
+	case proto.AdminGetBalancePlan:
+		m.getBalancePlan(w, r)
+	case proto.AdminPauseBalance:
+		m.setBalancePaused(w, r, true)
+	case proto.AdminResumeBalance:
+		m.setBalancePaused(w, r, false)
+	case proto.AdminCreateDecommissionJob:
+		m.createDecommissionJob(w, r)
+	case proto.AdminGetDecommissionJob:
+		m.getDecommissionJob(w, r)
+	case proto.AdminListDecommissionJobs:
+		m.listDecommissionJobs(w, r)
+	case proto.AdminPauseDecommissionJob:
+		m.updateDecommissionJob(w, r, "pause", m.cluster.pauseDecommissionJob)
+	case proto.AdminResumeDecommissionJob:
+		m.updateDecommissionJob(w, r, "resume", m.cluster.resumeDecommissionJob)
+	case proto.AdminCancelDecommissionJob:
+		m.updateDecommissionJob(w, r, "cancel", m.cluster.cancelDecommissionJob)
 	case proto.GetTopologyView:
 		m.getTopology(w, r)
 	default:
@@ -106,6 +106,10 @@ func (m *Server) loadMetadata() {
 		panic(err)
 	}
 
+	if err = m.cluster.loadECNodes(); err != nil {
+		panic(err)
+	}
+
 	if err = m.cluster.loadVols(); err != nil {
 		panic(err)
 	}
@@ -116,6 +120,12 @@ func (m *Server) loadMetadata() {
 	if err = m.cluster.loadDataPartitions(); err != nil {
 		panic(err)
 	}
+	if err = m.cluster.loadECPartitions(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadDecommissionJobs(); err != nil {
+		panic(err)
+	}
 	log.LogInfo("action[loadMetadata] end")
 
 }
@@ -124,6 +134,8 @@ func (m *Server) clearMetadata() {
 	m.cluster.clearTopology()
 	m.cluster.clearDataNodes()
 	m.cluster.clearMetaNodes()
+	m.cluster.clearECNodes()
 	m.cluster.clearVols()
+	m.cluster.clearDecommissionJobs()
 	m.cluster.t = newTopology()
 }
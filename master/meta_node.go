@@ -28,6 +28,8 @@ type MetaNode struct {
 	IsActive           bool
 	Sender             *AdminTaskManager
 	CellName           string `json:"Cell"`
+	ZoneName           string `json:"Zone"`
+	RackName           string `json:"Rack"`
 	MaxMemAvailWeight  uint64 `json:"MaxMemAvailWeight"`
 	Total              uint64 `json:"TotalWeight"`
 	Used               uint64 `json:"UsedWeight"`
@@ -41,6 +43,8 @@ type MetaNode struct {
 	NodeSetID          uint64
 	sync.RWMutex
 	PersistenceMetaPartitions []uint64
+	ProtocolVersion           uint32
+	MinClientVersion          uint32
 }
 
 func newMetaNode(addr, clusterID string) (node *MetaNode) {
@@ -108,7 +112,11 @@ func (metaNode *MetaNode) updateMetric(resp *proto.MetaNodeHeartbeatResponse, th
 	}
 	metaNode.MaxMemAvailWeight = resp.Total - resp.Used
 	metaNode.CellName = resp.CellName
+	metaNode.ZoneName = resp.ZoneName
+	metaNode.RackName = resp.RackName
 	metaNode.Threshold = threshold
+	metaNode.ProtocolVersion = resp.ProtocolVersion
+	metaNode.MinClientVersion = resp.MinClientVersion
 }
 
 func (metaNode *MetaNode) reachesThreshold() bool {
@@ -118,19 +126,25 @@ func (metaNode *MetaNode) reachesThreshold() bool {
 	return float32(float64(metaNode.Used)/float64(metaNode.Total)) > metaNode.Threshold
 }
 
-func (metaNode *MetaNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (metaNode *MetaNode) createHeartbeatTask(masterAddr string, rateLimits []*proto.RateLimitRule,
+	volSnapshots map[string][]*proto.VolSnapshot) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:     time.Now().Unix(),
+		MasterAddr:   masterAddr,
+		RateLimits:   rateLimits,
+		VolSnapshots: volSnapshots,
 	}
 	task = proto.NewAdminTask(proto.OpMetaNodeHeartbeat, metaNode.Addr, request)
 	return
 }
 
-func (metaNode *MetaNode) checkHeartbeat() {
+// checkHeartbeat returns true if the meta node goes offline.
+func (metaNode *MetaNode) checkHeartbeat() (offline bool) {
 	metaNode.Lock()
 	defer metaNode.Unlock()
 	if time.Since(metaNode.ReportTime) > time.Second*time.Duration(defaultNodeTimeOutSec) {
+		offline = metaNode.IsActive
 		metaNode.IsActive = false
 	}
+	return
 }
@@ -36,7 +36,12 @@ type MetaReplica struct {
 	ReportTime int64
 	Status     int8 // unavailable, readOnly, readWrite
 	IsLeader   bool
+	RaftHealth *proto.RaftHealth
 	metaNode   *MetaNode
+	// quotaUsages is the usage of the quotas in the partition, reported by the leader only.
+	quotaUsages []*proto.QuotaUsage
+	// volSnapshotIDs is the snapshots of the volume frozen in the replica.
+	volSnapshotIDs []uint32
 }
 
 // MetaPartition defines the structure of a meta partition
@@ -231,9 +236,14 @@ func (mp *MetaPartition) checkLeader() {
 	return
 }
 
-func (mp *MetaPartition) checkStatus(clusterID string, writeLog bool, replicaNum int, maxPartitionID uint64) {
+// checkStatus returns true if the meta partition becomes unavailable.
+func (mp *MetaPartition) checkStatus(clusterID string, writeLog bool, replicaNum int, maxPartitionID uint64) (unavailable bool) {
 	mp.Lock()
 	defer mp.Unlock()
+	oldStatus := mp.Status
+	defer func() {
+		unavailable = mp.Status == proto.Unavailable && oldStatus != proto.Unavailable
+	}()
 	liveReplicas := mp.getLiveReplicas()
 	if len(liveReplicas) <= replicaNum/2 {
 		mp.Status = proto.Unavailable
@@ -260,6 +270,7 @@ func (mp *MetaPartition) checkStatus(clusterID string, writeLog bool, replicaNum
 		log.LogInfo(msg)
 		Warn(clusterID, msg)
 	}
+	return
 }
 
 func (mp *MetaPartition) getMetaReplicaLeader() (mr *MetaReplica, err error) {
@@ -471,7 +482,7 @@ func (mp *MetaPartition) replicaCreationTasks(clusterID, volName string) (tasks
 	return
 }
 
-func (mp *MetaPartition) buildNewMetaPartitionTasks(specifyAddrs []string, peers []proto.Peer, volName string) (tasks []*proto.AdminTask) {
+func (mp *MetaPartition) buildNewMetaPartitionTasks(specifyAddrs []string, peers []proto.Peer, volName, storeMode string) (tasks []*proto.AdminTask) {
 	tasks = make([]*proto.AdminTask, 0)
 	hosts := make([]string, 0)
 	req := &proto.CreateMetaPartitionRequest{
@@ -480,6 +491,7 @@ func (mp *MetaPartition) buildNewMetaPartitionTasks(specifyAddrs []string, peers
 		PartitionID: mp.PartitionID,
 		Members:     peers,
 		VolName:     volName,
+		StoreMode:   storeMode,
 	}
 	if specifyAddrs == nil {
 		hosts = mp.Hosts
@@ -512,13 +524,14 @@ func (mp *MetaPartition) createTaskToTryToChangeLeader(addr string) (task *proto
 	return
 }
 
-func (mp *MetaPartition) createTaskToCreateReplica(host string) (t *proto.AdminTask, err error) {
+func (mp *MetaPartition) createTaskToCreateReplica(host, storeMode string) (t *proto.AdminTask, err error) {
 	req := &proto.CreateMetaPartitionRequest{
 		Start:       mp.Start,
 		End:         mp.End,
 		PartitionID: mp.PartitionID,
 		Members:     mp.Peers,
 		VolName:     mp.volName,
+		StoreMode:   storeMode,
 	}
 	t = proto.NewAdminTask(proto.OpCreateMetaPartition, host, req)
 	resetMetaPartitionTaskID(t, mp.PartitionID)
@@ -526,8 +539,20 @@ func (mp *MetaPartition) createTaskToCreateReplica(host string) (t *proto.AdminT
 }
 
 func (mp *MetaPartition) createTaskToAddRaftMember(addPeer proto.Peer, leaderAddr string) (t *proto.AdminTask, err error) {
-	req := &proto.AddMetaPartitionRaftMemberRequest{PartitionId: mp.PartitionID, AddPeer: addPeer}
-	t = proto.NewAdminTask(proto.OpAddMetaPartitionRaftMember, leaderAddr, req)
+	if addPeer.Learner {
+		req := &proto.AddMetaPartitionRaftLearnerRequest{PartitionId: mp.PartitionID, AddLearner: addPeer}
+		t = proto.NewAdminTask(proto.OpAddMetaPartitionRaftLearner, leaderAddr, req)
+	} else {
+		req := &proto.AddMetaPartitionRaftMemberRequest{PartitionId: mp.PartitionID, AddPeer: addPeer}
+		t = proto.NewAdminTask(proto.OpAddMetaPartitionRaftMember, leaderAddr, req)
+	}
+	resetMetaPartitionTaskID(t, mp.PartitionID)
+	return
+}
+
+func (mp *MetaPartition) createTaskToPromoteRaftLearner(learner proto.Peer, leaderAddr string) (t *proto.AdminTask, err error) {
+	req := &proto.PromoteMetaPartitionRaftLearnerRequest{PartitionId: mp.PartitionID, PromoteLearner: learner}
+	t = proto.NewAdminTask(proto.OpPromoteMetaPartitionRaftLearner, leaderAddr, req)
 	resetMetaPartitionTaskID(t, mp.PartitionID)
 	return
 }
@@ -554,6 +579,28 @@ func (mp *MetaPartition) createTaskToDecommissionReplica(volName string, removeP
 	return
 }
 
+// sendToLeader sends the request of the client op to the leader of the partition, and unmarshals
+// the response into the reply.
+func (mp *MetaPartition) sendToLeader(opcode uint8, req, reply interface{}) (err error) {
+	mp.RLock()
+	mr, err := mp.getMetaReplicaLeader()
+	mp.RUnlock()
+	if err != nil {
+		return
+	}
+	packet := proto.NewPacketReqID()
+	packet.Opcode = opcode
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		return
+	}
+	resp, err := mr.metaNode.Sender.syncSendPacket(packet)
+	if err != nil {
+		return
+	}
+	return resp.UnmarshalData(reply)
+}
+
 func resetMetaPartitionTaskID(t *proto.AdminTask, partitionID uint64) {
 	t.ID = fmt.Sprintf("%v_pid[%v]", t.ID, partitionID)
 	t.PartitionID = partitionID
@@ -603,6 +650,9 @@ func (mr *MetaReplica) updateMetric(mgr *proto.MetaPartitionReport) {
 	mr.Status = (int8)(mgr.Status)
 	mr.IsLeader = mgr.IsLeader
 	mr.MaxInodeID = mgr.MaxInodeID
+	mr.RaftHealth = mgr.RaftHealth
+	mr.quotaUsages = mgr.QuotaUsages
+	mr.volSnapshotIDs = mgr.VolSnapshotIDs
 	mr.setLastReportTime()
 }
 
@@ -16,6 +16,7 @@ package master
 
 import (
 	"fmt"
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/log"
 	"strconv"
 	"time"
@@ -177,6 +178,7 @@ func (c *Cluster) checkMetaPartitionRecoveryProgress() {
 
 		if len(newBadMpIds) == 0 {
 			Warn(c.Name, fmt.Sprintf("clusterID[%v],node[%v] has recovered success", c.Name, key))
+			c.events.publish(proto.EventDecommissionFinished, key.(string), "meta partitions decommissioned have recovered")
 			c.BadMetaPartitionIds.Delete(key)
 		} else {
 			c.BadMetaPartitionIds.Store(key, newBadMpIds)
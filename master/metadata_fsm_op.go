@@ -33,6 +33,9 @@ type clusterValue struct {
 	Name                string
 	Threshold           float32
 	DisableAutoAllocate bool
+	BalancePaused       bool
+	RateLimits          []*bsProto.RateLimitRule
+	EncryptionKeys      []*bsProto.EncryptionKey
 }
 
 func newClusterValue(c *Cluster) (cv *clusterValue) {
@@ -40,6 +43,9 @@ func newClusterValue(c *Cluster) (cv *clusterValue) {
 		Name:                c.Name,
 		Threshold:           c.cfg.MetaNodeThreshold,
 		DisableAutoAllocate: c.DisableAutoAllocate,
+		BalancePaused:       c.BalancePaused,
+		RateLimits:          c.getRateLimits(),
+		EncryptionKeys:      c.getEncryptionKeys(),
 	}
 	return cv
 }
@@ -80,6 +86,7 @@ type dataPartitionValue struct {
 	VolID       uint64
 	VolName     string
 	Replicas    []*replicaValue
+	Releasing   bool
 }
 
 type replicaValue struct {
@@ -97,6 +104,7 @@ func newDataPartitionValue(dp *DataPartition) (dpv *dataPartitionValue) {
 		VolID:       dp.VolID,
 		VolName:     dp.VolName,
 		Replicas:    make([]*replicaValue, 0),
+		Releasing:   dp.releasing,
 	}
 	for _, replica := range dp.Replicas {
 		rv := &replicaValue{Addr: replica.Addr, DiskPath: replica.DiskPath}
@@ -118,6 +126,23 @@ type volValue struct {
 	Authenticate      bool
 	OSSAccessKey      string
 	OSSSecretKey      string
+	LifecycleRules    []*bsProto.LifecycleRule
+	MetaStoreMode     string
+	Quotas            []*bsProto.QuotaInfo
+	MaxQuotaID        uint32
+	TrashRetention    uint32
+	Snapshots         []*bsProto.VolSnapshot
+	MaxSnapshotID     uint32
+	AccessKeys        []*bsProto.VolAccessKey
+	Compression       string
+	ECScheme          string
+	ECColdAge         int64
+	HotMedia          string
+	ColdMedia         string
+	TierColdAge       int64
+	ZoneSpread        uint8
+	RackSpread        uint8
+	BucketPolicy      string
 }
 
 func (v *volValue) Bytes() (raw []byte, err error) {
@@ -139,6 +164,23 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		Authenticate:      vol.authenticate,
 		OSSAccessKey:      vol.OSSAccessKey,
 		OSSSecretKey:      vol.OSSSecretKey,
+		LifecycleRules:    vol.lifecycleRules,
+		MetaStoreMode:     vol.metaStoreMode,
+		Quotas:            vol.quotas,
+		MaxQuotaID:        vol.maxQuotaID,
+		TrashRetention:    vol.trashRetention,
+		Snapshots:         vol.snapshots,
+		MaxSnapshotID:     vol.maxSnapshotID,
+		AccessKeys:        vol.accessKeys,
+		Compression:       vol.compression,
+		ECScheme:          vol.ecScheme,
+		ECColdAge:         vol.ecColdAge,
+		HotMedia:          vol.hotMedia,
+		ColdMedia:         vol.coldMedia,
+		TierColdAge:       vol.tierColdAge,
+		ZoneSpread:        vol.zoneSpread,
+		RackSpread:        vol.rackSpread,
+		BucketPolicy:      vol.bucketPolicy,
 	}
 	return
 }
@@ -234,6 +276,10 @@ func (m *RaftCmd) setOpType() {
 		m.Op = opSyncPutCluster
 	case nodeSetAcronym:
 		m.Op = opSyncAddNodeSet
+	case ecNodeAcronym:
+		m.Op = opSyncAddECNode
+	case ecPartitionAcronym:
+		m.Op = opSyncAddECPartition
 	case maxDataPartitionIDKey:
 		m.Op = opSyncAllocDataPartitionID
 	case maxMetaPartitionIDKey:
@@ -460,6 +506,9 @@ func (c *Cluster) loadClusterValue() (err error) {
 		}
 		c.cfg.MetaNodeThreshold = cv.Threshold
 		c.DisableAutoAllocate = cv.DisableAutoAllocate
+		c.BalancePaused = cv.BalancePaused
+		c.updateRateLimits(cv.RateLimits)
+		c.updateEncryptionKeys(cv.EncryptionKeys)
 		log.LogInfof("action[loadClusterValue], metaNodeThreshold[%v]", cv.Threshold)
 	}
 	return
@@ -605,6 +654,7 @@ func (c *Cluster) loadDataPartitions() (err error) {
 		dp := newDataPartition(dpv.PartitionID, dpv.ReplicaNum, dpv.VolName, dpv.VolID)
 		dp.Hosts = strings.Split(dpv.Hosts, underlineSeparator)
 		dp.Peers = dpv.Peers
+		dp.releasing = dpv.Releasing
 		for _, rv := range dpv.Replicas {
 			dp.afterCreation(rv.Addr, rv.DiskPath, c)
 		}
@@ -613,3 +663,149 @@ func (c *Cluster) loadDataPartitions() (err error) {
 	}
 	return
 }
+
+type ecNodeValue struct {
+	ID   uint64
+	Addr string
+}
+
+//key=#en#id#addr,value=json.Marshal(ecNodeValue)
+func (c *Cluster) syncAddECNode(ecNode *ECNode) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncAddECNode
+	metadata.K = ecNodePrefix + strconv.FormatUint(ecNode.ID, 10) + keySeparator + ecNode.Addr
+	metadata.V, err = json.Marshal(&ecNodeValue{ID: ecNode.ID, Addr: ecNode.Addr})
+	if err != nil {
+		return errors.New(err.Error())
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadECNodes() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(ecNodePrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadECNodes],err:%v", err.Error())
+		return err
+	}
+	for _, value := range result {
+		env := &ecNodeValue{}
+		if err = json.Unmarshal(value, env); err != nil {
+			err = fmt.Errorf("action[loadECNodes],value:%v,unmarshal err:%v", string(value), err)
+			return
+		}
+		ecNode := newECNode(env.Addr, c.Name)
+		ecNode.ID = env.ID
+		c.ecNodes.Store(ecNode.Addr, ecNode)
+		log.LogInfof("action[loadECNodes],ecNode[%v]", ecNode.Addr)
+	}
+	return
+}
+
+type ecPartitionValue struct {
+	PartitionID  uint64
+	VolID        uint64
+	VolName      string
+	DataShards   int
+	ParityShards int
+	StripeUnit   uint32
+	Hosts        []string
+	ExtentCount  int
+	Size         uint64
+	MigrateTime  int64
+}
+
+//key=#ep#volID#partitionID,value=json.Marshal(ecPartitionValue)
+func (c *Cluster) syncAddECPartition(ep *ECPartition) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncAddECPartition
+	metadata.K = ecPartitionPrefix + strconv.FormatUint(ep.VolID, 10) + keySeparator + strconv.FormatUint(ep.PartitionID, 10)
+	metadata.V, err = json.Marshal(&ecPartitionValue{
+		PartitionID:  ep.PartitionID,
+		VolID:        ep.VolID,
+		VolName:      ep.VolName,
+		DataShards:   ep.DataShards,
+		ParityShards: ep.ParityShards,
+		StripeUnit:   ep.StripeUnit,
+		Hosts:        ep.Hosts,
+		ExtentCount:  ep.ExtentCount,
+		Size:         ep.Size,
+		MigrateTime:  ep.MigrateTime,
+	})
+	if err != nil {
+		return errors.New(err.Error())
+	}
+	return c.submit(metadata)
+}
+
+// loadECPartitions loads the erasure coded partitions, which replace the data partitions of the
+// same IDs left if the master failed before the data partitions were deleted.
+func (c *Cluster) loadECPartitions() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(ecPartitionPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadECPartitions],err:%v", err.Error())
+		return err
+	}
+	for _, value := range result {
+		epv := &ecPartitionValue{}
+		if err = json.Unmarshal(value, epv); err != nil {
+			err = fmt.Errorf("action[loadECPartitions],value:%v,unmarshal err:%v", string(value), err)
+			return err
+		}
+		vol, err1 := c.getVol(epv.VolName)
+		if err1 != nil || vol.ID != epv.VolID {
+			log.LogErrorf("action[loadECPartitions] vol[%v] of ep[%v] not found", epv.VolName, epv.PartitionID)
+			continue
+		}
+		ep := &ECPartition{
+			PartitionID:  epv.PartitionID,
+			VolID:        epv.VolID,
+			VolName:      epv.VolName,
+			DataShards:   epv.DataShards,
+			ParityShards: epv.ParityShards,
+			StripeUnit:   epv.StripeUnit,
+			Hosts:        epv.Hosts,
+			ExtentCount:  epv.ExtentCount,
+			Size:         epv.Size,
+			MigrateTime:  epv.MigrateTime,
+		}
+		vol.dataPartitions.putECPartition(ep)
+		log.LogInfof("action[loadECPartitions],vol[%v],ep[%v]", vol.Name, ep.PartitionID)
+	}
+	return
+}
+
+//key=#dj#id,value=json.Marshal(bsProto.DecommissionJob)
+func (c *Cluster) syncPutDecommissionJob(job *bsProto.DecommissionJob) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutDecommissionJob
+	metadata.K = decommissionJobPrefix + strconv.FormatUint(job.ID, 10)
+	if metadata.V, err = json.Marshal(job); err != nil {
+		return errors.New(err.Error())
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteDecommissionJob(id uint64) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDelDecommissionJob
+	metadata.K = decommissionJobPrefix + strconv.FormatUint(id, 10)
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadDecommissionJobs() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(decommissionJobPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadDecommissionJobs],err:%v", err.Error())
+		return err
+	}
+	for _, value := range result {
+		job := &bsProto.DecommissionJob{}
+		if err = json.Unmarshal(value, job); err != nil {
+			err = fmt.Errorf("action[loadDecommissionJobs],value:%v,unmarshal err:%v", string(value), err)
+			return err
+		}
+		c.putDecommissionJob(newDecommissionJob(job))
+		log.LogInfof("action[loadDecommissionJobs],job[%v],kind[%v],addr[%v],state[%v]", job.ID, job.Kind, job.Addr, job.State)
+	}
+	return
+}
@@ -111,6 +111,12 @@ func (mds *MockDataServer) serveConn(rc net.Conn) {
 	case proto.OpDataPartitionTryToLeader:
 		err = mds.handleTryToLeader(conn, req, adminTask)
 		fmt.Printf("data node [%v] try to leader,id[%v],err:%v\n", mds.TcpAddr, adminTask.ID, err)
+	case proto.OpAddDataPartitionRaftLearner:
+		err = mds.handleAddDataPartitionRaftMember(conn, req, adminTask)
+		fmt.Printf("data node [%v] add data partition raft learner,id[%v],err:%v\n", mds.TcpAddr, adminTask.ID, err)
+	case proto.OpPromoteDataPartitionRaftLearner:
+		err = mds.handleAddDataPartitionRaftMember(conn, req, adminTask)
+		fmt.Printf("data node [%v] promote data partition raft learner,id[%v],err:%v\n", mds.TcpAddr, adminTask.ID, err)
 	default:
 		fmt.Printf("unknown code [%v]\n", req.Opcode)
 	}
@@ -109,6 +109,12 @@ func (mms *MockMetaServer) serveConn(rc net.Conn) {
 	case proto.OpMetaPartitionTryToLeader:
 		err = mms.handleTryToLeader(conn, req, adminTask)
 		fmt.Printf("meta node [%v] try to leader,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
+	case proto.OpAddMetaPartitionRaftLearner:
+		err = mms.handleAddMetaPartitionRaftMember(conn, req, adminTask)
+		fmt.Printf("meta node [%v] add meta partition raft learner,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
+	case proto.OpPromoteMetaPartitionRaftLearner:
+		err = mms.handleAddMetaPartitionRaftMember(conn, req, adminTask)
+		fmt.Printf("meta node [%v] promote meta partition raft learner,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
 	default:
 		fmt.Printf("unknown code [%v]\n", req.Opcode)
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
 )
 
 //metrics
@@ -41,6 +42,10 @@ const (
 	MetricDiskError            = "disk_error"
 	MetricDataNodesInactive    = "dataNodes_inactive"
 	MetricMetaNodesInactive    = "metaNodes_inactive"
+
+	MetricVolumeTotalBytes = "volume_total_bytes"
+	MetricVolumeUsedBytes  = "volume_used_bytes"
+	MetricVolumeUsageRatio = "volume_usage_ratio"
 )
 
 type monitorMetrics struct {
@@ -136,7 +141,7 @@ func (mm *monitorMetrics) setVolMetrics() {
 		if !ok {
 			return true
 		}
-		labels := map[string]string{"volName": volName}
+		labels := map[string]string{metrics.LabelVolume: volName}
 		volTotalGauge := exporter.NewGauge(MetricVolTotalGB)
 		volTotalGauge.SetWithLabels(int64(volStatInfo.TotalSize/util.GB), labels)
 
@@ -149,6 +154,18 @@ func (mm *monitorMetrics) setVolMetrics() {
 			volUsageRatioGauge.SetWithLabels(int64(usedRatio), labels)
 		}
 
+		if metrics.Enabled() {
+			cluster := mm.cluster.Name
+			metrics.GaugeVec(MetricVolumeTotalBytes, "Capacity of the volume in bytes.", metrics.LabelVolume).
+				WithLabelValues(cluster, volName).Set(float64(volStatInfo.TotalSize))
+			metrics.GaugeVec(MetricVolumeUsedBytes, "Used space of the volume in bytes.", metrics.LabelVolume).
+				WithLabelValues(cluster, volName).Set(float64(volStatInfo.UsedSize))
+			if e == nil {
+				metrics.GaugeVec(MetricVolumeUsageRatio, "Used ratio of the volume.", metrics.LabelVolume).
+					WithLabelValues(cluster, volName).Set(usedRatio)
+			}
+		}
+
 		return true
 	})
 }
@@ -64,6 +64,22 @@ func newAddDataPartitionRaftMemberRequest(ID uint64, addPeer proto.Peer) (req *p
 	return
 }
 
+func newAddDataPartitionRaftLearnerRequest(ID uint64, addLearner proto.Peer) (req *proto.AddDataPartitionRaftLearnerRequest) {
+	req = &proto.AddDataPartitionRaftLearnerRequest{
+		PartitionId: ID,
+		AddLearner:  addLearner,
+	}
+	return
+}
+
+func newPromoteDataPartitionRaftLearnerRequest(ID uint64, learner proto.Peer) (req *proto.PromoteDataPartitionRaftLearnerRequest) {
+	req = &proto.PromoteDataPartitionRaftLearnerRequest{
+		PartitionId:    ID,
+		PromoteLearner: learner,
+	}
+	return
+}
+
 func newRemoveDataPartitionRaftMemberRequest(ID uint64, removePeer proto.Peer) (req *proto.RemoveDataPartitionRaftMemberRequest) {
 	req = &proto.RemoveDataPartitionRaftMemberRequest{
 		PartitionId: ID,
@@ -102,6 +118,10 @@ func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
 		response = &proto.UpdateMetaPartitionResponse{}
 	case proto.OpDecommissionMetaPartition:
 		response = &proto.MetaPartitionDecommissionResponse{}
+	case proto.OpECNodeHeartbeat:
+		response = &proto.ECNodeHeartbeatResponse{}
+	case proto.OpECMigrateDataPartition:
+		response = &proto.ECMigrateResponse{}
 	default:
 		log.LogError(fmt.Sprintf("unknown operate code(%v)", task.OpCode))
 	}
@@ -116,6 +136,15 @@ func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
 	return
 }
 
+// convertTaskRequest converts the request of the task sent back by the node.
+func convertTaskRequest(task *proto.AdminTask, request interface{}) (err error) {
+	bytes, err := json.Marshal(task.Request)
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(bytes, request)
+}
+
 func contains(arr []string, element string) (ok bool) {
 	if arr == nil || len(arr) == 0 {
 		return
@@ -201,6 +230,10 @@ func dataNodeNotFound(addr string) (err error) {
 	return notFoundMsg(fmt.Sprintf("data node[%v]", addr))
 }
 
+func ecNodeNotFound(addr string) (err error) {
+	return notFoundMsg(fmt.Sprintf("ec node[%v]", addr))
+}
+
 func metaNodeNotFound(addr string) (err error) {
 	return notFoundMsg(fmt.Sprintf("meta node[%v]", addr))
 }
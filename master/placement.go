@@ -0,0 +1,502 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The placement policy of a volume requires the replicas of each partition to spread across a
+// minimum number of the failure domains, the zones and the racks reported by the nodes by their
+// configs. A policy is made of the rules, so that another kind of constraint is plugged in by
+// implementing the placementRule. The replicas of the new partitions and the ones replacing the
+// decommissioned replicas are placed as the policy requires, and the creation of a partition is
+// refused if no nodes satisfy it. The replicas of the partitions violating the policy, like the
+// ones created before the policy is set, are moved one replica of one partition of a volume at a
+// time, the same way as a replica is decommissioned.
+//
+// The nodes not reporting a zone or a rack are all in one unnamed zone or rack.
+
+// placementNode is a node a replica is placed on, with its failure domains.
+type placementNode struct {
+	id   uint64
+	addr string
+	zone string
+	rack string
+}
+
+// placementRule is a constraint of the placement of the replicas of a partition.
+type placementRule interface {
+	String() string
+	// gain tells whether adding the node to the chosen ones brings them closer to the rule.
+	gain(chosen []*placementNode, node *placementNode) bool
+	// satisfied tells whether the replicas on the nodes satisfy the rule.
+	satisfied(nodes []*placementNode) bool
+	// crowded returns the node whose replica is moved first to satisfy the rule, nil if none.
+	crowded(nodes []*placementNode) *placementNode
+}
+
+// spreadRule requires the replicas spread across the minimum number of the domains, or all the
+// replicas in distinct domains if there are fewer of them.
+type spreadRule struct {
+	level  string
+	min    int
+	domain func(node *placementNode) string
+}
+
+func newZoneSpreadRule(min int) *spreadRule {
+	return &spreadRule{level: "zones", min: min, domain: func(node *placementNode) string {
+		return node.zone
+	}}
+}
+
+// The racks are named within their zones.
+func newRackSpreadRule(min int) *spreadRule {
+	return &spreadRule{level: "racks", min: min, domain: func(node *placementNode) string {
+		return node.zone + "/" + node.rack
+	}}
+}
+
+func (r *spreadRule) String() string {
+	return fmt.Sprintf("spread across %v %v", r.min, r.level)
+}
+
+func (r *spreadRule) count(nodes []*placementNode) map[string]int {
+	counts := make(map[string]int)
+	for _, node := range nodes {
+		counts[r.domain(node)]++
+	}
+	return counts
+}
+
+func (r *spreadRule) gain(chosen []*placementNode, node *placementNode) bool {
+	counts := r.count(chosen)
+	_, exist := counts[r.domain(node)]
+	return !exist && len(counts) < r.min
+}
+
+func (r *spreadRule) satisfied(nodes []*placementNode) bool {
+	min := r.min
+	if len(nodes) < min {
+		min = len(nodes)
+	}
+	return len(r.count(nodes)) >= min
+}
+
+// crowded returns the last node of the domain with the most replicas.
+func (r *spreadRule) crowded(nodes []*placementNode) (node *placementNode) {
+	if r.satisfied(nodes) {
+		return nil
+	}
+	counts := r.count(nodes)
+	var most int
+	for _, n := range nodes {
+		if count := counts[r.domain(n)]; count > 1 && count >= most {
+			node, most = n, count
+		}
+	}
+	return
+}
+
+// placementPolicy is the rules the placement of the replicas of a partition must satisfy.
+type placementPolicy []placementRule
+
+func newPlacementPolicy(zoneSpread, rackSpread uint8) (policy placementPolicy) {
+	if zoneSpread > 1 {
+		policy = append(policy, newZoneSpreadRule(int(zoneSpread)))
+	}
+	if rackSpread > 1 {
+		policy = append(policy, newRackSpreadRule(int(rackSpread)))
+	}
+	return
+}
+
+func (policy placementPolicy) String() string {
+	rules := make([]string, 0, len(policy))
+	for _, rule := range policy {
+		rules = append(rules, rule.String())
+	}
+	return strings.Join(rules, ", ")
+}
+
+// check returns an error naming the first rule the replicas on the nodes violate.
+func (policy placementPolicy) check(nodes []*placementNode) error {
+	for _, rule := range policy {
+		if !rule.satisfied(nodes) {
+			return fmt.Errorf("replicas on %v violate the placement rule [%v]", placementAddrs(nodes), rule)
+		}
+	}
+	return nil
+}
+
+// choose chooses the replicaNum nodes of the new replicas among the candidates in the order of
+// preference, which together with the kept ones satisfy the policy. A candidate bringing the
+// chosen nodes closer to more rules is chosen first.
+func (policy placementPolicy) choose(kept, candidates []*placementNode, replicaNum int) (chosen []*placementNode, err error) {
+	nodes := append([]*placementNode{}, kept...)
+	used := make(map[string]bool)
+	for _, node := range kept {
+		used[node.addr] = true
+	}
+	for i := 0; i < replicaNum; i++ {
+		var best *placementNode
+		bestGain := -1
+		for _, candidate := range candidates {
+			if used[candidate.addr] {
+				continue
+			}
+			var gain int
+			for _, rule := range policy {
+				if rule.gain(nodes, candidate) {
+					gain++
+				}
+			}
+			if gain > bestGain {
+				best, bestGain = candidate, gain
+			}
+		}
+		if best == nil {
+			return nil, fmt.Errorf("no enough nodes for the placement policy [%v], need %v but %v", policy, replicaNum, i)
+		}
+		used[best.addr] = true
+		nodes = append(nodes, best)
+		chosen = append(chosen, best)
+	}
+	if err = policy.check(nodes); err != nil {
+		return nil, fmt.Errorf("no nodes satisfy the placement policy: %v", err)
+	}
+	return
+}
+
+// crowded returns the node whose replica is moved first to satisfy the policy, nil if none.
+func (policy placementPolicy) crowded(nodes []*placementNode) *placementNode {
+	for _, rule := range policy {
+		if node := rule.crowded(nodes); node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+func placementAddrs(nodes []*placementNode) (addrs []string) {
+	for _, node := range nodes {
+		addrs = append(addrs, node.addr)
+	}
+	return
+}
+
+func placementPeers(nodes []*placementNode) (peers []proto.Peer) {
+	for _, node := range nodes {
+		peers = append(peers, proto.Peer{ID: node.id, Addr: node.addr})
+	}
+	return
+}
+
+func removeHost(hosts []string, addr string) (kept []string) {
+	for _, host := range hosts {
+		if host != addr {
+			kept = append(kept, host)
+		}
+	}
+	return
+}
+
+// placementPolicy returns the placement policy of the volume, empty if none.
+func (vol *Vol) placementPolicy() placementPolicy {
+	vol.RLock()
+	defer vol.RUnlock()
+	return newPlacementPolicy(vol.zoneSpread, vol.rackSpread)
+}
+
+func newDataPlacementNode(node *DataNode) *placementNode {
+	node.RLock()
+	defer node.RUnlock()
+	return &placementNode{id: node.ID, addr: node.Addr, zone: node.ZoneName, rack: node.RackName}
+}
+
+func newMetaPlacementNode(node *MetaNode) *placementNode {
+	node.RLock()
+	defer node.RUnlock()
+	return &placementNode{id: node.ID, addr: node.Addr, zone: node.ZoneName, rack: node.RackName}
+}
+
+// dataPlacementNodes returns the data nodes of the replicas on the hosts.
+func (c *Cluster) dataPlacementNodes(hosts []string) (nodes []*placementNode) {
+	for _, host := range hosts {
+		if dataNode, err := c.dataNode(host); err == nil {
+			nodes = append(nodes, newDataPlacementNode(dataNode))
+		} else {
+			nodes = append(nodes, &placementNode{addr: host})
+		}
+	}
+	return
+}
+
+// metaPlacementNodes returns the meta nodes of the replicas on the hosts.
+func (c *Cluster) metaPlacementNodes(hosts []string) (nodes []*placementNode) {
+	for _, host := range hosts {
+		if metaNode, err := c.metaNode(host); err == nil {
+			nodes = append(nodes, newMetaPlacementNode(metaNode))
+		} else {
+			nodes = append(nodes, &placementNode{addr: host})
+		}
+	}
+	return
+}
+
+// choosePlacedDataNodes chooses the writable data nodes of the media, any if empty, for the new
+// replicas which together with the ones on the kept hosts satisfy the policy. The data nodes with
+// more available space are preferred.
+func (c *Cluster) choosePlacedDataNodes(policy placementPolicy, media string, keptHosts, excludeHosts []string, replicaNum int) (newHosts []string, peers []proto.Peer, err error) {
+	dataNodes := make([]*DataNode, 0)
+	c.dataNodes.Range(func(addr, value interface{}) bool {
+		node := value.(*DataNode)
+		node.RLock()
+		match := (media == "" || node.MediaType == media) && !contains(keptHosts, node.Addr) && !contains(excludeHosts, node.Addr)
+		node.RUnlock()
+		if match && node.isWriteAble() {
+			dataNodes = append(dataNodes, node)
+		}
+		return true
+	})
+	sort.Slice(dataNodes, func(i, j int) bool {
+		return dataNodes[i].AvailableSpace > dataNodes[j].AvailableSpace
+	})
+	candidates := make([]*placementNode, 0, len(dataNodes))
+	for _, node := range dataNodes {
+		candidates = append(candidates, newDataPlacementNode(node))
+	}
+	chosen, err := policy.choose(c.dataPlacementNodes(keptHosts), candidates, replicaNum)
+	if err != nil {
+		return
+	}
+	return placementAddrs(chosen), placementPeers(chosen), nil
+}
+
+// choosePlacedMetaNodes chooses the writable meta nodes for the new replicas which together with
+// the ones on the kept hosts satisfy the policy. The meta nodes with more available memory are
+// preferred.
+func (c *Cluster) choosePlacedMetaNodes(policy placementPolicy, keptHosts, excludeHosts []string, replicaNum int) (newHosts []string, peers []proto.Peer, err error) {
+	metaNodes := make([]*MetaNode, 0)
+	c.metaNodes.Range(func(addr, value interface{}) bool {
+		node := value.(*MetaNode)
+		if !contains(keptHosts, node.Addr) && !contains(excludeHosts, node.Addr) && node.isWritable() {
+			metaNodes = append(metaNodes, node)
+		}
+		return true
+	})
+	sort.Slice(metaNodes, func(i, j int) bool {
+		return metaNodes[i].MaxMemAvailWeight > metaNodes[j].MaxMemAvailWeight
+	})
+	candidates := make([]*placementNode, 0, len(metaNodes))
+	for _, node := range metaNodes {
+		candidates = append(candidates, newMetaPlacementNode(node))
+	}
+	chosen, err := policy.choose(c.metaPlacementNodes(keptHosts), candidates, replicaNum)
+	if err != nil {
+		return
+	}
+	return placementAddrs(chosen), placementPeers(chosen), nil
+}
+
+// chooseDataReplacement chooses the data node of the media replacing the replica of the partition
+// on the addr as the placement policy of the volume requires, or none if the volume has no policy.
+func (c *Cluster) chooseDataReplacement(dp *DataPartition, addr, media string) (targetHosts []string, err error) {
+	vol, err := c.getVol(dp.VolName)
+	if err != nil {
+		return
+	}
+	policy := vol.placementPolicy()
+	if len(policy) == 0 {
+		return
+	}
+	dp.RLock()
+	keptHosts := removeHost(dp.Hosts, addr)
+	dp.RUnlock()
+	targetHosts, _, err = c.choosePlacedDataNodes(policy, media, keptHosts, []string{addr}, 1)
+	return
+}
+
+// chooseMetaReplacement chooses the meta node replacing the replica of the partition on the addr
+// as the placement policy of the volume requires, or none if the volume has no policy.
+func (c *Cluster) chooseMetaReplacement(mp *MetaPartition, addr string) (newPeers []proto.Peer, err error) {
+	vol, err := c.getVol(mp.volName)
+	if err != nil {
+		return
+	}
+	policy := vol.placementPolicy()
+	if len(policy) == 0 {
+		return
+	}
+	mp.RLock()
+	keptHosts := removeHost(mp.Hosts, addr)
+	mp.RUnlock()
+	_, newPeers, err = c.choosePlacedMetaNodes(policy, keptHosts, []string{addr}, 1)
+	return
+}
+
+func (c *Cluster) scheduleToRepairPlacement() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.repairPlacement()
+			}
+			time.Sleep(time.Second * defaultIntervalToRepairPlacement)
+		}
+	}()
+}
+
+func (c *Cluster) repairPlacement() {
+	for _, vol := range c.copyVols() {
+		policy := vol.placementPolicy()
+		if len(policy) == 0 || vol.Status == markDelete {
+			continue
+		}
+		if dp, addr := c.dataReplicaToRepair(vol, policy); dp != nil {
+			if err := c.repairDataPlacement(dp, addr); err != nil {
+				log.LogWarnf("action[repairPlacement] vol[%v] dp[%v] addr[%v] err[%v]", vol.Name, dp.PartitionID, addr, err)
+			}
+		}
+		if mp, addr := c.metaReplicaToRepair(vol, policy); mp != nil {
+			if err := c.repairMetaPlacement(mp, addr); err != nil {
+				log.LogWarnf("action[repairPlacement] vol[%v] mp[%v] addr[%v] err[%v]", vol.Name, mp.PartitionID, addr, err)
+			}
+		}
+	}
+}
+
+// dataReplicaToRepair returns a replica of a data partition violating the policy, or nil if a
+// data partition of the volume is recovering.
+func (c *Cluster) dataReplicaToRepair(vol *Vol, policy placementPolicy) (candidate *DataPartition, addr string) {
+	vol.dataPartitions.RLock()
+	defer vol.dataPartitions.RUnlock()
+	for _, dp := range vol.dataPartitions.partitionMap {
+		dp.RLock()
+		if dp.isRecover {
+			dp.RUnlock()
+			return nil, ""
+		}
+		if candidate == nil && !dp.unavailable && !dp.isMigratingToEC() && !dp.releasing && len(dp.Replicas) == int(dp.ReplicaNum) {
+			if node := policy.crowded(c.dataPlacementNodes(dp.Hosts)); node != nil {
+				candidate, addr = dp, node.addr
+			}
+		}
+		dp.RUnlock()
+	}
+	return
+}
+
+// metaReplicaToRepair returns a replica of a meta partition violating the policy, or nil if a
+// meta partition of the volume is recovering.
+func (c *Cluster) metaReplicaToRepair(vol *Vol, policy placementPolicy) (candidate *MetaPartition, addr string) {
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		mp.RLock()
+		if mp.IsRecover {
+			mp.RUnlock()
+			return nil, ""
+		}
+		if candidate == nil && len(mp.Replicas) == int(mp.ReplicaNum) {
+			if node := policy.crowded(c.metaPlacementNodes(mp.Hosts)); node != nil {
+				candidate, addr = mp, node.addr
+			}
+		}
+		mp.RUnlock()
+	}
+	return
+}
+
+// repairDataPlacement moves the replica of the partition on the addr to a data node of the same
+// media satisfying the placement policy.
+func (c *Cluster) repairDataPlacement(dp *DataPartition, addr string) (err error) {
+	var media string
+	if dataNode, nodeErr := c.dataNode(addr); nodeErr == nil {
+		media = dataNode.MediaType
+	}
+	targetHosts, err := c.chooseDataReplacement(dp, addr, media)
+	if err != nil {
+		return
+	}
+	if len(targetHosts) == 0 {
+		return fmt.Errorf("no placement policy")
+	}
+	if err = c.moveDataReplica(dp, addr, targetHosts[0]); err != nil {
+		return
+	}
+	c.events.publish(proto.EventPlacementRepaired, strconv.FormatUint(dp.PartitionID, 10),
+		"data replica of vol %v moved from %v to %v", dp.VolName, addr, targetHosts[0])
+	return
+}
+
+// repairMetaPlacement moves the replica of the partition on the addr to a meta node satisfying the
+// placement policy.
+func (c *Cluster) repairMetaPlacement(mp *MetaPartition, addr string) (err error) {
+	if err = c.validateDecommissionMetaPartition(mp, addr); err != nil {
+		return
+	}
+	newPeers, err := c.chooseMetaReplacement(mp, addr)
+	if err != nil {
+		return
+	}
+	if len(newPeers) == 0 {
+		return fmt.Errorf("no placement policy")
+	}
+	if err = c.deleteMetaReplica(mp, addr, false); err != nil {
+		return
+	}
+	if err = c.addMetaReplica(mp, newPeers[0].Addr, false); err != nil {
+		return
+	}
+	mp.Lock()
+	mp.IsRecover = true
+	mp.Unlock()
+	c.putBadMetaPartitions(addr, mp.PartitionID)
+	c.events.publish(proto.EventPlacementRepaired, strconv.FormatUint(mp.PartitionID, 10),
+		"meta replica of vol %v moved from %v to %v", mp.volName, addr, newPeers[0].Addr)
+	return
+}
+
+func (c *Cluster) setVolPlacement(name, authKey string, zoneSpread, rackSpread uint8) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if zoneSpread > vol.dpReplicaNum && zoneSpread > vol.mpReplicaNum {
+		return fmt.Errorf("zone spread %v exceeds the replicas", zoneSpread)
+	}
+	if rackSpread > vol.dpReplicaNum && rackSpread > vol.mpReplicaNum {
+		return fmt.Errorf("rack spread %v exceeds the replicas", rackSpread)
+	}
+	oldZoneSpread, oldRackSpread := vol.zoneSpread, vol.rackSpread
+	vol.zoneSpread, vol.rackSpread = zoneSpread, rackSpread
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolPlacement] vol[%v] err[%v]", name, err)
+		vol.zoneSpread, vol.rackSpread = oldZoneSpread, oldRackSpread
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
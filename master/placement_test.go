@@ -0,0 +1,72 @@
+package master
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestPlacementNodes(domains ...string) (nodes []*placementNode) {
+	for i := 0; i < len(domains); i += 2 {
+		nodes = append(nodes, &placementNode{
+			id:   uint64(i/2 + 1),
+			addr: domains[i] + "-" + domains[i+1] + "-" + string(rune('a'+i/2)),
+			zone: domains[i],
+			rack: domains[i+1],
+		})
+	}
+	return
+}
+
+func TestPlacementPolicyChoose(t *testing.T) {
+	// ordered by preference, the first three in one zone
+	candidates := newTestPlacementNodes("z1", "r1", "z1", "r1", "z1", "r2", "z2", "r1", "z3", "r1")
+	policy := newPlacementPolicy(3, 0)
+	chosen, err := policy.choose(nil, candidates, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{candidates[0].addr, candidates[3].addr, candidates[4].addr}
+	if addrs := placementAddrs(chosen); !reflect.DeepEqual(addrs, expect) {
+		t.Fatalf("chosen %v, expect %v", addrs, expect)
+	}
+	// the racks are spread within the zone without the zone rule
+	policy = newPlacementPolicy(0, 2)
+	if chosen, err = policy.choose(nil, candidates[:3], 2); err != nil {
+		t.Fatal(err)
+	}
+	expect = []string{candidates[0].addr, candidates[2].addr}
+	if addrs := placementAddrs(chosen); !reflect.DeepEqual(addrs, expect) {
+		t.Fatalf("chosen %v, expect %v", addrs, expect)
+	}
+	// refused if the nodes cannot satisfy the policy
+	policy = newPlacementPolicy(3, 0)
+	if _, err = policy.choose(nil, candidates[:4], 3); err == nil {
+		t.Fatalf("choose from two zones: expect error")
+	}
+	// the replacement keeps the remaining replicas
+	kept := []*placementNode{candidates[0], candidates[3]}
+	if chosen, err = policy.choose(kept, candidates, 1); err != nil || chosen[0] != candidates[4] {
+		t.Fatalf("replace: chosen %v err %v", placementAddrs(chosen), err)
+	}
+}
+
+func TestPlacementPolicyCrowded(t *testing.T) {
+	nodes := newTestPlacementNodes("z1", "r1", "z1", "r2", "z2", "r1")
+	if node := newPlacementPolicy(2, 3).crowded(nodes); node != nil {
+		t.Fatalf("satisfied placement: crowded %v", node.addr)
+	}
+	policy := newPlacementPolicy(3, 0)
+	if err := policy.check(nodes); err == nil {
+		t.Fatalf("two zones: expect violation")
+	}
+	if node := policy.crowded(nodes); node != nodes[1] {
+		t.Fatalf("crowded %v, expect %v", node, nodes[1].addr)
+	}
+	// the rules are capped by the number of the replicas
+	if err := newPlacementPolicy(3, 3).check(nodes[:2]); err == nil {
+		t.Fatalf("one zone: expect violation")
+	}
+	if err := newPlacementPolicy(3, 3).check([]*placementNode{nodes[0], nodes[2]}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/cryptoutil"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	defaultRevocationRefreshInterval = 10 // in terms of seconds
+)
+
+// revocationList is the tickets revoked by the authnode, fetched periodically. The tickets of a
+// client issued no later than its revoke time are refused.
+type revocationList struct {
+	sync.RWMutex
+	revokeTs map[string]int64
+
+	hosts    []string
+	urlProto string
+	client   *http.Client
+	key      []byte
+	interval time.Duration
+}
+
+func newRevocationList() *revocationList {
+	return &revocationList{revokeTs: make(map[string]int64)}
+}
+
+// start fetches the revocations from the authnodes configured by ticketHost, nothing is done if
+// they are not configured.
+func (rl *revocationList) start(cfg *config.Config, key []byte) (err error) {
+	ticketHost := cfg.GetString(proto.TicketHost)
+	if ticketHost == "" {
+		return
+	}
+	rl.hosts = strings.Split(ticketHost, commaSplit)
+	rl.key = key
+	if cfg.GetBool(proto.EnableHTTPS) {
+		var cert []byte
+		if cert, err = ioutil.ReadFile(cfg.GetString(proto.CertFile)); err != nil {
+			return
+		}
+		if rl.client, err = cryptoutil.CreateClientX(&cert); err != nil {
+			return
+		}
+		rl.urlProto = "https://"
+	} else {
+		rl.client = &http.Client{}
+		rl.urlProto = "http://"
+	}
+	interval := cfg.GetInt64(revocationRefreshInterval)
+	if interval <= 0 {
+		interval = defaultRevocationRefreshInterval
+	}
+	rl.interval = time.Duration(interval) * time.Second
+	go rl.refresh()
+	return
+}
+
+func (rl *revocationList) refresh() {
+	ticker := time.NewTicker(rl.interval)
+	defer ticker.Stop()
+	for {
+		if err := rl.update(); err != nil {
+			log.LogWarnf("action[refreshRevocations] err[%v]", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (rl *revocationList) update() (err error) {
+	var (
+		ts   int64
+		body []byte
+		resp proto.AuthRevocationsResp
+	)
+	req := proto.AuthRevocationsReq{
+		Type:      proto.MsgAuthRevocationsReq,
+		ServiceID: proto.MasterServiceID,
+	}
+	if req.Verifier, ts, err = cryptoutil.GenVerifier(rl.key); err != nil {
+		return
+	}
+	for _, host := range rl.hosts {
+		if body, err = proto.SendData(rl.client, rl.urlProto+host+proto.ServiceGetRevocations, req); err != nil {
+			continue
+		}
+		if resp, err = proto.ParseAuthRevocationsResp(body, rl.key, proto.MasterServiceID, ts); err != nil {
+			continue
+		}
+		revokeTs := make(map[string]int64, len(resp.Revocations))
+		for _, r := range resp.Revocations {
+			revokeTs[r.ClientID] = r.RevokeTs
+		}
+		rl.Lock()
+		rl.revokeTs = revokeTs
+		rl.Unlock()
+		return nil
+	}
+	return fmt.Errorf("get revocations from %v failed: %v", rl.hosts, err)
+}
+
+func (rl *revocationList) isRevoked(ticket *cryptoutil.Ticket) bool {
+	if ticket.ClientID == "" {
+		return false
+	}
+	rl.RLock()
+	revokeTs, ok := rl.revokeTs[ticket.ClientID]
+	rl.RUnlock()
+	return ok && ticket.SessionKey.Ctime <= revokeTs
+}
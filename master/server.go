@@ -76,6 +76,7 @@ type Server struct {
 	wg           sync.WaitGroup
 	reverseProxy *httputil.ReverseProxy
 	metaReady    bool
+	revocations  *revocationList
 }
 
 // NewServer creates a new server
@@ -110,6 +111,9 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 		return
 	}
 	m.initCluster()
+	if err = m.cluster.events.startSinks(m.config.eventSinks); err != nil {
+		return fmt.Errorf("action[Start] failed %v, err: event sinks %v", proto.ErrInvalidCfg, err)
+	}
 	exporter.Init(ModuleName, cfg)
 	m.cluster.partition = m.partition
 	m.cluster.idAlloc.partition = m.partition
@@ -117,6 +121,10 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 	if m.cluster.MasterSecretKey, err = cryptoutil.Base64Decode(MasterSecretKey); err != nil {
 		return fmt.Errorf("action[Start] failed %v, err: master service Key invalid = %s", proto.ErrInvalidCfg, MasterSecretKey)
 	}
+	m.revocations = newRevocationList()
+	if err = m.revocations.start(cfg, m.cluster.MasterSecretKey); err != nil {
+		return fmt.Errorf("action[Start] failed %v, err: fetch revocations %v", proto.ErrInvalidCfg, err)
+	}
 	m.cluster.scheduleTask()
 	m.startHTTPService()
 	exporter.RegistConsul(m.clusterName, ModuleName, cfg)
@@ -220,6 +228,9 @@ func (m *Server) checkConfig(cfg *config.Config) (err error) {
 			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
 		}
 	}
+	for _, sink := range cfg.GetArray(cfgEventSinks) {
+		m.config.eventSinks = append(m.config.eventSinks, fmt.Sprint(sink))
+	}
 	m.tickInterval = int(cfg.GetFloat(cfgTickInterval))
 	m.electionTick = int(cfg.GetFloat(cfgElectionTick))
 	if m.tickInterval <= 300 {
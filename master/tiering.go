@@ -0,0 +1,201 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The data partitions of the volumes with the tiering enabled are created on the data nodes of
+// the hot media, and the replicas of the partitions not read or written for the cold age are moved
+// to the data nodes of the cold media, one replica of one partition of a volume at a time. A replica
+// is moved the same way as it is decommissioned: the new replica is recovered from the others, and
+// the partition is read-only until the recovery finishes. The follower reads skip the new replica
+// during the recovery.
+//
+// The replicas moved to the cold media are not moved back when the partition becomes hot again.
+
+// chooseDataNodesByMedia chooses the writable data nodes of the media with the most available space.
+func (c *Cluster) chooseDataNodesByMedia(media string, excludeHosts []string, replicaNum int) (hosts []string, peers []proto.Peer, err error) {
+	nodes := make([]*DataNode, 0)
+	c.dataNodes.Range(func(addr, value interface{}) bool {
+		node := value.(*DataNode)
+		node.RLock()
+		match := node.MediaType == media && !contains(excludeHosts, node.Addr)
+		node.RUnlock()
+		if match && node.isWriteAble() {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	if len(nodes) < replicaNum {
+		return nil, nil, fmt.Errorf("no enough writable data nodes of media %v, need %v but %v", media, replicaNum, len(nodes))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].AvailableSpace > nodes[j].AvailableSpace
+	})
+	for _, node := range nodes[:replicaNum] {
+		hosts = append(hosts, node.Addr)
+		peers = append(peers, proto.Peer{ID: node.ID, Addr: node.Addr})
+	}
+	return
+}
+
+func (c *Cluster) scheduleToMigrateColdReplicas() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.migrateColdReplicas()
+			}
+			time.Sleep(time.Second * defaultIntervalToMigrateTier)
+		}
+	}()
+}
+
+func (c *Cluster) migrateColdReplicas() {
+	for _, vol := range c.copyVols() {
+		vol.RLock()
+		coldMedia, coldAge := vol.coldMedia, vol.tierColdAge
+		vol.RUnlock()
+		if coldMedia == "" || vol.Status == markDelete {
+			continue
+		}
+		dp, addr := c.replicaToMigrate(vol, coldMedia, coldAge)
+		if dp == nil {
+			continue
+		}
+		if err := c.migrateDataReplica(dp, addr, coldMedia); err != nil {
+			log.LogWarnf("action[migrateColdReplicas] vol[%v] dp[%v] addr[%v] err[%v]", vol.Name, dp.PartitionID, addr, err)
+		}
+	}
+}
+
+// replicaToMigrate returns a replica not on the cold media of a cold data partition, or nil if a
+// partition of the volume is recovering.
+func (c *Cluster) replicaToMigrate(vol *Vol, coldMedia string, coldAge int64) (candidate *DataPartition, addr string) {
+	now := time.Now().Unix()
+	vol.dataPartitions.RLock()
+	defer vol.dataPartitions.RUnlock()
+	for _, dp := range vol.dataPartitions.partitionMap {
+		dp.RLock()
+		if dp.isRecover {
+			dp.RUnlock()
+			return nil, ""
+		}
+		if candidate == nil && dp.isColdToTier(now, coldAge) {
+			for _, host := range dp.Hosts {
+				if dataNode, err := c.dataNode(host); err == nil && dataNode.MediaType != coldMedia {
+					candidate, addr = dp, host
+					break
+				}
+			}
+		}
+		dp.RUnlock()
+	}
+	return
+}
+
+// isColdToTier tells whether no replica of the partition has been accessed for the cold age.
+// The lock of the partition must be held.
+func (partition *DataPartition) isColdToTier(now, coldAge int64) bool {
+	if partition.unavailable || partition.isMigratingToEC() || partition.releasing || len(partition.Replicas) != int(partition.ReplicaNum) {
+		return false
+	}
+	for _, replica := range partition.Replicas {
+		if now-replica.accessTime < coldAge {
+			return false
+		}
+	}
+	return true
+}
+
+// migrateDataReplica moves the replica of the partition on the addr to a data node of the media.
+func (c *Cluster) migrateDataReplica(dp *DataPartition, addr, media string) (err error) {
+	dp.RLock()
+	replica, _ := dp.getReplica(addr)
+	dp.RUnlock()
+	if err = c.validateDecommissionDataPartition(dp, addr); err != nil {
+		return
+	}
+	targetHosts, err := c.chooseDataReplacement(dp, addr, media)
+	if err != nil {
+		return
+	}
+	if len(targetHosts) == 0 {
+		dp.RLock()
+		targetHosts, _, err = c.chooseDataNodesByMedia(media, dp.Hosts, 1)
+		dp.RUnlock()
+		if err != nil {
+			return
+		}
+	}
+	if err = c.removeDataReplica(dp, addr, false); err != nil {
+		return
+	}
+	if err = c.addDataReplica(dp, targetHosts[0], false); err != nil {
+		return
+	}
+	dp.Lock()
+	dp.Status = proto.ReadOnly
+	dp.isRecover = true
+	dp.tierTarget = targetHosts[0]
+	dp.Unlock()
+	c.putBadDataPartitionIDs(replica, addr, dp.PartitionID)
+	log.LogInfof("action[migrateDataReplica] vol[%v] dp[%v] replica moved from %v to %v of media %v",
+		dp.VolName, dp.PartitionID, addr, targetHosts[0], media)
+	return
+}
+
+func (c *Cluster) setVolTiering(name, authKey, hotMedia, coldMedia string, coldAge int64) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	if hotMedia == proto.MediaNone {
+		hotMedia = ""
+	} else if !proto.IsValidMedia(hotMedia) {
+		return fmt.Errorf("invalid hot media %v", hotMedia)
+	}
+	if coldMedia == proto.MediaNone {
+		coldMedia = ""
+	} else if !proto.IsValidMedia(coldMedia) {
+		return fmt.Errorf("invalid cold media %v", coldMedia)
+	}
+	if coldMedia != "" && coldMedia == hotMedia {
+		return fmt.Errorf("the cold media is the same as the hot media %v", hotMedia)
+	}
+	if coldAge <= 0 {
+		coldAge = defaultTierColdAge
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldHotMedia, oldColdMedia, oldColdAge := vol.hotMedia, vol.coldMedia, vol.tierColdAge
+	vol.hotMedia, vol.coldMedia, vol.tierColdAge = hotMedia, coldMedia, coldAge
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolTiering] vol[%v] err[%v]", name, err)
+		vol.hotMedia, vol.coldMedia, vol.tierColdAge = oldHotMedia, oldColdMedia, oldColdAge
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
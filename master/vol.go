@@ -21,6 +21,8 @@ import (
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
+	"strconv"
 	"sync"
 )
 
@@ -40,6 +42,23 @@ type Vol struct {
 	NeedToLowerReplica bool
 	FollowerRead       bool
 	authenticate       bool
+	lifecycleRules     []*proto.LifecycleRule // replaced instead of modified
+	metaStoreMode      string                 // fixed at the creation of the volume
+	quotas             []*proto.QuotaInfo     // replaced instead of modified, without the usage
+	maxQuotaID         uint32                 // the IDs of the deleted quotas are never reused
+	trashRetention     uint32                 // hours the deleted files are kept in the trash, disabled if 0
+	compression        string                 // compression of the extents, none if empty
+	ecScheme           string                 // erasure coding scheme of the cold data partitions, disabled if empty
+	ecColdAge          int64                  // seconds the data partitions must have not been written for before encoded
+	hotMedia           string                 // media of the data nodes the data partitions are created on, any if empty
+	coldMedia          string                 // media of the data nodes the cold data partitions are moved to, disabled if empty
+	tierColdAge        int64                  // seconds the data partitions must have not been accessed for before moved
+	zoneSpread         uint8                  // minimum number of the zones the replicas of a partition spread across
+	rackSpread         uint8                  // minimum number of the racks the replicas of a partition spread across
+	bucketPolicy       string                 // S3 bucket policy in JSON evaluated by the object nodes, none if empty
+	snapshots          []*proto.VolSnapshot   // replaced instead of modified, in the order of creation
+	maxSnapshotID      uint32                 // the IDs of the deleted snapshots are never reused
+	accessKeys         []*proto.VolAccessKey  // replaced instead of modified, besides the one of the owner
 	MetaPartitions     map[uint64]*MetaPartition
 	mpsLock            sync.RWMutex
 	dataPartitions     *DataPartitionMap
@@ -92,6 +111,23 @@ func newVolFromVolValue(vv *volValue) (vol *Vol) {
 	// overwrite oss secure
 	vol.OSSAccessKey, vol.OSSSecretKey = vv.OSSAccessKey, vv.OSSSecretKey
 	vol.Status = vv.Status
+	vol.lifecycleRules = vv.LifecycleRules
+	vol.metaStoreMode = vv.MetaStoreMode
+	vol.quotas = vv.Quotas
+	vol.maxQuotaID = vv.MaxQuotaID
+	vol.trashRetention = vv.TrashRetention
+	vol.compression = vv.Compression
+	vol.ecScheme = vv.ECScheme
+	vol.ecColdAge = vv.ECColdAge
+	vol.hotMedia = vv.HotMedia
+	vol.coldMedia = vv.ColdMedia
+	vol.tierColdAge = vv.TierColdAge
+	vol.zoneSpread = vv.ZoneSpread
+	vol.rackSpread = vv.RackSpread
+	vol.bucketPolicy = vv.BucketPolicy
+	vol.snapshots = vv.Snapshots
+	vol.maxSnapshotID = vv.MaxSnapshotID
+	vol.accessKeys = vv.AccessKeys
 	return vol
 }
 
@@ -189,7 +225,10 @@ func (vol *Vol) checkDataPartitions(c *Cluster) (cnt int) {
 	defer vol.dataPartitions.RUnlock()
 	for _, dp := range vol.dataPartitions.partitionMap {
 		dp.checkReplicaStatus(c.cfg.DataPartitionTimeOutSec)
-		dp.checkStatus(c.Name, true, c.cfg.DataPartitionTimeOutSec)
+		if dp.checkStatus(c.Name, true, c.cfg.DataPartitionTimeOutSec) {
+			c.events.publish(proto.EventPartitionUnavailable, strconv.FormatUint(dp.PartitionID, 10),
+				"data partition of vol %v has no live replicas", vol.Name)
+		}
 
 		dp.checkMissingReplicas(c.Name, c.leaderInfo.addr, c.cfg.MissingDataPartitionInterval, c.cfg.IntervalToAlarmMissingDataPartition)
 		dp.checkReplicaNum(c, vol)
@@ -253,7 +292,10 @@ func (vol *Vol) checkMetaPartitions(c *Cluster) {
 	mps := vol.cloneMetaPartitionMap()
 	for _, mp := range mps {
 
-		mp.checkStatus(c.Name, true, int(vol.mpReplicaNum), maxPartitionID)
+		if mp.checkStatus(c.Name, true, int(vol.mpReplicaNum), maxPartitionID) {
+			c.events.publish(proto.EventPartitionUnavailable, strconv.FormatUint(mp.PartitionID, 10),
+				"meta partition of vol %v has no majority of live replicas", vol.Name)
+		}
 		mp.checkLeader()
 		mp.checkReplicaNum(c, vol.Name, vol.mpReplicaNum)
 		mp.checkEnd(c, maxPartitionID)
@@ -306,6 +348,153 @@ func (vol *Vol) cloneMetaPartitionMap() (mps map[uint64]*MetaPartition) {
 	return
 }
 
+// listQuotas returns the quotas with the usage summed up from the reports of the meta partition leaders.
+func (vol *Vol) listQuotas() (quotas []*proto.QuotaInfo) {
+	vol.RLock()
+	quotas = make([]*proto.QuotaInfo, 0, len(vol.quotas))
+	for _, q := range vol.quotas {
+		quota := *q
+		quotas = append(quotas, &quota)
+	}
+	vol.RUnlock()
+	if len(quotas) == 0 {
+		return
+	}
+	usages := make(map[uint32]*proto.QuotaUsage, len(quotas))
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		mp.RLock()
+		if mr, err := mp.getMetaReplicaLeader(); err == nil {
+			for _, usage := range mr.quotaUsages {
+				sum, ok := usages[usage.QuotaID]
+				if !ok {
+					sum = &proto.QuotaUsage{QuotaID: usage.QuotaID}
+					usages[usage.QuotaID] = sum
+				}
+				sum.UsedFiles += usage.UsedFiles
+				sum.UsedBytes += usage.UsedBytes
+			}
+		}
+		mp.RUnlock()
+	}
+	for _, quota := range quotas {
+		if usage, ok := usages[quota.QuotaID]; ok {
+			quota.UsedFiles, quota.UsedBytes = usage.UsedFiles, usage.UsedBytes
+		}
+		quota.FilesExceeded = quota.MaxFiles > 0 && quota.UsedFiles >= quota.MaxFiles
+		quota.BytesExceeded = quota.MaxBytes > 0 && quota.UsedBytes >= quota.MaxBytes
+	}
+	return
+}
+
+func (vol *Vol) listSnapshots() []*proto.VolSnapshot {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.snapshots
+}
+
+// checkSnapshots advances the snapshot being taken, once all the replicas of the data partitions
+// have frozen all their extents for it, then all the replicas of the meta partitions have frozen
+// their inodes and dentries, and at last all the replicas of the data partitions have marked the
+// extents created so far by the watermark.
+func (vol *Vol) checkSnapshots(c *Cluster) {
+	var taking *proto.VolSnapshot
+	vol.RLock()
+	for _, s := range vol.snapshots {
+		if s.Status != proto.VolSnapshotReady {
+			taking = s
+		}
+	}
+	vol.RUnlock()
+	if taking == nil {
+		return
+	}
+	var status string
+	switch taking.Status {
+	case proto.VolSnapshotFreezingData:
+		if !vol.isDataFrozen(taking.ID, true) {
+			return
+		}
+		status = proto.VolSnapshotFreezingMeta
+	case proto.VolSnapshotFreezingMeta:
+		if !vol.isMetaFrozen(taking.ID) {
+			return
+		}
+		status = proto.VolSnapshotMarkingData
+	case proto.VolSnapshotMarkingData:
+		if !vol.isDataFrozen(taking.ID, false) {
+			return
+		}
+		status = proto.VolSnapshotReady
+	default:
+		return
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	oldSnapshots := vol.snapshots
+	snapshots := make([]*proto.VolSnapshot, 0, len(oldSnapshots))
+	var found bool
+	for _, s := range oldSnapshots {
+		if s == taking {
+			advanced := *s
+			advanced.Status = status
+			s, found = &advanced, true
+		}
+		snapshots = append(snapshots, s)
+	}
+	// the snapshot has been deleted meanwhile
+	if !found {
+		return
+	}
+	vol.snapshots = snapshots
+	if err := c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[checkSnapshots] vol[%v] snapshot[%v] err[%v]", vol.Name, taking.Name, err)
+		vol.snapshots = oldSnapshots
+		return
+	}
+	log.LogInfof("action[checkSnapshots] vol[%v] snapshot[%v] status[%v]", vol.Name, taking.Name, status)
+}
+
+// isDataFrozen tells whether all the replicas of the data partitions have marked the extents for
+// the snapshot by the watermark, or have frozen all the extents for it if freezing.
+func (vol *Vol) isDataFrozen(snapshotID uint32, freezing bool) bool {
+	for _, dp := range vol.cloneDataPartitionMap() {
+		dp.RLock()
+		for _, host := range dp.Hosts {
+			replica, err := dp.getReplica(host)
+			if err != nil || !replica.isFrozen(snapshotID, freezing) {
+				dp.RUnlock()
+				return false
+			}
+		}
+		dp.RUnlock()
+	}
+	return true
+}
+
+func (vol *Vol) isMetaFrozen(snapshotID uint32) bool {
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		mp.RLock()
+		for _, host := range mp.Hosts {
+			mr, err := mp.getMetaReplica(host)
+			if err != nil || !containsSnapshotID(mr.volSnapshotIDs, snapshotID) {
+				mp.RUnlock()
+				return false
+			}
+		}
+		mp.RUnlock()
+	}
+	return true
+}
+
+func containsSnapshotID(ids []uint32, id uint32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
 func (vol *Vol) cloneDataPartitionMap() (dps map[uint64]*DataPartition) {
 	vol.dataPartitions.RLock()
 	defer vol.dataPartitions.RUnlock()
@@ -399,6 +588,10 @@ func (vol *Vol) updateViewCache(c *Cluster) {
 	view := proto.NewVolView(vol.Name, vol.Status, vol.FollowerRead)
 	view.SetOwner(vol.Owner)
 	view.SetOSSSecure(vol.OSSAccessKey, vol.OSSSecretKey)
+	view.TrashRetention = vol.trashRetention
+	vol.RLock()
+	view.AccessKeys = vol.validAccessKeys()
+	vol.RUnlock()
 	mpViews := vol.getMetaPartitionsView()
 	view.MetaPartitions = mpViews
 	mpViewsReply := newSuccessHTTPReply(mpViews)
@@ -465,6 +658,7 @@ func (vol *Vol) checkStatus(c *Cluster) {
 		}
 	}()
 	vol.updateViewCache(c)
+	vol.checkSnapshots(c)
 	vol.Lock()
 	defer vol.Unlock()
 	if vol.Status != markDelete {
@@ -550,6 +744,7 @@ func (vol *Vol) deleteVolFromStore(c *Cluster) {
 	// then delete the volume
 	c.deleteVol(vol.Name)
 	c.volStatInfo.Delete(vol.Name)
+	metrics.DeleteVolume(vol.Name)
 }
 
 func (vol *Vol) deleteMetaPartitionsFromStore(c *Cluster) {
@@ -685,7 +880,12 @@ func (vol *Vol) doCreateMetaPartition(c *Cluster, start, end uint64) (mp *MetaPa
 		wg          sync.WaitGroup
 	)
 	errChannel := make(chan error, vol.mpReplicaNum)
-	if hosts, peers, err = c.chooseTargetMetaHosts(nil, nil, int(vol.mpReplicaNum)); err != nil {
+	if policy := vol.placementPolicy(); len(policy) > 0 {
+		hosts, peers, err = c.choosePlacedMetaNodes(policy, nil, nil, int(vol.mpReplicaNum))
+	} else {
+		hosts, peers, err = c.chooseTargetMetaHosts(nil, nil, int(vol.mpReplicaNum))
+	}
+	if err != nil {
 		return nil, errors.NewError(err)
 	}
 	log.LogInfof("target meta hosts:%v,peers:%v", hosts, peers)
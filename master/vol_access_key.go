@@ -0,0 +1,176 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const accessKeyLetters = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// randomAccessKey returns a string of the letters and digits of the length, which is read from the
+// cryptographic random source. The bytes beyond the largest multiple of the letters are dropped,
+// so that the letters are uniformly distributed.
+func randomAccessKey(length int) (string, error) {
+	const limit = 256 - 256%len(accessKeyLetters)
+	key := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(key) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(key) < length {
+				key = append(key, accessKeyLetters[int(b)%len(accessKeyLetters)])
+			}
+		}
+	}
+	return string(key), nil
+}
+
+// validAccessKeys returns the unexpired access keys of the volume, which are delivered to the
+// object nodes in the view of the volume. The lock of the volume is held by the caller.
+func (vol *Vol) validAccessKeys() (keys []*proto.VolAccessKey) {
+	now := time.Now().Unix()
+	for _, k := range vol.accessKeys {
+		if !k.Expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// listAccessKeys returns the access keys of the volume without the secret keys, along with the
+// expired ones not purged yet.
+func (vol *Vol) listAccessKeys() []*proto.VolAccessKey {
+	vol.RLock()
+	defer vol.RUnlock()
+	keys := make([]*proto.VolAccessKey, 0, len(vol.accessKeys))
+	for _, k := range vol.accessKeys {
+		key := *k
+		key.SecretKey = ""
+		keys = append(keys, &key)
+	}
+	return keys
+}
+
+func checkVolAccessKeyScope(permission string, prefixes []string) error {
+	if permission != proto.AccessKeyReadWrite && permission != proto.AccessKeyReadOnly {
+		return fmt.Errorf("invalid permission[%v], must be %v or %v", permission, proto.AccessKeyReadWrite, proto.AccessKeyReadOnly)
+	}
+	if len(prefixes) > maxVolAccessKeyPrefixes {
+		return fmt.Errorf("more than %v prefixes", maxVolAccessKeyPrefixes)
+	}
+	for _, prefix := range prefixes {
+		if prefix == "" || strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid prefix[%v]", prefix)
+		}
+	}
+	return nil
+}
+
+// createVolAccessKey creates an access key of the volume of the permission, which is scoped by the
+// prefixes of the object keys if any, and expires after the seconds if not 0. The expired access
+// keys are purged meanwhile.
+func (c *Cluster) createVolAccessKey(name, authKey, permission string, prefixes []string, expire int64) (key *proto.VolAccessKey, err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return nil, proto.ErrVolNotExists
+	}
+	if err = checkVolAccessKeyScope(permission, prefixes); err != nil {
+		return
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return nil, proto.ErrVolAuthKeyNotMatch
+	}
+	oldKeys := vol.accessKeys
+	keys := vol.validAccessKeys()
+	if len(keys) >= maxVolAccessKeys {
+		return nil, fmt.Errorf("more than %v access keys", maxVolAccessKeys)
+	}
+	var accessKey, secretKey string
+	if accessKey, err = randomAccessKey(16); err != nil {
+		return
+	}
+	if secretKey, err = randomAccessKey(32); err != nil {
+		return
+	}
+	now := time.Now().Unix()
+	key = &proto.VolAccessKey{
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		Permission: permission,
+		Prefixes:   prefixes,
+		CreateTime: now,
+	}
+	if expire > 0 {
+		key.ExpireTime = now + expire
+	}
+	vol.accessKeys = append(keys, key)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[createVolAccessKey] vol[%v] err[%v]", name, err)
+		vol.accessKeys = oldKeys
+		return nil, proto.ErrPersistenceByRaft
+	}
+	return
+}
+
+// revokeVolAccessKey revokes the access key of the volume, which expires after the seconds of the
+// grace period if not 0, so that the applications are switched to a new key before it is revoked.
+func (c *Cluster) revokeVolAccessKey(name, authKey, accessKey string, grace int64) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	oldKeys := vol.accessKeys
+	keys := make([]*proto.VolAccessKey, 0, len(oldKeys))
+	var found bool
+	for _, k := range oldKeys {
+		if k.AccessKey != accessKey {
+			keys = append(keys, k)
+			continue
+		}
+		found = true
+		if expireTime := time.Now().Unix() + grace; grace > 0 && (k.ExpireTime == 0 || expireTime < k.ExpireTime) {
+			key := *k
+			key.ExpireTime = expireTime
+			keys = append(keys, &key)
+		}
+	}
+	if !found {
+		return fmt.Errorf("access key[%v] not exists", accessKey)
+	}
+	vol.accessKeys = keys
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[revokeVolAccessKey] vol[%v] err[%v]", name, err)
+		vol.accessKeys = oldKeys
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package master
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+func TestVolAccessKey(t *testing.T) {
+	volName := "access-key"
+	// the access keys are kept by the volume alone, which needs no partitions
+	vol, err := server.cluster.doCreateVol(volName, volName, util.DefaultDataPartitionSize, 100, 3, false, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		server.cluster.syncDeleteVol(vol)
+		server.cluster.deleteVol(volName)
+	}()
+	reqURL := fmt.Sprintf("%v%v?name=%v&authKey=%v&permission=%v&prefixes=logs/,images/&expire=3600",
+		hostAddr, proto.AdminCreateVolAccessKey, volName, buildAuthKey(volName), proto.AccessKeyReadOnly)
+	process(reqURL, t)
+	keys := vol.listAccessKeys()
+	if len(keys) != 1 || keys[0].SecretKey != "" || !keys[0].ReadOnly() || len(keys[0].Prefixes) != 2 || keys[0].ExpireTime == 0 {
+		t.Fatalf("unexpected access keys %+v", keys)
+	}
+	readOnlyKey := keys[0].AccessKey
+
+	if _, err = server.cluster.createVolAccessKey(volName, buildAuthKey(volName), "admin", nil, 0); err == nil {
+		t.Fatal("invalid permission accepted")
+	}
+	if _, err = server.cluster.createVolAccessKey(volName, buildAuthKey("other"), proto.AccessKeyReadWrite, nil, 0); err != proto.ErrVolAuthKeyNotMatch {
+		t.Fatalf("created by another owner: err(%v)", err)
+	}
+	key, err := server.cluster.createVolAccessKey(volName, buildAuthKey(volName), proto.AccessKeyReadWrite, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid := vol.validAccessKeys(); len(valid) != 2 || valid[1].SecretKey == "" || valid[1].SecretKey != key.SecretKey {
+		t.Fatalf("unexpected valid access keys %+v", valid)
+	}
+
+	// the access key rotated is kept valid in the grace period
+	if err = server.cluster.revokeVolAccessKey(volName, buildAuthKey(volName), key.AccessKey, 60); err != nil {
+		t.Fatal(err)
+	}
+	if valid := vol.validAccessKeys(); len(valid) != 2 || valid[1].ExpireTime == 0 || valid[1].ExpireTime > time.Now().Unix()+60 {
+		t.Fatalf("unexpected valid access keys %+v", valid)
+	}
+	reqURL = fmt.Sprintf("%v%v?name=%v&authKey=%v&accessKey=%v",
+		hostAddr, proto.AdminRevokeVolAccessKey, volName, buildAuthKey(volName), readOnlyKey)
+	process(reqURL, t)
+	if keys = vol.listAccessKeys(); len(keys) != 1 || keys[0].AccessKey != key.AccessKey {
+		t.Fatalf("unexpected access keys %+v", keys)
+	}
+	if err = server.cluster.revokeVolAccessKey(volName, buildAuthKey(volName), readOnlyKey, 0); err == nil {
+		t.Fatal("revoked access key revoked again")
+	}
+
+	// the expired access keys are not delivered, and purged once another one is created
+	vol.accessKeys[0].ExpireTime = time.Now().Unix()
+	if valid := vol.validAccessKeys(); len(valid) != 0 {
+		t.Fatalf("expired access keys %+v delivered", valid)
+	}
+	if key, err = server.cluster.createVolAccessKey(volName, buildAuthKey(volName), proto.AccessKeyReadWrite, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if keys = vol.listAccessKeys(); len(keys) != 1 || keys[0].AccessKey != key.AccessKey {
+		t.Fatalf("unexpected access keys %+v", keys)
+	}
+	if err = server.cluster.revokeVolAccessKey(volName, buildAuthKey(volName), key.AccessKey, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRandomAccessKey(t *testing.T) {
+	keys := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key, err := randomAccessKey(32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key) != 32 || strings.Trim(key, accessKeyLetters) != "" {
+			t.Fatalf("invalid key[%v]", key)
+		}
+		if keys[key] {
+			t.Fatalf("duplicate key[%v]", key)
+		}
+		keys[key] = true
+	}
+}
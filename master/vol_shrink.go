@@ -0,0 +1,159 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// A volume is shrunk by lowering its capacity and releasing the data partitions beyond the ones
+// the capacity needs. The releasing data partitions are kept read-only and skipped by the balancer,
+// the tiering, the migration to the erasure coding and the repair of the placement. The master
+// cannot tell whether a data partition is referred by the files, so the extents are moved out of
+// them by the cli, which deletes each releasing data partition once no file refers to it.
+
+// dataPartitionsToRelease returns the data partitions beyond the ones to keep, the least used first.
+func dataPartitionsToRelease(partitions []*DataPartition, keep int) (releasing []*DataPartition) {
+	candidates := make([]*DataPartition, 0, len(partitions))
+	used := make(map[uint64]uint64, len(partitions))
+	for _, dp := range partitions {
+		dp.RLock()
+		if !dp.releasing && !dp.isMigratingToEC() {
+			candidates = append(candidates, dp)
+			used[dp.PartitionID] = dp.getMaxUsedSpace()
+		}
+		dp.RUnlock()
+	}
+	if len(candidates) <= keep {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if used[candidates[i].PartitionID] != used[candidates[j].PartitionID] {
+			return used[candidates[i].PartitionID] < used[candidates[j].PartitionID]
+		}
+		return candidates[i].PartitionID > candidates[j].PartitionID
+	})
+	return candidates[:len(candidates)-keep]
+}
+
+// releasingDataPartitions returns the IDs of the data partitions being released.
+func (vol *Vol) releasingDataPartitions() (ids []uint64) {
+	vol.dataPartitions.RLock()
+	defer vol.dataPartitions.RUnlock()
+	for _, dp := range vol.dataPartitions.partitions {
+		dp.RLock()
+		if dp.releasing {
+			ids = append(ids, dp.PartitionID)
+		}
+		dp.RUnlock()
+	}
+	return
+}
+
+// shrinkVol lowers the capacity of the volume to the GB given, and marks the data partitions
+// beyond the ones the capacity needs as releasing.
+func (c *Cluster) shrinkVol(name, authKey string, capacity uint64) (releasing []uint64, err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		return nil, proto.ErrVolNotExists
+	}
+	vol.Lock()
+	defer vol.Unlock()
+	if !matchKey(vol.Owner, authKey) {
+		return nil, proto.ErrVolAuthKeyNotMatch
+	}
+	if capacity == 0 || capacity >= vol.Capacity {
+		return nil, fmt.Errorf("capacity[%v] not less than old capacity[%v]", capacity, vol.Capacity)
+	}
+	if used := vol.totalUsedSpace() / util.GB; capacity <= used {
+		return nil, fmt.Errorf("capacity[%v] not more than used space[%v]", capacity, used)
+	}
+	if len(vol.snapshots) > 0 {
+		return nil, fmt.Errorf("vol[%v] has snapshots holding the data partitions", name)
+	}
+	keep := int((capacity*util.GB + vol.dataPartitionSize - 1) / vol.dataPartitionSize)
+	if keep < minNumOfRWDataPartitions {
+		keep = minNumOfRWDataPartitions
+	}
+	oldCapacity := vol.Capacity
+	vol.Capacity = capacity
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[shrinkVol] vol[%v] err[%v]", name, err)
+		vol.Capacity = oldCapacity
+		return nil, proto.ErrPersistenceByRaft
+	}
+	vol.dataPartitions.RLock()
+	partitions := append([]*DataPartition{}, vol.dataPartitions.partitions...)
+	vol.dataPartitions.RUnlock()
+	for _, dp := range dataPartitionsToRelease(partitions, keep) {
+		dp.Lock()
+		dp.releasing = true
+		if err = c.syncUpdateDataPartition(dp); err != nil {
+			dp.releasing = false
+			dp.Unlock()
+			log.LogErrorf("action[shrinkVol] vol[%v] dp[%v] err[%v]", name, dp.PartitionID, err)
+			return releasing, proto.ErrPersistenceByRaft
+		}
+		dp.Status = proto.ReadOnly
+		dp.Unlock()
+		releasing = append(releasing, dp.PartitionID)
+	}
+	vol.dataPartitions.updateResponseCache(true, 0)
+	log.LogInfof("action[shrinkVol] vol[%v] capacity[%v] to[%v] releasing dps%v", name, oldCapacity, capacity, releasing)
+	return
+}
+
+// releaseDataPartition deletes the releasing data partition, which no file refers to any more.
+func (c *Cluster) releaseDataPartition(name, authKey string, partitionID uint64) (err error) {
+	var (
+		vol *Vol
+		dp  *DataPartition
+	)
+	if vol, err = c.getVol(name); err != nil {
+		return proto.ErrVolNotExists
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if dp, err = vol.getDataPartitionByID(partitionID); err != nil {
+		return proto.ErrDataPartitionNotExists
+	}
+	dp.RLock()
+	releasing := dp.releasing
+	dp.RUnlock()
+	if !releasing {
+		return fmt.Errorf("dp[%v] of vol[%v] is not releasing", partitionID, name)
+	}
+	if err = c.syncDeleteDataPartition(dp); err != nil {
+		log.LogErrorf("action[releaseDataPartition] vol[%v] dp[%v] err[%v]", name, partitionID, err)
+		return proto.ErrPersistenceByRaft
+	}
+	vol.dataPartitions.del(dp)
+	vol.dataPartitions.updateResponseCache(true, 0)
+	dp.RLock()
+	tasks := make([]*proto.AdminTask, 0, len(dp.Hosts))
+	for _, host := range dp.Hosts {
+		tasks = append(tasks, dp.createTaskToDeleteDataPartition(host))
+	}
+	dp.RUnlock()
+	c.addDataNodeTasks(tasks)
+	log.LogInfof("action[releaseDataPartition] vol[%v] dp[%v] released", name, partitionID)
+	return
+}
@@ -0,0 +1,25 @@
+package master
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDataPartitionsToRelease(t *testing.T) {
+	var partitions []*DataPartition
+	for id := uint64(1); id <= 5; id++ {
+		dp := newDataPartition(id, 3, "vol", 1)
+		dp.used = id * 10
+		partitions = append(partitions, dp)
+	}
+	// the released partition and the one migrating to the erasure coding are not counted
+	partitions[0].releasing = true
+	partitions[4].ecMigrateTime = time.Now().Unix()
+	releasing := dataPartitionsToRelease(partitions, 2)
+	if len(releasing) != 1 || releasing[0].PartitionID != 2 {
+		t.Fatalf("releasing %v, expect dp 2", releasing)
+	}
+	if releasing = dataPartitionsToRelease(partitions, 3); len(releasing) != 0 {
+		t.Fatalf("releasing %v, expect none", releasing)
+	}
+}
@@ -18,10 +18,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 
 	"bytes"
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/fault"
+	"github.com/chubaofs/chubaofs/util/health"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
@@ -57,9 +62,46 @@ func (m *MetaNode) registerAPIHandler() (err error) {
 	http.HandleFunc("/getDentry", m.getDentryHandler)
 	http.HandleFunc("/getDirectory", m.getDirectoryHandler)
 	http.HandleFunc("/getAllDentry", m.getAllDentriesHandler)
+	// get the counters of the metadata operations
+	http.HandleFunc("/getOpStats", m.getOpStatsHandler)
+
+	health.AddCheck("state", m.checkState)
+	health.AddCheck("raft", m.checkRaft)
+	health.AddCheck("memory", m.checkMemory)
+	health.RegisterHTTP()
+	fault.RegisterHTTP()
 	return
 }
 
+func (m *MetaNode) checkState() error {
+	if !m.control.IsRunning() {
+		return errors.New("not running")
+	}
+	if m.nodeId == 0 {
+		return errors.New("not registered to master")
+	}
+	return nil
+}
+
+func (m *MetaNode) checkRaft() error {
+	if m.raftStore == nil || m.metadataManager == nil {
+		return errors.New("raft is not started")
+	}
+	return m.metadataManager.CheckRaft()
+}
+
+// checkMemory reports the metanode overloaded if the memory of the process exceeds the configured total memory.
+func (m *MetaNode) checkMemory() error {
+	used, err := util.GetProcessMemory(os.Getpid())
+	if err != nil {
+		return err
+	}
+	if configTotalMem > 0 && used >= configTotalMem {
+		return fmt.Errorf("overloaded: memory used(%v) total(%v)", used, configTotalMem)
+	}
+	return nil
+}
+
 func (m *MetaNode) getPartitionsHandler(w http.ResponseWriter,
 	r *http.Request) {
 	resp := NewAPIResponse(http.StatusOK, http.StatusText(http.StatusOK))
@@ -70,6 +112,15 @@ func (m *MetaNode) getPartitionsHandler(w http.ResponseWriter,
 	}
 }
 
+func (m *MetaNode) getOpStatsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := NewAPIResponse(http.StatusOK, http.StatusText(http.StatusOK))
+	resp.Data = m.metadataManager.OpStats()
+	data, _ := resp.Marshal()
+	if _, err := w.Write(data); err != nil {
+		log.LogErrorf("[getOpStatsHandler] response %s", err)
+	}
+}
+
 func (m *MetaNode) getPartitionByIDHandler(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	resp := NewAPIResponse(http.StatusBadRequest, "")
@@ -97,6 +148,7 @@ func (m *MetaNode) getPartitionByIDHandler(w http.ResponseWriter, r *http.Reques
 	msg["peers"] = conf.Peers
 	msg["nodeId"] = conf.NodeId
 	msg["cursor"] = conf.Cursor
+	msg["raftStatus"] = m.raftStore.RaftStatus(pid)
 	resp.Data = msg
 	resp.Code = http.StatusOK
 	resp.Msg = http.StatusText(http.StatusOK)
@@ -128,7 +180,7 @@ func (m *MetaNode) getAllInodesHandler(w http.ResponseWriter, r *http.Request) {
 
 	var inode *Inode
 
-	f := func(i BtreeItem) bool {
+	f := func(ino *Inode) bool {
 		var (
 			data []byte
 			e    error
@@ -141,7 +193,7 @@ func (m *MetaNode) getAllInodesHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		inode = i.(*Inode)
+		inode = ino
 		if data, e = inode.MarshalToJSON(); e != nil {
 			log.LogErrorf("[getAllInodesHandler] failed to marshal to json: %v", e)
 			return false
@@ -155,7 +207,9 @@ func (m *MetaNode) getAllInodesHandler(w http.ResponseWriter, r *http.Request) {
 		return true
 	}
 
-	mp.GetInodeTree().Ascend(f)
+	if e := mp.RangeInodes(f); e != nil {
+		log.LogErrorf("[getAllInodesHandler] failed to range inodes: %v", e)
+	}
 }
 
 func (m *MetaNode) getInodeHandler(w http.ResponseWriter, r *http.Request) {
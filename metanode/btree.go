@@ -27,9 +27,13 @@ type (
 )
 
 // BTree is the wrapper of Google's btree.
+// With a loader the btree is a cache of a backing store, the missing items are loaded on lookups.
 type BTree struct {
 	sync.RWMutex
-	tree *btree.BTree
+	tree       *btree.BTree
+	loader     func(key BtreeItem) BtreeItem
+	version    uint64 // increased on removals, so that the loads started before are not cached
+	evictPivot BtreeItem
 }
 
 // NewBtree creates a new btree.
@@ -39,8 +43,46 @@ func NewBtree() *BTree {
 	}
 }
 
+// SetLoader makes the btree a cache of the backing store which the loader reads from.
+// The loader returns nil if the key does not exist. Only the lookups of single keys load the
+// missing items, while the ranges see the cached ones only, so the ranges of such a tree must
+// read the backing store instead, as the scans of the inode tree in the rocksdb store mode do.
+func (b *BTree) SetLoader(loader func(key BtreeItem) BtreeItem) {
+	b.loader = loader
+}
+
+// load loads the item of the given key by the loader and caches it.
+func (b *BTree) load(key BtreeItem) (item BtreeItem) {
+	b.RLock()
+	version := b.version
+	b.RUnlock()
+	if item = b.loader(key); item == nil {
+		return
+	}
+	b.Lock()
+	if cached := b.tree.Get(key); cached != nil {
+		item = cached
+	} else if version == b.version {
+		// an item removed during the load may have been loaded before the removal is persisted
+		b.tree.ReplaceOrInsert(item)
+	}
+	b.Unlock()
+	return
+}
+
 // Get returns the object of the given key in the btree.
 func (b *BTree) Get(key BtreeItem) (item BtreeItem) {
+	b.RLock()
+	item = b.tree.Get(key)
+	b.RUnlock()
+	if item == nil && b.loader != nil {
+		item = b.load(key)
+	}
+	return
+}
+
+// GetCached returns the object of the given key in the btree without loading it.
+func (b *BTree) GetCached(key BtreeItem) (item BtreeItem) {
 	b.RLock()
 	item = b.tree.Get(key)
 	b.RUnlock()
@@ -51,14 +93,15 @@ func (b *BTree) CopyGet(key BtreeItem) (item BtreeItem) {
 	b.Lock()
 	item = b.tree.CopyGet(key)
 	b.Unlock()
+	if item == nil && b.loader != nil {
+		item = b.load(key)
+	}
 	return
 }
 
 // Find searches for the given key in the btree.
 func (b *BTree) Find(key BtreeItem, fn func(i BtreeItem)) {
-	b.RLock()
-	item := b.tree.Get(key)
-	b.RUnlock()
+	item := b.Get(key)
 	if item == nil {
 		return
 	}
@@ -66,6 +109,9 @@ func (b *BTree) Find(key BtreeItem, fn func(i BtreeItem)) {
 }
 
 func (b *BTree) CopyFind(key BtreeItem, fn func(i BtreeItem)) {
+	if b.loader != nil {
+		b.Get(key)
+	}
 	b.Lock()
 	item := b.tree.CopyGet(key)
 	fn(item)
@@ -77,6 +123,9 @@ func (b *BTree) Has(key BtreeItem) (ok bool) {
 	b.RLock()
 	ok = b.tree.Has(key)
 	b.RUnlock()
+	if !ok && b.loader != nil {
+		ok = b.load(key) != nil
+	}
 	return
 }
 
@@ -84,12 +133,48 @@ func (b *BTree) Has(key BtreeItem) (ok bool) {
 func (b *BTree) Delete(key BtreeItem) (item BtreeItem) {
 	b.Lock()
 	item = b.tree.Delete(key)
+	b.version++
 	b.Unlock()
 	return
 }
 
+// Evict removes the cached objects over the limit in the key order, starting from where the last eviction stopped.
+// It only works for the btree with a loader.
+func (b *BTree) Evict(limit int) (count int) {
+	b.Lock()
+	defer b.Unlock()
+	if b.loader == nil || b.tree.Len() <= limit {
+		return
+	}
+	// evict a tenth more than needed so that it is not done on every insertion
+	target := b.tree.Len() - limit + limit/10
+	var keys = make([]BtreeItem, 0, target)
+	var collect = func(i BtreeItem) bool {
+		keys = append(keys, i)
+		return len(keys) < target
+	}
+	if b.evictPivot != nil {
+		b.tree.AscendGreaterOrEqual(b.evictPivot, collect)
+	}
+	if len(keys) < target {
+		b.tree.Ascend(collect)
+	}
+	for _, key := range keys {
+		if b.tree.Delete(key) != nil {
+			count++
+		}
+	}
+	b.evictPivot = keys[len(keys)-1]
+	b.version++
+	return
+}
+
 // ReplaceOrInsert is the wrapper of google's btree ReplaceOrInsert.
 func (b *BTree) ReplaceOrInsert(key BtreeItem, replace bool) (item BtreeItem, ok bool) {
+	if !replace && b.loader != nil {
+		// cache the stored object so that it is not overwritten
+		b.Get(key)
+	}
 	b.Lock()
 	if replace {
 		item = b.tree.ReplaceOrInsert(key)
@@ -147,6 +232,7 @@ func (b *BTree) GetTree() *BTree {
 func (b *BTree) Reset() {
 	b.Lock()
 	b.tree.Clear(true)
+	b.version++
 	b.Unlock()
 }
 
@@ -0,0 +1,76 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+)
+
+func TestBTree_Loader(t *testing.T) {
+	var stored = make(map[uint64]*Inode)
+	for ino := uint64(1); ino <= 100; ino++ {
+		stored[ino] = NewInode(ino, 0)
+	}
+	var tree = NewBtree()
+	tree.SetLoader(func(key BtreeItem) BtreeItem {
+		if ino, ok := stored[key.(*Inode).Inode]; ok {
+			return ino
+		}
+		return nil
+	})
+
+	// the missing items are loaded and cached
+	if item := tree.Get(NewInode(1, 0)); item == nil || item.(*Inode) != stored[1] {
+		t.Fatalf("load item mismatch: %v", item)
+	}
+	if tree.GetCached(NewInode(1, 0)) == nil || tree.GetCached(NewInode(2, 0)) != nil {
+		t.Fatalf("cached items mismatch")
+	}
+	if !tree.Has(NewInode(2, 0)) || tree.Has(NewInode(101, 0)) {
+		t.Fatalf("has items mismatch")
+	}
+	if _, ok := tree.ReplaceOrInsert(NewInode(3, 0), false); ok {
+		t.Fatalf("stored item overwritten")
+	}
+
+	// the item loaded during a removal is not cached
+	tree.SetLoader(func(key BtreeItem) BtreeItem {
+		tree.Delete(NewInode(1, 0))
+		return stored[key.(*Inode).Inode]
+	})
+	if item := tree.Get(NewInode(4, 0)); item == nil {
+		t.Fatalf("load item failed")
+	}
+	if tree.GetCached(NewInode(4, 0)) != nil {
+		t.Fatalf("stale item cached")
+	}
+
+	// the items over the limit are evicted
+	tree.SetLoader(func(key BtreeItem) BtreeItem {
+		return stored[key.(*Inode).Inode]
+	})
+	for ino := uint64(1); ino <= 100; ino++ {
+		tree.Get(NewInode(ino, 0))
+	}
+	if count := tree.Evict(50); count != 55 || tree.Len() != 45 {
+		t.Fatalf("evict mismatch: count(%v) len(%v)", count, tree.Len())
+	}
+	if count := tree.Evict(50); count != 0 {
+		t.Fatalf("evict under the limit: count(%v)", count)
+	}
+	if item := tree.Get(NewInode(1, 0)); item == nil || tree.Len() != 46 {
+		t.Fatalf("reload evicted item failed: %v", item)
+	}
+}
@@ -99,6 +99,22 @@ const (
 	opFSMCreateMultipart
 	opFSMRemoveMultipart
 	opFSMAppendMultipart
+	opFSMCompleteMultipart
+	opFSMSetACL
+	opFSMTrashDentry
+	opFSMRestoreTrash
+	opFSMPurgeTrash
+	opFSMFreezeVolSnapshot
+	opFSMDropVolSnapshot
+	opVolSnapshot
+	opVolSnapshotInode
+	opVolSnapshotDentry
+	opFSMSetLock
+	opFSMRenewLocks
+	opFSMInodeLocks
+	opFSMMarkDeleteTree
+	opFSMFinishDeleteTree
+	opFSMExtentsAddIfGeneration
 )
 
 var (
@@ -115,19 +131,43 @@ const (
 	defaultMetadataDir = "metadataDir"
 	defaultRaftDir     = "raftDir"
 	defaultAuthTimeout = 5 // seconds
+
+	// the max number of inodes cached by a partition in the rocksdb store mode
+	defaultInodeCacheCount = 1 << 20
+
+	// the max number of the raft logs committed but not applied by a partition of a ready metanode
+	readyRaftApplyLag = 10000
 )
 
 // Configuration keys
 const (
-	cfgLocalIP           = "localIP"
-	cfgListen            = "listen"
-	cfgMetadataDir       = "metadataDir"
-	cfgRaftDir           = "raftDir"
-	cfgMasterAddrs       = "masterAddrs" // will be deprecated
-	cfgMasterAddr        = "masterAddr"
-	cfgRaftHeartbeatPort = "raftHeartbeatPort"
-	cfgRaftReplicaPort   = "raftReplicaPort"
-	cfgTotalMem          = "totalMem"
+	cfgLocalIP                   = "localIP"
+	cfgListen                    = "listen"
+	cfgMetadataDir               = "metadataDir"
+	cfgRaftDir                   = "raftDir"
+	cfgMasterAddrs               = "masterAddrs" // will be deprecated
+	cfgMasterAddr                = "masterAddr"
+	cfgRaftHeartbeatPort         = "raftHeartbeatPort"
+	cfgRaftReplicaPort           = "raftReplicaPort"
+	cfgRaftPreVote               = "raftPreVote"
+	cfgRaftWalCompression        = "raftWalCompression"
+	cfgRaftWalPreAllocate        = "raftWalPreAllocate"
+	cfgRaftWalRecycleFiles       = "raftWalRecycleFiles"
+	cfgRaftSnapshotRate          = "raftSnapshotRate"
+	cfgRaftSnapshotResumeTimeout = "raftSnapshotResumeTimeout"
+	cfgRaftSendLinger            = "raftSendLinger"
+	cfgRaftWalDir                = "raftWalDir"
+	cfgMinClientVersion          = "minClientVersion"
+	cfgMultipartTTL              = "multipartTTL"
+	cfgTotalMem                  = "totalMem"
+	cfgInodeCacheCount           = "inodeCacheCount"
+	cfgZone                      = "zone"
+	cfgRack                      = "rack"
+	cfgAuditLogDir               = "auditLogDir"
+	cfgAuditLogSize              = "auditLogSize" // MB
+	cfgAuditLogBackups           = "auditLogBackups"
+	cfgAuditLogRemote            = "auditLogRemote"
+	cfgGRPCPort                  = "grpcPort"
 )
 
 const (
@@ -75,6 +75,10 @@ func (e *Extend) Less(than btree.Item) bool {
 	return is && e.inode < ext.inode
 }
 
+func (e *Extend) GetInode() (inode uint64) {
+	return e.inode
+}
+
 func (e *Extend) Put(key, value []byte) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
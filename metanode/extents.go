@@ -83,10 +83,20 @@ func (e *ExtentsTree) MarshalJSON() ([]byte, error) {
 	return e.Marshal()
 }
 
+func sameExtentRange(a, b *proto.ExtentKey) bool {
+	return a.FileOffset == b.FileOffset && a.PartitionId == b.PartitionId && a.ExtentId == b.ExtentId &&
+		a.ExtentOffset == b.ExtentOffset && a.Size == b.Size
+}
+
 // Append appends a btree item to the extent tree.
 func (e *ExtentsTree) Append(key BtreeItem) (items []BtreeItem) {
 	var delItems []BtreeItem
 	ext := key.(*proto.ExtentKey)
+	// re-appending an existing key only updates its digest, the newer keys over it are kept
+	if item := e.Get(key); item != nil && sameExtentRange(item.(*proto.ExtentKey), ext) {
+		e.ReplaceOrInsert(key, true)
+		return
+	}
 	lessFileOffset := ext.FileOffset + uint64(ext.Size)
 	e.AscendRange(key, &proto.ExtentKey{FileOffset: lessFileOffset},
 		func(item BtreeItem) bool {
@@ -364,6 +364,8 @@ func (i *Inode) ExtentsTruncate(exts []BtreeItem, length uint64, ct int64) {
 		ext := item.(*proto.ExtentKey)
 		if (ext.FileOffset + uint64(ext.Size)) > length {
 			ext.Size = uint32(length - ext.FileOffset)
+			// the digest of the write range is not valid for the remaining data
+			ext.CRC = 0
 		}
 	}
 	i.Size = length
@@ -433,7 +435,7 @@ func (i *Inode) ShouldDelete() bool {
 // SetAttr sets the attributes of the inode.
 func (i *Inode) SetAttr(valid, mode, uid, gid uint32) {
 	i.Lock()
-	if mode&proto.AttrMode != 0 {
+	if valid&proto.AttrMode != 0 {
 		i.Type = mode
 	}
 	if valid&proto.AttrUid != 0 {
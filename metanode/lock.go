@@ -0,0 +1,121 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/btree"
+)
+
+// InodeLocks is the byte-range locks of an inode. The items in the lock tree are never modified
+// but replaced, so that they are read without the locks.
+type InodeLocks struct {
+	Inode uint64             `json:"ino"`
+	Locks []*proto.RangeLock `json:"locks"`
+}
+
+// NewInodeLocks returns the locks of the inode.
+func NewInodeLocks(ino uint64) *InodeLocks {
+	return &InodeLocks{Inode: ino}
+}
+
+// InodeLocksFromBytes unmarshals the locks of an inode.
+func InodeLocksFromBytes(raw []byte) (*InodeLocks, error) {
+	l := &InodeLocks{}
+	if err := json.Unmarshal(raw, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Bytes marshals the locks of the inode.
+func (l *InodeLocks) Bytes() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+func (l *InodeLocks) Less(than btree.Item) bool {
+	thanLocks, is := than.(*InodeLocks)
+	return is && l.Inode < thanLocks.Inode
+}
+
+func (l *InodeLocks) Copy() btree.Item {
+	return &InodeLocks{
+		Inode: l.Inode,
+		Locks: append([]*proto.RangeLock(nil), l.Locks...),
+	}
+}
+
+// conflict returns a lock not expired at the time which conflicts with the lock, or nil.
+func (l *InodeLocks) conflict(lock *proto.RangeLock, now int64) *proto.RangeLock {
+	for _, held := range l.Locks {
+		if held.Expire > now && held.Conflicts(lock) {
+			return held
+		}
+	}
+	return nil
+}
+
+// set returns the locks after the lock or the unlock of the owner is applied as fcntl does. The
+// ranges of the owner overlapped are replaced by the lock, and split if partly overlapped. The
+// locks expired at the time are dropped.
+func (l *InodeLocks) set(lock *proto.RangeLock, now int64) (locks *InodeLocks, status uint8) {
+	if lock.Type != proto.LockUnlock && l.conflict(lock, now) != nil {
+		return nil, proto.OpExistErr
+	}
+	locks = &InodeLocks{Inode: l.Inode, Locks: make([]*proto.RangeLock, 0, len(l.Locks)+2)}
+	for _, held := range l.Locks {
+		switch {
+		case held.Expire <= now:
+		case !held.SameOwner(lock) || !held.Overlaps(lock):
+			locks.Locks = append(locks.Locks, held)
+		default:
+			if held.Start < lock.Start {
+				left := *held
+				left.End = lock.Start - 1
+				locks.Locks = append(locks.Locks, &left)
+			}
+			if held.End > lock.End {
+				right := *held
+				right.Start = lock.End + 1
+				locks.Locks = append(locks.Locks, &right)
+			}
+		}
+	}
+	if lock.Type != proto.LockUnlock {
+		locks.Locks = append(locks.Locks, lock)
+	}
+	return locks, proto.OpOk
+}
+
+// renew returns the locks with the leases of the client renewed, and the number of them. The
+// locks expired at the time are dropped.
+func (l *InodeLocks) renew(client uint64, now, expire int64) (locks *InodeLocks, count int) {
+	locks = &InodeLocks{Inode: l.Inode, Locks: make([]*proto.RangeLock, 0, len(l.Locks))}
+	for _, held := range l.Locks {
+		switch {
+		case held.Expire <= now:
+		case held.Client == client:
+			renewed := *held
+			renewed.Expire = expire
+			locks.Locks = append(locks.Locks, &renewed)
+			count++
+		default:
+			locks.Locks = append(locks.Locks, held)
+		}
+	}
+	return
+}
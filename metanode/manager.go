@@ -26,14 +26,20 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/chubaofs/chubaofs/cmd/common"
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
 	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/audit"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/fault"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 const partitionPrefix = "partition_"
@@ -45,13 +51,21 @@ type MetadataManager interface {
 	//CreatePartition(id string, start, end uint64, peers []proto.Peer) error
 	HandleMetadataOperation(conn net.Conn, p *Packet, remoteAddr string) error
 	GetPartition(id uint64) (MetaPartition, error)
+	OpStats() []metrics.OpStat
+	CheckRaft() error
 }
 
 // MetadataManagerConfig defines the configures in the metadata manager.
 type MetadataManagerConfig struct {
-	NodeID    uint64
-	RootDir   string
-	RaftStore raftstore.RaftStore
+	NodeID           uint64
+	RootDir          string
+	RaftStore        raftstore.RaftStore
+	MinClientVersion uint32
+	MultipartTTL     time.Duration // the multipart uploads are aborted after it, unless it is 0
+	InodeCacheCount  int           // the max number of inodes cached by a partition in the rocksdb store mode
+	ZoneName         string        // the zone of the node reported to the master for the placement of the replicas
+	RackName         string        // the rack of the node reported to the master for the placement of the replicas
+	AuditLogger      *audit.Logger // the operations changing the namespace are recorded by it unless it is nil
 }
 
 type metadataManager struct {
@@ -62,13 +76,50 @@ type metadataManager struct {
 	state      uint32
 	mu         sync.RWMutex
 	partitions map[uint64]MetaPartition // Key: metaRangeId, Val: metaPartition
+	opStats    *metrics.OpStats
+	limiter    *ratelimit.Limiter
+
+	minClientVersion uint32
+	multipartTTL     time.Duration
+	inodeCacheCount  int
+	zoneName         string
+	rackName         string
+	auditLogger      *audit.Logger
 }
 
 // HandleMetadataOperation handles the metadata operations.
 func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
+	start := time.Now()
 	metric := exporter.NewTPCnt(p.GetOpMsg())
 	defer metric.Set(err)
+	defer func() {
+		m.logSlowOp(p, remoteAddr, start, err)
+		m.opStats.Record(p.GetOpMsg(), start, err)
+	}()
+	if p.Trace != nil {
+		p.span = tracing.StartSpan("metanode."+p.GetOpMsg(), p.Trace)
+		p.span.SetTag("partition", p.PartitionID)
+		p.span.SetTag("remote", remoteAddr)
+		defer func() {
+			p.span.Finish(err)
+		}()
+	}
+	if fault.Enabled {
+		if err = fault.Inject(fault.MetaOp(p.GetOpMsg())); err != nil {
+			// a dropped packet is not replied, the client has to time out
+			if err != fault.ErrDropped {
+				p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+				m.respondToClient(conn, p)
+			}
+			return
+		}
+	}
+	if err = m.limitRate(p, remoteAddr); err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
 
 	switch p.Opcode {
 	case proto.OpMetaCreateInode:
@@ -121,12 +172,18 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 		err = m.opRemoveMetaPartitionRaftMember(conn, p, remoteAddr)
 	case proto.OpMetaPartitionTryToLeader:
 		err = m.opMetaPartitionTryToLeader(conn, p, remoteAddr)
+	case proto.OpAddMetaPartitionRaftLearner:
+		err = m.opAddMetaPartitionRaftLearner(conn, p, remoteAddr)
+	case proto.OpPromoteMetaPartitionRaftLearner:
+		err = m.opPromoteMetaPartitionRaftLearner(conn, p, remoteAddr)
 	case proto.OpMetaBatchInodeGet:
 		err = m.opMetaBatchInodeGet(conn, p, remoteAddr)
 	case proto.OpMetaDeleteInode:
 		err = m.opMetaDeleteInode(conn, p, remoteAddr)
 	case proto.OpMetaBatchExtentsAdd:
 		err = m.opMetaBatchExtentsAdd(conn, p, remoteAddr)
+	case proto.OpMetaCloneExtents:
+		err = m.opMetaCloneExtents(conn, p, remoteAddr)
 	// operations for extend attributes
 	case proto.OpMetaSetXAttr:
 		err = m.opMetaSetXAttr(conn, p, remoteAddr)
@@ -134,10 +191,36 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 		err = m.opMetaGetXAttr(conn, p, remoteAddr)
 	case proto.OpMetaBatchGetXAttr:
 		err = m.opMetaBatchGetXAttr(conn, p, remoteAddr)
+	case proto.OpMetaBatchSetXAttr:
+		err = m.opMetaBatchSetXAttr(conn, p, remoteAddr)
 	case proto.OpMetaRemoveXAttr:
 		err = m.opMetaRemoveXAttr(conn, p, remoteAddr)
 	case proto.OpMetaListXAttr:
 		err = m.opMetaListXAttr(conn, p, remoteAddr)
+	case proto.OpMetaSetACL:
+		err = m.opMetaSetACL(conn, p, remoteAddr)
+	case proto.OpMetaGetACL:
+		err = m.opMetaGetACL(conn, p, remoteAddr)
+	case proto.OpMetaTrashDentry:
+		err = m.opMetaTrashDentry(conn, p, remoteAddr)
+	case proto.OpMetaListTrash:
+		err = m.opMetaListTrash(conn, p, remoteAddr)
+	case proto.OpMetaRestoreTrash:
+		err = m.opMetaRestoreTrash(conn, p, remoteAddr)
+	case proto.OpMetaDeleteTree:
+		err = m.opMetaDeleteTree(conn, p, remoteAddr)
+	case proto.OpMetaListDeleteTrees:
+		err = m.opMetaListDeleteTrees(conn, p, remoteAddr)
+	case proto.OpMetaSetLock:
+		err = m.opMetaSetLock(conn, p, remoteAddr)
+	case proto.OpMetaGetLock:
+		err = m.opMetaGetLock(conn, p, remoteAddr)
+	case proto.OpMetaRenewLocks:
+		err = m.opMetaRenewLocks(conn, p, remoteAddr)
+	case proto.OpMetaReverseLookup:
+		err = m.opMetaReverseLookup(conn, p, remoteAddr)
+	case proto.OpMetaListDentry:
+		err = m.opMetaListDentry(conn, p, remoteAddr)
 	// operations for multipart session
 	case proto.OpCreateMultipart:
 		err = m.opCreateMultipart(conn, p, remoteAddr)
@@ -149,9 +232,18 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 		err = m.opAppendMultipart(conn, p, remoteAddr)
 	case proto.OpGetMultipart:
 		err = m.opGetMultipart(conn, p, remoteAddr)
+	case proto.OpCompleteMultipart:
+		err = m.opCompleteMultipart(conn, p, remoteAddr)
+	case proto.OpMetaBatch:
+		err = m.opMetaBatch(conn, p, remoteAddr)
+	case proto.OpProtoHandshake:
+		err = m.opProtoHandshake(conn, p, remoteAddr)
 	default:
 		err = fmt.Errorf("%s unknown Opcode: %d, reqId: %d", remoteAddr,
 			p.Opcode, p.GetReqID())
+		// reply to the ops of the newer clients instead of leaving them to time out
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
 	}
 	if err != nil {
 		err = errors.NewErrorf("%s [%s] req: %d - %s", remoteAddr, p.GetOpMsg(),
@@ -160,6 +252,94 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 	return
 }
 
+// limitRate limits the ops of the clients by the rate limits of the metanode module, the ops of
+// the master are never limited. Every op of a batch is charged under its own opcode, and the
+// batch is rejected if any of them is limited.
+func (m *metadataManager) limitRate(p *Packet, remoteAddr string) error {
+	switch p.Opcode {
+	case proto.OpMetaNodeHeartbeat, proto.OpCreateMetaPartition, proto.OpDeleteMetaPartition,
+		proto.OpUpdateMetaPartition, proto.OpLoadMetaPartition, proto.OpDecommissionMetaPartition,
+		proto.OpAddMetaPartitionRaftMember, proto.OpRemoveMetaPartitionRaftMember,
+		proto.OpMetaPartitionTryToLeader, proto.OpAddMetaPartitionRaftLearner,
+		proto.OpPromoteMetaPartitionRaftLearner, proto.OpProtoHandshake:
+		return nil
+	}
+	client, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		client = remoteAddr
+	}
+	if p.Opcode == proto.OpMetaBatch {
+		req := &proto.MetaBatchRequest{}
+		if err = json.Unmarshal(p.Data, req); err != nil || len(req.Ops) > proto.MetaBatchMaxOps {
+			// the malformed batch is replied by the op
+			return nil
+		}
+		mp, err := m.getPartition(req.PartitionID)
+		if err != nil {
+			return nil
+		}
+		vol := mp.GetBaseConfig().VolName
+		for _, op := range req.Ops {
+			sub := &proto.Packet{Opcode: op.Opcode}
+			if err = m.limiter.Allow(vol, sub.GetOpMsg(), client); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	mp, err := m.getPartition(p.PartitionID)
+	if err != nil {
+		// the unknown partition is replied by the op
+		return nil
+	}
+	return m.limiter.Allow(mp.GetBaseConfig().VolName, p.GetOpMsg(), client)
+}
+
+func (m *metadataManager) logSlowOp(p *Packet, remoteAddr string, start time.Time, err error) {
+	op := &log.SlowOp{
+		Op:        p.GetOpMsg(),
+		Partition: p.PartitionID,
+		ReqID:     p.ReqID,
+		Remote:    remoteAddr,
+		Exec:      time.Since(start),
+		Detail:    fmt.Sprintf("Raft(%v)", p.raftTime),
+		Err:       err,
+	}
+	if !p.recvTime.IsZero() {
+		op.Wait = start.Sub(p.recvTime)
+	}
+	if op.Err == nil && p.ResultCode != proto.OpOk {
+		op.Err = errors.New(p.GetResultMsg())
+	}
+	log.LogSlowOp(op)
+}
+
+// auditOp records the operation changing the namespace in the audit log, with the inode replied
+// unless the entry has it. The op proxied by a follower is recorded by the leader with the address
+// of the follower as the client.
+func (m *metadataManager) auditOp(p *Packet, remoteAddr string, e *audit.Entry) {
+	if m.auditLogger == nil {
+		return
+	}
+	e.Op, e.ReqID, e.Result = p.GetOpMsg(), p.GetReqID(), p.GetResultMsg()
+	if e.Client, _, _ = net.SplitHostPort(remoteAddr); e.Client == "" {
+		e.Client = remoteAddr
+	}
+	if e.Inode == 0 && p.ResultCode == proto.OpOk && int(p.Size) <= len(p.Data) {
+		// the replies carry either the inode or the info of it
+		reply := &struct {
+			Inode uint64           `json:"ino"`
+			Info  *proto.InodeInfo `json:"info"`
+		}{}
+		if json.Unmarshal(p.Data[:p.Size], reply) == nil {
+			if e.Inode = reply.Inode; reply.Info != nil {
+				e.Inode = reply.Info.Inode
+			}
+		}
+	}
+	m.auditLogger.Log(e)
+}
+
 // Start starts the metadata manager.
 func (m *metadataManager) Start() (err error) {
 	if atomic.CompareAndSwapUint32(&m.state, common.StateStandby, common.StateStart) {
@@ -325,7 +505,7 @@ func (m *metadataManager) detachPartition(id uint64) (err error) {
 }
 
 func (m *metadataManager) createPartition(id uint64, volName string, start,
-	end uint64, peers []proto.Peer) (err error) {
+	end uint64, peers []proto.Peer, storeMode string) (err error) {
 	// check partitions
 	if _, err = m.getPartition(id); err == nil {
 		err = errors.NewErrorf("create partition id=%d is exsited!", id)
@@ -341,6 +521,7 @@ func (m *metadataManager) createPartition(id uint64, volName string, start,
 		End:         end,
 		Cursor:      start,
 		Peers:       peers,
+		StoreMode:   storeMode,
 		RaftStore:   m.raftStore,
 		NodeId:      m.nodeId,
 		RootDir:     path.Join(m.rootDir, partitionPrefix+partitionId),
@@ -407,5 +588,37 @@ func NewMetadataManager(conf MetadataManagerConfig) MetadataManager {
 		rootDir:    conf.RootDir,
 		raftStore:  conf.RaftStore,
 		partitions: make(map[uint64]MetaPartition),
+		opStats:    metrics.NewOpStats(),
+		limiter:    ratelimit.NewLimiter(ratelimit.ModuleMetaNode),
+
+		minClientVersion: conf.MinClientVersion,
+		multipartTTL:     conf.MultipartTTL,
+		inodeCacheCount:  conf.InodeCacheCount,
+		zoneName:         conf.ZoneName,
+		rackName:         conf.RackName,
+		auditLogger:      conf.AuditLogger,
+	}
+}
+
+// OpStats returns the counters of the metadata operations handled by the metanode.
+func (m *metadataManager) OpStats() []metrics.OpStat {
+	return m.opStats.Snapshot()
+}
+
+// CheckRaft returns an error if any meta partition has no leader, or lags behind the commit of
+// its raft group by too many logs, for the readiness of the metanode.
+func (m *metadataManager) CheckRaft() error {
+	var noLeader, lagging []uint64
+	m.Range(func(id uint64, mp MetaPartition) bool {
+		if leader, _ := mp.IsLeader(); leader == "" {
+			noLeader = append(noLeader, id)
+		} else if health := mp.RaftHealth(); health == nil || health.ApplyLag > readyRaftApplyLag {
+			lagging = append(lagging, id)
+		}
+		return true
+	})
+	if len(noLeader) > 0 || len(lagging) > 0 {
+		return fmt.Errorf("partitions without leader%v, partitions not applying%v", noLeader, lagging)
 	}
+	return nil
 }
@@ -0,0 +1,163 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Handle OpMetaBatch packet. The ops of the batch are executed in order on the partition of the
+// batch, and the failure of an op does not stop the following ones.
+func (m *metadataManager) opMetaBatch(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.MetaBatchRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[opMetaBatch]: %s", err.Error())
+		return
+	}
+	if len(req.Ops) > proto.MetaBatchMaxOps {
+		err = fmt.Errorf("too many ops %v, the maximum is %v", len(req.Ops), proto.MetaBatchMaxOps)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[opMetaBatch]: %s", err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[opMetaBatch] %s, req: %s", err.Error(), string(p.Data))
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	resp := &proto.MetaBatchResponse{Results: make([]*proto.MetaBatchResult, 0, len(req.Ops))}
+	for _, op := range req.Ops {
+		sub := &Packet{span: p.span}
+		sub.Magic = proto.ProtoMagic
+		sub.Opcode = op.Opcode
+		sub.PartitionID = req.PartitionID
+		sub.ReqID = p.ReqID
+		sub.Data = op.Data
+		sub.Size = uint32(len(op.Data))
+		if e := m.executeBatchOp(mp, sub); e != nil {
+			log.LogWarnf("%s [opMetaBatch] req: %d - %v, err: %v", remoteAddr, p.GetReqID(), sub.GetOpMsg(), e)
+		}
+		p.raftTime += sub.raftTime
+		resp.Results = append(resp.Results, &proto.MetaBatchResult{
+			ResultCode: sub.ResultCode,
+			Data:       sub.Data[:sub.Size],
+		})
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+	} else {
+		p.PacketOkWithBody(reply)
+	}
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaBatch] req: %d - %v ops, resp: %v", remoteAddr, p.GetReqID(),
+		len(req.Ops), p.GetResultMsg())
+	return
+}
+
+// executeBatchOp executes an op of a batch on the partition, and leaves the result in the packet.
+// Only the ops of a single request and reply on the same partition may be batched.
+func (m *metadataManager) executeBatchOp(mp MetaPartition, p *Packet) (err error) {
+	switch p.Opcode {
+	case proto.OpMetaLookup:
+		req := &LookupReq{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.Lookup(req, p)
+		}
+	case proto.OpMetaInodeGet:
+		req := &InodeGetReq{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.InodeGet(req, p)
+		}
+	case proto.OpMetaBatchInodeGet:
+		req := &InodeGetReqBatch{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.InodeGetBatch(req, p)
+		}
+	case proto.OpMetaReadDir:
+		req := &ReadDirReq{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.ReadDir(req, p)
+		}
+	case proto.OpMetaListDentry:
+		req := &proto.ListDentryRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.ListDentry(req, p)
+		}
+	case proto.OpMetaExtentsList:
+		req := &proto.GetExtentsRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.ExtentsList(req, p)
+		}
+	case proto.OpMetaSetattr:
+		err = mp.SetAttr(p.Data, p)
+	case proto.OpMetaSetXAttr:
+		req := &proto.SetXAttrRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.SetXAttr(req, p)
+		}
+	case proto.OpMetaBatchSetXAttr:
+		req := &proto.BatchSetXAttrRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.BatchSetXAttr(req, p)
+		}
+	case proto.OpMetaGetXAttr:
+		req := &proto.GetXAttrRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.GetXAttr(req, p)
+		}
+	case proto.OpMetaBatchGetXAttr:
+		req := &proto.BatchGetXAttrRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.BatchGetXAttr(req, p)
+		}
+	case proto.OpMetaRemoveXAttr:
+		req := &proto.RemoveXAttrRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.RemoveXAttr(req, p)
+		}
+	case proto.OpMetaListXAttr:
+		req := &proto.ListXAttrRequest{}
+		if err = unmarshalBatchOp(p, req); err == nil {
+			err = mp.ListXAttr(req, p)
+		}
+	default:
+		err = fmt.Errorf("op %v can not be batched", p.GetOpMsg())
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+	}
+	return
+}
+
+func unmarshalBatchOp(p *Packet, req interface{}) (err error) {
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+	}
+	return
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/audit"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
 	raftProto "github.com/tiglabs/raft/proto"
@@ -44,6 +45,10 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 			Request: req,
 		}
 	)
+	resp.ProtocolVersion = proto.ProtocolVersion
+	resp.MinClientVersion = m.minClientVersion
+	resp.ZoneName = m.zoneName
+	resp.RackName = m.rackName
 	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
 	decode.UseNumber()
 	if err = decode.Decode(adminTask); err != nil {
@@ -51,6 +56,7 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 		resp.Result = err.Error()
 		goto end
 	}
+	m.limiter.Update(req.RateLimits)
 
 	// collect memory info
 	resp.Total = configTotalMem
@@ -74,6 +80,14 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 			mpr.Status = proto.Unavailable
 		}
 		mpr.IsLeader = isLeader
+		mpr.RaftHealth = partition.RaftHealth()
+		if isLeader {
+			mpr.QuotaUsages = partition.QuotaUsages()
+			if req.VolSnapshots != nil {
+				partition.SyncVolSnapshots(req.VolSnapshots[mConf.VolName])
+			}
+		}
+		mpr.VolSnapshotIDs = partition.VolSnapshotIDs()
 		if mConf.Cursor >= mConf.End {
 			mpr.Status = proto.ReadOnly
 		}
@@ -121,7 +135,7 @@ func (m *metadataManager) opCreateMetaPartition(conn net.Conn, p *Packet,
 		" master message: %v", remoteAddr, adminTask)
 	// create a new meta partition.
 	if err = m.createPartition(req.PartitionID, req.VolName,
-		req.Start, req.End, req.Members); err != nil {
+		req.Start, req.End, req.Members, req.StoreMode); err != nil {
 		err = errors.NewErrorf("[opCreateMetaPartition]->%s; request message: %v",
 			err.Error(), adminTask.Request)
 		return
@@ -152,6 +166,7 @@ func (m *metadataManager) opCreateInode(conn net.Conn, p *Packet,
 	err = mp.CreateInode(req, p)
 	// reply the operation result to the client through TCP
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID})
 	log.LogDebugf("%s [opCreateInode] req: %d - %v, resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -176,6 +191,7 @@ func (m *metadataManager) opMetaLinkInode(conn net.Conn, p *Packet,
 	}
 	err = mp.CreateInodeLink(req, p)
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Inode: req.Inode})
 	log.LogDebugf("%s [opMetaLinkInode] req: %d - %v, resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -217,6 +233,8 @@ func (m *metadataManager) opCreateDentry(conn net.Conn, p *Packet,
 	}
 	err = mp.CreateDentry(req, p)
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Parent: req.ParentID,
+		Name: req.Name, Inode: req.Inode})
 	log.LogDebugf("%s [opCreateDentry] req: %d - %v, resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -242,6 +260,7 @@ func (m *metadataManager) opDeleteDentry(conn net.Conn, p *Packet,
 	}
 	err = mp.DeleteDentry(req, p)
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Parent: req.ParentID, Name: req.Name})
 	log.LogDebugf("%s [opDeleteDentry] req: %d - %v, resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -266,6 +285,7 @@ func (m *metadataManager) opUpdateDentry(conn net.Conn, p *Packet,
 	}
 	err = mp.UpdateDentry(req, p)
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Parent: req.ParentID, Name: req.Name})
 	log.LogDebugf("%s [opUpdateDentry] req: %d - %v; resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -290,6 +310,7 @@ func (m *metadataManager) opMetaUnlinkInode(conn net.Conn, p *Packet,
 	}
 	err = mp.UnlinkInode(req, p)
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Inode: req.Inode})
 	log.LogDebugf("%s [opDeleteInode] req: %d - %v, resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -404,6 +425,8 @@ func (m *metadataManager) opSetAttr(conn net.Conn, p *Packet,
 		err = errors.NewErrorf("[opSetAttr] req: %v, error: %s", req, err.Error())
 	}
 	m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Inode: req.Inode,
+		Detail: fmt.Sprintf("valid(%v) mode(%o) uid(%v) gid(%v)", req.Valid, req.Mode, req.Uid, req.Gid)})
 	log.LogDebugf("%s [opSetAttr] req: %d - %v, resp: %v, body: %s", remoteAddr,
 		p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -803,6 +826,130 @@ func (m *metadataManager) opRemoveMetaPartitionRaftMember(conn net.Conn,
 	return
 }
 
+func (m *metadataManager) opAddMetaPartitionRaftLearner(conn net.Conn,
+	p *Packet, remoteAddr string) (err error) {
+	var reqData []byte
+	req := &proto.AddMetaPartitionRaftLearnerRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpTryOtherAddr, ([]byte)(proto.ErrMetaPartitionNotExists.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+
+	if mp.IsExsitPeer(req.AddLearner) {
+		p.PacketOkReply()
+		m.respondToClient(conn, p)
+		return
+	}
+
+	if !m.serveProxy(conn, mp, p) {
+		return nil
+	}
+	if req.AddLearner.ID == 0 {
+		err = errors.NewErrorf("[opAddMetaPartitionRaftLearner]: partitionID= %d, "+
+			"Marshal %s", req.PartitionId, fmt.Sprintf("unavali AddLearnerID %v", req.AddLearner.ID))
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	req.AddLearner.Learner = true
+	reqData, err = json.Marshal(&proto.MetaPartitionDecommissionRequest{
+		PartitionID: req.PartitionId,
+		AddPeer:     req.AddLearner,
+	})
+	if err != nil {
+		err = errors.NewErrorf("[opAddMetaPartitionRaftLearner]: partitionID= %d, "+
+			"Marshal %s", req.PartitionId, err)
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	_, err = mp.ChangeMember(raftProto.ConfAddLearner,
+		raftProto.Peer{ID: req.AddLearner.ID}, reqData)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+
+	return
+}
+
+func (m *metadataManager) opPromoteMetaPartitionRaftLearner(conn net.Conn,
+	p *Packet, remoteAddr string) (err error) {
+	var reqData []byte
+	req := &proto.PromoteMetaPartitionRaftLearnerRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpTryOtherAddr, ([]byte)(proto.ErrMetaPartitionNotExists.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+
+	if !m.serveProxy(conn, mp, p) {
+		return nil
+	}
+	promote, err := mp.CanPromoteRaftLearner(req.PromoteLearner)
+	if err != nil {
+		err = errors.NewErrorf("[opPromoteMetaPartitionRaftLearner]: partitionID= %d, "+
+			"learner(%v) %s", req.PartitionId, req.PromoteLearner, err)
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	if !promote {
+		// promoted already
+		p.PacketOkReply()
+		m.respondToClient(conn, p)
+		return
+	}
+	reqData, err = json.Marshal(&proto.MetaPartitionDecommissionRequest{
+		PartitionID: req.PartitionId,
+		AddPeer:     req.PromoteLearner,
+	})
+	if err != nil {
+		err = errors.NewErrorf("[opPromoteMetaPartitionRaftLearner]: partitionID= %d, "+
+			"Marshal %s", req.PartitionId, err)
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	_, err = mp.ChangeMember(raftProto.ConfPromoteLearner,
+		raftProto.Peer{ID: req.PromoteLearner.ID}, reqData)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+
+	return
+}
+
 func (m *metadataManager) opMetaBatchInodeGet(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
 	req := &proto.BatchInodeGetRequest{}
@@ -887,6 +1034,29 @@ func (m *metadataManager) opMetaSetXAttr(conn net.Conn, p *Packet, remoteAddr st
 	return
 }
 
+func (m *metadataManager) opMetaBatchSetXAttr(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.BatchSetXAttrRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.BatchSetXAttr(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaBatchSetXAttr] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
 func (m *metadataManager) opMetaGetXAttr(conn net.Conn, p *Packet, remoteAddr string) (err error) {
 	req := &proto.GetXAttrRequest{}
 	if err = json.Unmarshal(p.Data, req); err != nil {
@@ -979,6 +1149,286 @@ func (m *metadataManager) opMetaListXAttr(conn net.Conn, p *Packet, remoteAddr s
 	return
 }
 
+func (m *metadataManager) opMetaSetACL(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.SetACLRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.SetACL(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaSetACL] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaGetACL(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.GetACLRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.GetACL(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaGetACL] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaTrashDentry(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.TrashDentryRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.TrashDentry(req, p)
+	_ = m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Parent: req.ParentID, Name: req.Name})
+	log.LogDebugf("%s [opMetaTrashDentry] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaListTrash(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.ListTrashRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.ListTrash(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaListTrash] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaRestoreTrash(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.RestoreTrashRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.RestoreTrash(req, p)
+	_ = m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Parent: req.ParentID,
+		Name: req.Name, Detail: "trash " + req.TrashName})
+	log.LogDebugf("%s [opMetaRestoreTrash] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaDeleteTree(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.DeleteTreeRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.DeleteTree(req, p)
+	_ = m.respondToClient(conn, p)
+	m.auditOp(p, remoteAddr, &audit.Entry{Vol: req.VolName, Partition: req.PartitionID, Parent: req.ParentID, Name: req.Name})
+	log.LogDebugf("%s [opMetaDeleteTree] req: %d - %v, resp: %v",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg())
+	return
+}
+
+func (m *metadataManager) opMetaListDeleteTrees(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.ListDeleteTreesRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.ListDeleteTrees(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaListDeleteTrees] req: %d - %v, resp: %v",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg())
+	return
+}
+
+func (m *metadataManager) opMetaSetLock(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.SetLockRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.SetLock(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaSetLock] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaGetLock(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.GetLockRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.GetLock(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaGetLock] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaRenewLocks(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.RenewLocksRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.RenewLocks(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaRenewLocks] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaReverseLookup(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.ReverseLookupRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.ReverseLookup(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaReverseLookup] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaListDentry(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.ListDentryRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.ListDentry(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaListDentry] req: %d - %v, resp: %v",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg())
+	return
+}
+
 func (m *metadataManager) opMetaBatchExtentsAdd(conn net.Conn, p *Packet, remoteAddr string) (err error) {
 	req := &proto.AppendExtentKeysRequest{}
 	if err = json.Unmarshal(p.Data, req); err != nil {
@@ -1002,6 +1452,29 @@ func (m *metadataManager) opMetaBatchExtentsAdd(conn net.Conn, p *Packet, remote
 	return
 }
 
+func (m *metadataManager) opMetaCloneExtents(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.CloneExtentsRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.CloneExtents(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaCloneExtents] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
 func (m *metadataManager) opCreateMultipart(conn net.Conn, p *Packet, remote string) (err error) {
 	req := &proto.CreateMultipartRequest{}
 	if err = json.Unmarshal(p.Data, req); err != nil {
@@ -1065,6 +1538,27 @@ func (m *metadataManager) opGetMultipart(conn net.Conn, p *Packet, remote string
 	return
 }
 
+func (m *metadataManager) opCompleteMultipart(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.CompleteMultipartRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		_ = m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.CompleteMultipart(req, p)
+	_ = m.respondToClient(conn, p)
+	return
+}
+
 func (m *metadataManager) opAppendMultipart(conn net.Conn, p *Packet, remote string) (err error) {
 	defer func() {
 		if err != nil {
@@ -1107,3 +1601,11 @@ func (m *metadataManager) opListMultipart(conn net.Conn, p *Packet, remote strin
 	_ = m.respondToClient(conn, p)
 	return
 }
+
+// Handle OpProtoHandshake packet.
+func (m *metadataManager) opProtoHandshake(conn net.Conn, p *Packet, remote string) (err error) {
+	p.PacketHandshakeReply(m.minClientVersion)
+	err = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opProtoHandshake] req: %d - resp: %v", remote, p.GetReqID(), p.GetResultMsg())
+	return
+}
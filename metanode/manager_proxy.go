@@ -31,7 +31,7 @@ const (
 func (m *metadataManager) serveProxy(conn net.Conn, mp MetaPartition,
 	p *Packet) (ok bool) {
 	var (
-		mConn      *net.TCPConn
+		mConn      net.Conn
 		leaderAddr string
 		err        error
 	)
@@ -67,11 +67,12 @@ func (m *metadataManager) serveProxy(conn net.Conn, mp MetaPartition,
 	m.connPool.PutConnect(mConn, NoClosedConnect)
 end:
 	m.respondToClient(conn, p)
+	entry := log.WithPartition(p.PartitionID).WithReqID(p.ReqID)
 	if err != nil {
-		log.LogErrorf("[serveProxy]: req: %d - %v, %s", p.GetReqID(),
+		entry.LogErrorf("[serveProxy]: req: %d - %v, %s", p.GetReqID(),
 			p.GetOpMsg(), err.Error())
 	}
-	log.LogDebugf("[serveProxy] req: %d - %v, resp: %v", p.GetReqID(), p.GetOpMsg(),
+	entry.LogDebugf("[serveProxy] req: %d - %v, resp: %v", p.GetReqID(), p.GetOpMsg(),
 		p.GetResultMsg())
 	return
 }
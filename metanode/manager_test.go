@@ -0,0 +1,55 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
+)
+
+func TestMetadataManager_LimitBatch(t *testing.T) {
+	m := &metadataManager{
+		partitions: map[uint64]MetaPartition{
+			1: &metaPartition{config: &MetaPartitionConfig{PartitionId: 1, VolName: "vol"}},
+		},
+		limiter: ratelimit.NewLimiter(ratelimit.ModuleMetaNode),
+	}
+	m.limiter.Update([]*proto.RateLimitRule{
+		{Module: ratelimit.ModuleMetaNode, Op: "OpMetaLookup", Rate: 0.001, Burst: 2},
+	})
+	batch := func(ops ...uint8) *Packet {
+		req := &proto.MetaBatchRequest{VolName: "vol", PartitionID: 1}
+		for _, op := range ops {
+			req.Ops = append(req.Ops, &proto.MetaBatchOp{Opcode: op})
+		}
+		p := &Packet{}
+		p.Opcode = proto.OpMetaBatch
+		p.Data, _ = json.Marshal(req)
+		return p
+	}
+
+	if err := m.limitRate(batch(proto.OpMetaInodeGet, proto.OpMetaInodeGet, proto.OpMetaInodeGet), "10.0.0.1:1000"); err != nil {
+		t.Fatalf("batch of unlimited ops: err(%v)", err)
+	}
+	if err := m.limitRate(batch(proto.OpMetaInodeGet, proto.OpMetaLookup, proto.OpMetaLookup, proto.OpMetaLookup), "10.0.0.1:1000"); err != proto.ErrRateLimited {
+		t.Fatalf("batch of ops over the limit: err(%v)", err)
+	}
+	if err := m.limitRate(batch(proto.OpMetaLookup), "10.0.0.1:1000"); err != proto.ErrRateLimited {
+		t.Fatalf("batch after the limit is exhausted: err(%v)", err)
+	}
+}
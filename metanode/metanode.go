@@ -28,9 +28,11 @@ import (
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
 	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/audit"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/grpc"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
@@ -44,17 +46,37 @@ var (
 // The MetaNode manages the dentry and inode information of the meta partitions on a meta node.
 // The data consistency is ensured by Raft.
 type MetaNode struct {
-	nodeId            uint64
-	listen            string
-	metadataDir       string // root dir of the metaNode
-	raftDir           string // root dir of the raftStore log
-	metadataManager   MetadataManager
-	localAddr         string
-	clusterId         string
-	raftStore         raftstore.RaftStore
-	raftHeartbeatPort string
-	raftReplicatePort string
-	httpStopC         chan uint8
+	nodeId                    uint64
+	listen                    string
+	metadataDir               string // root dir of the metaNode
+	raftDir                   string // root dir of the raftStore log
+	metadataManager           MetadataManager
+	localAddr                 string
+	clusterId                 string
+	raftStore                 raftstore.RaftStore
+	raftHeartbeatPort         string
+	raftReplicatePort         string
+	raftPreVote               bool
+	raftWalCompression        string
+	raftWalPreAllocate        bool
+	raftWalRecycleFiles       int
+	raftSnapshotRate          int
+	raftSnapshotResumeTimeout int
+	raftSendLinger            int
+	raftWalDir                string
+	minClientVersion          uint32
+	multipartTTL              int
+	inodeCacheCount           int
+	zoneName                  string
+	rackName                  string
+	auditLogDir               string
+	auditLogSize              int64
+	auditLogBackups           int
+	auditLogRemote            string
+	auditLogger               *audit.Logger
+	httpStopC                 chan uint8
+	grpcPort                  string
+	grpcServer                *grpc.Server
 
 	control common.Control
 }
@@ -114,6 +136,9 @@ func doStart(s common.Server, cfg *config.Config) (err error) {
 	if err = m.startRaftServer(); err != nil {
 		return
 	}
+	if err = m.startAuditLog(); err != nil {
+		return
+	}
 	if err = m.startMetaManager(); err != nil {
 		return
 	}
@@ -133,6 +158,9 @@ func doStart(s common.Server, cfg *config.Config) (err error) {
 	if err = m.startServer(); err != nil {
 		return
 	}
+	if err = m.startGRPCServer(); err != nil {
+		return
+	}
 	exporter.RegistConsul(m.clusterId, cfg.GetString("role"), cfg)
 	return
 }
@@ -143,9 +171,11 @@ func doShutdown(s common.Server) {
 		return
 	}
 	// shutdown node and release the resource
+	m.stopGRPCServer()
 	m.stopServer()
 	m.stopMetaManager()
 	m.stopRaftServer()
+	m.stopAuditLog()
 }
 
 // Sync blocks the invoker's goroutine until the meta node shuts down.
@@ -165,6 +195,26 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	m.raftDir = cfg.GetString(cfgRaftDir)
 	m.raftHeartbeatPort = cfg.GetString(cfgRaftHeartbeatPort)
 	m.raftReplicatePort = cfg.GetString(cfgRaftReplicaPort)
+	m.raftPreVote = cfg.GetBool(cfgRaftPreVote)
+	m.raftWalCompression = cfg.GetString(cfgRaftWalCompression)
+	m.raftWalPreAllocate = cfg.GetBool(cfgRaftWalPreAllocate)
+	m.raftWalRecycleFiles = int(cfg.GetInt(cfgRaftWalRecycleFiles))
+	m.raftSnapshotRate = int(cfg.GetInt(cfgRaftSnapshotRate))
+	m.raftSnapshotResumeTimeout = int(cfg.GetInt(cfgRaftSnapshotResumeTimeout))
+	m.raftSendLinger = int(cfg.GetInt(cfgRaftSendLinger))
+	m.raftWalDir = cfg.GetString(cfgRaftWalDir)
+	m.minClientVersion = uint32(cfg.GetInt(cfgMinClientVersion))
+	m.multipartTTL = int(cfg.GetInt(cfgMultipartTTL))
+	if m.inodeCacheCount = int(cfg.GetInt(cfgInodeCacheCount)); m.inodeCacheCount <= 0 {
+		m.inodeCacheCount = defaultInodeCacheCount
+	}
+	m.zoneName = cfg.GetString(cfgZone)
+	m.rackName = cfg.GetString(cfgRack)
+	m.auditLogDir = cfg.GetString(cfgAuditLogDir)
+	m.auditLogSize = cfg.GetInt64(cfgAuditLogSize) * MB
+	m.auditLogBackups = int(cfg.GetInt(cfgAuditLogBackups))
+	m.auditLogRemote = cfg.GetString(cfgAuditLogRemote)
+	m.grpcPort = cfg.GetString(cfgGRPCPort)
 	configTotalMem, _ = strconv.ParseUint(cfg.GetString(cfgTotalMem), 10, 64)
 
 	if configTotalMem == 0 {
@@ -191,6 +241,9 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	if m.raftReplicatePort == "" {
 		return fmt.Errorf("bad cfgRaftReplicaPort config")
 	}
+	if m.grpcPort != "" && m.grpcPort == m.listen {
+		return fmt.Errorf("bad grpcPort config")
+	}
 
 	log.LogInfof("[parseConfig] load localAddr[%v].", m.localAddr)
 	log.LogInfof("[parseConfig] load listen[%v].", m.listen)
@@ -198,6 +251,20 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	log.LogInfof("[parseConfig] load raftDir[%v].", m.raftDir)
 	log.LogInfof("[parseConfig] load raftHeartbeatPort[%v].", m.raftHeartbeatPort)
 	log.LogInfof("[parseConfig] load raftReplicatePort[%v].", m.raftReplicatePort)
+	log.LogInfof("[parseConfig] load raftPreVote[%v].", m.raftPreVote)
+	log.LogInfof("[parseConfig] load raftWalCompression[%v].", m.raftWalCompression)
+	log.LogInfof("[parseConfig] load raftWalPreAllocate[%v].", m.raftWalPreAllocate)
+	log.LogInfof("[parseConfig] load raftWalRecycleFiles[%v].", m.raftWalRecycleFiles)
+	log.LogInfof("[parseConfig] load raftSnapshotRate[%v].", m.raftSnapshotRate)
+	log.LogInfof("[parseConfig] load raftSnapshotResumeTimeout[%v].", m.raftSnapshotResumeTimeout)
+	log.LogInfof("[parseConfig] load raftSendLinger[%v].", m.raftSendLinger)
+	log.LogInfof("[parseConfig] load raftWalDir[%v].", m.raftWalDir)
+	log.LogInfof("[parseConfig] load minClientVersion[%v].", m.minClientVersion)
+	log.LogInfof("[parseConfig] load multipartTTL[%v].", m.multipartTTL)
+	log.LogInfof("[parseConfig] load inodeCacheCount[%v].", m.inodeCacheCount)
+	log.LogInfof("[parseConfig] load zone[%v] rack[%v].", m.zoneName, m.rackName)
+	log.LogInfof("[parseConfig] load auditLogDir[%v] auditLogRemote[%v].", m.auditLogDir, m.auditLogRemote)
+	log.LogInfof("[parseConfig] load grpcPort[%v].", m.grpcPort)
 
 	addrs := cfg.GetArray(proto.MasterAddr)
 	masters := make([]string, 0, len(addrs))
@@ -238,6 +305,13 @@ func (m *MetaNode) startMetaManager() (err error) {
 		NodeID:    m.nodeId,
 		RootDir:   m.metadataDir,
 		RaftStore: m.raftStore,
+
+		MinClientVersion: m.minClientVersion,
+		MultipartTTL:     time.Duration(m.multipartTTL) * time.Second,
+		InodeCacheCount:  m.inodeCacheCount,
+		ZoneName:         m.zoneName,
+		RackName:         m.rackName,
+		AuditLogger:      m.auditLogger,
 	}
 	m.metadataManager = NewMetadataManager(conf)
 	if err = m.metadataManager.Start(); err == nil {
@@ -246,6 +320,35 @@ func (m *MetaNode) startMetaManager() (err error) {
 	return
 }
 
+// startAuditLog records the operations changing the namespace to the local file, the remote sink
+// or both of them, the audit log is disabled if neither is configured.
+func (m *MetaNode) startAuditLog() (err error) {
+	var sinks []audit.Sink
+	if m.auditLogDir != "" {
+		var sink audit.Sink
+		if sink, err = audit.NewFileSink(m.auditLogDir, m.auditLogSize, m.auditLogBackups); err != nil {
+			return fmt.Errorf("create audit log in %v: %v", m.auditLogDir, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if m.auditLogRemote != "" {
+		var sink audit.Sink
+		if sink, err = audit.NewRemoteSink(m.auditLogRemote); err != nil {
+			return fmt.Errorf("create audit log to %v: %v", m.auditLogRemote, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) > 0 {
+		m.auditLogger = audit.NewLogger(audit.DefaultBufferSize, sinks...)
+	}
+	return
+}
+
+func (m *MetaNode) stopAuditLog() {
+	m.auditLogger.Close()
+	m.auditLogger = nil
+}
+
 func (m *MetaNode) stopMetaManager() {
 	if m.metadataManager != nil {
 		m.metadataManager.Stop()
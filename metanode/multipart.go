@@ -16,13 +16,22 @@ package metanode
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/binary"
-	"github.com/chubaofs/chubaofs/util/btree"
+	"encoding/hex"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/btree"
 )
 
+// MultipartMinPartSize is the minimum size of the parts except the last one of a completed upload.
+const MultipartMinPartSize = 5 * util.MB
+
 // Part defined necessary fields for multipart part management.
 type Part struct {
 	ID         uint16
@@ -167,6 +176,19 @@ func (m Parts) Search(id uint16) (part *Part, found bool) {
 	return nil, false
 }
 
+// ListAfter returns at most max parts whose IDs are greater than the marker, and whether any
+// part is left. All the parts after the marker are returned if max is zero.
+func (m Parts) ListAfter(marker uint16, max uint64) (parts Parts, isTruncated bool) {
+	i := sort.Search(len(m), func(i int) bool {
+		return m[i].ID > marker
+	})
+	parts = m[i:]
+	if max > 0 && uint64(len(parts)) > max {
+		return parts[:max], true
+	}
+	return parts, false
+}
+
 func (m Parts) Bytes() ([]byte, error) {
 	var err error
 	var n int
@@ -219,6 +241,7 @@ type Multipart struct {
 	key      string
 	initTime time.Time
 	parts    Parts
+	extend   map[string]string // set on creation, e.g. the encryption of the object
 
 	mu sync.RWMutex
 }
@@ -234,6 +257,7 @@ func (m *Multipart) Copy() btree.Item {
 		key:      m.key,
 		initTime: m.initTime,
 		parts:    append(Parts{}, m.parts...),
+		extend:   m.extend,
 	}
 }
 
@@ -241,6 +265,14 @@ func (m *Multipart) ID() string {
 	return m.id
 }
 
+func (m *Multipart) Key() string {
+	return m.key
+}
+
+func (m *Multipart) InitTime() time.Time {
+	return m.initTime
+}
+
 func (m *Multipart) InsertPart(part *Part, replace bool) (success bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -298,6 +330,19 @@ func (m *Multipart) Bytes() ([]byte, error) {
 	if _, err = buffer.Write(marshaledParts); err != nil {
 		return nil, err
 	}
+	// marshal extend
+	n = binary.PutUvarint(tmp, uint64(len(m.extend)))
+	if _, err = buffer.Write(tmp[:n]); err != nil {
+		return nil, err
+	}
+	for key, value := range m.extend {
+		if err = marshalStr(key); err != nil {
+			return nil, err
+		}
+		if err = marshalStr(value); err != nil {
+			return nil, err
+		}
+	}
 	return buffer.Bytes(), nil
 }
 
@@ -326,12 +371,100 @@ func MultipartFromBytes(raw []byte) *Multipart {
 	partsLengthU64, n = binary.Uvarint(raw[offset:])
 	offset += n
 	var parts = PartsFromBytes(raw[offset : offset+int(partsLengthU64)])
+	offset += int(partsLengthU64)
+	// decode extend, which is absent in the sessions created by the old versions
+	var extend map[string]string
+	if offset < len(raw) {
+		var extendLengthU64 uint64
+		extendLengthU64, n = binary.Uvarint(raw[offset:])
+		offset += n
+		if extendLengthU64 > 0 {
+			extend = make(map[string]string, int(extendLengthU64))
+		}
+		for i := 0; i < int(extendLengthU64); i++ {
+			var extendKey, extendValue string
+			extendKey, n = unmarshalStr(raw[offset:])
+			offset += n
+			extendValue, n = unmarshalStr(raw[offset:])
+			offset += n
+			extend[extendKey] = extendValue
+		}
+	}
 
 	var muSession = &Multipart{
 		id:       id,
 		key:      key,
 		initTime: time.Unix(0, initTimeI64),
 		parts:    parts,
+		extend:   extend,
 	}
 	return muSession
 }
+
+// CompletedParts returns the stored parts of the upload requested to complete it, which are in
+// ascending order and match the inodes and the entity tags if requested, and are not smaller
+// than the minimum size except the last one.
+func (m *Multipart) CompletedParts(requested []*proto.MultipartPartInfo) (parts Parts, err error) {
+	if len(requested) == 0 {
+		return nil, proto.ErrInvalidPart
+	}
+	for i := 1; i < len(requested); i++ {
+		if requested[i].ID <= requested[i-1].ID {
+			return nil, proto.ErrInvalidPartOrder
+		}
+	}
+	var stored = Parts(m.Parts())
+	parts = make(Parts, 0, len(requested))
+	for i, r := range requested {
+		part, found := stored.Search(r.ID)
+		if !found || part.Inode != r.Inode || (r.MD5 != "" && r.MD5 != part.MD5) {
+			return nil, proto.ErrInvalidPart
+		}
+		if i < len(requested)-1 && part.Size < MultipartMinPartSize {
+			return nil, proto.ErrEntityTooSmall
+		}
+		parts = append(parts, part)
+	}
+	return
+}
+
+// MultipartETag returns the entity tag of the object completed by the parts, which is the MD5 of
+// the single part, or the MD5 of the MD5s of the parts followed by the number of the parts.
+func MultipartETag(parts Parts) string {
+	if len(parts) == 1 {
+		return parts[0].MD5
+	}
+	var h = md5.New()
+	for _, part := range parts {
+		if sum, err := hex.DecodeString(part.MD5); err == nil {
+			h.Write(sum)
+		} else {
+			h.Write([]byte(part.MD5))
+		}
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(parts))
+}
+
+// MultipartCompletion is the completion of a multipart upload applied by the raft, which creates
+// the file of the object and links it to the name in one step.
+type MultipartCompletion struct {
+	MultipartID string                     `json:"mid"`
+	ParentID    uint64                     `json:"pino"`
+	Name        string                     `json:"name"`
+	Inode       uint64                     `json:"ino"`
+	Mode        uint32                     `json:"mode"`
+	Timestamp   int64                      `json:"ts"`
+	Parts       []*proto.MultipartPartInfo `json:"parts"`
+	Extents     []proto.ExtentKey          `json:"eks"`
+	Extend      map[string]string          `json:"extend,omitempty"`
+	ETagKey     string                     `json:"etagKey,omitempty"`
+}
+
+// MultipartCompletionResult is the result of a completion applied, whose inode is nil if failed.
+type MultipartCompletionResult struct {
+	Status   uint8
+	Err      error
+	Inode    *Inode
+	ETag     string
+	OldInode uint64
+}
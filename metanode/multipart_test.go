@@ -16,10 +16,12 @@ package metanode
 
 import (
 	"math/rand"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util"
 )
 
@@ -121,6 +123,37 @@ func TestMUParts_Modify(t *testing.T) {
 	}
 }
 
+func TestMUParts_ListAfter(t *testing.T) {
+	var parts = PartsFromBytes(nil)
+	for i := 1; i <= 10; i++ {
+		// part IDs are 2, 4, ... 20
+		parts.Insert(&Part{ID: uint16(i * 2), UploadTime: time.Now().Local()}, false)
+	}
+	var cases = []struct {
+		marker      uint16
+		max         uint64
+		ids         []uint16
+		isTruncated bool
+	}{
+		{marker: 0, max: 0, ids: []uint16{2, 4, 6, 8, 10, 12, 14, 16, 18, 20}},
+		{marker: 0, max: 3, ids: []uint16{2, 4, 6}, isTruncated: true},
+		{marker: 6, max: 3, ids: []uint16{8, 10, 12}, isTruncated: true},
+		{marker: 7, max: 3, ids: []uint16{8, 10, 12}, isTruncated: true},
+		{marker: 14, max: 3, ids: []uint16{16, 18, 20}},
+		{marker: 20, max: 3, ids: []uint16{}},
+	}
+	for _, c := range cases {
+		listed, isTruncated := parts.ListAfter(c.marker, c.max)
+		var ids = make([]uint16, 0, len(listed))
+		for _, part := range listed {
+			ids = append(ids, part.ID)
+		}
+		if !reflect.DeepEqual(ids, c.ids) || isTruncated != c.isTruncated {
+			t.Fatalf("list after marker %v max %v: ids(%v) isTruncated(%v)", c.marker, c.max, ids, isTruncated)
+		}
+	}
+}
+
 func TestMUSession_Bytes(t *testing.T) {
 	var err error
 	var random = rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -149,3 +182,149 @@ func TestMUSession_Bytes(t *testing.T) {
 	}
 	t.Logf("encoded session length: %v", len(sessionBytes))
 }
+
+func TestMUSession_BytesWithExtend(t *testing.T) {
+	session1 := &Multipart{
+		id:       "id",
+		key:      "a/b",
+		initTime: time.Unix(0, time.Now().UnixNano()),
+		parts:    PartsFromBytes(nil),
+		extend:   map[string]string{"sse": "AES256", "empty": ""},
+	}
+	sessionBytes, err := session1.Bytes()
+	if err != nil {
+		t.Fatalf("encode session to bytes fail caue: %v", err)
+	}
+	session2 := MultipartFromBytes(sessionBytes)
+	if !reflect.DeepEqual(session1.extend, session2.extend) || session2.key != session1.key {
+		t.Fatalf("result mismatch:\n\tsession1:%v\n\tsession2:%v", session1, session2)
+	}
+}
+
+func TestMUExpiredMultiparts(t *testing.T) {
+	now := time.Now().Local()
+	mp := &metaPartition{multipartTree: NewBtree()}
+	mp.multipartTree.ReplaceOrInsert(&Multipart{id: "old", key: "a", initTime: now.Add(-2 * time.Hour),
+		parts: Parts{&Part{ID: 1, Inode: 100}}}, false)
+	mp.multipartTree.ReplaceOrInsert(&Multipart{id: "new", key: "b", initTime: now}, false)
+	expired := mp.expiredMultiparts(now.Add(-time.Hour))
+	if len(expired) != 1 || expired[0].id != "old" {
+		t.Fatalf("expired multiparts mismatch: %v", expired)
+	}
+	if len(expired[0].parts) != 1 || expired[0].parts[0].Inode != 100 {
+		t.Fatalf("parts of the expired multipart mismatch: %v", expired[0].parts)
+	}
+}
+
+func TestMUMultipart_CompletedParts(t *testing.T) {
+	multipart := &Multipart{id: "mid", parts: Parts{
+		&Part{ID: 1, MD5: "a", Size: MultipartMinPartSize, Inode: 101},
+		&Part{ID: 2, MD5: "b", Size: MultipartMinPartSize - 1, Inode: 102},
+		&Part{ID: 3, MD5: "c", Size: 1, Inode: 103},
+	}}
+	var cases = []struct {
+		requested []*proto.MultipartPartInfo
+		err       error
+	}{
+		{requested: []*proto.MultipartPartInfo{{ID: 1, Inode: 101, MD5: "a"}, {ID: 3, Inode: 103}}},
+		{requested: []*proto.MultipartPartInfo{{ID: 2, Inode: 102}}},
+		{requested: nil, err: proto.ErrInvalidPart},
+		{requested: []*proto.MultipartPartInfo{{ID: 3, Inode: 103}, {ID: 1, Inode: 101}}, err: proto.ErrInvalidPartOrder},
+		{requested: []*proto.MultipartPartInfo{{ID: 1, Inode: 101}, {ID: 1, Inode: 101}}, err: proto.ErrInvalidPartOrder},
+		{requested: []*proto.MultipartPartInfo{{ID: 4, Inode: 104}}, err: proto.ErrInvalidPart},
+		{requested: []*proto.MultipartPartInfo{{ID: 1, Inode: 101, MD5: "x"}}, err: proto.ErrInvalidPart},
+		{requested: []*proto.MultipartPartInfo{{ID: 1, Inode: 999}}, err: proto.ErrInvalidPart},
+		{requested: []*proto.MultipartPartInfo{{ID: 2, Inode: 102}, {ID: 3, Inode: 103}}, err: proto.ErrEntityTooSmall},
+	}
+	for i, c := range cases {
+		parts, err := multipart.CompletedParts(c.requested)
+		if err != c.err {
+			t.Fatalf("case %v: error mismatch: expect(%v) actual(%v)", i, c.err, err)
+		}
+		if err == nil && len(parts) != len(c.requested) {
+			t.Fatalf("case %v: parts mismatch: %v", i, parts)
+		}
+	}
+}
+
+func TestMUMultipartETag(t *testing.T) {
+	single := Parts{&Part{ID: 1, MD5: "0cc175b9c0f1b6a831c399e269772661"}}
+	if etag := MultipartETag(single); etag != "0cc175b9c0f1b6a831c399e269772661" {
+		t.Fatalf("etag of single part mismatch: %v", etag)
+	}
+	// md5(md5("a") + md5("b")) of two parts
+	double := Parts{&Part{ID: 1, MD5: "0cc175b9c0f1b6a831c399e269772661"}, &Part{ID: 2, MD5: "92eb5ffee6ae2fec3ad71c777531578f"}}
+	if etag := MultipartETag(double); etag != "96e024ba2074fe77e8e965ba43a704be-2" {
+		t.Fatalf("etag of parts mismatch: %v", etag)
+	}
+}
+
+func TestMUCompleteMultipart(t *testing.T) {
+	const parentID, mode = 1, 0600
+	mp := &metaPartition{
+		config:        &MetaPartitionConfig{PartitionId: 1},
+		dentryTree:    NewBtree(),
+		inodeTree:     NewBtree(),
+		extendTree:    NewBtree(),
+		multipartTree: NewBtree(),
+	}
+	mp.inodeTree.ReplaceOrInsert(NewInode(parentID, proto.Mode(os.ModeDir|0755)), false)
+	mp.multipartTree.ReplaceOrInsert(&Multipart{id: "mid", key: "a", parts: Parts{
+		&Part{ID: 1, MD5: "a", Size: MultipartMinPartSize, Inode: 101},
+		&Part{ID: 2, MD5: "b", Size: 10, Inode: 102},
+	}}, false)
+	completion := &MultipartCompletion{
+		MultipartID: "mid",
+		ParentID:    parentID,
+		Name:        "a",
+		Inode:       10,
+		Mode:        mode,
+		Parts:       []*proto.MultipartPartInfo{{ID: 1, Inode: 101}, {ID: 2, Inode: 102}},
+		Extents: []proto.ExtentKey{
+			{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: MultipartMinPartSize},
+			{FileOffset: MultipartMinPartSize, PartitionId: 1, ExtentId: 2, Size: 10},
+		},
+		Extend:  map[string]string{"oss:tg": "k=v"},
+		ETagKey: "oss:etag",
+	}
+
+	// the parts refused change nothing
+	refused := *completion
+	refused.Parts = []*proto.MultipartPartInfo{{ID: 2, Inode: 102}, {ID: 1, Inode: 101}}
+	if resp := mp.fsmCompleteMultipart(&refused); resp.Status != proto.OpArgMismatchErr || resp.Err != proto.ErrInvalidPartOrder {
+		t.Fatalf("complete with the parts refused: status(%v) err(%v)", resp.Status, resp.Err)
+	}
+	if mp.inodeTree.Len() != 1 || mp.dentryTree.Len() != 0 || mp.multipartTree.Len() != 1 {
+		t.Fatalf("trees changed by the completion refused")
+	}
+
+	resp := mp.fsmCompleteMultipart(completion)
+	if resp.Status != proto.OpOk || resp.OldInode != 0 || resp.Inode.Size != MultipartMinPartSize+10 {
+		t.Fatalf("complete multipart: status(%v) oldInode(%v) inode(%v)", resp.Status, resp.OldInode, resp.Inode)
+	}
+	if mp.multipartTree.Len() != 0 {
+		t.Fatalf("multipart not removed")
+	}
+	item := mp.dentryTree.Get(&Dentry{ParentId: parentID, Name: "a"})
+	if item == nil || item.(*Dentry).Inode != 10 {
+		t.Fatalf("dentry not linked: %v", item)
+	}
+	extend := mp.extendTree.Get(NewExtend(10)).(*Extend)
+	if etag, _ := extend.Get([]byte("oss:etag")); string(etag) != resp.ETag {
+		t.Fatalf("etag not kept: %s", etag)
+	}
+	if tagging, _ := extend.Get([]byte("oss:tg")); string(tagging) != "k=v" {
+		t.Fatalf("extend not kept: %s", tagging)
+	}
+
+	// complete another upload to the same name, which replaces the inode
+	mp.multipartTree.ReplaceOrInsert(&Multipart{id: "mid2", key: "a", parts: Parts{&Part{ID: 1, MD5: "c", Size: 1, Inode: 201}}}, false)
+	completion = &MultipartCompletion{MultipartID: "mid2", ParentID: parentID, Name: "a", Inode: 11, Mode: mode,
+		Parts: []*proto.MultipartPartInfo{{ID: 1, Inode: 201}}}
+	if resp = mp.fsmCompleteMultipart(completion); resp.Status != proto.OpOk || resp.OldInode != 10 || resp.ETag != "c" {
+		t.Fatalf("complete multipart to the name existed: status(%v) oldInode(%v) etag(%v)", resp.Status, resp.OldInode, resp.ETag)
+	}
+	if item = mp.dentryTree.Get(&Dentry{ParentId: parentID, Name: "a"}); item.(*Dentry).Inode != 11 {
+		t.Fatalf("dentry not replaced: %v", item)
+	}
+}
@@ -16,12 +16,18 @@ package metanode
 
 import (
 	"encoding/json"
+	"time"
+
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 type Packet struct {
 	proto.Packet
+	span     *tracing.Span
+	recvTime time.Time     // the time the packet is read from the connection
+	raftTime time.Duration // the time spent in submitting to the raft
 }
 
 // NewPacketToDeleteExtent returns a new packet to delete the extent.
@@ -20,6 +20,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"fmt"
@@ -76,6 +77,7 @@ type MetaPartitionConfig struct {
 	AfterStop   func()              `json:"-"`
 	RaftStore   raftstore.RaftStore `json:"-"`
 	ConnPool    *util.ConnectPool   `json:"-"`
+	StoreMode   string              `json:"store_mode"`
 }
 
 func (c *MetaPartitionConfig) checkMeta() (err error) {
@@ -115,6 +117,7 @@ type OpInode interface {
 	EvictInode(req *EvictInodeReq, p *Packet) (err error)
 	SetAttr(reqData []byte, p *Packet) (err error)
 	GetInodeTree() *BTree
+	RangeInodes(fn func(ino *Inode) bool) error
 	DeleteInode(req *proto.DeleteInodeRequest, p *Packet) (err error)
 }
 
@@ -122,8 +125,11 @@ type OpExtend interface {
 	SetXAttr(req *proto.SetXAttrRequest, p *Packet) (err error)
 	GetXAttr(req *proto.GetXAttrRequest, p *Packet) (err error)
 	BatchGetXAttr(req *proto.BatchGetXAttrRequest, p *Packet) (err error)
+	BatchSetXAttr(req *proto.BatchSetXAttrRequest, p *Packet) (err error)
 	RemoveXAttr(req *proto.RemoveXAttrRequest, p *Packet) (err error)
 	ListXAttr(req *proto.ListXAttrRequest, p *Packet) (err error)
+	SetACL(req *proto.SetACLRequest, p *Packet) (err error)
+	GetACL(req *proto.GetACLRequest, p *Packet) (err error)
 }
 
 // OpDentry defines the interface for the dentry operations.
@@ -134,6 +140,13 @@ type OpDentry interface {
 	ReadDir(req *ReadDirReq, p *Packet) (err error)
 	Lookup(req *LookupReq, p *Packet) (err error)
 	GetDentryTree() *BTree
+	TrashDentry(req *proto.TrashDentryRequest, p *Packet) (err error)
+	ListTrash(req *proto.ListTrashRequest, p *Packet) (err error)
+	RestoreTrash(req *proto.RestoreTrashRequest, p *Packet) (err error)
+	DeleteTree(req *proto.DeleteTreeRequest, p *Packet) (err error)
+	ListDeleteTrees(req *proto.ListDeleteTreesRequest, p *Packet) (err error)
+	ReverseLookup(req *proto.ReverseLookupRequest, p *Packet) (err error)
+	ListDentry(req *proto.ListDentryRequest, p *Packet) (err error)
 }
 
 // OpExtent defines the interface for the extent operations.
@@ -142,10 +155,19 @@ type OpExtent interface {
 	ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (err error)
 	BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error)
+	CloneExtents(req *proto.CloneExtentsRequest, p *Packet) (err error)
+}
+
+// OpLock defines the interface for the byte-range lock operations.
+type OpLock interface {
+	SetLock(req *proto.SetLockRequest, p *Packet) (err error)
+	GetLock(req *proto.GetLockRequest, p *Packet) (err error)
+	RenewLocks(req *proto.RenewLocksRequest, p *Packet) (err error)
 }
 
 type OpMultipart interface {
 	GetMultipart(req *proto.GetMultipartRequest, p *Packet) (err error)
+	CompleteMultipart(req *proto.CompleteMultipartRequest, p *Packet) (err error)
 	CreateMultipart(req *proto.CreateMultipartRequest, p *Packet) (err error)
 	AppendMultipart(req *proto.AddMultipartPartRequest, p *Packet) (err error)
 	RemoveMultipart(req *proto.RemoveMultipartRequest, p *Packet) (err error)
@@ -160,11 +182,16 @@ type OpMeta interface {
 	OpPartition
 	OpExtend
 	OpMultipart
+	OpLock
 }
 
 // OpPartition defines the interface for the partition operations.
 type OpPartition interface {
 	IsLeader() (leaderAddr string, isLeader bool)
+	RaftHealth() *proto.RaftHealth
+	QuotaUsages() []*proto.QuotaUsage
+	VolSnapshotIDs() []uint32
+	SyncVolSnapshots(snapshots []*proto.VolSnapshot)
 	GetCursor() uint64
 	GetBaseConfig() MetaPartitionConfig
 	ResponseLoadMetaPartition(p *Packet) (err error)
@@ -176,6 +203,7 @@ type OpPartition interface {
 	IsExsitPeer(peer proto.Peer) bool
 	TryToLeader(groupID uint64) error
 	CanRemoveRaftMember(peer proto.Peer) error
+	CanPromoteRaftLearner(peer proto.Peer) (promote bool, err error)
 }
 
 // MetaPartition defines the interface for the meta partition operations.
@@ -199,6 +227,7 @@ type metaPartition struct {
 	inodeTree     *BTree // btree for inodes
 	extendTree    *BTree // btree for inode extend (XAttr) management
 	multipartTree *BTree // collection for multipart management
+	lockTree      *BTree // byte-range locks indexed by the inodes
 	raftPartition raftstore.Partition
 	stopC         chan bool
 	storeChan     chan *storeMsg
@@ -209,6 +238,13 @@ type metaPartition struct {
 	extReset      chan struct{}
 	vol           *Vol
 	manager       *metadataManager
+	rocksdbStore  *raftstore.RocksDBStore // persists the metadata in the rocksdb store mode
+	quotaUsage    quotaUsageCache
+	deleteTrees   deleteTreeProgress
+
+	volSnapshots        []*volSnapshot // replaced instead of modified
+	volSnapshotsLock    sync.RWMutex
+	volSnapshotsSyncing int32
 }
 
 // Start starts a meta partition.
@@ -271,6 +307,9 @@ func (mp *metaPartition) onStart() (err error) {
 			mp.config.PartitionId, err.Error())
 		return
 	}
+	mp.startExpireMultipart()
+	mp.startPurgeTrash()
+	mp.startDeleteTree()
 	if err = mp.startRaft(); err != nil {
 		err = errors.NewErrorf("[onStart]start raft id=%d: %s",
 			mp.config.PartitionId, err.Error())
@@ -309,6 +348,9 @@ func (mp *metaPartition) startRaft() (err error) {
 			HeartbeatPort: heartbeatPort,
 			ReplicaPort:   replicaPort,
 		}
+		if peer.Learner {
+			rp.Type = raftproto.PeerLearner
+		}
 		peers = append(peers, rp)
 	}
 	log.LogDebugf("start partition id=%d raft peers: %s",
@@ -362,6 +404,7 @@ func NewMetaPartition(conf *MetaPartitionConfig, manager *metadataManager) MetaP
 		inodeTree:     NewBtree(),
 		extendTree:    NewBtree(),
 		multipartTree: NewBtree(),
+		lockTree:      NewBtree(),
 		stopC:         make(chan bool),
 		storeChan:     make(chan *storeMsg, 5),
 		freeList:      newFreeList(),
@@ -392,6 +435,14 @@ func (mp *metaPartition) IsLeader() (leaderAddr string, ok bool) {
 	return
 }
 
+// RaftHealth returns the health of the raft group of the meta partition on this node.
+func (mp *metaPartition) RaftHealth() *proto.RaftHealth {
+	if mp.raftPartition == nil {
+		return nil
+	}
+	return raftstore.NewRaftHealth(mp.raftPartition.Status())
+}
+
 func (mp *metaPartition) GetPeers() (peers []string) {
 	peers = make([]string, 0)
 	for _, peer := range mp.config.Peers {
@@ -419,6 +470,9 @@ func (mp *metaPartition) load() (err error) {
 	if err = mp.loadMetadata(); err != nil {
 		return
 	}
+	if mp.config.StoreMode == proto.StoreModeRocksDB {
+		return mp.loadFromStore()
+	}
 	snapshotPath := path.Join(mp.config.RootDir, snapshotDir)
 	if err = mp.loadInode(snapshotPath); err != nil {
 		return
@@ -432,6 +486,12 @@ func (mp *metaPartition) load() (err error) {
 	if err = mp.loadMultipart(snapshotPath); err != nil {
 		return
 	}
+	if err = mp.loadVolSnapshots(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadLocks(snapshotPath); err != nil {
+		return
+	}
 	err = mp.loadApplyID(snapshotPath)
 	return
 }
@@ -453,28 +513,13 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 			os.RemoveAll(tmpDir)
 		}
 	}()
-	var crcBuffer = bytes.NewBuffer(make([]byte, 0, 16))
-	var storeFuncs = []func(dir string, sm *storeMsg) (uint32, error){
-		mp.storeInode,
-		mp.storeDentry,
-		mp.storeExtend,
-		mp.storeMultipart,
+	if mp.rocksdbStore != nil {
+		// the metadata has been persisted on applying, keep a checkpoint as the snapshot
+		err = mp.rocksdbStore.Checkpoint(path.Join(tmpDir, rocksdbDir))
+	} else {
+		err = mp.storeTrees(tmpDir, sm)
 	}
-	for _, storeFunc := range storeFuncs {
-		var crc uint32
-		if crc, err = storeFunc(tmpDir, sm); err != nil {
-			return
-		}
-		if crcBuffer.Len() != 0 {
-			crcBuffer.WriteString(" ")
-		}
-		crcBuffer.WriteString(fmt.Sprintf("%d", crc))
-	}
-	if err = mp.storeApplyID(tmpDir, sm); err != nil {
-		return
-	}
-	// write crc to file
-	if err = ioutil.WriteFile(path.Join(tmpDir, SnapshotSign), crcBuffer.Bytes(), 0775); err != nil {
+	if err != nil {
 		return
 	}
 	snapshotDir := path.Join(mp.config.RootDir, snapshotDir)
@@ -503,6 +548,41 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 	return
 }
 
+// storeTrees dumps the trees and the apply ID to the files in the given directory.
+func (mp *metaPartition) storeTrees(tmpDir string, sm *storeMsg) (err error) {
+	var crcBuffer = bytes.NewBuffer(make([]byte, 0, 16))
+	var storeFuncs = []func(dir string, sm *storeMsg) (uint32, error){
+		mp.storeInode,
+		mp.storeDentry,
+		mp.storeExtend,
+		mp.storeMultipart,
+	}
+	for _, storeFunc := range storeFuncs {
+		var crc uint32
+		if crc, err = storeFunc(tmpDir, sm); err != nil {
+			return
+		}
+		if crcBuffer.Len() != 0 {
+			crcBuffer.WriteString(" ")
+		}
+		crcBuffer.WriteString(fmt.Sprintf("%d", crc))
+	}
+	if err = mp.storeVolSnapshots(tmpDir, sm.volSnapshots); err != nil {
+		return
+	}
+	if err = mp.storeLocks(tmpDir, sm); err != nil {
+		return
+	}
+	if err = mp.storeApplyID(tmpDir, sm); err != nil {
+		return
+	}
+	// write crc to file
+	if err = ioutil.WriteFile(path.Join(tmpDir, SnapshotSign), crcBuffer.Bytes(), 0775); err != nil {
+		return
+	}
+	return
+}
+
 // UpdatePeers updates the peers.
 func (mp *metaPartition) UpdatePeers(peers []proto.Peer) {
 	mp.config.Peers = peers
@@ -620,6 +700,11 @@ func (mp *metaPartition) Reset() (err error) {
 	mp.dentryTree.Reset()
 	mp.config.Cursor = 0
 	mp.applyID = 0
+	if mp.rocksdbStore != nil {
+		if err = mp.resetStore(0); err != nil {
+			return
+		}
+	}
 
 	// remove files
 	filenames := []string{applyIDFile, dentryFile, inodeFile, extendFile, multipartFile}
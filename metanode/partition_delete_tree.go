@@ -0,0 +1,289 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	DeleteTreeInterval = time.Minute
+
+	deleteTreePageSize = 1000
+)
+
+var errDeleteTreeStopped = errors.New("delete tree stopped")
+
+type deleteTreeKey struct {
+	parentID uint64
+	markName string
+}
+
+// deleteTreeProgress counts the deleted files and directories of the jobs on the leader, which
+// are counted from zero again if the leader changes.
+type deleteTreeProgress struct {
+	sync.Mutex
+	jobs  map[deleteTreeKey]*proto.DeleteTreeJob
+	wakeC chan struct{}
+}
+
+// wake wakes up the worker without waiting for the next scan.
+func (d *deleteTreeProgress) wake() {
+	select {
+	case d.wakeC <- struct{}{}:
+	default:
+	}
+}
+
+func (d *deleteTreeProgress) job(job *proto.DeleteTreeJob) *proto.DeleteTreeJob {
+	if d.jobs == nil {
+		d.jobs = make(map[deleteTreeKey]*proto.DeleteTreeJob)
+	}
+	key := deleteTreeKey{parentID: job.ParentID, markName: job.MarkName}
+	p, ok := d.jobs[key]
+	if !ok {
+		p = &proto.DeleteTreeJob{}
+		d.jobs[key] = p
+	}
+	return p
+}
+
+func (d *deleteTreeProgress) count(job *proto.DeleteTreeJob, files, dirs uint64) {
+	d.Lock()
+	defer d.Unlock()
+	p := d.job(job)
+	p.DeletedFiles += files
+	p.DeletedDirs += dirs
+}
+
+func (d *deleteTreeProgress) setError(job *proto.DeleteTreeJob, err error) {
+	d.Lock()
+	defer d.Unlock()
+	d.job(job).Error = err.Error()
+}
+
+// progress fills the progress of the jobs.
+func (d *deleteTreeProgress) progress(jobs []*proto.DeleteTreeJob) []*proto.DeleteTreeJob {
+	d.Lock()
+	defer d.Unlock()
+	for _, job := range jobs {
+		if p, ok := d.jobs[deleteTreeKey{parentID: job.ParentID, markName: job.MarkName}]; ok {
+			job.DeletedFiles, job.DeletedDirs, job.Error = p.DeletedFiles, p.DeletedDirs, p.Error
+		}
+	}
+	return jobs
+}
+
+// retain drops the progress of the finished jobs.
+func (d *deleteTreeProgress) retain(jobs []*proto.DeleteTreeJob) {
+	d.Lock()
+	defer d.Unlock()
+	keys := make(map[deleteTreeKey]bool, len(jobs))
+	for _, job := range jobs {
+		keys[deleteTreeKey{parentID: job.ParentID, markName: job.MarkName}] = true
+	}
+	for key := range d.jobs {
+		if !keys[key] {
+			delete(d.jobs, key)
+		}
+	}
+}
+
+// startDeleteTree starts the worker which deletes the subtrees of the detached directories.
+func (mp *metaPartition) startDeleteTree() {
+	mp.deleteTrees.wakeC = make(chan struct{}, 1)
+	go mp.deleteTreeWorker()
+}
+
+func (mp *metaPartition) deleteTreeWorker() {
+	t := time.NewTicker(DeleteTreeInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-mp.stopC:
+			return
+		case <-t.C:
+		case <-mp.deleteTrees.wakeC:
+		}
+		if _, isLeader := mp.IsLeader(); !isLeader {
+			continue
+		}
+		jobs := mp.deleteTreeDentries()
+		mp.deleteTrees.retain(jobs)
+		var views []*proto.MetaPartitionView
+		for _, job := range jobs {
+			err := mp.deleteTree(job, &views)
+			if err == errDeleteTreeStopped {
+				break
+			}
+			if err != nil {
+				mp.deleteTrees.setError(job, err)
+				log.LogWarnf("[deleteTreeWorker] partitionID(%v) delete parent(%v) name(%v) inode(%v) failed: %v",
+					mp.config.PartitionId, job.ParentID, job.MarkName, job.Inode, err)
+				continue
+			}
+			log.LogInfof("[deleteTreeWorker] partitionID(%v) parent(%v) name(%v) inode(%v) deleted",
+				mp.config.PartitionId, job.ParentID, job.MarkName, job.Inode)
+		}
+	}
+}
+
+// deleteTree deletes the subtree before releasing the inode of the directory and removing the
+// mark dentry, so that the job is resumed by the next scan if it fails.
+func (mp *metaPartition) deleteTree(job *proto.DeleteTreeJob, views *[]*proto.MetaPartitionView) (err error) {
+	if err = mp.deleteSubtree(job, job.Inode, views); err != nil {
+		return
+	}
+	if err = mp.releaseInode(job.Inode, views); err != nil {
+		return
+	}
+	val, err := (&Dentry{ParentId: job.ParentID, Name: job.MarkName}).Marshal()
+	if err != nil {
+		return
+	}
+	resp, err := mp.Put(opFSMFinishDeleteTree, val)
+	if err != nil {
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk && status != proto.OpNotExistErr {
+		return errors.NewErrorf("finish delete tree status(%v)", status)
+	}
+	mp.deleteTrees.count(job, 0, 1)
+	return
+}
+
+// deleteSubtree deletes the children of the directory page by page, deleting the dentry of each
+// child before releasing its inode as the clients do, so that a child retried by the next scan is
+// never unlinked twice. The inode of a child is left orphan if releasing it fails. The dentries
+// in the trash of the directory are left to be purged with the trash.
+func (mp *metaPartition) deleteSubtree(job *proto.DeleteTreeJob, parentID uint64, views *[]*proto.MetaPartitionView) (err error) {
+	var marker string
+	for {
+		if mp.deleteTreeStopped() {
+			return errDeleteTreeStopped
+		}
+		var resp *proto.ListDentryResponse
+		if resp, err = mp.listChildren(parentID, marker, views); err != nil {
+			return
+		}
+		for _, child := range resp.Children {
+			isDir := proto.IsDir(child.Type)
+			if isDir {
+				if err = mp.deleteSubtree(job, child.Inode, views); err != nil {
+					return
+				}
+			}
+			if err = mp.deleteChild(parentID, child.Name, views); err != nil {
+				return
+			}
+			if err = mp.releaseInode(child.Inode, views); err != nil {
+				log.LogWarnf("[deleteSubtree] partitionID(%v) release inode(%v) of parent(%v) name(%v) failed: %v",
+					mp.config.PartitionId, child.Inode, parentID, child.Name, err)
+				return
+			}
+			if isDir {
+				mp.deleteTrees.count(job, 0, 1)
+			} else {
+				mp.deleteTrees.count(job, 1, 0)
+			}
+		}
+		if !resp.IsTruncated {
+			return
+		}
+		marker = resp.NextMarker
+	}
+}
+
+func (mp *metaPartition) deleteTreeStopped() bool {
+	select {
+	case <-mp.stopC:
+		return true
+	default:
+	}
+	_, isLeader := mp.IsLeader()
+	return !isLeader
+}
+
+// listChildren lists a page of the children of the directory in this partition or in another
+// partition of the volume.
+func (mp *metaPartition) listChildren(parentID uint64, marker string, views *[]*proto.MetaPartitionView) (resp *proto.ListDentryResponse, err error) {
+	req := &proto.ListDentryRequest{
+		VolName:  mp.config.VolName,
+		ParentID: parentID,
+		Marker:   marker,
+		Limit:    deleteTreePageSize,
+	}
+	if mp.isLocalInode(parentID) {
+		req.PartitionID = mp.config.PartitionId
+		return mp.listDentry(req), nil
+	}
+	view, err := mp.remotePartition(parentID, views)
+	if err != nil {
+		return
+	}
+	req.PartitionID = view.PartitionID
+	p, err := mp.sendToRemotePartition(view.LeaderAddr, proto.OpMetaListDentry, req)
+	if err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		return nil, errors.NewErrorf("list dentry: %v", p.GetResultMsg())
+	}
+	resp = new(proto.ListDentryResponse)
+	err = p.UnmarshalData(resp)
+	return
+}
+
+// deleteChild deletes the dentry of the child in this partition or in another partition of the
+// volume.
+func (mp *metaPartition) deleteChild(parentID uint64, name string, views *[]*proto.MetaPartitionView) (err error) {
+	if mp.isLocalInode(parentID) {
+		var val []byte
+		if val, err = (&Dentry{ParentId: parentID, Name: name}).Marshal(); err != nil {
+			return
+		}
+		var resp interface{}
+		if resp, err = mp.Put(opFSMDeleteDentry, val); err != nil {
+			return
+		}
+		if status := resp.(*DentryResponse).Status; status != proto.OpOk && status != proto.OpNotExistErr {
+			return errors.NewErrorf("delete dentry status(%v)", status)
+		}
+		return
+	}
+	view, err := mp.remotePartition(parentID, views)
+	if err != nil {
+		return
+	}
+	req := &proto.DeleteDentryRequest{
+		VolName:     mp.config.VolName,
+		PartitionID: view.PartitionID,
+		ParentID:    parentID,
+		Name:        name,
+	}
+	p, err := mp.sendToRemotePartition(view.LeaderAddr, proto.OpMetaDeleteDentry, req)
+	if err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk && p.ResultCode != proto.OpNotExistErr {
+		return errors.NewErrorf("delete dentry: %v", p.GetResultMsg())
+	}
+	return
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	ExpireMultipartInterval = 10 * time.Minute
+)
+
+// startExpireMultipart starts the scanner which aborts the multipart uploads older than the
+// multipart TTL of the meta node, and releases the inodes of their parts. Otherwise the uploads
+// interrupted by the S3 clients are kept forever, along with the data of their parts.
+func (mp *metaPartition) startExpireMultipart() {
+	go mp.expireMultipartWorker()
+}
+
+func (mp *metaPartition) expireMultipartWorker() {
+	t := time.NewTicker(ExpireMultipartInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-mp.stopC:
+			return
+		case <-t.C:
+		}
+		if mp.manager == nil || mp.manager.multipartTTL <= 0 {
+			continue
+		}
+		if _, isLeader := mp.IsLeader(); !isLeader {
+			continue
+		}
+		expired := mp.expiredMultiparts(time.Now().Add(-mp.manager.multipartTTL))
+		for _, multipart := range expired {
+			if err := mp.abortMultipart(multipart); err != nil {
+				log.LogWarnf("[expireMultipartWorker] partitionID(%v) abort multipart(%v) key(%v) initTime(%v) failed: %v",
+					mp.config.PartitionId, multipart.id, multipart.key, multipart.initTime, err)
+				continue
+			}
+			log.LogInfof("[expireMultipartWorker] partitionID(%v) multipart(%v) key(%v) initTime(%v) parts(%v) aborted",
+				mp.config.PartitionId, multipart.id, multipart.key, multipart.initTime, len(multipart.parts))
+		}
+	}
+}
+
+// expiredMultiparts returns the copies of the multipart uploads initiated before the deadline.
+func (mp *metaPartition) expiredMultiparts(deadline time.Time) (expired []*Multipart) {
+	mp.multipartTree.Ascend(func(i BtreeItem) bool {
+		multipart := i.(*Multipart)
+		if multipart.initTime.Before(deadline) {
+			expired = append(expired, &Multipart{
+				id:       multipart.id,
+				key:      multipart.key,
+				initTime: multipart.initTime,
+				parts:    multipart.Parts(),
+			})
+		}
+		return true
+	})
+	return
+}
+
+// abortMultipart releases the inodes of the parts before removing the multipart upload, the same
+// as the object node aborts it, so that the upload is retried by the next scan if it fails.
+func (mp *metaPartition) abortMultipart(multipart *Multipart) (err error) {
+	var views []*proto.MetaPartitionView
+	for _, part := range multipart.parts {
+		if err = mp.releaseInode(part.Inode, &views); err != nil {
+			return errors.NewErrorf("release part(%v) inode(%v): %v", part.ID, part.Inode, err)
+		}
+	}
+	resp, err := mp.putMultipart(opFSMRemoveMultipart, &Multipart{id: multipart.id})
+	if err != nil {
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk && status != proto.OpNotExistErr {
+		err = errors.NewErrorf("remove multipart status(%v)", status)
+	}
+	return
+}
+
+// releaseInode releases the inode in this partition or in another partition of the volume. The
+// views of the meta partitions are fetched once for the caller.
+func (mp *metaPartition) releaseInode(ino uint64, views *[]*proto.MetaPartitionView) (err error) {
+	if mp.isLocalInode(ino) {
+		return mp.releaseLocalInode(ino)
+	}
+	view, err := mp.remotePartition(ino, views)
+	if err != nil {
+		return
+	}
+	return mp.releaseRemoteInode(view, ino)
+}
+
+func (mp *metaPartition) isLocalInode(ino uint64) bool {
+	return mp.config.Start <= ino && ino <= mp.config.End
+}
+
+func (mp *metaPartition) remotePartition(ino uint64, views *[]*proto.MetaPartitionView) (view *proto.MetaPartitionView, err error) {
+	if *views == nil {
+		if *views, err = masterClient.ClientAPI().GetMetaPartitions(mp.config.VolName); err != nil {
+			return
+		}
+	}
+	return remotePartitionOfInode(*views, ino)
+}
+
+// remotePartitionOfInode returns the view of the partition of the inode, which must have a leader.
+func remotePartitionOfInode(views []*proto.MetaPartitionView, ino uint64) (*proto.MetaPartitionView, error) {
+	for _, view := range views {
+		if view.Start <= ino && ino <= view.End {
+			if view.LeaderAddr == "" {
+				return nil, ErrNoLeader
+			}
+			return view, nil
+		}
+	}
+	return nil, errors.New("no meta partition of the inode")
+}
+
+// releaseLocalInode unlinks and evicts the inode of a part in this partition. The inode is not
+// unlinked again if it was unlinked by a former scan, which failed to evict it.
+func (mp *metaPartition) releaseLocalInode(ino uint64) (err error) {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return
+	}
+	inode := item.(*Inode)
+	if inode.ShouldDelete() {
+		return
+	}
+	val, err := NewInode(ino, 0).Marshal()
+	if err != nil {
+		return
+	}
+	if inode.GetNLink() > 0 {
+		if _, err = mp.Put(opFSMUnlinkInode, val); err != nil {
+			return
+		}
+	}
+	_, err = mp.Put(opFSMEvictInode, val)
+	return
+}
+
+// releaseRemoteInode unlinks and evicts the inode of a part in another partition of the volume,
+// by sending the requests to the leader of the partition like the clients.
+func (mp *metaPartition) releaseRemoteInode(view *proto.MetaPartitionView, ino uint64) (err error) {
+	getReq := &proto.InodeGetRequest{VolName: mp.config.VolName, PartitionID: view.PartitionID, Inode: ino}
+	p, err := mp.sendToRemotePartition(view.LeaderAddr, proto.OpMetaInodeGet, getReq)
+	if err != nil {
+		return
+	}
+	if p.ResultCode == proto.OpNotExistErr {
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		return errors.NewErrorf("get inode: %v", p.GetResultMsg())
+	}
+	resp := new(proto.InodeGetResponse)
+	if err = p.UnmarshalData(resp); err != nil {
+		return
+	}
+
+	if resp.Info.Nlink > 0 {
+		unlinkReq := &proto.UnlinkInodeRequest{VolName: mp.config.VolName, PartitionID: view.PartitionID, Inode: ino}
+		if p, err = mp.sendToRemotePartition(view.LeaderAddr, proto.OpMetaUnlinkInode, unlinkReq); err != nil {
+			return
+		}
+		if p.ResultCode != proto.OpOk && p.ResultCode != proto.OpNotExistErr {
+			return errors.NewErrorf("unlink inode: %v", p.GetResultMsg())
+		}
+	}
+	evictReq := &proto.EvictInodeRequest{VolName: mp.config.VolName, PartitionID: view.PartitionID, Inode: ino}
+	if p, err = mp.sendToRemotePartition(view.LeaderAddr, proto.OpMetaEvictInode, evictReq); err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk && p.ResultCode != proto.OpNotExistErr {
+		return errors.NewErrorf("evict inode: %v", p.GetResultMsg())
+	}
+	return
+}
+
+func (mp *metaPartition) sendToRemotePartition(addr string, opcode uint8, req interface{}) (p *proto.Packet, err error) {
+	p = proto.NewPacketReqID()
+	p.Opcode = opcode
+	if err = p.MarshalData(req); err != nil {
+		return
+	}
+	conn, err := mp.config.ConnPool.GetConnect(addr)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			mp.config.ConnPool.PutConnect(conn, ForceClosedConnect)
+		} else {
+			mp.config.ConnPool.PutConnect(conn, NoClosedConnect)
+		}
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	err = p.ReadFromConn(conn, proto.ReadDeadlineTime)
+	return
+}
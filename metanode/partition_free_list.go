@@ -197,7 +197,7 @@ func (mp *metaPartition) syncToRaftFollowersFreeInode(hasDeleteInodes []byte) (e
 }
 
 func (mp *metaPartition) notifyRaftFollowerToFreeInodes(wg *sync.WaitGroup, target string, hasDeleteInodes []byte) (err error) {
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = mp.config.ConnPool.GetConnect(target)
 	defer func() {
 		wg.Done()
@@ -228,6 +228,12 @@ func (mp *metaPartition) notifyRaftFollowerToFreeInodes(wg *sync.WaitGroup, targ
 }
 
 func (mp *metaPartition) doDeleteMarkedInodes(ext *proto.ExtentKey) (err error) {
+	// the extent is deleted once the snapshots holding it are dropped
+	if mp.isExtentHeldBySnapshots(ext) {
+		log.LogDebugf("[doDeleteMarkedInodes] partitionId=%d extent(%v) held by snapshots",
+			mp.config.PartitionId, ext)
+		return
+	}
 	// get the data node view
 	dp := mp.vol.GetPartition(ext.PartitionId)
 	if dp == nil {
@@ -30,6 +30,7 @@ import (
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/tracing"
 	"github.com/tiglabs/raft"
 	raftproto "github.com/tiglabs/raft/proto"
 )
@@ -37,7 +38,11 @@ import (
 // Apply applies the given operational commands.
 func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{}, err error) {
 	msg := &MetaItem{}
+	var changed []BtreeItem // keys of the items may be changed, which are persisted in the rocksdb store mode
 	defer func() {
+		if err == nil && mp.rocksdbStore != nil {
+			err = mp.writeThrough(index, changed)
+		}
 		if err == nil {
 			mp.uploadApplyID(index)
 		}
@@ -56,30 +61,35 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			mp.config.Cursor = ino.Inode
 		}
 		resp = mp.fsmCreateInode(ino)
+		changed = append(changed, ino)
 	case opFSMUnlinkInode:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmUnlinkInode(ino)
+		changed = append(changed, ino)
 	case opFSMExtentTruncate:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmExtentsTruncate(ino)
+		changed = append(changed, ino)
 	case opFSMCreateLinkInode:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmCreateLinkInode(ino)
+		changed = append(changed, ino)
 	case opFSMEvictInode:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmEvictInode(ino)
+		changed = append(changed, ino)
 	case opFSMSetAttr:
 		req := &SetattrRequest{}
 		err = json.Unmarshal(msg.V, req)
@@ -87,24 +97,28 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		err = mp.fsmSetAttr(req)
+		changed = append(changed, NewInode(req.Inode, 0), NewExtend(req.Inode))
 	case opFSMCreateDentry:
 		den := &Dentry{}
 		if err = den.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmCreateDentry(den, false)
+		changed = append(changed, den, NewInode(den.ParentId, 0))
 	case opFSMDeleteDentry:
 		den := &Dentry{}
 		if err = den.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmDeleteDentry(den)
+		changed = append(changed, den, NewInode(den.ParentId, 0))
 	case opFSMUpdateDentry:
 		den := &Dentry{}
 		if err = den.Unmarshal(msg.V); err != nil {
 			return
 		}
 		resp = mp.fsmUpdateDentry(den)
+		changed = append(changed, den)
 	case opFSMUpdatePartition:
 		req := &UpdatePartitionReq{}
 		if err = json.Unmarshal(msg.V, req); err != nil {
@@ -116,7 +130,15 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		if err = ino.Unmarshal(msg.V); err != nil {
 			return
 		}
-		resp = mp.fsmAppendExtents(ino)
+		resp = mp.fsmAppendExtents(ino, false)
+		changed = append(changed, ino)
+	case opFSMExtentsAddIfGeneration:
+		ino := NewInode(0, 0)
+		if err = ino.Unmarshal(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmAppendExtents(ino, true)
+		changed = append(changed, ino)
 	case opFSMStoreTick:
 		inodeTree := mp.getInodeTree()
 		dentryTree := mp.getDentryTree()
@@ -129,10 +151,13 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			dentryTree:    dentryTree,
 			extendTree:    extendTree,
 			multipartTree: multipartTree,
+			lockTree:      mp.lockTree.GetTree(),
+			volSnapshots:  mp.getVolSnapshots(),
 		}
 		mp.storeChan <- msg
 	case opFSMInternalDeleteInode:
 		err = mp.internalDelete(msg.V)
+		changed = internalDeleteItems(msg.V)
 	case opFSMInternalDelExtentFile:
 		err = mp.delOldExtentFile(msg.V)
 	case opFSMInternalDelExtentCursor:
@@ -143,24 +168,110 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		err = mp.fsmSetXAttr(extend)
+		changed = append(changed, extend)
 	case opFSMRemoveXAttr:
 		var extend *Extend
 		if extend, err = NewExtendFromBytes(msg.V); err != nil {
 			return
 		}
 		err = mp.fsmRemoveXAttr(extend)
+		changed = append(changed, extend)
+	case opFSMSetACL:
+		req := &proto.SetACLRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmSetACL(req)
+		changed = append(changed, NewInode(req.Inode, 0), NewExtend(req.Inode))
+	case opFSMTrashDentry:
+		den := &Dentry{}
+		if err = den.Unmarshal(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmTrashDentry(den)
+		name, _, _ := proto.ParseTrashName(den.Name)
+		changed = append(changed, den, &Dentry{ParentId: den.ParentId, Name: name}, NewInode(den.ParentId, 0))
+	case opFSMRestoreTrash:
+		req := &proto.RestoreTrashRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmRestoreTrash(req)
+		changed = append(changed, &Dentry{ParentId: req.ParentID, Name: req.TrashName},
+			&Dentry{ParentId: req.ParentID, Name: req.Name}, NewInode(req.ParentID, 0))
+	case opFSMPurgeTrash:
+		den := &Dentry{}
+		if err = den.Unmarshal(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmPurgeTrash(den)
+		changed = append(changed, den)
+	case opFSMMarkDeleteTree:
+		den := &Dentry{}
+		if err = den.Unmarshal(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmMarkDeleteTree(den)
+		name, _, _ := proto.ParseDeleteTreeName(den.Name)
+		changed = append(changed, den, &Dentry{ParentId: den.ParentId, Name: name}, NewInode(den.ParentId, 0))
+	case opFSMFinishDeleteTree:
+		den := &Dentry{}
+		if err = den.Unmarshal(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmFinishDeleteTree(den)
+		changed = append(changed, den)
+	case opFSMFreezeVolSnapshot:
+		snapshot := &proto.VolSnapshot{}
+		if err = json.Unmarshal(msg.V, snapshot); err != nil {
+			return
+		}
+		resp = mp.fsmFreezeVolSnapshot(snapshot)
+	case opFSMDropVolSnapshot:
+		snapshot := &proto.VolSnapshot{}
+		if err = json.Unmarshal(msg.V, snapshot); err != nil {
+			return
+		}
+		resp = mp.fsmDropVolSnapshot(snapshot)
+	case opFSMSetLock:
+		op := &lockOp{}
+		if err = json.Unmarshal(msg.V, op); err != nil {
+			return
+		}
+		resp = mp.fsmSetLock(op)
+		changed = append(changed, NewInodeLocks(op.Inode))
+	case opFSMRenewLocks:
+		op := &lockOp{}
+		if err = json.Unmarshal(msg.V, op); err != nil {
+			return
+		}
+		resp, changed = mp.fsmRenewLocks(op)
 	case opFSMCreateMultipart:
 		var multipart *Multipart
 		multipart = MultipartFromBytes(msg.V)
 		resp = mp.fsmCreateMultipart(multipart)
+		changed = append(changed, multipart)
 	case opFSMRemoveMultipart:
 		var multipart *Multipart
 		multipart = MultipartFromBytes(msg.V)
 		resp = mp.fsmRemoveMultipart(multipart)
+		changed = append(changed, multipart)
 	case opFSMAppendMultipart:
 		var multipart *Multipart
 		multipart = MultipartFromBytes(msg.V)
 		resp = mp.fsmAppendMultipart(multipart)
+		changed = append(changed, multipart)
+	case opFSMCompleteMultipart:
+		completion := &MultipartCompletion{}
+		if err = json.Unmarshal(msg.V, completion); err != nil {
+			return
+		}
+		if mp.config.Cursor < completion.Inode {
+			mp.config.Cursor = completion.Inode
+		}
+		resp = mp.fsmCompleteMultipart(completion)
+		changed = append(changed, &Multipart{id: completion.MultipartID}, NewInode(completion.ParentID, 0),
+			NewInode(completion.Inode, 0), &Extend{inode: completion.Inode}, &Dentry{ParentId: completion.ParentID, Name: completion.Name})
 	}
 	return
 }
@@ -187,6 +298,11 @@ func (mp *metaPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 		updated, err = mp.confRemoveNode(req, index)
 	case raftproto.ConfUpdateNode:
 		updated, err = mp.confUpdateNode(req, index)
+	case raftproto.ConfAddLearner:
+		req.AddPeer.Learner = true
+		updated, err = mp.confAddNode(req, index)
+	case raftproto.ConfPromoteLearner:
+		updated, err = mp.confPromoteLearner(req, index)
 	}
 	if err != nil {
 		return
@@ -218,13 +334,27 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		dentryTree    = NewBtree()
 		extendTree    = NewBtree()
 		multipartTree = NewBtree()
+		lockTree      = NewBtree()
+		volSnapshots  = make(map[uint32]*volSnapshot)
 	)
 	defer func() {
 		if err == io.EOF {
+			mp.config.Cursor = cursor
+			if mp.rocksdbStore != nil {
+				if err = mp.resetStore(appIndexID, inodeTree, dentryTree, extendTree, multipartTree, lockTree); err != nil {
+					log.LogErrorf("ApplySnapshot: reset store failed: partitionID(%v) err(%v)", mp.config.PartitionId, err)
+					return
+				}
+				inodeTree.SetLoader(mp.loadStoredInode)
+				inodeTree.Evict(mp.inodeCacheCount())
+			}
 			mp.applyID = appIndexID
 			mp.inodeTree = inodeTree
 			mp.dentryTree = dentryTree
-			mp.config.Cursor = cursor
+			mp.extendTree = extendTree
+			mp.multipartTree = multipartTree
+			mp.lockTree = lockTree
+			mp.setVolSnapshots(volSnapshots)
 			err = nil
 			// store message
 			mp.storeChan <- &storeMsg{
@@ -234,6 +364,8 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				dentryTree:    mp.dentryTree,
 				extendTree:    mp.extendTree,
 				multipartTree: mp.multipartTree,
+				lockTree:      mp.lockTree,
+				volSnapshots:  mp.getVolSnapshots(),
 			}
 			mp.extReset <- struct{}{}
 			log.LogDebugf("ApplySnapshot: finish with EOF: partitionID(%v) applyID(%v)", mp.config.PartitionId, mp.applyID)
@@ -299,6 +431,16 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 			}
 			log.LogDebugf("ApplySnapshot: write snap extent delete file: partitonID(%v) filename(%v).",
 				mp.config.PartitionId, fileName)
+		case opFSMInodeLocks:
+			var locks *InodeLocks
+			if locks, err = InodeLocksFromBytes(snap.V); err != nil {
+				return
+			}
+			lockTree.ReplaceOrInsert(locks, true)
+		case opVolSnapshot, opVolSnapshotInode, opVolSnapshotDentry:
+			if err = applyVolSnapshotItem(volSnapshots, snap); err != nil {
+				return
+			}
 		default:
 			err = fmt.Errorf("unknown op=%d", snap.Op)
 			return
@@ -363,6 +505,18 @@ func (mp *metaPartition) Put(key, val interface{}) (resp interface{}, err error)
 	return
 }
 
+// putWithTrace puts the operation into the raft store like Put, and reports the time spent
+// in raft as a child span of the given request if the request is traced.
+func (mp *metaPartition) putWithTrace(p *Packet, key, val interface{}) (resp interface{}, err error) {
+	span := tracing.StartSpan("metanode.raftSubmit", p.span.Context())
+	span.SetTag("partition", mp.config.PartitionId)
+	start := time.Now()
+	resp, err = mp.Put(key, val)
+	p.raftTime += time.Since(start)
+	span.Finish(err)
+	return
+}
+
 // Get has not been implemented yet.
 func (mp *metaPartition) Get(key interface{}) (interface{}, error) {
 	return nil, nil
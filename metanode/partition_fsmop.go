@@ -108,6 +108,20 @@ func (mp *metaPartition) confAddNode(req *proto.
 	return
 }
 
+func (mp *metaPartition) confPromoteLearner(req *proto.MetaPartitionDecommissionRequest,
+	index uint64) (updated bool, err error) {
+	for i, peer := range mp.config.Peers {
+		if peer.ID == req.AddPeer.ID && peer.Learner {
+			mp.config.Peers[i].Learner = false
+			updated = true
+			break
+		}
+	}
+	log.LogInfof("PromoteRaftLearner PartitionID(%v) nodeID(%v) learner(%v) updated(%v)",
+		req.PartitionID, mp.config.NodeId, req.AddPeer, updated)
+	return
+}
+
 func (mp *metaPartition) confRemoveNode(req *proto.MetaPartitionDecommissionRequest,
 	index uint64) (updated bool, err error) {
 	peerIndex := -1
@@ -198,6 +212,10 @@ func (mp *metaPartition) CanRemoveRaftMember(peer proto.Peer) error {
 	hasExsit := false
 	for _, p := range mp.config.Peers {
 		if p.ID == peer.ID {
+			if p.Learner {
+				// the learners are not counted in the quorum
+				return nil
+			}
 			hasExsit = true
 			break
 		}
@@ -206,15 +224,21 @@ func (mp *metaPartition) CanRemoveRaftMember(peer proto.Peer) error {
 		return fmt.Errorf("peer(%v) not exsit downReplicas(%v)", peer, downReplicas)
 	}
 
+	learners := make(map[uint64]bool)
+	for _, p := range mp.config.Peers {
+		if p.Learner {
+			learners[p.ID] = true
+		}
+	}
 	hasDownReplicasExcludePeer := make([]uint64, 0)
 	for _, nodeID := range downReplicas {
-		if nodeID.NodeID == peer.ID {
+		if nodeID.NodeID == peer.ID || learners[nodeID.NodeID] {
 			continue
 		}
 		hasDownReplicasExcludePeer = append(hasDownReplicasExcludePeer, nodeID.NodeID)
 	}
 
-	sumReplicas := len(mp.config.Peers)
+	sumReplicas := len(mp.config.Peers) - len(learners)
 	if sumReplicas%2 == 1 {
 		if sumReplicas-len(hasDownReplicasExcludePeer) > (sumReplicas/2 + 1) {
 			return nil
@@ -227,3 +251,21 @@ func (mp *metaPartition) CanRemoveRaftMember(peer proto.Peer) error {
 
 	return fmt.Errorf("downReplicas(%v) too much,so donnot offline (%v)", downReplicas, peer)
 }
+
+// CanPromoteRaftLearner returns true if the peer is a learner caught up with the leader, or false
+// if it has been promoted already.
+func (mp *metaPartition) CanPromoteRaftLearner(peer proto.Peer) (promote bool, err error) {
+	for _, p := range mp.config.Peers {
+		if p.ID != peer.ID {
+			continue
+		}
+		if !p.Learner {
+			return false, nil
+		}
+		if err = mp.raftPartition.CheckLearnerCaughtUp(peer.ID); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("peer(%v) not exsit", peer)
+}
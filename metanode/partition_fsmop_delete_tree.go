@@ -0,0 +1,77 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The directories detached to be deleted with their subtrees are kept under their parents with
+// the mark names, which are not counted in the links of the parents like the dentries in the trash,
+// until the subtrees are deleted by the leader of the partition.
+
+// fsmMarkDeleteTree deletes the dentry of the original name of the directory, and inserts the
+// mark dentry referring to the same inode.
+func (mp *metaPartition) fsmMarkDeleteTree(mark *Dentry) (resp *DentryResponse) {
+	resp = NewDentryResponse()
+	name, _, ok := proto.ParseDeleteTreeName(mark.Name)
+	if !ok {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+	dentry := &Dentry{ParentId: mark.ParentId, Name: name}
+	item := mp.dentryTree.Get(dentry)
+	if item == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	if !proto.IsDir(item.(*Dentry).Type) {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+	if resp = mp.fsmDeleteDentry(dentry); resp.Status != proto.OpOk {
+		return
+	}
+	mark.Inode, mark.Type = resp.Msg.Inode, resp.Msg.Type
+	mp.dentryTree.ReplaceOrInsert(mark, true)
+	return
+}
+
+// fsmFinishDeleteTree removes the mark dentry, whose subtree and inode have been deleted.
+func (mp *metaPartition) fsmFinishDeleteTree(mark *Dentry) (status uint8) {
+	if mp.dentryTree.Delete(mark) == nil {
+		return proto.OpNotExistErr
+	}
+	return proto.OpOk
+}
+
+// deleteTreeDentries returns the jobs of the mark dentries.
+func (mp *metaPartition) deleteTreeDentries() (jobs []*proto.DeleteTreeJob) {
+	mp.dentryTree.Ascend(func(i BtreeItem) bool {
+		d := i.(*Dentry)
+		name, markTime, ok := proto.ParseDeleteTreeName(d.Name)
+		if ok {
+			jobs = append(jobs, &proto.DeleteTreeJob{
+				ParentID: d.ParentId,
+				Name:     name,
+				MarkName: d.Name,
+				Inode:    d.Inode,
+				MarkTime: markTime,
+			})
+		}
+		return true
+	})
+	return
+}
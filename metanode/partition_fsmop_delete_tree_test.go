@@ -0,0 +1,93 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_MarkDeleteTree(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		inodeTree:  NewBtree(),
+		dentryTree: NewBtree(),
+	}
+	mp.inodeTree.ReplaceOrInsert(NewInode(1, proto.Mode(os.ModeDir|0755)), false)
+	mp.inodeTree.ReplaceOrInsert(NewInode(2, proto.Mode(os.ModeDir|0755)), false)
+	mp.inodeTree.ReplaceOrInsert(NewInode(3, proto.Mode(0644)), false)
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "d", Inode: 2, Type: proto.Mode(os.ModeDir | 0755)}, false)
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "f", Inode: 3, Type: proto.Mode(0644)}, false)
+	var nlink = func() uint32 {
+		return mp.inodeTree.Get(NewInode(1, 0)).(*Inode).GetNLink()
+	}
+	linked := nlink()
+
+	if resp := mp.fsmMarkDeleteTree(&Dentry{ParentId: 1, Name: proto.DeleteTreeName("f", 100)}); resp.Status != proto.OpArgMismatchErr {
+		t.Fatalf("mark file: status(%v)", resp.Status)
+	}
+	if resp := mp.fsmMarkDeleteTree(&Dentry{ParentId: 1, Name: proto.DeleteTreeName("e", 100)}); resp.Status != proto.OpNotExistErr {
+		t.Fatalf("mark nonexistent directory: status(%v)", resp.Status)
+	}
+	markName := proto.DeleteTreeName("d", 100)
+	if resp := mp.fsmMarkDeleteTree(&Dentry{ParentId: 1, Name: markName}); resp.Status != proto.OpOk || resp.Msg.Inode != 2 {
+		t.Fatalf("mark directory: status(%v) dentry(%v)", resp.Status, resp.Msg)
+	}
+	if _, status := mp.getDentry(&Dentry{ParentId: 1, Name: "d"}); status != proto.OpNotExistErr {
+		t.Fatalf("marked directory found: status(%v)", status)
+	}
+	if nlink() != linked-1 {
+		t.Fatalf("mark dentry counted in the links of the parent: nlink(%v)", nlink())
+	}
+	if children := mp.readDir(&ReadDirReq{ParentID: 1}).Children; len(children) != 1 || children[0].Name != "f" {
+		t.Fatalf("mark dentry listed: %v", children)
+	}
+	jobs := mp.deleteTreeDentries()
+	if len(jobs) != 1 || jobs[0].Name != "d" || jobs[0].MarkName != markName || jobs[0].Inode != 2 || jobs[0].MarkTime != 100 {
+		t.Fatalf("delete tree dentries: %v", jobs)
+	}
+
+	if status := mp.fsmFinishDeleteTree(&Dentry{ParentId: 1, Name: markName}); status != proto.OpOk {
+		t.Fatalf("finish: status(%v)", status)
+	}
+	if status := mp.fsmFinishDeleteTree(&Dentry{ParentId: 1, Name: markName}); status != proto.OpNotExistErr {
+		t.Fatalf("finish again: status(%v)", status)
+	}
+	if jobs = mp.deleteTreeDentries(); len(jobs) != 0 {
+		t.Fatalf("finished delete tree dentries: %v", jobs)
+	}
+}
+
+func TestDeleteTreeProgress(t *testing.T) {
+	var d deleteTreeProgress
+	job1 := &proto.DeleteTreeJob{ParentID: 1, MarkName: proto.DeleteTreeName("a", 100)}
+	job2 := &proto.DeleteTreeJob{ParentID: 1, MarkName: proto.DeleteTreeName("b", 100)}
+	d.count(job1, 2, 1)
+	d.count(job1, 1, 0)
+	d.count(job2, 0, 1)
+
+	jobs := d.progress([]*proto.DeleteTreeJob{{ParentID: 1, MarkName: job1.MarkName}})
+	if jobs[0].DeletedFiles != 3 || jobs[0].DeletedDirs != 1 {
+		t.Fatalf("progress: %v", jobs[0])
+	}
+	// the progress of the finished jobs is dropped
+	d.retain([]*proto.DeleteTreeJob{job1})
+	jobs = d.progress([]*proto.DeleteTreeJob{{ParentID: 1, MarkName: job2.MarkName}})
+	if jobs[0].DeletedDirs != 0 {
+		t.Fatalf("progress of the finished job: %v", jobs[0])
+	}
+}
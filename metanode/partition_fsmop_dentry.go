@@ -141,6 +141,9 @@ func (mp *metaPartition) readDir(req *ReadDirReq) (resp *ReadDirResp) {
 	}
 	mp.dentryTree.AscendRange(begDentry, endDentry, func(i BtreeItem) bool {
 		d := i.(*Dentry)
+		if proto.IsTrashName(d.Name) || proto.IsDeleteTreeName(d.Name) {
+			return true
+		}
 		resp.Children = append(resp.Children, proto.Dentry{
 			Inode: d.Inode,
 			Type:  d.Type,
@@ -150,3 +153,82 @@ func (mp *metaPartition) readDir(req *ReadDirReq) (resp *ReadDirResp) {
 	})
 	return
 }
+
+// listDentry lists a page of the dentries of the directory in the name order, beginning with the
+// prefix and after the marker. The names containing the delimiter after the prefix are rolled up
+// into the common prefixes, which are contiguous in the name order, and each common prefix is
+// counted as one entry by the limit. A common prefix equal to the marker is listed by the previous
+// page, so it is skipped. The limit 0 lists all.
+func (mp *metaPartition) listDentry(req *proto.ListDentryRequest) (resp *proto.ListDentryResponse) {
+	resp = &proto.ListDentryResponse{}
+	begDentry := &Dentry{
+		ParentId: req.ParentID,
+		Name:     req.Prefix,
+	}
+	if req.Marker > req.Prefix {
+		begDentry.Name = req.Marker
+	}
+	endDentry := &Dentry{
+		ParentId: req.ParentID + 1,
+	}
+	var count uint64
+	var lastPrefix string
+	mp.dentryTree.AscendRange(begDentry, endDentry, func(i BtreeItem) bool {
+		d := i.(*Dentry)
+		if !strings.HasPrefix(d.Name, req.Prefix) {
+			return false
+		}
+		if d.Name == req.Marker || proto.IsTrashName(d.Name) || proto.IsDeleteTreeName(d.Name) {
+			return true
+		}
+		var commonPrefix string
+		if req.Delimiter != "" {
+			if idx := strings.Index(d.Name[len(req.Prefix):], req.Delimiter); idx >= 0 {
+				commonPrefix = d.Name[:len(req.Prefix)+idx+len(req.Delimiter)]
+			}
+		}
+		if commonPrefix != "" && (commonPrefix == lastPrefix || commonPrefix == req.Marker) {
+			return true
+		}
+		if req.Limit > 0 && count >= req.Limit {
+			resp.IsTruncated = true
+			return false
+		}
+		count++
+		if commonPrefix != "" {
+			lastPrefix = commonPrefix
+			resp.CommonPrefixes = append(resp.CommonPrefixes, commonPrefix)
+			resp.NextMarker = commonPrefix
+			return true
+		}
+		resp.Children = append(resp.Children, proto.Dentry{
+			Inode: d.Inode,
+			Type:  d.Type,
+			Name:  d.Name,
+		})
+		resp.NextMarker = d.Name
+		return true
+	})
+	if !resp.IsTruncated {
+		resp.NextMarker = ""
+	}
+	return
+}
+
+// reverseLookup returns the dentries referring to the inode, including the ones in the trash.
+// The whole dentry tree is scanned since the dentries are indexed by the parents only.
+func (mp *metaPartition) reverseLookup(ino uint64) (links []*proto.InodeLink) {
+	mp.dentryTree.Ascend(func(i BtreeItem) bool {
+		d := i.(*Dentry)
+		if d.Inode == ino {
+			links = append(links, &proto.InodeLink{
+				ParentID: d.ParentId,
+				Name:     d.Name,
+				Type:     d.Type,
+				Trash:    proto.IsTrashName(d.Name),
+			})
+		}
+		return true
+	})
+	return
+}
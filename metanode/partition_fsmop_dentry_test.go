@@ -0,0 +1,89 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_ReverseLookup(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		dentryTree: NewBtree(),
+	}
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "a", Inode: 5, Type: proto.Mode(0644)}, false)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "b", Inode: 6, Type: proto.Mode(0644)}, false)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 3, Name: "c", Inode: 5, Type: proto.Mode(0644)}, false)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 4, Name: proto.TrashName("d", 100), Inode: 5, Type: proto.Mode(0644)}, false)
+
+	links := mp.reverseLookup(5)
+	if len(links) != 3 {
+		t.Fatalf("reverse lookup: links(%v)", links)
+	}
+	if links[0].ParentID != 1 || links[0].Name != "a" || links[0].Trash ||
+		links[1].ParentID != 3 || links[1].Name != "c" || links[1].Trash ||
+		links[2].ParentID != 4 || !links[2].Trash {
+		t.Fatalf("reverse lookup: links(%v %v %v)", links[0], links[1], links[2])
+	}
+	if links = mp.reverseLookup(7); len(links) != 0 {
+		t.Fatalf("reverse lookup of unlinked inode: links(%v)", links)
+	}
+}
+
+func TestMetaPartition_ListDentry(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		dentryTree: NewBtree(),
+	}
+	for i, name := range []string{"a", "b-1", "b-2", "b0", "c-1", "d", proto.TrashName("b3", 100)} {
+		mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: name, Inode: uint64(10 + i), Type: proto.Mode(0644)}, false)
+	}
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 2, Name: "b1", Inode: 20, Type: proto.Mode(0644)}, false)
+
+	names := func(resp *proto.ListDentryResponse) (result []string) {
+		for _, child := range resp.Children {
+			result = append(result, child.Name)
+		}
+		return
+	}
+	cases := []struct {
+		req        proto.ListDentryRequest
+		children   []string
+		prefixes   []string
+		nextMarker string
+	}{
+		{proto.ListDentryRequest{}, []string{"a", "b-1", "b-2", "b0", "c-1", "d"}, nil, ""},
+		{proto.ListDentryRequest{Prefix: "b"}, []string{"b-1", "b-2", "b0"}, nil, ""},
+		{proto.ListDentryRequest{Delimiter: "-"}, []string{"a", "b0", "d"}, []string{"b-", "c-"}, ""},
+		{proto.ListDentryRequest{Delimiter: "-", Limit: 2}, []string{"a"}, []string{"b-"}, "b-"},
+		// the common prefix equal to the marker is of the previous page
+		{proto.ListDentryRequest{Delimiter: "-", Marker: "b-", Limit: 2}, []string{"b0"}, []string{"c-"}, "c-"},
+		{proto.ListDentryRequest{Prefix: "b", Marker: "b-1"}, []string{"b-2", "b0"}, nil, ""},
+		{proto.ListDentryRequest{Prefix: "b-", Delimiter: "-"}, []string{"b-1", "b-2"}, nil, ""},
+		{proto.ListDentryRequest{Prefix: "e"}, nil, nil, ""},
+	}
+	for i, c := range cases {
+		c.req.ParentID = 1
+		resp := mp.listDentry(&c.req)
+		if !reflect.DeepEqual(names(resp), c.children) || !reflect.DeepEqual(resp.CommonPrefixes, c.prefixes) ||
+			resp.NextMarker != c.nextMarker || resp.IsTruncated != (c.nextMarker != "") {
+			t.Fatalf("case(%v): children(%v) prefixes(%v) nextMarker(%v) truncated(%v)",
+				i, names(resp), resp.CommonPrefixes, resp.NextMarker, resp.IsTruncated)
+		}
+	}
+}
@@ -14,6 +14,13 @@
 
 package metanode
 
+import (
+	"os"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
 type ExtendOpResult struct {
 	Status uint8
 	Extend *Extend
@@ -44,3 +51,58 @@ func (mp *metaPartition) fsmRemoveXAttr(extend *Extend) (err error) {
 	})
 	return
 }
+
+// fsmSetACL sets or removes the ACL of the inode. The access ACL equivalent to the mode is not
+// kept, and it sets the permission bits of the mode as well.
+func (mp *metaPartition) fsmSetACL(req *proto.SetACLRequest) (status uint8) {
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		return proto.OpNotExistErr
+	}
+	ino := item.(*Inode)
+	if ino.ShouldDelete() {
+		return proto.OpNotExistErr
+	}
+	var key = proto.XAttrPosixACLAccess
+	if req.Default {
+		key = proto.XAttrPosixACLDefault
+	}
+	var extend = NewExtend(req.Inode)
+	if len(req.ACL) == 0 || !req.Default && req.ACL.IsMinimal() {
+		extend.Put([]byte(key), nil)
+		_ = mp.fsmRemoveXAttr(extend)
+	} else {
+		extend.Put([]byte(key), req.ACL.Encode())
+		_ = mp.fsmSetXAttr(extend)
+	}
+	if !req.Default && len(req.ACL) > 0 {
+		ino.DoWriteFunc(func() {
+			ino.Type = ino.Type&^uint32(os.ModePerm) | req.ACL.Mode()
+		})
+	}
+	return proto.OpOk
+}
+
+// chmodACL sets the owner, the group class and the others of the access ACL of the inode, if
+// any, to the permission bits of the mode changed.
+func (mp *metaPartition) chmodACL(ino *Inode) {
+	item := mp.extendTree.Get(NewExtend(ino.Inode))
+	if item == nil {
+		return
+	}
+	extend := item.(*Extend)
+	raw, exist := extend.Get([]byte(proto.XAttrPosixACLAccess))
+	if !exist {
+		return
+	}
+	acl, err := proto.DecodeACL(raw)
+	if err != nil {
+		log.LogWarnf("chmodACL: partitionID(%v) inode(%v) err(%v)", mp.config.PartitionId, ino.Inode, err)
+		return
+	}
+	var mode uint32
+	ino.DoReadFunc(func() {
+		mode = ino.Type
+	})
+	extend.Put([]byte(proto.XAttrPosixACLAccess), acl.Chmod(mode).Encode())
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_SetACL(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		inodeTree:  NewBtree(),
+		extendTree: NewBtree(),
+	}
+	mp.inodeTree.ReplaceOrInsert(NewInode(2, proto.Mode(0640)), false)
+	var storedACL = func(key string) proto.ACL {
+		item := mp.extendTree.Get(NewExtend(2))
+		if item == nil {
+			return nil
+		}
+		raw, exist := item.(*Extend).Get([]byte(key))
+		if !exist {
+			return nil
+		}
+		acl, err := proto.DecodeACL(raw)
+		if err != nil {
+			t.Fatalf("decode acl: %v", err)
+		}
+		return acl
+	}
+	var mode = func() uint32 {
+		return mp.inodeTree.Get(NewInode(2, 0)).(*Inode).Type
+	}
+
+	acl := proto.ACL{
+		{Tag: proto.ACLUserObj, Perm: 6},
+		{Tag: proto.ACLUser, Perm: 7, ID: 1001},
+		{Tag: proto.ACLGroupObj, Perm: 4},
+		{Tag: proto.ACLMask, Perm: 6},
+		{Tag: proto.ACLOther, Perm: 0},
+	}
+	if status := mp.fsmSetACL(&proto.SetACLRequest{Inode: 2, ACL: acl}); status != proto.OpOk {
+		t.Fatalf("set acl: status(%v)", status)
+	}
+	if mode() != 0660 || !reflect.DeepEqual(storedACL(proto.XAttrPosixACLAccess), acl) {
+		t.Fatalf("set acl: mode(%o) acl(%v)", mode(), storedACL(proto.XAttrPosixACLAccess))
+	}
+	if !acl.Permits(0, 0, 1001, nil, proto.ACLRead|proto.ACLWrite) || acl.Permits(0, 0, 1001, nil, proto.ACLExecute) {
+		t.Fatalf("named user is not limited by the mask")
+	}
+	if !acl.Permits(0, 100, 1002, []uint32{100}, proto.ACLRead) || acl.Permits(0, 100, 1002, []uint32{100}, proto.ACLWrite) {
+		t.Fatalf("owning group is not checked")
+	}
+
+	// chmod sets the mask instead of the owning group
+	if err := mp.fsmSetAttr(&SetattrRequest{Inode: 2, Valid: proto.AttrMode, Mode: 0750}); err != nil {
+		t.Fatalf("set attr: %v", err)
+	}
+	chmoded := storedACL(proto.XAttrPosixACLAccess)
+	if mode() != 0750 || chmoded.Mode() != 0750 || chmoded[2].Perm != 4 {
+		t.Fatalf("chmod: mode(%o) acl(%v)", mode(), chmoded)
+	}
+
+	// the minimal access acl is not kept but sets the mode
+	if status := mp.fsmSetACL(&proto.SetACLRequest{Inode: 2, ACL: proto.NewACLFromMode(0604)}); status != proto.OpOk {
+		t.Fatalf("set minimal acl: status(%v)", status)
+	}
+	if mode() != 0604 || storedACL(proto.XAttrPosixACLAccess) != nil {
+		t.Fatalf("set minimal acl: mode(%o) acl(%v)", mode(), storedACL(proto.XAttrPosixACLAccess))
+	}
+
+	if status := mp.fsmSetACL(&proto.SetACLRequest{Inode: 3, ACL: acl}); status != proto.OpNotExistErr {
+		t.Fatalf("set acl of missing inode: status(%v)", status)
+	}
+}
+
+func TestACL_EncodeAndInherit(t *testing.T) {
+	acl := proto.ACL{
+		{Tag: proto.ACLOther, Perm: 5},
+		{Tag: proto.ACLGroup, Perm: 7, ID: 20},
+		{Tag: proto.ACLUserObj, Perm: 7},
+		{Tag: proto.ACLMask, Perm: 7},
+		{Tag: proto.ACLGroupObj, Perm: 5},
+	}
+	decoded, err := proto.DecodeACL(acl.Encode())
+	if err != nil {
+		t.Fatalf("decode acl: %v", err)
+	}
+	if decoded[0].Tag != proto.ACLUserObj || decoded[4].Tag != proto.ACLOther || decoded.Mode() != 0775 {
+		t.Fatalf("decoded acl is not sorted: %v", decoded)
+	}
+	if _, err = proto.DecodeACL(acl[:4].Encode()); err != proto.ErrInvalidACL {
+		t.Fatalf("acl without others is decoded: %v", err)
+	}
+
+	inherited := decoded.Inherit(uint32(os.ModeDir | 0640))
+	if inherited.Mode() != 0640 || inherited[2].Perm != 7 || inherited[1].Perm != 5 {
+		t.Fatalf("inherited acl: %v", inherited)
+	}
+}
+
+func TestMetaPartition_SetXAttrs(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		extendTree: NewBtree(),
+	}
+	var setXAttrs = func(attrs map[string]string) {
+		extend := NewExtend(2)
+		for key, value := range attrs {
+			extend.Put([]byte(key), []byte(value))
+		}
+		if err := mp.fsmSetXAttr(extend); err != nil {
+			t.Fatalf("set xattrs: %v", err)
+		}
+	}
+	setXAttrs(map[string]string{"a": "1", "b": "2"})
+	// the xattrs set at once are merged with the existing ones
+	setXAttrs(map[string]string{"b": "3", "c": "4"})
+
+	stored := make(map[string]string)
+	mp.extendTree.Get(NewExtend(2)).(*Extend).Range(func(key, value []byte) bool {
+		stored[string(key)] = string(value)
+		return true
+	})
+	if expect := map[string]string{"a": "1", "b": "3", "c": "4"}; !reflect.DeepEqual(stored, expect) {
+		t.Fatalf("xattrs(%v), expect(%v)", stored, expect)
+	}
+}
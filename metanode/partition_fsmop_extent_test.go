@@ -0,0 +1,53 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_AppendExtentsIfGeneration(t *testing.T) {
+	mp := &metaPartition{
+		config:    &MetaPartitionConfig{PartitionId: 1},
+		inodeTree: NewBtree(),
+		extDelCh:  make(chan BtreeItem, 10),
+	}
+	mp.inodeTree.ReplaceOrInsert(NewInode(2, proto.Mode(0644)), false)
+	var appendExtent = func(fileOffset, gen uint64, checkGeneration bool) uint8 {
+		ino := NewInode(2, 0)
+		ino.Generation = gen
+		ino.Extents.Append(&proto.ExtentKey{FileOffset: fileOffset, PartitionId: 1, ExtentId: fileOffset + 1, Size: 1})
+		return mp.fsmAppendExtents(ino, checkGeneration)
+	}
+	var generation = func() uint64 {
+		return mp.inodeTree.Get(NewInode(2, 0)).(*Inode).Generation
+	}
+
+	gen := generation()
+	if status := appendExtent(0, 0, false); status != proto.OpOk {
+		t.Fatalf("append extent: status(%v)", status)
+	}
+	if status := appendExtent(1, gen, true); status != proto.OpArgMismatchErr {
+		t.Fatalf("append extent of the old generation: status(%v)", status)
+	}
+	if generation() != gen+1 {
+		t.Fatalf("generation changed by the rejected append: %v", generation())
+	}
+	if status := appendExtent(1, gen+1, true); status != proto.OpOk || generation() != gen+2 {
+		t.Fatalf("append extent of the current generation: status(%v) generation(%v)", status, generation())
+	}
+}
@@ -161,10 +161,17 @@ func (mp *metaPartition) internalDeleteInode(ino *Inode) {
 	mp.inodeTree.Delete(ino)
 	mp.freeList.Remove(ino.Inode)
 	mp.extendTree.Delete(&Extend{inode: ino.Inode}) // Also delete extend attribute.
+	if mp.rocksdbStore != nil {
+		// the internal deletion is not always applied from the raft log
+		mp.deleteStoredItems(NewInode(ino.Inode, 0), &Extend{inode: ino.Inode})
+	}
 	return
 }
 
-func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
+// fsmAppendExtents appends the extent keys to the inode, which are rejected if the generation of
+// the inode has changed since the one of the request when checked, so that the extents copied from
+// the old ones never overwrite the data written meanwhile.
+func (mp *metaPartition) fsmAppendExtents(ino *Inode, checkGeneration bool) (status uint8) {
 	status = proto.OpOk
 	var items []BtreeItem
 	item := mp.inodeTree.CopyGet(ino)
@@ -177,6 +184,10 @@ func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
 		status = proto.OpNotExistErr
 		return
 	}
+	if checkGeneration && ino2.Generation != ino.Generation {
+		status = proto.OpArgMismatchErr
+		return
+	}
 	ino.Extents.Range(func(item BtreeItem) bool {
 		items = append(items, item)
 		return true
@@ -269,5 +280,8 @@ func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 		return
 	}
 	ino.SetAttr(req.Valid, req.Mode, req.Uid, req.Gid)
+	if req.Valid&proto.AttrMode != 0 {
+		mp.chmodACL(ino)
+	}
 	return
 }
@@ -0,0 +1,75 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The byte-range locks are replicated by the raft log with the time of the leader, so that they
+// survive the leader changes, and the leases are checked against the time in the log instead of
+// the clocks of the replicas.
+
+// lockOp is the raft log of the lock ops, stamped with the time of the leader.
+type lockOp struct {
+	Inode  uint64           `json:"ino"`
+	Lock   *proto.RangeLock `json:"lock"`
+	Client uint64           `json:"client"`
+	Now    int64            `json:"now"`
+	Expire int64            `json:"expire"`
+}
+
+func (mp *metaPartition) fsmSetLock(op *lockOp) (status uint8) {
+	locks := NewInodeLocks(op.Inode)
+	if item := mp.lockTree.Get(locks); item != nil {
+		locks = item.(*InodeLocks)
+	}
+	if locks, status = locks.set(op.Lock, op.Now); status != proto.OpOk {
+		return
+	}
+	if len(locks.Locks) == 0 {
+		mp.lockTree.Delete(locks)
+		return
+	}
+	mp.lockTree.ReplaceOrInsert(locks, true)
+	return
+}
+
+// fsmRenewLocks renews the leases of the locks of the client, and drops the expired locks of all
+// the inodes. It returns the number of the locks of the client and the inodes changed.
+func (mp *metaPartition) fsmRenewLocks(op *lockOp) (count int, changed []BtreeItem) {
+	var touched []*InodeLocks
+	mp.lockTree.Ascend(func(i BtreeItem) bool {
+		locks := i.(*InodeLocks)
+		for _, held := range locks.Locks {
+			if held.Client == op.Client || held.Expire <= op.Now {
+				touched = append(touched, locks)
+				break
+			}
+		}
+		return true
+	})
+	for _, locks := range touched {
+		renewed, n := locks.renew(op.Client, op.Now, op.Expire)
+		count += n
+		if len(renewed.Locks) == 0 {
+			mp.lockTree.Delete(renewed)
+		} else {
+			mp.lockTree.ReplaceOrInsert(renewed, true)
+		}
+		changed = append(changed, NewInodeLocks(locks.Inode))
+	}
+	return
+}
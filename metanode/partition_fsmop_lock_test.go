@@ -0,0 +1,85 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_SetLock(t *testing.T) {
+	mp := &metaPartition{
+		config:   &MetaPartitionConfig{PartitionId: 1},
+		lockTree: NewBtree(),
+	}
+	var set = func(lockType uint32, start, end, client, owner uint64, now int64) uint8 {
+		lock := &proto.RangeLock{Start: start, End: end, Type: lockType, Client: client, Owner: owner, Expire: now + 30}
+		return mp.fsmSetLock(&lockOp{Inode: 2, Lock: lock, Now: now})
+	}
+	var held = func() []*proto.RangeLock {
+		item := mp.lockTree.Get(NewInodeLocks(2))
+		if item == nil {
+			return nil
+		}
+		return item.(*InodeLocks).Locks
+	}
+
+	if status := set(proto.LockRead, 0, 99, 1, 1, 0); status != proto.OpOk {
+		t.Fatalf("read lock: status(%v)", status)
+	}
+	if status := set(proto.LockRead, 50, 149, 2, 1, 0); status != proto.OpOk {
+		t.Fatalf("shared read lock: status(%v)", status)
+	}
+	if status := set(proto.LockWrite, 90, 199, 2, 2, 0); status != proto.OpExistErr {
+		t.Fatalf("conflicting write lock: status(%v)", status)
+	}
+
+	// the write lock of the same owner splits its read lock
+	if status := set(proto.LockWrite, 20, 29, 1, 1, 0); status != proto.OpOk {
+		t.Fatalf("upgrade: status(%v)", status)
+	}
+	if locks := held(); len(locks) != 4 {
+		t.Fatalf("upgrade: locks(%v)", locks)
+	}
+	if status := set(proto.LockRead, 25, 25, 2, 1, 0); status != proto.OpExistErr {
+		t.Fatalf("read lock on the write lock: status(%v)", status)
+	}
+
+	// the locks expired are dropped
+	if status := set(proto.LockWrite, 100, 199, 3, 1, 10); status != proto.OpExistErr {
+		t.Fatalf("write lock before expired: status(%v)", status)
+	}
+	if count, _ := mp.fsmRenewLocks(&lockOp{Client: 1, Now: 20, Expire: 50}); count != 3 {
+		t.Fatalf("renew: count(%v)", count)
+	}
+	if status := set(proto.LockWrite, 100, 199, 3, 1, 40); status != proto.OpOk {
+		t.Fatalf("write lock after expired: status(%v)", status)
+	}
+	if status := set(proto.LockWrite, 0, 9, 3, 1, 40); status != proto.OpExistErr {
+		t.Fatalf("write lock on the lock renewed: status(%v)", status)
+	}
+
+	// unlocks release the ranges and the item of the inode at last
+	if status := set(proto.LockUnlock, 0, 99, 1, 1, 40); status != proto.OpOk {
+		t.Fatalf("unlock: status(%v)", status)
+	}
+	if locks := held(); len(locks) != 1 || locks[0].Client != 3 {
+		t.Fatalf("unlock: locks(%v)", locks)
+	}
+	if status := set(proto.LockUnlock, 0, 1<<63, 3, 1, 40); status != proto.OpOk || held() != nil {
+		t.Fatalf("unlock all: status(%v) locks(%v)", status, held())
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_PromoteLearner(t *testing.T) {
+	voter := proto.Peer{ID: 1, Addr: "127.0.0.1:17210"}
+	learner := proto.Peer{ID: 2, Addr: "127.0.0.2:17210", Learner: true}
+	mp := &metaPartition{
+		config: &MetaPartitionConfig{PartitionId: 1, NodeId: 1, Peers: []proto.Peer{voter, learner}},
+	}
+
+	if promote, err := mp.CanPromoteRaftLearner(voter); promote || err != nil {
+		t.Fatalf("promote voter: promote(%v) err(%v)", promote, err)
+	}
+	if _, err := mp.CanPromoteRaftLearner(proto.Peer{ID: 3, Addr: "127.0.0.3:17210"}); err == nil {
+		t.Fatalf("promote unknown peer: expect error")
+	}
+
+	req := &proto.MetaPartitionDecommissionRequest{PartitionID: 1, AddPeer: proto.Peer{ID: learner.ID, Addr: learner.Addr}}
+	if updated, err := mp.confPromoteLearner(req, 1); !updated || err != nil {
+		t.Fatalf("promote learner: updated(%v) err(%v)", updated, err)
+	}
+	if mp.config.Peers[1].Learner {
+		t.Fatalf("promote learner: peers(%v)", mp.config.Peers)
+	}
+	// the promotion replayed from the raft log changes nothing
+	if updated, err := mp.confPromoteLearner(req, 2); updated || err != nil {
+		t.Fatalf("promote again: updated(%v) err(%v)", updated, err)
+	}
+	if promote, err := mp.CanPromoteRaftLearner(learner); promote || err != nil {
+		t.Fatalf("promote promoted learner: promote(%v) err(%v)", promote, err)
+	}
+}
@@ -14,7 +14,10 @@
 
 package metanode
 
-import "github.com/chubaofs/chubaofs/proto"
+import (
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
 
 func (mp *metaPartition) fsmCreateMultipart(multipart *Multipart) (status uint8) {
 	_, ok := mp.multipartTree.ReplaceOrInsert(multipart, false)
@@ -45,3 +48,77 @@ func (mp *metaPartition) fsmAppendMultipart(multipart *Multipart) (status uint8)
 	}
 	return proto.OpOk
 }
+
+// fsmCompleteMultipart validates the parts, creates the file of the object with the extent keys and
+// the extended attributes, links it to the name and removes the multipart upload. Nothing is changed
+// unless all the steps can be applied.
+func (mp *metaPartition) fsmCompleteMultipart(c *MultipartCompletion) (resp *MultipartCompletionResult) {
+	resp = &MultipartCompletionResult{Status: proto.OpOk}
+	storedItem := mp.multipartTree.Get(&Multipart{id: c.MultipartID})
+	if storedItem == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	multipart := storedItem.(*Multipart)
+	var parts Parts
+	if parts, resp.Err = multipart.CompletedParts(c.Parts); resp.Err != nil {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+
+	// check the parent, the name and the inode before any change
+	parentItem := mp.inodeTree.Get(NewInode(c.ParentID, 0))
+	if parentItem == nil || parentItem.(*Inode).ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	if !proto.IsDir(parentItem.(*Inode).Type) {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+	var existDentry *Dentry
+	if item := mp.dentryTree.Get(&Dentry{ParentId: c.ParentID, Name: c.Name}); item != nil {
+		existDentry = item.(*Dentry)
+		if proto.IsDir(existDentry.Type) {
+			resp.Status = proto.OpArgMismatchErr
+			return
+		}
+	}
+	if mp.inodeTree.Has(NewInode(c.Inode, 0)) {
+		resp.Status = proto.OpExistErr
+		return
+	}
+
+	ino := NewInode(c.Inode, c.Mode)
+	ino.CreateTime, ino.AccessTime = c.Timestamp, c.Timestamp
+	var eks = make([]BtreeItem, 0, len(c.Extents))
+	for i := range c.Extents {
+		eks = append(eks, &c.Extents[i])
+	}
+	ino.AppendExtents(eks, c.Timestamp)
+	if resp.Status = mp.fsmCreateInode(ino); resp.Status != proto.OpOk {
+		return
+	}
+
+	resp.ETag = MultipartETag(parts)
+	extend := NewExtend(ino.Inode)
+	for key, value := range c.Extend {
+		extend.Put([]byte(key), []byte(value))
+	}
+	if c.ETagKey != "" {
+		extend.Put([]byte(c.ETagKey), []byte(resp.ETag))
+	}
+	_ = mp.fsmSetXAttr(extend)
+
+	if existDentry == nil {
+		mp.fsmCreateDentry(&Dentry{ParentId: c.ParentID, Name: c.Name, Inode: ino.Inode, Type: c.Mode}, false)
+	} else {
+		updated := mp.fsmUpdateDentry(&Dentry{ParentId: c.ParentID, Name: c.Name, Inode: ino.Inode})
+		resp.OldInode = updated.Msg.Inode
+	}
+	mp.multipartTree.Delete(multipart)
+	resp.Inode = ino
+	log.LogDebugf("fsmCompleteMultipart: multipart completed: partitionID(%v) multipartID(%v) parentID(%v) name(%v) inode(%v) oldInode(%v)",
+		mp.config.PartitionId, c.MultipartID, c.ParentID, c.Name, ino.Inode, resp.OldInode)
+	return
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The dentries in the trash are kept under their parents with the trash names, which are not
+// counted in the links of the parents, so that the directories with only the dentries in the
+// trash are still empty. The inodes are not unlinked until the dentries are purged.
+
+// fsmTrashDentry deletes the dentry of the original name of the trash dentry, and inserts the
+// trash dentry referring to the same inode.
+func (mp *metaPartition) fsmTrashDentry(trash *Dentry) (resp *DentryResponse) {
+	name, _, ok := proto.ParseTrashName(trash.Name)
+	if !ok {
+		resp = NewDentryResponse()
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+	resp = mp.fsmDeleteDentry(&Dentry{ParentId: trash.ParentId, Name: name})
+	if resp.Status != proto.OpOk {
+		return
+	}
+	trash.Inode, trash.Type = resp.Msg.Inode, resp.Msg.Type
+	mp.dentryTree.ReplaceOrInsert(trash, true)
+	return
+}
+
+// fsmRestoreTrash creates the dentry of the name referring to the inode of the trash dentry,
+// and removes the trash dentry if it is created.
+func (mp *metaPartition) fsmRestoreTrash(req *proto.RestoreTrashRequest) (status uint8) {
+	item := mp.dentryTree.Get(&Dentry{ParentId: req.ParentID, Name: req.TrashName})
+	if item == nil {
+		return proto.OpNotExistErr
+	}
+	trash := item.(*Dentry)
+	dentry := &Dentry{
+		ParentId: req.ParentID,
+		Name:     req.Name,
+		Inode:    trash.Inode,
+		Type:     trash.Type,
+	}
+	if status = mp.fsmCreateDentry(dentry, false); status != proto.OpOk {
+		return
+	}
+	mp.dentryTree.Delete(trash)
+	return
+}
+
+// fsmPurgeTrash removes the trash dentry, whose inode has been released.
+func (mp *metaPartition) fsmPurgeTrash(trash *Dentry) (status uint8) {
+	if mp.dentryTree.Delete(trash) == nil {
+		return proto.OpNotExistErr
+	}
+	return proto.OpOk
+}
+
+// trashDentries returns the copies of the dentries in the trash.
+func (mp *metaPartition) trashDentries() (items []*proto.TrashItem) {
+	mp.dentryTree.Ascend(func(i BtreeItem) bool {
+		d := i.(*Dentry)
+		name, deleteTime, ok := proto.ParseTrashName(d.Name)
+		if ok {
+			items = append(items, &proto.TrashItem{
+				ParentID:   d.ParentId,
+				Name:       name,
+				TrashName:  d.Name,
+				Inode:      d.Inode,
+				Type:       d.Type,
+				DeleteTime: deleteTime,
+			})
+		}
+		return true
+	})
+	return
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_TrashAndRestore(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		inodeTree:  NewBtree(),
+		dentryTree: NewBtree(),
+	}
+	parent := NewInode(1, proto.Mode(os.ModeDir|0755))
+	mp.inodeTree.ReplaceOrInsert(parent, false)
+	mp.inodeTree.ReplaceOrInsert(NewInode(2, proto.Mode(0644)), false)
+	if status := mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "f", Inode: 2, Type: proto.Mode(0644)}, false); status != proto.OpOk {
+		t.Fatalf("create dentry: status(%v)", status)
+	}
+	var nlink = func() uint32 {
+		return mp.inodeTree.Get(NewInode(1, 0)).(*Inode).GetNLink()
+	}
+	linked := nlink()
+
+	trashName := proto.TrashName("f", 100)
+	if resp := mp.fsmTrashDentry(&Dentry{ParentId: 1, Name: trashName}); resp.Status != proto.OpOk || resp.Msg.Inode != 2 {
+		t.Fatalf("trash dentry: status(%v) dentry(%v)", resp.Status, resp.Msg)
+	}
+	if nlink() != linked-1 {
+		t.Fatalf("trash dentry counted in the links of the parent: nlink(%v)", nlink())
+	}
+	if children := mp.readDir(&ReadDirReq{ParentID: 1}).Children; len(children) != 0 {
+		t.Fatalf("trash dentry listed: %v", children)
+	}
+	items := mp.trashDentries()
+	if len(items) != 1 || items[0].Name != "f" || items[0].Inode != 2 || items[0].DeleteTime != 100 {
+		t.Fatalf("trash dentries: %v", items)
+	}
+
+	// the name taken by another dentry is not restored
+	mp.inodeTree.ReplaceOrInsert(NewInode(3, proto.Mode(0644)), false)
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "f", Inode: 3, Type: proto.Mode(0644)}, false)
+	if status := mp.fsmRestoreTrash(&proto.RestoreTrashRequest{ParentID: 1, TrashName: trashName, Name: "f"}); status != proto.OpExistErr {
+		t.Fatalf("restore to the existing name: status(%v)", status)
+	}
+	if status := mp.fsmRestoreTrash(&proto.RestoreTrashRequest{ParentID: 1, TrashName: trashName, Name: "g"}); status != proto.OpOk {
+		t.Fatalf("restore: status(%v)", status)
+	}
+	if d, status := mp.getDentry(&Dentry{ParentId: 1, Name: "g"}); status != proto.OpOk || d.Inode != 2 {
+		t.Fatalf("restored dentry: status(%v) dentry(%v)", status, d)
+	}
+	if nlink() != linked+1 || len(mp.trashDentries()) != 0 {
+		t.Fatalf("restored: nlink(%v) trash(%v)", nlink(), mp.trashDentries())
+	}
+
+	if status := mp.fsmPurgeTrash(&Dentry{ParentId: 1, Name: trashName}); status != proto.OpNotExistErr {
+		t.Fatalf("purge restored dentry: status(%v)", status)
+	}
+}
@@ -26,6 +26,9 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+
+	"github.com/chubaofs/chubaofs/raftstore"
+	"github.com/tecbot/gorocksdb"
 )
 
 // MetaItem defines the structure of the metadata operations.
@@ -129,6 +132,12 @@ type MetaItemIterator struct {
 	dentryTree    *BTree
 	extendTree    *BTree
 	multipartTree *BTree
+	lockTree      *BTree
+	volSnapshots  []*volSnapshot
+
+	// the inodes are read from the store in the rocksdb store mode
+	store         *raftstore.RocksDBStore
+	storeSnapshot *gorocksdb.Snapshot
 
 	filenames []string
 
@@ -144,10 +153,16 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	si = new(MetaItemIterator)
 	si.fileRootDir = mp.config.RootDir
 	si.applyID = mp.applyID
-	si.inodeTree = mp.inodeTree.GetTree()
+	if mp.rocksdbStore != nil {
+		si.store, si.storeSnapshot = mp.rocksdbStore, mp.rocksdbStore.RocksDBSnapshot()
+	} else {
+		si.inodeTree = mp.inodeTree.GetTree()
+	}
 	si.dentryTree = mp.dentryTree.GetTree()
 	si.extendTree = mp.extendTree.GetTree()
 	si.multipartTree = mp.multipartTree.GetTree()
+	si.lockTree = mp.lockTree.GetTree()
+	si.volSnapshots = mp.getVolSnapshots()
 	si.dataCh = make(chan interface{})
 	si.errorCh = make(chan error, 1)
 	si.closeCh = make(chan struct{})
@@ -156,6 +171,9 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	var filenames = make([]string, 0)
 	var fileInfos []os.FileInfo
 	if fileInfos, err = ioutil.ReadDir(mp.config.RootDir); err != nil {
+		if si.store != nil {
+			si.store.ReleaseSnapshot(si.storeSnapshot)
+		}
 		return
 	}
 
@@ -198,9 +216,20 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 		produceItem(si.applyID)
 
 		// process inodes
-		iter.inodeTree.Ascend(func(i BtreeItem) bool {
-			return produceItem(i)
-		})
+		if iter.store != nil {
+			err := rangeStoredInodes(iter.store, iter.storeSnapshot, func(ino *Inode) bool {
+				return produceItem(ino)
+			})
+			iter.store.ReleaseSnapshot(iter.storeSnapshot)
+			if err != nil {
+				produceError(err)
+				return
+			}
+		} else {
+			iter.inodeTree.Ascend(func(i BtreeItem) bool {
+				return produceItem(i)
+			})
+		}
 		if checkClose() {
 			return
 		}
@@ -225,6 +254,27 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 		if checkClose() {
 			return
 		}
+		// process byte-range locks
+		iter.lockTree.Ascend(func(i BtreeItem) bool {
+			return produceItem(i)
+		})
+		if checkClose() {
+			return
+		}
+		// process the snapshots of the volume, each followed by its inodes and dentries
+		for _, s := range iter.volSnapshots {
+			if !produceItem(&volSnapshotItem{snapshot: s}) {
+				return
+			}
+			var produceSnapshotItem = func(i BtreeItem) bool {
+				return produceItem(&volSnapshotItem{snapshot: s, item: i})
+			}
+			s.inodeTree.Ascend(produceSnapshotItem)
+			s.dentryTree.Ascend(produceSnapshotItem)
+			if checkClose() {
+				return
+			}
+		}
 		// process extent del files
 		var err error
 		var raw []byte
@@ -306,6 +356,20 @@ func (si *MetaItemIterator) Next() (data []byte, err error) {
 			return
 		}
 		snap = NewMetaItem(opFSMCreateMultipart, nil, raw)
+	case *InodeLocks:
+		var raw []byte
+		if raw, err = typedItem.Bytes(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMInodeLocks, nil, raw)
+	case *volSnapshotItem:
+		if snap, err = typedItem.metaItem(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
 	case *fileData:
 		snap = NewMetaItem(opExtentFileSnapshot, []byte(typedItem.filename), typedItem.data)
 	default:
@@ -0,0 +1,206 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/proto"
+	raftproto "github.com/tiglabs/raft/proto"
+	"github.com/tiglabs/raft/storage/wal"
+)
+
+// OfflinePartition is a read-only meta partition loaded from the snapshot on the disk
+// without raft, which is used by the offline tools when the cluster is not available.
+type OfflinePartition struct {
+	mp *metaPartition
+}
+
+// LoadOfflinePartition loads the meta partition from the given partition directory,
+// which contains the metadata file and the snapshot directory.
+func LoadOfflinePartition(rootDir string) (p *OfflinePartition, err error) {
+	conf := &MetaPartitionConfig{RootDir: rootDir}
+	mp := NewMetaPartition(conf, nil).(*metaPartition)
+	if err = mp.loadMetadata(); err != nil {
+		return
+	}
+	if mp.config.StoreMode == proto.StoreModeRocksDB {
+		err = fmt.Errorf("partition %v in the rocksdb store mode is not supported", mp.config.PartitionId)
+		return
+	}
+	snapshotPath := path.Join(rootDir, snapshotDir)
+	if err = mp.loadInode(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadDentry(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadExtend(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadMultipart(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadApplyID(snapshotPath); err != nil {
+		return
+	}
+	return &OfflinePartition{mp: mp}, nil
+}
+
+// LoadOfflinePartitions loads all the meta partitions under the given metadata directory
+// of a metanode.
+func LoadOfflinePartitions(metadataDir string) (partitions []*OfflinePartition, err error) {
+	fileInfos, err := ioutil.ReadDir(metadataDir)
+	if err != nil {
+		return
+	}
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() || !strings.HasPrefix(fileInfo.Name(), partitionPrefix) {
+			continue
+		}
+		var p *OfflinePartition
+		if p, err = LoadOfflinePartition(path.Join(metadataDir, fileInfo.Name())); err != nil {
+			return
+		}
+		partitions = append(partitions, p)
+	}
+	return
+}
+
+// Config returns the config of the partition.
+func (p *OfflinePartition) Config() *MetaPartitionConfig {
+	return p.mp.config
+}
+
+// ApplyID returns the raft apply ID of the snapshot.
+func (p *OfflinePartition) ApplyID() uint64 {
+	return p.mp.applyID
+}
+
+// GetInode returns the inode of the given ID, or nil if the inode does not exist.
+func (p *OfflinePartition) GetInode(ino uint64) *Inode {
+	item := p.mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return nil
+	}
+	return item.(*Inode)
+}
+
+// RangeInodes calls f on the inodes in ascending order until f returns false.
+func (p *OfflinePartition) RangeInodes(f func(ino *Inode) bool) {
+	p.mp.inodeTree.Ascend(func(i BtreeItem) bool {
+		return f(i.(*Inode))
+	})
+}
+
+// RangeDentries calls f on the dentries in ascending order until f returns false.
+func (p *OfflinePartition) RangeDentries(f func(d *Dentry) bool) {
+	p.mp.dentryTree.Ascend(func(i BtreeItem) bool {
+		return f(i.(*Dentry))
+	})
+}
+
+// ReadDir returns the dentries under the given parent.
+func (p *OfflinePartition) ReadDir(parentID uint64) (children []*Dentry) {
+	begin := &Dentry{ParentId: parentID}
+	end := &Dentry{ParentId: parentID + 1}
+	p.mp.dentryTree.AscendRange(begin, end, func(i BtreeItem) bool {
+		children = append(children, i.(*Dentry))
+		return true
+	})
+	return
+}
+
+// GetExtend returns the extended attributes of the given inode, or nil if there is none.
+func (p *OfflinePartition) GetExtend(ino uint64) *Extend {
+	item := p.mp.extendTree.Get(NewExtend(ino))
+	if item == nil {
+		return nil
+	}
+	return item.(*Extend)
+}
+
+// RangeExtends calls f on the extended attributes in ascending order until f returns false.
+func (p *OfflinePartition) RangeExtends(f func(e *Extend) bool) {
+	p.mp.extendTree.Ascend(func(i BtreeItem) bool {
+		return f(i.(*Extend))
+	})
+}
+
+// RangeMultiparts calls f on the multipart sessions in ascending order until f returns false.
+func (p *OfflinePartition) RangeMultiparts(f func(m *Multipart) bool) {
+	p.mp.multipartTree.Ascend(func(i BtreeItem) bool {
+		return f(i.(*Multipart))
+	})
+}
+
+// ReplayWAL applies the committed raft log entries after the snapshot from the given WAL
+// directory, and returns the index of the last applied entry. The ops which write the files
+// of the partition, e.g. storing the snapshot or deleting the extent files, are skipped.
+func (p *OfflinePartition) ReplayWAL(walDir string) (applied uint64, err error) {
+	if _, err = os.Stat(walDir); err != nil {
+		return
+	}
+	ws, err := wal.NewStorage(walDir, &wal.Config{})
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	hs, err := ws.InitialState()
+	if err != nil {
+		return
+	}
+	applied = p.mp.applyID
+	for applied < hs.Commit {
+		entries, isCompact, e := ws.Entries(applied+1, hs.Commit+1, math.MaxUint32)
+		if e != nil {
+			return applied, e
+		}
+		if isCompact {
+			return applied, fmt.Errorf("log entries after apply id(%v) are truncated", applied)
+		}
+		if len(entries) == 0 {
+			return
+		}
+		for _, entry := range entries {
+			if entry.Type == raftproto.EntryNormal && len(entry.Data) > 0 {
+				if err = p.applyOffline(entry.Data, entry.Index); err != nil {
+					return
+				}
+			}
+			applied = entry.Index
+		}
+	}
+	return
+}
+
+func (p *OfflinePartition) applyOffline(command []byte, index uint64) (err error) {
+	msg := &MetaItem{}
+	if err = msg.UnmarshalJson(command); err != nil {
+		return
+	}
+	switch msg.Op {
+	case opFSMStoreTick, opFSMUpdatePartition, opFSMInternalDelExtentFile, opFSMInternalDelExtentCursor:
+		p.mp.uploadApplyID(index)
+		return
+	}
+	_, err = p.mp.Apply(command, index)
+	return
+}
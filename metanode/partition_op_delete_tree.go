@@ -0,0 +1,70 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// DeleteTree detaches the directory, named after the time of the detaching, and wakes up the
+// worker deleting its subtree. The subtree is deleted without moving into the trash.
+func (mp *metaPartition) DeleteTree(req *proto.DeleteTreeRequest, p *Packet) (err error) {
+	if proto.IsTrashName(req.Name) || proto.IsDeleteTreeName(req.Name) {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	mark := &Dentry{
+		ParentId: req.ParentID,
+		Name:     proto.DeleteTreeName(req.Name, time.Now().UnixNano()),
+	}
+	val, err := mark.Marshal()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.putWithTrace(p, opFSMMarkDeleteTree, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	msg := r.(*DentryResponse)
+	if msg.Status != proto.OpOk {
+		p.PacketErrorWithBody(msg.Status, nil)
+		return
+	}
+	mp.deleteTrees.wake()
+	reply, err := json.Marshal(&proto.DeleteTreeResponse{Inode: msg.Msg.Inode})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// ListDeleteTrees lists the jobs of the partition with their progress.
+func (mp *metaPartition) ListDeleteTrees(req *proto.ListDeleteTreesRequest, p *Packet) (err error) {
+	resp := &proto.ListDeleteTreesResponse{Jobs: mp.deleteTrees.progress(mp.deleteTreeDentries())}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
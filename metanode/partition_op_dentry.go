@@ -38,7 +38,7 @@ func (mp *metaPartition) CreateDentry(req *CreateDentryReq, p *Packet) (err erro
 	if err != nil {
 		return
 	}
-	resp, err := mp.Put(opFSMCreateDentry, val)
+	resp, err := mp.putWithTrace(p, opFSMCreateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -58,7 +58,7 @@ func (mp *metaPartition) DeleteDentry(req *DeleteDentryReq, p *Packet) (err erro
 		p.ResultCode = proto.OpErr
 		return
 	}
-	r, err := mp.Put(opFSMDeleteDentry, val)
+	r, err := mp.putWithTrace(p, opFSMDeleteDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -95,7 +95,7 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet) (err erro
 		p.ResultCode = proto.OpErr
 		return
 	}
-	resp, err := mp.Put(opFSMUpdateDentry, val)
+	resp, err := mp.putWithTrace(p, opFSMUpdateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -115,6 +115,10 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet) (err erro
 
 // ReadDir reads the directory based on the given request.
 func (mp *metaPartition) ReadDir(req *ReadDirReq, p *Packet) (err error) {
+	if mp = mp.volSnapshotView(req.SnapshotID); mp == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
 	resp := mp.readDir(req)
 	reply, err := json.Marshal(resp)
 	if err != nil {
@@ -127,6 +131,10 @@ func (mp *metaPartition) ReadDir(req *ReadDirReq, p *Packet) (err error) {
 
 // Lookup looks up the given dentry from the request.
 func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
+	if mp = mp.volSnapshotView(req.SnapshotID); mp == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
 	dentry := &Dentry{
 		ParentId: req.ParentID,
 		Name:     req.Name,
@@ -147,6 +155,30 @@ func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
 	return
 }
 
+// ReverseLookup returns the dentries of the partition referring to the inode.
+func (mp *metaPartition) ReverseLookup(req *proto.ReverseLookupRequest, p *Packet) (err error) {
+	resp := &proto.ReverseLookupResponse{Links: mp.reverseLookup(req.Inode)}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// ListDentry lists a page of the dentries of the directory with the prefix, the delimiter and the marker.
+func (mp *metaPartition) ListDentry(req *proto.ListDentryRequest, p *Packet) (err error) {
+	resp := mp.listDentry(req)
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
 // GetDentryTree returns the dentry tree stored in the meta partition.
 func (mp *metaPartition) GetDentryTree() *BTree {
 	return mp.dentryTree.GetTree()
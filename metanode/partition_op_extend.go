@@ -30,6 +30,25 @@ func (mp *metaPartition) SetXAttr(req *proto.SetXAttrRequest, p *Packet) (err er
 	return
 }
 
+// BatchSetXAttr sets the xattrs of the inode in one raft log entry, which are merged with the
+// existing ones like a single xattr.
+func (mp *metaPartition) BatchSetXAttr(req *proto.BatchSetXAttrRequest, p *Packet) (err error) {
+	if len(req.Attrs) == 0 {
+		p.PacketOkReply()
+		return
+	}
+	var extend = NewExtend(req.Inode)
+	for key, value := range req.Attrs {
+		extend.Put([]byte(key), []byte(value))
+	}
+	if _, err = mp.putExtend(opFSMSetXAttr, extend); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkReply()
+	return
+}
+
 func (mp *metaPartition) GetXAttr(req *proto.GetXAttrRequest, p *Packet) (err error) {
 	var response = &proto.GetXAttrResponse{
 		VolName:     req.VolName,
@@ -129,3 +148,74 @@ func (mp *metaPartition) putExtend(op uint32, extend *Extend) (resp interface{},
 	resp, err = mp.Put(op, marshaled)
 	return
 }
+
+// SetACL sets or removes the access or default ACL of the inode, the default ACL is of the
+// directories only.
+func (mp *metaPartition) SetACL(req *proto.SetACLRequest, p *Packet) (err error) {
+	if len(req.ACL) > 0 {
+		if err = req.ACL.Validate(); err != nil {
+			p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+			return
+		}
+	}
+	if req.Default && len(req.ACL) > 0 {
+		item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+		if item == nil {
+			p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+			return
+		}
+		if !proto.IsDir(item.(*Inode).Type) {
+			p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte("default acl of non-directory"))
+			return
+		}
+	}
+	var val []byte
+	if val, err = json.Marshal(req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.putWithTrace(p, opFSMSetACL, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
+// GetACL returns the access and default ACLs of the inode with the inode info, so that the
+// permissions are checked with the owner and the mode at once.
+func (mp *metaPartition) GetACL(req *proto.GetACLRequest, p *Packet) (err error) {
+	retMsg := mp.getInode(NewInode(req.Inode, 0))
+	if retMsg.Status != proto.OpOk {
+		p.PacketErrorWithBody(retMsg.Status, nil)
+		return
+	}
+	var response = &proto.GetACLResponse{Info: &proto.InodeInfo{}}
+	if !replyInfo(response.Info, retMsg.Msg) {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	if treeItem := mp.extendTree.Get(NewExtend(req.Inode)); treeItem != nil {
+		extend := treeItem.(*Extend)
+		if raw, exist := extend.Get([]byte(proto.XAttrPosixACLAccess)); exist {
+			if response.Access, err = proto.DecodeACL(raw); err != nil {
+				p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+				return
+			}
+		}
+		if raw, exist := extend.Get([]byte(proto.XAttrPosixACLDefault)); exist {
+			if response.Default, err = proto.DecodeACL(raw); err != nil {
+				p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+				return
+			}
+		}
+	}
+	var encoded []byte
+	if encoded, err = json.Marshal(response); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(encoded)
+	return
+}
@@ -32,7 +32,7 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 		p.PacketErrorWithBody(proto.OpErr, nil)
 		return
 	}
-	resp, err := mp.Put(opFSMExtentsAdd, val)
+	resp, err := mp.putWithTrace(p, opFSMExtentsAdd, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -43,6 +43,10 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 
 // ExtentsList returns the list of extents.
 func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error) {
+	if mp = mp.volSnapshotView(req.SnapshotID); mp == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	retMsg := mp.getInode(ino)
 	ino = retMsg.Msg
@@ -80,7 +84,7 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq,
 		p.PacketErrorWithBody(proto.OpErr, nil)
 		return
 	}
-	resp, err := mp.Put(opFSMExtentTruncate, val)
+	resp, err := mp.putWithTrace(p, opFSMExtentTruncate, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -92,6 +96,11 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq,
 
 func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
+	op := opFSMExtentsAdd
+	if req.Generation != 0 {
+		ino.Generation = req.Generation
+		op = opFSMExtentsAddIfGeneration
+	}
 	extents := req.Extents
 	for _, extent := range extents {
 		ino.Extents.Append(&proto.ExtentKey{
@@ -108,7 +117,7 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 		p.PacketErrorWithBody(proto.OpErr, nil)
 		return
 	}
-	resp, err := mp.Put(opFSMExtentsAdd, val)
+	resp, err := mp.putWithTrace(p, op, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -116,3 +125,49 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 	p.PacketErrorWithBody(resp.(uint8), nil)
 	return
 }
+
+// CloneExtents creates an inode with the extent keys copied from another file, which is linked to
+// a dentry by the client afterwards as the inode created by CreateInode.
+func (mp *metaPartition) CloneExtents(req *proto.CloneExtentsRequest, p *Packet) (err error) {
+	inoID, err := mp.nextInodeID()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
+		return
+	}
+	ino := NewInode(inoID, req.Mode)
+	ino.Uid = req.Uid
+	ino.Gid = req.Gid
+	for i := range req.Extents {
+		ino.Extents.Append(&req.Extents[i])
+	}
+	ino.Size = ino.Extents.Size()
+	val, err := ino.Marshal()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.putWithTrace(p, opFSMCreateInode, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	var (
+		status = proto.OpNotExistErr
+		reply  []byte
+	)
+	if resp.(uint8) == proto.OpOk {
+		resp := &proto.CloneExtentsResponse{
+			Info: &proto.InodeInfo{},
+		}
+		if replyInfo(resp.Info, ino) {
+			status = proto.OpOk
+			reply, err = json.Marshal(resp)
+			if err != nil {
+				status = proto.OpErr
+				reply = []byte(err.Error())
+			}
+		}
+	}
+	p.PacketErrorWithBody(status, reply)
+	return
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
 )
 
 func replyInfo(info *proto.InodeInfo, ino *Inode) bool {
@@ -60,7 +61,7 @@ func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.Put(opFSMCreateInode, val)
+	resp, err := mp.putWithTrace(p, opFSMCreateInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -69,6 +70,13 @@ func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
 		status = proto.OpNotExistErr
 		reply  []byte
 	)
+	// the inode created is not counted in the quotas if it fails to be tagged, rather than leaked
+	if resp.(uint8) == proto.OpOk && len(req.QuotaIds) > 0 {
+		if tagErr := mp.tagQuota(inoID, req.QuotaIds); tagErr != nil {
+			log.LogWarnf("CreateInode: tag quota fail: partitionID(%v) inode(%v) quotaIDs(%v) err(%v)",
+				mp.config.PartitionId, inoID, req.QuotaIds, tagErr)
+		}
+	}
 	if resp.(uint8) == proto.OpOk {
 		resp := &CreateInoResp{
 			Info: &proto.InodeInfo{},
@@ -94,7 +102,7 @@ func (mp *metaPartition) UnlinkInode(req *UnlinkInoReq, p *Packet) (err error) {
 		p.PacketErrorWithBody(proto.OpErr, nil)
 		return
 	}
-	r, err := mp.Put(opFSMUnlinkInode, val)
+	r, err := mp.putWithTrace(p, opFSMUnlinkInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -117,6 +125,10 @@ func (mp *metaPartition) UnlinkInode(req *UnlinkInoReq, p *Packet) (err error) {
 
 // InodeGet executes the inodeGet command from the client.
 func (mp *metaPartition) InodeGet(req *InodeGetReq, p *Packet) (err error) {
+	if mp = mp.volSnapshotView(req.SnapshotID); mp == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	retMsg := mp.getInode(ino)
 	ino = retMsg.Msg
@@ -142,6 +154,10 @@ func (mp *metaPartition) InodeGet(req *InodeGetReq, p *Packet) (err error) {
 
 // InodeGetBatch executes the inodeBatchGet command from the client.
 func (mp *metaPartition) InodeGetBatch(req *InodeGetReqBatch, p *Packet) (err error) {
+	if mp = mp.volSnapshotView(req.SnapshotID); mp == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
 	resp := &proto.BatchInodeGetResponse{}
 	ino := NewInode(0, 0)
 	for _, inoId := range req.Inodes {
@@ -171,7 +187,7 @@ func (mp *metaPartition) CreateInodeLink(req *LinkInodeReq, p *Packet) (err erro
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.Put(opFSMCreateLinkInode, val)
+	resp, err := mp.putWithTrace(p, opFSMCreateLinkInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -204,7 +220,7 @@ func (mp *metaPartition) EvictInode(req *EvictInodeReq, p *Packet) (err error) {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.Put(opFSMEvictInode, val)
+	resp, err := mp.putWithTrace(p, opFSMEvictInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -216,7 +232,8 @@ func (mp *metaPartition) EvictInode(req *EvictInodeReq, p *Packet) (err error) {
 
 // SetAttr set the inode attributes.
 func (mp *metaPartition) SetAttr(reqData []byte, p *Packet) (err error) {
-	_, err = mp.Put(opFSMSetAttr, reqData)
+	// the request data is put back to the buffer pool after the reply, while raft keeps the entry
+	_, err = mp.putWithTrace(p, opFSMSetAttr, append([]byte(nil), reqData...))
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -230,6 +247,20 @@ func (mp *metaPartition) GetInodeTree() *BTree {
 	return mp.inodeTree.GetTree()
 }
 
+// RangeInodes calls fn on all the inodes in ascending order until fn returns false.
+// Unlike the inode tree, it also covers the inodes not cached in the rocksdb store mode.
+func (mp *metaPartition) RangeInodes(fn func(ino *Inode) bool) (err error) {
+	if mp.rocksdbStore != nil {
+		snapshot := mp.rocksdbStore.RocksDBSnapshot()
+		defer mp.rocksdbStore.ReleaseSnapshot(snapshot)
+		return rangeStoredInodes(mp.rocksdbStore, snapshot, fn)
+	}
+	mp.GetInodeTree().Ascend(func(i BtreeItem) bool {
+		return fn(i.(*Inode))
+	})
+	return
+}
+
 func (mp *metaPartition) DeleteInode(req *proto.DeleteInodeRequest, p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
 	encoded, err := ino.Marshal()
@@ -237,7 +268,7 @@ func (mp *metaPartition) DeleteInode(req *proto.DeleteInodeRequest, p *Packet) (
 		p.ResultCode = proto.OpErr
 		return
 	}
-	_, err = mp.Put(opFSMInternalDeleteInode, encoded)
+	_, err = mp.putWithTrace(p, opFSMInternalDeleteInode, encoded)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -0,0 +1,94 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// SetLock acquires or releases the byte-range lock of the inode with the lease from now on. The
+// conflicts are checked before the proposal as well, so that the clients waiting for the locks
+// do not flood the raft log.
+func (mp *metaPartition) SetLock(req *proto.SetLockRequest, p *Packet) (err error) {
+	lock := req.Lock
+	if lock.Start > lock.End || lock.Type > proto.LockUnlock {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	now := time.Now()
+	if lock.Type != proto.LockUnlock && mp.lockConflict(req.Inode, &lock, now.UnixNano()) != nil {
+		p.PacketErrorWithBody(proto.OpExistErr, nil)
+		return
+	}
+	lock.Expire = now.Add(proto.LockLease).UnixNano()
+	val, err := json.Marshal(&lockOp{Inode: req.Inode, Lock: &lock, Now: now.UnixNano()})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.putWithTrace(p, opFSMSetLock, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
+// GetLock returns a lock of another owner conflicting with the lock, as F_GETLK does.
+func (mp *metaPartition) GetLock(req *proto.GetLockRequest, p *Packet) (err error) {
+	resp := &proto.GetLockResponse{Lock: mp.lockConflict(req.Inode, &req.Lock, time.Now().UnixNano())}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// RenewLocks renews the leases of the locks of the client in the partition.
+func (mp *metaPartition) RenewLocks(req *proto.RenewLocksRequest, p *Packet) (err error) {
+	now := time.Now()
+	op := &lockOp{Client: req.Client, Now: now.UnixNano(), Expire: now.Add(proto.LockLease).UnixNano()}
+	val, err := json.Marshal(op)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.putWithTrace(p, opFSMRenewLocks, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	reply, err := json.Marshal(&proto.RenewLocksResponse{Count: resp.(int)})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (mp *metaPartition) lockConflict(ino uint64, lock *proto.RangeLock, now int64) *proto.RangeLock {
+	item := mp.lockTree.Get(NewInodeLocks(ino))
+	if item == nil {
+		return nil
+	}
+	return item.(*InodeLocks).conflict(lock, now)
+}
@@ -31,15 +31,21 @@ func (mp *metaPartition) GetMultipart(req *proto.GetMultipartRequest, p *Packet)
 		return
 	}
 	multipart := item.(*Multipart)
+	parts, isTruncated := Parts(multipart.Parts()).ListAfter(req.PartNumberMarker, req.MaxParts)
 	resp := &proto.GetMultipartResponse{
 		Info: &proto.MultipartInfo{
 			ID:       multipart.id,
 			Path:     multipart.key,
 			InitTime: multipart.initTime,
-			Parts:    make([]*proto.MultipartPartInfo, 0, len(multipart.parts)),
+			Parts:    make([]*proto.MultipartPartInfo, 0, len(parts)),
+			Extend:   multipart.extend,
 		},
+		IsTruncated: isTruncated,
 	}
-	for _, part := range multipart.Parts() {
+	if isTruncated {
+		resp.NextPartNumberMarker = parts[len(parts)-1].ID
+	}
+	for _, part := range parts {
 		resp.Info.Parts = append(resp.Info.Parts, &proto.MultipartPartInfo{
 			ID:         part.ID,
 			Inode:      part.Inode,
@@ -57,6 +63,70 @@ func (mp *metaPartition) GetMultipart(req *proto.GetMultipartRequest, p *Packet)
 	return
 }
 
+// CompleteMultipart completes the multipart upload by the parts requested in order, and replies the
+// reason as the body if the parts are refused.
+func (mp *metaPartition) CompleteMultipart(req *proto.CompleteMultipartRequest, p *Packet) (err error) {
+	item := mp.multipartTree.Get(&Multipart{id: req.MultipartId})
+	if item == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	// refuse the invalid parts before the inode is allocated, they are validated again on apply
+	if _, err = item.(*Multipart).CompletedParts(req.Parts); err != nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	inoID, err := mp.nextInodeID()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
+		return
+	}
+	completion := &MultipartCompletion{
+		MultipartID: req.MultipartId,
+		ParentID:    req.ParentId,
+		Name:        req.Name,
+		Inode:       inoID,
+		Mode:        req.Mode,
+		Timestamp:   Now.GetCurrentTime().Unix(),
+		Parts:       req.Parts,
+		Extents:     req.Extents,
+		Extend:      req.Extend,
+		ETagKey:     req.ETagKey,
+	}
+	var val []byte
+	if val, err = json.Marshal(completion); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.putWithTrace(p, opFSMCompleteMultipart, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	result := resp.(*MultipartCompletionResult)
+	if result.Status != proto.OpOk {
+		var body []byte
+		if result.Err != nil {
+			body = []byte(result.Err.Error())
+		}
+		p.PacketErrorWithBody(result.Status, body)
+		return
+	}
+	reply := &proto.CompleteMultipartResponse{
+		Info:     &proto.InodeInfo{},
+		ETag:     result.ETag,
+		OldInode: result.OldInode,
+	}
+	replyInfo(reply.Info, result.Inode)
+	var encoded []byte
+	if encoded, err = json.Marshal(reply); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(encoded)
+	return
+}
+
 func (mp *metaPartition) AppendMultipart(req *proto.AddMultipartPartRequest, p *Packet) (err error) {
 	if req.Part == nil {
 		p.PacketOkReply()
@@ -117,6 +187,7 @@ func (mp *metaPartition) CreateMultipart(req *proto.CreateMultipartRequest, p *P
 		id:       nextId,
 		key:      req.Path,
 		initTime: time.Now().Local(),
+		extend:   req.Extend,
 	}
 	if _, err = mp.putMultipart(opFSMCreateMultipart, multipart); err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -0,0 +1,94 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// TrashDentry moves the dentry into the trash, named after the time of the deletion.
+func (mp *metaPartition) TrashDentry(req *proto.TrashDentryRequest, p *Packet) (err error) {
+	if proto.IsTrashName(req.Name) {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	dentry := &Dentry{
+		ParentId: req.ParentID,
+		Name:     proto.TrashName(req.Name, time.Now().UnixNano()),
+	}
+	val, err := dentry.Marshal()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.putWithTrace(p, opFSMTrashDentry, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	msg := r.(*DentryResponse)
+	if msg.Status != proto.OpOk {
+		p.PacketErrorWithBody(msg.Status, nil)
+		return
+	}
+	reply, err := json.Marshal(&proto.TrashDentryResponse{Inode: msg.Msg.Inode})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// ListTrash lists the dentries in the trash of the partition.
+func (mp *metaPartition) ListTrash(req *proto.ListTrashRequest, p *Packet) (err error) {
+	resp := &proto.ListTrashResponse{Items: mp.trashDentries()}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// RestoreTrash moves the dentry in the trash back under its parent, which fails if the name is
+// taken by another dentry.
+func (mp *metaPartition) RestoreTrash(req *proto.RestoreTrashRequest, p *Packet) (err error) {
+	name, _, ok := proto.ParseTrashName(req.TrashName)
+	if !ok || strings.Contains(req.Name, "/") {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	if req.Name == "" {
+		req.Name = name
+	}
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.putWithTrace(p, opFSMRestoreTrash, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
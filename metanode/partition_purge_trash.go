@@ -0,0 +1,96 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	PurgeTrashInterval = 10 * time.Minute
+)
+
+// startPurgeTrash starts the scanner which purges the dentries in the trash older than the trash
+// retention of the volume, and releases their inodes.
+func (mp *metaPartition) startPurgeTrash() {
+	go mp.purgeTrashWorker()
+}
+
+func (mp *metaPartition) purgeTrashWorker() {
+	t := time.NewTicker(PurgeTrashInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-mp.stopC:
+			return
+		case <-t.C:
+		}
+		if _, isLeader := mp.IsLeader(); !isLeader {
+			continue
+		}
+		items := mp.trashDentries()
+		if len(items) == 0 {
+			continue
+		}
+		view, err := masterClient.AdminAPI().GetVolumeSimpleInfo(mp.config.VolName)
+		if err != nil {
+			log.LogWarnf("[purgeTrashWorker] partitionID(%v) get volume(%v) failed: %v",
+				mp.config.PartitionId, mp.config.VolName, err)
+			continue
+		}
+		// the dentries are kept if the trash is disabled later, which are still restorable
+		if view.TrashRetention == 0 {
+			continue
+		}
+		deadline := time.Now().Add(-time.Duration(view.TrashRetention) * time.Hour).UnixNano()
+		var views []*proto.MetaPartitionView
+		for _, item := range items {
+			if item.DeleteTime >= deadline {
+				continue
+			}
+			if err = mp.purgeTrash(item, &views); err != nil {
+				log.LogWarnf("[purgeTrashWorker] partitionID(%v) purge parent(%v) name(%v) inode(%v) failed: %v",
+					mp.config.PartitionId, item.ParentID, item.TrashName, item.Inode, err)
+				continue
+			}
+			log.LogInfof("[purgeTrashWorker] partitionID(%v) parent(%v) name(%v) inode(%v) purged",
+				mp.config.PartitionId, item.ParentID, item.TrashName, item.Inode)
+		}
+	}
+}
+
+// purgeTrash releases the inode before removing the trash dentry, so that the dentry is purged
+// again by the next scan if it fails. The views of the meta partitions are fetched once per scan.
+func (mp *metaPartition) purgeTrash(item *proto.TrashItem, views *[]*proto.MetaPartitionView) (err error) {
+	if err = mp.releaseInode(item.Inode, views); err != nil {
+		return
+	}
+	val, err := (&Dentry{ParentId: item.ParentID, Name: item.TrashName}).Marshal()
+	if err != nil {
+		return
+	}
+	resp, err := mp.Put(opFSMPurgeTrash, val)
+	if err != nil {
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk && status != proto.OpNotExistErr {
+		err = errors.NewErrorf("purge trash status(%v)", status)
+	}
+	return
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// quotaUsageInterval is the interval to recount the usage of the quotas reported in the heartbeats.
+const quotaUsageInterval = time.Minute
+
+// quotaUsageCache is the usage of the quotas counted by the leader of the partition.
+type quotaUsageCache struct {
+	sync.Mutex
+	usages    []*proto.QuotaUsage
+	countTime time.Time
+}
+
+// QuotaUsages returns the usage of the quotas of the inodes tagged in the partition, which is
+// recounted at most once a quota usage interval.
+func (mp *metaPartition) QuotaUsages() []*proto.QuotaUsage {
+	mp.quotaUsage.Lock()
+	defer mp.quotaUsage.Unlock()
+	if time.Since(mp.quotaUsage.countTime) >= quotaUsageInterval {
+		mp.quotaUsage.usages = mp.countQuotaUsages()
+		mp.quotaUsage.countTime = time.Now()
+	}
+	return mp.quotaUsage.usages
+}
+
+// countQuotaUsages counts the inodes tagged with the quota IDs and the bytes of them. The stale
+// IDs of the deleted quotas are counted as well, which are ignored by the master.
+func (mp *metaPartition) countQuotaUsages() (usages []*proto.QuotaUsage) {
+	var tagged = make(map[uint64][]uint32)
+	mp.extendTree.Ascend(func(i BtreeItem) bool {
+		extend := i.(*Extend)
+		if value, exist := extend.Get([]byte(proto.QuotaXAttrKey)); exist {
+			ids, err := proto.ParseQuotaIDs(string(value))
+			if err != nil {
+				log.LogWarnf("countQuotaUsages: partitionID(%v) inode(%v) err(%v)",
+					mp.config.PartitionId, extend.GetInode(), err)
+				return true
+			}
+			tagged[extend.GetInode()] = ids
+		}
+		return true
+	})
+	if len(tagged) == 0 {
+		return nil
+	}
+	var sums = make(map[uint32]*proto.QuotaUsage)
+	for inode, ids := range tagged {
+		item := mp.inodeTree.Get(NewInode(inode, 0))
+		if item == nil || item.(*Inode).ShouldDelete() {
+			continue
+		}
+		ino := item.(*Inode)
+		ino.RLock()
+		size := ino.Size
+		ino.RUnlock()
+		for _, id := range ids {
+			sum, ok := sums[id]
+			if !ok {
+				sum = &proto.QuotaUsage{QuotaID: id}
+				sums[id] = sum
+			}
+			sum.UsedFiles++
+			sum.UsedBytes += size
+		}
+	}
+	// the inodes loaded by the counting are not kept in the rocksdb store mode
+	if mp.rocksdbStore != nil {
+		mp.inodeTree.Evict(mp.inodeCacheCount())
+	}
+	usages = make([]*proto.QuotaUsage, 0, len(sums))
+	for _, sum := range sums {
+		usages = append(usages, sum)
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].QuotaID < usages[j].QuotaID })
+	return
+}
+
+// tagQuota tags the inode created with the quota IDs inherited from the parent directory.
+func (mp *metaPartition) tagQuota(inode uint64, ids []uint32) (err error) {
+	var extend = NewExtend(inode)
+	extend.Put([]byte(proto.QuotaXAttrKey), []byte(proto.FormatQuotaIDs(ids)))
+	_, err = mp.putExtend(opFSMSetXAttr, extend)
+	return
+}
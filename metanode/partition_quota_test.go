@@ -0,0 +1,53 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func TestMetaPartition_CountQuotaUsages(t *testing.T) {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		inodeTree:  NewBtree(),
+		extendTree: NewBtree(),
+	}
+	var tag = func(ino, size uint64, value string) {
+		inode := NewInode(ino, 0644)
+		inode.Size = size
+		mp.inodeTree.ReplaceOrInsert(inode, false)
+		extend := NewExtend(ino)
+		extend.Put([]byte(proto.QuotaXAttrKey), []byte(value))
+		mp.extendTree.ReplaceOrInsert(extend, false)
+	}
+	tag(1, 0, "1")
+	tag(2, 100, "1,2")
+	tag(3, 50, "2")
+	tag(4, 1000, "bad")
+	tag(5, 10, "1")
+	mp.inodeTree.Get(NewInode(5, 0)).(*Inode).SetDeleteMark()
+
+	usages := mp.countQuotaUsages()
+	expected := []*proto.QuotaUsage{
+		{QuotaID: 1, UsedFiles: 2, UsedBytes: 100},
+		{QuotaID: 2, UsedFiles: 2, UsedBytes: 150},
+	}
+	if !reflect.DeepEqual(usages, expected) {
+		t.Fatalf("quota usages mismatch: %v", usages)
+	}
+}
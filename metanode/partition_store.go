@@ -42,6 +42,7 @@ const (
 	dentryFile      = "dentry"
 	extendFile      = "extend"
 	multipartFile   = "multipart"
+	lockFile        = "lock"
 	applyIDFile     = "apply"
 	SnapshotSign    = ".sign"
 	metadataFile    = "meta"
@@ -77,6 +78,7 @@ func (mp *metaPartition) loadMetadata() (err error) {
 	mp.config.Start = mConf.Start
 	mp.config.End = mConf.End
 	mp.config.Peers = mConf.Peers
+	mp.config.StoreMode = mConf.StoreMode
 	mp.config.Cursor = mp.config.Start
 
 	log.LogInfof("loadMetadata: load complete: partitionID(%v) volume(%v) range(%v,%v) cursor(%v)",
@@ -604,3 +606,43 @@ func (mp *metaPartition) storeMultipart(rootDir string, sm *storeMsg) (crc uint3
 		mp.config.PartitionId, mp.config.VolName, multipartTree.Len(), crc)
 	return
 }
+
+// storeLocks dumps the byte-range locks as a JSON array, which are few.
+func (mp *metaPartition) storeLocks(rootDir string, sm *storeMsg) (err error) {
+	var locks = make([]*InodeLocks, 0, sm.lockTree.Len())
+	sm.lockTree.Ascend(func(i BtreeItem) bool {
+		locks = append(locks, i.(*InodeLocks))
+		return true
+	})
+	data, err := json.Marshal(locks)
+	if err != nil {
+		return
+	}
+	if err = ioutil.WriteFile(path.Join(rootDir, lockFile), data, 0644); err != nil {
+		return
+	}
+	log.LogInfof("storeLocks: store complete: partitionID(%v) volume(%v) numInodes(%v)",
+		mp.config.PartitionId, mp.config.VolName, len(locks))
+	return
+}
+
+func (mp *metaPartition) loadLocks(rootDir string) (err error) {
+	data, err := ioutil.ReadFile(path.Join(rootDir, lockFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var locks []*InodeLocks
+	if err = json.Unmarshal(data, &locks); err != nil {
+		err = errors.NewErrorf("[loadLocks] Unmarshal: %s", err.Error())
+		return
+	}
+	for _, l := range locks {
+		mp.lockTree.ReplaceOrInsert(l, true)
+	}
+	log.LogInfof("loadLocks: load complete: partitionID(%v) volume(%v) numInodes(%v)",
+		mp.config.PartitionId, mp.config.VolName, len(locks))
+	return
+}
@@ -0,0 +1,382 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/chubaofs/chubaofs/raftstore"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/tecbot/gorocksdb"
+)
+
+// In the rocksdb store mode, every applied raft log writes the items it changed through to the RocksDB
+// of the partition together with the apply index, so the RocksDB is always the complete metadata.
+// Only the inode tree is backed by the RocksDB, which caches a part of the inodes and loads the others
+// on demand. The dentries, the extends and the multiparts are all kept in memory as before, since the
+// dentry tree is read by the ranges of the directories, which a loader of single keys cannot serve.
+// The snapshot of the store ticket is a checkpoint of the RocksDB instead of the dumped trees.
+
+const (
+	rocksdbDir = "rocksdb"
+
+	storeLRUCacheSize    = 256 * MB
+	storeWriteBufferSize = 64 * MB
+	storeBatchSize       = 1024 // the number of keys written in one batch when the store is rebuilt
+)
+
+// Keys of the items in the RocksDB.
+const (
+	storeInodePrefix     = "i/"
+	storeDentryPrefix    = "d/"
+	storeExtendPrefix    = "x/"
+	storeMultipartPrefix = "m/"
+	storeLockPrefix      = "l/"
+	storeApplyIDKey      = "applyID"
+	storeCursorKey       = "cursor"
+)
+
+func storeKey(item BtreeItem) string {
+	switch typedItem := item.(type) {
+	case *Inode:
+		return storeInodePrefix + string(typedItem.MarshalKey())
+	case *Dentry:
+		return storeDentryPrefix + string(typedItem.MarshalKey())
+	case *Extend:
+		var key = make([]byte, 8)
+		binary.BigEndian.PutUint64(key, typedItem.inode)
+		return storeExtendPrefix + string(key)
+	case *Multipart:
+		return storeMultipartPrefix + typedItem.id
+	case *InodeLocks:
+		return storeLockPrefix + string(uint64Bytes(typedItem.Inode))
+	default:
+		panic(fmt.Sprintf("unknown item type: %T", item))
+	}
+}
+
+func storeValue(item BtreeItem) ([]byte, error) {
+	switch typedItem := item.(type) {
+	case *Inode:
+		return typedItem.Marshal()
+	case *Dentry:
+		return typedItem.Marshal()
+	case *Extend:
+		return typedItem.Bytes()
+	case *Multipart:
+		return typedItem.Bytes()
+	case *InodeLocks:
+		return typedItem.Bytes()
+	default:
+		panic(fmt.Sprintf("unknown item type: %T", item))
+	}
+}
+
+func uint64Bytes(value uint64) []byte {
+	var raw = make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, value)
+	return raw
+}
+
+func (mp *metaPartition) treeOf(item BtreeItem) *BTree {
+	switch item.(type) {
+	case *Inode:
+		return mp.inodeTree
+	case *Dentry:
+		return mp.dentryTree
+	case *Extend:
+		return mp.extendTree
+	case *Multipart:
+		return mp.multipartTree
+	case *InodeLocks:
+		return mp.lockTree
+	default:
+		panic(fmt.Sprintf("unknown item type: %T", item))
+	}
+}
+
+func (mp *metaPartition) inodeCacheCount() int {
+	if mp.manager == nil || mp.manager.inodeCacheCount <= 0 {
+		return defaultInodeCacheCount
+	}
+	return mp.manager.inodeCacheCount
+}
+
+// loadFromStore opens the RocksDB of the partition and loads the metadata except the inodes from it.
+func (mp *metaPartition) loadFromStore() (err error) {
+	var (
+		storeDir      = path.Join(mp.config.RootDir, rocksdbDir)
+		checkpointDir = path.Join(mp.config.RootDir, snapshotDir, rocksdbDir)
+	)
+	if _, err = os.Stat(storeDir); os.IsNotExist(err) {
+		// recover from the last checkpoint if the store has been lost
+		if _, err = os.Stat(checkpointDir); err == nil {
+			if err = os.Rename(checkpointDir, storeDir); err != nil {
+				return
+			}
+			log.LogWarnf("loadFromStore: recover store from checkpoint: partitionID(%v) volume(%v)",
+				mp.config.PartitionId, mp.config.VolName)
+		}
+	}
+	if mp.rocksdbStore, err = raftstore.NewRocksDBStore(storeDir, storeLRUCacheSize, storeWriteBufferSize); err != nil {
+		err = errors.NewErrorf("[loadFromStore] open store: %s", err.Error())
+		return
+	}
+	var value interface{}
+	if value, err = mp.rocksdbStore.Get(storeApplyIDKey); err != nil {
+		return
+	}
+	if raw := value.([]byte); len(raw) == 8 {
+		mp.applyID = binary.BigEndian.Uint64(raw)
+	}
+	if value, err = mp.rocksdbStore.Get(storeCursorKey); err != nil {
+		return
+	}
+	if raw := value.([]byte); len(raw) == 8 && binary.BigEndian.Uint64(raw) > mp.config.Cursor {
+		mp.config.Cursor = binary.BigEndian.Uint64(raw)
+	}
+
+	var (
+		numInodes   uint64
+		numDentries int
+		snapshot    = mp.rocksdbStore.RocksDBSnapshot()
+	)
+	defer mp.rocksdbStore.ReleaseSnapshot(snapshot)
+	// the inodes are loaded on demand, only the ones to be freed are collected
+	if err = rangeStoredInodes(mp.rocksdbStore, snapshot, func(ino *Inode) bool {
+		mp.checkAndInsertFreeList(ino)
+		numInodes++
+		return true
+	}); err != nil {
+		err = errors.NewErrorf("[loadFromStore] load inodes: %s", err.Error())
+		return
+	}
+	if err = rangeStore(mp.rocksdbStore, snapshot, storeDentryPrefix, func(value []byte) error {
+		dentry := &Dentry{}
+		if err := dentry.Unmarshal(value); err != nil {
+			return err
+		}
+		mp.dentryTree.ReplaceOrInsert(dentry, true)
+		numDentries++
+		return nil
+	}); err != nil {
+		err = errors.NewErrorf("[loadFromStore] load dentries: %s", err.Error())
+		return
+	}
+	if err = rangeStore(mp.rocksdbStore, snapshot, storeExtendPrefix, func(value []byte) error {
+		extend, err := NewExtendFromBytes(value)
+		if err != nil {
+			return err
+		}
+		mp.extendTree.ReplaceOrInsert(extend, true)
+		return nil
+	}); err != nil {
+		err = errors.NewErrorf("[loadFromStore] load extends: %s", err.Error())
+		return
+	}
+	if err = rangeStore(mp.rocksdbStore, snapshot, storeMultipartPrefix, func(value []byte) error {
+		mp.multipartTree.ReplaceOrInsert(MultipartFromBytes(value), true)
+		return nil
+	}); err != nil {
+		err = errors.NewErrorf("[loadFromStore] load multiparts: %s", err.Error())
+		return
+	}
+	if err = rangeStore(mp.rocksdbStore, snapshot, storeLockPrefix, func(value []byte) error {
+		locks, err := InodeLocksFromBytes(value)
+		if err != nil {
+			return err
+		}
+		mp.lockTree.ReplaceOrInsert(locks, true)
+		return nil
+	}); err != nil {
+		err = errors.NewErrorf("[loadFromStore] load locks: %s", err.Error())
+		return
+	}
+	mp.inodeTree.SetLoader(mp.loadStoredInode)
+	log.LogInfof("loadFromStore: load complete: partitionID(%v) volume(%v) applyID(%v) cursor(%v) numInodes(%v) numDentries(%v)",
+		mp.config.PartitionId, mp.config.VolName, mp.applyID, mp.config.Cursor, numInodes, numDentries)
+	return
+}
+
+// rangeStore calls fn on the values with the given prefix in the snapshot of the store until fn fails.
+func rangeStore(store *raftstore.RocksDBStore, snapshot *gorocksdb.Snapshot, prefix string, fn func(value []byte) error) (err error) {
+	var rangeErr = store.RangeForPrefix(snapshot, []byte(prefix), func(key, value []byte) bool {
+		err = fn(value)
+		return err == nil
+	})
+	if err == nil {
+		err = rangeErr
+	}
+	return
+}
+
+// rangeStoredInodes calls fn on the inodes in the snapshot of the store in ascending order until fn returns false.
+func rangeStoredInodes(store *raftstore.RocksDBStore, snapshot *gorocksdb.Snapshot, fn func(ino *Inode) bool) (err error) {
+	var rangeErr = store.RangeForPrefix(snapshot, []byte(storeInodePrefix), func(key, value []byte) bool {
+		ino := NewInode(0, 0)
+		if err = ino.Unmarshal(value); err != nil {
+			return false
+		}
+		return fn(ino)
+	})
+	if err == nil {
+		err = rangeErr
+	}
+	return
+}
+
+// loadStoredInode is the loader of the inode tree in the rocksdb store mode.
+func (mp *metaPartition) loadStoredInode(key BtreeItem) BtreeItem {
+	value, err := mp.rocksdbStore.Get(storeKey(key))
+	if err != nil {
+		log.LogFatalf("loadStoredInode: get inode failed: partitionID(%v) inode(%v) err(%v)",
+			mp.config.PartitionId, key.(*Inode).Inode, err)
+		return nil
+	}
+	raw := value.([]byte)
+	if len(raw) == 0 {
+		return nil
+	}
+	ino := NewInode(0, 0)
+	if err = ino.Unmarshal(raw); err != nil {
+		log.LogFatalf("loadStoredInode: unmarshal inode failed: partitionID(%v) inode(%v) err(%v)",
+			mp.config.PartitionId, key.(*Inode).Inode, err)
+		return nil
+	}
+	return ino
+}
+
+// writeThrough persists the changed items of the applied raft log with the apply index.
+// The items not in the trees any more are deleted from the store.
+func (mp *metaPartition) writeThrough(index uint64, changed []BtreeItem) (err error) {
+	var (
+		puts    = make(map[string][]byte, len(changed)+2)
+		dels    []string
+		removed []BtreeItem
+	)
+	for _, key := range changed {
+		item := mp.treeOf(key).GetCached(key)
+		if item == nil {
+			dels = append(dels, storeKey(key))
+			removed = append(removed, key)
+			continue
+		}
+		if puts[storeKey(item)], err = storeValue(item); err != nil {
+			return
+		}
+	}
+	puts[storeApplyIDKey] = uint64Bytes(index)
+	puts[storeCursorKey] = uint64Bytes(mp.config.Cursor)
+	if err = mp.rocksdbStore.BatchWrite(puts, dels, false); err != nil {
+		log.LogErrorf("writeThrough: write store failed: partitionID(%v) index(%v) err(%v)",
+			mp.config.PartitionId, index, err)
+		return
+	}
+	// drop the copies loaded before the deletions are persisted
+	for _, key := range removed {
+		mp.treeOf(key).Delete(key)
+	}
+	mp.inodeTree.Evict(mp.inodeCacheCount())
+	return
+}
+
+// deleteStoredItems deletes the items from the store out of the raft log.
+func (mp *metaPartition) deleteStoredItems(items ...BtreeItem) {
+	var dels = make([]string, 0, len(items))
+	for _, item := range items {
+		dels = append(dels, storeKey(item))
+	}
+	if err := mp.rocksdbStore.BatchWrite(nil, dels, false); err != nil {
+		log.LogErrorf("deleteStoredItems: write store failed: partitionID(%v) err(%v)", mp.config.PartitionId, err)
+		return
+	}
+	// drop the copies loaded before the deletions are persisted
+	for _, item := range items {
+		mp.treeOf(item).Delete(item)
+	}
+}
+
+// resetStore replaces the content of the store with the trees and the apply index, which
+// is used after the partition applies a raft snapshot.
+func (mp *metaPartition) resetStore(index uint64, trees ...*BTree) (err error) {
+	var (
+		puts     = make(map[string][]byte, storeBatchSize)
+		dels     = make([]string, 0, storeBatchSize)
+		snapshot = mp.rocksdbStore.RocksDBSnapshot()
+	)
+	var flush = func(force bool) error {
+		if !force && len(puts)+len(dels) < storeBatchSize {
+			return nil
+		}
+		if err := mp.rocksdbStore.BatchWrite(puts, dels, false); err != nil {
+			return err
+		}
+		puts, dels = make(map[string][]byte, storeBatchSize), dels[:0]
+		return nil
+	}
+	var rangeErr = mp.rocksdbStore.RangeForPrefix(snapshot, nil, func(key, value []byte) bool {
+		dels = append(dels, string(key))
+		err = flush(false)
+		return err == nil
+	})
+	mp.rocksdbStore.ReleaseSnapshot(snapshot)
+	if err == nil {
+		err = rangeErr
+	}
+	if err != nil {
+		return
+	}
+	if err = flush(true); err != nil {
+		return
+	}
+	for _, tree := range trees {
+		tree.Ascend(func(i BtreeItem) bool {
+			if puts[storeKey(i)], err = storeValue(i); err != nil {
+				return false
+			}
+			err = flush(false)
+			return err == nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	puts[storeApplyIDKey] = uint64Bytes(index)
+	puts[storeCursorKey] = uint64Bytes(mp.config.Cursor)
+	return flush(true)
+}
+
+// internalDeleteItems returns the keys of the items removed by the internal deletion of the inodes.
+func internalDeleteItems(val []byte) (items []BtreeItem) {
+	var (
+		buf = bytes.NewBuffer(val)
+		ino uint64
+	)
+	for {
+		if err := binary.Read(buf, binary.BigEndian, &ino); err != nil {
+			if err != io.EOF {
+				log.LogWarnf("internalDeleteItems: read inode failed: %v", err)
+			}
+			return
+		}
+		items = append(items, NewInode(ino, 0), &Extend{inode: ino})
+	}
+}
@@ -30,6 +30,8 @@ type storeMsg struct {
 	dentryTree    *BTree
 	extendTree    *BTree
 	multipartTree *BTree
+	lockTree      *BTree
+	volSnapshots  []*volSnapshot
 }
 
 func (mp *metaPartition) startSchedule(curIndex uint64) {
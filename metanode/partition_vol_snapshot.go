@@ -0,0 +1,432 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The snapshots of the volume are frozen in a meta partition by the clones of the inode and the
+// dentry trees, which are never modified since the trees are copied on write. The extents referred
+// by the snapshots are not deleted when the inodes release them, but when the last snapshot holding
+// them is dropped. The snapshots are not supported in the rocksdb store mode, whose inodes are evicted.
+
+const (
+	volSnapshotDirPrefix = "volsnap_"
+	volSnapshotMetaFile  = "meta"
+)
+
+// volSnapshot is a snapshot of the volume frozen in the partition.
+type volSnapshot struct {
+	ID         uint32 `json:"id"`
+	Name       string `json:"name"`
+	CreateTime int64  `json:"createTime"`
+
+	inodeTree  *BTree
+	dentryTree *BTree
+
+	// extents is the index of the extents referred by the inodes, built once it is needed.
+	extentsOnce sync.Once
+	extents     extentIndex
+}
+
+func newVolSnapshot(snapshot *proto.VolSnapshot, inodeTree, dentryTree *BTree) *volSnapshot {
+	return &volSnapshot{
+		ID:         snapshot.ID,
+		Name:       snapshot.Name,
+		CreateTime: snapshot.CreateTime,
+		inodeTree:  inodeTree,
+		dentryTree: dentryTree,
+	}
+}
+
+func (s *volSnapshot) extentIndex() extentIndex {
+	s.extentsOnce.Do(func() {
+		s.extents = newExtentIndex(s.inodeTree)
+	})
+	return s.extents
+}
+
+type extentRef struct {
+	partitionID uint64
+	extentID    uint64
+}
+
+// extentIndex indexes the extent keys by the extents they refer.
+type extentIndex map[extentRef][]*proto.ExtentKey
+
+func newExtentIndex(inodeTree *BTree) extentIndex {
+	index := make(extentIndex)
+	inodeTree.Ascend(func(i BtreeItem) bool {
+		ino := i.(*Inode)
+		ino.DoReadFunc(func() {
+			ino.Extents.Range(func(item BtreeItem) bool {
+				ek := item.(*proto.ExtentKey)
+				ref := extentRef{partitionID: ek.PartitionId, extentID: ek.ExtentId}
+				index[ref] = append(index[ref], ek)
+				return true
+			})
+		})
+		return true
+	})
+	return index
+}
+
+// holds tells whether the data of the extent key is referred, by the whole extent for the normal
+// extents which are deleted as a whole, and by the overlapped range for the tiny extents.
+func (index extentIndex) holds(ek *proto.ExtentKey) bool {
+	eks, ok := index[extentRef{partitionID: ek.PartitionId, extentID: ek.ExtentId}]
+	if !ok {
+		return false
+	}
+	if !storage.IsTinyExtent(ek.ExtentId) {
+		return true
+	}
+	for _, held := range eks {
+		if ek.ExtentOffset < held.ExtentOffset+uint64(held.Size) && held.ExtentOffset < ek.ExtentOffset+uint64(ek.Size) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mp *metaPartition) getVolSnapshots() []*volSnapshot {
+	mp.volSnapshotsLock.RLock()
+	defer mp.volSnapshotsLock.RUnlock()
+	return mp.volSnapshots
+}
+
+func (mp *metaPartition) getVolSnapshot(id uint32) *volSnapshot {
+	for _, s := range mp.getVolSnapshots() {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// VolSnapshotIDs returns the IDs of the snapshots frozen in the partition.
+func (mp *metaPartition) VolSnapshotIDs() (ids []uint32) {
+	for _, s := range mp.getVolSnapshots() {
+		ids = append(ids, s.ID)
+	}
+	return
+}
+
+// SyncVolSnapshots freezes the snapshots being frozen by the meta partitions and drops the ones
+// deleted, by the leader. The partitions created after a snapshot is ready have nothing to freeze
+// for it, whose reads of the snapshot find nothing.
+func (mp *metaPartition) SyncVolSnapshots(snapshots []*proto.VolSnapshot) {
+	if mp.rocksdbStore != nil {
+		return
+	}
+	if _, isLeader := mp.IsLeader(); !isLeader {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&mp.volSnapshotsSyncing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&mp.volSnapshotsSyncing, 0)
+		listed := make(map[uint32]bool, len(snapshots))
+		for _, snapshot := range snapshots {
+			listed[snapshot.ID] = true
+			if snapshot.Status != proto.VolSnapshotFreezingMeta || mp.getVolSnapshot(snapshot.ID) != nil {
+				continue
+			}
+			if err := mp.putVolSnapshot(opFSMFreezeVolSnapshot, snapshot); err != nil {
+				log.LogErrorf("[SyncVolSnapshots] partitionID(%v) freeze snapshot(%v) err(%v)",
+					mp.config.PartitionId, snapshot.Name, err)
+				return
+			}
+			log.LogInfof("[SyncVolSnapshots] partitionID(%v) snapshot(%v) frozen", mp.config.PartitionId, snapshot.Name)
+		}
+		for _, s := range mp.getVolSnapshots() {
+			if listed[s.ID] {
+				continue
+			}
+			if err := mp.putVolSnapshot(opFSMDropVolSnapshot, &proto.VolSnapshot{ID: s.ID}); err != nil {
+				log.LogErrorf("[SyncVolSnapshots] partitionID(%v) drop snapshot(%v) err(%v)",
+					mp.config.PartitionId, s.Name, err)
+				return
+			}
+			log.LogInfof("[SyncVolSnapshots] partitionID(%v) snapshot(%v) dropped", mp.config.PartitionId, s.Name)
+		}
+	}()
+}
+
+func (mp *metaPartition) putVolSnapshot(op uint32, snapshot *proto.VolSnapshot) (err error) {
+	val, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+	resp, err := mp.Put(op, val)
+	if err != nil {
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk && status != proto.OpExistErr && status != proto.OpNotExistErr {
+		err = errors.NewErrorf("status(%v)", status)
+	}
+	return
+}
+
+func (mp *metaPartition) fsmFreezeVolSnapshot(snapshot *proto.VolSnapshot) (status uint8) {
+	if mp.rocksdbStore != nil {
+		return proto.OpNotPerm
+	}
+	mp.volSnapshotsLock.Lock()
+	defer mp.volSnapshotsLock.Unlock()
+	for _, s := range mp.volSnapshots {
+		if s.ID == snapshot.ID {
+			return proto.OpExistErr
+		}
+	}
+	frozen := newVolSnapshot(snapshot, mp.inodeTree.GetTree(), mp.dentryTree.GetTree())
+	mp.volSnapshots = append(append(make([]*volSnapshot, 0, len(mp.volSnapshots)+1), mp.volSnapshots...), frozen)
+	return proto.OpOk
+}
+
+// fsmDropVolSnapshot drops the snapshot, and deletes the extents held by it only in the background.
+func (mp *metaPartition) fsmDropVolSnapshot(snapshot *proto.VolSnapshot) (status uint8) {
+	var dropped *volSnapshot
+	mp.volSnapshotsLock.Lock()
+	snapshots := make([]*volSnapshot, 0, len(mp.volSnapshots))
+	for _, s := range mp.volSnapshots {
+		if s.ID == snapshot.ID {
+			dropped = s
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	mp.volSnapshots = snapshots
+	mp.volSnapshotsLock.Unlock()
+	if dropped == nil {
+		return proto.OpNotExistErr
+	}
+	inodeTree := mp.inodeTree.GetTree()
+	go func() {
+		for _, ek := range releasableExtents(dropped, inodeTree, snapshots) {
+			mp.extDelCh <- ek
+		}
+	}()
+	return proto.OpOk
+}
+
+// releasableExtents returns the extent keys of the dropped snapshot held by neither the inodes nor
+// the other snapshots, the normal extents referred by several keys are returned once.
+func releasableExtents(dropped *volSnapshot, inodeTree *BTree, others []*volSnapshot) (eks []*proto.ExtentKey) {
+	live := newExtentIndex(inodeTree)
+	released := make(map[extentRef]bool)
+	for ref, refEks := range dropped.extentIndex() {
+		for _, ek := range refEks {
+			if released[ref] || live.holds(ek) || isHeldBySnapshots(others, ek) {
+				continue
+			}
+			eks = append(eks, ek)
+			if !storage.IsTinyExtent(ek.ExtentId) {
+				released[ref] = true
+			}
+		}
+	}
+	return
+}
+
+func isHeldBySnapshots(snapshots []*volSnapshot, ek *proto.ExtentKey) bool {
+	for _, s := range snapshots {
+		if s.extentIndex().holds(ek) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExtentHeldBySnapshots tells whether the extent released by the inodes is kept for the snapshots.
+func (mp *metaPartition) isExtentHeldBySnapshots(ek *proto.ExtentKey) bool {
+	return isHeldBySnapshots(mp.getVolSnapshots(), ek)
+}
+
+// volSnapshotView returns the read-only view of the partition for the snapshot, or the partition
+// itself if the ID is 0. It returns nil if the snapshot is not frozen in the partition.
+func (mp *metaPartition) volSnapshotView(id uint32) *metaPartition {
+	if id == 0 {
+		return mp
+	}
+	s := mp.getVolSnapshot(id)
+	if s == nil {
+		return nil
+	}
+	return &metaPartition{
+		config:        mp.config,
+		inodeTree:     s.inodeTree,
+		dentryTree:    s.dentryTree,
+		extendTree:    NewBtree(),
+		multipartTree: NewBtree(),
+	}
+}
+
+func (mp *metaPartition) setVolSnapshots(snapshots map[uint32]*volSnapshot) {
+	sorted := make([]*volSnapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	mp.volSnapshotsLock.Lock()
+	mp.volSnapshots = sorted
+	mp.volSnapshotsLock.Unlock()
+}
+
+// volSnapshotItem is an item of a snapshot in the raft snapshot, or the snapshot itself if the
+// item is nil. The keys of the inodes and the dentries are prefixed by the snapshot ID.
+type volSnapshotItem struct {
+	snapshot *volSnapshot
+	item     BtreeItem
+}
+
+func (i *volSnapshotItem) metaItem() (*MetaItem, error) {
+	var key = make([]byte, 4)
+	binary.BigEndian.PutUint32(key, i.snapshot.ID)
+	switch item := i.item.(type) {
+	case nil:
+		raw, err := json.Marshal(i.snapshot)
+		if err != nil {
+			return nil, err
+		}
+		return NewMetaItem(opVolSnapshot, nil, raw), nil
+	case *Inode:
+		return NewMetaItem(opVolSnapshotInode, append(key, item.MarshalKey()...), item.MarshalValue()), nil
+	case *Dentry:
+		return NewMetaItem(opVolSnapshotDentry, append(key, item.MarshalKey()...), item.MarshalValue()), nil
+	default:
+		return nil, errors.NewErrorf("unknown snapshot item type: %T", item)
+	}
+}
+
+// applyVolSnapshotItem adds the item of the raft snapshot to the snapshots.
+func applyVolSnapshotItem(snapshots map[uint32]*volSnapshot, item *MetaItem) (err error) {
+	if item.Op == opVolSnapshot {
+		s := &volSnapshot{inodeTree: NewBtree(), dentryTree: NewBtree()}
+		if err = json.Unmarshal(item.V, s); err != nil {
+			return
+		}
+		snapshots[s.ID] = s
+		return
+	}
+	if len(item.K) < 4 {
+		return errors.NewErrorf("invalid snapshot item key: %v", item.K)
+	}
+	s, ok := snapshots[binary.BigEndian.Uint32(item.K)]
+	if !ok {
+		return errors.NewErrorf("unknown snapshot(%v)", binary.BigEndian.Uint32(item.K))
+	}
+	if item.Op == opVolSnapshotInode {
+		ino := NewInode(0, 0)
+		if err = ino.UnmarshalKey(item.K[4:]); err != nil {
+			return
+		}
+		if err = ino.UnmarshalValue(item.V); err != nil {
+			return
+		}
+		s.inodeTree.ReplaceOrInsert(ino, true)
+		return
+	}
+	dentry := &Dentry{}
+	if err = dentry.UnmarshalKey(item.K[4:]); err != nil {
+		return
+	}
+	if err = dentry.UnmarshalValue(item.V); err != nil {
+		return
+	}
+	s.dentryTree.ReplaceOrInsert(dentry, true)
+	return
+}
+
+// storeVolSnapshots dumps the trees of each snapshot to a directory in the given one.
+func (mp *metaPartition) storeVolSnapshots(rootDir string, snapshots []*volSnapshot) (err error) {
+	for _, s := range snapshots {
+		dir := path.Join(rootDir, volSnapshotDirPrefix+strconv.FormatUint(uint64(s.ID), 10))
+		if err = os.MkdirAll(dir, 0775); err != nil {
+			return
+		}
+		sm := &storeMsg{inodeTree: s.inodeTree, dentryTree: s.dentryTree}
+		if _, err = mp.storeInode(dir, sm); err != nil {
+			return
+		}
+		if _, err = mp.storeDentry(dir, sm); err != nil {
+			return
+		}
+		var data []byte
+		if data, err = json.Marshal(s); err != nil {
+			return
+		}
+		if err = ioutil.WriteFile(path.Join(dir, volSnapshotMetaFile), data, 0644); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// loadVolSnapshots loads the snapshots dumped by storeVolSnapshots, into a partition without them.
+func (mp *metaPartition) loadVolSnapshots(rootDir string) (err error) {
+	fileInfos, err := ioutil.ReadDir(rootDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var snapshots = make(map[uint32]*volSnapshot)
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() || !strings.HasPrefix(fileInfo.Name(), volSnapshotDirPrefix) {
+			continue
+		}
+		dir := path.Join(rootDir, fileInfo.Name())
+		var data []byte
+		if data, err = ioutil.ReadFile(path.Join(dir, volSnapshotMetaFile)); err != nil {
+			return
+		}
+		s := &volSnapshot{}
+		if err = json.Unmarshal(data, s); err != nil {
+			return
+		}
+		// the inodes are loaded by a partition of the copied config, which keeps the cursor
+		config := *mp.config
+		loader := &metaPartition{config: &config, inodeTree: NewBtree(), dentryTree: NewBtree(), freeList: newFreeList()}
+		if err = loader.loadInode(dir); err != nil {
+			return
+		}
+		if err = loader.loadDentry(dir); err != nil {
+			return
+		}
+		s.inodeTree, s.dentryTree = loader.inodeTree, loader.dentryTree
+		snapshots[s.ID] = s
+		log.LogInfof("loadVolSnapshots: partitionID(%v) snapshot(%v) loaded", mp.config.PartitionId, s.Name)
+	}
+	mp.setVolSnapshots(snapshots)
+	return
+}
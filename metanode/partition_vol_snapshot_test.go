@@ -0,0 +1,164 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+)
+
+func newVolSnapshotTestPartition() *metaPartition {
+	mp := &metaPartition{
+		config:     &MetaPartitionConfig{PartitionId: 1},
+		inodeTree:  NewBtree(),
+		dentryTree: NewBtree(),
+		extDelCh:   make(chan BtreeItem, 16),
+	}
+	file := NewInode(2, proto.Mode(0644))
+	file.Extents.Append(&proto.ExtentKey{PartitionId: 1, ExtentId: 1025, Size: 100})
+	tiny := NewInode(3, proto.Mode(0644))
+	tiny.Extents.Append(&proto.ExtentKey{PartitionId: 1, ExtentId: storage.TinyExtentStartID, Size: 50})
+	mp.inodeTree.ReplaceOrInsert(file, false)
+	mp.inodeTree.ReplaceOrInsert(tiny, false)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "a", Inode: 2, Type: proto.Mode(0644)}, false)
+	return mp
+}
+
+func TestMetaPartition_FreezeVolSnapshot(t *testing.T) {
+	mp := newVolSnapshotTestPartition()
+	if status := mp.fsmFreezeVolSnapshot(&proto.VolSnapshot{ID: 1, Name: "s1"}); status != proto.OpOk {
+		t.Fatalf("freeze snapshot: status(%v)", status)
+	}
+	if status := mp.fsmFreezeVolSnapshot(&proto.VolSnapshot{ID: 1, Name: "s1"}); status != proto.OpExistErr {
+		t.Fatalf("freeze snapshot again: status(%v)", status)
+	}
+
+	// the live inodes modified in place are copied on write
+	mp.inodeTree.CopyFind(NewInode(2, 0), func(item BtreeItem) {
+		item.(*Inode).Extents.Append(&proto.ExtentKey{PartitionId: 1, ExtentId: 1026, FileOffset: 100, Size: 100})
+	})
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "b", Inode: 3}, false)
+
+	view := mp.volSnapshotView(1)
+	if view == nil {
+		t.Fatalf("snapshot view not found")
+	}
+	if eks := view.inodeTree.Get(NewInode(2, 0)).(*Inode).Extents.Len(); eks != 1 {
+		t.Fatalf("snapshot inode modified: extents(%v)", eks)
+	}
+	if view.dentryTree.Has(&Dentry{ParentId: 1, Name: "b"}) || !view.dentryTree.Has(&Dentry{ParentId: 1, Name: "a"}) {
+		t.Fatalf("snapshot dentries modified")
+	}
+	if mp.volSnapshotView(2) != nil || mp.volSnapshotView(0) != mp {
+		t.Fatalf("unexpected snapshot view")
+	}
+
+	// the normal extents are held as a whole, and the tiny extents by the ranges
+	var held = func(extentID, offset uint64) bool {
+		return mp.isExtentHeldBySnapshots(&proto.ExtentKey{PartitionId: 1, ExtentId: extentID, ExtentOffset: offset, Size: 10})
+	}
+	if !held(1025, 4096) || held(1026, 0) || !held(storage.TinyExtentStartID, 40) || held(storage.TinyExtentStartID, 50) {
+		t.Fatalf("extents held by snapshot mismatch")
+	}
+}
+
+func TestMetaPartition_DropVolSnapshot(t *testing.T) {
+	mp := newVolSnapshotTestPartition()
+	mp.fsmFreezeVolSnapshot(&proto.VolSnapshot{ID: 1})
+	mp.fsmFreezeVolSnapshot(&proto.VolSnapshot{ID: 2})
+	mp.inodeTree.Delete(NewInode(2, 0))
+	mp.inodeTree.Delete(NewInode(3, 0))
+
+	// the extents are held by the other snapshot
+	if status := mp.fsmDropVolSnapshot(&proto.VolSnapshot{ID: 1}); status != proto.OpOk {
+		t.Fatalf("drop snapshot: status(%v)", status)
+	}
+	if eks := releasableExtents(mp.getVolSnapshot(2), mp.inodeTree, nil); len(eks) != 2 {
+		t.Fatalf("releasable extents: %v", eks)
+	}
+	if status := mp.fsmDropVolSnapshot(&proto.VolSnapshot{ID: 1}); status != proto.OpNotExistErr {
+		t.Fatalf("drop snapshot again: status(%v)", status)
+	}
+	if ids := mp.VolSnapshotIDs(); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("snapshots after drop: %v", ids)
+	}
+
+	// the extents are deleted once the last snapshot holding them is dropped
+	if status := mp.fsmDropVolSnapshot(&proto.VolSnapshot{ID: 2}); status != proto.OpOk {
+		t.Fatalf("drop last snapshot: status(%v)", status)
+	}
+	var deleted = make(map[uint64]bool)
+	for len(deleted) < 2 {
+		select {
+		case item := <-mp.extDelCh:
+			deleted[item.(*proto.ExtentKey).ExtentId] = true
+		case <-time.After(time.Second):
+			t.Fatalf("extents not deleted: %v", deleted)
+		}
+	}
+	if !deleted[1025] || !deleted[storage.TinyExtentStartID] {
+		t.Fatalf("extents deleted mismatch: %v", deleted)
+	}
+}
+
+func TestMetaPartition_VolSnapshotItems(t *testing.T) {
+	mp := newVolSnapshotTestPartition()
+	mp.fsmFreezeVolSnapshot(&proto.VolSnapshot{ID: 3, Name: "s3", CreateTime: 100})
+	frozen := mp.getVolSnapshot(3)
+
+	snapshots := make(map[uint32]*volSnapshot)
+	var apply = func(item BtreeItem) {
+		mi, err := (&volSnapshotItem{snapshot: frozen, item: item}).metaItem()
+		if err != nil {
+			t.Fatalf("snapshot item: %v", err)
+		}
+		if err = applyVolSnapshotItem(snapshots, mi); err != nil {
+			t.Fatalf("apply snapshot item: %v", err)
+		}
+	}
+	apply(nil)
+	frozen.inodeTree.Ascend(func(i BtreeItem) bool { apply(i); return true })
+	frozen.dentryTree.Ascend(func(i BtreeItem) bool { apply(i); return true })
+	applied := snapshots[3]
+	if applied == nil || applied.Name != "s3" || applied.CreateTime != 100 ||
+		applied.inodeTree.Len() != 2 || applied.dentryTree.Len() != 1 {
+		t.Fatalf("applied snapshot mismatch: %v", applied)
+	}
+
+	dir, err := ioutil.TempDir("", "volsnap")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = mp.storeVolSnapshots(dir, mp.getVolSnapshots()); err != nil {
+		t.Fatalf("store snapshots: %v", err)
+	}
+	loaded := &metaPartition{config: &MetaPartitionConfig{PartitionId: 1}}
+	if err = loaded.loadVolSnapshots(dir); err != nil {
+		t.Fatalf("load snapshots: %v", err)
+	}
+	s := loaded.getVolSnapshot(3)
+	if s == nil || s.Name != "s3" || s.inodeTree.Len() != 2 || s.dentryTree.Len() != 1 {
+		t.Fatalf("loaded snapshot mismatch: %v", s)
+	}
+	if loaded.config.Cursor != 0 {
+		t.Fatalf("cursor changed by loading snapshots: %v", loaded.config.Cursor)
+	}
+}
@@ -35,12 +35,20 @@ func (m *MetaNode) startRaftServer() (err error) {
 	replicaPort, _ := strconv.Atoi(m.raftReplicatePort)
 
 	raftConf := &raftstore.Config{
-		NodeID:            m.nodeId,
-		RaftPath:          m.raftDir,
-		IPAddr:            m.localAddr,
-		HeartbeatPort:     heartbeatPort,
-		ReplicaPort:       replicaPort,
-		NumOfLogsToRetain: 2000000,
+		NodeID:                m.nodeId,
+		RaftPath:              m.raftDir,
+		IPAddr:                m.localAddr,
+		HeartbeatPort:         heartbeatPort,
+		ReplicaPort:           replicaPort,
+		NumOfLogsToRetain:     2000000,
+		PreVote:               m.raftPreVote,
+		WalCompression:        m.raftWalCompression,
+		WalPreAllocate:        m.raftWalPreAllocate,
+		WalRecycleFiles:       m.raftWalRecycleFiles,
+		SnapshotRate:          m.raftSnapshotRate,
+		SnapshotResumeTimeout: m.raftSnapshotResumeTimeout,
+		SendLinger:            m.raftSendLinger,
+		WalDir:                m.raftWalDir,
 	}
 	m.raftStore, err = raftstore.NewRaftStore(raftConf)
 	if err != nil {
@@ -17,9 +17,12 @@ package metanode
 import (
 	"io"
 	"net"
+	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/grpc"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
 )
 
 // StartTcpService binds and listens to the specified port.
@@ -60,12 +63,37 @@ func (m *MetaNode) stopServer() {
 	}
 }
 
+// startGRPCServer serves the service chubaofs.MetaNode of proto/chubaofs.proto if the port is
+// configured, whose calls are forwarded to the tcp server as packets.
+func (m *MetaNode) startGRPCServer() (err error) {
+	if m.grpcPort == "" {
+		return
+	}
+	gateway := proto.NewGRPCGateway("127.0.0.1:" + m.listen)
+	m.grpcServer = grpc.NewServer()
+	m.grpcServer.Handle(proto.GRPCMetaNodeService, "Call", grpc.Unary(gateway.Call))
+	return m.grpcServer.Start(m.grpcPort)
+}
+
+func (m *MetaNode) stopGRPCServer() {
+	if m.grpcServer != nil {
+		m.grpcServer.Stop()
+	}
+}
+
 // Read data from the specified tcp connection until the connection is closed by the remote or the tcp service is down.
 func (m *MetaNode) serveConn(conn net.Conn, stopC chan uint8) {
 	defer conn.Close()
 	c := conn.(*net.TCPConn)
 	c.SetKeepAlive(true)
 	c.SetNoDelay(true)
+	conn, err := mtls.Server(c)
+	if err != nil {
+		if err != io.EOF {
+			log.LogWarnf("serve MetaNode: tls handshake with %v failed: %v", c.RemoteAddr(), err)
+		}
+		return
+	}
 	remoteAddr := conn.RemoteAddr().String()
 	for {
 		select {
@@ -80,6 +108,7 @@ func (m *MetaNode) serveConn(conn net.Conn, stopC chan uint8) {
 			}
 			return
 		}
+		p.recvTime = time.Now()
 		// Start a goroutine for packet handling. Do not block connection read goroutine.
 		go func() {
 			if err := m.handlePacket(conn, p, remoteAddr); err != nil {
@@ -93,6 +122,9 @@ func (m *MetaNode) serveConn(conn net.Conn, stopC chan uint8) {
 func (m *MetaNode) handlePacket(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
 	// Handle request
+	data := p.Data
 	err = m.metadataManager.HandleMetadataOperation(conn, p, remoteAddr)
+	// the request is replied, and its data is copied by the ops which keep it
+	proto.Buffers.Put(data)
 	return
 }
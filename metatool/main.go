@@ -0,0 +1,313 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-metatool opens a meta partition offline from its snapshot, and optionally replays
+// its raft log, to query the inodes, dentries, extended attributes and multipart sessions
+// interactively or to export them in JSON.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/metanode"
+)
+
+var (
+	partitionDir = flag.String("partition", "", "directory of the meta partition")
+	walDir       = flag.String("wal", "", "raft log directory of the meta partition, the log is not replayed if empty")
+	exportFile   = flag.String("export", "", "export the partition in JSON to the file and exit, '-' for stdout")
+)
+
+func main() {
+	flag.Parse()
+	if *partitionDir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	p, err := metanode.LoadOfflinePartition(*partitionDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load partition failed: %v\n", err)
+		os.Exit(1)
+	}
+	if *walDir != "" {
+		var applied uint64
+		if applied, err = p.ReplayWAL(*walDir); err != nil {
+			fmt.Fprintf(os.Stderr, "replay wal failed at index(%v): %v\n", applied, err)
+			os.Exit(1)
+		}
+	}
+
+	t := &tool{p: p, out: os.Stdout}
+	if *exportFile != "" {
+		if err = t.export(*exportFile); err != nil {
+			fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	t.run(os.Stdin)
+}
+
+type command struct {
+	usage string
+	run   func(t *tool, args []string) error
+}
+
+var commands = map[string]*command{
+	"stat":      {"stat", (*tool).stat},
+	"inode":     {"inode <ino>", (*tool).inode},
+	"ls":        {"ls <parent ino>", (*tool).ls},
+	"dentry":    {"dentry <parent ino> <name>", (*tool).dentry},
+	"xattr":     {"xattr <ino>", (*tool).xattr},
+	"multipart": {"multipart [key prefix]", (*tool).multipart},
+	"export":    {"export <file>", func(t *tool, args []string) error { return t.export(arg(args, 0)) }},
+}
+
+type tool struct {
+	p   *metanode.OfflinePartition
+	out io.Writer
+}
+
+func (t *tool) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(t.out, "> ")
+		if !scanner.Scan() {
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "quit", "exit":
+			return
+		case "help":
+			for _, name := range []string{"stat", "inode", "ls", "dentry", "xattr", "multipart", "export"} {
+				fmt.Fprintf(t.out, "  %v\n", commands[name].usage)
+			}
+			fmt.Fprintln(t.out, "  quit")
+			continue
+		}
+		cmd, ok := commands[fields[0]]
+		if !ok {
+			fmt.Fprintf(t.out, "unknown command %v, type help for the commands\n", fields[0])
+			continue
+		}
+		if err := cmd.run(t, fields[1:]); err != nil {
+			fmt.Fprintf(t.out, "%v\nusage: %v\n", err, cmd.usage)
+		}
+	}
+}
+
+func (t *tool) stat(args []string) error {
+	var inodes, dentries, extends, multiparts int
+	t.p.RangeInodes(func(*metanode.Inode) bool { inodes++; return true })
+	t.p.RangeDentries(func(*metanode.Dentry) bool { dentries++; return true })
+	t.p.RangeExtends(func(*metanode.Extend) bool { extends++; return true })
+	t.p.RangeMultiparts(func(*metanode.Multipart) bool { multiparts++; return true })
+	conf := t.p.Config()
+	fmt.Fprintf(t.out, "partition: %v\nvolume: %v\nrange: [%v, %v]\napply id: %v\n",
+		conf.PartitionId, conf.VolName, conf.Start, conf.End, t.p.ApplyID())
+	fmt.Fprintf(t.out, "inodes: %v\ndentries: %v\nxattrs: %v\nmultiparts: %v\n", inodes, dentries, extends, multiparts)
+	return nil
+}
+
+func (t *tool) inode(args []string) error {
+	ino, err := parseUint(arg(args, 0))
+	if err != nil {
+		return err
+	}
+	inode := t.p.GetInode(ino)
+	if inode == nil {
+		return fmt.Errorf("inode %v not found", ino)
+	}
+	data, err := inode.MarshalToJSON()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(t.out, string(data))
+	return nil
+}
+
+func (t *tool) ls(args []string) error {
+	parent, err := parseUint(arg(args, 0))
+	if err != nil {
+		return err
+	}
+	for _, d := range t.p.ReadDir(parent) {
+		fmt.Fprintf(t.out, "%-20v %-8v %v\n", d.Inode, d.Type, d.Name)
+	}
+	return nil
+}
+
+func (t *tool) dentry(args []string) error {
+	parent, err := parseUint(arg(args, 0))
+	if err != nil {
+		return err
+	}
+	name := arg(args, 1)
+	for _, d := range t.p.ReadDir(parent) {
+		if d.Name == name {
+			return t.printJSON(d)
+		}
+	}
+	return fmt.Errorf("dentry %v not found under %v", name, parent)
+}
+
+func (t *tool) xattr(args []string) error {
+	ino, err := parseUint(arg(args, 0))
+	if err != nil {
+		return err
+	}
+	extend := t.p.GetExtend(ino)
+	if extend == nil {
+		return nil
+	}
+	extend.Range(func(key, value []byte) bool {
+		fmt.Fprintf(t.out, "%s=%q\n", key, value)
+		return true
+	})
+	return nil
+}
+
+func (t *tool) multipart(args []string) error {
+	prefix := arg(args, 0)
+	t.p.RangeMultiparts(func(m *metanode.Multipart) bool {
+		if !strings.HasPrefix(m.Key(), prefix) {
+			return true
+		}
+		var size uint64
+		parts := m.Parts()
+		for _, part := range parts {
+			size += part.Size
+		}
+		fmt.Fprintf(t.out, "%v %v init(%v) parts(%v) size(%v)\n", m.ID(), m.Key(), m.InitTime(), len(parts), size)
+		return true
+	})
+	return nil
+}
+
+type exportXAttr struct {
+	Inode uint64            `json:"inode"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+type exportMultipart struct {
+	ID       string           `json:"id"`
+	Key      string           `json:"key"`
+	InitTime string           `json:"initTime"`
+	Parts    []*metanode.Part `json:"parts"`
+}
+
+// export writes the partition as a JSON object, the inodes are streamed one by one to
+// keep the memory usage low.
+func (t *tool) export(file string) (err error) {
+	if file == "" {
+		return fmt.Errorf("file is required")
+	}
+	var w io.Writer = t.out
+	if file != "-" {
+		var fp *os.File
+		if fp, err = os.Create(file); err != nil {
+			return
+		}
+		defer func() {
+			if e := fp.Close(); err == nil {
+				err = e
+			}
+		}()
+		w = fp
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	conf := t.p.Config()
+	fmt.Fprintf(bw, `{"partitionId":%v,"volName":%q,"start":%v,"end":%v,"applyId":%v,`,
+		conf.PartitionId, conf.VolName, conf.Start, conf.End, t.p.ApplyID())
+
+	writeArray := func(name string, rangeFunc func(f func(v interface{}) bool)) {
+		fmt.Fprintf(bw, "\n%q:[", name)
+		first := true
+		rangeFunc(func(v interface{}) bool {
+			if !first {
+				bw.WriteByte(',')
+			}
+			first = false
+			if err = enc.Encode(v); err != nil {
+				return false
+			}
+			return true
+		})
+		bw.WriteByte(']')
+	}
+	writeArray("inodes", func(f func(v interface{}) bool) {
+		t.p.RangeInodes(func(ino *metanode.Inode) bool { return f(ino) })
+	})
+	bw.WriteByte(',')
+	writeArray("dentries", func(f func(v interface{}) bool) {
+		t.p.RangeDentries(func(d *metanode.Dentry) bool { return f(d) })
+	})
+	bw.WriteByte(',')
+	writeArray("xattrs", func(f func(v interface{}) bool) {
+		t.p.RangeExtends(func(e *metanode.Extend) bool {
+			x := &exportXAttr{Inode: e.GetInode(), Attrs: make(map[string]string)}
+			e.Range(func(key, value []byte) bool {
+				x.Attrs[string(key)] = string(value)
+				return true
+			})
+			return f(x)
+		})
+	})
+	bw.WriteByte(',')
+	writeArray("multiparts", func(f func(v interface{}) bool) {
+		t.p.RangeMultiparts(func(m *metanode.Multipart) bool {
+			return f(&exportMultipart{ID: m.ID(), Key: m.Key(), InitTime: m.InitTime().String(), Parts: m.Parts()})
+		})
+	})
+	bw.WriteString("}\n")
+	if err != nil {
+		return
+	}
+	return bw.Flush()
+}
+
+func (t *tool) printJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(t.out, string(data))
+	return nil
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func parseUint(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("inode is required")
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
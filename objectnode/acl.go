@@ -19,8 +19,12 @@ package objectnode
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 
-	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/gorilla/mux"
 )
 
 const (
@@ -40,13 +44,13 @@ const (
 // https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/acl-overview.html
 var (
 	aclBucketPermissionActions = map[Permission][]Action{
-		ReadPermission:     {ListBucketAction, ListBucketVersionsAction, ListBucketMultipartUploadsAction},
-		WritePermission:    {PutObjectAction, DeleteObjectAction},
+		ReadPermission:     {ListBucketAction, ListBucketVersionsAction, ListBucketMultipartUploadsAction, ListMultipartUploadPartsAction},
+		WritePermission:    {PutObjectAction, DeleteObjectAction, DeleteObjectVersionAction, AbortMultipartUploadAction},
 		ReadACPPermission:  {GetBucketAclAction},
 		WriteACPPermission: {PutBucketAclAction},
 		FullControlPermission: {
-			ListBucketAction, ListBucketVersionsAction, ListBucketMultipartUploadsAction,
-			PutObjectAction, DeleteObjectAction,
+			ListBucketAction, ListBucketVersionsAction, ListBucketMultipartUploadsAction, ListMultipartUploadPartsAction,
+			PutObjectAction, DeleteObjectAction, DeleteObjectVersionAction, AbortMultipartUploadAction,
 			GetBucketAclAction,
 			PutBucketAclAction},
 	}
@@ -85,10 +89,11 @@ const (
 type AclRole = string
 
 const (
-	objectOwnerRole AclRole = "owner"
-	bucketOwnerRole         = "bucket-owner"
-	allUsersRole            = "AllUsers"
-	LogDeliveryRole         = "LogDelivery"
+	objectOwnerRole        AclRole = "owner"
+	bucketOwnerRole                = "bucket-owner"
+	allUsersRole                   = "AllUsers"
+	authenticatedUsersRole         = "AuthenticatedUsers"
+	LogDeliveryRole                = "LogDelivery"
 )
 
 var (
@@ -97,7 +102,7 @@ var (
 		PublicReadACL:             {"bucket": {"owner": {FullControlPermission}, "AllUsers": {ReadPermission}}, "object": {"owner": {FullControlPermission}, "AllUsers": {ReadPermission}}},
 		PubliceReadWriteACL:       {"bucket": {"owner": {FullControlPermission}, "AllUsers": {ReadPermission, WritePermission}}, "object": {"owner": {FullControlPermission}, "AllUsers": {ReadPermission, WritePermission}}},
 		AwsExecReadACL:            {"bucket": {"owner": {FullControlPermission}}, "object": {"owner": {FullControlPermission}}},
-		AuthenticatedReadACL:      {"bucket": {"owner": {FullControlPermission}}, "object": {"owner": {FullControlPermission}}},
+		AuthenticatedReadACL:      {"bucket": {"owner": {FullControlPermission}, "AuthenticatedUsers": {ReadPermission}}, "object": {"owner": {FullControlPermission}, "AuthenticatedUsers": {ReadPermission}}},
 		BucketOwnerReadACL:        {"object": {"owner": {FullControlPermission}, "bucket-owner": {ReadPermission}}},
 		BucketOwnerFullControlACL: {"object": {"owner": {FullControlPermission}, "bucket-owner": {FullControlPermission}}},
 		LogDeliveryWriteACL:       {"bucket": {"LogDelivery": {WriteACPPermission, ReadACPPermission}}},
@@ -109,8 +114,8 @@ type Permission string
 
 // grantee
 type Grantee struct {
-	Xmlns        string `xmlns:si,attr,omitempty`
-	Xmlsi        string `xsi:type,attr,omitempty`
+	Xmlns        string `xml:"xmlns:xsi,attr,omitempty"`
+	Xmlsi        string `xml:"xsi:type,attr,omitempty"`
 	Id           string `xml:"ID,omitempty"`
 	URI          string `xml:"URI,omitempty"`
 	Type         string `xml:"Type,omitempty"`
@@ -143,9 +148,12 @@ type AccessControlPolicy struct {
 }
 
 func (acp *AccessControlPolicy) Validate(bucket string) (bool, error) {
+	if len(acp.Acl.Grants) > maxGrantCount {
+		return false, fmt.Errorf("more than %v grants", maxGrantCount)
+	}
 	for _, grant := range acp.Acl.Grants {
 		if !grant.Validate() {
-			return false, nil
+			return false, fmt.Errorf("invalid grant of permission %v to grantee %v%v", grant.Permission, grant.Grantee.Id, grant.Grantee.URI)
 		}
 	}
 
@@ -153,9 +161,8 @@ func (acp *AccessControlPolicy) Validate(bucket string) (bool, error) {
 }
 
 func (acp *AccessControlPolicy) IsAllowed(param *RequestParam) bool {
-	log.LogInfof("acl is allowed ?")
 	if len(acp.Acl.Grants) == 0 {
-		return true
+		return param.account != ""
 	}
 	for _, grant := range acp.Acl.Grants {
 		if grant.IsAllowed(param) {
@@ -165,6 +172,30 @@ func (acp *AccessControlPolicy) IsAllowed(param *RequestParam) bool {
 	return false
 }
 
+// allowsAnonymous tells whether the ACL grants the anonymous requests of the method, i.e. the
+// reads by public-read, and the writes too by public-read-write. The actions of the requests
+// are checked later by the policy and the ACL.
+func (acp *AccessControlPolicy) allowsAnonymous(method string) bool {
+	for _, grant := range acp.Acl.Grants {
+		if grant.Grantee.URI != aclRoleURIMap[allUsersRole] {
+			continue
+		}
+		switch grant.Permission {
+		case FullControlPermission:
+			return true
+		case ReadPermission:
+			if method == http.MethodGet || method == http.MethodHead {
+				return true
+			}
+		case WritePermission:
+			if method == http.MethodPut || method == http.MethodPost || method == http.MethodDelete {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 var (
 	aclGrantKeyPermissionMap = map[string]Permission{
 		"x-amz-grant-full-control": FullControlPermission,
@@ -174,50 +205,88 @@ var (
 		"x-amz-grant-write-acp":    WriteACPPermission,
 	}
 	aclRoleURIMap = map[string]string{
-		"AllUsers":    "http://acs.amazonaws.com/groups/global/AllUsers",
-		"LogDelivery": "http://acs.amazonaws.com/groups/s3/LogDelivery",
+		"AllUsers":           "http://acs.amazonaws.com/groups/global/AllUsers",
+		"AuthenticatedUsers": "http://acs.amazonaws.com/groups/global/AuthenticatedUsers",
+		"LogDelivery":        "http://acs.amazonaws.com/groups/s3/LogDelivery",
 	}
 )
 
+func newBucketACL(owner string) *AccessControlPolicy {
+	return &AccessControlPolicy{
+		Xmlns: XMLNS,
+		Owner: Owner{Id: owner, DispalyName: owner},
+	}
+}
+
+func canonicalUserGrantee(id string) Grantee {
+	return Grantee{Xmlns: XMLNS, Xmlsi: XMLXSI, Type: DEF_GRANTEE_TYPE, Id: id, DisplayName: id}
+}
+
+func groupGrantee(uri string) Grantee {
+	return Grantee{Xmlns: XMLNS, Xmlsi: "Group", Type: "Group", URI: uri}
+}
+
 // https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAcl.html
-func (acp *AccessControlPolicy) SetBucketStandardACL(param *RequestParam, acl string) {
-	sacl := StandardACL(acl)
-	var (
-		rolePermissionsMap map[string][]Permission
-		ok                 bool
-	)
-
-	if rolePermissionsMap, ok = aclPermissions[sacl]["bucket"]; !ok {
-		return
+// NewBucketStandardACL returns the ACL of the canned ACL of the bucket, or nil if it is not
+// supported by the buckets.
+func NewBucketStandardACL(owner string, acl StandardACL) *AccessControlPolicy {
+	rolePermissionsMap, ok := aclPermissions[acl][bucketResource]
+	if !ok {
+		return nil
+	}
+	roles := make([]string, 0, len(rolePermissionsMap))
+	for role := range rolePermissionsMap {
+		roles = append(roles, role)
 	}
-	for role, permissions := range rolePermissionsMap {
-		grantee := Grantee{}
+	sort.Strings(roles)
+	acp := newBucketACL(owner)
+	for _, role := range roles {
+		grantee := canonicalUserGrantee(owner)
 		if uri, ok := aclRoleURIMap[role]; ok {
-			grantee.URI = uri
-		} else {
-			grantee.Id = param.account
-			grantee.DisplayName = param.account
+			grantee = groupGrantee(uri)
 		}
-		for _, p := range permissions {
-			grant := Grant{
-				Grantee:    grantee,
-				Permission: p,
-			}
-			acp.Acl.Grants = append(acp.Acl.Grants, grant)
+		for _, p := range rolePermissionsMap[role] {
+			acp.Acl.Grants = append(acp.Acl.Grants, Grant{Grantee: grantee, Permission: p})
 		}
 	}
+	return acp
 }
 
-func (acp *AccessControlPolicy) SetBucketGrantACL(param *RequestParam, permission Permission) {
-	grantee := Grantee{
-		Id:          param.account,
-		DisplayName: param.account,
+// parseBucketGrantHeaders returns the ACL of the grant headers like
+// 'x-amz-grant-read: id="accessKey", uri="http://acs.amazonaws.com/groups/global/AllUsers"',
+// or nil if there is none.
+func parseBucketGrantHeaders(owner string, header http.Header) (acp *AccessControlPolicy, err error) {
+	names := make([]string, 0, len(aclGrantKeyPermissionMap))
+	for name := range aclGrantKeyPermissionMap {
+		names = append(names, name)
 	}
-	grant := Grant{
-		Grantee:    grantee,
-		Permission: permission,
+	sort.Strings(names)
+	for _, name := range names {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		if acp == nil {
+			acp = newBucketACL(owner)
+		}
+		for _, item := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid grantee %v", item)
+			}
+			var grantee Grantee
+			switch v := strings.Trim(kv[1], `"`); strings.ToLower(kv[0]) {
+			case "id":
+				grantee = canonicalUserGrantee(v)
+			case "uri":
+				grantee = groupGrantee(v)
+			default:
+				return nil, fmt.Errorf("unsupported grantee %v", item)
+			}
+			acp.Acl.Grants = append(acp.Acl.Grants, Grant{Grantee: grantee, Permission: aclGrantKeyPermissionMap[name]})
+		}
 	}
-	acp.Acl.Grants = append(acp.Acl.Grants, grant)
+	return
 }
 
 func (acl *AccessControlPolicy) Marshal() ([]byte, error) {
@@ -268,13 +337,53 @@ func storeBucketACL(bytes []byte, vol *volume) (*AccessControlPolicy, error) {
 }
 
 func (g Grant) Validate() bool {
-	return true
+	if _, ok := aclBucketPermissionActions[g.Permission]; !ok {
+		return false
+	}
+	if g.Grantee.URI == "" {
+		return g.Grantee.Id != ""
+	}
+	for _, uri := range aclRoleURIMap {
+		if g.Grantee.URI == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// matches tells whether the grantee is the requester, which is anonymous if the account is empty.
+func (g Grantee) matches(account string) bool {
+	switch g.URI {
+	case "":
+		return g.Id != "" && g.Id == account
+	case aclRoleURIMap[allUsersRole]:
+		return true
+	case aclRoleURIMap[authenticatedUsersRole]:
+		return account != ""
+	}
+	return false
 }
 
 func (g *Grant) IsAllowed(param *RequestParam) bool {
-	if param.account != g.Grantee.Id {
+	if !g.Grantee.matches(param.account) {
+		return false
+	}
+	// the objects have no ACLs of their own, and are granted by the ACL of the bucket
+	return IsIntersectionActions(aclBucketPermissionActions[g.Permission], param.actions) ||
+		IsIntersectionActions(aclObjectPermissionActions[g.Permission], param.actions)
+}
+
+// isAnonymousAllowed tells whether the request without signature is allowed by the ACL of the
+// bucket, i.e. the bucket is public.
+func (o *ObjectNode) isAnonymousAllowed(r *http.Request) bool {
+	bucket := mux.Vars(r)["bucket"]
+	if bucket == "" {
+		return false
+	}
+	vol, err := o.getVol(bucket)
+	if err != nil {
 		return false
 	}
-	actions := aclBucketPermissionActions[g.Permission]
-	return IsIntersectionActions(actions, param.actions)
+	acl := vol.loadACL()
+	return acl != nil && acl.allowsAnonymous(r.Method)
 }
@@ -17,8 +17,6 @@ package objectnode
 // https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/acl-using-rest-api.html
 
 import (
-	"encoding/xml"
-	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -32,126 +30,104 @@ const (
 	XMLXSI           = "CanonicalUser"
 	DEF_GRANTEE_TYPE = "CanonicalUser" //
 
+	BucketACLLimitSize = 64 * 1024
 )
 
-var (
-	defaultGrant = Grant{
-		Grantee: Grantee{
-			Xmlns: XMLNS,
-			Xmlsi: XMLXSI,
-			Type:  DEF_GRANTEE_TYPE,
-		},
-		Permission: FullControlPermission,
-	}
-)
-
-// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketAcl.html
+// Get bucket acl
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketAcl.html
 func (o *ObjectNode) getBucketACLHandler(w http.ResponseWriter, r *http.Request) {
-	log.LogInfof("Get bucket acl")
-	var (
-		err error
-		ec  *ErrorCode
-	)
-	defer o.errorResponse(w, r, err, ec)
-
+	log.LogInfof("getBucketACLHandler: get bucket acl: requestID(%v)", RequestIDFromRequest(r))
 	_, bucket, _, vol, err := o.parseRequestParams(r)
-	if bucket == "" {
-		ec = &NoSuchBucket
+	if err != nil || vol == nil {
+		log.LogErrorf("getBucketACLHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
 		return
 	}
 
-	//om := vol.OSSMeta()
 	acl := vol.loadACL()
-	var aclData []byte
-	if acl != nil {
-		aclData, err = xml.Marshal(acl)
-		if err != nil {
-			ec = &InternalError
-			return
-		}
-
-	} else {
-		acl = &AccessControlPolicy{}
-		acl.Acl.Grants = append(acl.Acl.Grants, defaultGrant)
-		aclData, err = xml.Marshal(acl)
-		if err != nil {
-			ec = &InternalError
-			return
-		}
+	if acl == nil {
+		owner, _ := vol.OSSSecure()
+		acl = NewBucketStandardACL(owner, PrivateACL)
 	}
-
-	w.Write(aclData)
-
-	return
-}
-
-// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAcl.html#API_PutBucketAcl_RequestSyntax
-//
-func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request) {
-	var (
-		err error
-		ec  *ErrorCode
-	)
-	defer o.errorResponse(w, r, err, ec)
-
-	log.LogInfof("Put bucket acl")
-	_, bucket, _, vol, err1 := o.parseRequestParams(r)
-	if err1 != nil {
-		err = err1
+	var marshaled []byte
+	if marshaled, err = acl.Marshal(); err != nil {
+		log.LogErrorf("getBucketACLHandler: marshal result fail: requestID(%v) bucket(%v) err(%v)", RequestIDFromRequest(r), bucket, err)
+		ServeInternalStaticErrorResponse(w, r)
 		return
 	}
-	if bucket == "" {
-		err = errors.New("")
-		ec = &NoSuchBucket
-		return
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketACLHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
 	}
+	return
+}
 
-	bytes, err2 := ioutil.ReadAll(r.Body)
-	if err2 != nil && err2 != io.EOF {
-		err = err2
+// Put bucket acl, which is either the canned acl of the header 'x-amz-acl', the grants of the
+// headers 'x-amz-grant-*', or the access control policy of the request body.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAcl.html
+func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketACLHandler: put bucket acl: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vol, err := o.parseRequestParams(r)
+	if err != nil || vol == nil {
+		log.LogErrorf("putBucketACLHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
 		return
 	}
+	owner, _ := vol.OSSSecure()
 
-	acl, err3 := ParseACL(bytes, vol.name)
-	if err3 != nil {
-		err = err3
-		return
-	}
-	if acl == nil {
-		err = errors.New("")
+	var acl *AccessControlPolicy
+	if canned := r.Header.Get(HeaderNameACL); canned != "" {
+		if acl = NewBucketStandardACL(owner, StandardACL(canned)); acl == nil {
+			log.LogWarnf("putBucketACLHandler: unsupported canned acl: requestID(%v) acl(%v)", RequestIDFromRequest(r), canned)
+			_ = InvalidArgument.ServeResponse(w, r)
+			return
+		}
+	} else if acl, err = parseBucketGrantHeaders(owner, r.Header); err != nil {
+		log.LogWarnf("putBucketACLHandler: parse grant headers fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InvalidArgument.ServeResponse(w, r)
 		return
 	}
 
-	//add standard acl request header
-	// https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/acl-overview.html
-	p, err5 := o.parseRequestParam(r)
-	if err5 != nil {
-		err = err5
-		return
-	}
-	if standardAcls, found := r.Header["x-amz-acl"]; found {
-		acl.SetBucketStandardACL(p, standardAcls[0])
+	var body []byte
+	if acl != nil {
+		if _, err = acl.Validate(bucket); err != nil {
+			log.LogWarnf("putBucketACLHandler: invalid acl: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			_ = InvalidArgument.ServeResponse(w, r)
+			return
+		}
+		if body, err = acl.Marshal(); err != nil {
+			log.LogErrorf("putBucketACLHandler: marshal acl fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			ServeInternalStaticErrorResponse(w, r)
+			return
+		}
 	} else {
-		for grant, permission := range aclGrantKeyPermissionMap {
-			if _, found2 := r.Header[grant]; found2 {
-				acl.SetBucketGrantACL(p, permission)
-			}
+		if r.ContentLength > BucketACLLimitSize {
+			_ = EntityTooLarge.ServeResponse(w, r)
+			return
+		}
+		if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketACLLimitSize+1)); err != nil {
+			log.LogErrorf("putBucketACLHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			_ = InternalError.ServeResponse(w, r)
+			return
+		}
+		if len(body) > BucketACLLimitSize {
+			_ = EntityTooLarge.ServeResponse(w, r)
+			return
+		}
+		if acl, err = ParseACL(body, bucket); err != nil {
+			log.LogWarnf("putBucketACLHandler: parse acl fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			_ = MalformedACLError.ServeResponse(w, r)
+			return
 		}
 	}
 
-	newBytes, err6 := acl.Marshal()
-	if err6 != nil {
-		err = err6
-		return
-	}
-
-	// store bucket acl
-	_, err4 := storeBucketACL(newBytes, vol)
-	if err4 != nil {
-		err = err4
+	if _, err = storeBucketACL(body, vol); err != nil {
+		log.LogErrorf("putBucketACLHandler: store acl fail: requestID(%v) bucket(%v) err(%v)", RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
 		return
 	}
-
+	log.LogDebugf("putBucketACLHandler: bucket acl set: requestID(%v) bucket(%v) grants(%v)",
+		RequestIDFromRequest(r), bucket, len(acl.Acl.Grants))
 	return
 }
 
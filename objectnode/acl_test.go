@@ -13,3 +13,98 @@
 // permissions and limitations under the License.
 
 package objectnode
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewBucketStandardACL(t *testing.T) {
+	anonymousGet := &RequestParam{actions: []Action{GetObjectAction}}
+	anonymousPut := &RequestParam{actions: []Action{PutObjectAction}}
+	authenticatedGet := &RequestParam{account: "other", actions: []Action{GetObjectAction}}
+
+	acl := NewBucketStandardACL("owner", PrivateACL)
+	if acl == nil || acl.IsAllowed(anonymousGet) || acl.IsAllowed(authenticatedGet) || acl.allowsAnonymous(http.MethodGet) {
+		t.Fatalf("private acl allows others: %v", acl)
+	}
+	if !acl.IsAllowed(&RequestParam{account: "owner", actions: []Action{PutBucketAclAction}}) {
+		t.Fatalf("private acl denies owner")
+	}
+
+	acl = NewBucketStandardACL("owner", PublicReadACL)
+	if !acl.IsAllowed(anonymousGet) || acl.IsAllowed(anonymousPut) {
+		t.Fatalf("public-read acl: get(%v) put(%v)", acl.IsAllowed(anonymousGet), acl.IsAllowed(anonymousPut))
+	}
+	if !acl.allowsAnonymous(http.MethodHead) || acl.allowsAnonymous(http.MethodPut) {
+		t.Fatalf("public-read acl allows anonymous put")
+	}
+
+	acl = NewBucketStandardACL("owner", PubliceReadWriteACL)
+	if !acl.IsAllowed(anonymousPut) || acl.IsAllowed(&RequestParam{actions: []Action{PutBucketAclAction}}) {
+		t.Fatalf("public-read-write acl: put(%v)", acl.IsAllowed(anonymousPut))
+	}
+	if !acl.allowsAnonymous(http.MethodDelete) {
+		t.Fatalf("public-read-write acl denies anonymous delete")
+	}
+
+	acl = NewBucketStandardACL("owner", AuthenticatedReadACL)
+	if acl.IsAllowed(anonymousGet) || !acl.IsAllowed(authenticatedGet) || acl.allowsAnonymous(http.MethodGet) {
+		t.Fatalf("authenticated-read acl: anonymous(%v) authenticated(%v)", acl.IsAllowed(anonymousGet), acl.IsAllowed(authenticatedGet))
+	}
+
+	if acl = NewBucketStandardACL("owner", BucketOwnerReadACL); acl != nil {
+		t.Fatalf("object acl for bucket: %v", acl)
+	}
+}
+
+func TestParseBucketGrantHeaders(t *testing.T) {
+	header := http.Header{}
+	if acl, err := parseBucketGrantHeaders("owner", header); acl != nil || err != nil {
+		t.Fatalf("acl(%v) err(%v) without grant headers", acl, err)
+	}
+	header.Set("x-amz-grant-read", `uri="http://acs.amazonaws.com/groups/global/AllUsers", id="reader"`)
+	header.Set("x-amz-grant-write", `id="writer"`)
+	acl, err := parseBucketGrantHeaders("owner", header)
+	if err != nil || len(acl.Acl.Grants) != 3 {
+		t.Fatalf("acl(%v) err(%v)", acl, err)
+	}
+	if _, err = acl.Validate("b"); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if !acl.allowsAnonymous(http.MethodGet) || acl.allowsAnonymous(http.MethodPut) {
+		t.Fatalf("grant headers: anonymous get or put")
+	}
+	if !acl.IsAllowed(&RequestParam{account: "writer", actions: []Action{PutObjectAction}}) ||
+		acl.IsAllowed(&RequestParam{account: "reader", actions: []Action{PutObjectAction}}) {
+		t.Fatalf("grant headers: writer denied or reader allowed to put")
+	}
+
+	header.Set("x-amz-grant-write", `email="a@b.c"`)
+	if _, err = parseBucketGrantHeaders("owner", header); err == nil {
+		t.Fatalf("no error of unsupported grantee")
+	}
+}
+
+func TestAccessControlPolicy_Validate(t *testing.T) {
+	grant := func(g Grantee, p Permission) Grant {
+		return Grant{Grantee: g, Permission: p}
+	}
+	cases := []struct {
+		grant Grant
+		valid bool
+	}{
+		{grant(canonicalUserGrantee("a"), ReadPermission), true},
+		{grant(groupGrantee(aclRoleURIMap[allUsersRole]), WritePermission), true},
+		{grant(groupGrantee("http://acs.amazonaws.com/groups/global/Unknown"), ReadPermission), false},
+		{grant(canonicalUserGrantee(""), ReadPermission), false},
+		{grant(canonicalUserGrantee("a"), "EXECUTE"), false},
+	}
+	for i, c := range cases {
+		acl := newBucketACL("owner")
+		acl.Acl.Grants = []Grant{c.grant}
+		if _, err := acl.Validate("b"); (err == nil) != c.valid {
+			t.Fatalf("case(%v): err(%v), expect valid(%v)", i, err, c.valid)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
@@ -35,6 +36,9 @@ type RequestParam struct {
 	condVals map[string][]string
 	isOwner  bool
 	vars     map[string]string
+	// the access key of the volume the request is signed by besides the one of the owner, whose
+	// requests are served as the ones of the owner within its scope
+	volAccessKey *proto.VolAccessKey
 }
 
 func (o *ObjectNode) parseRequestParam(r *http.Request) (*RequestParam, error) {
@@ -58,9 +62,12 @@ func (o *ObjectNode) parseRequestParam(r *http.Request) (*RequestParam, error) {
 	auth := parseRequestAuthInfo(r)
 	if auth != nil && p.vol != nil {
 		accessKey, _ := p.vol.OSSSecure()
-		p.account = accessKey
+		p.account = auth.accessKey
 		if auth.accessKey == accessKey {
 			p.isOwner = true
+		} else if p.volAccessKey = p.vol.VolAccessKey(auth.accessKey); p.volAccessKey != nil {
+			p.account = accessKey
+			p.isOwner = true
 		}
 	}
 
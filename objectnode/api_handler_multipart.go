@@ -15,12 +15,24 @@
 package objectnode
 
 import (
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
+const (
+	// CompleteMultipartUploadMaxSize limits the request body of CompleteMultipartUpload, which is
+	// enough for the 10000 parts allowed.
+	CompleteMultipartUploadMaxSize = 1 << 21
+)
+
 // Create multipart upload
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateMultipartUpload.html
 func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +46,23 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 		_ = NoSuchBucket.ServeResponse(w, r)
 		return
 	}
-	uploadId, initErr := vl.InitMultipart(object)
+	sse, ec := parseSSEHeader(r)
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	tagging, ec := parseTaggingHeader(r.Header.Get(HeaderNameTagging))
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	retention, legalHold, ec := parseObjectLockHeaders(r, vl.loadObjectLock(), time.Now())
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	var opt = &PutFileOption{SSE: sse, Tagging: tagging, Retention: retention.Encode(), LegalHold: legalHold}
+	uploadId, initErr := vl.InitMultipart(object, opt)
 	if initErr != nil {
 		log.LogErrorf("createMultipleUploadHandler:  init multipart fail, requestID(%v) err(%v)",
 			RequestIDFromRequest(r), err)
@@ -60,6 +88,9 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 	// set response header
 	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
 	w.Header().Set(HeaderNameContentLength, strconv.Itoa(len(bytes)))
+	if sse != "" {
+		w.Header().Set(HeaderNameSSE, sse)
+	}
 	if _, err = w.Write(bytes); err != nil {
 		log.LogErrorf("createMultipleUploadHandler: write response body fail, requestID(%v) err(%v)",
 			RequestIDFromRequest(r), err)
@@ -103,6 +134,14 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 	var fsFileInfo *FSFileInfo
 	if fsFileInfo, err = vl.WritePart(object, uploadId, uint16(partNumberInt), r.Body); err != nil {
 		log.LogErrorf("uploadPartHandler: write part fail, requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		if err == syscall.EDQUOT {
+			_ = QuotaExceeded.ServeResponse(w, r)
+			return
+		}
+		if ec := chunkReadErrorCode(err); ec != nil {
+			_ = ec.ServeResponse(w, r)
+			return
+		}
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
@@ -111,6 +150,9 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 	// write header to response
 	w.Header().Set(HeaderNameContentLength, "0")
 	w.Header().Set(HeaderNameETag, fsFileInfo.ETag)
+	if fsFileInfo.SSE != "" {
+		w.Header().Set(HeaderNameSSE, fsFileInfo.SSE)
+	}
 	return
 }
 
@@ -128,8 +170,8 @@ func (o *ObjectNode) listPartsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	//// get upload id and part number
 	uploadId := params[ParamUploadId]
-	maxParts := params[ParamMaxParts]
-	partNoMarker := params[ParamPartNoMarker]
+	maxParts := r.URL.Query().Get(ParamMaxParts)
+	partNoMarker := r.URL.Query().Get(ParamPartNoMarker)
 
 	var maxPartsInt uint64
 	var partNoMarkerInt uint64
@@ -154,9 +196,9 @@ func (o *ObjectNode) listPartsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if partNoMarker != "" {
-		res, err := strconv.ParseUint(uploadId, 10, 64)
+		res, err := strconv.ParseUint(partNoMarker, 10, 16)
 		if err != nil {
-			log.LogErrorf("listPatsHandler: parse update ID fail, requestID(%v) raw(%v) err(%v)", RequestIDFromRequest(r), uploadId, err)
+			log.LogErrorf("listPartsHandler: parse part number marker fail, requestID(%v) raw(%v) err(%v)", RequestIDFromRequest(r), partNoMarker, err)
 			_ = InvalidArgument.ServeResponse(w, r)
 			return
 		}
@@ -182,15 +224,16 @@ func (o *ObjectNode) listPartsHandler(w http.ResponseWriter, r *http.Request) {
 	parts := NewParts(fsParts)
 
 	listPartsResult := ListPartsResult{
-		Bucket:       bucket,
-		Key:          object,
-		UploadId:     uploadId,
-		StorageClass: StorageClassStandard,
-		NextMarker:   int(nextMarker),
-		MaxParts:     int(maxPartsInt),
-		IsTruncated:  isTruncated,
-		Parts:        parts,
-		Owner:        bucketOwner,
+		Bucket:           bucket,
+		Key:              object,
+		UploadId:         uploadId,
+		StorageClass:     StorageClassStandard,
+		PartNumberMarker: int(partNoMarkerInt),
+		NextMarker:       int(nextMarker),
+		MaxParts:         int(maxPartsInt),
+		IsTruncated:      isTruncated,
+		Parts:            parts,
+		Owner:            bucketOwner,
 	}
 
 	var bytes []byte
@@ -232,11 +275,42 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	fsFileInfo, err := vl.CompleteMultipart(object, uploadId)
+	// parse the parts requested in order
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, CompleteMultipartUploadMaxSize)); err != nil {
+		log.LogErrorf("completeMultipartUploadHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var completeUpload = &CompleteMultipartUpload{}
+	if err = UnmarshalXMLEntity(body, completeUpload); err != nil || len(completeUpload.Parts) == 0 {
+		log.LogWarnf("completeMultipartUploadHandler: unmarshal parts fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	var parts = make([]*FSPart, 0, len(completeUpload.Parts))
+	for _, part := range completeUpload.Parts {
+		parts = append(parts, &FSPart{PartNumber: part.PartNumber, ETag: strings.Trim(part.ETag, "\"")})
+	}
+
+	fsFileInfo, err := vl.CompleteMultipart(object, uploadId, parts)
 	if err != nil {
 		log.LogErrorf("completeMultipartUploadHandler: complete multipart fail, requestID(%v) uploadID(%v) err(%v)",
 			RequestIDFromRequest(r), uploadId, err)
-		_ = InternalError.ServeResponse(w, r)
+		switch err {
+		case proto.ErrInvalidPart:
+			_ = InvalidPart.ServeResponse(w, r)
+		case proto.ErrInvalidPartOrder:
+			_ = InvalidPartOrder.ServeResponse(w, r)
+		case proto.ErrEntityTooSmall:
+			_ = EntityTooSmall.ServeResponse(w, r)
+		case syscall.ENOENT:
+			_ = NoSuchUpload.ServeResponse(w, r)
+		case syscall.EDQUOT:
+			_ = QuotaExceeded.ServeResponse(w, r)
+		default:
+			_ = InternalError.ServeResponse(w, r)
+		}
 		return
 	}
 	log.LogDebugf("completeMultipartUploadHandler: complete multipart, requestID(%v) uploadID(%v) path(%v)",
@@ -260,10 +334,21 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 	// set response header
 	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
 	w.Header().Set(HeaderNameContentLength, strconv.Itoa(len(bytes)))
+	if vl.versioningEnabled() {
+		w.Header().Set(HeaderNameVersionId, versionID(fsFileInfo.Inode))
+	}
+	if fsFileInfo.SSE != "" {
+		w.Header().Set(HeaderNameSSE, fsFileInfo.SSE)
+	}
 	if _, err = w.Write(bytes); err != nil {
 		log.LogErrorf("completeMultipartUploadHandler: write response body fail, requestID(%v) err(%v)", RequestIDFromRequest(r), err)
-		return
 	}
+
+	var event = &objectEvent{name: EventObjectCreatedCompleteMultipartUpload, key: object, size: fsFileInfo.Size, etag: fsFileInfo.ETag}
+	if vl.versioningEnabled() {
+		event.versionID = versionID(fsFileInfo.Inode)
+	}
+	o.notifyEvents(r, vl, event)
 	return
 }
 
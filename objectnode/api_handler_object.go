@@ -15,30 +15,27 @@
 package objectnode
 
 import (
+	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
-var (
-	rangeRegexp = regexp.MustCompile("^bytes=(\\d)+-(\\d)*$")
-)
-
 // Get object
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
 func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO: range read support
 	log.LogInfof("getObjectHandler: get object, requestID(%v) remote(%v)", RequestIDFromRequest(r), r.RemoteAddr)
 
 	_, _, object, vl, err := o.parseRequestParams(r)
@@ -48,72 +45,52 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// parse http range option
-	var rangeOpt = strings.TrimSpace(r.Header.Get(HeaderNameRange))
-	var rangeLower uint64
-	var rangeUpper uint64
-	var isRangeRead bool
-	if len(rangeOpt) > 0 && rangeRegexp.MatchString(rangeOpt) {
-
-		var hyphenIndex = strings.Index(rangeOpt, "-")
-		if hyphenIndex < 0 {
-			_ = InvalidArgument.ServeResponse(w, r)
+	// get object meta
+	var fileInfo *FSFileInfo
+	var version *FSVersion
+	if id := r.URL.Query().Get(ParamVersionId); id != "" {
+		if version = o.getObjectVersion(w, r, vl, object, id); version == nil {
 			return
 		}
-
-		var lowerPart = rangeOpt[len("bytes="):hyphenIndex]
-		var upperPart = ""
-		if hyphenIndex+1 < len(rangeOpt) {
-			upperPart = rangeOpt[hyphenIndex+1:]
-		}
-
-		if len(lowerPart) > 0 {
-			if rangeLower, err = strconv.ParseUint(lowerPart, 10, 64); err != nil {
-				log.LogErrorf("getObjectHandler: parse range lower fail: requestID(%v) rangeOpt(%v) err(%v)",
-					RequestIDFromRequest(r), rangeOpt, err)
-				ServeInternalStaticErrorResponse(w, r)
-				return
-			}
-		}
-		if len(upperPart) > 0 {
-			if rangeUpper, err = strconv.ParseUint(upperPart, 10, 64); err != nil {
-				log.LogErrorf("getObjectHandler: parse range upper fail: requestID(%v) rangeOpt(%v) err(%v)",
-					RequestIDFromRequest(r), rangeOpt, err)
-				ServeInternalStaticErrorResponse(w, r)
-				return
-			}
+		fileInfo = &version.FSFileInfo
+	} else {
+		if fileInfo, err = vl.FileInfo(object); err != nil {
+			log.LogErrorf("getObjectHandler: volume get file info fail, requestId(%v) err(%v)", RequestIDFromRequest(r), err)
+			_ = NoSuchKey.ServeResponse(w, r)
+			return
 		}
-		if rangeUpper > 0 && rangeUpper < rangeLower {
-			// upper enabled and lower than lower side
-			if err = InvalidArgument.ServeResponse(w, r); err != nil {
-				log.LogErrorf("getObjectHandler: serve response fail: requestID(%v) err(%v)",
-					RequestIDFromRequest(r), err)
-				return
-			}
+		if vl.loadVersioning() != "" {
+			w.Header().Set(HeaderNameVersionId, versionID(fileInfo.Inode))
 		}
-
-		isRangeRead = true
-		log.LogDebugf("getObjectHandler: parse range option: requestID(%v) rangeOpt(%v) rangeLower(%v) rangeUpper(%v)",
-			RequestIDFromRequest(r), rangeOpt, rangeLower, rangeUpper)
 	}
 
-	// get object meta
-	fileInfo, err := vl.FileInfo(object)
-	if err != nil {
-		log.LogErrorf("getObjectHandler: volume get file info fail, requestId(%v) err(%v)", RequestIDFromRequest(r), err)
-		_ = NoSuchKey.ServeResponse(w, r)
+	switch objectConditionHeaders.check(r, fileInfo) {
+	case conditionNotModified:
+		serveNotModified(w, fileInfo)
+		return
+	case conditionFailed:
+		_ = PreconditionFailed.ServeResponse(w, r)
 		return
 	}
 
-	// validate and fix range
-	if isRangeRead && rangeUpper > uint64(fileInfo.Size) {
-		rangeUpper = uint64(fileInfo.Size)
-	}
-
-	// compute content length
-	var contentLength = uint64(fileInfo.Size)
-	if isRangeRead {
-		contentLength = rangeUpper - rangeLower
+	// parse http range option, which is ignored if the If-Range does not match
+	var ranges []httpRange
+	if rangeOpt := strings.TrimSpace(r.Header.Get(HeaderNameRange)); rangeOpt != "" && ifRangeMatches(r, fileInfo) {
+		ranges, err = parseRanges(rangeOpt, fileInfo.Size)
+		switch {
+		case err == errUnsatisfiableRange:
+			w.Header().Set(HeaderNameContentRange, fmt.Sprintf("bytes */%d", fileInfo.Size))
+			_ = InvalidRange.ServeResponse(w, r)
+			return
+		case err != nil:
+			log.LogDebugf("getObjectHandler: ignore invalid range: requestID(%v) rangeOpt(%v)",
+				RequestIDFromRequest(r), rangeOpt)
+		case rangesSize(ranges) > fileInfo.Size:
+			// the ranges overlapping too much are served as the whole object
+			ranges = nil
+		}
+		log.LogDebugf("getObjectHandler: parse range option: requestID(%v) rangeOpt(%v) ranges(%v)",
+			RequestIDFromRequest(r), rangeOpt, ranges)
 	}
 
 	// set response header for GetObject
@@ -121,30 +98,54 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(HeaderNameAcceptRange, HeaderValueAcceptRange)
 	w.Header().Set(HeaderNameLastModified, formatTimeRFC1123(fileInfo.ModifyTime))
 	w.Header().Set(HeaderNameContentType, HeaderValueTypeStream)
-	w.Header().Set(HeaderNameContentLength, strconv.FormatUint(contentLength, 10))
-
-	if isRangeRead {
-		w.Header().Set(HeaderNameContentRange, fmt.Sprintf("bytes %d-%d/%d", rangeLower, rangeUpper, fileInfo.Size))
+	if fileInfo.SSE != "" {
+		w.Header().Set(HeaderNameSSE, fileInfo.SSE)
 	}
+	if count := tagCount(fileInfo.Tagging); count > 0 {
+		w.Header().Set(HeaderNameTaggingCount, strconv.Itoa(count))
+	}
+	setObjectLockHeaders(w, fileInfo)
 
-	// get object content
-	var offset = rangeLower
-	var size = uint64(fileInfo.Size)
-	if isRangeRead {
-		if rangeUpper == 0 {
-			size = uint64(fileInfo.Size) - rangeLower
-		} else {
-			size = rangeUpper - rangeLower
-		}
+	// get object content, only the extents covering the ranges are read
+	var fileRanges = make([]FileRange, 0, len(ranges))
+	for _, rng := range ranges {
+		fileRanges = append(fileRanges, FileRange{Offset: uint64(rng.start), Size: uint64(rng.length)})
 	}
-	if err = vl.ReadFile(object, w, offset, size); err != nil {
-		log.LogErrorf("getObjectHandler: read from volume fail: requestId(%v) volume(%v) path(%v) offset(%v) size(%v) err(%v)",
-			RequestIDFromRequest(r), vl.name, object, offset, size, err)
+	var next = func(int) (io.Writer, error) {
+		return w, nil
+	}
+	var byteRanges *byteRangesWriter
+	switch len(ranges) {
+	case 0:
+		fileRanges = append(fileRanges, FileRange{Size: uint64(fileInfo.Size)})
+		w.Header().Set(HeaderNameContentLength, strconv.FormatInt(fileInfo.Size, 10))
+	case 1:
+		w.Header().Set(HeaderNameContentRange, ranges[0].contentRange(fileInfo.Size))
+		w.Header().Set(HeaderNameContentLength, strconv.FormatInt(ranges[0].length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+	default:
+		byteRanges = newByteRangesWriter(w, ranges, fileInfo.Size, HeaderValueTypeStream)
+		next = byteRanges.NextPart
+		w.Header().Set(HeaderNameContentType, byteRanges.ContentType())
+		w.Header().Set(HeaderNameContentLength, strconv.FormatInt(byteRanges.ContentLength(), 10))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	if version != nil {
+		err = vl.ReadFileVersionRanges(version, fileRanges, next)
+	} else {
+		err = vl.ReadFileRanges(object, fileRanges, next)
+	}
+	if err == nil && byteRanges != nil {
+		err = byteRanges.Close()
+	}
+	if err != nil {
+		log.LogErrorf("getObjectHandler: read from volume fail: requestId(%v) volume(%v) path(%v) ranges(%v) err(%v)",
+			RequestIDFromRequest(r), vl.name, object, fileRanges, err)
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
-	log.LogDebugf("getObjectHandler: volume read file: requestID(%v) volume(%v) path(%v) offset(%v) size(%v)",
-		RequestIDFromRequest(r), vl.name, object, offset, size)
+	log.LogDebugf("getObjectHandler: volume read file: requestID(%v) volume(%v) path(%v) ranges(%v)",
+		RequestIDFromRequest(r), vl.name, object, fileRanges)
 	return
 }
 
@@ -163,15 +164,36 @@ func (o *ObjectNode) headObjectHandler(w http.ResponseWriter, r *http.Request) {
 	log.LogInfof("headObjectHandler: parse request params result in header object handler, object(%v) vl(%v) err(%v)", object, vl.name, err)
 
 	// get object meta
-	fileInfo, err := vl.FileInfo(object)
-	if err != nil && err == syscall.ENOENT {
-		log.LogErrorf("headObjectHandler: get file meta fail, requestId(%v), err(%v)", RequestIDFromRequest(r), err)
-		_ = NoSuchKey.ServeResponse(w, r)
-		return
+	var fileInfo *FSFileInfo
+	if id := r.URL.Query().Get(ParamVersionId); id != "" {
+		var version *FSVersion
+		if version = o.getObjectVersion(w, r, vl, object, id); version == nil {
+			return
+		}
+		fileInfo = &version.FSFileInfo
+	} else {
+		fileInfo, err = vl.FileInfo(object)
+		if err != nil && err == syscall.ENOENT {
+			log.LogErrorf("headObjectHandler: get file meta fail, requestId(%v), err(%v)", RequestIDFromRequest(r), err)
+			_ = NoSuchKey.ServeResponse(w, r)
+			return
+		}
+		if err != nil {
+			log.LogErrorf("headObjectHandler: get file meta fail, requestId(%v), err(%v)", RequestIDFromRequest(r), err)
+			_ = InternalError.ServeResponse(w, r)
+			return
+		}
+		if vl.loadVersioning() != "" {
+			w.Header().Set(HeaderNameVersionId, versionID(fileInfo.Inode))
+		}
 	}
-	if err != nil {
-		log.LogErrorf("headObjectHandler: get file meta fail, requestId(%v), err(%v)", RequestIDFromRequest(r), err)
-		_ = InternalError.ServeResponse(w, r)
+
+	switch objectConditionHeaders.check(r, fileInfo) {
+	case conditionNotModified:
+		serveNotModified(w, fileInfo)
+		return
+	case conditionFailed:
+		_ = PreconditionFailed.ServeResponse(w, r)
 		return
 	}
 
@@ -182,103 +204,105 @@ func (o *ObjectNode) headObjectHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(HeaderNameLastModified, formatTimeRFC1123(fileInfo.ModifyTime))
 	w.Header().Set(HeaderNameContentLength, strconv.Itoa(int(fileInfo.Size)))
 	w.Header().Set(HeaderNameContentMD5, EmptyContentMD5String)
+	if fileInfo.SSE != "" {
+		w.Header().Set(HeaderNameSSE, fileInfo.SSE)
+	}
+	if count := tagCount(fileInfo.Tagging); count > 0 {
+		w.Header().Set(HeaderNameTaggingCount, strconv.Itoa(count))
+	}
+	setObjectLockHeaders(w, fileInfo)
 	return
 }
 
 // Delete objects (multiple objects)
+// The keys are deleted in parallel, and the result of each key is reported in the order of the
+// request, only the errors in the quiet mode. The keys not found are reported as deleted.
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjects.html
 func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request) {
 	log.LogInfof("deleteObjectsHandler: delete multiple objects, requestID(%v) remote(%v)",
 		RequestIDFromRequest(r), r.RemoteAddr)
 	// check args
 	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("deleteObjectsHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
 
-	bytes, err := ioutil.ReadAll(r.Body)
-	if err != nil && err != io.EOF {
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, DeleteObjectsLimitSize+1)); err != nil {
 		log.LogErrorf("deleteObjectsHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
+	if len(body) > DeleteObjectsLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+	if requestMD5 := r.Header.Get(HeaderNameContentMD5); requestMD5 != "" {
+		sum := md5.Sum(body)
+		if base64.StdEncoding.EncodeToString(sum[:]) != requestMD5 {
+			_ = BadDigest.ServeResponse(w, r)
+			return
+		}
+	}
 
 	deleteReq := DeleteRequest{}
-	err = UnmarshalXMLEntity(bytes, &deleteReq)
-	if err != nil {
+	if err = UnmarshalXMLEntity(body, &deleteReq); err != nil {
 		log.LogErrorf("deleteObjectsHandler: unmarshal xml fail: requestID(%v) err(%v)",
 			RequestIDFromRequest(r), err)
-		_ = InvalidArgument.ServeResponse(w, r)
+		_ = MalformedXML.ServeResponse(w, r)
 		return
 	}
-
-	if len(deleteReq.Objects) <= 0 {
-		log.LogDebugf("deleteObjectsHandler: non objects found in request: requestID(%v)", RequestIDFromRequest(r))
-		_ = InvalidArgument.ServeResponse(w, r)
+	if len(deleteReq.Objects) == 0 || len(deleteReq.Objects) > MaxDeleteObjects {
+		log.LogDebugf("deleteObjectsHandler: invalid number of objects: requestID(%v) objects(%v)",
+			RequestIDFromRequest(r), len(deleteReq.Objects))
+		_ = MalformedXML.ServeResponse(w, r)
 		return
 	}
 
+	var deleted = make([]*Deleted, len(deleteReq.Objects))
+	var deleteErrors = make([]*Error, len(deleteReq.Objects))
 	var wg sync.WaitGroup
-	deletesResult := DeletesResult{
-		DeletedObjects: make([]Deleted, 0),
-		DeletedErrors:  make([]Error, 0),
+	var indexCh = make(chan int, len(deleteReq.Objects))
+	for i := range deleteReq.Objects {
+		indexCh <- i
 	}
-	deletedObjectsCh := make(chan *Deleted, len(deleteReq.Objects))
-	deletedErrorsCh := make(chan *Error, len(deleteReq.Objects))
-
-	for _, object := range deleteReq.Objects {
+	close(indexCh)
+	for worker := 0; worker < deleteObjectsParallelism && worker < len(deleteReq.Objects); worker++ {
 		wg.Add(1)
-		go func(obj Object) {
-			defer func() {
-				wg.Done()
-				if errDelete := recover(); errDelete != nil {
-					deletedError := Error{
-						Key:     obj.Key,
-						Code:    strconv.Itoa(InternalError.StatusCode),
-						Message: InternalError.ErrorMessage,
-					}
-					deletedErrorsCh <- &deletedError
-				}
-			}()
-
-			err = vl.DeleteFile(obj.Key)
-			if err != nil {
-				ossError := transferError(obj.Key, err)
-				deletedErrorsCh <- &ossError
-			} else {
-				deleted := Deleted{Key: obj.Key}
-				deletedObjectsCh <- &deleted
-				log.LogDebugf("deleteObjectsHandler: delete object: requestID(%v) key(%v)", RequestIDFromRequest(r),
-					deleted.Key)
+		go func() {
+			defer wg.Done()
+			for i := range indexCh {
+				deleted[i], deleteErrors[i] = o.deleteObjectOfBatch(r, vl, deleteReq.Objects[i])
 			}
-		}(object)
+		}()
 	}
-
 	wg.Wait()
-	close(deletedObjectsCh)
-	close(deletedErrorsCh)
 
-	for {
-		deletedObject := <-deletedObjectsCh
-		if deletedObject == nil {
-			break
-		}
-		deletesResult.DeletedObjects = append(deletesResult.DeletedObjects, *deletedObject)
+	deletesResult := DeletesResult{
+		DeletedObjects: make([]Deleted, 0),
+		DeletedErrors:  make([]Error, 0),
 	}
-	for {
-		deletedError := <-deletedErrorsCh
-		if deletedError == nil {
-			break
+	var events = make([]*objectEvent, 0, len(deleteReq.Objects))
+	for i := range deleteReq.Objects {
+		if deleteErrors[i] != nil {
+			deletesResult.DeletedErrors = append(deletesResult.DeletedErrors, *deleteErrors[i])
+			continue
+		}
+		if !deleteReq.Quiet {
+			deletesResult.DeletedObjects = append(deletesResult.DeletedObjects, *deleted[i])
 		}
-		deletesResult.DeletedErrors = append(deletesResult.DeletedErrors, *deletedError)
+		events = append(events, deleteEvent(deleted[i].Key, deleted[i].VersionId, deleted[i].DeleteMarkerVersionId))
 	}
+	o.notifyEvents(r, vl, events...)
 
-	log.LogDebugf("deleteObjectsHandler: delete objects: deletes(%v) errors(%v)", len(deletesResult.DeletedObjects), len(deletesResult.DeletedErrors))
+	log.LogDebugf("deleteObjectsHandler: delete objects: requestID(%v) objects(%v) errors(%v)",
+		RequestIDFromRequest(r), len(deleteReq.Objects), len(deletesResult.DeletedErrors))
 	var bytesRes []byte
-	var marshalError error
-	if bytesRes, marshalError = MarshalXMLEntity(deletesResult); marshalError != nil {
+	if bytesRes, err = MarshalXMLEntity(deletesResult); err != nil {
 		log.LogErrorf("deleteObjectsHandler: marshal xml entity fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
-		if respErr := InternalError.ServeResponse(w, r); respErr != nil {
-			log.LogErrorf("deleteObjectsHandler: write response fail: requestID(%v) err(%v)", RequestIDFromRequest(r), respErr)
-			return
-		}
+		_ = InternalError.ServeResponse(w, r)
 		return
 	}
 
@@ -291,6 +315,53 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 	return
 }
 
+// deleteObjectOfBatch deletes an object of DeleteObjects, and returns either its result or its error.
+func (o *ObjectNode) deleteObjectOfBatch(r *http.Request, vl *volume, obj Object) (deleted *Deleted, deleteError *Error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.LogErrorf("deleteObjectsHandler: delete object panic: requestID(%v) key(%v) versionID(%v) panic(%v)",
+				RequestIDFromRequest(r), obj.Key, obj.VersionId, recovered)
+			deleted = nil
+			deleteError = &Error{Key: obj.Key, VersionId: obj.VersionId, Code: InternalError.ErrorCode, Message: InternalError.ErrorMessage}
+		}
+	}()
+
+	deleted = &Deleted{Key: obj.Key, VersionId: obj.VersionId}
+	var err error
+	if obj.VersionId != "" {
+		if ec := o.checkVersionDeletable(r, vl, obj.Key, obj.VersionId); ec != nil {
+			return nil, &Error{Key: obj.Key, VersionId: obj.VersionId, Code: ec.ErrorCode, Message: ec.ErrorMessage}
+		}
+		var version *FSVersion
+		if version, err = vl.DeleteFileVersion(obj.Key, obj.VersionId); err == syscall.ENOENT {
+			err = nil
+		}
+		if version != nil && version.DeleteMarker {
+			deleted.DeleteMarker = "true"
+			deleted.DeleteMarkerVersionId = version.VersionID
+		}
+	} else {
+		var deleteMarker string
+		if deleteMarker, err = vl.DeleteFile(obj.Key); err == syscall.ENOENT {
+			err = nil
+		}
+		if deleteMarker != "" {
+			deleted.DeleteMarker = "true"
+			deleted.DeleteMarkerVersionId = deleteMarker
+		}
+	}
+	if err != nil {
+		log.LogWarnf("deleteObjectsHandler: delete object fail: requestID(%v) key(%v) versionID(%v) err(%v)",
+			RequestIDFromRequest(r), obj.Key, obj.VersionId, err)
+		ossError := transferError(obj.Key, err)
+		ossError.VersionId = obj.VersionId
+		return nil, &ossError
+	}
+	log.LogDebugf("deleteObjectsHandler: delete object: requestID(%v) key(%v) versionID(%v)",
+		RequestIDFromRequest(r), obj.Key, obj.VersionId)
+	return deleted, nil
+}
+
 func parseCopySourceInfo(r *http.Request) (sourceBucket, sourceObject string) {
 	var copySource = r.Header.Get(HeaderNameCopySource)
 	if strings.HasPrefix(copySource, "/") {
@@ -320,15 +391,27 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the source of another bucket is copied by the data nodes, which is allowed to the owner of
+	// both the buckets, but not to the anonymous writer of a public bucket
 	sourceBucket, sourceObject := parseCopySourceInfo(r)
+	var sourceVol = vl
 	if bucket != sourceBucket {
-		log.LogDebugf("copyObjectHandler: source bucket is not same with bucket: requestID(%v) target(%v) source(%v)",
-			RequestIDFromRequest(r), bucket, sourceBucket)
-		_ = UnsupportedOperation.ServeResponse(w, r)
-		return
+		if sourceVol, err = o.getVol(sourceBucket); err != nil {
+			log.LogErrorf("copyObjectHandler: load source volume fail: requestID(%v) source(%v) err(%v)",
+				RequestIDFromRequest(r), sourceBucket, err)
+			_ = NoSuchBucket.ServeResponse(w, r)
+			return
+		}
+		accessKey, _ := vl.OSSSecure()
+		if sourceAccessKey, _ := sourceVol.OSSSecure(); sourceAccessKey != accessKey || parseRequestAuthInfo(r).accessKey == "" {
+			log.LogWarnf("copyObjectHandler: source bucket of another owner: requestID(%v) target(%v) source(%v)",
+				RequestIDFromRequest(r), bucket, sourceBucket)
+			_ = AccessDenied.ServeResponse(w, r)
+			return
+		}
 	}
 
-	if sourceObject == object {
+	if sourceVol == vl && sourceObject == object {
 		log.LogErrorf("copyObjectHandler: source object same with target object: requestID(%v) target(%v) source(%v)",
 			RequestIDFromRequest(r), object, sourceObject)
 		_ = InvalidArgument.ServeResponse(w, r)
@@ -336,63 +419,54 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get object meta
-	fileInfo, err := vl.FileInfo(sourceObject)
+	fileInfo, err := sourceVol.FileInfo(sourceObject)
 	if err != nil {
 		log.LogErrorf("copyObjectHandler: volume get file info fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
 		_ = NoSuchKey.ServeResponse(w, r)
 		return
 	}
 
-	// get header
-	copyMatch := r.Header.Get(HeaderNameCopyMatch)
-	noneMatch := r.Header.Get(HeaderNameCopyNoneMatch)
-	modified := r.Header.Get(HeaderNameCopyModified)
-	unModified := r.Header.Get(HeaderNameCopyUnModified)
+	// the copy fails with 412 if any condition of the source is false
+	if copySourceConditionHeaders.check(r, fileInfo) != conditionPassed {
+		log.LogInfof("copyObjectHandler: source preconditions failed: requestID(%v) source(%v)", RequestIDFromRequest(r), sourceObject)
+		_ = PreconditionFailed.ServeResponse(w, r)
+		return
+	}
 
-	// response 412
-	if modified != "" {
-		fileModTime := fileInfo.ModifyTime
-		modifiedTime, err := parseTimeRFC1123(modified)
-		if err != nil {
-			log.LogErrorf("copyObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
-			_ = InvalidArgument.ServeResponse(w, r)
-			return
-		}
-		if fileModTime.Before(modifiedTime) {
-			log.LogInfof("copyObjectHandler: file modified time not after than specified time: requestID(%v)", RequestIDFromRequest(r))
-			_ = PreconditionFailed.ServeResponse(w, r)
-			return
+	// the copy in the bucket shares the inode and the tags of the source unless the tags are
+	// replaced, which copies the data instead
+	var fsFileInfo *FSFileInfo
+	switch directive := r.Header.Get(HeaderNameTaggingDirective); directive {
+	case "", TaggingDirectiveCopy:
+		if sourceVol != vl {
+			fsFileInfo, err = vl.CopyFileFrom(sourceVol, object, sourceObject, nil)
+		} else {
+			fsFileInfo, err = vl.CopyFile(object, sourceObject)
 		}
-	}
-	if unModified != "" {
-		fileModTime := fileInfo.ModifyTime
-		unmodifiedTime, err := parseTimeRFC1123(unModified)
-		if err != nil {
-			log.LogErrorf("copyObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
-			_ = InvalidArgument.ServeResponse(w, r)
+	case TaggingDirectiveReplace:
+		tagging, ec := parseTaggingHeader(r.Header.Get(HeaderNameTagging))
+		if ec != nil {
+			_ = ec.ServeResponse(w, r)
 			return
 		}
-		if fileModTime.After(unmodifiedTime) {
-			log.LogInfof("copyObjectHandler: file modified time not before than specified time: requestID(%v)", RequestIDFromRequest(r))
-			_ = PreconditionFailed.ServeResponse(w, r)
-			return
+		if sourceVol != vl {
+			fsFileInfo, err = vl.CopyFileFrom(sourceVol, object, sourceObject, &PutFileOption{Tagging: tagging})
+		} else {
+			fsFileInfo, err = vl.CopyFileData(object, sourceObject, &PutFileOption{SSE: fileInfo.SSE, Tagging: tagging})
 		}
-	}
-	if copyMatch != "" && fileInfo.ETag != copyMatch {
-		log.LogInfof("copyObjectHandler: eTag mismatched with specified: requestID(%v)", RequestIDFromRequest(r))
-		_ = PreconditionFailed.ServeResponse(w, r)
-		return
-	}
-	if noneMatch != "" && fileInfo.ETag == noneMatch {
-		log.LogInfof("copyObjectHandler: eTag same with specified: requestID(%v)", RequestIDFromRequest(r))
-		_ = PreconditionFailed.ServeResponse(w, r)
+	default:
+		log.LogWarnf("copyObjectHandler: invalid tagging directive: requestID(%v) directive(%v)",
+			RequestIDFromRequest(r), directive)
+		_ = InvalidArgument.ServeResponse(w, r)
 		return
 	}
-
-	fsFileInfo, err := vl.CopyFile(object, sourceObject)
 	if err != nil {
-		log.LogErrorf("copyObjectHandler: volume copy file fail: requestID(%v) volume(%v) source(%v) target(%v) err(%v)",
-			RequestIDFromRequest(r), vl.name, sourceObject, object, err)
+		log.LogErrorf("copyObjectHandler: volume copy file fail: requestID(%v) volume(%v) source(%v/%v) target(%v) err(%v)",
+			RequestIDFromRequest(r), vl.name, sourceVol.name, sourceObject, object, err)
+		if err == syscall.EDQUOT {
+			_ = QuotaExceeded.ServeResponse(w, r)
+			return
+		}
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
@@ -410,11 +484,19 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// set response header
+	// set response header, the copy keeps the encryption of the source
 	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
 	w.Header().Set(HeaderNameContentLength, strconv.Itoa(len(bytes)))
+	if fileInfo.SSE != "" {
+		w.Header().Set(HeaderNameSSE, fileInfo.SSE)
+	}
 	_, _ = w.Write(bytes)
 
+	var event = &objectEvent{name: EventObjectCreatedCopy, key: object, size: fsFileInfo.Size, etag: fsFileInfo.ETag}
+	if vl.versioningEnabled() {
+		event.versionID = versionID(fsFileInfo.Inode)
+	}
+	o.notifyEvents(r, vl, event)
 	return
 }
 
@@ -456,6 +538,7 @@ func (o *ObjectNode) getBucketV1Handler(w http.ResponseWriter, r *http.Request)
 		delimiter: delimiter,
 		marker:    marker,
 		maxKeys:   maxKeysInt,
+		tagFilter: ParseTagFilter(r.URL.Query().Get(ParamTag)),
 	}
 
 	fsFileInfos, nextMarker, isTruncated, prefixes, err := vl.ListFilesV1(listBucketRequest)
@@ -575,6 +658,7 @@ func (o *ObjectNode) getBucketV2Handler(w http.ResponseWriter, r *http.Request)
 		contToken:  contToken,
 		fetchOwner: fetchOwnerBool,
 		startAfter: startAfter,
+		tagFilter:  ParseTagFilter(r.URL.Query().Get(ParamTag)),
 	}
 
 	fsFileInfos, keyCount, nextToken, isTruncated, prefixes, err := vl.ListFilesV2(request)
@@ -670,8 +754,41 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 		checkMD5 = true
 	}
 
+	sse, ec := parseSSEHeader(r)
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	tagging, ec := parseTaggingHeader(r.Header.Get(HeaderNameTagging))
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	retention, legalHold, ec := parseObjectLockHeaders(r, vl.loadObjectLock(), time.Now())
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+
+	// the conditions against the existing object, e.g. 'If-None-Match: *' to create the object only
+	// if absent, are checked before the upload, but not atomically with the completion
+	if objectConditionHeaders.present(r) {
+		var fileInfo *FSFileInfo
+		if fileInfo, err = vl.FileInfo(object); err != nil && err != syscall.ENOENT {
+			log.LogErrorf("putObjectHandler: get file info fail: requestID(%v) path(%v) err(%v)",
+				RequestIDFromRequest(r), object, err)
+			_ = InternalError.ServeResponse(w, r)
+			return
+		}
+		if objectConditionHeaders.check(r, fileInfo) != conditionPassed {
+			_ = PreconditionFailed.ServeResponse(w, r)
+			return
+		}
+	}
+
 	var multipartID string
-	if multipartID, err = vl.InitMultipart(object); err != nil {
+	var opt = &PutFileOption{SSE: sse, Tagging: tagging, Retention: retention.Encode(), LegalHold: legalHold}
+	if multipartID, err = vl.InitMultipart(object, opt); err != nil {
 		log.LogErrorf("putObjectHandler: volume init multipart fail: requestID(%v) path(%v) err(%v)",
 			RequestIDFromRequest(r), object, err)
 		_ = InternalError.ServeResponse(w, r)
@@ -690,13 +807,25 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err = vl.WritePart(object, multipartID, partID, r.Body); err != nil {
 		log.LogErrorf("putObjectHandler: volume write part fail: requestID(%v) path(%v) multipartID(%v) err(%v)",
 			RequestIDFromRequest(r), object, multipartID, err)
+		if err == syscall.EDQUOT {
+			_ = QuotaExceeded.ServeResponse(w, r)
+			return
+		}
+		if ec := chunkReadErrorCode(err); ec != nil {
+			_ = ec.ServeResponse(w, r)
+			return
+		}
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
 	var fsFileInfo *FSFileInfo
-	if fsFileInfo, err = vl.CompleteMultipart(object, multipartID); err != nil {
+	if fsFileInfo, err = vl.CompleteMultipart(object, multipartID, nil); err != nil {
 		log.LogErrorf("putObjectHandler: volume complete multipart fail: requestID(%v) path(%v) multipartID(%v) err(%v)",
 			RequestIDFromRequest(r), object, multipartID, err)
+		if err == syscall.EDQUOT {
+			_ = QuotaExceeded.ServeResponse(w, r)
+			return
+		}
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
@@ -723,6 +852,15 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 	// set response header
 	w.Header().Set(HeaderNameETag, fsFileInfo.ETag)
 	w.Header().Set(HeaderNameContentLength, "0")
+	var event = &objectEvent{name: EventObjectCreatedPut, key: object, size: fsFileInfo.Size, etag: fsFileInfo.ETag}
+	if vl.versioningEnabled() {
+		event.versionID = versionID(fsFileInfo.Inode)
+		w.Header().Set(HeaderNameVersionId, event.versionID)
+	}
+	if fsFileInfo.SSE != "" {
+		w.Header().Set(HeaderNameSSE, fsFileInfo.SSE)
+	}
+	o.notifyEvents(r, vl, event)
 	return
 }
 
@@ -738,34 +876,151 @@ func (o *ObjectNode) deleteObjectHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = vl.DeleteFile(object)
+	// delete a version permanently
+	if versionID := r.URL.Query().Get(ParamVersionId); versionID != "" {
+		if ec := o.checkVersionDeletable(r, vl, object, versionID); ec != nil {
+			_ = ec.ServeResponse(w, r)
+			return
+		}
+		var version *FSVersion
+		if version, err = vl.DeleteFileVersion(object, versionID); err != nil && err != syscall.ENOENT {
+			log.LogErrorf("deleteObjectHandler: volume delete file version fail: requestID(%v) version(%v) err(%v)",
+				RequestIDFromRequest(r), versionID, err)
+			_ = InternalError.ServeResponse(w, r)
+			return
+		}
+		w.Header().Set(HeaderNameVersionId, versionID)
+		if version != nil && version.DeleteMarker {
+			w.Header().Set(HeaderNameDeleteMarker, "true")
+		}
+		o.notifyEvents(r, vl, deleteEvent(object, versionID, ""))
+		return
+	}
+
+	var deleteMarker string
+	deleteMarker, err = vl.DeleteFile(object)
 	if err != nil {
 		log.LogErrorf("deleteObjectHandler: volume delete file fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
 		_ = InternalError.ServeResponse(w, r)
 		return
 	}
-
+	if deleteMarker != "" {
+		w.Header().Set(HeaderNameVersionId, deleteMarker)
+		w.Header().Set(HeaderNameDeleteMarker, "true")
+	}
+	o.notifyEvents(r, vl, deleteEvent(object, "", deleteMarker))
 	return
 }
 
 // Get object tagging
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectTagging.html
 func (o *ObjectNode) getObjectTagging(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement handler 'GetObjectTagging'
+	log.LogInfof("getObjectTagging: get object tagging: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getObjectTagging: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var xAttrInfo *proto.XAttrInfo
+	if xAttrInfo, err = vl.GetXAttr(object, XAttrKeyOSSTagging); err != nil {
+		if err == syscall.ENOENT {
+			_ = NoSuchKey.ServeResponse(w, r)
+			return
+		}
+		log.LogErrorf("getObjectTagging: get tagging fail: requestID(%v) object(%v) err(%v)", RequestIDFromRequest(r), object, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var tagging *Tagging
+	if tagging, err = ParseTagging(xAttrInfo.XAttrs[XAttrKeyOSSTagging]); err != nil {
+		log.LogErrorf("getObjectTagging: parse tagging fail: requestID(%v) object(%v) err(%v)", RequestIDFromRequest(r), object, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(tagging); err != nil {
+		log.LogErrorf("getObjectTagging: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	w.Header().Set(HeaderNameContentLength, strconv.Itoa(len(marshaled)))
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getObjectTagging: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
 	return
 }
 
 // Put object tagging
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectTagging.html
 func (o *ObjectNode) putObjectTagging(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement handler 'PutObjectTagging'
+	log.LogInfof("putObjectTagging: put object tagging: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putObjectTagging: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, ObjectTaggingMaxSize)); err != nil {
+		log.LogErrorf("putObjectTagging: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var tagging = &Tagging{}
+	if err = UnmarshalXMLEntity(body, tagging); err != nil {
+		log.LogWarnf("putObjectTagging: unmarshal tagging fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if ec := tagging.Validate(); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+
+	// an empty tag set removes all the tags of the object
+	if len(tagging.TagSet) == 0 {
+		err = vl.DeleteXAttr(object, XAttrKeyOSSTagging)
+	} else {
+		err = vl.SetXAttr(object, XAttrKeyOSSTagging, []byte(tagging.Encode()))
+	}
+	if err == syscall.ENOENT {
+		_ = NoSuchKey.ServeResponse(w, r)
+		return
+	}
+	if err != nil {
+		log.LogErrorf("putObjectTagging: set tagging fail: requestID(%v) object(%v) err(%v)", RequestIDFromRequest(r), object, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
 	return
 }
 
 // Delete object tagging
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjectTagging.html
 func (o *ObjectNode) deleteObjectTagging(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement handler 'DeleteObjectTagging'
+	log.LogInfof("deleteObjectTagging: delete object tagging: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("deleteObjectTagging: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	if err = vl.DeleteXAttr(object, XAttrKeyOSSTagging); err != nil {
+		if err == syscall.ENOENT {
+			_ = NoSuchKey.ServeResponse(w, r)
+			return
+		}
+		log.LogErrorf("deleteObjectTagging: delete tagging fail: requestID(%v) object(%v) err(%v)", RequestIDFromRequest(r), object, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
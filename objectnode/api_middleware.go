@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -81,6 +82,29 @@ func (o *ObjectNode) traceMiddleware(next http.Handler) http.Handler {
 	return handlerFunc
 }
 
+// corsMiddleware answers the preflight requests, which are not signed, before the authentication,
+// and sets the CORS headers of the response to the request from an origin allowed by the CORS
+// configuration of the bucket.
+func (o *ObjectNode) corsMiddleware(next http.Handler) http.Handler {
+	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			o.corsPreflightHandler(w, r)
+			return
+		}
+		if origin := r.Header.Get(HeaderNameOrigin); origin != "" {
+			if vol, err := o.getVol(mux.Vars(r)["bucket"]); err == nil {
+				if config := vol.loadCORS(); config != nil {
+					if rule := config.Match(origin, r.Method, nil); rule != nil {
+						rule.setResponseHeaders(w, origin, nil, false)
+					}
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return handlerFunc
+}
+
 func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -115,7 +139,7 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 					}
 					return
 				}
-			} else {
+			} else if !o.isAnonymousAllowed(r) {
 				if err := AccessDenied.ServeResponse(w, r); err != nil {
 					log.LogErrorf("authMiddleware: serve response fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
 				}
@@ -128,6 +152,11 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 
 func (o *ObjectNode) contentMiddleware(next http.Handler) http.Handler {
 	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		// the signed chunks are decoded by the reader set by the signature check
+		if _, signed := r.Body.(*signedChunkReader); signed {
+			next.ServeHTTP(w, r)
+			return
+		}
 		if len(r.Header) > 0 && len(r.Header.Get(http.CanonicalHeaderKey(HeaderNameDecodeContentLength))) > 0 {
 			r.Body = NewChunkedReader(r.Body)
 			log.LogDebugf("contentMiddleware: chunk reader inited: requestID(%v)", RequestIDFromRequest(r))
@@ -136,3 +165,47 @@ func (o *ObjectNode) contentMiddleware(next http.Handler) http.Handler {
 	}
 	return handlerFunc
 }
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush flushes the streaming responses like the event stream of SelectObjectContent.
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// metricsMiddleware records the latency of every S3 request labeled with the bucket and
+// an operation name made of the HTTP method and the target level (bucket or object).
+func (o *ObjectNode) metricsMiddleware(next http.Handler) http.Handler {
+	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		if !metrics.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var startTime = time.Now()
+		var sw = &statusResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(sw, r)
+
+		var vars = mux.Vars(r)
+		var target = "bucket"
+		if len(vars["object"]) > 0 {
+			target = "object"
+		}
+		var err error
+		if sw.statusCode >= http.StatusInternalServerError {
+			err = fmt.Errorf("status %v", sw.statusCode)
+		}
+		metrics.ObserveOp(r.Method+"_"+target, vars["bucket"], startTime, err)
+	}
+	return handlerFunc
+}
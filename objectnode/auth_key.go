@@ -0,0 +1,206 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/cryptoutil"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	defaultAccessKeyCacheTTL = 60 * time.Second
+)
+
+var (
+	ErrAccessKeyNotMatch = errors.New("access key not match")
+)
+
+type accessKeyEntry struct {
+	secretKey string
+	caps      []byte
+	expire    time.Time
+}
+
+// accessKeyStore gets the secret keys of the access keys in the keystore of the authnodes, which
+// are cached for a while, so that a revoked or changed key takes effect after the cache expires.
+type accessKeyStore struct {
+	sync.RWMutex
+	keys map[string]*accessKeyEntry
+
+	hosts    []string
+	urlProto string
+	client   *http.Client
+	key      []byte
+	ttl      time.Duration
+}
+
+// newAccessKeyStore returns nil if the authnodes are not configured.
+func newAccessKeyStore(cfg *config.Config) (s *accessKeyStore, err error) {
+	authnodes := cfg.GetArray(configAuthnodes)
+	if len(authnodes) == 0 {
+		return
+	}
+	s = &accessKeyStore{keys: make(map[string]*accessKeyEntry), ttl: defaultAccessKeyCacheTTL}
+	for _, host := range authnodes {
+		s.hosts = append(s.hosts, host.(string))
+	}
+	if s.key, err = cryptoutil.Base64Decode(cfg.GetString(configAuthKey)); err != nil || len(s.key) == 0 {
+		return nil, fmt.Errorf("invalid %v: %v", configAuthKey, err)
+	}
+	if cfg.GetBool(proto.EnableHTTPS) {
+		var cert []byte
+		if cert, err = ioutil.ReadFile(cfg.GetString(proto.CertFile)); err != nil {
+			return nil, err
+		}
+		if s.client, err = cryptoutil.CreateClientX(&cert); err != nil {
+			return nil, err
+		}
+		s.urlProto = "https://"
+	} else {
+		s.client = &http.Client{Timeout: 10 * time.Second}
+		s.urlProto = "http://"
+	}
+	if ttl := cfg.GetInt64(configAccessKeyCacheTTL); ttl > 0 {
+		s.ttl = time.Duration(ttl) * time.Second
+	}
+	return
+}
+
+// get returns the secret key and the caps of the access key.
+func (s *accessKeyStore) get(accessKey string) (secretKey string, caps []byte, err error) {
+	s.RLock()
+	entry, ok := s.keys[accessKey]
+	s.RUnlock()
+	if ok && time.Now().Before(entry.expire) {
+		return entry.secretKey, entry.caps, nil
+	}
+	if entry, err = s.fetch(accessKey); err != nil {
+		return
+	}
+	s.Lock()
+	s.keys[accessKey] = entry
+	s.Unlock()
+	return entry.secretKey, entry.caps, nil
+}
+
+func (s *accessKeyStore) fetch(accessKey string) (entry *accessKeyEntry, err error) {
+	var (
+		ts   int64
+		body []byte
+		resp proto.AuthSecretKeyResp
+	)
+	req := proto.AuthSecretKeyReq{
+		Type:      proto.MsgAuthSecretKeyReq,
+		ServiceID: proto.ObjectServiceID,
+		AccessKey: accessKey,
+	}
+	if req.Verifier, ts, err = cryptoutil.GenVerifier(s.key); err != nil {
+		return
+	}
+	for _, host := range s.hosts {
+		if body, err = proto.SendData(s.client, s.urlProto+host+proto.ServiceGetSecretKey, req); err != nil {
+			continue
+		}
+		if resp, err = proto.ParseAuthSecretKeyResp(body, s.key, proto.ObjectServiceID, ts); err != nil {
+			// the access key not exists is replied by the leader, and not retried on the others
+			break
+		}
+		if resp.AccessKey != accessKey || resp.SecretKey == "" {
+			return nil, ErrAccessKeyNotMatch
+		}
+		return &accessKeyEntry{secretKey: resp.SecretKey, caps: resp.Caps, expire: time.Now().Add(s.ttl)}, nil
+	}
+	log.LogWarnf("accessKeyStore: get secret key of access key(%v) from %v failed: %v", accessKey, s.hosts, err)
+	return
+}
+
+// getSecretKey returns the secret key to verify the signature of the request to the volume by the
+// access key, which is either the access key of the owner or another unexpired one of the volume,
+// or an access key in the keystore of the authnodes whose caps grant the access to the volume.
+func (o *ObjectNode) getSecretKey(vol Volume, volName, accessKey string) (secretKey string, err error) {
+	volAccessKey, volSecretKey := vol.OSSSecure()
+	if accessKey != "" && accessKey == volAccessKey {
+		return volSecretKey, nil
+	}
+	if key := vol.VolAccessKey(accessKey); key != nil {
+		return key.SecretKey, nil
+	}
+	if o.akStore == nil || accessKey == "" {
+		return "", ErrAccessKeyNotMatch
+	}
+	var caps []byte
+	if secretKey, caps, err = o.akStore.get(accessKey); err != nil {
+		return
+	}
+	ticket := &cryptoutil.Ticket{Caps: caps}
+	if err = proto.CheckVOLAccessCaps(ticket, volName, proto.VOLAccess, proto.ObjectNode); err != nil {
+		return "", err
+	}
+	return
+}
+
+func isReadAction(action Action) bool {
+	name := strings.TrimPrefix(string(action), "s3:")
+	return strings.HasPrefix(name, "Get") || strings.HasPrefix(name, "List") || strings.HasPrefix(name, "Head")
+}
+
+// isBucketConfigAction returns whether the action changes the configurations of the bucket, which
+// is only allowed to the access key of the owner.
+func isBucketConfigAction(action Action) bool {
+	name := string(action)
+	return !isReadAction(action) && (strings.Contains(name, "Bucket") || strings.Contains(name, "Lifecycle"))
+}
+
+// allowedByVolAccessKey checks the request signed by an access key of the volume besides the one
+// of the owner against its scope. The configurations of the bucket are never changed by it, and
+// only read if it is read-only. If it is scoped by the prefixes, the keys of the objects requested
+// or copied must be under them, and the only requests to the bucket allowed are to list the
+// objects under them.
+func allowedByVolAccessKey(key *proto.VolAccessKey, param *RequestParam, r *http.Request) bool {
+	for _, action := range param.actions {
+		if isBucketConfigAction(action) || key.ReadOnly() && !isReadAction(action) {
+			return false
+		}
+	}
+	if r.Header.Get(HeaderNameCopySource) != "" {
+		// the access key is of the volume only, so is the source of the copy
+		sourceBucket, sourceObject := parseCopySourceInfo(r)
+		if sourceBucket != param.bucket || !key.AllowsKey(sourceObject) {
+			return false
+		}
+	}
+	if len(key.Prefixes) == 0 {
+		return true
+	}
+	if param.object != "" {
+		return key.AllowsKey(strings.TrimPrefix(param.object, "/"))
+	}
+	for _, action := range param.actions {
+		if action != ListBucketAction && action != ListBucketVersionsAction && action != GetBucketLocationAction {
+			return false
+		}
+	}
+	return key.AllowsKey(r.URL.Query().Get("prefix"))
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/cryptoutil"
+)
+
+// newFakeAuthnode serves the secret keys of the access keys like the authnode.
+func newFakeAuthnode(t *testing.T, key []byte, secretKeys map[string]string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		plaintext, err := cryptoutil.Base64Decode(r.FormValue(proto.ClientMessage))
+		if err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		var req proto.AuthSecretKeyReq
+		if err = json.Unmarshal(plaintext, &req); err != nil {
+			t.Fatalf("unmarshal request: %v", err)
+		}
+		ts, err := proto.ParseVerifier(req.Verifier, key)
+		if err != nil {
+			t.Fatalf("parse verifier: %v", err)
+		}
+		reply := &proto.HTTPAuthReply{Code: proto.ErrCodeAccessKeyNotExists, Msg: proto.ErrAccessKeyNotExists.Error()}
+		if secretKey, ok := secretKeys[req.AccessKey]; ok {
+			data, _ := json.Marshal(&proto.AuthSecretKeyResp{
+				Type:      req.Type + 1,
+				ServiceID: req.ServiceID,
+				Verifier:  ts + 1,
+				AccessKey: req.AccessKey,
+				SecretKey: secretKey,
+				Caps:      []byte(`{"OwnerVOL": ["objectnode:bucket1:*"]}`),
+			})
+			message, _ := cryptoutil.EncodeMessage(data, key)
+			reply = &proto.HTTPAuthReply{Code: proto.ErrCodeSuccess, Msg: proto.ErrSuc.Error(), Data: message}
+		}
+		data, _ := json.Marshal(reply)
+		w.Write(data)
+	}))
+}
+
+func TestAccessKeyStore_Get(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	var requests int32
+	server := newFakeAuthnode(t, key, map[string]string{"ak1": "sk1"}, &requests)
+	defer server.Close()
+
+	cfg := config.LoadConfigString(`{"authNodes": ["` + strings.TrimPrefix(server.URL, "http://") + `"], ` +
+		`"authKey": "` + cryptoutil.Base64Encode(key) + `"}`)
+	store, err := newAccessKeyStore(cfg)
+	if err != nil || store == nil {
+		t.Fatalf("new access key store: store(%v) err(%v)", store, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		secretKey, caps, err := store.get("ak1")
+		if err != nil || secretKey != "sk1" {
+			t.Fatalf("get ak1: secretKey(%v) err(%v)", secretKey, err)
+		}
+		ticket := &cryptoutil.Ticket{Caps: caps}
+		if err = proto.CheckVOLAccessCaps(ticket, "bucket1", proto.VOLAccess, proto.ObjectNode); err != nil {
+			t.Fatalf("caps of ak1 not granting bucket1: %v", err)
+		}
+		if err = proto.CheckVOLAccessCaps(ticket, "bucket2", proto.VOLAccess, proto.ObjectNode); err == nil {
+			t.Fatalf("caps of ak1 granting bucket2")
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("secret key not cached, %v requests", requests)
+	}
+
+	if _, _, err = store.get("ak2"); err == nil {
+		t.Fatalf("get not existing ak2 succeeded")
+	}
+}
+
+func TestAccessKeyStore_NotConfigured(t *testing.T) {
+	store, err := newAccessKeyStore(config.LoadConfigString(`{}`))
+	if store != nil || err != nil {
+		t.Fatalf("store(%v) err(%v) without authnodes", store, err)
+	}
+	if _, err = newAccessKeyStore(config.LoadConfigString(`{"authNodes": ["127.0.0.1:8080"]}`)); err == nil {
+		t.Fatalf("no error without auth key")
+	}
+}
+
+func TestAllowedByVolAccessKey(t *testing.T) {
+	readWrite := &proto.VolAccessKey{AccessKey: "rw", Permission: proto.AccessKeyReadWrite}
+	readOnly := &proto.VolAccessKey{AccessKey: "ro", Permission: proto.AccessKeyReadOnly, Prefixes: []string{"logs/"}}
+	cases := []struct {
+		key     *proto.VolAccessKey
+		method  string
+		url     string
+		object  string
+		source  string
+		actions []Action
+		allowed bool
+	}{
+		{readWrite, "PUT", "/bucket/a", "a", "", []Action{PutObjectAction}, true},
+		{readWrite, "PUT", "/bucket/a", "a", "bucket/b", []Action{PutObjectAction}, true},
+		{readWrite, "PUT", "/bucket/a", "a", "other/b", []Action{PutObjectAction}, false},
+		{readWrite, "PUT", "/bucket?policy", "", "", []Action{PutBucketPolicyAction}, false},
+		{readWrite, "PUT", "/bucket?lifecycle", "", "", []Action{PutLifecycleConfigurationAction}, false},
+		{readWrite, "GET", "/bucket?policy", "", "", []Action{GetBucketPolicyAction}, true},
+		{readOnly, "GET", "/bucket/logs/a", "logs/a", "", []Action{GetObjectAction}, true},
+		{readOnly, "GET", "/bucket/images/a", "images/a", "", []Action{GetObjectAction}, false},
+		{readOnly, "PUT", "/bucket/logs/a", "logs/a", "", []Action{PutObjectAction}, false},
+		{readOnly, "GET", "/bucket?prefix=logs/2020", "", "", []Action{ListBucketAction}, true},
+		{readOnly, "GET", "/bucket", "", "", []Action{ListBucketAction}, false},
+		{readOnly, "GET", "/bucket?acl", "", "", []Action{GetBucketAclAction}, false},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(c.method, c.url, nil)
+		if c.source != "" {
+			r.Header.Set(HeaderNameCopySource, c.source)
+		}
+		param := &RequestParam{bucket: "bucket", object: c.object, actions: c.actions}
+		if allowed := allowedByVolAccessKey(c.key, param, r); allowed != c.allowed {
+			t.Errorf("case %v: %v %v by %v allowed(%v), expect %v", i, c.method, c.url, c.key.AccessKey, allowed, c.allowed)
+		}
+	}
+}
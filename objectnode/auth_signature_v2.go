@@ -92,10 +92,12 @@ func parsePresignedV2AuthInfo(r *http.Request) (*requestAuthInfoV2, error) {
 		return nil, errors.New("uri is invalid")
 	}
 
-	vars := mux.Vars(r)
-	ai.accessKeyId = vars["accessKey"]
-	ai.signature = vars["signature"]
-	ai.expires = vars["expires"]
+	// the queries are not routed as the vars for the requests other than getting object
+	query := r.URL.Query()
+	ai.bucket = mux.Vars(r)["bucket"]
+	ai.accessKeyId = query.Get("AWSAccessKeyId")
+	ai.signature = query.Get("Signature")
+	ai.expires = query.Get("Expires")
 
 	return ai, nil
 }
@@ -178,11 +180,10 @@ func (o *ObjectNode) checkSignatureV2(r *http.Request) (bool, error) {
 		log.LogInfof("load Volume error: %v, %v", authInfo.r, err)
 		return false, err
 	}
-	volAccessKey, volSecret := v.OSSSecure()
-
-	if authInfo.accessKeyId != volAccessKey {
-		log.LogInfof("load Volume error: %v, %v", authInfo.accessKeyId, volAccessKey)
-		return false, errors.New("")
+	volSecret, err := o.getSecretKey(v, authInfo.bucket, authInfo.accessKeyId)
+	if err != nil {
+		log.LogInfof("checkSignatureV2: get secret key of access key(%v) error: %v", authInfo.accessKeyId, err)
+		return false, err
 	}
 
 	// 2. calculate new signature
@@ -238,24 +239,21 @@ func calculateSignatureV2(authInfo *requestAuthInfoV2, secretKey string, wildcar
 //
 func (o *ObjectNode) checkPresignedSignatureV2(r *http.Request) (bool, error) {
 
-	var err error
-
-	uris := strings.SplitN(r.RequestURI, "?", 2)
-	if len(uris) < 2 {
-		log.LogInfof("checkPresignedSignatureV2 error, request url invalid %v ", r.RequestURI)
+	authInfo, err := parsePresignedV2AuthInfo(r)
+	if err != nil {
 		return false, nil
 	}
 
-	params, _, _, vl, err := o.parseRequestParams(r)
+	_, _, _, vl, err := o.parseRequestParams(r)
 	if err != nil || vl == nil {
 		log.LogInfof("check PresignedSignatureV2 error: %v %v", err, vl)
 		return false, err
 	}
-	accessKey := params["accessKey"]
-	signature := params["signature"]
-	expires := params["expires"]
+	accessKey := authInfo.accessKeyId
+	signature := authInfo.signature
+	expires := authInfo.expires
 	if accessKey == "" || signature == "" || expires == "" {
-		log.LogInfof("checkPresignedSignatureV2 params not valid: %v", params)
+		log.LogInfof("checkPresignedSignatureV2 params not valid: accessKey(%v) signature(%v) expires(%v)", accessKey, signature, expires)
 		return false, nil
 	}
 
@@ -263,8 +261,9 @@ func (o *ObjectNode) checkPresignedSignatureV2(r *http.Request) (bool, error) {
 		RequestIDFromRequest(r), r.URL.String(), accessKey, signature, expires)
 
 	//check access key
-	vlKey, secretKey := vl.OSSSecure()
-	if vlKey == "" || accessKey != vlKey {
+	secretKey, err := o.getSecretKey(vl, authInfo.bucket, accessKey)
+	if err != nil {
+		log.LogInfof("checkPresignedSignatureV2: get secret key of access key(%v) error: %v", accessKey, err)
 		return false, nil
 	}
 
@@ -295,9 +294,9 @@ func checkExpires(expires string) (ok bool, err error) {
 	}
 	now := time.Now().UTC().Unix()
 	if now < expiresInt {
-		log.LogInfof("checkPresignedSignatureV2 expired is out time %v, now: %v", expires, now)
 		return true, nil
 	}
+	log.LogInfof("checkPresignedSignatureV2 expired is out time %v, now: %v", expires, now)
 
 	return false, nil
 }
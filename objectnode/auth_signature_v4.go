@@ -98,16 +98,21 @@ func isSignaturedV4(r *http.Request) bool {
 
 // check request signature valid
 func (o *ObjectNode) checkSignatureV4(r *http.Request) (bool, error) {
-	_, _, _, vl, _ := o.parseRequestParams(r)
+	_, bucket, _, vl, _ := o.parseRequestParams(r)
 	if vl == nil {
 		log.LogInfof("checkSignatureV4: no volume info: requestID(%v)", RequestIDFromRequest(r))
 		return false, nil
 	}
-	_, secretKey := vl.OSSSecure()
 	req, err := parseRequestV4(r)
 	if err != nil {
 		return false, err
 	}
+	secretKey, err := o.getSecretKey(vl, bucket, req.Credential.AccessKey)
+	if err != nil {
+		log.LogInfof("checkSignatureV4: get secret key fail: requestID(%v) accessKey(%v) err(%v)",
+			RequestIDFromRequest(r), req.Credential.AccessKey, err)
+		return false, nil
+	}
 	newSignature := calculateSignatureV4(r, o.region, secretKey, req.SignedHeaders)
 	if req.Signature != newSignature {
 		log.LogDebugf("checkSignatureV4: invalid signature: requestID(%v) client(%v) server(%v)",
@@ -115,6 +120,22 @@ func (o *ObjectNode) checkSignatureV4(r *http.Request) (bool, error) {
 		return false, nil
 	}
 
+	// the chunks of the streaming body are verified while being read, by the signatures chained
+	// to the one of the headers
+	if getContentHash(r.Header) == StreamingContentSHA256 {
+		var decodedLength int64 = -1
+		if value := r.Header.Get(HeaderNameDecodeContentLength); value != "" {
+			if decodedLength, err = strconv.ParseInt(value, 10, 64); err != nil || decodedLength < 0 {
+				log.LogInfof("checkSignatureV4: invalid decoded content length: requestID(%v) length(%v)",
+					RequestIDFromRequest(r), value)
+				return false, nil
+			}
+		}
+		signingKey := buildSigningKey(SCHEME, secretKey, req.Credential.Date, req.Credential.Region, SERVICE, TERMINATOR)
+		scope := buildScope(req.Credential.Date, req.Credential.Region, SERVICE, TERMINATOR)
+		r.Body = newSignedChunkReader(r.Body, signingKey, getStartTime(r.Header), scope, req.Signature, decodedLength)
+	}
+
 	return true, nil
 }
 
@@ -150,11 +171,10 @@ func (o *ObjectNode) checkPresignedSignatureV4(r *http.Request) (pass bool, err
 		return
 	}
 	//check accesskey
-	vaKey, secretKey := v.OSSSecure()
-	if req.Credential.AccessKey != vaKey {
-		log.LogInfof("checkPresignedSignatureV4: credential accessKey invalid: requestID(%v) requestAK(%v) volAK(%v)",
-			RequestIDFromRequest(r), req.Credential.AccessKey, vaKey)
-		err = errors.New("accesskey invalid")
+	var secretKey string
+	if secretKey, err = o.getSecretKey(v, req.bucket, req.Credential.AccessKey); err != nil {
+		log.LogInfof("checkPresignedSignatureV4: credential accessKey invalid: requestID(%v) requestAK(%v) err(%v)",
+			RequestIDFromRequest(r), req.Credential.AccessKey, err)
 		return
 	}
 	// create canonicalRequest
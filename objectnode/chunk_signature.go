@@ -0,0 +1,170 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+//
+// The body of the request with the content hash 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD' is sent in
+// the chunks like '<hex size>;chunk-signature=<signature>\r\n<data>\r\n', ending with the chunk of
+// the size 0. The signature of each chunk is chained to the one of the previous chunk, starting
+// from the signature of the headers, so that the chunks can not be modified, reordered or dropped.
+// Each chunk is verified before its data is returned to the reader.
+
+const (
+	StreamingContentSHA256 = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	signatureV4ChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	chunkSignatureFlag        = "chunk-signature="
+
+	// the chunks of the SDKs are 64KB to 1MB, the larger ones are rejected to bound the memory
+	maxSignedChunkSize = 16 << 20
+)
+
+var (
+	errChunkMalformed         = errors.New("malformed chunk")
+	errChunkSignatureMismatch = errors.New("chunk signature mismatch")
+	errChunkLengthMismatch    = errors.New("decoded content length mismatch")
+
+	emptySHA256 = calcHash("")
+)
+
+// signedChunkReader reads and verifies the data of the signed chunks.
+type signedChunkReader struct {
+	body       io.ReadCloser
+	reader     *bufio.Reader
+	signingKey []byte
+	timestamp  string
+	scope      string
+	signature  string // signature of the previous chunk, or of the headers at first
+	remaining  int64  // decoded content length not read yet, -1 if unknown
+	chunk      []byte // verified data of the current chunk not read yet
+	buffer     bytes.Buffer
+	err        error
+}
+
+func newSignedChunkReader(body io.ReadCloser, signingKey []byte, timestamp, scope, seedSignature string, decodedLength int64) *signedChunkReader {
+	return &signedChunkReader{
+		body:       body,
+		reader:     bufio.NewReader(body),
+		signingKey: signingKey,
+		timestamp:  timestamp,
+		scope:      scope,
+		signature:  seedSignature,
+		remaining:  decodedLength,
+	}
+}
+
+func (r *signedChunkReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *signedChunkReader) Read(p []byte) (n int, err error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.nextChunk()
+	}
+	n = copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// nextChunk reads and verifies the next chunk, and returns io.EOF after the final chunk.
+func (r *signedChunkReader) nextChunk() error {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	sepIndex := strings.Index(line, ";")
+	if sepIndex < 0 || !strings.HasPrefix(line[sepIndex+1:], chunkSignatureFlag) {
+		return errChunkMalformed
+	}
+	size, err := strconv.ParseInt(line[:sepIndex], 16, 64)
+	if err != nil || size < 0 || size > maxSignedChunkSize {
+		return errChunkMalformed
+	}
+	signature := line[sepIndex+1+len(chunkSignatureFlag):]
+
+	r.buffer.Reset()
+	if _, err = io.CopyN(&r.buffer, r.reader, size+2); err != nil {
+		return unexpectedEOF(err)
+	}
+	data := r.buffer.Bytes()
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return errChunkMalformed
+	}
+	data = data[:size]
+
+	if expect := r.chunkSignature(data); signature != expect {
+		return errChunkSignatureMismatch
+	}
+	r.signature = signature
+
+	if r.remaining >= 0 {
+		if r.remaining -= size; r.remaining < 0 || (size == 0 && r.remaining != 0) {
+			return errChunkLengthMismatch
+		}
+	}
+	if size == 0 {
+		return io.EOF
+	}
+	r.chunk = data
+	return nil
+}
+
+func (r *signedChunkReader) chunkSignature(data []byte) string {
+	hash := sha256.Sum256(data)
+	stringToSign := strings.Join([]string{
+		signatureV4ChunkAlgorithm,
+		r.timestamp,
+		r.scope,
+		r.signature,
+		emptySHA256,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+	return hex.EncodeToString(sign(stringToSign, r.signingKey))
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// chunkReadErrorCode returns the error code of the error reading the signed chunks, or nil if the
+// error is not of the chunks.
+func chunkReadErrorCode(err error) *ErrorCode {
+	switch err {
+	case errChunkSignatureMismatch:
+		return &SignatureDoesNotMatch
+	case errChunkMalformed, errChunkLengthMismatch, io.ErrUnexpectedEOF:
+		return &IncompleteBody
+	}
+	return nil
+}
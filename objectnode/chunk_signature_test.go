@@ -0,0 +1,89 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// the example of https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+const (
+	testChunkSecretKey = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	testChunkTimestamp = "20130524T000000Z"
+	testChunkScope     = "20130524/us-east-1/s3/aws4_request"
+	testChunkSeed      = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+)
+
+var testChunkSignatures = []string{
+	"ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648",
+	"0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497",
+	"b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9",
+}
+
+func newTestSignedChunkReader(body string, decodedLength int64) *signedChunkReader {
+	signingKey := buildSigningKey(SCHEME, testChunkSecretKey, "20130524", "us-east-1", SERVICE, TERMINATOR)
+	return newSignedChunkReader(wrapReader(strings.NewReader(body)), signingKey, testChunkTimestamp, testChunkScope,
+		testChunkSeed, decodedLength)
+}
+
+func buildTestSignedChunks(sizes []int, signatures []string) string {
+	var body strings.Builder
+	for i, size := range sizes {
+		body.WriteString(fmt.Sprintf("%x;chunk-signature=%s\r\n", size, signatures[i]))
+		body.WriteString(strings.Repeat("a", size))
+		body.WriteString("\r\n")
+	}
+	return body.String()
+}
+
+func TestSignedChunkReader(t *testing.T) {
+	body := buildTestSignedChunks([]int{65536, 1024, 0}, testChunkSignatures)
+	data, err := ioutil.ReadAll(newTestSignedChunkReader(body, 66560))
+	if err != nil {
+		t.Fatalf("read signed chunks: %v", err)
+	}
+	if !bytes.Equal(data, bytes.Repeat([]byte("a"), 66560)) {
+		t.Fatalf("data mismatch: length(%v)", len(data))
+	}
+}
+
+func TestSignedChunkReader_Invalid(t *testing.T) {
+	tampered := buildTestSignedChunks([]int{65536, 1024, 0}, testChunkSignatures)
+	tampered = strings.Replace(tampered, "aaaa", "aaab", 1)
+	cases := []struct {
+		body          string
+		decodedLength int64
+		err           error
+	}{
+		{tampered, -1, errChunkSignatureMismatch},
+		// the chunks can not be reordered or dropped
+		{buildTestSignedChunks([]int{1024, 0}, testChunkSignatures[1:]), -1, errChunkSignatureMismatch},
+		{buildTestSignedChunks([]int{65536, 1024}, testChunkSignatures[:2]), -1, io.ErrUnexpectedEOF},
+		{buildTestSignedChunks([]int{65536, 1024, 0}, testChunkSignatures), 1024, errChunkLengthMismatch},
+		{buildTestSignedChunks([]int{65536, 1024, 0}, testChunkSignatures), 70000, errChunkLengthMismatch},
+		{"400\r\naaaa\r\n", -1, errChunkMalformed},
+	}
+	for i, c := range cases {
+		_, err := ioutil.ReadAll(newTestSignedChunkReader(c.body, c.decodedLength))
+		if err != c.err {
+			t.Fatalf("case(%v): err(%v), expect(%v)", i, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// https://tools.ietf.org/html/rfc7232
+//
+// The conditional headers are evaluated against the ETag and the modify time of the object in the
+// order of RFC 7232 section 6, which is also the one of Amazon S3: If-Match, If-Unmodified-Since,
+// If-None-Match and If-Modified-Since. The date conditions are ignored if the ETag conditions are
+// present, and the invalid dates are ignored.
+
+// conditionHeaders are the names of the conditional headers, of the object itself or of the source
+// object of the copy.
+type conditionHeaders struct {
+	ifMatch           string
+	ifUnmodifiedSince string
+	ifNoneMatch       string
+	ifModifiedSince   string
+}
+
+var (
+	objectConditionHeaders = conditionHeaders{
+		ifMatch:           HeaderNameIfMatch,
+		ifUnmodifiedSince: HeaderNameIfUnmodifiedSince,
+		ifNoneMatch:       HeaderNameIfNoneMatch,
+		ifModifiedSince:   HeaderNameIfModifiedSince,
+	}
+	copySourceConditionHeaders = conditionHeaders{
+		ifMatch:           HeaderNameCopyMatch,
+		ifUnmodifiedSince: HeaderNameCopyUnModified,
+		ifNoneMatch:       HeaderNameCopyNoneMatch,
+		ifModifiedSince:   HeaderNameCopyModified,
+	}
+)
+
+type conditionResult int
+
+const (
+	conditionPassed conditionResult = iota
+	conditionNotModified
+	conditionFailed
+)
+
+// present returns whether any of the conditional headers is present.
+func (h conditionHeaders) present(r *http.Request) bool {
+	for _, name := range []string{h.ifMatch, h.ifUnmodifiedSince, h.ifNoneMatch, h.ifModifiedSince} {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// check evaluates the conditional headers against the object, which is nil if it does not exist.
+// A false If-None-Match or If-Modified-Since results in conditionNotModified, which is served as
+// 304 for GET and HEAD, or as 412 for the writes.
+func (h conditionHeaders) check(r *http.Request, fileInfo *FSFileInfo) conditionResult {
+	var etag string
+	var modifyTime time.Time
+	if fileInfo != nil {
+		etag = fileInfo.ETag
+		// the dates of HTTP are in seconds
+		modifyTime = fileInfo.ModifyTime.Truncate(time.Second)
+	}
+
+	if ifMatch := r.Header.Get(h.ifMatch); ifMatch != "" {
+		if fileInfo == nil || !etagListMatches(ifMatch, etag, false) {
+			return conditionFailed
+		}
+	} else if t, ok := parseConditionTime(r.Header.Get(h.ifUnmodifiedSince)); ok && fileInfo != nil {
+		if modifyTime.After(t) {
+			return conditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get(h.ifNoneMatch); ifNoneMatch != "" {
+		if fileInfo != nil && etagListMatches(ifNoneMatch, etag, true) {
+			return conditionNotModified
+		}
+	} else if t, ok := parseConditionTime(r.Header.Get(h.ifModifiedSince)); ok && fileInfo != nil {
+		if !modifyTime.After(t) {
+			return conditionNotModified
+		}
+	}
+	return conditionPassed
+}
+
+func parseConditionTime(value string) (t time.Time, ok bool) {
+	if value == "" {
+		return
+	}
+	var err error
+	if t, err = parseTimeRFC1123(value); err != nil {
+		return
+	}
+	return t, true
+}
+
+// etagListMatches checks whether the ETag matches any one of the list like '"a", "b"', or '*'
+// matching any existing object. The weak ETags match only by the weak comparison.
+func etagListMatches(list, etag string, weak bool) bool {
+	etag = strings.Trim(etag, "\"")
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" {
+			return true
+		}
+		if strings.HasPrefix(item, "W/") {
+			if !weak {
+				continue
+			}
+			item = item[len("W/"):]
+		}
+		if strings.Trim(item, "\"") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serveNotModified serves the response 304 without the body.
+func serveNotModified(w http.ResponseWriter, fileInfo *FSFileInfo) {
+	w.Header().Set(HeaderNameETag, fileInfo.ETag)
+	w.Header().Set(HeaderNameLastModified, formatTimeRFC1123(fileInfo.ModifyTime))
+	w.WriteHeader(http.StatusNotModified)
+}
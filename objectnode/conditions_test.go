@@ -0,0 +1,84 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditionHeaders_Check(t *testing.T) {
+	modifyTime := time.Date(2020, 1, 2, 3, 4, 5, 500, time.UTC)
+	before := formatTimeRFC1123(modifyTime.Add(-time.Hour))
+	same := formatTimeRFC1123(modifyTime)
+	after := formatTimeRFC1123(modifyTime.Add(time.Hour))
+	fileInfo := &FSFileInfo{ETag: "abc", ModifyTime: modifyTime}
+
+	cases := []struct {
+		headers  map[string]string
+		fileInfo *FSFileInfo
+		result   conditionResult
+	}{
+		{map[string]string{}, fileInfo, conditionPassed},
+		{map[string]string{HeaderNameIfMatch: `"abc"`}, fileInfo, conditionPassed},
+		{map[string]string{HeaderNameIfMatch: `"x", "abc"`}, fileInfo, conditionPassed},
+		{map[string]string{HeaderNameIfMatch: `W/"abc"`}, fileInfo, conditionFailed},
+		{map[string]string{HeaderNameIfMatch: `"x"`}, fileInfo, conditionFailed},
+		{map[string]string{HeaderNameIfMatch: "*"}, nil, conditionFailed},
+		{map[string]string{HeaderNameIfUnmodifiedSince: before}, fileInfo, conditionFailed},
+		{map[string]string{HeaderNameIfUnmodifiedSince: same}, fileInfo, conditionPassed},
+		{map[string]string{HeaderNameIfUnmodifiedSince: "invalid"}, fileInfo, conditionPassed},
+		// If-Match takes precedence over If-Unmodified-Since
+		{map[string]string{HeaderNameIfMatch: `"abc"`, HeaderNameIfUnmodifiedSince: before}, fileInfo, conditionPassed},
+		{map[string]string{HeaderNameIfNoneMatch: `W/"abc"`}, fileInfo, conditionNotModified},
+		{map[string]string{HeaderNameIfNoneMatch: `"x"`}, fileInfo, conditionPassed},
+		{map[string]string{HeaderNameIfNoneMatch: "*"}, fileInfo, conditionNotModified},
+		{map[string]string{HeaderNameIfNoneMatch: "*"}, nil, conditionPassed},
+		{map[string]string{HeaderNameIfModifiedSince: same}, fileInfo, conditionNotModified},
+		{map[string]string{HeaderNameIfModifiedSince: after}, fileInfo, conditionNotModified},
+		{map[string]string{HeaderNameIfModifiedSince: before}, fileInfo, conditionPassed},
+		// If-None-Match takes precedence over If-Modified-Since
+		{map[string]string{HeaderNameIfNoneMatch: `"x"`, HeaderNameIfModifiedSince: after}, fileInfo, conditionPassed},
+		// the failed preconditions take precedence over the not modified ones
+		{map[string]string{HeaderNameIfMatch: `"x"`, HeaderNameIfNoneMatch: `"abc"`}, fileInfo, conditionFailed},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest("GET", "/bucket/key", nil)
+		for name, value := range c.headers {
+			r.Header.Set(name, value)
+		}
+		if result := objectConditionHeaders.check(r, c.fileInfo); result != c.result {
+			t.Fatalf("case(%v) headers(%v): result(%v), expect(%v)", i, c.headers, result, c.result)
+		}
+		if present := objectConditionHeaders.present(r); present != (len(c.headers) > 0) {
+			t.Fatalf("case(%v) headers(%v): present(%v)", i, c.headers, present)
+		}
+	}
+}
+
+func TestConditionHeaders_CopySource(t *testing.T) {
+	fileInfo := &FSFileInfo{ETag: "abc", ModifyTime: time.Now()}
+	r := httptest.NewRequest("PUT", "/bucket/key", nil)
+	r.Header.Set(HeaderNameIfMatch, `"x"`)
+	r.Header.Set(HeaderNameCopyMatch, `"abc"`)
+	if result := copySourceConditionHeaders.check(r, fileInfo); result != conditionPassed {
+		t.Fatalf("result(%v), expect passed", result)
+	}
+	r.Header.Set(HeaderNameCopyNoneMatch, `"abc"`)
+	if result := copySourceConditionHeaders.check(r, fileInfo); result != conditionNotModified {
+		t.Fatalf("result(%v), expect not modified", result)
+	}
+}
@@ -30,6 +30,12 @@ const (
 	HeaderNameAuthorization = "Authorization"
 	HeaderNameAcceptRange   = "Accept-Ranges"
 	HeaderNameRange         = "Range"
+	HeaderNameIfRange       = "If-Range"
+
+	HeaderNameIfMatch           = "If-Match"
+	HeaderNameIfNoneMatch       = "If-None-Match"
+	HeaderNameIfModifiedSince   = "If-Modified-Since"
+	HeaderNameIfUnmodifiedSince = "If-Unmodified-Since"
 
 	HeaderNameStartDate           = "x-amz-date"
 	HeaderNameRequestId           = "x-amz-request-id"
@@ -40,13 +46,38 @@ const (
 	HeaderNameCopyModified        = "x-amz-copy-source-if-modified-since"
 	HeaderNameCopyUnModified      = "x-amz-copy-source-if-unmodified-since"
 	HeaderNameDecodeContentLength = "X-Amz-Decoded-Content-Length"
+	HeaderNameVersionId           = "x-amz-version-id"
+	HeaderNameDeleteMarker        = "x-amz-delete-marker"
+	HeaderNameSSE                 = "x-amz-server-side-encryption"
+	HeaderNameTagging             = "x-amz-tagging"
+	HeaderNameTaggingCount        = "x-amz-tagging-count"
+	HeaderNameTaggingDirective    = "x-amz-tagging-directive"
+	HeaderNameForwardedProto      = "X-Forwarded-Proto"
+	HeaderNameACL                 = "x-amz-acl"
+
+	HeaderNameObjectLockMode            = "x-amz-object-lock-mode"
+	HeaderNameObjectLockRetainUntilDate = "x-amz-object-lock-retain-until-date"
+	HeaderNameObjectLockLegalHold       = "x-amz-object-lock-legal-hold"
+	HeaderNameBypassGovernanceRetention = "x-amz-bypass-governance-retention"
+
+	HeaderNameOrigin                        = "Origin"
+	HeaderNameVary                          = "Vary"
+	HeaderNameAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderNameAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderNameAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderNameAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderNameAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderNameAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderNameAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderNameAccessControlMaxAge           = "Access-Control-Max-Age"
 )
 
 const (
-	HeaderValueServer         = "ChubaoFS"
-	HeaderValueAcceptRange    = "bytes"
-	HeaderValueTypeStream     = "application/octet-stream"
-	HeaderValueContentTypeXML = "application/xml"
+	HeaderValueServer          = "ChubaoFS"
+	HeaderValueAcceptRange     = "bytes"
+	HeaderValueTypeStream      = "application/octet-stream"
+	HeaderValueContentTypeXML  = "application/xml"
+	HeaderValueContentTypeJSON = "application/json"
 )
 
 const (
@@ -70,6 +101,10 @@ const (
 	ParamPartNoMarker   = "part-number-marker"
 	ParamPartMaxUploads = "max-uploads"
 	ParamPartDelimiter  = "delimiter"
+
+	ParamTag             = "tag" // ChubaoFS owned, filters the objects listed by a tag
+	ParamVersionId       = "versionId"
+	ParamVersionIdMarker = "version-id-marker"
 )
 
 const (
@@ -86,7 +121,21 @@ const (
 const (
 	XAttrKeyOSSETag    = "oss:etag"
 	XAttrKeyOSSTagging = "oss:tg"
-	XAttrKeyOSSPolicy  = "oss:ply"
+
+	XAttrKeyOSSVersioning   = "oss:ver"
+	XAttrKeyOSSDeleteMarker = "oss:dm"
+
+	XAttrKeyOSSSSE = "oss:sse"
+
+	XAttrKeyOSSWebsite = "oss:web"
+	XAttrKeyOSSCORS    = "oss:cors"
+
+	XAttrKeyOSSObjectLock = "oss:lock"
+	XAttrKeyOSSRetention  = "oss:ret"
+	XAttrKeyOSSLegalHold  = "oss:lh"
+
+	XAttrKeyOSSLogging      = "oss:log"
+	XAttrKeyOSSNotification = "oss:ntf"
 )
 
 const (
@@ -0,0 +1,215 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/cors.html
+
+const (
+	BucketCORSLimitSize = 64 * 1024
+	MaxCORSRules        = 100
+)
+
+var (
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPut, http.MethodHead, http.MethodPost, http.MethodDelete}
+)
+
+type CORSConfiguration struct {
+	XMLName   xml.Name    `xml:"CORSConfiguration"`
+	CORSRules []*CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// Validate checks each rule has the origins and the methods, and an origin or an allowed header
+// has at most one wildcard.
+func (c *CORSConfiguration) Validate() *ErrorCode {
+	if len(c.CORSRules) == 0 || len(c.CORSRules) > MaxCORSRules {
+		return &MalformedXML
+	}
+	for _, rule := range c.CORSRules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 || rule.MaxAgeSeconds < 0 {
+			return &MalformedXML
+		}
+		for _, method := range rule.AllowedMethods {
+			if !contains(corsAllowedMethods, method) {
+				return &InvalidArgument
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if origin == "" || strings.Count(origin, "*") > 1 {
+				return &InvalidArgument
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return &InvalidArgument
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule allowing the origin, the method and the request headers.
+func (c *CORSConfiguration) Match(origin, method string, headers []string) *CORSRule {
+	for _, rule := range c.CORSRules {
+		if rule.match(origin, method, headers) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (rule *CORSRule) match(origin, method string, headers []string) bool {
+	if !contains(rule.AllowedMethods, method) {
+		return false
+	}
+	var originAllowed bool
+	for _, allowed := range rule.AllowedOrigins {
+		if wildcardMatch(allowed, origin) {
+			originAllowed = true
+			break
+		}
+	}
+	if !originAllowed {
+		return false
+	}
+	// the headers are case insensitive
+	for _, header := range headers {
+		var headerAllowed bool
+		for _, allowed := range rule.AllowedHeaders {
+			if wildcardMatch(strings.ToLower(allowed), strings.ToLower(header)) {
+				headerAllowed = true
+				break
+			}
+		}
+		if !headerAllowed {
+			return false
+		}
+	}
+	return true
+}
+
+// setResponseHeaders sets the CORS headers of the response to the request of the origin allowed
+// by the rule, and the allowed headers and the max age if it is a preflight request.
+func (rule *CORSRule) setResponseHeaders(w http.ResponseWriter, origin string, headers []string, preflight bool) {
+	if contains(rule.AllowedOrigins, "*") {
+		w.Header().Set(HeaderNameAccessControlAllowOrigin, "*")
+	} else {
+		w.Header().Set(HeaderNameAccessControlAllowOrigin, origin)
+		w.Header().Set(HeaderNameAccessControlAllowCredentials, "true")
+	}
+	w.Header().Set(HeaderNameAccessControlAllowMethods, strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		w.Header().Set(HeaderNameAccessControlExposeHeaders, strings.Join(rule.ExposeHeaders, ", "))
+	}
+	if preflight {
+		if len(headers) > 0 {
+			w.Header().Set(HeaderNameAccessControlAllowHeaders, strings.Join(headers, ", "))
+		}
+		if rule.MaxAgeSeconds > 0 {
+			w.Header().Set(HeaderNameAccessControlMaxAge, strconv.Itoa(rule.MaxAgeSeconds))
+		}
+	}
+	w.Header().Add(HeaderNameVary, HeaderNameOrigin)
+	w.Header().Add(HeaderNameVary, HeaderNameAccessControlRequestHeaders)
+	w.Header().Add(HeaderNameVary, HeaderNameAccessControlRequestMethod)
+}
+
+// parseCORSRequestHeaders returns the headers of the header Access-Control-Request-Headers.
+func parseCORSRequestHeaders(r *http.Request) (headers []string) {
+	for _, value := range r.Header[http.CanonicalHeaderKey(HeaderNameAccessControlRequestHeaders)] {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, header)
+			}
+		}
+	}
+	return
+}
+
+func (v *volume) loadCORS() (c *CORSConfiguration) {
+	v.om.corsLock.RLock()
+	c = v.om.cors
+	v.om.corsLock.RUnlock()
+	return
+}
+
+func (v *volume) storeCORS(c *CORSConfiguration) {
+	v.om.corsLock.Lock()
+	v.om.cors = c
+	v.om.corsLock.Unlock()
+	return
+}
+
+// loadBucketCORS loads the CORS configuration of the volume, which is nil if the volume has none.
+func (v *volume) loadBucketCORS() (c *CORSConfiguration, err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSCORS); err != nil {
+		log.LogErrorf("loadBucketCORS: load bucket cors fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	c = &CORSConfiguration{}
+	if err = xml.Unmarshal(data, c); err != nil {
+		log.LogErrorf("loadBucketCORS: unmarshal bucket cors fail: volume(%v) err(%v)", v.name, err)
+		return nil, err
+	}
+	return
+}
+
+// SetCORS stores the CORS configuration of the volume, or deletes it if the configuration is nil.
+// The change is loaded by the other object nodes in OSSMetaUpdateDuration.
+func (v *volume) SetCORS(c *CORSConfiguration) (err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	if c == nil {
+		err = store.Delete(v.name, bucketRootPath, XAttrKeyOSSCORS)
+	} else {
+		var data []byte
+		if data, err = xml.Marshal(c); err != nil {
+			return
+		}
+		err = store.Put(v.name, bucketRootPath, XAttrKeyOSSCORS, data)
+	}
+	if err != nil {
+		return
+	}
+	v.storeCORS(c)
+	return
+}
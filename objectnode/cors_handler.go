@@ -0,0 +1,156 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Get bucket cors
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
+func (o *ObjectNode) getBucketCORSHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketCORSHandler: get bucket cors: requestID(%v)", RequestIDFromRequest(r))
+	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketCORSHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	config := vl.loadCORS()
+	if config == nil {
+		_ = NoSuchCORSConfiguration.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(config); err != nil {
+		log.LogErrorf("getBucketCORSHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketCORSHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put bucket cors
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+func (o *ObjectNode) putBucketCORSHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketCORSHandler: put bucket cors: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketCORSHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if r.ContentLength > BucketCORSLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketCORSLimitSize+1)); err != nil {
+		log.LogErrorf("putBucketCORSHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	if len(body) > BucketCORSLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+	var config = &CORSConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		log.LogWarnf("putBucketCORSHandler: unmarshal cors fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if ec := config.Validate(); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+
+	if err = vl.SetCORS(config); err != nil {
+		log.LogErrorf("putBucketCORSHandler: set cors fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketCORSHandler: bucket cors set: requestID(%v) bucket(%v) rules(%v)",
+		RequestIDFromRequest(r), bucket, len(config.CORSRules))
+	return
+}
+
+// Delete bucket cors
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
+func (o *ObjectNode) deleteBucketCORSHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("deleteBucketCORSHandler: delete bucket cors: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("deleteBucketCORSHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if vl.loadCORS() != nil {
+		if err = vl.SetCORS(nil); err != nil {
+			log.LogErrorf("deleteBucketCORSHandler: delete cors fail: requestID(%v) bucket(%v) err(%v)",
+				RequestIDFromRequest(r), bucket, err)
+			_ = InternalError.ServeResponse(w, r)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// Answer the preflight request of the browser, which is not signed, by the CORS configuration of
+// the bucket.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTOPTIONSobject.html
+func (o *ObjectNode) corsPreflightHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("corsPreflightHandler: cors preflight: requestID(%v) remote(%v)", RequestIDFromRequest(r), r.RemoteAddr)
+	origin := r.Header.Get(HeaderNameOrigin)
+	method := r.Header.Get(HeaderNameAccessControlRequestMethod)
+	if origin == "" || method == "" {
+		_ = CORSBadRequest.ServeResponse(w, r)
+		return
+	}
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil || vl == nil {
+		log.LogErrorf("corsPreflightHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	config := vl.loadCORS()
+	if config == nil {
+		_ = CORSForbidden.ServeResponse(w, r)
+		return
+	}
+	headers := parseCORSRequestHeaders(r)
+	rule := config.Match(origin, method, headers)
+	if rule == nil {
+		log.LogDebugf("corsPreflightHandler: no rule matched: requestID(%v) bucket(%v) origin(%v) method(%v) headers(%v)",
+			RequestIDFromRequest(r), bucket, origin, method, headers)
+		_ = CORSForbidden.ServeResponse(w, r)
+		return
+	}
+	rule.setResponseHeaders(w, origin, headers, true)
+	w.WriteHeader(http.StatusOK)
+	return
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testCORSConfiguration = `<CORSConfiguration>
+	<CORSRule>
+		<AllowedOrigin>https://*.example.com</AllowedOrigin>
+		<AllowedMethod>PUT</AllowedMethod>
+		<AllowedMethod>POST</AllowedMethod>
+		<AllowedHeader>x-amz-*</AllowedHeader>
+		<AllowedHeader>Content-Type</AllowedHeader>
+		<ExposeHeader>ETag</ExposeHeader>
+		<MaxAgeSeconds>3000</MaxAgeSeconds>
+	</CORSRule>
+	<CORSRule>
+		<AllowedOrigin>*</AllowedOrigin>
+		<AllowedMethod>GET</AllowedMethod>
+	</CORSRule>
+</CORSConfiguration>`
+
+func TestCORSConfiguration_Validate(t *testing.T) {
+	cases := []struct {
+		body  string
+		valid bool
+	}{
+		{testCORSConfiguration, true},
+		{`<CORSConfiguration></CORSConfiguration>`, false},
+		{`<CORSConfiguration><CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`, false},
+		{`<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`, false},
+		{`<CORSConfiguration><CORSRule><AllowedOrigin>http://*.*.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`, false},
+	}
+	for i, c := range cases {
+		config := &CORSConfiguration{}
+		if err := UnmarshalXMLEntity([]byte(c.body), config); err != nil {
+			t.Fatalf("case(%v): unmarshal: %v", i, err)
+		}
+		if ec := config.Validate(); (ec == nil) != c.valid {
+			t.Fatalf("case(%v): error(%v), expect valid(%v)", i, ec, c.valid)
+		}
+	}
+}
+
+func TestCORSConfiguration_Match(t *testing.T) {
+	config := &CORSConfiguration{}
+	if err := UnmarshalXMLEntity([]byte(testCORSConfiguration), config); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	cases := []struct {
+		origin, method string
+		headers        []string
+		rule           int
+	}{
+		{"https://www.example.com", http.MethodPut, []string{"X-Amz-Date", "content-type"}, 0},
+		{"https://www.example.com", http.MethodPut, []string{"Authorization"}, -1},
+		{"http://www.example.com", http.MethodPut, nil, -1},
+		{"http://www.example.org", http.MethodGet, nil, 1},
+		{"http://www.example.org", http.MethodDelete, nil, -1},
+	}
+	for i, c := range cases {
+		rule := config.Match(c.origin, c.method, c.headers)
+		if (c.rule < 0 && rule != nil) || (c.rule >= 0 && rule != config.CORSRules[c.rule]) {
+			t.Fatalf("case(%v): rule(%v), expect rule(%v)", i, rule, c.rule)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	config.CORSRules[0].setResponseHeaders(w, "https://www.example.com", []string{"X-Amz-Date"}, true)
+	expected := map[string]string{
+		HeaderNameAccessControlAllowOrigin:      "https://www.example.com",
+		HeaderNameAccessControlAllowCredentials: "true",
+		HeaderNameAccessControlAllowMethods:     "PUT, POST",
+		HeaderNameAccessControlAllowHeaders:     "X-Amz-Date",
+		HeaderNameAccessControlExposeHeaders:    "ETag",
+		HeaderNameAccessControlMaxAge:           "3000",
+	}
+	for name, value := range expected {
+		if w.Header().Get(name) != value {
+			t.Fatalf("header %v: %v, expect %v", name, w.Header().Get(name), value)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	config.CORSRules[1].setResponseHeaders(w, "http://www.example.org", nil, false)
+	if w.Header().Get(HeaderNameAccessControlAllowOrigin) != "*" || w.Header().Get(HeaderNameAccessControlAllowCredentials) != "" {
+		t.Fatalf("headers of any origin: %v", w.Header())
+	}
+}
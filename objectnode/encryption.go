@@ -0,0 +1,288 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+
+	encryptionKeyRefreshInterval = time.Minute
+	dataKeyLength                = 32
+	sseIVLength                  = 8
+)
+
+var (
+	errInvalidEncryptionKey = errors.New("invalid encryption key")
+	errInvalidSSEMeta       = errors.New("invalid server side encryption meta")
+)
+
+// SSEMeta is the server side encryption of an object, which is kept in the xattr of the object
+// and in the extend of its multipart upload.
+//
+// The data of each part is encrypted by AES-CTR with the data key, whose counter block is the IV
+// with the part ID in the first two bytes and followed by the index of the block in the part, so
+// any range of the object can be decrypted. The data key is encrypted by AES-GCM with the
+// encryption key of the version, which is kept by the master.
+type SSEMeta struct {
+	Algorithm  string `json:"alg"`
+	KeyVersion uint32 `json:"kv"`
+	Key        []byte `json:"key"`
+	IV         []byte `json:"iv"`
+	// Parts are the ID and the size of the parts of the object in order, set on completion.
+	Parts [][2]uint64 `json:"parts,omitempty"`
+}
+
+func parseSSEMeta(raw string) (*SSEMeta, error) {
+	var meta = &SSEMeta{}
+	if err := json.Unmarshal([]byte(raw), meta); err != nil {
+		return nil, err
+	}
+	if len(meta.IV) != sseIVLength {
+		return nil, errInvalidSSEMeta
+	}
+	return meta, nil
+}
+
+// sseAlgorithm returns the algorithm of the encryption kept in the xattr, empty if not encrypted.
+func sseAlgorithm(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if meta, err := parseSSEMeta(raw); err == nil {
+		return meta.Algorithm
+	}
+	return ""
+}
+
+// parseSSEHeader returns the algorithm of the server side encryption requested by the header,
+// empty if not requested. Only the encryption with the keys managed by the object node is supported.
+func parseSSEHeader(r *http.Request) (sse string, ec *ErrorCode) {
+	switch sse = r.Header.Get(HeaderNameSSE); sse {
+	case "", SSEAlgorithmAES256:
+		return sse, nil
+	case SSEAlgorithmKMS:
+		return "", &NotImplemented
+	default:
+		return "", &InvalidArgument
+	}
+}
+
+func (m *SSEMeta) String() string {
+	marshaled, _ := json.Marshal(m)
+	return string(marshaled)
+}
+
+// partStream returns the stream encrypting the data of the part from the offset.
+func (m *SSEMeta) partStream(block cipher.Block, partID uint16, offset uint64) cipher.Stream {
+	var counter = make([]byte, aes.BlockSize)
+	copy(counter, m.IV)
+	counter[0] ^= byte(partID >> 8)
+	counter[1] ^= byte(partID)
+	binary.BigEndian.PutUint64(counter[sseIVLength:], offset/aes.BlockSize)
+	var stream = cipher.NewCTR(block, counter)
+	if skip := offset % aes.BlockSize; skip > 0 {
+		var discard = make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	return stream
+}
+
+// objectStream returns the stream decrypting the data of the object from the offset, which
+// switches the counter at the boundaries of the parts.
+func (m *SSEMeta) objectStream(block cipher.Block, offset uint64) cipher.Stream {
+	var s = &sseObjectStream{meta: m, block: block, index: -1}
+	for i, part := range m.Parts {
+		if offset < part[1] {
+			s.index = i
+			s.rest = part[1] - offset
+			s.stream = m.partStream(block, uint16(part[0]), offset)
+			break
+		}
+		offset -= part[1]
+	}
+	return s
+}
+
+type sseObjectStream struct {
+	meta   *SSEMeta
+	block  cipher.Block
+	index  int    // index of the current part, -1 if beyond the parts
+	rest   uint64 // bytes left in the current part
+	stream cipher.Stream
+}
+
+func (s *sseObjectStream) XORKeyStream(dst, src []byte) {
+	for len(src) > 0 && s.index >= 0 {
+		var n = len(src)
+		if uint64(n) > s.rest {
+			n = int(s.rest)
+		}
+		s.stream.XORKeyStream(dst[:n], src[:n])
+		dst, src = dst[n:], src[n:]
+		if s.rest -= uint64(n); s.rest > 0 {
+			continue
+		}
+		if s.index++; s.index >= len(s.meta.Parts) {
+			s.index = -1
+			break
+		}
+		part := s.meta.Parts[s.index]
+		s.rest = part[1]
+		s.stream = s.meta.partStream(s.block, uint16(part[0]), 0)
+	}
+	copy(dst, src)
+}
+
+// encryptionKeyCache caches the encryption keys from the master. The keys never change, and the
+// latest version is refreshed every minute to follow the rotation.
+type encryptionKeyCache struct {
+	mc         *masterSDK.MasterClient
+	keys       map[uint32][]byte
+	latest     uint32
+	updateTime time.Time
+	mu         sync.RWMutex
+}
+
+func newEncryptionKeyCache(mc *masterSDK.MasterClient) *encryptionKeyCache {
+	return &encryptionKeyCache{mc: mc, keys: make(map[uint32][]byte)}
+}
+
+// latestKey returns the latest encryption key, which encrypts the new data keys.
+func (c *encryptionKeyCache) latestKey() (version uint32, key []byte, err error) {
+	c.mu.RLock()
+	version, key = c.latest, c.keys[c.latest]
+	var expired = time.Since(c.updateTime) > encryptionKeyRefreshInterval
+	c.mu.RUnlock()
+	if key != nil && !expired {
+		return
+	}
+	var encryptionKey *proto.EncryptionKey
+	if encryptionKey, err = c.mc.AdminAPI().GetEncryptionKey(0); err != nil {
+		log.LogErrorf("latestKey: get encryption key from master fail: err(%v)", err)
+		if key != nil {
+			// keep encrypting with the cached one until the master is back
+			return version, key, nil
+		}
+		return
+	}
+	c.mu.Lock()
+	c.keys[encryptionKey.Version] = encryptionKey.Key
+	c.latest = encryptionKey.Version
+	c.updateTime = time.Now()
+	c.mu.Unlock()
+	return encryptionKey.Version, encryptionKey.Key, nil
+}
+
+// key returns the encryption key of the version.
+func (c *encryptionKeyCache) key(version uint32) (key []byte, err error) {
+	c.mu.RLock()
+	key = c.keys[version]
+	c.mu.RUnlock()
+	if key != nil {
+		return
+	}
+	var encryptionKey *proto.EncryptionKey
+	if encryptionKey, err = c.mc.AdminAPI().GetEncryptionKey(version); err != nil {
+		log.LogErrorf("key: get encryption key from master fail: version(%v) err(%v)", version, err)
+		return
+	}
+	c.mu.Lock()
+	c.keys[version] = encryptionKey.Key
+	c.mu.Unlock()
+	return encryptionKey.Key, nil
+}
+
+// newSSEMeta generates the data key of a new object, encrypted by the latest encryption key.
+func (c *encryptionKeyCache) newSSEMeta() (meta *SSEMeta, err error) {
+	var version uint32
+	var key []byte
+	if version, key, err = c.latestKey(); err != nil {
+		return
+	}
+	var dataKey = make([]byte, dataKeyLength)
+	meta = &SSEMeta{Algorithm: SSEAlgorithmAES256, KeyVersion: version, IV: make([]byte, sseIVLength)}
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if _, err = rand.Read(meta.IV); err != nil {
+		return nil, err
+	}
+	if meta.Key, err = sealDataKey(key, dataKey); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// dataKeyBlock returns the cipher of the data key of the object.
+func (c *encryptionKeyCache) dataKeyBlock(meta *SSEMeta) (block cipher.Block, err error) {
+	var key, dataKey []byte
+	if key, err = c.key(meta.KeyVersion); err != nil {
+		return
+	}
+	if dataKey, err = openDataKey(key, meta.Key); err != nil {
+		return
+	}
+	return aes.NewCipher(dataKey)
+}
+
+func sealDataKey(key, dataKey []byte) (sealed []byte, err error) {
+	var gcm cipher.AEAD
+	if gcm, err = newGCM(key); err != nil {
+		return
+	}
+	var nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	return gcm.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func openDataKey(key, sealed []byte) (dataKey []byte, err error) {
+	var gcm cipher.AEAD
+	if gcm, err = newGCM(key); err != nil {
+		return
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errInvalidEncryptionKey
+	}
+	if dataKey, err = gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil); err != nil {
+		return nil, errInvalidEncryptionKey
+	}
+	return
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"net/http"
+	"testing"
+)
+
+func TestSSEObjectStream(t *testing.T) {
+	key := make([]byte, dataKeyLength)
+	meta := &SSEMeta{Algorithm: SSEAlgorithmAES256, IV: make([]byte, sseIVLength)}
+	_, _ = rand.Read(key)
+	_, _ = rand.Read(meta.IV)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+
+	// encrypt the parts separately as they are uploaded
+	sizes := map[uint16]int{1: 100, 3: 37, 4: 64}
+	var plain, encrypted []byte
+	for _, id := range []uint16{1, 3, 4} {
+		part := make([]byte, sizes[id])
+		_, _ = rand.Read(part)
+		sealed := make([]byte, len(part))
+		stream := meta.partStream(block, id, 0)
+		// encrypt in pieces not aligned to the blocks
+		stream.XORKeyStream(sealed[:5], part[:5])
+		stream.XORKeyStream(sealed[5:], part[5:])
+		plain = append(plain, part...)
+		encrypted = append(encrypted, sealed...)
+		meta.Parts = append(meta.Parts, [2]uint64{uint64(id), uint64(len(part))})
+	}
+	if bytes.Equal(plain, encrypted) {
+		t.Fatalf("data not encrypted")
+	}
+
+	// decrypt any range of the object
+	for _, r := range [][2]int{{0, len(plain)}, {17, 120}, {99, 101}, {100, 137}, {136, len(plain)}, {150, 151}} {
+		decrypted := make([]byte, r[1]-r[0])
+		copy(decrypted, encrypted[r[0]:r[1]])
+		stream := meta.objectStream(block, uint64(r[0]))
+		for i := 0; i < len(decrypted); i += 7 {
+			end := i + 7
+			if end > len(decrypted) {
+				end = len(decrypted)
+			}
+			stream.XORKeyStream(decrypted[i:end], decrypted[i:end])
+		}
+		if !bytes.Equal(decrypted, plain[r[0]:r[1]]) {
+			t.Fatalf("range %v decrypted mismatch", r)
+		}
+	}
+}
+
+func TestSealDataKey(t *testing.T) {
+	key := make([]byte, 32)
+	dataKey := make([]byte, dataKeyLength)
+	_, _ = rand.Read(key)
+	_, _ = rand.Read(dataKey)
+	sealed, err := sealDataKey(key, dataKey)
+	if err != nil {
+		t.Fatalf("seal data key: %v", err)
+	}
+	opened, err := openDataKey(key, sealed)
+	if err != nil || !bytes.Equal(opened, dataKey) {
+		t.Fatalf("open data key: %v", err)
+	}
+	key[0] ^= 1
+	if _, err = openDataKey(key, sealed); err != errInvalidEncryptionKey {
+		t.Fatalf("open data key with another key: %v", err)
+	}
+}
+
+func TestParseSSEMeta(t *testing.T) {
+	meta := &SSEMeta{Algorithm: SSEAlgorithmAES256, KeyVersion: 2, Key: []byte("key"), IV: make([]byte, sseIVLength),
+		Parts: [][2]uint64{{1, 10}}}
+	parsed, err := parseSSEMeta(meta.String())
+	if err != nil || parsed.KeyVersion != 2 || len(parsed.Parts) != 1 || parsed.Parts[0][1] != 10 {
+		t.Fatalf("parse meta: %+v %v", parsed, err)
+	}
+	if sseAlgorithm(meta.String()) != SSEAlgorithmAES256 || sseAlgorithm("") != "" || sseAlgorithm("{}") != "" {
+		t.Fatalf("algorithm of the meta mismatch")
+	}
+}
+
+func TestParseSSEHeader(t *testing.T) {
+	for value, expect := range map[string]string{"": "", SSEAlgorithmAES256: SSEAlgorithmAES256} {
+		r, _ := http.NewRequest(http.MethodPut, "/bucket/object", nil)
+		r.Header.Set(HeaderNameSSE, value)
+		if sse, ec := parseSSEHeader(r); ec != nil || sse != expect {
+			t.Fatalf("header %q: sse(%v) error(%v)", value, sse, ec)
+		}
+	}
+	for value, expect := range map[string]ErrorCode{SSEAlgorithmKMS: NotImplemented, "DES": InvalidArgument} {
+		r, _ := http.NewRequest(http.MethodPut, "/bucket/object", nil)
+		r.Header.Set(HeaderNameSSE, value)
+		if _, ec := parseSSEHeader(r); ec == nil || ec.ErrorCode != expect.ErrorCode {
+			t.Fatalf("header %q: error(%v)", value, ec)
+		}
+	}
+}
@@ -38,6 +38,32 @@ type FSFileInfo struct {
 	ModifyTime time.Time
 	ETag       string
 	Inode      uint64
+	SSE        string // algorithm of the server side encryption, empty if not encrypted
+	Tagging    string // tags encoded as the URL query parameters, empty if not tagged
+	Retention  string // retention mode and date of the object lock, empty if not retained
+	LegalHold  string // legal hold status of the object lock, empty if never set
+}
+
+// FileRange is a range of the data of a file to read.
+type FileRange struct {
+	Offset uint64
+	Size   uint64
+}
+
+// PutFileOption is the option of the files written by the multipart uploads and the copies.
+type PutFileOption struct {
+	SSE       string   // algorithm of the server side encryption, empty if not encrypted
+	Tagging   *Tagging // tags of the file, nil if not tagged
+	Retention string   // retention of the object lock encoded by ObjectRetention, empty if not retained
+	LegalHold string   // legal hold status of the object lock, empty if not set
+}
+
+// FSVersion is a version of the file, the current one or a non-current one kept by the versioning.
+type FSVersion struct {
+	FSFileInfo
+	VersionID    string
+	IsLatest     bool
+	DeleteMarker bool
 }
 
 type Prefixes []string
@@ -73,6 +99,8 @@ type FSPart struct {
 
 type Volume interface {
 	OSSSecure() (accessKey, secretKey string)
+	// VolAccessKey returns the unexpired access key of the volume besides the one of the owner.
+	VolAccessKey(accessKey string) *proto.VolAccessKey
 	OSSMeta() *OSSMeta
 
 	// ListFiles return an FileInfo slice of specified volume, like read dir for hole volume.
@@ -85,21 +113,33 @@ type Volume interface {
 	WriteFile(path string, reader io.Reader) (*FSFileInfo, error)
 
 	// DeleteFile delete specified file from specified volume. If target is not exists then returns error.
-	DeleteFile(path string) error
+	// If the versioning of the volume is enabled, the file is kept as a non-current version, and the
+	// version ID of the delete marker is returned.
+	DeleteFile(path string) (deleteMarker string, err error)
 
 	FileInfo(path string) (*FSFileInfo, error)
 
-	// operation about multipart uploads
-	InitMultipart(path string) (multipartID string, err error)
+	// operation about multipart uploads, whose files are encrypted and tagged by the option
+	InitMultipart(path string, opt *PutFileOption) (multipartID string, err error)
 	WritePart(path, multipartID string, partId uint16, reader io.Reader) (*FSFileInfo, error)
 	ListParts(path, multipartID string, maxParts, partNumberMarker uint64) ([]*FSPart, uint64, bool, error)
-	CompleteMultipart(path, multipartID string) (*FSFileInfo, error)
+	// CompleteMultipart completes the upload by the parts in order, all the parts uploaded if nil.
+	CompleteMultipart(path, multipartID string, parts []*FSPart) (*FSFileInfo, error)
 	AbortMultipart(path, multipartID string) error
 	ListMultipartUploads(prefix, delimiter, keyMarker, uploadIdMarker string, maxUploads uint64) ([]*FSUpload, string, string, bool, []string, error)
 
 	ReadFile(path string, writer io.Writer, offset, size uint64) error
+	// ReadFileRanges reads the ranges of the file in order, and writes each range to the writer
+	// returned by next for it.
+	ReadFileRanges(path string, ranges []FileRange, next func(i int) (io.Writer, error)) error
 
 	CopyFile(path, sourcePath string) (*FSFileInfo, error)
+	// CopyFileData copies the data of the source file to a new file with the option, which does
+	// not share the inode and the xattrs with the source as CopyFile does.
+	CopyFileData(path, sourcePath string, opt *PutFileOption) (*FSFileInfo, error)
+	// CopyFileFrom copies the file of another volume by the data nodes, with the tags of the
+	// source unless they are replaced by the option.
+	CopyFileFrom(source *volume, path, sourcePath string, opt *PutFileOption) (*FSFileInfo, error)
 
 	SetXAttr(path string, key string, data []byte) error
 	GetXAttr(path string, key string) (*proto.XAttrInfo, error)
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"sync"
 
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
@@ -26,6 +27,8 @@ type volumeManager struct {
 	volumes   map[string]*volume // volume key -> vol
 	volMu     sync.RWMutex
 	store     Store
+	mc        *masterSDK.MasterClient
+	keys      *encryptionKeyCache
 	closeOnce sync.Once
 }
 
@@ -95,9 +98,12 @@ func (m *volumeManager) GetStore() (Store, error) {
 }
 
 func NewVolumeManager(masters []string) VolumeManager {
+	mc := masterSDK.NewMasterClient(masters, false)
 	vc := &volumeManager{
 		volumes: make(map[string]*volume),
 		masters: masters,
+		mc:      mc,
+		keys:    newEncryptionKeyCache(mc),
 	}
 	return vc
 }
@@ -93,8 +93,11 @@ func (s *xattrStore) Get(vol, path, key string) (val []byte, err error) {
 }
 
 func (s *xattrStore) Delete(vol, obj, key string) (err error) {
-
-	return
+	var v *volume
+	if v, err = s.vm.loadVolume(vol); err != nil {
+		return
+	}
+	return v.DeleteXAttr(obj, key)
 }
 
 func (s *xattrStore) List(vol, obj string) (data [][]byte, err error) {
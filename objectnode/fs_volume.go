@@ -1,10 +1,10 @@
 package objectnode
 
 import (
+	"crypto/cipher"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
-	"hash"
 	"io"
 	"os"
 	"sort"
@@ -33,10 +33,23 @@ const (
 var _ Volume = &volume{}
 
 type OSSMeta struct {
-	policy     *Policy
-	acl        *AccessControlPolicy
-	policyLock sync.RWMutex
-	aclLock    sync.RWMutex
+	policy         *Policy
+	acl            *AccessControlPolicy
+	versioning     string
+	website        *WebsiteConfiguration
+	cors           *CORSConfiguration
+	objectLock     *ObjectLockConfiguration
+	logging        *LoggingEnabled
+	notification   *NotificationConfiguration
+	policyLock     sync.RWMutex
+	aclLock        sync.RWMutex
+	versioningLock sync.RWMutex
+	websiteLock    sync.RWMutex
+	corsLock       sync.RWMutex
+	objectLockLock sync.RWMutex
+	loggingLock    sync.RWMutex
+
+	notificationLock sync.RWMutex
 }
 
 func (v *volume) loadPolicy() (p *Policy) {
@@ -107,8 +120,8 @@ func (v *volume) stopOSSMetaSync() {
 
 // update volume meta info
 func (v *volume) loadOSSMeta() {
-	policy, _ := v.loadBucketPolicy()
-	if policy != nil {
+	// the policy deleted by another object node is removed
+	if policy, err := v.loadBucketPolicy(); err == nil {
 		v.storePolicy(policy)
 	}
 
@@ -116,23 +129,46 @@ func (v *volume) loadOSSMeta() {
 	if acl != nil {
 		v.storeACL(acl)
 	}
+
+	versioning, _ := v.loadBucketVersioning()
+	if versioning != "" {
+		v.storeVersioning(versioning)
+	}
+
+	// the website, the cors, the logging and the notification configurations deleted by another
+	// object node are removed
+	if website, err := v.loadBucketWebsite(); err == nil {
+		v.storeWebsite(website)
+	}
+	if cors, err := v.loadBucketCORS(); err == nil {
+		v.storeCORS(cors)
+	}
+	if logging, err := v.loadBucketLogging(); err == nil {
+		v.storeLogging(logging)
+	}
+	if notification, err := v.loadBucketNotification(); err == nil {
+		v.storeNotification(notification)
+	}
+
+	// the object lock can not be disabled once enabled
+	if objectLock, _ := v.loadBucketObjectLock(); objectLock != nil {
+		v.storeObjectLock(objectLock)
+	}
 }
 
-// load bucket policy from vm
+// load bucket policy from master, which is nil if the bucket has no policy
 func (v *volume) loadBucketPolicy() (policy *Policy, err error) {
-	var store Store
-	store, err = v.vm.GetStore()
-	if err != nil {
+	var bp *proto.BucketPolicy
+	if bp, err = v.vm.mc.AdminAPI().GetVolumePolicy(v.name); err != nil {
+		log.LogErrorf("loadBucketPolicy: load bucket policy fail: volume(%v) err(%v)", v.name, err)
 		return
 	}
-	var data []byte
-	data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSPolicy)
-	if err != nil {
-		log.LogErrorf("loadBucketPolicy: load bucket policy fail: volume(%v) err(%v)", v.name, err)
+	if bp.Policy == "" {
 		return
 	}
 	policy = &Policy{}
-	if err = json.Unmarshal(data, policy); err != nil {
+	if err = json.Unmarshal([]byte(bp.Policy), policy); err != nil {
+		log.LogErrorf("loadBucketPolicy: unmarshal bucket policy fail: volume(%v) err(%v)", v.name, err)
 		return
 	}
 	return
@@ -230,69 +266,38 @@ func (v *volume) OSSSecure() (accessKey, secretKey string) {
 	return v.mw.OSSSecure()
 }
 
-func (v *volume) ListFilesV1(request *ListBucketRequestV1) ([]*FSFileInfo, string, bool, []string, error) {
-	//prefix, delimiter, marker string, maxKeys uint64
-
-	marker := request.marker
-	prefix := request.prefix
-	maxKeys := request.maxKeys
-	delimiter := request.delimiter
-
-	var err error
-	var infos []*FSFileInfo
-	var prefixes Prefixes
+func (v *volume) VolAccessKey(accessKey string) *proto.VolAccessKey {
+	return v.mw.VolAccessKey(accessKey)
+}
 
-	infos, prefixes, err = v.listFilesV1(prefix, marker, delimiter, maxKeys)
+func (v *volume) ListFilesV1(request *ListBucketRequestV1) ([]*FSFileInfo, string, bool, []string, error) {
+	infos, prefixes, nextMarker, isTruncated, err := v.listFiles(request.prefix, request.marker, request.delimiter, request.maxKeys)
 	if err != nil {
 		return nil, "", false, nil, err
 	}
-	if len(infos) <= 0 {
-		return nil, "", false, nil, nil
-	}
-
-	var nextMarker string
-	var isTruncated bool
-
-	if len(infos) > int(maxKeys) {
-		nextMarker = infos[maxKeys].Path
-		infos = infos[:maxKeys]
-		isTruncated = true
+	// filter the page, whose marker is still of all the files listed
+	if request.tagFilter != nil {
+		infos = filterFilesByTag(infos, request.tagFilter)
 	}
 
 	return infos, nextMarker, isTruncated, prefixes, nil
 }
 
 func (v *volume) ListFilesV2(request *ListBucketRequestV2) ([]*FSFileInfo, uint64, string, bool, []string, error) {
-
-	delimiter := request.delimiter
-	maxKeys := request.maxKeys
-	prefix := request.prefix
-	contToken := request.contToken
-	startAfter := request.startAfter
-
-	var err error
-	var infos []*FSFileInfo
-	var prefixes Prefixes
-
-	infos, prefixes, err = v.listFilesV2(prefix, startAfter, contToken, delimiter, maxKeys)
-	if err != nil {
-		return nil, 0, "", false, nil, err
+	var marker = request.startAfter
+	if request.contToken != "" {
+		marker = request.contToken
 	}
-	if len(infos) <= 0 {
+	infos, prefixes, nextToken, isTruncated, err := v.listFiles(request.prefix, marker, request.delimiter, request.maxKeys)
+	if err != nil {
 		return nil, 0, "", false, nil, err
 	}
-
-	var keyCount uint64
-	var nextToken string
-	var isTruncated bool
-
-	keyCount = uint64(len(infos))
-	if len(infos) > int(maxKeys) {
-		nextToken = infos[maxKeys].Path
-		infos = infos[:maxKeys]
-		isTruncated = true
-		keyCount = maxKeys
+	// filter the page, whose continuation token is still of all the files listed
+	if request.tagFilter != nil {
+		infos = filterFilesByTag(infos, request.tagFilter)
 	}
+	// the key count includes the common prefixes, as they are both counted by the max keys
+	keyCount := uint64(len(infos) + len(prefixes))
 
 	return infos, keyCount, nextToken, isTruncated, prefixes, nil
 }
@@ -310,14 +315,16 @@ func (v *volume) WriteFile(path string, reader io.Reader) (*FSFileInfo, error) {
 	}
 	log.LogDebugf("WriteFile: lookup directories, path(%v) parentId(%v)", path, parentId)
 	// check file
+	var lookupInode uint64
 	var lookupMode uint32
-	_, lookupMode, err = v.mw.Lookup_ll(parentId, filename)
+	lookupInode, lookupMode, err = v.mw.Lookup_ll(parentId, filename)
 	if err != nil && err != syscall.ENOENT {
 		return nil, err
 	}
 	if err == nil && os.FileMode(lookupMode).IsDir() {
 		return nil, syscall.EEXIST
 	}
+	var exist = err == nil
 	// create  temp file
 	var tempFilename = tempFileName(filename)
 	var tempInodeInfo *proto.InodeInfo
@@ -378,6 +385,14 @@ func (v *volume) WriteFile(path string, reader io.Reader) (*FSFileInfo, error) {
 		return nil, err
 	}
 
+	// keep the file overwritten as a non-current version
+	if exist && v.versioningEnabled() {
+		if err = v.archiveVersion(dirs, filename, lookupInode); err != nil {
+			log.LogErrorf("WriteFile: archive version fail, path(%v) inode(%v) err(%v)", path, lookupInode, err)
+			return nil, err
+		}
+	}
+
 	// rename temp file to origin
 	if err = v.mw.Rename_ll(parentId, tempFilename, parentId, filename); err != nil {
 		log.LogErrorf("WriteFile: rename temp file fail, (%v_%v) to (%v_%v) err(%v)", parentId, tempFilename, parentId, filename, err)
@@ -405,42 +420,52 @@ func (v *volume) WriteFile(path string, reader io.Reader) (*FSFileInfo, error) {
 	return fInfo, nil
 }
 
-func (v *volume) DeleteFile(path string) error {
-	var err error
+func (v *volume) DeleteFile(path string) (deleteMarker string, err error) {
 	dirs, filename := splitPath(path)
 
 	// process path
 	var parentId uint64
 	if parentId, err = v.lookupDirectories(dirs, false); err != nil {
-		return err
+		return
 	}
 	// lookup target inode
 	var inode uint64
 	var mode uint32
 	if inode, mode, err = v.mw.Lookup_ll(parentId, filename); err != nil {
-		return err
+		return
 	}
 	if os.FileMode(mode).IsDir() {
-		return syscall.ENOENT
+		return "", syscall.ENOENT
+	}
+
+	// keep the file as a non-current version, which is not released by the eviction
+	var versioning = v.versioningEnabled()
+	if versioning {
+		if err = v.archiveVersion(dirs, filename, inode); err != nil {
+			return
+		}
 	}
 
 	// remove file
 	if _, err = v.mw.Delete_ll(parentId, filename, false); err != nil {
-		return err
+		return
 	}
 
 	// evict inode
 	if err = v.ec.EvictStream(inode); err != nil {
-		return err
+		return
 	}
 	if err = v.mw.Evict(inode); err != nil {
-		return err
+		return
 	}
 
-	return nil
+	if versioning {
+		return v.createDeleteMarker(dirs, filename)
+	}
+	return
 }
 
-func (v *volume) InitMultipart(path string) (multipartID string, err error) {
+func (v *volume) InitMultipart(path string, opt *PutFileOption) (multipartID string, err error) {
 	// Invoke meta service to get a session id
 	// Create parent path
 
@@ -452,8 +477,28 @@ func (v *volume) InitMultipart(path string) (multipartID string, err error) {
 		return "", err
 	}
 
+	// generate the data key of the object, kept by the session with the tags until completion
+	var extend = make(map[string]string)
+	if opt != nil && opt.SSE != "" {
+		var sseMeta *SSEMeta
+		if sseMeta, err = v.vm.keys.newSSEMeta(); err != nil {
+			log.LogErrorf("InitMultipart: generate data key fail: path(%v) err(%v)", path, err)
+			return "", err
+		}
+		extend[XAttrKeyOSSSSE] = sseMeta.String()
+	}
+	if opt != nil && opt.Tagging != nil && len(opt.Tagging.TagSet) > 0 {
+		extend[XAttrKeyOSSTagging] = opt.Tagging.Encode()
+	}
+	if opt != nil && opt.Retention != "" {
+		extend[XAttrKeyOSSRetention] = opt.Retention
+	}
+	if opt != nil && opt.LegalHold != "" {
+		extend[XAttrKeyOSSLegalHold] = opt.LegalHold
+	}
+
 	// save parent id to meta
-	multipartID, err = v.mw.InitMultipart_ll(path, parentId)
+	multipartID, err = v.mw.InitMultipart_ll(path, parentId, extend)
 	if err != nil {
 		log.LogErrorf("InitMultipart: meta init multipart fail: path(%v) err(%v)", path, err)
 		return "", err
@@ -472,6 +517,10 @@ func (v *volume) WritePart(path string, multipartId string, partId uint16, reade
 		log.LogErrorf("WritePart: lookup directories fail, path(%v) err(%v)", path, err)
 		return nil, err
 	}
+	if _, err = v.mw.CheckQuota(parentId); err != nil {
+		log.LogWarnf("WritePart: check quota fail, path(%v) parentID(%v) err(%v)", path, parentId, err)
+		return nil, err
+	}
 
 	// create temp file (inode only, invisible for user)
 	var tempInodeInfo *proto.InodeInfo
@@ -483,6 +532,22 @@ func (v *volume) WritePart(path string, multipartId string, partId uint16, reade
 	log.LogDebugf("WritePart: meta create temp file inode: multipartID(%v) partID(%v) inode(%v)",
 		multipartId, parentId, tempInodeInfo.Inode)
 
+	// encrypt data with the data key of the session
+	var sseMeta *SSEMeta
+	var sseStream cipher.Stream
+	if sseMeta, err = v.multipartSSEMeta(multipartId, parentId); err != nil {
+		log.LogErrorf("WritePart: get encryption of multipart fail: multipartID(%v) err(%v)", multipartId, err)
+		return nil, err
+	}
+	if sseMeta != nil {
+		var block cipher.Block
+		if block, err = v.vm.keys.dataKeyBlock(sseMeta); err != nil {
+			log.LogErrorf("WritePart: get data key fail: multipartID(%v) err(%v)", multipartId, err)
+			return nil, err
+		}
+		sseStream = sseMeta.partStream(block, partId, 0)
+	}
+
 	// write data
 	var buf = make([]byte, 128*1024)
 	var readN, writeN, offset int
@@ -508,15 +573,18 @@ func (v *volume) WritePart(path string, multipartId string, partId uint16, reade
 			return nil, err
 		}
 		if readN > 0 {
+			// copy to md5 buffer, and then write to md5
+			size += uint64(readN)
+			copy(md5Buf, buf[:readN])
+			md5Hash.Write(md5Buf[:readN])
+			if sseStream != nil {
+				sseStream.XORKeyStream(buf[:readN], buf[:readN])
+			}
 			if writeN, err = v.ec.Write(tempInodeInfo.Inode, offset, buf[:readN], false); err != nil {
 				log.LogErrorf("WritePart: data write tmp file fail, inode(%v) offset(%v) err(%v)", tempInodeInfo.Inode, offset, err)
 				return nil, err
 			}
 			offset += writeN
-			// copy to md5 buffer, and then write to md5
-			size += uint64(readN)
-			copy(md5Buf, buf[:readN])
-			md5Hash.Write(md5Buf[:readN])
 		}
 		if err == io.EOF {
 			break
@@ -556,9 +624,24 @@ func (v *volume) WritePart(path string, multipartId string, partId uint16, reade
 		ETag:       fileMd5,
 		Inode:      tempInodeInfo.Inode,
 	}
+	if sseMeta != nil {
+		fInfo.SSE = sseMeta.Algorithm
+	}
 	return fInfo, nil
 }
 
+// multipartSSEMeta returns the encryption of the multipart upload, nil if it is not encrypted.
+func (v *volume) multipartSSEMeta(multipartID string, parentID uint64) (sseMeta *SSEMeta, err error) {
+	var multipartInfo *proto.MultipartInfo
+	if multipartInfo, err = v.mw.GetMultipart_ll(multipartID, parentID); err != nil {
+		return
+	}
+	if raw := multipartInfo.Extend[XAttrKeyOSSSSE]; raw != "" {
+		return parseSSEMeta(raw)
+	}
+	return
+}
+
 func (v *volume) AbortMultipart(path string, multipartID string) (err error) {
 	// TODO: cleanup data while abort multipart
 	var parentId uint64
@@ -602,7 +685,10 @@ func (v *volume) AbortMultipart(path string, multipartID string) (err error) {
 	return nil
 }
 
-func (v *volume) CompleteMultipart(path string, multipartID string) (fsFileInfo *FSFileInfo, err error) {
+// CompleteMultipart completes the multipart upload by the parts in order, or by all the parts
+// uploaded if the parts are not specified. The file of the object is created and linked to the
+// path by the meta node in one step, which validates the parts and computes the entity tag.
+func (v *volume) CompleteMultipart(path, multipartID string, completeParts []*FSPart) (fsFileInfo *FSFileInfo, err error) {
 
 	const mode = 0600
 
@@ -624,25 +710,35 @@ func (v *volume) CompleteMultipart(path string, multipartID string) (fsFileInfo
 		return
 	}
 
-	// sort multipart parts
-	parts := multipartInfo.Parts
-	sort.SliceStable(parts, func(i, j int) bool { return parts[i].ID < parts[j].ID })
-
-	// create inode for complete data
-	var completeInodeInfo *proto.InodeInfo
-	if completeInodeInfo, err = v.mw.InodeCreate_ll(mode, 0, 0, nil); err != nil {
-		log.LogErrorf("CompleteMultipart: meta inode create fail: err(%v)", err)
-		return
-	}
-	log.LogDebugf("CompleteMultipart: meta inode create: inode(%v)", completeInodeInfo.Inode)
-	defer func() {
-		if err != nil {
-			if deleteErr := v.mw.InodeDelete_ll(completeInodeInfo.Inode); deleteErr != nil {
-				log.LogErrorf("CompleteMultipart: meta delete complete inode fail: inode(%v) err(%v)",
-					completeInodeInfo.Inode, err)
+	// select the parts requested, whose order and entity tags are validated by the meta node
+	var parts []*proto.MultipartPartInfo
+	if completeParts == nil {
+		parts = multipartInfo.Parts
+		sort.SliceStable(parts, func(i, j int) bool { return parts[i].ID < parts[j].ID })
+	} else {
+		var uploaded = make(map[int]*proto.MultipartPartInfo, len(multipartInfo.Parts))
+		for _, part := range multipartInfo.Parts {
+			uploaded[int(part.ID)] = part
+		}
+		parts = make([]*proto.MultipartPartInfo, 0, len(completeParts))
+		for _, completePart := range completeParts {
+			part, found := uploaded[completePart.PartNumber]
+			if !found {
+				log.LogWarnf("CompleteMultipart: part not uploaded: multipartID(%v) partNumber(%v)",
+					multipartID, completePart.PartNumber)
+				return nil, proto.ErrInvalidPart
 			}
+			parts = append(parts, &proto.MultipartPartInfo{
+				ID:    part.ID,
+				Inode: part.Inode,
+				MD5:   completePart.ETag,
+				Size:  part.Size,
+			})
 		}
-	}()
+	}
+	if len(parts) == 0 {
+		return nil, proto.ErrInvalidPart
+	}
 
 	// merge complete extent keys
 	var size uint64
@@ -663,224 +759,198 @@ func (v *volume) CompleteMultipart(path string, multipartID string) (fsFileInfo
 		size += part.Size
 	}
 
-	// compute md5 hash
-	var md5Val string
-	if len(parts) == 1 {
-		md5Val = parts[0].MD5
-	} else {
-		var md5Hash = md5.New()
-		var reuseBuf = make([]byte, util.BlockSize)
+	// the encryption and the tags are saved before the object is visible, which is never read as
+	// plain data. The data of each part is encrypted from the start of the part.
+	var extend = make(map[string]string)
+	var sseMeta *SSEMeta
+	if raw := multipartInfo.Extend[XAttrKeyOSSSSE]; raw != "" {
+		if sseMeta, err = parseSSEMeta(raw); err != nil {
+			log.LogErrorf("CompleteMultipart: parse encryption fail: multipartID(%v) err(%v)", multipartID, err)
+			return
+		}
+		sseMeta.Parts = make([][2]uint64, 0, len(parts))
 		for _, part := range parts {
-			if err = v.appendInodeHash(md5Hash, part.Inode, part.Size, reuseBuf); err != nil {
-				log.LogErrorf("CompleteMultipart: append part hash fail: partID(%v) inode(%v) err(%v)",
-					part.ID, part.Inode, err)
-				return
-			}
+			sseMeta.Parts = append(sseMeta.Parts, [2]uint64{uint64(part.ID), part.Size})
 		}
-		md5Val = hex.EncodeToString(md5Hash.Sum(nil))
+		extend[XAttrKeyOSSSSE] = sseMeta.String()
 	}
-	log.LogDebugf("CompleteMultipart: merge parts: numParts(%v) MD5(%v)", len(parts), md5Val)
-
-	if err = v.mw.AppendExtentKeys(completeInodeInfo.Inode, completeExtentKeys); err != nil {
-		log.LogErrorf("CompleteMultipart: meta append extent keys fail: inode(%v) err(%v)", completeInodeInfo.Inode, err)
+	for _, key := range []string{XAttrKeyOSSTagging, XAttrKeyOSSRetention, XAttrKeyOSSLegalHold} {
+		if value := multipartInfo.Extend[key]; value != "" {
+			extend[key] = value
+		}
+	}
+	// the object inherits the quotas of the parent directory as the files created in it
+	var quotaIDs []uint32
+	if quotaIDs, err = v.mw.CheckQuota(parentId); err != nil {
+		log.LogWarnf("CompleteMultipart: check quota fail: multipartID(%v) parentID(%v) err(%v)",
+			multipartID, parentId, err)
 		return
 	}
+	if len(quotaIDs) > 0 {
+		extend[proto.QuotaXAttrKey] = proto.FormatQuotaIDs(quotaIDs)
+	}
 
 	var (
-		existMode uint32
+		existInode uint64
+		existMode  uint32
 	)
-	_, existMode, err = v.mw.Lookup_ll(parentId, filename)
+	existInode, existMode, err = v.mw.Lookup_ll(parentId, filename)
 	if err != nil && err != syscall.ENOENT {
 		log.LogErrorf("CompleteMultipart: meta lookup fail: parentID(%v) name(%v) err(%v)", parentId, filename, err)
 		return
 	}
-
-	if err == syscall.ENOENT {
-		if err = v.applyInodeToNewDentry(parentId, filename, completeInodeInfo.Inode); err != nil {
-			log.LogErrorf("CompleteMultipart: apply inode to new dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
-				parentId, filename, completeInodeInfo.Inode, err)
-			return
-		}
-		log.LogDebugf("CompleteMultipart: apply inode to new dentry: parentID(%v) name(%v) inode(%v)",
-			parentId, filename, completeInodeInfo.Inode)
-	} else {
+	if err == nil {
 		if os.FileMode(existMode).IsDir() {
 			log.LogErrorf("CompleteMultipart: target mode conflict: parentID(%v) name(%v) mode(%v)",
 				parentId, filename, os.FileMode(existMode).String())
 			err = syscall.EEXIST
 			return
 		}
-		if err = v.applyInodeToExistDentry(parentId, filename, completeInodeInfo.Inode); err != nil {
-			log.LogErrorf("CompleteMultipart: apply inode to exist dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
-				parentId, filename, completeInodeInfo.Inode, err)
-			return
+		// keep the object overwritten as a non-current version
+		if v.versioningEnabled() {
+			if err = v.archiveVersion(dirs, filename, existInode); err != nil {
+				log.LogErrorf("CompleteMultipart: archive version fail: parentID(%v) name(%v) inode(%v) err(%v)",
+					parentId, filename, existInode, err)
+				return
+			}
 		}
 	}
 
-	if err = v.mw.XAttrSet_ll(completeInodeInfo.Inode, []byte(XAttrKeyOSSETag), []byte(md5Val)); err != nil {
-		log.LogErrorf("CompleteMultipart: save MD5 fail: inode(%v) err(%v)", completeInodeInfo, err)
-		return
-	}
-
-	// remove multipart
-	err = v.mw.RemoveMultipart_ll(multipartID, parentId)
+	var resp *proto.CompleteMultipartResponse
+	resp, err = v.mw.CompleteMultipart_ll(&proto.CompleteMultipartRequest{
+		MultipartId: multipartID,
+		ParentId:    parentId,
+		Name:        filename,
+		Mode:        mode,
+		Parts:       parts,
+		Extents:     completeExtentKeys,
+		Extend:      extend,
+		ETagKey:     XAttrKeyOSSETag,
+	})
 	if err != nil {
 		log.LogErrorf("CompleteMultipart: meta complete multipart fail, multipartID(%v) path(%v) parentID(%v) err(%v)",
 			multipartID, path, parentId, err)
 		return nil, err
 	}
-	// delete part inodes
+
+	// release the inode overwritten and the inodes of the parts, whose extents are referenced
+	// by the completed inode now
+	if resp.OldInode != 0 {
+		v.releaseInode(resp.OldInode)
+	}
 	for _, part := range parts {
-		if err = v.mw.InodeDelete_ll(part.Inode); err != nil {
-			log.LogErrorf("CompleteMultipart: ")
+		if deleteErr := v.mw.InodeDelete_ll(part.Inode); deleteErr != nil {
+			log.LogErrorf("CompleteMultipart: meta delete part inode fail: inode(%v) err(%v)", part.Inode, deleteErr)
 		}
 	}
 
 	log.LogDebugf("CompleteMultipart: meta complete multipart, multipartID(%v) path(%v) parentID(%v) inode(%v) md5(%v)",
-		multipartID, path, parentId, completeInodeInfo.Inode, md5Val)
+		multipartID, path, parentId, resp.Info.Inode, resp.ETag)
 
 	// create file info
 	fInfo := &FSFileInfo{
 		Path:       path,
 		Size:       int64(size),
-		Mode:       os.FileMode(0600),
-		ModifyTime: time.Now(),
-		ETag:       md5Val,
-		Inode:      completeInodeInfo.Inode,
+		Mode:       os.FileMode(mode),
+		ModifyTime: resp.Info.ModifyTime,
+		ETag:       resp.ETag,
+		Inode:      resp.Info.Inode,
+		Tagging:    extend[XAttrKeyOSSTagging],
+		Retention:  extend[XAttrKeyOSSRetention],
+		LegalHold:  extend[XAttrKeyOSSLegalHold],
+	}
+	if sseMeta != nil {
+		fInfo.SSE = sseMeta.Algorithm
 	}
 	return fInfo, nil
 }
 
-func (v *volume) appendInodeHash(h hash.Hash, inode uint64, total uint64, preAllocatedBuf []byte) (err error) {
-	if err = v.ec.OpenStream(inode); err != nil {
-		log.LogErrorf("appendInodeHash: data open stream fail: inode(%v) err(%v)",
-			inode, err)
+// releaseInode unlinks and evicts the inode no longer linked by the dentry.
+func (v *volume) releaseInode(inode uint64) {
+	if _, err := v.mw.InodeUnlink_ll(inode); err != nil {
+		log.LogErrorf("releaseInode: meta unlink inode fail: inode(%v) err(%v)", inode, err)
 		return
 	}
-	defer func() {
-		if closeErr := v.ec.CloseStream(inode); closeErr != nil {
-			log.LogErrorf("appendInodeHash: data close stream fail: inode(%v) err(%v)",
-				inode, err)
-		}
-		if evictErr := v.ec.EvictStream(inode); evictErr != nil {
-			log.LogErrorf("appendInodeHash: data evict stream: inode(%v) err(%v)",
-				inode, err)
-		}
-	}()
-
-	var buf = preAllocatedBuf
-	if len(buf) == 0 {
-		buf = make([]byte, 1024*64)
+	if err := v.mw.Evict(inode); err != nil {
+		log.LogErrorf("releaseInode: meta evict inode fail: inode(%v) err(%v)", inode, err)
 	}
-
-	var n, offset, size int
-	for {
-		size = len(buf)
-		rest := total - uint64(offset)
-		if uint64(size) > rest {
-			size = int(rest)
-		}
-		n, err = v.ec.Read(inode, buf, offset, size)
-		if err != nil && err != io.EOF {
-			log.LogErrorf("appendInodeHash: data read fail, inode(%v) offset(%v) size(%v) err(%v)", inode, offset, size, err)
-			return
-		}
-		log.LogDebugf("appendInodeHash: data read, inode(%v) offset(%v) n(%v)", inode, offset, n)
-		if n > 0 {
-			if _, err = h.Write(buf[:n]); err != nil {
-				return
-			}
-			offset += n
-		}
-		if n == 0 || err == io.EOF {
-			break
-		}
-	}
-	log.LogDebugf("appendInodeHash: append to hash function: inode(%v)", inode)
-	return
 }
 
-func (v *volume) applyInodeToNewDentry(parentID uint64, name string, inode uint64) (err error) {
-	const mode = 0600
-	if err = v.mw.DentryCreate_ll(parentID, name, inode, mode); err != nil {
-		log.LogErrorf("applyInodeToNewDentry: meta dentry create fail: parentID(%v) name(%v) inode(%v) mode(%v) err(%v)",
-			parentID, name, inode, mode, err)
+func (v *volume) ReadFile(path string, writer io.Writer, offset, size uint64) error {
+	var err error
+	dirs, filename := splitPath(path)
+
+	// process path
+	var parentId uint64
+	if parentId, err = v.lookupDirectories(dirs, false); err != nil {
 		return err
 	}
-	return
-}
-
-func (v *volume) applyInodeToExistDentry(parentID uint64, name string, inode uint64) (err error) {
-	var oldInode uint64
-	oldInode, err = v.mw.DentryUpdate_ll(parentID, name, inode)
+	log.LogDebugf("ReadFile: lookup directories, path(%v) parentId(%v)", path, parentId)
+	// check file
+	var fileInode uint64
+	var lookupMode uint32
+	fileInode, lookupMode, err = v.mw.Lookup_ll(parentId, filename)
 	if err != nil {
-		log.LogErrorf("applyInodeToExistDentry: meta update dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
-			parentID, name, inode, err)
-		return
+		return err
 	}
-
-	defer func() {
-		if err != nil {
-			// rollback dentry update
-			if _, updateErr := v.mw.DentryUpdate_ll(parentID, name, oldInode); updateErr != nil {
-				log.LogErrorf("CompleteMultipart: meta rollback dentry update fail: parentID(%v) name(%v) inode(%v) err(%v)",
-					parentID, name, oldInode, updateErr)
-			}
-		}
-	}()
-
-	// unlink and evict old inode
-	if _, err = v.mw.InodeUnlink_ll(oldInode); err != nil {
-		log.LogErrorf("CompleteMultipart: meta unlink old inode fail: inode(%v) err(%v)",
-			oldInode, err)
-		return
+	if os.FileMode(lookupMode).IsDir() {
+		return syscall.ENOENT
 	}
+	return v.readInode(fileInode, writer, offset, size)
+}
 
-	defer func() {
-		if err != nil {
-			// rollback unlink
-			if _, linkErr := v.mw.InodeLink_ll(oldInode); linkErr != nil {
-				log.LogErrorf("CompleteMultipart: meta rollback inode unlink fail: inode(%v) err(%v)",
-					oldInode, linkErr)
-			}
-		}
-	}()
-
-	if err = v.mw.Evict(oldInode); err != nil {
-		log.LogErrorf("CompleteMultipart: meta evict old inode fail: inode(%v) err(%v)",
-			oldInode, err)
-		return
-	}
-	return
+func (v *volume) readInode(fileInode uint64, writer io.Writer, offset, size uint64) (err error) {
+	return v.readInodeRanges(fileInode, []FileRange{{Offset: offset, Size: size}}, func(int) (io.Writer, error) {
+		return writer, nil
+	})
 }
 
-func (v *volume) ReadFile(path string, writer io.Writer, offset, size uint64) error {
+// ReadFileRanges reads the ranges of the file in order, and writes each range to the writer
+// returned by next for it.
+func (v *volume) ReadFileRanges(path string, ranges []FileRange, next func(i int) (io.Writer, error)) error {
 	var err error
 	dirs, filename := splitPath(path)
 
-	// process path
 	var parentId uint64
 	if parentId, err = v.lookupDirectories(dirs, false); err != nil {
 		return err
 	}
-	log.LogDebugf("ReadFile: lookup directories, path(%v) parentId(%v)", path, parentId)
-	// check file
 	var fileInode uint64
 	var lookupMode uint32
-	fileInode, lookupMode, err = v.mw.Lookup_ll(parentId, filename)
-	if err != nil {
+	if fileInode, lookupMode, err = v.mw.Lookup_ll(parentId, filename); err != nil {
 		return err
 	}
 	if os.FileMode(lookupMode).IsDir() {
 		return syscall.ENOENT
 	}
+	return v.readInodeRanges(fileInode, ranges, next)
+}
+
+// readInodeRanges reads the ranges of the inode by one stream, so that only the extents covering
+// the ranges are read from the data nodes.
+func (v *volume) readInodeRanges(fileInode uint64, ranges []FileRange, next func(i int) (io.Writer, error)) (err error) {
 	// read file data
 	var fileInodeInfo *proto.InodeInfo
-	fileInodeInfo, err = v.mw.InodeGet_ll(fileInode)
+	var xAttrInfo *proto.XAttrInfo
+	fileInodeInfo, xAttrInfo, err = v.mw.InodeGetWithXAttrs_ll(fileInode, []string{XAttrKeyOSSSSE})
 	if err != nil {
 		return err
 	}
 
+	// decrypt data with the data key of the object
+	var sseMeta *SSEMeta
+	var block cipher.Block
+	if raw := xAttrInfo.XAttrs[XAttrKeyOSSSSE]; raw != "" {
+		if sseMeta, err = parseSSEMeta(raw); err != nil {
+			log.LogErrorf("ReadFile: parse encryption fail, inode(%v) err(%v)", fileInode, err)
+			return err
+		}
+		if block, err = v.vm.keys.dataKeyBlock(sseMeta); err != nil {
+			log.LogErrorf("ReadFile: get data key fail, inode(%v) err(%v)", fileInode, err)
+			return err
+		}
+	}
+
 	if err = v.ec.OpenStream(fileInode); err != nil {
 		log.LogErrorf("ReadFile: data open stream fail, Inode(%v) err(%v)", fileInode, err)
 		return err
@@ -891,28 +961,45 @@ func (v *volume) ReadFile(path string, writer io.Writer, offset, size uint64) er
 		}
 	}()
 
-	var upper = size + offset
-	if upper > fileInodeInfo.Size {
-		upper = fileInodeInfo.Size - offset
+	var tmp = make([]byte, util.BlockSize)
+	for i, fileRange := range ranges {
+		var writer io.Writer
+		if writer, err = next(i); err != nil {
+			return err
+		}
+		var sseStream cipher.Stream
+		if sseMeta != nil {
+			sseStream = sseMeta.objectStream(block, fileRange.Offset)
+		}
+		if err = v.readInodeRange(fileInode, fileInodeInfo.Size, writer, sseStream, tmp, fileRange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *volume) readInodeRange(fileInode, fileSize uint64, writer io.Writer, sseStream cipher.Stream, tmp []byte, fileRange FileRange) (err error) {
+	var offset = fileRange.Offset
+	var upper = fileRange.Offset + fileRange.Size
+	if upper > fileSize {
+		upper = fileSize
 	}
 
 	var n int
-	var tmp = make([]byte, util.BlockSize)
-	for {
-		var rest = upper - uint64(offset)
-		if rest == 0 {
-			break
-		}
+	for offset < upper {
 		var readSize = len(tmp)
-		if uint64(readSize) > rest {
-			readSize = int(rest)
+		if uint64(readSize) > upper-offset {
+			readSize = int(upper - offset)
 		}
 		n, err = v.ec.Read(fileInode, tmp, int(offset), readSize)
 		if err != nil && err != io.EOF {
-			log.LogErrorf("ReadFile: data read fail, inode(%v) offset(%v) size(%v) err(%v)", fileInode, offset, size, err)
+			log.LogErrorf("ReadFile: data read fail, inode(%v) offset(%v) size(%v) err(%v)", fileInode, offset, fileRange.Size, err)
 			return err
 		}
 		if n > 0 {
+			if sseStream != nil {
+				sseStream.XORKeyStream(tmp[:n], tmp[:n])
+			}
 			if _, err = writer.Write(tmp[:n]); err != nil {
 				return err
 			}
@@ -944,13 +1031,10 @@ func (v *volume) FileInfo(path string) (info *FSFileInfo, err error) {
 	}
 	// read file data
 	var fileInodeInfo *proto.InodeInfo
-	if fileInodeInfo, err = v.mw.InodeGet_ll(fileInode); err != nil {
-		return
-	}
-
 	var xAttrInfo *proto.XAttrInfo
-	if xAttrInfo, err = v.mw.XAttrGet_ll(fileInode, XAttrKeyOSSETag); err != nil {
-		logger.Error("FileInfo: meta get xattr fail, inode(%v) path(%v) err(%v)", fileInode, path, err)
+	if fileInodeInfo, xAttrInfo, err = v.mw.InodeGetWithXAttrs_ll(fileInode,
+		[]string{XAttrKeyOSSETag, XAttrKeyOSSSSE, XAttrKeyOSSTagging, XAttrKeyOSSRetention, XAttrKeyOSSLegalHold}); err != nil {
+		logger.Error("FileInfo: meta get inode and xattr fail, inode(%v) path(%v) err(%v)", fileInode, path, err)
 		return
 	}
 	md5Val := xAttrInfo.XAttrs[XAttrKeyOSSETag]
@@ -962,6 +1046,10 @@ func (v *volume) FileInfo(path string) (info *FSFileInfo, err error) {
 		ModifyTime: fileInodeInfo.ModifyTime,
 		ETag:       md5Val,
 		Inode:      fileInodeInfo.Inode,
+		SSE:        sseAlgorithm(xAttrInfo.XAttrs[XAttrKeyOSSSSE]),
+		Tagging:    xAttrInfo.XAttrs[XAttrKeyOSSTagging],
+		Retention:  xAttrInfo.XAttrs[XAttrKeyOSSRetention],
+		LegalHold:  xAttrInfo.XAttrs[XAttrKeyOSSLegalHold],
 	}
 	return
 }
@@ -975,7 +1063,14 @@ func (v *volume) Close() error {
 }
 
 func (v *volume) lookupDirectories(dirs []string, autoCreate bool) (inode uint64, err error) {
-	var parentId = rootIno
+	// the versions of the objects are only accessed by the version IDs
+	if len(dirs) > 0 && dirs[0] == versionsDirName {
+		return 0, syscall.EPERM
+	}
+	return v.lookupDirectoriesFrom(rootIno, dirs, autoCreate)
+}
+
+func (v *volume) lookupDirectoriesFrom(parentId uint64, dirs []string, autoCreate bool) (inode uint64, err error) {
 	// check and create dirs
 	for _, dir := range dirs {
 		curIno, curMode, lookupErr := v.mw.Lookup_ll(parentId, dir)
@@ -1024,157 +1119,25 @@ func (v *volume) lookupDirectories(dirs []string, autoCreate bool) (inode uint64
 	return
 }
 
-func (v *volume) listFilesV1(prefix, marker, delimiter string, maxKeys uint64) (infos []*FSFileInfo, prefixes Prefixes, err error) {
-	var prefixMap = PrefixMap(make(map[string]struct{}))
-
-	parentId, dirs, err := v.findParentId(prefix)
-	if err != nil {
-		return nil, nil, err
-	}
-	log.LogDebugf("listFilesV1: find parent ID, prefix(%v) marker(%v) delimiter(%v) parentId(%v) dirs(%v)", prefix, marker, delimiter, parentId, len(dirs))
-
-	// recursion call listDir method
-	infos, prefixMap, err = v.listDir(infos, prefixMap, parentId, maxKeys, dirs, prefix, marker, delimiter)
-	if err != nil {
-		log.LogErrorf("listFilesV1: volume list dir fail: volume(%v) err(%v)", v.name, err)
+// listFiles lists a page of the files and the common prefixes after the marker, with the size and
+// the MD5 of the files supplied.
+func (v *volume) listFiles(prefix, marker, delimiter string, maxKeys uint64) (infos []*FSFileInfo, prefixes Prefixes, nextMarker string, isTruncated bool, err error) {
+	lister := newObjectLister(v.mw, prefix, marker, delimiter, maxKeys)
+	if err = lister.list(); err != nil {
+		log.LogErrorf("listFiles: volume list dir fail: volume(%v) prefix(%v) marker(%v) err(%v)", v.name, prefix, marker, err)
 		return
 	}
 
 	// supply size and MD5
-	if err = v.supplyListFileInfo(infos); err != nil {
-		log.LogDebugf("listFilesV1: supply list file info fail, err(%v)", err)
+	if err = v.supplyListFileInfo(lister.infos); err != nil {
+		log.LogDebugf("listFiles: supply list file info fail, err(%v)", err)
 		return
 	}
 
-	prefixes = prefixMap.Prefixes()
-
-	log.LogDebugf("listFilesV1: volume list dir: volume(%v) prefix(%v) marker(%v) delimiter(%v) maxKeys(%v) infos(%v) prefixes(%v)",
+	infos, prefixes = lister.infos, lister.prefixes.Prefixes()
+	log.LogDebugf("listFiles: volume list dir: volume(%v) prefix(%v) marker(%v) delimiter(%v) maxKeys(%v) infos(%v) prefixes(%v)",
 		v.name, prefix, marker, delimiter, maxKeys, len(infos), len(prefixes))
-
-	return
-}
-
-func (v *volume) listFilesV2(prefix, startAfter, contToken, delimiter string, maxKeys uint64) (infos []*FSFileInfo, prefixes Prefixes, err error) {
-	var prefixMap = PrefixMap(make(map[string]struct{}))
-
-	var marker string
-	if startAfter != "" {
-		marker = startAfter
-	}
-	if contToken != "" {
-		marker = contToken
-	}
-	parentId, dirs, err := v.findParentId(prefix)
-	if err != nil {
-		log.LogErrorf("listFilesV2: find parent ID fail, prefix(%v) marker(%v) err(%v)", prefix, marker, err)
-		return
-	}
-	log.LogDebugf("listFilesV2: find parent ID, prefix(%v) marker(%v) delimiter(%v) parentId(%v) dirs(%v)", prefix, marker, delimiter, parentId, len(dirs))
-
-	// recursion call listDir method
-	infos, prefixMap, err = v.listDir(infos, prefixMap, parentId, maxKeys, dirs, prefix, marker, delimiter)
-	if err != nil {
-		log.LogErrorf("listFilesV2: volume list dir fail, volume(%v) err(%v)", v.name, err)
-		return
-	}
-
-	// supply size and MD5
-	err = v.supplyListFileInfo(infos)
-	if err != nil {
-		log.LogDebugf("listFilesV2: supply list file info fail, err(%v)", err)
-		return
-	}
-
-	prefixes = prefixMap.Prefixes()
-
-	log.LogDebugf("listFilesV2: volume list dir: volume(%v) prefix(%v) marker(%v) delimiter(%v) maxKeys(%v) infos(%v) prefixes(%v)",
-		v.name, prefix, marker, delimiter, maxKeys, len(infos), len(prefixes))
-
-	return
-}
-
-func (v *volume) findParentId(prefix string) (inode uint64, prefixDirs []string, err error) {
-	prefixDirs = make([]string, 0)
-
-	// if prefix and marker are both not empty, use marker
-	var dirs []string
-	if prefix != "" {
-		dirs = strings.Split(prefix, "/")
-	}
-	if len(dirs) <= 1 {
-		return proto.RootIno, prefixDirs, nil
-	}
-
-	var parentId = proto.RootIno
-	for index, dir := range dirs {
-		curIno, curMode, err := v.mw.Lookup_ll(parentId, dir)
-		if index == len(dirs) && err == syscall.ENOENT {
-			return parentId, prefixDirs, nil
-		}
-
-		if err != nil && err != syscall.ENOENT {
-			log.LogErrorf("findParentId: find directories fail: prefix(%v) err(%v)", prefix, err)
-			return 0, nil, err
-		}
-
-		if !os.FileMode(curMode).IsDir() {
-			return 0, nil, err
-		}
-
-		prefixDirs = append(prefixDirs, dir)
-		parentId = curIno
-	}
-	inode = parentId
-	return
-}
-
-func (v *volume) listDir(fileInfos []*FSFileInfo, prefixMap PrefixMap, parentId, maxKeys uint64, dirs []string, prefix, marker, delimiter string) ([]*FSFileInfo, PrefixMap, error) {
-
-	children, err := v.mw.ReadDir_ll(parentId)
-	if err != nil {
-		return fileInfos, prefixMap, err
-	}
-
-	for _, child := range children {
-		log.LogDebugf("listDir: process child, inode(%v) name(%v) parentId(%v) isDir(%v) isRegular(%v)",
-			child.Inode, child.Name, parentId, os.FileMode(child.Type).IsDir(), os.FileMode(child.Type).IsRegular())
-		if os.FileMode(child.Type).IsDir() {
-			fileInfos, prefixMap, err = v.listDir(fileInfos, prefixMap, child.Inode, maxKeys, append(dirs, child.Name), prefix, marker, delimiter)
-			if err != nil {
-				return fileInfos, prefixMap, err
-			}
-		} else {
-			path := strings.Join(dirs, "/")
-			if len(dirs) == 0 {
-				path += child.Name
-			} else {
-				path += "/" + child.Name
-			}
-			if marker != "" && path < marker {
-				continue
-			}
-			if prefix != "" && !strings.HasPrefix(path, prefix) {
-				continue
-			}
-			if delimiter != "" && strings.Contains(child.Name, delimiter) {
-				idx := strings.Index(path, delimiter)
-				prefix := util.SubString(path, 0, idx)
-				prefixMap.AddPrefix(prefix)
-				continue
-			}
-			fileInfo := &FSFileInfo{
-				Inode: child.Inode,
-				Path:  path,
-			}
-			fileInfos = append(fileInfos, fileInfo)
-
-			// if file numbers is enough, end list dir
-			if len(fileInfos) >= int(maxKeys+1) {
-				return fileInfos, prefixMap, nil
-			}
-		}
-	}
-	return fileInfos, prefixMap, nil
+	return infos, prefixes, lister.nextMarker, lister.isTruncated, nil
 }
 
 func (v *volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
@@ -1199,19 +1162,20 @@ func (v *volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 		}
 	}
 
-	// Get MD5 information in batches, then update to fileInfos
-	md5Map := make(map[uint64]string)
-	keys := []string{XAttrKeyOSSETag}
+	// Get MD5 and tagging information in batches, then update to fileInfos
+	xAttrsMap := make(map[uint64]map[string]string)
+	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSTagging}
 	batchXAttrInfos, err := v.mw.BatchGetXAttr(inodes, keys)
 	if err != nil {
 		logger.Error("supplyListFileInfo: batch get xattr fail, inodes(%v), err(%v)", inodes, err)
 		return
 	}
 	for _, xAttrInfo := range batchXAttrInfos {
-		md5Map[xAttrInfo.Inode] = xAttrInfo.XAttrs[XAttrKeyOSSETag]
+		xAttrsMap[xAttrInfo.Inode] = xAttrInfo.XAttrs
 	}
 	for _, fileInfo := range fileInfos {
-		fileInfo.ETag = md5Map[fileInfo.Inode]
+		fileInfo.ETag = xAttrsMap[fileInfo.Inode][XAttrKeyOSSETag]
+		fileInfo.Tagging = xAttrsMap[fileInfo.Inode][XAttrKeyOSSTagging]
 	}
 	return
 }
@@ -1277,24 +1241,16 @@ func (v *volume) ListParts(path, sessionId string, maxParts, partNumberMarker ui
 		return nil, 0, false, err
 	}
 
-	multipartInfo, err := v.mw.GetMultipart_ll(sessionId, parentId)
+	// the parts are paged by the meta node, which keeps the response small for the large uploads
+	multipartInfo, next, isTruncated, err := v.mw.ListMultipartParts_ll(sessionId, parentId, maxParts, uint16(partNumberMarker))
 	if err != nil {
-		log.LogErrorf("Get session(%s) parts failed cause: %v", sessionId, err)
-	}
-
-	sessionParts := multipartInfo.Parts
-	resLength := maxParts
-	isTruncated = true
-	if (uint64(len(sessionParts)) - partNumberMarker) < maxParts {
-		resLength = uint64(len(sessionParts)) - partNumberMarker
-		isTruncated = false
-		nextMarker = 0
-	} else {
-		nextMarker = partNumberMarker + resLength
+		log.LogErrorf("ListParts: meta list multipart parts fail: multipartID(%v) maxParts(%v) partNumberMarker(%v) err(%v)",
+			sessionId, maxParts, partNumberMarker, err)
+		return nil, 0, false, err
 	}
+	nextMarker = uint64(next)
 
-	sessionPartsTemp := sessionParts[partNumberMarker:resLength]
-	for _, sessionPart := range sessionPartsTemp {
+	for _, sessionPart := range multipartInfo.Parts {
 		fsPart := &FSPart{
 			PartNumber:   int(sessionPart.ID),
 			LastModified: formatTimeISO(sessionPart.UploadTime),
@@ -1360,6 +1316,171 @@ func (v *volume) CopyFile(targetPath, sourcePath string) (info *FSFileInfo, err
 	return
 }
 
+func (v *volume) CopyFileData(targetPath, sourcePath string, opt *PutFileOption) (info *FSFileInfo, err error) {
+	var sourceInfo *FSFileInfo
+	if sourceInfo, err = v.FileInfo(sourcePath); err != nil {
+		return nil, err
+	}
+
+	var multipartID string
+	if multipartID, err = v.InitMultipart(targetPath, opt); err != nil {
+		log.LogErrorf("CopyFileData: init multipart fail: target(%v) err(%v)", targetPath, err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if abortErr := v.AbortMultipart(targetPath, multipartID); abortErr != nil {
+				log.LogErrorf("CopyFileData: abort multipart fail: target(%v) multipartID(%v) err(%v)",
+					targetPath, multipartID, abortErr)
+			}
+		}
+	}()
+
+	// the source is read as plain data and written in one part
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(v.readInode(sourceInfo.Inode, writer, 0, uint64(sourceInfo.Size)))
+	}()
+	_, err = v.WritePart(targetPath, multipartID, 1, reader)
+	_ = reader.Close()
+	if err != nil {
+		log.LogErrorf("CopyFileData: write part fail: target(%v) source(%v) multipartID(%v) err(%v)",
+			targetPath, sourcePath, multipartID, err)
+		return nil, err
+	}
+	if info, err = v.CompleteMultipart(targetPath, multipartID, nil); err != nil {
+		log.LogErrorf("CopyFileData: complete multipart fail: target(%v) multipartID(%v) err(%v)",
+			targetPath, multipartID, err)
+		return nil, err
+	}
+	return
+}
+
+// CopyFileFrom copies the file of another volume, whose data is copied by the data nodes instead of
+// through the object node. The copy keeps the entity tag and the encryption of the source, and the
+// tags unless they are replaced by the option.
+func (v *volume) CopyFileFrom(source *volume, targetPath, sourcePath string, opt *PutFileOption) (info *FSFileInfo, err error) {
+	const mode = 0600
+
+	sourceDirs, sourceFilename := splitPath(sourcePath)
+	var sourceParentID uint64
+	if sourceParentID, err = source.lookupDirectories(sourceDirs, false); err != nil {
+		return nil, err
+	}
+	var sourceInode uint64
+	var lookupMode uint32
+	if sourceInode, lookupMode, err = source.mw.Lookup_ll(sourceParentID, sourceFilename); err != nil {
+		return nil, err
+	}
+	if os.FileMode(lookupMode).IsDir() {
+		return nil, syscall.ENOENT
+	}
+	var xAttrInfo *proto.XAttrInfo
+	if _, xAttrInfo, err = source.mw.InodeGetWithXAttrs_ll(sourceInode,
+		[]string{XAttrKeyOSSETag, XAttrKeyOSSSSE, XAttrKeyOSSTagging}); err != nil {
+		log.LogErrorf("CopyFileFrom: meta get source inode fail: volume(%v) inode(%v) err(%v)", source.name, sourceInode, err)
+		return nil, err
+	}
+	var extents []proto.ExtentKey
+	if _, _, extents, err = source.mw.GetExtents(sourceInode); err != nil {
+		log.LogErrorf("CopyFileFrom: meta get source extents fail: volume(%v) inode(%v) err(%v)", source.name, sourceInode, err)
+		return nil, err
+	}
+
+	// copy the extents and create the file with them, which is released unless linked
+	var copies []proto.ExtentKey
+	if copies, err = v.ec.CopyExtents(source.ec, extents); err != nil {
+		log.LogErrorf("CopyFileFrom: data copy extents fail: source(%v/%v) inode(%v) err(%v)",
+			source.name, sourcePath, sourceInode, err)
+		return nil, err
+	}
+	var inodeInfo *proto.InodeInfo
+	if inodeInfo, err = v.mw.InodeCloneExtents_ll(mode, 0, 0, copies); err != nil {
+		log.LogErrorf("CopyFileFrom: meta clone extents fail: source(%v/%v) extents(%v) err(%v)",
+			source.name, sourcePath, len(copies), err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			v.releaseInode(inodeInfo.Inode)
+		}
+	}()
+
+	var xattrs = map[string]string{
+		XAttrKeyOSSETag:    xAttrInfo.XAttrs[XAttrKeyOSSETag],
+		XAttrKeyOSSSSE:     xAttrInfo.XAttrs[XAttrKeyOSSSSE],
+		XAttrKeyOSSTagging: xAttrInfo.XAttrs[XAttrKeyOSSTagging],
+	}
+	if opt != nil {
+		xattrs[XAttrKeyOSSTagging] = ""
+		if opt.Tagging != nil {
+			xattrs[XAttrKeyOSSTagging] = opt.Tagging.Encode()
+		}
+	}
+
+	// link the file to the target, the file overwritten is kept as a non-current version
+	dirs, filename := splitPath(targetPath)
+	var parentID uint64
+	if parentID, err = v.lookupDirectories(dirs, true); err != nil {
+		return nil, err
+	}
+	var quotaIDs []uint32
+	if quotaIDs, err = v.mw.CheckQuota(parentID); err != nil {
+		log.LogWarnf("CopyFileFrom: check quota fail: target(%v) parentID(%v) err(%v)", targetPath, parentID, err)
+		return nil, err
+	}
+	if len(quotaIDs) > 0 {
+		xattrs[proto.QuotaXAttrKey] = proto.FormatQuotaIDs(quotaIDs)
+	}
+	// the metadata of the object is set at once before it is linked
+	var attrs = make(map[string][]byte, len(xattrs))
+	for key, val := range xattrs {
+		if val != "" {
+			attrs[key] = []byte(val)
+		}
+	}
+	if err = v.mw.XAttrsSet_ll(inodeInfo.Inode, attrs); err != nil {
+		log.LogErrorf("CopyFileFrom: meta set xattrs fail: inode(%v) count(%v) err(%v)", inodeInfo.Inode, len(attrs), err)
+		return nil, err
+	}
+	var existInode uint64
+	var existMode uint32
+	existInode, existMode, err = v.mw.Lookup_ll(parentID, filename)
+	switch {
+	case err == syscall.ENOENT:
+		err = v.mw.DentryCreate_ll(parentID, filename, inodeInfo.Inode, mode)
+	case err != nil:
+	case os.FileMode(existMode).IsDir():
+		err = syscall.EEXIST
+	default:
+		if v.versioningEnabled() {
+			if err = v.archiveVersion(dirs, filename, existInode); err != nil {
+				break
+			}
+		}
+		if existInode, err = v.mw.DentryUpdate_ll(parentID, filename, inodeInfo.Inode); err == nil {
+			v.releaseInode(existInode)
+		}
+	}
+	if err != nil {
+		log.LogErrorf("CopyFileFrom: link file fail: target(%v) parentID(%v) inode(%v) err(%v)",
+			targetPath, parentID, inodeInfo.Inode, err)
+		return nil, err
+	}
+
+	info = &FSFileInfo{
+		Path:       targetPath,
+		Size:       int64(inodeInfo.Size),
+		Mode:       os.FileMode(inodeInfo.Mode),
+		ModifyTime: inodeInfo.ModifyTime,
+		ETag:       xattrs[XAttrKeyOSSETag],
+		Inode:      inodeInfo.Inode,
+		SSE:        sseAlgorithm(xattrs[XAttrKeyOSSSSE]),
+		Tagging:    xattrs[XAttrKeyOSSTagging],
+	}
+	return
+}
+
 func (v *volume) copyFile(parentID uint64, newFileName string, sourceFileInode uint64, mode uint32) (info *proto.InodeInfo, err error) {
 
 	if err = v.mw.DentryCreate_ll(parentID, newFileName, sourceFileInode, mode); err != nil {
@@ -0,0 +1,311 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/object-lifecycle-mgmt.html
+
+const (
+	BucketLifecycleLimitSize = 1 << 20
+	MaxLifecycleRules        = 1000
+	MaxLifecycleRuleIDLength = 255
+
+	LifecycleStatusEnabled  = "Enabled"
+	LifecycleStatusDisabled = "Disabled"
+
+	defaultLifecycleInterval = time.Hour
+	lifecycleDay             = 24 * time.Hour
+)
+
+type LifecycleConfiguration struct {
+	XMLName xml.Name         `xml:"LifecycleConfiguration"`
+	Rules   []*LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID                             string                          `xml:"ID,omitempty"`
+	Prefix                         *string                         `xml:"Prefix"` // deprecated by the filter
+	Filter                         *LifecycleFilter                `xml:"Filter"`
+	Status                         string                          `xml:"Status"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload"`
+}
+
+// LifecycleFilter only supports the prefix, the rules filtering the tags are rejected.
+type LifecycleFilter struct {
+	Prefix string    `xml:"Prefix"`
+	Tag    *struct{} `xml:"Tag"`
+	And    *struct{} `xml:"And"`
+}
+
+// LifecycleExpiration only supports the days, the rules expiring the objects at a date are rejected.
+type LifecycleExpiration struct {
+	Days int     `xml:"Days,omitempty"`
+	Date *string `xml:"Date"`
+}
+
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// NewLifecycleConfiguration returns the S3 lifecycle configuration of the rules kept by the master.
+func NewLifecycleConfiguration(rules []*proto.LifecycleRule) *LifecycleConfiguration {
+	lc := &LifecycleConfiguration{Rules: make([]*LifecycleRule, 0, len(rules))}
+	for _, rule := range rules {
+		r := &LifecycleRule{
+			ID:     rule.ID,
+			Filter: &LifecycleFilter{Prefix: rule.Prefix},
+			Status: LifecycleStatusDisabled,
+		}
+		if rule.Enabled {
+			r.Status = LifecycleStatusEnabled
+		}
+		if rule.ExpirationDays > 0 {
+			r.Expiration = &LifecycleExpiration{Days: rule.ExpirationDays}
+		}
+		if rule.AbortIncompleteMultipartUploadDays > 0 {
+			r.AbortIncompleteMultipartUpload = &AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: rule.AbortIncompleteMultipartUploadDays,
+			}
+		}
+		lc.Rules = append(lc.Rules, r)
+	}
+	return lc
+}
+
+// ProtoRules validates the configuration and returns the rules to be kept by the master. The
+// rules without IDs are given random ones.
+func (lc *LifecycleConfiguration) ProtoRules() (rules []*proto.LifecycleRule, ec *ErrorCode) {
+	if len(lc.Rules) == 0 || len(lc.Rules) > MaxLifecycleRules {
+		return nil, &MalformedXML
+	}
+	ids := make(map[string]bool, len(lc.Rules))
+	rules = make([]*proto.LifecycleRule, 0, len(lc.Rules))
+	for _, r := range lc.Rules {
+		rule := &proto.LifecycleRule{ID: r.ID}
+		if rule.ID == "" {
+			rule.ID = util.RandomString(16, util.Numeric|util.LowerLetter|util.UpperLetter)
+		}
+		if len(rule.ID) > MaxLifecycleRuleIDLength || ids[rule.ID] {
+			return nil, &InvalidArgument
+		}
+		ids[rule.ID] = true
+
+		switch r.Status {
+		case LifecycleStatusEnabled:
+			rule.Enabled = true
+		case LifecycleStatusDisabled:
+		default:
+			return nil, &MalformedXML
+		}
+
+		if r.Prefix != nil && r.Filter != nil {
+			return nil, &MalformedXML
+		}
+		if r.Prefix != nil {
+			rule.Prefix = *r.Prefix
+		}
+		if r.Filter != nil {
+			if r.Filter.Tag != nil || r.Filter.And != nil {
+				return nil, &NotImplemented
+			}
+			rule.Prefix = r.Filter.Prefix
+		}
+
+		if r.Expiration != nil {
+			if r.Expiration.Date != nil {
+				return nil, &NotImplemented
+			}
+			if r.Expiration.Days <= 0 {
+				return nil, &InvalidArgument
+			}
+			rule.ExpirationDays = r.Expiration.Days
+		}
+		if r.AbortIncompleteMultipartUpload != nil {
+			if r.AbortIncompleteMultipartUpload.DaysAfterInitiation <= 0 {
+				return nil, &InvalidArgument
+			}
+			rule.AbortIncompleteMultipartUploadDays = r.AbortIncompleteMultipartUpload.DaysAfterInitiation
+		}
+		if rule.ExpirationDays == 0 && rule.AbortIncompleteMultipartUploadDays == 0 {
+			return nil, &MalformedXML
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// runLifecycle executes the lifecycle rules of all the buckets periodically. The rules are kept by
+// the master, so that every object node executes them, and the objects expired or the uploads
+// aborted by an object node are skipped by the others.
+func (o *ObjectNode) runLifecycle() {
+	ticker := time.NewTicker(o.lifecycleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stopC:
+			return
+		case <-ticker.C:
+		}
+		lcs, err := o.mc.AdminAPI().ListVolumeLifecycles()
+		if err != nil {
+			log.LogWarnf("runLifecycle: list lifecycle configurations from master fail: err(%v)", err)
+			continue
+		}
+		for _, lc := range lcs {
+			o.executeLifecycle(lc, time.Now())
+		}
+	}
+}
+
+func (o *ObjectNode) executeLifecycle(lc *proto.LifecycleConfiguration, now time.Time) {
+	vol, err := o.getVol(lc.VolName)
+	if err != nil {
+		log.LogWarnf("executeLifecycle: load volume fail: volume(%v) err(%v)", lc.VolName, err)
+		return
+	}
+	for _, rule := range lc.Rules {
+		if !rule.Enabled {
+			continue
+		}
+		if rule.ExpirationDays > 0 {
+			deadline := now.Add(-time.Duration(rule.ExpirationDays) * lifecycleDay)
+			expired, err := vol.expireFiles(proto.RootIno, "", rule.Prefix, deadline, o.stopC)
+			if err != nil {
+				log.LogWarnf("executeLifecycle: expire objects fail: volume(%v) rule(%v) err(%v)", lc.VolName, rule.ID, err)
+			}
+			log.LogInfof("executeLifecycle: objects expired: volume(%v) rule(%v) prefix(%v) count(%v)",
+				lc.VolName, rule.ID, rule.Prefix, expired)
+		}
+		if rule.AbortIncompleteMultipartUploadDays > 0 {
+			deadline := now.Add(-time.Duration(rule.AbortIncompleteMultipartUploadDays) * lifecycleDay)
+			aborted, err := vol.abortExpiredMultiparts(rule.Prefix, deadline, o.stopC)
+			if err != nil {
+				log.LogWarnf("executeLifecycle: abort multipart uploads fail: volume(%v) rule(%v) err(%v)", lc.VolName, rule.ID, err)
+			}
+			log.LogInfof("executeLifecycle: multipart uploads aborted: volume(%v) rule(%v) prefix(%v) count(%v)",
+				lc.VolName, rule.ID, rule.Prefix, aborted)
+		}
+	}
+}
+
+// expireFiles deletes the files with the prefix modified before the deadline under the directory,
+// and only walks into the subdirectories which may contain such files. The files failed to be
+// deleted are left to the next run.
+func (v *volume) expireFiles(dirIno uint64, dir, prefix string, deadline time.Time, stopC <-chan struct{}) (expired int, err error) {
+	children, err := v.mw.ReadDir_ll(dirIno)
+	if err != nil {
+		return
+	}
+	var paths = make(map[uint64]string)
+	var inodes = make([]uint64, 0)
+	for _, child := range children {
+		if dirIno == proto.RootIno && child.Name == versionsDirName {
+			continue
+		}
+		path := dir + child.Name
+		if os.FileMode(child.Type).IsDir() {
+			if !strings.HasPrefix(path+"/", prefix) && !strings.HasPrefix(prefix, path+"/") {
+				continue
+			}
+			var n int
+			n, err = v.expireFiles(child.Inode, path+"/", prefix, deadline, stopC)
+			expired += n
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if strings.HasPrefix(path, prefix) {
+			paths[child.Inode] = path
+			inodes = append(inodes, child.Inode)
+		}
+	}
+	for start := 0; start < len(inodes); start += MaxKeys {
+		end := start + MaxKeys
+		if end > len(inodes) {
+			end = len(inodes)
+		}
+		select {
+		case <-stopC:
+			return
+		default:
+		}
+		for _, info := range v.mw.BatchInodeGet(inodes[start:end]) {
+			if !info.ModifyTime.Before(deadline) {
+				continue
+			}
+			if _, err := v.DeleteFile(paths[info.Inode]); err != nil {
+				if err != syscall.ENOENT {
+					log.LogWarnf("expireFiles: delete file fail: volume(%v) path(%v) err(%v)", v.name, paths[info.Inode], err)
+				}
+				continue
+			}
+			expired++
+		}
+	}
+	return
+}
+
+// abortExpiredMultiparts aborts the multipart uploads of the keys with the prefix initiated before
+// the deadline. The uploads are listed in the order of their IDs, which are listed from the marker
+// by every meta partition.
+func (v *volume) abortExpiredMultiparts(prefix string, deadline time.Time, stopC <-chan struct{}) (aborted int, err error) {
+	var marker string
+	for {
+		var sessions []*proto.MultipartInfo
+		if sessions, err = v.mw.ListMultipart_ll(prefix, "", "", marker, MaxUploads); err != nil {
+			return
+		}
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+		var truncated bool
+		if len(sessions) > MaxUploads {
+			marker = sessions[MaxUploads].ID
+			sessions = sessions[:MaxUploads]
+			truncated = true
+		}
+		for _, session := range sessions {
+			if !session.InitTime.Before(deadline) {
+				continue
+			}
+			select {
+			case <-stopC:
+				return
+			default:
+			}
+			if err := v.AbortMultipart(session.Path, session.ID); err != nil {
+				log.LogWarnf("abortExpiredMultiparts: abort multipart fail: volume(%v) path(%v) multipartID(%v) err(%v)",
+					v.name, session.Path, session.ID, err)
+				continue
+			}
+			aborted++
+		}
+		if !truncated {
+			return
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Get bucket lifecycle
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycleConfiguration.html
+func (o *ObjectNode) getBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketLifecycleHandler: get bucket lifecycle: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, _, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var lc *proto.LifecycleConfiguration
+	if lc, err = o.mc.AdminAPI().GetVolumeLifecycle(bucket); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: get lifecycle from master fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	if len(lc.Rules) == 0 {
+		_ = NoSuchLifecycleConfiguration.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(NewLifecycleConfiguration(lc.Rules)); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put bucket lifecycle
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
+func (o *ObjectNode) putBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketLifecycleHandler: put bucket lifecycle: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, _, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketLifecycleHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if r.ContentLength > BucketLifecycleLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketLifecycleLimitSize+1)); err != nil {
+		log.LogErrorf("putBucketLifecycleHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	if len(body) > BucketLifecycleLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+	var lc = &LifecycleConfiguration{}
+	if err = UnmarshalXMLEntity(body, lc); err != nil {
+		log.LogWarnf("putBucketLifecycleHandler: unmarshal lifecycle fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	rules, ec := lc.ProtoRules()
+	if ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+
+	if err = o.setVolumeLifecycle(bucket, rules); err != nil {
+		log.LogErrorf("putBucketLifecycleHandler: set lifecycle to master fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketLifecycleHandler: bucket lifecycle set: requestID(%v) bucket(%v) rules(%v)",
+		RequestIDFromRequest(r), bucket, len(rules))
+	return
+}
+
+// Delete bucket lifecycle
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
+func (o *ObjectNode) deleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("deleteBucketLifecycleHandler: delete bucket lifecycle: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, _, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("deleteBucketLifecycleHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if err = o.setVolumeLifecycle(bucket, nil); err != nil {
+		log.LogErrorf("deleteBucketLifecycleHandler: delete lifecycle from master fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// setVolumeLifecycle sets the lifecycle rules to the master.
+func (o *ObjectNode) setVolumeLifecycle(bucket string, rules []*proto.LifecycleRule) (err error) {
+	var authKey string
+	if authKey, err = o.volumeAuthKey(bucket); err != nil {
+		return
+	}
+	return o.mc.AdminAPI().SetVolumeLifecycle(bucket, authKey, rules)
+}
+
+// volumeAuthKey returns the auth key of the owner of the volume for the settings to the master,
+// since the requests have been authenticated by the keys of the bucket.
+func (o *ObjectNode) volumeAuthKey(bucket string) (authKey string, err error) {
+	var view *proto.SimpleVolView
+	if view, err = o.mc.AdminAPI().GetVolumeSimpleInfo(bucket); err != nil {
+		return
+	}
+	sum := md5.Sum([]byte(view.Owner))
+	return hex.EncodeToString(sum[:]), nil
+}
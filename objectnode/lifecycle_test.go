@@ -0,0 +1,100 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+)
+
+func TestLifecycleConfiguration(t *testing.T) {
+	body := `<LifecycleConfiguration>
+  <Rule>
+    <ID>logs</ID>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <Status>Enabled</Status>
+    <Expiration><Days>30</Days></Expiration>
+  </Rule>
+  <Rule>
+    <Prefix>tmp/</Prefix>
+    <Status>Disabled</Status>
+    <AbortIncompleteMultipartUpload><DaysAfterInitiation>7</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+  </Rule>
+</LifecycleConfiguration>`
+	lc := &LifecycleConfiguration{}
+	if err := UnmarshalXMLEntity([]byte(body), lc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	rules, ec := lc.ProtoRules()
+	if ec != nil {
+		t.Fatalf("rules: %v", ec.ErrorCode)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("rules %v, expect 2", len(rules))
+	}
+	if r := rules[0]; r.ID != "logs" || r.Prefix != "logs/" || !r.Enabled || r.ExpirationDays != 30 {
+		t.Fatalf("rule 0: %+v", *r)
+	}
+	if r := rules[1]; r.ID == "" || r.Prefix != "tmp/" || r.Enabled || r.AbortIncompleteMultipartUploadDays != 7 {
+		t.Fatalf("rule 1: %+v", *r)
+	}
+
+	rlc := NewLifecycleConfiguration(rules)
+	marshaled, err := MarshalXMLEntity(rlc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	lc = &LifecycleConfiguration{}
+	if err = UnmarshalXMLEntity(marshaled, lc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	rrules, ec := lc.ProtoRules()
+	if ec != nil {
+		t.Fatalf("rules: %v", ec.ErrorCode)
+	}
+	for i := range rules {
+		if *rules[i] != *rrules[i] {
+			t.Fatalf("rule %v: %+v, expect %+v", i, *rrules[i], *rules[i])
+		}
+	}
+}
+
+func TestLifecycleConfigurationInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		code string
+	}{
+		{`<LifecycleConfiguration></LifecycleConfiguration>`, MalformedXML.ErrorCode},
+		{`<LifecycleConfiguration><Rule><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+			MalformedXML.ErrorCode},
+		{`<LifecycleConfiguration><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>`, MalformedXML.ErrorCode},
+		{`<LifecycleConfiguration><Rule><Status>Enabled</Status><Expiration><Days>0</Days></Expiration></Rule></LifecycleConfiguration>`,
+			InvalidArgument.ErrorCode},
+		{`<LifecycleConfiguration><Rule><Status>Enabled</Status><Expiration><Date>2020-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+			NotImplemented.ErrorCode},
+		{`<LifecycleConfiguration><Rule><Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+			NotImplemented.ErrorCode},
+		{`<LifecycleConfiguration><Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule>` +
+			`<Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule></LifecycleConfiguration>`,
+			InvalidArgument.ErrorCode},
+	} {
+		lc := &LifecycleConfiguration{}
+		if err := UnmarshalXMLEntity([]byte(tc.body), lc); err != nil {
+			t.Fatalf("unmarshal %v: %v", tc.body, err)
+		}
+		if _, ec := lc.ProtoRules(); ec == nil || ec.ErrorCode != tc.code {
+			t.Fatalf("%v: error %v, expect %v", tc.body, ec, tc.code)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The objects are listed in the key order by walking the directories with the pages of the
+// dentries listed by the meta nodes, which filter the names by the prefix and the marker and roll
+// up the names containing the delimiter, so that a huge directory is never read as a whole. The
+// keys and the common prefixes are both counted by the max keys, and the marker is exclusive, so
+// the next marker is the last key or common prefix of the page.
+
+// dentryLister lists the dentries of the directories, which is the meta wrapper of the volume.
+type dentryLister interface {
+	Lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error)
+	ListDentry_ll(parentID uint64, prefix, delimiter, marker string, limit uint64) (*proto.ListDentryResponse, error)
+}
+
+type objectLister struct {
+	mw        dentryLister
+	prefix    string
+	marker    string
+	delimiter string
+	maxKeys   uint64
+
+	infos       []*FSFileInfo
+	prefixes    PrefixMap
+	count       uint64
+	nextMarker  string
+	isTruncated bool
+}
+
+func newObjectLister(mw dentryLister, prefix, marker, delimiter string, maxKeys uint64) *objectLister {
+	return &objectLister{
+		mw:        mw,
+		prefix:    prefix,
+		marker:    marker,
+		delimiter: delimiter,
+		maxKeys:   maxKeys,
+		prefixes:  PrefixMap(make(map[string]struct{})),
+	}
+}
+
+// list lists a page of the keys and the common prefixes after the marker.
+func (l *objectLister) list() error {
+	parentID, dir, err := l.findPrefixDir()
+	if err == syscall.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = l.listDir(parentID, dir); err != nil {
+		return err
+	}
+	if !l.isTruncated {
+		l.nextMarker = ""
+	}
+	return nil
+}
+
+// findPrefixDir returns the directory of all the complete directory names of the prefix, which
+// contains all the keys beginning with the prefix.
+func (l *objectLister) findPrefixDir() (ino uint64, dir string, err error) {
+	ino = rootIno
+	idx := strings.LastIndex(l.prefix, "/")
+	if idx < 0 {
+		return
+	}
+	for _, name := range strings.Split(l.prefix[:idx], "/") {
+		var mode uint32
+		if ino, mode, err = l.mw.Lookup_ll(ino, name); err != nil {
+			return
+		}
+		if !os.FileMode(mode).IsDir() {
+			return 0, "", syscall.ENOENT
+		}
+	}
+	return ino, l.prefix[:idx+1], nil
+}
+
+// listDir lists the keys and the common prefixes under the directory, and returns true once the
+// page is full. Since the keys of a directory end with '/', a subdirectory is walked after the
+// names less than its key, like the file 'a-b' before the subdirectory 'a'.
+func (l *objectLister) listDir(parentID uint64, dir string) (full bool, err error) {
+	if l.marker > dir && !strings.HasPrefix(l.marker, dir) {
+		// all the keys of the directory are before the marker
+		return false, nil
+	}
+	var namePrefix, marker string
+	if len(l.prefix) > len(dir) {
+		namePrefix = l.prefix[len(dir):]
+	}
+	var pending []proto.Dentry
+	if strings.HasPrefix(l.marker, dir) {
+		marker = l.marker[len(dir):]
+		if idx := strings.Index(marker, "/"); idx >= 0 {
+			marker = marker[:idx]
+		}
+		if pending, err = l.markerDirs(parentID, namePrefix, marker); err != nil {
+			return
+		}
+	}
+
+	for {
+		var resp *proto.ListDentryResponse
+		if resp, err = l.mw.ListDentry_ll(parentID, namePrefix, l.delimiter, marker, l.maxKeys-l.count+1); err != nil {
+			return
+		}
+		children, prefixes := resp.Children, resp.CommonPrefixes
+		for len(children) > 0 || len(prefixes) > 0 {
+			var name string
+			var child *proto.Dentry
+			if len(prefixes) == 0 || (len(children) > 0 && children[0].Name < prefixes[0]) {
+				child, name = &children[0], children[0].Name
+				children = children[1:]
+			} else {
+				name = prefixes[0]
+				prefixes = prefixes[1:]
+			}
+			for len(pending) > 0 && pending[0].Name+"/" < name {
+				if full, err = l.walkDir(dir, pending[0]); full || err != nil {
+					return
+				}
+				pending = pending[1:]
+			}
+			switch {
+			case child == nil:
+				full = l.addPrefix(dir + name)
+			case os.FileMode(child.Type).IsDir():
+				if parentID == rootIno && name == versionsDirName {
+					continue
+				}
+				idx := sort.Search(len(pending), func(i int) bool {
+					return pending[i].Name+"/" > name+"/"
+				})
+				pending = append(pending, proto.Dentry{})
+				copy(pending[idx+1:], pending[idx:])
+				pending[idx] = *child
+			default:
+				full = l.addKey(dir+name, child.Inode)
+			}
+			if full {
+				return
+			}
+		}
+		if !resp.IsTruncated {
+			break
+		}
+		marker = resp.NextMarker
+	}
+
+	for _, child := range pending {
+		if full, err = l.walkDir(dir, child); full || err != nil {
+			return
+		}
+	}
+	return false, nil
+}
+
+// markerDirs returns the subdirectories not before the marker name, but skipped by listing the
+// names after it. They are the one of the marker name, and the ones named after the prefixes of
+// the marker name followed by a byte less than '/', like the subdirectory 'a' of the marker 'a-b'.
+func (l *objectLister) markerDirs(parentID uint64, namePrefix, marker string) (dirs []proto.Dentry, err error) {
+	for i := len(namePrefix); i <= len(marker); i++ {
+		if i == 0 || (i < len(marker) && marker[i] >= '/') {
+			continue
+		}
+		ino, mode, lookupErr := l.mw.Lookup_ll(parentID, marker[:i])
+		if lookupErr == syscall.ENOENT {
+			continue
+		}
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if os.FileMode(mode).IsDir() {
+			dirs = append(dirs, proto.Dentry{Name: marker[:i], Inode: ino, Type: mode})
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].Name+"/" < dirs[j].Name+"/"
+	})
+	return
+}
+
+// walkDir lists the subdirectory, or adds the common prefix if its key contains the delimiter.
+func (l *objectLister) walkDir(dir string, child proto.Dentry) (full bool, err error) {
+	key := dir + child.Name + "/"
+	if commonPrefix := l.commonPrefix(key); commonPrefix != "" {
+		return l.addPrefix(commonPrefix), nil
+	}
+	return l.listDir(child.Inode, key)
+}
+
+// commonPrefix returns the key rolled up to the first delimiter after the prefix, or empty if the
+// key contains no delimiter after the prefix.
+func (l *objectLister) commonPrefix(key string) string {
+	if l.delimiter == "" || len(key) < len(l.prefix) {
+		return ""
+	}
+	if idx := strings.Index(key[len(l.prefix):], l.delimiter); idx >= 0 {
+		return key[:len(l.prefix)+idx+len(l.delimiter)]
+	}
+	return ""
+}
+
+func (l *objectLister) addKey(key string, inode uint64) (full bool) {
+	if key <= l.marker {
+		return false
+	}
+	if l.count >= l.maxKeys {
+		l.isTruncated = true
+		return true
+	}
+	l.count++
+	l.infos = append(l.infos, &FSFileInfo{
+		Inode: inode,
+		Path:  key,
+	})
+	l.nextMarker = key
+	return false
+}
+
+func (l *objectLister) addPrefix(prefix string) (full bool) {
+	if _, exist := l.prefixes[prefix]; exist || prefix == l.marker {
+		return false
+	}
+	if prefix < l.marker && !strings.HasPrefix(l.marker, prefix) {
+		return false
+	}
+	if l.count >= l.maxKeys {
+		l.isTruncated = true
+		return true
+	}
+	l.count++
+	l.prefixes.AddPrefix(prefix)
+	l.nextMarker = prefix
+	return false
+}
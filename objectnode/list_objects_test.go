@@ -0,0 +1,179 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// testDentryLister keeps the directories in memory, listing the dentries like the meta nodes.
+type testDentryLister struct {
+	dirs    map[uint64][]proto.Dentry
+	nextIno uint64
+}
+
+func newTestDentryLister(keys ...string) *testDentryLister {
+	l := &testDentryLister{dirs: make(map[uint64][]proto.Dentry), nextIno: rootIno + 1}
+	for _, key := range keys {
+		parentID := rootIno
+		names := strings.Split(key, "/")
+		for _, name := range names[:len(names)-1] {
+			ino, _, err := l.Lookup_ll(parentID, name)
+			if err != nil {
+				ino = l.add(parentID, name, uint32(os.ModeDir))
+			}
+			parentID = ino
+		}
+		l.add(parentID, names[len(names)-1], 0644)
+	}
+	return l
+}
+
+func (l *testDentryLister) add(parentID uint64, name string, mode uint32) uint64 {
+	l.nextIno++
+	children := append(l.dirs[parentID], proto.Dentry{Name: name, Inode: l.nextIno, Type: mode})
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	l.dirs[parentID] = children
+	return l.nextIno
+}
+
+func (l *testDentryLister) Lookup_ll(parentID uint64, name string) (uint64, uint32, error) {
+	for _, child := range l.dirs[parentID] {
+		if child.Name == name {
+			return child.Inode, child.Type, nil
+		}
+	}
+	return 0, 0, syscall.ENOENT
+}
+
+func (l *testDentryLister) ListDentry_ll(parentID uint64, prefix, delimiter, marker string, limit uint64) (*proto.ListDentryResponse, error) {
+	resp := &proto.ListDentryResponse{}
+	var count uint64
+	var lastPrefix string
+	for _, child := range l.dirs[parentID] {
+		if child.Name <= marker || !strings.HasPrefix(child.Name, prefix) {
+			continue
+		}
+		var commonPrefix string
+		if idx := strings.Index(child.Name[len(prefix):], delimiter); delimiter != "" && idx >= 0 {
+			commonPrefix = child.Name[:len(prefix)+idx+len(delimiter)]
+			if commonPrefix == lastPrefix || commonPrefix == marker {
+				continue
+			}
+		}
+		if count >= limit {
+			resp.IsTruncated = true
+			break
+		}
+		count++
+		if commonPrefix != "" {
+			lastPrefix = commonPrefix
+			resp.CommonPrefixes = append(resp.CommonPrefixes, commonPrefix)
+			resp.NextMarker = commonPrefix
+			continue
+		}
+		resp.Children = append(resp.Children, child)
+		resp.NextMarker = child.Name
+	}
+	return resp, nil
+}
+
+func testListObjects(mw dentryLister, prefix, marker, delimiter string, maxKeys uint64) (entries []string, nextMarker string, err error) {
+	lister := newObjectLister(mw, prefix, marker, delimiter, maxKeys)
+	if err = lister.list(); err != nil {
+		return
+	}
+	for _, info := range lister.infos {
+		entries = append(entries, info.Path)
+	}
+	for _, commonPrefix := range lister.prefixes.Prefixes() {
+		entries = append(entries, commonPrefix)
+	}
+	sort.Strings(entries)
+	if lister.isTruncated != (lister.nextMarker != "") {
+		err = syscall.EINVAL
+	}
+	return entries, lister.nextMarker, err
+}
+
+func TestObjectLister(t *testing.T) {
+	mw := newTestDentryLister("a-b", "a/x", "a/y/z", "a0", "b/c-1", "b/c-2", "b/d", versionsDirName+"/v")
+	cases := []struct {
+		prefix     string
+		marker     string
+		delimiter  string
+		maxKeys    uint64
+		entries    []string
+		nextMarker string
+	}{
+		{"", "", "", 100, []string{"a-b", "a/x", "a/y/z", "a0", "b/c-1", "b/c-2", "b/d"}, ""},
+		// the files less than the keys of the directory are listed first
+		{"", "", "", 2, []string{"a-b", "a/x"}, "a/x"},
+		{"", "a/x", "", 3, []string{"a/y/z", "a0", "b/c-1"}, "b/c-1"},
+		{"", "", "/", 100, []string{"a-b", "a/", "a0", "b/"}, ""},
+		{"", "", "/", 2, []string{"a-b", "a/"}, "a/"},
+		{"", "a/", "/", 3, []string{"a0", "b/"}, ""},
+		{"a", "", "/", 100, []string{"a-b", "a/", "a0"}, ""},
+		{"a/", "", "/", 100, []string{"a/x", "a/y/"}, ""},
+		{"b/c", "", "", 100, []string{"b/c-1", "b/c-2"}, ""},
+		{"b/", "", "-", 100, []string{"b/c-", "b/d"}, ""},
+		{"", "", "-", 100, []string{"a-", "a/x", "a/y/z", "a0", "b/c-", "b/d"}, ""},
+		{"", "b/c-", "-", 100, []string{"b/d"}, ""},
+		{"c/", "", "", 100, nil, ""},
+		{"a/x/", "", "", 100, nil, ""},
+	}
+	for i, c := range cases {
+		entries, nextMarker, err := testListObjects(mw, c.prefix, c.marker, c.delimiter, c.maxKeys)
+		if err != nil || !reflect.DeepEqual(entries, c.entries) || nextMarker != c.nextMarker {
+			t.Fatalf("case(%v): entries(%v) nextMarker(%v) err(%v), expect entries(%v) nextMarker(%v)",
+				i, entries, nextMarker, err, c.entries, c.nextMarker)
+		}
+	}
+}
+
+func TestObjectLister_Pages(t *testing.T) {
+	var keys []string
+	for _, name := range []string{"k", "k-1", "k-2", "k0", "m"} {
+		keys = append(keys, name, "d/"+name+"/x", "d/"+name+"/y")
+	}
+	mw := newTestDentryLister(keys...)
+	all, _, err := testListObjects(mw, "", "", "", 100)
+	if err != nil || len(all) != len(keys) {
+		t.Fatalf("list all: entries(%v) err(%v)", all, err)
+	}
+	var paged []string
+	var marker string
+	for {
+		entries, nextMarker, err := testListObjects(mw, "", marker, "", 2)
+		if err != nil {
+			t.Fatalf("list page: marker(%v) err(%v)", marker, err)
+		}
+		paged = append(paged, entries...)
+		if nextMarker == "" {
+			break
+		}
+		marker = nextMarker
+	}
+	if !reflect.DeepEqual(paged, all) {
+		t.Fatalf("paged entries(%v), expect(%v)", paged, all)
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/gorilla/mux"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/ServerLogs.html
+//
+// The access logs of the requests to a bucket with the logging enabled are batched by the object
+// node, and delivered as the objects of the target bucket periodically, or once a batch is large
+// enough. The records are in the format of the server access logs of Amazon S3, and the ones not
+// delivered yet are lost if the object node crashes.
+
+const (
+	BucketLoggingLimitSize = 1 << 10
+
+	defaultAccessLogInterval = 5 * time.Minute
+
+	// the size of a batch of the records delivered at once
+	accessLogBatchSize = 4 << 20
+
+	accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+	accessLogKeyFormat  = "2006-01-02-15-04-05"
+)
+
+type BucketLoggingStatus struct {
+	XMLName        xml.Name        `xml:"BucketLoggingStatus"`
+	LoggingEnabled *LoggingEnabled `xml:"LoggingEnabled,omitempty"`
+}
+
+type LoggingEnabled struct {
+	TargetBucket string `xml:"TargetBucket"`
+	TargetPrefix string `xml:"TargetPrefix"`
+}
+
+func (v *volume) loadLogging() (l *LoggingEnabled) {
+	v.om.loggingLock.RLock()
+	l = v.om.logging
+	v.om.loggingLock.RUnlock()
+	return
+}
+
+func (v *volume) storeLogging(l *LoggingEnabled) {
+	v.om.loggingLock.Lock()
+	v.om.logging = l
+	v.om.loggingLock.Unlock()
+	return
+}
+
+// loadBucketLogging loads the logging of the volume, which is nil if the logging is disabled.
+func (v *volume) loadBucketLogging() (l *LoggingEnabled, err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSLogging); err != nil {
+		log.LogErrorf("loadBucketLogging: load bucket logging fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	var status = &BucketLoggingStatus{}
+	if err = xml.Unmarshal(data, status); err != nil {
+		log.LogErrorf("loadBucketLogging: unmarshal bucket logging fail: volume(%v) err(%v)", v.name, err)
+		return nil, err
+	}
+	return status.LoggingEnabled, nil
+}
+
+// SetLogging stores the logging of the volume, or deletes it if the logging is nil. The change is
+// loaded by the other object nodes in OSSMetaUpdateDuration.
+func (v *volume) SetLogging(l *LoggingEnabled) (err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	if l == nil {
+		err = store.Delete(v.name, bucketRootPath, XAttrKeyOSSLogging)
+	} else {
+		var data []byte
+		if data, err = xml.Marshal(&BucketLoggingStatus{LoggingEnabled: l}); err != nil {
+			return
+		}
+		err = store.Put(v.name, bucketRootPath, XAttrKeyOSSLogging, data)
+	}
+	if err != nil {
+		return
+	}
+	v.storeLogging(l)
+	return
+}
+
+// accessLogRecord is a record of the server access log.
+type accessLogRecord struct {
+	BucketOwner        string
+	Bucket             string
+	Time               time.Time
+	RemoteIP           string
+	Requester          string
+	RequestID          string
+	Operation          string
+	Key                string
+	RequestURI         string
+	HTTPStatus         int
+	ErrorCode          string
+	BytesSent          int64
+	TotalTime          time.Duration
+	Referer            string
+	UserAgent          string
+	VersionID          string
+	SignatureVersion   string
+	AuthenticationType string
+	HostHeader         string
+}
+
+// String formats the record as a line of the server access log, in which the empty fields are '-'.
+func (rec *accessLogRecord) String() string {
+	var field = func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	var quoted = func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return strconv.Quote(s)
+	}
+	var bytesSent = "-"
+	if rec.BytesSent > 0 {
+		bytesSent = strconv.FormatInt(rec.BytesSent, 10)
+	}
+	var key = rec.Key
+	if key != "" {
+		key = strings.Replace(url.PathEscape(key), "%2F", "/", -1)
+	}
+	return strings.Join([]string{
+		field(rec.BucketOwner),
+		field(rec.Bucket),
+		"[" + rec.Time.Format(accessLogTimeFormat) + "]",
+		field(rec.RemoteIP),
+		field(rec.Requester),
+		field(rec.RequestID),
+		field(rec.Operation),
+		field(key),
+		quoted(rec.RequestURI),
+		strconv.Itoa(rec.HTTPStatus),
+		field(rec.ErrorCode),
+		bytesSent,
+		"-", // object size
+		strconv.FormatInt(int64(rec.TotalTime/time.Millisecond), 10),
+		"-", // turn around time
+		quoted(rec.Referer),
+		quoted(rec.UserAgent),
+		field(rec.VersionID),
+		"-", // host ID
+		field(rec.SignatureVersion),
+		"-", // cipher suite
+		field(rec.AuthenticationType),
+		field(rec.HostHeader),
+		"-", // TLS version
+	}, " ")
+}
+
+// accessLogSubresources are the names of the operations on the subresources, like REST.GET.ACL.
+var accessLogSubresources = map[string]string{
+	"acl":          "ACL",
+	"cors":         "CORS",
+	"delete":       "MULTI_OBJECT_DELETE",
+	"legal-hold":   "LEGAL_HOLD",
+	"lifecycle":    "LIFECYCLE",
+	"location":     "LOCATION",
+	"logging":      "LOGGING_STATUS",
+	"notification": "NOTIFICATION",
+	"object-lock":  "OBJECT_LOCK_CONFIGURATION",
+	"policy":       "BUCKETPOLICY",
+	"retention":    "RETENTION",
+	"select":       "SELECT",
+	"tagging":      "OBJECT_TAGGING",
+	"uploadId":     "UPLOAD",
+	"uploads":      "UPLOADS",
+	"versioning":   "VERSIONING",
+	"versions":     "BUCKETVERSIONS",
+	"website":      "WEBSITE",
+}
+
+// accessLogOperation returns the operation of the request, e.g. REST.PUT.OBJECT or REST.GET.ACL.
+func accessLogOperation(r *http.Request, object string) string {
+	var resource = "BUCKET"
+	if object != "" {
+		resource = "OBJECT"
+	}
+	var query = r.URL.Query()
+	for name, subresource := range accessLogSubresources {
+		if _, ok := query[name]; ok {
+			resource = subresource
+			break
+		}
+	}
+	return "REST." + r.Method + "." + resource
+}
+
+// accessLogAuthentication returns the signature version and the authentication type of the request.
+func accessLogAuthentication(r *http.Request) (signatureVersion, authenticationType string) {
+	var authorization = r.Header.Get(HeaderNameAuthorization)
+	var query = r.URL.Query()
+	switch {
+	case strings.HasPrefix(authorization, "AWS4-"):
+		return "SigV4", "AuthHeader"
+	case strings.HasPrefix(authorization, "AWS "):
+		return "SigV2", "AuthHeader"
+	case query.Get("X-Amz-Algorithm") != "":
+		return "SigV4", "QueryString"
+	case query.Get("Signature") != "":
+		return "SigV2", "QueryString"
+	}
+	return "", ""
+}
+
+type accessLogBatch struct {
+	bucket string
+	prefix string
+	buf    bytes.Buffer
+}
+
+// accessLogger batches the records of the access logs by the target buckets and the prefixes,
+// and delivers the batches by the deliver function.
+type accessLogger struct {
+	mu      sync.Mutex
+	batches map[string]*accessLogBatch
+	deliver func(bucket, key string, data []byte) error
+}
+
+func newAccessLogger(deliver func(bucket, key string, data []byte) error) *accessLogger {
+	return &accessLogger{batches: make(map[string]*accessLogBatch), deliver: deliver}
+}
+
+// append appends the record to the batch of the target, which is delivered asynchronously once
+// it is large enough.
+func (l *accessLogger) append(target *LoggingEnabled, rec *accessLogRecord) {
+	var id = target.TargetBucket + "/" + target.TargetPrefix
+	l.mu.Lock()
+	batch, ok := l.batches[id]
+	if !ok {
+		batch = &accessLogBatch{bucket: target.TargetBucket, prefix: target.TargetPrefix}
+		l.batches[id] = batch
+	}
+	batch.buf.WriteString(rec.String())
+	batch.buf.WriteByte('\n')
+	if batch.buf.Len() < accessLogBatchSize {
+		l.mu.Unlock()
+		return
+	}
+	delete(l.batches, id)
+	l.mu.Unlock()
+	go l.deliverBatch(batch, time.Now())
+}
+
+// flush delivers all the batches.
+func (l *accessLogger) flush(now time.Time) {
+	l.mu.Lock()
+	var batches = l.batches
+	l.batches = make(map[string]*accessLogBatch)
+	l.mu.Unlock()
+	for _, batch := range batches {
+		l.deliverBatch(batch, now)
+	}
+}
+
+// deliverBatch delivers the batch as an object named like the ones of Amazon S3, e.g.
+// 'logs/2020-01-02-03-04-05-0123456789ABCDEF'. The batch is dropped if it fails.
+func (l *accessLogger) deliverBatch(batch *accessLogBatch, now time.Time) {
+	var unique = make([]byte, 8)
+	_, _ = rand.Read(unique)
+	var key = batch.prefix + now.UTC().Format(accessLogKeyFormat) + "-" + strings.ToUpper(hex.EncodeToString(unique))
+	if err := l.deliver(batch.bucket, key, batch.buf.Bytes()); err != nil {
+		log.LogWarnf("deliverBatch: deliver access logs fail: bucket(%v) key(%v) size(%v) err(%v)",
+			batch.bucket, key, batch.buf.Len(), err)
+		return
+	}
+	log.LogDebugf("deliverBatch: access logs delivered: bucket(%v) key(%v) size(%v)", batch.bucket, key, batch.buf.Len())
+}
+
+// deliverAccessLogs writes the access logs as an object of the target bucket.
+func (o *ObjectNode) deliverAccessLogs(bucket, key string, data []byte) error {
+	vol, err := o.getVol(bucket)
+	if err != nil {
+		return err
+	}
+	_, err = vol.WriteFile(key, bytes.NewReader(data))
+	return err
+}
+
+func (o *ObjectNode) runAccessLogger() {
+	ticker := time.NewTicker(o.accessLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stopC:
+			return
+		case now := <-ticker.C:
+			o.accessLogger.flush(now)
+		}
+	}
+}
+
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	errorCode  string
+	bytesSent  int64
+}
+
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogResponseWriter) Write(p []byte) (n int, err error) {
+	n, err = w.ResponseWriter.Write(p)
+	w.bytesSent += int64(n)
+	return
+}
+
+func (w *accessLogResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// setErrorCode records the error code served by ErrorCode.ServeResponse.
+func (w *accessLogResponseWriter) setErrorCode(code string) {
+	w.errorCode = code
+}
+
+// accessLogMiddleware records the requests to the buckets with the logging enabled, including
+// the ones denied by the rate limits and the authentication.
+func (o *ObjectNode) accessLogMiddleware(next http.Handler) http.Handler {
+	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		var vars = mux.Vars(r)
+		if o.accessLogger == nil || vars["bucket"] == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		vol, err := o.getVol(vars["bucket"])
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var target = vol.loadLogging()
+		if target == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var startTime = time.Now()
+		var lw = &accessLogResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(lw, r)
+
+		var owner, _ = vol.OSSSecure()
+		var rec = &accessLogRecord{
+			BucketOwner: owner,
+			Bucket:      vars["bucket"],
+			Time:        startTime,
+			RemoteIP:    getRequestIP(r),
+			Requester:   parseRequestAuthInfo(r).accessKey,
+			RequestID:   RequestIDFromRequest(r),
+			Operation:   accessLogOperation(r, vars["object"]),
+			Key:         vars["object"],
+			RequestURI:  r.Method + " " + r.RequestURI + " " + r.Proto,
+			HTTPStatus:  lw.statusCode,
+			ErrorCode:   lw.errorCode,
+			BytesSent:   lw.bytesSent,
+			TotalTime:   time.Since(startTime),
+			Referer:     r.Header.Get("Referer"),
+			UserAgent:   r.Header.Get("User-Agent"),
+			VersionID:   r.URL.Query().Get(ParamVersionId),
+			HostHeader:  r.Host,
+		}
+		rec.SignatureVersion, rec.AuthenticationType = accessLogAuthentication(r)
+		o.accessLogger.append(target, rec)
+	}
+	return handlerFunc
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Get bucket logging
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLogging.html
+func (o *ObjectNode) getBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketLoggingHandler: get bucket logging: requestID(%v)", RequestIDFromRequest(r))
+	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketLoggingHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	// the status without the LoggingEnabled is returned if the logging is disabled
+	var status = &BucketLoggingStatus{LoggingEnabled: vl.loadLogging()}
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(status); err != nil {
+		log.LogErrorf("getBucketLoggingHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketLoggingHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put bucket logging, which disables the logging if the LoggingEnabled is absent. The target
+// bucket must be owned by the owner of the source bucket.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLogging.html
+func (o *ObjectNode) putBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketLoggingHandler: put bucket logging: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketLoggingHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketLoggingLimitSize)); err != nil {
+		log.LogErrorf("putBucketLoggingHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var status = &BucketLoggingStatus{}
+	if err = UnmarshalXMLEntity(body, status); err != nil {
+		log.LogWarnf("putBucketLoggingHandler: unmarshal logging fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+
+	if target := status.LoggingEnabled; target != nil {
+		if target.TargetBucket == "" {
+			_ = MalformedXML.ServeResponse(w, r)
+			return
+		}
+		var targetVol *volume
+		if targetVol, err = o.getVol(target.TargetBucket); err != nil {
+			log.LogWarnf("putBucketLoggingHandler: load target bucket fail: requestID(%v) target(%v) err(%v)",
+				RequestIDFromRequest(r), target.TargetBucket, err)
+			_ = InvalidTargetBucketForLogging.ServeResponse(w, r)
+			return
+		}
+		ownerAK, _ := vl.OSSSecure()
+		targetAK, _ := targetVol.OSSSecure()
+		if ownerAK != targetAK {
+			_ = InvalidTargetBucketForLogging.ServeResponse(w, r)
+			return
+		}
+	}
+
+	if err = vl.SetLogging(status.LoggingEnabled); err != nil {
+		log.LogErrorf("putBucketLoggingHandler: set logging fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketLoggingHandler: bucket logging set: requestID(%v) bucket(%v) logging(%v)",
+		RequestIDFromRequest(r), bucket, status.LoggingEnabled)
+	return
+}
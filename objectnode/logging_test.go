@@ -0,0 +1,121 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAccessLogRecord_String(t *testing.T) {
+	rec := &accessLogRecord{
+		BucketOwner:        "owner",
+		Bucket:             "bucket",
+		Time:               time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		RemoteIP:           "192.168.0.1",
+		Requester:          "requester",
+		RequestID:          "id",
+		Operation:          "REST.GET.OBJECT",
+		Key:                "dir/a b.txt",
+		RequestURI:         "GET /bucket/dir/a%20b.txt HTTP/1.1",
+		HTTPStatus:         404,
+		ErrorCode:          "NoSuchKey",
+		TotalTime:          25 * time.Millisecond,
+		UserAgent:          "aws-cli",
+		SignatureVersion:   "SigV4",
+		AuthenticationType: "AuthHeader",
+		HostHeader:         "s3.example.com",
+	}
+	expect := `owner bucket [02/Jan/2020:03:04:05 +0000] 192.168.0.1 requester id REST.GET.OBJECT dir/a%20b.txt ` +
+		`"GET /bucket/dir/a%20b.txt HTTP/1.1" 404 NoSuchKey - - 25 - - "aws-cli" - - SigV4 - AuthHeader s3.example.com -`
+	if line := rec.String(); line != expect {
+		t.Fatalf("line(%v), expect(%v)", line, expect)
+	}
+}
+
+func TestAccessLogOperation(t *testing.T) {
+	cases := []struct {
+		method    string
+		target    string
+		object    string
+		operation string
+	}{
+		{"GET", "/bucket/key", "key", "REST.GET.OBJECT"},
+		{"PUT", "/bucket", "", "REST.PUT.BUCKET"},
+		{"GET", "/bucket?acl", "", "REST.GET.ACL"},
+		{"PUT", "/bucket/key?tagging", "key", "REST.PUT.OBJECT_TAGGING"},
+		{"POST", "/bucket?delete", "", "REST.POST.MULTI_OBJECT_DELETE"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		if operation := accessLogOperation(r, c.object); operation != c.operation {
+			t.Fatalf("request(%v %v): operation(%v), expect(%v)", c.method, c.target, operation, c.operation)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/bucket/key?X-Amz-Algorithm=AWS4-HMAC-SHA256", nil)
+	if version, authType := accessLogAuthentication(r); version != "SigV4" || authType != "QueryString" {
+		t.Fatalf("signature version(%v) authentication type(%v)", version, authType)
+	}
+}
+
+func TestAccessLogger(t *testing.T) {
+	var mu sync.Mutex
+	delivered := make(map[string]string)
+	logger := newAccessLogger(func(bucket, key string, data []byte) error {
+		mu.Lock()
+		delivered[bucket+"/"+key] = string(data)
+		mu.Unlock()
+		return nil
+	})
+
+	targets := []*LoggingEnabled{
+		{TargetBucket: "logs", TargetPrefix: "a/"},
+		{TargetBucket: "logs", TargetPrefix: "b/"},
+	}
+	logger.append(targets[0], &accessLogRecord{RequestID: "1"})
+	logger.append(targets[1], &accessLogRecord{RequestID: "2"})
+	logger.append(targets[0], &accessLogRecord{RequestID: "3"})
+	logger.flush(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	if len(delivered) != 2 {
+		t.Fatalf("delivered(%v), expect 2 objects", len(delivered))
+	}
+	keyRegexp := regexp.MustCompile(`^logs/[ab]/2020-01-02-03-04-05-[0-9A-F]{16}$`)
+	for key, data := range delivered {
+		if !keyRegexp.MatchString(key) {
+			t.Fatalf("key(%v) mismatch", key)
+		}
+		lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+		expect := 2
+		if strings.HasPrefix(key, "logs/b/") {
+			expect = 1
+		}
+		if len(lines) != expect {
+			t.Fatalf("key(%v): lines(%v), expect(%v)", key, len(lines), expect)
+		}
+	}
+
+	// nothing is delivered if no record is appended
+	delivered = make(map[string]string)
+	logger.flush(time.Now())
+	if len(delivered) != 0 {
+		t.Fatalf("delivered(%v), expect nothing", len(delivered))
+	}
+}
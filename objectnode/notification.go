@@ -0,0 +1,356 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/NotificationHowTo.html
+//
+// The events of the objects created and removed are published to the destinations configured by
+// the notification of the bucket. The destinations are the targets of the object node, i.e. Kafka
+// topics, NATS subjects or HTTP webhooks, which are referred by the ARNs like
+// 'arn:cfs:sqs:<region>:<target id>:<target type>'. The events are published asynchronously, and
+// are lost if the target is unavailable for too long or the object node crashes.
+
+const (
+	BucketNotificationLimitSize = 1 << 16
+
+	EventObjectCreatedAll                     = "s3:ObjectCreated:*"
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedAll                     = "s3:ObjectRemoved:*"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+
+	FilterRuleNamePrefix = "prefix"
+	FilterRuleNameSuffix = "suffix"
+
+	notificationARNPrefix = "arn:cfs:sqs:"
+	eventTimeFormat       = "2006-01-02T15:04:05.000Z"
+)
+
+var supportedEvents = map[string]bool{
+	EventObjectCreatedAll:                     true,
+	EventObjectCreatedPut:                     true,
+	EventObjectCreatedCopy:                    true,
+	EventObjectCreatedCompleteMultipartUpload: true,
+	EventObjectRemovedAll:                     true,
+	EventObjectRemovedDelete:                  true,
+	EventObjectRemovedDeleteMarkerCreated:     true,
+}
+
+type NotificationConfiguration struct {
+	XMLName             xml.Name              `xml:"NotificationConfiguration"`
+	QueueConfigurations []*QueueConfiguration `xml:"QueueConfiguration,omitempty"`
+	TopicConfigurations []*TopicConfiguration `xml:"TopicConfiguration,omitempty"`
+}
+
+// QueueConfiguration publishes the events to a target of the object node.
+type QueueConfiguration struct {
+	ID     string              `xml:"Id,omitempty"`
+	Queue  string              `xml:"Queue"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+// TopicConfiguration is the same as the QueueConfiguration but for the topic.
+type TopicConfiguration struct {
+	ID     string              `xml:"Id,omitempty"`
+	Topic  string              `xml:"Topic"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+type NotificationFilter struct {
+	S3Key *S3KeyFilter `xml:"S3Key,omitempty"`
+}
+
+type S3KeyFilter struct {
+	FilterRules []*FilterRule `xml:"FilterRule"`
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// notificationRule is a queue or topic configuration with the validated filter.
+type notificationRule struct {
+	id     string
+	arn    string
+	events []string
+	prefix string
+	suffix string
+}
+
+// match reports whether the event of the key is published by the rule.
+func (rule *notificationRule) match(event, key string) bool {
+	if !strings.HasPrefix(key, rule.prefix) || !strings.HasSuffix(key, rule.suffix) {
+		return false
+	}
+	for _, e := range rule.events {
+		if e == event || strings.HasSuffix(e, ":*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func newNotificationRule(id, arn string, events []string, filter *NotificationFilter) (*notificationRule, error) {
+	if arn == "" {
+		return nil, errors.New("destination not specified")
+	}
+	if len(events) == 0 {
+		return nil, errors.New("events not specified")
+	}
+	for _, event := range events {
+		if !supportedEvents[event] {
+			return nil, fmt.Errorf("unsupported event %v", event)
+		}
+	}
+	rule := &notificationRule{id: id, arn: arn, events: events}
+	if filter == nil || filter.S3Key == nil {
+		return rule, nil
+	}
+	var names = make(map[string]bool)
+	for _, fr := range filter.S3Key.FilterRules {
+		var name = strings.ToLower(fr.Name)
+		if names[name] {
+			return nil, fmt.Errorf("duplicate filter rule %v", fr.Name)
+		}
+		names[name] = true
+		switch name {
+		case FilterRuleNamePrefix:
+			rule.prefix = fr.Value
+		case FilterRuleNameSuffix:
+			rule.suffix = fr.Value
+		default:
+			return nil, fmt.Errorf("invalid filter rule name %v", fr.Name)
+		}
+	}
+	return rule, nil
+}
+
+// rules validates the configuration and returns its rules.
+func (c *NotificationConfiguration) rules() (rules []*notificationRule, err error) {
+	var rule *notificationRule
+	for _, qc := range c.QueueConfigurations {
+		if rule, err = newNotificationRule(qc.ID, qc.Queue, qc.Events, qc.Filter); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	for _, tc := range c.TopicConfigurations {
+		if rule, err = newNotificationRule(tc.ID, tc.Topic, tc.Events, tc.Filter); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+func (v *volume) loadNotification() (c *NotificationConfiguration) {
+	v.om.notificationLock.RLock()
+	c = v.om.notification
+	v.om.notificationLock.RUnlock()
+	return
+}
+
+func (v *volume) storeNotification(c *NotificationConfiguration) {
+	v.om.notificationLock.Lock()
+	v.om.notification = c
+	v.om.notificationLock.Unlock()
+	return
+}
+
+// loadBucketNotification loads the notification of the volume, which is nil if not configured.
+func (v *volume) loadBucketNotification() (c *NotificationConfiguration, err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSNotification); err != nil {
+		log.LogErrorf("loadBucketNotification: load bucket notification fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	c = &NotificationConfiguration{}
+	if err = xml.Unmarshal(data, c); err != nil {
+		log.LogErrorf("loadBucketNotification: unmarshal bucket notification fail: volume(%v) err(%v)", v.name, err)
+		return nil, err
+	}
+	return
+}
+
+// SetNotification stores the notification of the volume, or deletes it if the configuration has
+// no destinations. The change is loaded by the other object nodes in OSSMetaUpdateDuration.
+func (v *volume) SetNotification(c *NotificationConfiguration) (err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	if len(c.QueueConfigurations) == 0 && len(c.TopicConfigurations) == 0 {
+		c = nil
+		err = store.Delete(v.name, bucketRootPath, XAttrKeyOSSNotification)
+	} else {
+		var data []byte
+		if data, err = xml.Marshal(c); err != nil {
+			return
+		}
+		err = store.Put(v.name, bucketRootPath, XAttrKeyOSSNotification, data)
+	}
+	if err != nil {
+		return
+	}
+	v.storeNotification(c)
+	return
+}
+
+// EventMessage is the message of the events published, in the format of Amazon S3.
+type EventMessage struct {
+	Records []*EventRecord `json:"Records"`
+}
+
+type EventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AWSRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      EventIdentity     `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                EventS3           `json:"s3"`
+}
+
+type EventIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type EventS3 struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationID string      `json:"configurationId"`
+	Bucket          EventBucket `json:"bucket"`
+	Object          EventObject `json:"object"`
+}
+
+type EventBucket struct {
+	Name          string        `json:"name"`
+	OwnerIdentity EventIdentity `json:"ownerIdentity"`
+	ARN           string        `json:"arn"`
+}
+
+type EventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// objectEvent is an event of an object to be published.
+type objectEvent struct {
+	name      string
+	key       string
+	size      int64
+	etag      string
+	versionID string
+}
+
+// newEventRecord returns the record of the event published by the rule.
+func newEventRecord(r *http.Request, region, bucket, owner string, rule *notificationRule, e *objectEvent, now time.Time) *EventRecord {
+	return &EventRecord{
+		EventVersion:      "2.1",
+		EventSource:       "cfs:s3",
+		AWSRegion:         region,
+		EventTime:         now.UTC().Format(eventTimeFormat),
+		EventName:         strings.TrimPrefix(e.name, "s3:"),
+		UserIdentity:      EventIdentity{PrincipalID: parseRequestAuthInfo(r).accessKey},
+		RequestParameters: map[string]string{"sourceIPAddress": getRequestIP(r)},
+		ResponseElements:  map[string]string{"x-amz-request-id": RequestIDFromRequest(r)},
+		S3: EventS3{
+			SchemaVersion:   "1.0",
+			ConfigurationID: rule.id,
+			Bucket: EventBucket{
+				Name:          bucket,
+				OwnerIdentity: EventIdentity{PrincipalID: owner},
+				ARN:           "arn:aws:s3:::" + bucket,
+			},
+			Object: EventObject{
+				Key:       url.QueryEscape(e.key),
+				Size:      e.size,
+				ETag:      e.etag,
+				VersionID: e.versionID,
+				Sequencer: fmt.Sprintf("%016X", now.UnixNano()),
+			},
+		},
+	}
+}
+
+// notifyEvents publishes the events of the objects of the bucket to the destinations of its
+// notification which the events match.
+func (o *ObjectNode) notifyEvents(r *http.Request, vol *volume, events ...*objectEvent) {
+	var c = vol.loadNotification()
+	if o.notifier == nil || c == nil {
+		return
+	}
+	rules, err := c.rules()
+	if err != nil {
+		log.LogWarnf("notifyEvents: invalid notification: requestID(%v) volume(%v) err(%v)", RequestIDFromRequest(r), vol.name, err)
+		return
+	}
+	var owner, _ = vol.OSSSecure()
+	var now = time.Now()
+	for _, e := range events {
+		for _, rule := range rules {
+			if !rule.match(e.name, e.key) {
+				continue
+			}
+			var msg = &EventMessage{Records: []*EventRecord{newEventRecord(r, o.region, vol.name, owner, rule, e, now)}}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			if !o.notifier.publish(rule.arn, data) {
+				log.LogWarnf("notifyEvents: event not published: requestID(%v) volume(%v) key(%v) event(%v) destination(%v)",
+					RequestIDFromRequest(r), vol.name, e.key, e.name, rule.arn)
+			}
+		}
+	}
+}
+
+// deleteEvent returns the event of deleting the object, which creates a delete marker if the
+// version of the marker is not empty.
+func deleteEvent(key, versionID, deleteMarker string) *objectEvent {
+	if deleteMarker != "" {
+		return &objectEvent{name: EventObjectRemovedDeleteMarkerCreated, key: key, versionID: deleteMarker}
+	}
+	return &objectEvent{name: EventObjectRemovedDelete, key: key, versionID: versionID}
+}
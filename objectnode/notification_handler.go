@@ -0,0 +1,102 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Get bucket notification
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+func (o *ObjectNode) getBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketNotificationHandler: get bucket notification: requestID(%v)", RequestIDFromRequest(r))
+	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketNotificationHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	// the empty configuration is returned if the notification is not configured
+	var c = vl.loadNotification()
+	if c == nil {
+		c = &NotificationConfiguration{}
+	}
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(c); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put bucket notification, which disables the notification if no destination is configured. The
+// destinations must be the targets of the object node.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+func (o *ObjectNode) putBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketNotificationHandler: put bucket notification: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketNotificationHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketNotificationLimitSize)); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var c = &NotificationConfiguration{}
+	if err = UnmarshalXMLEntity(body, c); err != nil {
+		log.LogWarnf("putBucketNotificationHandler: unmarshal notification fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	var rules []*notificationRule
+	if rules, err = c.rules(); err != nil {
+		log.LogWarnf("putBucketNotificationHandler: invalid notification: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InvalidArgument.ServeResponse(w, r)
+		return
+	}
+	for _, rule := range rules {
+		if o.notifier == nil || !contains(o.notifier.arns(), rule.arn) {
+			log.LogWarnf("putBucketNotificationHandler: unknown destination: requestID(%v) destination(%v)",
+				RequestIDFromRequest(r), rule.arn)
+			_ = InvalidNotificationDestination.ServeResponse(w, r)
+			return
+		}
+	}
+
+	if err = vl.SetNotification(c); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: set notification fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketNotificationHandler: bucket notification set: requestID(%v) bucket(%v) destinations(%v)",
+		RequestIDFromRequest(r), bucket, len(rules))
+	return
+}
@@ -0,0 +1,341 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	TargetTypeKafka   = "kafka"
+	TargetTypeNATS    = "nats"
+	TargetTypeWebhook = "webhook"
+
+	eventQueueSize    = 10000
+	eventBatchSize    = 100
+	eventMaxRetries   = 3
+	eventRetryBackoff = time.Second
+	eventSendTimeout  = 10 * time.Second
+)
+
+// eventTarget publishes the messages of the events, the batch is retried if an error is returned.
+type eventTarget interface {
+	send(msgs [][]byte) error
+	close() error
+}
+
+// newEventTarget creates the target from the address, the type of which is told by the scheme:
+//
+//	kafka://broker1:port,broker2:port/topic
+//	nats://[user:password@]host:port/subject
+//	http://host:port/path or https://host:port/path
+func newEventTarget(addr string) (target eventTarget, targetType string, err error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return
+	}
+	switch u.Scheme {
+	case "kafka":
+		var sink log.RemoteSink
+		if sink, err = log.NewRemoteSink(addr); err != nil {
+			return
+		}
+		return &kafkaTarget{sink: sink}, TargetTypeKafka, nil
+	case "nats":
+		var subject = strings.Trim(u.Path, "/")
+		if u.Host == "" || subject == "" {
+			return nil, "", fmt.Errorf("nats host or subject is not specified in %v", addr)
+		}
+		return &natsTarget{addr: u.Host, subject: subject, user: u.User}, TargetTypeNATS, nil
+	case "http", "https":
+		return &webhookTarget{url: addr, client: &http.Client{Timeout: eventSendTimeout}}, TargetTypeWebhook, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported notification target %v", addr)
+	}
+}
+
+// notificationARN returns the ARN of the target referred by the notifications of the buckets.
+func notificationARN(region, id, targetType string) string {
+	return notificationARNPrefix + region + ":" + id + ":" + targetType
+}
+
+// kafkaTarget appends the messages to a topic by the producer of the remote log.
+type kafkaTarget struct {
+	sink log.RemoteSink
+}
+
+func (t *kafkaTarget) send(msgs [][]byte) error {
+	var now = time.Now()
+	var records = make([]*log.RemoteRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		records = append(records, &log.RemoteRecord{Time: now, Level: log.InfoLevel, Module: ModuleName, Line: msg})
+	}
+	return t.sink.Send(records)
+}
+
+func (t *kafkaTarget) close() error {
+	return t.sink.Close()
+}
+
+// natsTarget publishes the messages to a subject by the text protocol of NATS, and waits for the
+// PONG of a PING after them, so that the messages rejected by the server fail the batch.
+type natsTarget struct {
+	addr    string
+	subject string
+	user    *url.Userinfo
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+func (t *natsTarget) connect() (err error) {
+	if t.conn, err = net.DialTimeout("tcp", t.addr, eventSendTimeout); err != nil {
+		return
+	}
+	t.reader = bufio.NewReader(t.conn)
+	t.conn.SetDeadline(time.Now().Add(eventSendTimeout))
+	// the server greets with the INFO
+	if _, err = t.reader.ReadString('\n'); err != nil {
+		return
+	}
+	var opts = map[string]interface{}{"verbose": false, "pedantic": false, "name": ModuleName}
+	if t.user != nil {
+		opts["user"] = t.user.Username()
+		opts["pass"], _ = t.user.Password()
+	}
+	data, _ := json.Marshal(opts)
+	if _, err = fmt.Fprintf(t.conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		return
+	}
+	return t.waitPong()
+}
+
+func (t *natsTarget) waitPong() error {
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = io.WriteString(t.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %v", line)
+		}
+	}
+}
+
+func (t *natsTarget) send(msgs [][]byte) (err error) {
+	if t.conn == nil {
+		if err = t.connect(); err != nil {
+			t.close()
+			return
+		}
+	}
+	defer func() {
+		if err != nil {
+			t.close()
+		}
+	}()
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		fmt.Fprintf(&buf, "PUB %v %v\r\n", t.subject, len(msg))
+		buf.Write(msg)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("PING\r\n")
+	t.conn.SetDeadline(time.Now().Add(eventSendTimeout))
+	if _, err = t.conn.Write(buf.Bytes()); err != nil {
+		return
+	}
+	return t.waitPong()
+}
+
+func (t *natsTarget) close() (err error) {
+	if t.conn != nil {
+		err = t.conn.Close()
+		t.conn = nil
+	}
+	return
+}
+
+// webhookTarget posts each message to the URL, which accepts it by a status of 2xx.
+type webhookTarget struct {
+	url    string
+	client *http.Client
+}
+
+func (t *webhookTarget) send(msgs [][]byte) error {
+	for len(msgs) > 0 {
+		resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(msgs[0]))
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook %v: status %v", t.url, resp.Status)
+		}
+		msgs = msgs[1:]
+	}
+	return nil
+}
+
+func (t *webhookTarget) close() error {
+	return nil
+}
+
+// eventQueue buffers the messages of a target and sends them in the background. When the queue is
+// full the new messages are dropped, and a batch is dropped if it fails after the retries, so that
+// an unavailable target never blocks the requests.
+type eventQueue struct {
+	arn    string
+	target eventTarget
+	queue  chan []byte
+	stopC  chan struct{}
+	doneC  chan struct{}
+}
+
+func newEventQueue(arn string, target eventTarget) *eventQueue {
+	q := &eventQueue{
+		arn:    arn,
+		target: target,
+		queue:  make(chan []byte, eventQueueSize),
+		stopC:  make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *eventQueue) run() {
+	defer close(q.doneC)
+	for {
+		var batch [][]byte
+		select {
+		case msg := <-q.queue:
+			batch = append(batch, msg)
+		case <-q.stopC:
+			for len(q.queue) > 0 {
+				batch = append(batch, <-q.queue)
+			}
+			if len(batch) > 0 {
+				q.sendBatch(batch, 1)
+			}
+			return
+		}
+		for len(q.queue) > 0 && len(batch) < eventBatchSize {
+			batch = append(batch, <-q.queue)
+		}
+		q.sendBatch(batch, eventMaxRetries)
+	}
+}
+
+func (q *eventQueue) sendBatch(batch [][]byte, tries int) {
+	var backoff = eventRetryBackoff
+	var err error
+	for i := 0; i < tries; i++ {
+		if i > 0 {
+			select {
+			case <-q.stopC:
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if err = q.target.send(batch); err == nil {
+			return
+		}
+	}
+	log.LogWarnf("sendBatch: events dropped: destination(%v) events(%v) err(%v)", q.arn, len(batch), err)
+}
+
+func (q *eventQueue) close() {
+	close(q.stopC)
+	<-q.doneC
+	_ = q.target.close()
+}
+
+// eventNotifier publishes the messages of the events to the targets by their ARNs.
+type eventNotifier struct {
+	queues map[string]*eventQueue
+}
+
+// newEventNotifier creates the targets configured by the IDs and the addresses.
+func newEventNotifier(region string, targets map[string]interface{}) (n *eventNotifier, err error) {
+	n = &eventNotifier{queues: make(map[string]*eventQueue)}
+	for id, value := range targets {
+		addr, ok := value.(string)
+		if !ok {
+			n.close()
+			return nil, fmt.Errorf("invalid address of notification target %v", id)
+		}
+		var target eventTarget
+		var targetType string
+		if target, targetType, err = newEventTarget(addr); err != nil {
+			n.close()
+			return nil, fmt.Errorf("notification target %v: %v", id, err)
+		}
+		var arn = notificationARN(region, id, targetType)
+		n.queues[arn] = newEventQueue(arn, target)
+		log.LogInfof("newEventNotifier: notification target: arn(%v) address(%v)", arn, addr)
+	}
+	return
+}
+
+// publish queues the message to the target of the ARN, and reports whether it is queued.
+func (n *eventNotifier) publish(arn string, msg []byte) bool {
+	q, ok := n.queues[arn]
+	if !ok {
+		return false
+	}
+	select {
+	case q.queue <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// arns returns the ARNs of the targets in order.
+func (n *eventNotifier) arns() (arns []string) {
+	for arn := range n.queues {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	return
+}
+
+// close sends the messages queued and closes the targets.
+func (n *eventNotifier) close() {
+	for _, q := range n.queues {
+		q.close()
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/object-lock.html
+//
+// The versions of the objects in a bucket with the object lock enabled can not be deleted
+// permanently or overwritten during their retention periods, or while their legal holds are on.
+// The object lock requires the versioning, which can not be suspended after the object lock is
+// enabled, so that a locked object is only hidden by a delete marker or a new version. The retention
+// and the legal hold of a version are kept in the xattrs of its inode by the meta node.
+
+const (
+	ObjectLockEnabled = "Enabled"
+
+	RetentionModeGovernance = "GOVERNANCE"
+	RetentionModeCompliance = "COMPLIANCE"
+
+	LegalHoldOn  = "ON"
+	LegalHoldOff = "OFF"
+
+	BucketObjectLockLimitSize = 1 << 10
+)
+
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+type ObjectLockRule struct {
+	DefaultRetention *DefaultRetention `xml:"DefaultRetention"`
+}
+
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+type ObjectRetention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Mode            string   `xml:"Mode,omitempty"`
+	RetainUntilDate string   `xml:"RetainUntilDate,omitempty"`
+}
+
+type ObjectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+func isValidRetentionMode(mode string) bool {
+	return mode == RetentionModeGovernance || mode == RetentionModeCompliance
+}
+
+func (c *ObjectLockConfiguration) Validate() *ErrorCode {
+	if c.ObjectLockEnabled != ObjectLockEnabled {
+		return &MalformedXML
+	}
+	if c.Rule == nil {
+		return nil
+	}
+	retention := c.Rule.DefaultRetention
+	if retention == nil || !isValidRetentionMode(retention.Mode) {
+		return &MalformedXML
+	}
+	if (retention.Days > 0) == (retention.Years > 0) || retention.Days < 0 || retention.Years < 0 {
+		return &InvalidArgument
+	}
+	return nil
+}
+
+// defaultRetention returns the retention of the new objects by the default retention rule, or an
+// empty one if there is no rule.
+func (c *ObjectLockConfiguration) defaultRetention(now time.Time) (retention *ObjectRetention) {
+	retention = &ObjectRetention{}
+	if c.Rule == nil || c.Rule.DefaultRetention == nil {
+		return
+	}
+	rule := c.Rule.DefaultRetention
+	retention.Mode = rule.Mode
+	retention.RetainUntilDate = now.AddDate(rule.Years, 0, rule.Days).UTC().Format(AMZTimeFormat)
+	return
+}
+
+// parseRetentionDate parses the date in ISO 8601, with or without the fractional seconds.
+func parseRetentionDate(date string) (time.Time, error) {
+	return time.Parse(time.RFC3339, date)
+}
+
+// Validate checks the retention is either empty to remove the retention, or has the mode and the
+// date in the future.
+func (r *ObjectRetention) Validate(now time.Time) *ErrorCode {
+	if r.Mode == "" && r.RetainUntilDate == "" {
+		return nil
+	}
+	if !isValidRetentionMode(r.Mode) {
+		return &MalformedXML
+	}
+	until, err := parseRetentionDate(r.RetainUntilDate)
+	if err != nil {
+		return &MalformedXML
+	}
+	if !until.After(now) {
+		return &InvalidArgument
+	}
+	return nil
+}
+
+// Encode encodes the retention as the value of the xattr, empty if there is no retention.
+func (r *ObjectRetention) Encode() string {
+	if r.Mode == "" {
+		return ""
+	}
+	return r.Mode + " " + r.RetainUntilDate
+}
+
+// parseObjectRetention parses the retention of the xattr, which is empty if there is none.
+func parseObjectRetention(raw string) *ObjectRetention {
+	retention := &ObjectRetention{}
+	if parts := strings.SplitN(raw, " ", 2); len(parts) == 2 {
+		retention.Mode, retention.RetainUntilDate = parts[0], parts[1]
+	}
+	return retention
+}
+
+// isActive tells whether the retention period is not over.
+func (r *ObjectRetention) isActive(now time.Time) bool {
+	if r.Mode == "" {
+		return false
+	}
+	until, err := parseRetentionDate(r.RetainUntilDate)
+	return err != nil || now.Before(until)
+}
+
+// checkRetentionChange checks the change of the retention of a version, the retention of the
+// compliance mode can only be extended, and the one of the governance mode can be shortened or
+// removed only if the governance is bypassed.
+func checkRetentionChange(old, new *ObjectRetention, bypassGovernance bool, now time.Time) *ErrorCode {
+	if !old.isActive(now) {
+		return nil
+	}
+	if old.Mode == RetentionModeGovernance && bypassGovernance {
+		return nil
+	}
+	if new.Mode == "" {
+		return &ObjectLocked
+	}
+	oldUntil, _ := parseRetentionDate(old.RetainUntilDate)
+	newUntil, _ := parseRetentionDate(new.RetainUntilDate)
+	if newUntil.Before(oldUntil) || (old.Mode == RetentionModeCompliance && new.Mode != RetentionModeCompliance) {
+		return &ObjectLocked
+	}
+	return nil
+}
+
+// checkVersionLocked returns the error code if the version can not be deleted by its legal hold
+// or its retention.
+func checkVersionLocked(info *FSFileInfo, bypassGovernance bool, now time.Time) *ErrorCode {
+	if info.LegalHold == LegalHoldOn {
+		return &ObjectLocked
+	}
+	retention := parseObjectRetention(info.Retention)
+	if retention.isActive(now) && (retention.Mode == RetentionModeCompliance || !bypassGovernance) {
+		return &ObjectLocked
+	}
+	return nil
+}
+
+// parseObjectLockHeaders returns the retention and the legal hold of the new object by the headers
+// of the request, or by the default retention of the bucket.
+func parseObjectLockHeaders(r *http.Request, config *ObjectLockConfiguration, now time.Time) (retention *ObjectRetention, legalHold string, ec *ErrorCode) {
+	mode := r.Header.Get(HeaderNameObjectLockMode)
+	until := r.Header.Get(HeaderNameObjectLockRetainUntilDate)
+	legalHold = r.Header.Get(HeaderNameObjectLockLegalHold)
+	if config == nil {
+		if mode != "" || until != "" || legalHold != "" {
+			return nil, "", &ObjectLockNotEnabled
+		}
+		return &ObjectRetention{}, "", nil
+	}
+	if legalHold != "" && legalHold != LegalHoldOn && legalHold != LegalHoldOff {
+		return nil, "", &InvalidArgument
+	}
+	if mode == "" && until == "" {
+		return config.defaultRetention(now), legalHold, nil
+	}
+	retention = &ObjectRetention{Mode: mode, RetainUntilDate: until}
+	if mode == "" || until == "" {
+		return nil, "", &InvalidArgument
+	}
+	if ec = retention.Validate(now); ec != nil {
+		return nil, "", &InvalidArgument
+	}
+	return
+}
+
+// setObjectLockHeaders sets the headers of the retention and the legal hold of the object.
+func setObjectLockHeaders(w http.ResponseWriter, info *FSFileInfo) {
+	if retention := parseObjectRetention(info.Retention); retention.Mode != "" {
+		w.Header().Set(HeaderNameObjectLockMode, retention.Mode)
+		w.Header().Set(HeaderNameObjectLockRetainUntilDate, retention.RetainUntilDate)
+	}
+	if info.LegalHold != "" {
+		w.Header().Set(HeaderNameObjectLockLegalHold, info.LegalHold)
+	}
+}
+
+func (v *volume) loadObjectLock() (c *ObjectLockConfiguration) {
+	v.om.objectLockLock.RLock()
+	c = v.om.objectLock
+	v.om.objectLockLock.RUnlock()
+	return
+}
+
+func (v *volume) storeObjectLock(c *ObjectLockConfiguration) {
+	v.om.objectLockLock.Lock()
+	v.om.objectLock = c
+	v.om.objectLockLock.Unlock()
+	return
+}
+
+// loadBucketObjectLock loads the object lock configuration of the volume, which is nil if the
+// object lock is not enabled.
+func (v *volume) loadBucketObjectLock() (c *ObjectLockConfiguration, err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSObjectLock); err != nil {
+		log.LogErrorf("loadBucketObjectLock: load bucket object lock fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	c = &ObjectLockConfiguration{}
+	if err = xml.Unmarshal(data, c); err != nil {
+		log.LogErrorf("loadBucketObjectLock: unmarshal bucket object lock fail: volume(%v) err(%v)", v.name, err)
+		return nil, err
+	}
+	return
+}
+
+// SetObjectLock stores the object lock configuration of the volume, which is loaded by the other
+// object nodes in OSSMetaUpdateDuration.
+func (v *volume) SetObjectLock(c *ObjectLockConfiguration) (err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = xml.Marshal(c); err != nil {
+		return
+	}
+	if err = store.Put(v.name, bucketRootPath, XAttrKeyOSSObjectLock, data); err != nil {
+		return
+	}
+	v.storeObjectLock(c)
+	return
+}
+
+// SetFileXAttr sets the xattr of the inode of the file or the version returned by FileInfo or
+// FileVersion.
+func (v *volume) SetFileXAttr(info *FSFileInfo, key string, data []byte) error {
+	return v.mw.XAttrSet_ll(info.Inode, []byte(key), data)
+}
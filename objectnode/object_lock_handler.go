@@ -0,0 +1,313 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Get object lock configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+func (o *ObjectNode) getBucketObjectLockHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketObjectLockHandler: get bucket object lock: requestID(%v)", RequestIDFromRequest(r))
+	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketObjectLockHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	config := vl.loadObjectLock()
+	if config == nil {
+		_ = ObjectLockConfigurationNotFound.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(config); err != nil {
+		log.LogErrorf("getBucketObjectLockHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketObjectLockHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put object lock configuration, which enables the object lock of the bucket with the versioning
+// enabled, and sets the default retention. The object lock can not be disabled once enabled.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
+func (o *ObjectNode) putBucketObjectLockHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketObjectLockHandler: put bucket object lock: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketObjectLockHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketObjectLockLimitSize)); err != nil {
+		log.LogErrorf("putBucketObjectLockHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var config = &ObjectLockConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		log.LogWarnf("putBucketObjectLockHandler: unmarshal object lock fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if ec := config.Validate(); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	if !vl.versioningEnabled() {
+		_ = InvalidBucketState.ServeResponse(w, r)
+		return
+	}
+
+	if err = vl.SetObjectLock(config); err != nil {
+		log.LogErrorf("putBucketObjectLockHandler: set object lock fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketObjectLockHandler: bucket object lock set: requestID(%v) bucket(%v) rule(%v)",
+		RequestIDFromRequest(r), bucket, config.Rule != nil)
+	return
+}
+
+// Get object retention
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
+func (o *ObjectNode) getObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getObjectRetentionHandler: get object retention: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getObjectRetentionHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if vl.loadObjectLock() == nil {
+		_ = ObjectLockNotEnabled.ServeResponse(w, r)
+		return
+	}
+	info := o.lockTargetVersion(w, r, vl, object)
+	if info == nil {
+		return
+	}
+	retention := parseObjectRetention(info.Retention)
+	if retention.Mode == "" {
+		_ = NoSuchObjectLockConfiguration.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(retention); err != nil {
+		log.LogErrorf("getObjectRetentionHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getObjectRetentionHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put object retention, which can only be extended in the compliance mode, and can be shortened
+// or removed in the governance mode only by the owner bypassing the governance.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
+func (o *ObjectNode) putObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putObjectRetentionHandler: put object retention: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putObjectRetentionHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if vl.loadObjectLock() == nil {
+		_ = ObjectLockNotEnabled.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketObjectLockLimitSize)); err != nil {
+		log.LogErrorf("putObjectRetentionHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var retention = &ObjectRetention{}
+	if err = UnmarshalXMLEntity(body, retention); err != nil {
+		log.LogWarnf("putObjectRetentionHandler: unmarshal retention fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	now := time.Now()
+	if ec := retention.Validate(now); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	info := o.lockTargetVersion(w, r, vl, object)
+	if info == nil {
+		return
+	}
+	if ec := checkRetentionChange(parseObjectRetention(info.Retention), retention, o.bypassGovernance(r, vl), now); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+
+	if err = vl.SetFileXAttr(info, XAttrKeyOSSRetention, []byte(retention.Encode())); err != nil {
+		log.LogErrorf("putObjectRetentionHandler: set retention fail: requestID(%v) path(%v) inode(%v) err(%v)",
+			RequestIDFromRequest(r), object, info.Inode, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putObjectRetentionHandler: object retention set: requestID(%v) path(%v) inode(%v) retention(%v)",
+		RequestIDFromRequest(r), object, info.Inode, retention.Encode())
+	return
+}
+
+// Get object legal hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
+func (o *ObjectNode) getObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getObjectLegalHoldHandler: get object legal hold: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getObjectLegalHoldHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if vl.loadObjectLock() == nil {
+		_ = ObjectLockNotEnabled.ServeResponse(w, r)
+		return
+	}
+	info := o.lockTargetVersion(w, r, vl, object)
+	if info == nil {
+		return
+	}
+	if info.LegalHold == "" {
+		_ = NoSuchObjectLockConfiguration.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(&ObjectLegalHold{Status: info.LegalHold}); err != nil {
+		log.LogErrorf("getObjectLegalHoldHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getObjectLegalHoldHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put object legal hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
+func (o *ObjectNode) putObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putObjectLegalHoldHandler: put object legal hold: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putObjectLegalHoldHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if vl.loadObjectLock() == nil {
+		_ = ObjectLockNotEnabled.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketObjectLockLimitSize)); err != nil {
+		log.LogErrorf("putObjectLegalHoldHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var legalHold = &ObjectLegalHold{}
+	if err = UnmarshalXMLEntity(body, legalHold); err != nil {
+		log.LogWarnf("putObjectLegalHoldHandler: unmarshal legal hold fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if legalHold.Status != LegalHoldOn && legalHold.Status != LegalHoldOff {
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	info := o.lockTargetVersion(w, r, vl, object)
+	if info == nil {
+		return
+	}
+
+	if err = vl.SetFileXAttr(info, XAttrKeyOSSLegalHold, []byte(legalHold.Status)); err != nil {
+		log.LogErrorf("putObjectLegalHoldHandler: set legal hold fail: requestID(%v) path(%v) inode(%v) err(%v)",
+			RequestIDFromRequest(r), object, info.Inode, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putObjectLegalHoldHandler: object legal hold set: requestID(%v) path(%v) inode(%v) status(%v)",
+		RequestIDFromRequest(r), object, info.Inode, legalHold.Status)
+	return
+}
+
+// lockTargetVersion returns the version of the object specified by the version ID in the request,
+// or the current version, and serves the error response if it does not exist.
+func (o *ObjectNode) lockTargetVersion(w http.ResponseWriter, r *http.Request, vl *volume, object string) *FSFileInfo {
+	if id := r.URL.Query().Get(ParamVersionId); id != "" {
+		if version := o.getObjectVersion(w, r, vl, object, id); version != nil {
+			return &version.FSFileInfo
+		}
+		return nil
+	}
+	info, err := vl.FileInfo(object)
+	if err != nil {
+		log.LogWarnf("lockTargetVersion: get file info fail: requestID(%v) path(%v) err(%v)", RequestIDFromRequest(r), object, err)
+		_ = NoSuchKey.ServeResponse(w, r)
+		return nil
+	}
+	return info
+}
+
+// bypassGovernance tells whether the request of the owner of the bucket bypasses the retention of
+// the governance mode.
+func (o *ObjectNode) bypassGovernance(r *http.Request, vl *volume) bool {
+	if !strings.EqualFold(r.Header.Get(HeaderNameBypassGovernanceRetention), "true") {
+		return false
+	}
+	accessKey, _ := vl.OSSSecure()
+	return parseRequestAuthInfo(r).accessKey == accessKey
+}
+
+// checkVersionDeletable returns the error code if the version of the object can not be deleted
+// permanently by the object lock.
+func (o *ObjectNode) checkVersionDeletable(r *http.Request, vl *volume, object, versionID string) *ErrorCode {
+	if vl.loadObjectLock() == nil {
+		return nil
+	}
+	version, err := vl.FileVersion(object, versionID)
+	if err != nil || version.DeleteMarker {
+		// the version not found is handled by the deletion
+		return nil
+	}
+	return checkVersionLocked(&version.FSFileInfo, o.bypassGovernance(r, vl), time.Now())
+}
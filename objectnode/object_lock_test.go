@@ -0,0 +1,131 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestObjectLockConfiguration_Validate(t *testing.T) {
+	cases := []struct {
+		body  string
+		valid bool
+	}{
+		{`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`, true},
+		{`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention>` +
+			`<Mode>GOVERNANCE</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`, true},
+		{`<ObjectLockConfiguration></ObjectLockConfiguration>`, false},
+		{`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention>` +
+			`<Mode>UNKNOWN</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`, false},
+		{`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention>` +
+			`<Mode>COMPLIANCE</Mode><Days>1</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`, false},
+		{`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention>` +
+			`<Mode>COMPLIANCE</Mode></DefaultRetention></Rule></ObjectLockConfiguration>`, false},
+	}
+	for i, c := range cases {
+		config := &ObjectLockConfiguration{}
+		if err := UnmarshalXMLEntity([]byte(c.body), config); err != nil {
+			t.Fatalf("case(%v): unmarshal: %v", i, err)
+		}
+		if ec := config.Validate(); (ec == nil) != c.valid {
+			t.Fatalf("case(%v): error(%v), expect valid(%v)", i, ec, c.valid)
+		}
+	}
+}
+
+func TestCheckRetentionChange(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	governance := &ObjectRetention{Mode: RetentionModeGovernance, RetainUntilDate: "2020-02-01T00:00:00Z"}
+	compliance := &ObjectRetention{Mode: RetentionModeCompliance, RetainUntilDate: "2020-02-01T00:00:00Z"}
+	expired := &ObjectRetention{Mode: RetentionModeCompliance, RetainUntilDate: "2019-12-01T00:00:00Z"}
+	shorter := &ObjectRetention{Mode: RetentionModeCompliance, RetainUntilDate: "2020-01-15T00:00:00Z"}
+	longer := &ObjectRetention{Mode: RetentionModeGovernance, RetainUntilDate: "2020-03-01T00:00:00Z"}
+	cases := []struct {
+		old, new *ObjectRetention
+		bypass   bool
+		allowed  bool
+	}{
+		{&ObjectRetention{}, compliance, false, true},
+		{expired, &ObjectRetention{}, false, true},
+		{governance, &ObjectRetention{}, false, false},
+		{governance, &ObjectRetention{}, true, true},
+		{governance, shorter, false, false},
+		{compliance, shorter, true, false},
+		{compliance, &ObjectRetention{Mode: RetentionModeCompliance, RetainUntilDate: "2020-03-01T00:00:00Z"}, false, true},
+		{compliance, longer, false, false},
+	}
+	for i, c := range cases {
+		if ec := checkRetentionChange(c.old, c.new, c.bypass, now); (ec == nil) != c.allowed {
+			t.Fatalf("case(%v): error(%v), expect allowed(%v)", i, ec, c.allowed)
+		}
+	}
+}
+
+func TestCheckVersionLocked(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		info    *FSFileInfo
+		bypass  bool
+		deleted bool
+	}{
+		{&FSFileInfo{}, false, true},
+		{&FSFileInfo{LegalHold: LegalHoldOn}, true, false},
+		{&FSFileInfo{LegalHold: LegalHoldOff}, false, true},
+		{&FSFileInfo{Retention: "GOVERNANCE 2020-02-01T00:00:00Z"}, false, false},
+		{&FSFileInfo{Retention: "GOVERNANCE 2020-02-01T00:00:00Z"}, true, true},
+		{&FSFileInfo{Retention: "COMPLIANCE 2020-02-01T00:00:00Z"}, true, false},
+		{&FSFileInfo{Retention: "COMPLIANCE 2019-12-01T00:00:00Z"}, false, true},
+	}
+	for i, c := range cases {
+		if ec := checkVersionLocked(c.info, c.bypass, now); (ec == nil) != c.deleted {
+			t.Fatalf("case(%v): error(%v), expect deleted(%v)", i, ec, c.deleted)
+		}
+	}
+}
+
+func TestParseObjectLockHeaders(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := &ObjectLockConfiguration{
+		ObjectLockEnabled: ObjectLockEnabled,
+		Rule:              &ObjectLockRule{DefaultRetention: &DefaultRetention{Mode: RetentionModeGovernance, Days: 10}},
+	}
+
+	r := httptest.NewRequest("PUT", "/bucket/key", nil)
+	retention, legalHold, ec := parseObjectLockHeaders(r, config, now)
+	if ec != nil || retention.Encode() != "GOVERNANCE 2020-01-11T00:00:00Z" || legalHold != "" {
+		t.Fatalf("default retention(%v) legalHold(%v) error(%v)", retention, legalHold, ec)
+	}
+	if retention, _, ec = parseObjectLockHeaders(r, nil, now); ec != nil || retention.Encode() != "" {
+		t.Fatalf("retention(%v) error(%v) without object lock", retention, ec)
+	}
+
+	r.Header.Set(HeaderNameObjectLockMode, RetentionModeCompliance)
+	r.Header.Set(HeaderNameObjectLockRetainUntilDate, "2021-01-01T00:00:00.000Z")
+	r.Header.Set(HeaderNameObjectLockLegalHold, LegalHoldOn)
+	retention, legalHold, ec = parseObjectLockHeaders(r, config, now)
+	if ec != nil || retention.Mode != RetentionModeCompliance || legalHold != LegalHoldOn {
+		t.Fatalf("retention(%v) legalHold(%v) error(%v)", retention, legalHold, ec)
+	}
+	if _, _, ec = parseObjectLockHeaders(r, nil, now); ec != &ObjectLockNotEnabled {
+		t.Fatalf("error(%v) without object lock", ec)
+	}
+
+	r.Header.Set(HeaderNameObjectLockRetainUntilDate, "2019-01-01T00:00:00Z")
+	if _, _, ec = parseObjectLockHeaders(r, config, now); ec == nil {
+		t.Fatalf("retain until date in the past accepted")
+	}
+}
@@ -19,6 +19,7 @@ package objectnode
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -29,19 +30,20 @@ import (
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/example-bucket-policies.html
 const (
 	PolicyDefaultVersion  = "2012-10-17"
+	PolicyOldVersion      = "2008-10-17"
 	BucketPolicyLimitSize = 20 * 1024 //Bucket policies are limited to 20KB
 	ArnSplitToken         = ":"
+	S3ArnPrefix           = "arn:aws:s3:::"
 )
 
 //https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
 
 type Policy struct {
 	Version    string      `json:"Version"`
-	Id         string      `json:"Id,omnistring"`
+	Id         string      `json:"Id,omitempty"`
 	Statements []Statement `json:"Statement,omitempty"`
 }
 
-//
 // arn:partition:service:region:account-id:resource-id
 // arn:partition:service:region:account-id:resource-type/resource-id
 // arn:partition:service:region:account-id:resource-type:resource-id
@@ -56,12 +58,13 @@ type Arn struct {
 }
 
 func parseArn(str string) (*Arn, error) {
-	items := strings.Split(str, ArnSplitToken)
-	if len(items) < 4 {
+	items := strings.SplitN(str, ArnSplitToken, 7)
+	if len(items) < 6 || items[0] != "arn" {
 		log.LogErrorf("Arn is invalid: %v", str)
 		return nil, errors.New("invalid arn")
 	}
 	arn := &Arn{
+		arn:       Resource(str),
 		partition: items[1],
 		service:   items[2],
 		region:    items[3],
@@ -78,42 +81,6 @@ func parseArn(str string) (*Arn, error) {
 	return arn, nil
 }
 
-// write bucket policy into store and update vol policy meta
-func storeBucketPolicy(bytes []byte, vol *volume) (*Policy, error) {
-	store, err1 := vol.vm.GetStore()
-	if err1 != nil {
-		return nil, err1
-	}
-
-	policy := &Policy{}
-	err2 := json.Unmarshal(bytes, policy)
-	if err2 != nil {
-		log.LogErrorf("policy unmarshal err: %v", err2)
-		return nil, err2
-	}
-
-	// validate policy
-	ok, err3 := policy.Validate(vol.name)
-	if err3 != nil {
-		log.LogErrorf("policy validate err: %v", err2)
-		return nil, err3
-	}
-	if !ok {
-		return nil, errors.New("policy is invalid")
-	}
-
-	// put policy bytes into store
-	err4 := store.Put(vol.name, bucketRootPath, XAttrKeyOSSPolicy, bytes)
-	if err4 != nil {
-		return nil, err4
-	}
-
-	vol.storePolicy(policy)
-
-	return policy, nil
-}
-
-//
 func ParsePolicy(r io.Reader, bucket string) (*Policy, error) {
 	var policy Policy
 	d := json.NewDecoder(r)
@@ -130,8 +97,11 @@ func ParsePolicy(r io.Reader, bucket string) (*Policy, error) {
 }
 
 func (p Policy) isValid() (bool, error) {
-	if p.Version == "" {
-		return false, errors.New("policy version cannot be empty")
+	if p.Version != PolicyDefaultVersion && p.Version != PolicyOldVersion {
+		return false, fmt.Errorf("invalid policy version %v", p.Version)
+	}
+	if len(p.Statements) == 0 {
+		return false, errors.New("policy statements cannot be empty")
 	}
 
 	return true, nil
@@ -151,33 +121,62 @@ func (p Policy) Validate(bucket string) (bool, error) {
 	return true, nil
 }
 
-// check policy is allowed for request
+type PolicyResult int
+
+const (
+	PolicyNotMatched PolicyResult = iota
+	PolicyAllow
+	PolicyDeny
+)
+
+// Evaluate returns the effect of the statements matching the request, in which an explicit deny
+// overrides the allows.
 // https://docs.aws.amazon.com/zh_cn/IAM/latest/UserGuide/reference_policies_evaluation-logic.html
-// 如果适用策略包含 Deny 语句，则请求会导致显式拒绝。
-// 如果应用于请求的策略包含一个 Allow 语句和一个 Deny 语句，Deny 语句优先于 Allow 语句。将显式拒绝请求。
-// 当没有适用的 Deny 语句但也没有适用的 Allow 语句时，会发生隐式拒绝。
-func (p *Policy) IsAllowed(params *RequestParam) bool {
+func (p *Policy) Evaluate(params *RequestParam) PolicyResult {
+	result := PolicyNotMatched
 	for _, s := range p.Statements {
+		if !s.check(params) {
+			continue
+		}
 		if s.Effect == Deny {
-			if !s.IsAllowed(params) {
-				return false
-			}
+			return PolicyDeny
 		}
+		result = PolicyAllow
 	}
 
-	if params.isOwner {
+	return result
+}
+
+var (
+	bucketPolicyActions = []Action{GetBucketPolicyAction, PutBucketPolicyAction, DeleteBucketPolicyAction}
+)
+
+// isAllowed checks the request signed by the access key of the bucket, or an access key granted
+// the access to the bucket. An explicit deny of the policy overrides the others, except for the
+// access of the owner to the policy itself, so that the owner is never locked out. Otherwise the
+// requests of the owner and the ones allowed by the policy are allowed, and the others are
+// decided by the ACL, or denied if there is a policy but no ACL. The anonymous requests are only
+// allowed by the policy or the ACL.
+func isAllowed(policy *Policy, acl *AccessControlPolicy, param *RequestParam) bool {
+	if param.isOwner && IsIntersectionActions(param.actions, bucketPolicyActions) {
 		return true
 	}
-
-	for _, s := range p.Statements {
-		if s.Effect == Allow {
-			if s.IsAllowed(params) {
-				return true
-			}
+	if policy != nil {
+		switch policy.Evaluate(param) {
+		case PolicyDeny:
+			return false
+		case PolicyAllow:
+			return true
 		}
 	}
+	if param.isOwner {
+		return true
+	}
+	if acl != nil {
+		return acl.IsAllowed(param)
+	}
 
-	return false
+	return policy == nil && param.account != ""
 }
 
 func (o *ObjectNode) policyCheck(f http.HandlerFunc, actions []Action) http.HandlerFunc {
@@ -212,25 +211,17 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc, actions []Action) http.Hand
 
 		param.actions = actions
 
-		//check policy and acl
-		acl := param.vol.loadACL()
-		policy := param.vol.loadPolicy()
-		//check ip policy
-		if policy != nil {
-			allowed = policy.IsAllowed(param)
-			if !allowed {
-				log.LogWarnf("policy not allowed %v", param)
-				return
-			}
+		if param.volAccessKey != nil && !allowedByVolAccessKey(param.volAccessKey, param, r) {
+			log.LogWarnf("policyCheck: out of the scope of the access key: requestID(%v) resource(%v) accessKey(%v) actions(%v)",
+				RequestIDFromRequest(r), param.resource, param.volAccessKey.AccessKey, actions)
+			return
 		}
 
-		if acl != nil {
-			allowed = acl.IsAllowed(param)
-			if !allowed {
-				log.LogWarnf("acl not allowed %v", param)
-				return
-			}
+		//check policy and acl
+		allowed = isAllowed(param.vol.loadPolicy(), param.vol.loadACL(), param)
+		if !allowed {
+			log.LogWarnf("policyCheck: access denied: requestID(%v) resource(%v) account(%v) actions(%v)",
+				RequestIDFromRequest(r), param.resource, param.account, actions)
 		}
-
 	}
 }
@@ -16,6 +16,8 @@ package objectnode
 
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/access-policy-language-overview.html
 
+import "strings"
+
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/example-bucket-policies.html
 // https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/amazon-s3-policy-keys.html
 type Action string
@@ -45,6 +47,29 @@ const (
 	ListMultipartUploadPartsAction          = "s3:ListMultipartUploadParts"
 	AbortMultipartUploadAction              = "s3:AbortMultipartUpload"
 	GetBucketLocationAction                 = "s3:GetBucketLocation"
+	GetLifecycleConfigurationAction         = "s3:GetLifecycleConfiguration"
+	PutLifecycleConfigurationAction         = "s3:PutLifecycleConfiguration"
+	GetBucketVersioningAction               = "s3:GetBucketVersioning"
+	PutBucketVersioningAction               = "s3:PutBucketVersioning"
+	DeleteObjectVersionAction               = "s3:DeleteObjectVersion"
+	GetObjectTaggingAction                  = "s3:GetObjectTagging"
+	PutObjectTaggingAction                  = "s3:PutObjectTagging"
+	DeleteObjectTaggingAction               = "s3:DeleteObjectTagging"
+	GetBucketWebsiteAction                  = "s3:GetBucketWebsite"
+	PutBucketWebsiteAction                  = "s3:PutBucketWebsite"
+	DeleteBucketWebsiteAction               = "s3:DeleteBucketWebsite"
+	GetBucketCORSAction                     = "s3:GetBucketCORS"
+	PutBucketCORSAction                     = "s3:PutBucketCORS"
+	GetObjectRetentionAction                = "s3:GetObjectRetention"
+	PutObjectRetentionAction                = "s3:PutObjectRetention"
+	GetObjectLegalHoldAction                = "s3:GetObjectLegalHold"
+	PutObjectLegalHoldAction                = "s3:PutObjectLegalHold"
+	GetBucketObjectLockConfigAction         = "s3:GetBucketObjectLockConfiguration"
+	PutBucketObjectLockConfigAction         = "s3:PutBucketObjectLockConfiguration"
+	GetBucketLoggingAction                  = "s3:GetBucketLogging"
+	PutBucketLoggingAction                  = "s3:PutBucketLogging"
+	GetBucketNotificationAction             = "s3:GetBucketNotification"
+	PutBucketNotificationAction             = "s3:PutBucketNotification"
 )
 
 func (s Statement) checkActions(p *RequestParam) bool {
@@ -52,7 +77,7 @@ func (s Statement) checkActions(p *RequestParam) bool {
 		return true
 	}
 	for _, pa := range p.actions {
-		if s.Actions.ContainsWithAny(string(pa)) {
+		if matchAction(s.Actions, pa) {
 			return true
 		}
 	}
@@ -65,7 +90,7 @@ func (s Statement) checkNotActions(p *RequestParam) bool {
 		return true
 	}
 	for _, pa := range p.actions {
-		if s.NotActions.ContainsWithAny(string(pa)) {
+		if matchAction(s.NotActions, pa) {
 			return false
 		}
 	}
@@ -73,7 +98,16 @@ func (s Statement) checkNotActions(p *RequestParam) bool {
 	return true
 }
 
-//
+// the actions are case insensitive, and the wildcards are allowed, e.g. s3:Get*
+func matchAction(actions StringSet, action Action) bool {
+	for a := range actions.values {
+		if wildcardMatch(strings.ToLower(a), strings.ToLower(string(action))) {
+			return true
+		}
+	}
+	return false
+}
+
 func IsIntersectionActions(actions1, actions2 []Action) bool {
 	if len(actions1) == 0 && len(actions2) == 0 {
 		return true
@@ -24,7 +24,7 @@ import (
 	"time"
 )
 
-//https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
+// https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
 type ConditionValues map[string]StringSet
 type Condition map[ConditionType]ConditionValues
 type ConditionType string
@@ -132,36 +132,47 @@ var ConditionKeyType = map[ConditionKey]ConditionTypeSet{
 	AwsVpcSourceIp:            IpAddressType,
 }
 
+// the s3 condition keys of the request parameters and the amz headers are the names of them
+// prefixed with "s3:", e.g. s3:prefix, s3:max-keys and s3:x-amz-acl
+// https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/amazon-s3-policy-keys.html
+const (
+	S3ConditionKeyPrefix = "s3:"
+	S3Prefix             = "s3:prefix"
+	S3Delimiter          = "s3:delimiter"
+	S3MaxKeys            = "s3:max-keys"
+)
+
 var ConditionFuncMap = map[ConditionType]ConditionFunc{
-	IpAddress:             IpAddressFunc,
-	NotIpAddress:          NotIpAddressFunc,
-	StringLike:            StringLikeFunc,
-	StringNotLike:         StringNotLikeFunc,
-	StringEquals:          StringEqualsFunc,
-	StringNotEquals:       StringNotEqualsFunc,
-	Bool:                  BoolFunc,
-	DateEquals:            DateEqualsFunc,
-	DateNotEquals:         DateNotEqualsFunc,
-	DateLessThan:          DateLessThanFunc,
-	DateLessThanEquals:    DateLessThanEqualsFunc,
-	DateGreaterThan:       DateGreaterThanFunc,
-	DateGreaterThanEquals: DateGreaterThanEqualsFunc,
+	IpAddress:                IpAddressFunc,
+	NotIpAddress:             NotIpAddressFunc,
+	StringLike:               StringLikeFunc,
+	StringNotLike:            StringNotLikeFunc,
+	StringEquals:             StringEqualsFunc,
+	StringNotEquals:          StringNotEqualsFunc,
+	Bool:                     BoolFunc,
+	DateEquals:               DateEqualsFunc,
+	DateNotEquals:            DateNotEqualsFunc,
+	DateLessThan:             DateLessThanFunc,
+	DateLessThanEquals:       DateLessThanEqualsFunc,
+	DateGreaterThan:          DateGreaterThanFunc,
+	DateGreaterThanEquals:    DateGreaterThanEqualsFunc,
+	NumericEquals:            NumericEqualsFunc,
+	NumericNotEquals:         NumericNotEqualsFunc,
+	NumericLessThan:          NumericLessThanFunc,
+	NumericLessThanEquals:    NumericLessThanEqualsFunc,
+	NumericGreaterThan:       NumericGreaterThanFunc,
+	NumericGreaterThanEquals: NumericGreaterThanEqualsFunc,
+	ArnEquals:                ArnEqualsFunc,
+	ArnLike:                  ArnLikeFunc,
+	ArnNotEquals:             ArnNotEqualsFunc,
+	ArnNotLike:               ArnNotLikeFunc,
 }
 
 type ConditionFunc func(p *RequestParam, values ConditionValues) bool
 
-var (
-	awsTrimedPrefix = []string{"aws:", "jwt:", "s3:"}
-)
-
-func TrimAwsPrefixKey(key string) string {
-	for _, prefix := range awsTrimedPrefix {
-		if strings.HasPrefix(key, prefix) {
-			return strings.TrimPrefix(key, prefix)
-		}
-	}
-
-	return key
+// the condition keys are case insensitive
+func canonicalConditionKey(key string) string {
+	return strings.ToLower(key)
 }
 
 func getCondtionValues(r *http.Request) map[string][]string {
@@ -172,105 +183,167 @@ func getCondtionValues(r *http.Request) map[string][]string {
 	if accessKey == "" {
 		principalType = "Anonymous"
 	}
+	secureTransport := requestScheme(r) == "https"
 	values := map[string][]string{
-		"SourceIp":      {getRequestIP(r)},
-		"UserAgent":     {r.UserAgent()},
-		"Referer":       {r.Referer()},
-		"CurrentTime":   {currentTime.Format(AMZTimeFormat)},
-		"EpochTime":     {fmt.Sprintf("%d", currentTime.Unix())},
-		"userid":        {accessKey},
-		"username":      {accessKey},
-		"PrincipalType": {principalType},
+		canonicalConditionKey(AwsSourceIp):            {getRequestIP(r)},
+		canonicalConditionKey(AwsUserAgent):           {r.UserAgent()},
+		canonicalConditionKey(AwsReferer):             {r.Referer()},
+		canonicalConditionKey(string(AwsCurrentTime)): {currentTime.Format(AMZTimeFormat)},
+		canonicalConditionKey(AwsEpochTime):           {fmt.Sprintf("%d", currentTime.Unix())},
+		canonicalConditionKey(AwsUserId):              {accessKey},
+		canonicalConditionKey(AwsUserName):            {accessKey},
+		canonicalConditionKey(AwsPrincipalType):       {principalType},
+		canonicalConditionKey(AwsSecureTransport):     {strconv.FormatBool(secureTransport)},
 	}
 
-	for k, v := range r.Header {
-		if existsV, ok := values[k]; ok {
-			values[k] = append(existsV, v...)
-		} else {
-			values[k] = v
-		}
+	for k, v := range r.URL.Query() {
+		key := canonicalConditionKey(S3ConditionKeyPrefix + k)
+		values[key] = append(values[key], v...)
 	}
 
-	for k, v := range r.URL.Query() {
-		if existsV, ok := values[k]; ok {
-			values[k] = append(existsV, v...)
-		} else {
-			values[k] = v
+	for k, v := range r.Header {
+		if key := canonicalConditionKey(k); strings.HasPrefix(key, "x-amz-") {
+			key = S3ConditionKeyPrefix + key
+			values[key] = append(values[key], v...)
 		}
 	}
 
 	return values
 }
 
-func IpAddressFunc(p *RequestParam, value ConditionValues) bool {
-	key := TrimAwsPrefixKey(AwsSourceIp)
-	canonicalKey := http.CanonicalHeaderKey(key)
-	sourceIP, ok := value[canonicalKey]
-	if !ok {
-		return false
-	}
-	for ipnet, _ := range sourceIP.values {
-		if ok, _ := isIPNetContainsIP(p.sourceIP, ipnet); ok {
-			return true
+// matchCondition tells whether a value of the request matches a value of the condition for each
+// key of the condition, which fails if the key is missing in the request.
+func matchCondition(p *RequestParam, values ConditionValues, match func(reqVal, condVal string) bool) bool {
+	for key, condVals := range values {
+		reqVals, ok := p.condVals[canonicalConditionKey(key)]
+		if !ok || !matchAnyValue(reqVals, condVals, match) {
+			return false
 		}
 	}
+	return true
+}
 
+// matchNegatedCondition tells whether no value of the request matches any value of the condition
+// for each key of the condition, which is satisfied if the key is missing in the request.
+func matchNegatedCondition(p *RequestParam, values ConditionValues, match func(reqVal, condVal string) bool) bool {
+	for key, condVals := range values {
+		if reqVals, ok := p.condVals[canonicalConditionKey(key)]; ok && matchAnyValue(reqVals, condVals, match) {
+			return false
+		}
+	}
 	return true
 }
 
-func NotIpAddressFunc(p *RequestParam, values ConditionValues) bool {
-	return !IpAddressFunc(p, values)
-}
-
-func StringLikeFunc(reqParam *RequestParam, storeCondVals ConditionValues) bool {
-	for k, storeVals := range storeCondVals {
-		key := TrimAwsPrefixKey(k)
-		canonicalKey := http.CanonicalHeaderKey(key)
-		if reqVals, ok := reqParam.condVals[canonicalKey]; ok {
-			for _, rv := range reqVals {
-				for sv, _ := range storeVals.values {
-					if match := patternMatch(rv, sv); match {
-						return true
-					}
-				}
+func matchAnyValue(reqVals []string, condVals StringSet, match func(reqVal, condVal string) bool) bool {
+	for _, rv := range reqVals {
+		for cv := range condVals.values {
+			if match(rv, cv) {
+				return true
 			}
 		}
 	}
-
 	return false
 }
 
-func StringNotLikeFunc(p *RequestParam, values ConditionValues) bool {
-	return !StringLikeFunc(p, values)
-}
-
-func StringEqualsFunc(reqParam *RequestParam, storeCondVals ConditionValues) bool {
-	for k, storeVals := range storeCondVals {
-		key := TrimAwsPrefixKey(k)
-		canonicalKey := http.CanonicalHeaderKey(key)
-		if reqVals, ok := reqParam.condVals[canonicalKey]; ok {
-			for _, rv := range reqVals {
-				if storeVals.Contains(rv) {
-					return true
-				}
-			}
-		} else if reqVals, ok := reqParam.condVals[key]; ok {
-			for _, rv := range reqVals {
-				if storeVals.Contains(rv) {
-					return true
-				}
-			}
+func ipMatch(reqVal, condVal string) bool {
+	ok, _ := isIPNetContainsIP(reqVal, condVal)
+	return ok
+}
+
+func stringEqual(reqVal, condVal string) bool {
+	return reqVal == condVal
+}
+
+func stringLike(reqVal, condVal string) bool {
+	return wildcardMatch(condVal, reqVal)
+}
+
+// parseConditionDate parses the date in ISO 8601 or in epoch seconds.
+func parseConditionDate(val string) (date time.Time, err error) {
+	if date, err = time.Parse(time.RFC3339, val); err == nil {
+		return
+	}
+	if date, err = time.Parse(AMZTimeFormat, val); err == nil {
+		return
+	}
+	var epoch int64
+	if epoch, err = strconv.ParseInt(val, 10, 64); err != nil {
+		return
+	}
+	return time.Unix(epoch, 0), nil
+}
+
+// dateCompare returns a function telling whether the result of comparing the date of the request
+// to the date of the condition satisfies the operator.
+func dateCompare(satisfy func(cmp int) bool) func(reqVal, condVal string) bool {
+	return func(reqVal, condVal string) bool {
+		reqDate, err1 := parseConditionDate(reqVal)
+		condDate, err2 := parseConditionDate(condVal)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		switch {
+		case reqDate.Before(condDate):
+			return satisfy(-1)
+		case reqDate.After(condDate):
+			return satisfy(1)
+		default:
+			return satisfy(0)
 		}
 	}
+}
 
-	return false
+func numericCompare(satisfy func(cmp int) bool) func(reqVal, condVal string) bool {
+	return func(reqVal, condVal string) bool {
+		reqNum, err1 := strconv.ParseFloat(reqVal, 64)
+		condNum, err2 := strconv.ParseFloat(condVal, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		switch {
+		case reqNum < condNum:
+			return satisfy(-1)
+		case reqNum > condNum:
+			return satisfy(1)
+		default:
+			return satisfy(0)
+		}
+	}
+}
+
+var (
+	cmpEquals            = func(cmp int) bool { return cmp == 0 }
+	cmpLessThan          = func(cmp int) bool { return cmp < 0 }
+	cmpLessThanEquals    = func(cmp int) bool { return cmp <= 0 }
+	cmpGreaterThan       = func(cmp int) bool { return cmp > 0 }
+	cmpGreaterThanEquals = func(cmp int) bool { return cmp >= 0 }
+)
+
+func IpAddressFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, ipMatch)
+}
+
+func NotIpAddressFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, ipMatch)
+}
+
+func StringLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringLike)
+}
+
+func StringNotLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, stringLike)
+}
+
+func StringEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringEqual)
 }
 
 func StringNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
-	return !StringNotEqualsFunc(p, values)
+	return matchNegatedCondition(p, values, stringEqual)
 }
 
-// check statement conditions
+// check statement conditions, all of which must be satisfied
 func (s Statement) checkConditions(param *RequestParam) bool {
 	if len(s.Condition) == 0 {
 		return true
@@ -278,7 +351,7 @@ func (s Statement) checkConditions(param *RequestParam) bool {
 	for k, v := range s.Condition {
 		f, ok := ConditionFuncMap[k]
 		if !ok {
-			continue
+			return false
 		}
 		if !f(param, v) {
 			return false
@@ -288,145 +361,75 @@ func (s Statement) checkConditions(param *RequestParam) bool {
 	return true
 }
 
-func BoolFunc(p *RequestParam, policyCondtion ConditionValues) bool {
-	if len(policyCondtion) == 0 {
-		return true
-	}
-	for condKey, condVal := range policyCondtion {
-		for vals, _ := range condVal.values {
-			val1, _ := strconv.ParseBool(vals)
-			if cond, ok := p.condVals[condKey]; ok {
-				for _, c := range cond {
-					val2, _ := strconv.ParseBool(c)
-					return val1 == val2
-				}
-			}
-		}
-	}
-
-	return false
+func BoolFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, func(reqVal, condVal string) bool {
+		reqBool, err1 := strconv.ParseBool(reqVal)
+		condBool, err2 := strconv.ParseBool(condVal)
+		return err1 == nil && err2 == nil && reqBool == condBool
+	})
 }
 
-func DateEqualsFunc(p *RequestParam, policyVals ConditionValues) bool {
-	for k, pVals := range policyVals {
-		for pVal, _ := range pVals.values {
-			pDate, err := time.Parse(AMZTimeFormat, pVal)
-			if err != nil {
-				return false
-			}
-			if reqVals, ok := p.condVals[k]; ok {
-				for _, reqVal := range reqVals {
-					reqDate, err := time.Parse(AMZTimeFormat, reqVal)
-					if err != nil {
-						return false
-					}
-					return pDate.Equal(reqDate)
-				}
-			}
-		}
-	}
-
-	return false
+func DateEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(cmpEquals))
 }
 
-func DateNotEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !DateEqualsFunc(p, value)
+func DateNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, dateCompare(cmpEquals))
 }
 
-func DateLessThanFunc(p *RequestParam, value ConditionValues) bool {
-	for k, pVals := range value {
-		for pVal, _ := range pVals.values {
-			pDate, err := time.Parse(AMZTimeFormat, pVal)
-			if err != nil {
-				return false
-			}
-			if reqVals, ok := p.condVals[k]; ok {
-				for _, reqVal := range reqVals {
-					reqDate, err := time.Parse(AMZTimeFormat, reqVal)
-					if err != nil {
-						return false
-					}
-					return reqDate.Before(pDate)
-				}
-			}
-		}
-	}
-	return false
+func DateLessThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(cmpLessThan))
 }
 
-func DateLessThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	for k, pVals := range value {
-		for pVal, _ := range pVals.values {
-			pDate, err := time.Parse(AMZTimeFormat, pVal)
-			if err != nil {
-				return false
-			}
-			if reqVals, ok := p.condVals[k]; ok {
-				for _, reqVal := range reqVals {
-					reqDate, err := time.Parse(AMZTimeFormat, reqVal)
-					if err != nil {
-						return false
-					}
-					return reqDate.Before(pDate) || reqDate.Equal(pDate)
-				}
-			}
-		}
-	}
-	return true
+func DateLessThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(cmpLessThanEquals))
 }
 
-func DateGreaterThanFunc(p *RequestParam, value ConditionValues) bool {
-	return !DateLessThanEqualsFunc(p, value)
+func DateGreaterThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(cmpGreaterThan))
 }
 
-func DateGreaterThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !DateLessThanFunc(p, value)
+func DateGreaterThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(cmpGreaterThanEquals))
 }
 
-func NumericEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric equal implements
-
-	return true
+func NumericEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(cmpEquals))
 }
 
-func NumericNotEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !NumericEqualsFunc(p, value)
+func NumericNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, numericCompare(cmpEquals))
 }
 
-func NumericLessThanFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric less than implements
-
-	return true
+func NumericLessThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(cmpLessThan))
 }
 
-func NumericLessThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return NumericLessThanFunc(p, value) || NumericEqualsFunc(p, value)
+func NumericLessThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(cmpLessThanEquals))
 }
 
-func NumericGreaterThanFunc(p *RequestParam, value ConditionValues) bool {
-	return !NumericLessThanEqualsFunc(p, value)
+func NumericGreaterThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(cmpGreaterThan))
 }
 
-func NumericGreaterThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !NumericLessThanFunc(p, value)
+func NumericGreaterThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(cmpGreaterThanEquals))
 }
 
-func ArnEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric equal implements
-
-	return true
+// ArnEquals and ArnLike are the same, both of which allow the wildcards
+func ArnEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringLike)
 }
 
-func ArnNotEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !ArnEqualsFunc(p, value)
+func ArnNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, stringLike)
 }
 
-func ArnLikeFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric equal implements
-
-	return true
+func ArnLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringLike)
 }
 
-func ArnNotLikeFunc(p *RequestParam, value ConditionValues) bool {
-	return !ArnLikeFunc(p, value)
+func ArnNotLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, stringLike)
 }
@@ -15,100 +15,117 @@
 package objectnode
 
 import (
-	"encoding/json"
-	"errors"
+	"bytes"
 	"io"
 	"io/ioutil"
 	"net/http"
 
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/log"
-	"github.com/gorilla/mux"
 )
 
+// Get bucket policy
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketPolicy.html
 func (o *ObjectNode) getBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	log.LogInfof("Get bucket acl")
-	var (
-		err error
-		ec  *ErrorCode
-	)
-	defer o.errorResponse(w, r, err, ec)
-
-	_, bucket, _, vol, err := o.parseRequestParams(r)
-	if bucket == "" {
-		ec = &NoSuchBucket
+	log.LogInfof("getBucketPolicyHandler: get bucket policy: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, _, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketPolicyHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
 		return
 	}
 
-	ossMeta := vol.OSSMeta()
-	if ossMeta == nil {
-		ec = &InternalError
+	var bp *proto.BucketPolicy
+	if bp, err = o.mc.AdminAPI().GetVolumePolicy(bucket); err != nil {
+		log.LogErrorf("getBucketPolicyHandler: get policy from master fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
 		return
 	}
-
-	policyData, err2 := json.Marshal(ossMeta.policy)
-	if err2 != nil {
-		err = err2
-		ec = &InternalError
+	if bp.Policy == "" {
+		_ = NoSuchBucketPolicy.ServeResponse(w, r)
 		return
 	}
 
-	w.Write(policyData)
-
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeJSON)
+	if _, err = w.Write([]byte(bp.Policy)); err != nil {
+		log.LogErrorf("getBucketPolicyHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
 	return
 }
 
+// Put bucket policy
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketPolicy.html
 func (o *ObjectNode) putBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	var (
-		err error
-		ec  *ErrorCode
-	)
-	defer o.errorResponse(w, r, err, ec)
-
+	log.LogInfof("putBucketPolicyHandler: put bucket policy: requestID(%v)", RequestIDFromRequest(r))
 	_, bucket, _, vol, err := o.parseRequestParams(r)
-	if bucket == "" {
-		ec = &NoSuchBucket
+	if err != nil || vol == nil {
+		log.LogErrorf("putBucketPolicyHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
 		return
 	}
-
 	if r.ContentLength > BucketPolicyLimitSize {
-		ec = &MaxContentLength
+		_ = MaxContentLength.ServeResponse(w, r)
 		return
 	}
 
-	bytes, err2 := ioutil.ReadAll(r.Body)
-	if err2 != nil && err2 != io.EOF {
-		err = err2
-		log.LogInfof("read body err, %v", err)
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketPolicyLimitSize+1)); err != nil {
+		log.LogErrorf("putBucketPolicyHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
 		return
 	}
-
-	policy, err3 := storeBucketPolicy(bytes, vol)
-	if err3 != nil {
-		err = err3
-		log.LogErrorf("store policy err, %v", err)
+	if len(body) > BucketPolicyLimitSize {
+		_ = MaxContentLength.ServeResponse(w, r)
+		return
+	}
+	var policy *Policy
+	if policy, err = ParsePolicy(bytes.NewReader(body), bucket); err != nil {
+		log.LogWarnf("putBucketPolicyHandler: invalid policy: requestID(%v) bucket(%v) err(%v)", RequestIDFromRequest(r), bucket, err)
+		_ = MalformedPolicy.ServeResponse(w, r)
 		return
 	}
 
-	log.LogInfof("put bucket policy %v %v", bucket, policy)
-
+	if err = o.setVolumePolicy(bucket, body); err != nil {
+		log.LogErrorf("putBucketPolicyHandler: set policy to master fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	// the other object nodes load the policy from the master later
+	vol.storePolicy(policy)
+	log.LogDebugf("putBucketPolicyHandler: bucket policy set: requestID(%v) bucket(%v) statements(%v)",
+		RequestIDFromRequest(r), bucket, len(policy.Statements))
+	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
+// Delete bucket policy
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketPolicy.html
 func (o *ObjectNode) deleteBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	log.LogInfof("delete bucket policy...")
-	var (
-		err error
-		ec  *ErrorCode
-	)
-	defer o.errorResponse(w, r, err, ec)
-
-	vars := mux.Vars(r)
-	bucket := vars["bucket"]
-	if bucket == "" {
-		err = errors.New("")
-		ec = &NoSuchBucket
+	log.LogInfof("deleteBucketPolicyHandler: delete bucket policy: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vol, err := o.parseRequestParams(r)
+	if err != nil || vol == nil {
+		log.LogErrorf("deleteBucketPolicyHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
 		return
 	}
-
+	if err = o.setVolumePolicy(bucket, nil); err != nil {
+		log.LogErrorf("deleteBucketPolicyHandler: delete policy from master fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	vol.storePolicy(nil)
+	w.WriteHeader(http.StatusNoContent)
 	return
 }
+
+// setVolumePolicy sets the policy to the master, which is removed if it is empty.
+func (o *ObjectNode) setVolumePolicy(bucket string, policy []byte) (err error) {
+	var authKey string
+	if authKey, err = o.volumeAuthKey(bucket); err != nil {
+		return
+	}
+	return o.mc.AdminAPI().SetVolumePolicy(bucket, authKey, policy)
+}
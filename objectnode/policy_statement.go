@@ -16,6 +16,13 @@ package objectnode
 
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/access-policy-language-overview.html
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/example-bucket-policies.html
 //https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
 
@@ -28,6 +35,24 @@ const (
 	Deny         = "Deny"
 )
 
+// UnmarshalJSON accepts the principal "*" of anyone besides the map of the principals.
+func (p *Principal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != "*" {
+			return fmt.Errorf("invalid principal %v", s)
+		}
+		*p = Principal{"AWS": StringSet{values: map[string]null{s: void}}}
+		return nil
+	}
+	var m map[string]StringSet
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*p = m
+	return nil
+}
+
 type Statement struct {
 	Sid          string    `json:"Sid,omitempty"`
 	Effect       Effect    `json:"Effect"`
@@ -44,23 +69,54 @@ func (s *Statement) Validate(bucket string) (bool, error) {
 }
 
 func (s *Statement) isValid(bucket string) (bool, error) {
-	//TODO:
+	if s.Effect != Allow && s.Effect != Deny {
+		return false, fmt.Errorf("invalid effect %v", s.Effect)
+	}
+	if len(s.Principal) == 0 {
+		return false, errors.New("principal cannot be empty")
+	}
+	if s.Actions.Empty() == s.NotActions.Empty() {
+		return false, errors.New("either action or not action is required")
+	}
+	for _, actions := range []StringSet{s.Actions, s.NotActions} {
+		for action := range actions.values {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return false, fmt.Errorf("invalid action %v", action)
+			}
+		}
+	}
+	if s.Resources.Empty() == s.NotResources.Empty() {
+		return false, errors.New("either resource or not resource is required")
+	}
+	for _, resources := range []StringSet{s.Resources, s.NotResources} {
+		for resource := range resources.values {
+			if !isBucketResource(resource, bucket) {
+				return false, fmt.Errorf("resource %v is not in bucket %v", resource, bucket)
+			}
+		}
+	}
+	for t := range s.Condition {
+		if _, ok := ConditionFuncMap[t]; !ok {
+			return false, fmt.Errorf("unsupported condition %v", t)
+		}
+	}
 
 	return true, nil
 }
 
-func (s Statement) IsAllowed(p *RequestParam) bool {
-	checked := s.check(p)
-
-	if s.Effect != Allow {
-		return !checked
+// isBucketResource tells whether the resource is the bucket or the objects of the bucket.
+func isBucketResource(resource, bucket string) bool {
+	arn, err := parseArn(resource)
+	if err != nil || arn.service != "s3" || arn.resourceType != "" {
+		return false
 	}
-
-	return checked
+	name := strings.SplitN(arn.resourceId, "/", 2)[0]
+	return wildcardMatch(name, bucket)
 }
 
 type CheckFuncs func(p *RequestParam) bool
 
+// check tells whether the statement matches the request.
 func (s Statement) check(p *RequestParam) bool {
 	checkFuncs := []CheckFuncs{
 		s.checkPrincipal,
@@ -80,6 +136,7 @@ func (s Statement) check(p *RequestParam) bool {
 	return true
 }
 
+// the principals are the access keys
 func (s Statement) checkPrincipal(p *RequestParam) bool {
 	if len(s.Principal) == 0 {
 		return true
@@ -97,18 +154,23 @@ func (s Statement) checkResources(p *RequestParam) bool {
 	if s.Resources.Empty() {
 		return true
 	}
-	if s.Resources.ContainsRegex(p.resource) {
-		return true
-	}
-	return false
+	return matchResource(s.Resources, p.resource)
 }
 
 func (s Statement) checkNotResources(p *RequestParam) bool {
 	if s.NotResources.Empty() {
 		return true
 	}
-	if s.NotResources.ContainsRegex(p.resource) {
-		return false
+	return !matchResource(s.NotResources, p.resource)
+}
+
+// matchResource matches the resource of the request, i.e. the bucket or the bucket and the key,
+// with the resources like arn:aws:s3:::bucket/key in which the wildcards are allowed.
+func matchResource(resources StringSet, resource string) bool {
+	for r := range resources.values {
+		if wildcardMatch(strings.TrimPrefix(r, S3ArnPrefix), resource) {
+			return true
+		}
 	}
-	return true
+	return false
 }
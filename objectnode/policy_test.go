@@ -66,3 +66,159 @@ https://docs.aws.amazon.com/zh_cn/IAM/latest/UserGuide/reference_policies_elemen
 }
 
 */
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "AllowReadFromOffice",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:Get*", "s3:ListBucket"],
+      "Resource": ["arn:aws:s3:::examplebucket", "arn:aws:s3:::examplebucket/*"],
+      "Condition": {
+        "IpAddress": {"aws:SourceIp": "54.240.143.0/24"},
+        "NotIpAddress": {"aws:SourceIp": "54.240.143.188/32"}
+      }
+    },
+    {
+      "Sid": "DenyListOutOfHome",
+      "Effect": "Deny",
+      "Principal": {"AWS": ["ak1"]},
+      "Action": "s3:ListBucket",
+      "Resource": "arn:aws:s3:::examplebucket",
+      "Condition": {"StringNotLike": {"s3:prefix": "home/*"}}
+    },
+    {
+      "Sid": "DenyInsecure",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": "arn:aws:s3:::examplebucket/secret/*",
+      "Condition": {"Bool": {"aws:SecureTransport": "false"}}
+    }
+  ]
+}`
+
+func newTestRequestParam(url, ip, account, object string, actions ...Action) *RequestParam {
+	r := httptest.NewRequest("GET", url, nil)
+	r.RemoteAddr = ip + ":1234"
+	p := &RequestParam{
+		account:  account,
+		bucket:   "examplebucket",
+		object:   object,
+		resource: "examplebucket",
+		actions:  actions,
+		sourceIP: ip,
+		condVals: getCondtionValues(r),
+	}
+	if object != "" {
+		p.resource += "/" + object
+	}
+	return p
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	if _, err := ParsePolicy(strings.NewReader(testPolicy), "examplebucket"); err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	if _, err := ParsePolicy(strings.NewReader(testPolicy), "otherbucket"); err == nil {
+		t.Fatalf("policy of examplebucket valid for otherbucket")
+	}
+	invalids := []string{
+		`{"Version": "2012-10-17", "Statement": []}`,
+		`{"Version": "2020-01-01", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::b/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::b/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Maybe", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::b/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "iam:*", "Resource": "arn:aws:s3:::b/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "b/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::b/*", "Condition": {"Null": {"s3:prefix": "true"}}}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::b/*"}], "Unknown": 1}`,
+	}
+	for _, invalid := range invalids {
+		if _, err := ParsePolicy(strings.NewReader(invalid), "b"); err == nil {
+			t.Fatalf("invalid policy parsed: %v", invalid)
+		}
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy, err := ParsePolicy(strings.NewReader(testPolicy), "examplebucket")
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	cases := []struct {
+		param  *RequestParam
+		result PolicyResult
+	}{
+		{newTestRequestParam("http://s3/examplebucket/a", "54.240.143.1", "ak2", "a", GetObjectAction), PolicyAllow},
+		{newTestRequestParam("http://s3/examplebucket/a", "54.240.143.188", "ak2", "a", GetObjectAction), PolicyNotMatched},
+		{newTestRequestParam("http://s3/examplebucket/a", "10.0.0.1", "ak2", "a", GetObjectAction), PolicyNotMatched},
+		{newTestRequestParam("http://s3/examplebucket/a", "54.240.143.1", "ak2", "a", PutObjectAction), PolicyNotMatched},
+		{newTestRequestParam("http://s3/examplebucket?prefix=home/ak1/", "54.240.143.1", "ak1", "", ListBucketAction), PolicyAllow},
+		{newTestRequestParam("http://s3/examplebucket?prefix=tmp/", "54.240.143.1", "ak1", "", ListBucketAction), PolicyDeny},
+		{newTestRequestParam("http://s3/examplebucket", "54.240.143.1", "ak1", "", ListBucketAction), PolicyDeny},
+		{newTestRequestParam("http://s3/examplebucket?prefix=tmp/", "54.240.143.1", "ak2", "", ListBucketAction), PolicyAllow},
+		{newTestRequestParam("http://s3/examplebucket/secret/a", "54.240.143.1", "ak2", "secret/a", GetObjectAction), PolicyDeny},
+		{newTestRequestParam("https://s3/examplebucket/secret/a", "54.240.143.1", "ak2", "secret/a", GetObjectAction), PolicyAllow},
+	}
+	for i, c := range cases {
+		if result := policy.Evaluate(c.param); result != c.result {
+			t.Fatalf("case %v: resource(%v) ip(%v) account(%v) result %v, expect %v",
+				i, c.param.resource, c.param.sourceIP, c.param.account, result, c.result)
+		}
+	}
+}
+
+func TestPolicy_IsAllowed(t *testing.T) {
+	policy, err := ParsePolicy(strings.NewReader(`{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": ["arn:aws:s3:::b", "arn:aws:s3:::b/*"]}]}`), "b")
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	owner := &RequestParam{account: "owner", resource: "b", isOwner: true, actions: []Action{PutBucketPolicyAction}}
+	if !isAllowed(policy, nil, owner) {
+		t.Fatalf("owner locked out of the policy")
+	}
+	owner.actions = []Action{ListBucketAction}
+	if isAllowed(policy, nil, owner) {
+		t.Fatalf("owner not denied explicitly")
+	}
+	other := &RequestParam{account: "other", resource: "b/a", actions: []Action{GetObjectAction}}
+	if !isAllowed(nil, nil, other) {
+		t.Fatalf("denied without policy")
+	}
+	if isAllowed(&Policy{Version: PolicyDefaultVersion}, nil, other) {
+		t.Fatalf("allowed without matching statement")
+	}
+	anonymous := &RequestParam{resource: "b/a", actions: []Action{GetObjectAction}}
+	if isAllowed(nil, nil, anonymous) {
+		t.Fatalf("anonymous allowed without policy and acl")
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	cases := []struct {
+		pattern, value string
+		match          bool
+	}{
+		{"*", "", true},
+		{"b/*", "b/a/c", true},
+		{"b/*", "b", false},
+		{"b/a?c", "b/abc", true},
+		{"b/*.jpg", "b/a/c.jpg", true},
+		{"b/*.jpg", "b/a/c.png", false},
+		{"s3:get*", "s3:getobject", true},
+	}
+	for _, c := range cases {
+		if match := wildcardMatch(c.pattern, c.value); match != c.match {
+			t.Fatalf("match(%v, %v) %v, expect %v", c.pattern, c.value, match, c.match)
+		}
+	}
+}
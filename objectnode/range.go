@@ -0,0 +1,196 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// https://tools.ietf.org/html/rfc7233
+//
+// The object is read by the ranges of the header 'Range', e.g. 'bytes=0-99,200-,-50', and the
+// ranges are returned in a response of 'multipart/byteranges' if more than one. As Amazon S3, the
+// header of the invalid syntax is ignored, and the whole object is returned.
+
+var (
+	errInvalidRange       = errors.New("invalid range")
+	errUnsatisfiableRange = errors.New("unsatisfiable range")
+)
+
+const rangeUnitPrefix = "bytes="
+
+// httpRange is a range of the object requested by the header 'Range'.
+type httpRange struct {
+	start  int64
+	length int64
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRanges parses the header 'Range' against the object of the size. The ranges beyond the
+// object are dropped, and errUnsatisfiableRange is returned if none is left.
+func parseRanges(header string, size int64) (ranges []httpRange, err error) {
+	if !strings.HasPrefix(header, rangeUnitPrefix) {
+		return nil, errInvalidRange
+	}
+	var unsatisfiable bool
+	for _, spec := range strings.Split(header[len(rangeUnitPrefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		hyphenIndex := strings.Index(spec, "-")
+		if hyphenIndex < 0 {
+			return nil, errInvalidRange
+		}
+		first, last := strings.TrimSpace(spec[:hyphenIndex]), strings.TrimSpace(spec[hyphenIndex+1:])
+		var rng httpRange
+		if first == "" {
+			// suffix range, e.g. '-50' for the last 50 bytes
+			var suffix int64
+			if suffix, err = strconv.ParseInt(last, 10, 64); err != nil || suffix < 0 {
+				return nil, errInvalidRange
+			}
+			if suffix == 0 || size == 0 {
+				unsatisfiable = true
+				continue
+			}
+			if suffix > size {
+				suffix = size
+			}
+			rng = httpRange{start: size - suffix, length: suffix}
+		} else {
+			var start, end int64
+			if start, err = strconv.ParseInt(first, 10, 64); err != nil || start < 0 {
+				return nil, errInvalidRange
+			}
+			end = size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, errInvalidRange
+				}
+			}
+			if start >= size {
+				unsatisfiable = true
+				continue
+			}
+			if end >= size {
+				end = size - 1
+			}
+			rng = httpRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, rng)
+	}
+	if len(ranges) == 0 {
+		if unsatisfiable {
+			return nil, errUnsatisfiableRange
+		}
+		return nil, errInvalidRange
+	}
+	return ranges, nil
+}
+
+// rangesSize returns the total size of the ranges.
+func rangesSize(ranges []httpRange) (size int64) {
+	for _, rng := range ranges {
+		size += rng.length
+	}
+	return
+}
+
+// ifRangeMatches checks the header 'If-Range' by the strong comparison of the ETag, or the equality
+// of the last modified time. The ranges are ignored if it does not match.
+func ifRangeMatches(r *http.Request, fileInfo *FSFileInfo) bool {
+	var ifRange = strings.TrimSpace(r.Header.Get(HeaderNameIfRange))
+	if ifRange == "" {
+		return true
+	}
+	if t, err := parseTimeRFC1123(ifRange); err == nil {
+		return t.Unix() == fileInfo.ModifyTime.Unix()
+	}
+	// the weak ETags never match by the strong comparison
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	return strings.Trim(ifRange, "\"") == strings.Trim(fileInfo.ETag, "\"")
+}
+
+// byteRangesWriter writes the ranges as the parts of a response of 'multipart/byteranges'.
+type byteRangesWriter struct {
+	ranges      []httpRange
+	size        int64
+	contentType string
+	writer      *multipart.Writer
+}
+
+func newByteRangesWriter(w io.Writer, ranges []httpRange, size int64, contentType string) *byteRangesWriter {
+	return &byteRangesWriter{
+		ranges:      ranges,
+		size:        size,
+		contentType: contentType,
+		writer:      multipart.NewWriter(w),
+	}
+}
+
+func (bw *byteRangesWriter) ContentType() string {
+	return "multipart/byteranges; boundary=" + bw.writer.Boundary()
+}
+
+// ContentLength returns the length of the response by writing the part headers to nowhere.
+func (bw *byteRangesWriter) ContentLength() int64 {
+	var counter = &countingWriter{}
+	var mw = multipart.NewWriter(counter)
+	_ = mw.SetBoundary(bw.writer.Boundary())
+	for i := range bw.ranges {
+		_, _ = mw.CreatePart(bw.partHeader(i))
+	}
+	_ = mw.Close()
+	return counter.n + rangesSize(bw.ranges)
+}
+
+func (bw *byteRangesWriter) partHeader(i int) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		HeaderNameContentType:  {bw.contentType},
+		HeaderNameContentRange: {bw.ranges[i].contentRange(bw.size)},
+	}
+}
+
+// NextPart starts the part of the ith range, whose data is written to the returned writer.
+func (bw *byteRangesWriter) NextPart(i int) (io.Writer, error) {
+	return bw.writer.CreatePart(bw.partHeader(i))
+}
+
+// Close writes the trailing boundary.
+func (bw *byteRangesWriter) Close() error {
+	return bw.writer.Close()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
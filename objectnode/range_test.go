@@ -0,0 +1,126 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRanges(t *testing.T) {
+	cases := []struct {
+		header string
+		ranges []httpRange
+		err    error
+	}{
+		{"bytes=0-9", []httpRange{{0, 10}}, nil},
+		{"bytes=90-", []httpRange{{90, 10}}, nil},
+		{"bytes=-20", []httpRange{{80, 20}}, nil},
+		{"bytes=-200", []httpRange{{0, 100}}, nil},
+		{"bytes=50-500", []httpRange{{50, 50}}, nil},
+		{"bytes=0-0, 10-19,-5", []httpRange{{0, 1}, {10, 10}, {95, 5}}, nil},
+		{"bytes=0-9,100-", []httpRange{{0, 10}}, nil},
+		{"bytes=100-", nil, errUnsatisfiableRange},
+		{"bytes=-0", nil, errUnsatisfiableRange},
+		{"bytes=9-0", nil, errInvalidRange},
+		{"bytes=a-9", nil, errInvalidRange},
+		{"bytes=10", nil, errInvalidRange},
+		{"items=0-9", nil, errInvalidRange},
+	}
+	for _, c := range cases {
+		ranges, err := parseRanges(c.header, 100)
+		if err != c.err || !reflect.DeepEqual(ranges, c.ranges) {
+			t.Fatalf("header(%v): ranges(%v) err(%v), expect ranges(%v) err(%v)", c.header, ranges, err, c.ranges, c.err)
+		}
+	}
+	if _, err := parseRanges("bytes=0-", 0); err != errUnsatisfiableRange {
+		t.Fatalf("empty object: err(%v), expect unsatisfiable", err)
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	modifyTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fileInfo := &FSFileInfo{ETag: "5d41402abc4b2a76b9719d911017c592", ModifyTime: modifyTime}
+	cases := []struct {
+		ifRange string
+		match   bool
+	}{
+		{"", true},
+		{`"5d41402abc4b2a76b9719d911017c592"`, true},
+		{`"0123"`, false},
+		{`W/"5d41402abc4b2a76b9719d911017c592"`, false},
+		{formatTimeRFC1123(modifyTime), true},
+		{formatTimeRFC1123(modifyTime.Add(time.Second)), false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/bucket/key", nil)
+		if c.ifRange != "" {
+			r.Header.Set(HeaderNameIfRange, c.ifRange)
+		}
+		if match := ifRangeMatches(r, fileInfo); match != c.match {
+			t.Fatalf("If-Range(%v): match(%v), expect(%v)", c.ifRange, match, c.match)
+		}
+	}
+}
+
+func TestByteRangesWriter(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	ranges := []httpRange{{0, 3}, {15, 5}}
+	var body bytes.Buffer
+	writer := newByteRangesWriter(&body, ranges, int64(len(data)), HeaderValueTypeStream)
+	for i, rng := range ranges {
+		part, err := writer.NextPart(i)
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		_, _ = part.Write(data[rng.start : rng.start+rng.length])
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if length := writer.ContentLength(); length != int64(body.Len()) {
+		t.Fatalf("content length(%v), expect(%v)", length, body.Len())
+	}
+
+	_, params, err := mime.ParseMediaType(writer.ContentType())
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+	reader := multipart.NewReader(&body, params["boundary"])
+	expects := []struct {
+		contentRange string
+		data         string
+	}{
+		{"bytes 0-2/20", "012"},
+		{"bytes 15-19/20", "fghij"},
+	}
+	for _, expect := range expects {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		partData, _ := ioutil.ReadAll(part)
+		if part.Header.Get(HeaderNameContentRange) != expect.contentRange || string(partData) != expect.data {
+			t.Fatalf("part range(%v) data(%s), expect range(%v) data(%v)",
+				part.Header.Get(HeaderNameContentRange), partData, expect.contentRange, expect.data)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	rateLimitRefreshInterval = time.Minute
+)
+
+// refreshRateLimits fetches the rate limits from the master periodically, since the objectnodes
+// are not sent heartbeats.
+func (o *ObjectNode) refreshRateLimits() {
+	ticker := time.NewTicker(rateLimitRefreshInterval)
+	defer ticker.Stop()
+	for {
+		rules, err := o.mc.AdminAPI().GetRateLimits()
+		if err != nil {
+			log.LogWarnf("refreshRateLimits: get rate limits from master fail: err(%v)", err)
+		} else {
+			o.limiter.Update(rules)
+		}
+		select {
+		case <-o.stopC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rateLimitMiddleware limits the S3 requests by the rate limits of the objectnode module, with
+// the bucket as the volume and the same operation name as the metrics. The bytes limited are the
+// ones of the request bodies.
+func (o *ObjectNode) rateLimitMiddleware(next http.Handler) http.Handler {
+	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		var vars = mux.Vars(r)
+		var target = "bucket"
+		if len(vars["object"]) > 0 {
+			target = "object"
+		}
+		var bytes int
+		if r.ContentLength > 0 {
+			bytes = int(r.ContentLength)
+		}
+		if err := o.limiter.AllowBytes(vars["bucket"], r.Method+"_"+target, getRequestIP(r), bytes); err != nil {
+			log.LogDebugf("rateLimitMiddleware: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			if err = SlowDown.ServeResponse(w, r); err != nil {
+				log.LogErrorf("rateLimitMiddleware: serve response fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return handlerFunc
+}
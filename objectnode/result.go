@@ -70,6 +70,16 @@ type InitMultipartResult struct {
 	UploadId string   `xml:"UploadId"`
 }
 
+type CompleteMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []*CompletePart `xml:"Part"`
+}
+
+type CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
 type CompleteMultipartResult struct {
 	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
 	Location string   `xml:"Location"`
@@ -202,13 +212,24 @@ type Object struct {
 	VersionId string `xml:"VersionId,omitempty"`
 }
 
+const (
+	// the max number of the objects deleted by a DeleteObjects request
+	MaxDeleteObjects = 1000
+
+	DeleteObjectsLimitSize = 2 << 20
+
+	// the number of the objects of a DeleteObjects request deleted in parallel
+	deleteObjectsParallelism = 32
+)
+
 type DeleteRequest struct {
 	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
 	Objects []Object `xml:"Object"`
 }
 
 type DeletesResult struct {
-	XMLName        xml.Name  `xml:"DeleteObjectsOutput"`
+	XMLName        xml.Name  `xml:"DeleteResult"`
 	DeletedObjects []Deleted `xml:"Deleted,omitempty"`
 	DeletedErrors  []Error   `xml:"Error,omitempty"`
 }
@@ -224,6 +245,7 @@ type ListBucketRequestV1 struct {
 	delimiter string
 	marker    string
 	maxKeys   uint64
+	tagFilter *TagFilter
 }
 
 type ListBucketRequestV2 struct {
@@ -233,6 +255,7 @@ type ListBucketRequestV2 struct {
 	contToken  string
 	fetchOwner bool
 	startAfter string
+	tagFilter  *TagFilter
 }
 
 type ListBucketResultV2 struct {
@@ -22,6 +22,12 @@ import (
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
+// errorCodeRecorder is implemented by the response writers recording the error codes of the
+// responses, e.g. for the access logs.
+type errorCodeRecorder interface {
+	setErrorCode(code string)
+}
+
 type ErrorCode struct {
 	ErrorCode    string
 	ErrorMessage string
@@ -46,6 +52,9 @@ func (code ErrorCode) ServeResponse(w http.ResponseWriter, r *http.Request) erro
 	if marshaled, err = xml.Marshal(&xmlError); err != nil {
 		return err
 	}
+	if recorder, ok := w.(errorCodeRecorder); ok {
+		recorder.setErrorCode(code.ErrorCode)
+	}
 	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
 	w.WriteHeader(code.StatusCode)
 	log.LogInfof("Error info : %s", string(marshaled))
@@ -79,6 +88,9 @@ var (
 	KeyTooLongError                     = ErrorCode{ErrorCode: "KeyTooLongError", ErrorMessage: "", StatusCode: http.StatusBadRequest}
 	InvalidKey                          = ErrorCode{ErrorCode: "InvalidKey", ErrorMessage: "Object key is Illegal", StatusCode: http.StatusBadRequest}
 	EntityTooSmall                      = ErrorCode{ErrorCode: "EntityTooSmall", ErrorMessage: "Your proposed upload is smaller than the minimum allowed object size.", StatusCode: http.StatusBadRequest}
+	InvalidPart                         = ErrorCode{ErrorCode: "InvalidPart", ErrorMessage: "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.", StatusCode: http.StatusBadRequest}
+	InvalidPartOrder                    = ErrorCode{ErrorCode: "InvalidPartOrder", ErrorMessage: "The list of parts was not in ascending order. Parts list must be specified in order by part number.", StatusCode: http.StatusBadRequest}
+	NoSuchUpload                        = ErrorCode{ErrorCode: "NoSuchUpload", ErrorMessage: "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.", StatusCode: http.StatusNotFound}
 	EntityTooLarge                      = ErrorCode{ErrorCode: "EntityTooLarge", ErrorMessage: "Your proposed upload exceeds the maximum allowed object size.", StatusCode: http.StatusBadRequest}
 	IncorrectNumberOfFilesInPostRequest = ErrorCode{ErrorCode: "IncorrectNumberOfFilesInPostRequest", ErrorMessage: "POST requires exactly one file upload per request.", StatusCode: http.StatusBadRequest}
 	InternalError                       = ErrorCode{ErrorCode: "InternalError", ErrorMessage: "We encountered an internal error. Please try again.", StatusCode: http.StatusInternalServerError}
@@ -90,4 +102,33 @@ var (
 	NoSuchKey                           = ErrorCode{ErrorCode: "NoLoggingStatusForKey", ErrorMessage: "The specified key does not exist.", StatusCode: http.StatusNotFound}
 	PreconditionFailed                  = ErrorCode{ErrorCode: "PreconditionFailed", ErrorMessage: "At least one of the preconditions you specified did not hold.", StatusCode: http.StatusPreconditionFailed}
 	MaxContentLength                    = ErrorCode{ErrorCode: "MaxContentLength", ErrorMessage: "Content-Length is bigger than 20KB.", StatusCode: http.StatusLengthRequired}
+	SlowDown                            = ErrorCode{ErrorCode: "SlowDown", ErrorMessage: "Please reduce your request rate.", StatusCode: http.StatusServiceUnavailable}
+	MalformedXML                        = ErrorCode{ErrorCode: "MalformedXML", ErrorMessage: "The XML you provided was not well-formed or did not validate against our published schema.", StatusCode: http.StatusBadRequest}
+	NotImplemented                      = ErrorCode{ErrorCode: "NotImplemented", ErrorMessage: "A header or element you provided implies functionality that is not implemented.", StatusCode: http.StatusNotImplemented}
+	NoSuchLifecycleConfiguration        = ErrorCode{ErrorCode: "NoSuchLifecycleConfiguration", ErrorMessage: "The lifecycle configuration does not exist.", StatusCode: http.StatusNotFound}
+	NoSuchVersion                       = ErrorCode{ErrorCode: "NoSuchVersion", ErrorMessage: "The version ID specified in the request does not match an existing version.", StatusCode: http.StatusNotFound}
+	MethodNotAllowed                    = ErrorCode{ErrorCode: "MethodNotAllowed", ErrorMessage: "The specified method is not allowed against this resource.", StatusCode: http.StatusMethodNotAllowed}
+	InvalidTag                          = ErrorCode{ErrorCode: "InvalidTag", ErrorMessage: "The tag provided was not a valid tag.", StatusCode: http.StatusBadRequest}
+	QuotaExceeded                       = ErrorCode{ErrorCode: "QuotaExceeded", ErrorMessage: "The quota of the path is exceeded.", StatusCode: http.StatusForbidden}
+	NoSuchBucketPolicy                  = ErrorCode{ErrorCode: "NoSuchBucketPolicy", ErrorMessage: "The bucket policy does not exist.", StatusCode: http.StatusNotFound}
+	MalformedPolicy                     = ErrorCode{ErrorCode: "MalformedPolicy", ErrorMessage: "The policy is not well-formed or has invalid elements.", StatusCode: http.StatusBadRequest}
+	NoSuchWebsiteConfiguration          = ErrorCode{ErrorCode: "NoSuchWebsiteConfiguration", ErrorMessage: "The specified bucket does not have a website configuration.", StatusCode: http.StatusNotFound}
+	NoSuchCORSConfiguration             = ErrorCode{ErrorCode: "NoSuchCORSConfiguration", ErrorMessage: "The CORS configuration does not exist.", StatusCode: http.StatusNotFound}
+	CORSBadRequest                      = ErrorCode{ErrorCode: "BadRequest", ErrorMessage: "Insufficient information. Origin request header needed.", StatusCode: http.StatusBadRequest}
+	CORSForbidden                       = ErrorCode{ErrorCode: "AccessForbidden", ErrorMessage: "CORSResponse: This CORS request is not allowed.", StatusCode: http.StatusForbidden}
+	ObjectLocked                        = ErrorCode{ErrorCode: "AccessDenied", ErrorMessage: "Access Denied because object protected by object lock.", StatusCode: http.StatusForbidden}
+	ObjectLockNotEnabled                = ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Bucket is missing Object Lock Configuration.", StatusCode: http.StatusBadRequest}
+	InvalidBucketState                  = ErrorCode{ErrorCode: "InvalidBucketState", ErrorMessage: "The request is not valid with the current state of the bucket.", StatusCode: http.StatusConflict}
+	ObjectLockConfigurationNotFound     = ErrorCode{ErrorCode: "ObjectLockConfigurationNotFoundError", ErrorMessage: "Object Lock configuration does not exist for this bucket.", StatusCode: http.StatusNotFound}
+	NoSuchObjectLockConfiguration       = ErrorCode{ErrorCode: "NoSuchObjectLockConfiguration", ErrorMessage: "The specified object does not have a ObjectLock configuration.", StatusCode: http.StatusNotFound}
+	InvalidExpressionType               = ErrorCode{ErrorCode: "InvalidExpressionType", ErrorMessage: "The ExpressionType is invalid. Only SQL expressions are supported.", StatusCode: http.StatusBadRequest}
+	InvalidCompressionFormat            = ErrorCode{ErrorCode: "InvalidCompressionFormat", ErrorMessage: "The file is not in a supported compression format. Only GZIP and BZIP2 are supported.", StatusCode: http.StatusBadRequest}
+	UnsupportedSyntax                   = ErrorCode{ErrorCode: "UnsupportedSyntax", ErrorMessage: "Encountered invalid syntax.", StatusCode: http.StatusBadRequest}
+	CSVParsingError                     = ErrorCode{ErrorCode: "CSVParsingError", ErrorMessage: "Encountered an error parsing the CSV file.", StatusCode: http.StatusBadRequest}
+	JSONParsingError                    = ErrorCode{ErrorCode: "JSONParsingError", ErrorMessage: "Encountered an error parsing the JSON file.", StatusCode: http.StatusBadRequest}
+	SignatureDoesNotMatch               = ErrorCode{ErrorCode: "SignatureDoesNotMatch", ErrorMessage: "The request signature we calculated does not match the signature you provided.", StatusCode: http.StatusForbidden}
+	IncompleteBody                      = ErrorCode{ErrorCode: "IncompleteBody", ErrorMessage: "You did not provide the number of bytes specified by the Content-Length HTTP header.", StatusCode: http.StatusBadRequest}
+	InvalidTargetBucketForLogging       = ErrorCode{ErrorCode: "InvalidTargetBucketForLogging", ErrorMessage: "The target bucket for logging does not exist, or is not owned by you.", StatusCode: http.StatusBadRequest}
+	InvalidNotificationDestination      = ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "Unable to validate the following destination configurations.", StatusCode: http.StatusBadRequest}
+	MalformedACLError                   = ErrorCode{ErrorCode: "MalformedACLError", ErrorMessage: "The XML you provided was not well-formed or did not validate against our published schema.", StatusCode: http.StatusBadRequest}
 )
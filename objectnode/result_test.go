@@ -16,6 +16,8 @@ package objectnode
 
 import (
 	"fmt"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -192,6 +194,26 @@ func TestUnmarshalDeleteRequest(t *testing.T) {
 	fmt.Println("----", deleteReq.Objects)
 }
 
+func TestUnmarshalDeleteRequest_Quiet(t *testing.T) {
+	source := `<Delete><Quiet>true</Quiet><Object><Key>a</Key><VersionId>1</VersionId></Object></Delete>`
+	deleteReq := DeleteRequest{}
+	if err := UnmarshalXMLEntity([]byte(source), &deleteReq); err != nil {
+		t.Fatalf("unmarshal fail cause: %v", err)
+	}
+	if !deleteReq.Quiet || len(deleteReq.Objects) != 1 || deleteReq.Objects[0].VersionId != "1" {
+		t.Fatalf("request(%+v) mismatch", deleteReq)
+	}
+
+	result := DeletesResult{DeletedErrors: []Error{transferError("a", syscall.EDQUOT)}}
+	marshaled, err := MarshalXMLEntity(result)
+	if err != nil {
+		t.Fatalf("marshal fail cause: %v", err)
+	}
+	if !strings.Contains(string(marshaled), "<DeleteResult><Error><Key>a</Key><Code>QuotaExceeded</Code>") {
+		t.Fatalf("marshal result: %v", string(marshaled))
+	}
+}
+
 func TestMarshalTagging(t *testing.T) {
 	tagging := &Tagging{
 		TagSet: []*Tag{
@@ -23,6 +23,16 @@ import (
 // register api routers
 func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
+	// Website endpoints, which are registered before the API ones since the API domains may be
+	// the suffixes of the website domains.
+	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/dev/WebsiteEndpoints.html
+	for _, d := range o.websiteDomains {
+		router.Host("{bucket:.+}." + d).Path("/{object:.*}").
+			HandlerFunc(o.policyCheck(o.websiteHandler, []Action{GetObjectAction}))
+		router.Host("{bucket:.+}." + d + ":{port:[0-9]+}").Path("/{object:.*}").
+			HandlerFunc(o.policyCheck(o.websiteHandler, []Action{GetObjectAction}))
+	}
+
 	var bucketRouters []*mux.Router
 	bRouter := router.PathPrefix("/").Subrouter()
 	for _, d := range o.domains {
@@ -32,6 +42,13 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 	bucketRouters = append(bucketRouters, bRouter.PathPrefix("/{bucket}").Subrouter())
 
 	var registerBucketHttpHeadRouters = func(r *mux.Router) {
+		// Head object version
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html
+		r.Methods(http.MethodHead).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.headObjectHandler, []Action{GetObjectVersionAction})).
+			Queries("versionId", "{versionId:.+}")
+
 		// Head object
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html
 		r.Methods(http.MethodHead).
@@ -49,7 +66,7 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
 		r.Methods(http.MethodGet).
 			Path("/{object:.+}").
-			HandlerFunc(o.policyCheck(o.getObjectHandler, []Action{ListBucketAction})).
+			HandlerFunc(o.policyCheck(o.getObjectHandler, []Action{GetObjectAction})).
 			Queries("AWSAccessKeyId", "{accessKey:.+}",
 				"Expires", "{expires:[0-9]+}", "Signature", "{signature:.+}")
 
@@ -57,7 +74,7 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
 		r.Methods(http.MethodGet).
 			Path("/{object:.+}").
-			HandlerFunc(o.policyCheck(o.getObjectHandler, []Action{ListBucketAction})).
+			HandlerFunc(o.policyCheck(o.getObjectHandler, []Action{GetObjectAction})).
 			Queries("X-Amz-Credential", "{creadential:.+}",
 				"X-Amz-Algorithm", "{algorithm:.+}", "X-Amz-Signature", "{signature:.+}",
 				"X-Amz-Date", "{date:.+}", "X-Amz-SignedHeaders", "{signedHeaders:.+}",
@@ -67,20 +84,34 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectTagging.html
 		r.Methods(http.MethodGet).
 			Path("/{object:.+}").
-			HandlerFunc(o.policyCheck(o.getObjectTagging, []Action{GetBucketPolicyAction})).
+			HandlerFunc(o.policyCheck(o.getObjectTagging, []Action{GetObjectTaggingAction})).
 			Queries("tagging", "")
 
+		// Get object retention
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
+		r.Methods(http.MethodGet).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.getObjectRetentionHandler, []Action{GetObjectRetentionAction})).
+			Queries("retention", "")
+
+		// Get object legal hold
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
+		r.Methods(http.MethodGet).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.getObjectLegalHoldHandler, []Action{GetObjectLegalHoldAction})).
+			Queries("legal-hold", "")
+
 		// Get object XAttr
 		// Notes: ChubaoFS owned API for XAttr operation
 		r.Methods(http.MethodGet).
 			Path("/{object:.+}").
-			HandlerFunc(o.getObjectXAttr).
+			HandlerFunc(o.policyCheck(o.getObjectXAttr, []Action{GetObjectAction})).
 			Queries("xattr", "", "key", "{key:.+}")
 
 		// List object XAttrs
 		r.Methods(http.MethodGet).
 			Path("/{object:.+}").
-			HandlerFunc(o.listObjectXAttrs).
+			HandlerFunc(o.policyCheck(o.listObjectXAttrs, []Action{GetObjectAction})).
 			Queries("xattr", "")
 
 		// Get object acl
@@ -90,6 +121,13 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 			HandlerFunc(o.policyCheck(o.getObjectACLHandler, []Action{GetObjectAclAction})).
 			Queries("acl", "")
 
+		// Get object version
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
+		r.Methods(http.MethodGet).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.getObjectHandler, []Action{GetObjectVersionAction})).
+			Queries("versionId", "{versionId:.+}")
+
 		// Get object
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
 		r.Methods(http.MethodGet).
@@ -132,6 +170,54 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 			HandlerFunc(o.policyCheck(o.getBucketACLHandler, []Action{GetBucketAclAction})).
 			Queries("acl", "")
 
+		// Get bucket lifecycle
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycleConfiguration.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketLifecycleHandler, []Action{GetLifecycleConfigurationAction})).
+			Queries("lifecycle", "")
+
+		// Get bucket versioning
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketVersioningHandler, []Action{GetBucketVersioningAction})).
+			Queries("versioning", "")
+
+		// Get bucket cors
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketCORSHandler, []Action{GetBucketCORSAction})).
+			Queries("cors", "")
+
+		// Get bucket website
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketWebsiteHandler, []Action{GetBucketWebsiteAction})).
+			Queries("website", "")
+
+		// Get bucket object lock configuration
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketObjectLockHandler, []Action{GetBucketObjectLockConfigAction})).
+			Queries("object-lock", "")
+
+		// Get bucket logging
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLogging.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketLoggingHandler, []Action{GetBucketLoggingAction})).
+			Queries("logging", "")
+
+		// Get bucket notification
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.getBucketNotificationHandler, []Action{GetBucketNotificationAction})).
+			Queries("notification", "")
+
+		// List object versions
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+		r.Methods(http.MethodGet).
+			HandlerFunc(o.policyCheck(o.listObjectVersionsHandler, []Action{ListBucketVersionsAction})).
+			Queries("versions", "")
+
 		// List objects version 1
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjects.html
 		r.Methods(http.MethodGet).
@@ -139,6 +225,13 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 	}
 
 	var registerBucketHttpPostRouters = func(r *mux.Router) {
+		// Select object content
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+		r.Methods(http.MethodPost).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.selectObjectContentHandler, []Action{GetObjectAction})).
+			Queries("select", "", "select-type", "2")
+
 		// Create multipart upload
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateMultipartUpload.html
 		r.Methods(http.MethodPost).
@@ -179,14 +272,28 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectTagging.html
 		r.Methods(http.MethodPut).
 			Path("/{object:.+}").
-			HandlerFunc(o.policyCheck(o.putObjectTagging, []Action{PutBucketPolicyAction})).
+			HandlerFunc(o.policyCheck(o.putObjectTagging, []Action{PutObjectTaggingAction})).
 			Queries("tagging", "")
 
+		// Put object retention
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
+		r.Methods(http.MethodPut).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.putObjectRetentionHandler, []Action{PutObjectRetentionAction})).
+			Queries("retention", "")
+
+		// Put object legal hold
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
+		r.Methods(http.MethodPut).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.putObjectLegalHoldHandler, []Action{PutObjectLegalHoldAction})).
+			Queries("legal-hold", "")
+
 		// Put object xattrs
 		// Notes: ChubaoFS owned API for XAttr operation
 		r.Methods(http.MethodPut).
 			Path("/{object:.+}").
-			HandlerFunc(o.putObjectXAttr).
+			HandlerFunc(o.policyCheck(o.putObjectXAttr, []Action{PutObjectAction})).
 			Queries("xattr", "")
 
 		// Put object acl
@@ -213,6 +320,48 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		r.Methods(http.MethodPut).
 			HandlerFunc(o.policyCheck(o.putBucketPolicyHandler, []Action{PutBucketPolicyAction})).
 			Queries("policy", "")
+
+		// Put bucket lifecycle
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketLifecycleHandler, []Action{PutLifecycleConfigurationAction})).
+			Queries("lifecycle", "")
+
+		// Put bucket versioning
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketVersioningHandler, []Action{PutBucketVersioningAction})).
+			Queries("versioning", "")
+
+		// Put bucket cors
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketCORSHandler, []Action{PutBucketCORSAction})).
+			Queries("cors", "")
+
+		// Put bucket object lock configuration
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketObjectLockHandler, []Action{PutBucketObjectLockConfigAction})).
+			Queries("object-lock", "")
+
+		// Put bucket logging
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLogging.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketLoggingHandler, []Action{PutBucketLoggingAction})).
+			Queries("logging", "")
+
+		// Put bucket notification
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketNotificationHandler, []Action{PutBucketNotificationAction})).
+			Queries("notification", "")
+
+		// Put bucket website
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+		r.Methods(http.MethodPut).
+			HandlerFunc(o.policyCheck(o.putBucketWebsiteHandler, []Action{PutBucketWebsiteAction})).
+			Queries("website", "")
 	}
 
 	var registerBucketHttpDeleteRouters = func(r *mux.Router) {
@@ -226,17 +375,24 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		// Delete object tagging
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjectTagging.html
 		r.Methods(http.MethodDelete).
-			Path("/{object:.+").
-			HandlerFunc(o.policyCheck(o.deleteObjectTagging, []Action{PutBucketPolicyAction})).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.deleteObjectTagging, []Action{DeleteObjectTaggingAction})).
 			Queries("tagging", "")
 
 		// Delete object xattrs
 		// Notes: ChubaoFS owned API for XAttr operation
 		r.Methods(http.MethodDelete).
 			Path("/{object:.+}").
-			HandlerFunc(o.deleteObjectXAttr).
+			HandlerFunc(o.policyCheck(o.deleteObjectXAttr, []Action{PutObjectAction})).
 			Queries("xattr", "key", "{key:.+}}")
 
+		// Delete object version
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObject.html .
+		r.Methods(http.MethodDelete).
+			Path("/{object:.+}").
+			HandlerFunc(o.policyCheck(o.deleteObjectHandler, []Action{DeleteObjectVersionAction})).
+			Queries("versionId", "{versionId:.+}")
+
 		// Delete object
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObject.html .
 		r.Methods(http.MethodDelete).
@@ -249,6 +405,31 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 			HandlerFunc(o.policyCheck(o.deleteBucketPolicyHandler, []Action{DeleteBucketPolicyAction})).
 			Queries("policy", "")
 
+		// Delete bucket lifecycle
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
+		r.Methods(http.MethodDelete).
+			HandlerFunc(o.policyCheck(o.deleteBucketLifecycleHandler, []Action{PutLifecycleConfigurationAction})).
+			Queries("lifecycle", "")
+
+		// Delete bucket cors
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
+		r.Methods(http.MethodDelete).
+			HandlerFunc(o.policyCheck(o.deleteBucketCORSHandler, []Action{PutBucketCORSAction})).
+			Queries("cors", "")
+
+		// Delete bucket website
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
+		r.Methods(http.MethodDelete).
+			HandlerFunc(o.policyCheck(o.deleteBucketWebsiteHandler, []Action{DeleteBucketWebsiteAction})).
+			Queries("website", "")
+
+	}
+
+	var registerBucketHttpOptionsRouters = func(r *mux.Router) {
+		// CORS preflight of the bucket and the objects, which is answered by the cors middleware
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTOPTIONSobject.html
+		r.Methods(http.MethodOptions).
+			HandlerFunc(o.corsPreflightHandler)
 	}
 
 	for _, r := range bucketRouters {
@@ -257,6 +438,7 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 		registerBucketHttpPostRouters(r)
 		registerBucketHttpPutRouters(r)
 		registerBucketHttpDeleteRouters(r)
+		registerBucketHttpOptionsRouters(r)
 	}
 
 	// List buckets
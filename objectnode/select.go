@@ -0,0 +1,641 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+//
+// The records of a CSV or JSON object are read from the volume as a stream, filtered and projected
+// by the SQL expression, and returned in the event stream of the Records events, followed by the
+// Stats event and the End event. An error after the response started is returned by an error event.
+
+const (
+	SelectObjectContentLimitSize = 256 * 1024
+
+	// the size of the records buffered before returned by a Records event
+	selectRecordsChunkSize = 64 * 1024
+
+	SelectExpressionTypeSQL = "SQL"
+
+	SelectCompressionNone  = "NONE"
+	SelectCompressionGZIP  = "GZIP"
+	SelectCompressionBZIP2 = "BZIP2"
+
+	SelectFileHeaderUse    = "USE"
+	SelectFileHeaderIgnore = "IGNORE"
+	SelectFileHeaderNone   = "NONE"
+
+	SelectJSONTypeDocument = "DOCUMENT"
+	SelectJSONTypeLines    = "LINES"
+
+	SelectQuoteFieldsAlways   = "ALWAYS"
+	SelectQuoteFieldsAsNeeded = "ASNEEDED"
+)
+
+type SelectObjectContentRequest struct {
+	XMLName             xml.Name                   `xml:"SelectObjectContentRequest"`
+	Expression          string                     `xml:"Expression"`
+	ExpressionType      string                     `xml:"ExpressionType"`
+	RequestProgress     *SelectRequestProgress     `xml:"RequestProgress,omitempty"`
+	InputSerialization  *SelectInputSerialization  `xml:"InputSerialization"`
+	OutputSerialization *SelectOutputSerialization `xml:"OutputSerialization"`
+}
+
+type SelectRequestProgress struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+type SelectInputSerialization struct {
+	CompressionType string           `xml:"CompressionType,omitempty"`
+	CSV             *SelectCSVInput  `xml:"CSV,omitempty"`
+	JSON            *SelectJSONInput `xml:"JSON,omitempty"`
+	Parquet         *struct{}        `xml:"Parquet,omitempty"`
+}
+
+type SelectCSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo,omitempty"`
+	Comments                   string `xml:"Comments,omitempty"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter,omitempty"`
+	RecordDelimiter            string `xml:"RecordDelimiter,omitempty"`
+	FieldDelimiter             string `xml:"FieldDelimiter,omitempty"`
+	QuoteCharacter             string `xml:"QuoteCharacter,omitempty"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter,omitempty"`
+}
+
+type SelectJSONInput struct {
+	Type string `xml:"Type"`
+}
+
+type SelectOutputSerialization struct {
+	CSV  *SelectCSVOutput  `xml:"CSV,omitempty"`
+	JSON *SelectJSONOutput `xml:"JSON,omitempty"`
+}
+
+type SelectCSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields,omitempty"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter,omitempty"`
+	RecordDelimiter      string `xml:"RecordDelimiter,omitempty"`
+	FieldDelimiter       string `xml:"FieldDelimiter,omitempty"`
+	QuoteCharacter       string `xml:"QuoteCharacter,omitempty"`
+}
+
+type SelectJSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter,omitempty"`
+}
+
+type SelectStats struct {
+	XMLName        xml.Name `xml:"Stats"`
+	BytesScanned   int64    `xml:"BytesScanned"`
+	BytesProcessed int64    `xml:"BytesProcessed"`
+	BytesReturned  int64    `xml:"BytesReturned"`
+}
+
+// Validate checks the request has one of the input formats and one of the output formats. The
+// CSV records are delimited by the line breaks and quoted by the double quotes.
+func (req *SelectObjectContentRequest) Validate() *ErrorCode {
+	if req.Expression == "" || req.InputSerialization == nil || req.OutputSerialization == nil {
+		return &MalformedXML
+	}
+	if req.ExpressionType != SelectExpressionTypeSQL {
+		return &InvalidExpressionType
+	}
+	input, output := req.InputSerialization, req.OutputSerialization
+	switch strings.ToUpper(input.CompressionType) {
+	case "", SelectCompressionNone, SelectCompressionGZIP, SelectCompressionBZIP2:
+	default:
+		return &InvalidCompressionFormat
+	}
+	if input.Parquet != nil {
+		return &NotImplemented
+	}
+	if (input.CSV == nil) == (input.JSON == nil) || (output.CSV == nil) == (output.JSON == nil) {
+		return &MalformedXML
+	}
+	if csvInput := input.CSV; csvInput != nil {
+		switch strings.ToUpper(csvInput.FileHeaderInfo) {
+		case "", SelectFileHeaderUse, SelectFileHeaderIgnore, SelectFileHeaderNone:
+		default:
+			return &InvalidArgument
+		}
+		if utf8.RuneCountInString(csvInput.FieldDelimiter) > 1 || utf8.RuneCountInString(csvInput.Comments) > 1 {
+			return &InvalidArgument
+		}
+		if !isSupportedCSVQuote(csvInput.QuoteCharacter) || !isSupportedCSVQuote(csvInput.QuoteEscapeCharacter) ||
+			!isSupportedCSVRecordDelimiter(csvInput.RecordDelimiter) {
+			return &NotImplemented
+		}
+	}
+	if jsonInput := input.JSON; jsonInput != nil {
+		switch strings.ToUpper(jsonInput.Type) {
+		case "", SelectJSONTypeDocument, SelectJSONTypeLines:
+		default:
+			return &InvalidArgument
+		}
+	}
+	if csvOutput := output.CSV; csvOutput != nil {
+		switch strings.ToUpper(csvOutput.QuoteFields) {
+		case "", SelectQuoteFieldsAlways, SelectQuoteFieldsAsNeeded:
+		default:
+			return &InvalidArgument
+		}
+	}
+	return nil
+}
+
+func isSupportedCSVQuote(quote string) bool {
+	return quote == "" || quote == `"`
+}
+
+func isSupportedCSVRecordDelimiter(delimiter string) bool {
+	return delimiter == "" || delimiter == "\n" || delimiter == "\r\n"
+}
+
+// selectRecord is a record of the CSV or the JSON object.
+type selectRecord struct {
+	names  []string // names of the header of the CSV, nil if not used
+	fields []string // fields of the CSV
+	object map[string]interface{}
+	raw    json.RawMessage // raw JSON of the object
+}
+
+// column returns the value of the column referenced by the path.
+func (rec *selectRecord) column(path []sqlPathElem) interface{} {
+	if rec.object == nil {
+		if len(path) != 1 || path[0].isIndex {
+			return nil
+		}
+		name := path[0].name
+		if strings.HasPrefix(name, "_") {
+			if n, err := strconv.Atoi(name[1:]); err == nil && n >= 1 && n <= len(rec.fields) {
+				return rec.fields[n-1]
+			}
+		}
+		for i, header := range rec.names {
+			if header == name && i < len(rec.fields) {
+				return rec.fields[i]
+			}
+		}
+		for i, header := range rec.names {
+			if strings.EqualFold(header, name) && i < len(rec.fields) {
+				return rec.fields[i]
+			}
+		}
+		return nil
+	}
+	var value interface{} = rec.object
+	for _, elem := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if elem.isIndex {
+				return nil
+			}
+			value = v[elem.name]
+		case []interface{}:
+			if !elem.isIndex || elem.index >= len(v) {
+				return nil
+			}
+			value = v[elem.index]
+		default:
+			return nil
+		}
+	}
+	if n, ok := value.(json.Number); ok {
+		number, _ := parseSelectNumber(n.String())
+		return number
+	}
+	return value
+}
+
+// columns returns the names and the values of all the columns, in the order of the CSV header or
+// the JSON object.
+func (rec *selectRecord) columns() (names []string, values []interface{}) {
+	if rec.object == nil {
+		names = make([]string, len(rec.fields))
+		values = make([]interface{}, len(rec.fields))
+		for i, field := range rec.fields {
+			names[i], values[i] = "_"+strconv.Itoa(i+1), field
+			if i < len(rec.names) {
+				names[i] = rec.names[i]
+			}
+		}
+		return
+	}
+	names = jsonObjectKeys(rec.raw)
+	values = make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = rec.object[name]
+	}
+	return
+}
+
+// jsonObjectKeys returns the keys of the JSON object in order.
+func jsonObjectKeys(raw json.RawMessage) (keys []string) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+type selectRecordReader interface {
+	Read() (*selectRecord, error)
+}
+
+type csvRecordReader struct {
+	reader     *csv.Reader
+	headerInfo string
+	names      []string
+	started    bool
+}
+
+func newCSVRecordReader(r io.Reader, input *SelectCSVInput) *csvRecordReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if input.FieldDelimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(input.FieldDelimiter)
+	}
+	if input.Comments != "" {
+		reader.Comment, _ = utf8.DecodeRuneInString(input.Comments)
+	}
+	return &csvRecordReader{reader: reader, headerInfo: strings.ToUpper(input.FileHeaderInfo)}
+}
+
+func (r *csvRecordReader) Read() (*selectRecord, error) {
+	if !r.started {
+		r.started = true
+		if r.headerInfo == SelectFileHeaderUse || r.headerInfo == SelectFileHeaderIgnore {
+			header, err := r.reader.Read()
+			if err != nil {
+				return nil, err
+			}
+			if r.headerInfo == SelectFileHeaderUse {
+				r.names = header
+			}
+		}
+	}
+	fields, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &selectRecord{names: r.names, fields: fields}, nil
+}
+
+var errJSONRecordNotObject = errors.New("json record is not an object")
+
+// jsonRecordReader reads the JSON objects of both the types DOCUMENT and LINES, and the elements
+// of the top level arrays are read as the records.
+type jsonRecordReader struct {
+	decoder *json.Decoder
+	pending []json.RawMessage
+}
+
+func newJSONRecordReader(r io.Reader) *jsonRecordReader {
+	return &jsonRecordReader{decoder: json.NewDecoder(r)}
+}
+
+func (r *jsonRecordReader) Read() (*selectRecord, error) {
+	var raw json.RawMessage
+	for {
+		if len(r.pending) > 0 {
+			raw, r.pending = r.pending[0], r.pending[1:]
+		} else if err := r.decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			if err := json.Unmarshal(raw, &r.pending); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, errJSONRecordNotObject
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	rec := &selectRecord{raw: raw}
+	if err := decoder.Decode(&rec.object); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+type selectRecordWriter interface {
+	write(buf *bytes.Buffer, names []string, values []interface{})
+}
+
+type csvRecordWriter struct {
+	fieldDelimiter  string
+	recordDelimiter string
+	quote           string
+	quoteEscape     string
+	quoteAlways     bool
+}
+
+func newCSVRecordWriter(output *SelectCSVOutput) *csvRecordWriter {
+	w := &csvRecordWriter{
+		fieldDelimiter:  output.FieldDelimiter,
+		recordDelimiter: output.RecordDelimiter,
+		quote:           output.QuoteCharacter,
+		quoteEscape:     output.QuoteEscapeCharacter,
+		quoteAlways:     strings.ToUpper(output.QuoteFields) == SelectQuoteFieldsAlways,
+	}
+	if w.fieldDelimiter == "" {
+		w.fieldDelimiter = ","
+	}
+	if w.recordDelimiter == "" {
+		w.recordDelimiter = "\n"
+	}
+	if w.quote == "" {
+		w.quote = `"`
+	}
+	if w.quoteEscape == "" {
+		w.quoteEscape = w.quote
+	}
+	return w
+}
+
+func (w *csvRecordWriter) write(buf *bytes.Buffer, names []string, values []interface{}) {
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteString(w.fieldDelimiter)
+		}
+		field := formatSelectValue(value)
+		if w.quoteAlways || strings.Contains(field, w.fieldDelimiter) || strings.Contains(field, w.quote) ||
+			strings.ContainsAny(field, "\r\n") || strings.Contains(field, w.recordDelimiter) {
+			buf.WriteString(w.quote)
+			buf.WriteString(strings.Replace(field, w.quote, w.quoteEscape+w.quote, -1))
+			buf.WriteString(w.quote)
+		} else {
+			buf.WriteString(field)
+		}
+	}
+	buf.WriteString(w.recordDelimiter)
+}
+
+type jsonRecordWriter struct {
+	recordDelimiter string
+}
+
+func newJSONRecordWriter(output *SelectJSONOutput) *jsonRecordWriter {
+	w := &jsonRecordWriter{recordDelimiter: output.RecordDelimiter}
+	if w.recordDelimiter == "" {
+		w.recordDelimiter = "\n"
+	}
+	return w
+}
+
+func (w *jsonRecordWriter) write(buf *bytes.Buffer, names []string, values []interface{}) {
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(values[i])
+		if err != nil {
+			// e.g. the NaN of the floats
+			value = []byte("null")
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	buf.WriteString(w.recordDelimiter)
+}
+
+// countingReader counts the bytes read, which are the bytes scanned and the bytes processed of the stats.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.n += int64(n)
+	return
+}
+
+// eventStreamWriter writes the messages of the event stream.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTSelectObjectAppendix.html
+type eventStreamWriter struct {
+	writer io.Writer
+}
+
+func (e *eventStreamWriter) writeMessage(headers [][2]string, payload []byte) error {
+	var headerBuf bytes.Buffer
+	for _, header := range headers {
+		headerBuf.WriteByte(byte(len(header[0])))
+		headerBuf.WriteString(header[0])
+		// the value type 7 is string
+		headerBuf.WriteByte(7)
+		_ = binary.Write(&headerBuf, binary.BigEndian, uint16(len(header[1])))
+		headerBuf.WriteString(header[1])
+	}
+	var message bytes.Buffer
+	totalLength := 4 + 4 + 4 + headerBuf.Len() + len(payload) + 4
+	_ = binary.Write(&message, binary.BigEndian, uint32(totalLength))
+	_ = binary.Write(&message, binary.BigEndian, uint32(headerBuf.Len()))
+	_ = binary.Write(&message, binary.BigEndian, crc32.ChecksumIEEE(message.Bytes()))
+	message.Write(headerBuf.Bytes())
+	message.Write(payload)
+	_ = binary.Write(&message, binary.BigEndian, crc32.ChecksumIEEE(message.Bytes()))
+	if _, err := e.writer.Write(message.Bytes()); err != nil {
+		return err
+	}
+	if flusher, ok := e.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (e *eventStreamWriter) writeEvent(eventType, contentType string, payload []byte) error {
+	headers := [][2]string{{":message-type", "event"}, {":event-type", eventType}}
+	if contentType != "" {
+		headers = append(headers, [2]string{":content-type", contentType})
+	}
+	return e.writeMessage(headers, payload)
+}
+
+func (e *eventStreamWriter) writeRecords(payload []byte) error {
+	return e.writeEvent("Records", HeaderValueTypeStream, payload)
+}
+
+func (e *eventStreamWriter) writeStats(eventType string, stats *SelectStats) error {
+	stats.XMLName = xml.Name{Local: eventType}
+	payload, err := xml.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return e.writeEvent(eventType, "text/xml", payload)
+}
+
+func (e *eventStreamWriter) writeEnd() error {
+	return e.writeEvent("End", "", nil)
+}
+
+func (e *eventStreamWriter) writeError(ec *ErrorCode) error {
+	headers := [][2]string{{":message-type", "error"}, {":error-code", ec.ErrorCode}, {":error-message", ec.ErrorMessage}}
+	return e.writeMessage(headers, nil)
+}
+
+// selectExecutor executes the statement over the records, and writes the results to the event stream.
+type selectExecutor struct {
+	stmt      *selectStatement
+	reader    selectRecordReader
+	writer    selectRecordWriter
+	events    *eventStreamWriter
+	progress  bool
+	scanned   *countingReader
+	processed *countingReader
+	returned  int64
+}
+
+// newSelectExecutor returns the executor reading the records of the object from the reader.
+func newSelectExecutor(stmt *selectStatement, req *SelectObjectContentRequest, reader io.Reader, writer io.Writer) (e *selectExecutor, err error) {
+	e = &selectExecutor{
+		stmt:     stmt,
+		events:   &eventStreamWriter{writer: writer},
+		progress: req.RequestProgress != nil && req.RequestProgress.Enabled,
+		scanned:  &countingReader{reader: reader},
+	}
+	input, output := req.InputSerialization, req.OutputSerialization
+	var decompressed io.Reader = e.scanned
+	switch strings.ToUpper(input.CompressionType) {
+	case SelectCompressionGZIP:
+		if decompressed, err = gzip.NewReader(e.scanned); err != nil {
+			return nil, err
+		}
+	case SelectCompressionBZIP2:
+		decompressed = bzip2.NewReader(e.scanned)
+	}
+	e.processed = &countingReader{reader: decompressed}
+	if input.CSV != nil {
+		e.reader = newCSVRecordReader(e.processed, input.CSV)
+	} else {
+		e.reader = newJSONRecordReader(e.processed)
+	}
+	if output.CSV != nil {
+		e.writer = newCSVRecordWriter(output.CSV)
+	} else {
+		e.writer = newJSONRecordWriter(output.JSON)
+	}
+	return e, nil
+}
+
+func (e *selectExecutor) stats() *SelectStats {
+	return &SelectStats{BytesScanned: e.scanned.n, BytesProcessed: e.processed.n, BytesReturned: e.returned}
+}
+
+func (e *selectExecutor) flush(buf *bytes.Buffer) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+	if err := e.events.writeRecords(buf.Bytes()); err != nil {
+		return err
+	}
+	e.returned += int64(buf.Len())
+	buf.Reset()
+	if e.progress {
+		return e.events.writeStats("Progress", e.stats())
+	}
+	return nil
+}
+
+// run executes the statement, and returns the error code of the records failed to read, or the
+// error failed to write the event stream.
+func (e *selectExecutor) run() (ec *ErrorCode, err error) {
+	var buf bytes.Buffer
+	var rows int64
+	for e.stmt.limit < 0 || e.stmt.aggregate || rows < e.stmt.limit {
+		var rec *selectRecord
+		if rec, err = e.reader.Read(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return selectReadErrorCode(err), nil
+		}
+		if e.stmt.where != nil && e.stmt.where.eval(rec) != true {
+			continue
+		}
+		if e.stmt.aggregate {
+			e.stmt.accumulate(rec)
+			continue
+		}
+		names, values := e.stmt.project(rec)
+		e.writer.write(&buf, names, values)
+		rows++
+		if buf.Len() >= selectRecordsChunkSize {
+			if err = e.flush(&buf); err != nil {
+				return
+			}
+		}
+	}
+	if e.stmt.aggregate && e.stmt.limit != 0 {
+		names, values := e.stmt.project(nil)
+		e.writer.write(&buf, names, values)
+	}
+	if err = e.flush(&buf); err != nil {
+		return
+	}
+	if err = e.events.writeStats("Stats", e.stats()); err != nil {
+		return
+	}
+	return nil, e.events.writeEnd()
+}
+
+func selectReadErrorCode(err error) *ErrorCode {
+	switch err.(type) {
+	case *csv.ParseError:
+		return &CSVParsingError
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return &JSONParsingError
+	}
+	if err == errJSONRecordNotObject || err == io.ErrUnexpectedEOF {
+		return &JSONParsingError
+	}
+	return &InternalError
+}
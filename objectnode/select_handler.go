@@ -0,0 +1,112 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Select object content
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+func (o *ObjectNode) selectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("selectObjectContentHandler: select object content: requestID(%v)", RequestIDFromRequest(r))
+	_, _, object, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("selectObjectContentHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, SelectObjectContentLimitSize+1)); err != nil {
+		log.LogErrorf("selectObjectContentHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	if len(body) > SelectObjectContentLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+	var req = &SelectObjectContentRequest{}
+	if err = UnmarshalXMLEntity(body, req); err != nil {
+		log.LogWarnf("selectObjectContentHandler: unmarshal request fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if ec := req.Validate(); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+	var stmt *selectStatement
+	if stmt, err = parseSelectStatement(req.Expression); err != nil {
+		log.LogWarnf("selectObjectContentHandler: parse expression fail: requestID(%v) expression(%v) err(%v)",
+			RequestIDFromRequest(r), req.Expression, err)
+		_ = UnsupportedSyntax.ServeResponse(w, r)
+		return
+	}
+
+	var fileInfo *FSFileInfo
+	if fileInfo, err = vl.FileInfo(object); err != nil {
+		log.LogWarnf("selectObjectContentHandler: get file info fail: requestID(%v) path(%v) err(%v)",
+			RequestIDFromRequest(r), object, err)
+		_ = NoSuchKey.ServeResponse(w, r)
+		return
+	}
+
+	// the object is read from the volume as a stream, and the reading is stopped by closing the
+	// pipe once the executor finishes, e.g. by the limit
+	reader, writer := io.Pipe()
+	defer func() {
+		_ = reader.Close()
+	}()
+	go func() {
+		readErr := vl.ReadFile(object, writer, 0, uint64(fileInfo.Size))
+		if readErr != nil && readErr != io.ErrClosedPipe {
+			log.LogErrorf("selectObjectContentHandler: read from volume fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				RequestIDFromRequest(r), vl.name, object, readErr)
+		}
+		_ = writer.CloseWithError(readErr)
+	}()
+
+	var executor *selectExecutor
+	if executor, err = newSelectExecutor(stmt, req, reader, w); err != nil {
+		log.LogWarnf("selectObjectContentHandler: decompress object fail: requestID(%v) path(%v) err(%v)",
+			RequestIDFromRequest(r), object, err)
+		_ = InvalidCompressionFormat.ServeResponse(w, r)
+		return
+	}
+	var ec *ErrorCode
+	if ec, err = executor.run(); err != nil {
+		log.LogErrorf("selectObjectContentHandler: write event stream fail: requestID(%v) path(%v) err(%v)",
+			RequestIDFromRequest(r), object, err)
+		return
+	}
+	if ec != nil {
+		log.LogWarnf("selectObjectContentHandler: read records fail: requestID(%v) path(%v) error(%v)",
+			RequestIDFromRequest(r), object, ec.ErrorCode)
+		if err = executor.events.writeError(ec); err != nil {
+			log.LogErrorf("selectObjectContentHandler: write error event fail: requestID(%v) path(%v) err(%v)",
+				RequestIDFromRequest(r), object, err)
+		}
+		return
+	}
+	log.LogDebugf("selectObjectContentHandler: object content selected: requestID(%v) path(%v) stats(%v)",
+		RequestIDFromRequest(r), object, executor.stats())
+	return
+}
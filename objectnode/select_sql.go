@@ -0,0 +1,1101 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/s3-glacier-select-sql-reference-select.html
+//
+// The SQL of SelectObjectContent is a subset of the one of Amazon S3:
+//
+//   SELECT * | expr [[AS] alias], ... FROM S3Object[[*]] [[AS] alias] [WHERE expr] [LIMIT n]
+//
+// The expressions are made of the columns, the literals, the operators of the comparison, the
+// logic and the arithmetic, IS [NOT] NULL, [NOT] LIKE, [NOT] BETWEEN, [NOT] IN, CAST and the
+// functions LOWER, UPPER, TRIM, CHAR_LENGTH, SUBSTRING and COALESCE. The projections are either
+// all the aggregates COUNT, SUM, AVG, MIN and MAX, or none of them. The columns of the CSV are
+// referenced by the positions like _1, or by the names of the header, and the ones of the JSON by
+// the paths like s.a.b[0]. The evaluation is lenient: the missing columns and the values of the
+// mismatched types are evaluated as NULL.
+
+// sqlSyntaxError is the error of the expression not supported or invalid.
+type sqlSyntaxError string
+
+func (e sqlSyntaxError) Error() string {
+	return "sql syntax error: " + string(e)
+}
+
+type sqlTokenKind int
+
+const (
+	sqlTokenEOF sqlTokenKind = iota
+	sqlTokenIdent
+	sqlTokenQuotedIdent
+	sqlTokenString
+	sqlTokenNumber
+	sqlTokenOperator
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+func lexSQL(s string) (tokens []sqlToken, err error) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '\'' || c == '"':
+			// the quote is escaped by doubling it
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, sqlSyntaxError(fmt.Sprintf("unterminated quote at %v", i))
+				}
+				if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c {
+						sb.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(s[j])
+				j++
+			}
+			kind := sqlTokenString
+			if c == '"' {
+				kind = sqlTokenQuotedIdent
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9' || (c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && s[k] >= '0' && s[k] <= '9' {
+					for j = k; j < len(s) && s[j] >= '0' && s[j] <= '9'; j++ {
+					}
+				}
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenNumber, text: s[i:j]})
+			i = j
+		case c == '_' || c < utf8.RuneSelf && unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] < utf8.RuneSelf && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])))) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenIdent, text: s[i:j]})
+			i = j
+		default:
+			if i+1 < len(s) {
+				if op := s[i : i+2]; op == "<=" || op == ">=" || op == "<>" || op == "!=" || op == "||" {
+					tokens = append(tokens, sqlToken{kind: sqlTokenOperator, text: op})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("*,().=<>+-/%[]", rune(c)) {
+				return nil, sqlSyntaxError(fmt.Sprintf("unexpected character %q at %v", c, i))
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenOperator, text: string(c)})
+			i++
+		}
+	}
+	return append(tokens, sqlToken{kind: sqlTokenEOF}), nil
+}
+
+// sqlExpr is an expression evaluated against a record, which is nil for the aggregates.
+type sqlExpr interface {
+	eval(rec *selectRecord) interface{}
+}
+
+type selectProjection struct {
+	expr sqlExpr
+	name string
+}
+
+type selectStatement struct {
+	projections []*selectProjection // nil if all the columns are selected by '*'
+	alias       string              // alias of S3Object, empty if none
+	where       sqlExpr
+	limit       int64 // -1 if no limit
+	aggregate   bool
+}
+
+// project returns the names and the values of the columns selected from the record.
+func (s *selectStatement) project(rec *selectRecord) (names []string, values []interface{}) {
+	if s.projections == nil {
+		return rec.columns()
+	}
+	names = make([]string, len(s.projections))
+	values = make([]interface{}, len(s.projections))
+	for i, p := range s.projections {
+		names[i], values[i] = p.name, p.expr.eval(rec)
+	}
+	return
+}
+
+// accumulate accumulates the record by the aggregates of the projections.
+func (s *selectStatement) accumulate(rec *selectRecord) {
+	for _, p := range s.projections {
+		p.expr.(*sqlAggregate).accumulate(rec)
+	}
+}
+
+type sqlParser struct {
+	tokens       []sqlToken
+	pos          int
+	stmt         *selectStatement
+	inProjection bool
+}
+
+func parseSelectStatement(expression string) (stmt *selectStatement, err error) {
+	var tokens []sqlToken
+	if tokens, err = lexSQL(expression); err != nil {
+		return
+	}
+	p := &sqlParser{tokens: tokens, stmt: &selectStatement{limit: -1}}
+	defer func() {
+		// the parser panics with the syntax errors to unwind
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(sqlSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			stmt, err = nil, syntaxErr
+		}
+	}()
+	p.parseStatement()
+	return p.stmt, nil
+}
+
+func (p *sqlParser) fail(format string, args ...interface{}) {
+	panic(sqlSyntaxError(fmt.Sprintf(format, args...)))
+}
+
+func (p *sqlParser) peek() sqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *sqlParser) next() sqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != sqlTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sqlParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == sqlTokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *sqlParser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == sqlTokenOperator && t.text == op
+}
+
+func (p *sqlParser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) acceptOperator(op string) bool {
+	if p.isOperator(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectKeyword(keyword string) {
+	if !p.acceptKeyword(keyword) {
+		p.fail("expect %v but %q found", keyword, p.peek().text)
+	}
+}
+
+func (p *sqlParser) expectOperator(op string) {
+	if !p.acceptOperator(op) {
+		p.fail("expect %q but %q found", op, p.peek().text)
+	}
+}
+
+var sqlReservedKeywords = []string{"SELECT", "FROM", "WHERE", "AS", "AND", "OR", "NOT", "IS", "NULL", "MISSING",
+	"LIKE", "ESCAPE", "BETWEEN", "IN", "LIMIT", "TRUE", "FALSE", "CAST"}
+
+func (p *sqlParser) isReserved() bool {
+	for _, keyword := range sqlReservedKeywords {
+		if p.isKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAlias parses the optional alias with or without AS.
+func (p *sqlParser) parseAlias() string {
+	if p.acceptKeyword("AS") {
+		t := p.next()
+		if t.kind != sqlTokenIdent && t.kind != sqlTokenQuotedIdent {
+			p.fail("expect alias but %q found", t.text)
+		}
+		return t.text
+	}
+	if t := p.peek(); t.kind == sqlTokenQuotedIdent || t.kind == sqlTokenIdent && !p.isReserved() {
+		p.pos++
+		return t.text
+	}
+	return ""
+}
+
+func (p *sqlParser) parseStatement() {
+	p.expectKeyword("SELECT")
+	if !p.acceptOperator("*") {
+		p.inProjection = true
+		for {
+			expr := p.parseExpr()
+			projection := &selectProjection{expr: expr, name: p.parseAlias()}
+			if projection.name == "" {
+				projection.name = "_" + strconv.Itoa(len(p.stmt.projections)+1)
+				if column, ok := expr.(*sqlColumn); ok && !column.path[len(column.path)-1].isIndex {
+					projection.name = column.path[len(column.path)-1].name
+				}
+			}
+			p.stmt.projections = append(p.stmt.projections, projection)
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+		p.inProjection = false
+		var aggregates int
+		for _, projection := range p.stmt.projections {
+			if _, ok := projection.expr.(*sqlAggregate); ok {
+				aggregates++
+			}
+		}
+		if aggregates > 0 && aggregates != len(p.stmt.projections) {
+			p.fail("aggregates mixed with the other projections")
+		}
+		p.stmt.aggregate = aggregates > 0
+	}
+
+	p.expectKeyword("FROM")
+	if !p.acceptKeyword("S3Object") {
+		p.fail("expect S3Object but %q found", p.peek().text)
+	}
+	if p.acceptOperator("[") {
+		p.expectOperator("*")
+		p.expectOperator("]")
+	}
+	p.stmt.alias = p.parseAlias()
+
+	if p.acceptKeyword("WHERE") {
+		p.stmt.where = p.parseExpr()
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		limit, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != sqlTokenNumber || err != nil || limit < 0 {
+			p.fail("invalid limit %q", t.text)
+		}
+		p.stmt.limit = limit
+	}
+	if t := p.peek(); t.kind != sqlTokenEOF {
+		p.fail("unexpected %q", t.text)
+	}
+}
+
+func (p *sqlParser) parseExpr() sqlExpr {
+	return p.parseOr()
+}
+
+func (p *sqlParser) parseOr() sqlExpr {
+	left := p.parseAnd()
+	for p.acceptKeyword("OR") {
+		left = &sqlBinary{op: "OR", left: left, right: p.parseAnd()}
+	}
+	return left
+}
+
+func (p *sqlParser) parseAnd() sqlExpr {
+	left := p.parseNot()
+	for p.acceptKeyword("AND") {
+		left = &sqlBinary{op: "AND", left: left, right: p.parseNot()}
+	}
+	return left
+}
+
+func (p *sqlParser) parseNot() sqlExpr {
+	if p.acceptKeyword("NOT") {
+		return &sqlNot{expr: p.parseNot()}
+	}
+	return p.parseComparison()
+}
+
+func (p *sqlParser) parseComparison() sqlExpr {
+	left := p.parseConcat()
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.acceptOperator(op) {
+			return &sqlBinary{op: op, left: left, right: p.parseConcat()}
+		}
+	}
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") && !p.acceptKeyword("MISSING") {
+			p.fail("expect NULL but %q found", p.peek().text)
+		}
+		return &sqlIsNull{expr: left, not: not}
+	}
+	not := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		like := &sqlLike{expr: left, pattern: p.parseConcat(), not: not}
+		if p.acceptKeyword("ESCAPE") {
+			like.escape = p.parseConcat()
+		}
+		if pattern, ok := like.pattern.(*sqlLiteral); ok && like.escape == nil {
+			var err error
+			if like.re, err = likeRegexp(formatSelectValue(pattern.value), ""); err != nil {
+				p.fail("invalid pattern %q", pattern.value)
+			}
+		}
+		return like
+	case p.acceptKeyword("BETWEEN"):
+		lower := p.parseConcat()
+		p.expectKeyword("AND")
+		return &sqlBetween{expr: left, lower: lower, upper: p.parseConcat(), not: not}
+	case p.acceptKeyword("IN"):
+		in := &sqlIn{expr: left, not: not}
+		p.expectOperator("(")
+		for {
+			in.list = append(in.list, p.parseExpr())
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+		p.expectOperator(")")
+		return in
+	}
+	if not {
+		p.fail("expect LIKE, BETWEEN or IN after NOT but %q found", p.peek().text)
+	}
+	return left
+}
+
+func (p *sqlParser) parseConcat() sqlExpr {
+	left := p.parseAdditive()
+	for p.acceptOperator("||") {
+		left = &sqlBinary{op: "||", left: left, right: p.parseAdditive()}
+	}
+	return left
+}
+
+func (p *sqlParser) parseAdditive() sqlExpr {
+	left := p.parseMultiplicative()
+	for {
+		if p.acceptOperator("+") {
+			left = &sqlBinary{op: "+", left: left, right: p.parseMultiplicative()}
+		} else if p.acceptOperator("-") {
+			left = &sqlBinary{op: "-", left: left, right: p.parseMultiplicative()}
+		} else {
+			return left
+		}
+	}
+}
+
+func (p *sqlParser) parseMultiplicative() sqlExpr {
+	left := p.parseUnary()
+	for {
+		var op string
+		for _, candidate := range []string{"*", "/", "%"} {
+			if p.acceptOperator(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left
+		}
+		left = &sqlBinary{op: op, left: left, right: p.parseUnary()}
+	}
+}
+
+func (p *sqlParser) parseUnary() sqlExpr {
+	if p.acceptOperator("-") {
+		return &sqlBinary{op: "-", left: &sqlLiteral{value: int64(0)}, right: p.parseUnary()}
+	}
+	if p.acceptOperator("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *sqlParser) parsePrimary() sqlExpr {
+	t := p.next()
+	switch t.kind {
+	case sqlTokenNumber:
+		value, ok := parseSelectNumber(t.text)
+		if !ok {
+			p.fail("invalid number %q", t.text)
+		}
+		return &sqlLiteral{value: value}
+	case sqlTokenString:
+		return &sqlLiteral{value: t.text}
+	case sqlTokenOperator:
+		if t.text != "(" {
+			p.fail("unexpected %q", t.text)
+		}
+		expr := p.parseExpr()
+		p.expectOperator(")")
+		return expr
+	case sqlTokenQuotedIdent:
+		return p.parseColumn(t.text)
+	case sqlTokenIdent:
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return &sqlLiteral{value: true}
+		case "FALSE":
+			return &sqlLiteral{value: false}
+		case "NULL", "MISSING":
+			return &sqlLiteral{}
+		case "CAST":
+			p.expectOperator("(")
+			expr := p.parseExpr()
+			p.expectKeyword("AS")
+			typ := strings.ToUpper(p.next().text)
+			if !contains(sqlCastTypes, typ) {
+				p.fail("unsupported type %q", typ)
+			}
+			p.expectOperator(")")
+			return &sqlCast{expr: expr, typ: typ}
+		}
+		if p.isOperator("(") {
+			return p.parseFunction(strings.ToUpper(t.text))
+		}
+		if contains(sqlReservedKeywords, strings.ToUpper(t.text)) {
+			p.fail("unexpected %q", t.text)
+		}
+		return p.parseColumn(t.text)
+	}
+	p.fail("unexpected end of the expression")
+	return nil
+}
+
+func (p *sqlParser) parseColumn(name string) sqlExpr {
+	column := &sqlColumn{path: []sqlPathElem{{name: name}}, stmt: p.stmt}
+	for {
+		if p.acceptOperator(".") {
+			t := p.next()
+			if t.kind != sqlTokenIdent && t.kind != sqlTokenQuotedIdent {
+				p.fail("expect name but %q found", t.text)
+			}
+			column.path = append(column.path, sqlPathElem{name: t.text})
+		} else if p.acceptOperator("[") {
+			t := p.next()
+			index, err := strconv.Atoi(t.text)
+			if t.kind != sqlTokenNumber || err != nil || index < 0 {
+				p.fail("invalid index %q", t.text)
+			}
+			p.expectOperator("]")
+			column.path = append(column.path, sqlPathElem{index: index, isIndex: true})
+		} else {
+			return column
+		}
+	}
+}
+
+func (p *sqlParser) parseFunction(name string) sqlExpr {
+	p.expectOperator("(")
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		if !p.inProjection {
+			p.fail("aggregate %v not in the projections", name)
+		}
+		p.inProjection = false
+		aggregate := &sqlAggregate{fn: name}
+		if name != "COUNT" || !p.acceptOperator("*") {
+			aggregate.arg = p.parseExpr()
+		}
+		p.expectOperator(")")
+		p.inProjection = true
+		return aggregate
+	}
+	fn := &sqlFunction{name: name}
+	if !p.acceptOperator(")") {
+		for {
+			fn.args = append(fn.args, p.parseExpr())
+			if !p.acceptOperator(",") {
+				break
+			}
+		}
+		p.expectOperator(")")
+	}
+	var valid bool
+	switch name {
+	case "LOWER", "UPPER", "TRIM", "CHAR_LENGTH", "CHARACTER_LENGTH":
+		valid = len(fn.args) == 1
+	case "SUBSTRING":
+		valid = len(fn.args) == 2 || len(fn.args) == 3
+	case "COALESCE":
+		valid = len(fn.args) > 0
+	default:
+		p.fail("unsupported function %v", name)
+	}
+	if !valid {
+		p.fail("invalid arguments of function %v", name)
+	}
+	return fn
+}
+
+type sqlLiteral struct {
+	value interface{}
+}
+
+func (e *sqlLiteral) eval(rec *selectRecord) interface{} {
+	return e.value
+}
+
+type sqlPathElem struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type sqlColumn struct {
+	path []sqlPathElem
+	stmt *selectStatement
+}
+
+func (e *sqlColumn) eval(rec *selectRecord) interface{} {
+	path := e.path
+	// the columns may be qualified by the alias or S3Object
+	if len(path) > 1 && !path[0].isIndex &&
+		(strings.EqualFold(path[0].name, "S3Object") || e.stmt.alias != "" && strings.EqualFold(path[0].name, e.stmt.alias)) {
+		path = path[1:]
+	}
+	return rec.column(path)
+}
+
+type sqlBinary struct {
+	op          string
+	left, right sqlExpr
+}
+
+func (e *sqlBinary) eval(rec *selectRecord) interface{} {
+	switch e.op {
+	case "AND":
+		left, right := e.left.eval(rec), e.right.eval(rec)
+		if left == false || right == false {
+			return false
+		}
+		if left == true && right == true {
+			return true
+		}
+		return nil
+	case "OR":
+		left, right := e.left.eval(rec), e.right.eval(rec)
+		if left == true || right == true {
+			return true
+		}
+		if left == false && right == false {
+			return false
+		}
+		return nil
+	}
+	left, right := e.left.eval(rec), e.right.eval(rec)
+	if left == nil || right == nil {
+		return nil
+	}
+	switch e.op {
+	case "||":
+		return formatSelectValue(left) + formatSelectValue(right)
+	case "+", "-", "*", "/", "%":
+		return selectArithmetic(e.op, left, right)
+	}
+	c, ok := compareSelectValues(left, right)
+	if !ok {
+		return nil
+	}
+	switch e.op {
+	case "=":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return nil
+}
+
+type sqlNot struct {
+	expr sqlExpr
+}
+
+func (e *sqlNot) eval(rec *selectRecord) interface{} {
+	if b, ok := e.expr.eval(rec).(bool); ok {
+		return !b
+	}
+	return nil
+}
+
+type sqlIsNull struct {
+	expr sqlExpr
+	not  bool
+}
+
+func (e *sqlIsNull) eval(rec *selectRecord) interface{} {
+	return (e.expr.eval(rec) == nil) != e.not
+}
+
+type sqlLike struct {
+	expr, pattern, escape sqlExpr
+	re                    *regexp.Regexp // compiled pattern if it is a literal
+	not                   bool
+}
+
+func (e *sqlLike) eval(rec *selectRecord) interface{} {
+	value := e.expr.eval(rec)
+	if value == nil {
+		return nil
+	}
+	re := e.re
+	if re == nil {
+		pattern := e.pattern.eval(rec)
+		var escape interface{} = ""
+		if e.escape != nil {
+			escape = e.escape.eval(rec)
+		}
+		if pattern == nil || escape == nil {
+			return nil
+		}
+		var err error
+		if re, err = likeRegexp(formatSelectValue(pattern), formatSelectValue(escape)); err != nil {
+			return nil
+		}
+	}
+	return re.MatchString(formatSelectValue(value)) != e.not
+}
+
+// likeRegexp converts the pattern of LIKE to the regular expression, in which '%' matches any
+// sequence and '_' matches any character, unless escaped by the escape character.
+func likeRegexp(pattern, escape string) (*regexp.Regexp, error) {
+	if utf8.RuneCountInString(escape) > 1 {
+		return nil, sqlSyntaxError("invalid escape " + escape)
+	}
+	escapeRune, _ := utf8.DecodeRuneInString(escape)
+	var sb strings.Builder
+	sb.WriteString("^(?s:")
+	var escaped bool
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case escape != "" && c == escapeRune:
+			escaped = true
+		case c == '%':
+			sb.WriteString(".*")
+		case c == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if escaped {
+		return nil, sqlSyntaxError("pattern ends with escape " + escape)
+	}
+	sb.WriteString(")$")
+	return regexp.Compile(sb.String())
+}
+
+type sqlBetween struct {
+	expr, lower, upper sqlExpr
+	not                bool
+}
+
+func (e *sqlBetween) eval(rec *selectRecord) interface{} {
+	value := e.expr.eval(rec)
+	lower, ok1 := compareSelectValues(value, e.lower.eval(rec))
+	upper, ok2 := compareSelectValues(value, e.upper.eval(rec))
+	if !ok1 || !ok2 {
+		return nil
+	}
+	return (lower >= 0 && upper <= 0) != e.not
+}
+
+type sqlIn struct {
+	expr sqlExpr
+	list []sqlExpr
+	not  bool
+}
+
+func (e *sqlIn) eval(rec *selectRecord) interface{} {
+	value := e.expr.eval(rec)
+	if value == nil {
+		return nil
+	}
+	for _, item := range e.list {
+		if c, ok := compareSelectValues(value, item.eval(rec)); ok && c == 0 {
+			return !e.not
+		}
+	}
+	return e.not
+}
+
+var sqlCastTypes = []string{"INT", "INTEGER", "FLOAT", "DECIMAL", "NUMERIC", "STRING", "VARCHAR", "CHAR", "BOOL", "BOOLEAN"}
+
+type sqlCast struct {
+	expr sqlExpr
+	typ  string
+}
+
+func (e *sqlCast) eval(rec *selectRecord) interface{} {
+	value := e.expr.eval(rec)
+	if value == nil {
+		return nil
+	}
+	switch e.typ {
+	case "INT", "INTEGER":
+		switch n := toSelectNumber(value).(type) {
+		case int64:
+			return n
+		case float64:
+			return int64(n)
+		}
+	case "FLOAT", "DECIMAL", "NUMERIC":
+		switch n := toSelectNumber(value).(type) {
+		case int64:
+			return float64(n)
+		case float64:
+			return n
+		}
+	case "STRING", "VARCHAR", "CHAR":
+		return formatSelectValue(value)
+	case "BOOL", "BOOLEAN":
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b
+			}
+		case int64:
+			return v != 0
+		case float64:
+			return v != 0
+		}
+	}
+	return nil
+}
+
+type sqlFunction struct {
+	name string
+	args []sqlExpr
+}
+
+func (e *sqlFunction) eval(rec *selectRecord) interface{} {
+	if e.name == "COALESCE" {
+		for _, arg := range e.args {
+			if value := arg.eval(rec); value != nil {
+				return value
+			}
+		}
+		return nil
+	}
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		if args[i] = arg.eval(rec); args[i] == nil {
+			return nil
+		}
+	}
+	s := formatSelectValue(args[0])
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(s)
+	case "UPPER":
+		return strings.ToUpper(s)
+	case "TRIM":
+		return strings.TrimSpace(s)
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(utf8.RuneCountInString(s))
+	case "SUBSTRING":
+		// the start position is 1-based, and the positions before 1 shorten the length
+		runes := []rune(s)
+		start, ok := toSelectNumber(args[1]).(int64)
+		if !ok {
+			return nil
+		}
+		end := int64(len(runes))
+		if len(args) == 3 {
+			length, ok := toSelectNumber(args[2]).(int64)
+			if !ok || length < 0 {
+				return nil
+			}
+			end = start + length - 1
+		}
+		if start < 1 {
+			start = 1
+		}
+		if end > int64(len(runes)) {
+			end = int64(len(runes))
+		}
+		if start > end {
+			return ""
+		}
+		return string(runes[start-1 : end])
+	}
+	return nil
+}
+
+type sqlAggregate struct {
+	fn       string
+	arg      sqlExpr // nil for COUNT(*)
+	count    int64
+	sumInt   int64
+	sumFloat float64
+	isFloat  bool
+	value    interface{} // value of MIN and MAX
+}
+
+func (e *sqlAggregate) accumulate(rec *selectRecord) {
+	if e.arg == nil {
+		e.count++
+		return
+	}
+	value := e.arg.eval(rec)
+	if value == nil {
+		return
+	}
+	switch e.fn {
+	case "SUM", "AVG":
+		switch n := toSelectNumber(value).(type) {
+		case int64:
+			e.sumInt += n
+		case float64:
+			e.sumFloat += n
+			e.isFloat = true
+		default:
+			return
+		}
+	case "MIN", "MAX":
+		if e.value == nil {
+			e.value = value
+		} else if c, ok := compareSelectValues(value, e.value); ok && (e.fn == "MIN" && c < 0 || e.fn == "MAX" && c > 0) {
+			e.value = value
+		}
+	}
+	e.count++
+}
+
+func (e *sqlAggregate) eval(rec *selectRecord) interface{} {
+	switch e.fn {
+	case "COUNT":
+		return e.count
+	case "SUM":
+		if e.count == 0 {
+			return nil
+		}
+		if e.isFloat {
+			return e.sumFloat + float64(e.sumInt)
+		}
+		return e.sumInt
+	case "AVG":
+		if e.count == 0 {
+			return nil
+		}
+		return (e.sumFloat + float64(e.sumInt)) / float64(e.count)
+	}
+	return e.value
+}
+
+// parseSelectNumber parses the number as an int64 if it is an integer, or a float64.
+func parseSelectNumber(s string) (interface{}, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+// toSelectNumber converts the value to an int64 or a float64, or nil if it is not a number.
+func toSelectNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64, float64:
+		return v
+	case string:
+		if n, ok := parseSelectNumber(strings.TrimSpace(v)); ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// compareSelectValues compares the values of the same type, the strings are compared with the
+// numbers as the numbers, and the others are not comparable.
+func compareSelectValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb), true
+		}
+	}
+	if ba, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			if ba == bb {
+				return 0, true
+			}
+			if bb {
+				return -1, true
+			}
+			return 1, true
+		}
+		return 0, false
+	}
+	na, nb := toSelectNumber(a), toSelectNumber(b)
+	if na == nil || nb == nil {
+		return 0, false
+	}
+	ia, ok1 := na.(int64)
+	ib, ok2 := nb.(int64)
+	if ok1 && ok2 {
+		switch {
+		case ia < ib:
+			return -1, true
+		case ia > ib:
+			return 1, true
+		}
+		return 0, true
+	}
+	fa, fb := toSelectFloat(na), toSelectFloat(nb)
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	case fa == fb:
+		return 0, true
+	}
+	return 0, false
+}
+
+func toSelectFloat(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+// selectArithmetic applies the arithmetic operator to the numbers, which results in NULL for
+// the division by zero.
+func selectArithmetic(op string, a, b interface{}) interface{} {
+	na, nb := toSelectNumber(a), toSelectNumber(b)
+	if na == nil || nb == nil {
+		return nil
+	}
+	ia, ok1 := na.(int64)
+	ib, ok2 := nb.(int64)
+	if ok1 && ok2 {
+		switch op {
+		case "+":
+			return ia + ib
+		case "-":
+			return ia - ib
+		case "*":
+			return ia * ib
+		case "/":
+			if ib == 0 {
+				return nil
+			}
+			return ia / ib
+		case "%":
+			if ib == 0 {
+				return nil
+			}
+			return ia % ib
+		}
+	}
+	fa, fb := toSelectFloat(na), toSelectFloat(nb)
+	switch op {
+	case "+":
+		return fa + fb
+	case "-":
+		return fa - fb
+	case "*":
+		return fa * fb
+	case "/":
+		if fb == 0 {
+			return nil
+		}
+		return fa / fb
+	case "%":
+		if fb == 0 {
+			return nil
+		}
+		return math.Mod(fa, fb)
+	}
+	return nil
+}
+
+// formatSelectValue formats the value as the string of the CSV.
+func formatSelectValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
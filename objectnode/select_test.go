@@ -0,0 +1,206 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+const testSelectCSV = `name,age,city
+alice,30,Beijing
+bob,25,"Shang, hai"
+carol,41,Beijing
+dave,,Shenzhen
+`
+
+const testSelectJSON = `{"name": "alice", "age": 30, "tags": ["a", "b"], "address": {"city": "Beijing"}}
+{"name": "bob", "age": 25, "address": {"city": "Shanghai"}}
+[{"name": "carol", "age": 41.5}, {"name": "dave"}]
+`
+
+type testSelectEvent struct {
+	headers map[string]string
+	payload []byte
+}
+
+// decodeEventStream decodes the messages of the event stream and verifies their checksums.
+func decodeEventStream(t *testing.T, data []byte) (events []*testSelectEvent) {
+	for len(data) > 0 {
+		total := binary.BigEndian.Uint32(data[0:4])
+		headersLength := binary.BigEndian.Uint32(data[4:8])
+		if crc32.ChecksumIEEE(data[0:8]) != binary.BigEndian.Uint32(data[8:12]) {
+			t.Fatalf("prelude checksum mismatch")
+		}
+		if crc32.ChecksumIEEE(data[:total-4]) != binary.BigEndian.Uint32(data[total-4:total]) {
+			t.Fatalf("message checksum mismatch")
+		}
+		event := &testSelectEvent{headers: make(map[string]string)}
+		headers := data[12 : 12+headersLength]
+		for len(headers) > 0 {
+			nameLength := int(headers[0])
+			name := string(headers[1 : 1+nameLength])
+			valueLength := int(binary.BigEndian.Uint16(headers[2+nameLength : 4+nameLength]))
+			event.headers[name] = string(headers[4+nameLength : 4+nameLength+valueLength])
+			headers = headers[4+nameLength+valueLength:]
+		}
+		event.payload = data[12+headersLength : total-4]
+		events = append(events, event)
+		data = data[total:]
+	}
+	return
+}
+
+func runTestSelect(t *testing.T, req *SelectObjectContentRequest, object []byte) (records string, events []*testSelectEvent) {
+	if ec := req.Validate(); ec != nil {
+		t.Fatalf("validate request: %v", ec)
+	}
+	stmt, err := parseSelectStatement(req.Expression)
+	if err != nil {
+		t.Fatalf("parse expression(%v): %v", req.Expression, err)
+	}
+	var output bytes.Buffer
+	executor, err := newSelectExecutor(stmt, req, bytes.NewReader(object), &output)
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	ec, err := executor.run()
+	if ec != nil || err != nil {
+		t.Fatalf("run expression(%v): error(%v) err(%v)", req.Expression, ec, err)
+	}
+	events = decodeEventStream(t, output.Bytes())
+	for _, event := range events {
+		if event.headers[":event-type"] == "Records" {
+			records += string(event.payload)
+		}
+	}
+	if last := events[len(events)-1]; last.headers[":event-type"] != "End" {
+		t.Fatalf("last event(%v) is not End", last.headers)
+	}
+	return
+}
+
+func newTestSelectRequest(expression string, csvInput bool, csvOutput bool) *SelectObjectContentRequest {
+	req := &SelectObjectContentRequest{
+		Expression:          expression,
+		ExpressionType:      SelectExpressionTypeSQL,
+		InputSerialization:  &SelectInputSerialization{},
+		OutputSerialization: &SelectOutputSerialization{},
+	}
+	if csvInput {
+		req.InputSerialization.CSV = &SelectCSVInput{FileHeaderInfo: SelectFileHeaderUse}
+	} else {
+		req.InputSerialization.JSON = &SelectJSONInput{Type: SelectJSONTypeLines}
+	}
+	if csvOutput {
+		req.OutputSerialization.CSV = &SelectCSVOutput{}
+	} else {
+		req.OutputSerialization.JSON = &SelectJSONOutput{}
+	}
+	return req
+}
+
+func TestSelectObjectContent_CSV(t *testing.T) {
+	cases := []struct {
+		expression string
+		csvOutput  bool
+		records    string
+	}{
+		{"SELECT * FROM S3Object", true, "alice,30,Beijing\nbob,25,\"Shang, hai\"\ncarol,41,Beijing\ndave,,Shenzhen\n"},
+		{"SELECT s.name FROM S3Object s WHERE s.city = 'Beijing' AND CAST(s.age AS INT) > 35", true, "carol\n"},
+		{"SELECT _1, age + 1 AS next FROM S3Object WHERE age BETWEEN 25 AND 30 LIMIT 1", false, `{"_1":"alice","next":31}` + "\n"},
+		{"SELECT UPPER(name) FROM S3Object WHERE age IS NULL OR age = ''", true, "DAVE\n"},
+		{"SELECT name FROM S3Object WHERE city LIKE 'Shang%' OR name IN ('carol')", true, "bob\ncarol\n"},
+		{"SELECT COUNT(*), SUM(age), MAX(age), AVG(CAST(age AS INT)) FROM S3Object WHERE city <> 'Shenzhen'", true, "3,96,41,32\n"},
+		{"SELECT * FROM S3Object WHERE name = 'nobody'", true, ""},
+	}
+	for i, c := range cases {
+		records, _ := runTestSelect(t, newTestSelectRequest(c.expression, true, c.csvOutput), []byte(testSelectCSV))
+		if records != c.records {
+			t.Fatalf("case(%v): records(%q), expect(%q)", i, records, c.records)
+		}
+	}
+}
+
+func TestSelectObjectContent_JSON(t *testing.T) {
+	cases := []struct {
+		expression string
+		csvOutput  bool
+		records    string
+	}{
+		{"SELECT * FROM S3Object[*] s WHERE s.age > 40", false, `{"name":"carol","age":41.5}` + "\n"},
+		{"SELECT s.name, s.address.city FROM S3Object s WHERE s.tags[1] = 'b'", true, "alice,Beijing\n"},
+		{"SELECT name FROM S3Object WHERE age IS MISSING", true, "dave\n"},
+		{"SELECT MIN(age) AS youngest, COUNT(age) FROM S3Object", false, `{"youngest":25,"_2":3}` + "\n"},
+	}
+	for i, c := range cases {
+		records, _ := runTestSelect(t, newTestSelectRequest(c.expression, false, c.csvOutput), []byte(testSelectJSON))
+		if records != c.records {
+			t.Fatalf("case(%v): records(%q), expect(%q)", i, records, c.records)
+		}
+	}
+}
+
+func TestSelectObjectContent_GZIP(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, _ = writer.Write([]byte(testSelectCSV))
+	_ = writer.Close()
+
+	req := newTestSelectRequest("SELECT name FROM S3Object LIMIT 2", true, true)
+	req.InputSerialization.CompressionType = SelectCompressionGZIP
+	records, events := runTestSelect(t, req, compressed.Bytes())
+	if records != "alice\nbob\n" {
+		t.Fatalf("records(%q)", records)
+	}
+	stats := events[len(events)-2]
+	if stats.headers[":event-type"] != "Stats" || !strings.Contains(string(stats.payload), "<BytesReturned>10</BytesReturned>") {
+		t.Fatalf("stats(%v) payload(%s)", stats.headers, stats.payload)
+	}
+}
+
+func TestSelectObjectContent_ParsingError(t *testing.T) {
+	req := newTestSelectRequest("SELECT * FROM S3Object", false, false)
+	stmt, _ := parseSelectStatement(req.Expression)
+	executor, err := newSelectExecutor(stmt, req, strings.NewReader(`{"name": "alice"} 1`), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("new executor: %v", err)
+	}
+	if ec, err := executor.run(); ec != &JSONParsingError || err != nil {
+		t.Fatalf("error(%v) err(%v), expect JSONParsingError", ec, err)
+	}
+}
+
+func TestParseSelectStatement_Invalid(t *testing.T) {
+	expressions := []string{
+		"SELECT FROM S3Object",
+		"SELECT * FROM table",
+		"SELECT name, COUNT(*) FROM S3Object",
+		"SELECT * FROM S3Object WHERE COUNT(*) > 1",
+		"SELECT * FROM S3Object WHERE name = 'alice",
+		"SELECT * FROM S3Object LIMIT -1",
+		"SELECT UNKNOWN(name) FROM S3Object",
+		"SELECT * FROM S3Object WHERE name NOT = 'a'",
+	}
+	for _, expression := range expressions {
+		if _, err := parseSelectStatement(expression); err == nil {
+			t.Fatalf("expression(%v) parsed", expression)
+		}
+	}
+}
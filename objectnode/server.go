@@ -19,24 +19,44 @@ import (
 	"github.com/chubaofs/chubaofs/proto"
 	"net/http"
 	"regexp"
+	"time"
 
 	"github.com/chubaofs/chubaofs/cmd/common"
+	masterSDK "github.com/chubaofs/chubaofs/sdk/master"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/health"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
 	"github.com/gorilla/mux"
 )
 
 // Configuration keys
 const (
-	configListen    = "listen"
-	configDomains   = "domains"
-	configMasters   = "masters"
-	configAuthnodes = "authNodes"
-	configRegion    = "region"
+	configListen         = "listen"
+	configDomains        = "domains"
+	configWebsiteDomains = "websiteDomains"
+	configMasters        = "masters"
+	configAuthnodes      = "authNodes"
+	configRegion         = "region"
+
+	// the objectnode key in the keystore of the authnodes, to get the secret keys of the access keys
+	configAuthKey           = "authKey"
+	configAccessKeyCacheTTL = "accessKeyCacheTTL"
+
+	configLifecycleInterval = "lifecycleInterval"
+	configAccessLogInterval = "accessLogInterval"
+
+	// the targets of the bucket notifications by the IDs, e.g. {"1": "kafka://broker:9092/topic"}
+	configNotificationTargets = "notificationTargets"
 )
 
 // Default of configuration value
+const (
+	ModuleName = "objectnode"
+)
+
 const (
 	defaultListen = ":80"
 	defaultRegion = "cfs_default"
@@ -53,6 +73,22 @@ type ObjectNode struct {
 	region     string
 	httpServer *http.Server
 	vm         VolumeManager
+	mc         *masterSDK.MasterClient
+	limiter    *ratelimit.Limiter
+	akStore    *accessKeyStore
+	stopC      chan struct{}
+
+	lifecycleInterval time.Duration
+
+	// the access logs of the buckets are delivered to the target buckets in the interval
+	accessLogInterval time.Duration
+	accessLogger      *accessLogger
+
+	// the events of the objects are published to the targets by the notifications of the buckets
+	notifier *eventNotifier
+
+	// the buckets are served as the static websites by the subdomains of the website domains
+	websiteDomains []string
 
 	control common.Control
 }
@@ -91,6 +127,9 @@ func (o *ObjectNode) parseConfig(cfg *config.Config) (err error) {
 	if o.wildcards, err = NewWildcards(domains); err != nil {
 		return
 	}
+	for _, domain := range cfg.GetArray(configWebsiteDomains) {
+		o.websiteDomains = append(o.websiteDomains, domain.(string))
+	}
 
 	// parse master config
 	masterCfgs := cfg.GetArray(proto.MasterAddr)
@@ -99,14 +138,40 @@ func (o *ObjectNode) parseConfig(cfg *config.Config) (err error) {
 		masters[i] = masterCfg.(string)
 	}
 	o.vm = NewVolumeManager(masters)
+	o.mc = masterSDK.NewMasterClient(masters, false)
 	o.vm.InitStore(new(xattrStore))
 
+	// parse authnode config, the access keys in the keystore of the authnodes are accepted besides
+	// the ones of the volumes if configured
+	if o.akStore, err = newAccessKeyStore(cfg); err != nil {
+		return
+	}
+
 	// parse region
 	region := cfg.GetString(configRegion)
 	if len(region) == 0 {
 		region = defaultRegion
 	}
 	o.region = region
+
+	// parse lifecycle interval, the lifecycle rules are not executed if it is negative
+	o.lifecycleInterval = defaultLifecycleInterval
+	if interval := cfg.GetInt(configLifecycleInterval); interval != 0 {
+		o.lifecycleInterval = time.Duration(interval) * time.Second
+	}
+
+	// parse access log interval, the access logs are not recorded if it is negative
+	o.accessLogInterval = defaultAccessLogInterval
+	if interval := cfg.GetInt(configAccessLogInterval); interval != 0 {
+		o.accessLogInterval = time.Duration(interval) * time.Second
+	}
+
+	// parse notification targets
+	if targets := cfg.GetMap(configNotificationTargets); len(targets) > 0 {
+		if o.notifier, err = newEventNotifier(o.region, targets); err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -119,6 +184,17 @@ func handleStart(s common.Server, cfg *config.Config) (err error) {
 	if err = o.parseConfig(cfg); err != nil {
 		return
 	}
+	exporter.Init(ModuleName, cfg)
+	o.limiter = ratelimit.NewLimiter(ratelimit.ModuleObjectNode)
+	o.stopC = make(chan struct{})
+	go o.refreshRateLimits()
+	if o.lifecycleInterval > 0 {
+		go o.runLifecycle()
+	}
+	if o.accessLogInterval > 0 {
+		o.accessLogger = newAccessLogger(o.deliverAccessLogs)
+		go o.runAccessLogger()
+	}
 	// start rest api
 	if err = o.startMuxRestAPI(); err != nil {
 		log.LogInfof("handleStart: start mux rest api fail, err(%v)", err)
@@ -134,6 +210,14 @@ func handleShutdown(s common.Server) {
 		return
 	}
 	o.shutdownRestAPI()
+	close(o.stopC)
+	// the access logs of the served requests are delivered before the object node exits
+	if o.accessLogger != nil {
+		o.accessLogger.flush(time.Now())
+	}
+	if o.notifier != nil {
+		o.notifier.close()
+	}
 }
 
 func (o *ObjectNode) startMuxRestAPI() (err error) {
@@ -141,10 +225,16 @@ func (o *ObjectNode) startMuxRestAPI() (err error) {
 	o.registerApiRouters(router)
 	router.Use(
 		o.traceMiddleware,
+		o.metricsMiddleware,
+		o.accessLogMiddleware,
+		o.rateLimitMiddleware,
+		o.corsMiddleware,
 		o.authMiddleware,
 		o.contentMiddleware,
 	)
 
+	health.AddCheck("state", o.checkState)
+
 	var server = &http.Server{
 		Addr:    o.listen,
 		Handler: router,
@@ -170,3 +260,10 @@ func (o *ObjectNode) shutdownRestAPI() {
 func NewServer() *ObjectNode {
 	return &ObjectNode{}
 }
+
+func (o *ObjectNode) checkState() error {
+	if !o.control.IsRunning() {
+		return errors.New("not running")
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxObjectTags        = 10
+	MaxTagKeyLength      = 128
+	MaxTagValueLength    = 256
+	ObjectTaggingMaxSize = 1 << 16
+
+	TaggingDirectiveCopy    = "COPY"
+	TaggingDirectiveReplace = "REPLACE"
+)
+
+// ParseTagging parses the tags encoded as the URL query parameters, which is the format of the
+// header 'x-amz-tagging' and of the xattr of the objects.
+func ParseTagging(raw string) (*Tagging, error) {
+	var tagging = &Tagging{TagSet: make([]*Tag, 0)}
+	if raw == "" {
+		return tagging, nil
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, err
+	}
+	for key, vals := range values {
+		for _, val := range vals {
+			tagging.TagSet = append(tagging.TagSet, &Tag{Key: key, Value: val})
+		}
+	}
+	return tagging, nil
+}
+
+// Encode encodes the tags as the URL query parameters sorted by the keys.
+func (t *Tagging) Encode() string {
+	var values = url.Values{}
+	for _, tag := range t.TagSet {
+		values.Add(tag.Key, tag.Value)
+	}
+	return values.Encode()
+}
+
+// Validate checks the tags by the limits of S3, which returns the error code if any tag is invalid.
+func (t *Tagging) Validate() *ErrorCode {
+	if len(t.TagSet) > MaxObjectTags {
+		return &InvalidTag
+	}
+	var keys = make(map[string]bool, len(t.TagSet))
+	for _, tag := range t.TagSet {
+		if tag.Key == "" || utf8.RuneCountInString(tag.Key) > MaxTagKeyLength ||
+			utf8.RuneCountInString(tag.Value) > MaxTagValueLength || strings.HasPrefix(tag.Key, "aws:") {
+			return &InvalidTag
+		}
+		if keys[tag.Key] {
+			return &InvalidTag
+		}
+		keys[tag.Key] = true
+	}
+	return nil
+}
+
+// parseTaggingHeader returns the tags in the header 'x-amz-tagging', nil if there is none.
+func parseTaggingHeader(raw string) (tagging *Tagging, ec *ErrorCode) {
+	if raw == "" {
+		return nil, nil
+	}
+	var err error
+	if tagging, err = ParseTagging(raw); err != nil {
+		return nil, &InvalidTag
+	}
+	if ec = tagging.Validate(); ec != nil {
+		return nil, ec
+	}
+	return tagging, nil
+}
+
+// TagFilter filters the objects listed by a tag, which matches the objects with the key, and
+// with the value too unless the value is absent.
+type TagFilter struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ParseTagFilter parses the filter in the format 'key' or 'key=value', nil if it is empty.
+func ParseTagFilter(raw string) *TagFilter {
+	if raw == "" {
+		return nil
+	}
+	if index := strings.Index(raw, "="); index >= 0 {
+		return &TagFilter{Key: raw[:index], Value: raw[index+1:], HasValue: true}
+	}
+	return &TagFilter{Key: raw}
+}
+
+// Match returns whether the tags encoded in the xattr of the object match the filter.
+func (f *TagFilter) Match(rawTagging string) bool {
+	values, err := url.ParseQuery(rawTagging)
+	if err != nil {
+		return false
+	}
+	vals, found := values[f.Key]
+	if !found {
+		return false
+	}
+	if !f.HasValue {
+		return true
+	}
+	for _, val := range vals {
+		if val == f.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func filterFilesByTag(infos []*FSFileInfo, filter *TagFilter) []*FSFileInfo {
+	var matches = make([]*FSFileInfo, 0, len(infos))
+	for _, info := range infos {
+		if filter.Match(info.Tagging) {
+			matches = append(matches, info)
+		}
+	}
+	return matches
+}
+
+func tagCount(rawTagging string) int {
+	if rawTagging == "" {
+		return 0
+	}
+	tagging, err := ParseTagging(rawTagging)
+	if err != nil {
+		return 0
+	}
+	return len(tagging.TagSet)
+}
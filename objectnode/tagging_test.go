@@ -0,0 +1,89 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTaggingEncode(t *testing.T) {
+	tagging := &Tagging{TagSet: []*Tag{{Key: "project", Value: "chubao fs"}, {Key: "env", Value: "test&dev"}}}
+	encoded := tagging.Encode()
+	if encoded != "env=test%26dev&project=chubao+fs" {
+		t.Fatalf("encoded tagging mismatch: %v", encoded)
+	}
+	parsed, err := ParseTagging(encoded)
+	if err != nil || len(parsed.TagSet) != 2 || parsed.Encode() != encoded {
+		t.Fatalf("parse tagging: %v %v", parsed, err)
+	}
+	if parsed, err = ParseTagging(""); err != nil || len(parsed.TagSet) != 0 {
+		t.Fatalf("parse empty tagging: %v %v", parsed, err)
+	}
+	if count := tagCount(encoded); count != 2 {
+		t.Fatalf("tag count mismatch: %v", count)
+	}
+}
+
+func TestTaggingValidate(t *testing.T) {
+	var valid = &Tagging{TagSet: []*Tag{{Key: "key", Value: ""}}}
+	if ec := valid.Validate(); ec != nil {
+		t.Fatalf("validate valid tagging: %v", ec)
+	}
+	var tooMany = &Tagging{}
+	for i := 0; i <= MaxObjectTags; i++ {
+		tooMany.TagSet = append(tooMany.TagSet, &Tag{Key: fmt.Sprintf("key%d", i)})
+	}
+	var invalids = []*Tagging{
+		tooMany,
+		{TagSet: []*Tag{{Key: ""}}},
+		{TagSet: []*Tag{{Key: "aws:name"}}},
+		{TagSet: []*Tag{{Key: "key"}, {Key: "key"}}},
+		{TagSet: []*Tag{{Key: strings.Repeat("k", MaxTagKeyLength+1)}}},
+		{TagSet: []*Tag{{Key: "key", Value: strings.Repeat("v", MaxTagValueLength+1)}}},
+	}
+	for i, tagging := range invalids {
+		if ec := tagging.Validate(); ec == nil || ec.ErrorCode != InvalidTag.ErrorCode {
+			t.Fatalf("validate invalid tagging %v: %v", i, ec)
+		}
+	}
+	if _, ec := parseTaggingHeader("key=a&key=b"); ec == nil {
+		t.Fatalf("parse header with duplicated keys")
+	}
+	if tagging, ec := parseTaggingHeader(""); tagging != nil || ec != nil {
+		t.Fatalf("parse empty header: %v %v", tagging, ec)
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	infos := []*FSFileInfo{
+		{Path: "a", Tagging: "env=test&project=cfs"},
+		{Path: "b", Tagging: "env=prod"},
+		{Path: "c"},
+	}
+	for raw, expect := range map[string]string{"env": "ab", "env=prod": "b", "project=": "", "owner": ""} {
+		var paths string
+		for _, info := range filterFilesByTag(infos, ParseTagFilter(raw)) {
+			paths += info.Path
+		}
+		if paths != expect {
+			t.Fatalf("filter %q: matched(%v) expect(%v)", raw, paths, expect)
+		}
+	}
+	if ParseTagFilter("") != nil {
+		t.Fatalf("empty filter parsed")
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"net"
 	"net/http"
+	"syscall"
 	"time"
 
 	"github.com/chubaofs/chubaofs/util"
@@ -64,10 +65,20 @@ func parseTimeRFC1123(timeStr string) (time.Time, error) {
 	return t, err
 }
 
+// transferError transfers the error of the volume to the error of the key in the result.
 func transferError(key string, err error) Error {
-	// TODO: complete sys error transfer
+	var ec = &InternalError
+	switch err {
+	case syscall.ENOENT:
+		ec = &NoSuchKey
+	case syscall.EACCES, syscall.EPERM:
+		ec = &AccessDenied
+	case syscall.EDQUOT:
+		ec = &QuotaExceeded
+	}
 	ossError := Error{
 		Key:     key,
+		Code:    ec.ErrorCode,
 		Message: err.Error(),
 	}
 	return ossError
@@ -89,6 +100,14 @@ func getRequestIP(r *http.Request) string {
 	return IPAddress
 }
 
+// get request scheme, which is https if the request is by TLS or forwarded from HTTPS by a proxy
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || strings.EqualFold(r.Header.Get(HeaderNameForwardedProto), "https") {
+		return "https"
+	}
+	return "http"
+}
+
 // check ipnet contains ip
 // ip: 172.17.0.2
 // ipnet: 172.17.0.0/16
@@ -114,6 +133,31 @@ func isIPNetContainsIP(ipStr, ipnetStr string) (bool, error) {
 	return false, nil
 }
 
+// wildcardMatch matches the value with the pattern, in which '*' matches any sequence of characters
+// and '?' matches any single character.
+func wildcardMatch(pattern, value string) bool {
+	p, v := 0, 0
+	star, mark := -1, 0
+	for v < len(value) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]) {
+			p++
+			v++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			star, mark = p, v
+			p++
+		} else if star >= 0 {
+			mark++
+			p, v = star+1, mark
+		} else {
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
 func patternMatch(pattern, key string) bool {
 	if pattern == "" {
 		return key == pattern
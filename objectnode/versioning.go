@@ -0,0 +1,550 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/Versioning.html
+
+const (
+	VersioningStatusEnabled   = "Enabled"
+	VersioningStatusSuspended = "Suspended"
+
+	// versionsDirName is the directory under the root of the volume which keeps the non-current
+	// versions and the delete markers of the objects. The versions of an object are kept in the
+	// directory at the path of the object under it, and named by their version IDs.
+	versionsDirName = ".cfs_versions"
+)
+
+type VersioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
+}
+
+// VersionEntry is either a Version or a DeleteMarker element of the ListVersionsResult, which are
+// listed together in the order of the keys and the versions.
+type VersionEntry struct {
+	XMLName      xml.Name
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	ETag         string       `xml:"ETag,omitempty"`
+	Size         *int64       `xml:"Size,omitempty"`
+	StorageClass string       `xml:"StorageClass,omitempty"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type ListVersionsResult struct {
+	XMLName             xml.Name        `xml:"ListVersionsResult"`
+	Bucket              string          `xml:"Name"`
+	Prefix              string          `xml:"Prefix"`
+	KeyMarker           string          `xml:"KeyMarker"`
+	VersionIdMarker     string          `xml:"VersionIdMarker"`
+	NextKeyMarker       string          `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string          `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int             `xml:"MaxKeys"`
+	Delimiter           string          `xml:"Delimiter,omitempty"`
+	IsTruncated         bool            `xml:"IsTruncated"`
+	Versions            []*VersionEntry `xml:",any"`
+	CommonPrefixes      []*CommonPrefix `xml:"CommonPrefixes"`
+}
+
+func NewVersionEntries(versions []*FSVersion, owner *BucketOwner) []*VersionEntry {
+	entries := make([]*VersionEntry, 0, len(versions))
+	for _, version := range versions {
+		entry := &VersionEntry{
+			XMLName:      xml.Name{Local: "Version"},
+			Key:          version.Path,
+			VersionId:    version.VersionID,
+			IsLatest:     version.IsLatest,
+			LastModified: formatTimeISO(version.ModifyTime),
+			Owner:        owner,
+		}
+		if version.DeleteMarker {
+			entry.XMLName.Local = "DeleteMarker"
+		} else {
+			size := version.Size
+			entry.ETag = version.ETag
+			entry.Size = &size
+			entry.StorageClass = StorageClassStandard
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// The version ID of an object is the inode of it, which is kept when the object becomes a
+// non-current version, and never reused by the volume.
+func versionID(inode uint64) string {
+	return strconv.FormatUint(inode, 10)
+}
+
+func (v *volume) loadVersioning() (status string) {
+	v.om.versioningLock.RLock()
+	status = v.om.versioning
+	v.om.versioningLock.RUnlock()
+	return
+}
+
+func (v *volume) storeVersioning(status string) {
+	v.om.versioningLock.Lock()
+	v.om.versioning = status
+	v.om.versioningLock.Unlock()
+	return
+}
+
+// versioningEnabled returns if the objects overwritten or deleted are kept as the non-current
+// versions. The versions kept before the versioning is suspended are still accessible.
+func (v *volume) versioningEnabled() bool {
+	return v.loadVersioning() == VersioningStatusEnabled
+}
+
+func (v *volume) loadBucketVersioning() (status string, err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSVersioning); err != nil {
+		log.LogErrorf("loadBucketVersioning: load bucket versioning fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	status = string(data)
+	return
+}
+
+// SetVersioning stores the versioning status of the volume, which is loaded by the other object
+// nodes in OSSMetaUpdateDuration.
+func (v *volume) SetVersioning(status string) (err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	if err = store.Put(v.name, bucketRootPath, XAttrKeyOSSVersioning, []byte(status)); err != nil {
+		return
+	}
+	v.storeVersioning(status)
+	return
+}
+
+func (v *volume) versionsDir(dirs []string, filename string, autoCreate bool) (uint64, error) {
+	var path = make([]string, 0, len(dirs)+2)
+	path = append(path, versionsDirName)
+	path = append(path, dirs...)
+	path = append(path, filename)
+	return v.lookupDirectoriesFrom(rootIno, path, autoCreate)
+}
+
+// archiveVersion keeps the inode of an object as a non-current version by linking it into the
+// versions directory of the object, so that it is not released when the object is overwritten or
+// deleted.
+func (v *volume) archiveVersion(dirs []string, filename string, inode uint64) (err error) {
+	const mode = 0600
+	var dirIno uint64
+	if dirIno, err = v.versionsDir(dirs, filename, true); err != nil {
+		return
+	}
+	var name = versionID(inode)
+	// the inode has been kept, e.g. by the copies of an object
+	if _, _, err = v.mw.Lookup_ll(dirIno, name); err == nil {
+		return
+	}
+	if err != syscall.ENOENT {
+		return
+	}
+	if _, err = v.mw.InodeLink_ll(inode); err != nil {
+		return
+	}
+	if err = v.mw.DentryCreate_ll(dirIno, name, inode, mode); err != nil {
+		if _, unlinkErr := v.mw.InodeUnlink_ll(inode); unlinkErr != nil {
+			log.LogErrorf("archiveVersion: meta rollback inode link fail: inode(%v) err(%v)", inode, unlinkErr)
+		}
+		if err == syscall.EEXIST {
+			err = nil
+		}
+		return
+	}
+	log.LogDebugf("archiveVersion: keep version: volume(%v) dirs(%v) name(%v) inode(%v)", v.name, dirs, filename, inode)
+	return
+}
+
+// createDeleteMarker creates an empty inode marked as the delete marker in the versions directory
+// of the object, and returns its version ID.
+func (v *volume) createDeleteMarker(dirs []string, filename string) (marker string, err error) {
+	const mode = 0600
+	var dirIno uint64
+	if dirIno, err = v.versionsDir(dirs, filename, true); err != nil {
+		return
+	}
+	var info *proto.InodeInfo
+	if info, err = v.mw.InodeCreate_ll(mode, 0, 0, nil); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			// release the inode of the delete marker
+			if _, unlinkErr := v.mw.InodeUnlink_ll(info.Inode); unlinkErr == nil {
+				_ = v.mw.Evict(info.Inode)
+			}
+			marker = ""
+		}
+	}()
+	if err = v.mw.XAttrSet_ll(info.Inode, []byte(XAttrKeyOSSDeleteMarker), []byte("true")); err != nil {
+		return
+	}
+	marker = versionID(info.Inode)
+	if err = v.mw.DentryCreate_ll(dirIno, marker, info.Inode, mode); err != nil {
+		return
+	}
+	log.LogDebugf("createDeleteMarker: create delete marker: volume(%v) dirs(%v) name(%v) marker(%v)",
+		v.name, dirs, filename, marker)
+	return
+}
+
+// FileVersion returns a version of the file, which is either the current one or a non-current one
+// kept in the versions directory. Only the current version is marked as the latest.
+func (v *volume) FileVersion(path, id string) (version *FSVersion, err error) {
+	dirs, filename := splitPath(path)
+
+	var parentId, inode, dirIno uint64
+	var mode uint32
+	if parentId, err = v.lookupDirectories(dirs, false); err == nil {
+		inode, mode, err = v.mw.Lookup_ll(parentId, filename)
+		if err == nil && !os.FileMode(mode).IsDir() && versionID(inode) == id {
+			return v.versionInfo(path, inode, true)
+		}
+	}
+
+	if dirIno, err = v.versionsDir(dirs, filename, false); err != nil {
+		return
+	}
+	if inode, mode, err = v.mw.Lookup_ll(dirIno, id); err != nil {
+		return
+	}
+	if os.FileMode(mode).IsDir() {
+		return nil, syscall.ENOENT
+	}
+	return v.versionInfo(path, inode, false)
+}
+
+func (v *volume) versionInfo(path string, inode uint64, current bool) (version *FSVersion, err error) {
+	var inodeInfo *proto.InodeInfo
+	var xAttrInfo *proto.XAttrInfo
+	if inodeInfo, xAttrInfo, err = v.mw.InodeGetWithXAttrs_ll(inode, []string{XAttrKeyOSSETag, XAttrKeyOSSDeleteMarker,
+		XAttrKeyOSSSSE, XAttrKeyOSSTagging, XAttrKeyOSSRetention, XAttrKeyOSSLegalHold}); err != nil {
+		log.LogErrorf("versionInfo: meta get inode and xattr fail, inode(%v) path(%v) err(%v)", inode, path, err)
+		return
+	}
+	version = &FSVersion{
+		FSFileInfo: FSFileInfo{
+			Path:       path,
+			Size:       int64(inodeInfo.Size),
+			Mode:       os.FileMode(inodeInfo.Mode),
+			ModifyTime: inodeInfo.ModifyTime,
+			ETag:       xAttrInfo.XAttrs[XAttrKeyOSSETag],
+			Inode:      inode,
+			SSE:        sseAlgorithm(xAttrInfo.XAttrs[XAttrKeyOSSSSE]),
+			Tagging:    xAttrInfo.XAttrs[XAttrKeyOSSTagging],
+			Retention:  xAttrInfo.XAttrs[XAttrKeyOSSRetention],
+			LegalHold:  xAttrInfo.XAttrs[XAttrKeyOSSLegalHold],
+		},
+		VersionID:    versionID(inode),
+		IsLatest:     current,
+		DeleteMarker: xAttrInfo.XAttrs[XAttrKeyOSSDeleteMarker] != "",
+	}
+	return
+}
+
+// ReadFileVersion reads the data of a version returned by FileVersion.
+func (v *volume) ReadFileVersion(version *FSVersion, writer io.Writer, offset, size uint64) error {
+	return v.readInode(version.Inode, writer, offset, size)
+}
+
+// ReadFileVersionRanges reads the ranges of a version returned by FileVersion, as ReadFileRanges.
+func (v *volume) ReadFileVersionRanges(version *FSVersion, ranges []FileRange, next func(i int) (io.Writer, error)) error {
+	return v.readInodeRanges(version.Inode, ranges, next)
+}
+
+// DeleteFileVersion removes a version of the file permanently. If the current version is removed,
+// the latest non-current version becomes the current one, unless it is a delete marker.
+func (v *volume) DeleteFileVersion(path, id string) (version *FSVersion, err error) {
+	dirs, filename := splitPath(path)
+
+	if version, err = v.FileVersion(path, id); err != nil {
+		return
+	}
+
+	var parentId, dirIno uint64
+	if version.IsLatest {
+		if parentId, err = v.lookupDirectories(dirs, false); err != nil {
+			return
+		}
+		if _, err = v.mw.Delete_ll(parentId, filename, false); err != nil {
+			return
+		}
+	} else {
+		if dirIno, err = v.versionsDir(dirs, filename, false); err != nil {
+			return
+		}
+		if _, err = v.mw.Delete_ll(dirIno, id, false); err != nil {
+			return
+		}
+	}
+
+	// evict inode
+	if err = v.ec.EvictStream(version.Inode); err != nil {
+		return
+	}
+	if err = v.mw.Evict(version.Inode); err != nil {
+		return
+	}
+
+	if version.IsLatest {
+		err = v.restoreLatestVersion(path, parentId)
+	}
+	return
+}
+
+// restoreLatestVersion makes the latest non-current version of the file the current one, unless it
+// is a delete marker.
+func (v *volume) restoreLatestVersion(path string, parentId uint64) (err error) {
+	dirs, filename := splitPath(path)
+
+	var dirIno uint64
+	if dirIno, err = v.versionsDir(dirs, filename, false); err == syscall.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	var children []proto.Dentry
+	if children, err = v.mw.ReadDir_ll(dirIno); err != nil {
+		return
+	}
+	var versions = make([]*FSVersion, 0, len(children))
+	for _, child := range children {
+		if os.FileMode(child.Type).IsDir() {
+			continue
+		}
+		versions = append(versions, &FSVersion{
+			FSFileInfo: FSFileInfo{Path: path, Inode: child.Inode},
+			VersionID:  child.Name,
+		})
+	}
+	if len(versions) == 0 {
+		return
+	}
+	if err = v.supplyVersions(versions); err != nil {
+		return
+	}
+	sortVersions(versions)
+	var latest = versions[0]
+	if latest.DeleteMarker {
+		return
+	}
+	// the file has been written again
+	if _, _, err = v.mw.Lookup_ll(parentId, filename); err == nil {
+		return
+	}
+	if err = v.mw.Rename_ll(dirIno, latest.VersionID, parentId, filename); err != nil {
+		return
+	}
+	log.LogDebugf("restoreLatestVersion: restore version: volume(%v) path(%v) version(%v)", v.name, path, latest.VersionID)
+	return
+}
+
+// ListVersions lists the versions of the files with the prefix in the order of the keys, and the
+// versions of a key from the latest one. Both the files and the versions directory are walked, so
+// that the cost is in proportion to the files and the versions under the prefix.
+func (v *volume) ListVersions(prefix, delimiter, keyMarker, versionIDMarker string, maxKeys uint64) (
+	versions []*FSVersion, prefixes Prefixes, nextKeyMarker, nextVersionIDMarker string, isTruncated bool, err error) {
+
+	var all []*FSVersion
+	if all, err = v.walkVersions(rootIno, "", prefix, true, all); err != nil {
+		return
+	}
+	var versionsIno uint64
+	versionsIno, _, err = v.mw.Lookup_ll(rootIno, versionsDirName)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == nil {
+		if all, err = v.walkVersions(versionsIno, "", prefix, false, all); err != nil {
+			return
+		}
+	}
+	if err = v.supplyVersions(all); err != nil {
+		return
+	}
+	sortVersions(all)
+
+	var prefixMap = PrefixMap(make(map[string]struct{}))
+	var count uint64
+	var skipping = versionIDMarker != ""
+	for _, version := range all {
+		if version.Path < keyMarker {
+			continue
+		}
+		if version.Path == keyMarker {
+			if skipping && version.VersionID == versionIDMarker {
+				skipping = false
+				continue
+			}
+			if versionIDMarker == "" || skipping {
+				continue
+			}
+		}
+		var commonPrefix string
+		if delimiter != "" {
+			if idx := strings.Index(version.Path[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix = version.Path[:len(prefix)+idx+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" {
+			if _, exist := prefixMap[commonPrefix]; exist || commonPrefix == keyMarker {
+				continue
+			}
+		}
+		if count >= maxKeys {
+			isTruncated = true
+			break
+		}
+		count++
+		if commonPrefix != "" {
+			prefixMap.AddPrefix(commonPrefix)
+			nextKeyMarker, nextVersionIDMarker = commonPrefix, ""
+			continue
+		}
+		versions = append(versions, version)
+		nextKeyMarker, nextVersionIDMarker = version.Path, version.VersionID
+	}
+	if !isTruncated {
+		nextKeyMarker, nextVersionIDMarker = "", ""
+	}
+	prefixes = prefixMap.Prefixes()
+	return
+}
+
+// walkVersions collects the current versions of the files under the directory if current is set,
+// otherwise the non-current versions in the versions directory, whose subdirectories are both the
+// versions directories of the files and the parents of the deeper ones.
+func (v *volume) walkVersions(dirIno uint64, dir, prefix string, current bool, versions []*FSVersion) ([]*FSVersion, error) {
+	children, err := v.mw.ReadDir_ll(dirIno)
+	if err != nil {
+		return versions, err
+	}
+	for _, child := range children {
+		if current && dirIno == rootIno && child.Name == versionsDirName {
+			continue
+		}
+		if os.FileMode(child.Type).IsDir() {
+			path := dir + child.Name
+			if !strings.HasPrefix(path, prefix) && !strings.HasPrefix(prefix, path+"/") {
+				continue
+			}
+			if versions, err = v.walkVersions(child.Inode, path+"/", prefix, current, versions); err != nil {
+				return versions, err
+			}
+			continue
+		}
+		var path, id = dir + child.Name, versionID(child.Inode)
+		if !current {
+			if dir == "" {
+				continue
+			}
+			path, id = strings.TrimSuffix(dir, "/"), child.Name
+		}
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		versions = append(versions, &FSVersion{
+			FSFileInfo: FSFileInfo{Path: path, Inode: child.Inode},
+			VersionID:  id,
+			IsLatest:   current,
+		})
+	}
+	return versions, nil
+}
+
+func (v *volume) supplyVersions(versions []*FSVersion) (err error) {
+	if len(versions) == 0 {
+		return
+	}
+	var inodes = make([]uint64, 0, len(versions))
+	for _, version := range versions {
+		inodes = append(inodes, version.Inode)
+	}
+
+	var inoInfoMap = make(map[uint64]*proto.InodeInfo)
+	for _, inodeInfo := range v.mw.BatchInodeGet(inodes) {
+		inoInfoMap[inodeInfo.Inode] = inodeInfo
+	}
+	var xAttrInfos []*proto.XAttrInfo
+	if xAttrInfos, err = v.mw.BatchGetXAttr(inodes, []string{XAttrKeyOSSETag, XAttrKeyOSSDeleteMarker}); err != nil {
+		log.LogErrorf("supplyVersions: batch get xattr fail, volume(%v) err(%v)", v.name, err)
+		return
+	}
+	var xAttrMap = make(map[uint64]map[string]string)
+	for _, xAttrInfo := range xAttrInfos {
+		xAttrMap[xAttrInfo.Inode] = xAttrInfo.XAttrs
+	}
+
+	for _, version := range versions {
+		if inoInfo := inoInfoMap[version.Inode]; inoInfo != nil {
+			version.Size = int64(inoInfo.Size)
+			version.ModifyTime = inoInfo.ModifyTime
+			version.Mode = os.FileMode(inoInfo.Mode)
+		}
+		if xAttrs := xAttrMap[version.Inode]; xAttrs != nil {
+			version.ETag = xAttrs[XAttrKeyOSSETag]
+			version.DeleteMarker = xAttrs[XAttrKeyOSSDeleteMarker] != ""
+		}
+	}
+	return
+}
+
+// sortVersions sorts the versions by the keys, and the versions of a key from the current one and
+// the latest non-current one, and then marks the first version of each key as the latest.
+func sortVersions(versions []*FSVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, vj := versions[i], versions[j]
+		if vi.Path != vj.Path {
+			return vi.Path < vj.Path
+		}
+		if vi.IsLatest != vj.IsLatest {
+			return vi.IsLatest
+		}
+		if !vi.ModifyTime.Equal(vj.ModifyTime) {
+			return vi.ModifyTime.After(vj.ModifyTime)
+		}
+		return vi.Inode > vj.Inode
+	})
+	for i, version := range versions {
+		version.IsLatest = i == 0 || versions[i-1].Path != version.Path
+	}
+}
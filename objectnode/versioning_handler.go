@@ -0,0 +1,198 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	BucketVersioningLimitSize = 1 << 10
+)
+
+// Get bucket versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
+func (o *ObjectNode) getBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketVersioningHandler: get bucket versioning: requestID(%v)", RequestIDFromRequest(r))
+	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketVersioningHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(&VersioningConfiguration{Status: vl.loadVersioning()}); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put bucket versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+func (o *ObjectNode) putBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketVersioningHandler: put bucket versioning: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketVersioningHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketVersioningLimitSize)); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	var config = &VersioningConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		log.LogWarnf("putBucketVersioningHandler: unmarshal versioning fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if config.Status != VersioningStatusEnabled && config.Status != VersioningStatusSuspended {
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if config.MfaDelete == VersioningStatusEnabled {
+		_ = NotImplemented.ServeResponse(w, r)
+		return
+	}
+	// the versioning of the bucket with the object lock enabled can not be suspended
+	if config.Status == VersioningStatusSuspended && vl.loadObjectLock() != nil {
+		_ = InvalidBucketState.ServeResponse(w, r)
+		return
+	}
+
+	if err = vl.SetVersioning(config.Status); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: set versioning fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketVersioningHandler: bucket versioning set: requestID(%v) bucket(%v) status(%v)",
+		RequestIDFromRequest(r), bucket, config.Status)
+	return
+}
+
+// List object versions
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+func (o *ObjectNode) listObjectVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("listObjectVersionsHandler: list object versions: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("listObjectVersionsHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	// get options
+	prefix := r.URL.Query().Get(ParamPrefix)
+	delimiter := r.URL.Query().Get(ParamPartDelimiter)
+	keyMarker := r.URL.Query().Get(ParamKeyMarker)
+	versionIDMarker := r.URL.Query().Get(ParamVersionIdMarker)
+	maxKeys := r.URL.Query().Get(ParamMaxKeys)
+
+	var maxKeysInt = uint64(MaxKeys)
+	if maxKeys != "" {
+		if maxKeysInt, err = strconv.ParseUint(maxKeys, 10, 16); err != nil {
+			log.LogErrorf("listObjectVersionsHandler: parse max keys fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+			_ = InvalidArgument.ServeResponse(w, r)
+			return
+		}
+		if maxKeysInt > MaxKeys {
+			maxKeysInt = MaxKeys
+		}
+	}
+	if versionIDMarker != "" && keyMarker == "" {
+		_ = InvalidArgument.ServeResponse(w, r)
+		return
+	}
+
+	versions, prefixes, nextKeyMarker, nextVersionIDMarker, isTruncated, err := vl.ListVersions(prefix, delimiter, keyMarker, versionIDMarker, maxKeysInt)
+	if err != nil {
+		log.LogErrorf("listObjectVersionsHandler: list versions fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+
+	accessKey, _ := vl.OSSSecure()
+	var commonPrefixes = make([]*CommonPrefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		commonPrefixes = append(commonPrefixes, &CommonPrefix{Prefix: prefix})
+	}
+	result := &ListVersionsResult{
+		Bucket:              bucket,
+		Prefix:              prefix,
+		KeyMarker:           keyMarker,
+		VersionIdMarker:     versionIDMarker,
+		NextKeyMarker:       nextKeyMarker,
+		NextVersionIdMarker: nextVersionIDMarker,
+		MaxKeys:             int(maxKeysInt),
+		Delimiter:           delimiter,
+		IsTruncated:         isTruncated,
+		Versions:            NewVersionEntries(versions, NewBucketOwner(accessKey)),
+		CommonPrefixes:      commonPrefixes,
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(result); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	w.Header().Set(HeaderNameContentLength, strconv.Itoa(len(marshaled)))
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// getObjectVersion returns the version of the object specified by the version ID in the request, and
+// serves the error response if the version does not exist or it is a delete marker.
+func (o *ObjectNode) getObjectVersion(w http.ResponseWriter, r *http.Request, vl *volume, object, versionID string) *FSVersion {
+	if _, err := strconv.ParseUint(versionID, 10, 64); err != nil {
+		_ = InvalidArgument.ServeResponse(w, r)
+		return nil
+	}
+	version, err := vl.FileVersion(object, versionID)
+	if err != nil {
+		log.LogWarnf("getObjectVersion: get file version fail: requestID(%v) path(%v) version(%v) err(%v)",
+			RequestIDFromRequest(r), object, versionID, err)
+		_ = NoSuchVersion.ServeResponse(w, r)
+		return nil
+	}
+	w.Header().Set(HeaderNameVersionId, version.VersionID)
+	if version.DeleteMarker {
+		w.Header().Set(HeaderNameDeleteMarker, "true")
+		_ = MethodNotAllowed.ServeResponse(w, r)
+		return nil
+	}
+	return version
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortVersions(t *testing.T) {
+	now := time.Now()
+	versions := []*FSVersion{
+		{FSFileInfo: FSFileInfo{Path: "b", Inode: 5, ModifyTime: now}},
+		{FSFileInfo: FSFileInfo{Path: "a", Inode: 3, ModifyTime: now.Add(-time.Hour)}},
+		{FSFileInfo: FSFileInfo{Path: "a", Inode: 4, ModifyTime: now.Add(-2 * time.Hour)}, IsLatest: true},
+		{FSFileInfo: FSFileInfo{Path: "a", Inode: 2, ModifyTime: now.Add(-time.Hour)}},
+		{FSFileInfo: FSFileInfo{Path: "b", Inode: 6, ModifyTime: now.Add(-time.Hour)}},
+	}
+	sortVersions(versions)
+	expects := []struct {
+		path   string
+		inode  uint64
+		latest bool
+	}{
+		{"a", 4, true},
+		{"a", 3, false},
+		{"a", 2, false},
+		{"b", 5, true},
+		{"b", 6, false},
+	}
+	for i, expect := range expects {
+		if v := versions[i]; v.Path != expect.path || v.Inode != expect.inode || v.IsLatest != expect.latest {
+			t.Fatalf("version %v: path(%v) inode(%v) latest(%v), expect %+v", i, v.Path, v.Inode, v.IsLatest, expect)
+		}
+	}
+}
+
+func TestListVersionsResult(t *testing.T) {
+	versions := []*FSVersion{
+		{FSFileInfo: FSFileInfo{Path: "a", Size: 3, ETag: "e1"}, VersionID: "11", IsLatest: true},
+		{FSFileInfo: FSFileInfo{Path: "a"}, VersionID: "10", DeleteMarker: true},
+		{FSFileInfo: FSFileInfo{Path: "a", Size: 0, ETag: "e2"}, VersionID: "9"},
+	}
+	result := &ListVersionsResult{Bucket: "bucket", MaxKeys: MaxKeys, Versions: NewVersionEntries(versions, nil)}
+	marshaled, err := MarshalXMLEntity(result)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	s := string(marshaled)
+	first := strings.Index(s, "<Version><Key>a</Key><VersionId>11</VersionId>")
+	marker := strings.Index(s, "<DeleteMarker><Key>a</Key><VersionId>10</VersionId>")
+	last := strings.Index(s, "<Version><Key>a</Key><VersionId>9</VersionId>")
+	if first < 0 || marker < first || last < marker {
+		t.Fatalf("unexpected result: %v", s)
+	}
+	if !strings.Contains(s, "<Size>0</Size>") || strings.Count(s, "<Size>") != 2 {
+		t.Fatalf("unexpected sizes: %v", s)
+	}
+}
+
+func TestVersioningConfiguration(t *testing.T) {
+	config := &VersioningConfiguration{}
+	body := `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Status>Enabled</Status></VersioningConfiguration>`
+	if err := UnmarshalXMLEntity([]byte(body), config); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if config.Status != VersioningStatusEnabled || config.MfaDelete != "" {
+		t.Fatalf("unexpected configuration: %+v", *config)
+	}
+	marshaled, err := MarshalXMLEntity(&VersioningConfiguration{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(marshaled), "Status") {
+		t.Fatalf("unexpected status of the bucket never versioned: %s", marshaled)
+	}
+}
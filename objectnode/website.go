@@ -0,0 +1,217 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/WebsiteHosting.html
+
+const (
+	BucketWebsiteLimitSize = 64 * 1024
+	MaxWebsiteRoutingRules = 50
+
+	defaultWebsiteRedirectCode = http.StatusMovedPermanently
+)
+
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	RoutingRules          []*RoutingRule         `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  *RoutingRuleRedirect  `xml:"Redirect"`
+}
+
+type RoutingRuleCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type RoutingRuleRedirect struct {
+	HostName             string `xml:"HostName,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty"`
+}
+
+func isValidWebsiteProtocol(protocol string) bool {
+	return protocol == "" || protocol == "http" || protocol == "https"
+}
+
+// Validate checks the configuration is either one redirecting all the requests to another host,
+// or one with the index document.
+func (c *WebsiteConfiguration) Validate() *ErrorCode {
+	if c.RedirectAllRequestsTo != nil {
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 ||
+			c.RedirectAllRequestsTo.HostName == "" || !isValidWebsiteProtocol(c.RedirectAllRequestsTo.Protocol) {
+			return &InvalidArgument
+		}
+		return nil
+	}
+	if c.IndexDocument == nil || c.IndexDocument.Suffix == "" || strings.Contains(c.IndexDocument.Suffix, "/") {
+		return &InvalidArgument
+	}
+	if c.ErrorDocument != nil && c.ErrorDocument.Key == "" {
+		return &InvalidArgument
+	}
+	if len(c.RoutingRules) > MaxWebsiteRoutingRules {
+		return &InvalidArgument
+	}
+	for _, rule := range c.RoutingRules {
+		if rule.Redirect == nil || !isValidWebsiteProtocol(rule.Redirect.Protocol) ||
+			(rule.Redirect.ReplaceKeyPrefixWith != "" && rule.Redirect.ReplaceKeyWith != "") {
+			return &InvalidArgument
+		}
+		if code := rule.Redirect.HttpRedirectCode; code != "" {
+			if status, err := strconv.Atoi(code); err != nil || status < 300 || status > 399 {
+				return &InvalidArgument
+			}
+		}
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			if status, err := strconv.Atoi(rule.Condition.HttpErrorCodeReturnedEquals); err != nil || status < 400 || status > 599 {
+				return &InvalidArgument
+			}
+		}
+	}
+	return nil
+}
+
+// matchRoutingRule returns the first routing rule matching the key and the status code, which is
+// zero before the object is looked up, so that only the rules without the error code condition
+// are matched.
+func (c *WebsiteConfiguration) matchRoutingRule(key string, status int) *RoutingRule {
+	for _, rule := range c.RoutingRules {
+		var prefix, code string
+		if rule.Condition != nil {
+			prefix, code = rule.Condition.KeyPrefixEquals, rule.Condition.HttpErrorCodeReturnedEquals
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if (status == 0 && code == "") || (status != 0 && code == strconv.Itoa(status)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// redirectLocation returns the location and the status code redirecting the key by the rule.
+func (rule *RoutingRule) redirectLocation(r *http.Request, key string) (location string, status int) {
+	redirect := rule.Redirect
+	protocol, host := redirect.Protocol, redirect.HostName
+	if protocol == "" {
+		protocol = requestScheme(r)
+	}
+	if host == "" {
+		host = r.Host
+	}
+	if redirect.ReplaceKeyWith != "" {
+		key = redirect.ReplaceKeyWith
+	} else if redirect.ReplaceKeyPrefixWith != "" && rule.Condition != nil {
+		key = redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, rule.Condition.KeyPrefixEquals)
+	} else if redirect.ReplaceKeyPrefixWith != "" {
+		key = redirect.ReplaceKeyPrefixWith + key
+	}
+	status = defaultWebsiteRedirectCode
+	if redirect.HttpRedirectCode != "" {
+		status, _ = strconv.Atoi(redirect.HttpRedirectCode)
+	}
+	return protocol + "://" + host + "/" + key, status
+}
+
+func (v *volume) loadWebsite() (c *WebsiteConfiguration) {
+	v.om.websiteLock.RLock()
+	c = v.om.website
+	v.om.websiteLock.RUnlock()
+	return
+}
+
+func (v *volume) storeWebsite(c *WebsiteConfiguration) {
+	v.om.websiteLock.Lock()
+	v.om.website = c
+	v.om.websiteLock.Unlock()
+	return
+}
+
+// loadBucketWebsite loads the website configuration of the volume, which is nil if the volume
+// has none.
+func (v *volume) loadBucketWebsite() (c *WebsiteConfiguration, err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = store.Get(v.name, bucketRootPath, XAttrKeyOSSWebsite); err != nil {
+		log.LogErrorf("loadBucketWebsite: load bucket website fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	c = &WebsiteConfiguration{}
+	if err = xml.Unmarshal(data, c); err != nil {
+		log.LogErrorf("loadBucketWebsite: unmarshal bucket website fail: volume(%v) err(%v)", v.name, err)
+		return nil, err
+	}
+	return
+}
+
+// SetWebsite stores the website configuration of the volume, or deletes it if the configuration
+// is nil. The change is loaded by the other object nodes in OSSMetaUpdateDuration.
+func (v *volume) SetWebsite(c *WebsiteConfiguration) (err error) {
+	var store Store
+	if store, err = v.vm.GetStore(); err != nil {
+		return
+	}
+	if c == nil {
+		err = store.Delete(v.name, bucketRootPath, XAttrKeyOSSWebsite)
+	} else {
+		var data []byte
+		if data, err = xml.Marshal(c); err != nil {
+			return
+		}
+		err = store.Put(v.name, bucketRootPath, XAttrKeyOSSWebsite, data)
+	}
+	if err != nil {
+		return
+	}
+	v.storeWebsite(c)
+	return
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Get bucket website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
+func (o *ObjectNode) getBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("getBucketWebsiteHandler: get bucket website: requestID(%v)", RequestIDFromRequest(r))
+	_, _, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	config := vl.loadWebsite()
+	if config == nil {
+		_ = NoSuchWebsiteConfiguration.ServeResponse(w, r)
+		return
+	}
+
+	var marshaled []byte
+	if marshaled, err = MarshalXMLEntity(config); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: marshal result fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		ServeInternalStaticErrorResponse(w, r)
+		return
+	}
+	w.Header().Set(HeaderNameContentType, HeaderValueContentTypeXML)
+	if _, err = w.Write(marshaled); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: write response body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+	}
+	return
+}
+
+// Put bucket website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+func (o *ObjectNode) putBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("putBucketWebsiteHandler: put bucket website: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if r.ContentLength > BucketWebsiteLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, BucketWebsiteLimitSize+1)); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: read request body fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	if len(body) > BucketWebsiteLimitSize {
+		_ = EntityTooLarge.ServeResponse(w, r)
+		return
+	}
+	var config = &WebsiteConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		log.LogWarnf("putBucketWebsiteHandler: unmarshal website fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if ec := config.Validate(); ec != nil {
+		_ = ec.ServeResponse(w, r)
+		return
+	}
+
+	if err = vl.SetWebsite(config); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: set website fail: requestID(%v) bucket(%v) err(%v)",
+			RequestIDFromRequest(r), bucket, err)
+		_ = InternalError.ServeResponse(w, r)
+		return
+	}
+	log.LogDebugf("putBucketWebsiteHandler: bucket website set: requestID(%v) bucket(%v) rules(%v)",
+		RequestIDFromRequest(r), bucket, len(config.RoutingRules))
+	return
+}
+
+// Delete bucket website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
+func (o *ObjectNode) deleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("deleteBucketWebsiteHandler: delete bucket website: requestID(%v)", RequestIDFromRequest(r))
+	_, bucket, _, vl, err := o.parseRequestParams(r)
+	if err != nil {
+		log.LogErrorf("deleteBucketWebsiteHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if vl.loadWebsite() != nil {
+		if err = vl.SetWebsite(nil); err != nil {
+			log.LogErrorf("deleteBucketWebsiteHandler: delete website fail: requestID(%v) bucket(%v) err(%v)",
+				RequestIDFromRequest(r), bucket, err)
+			_ = InternalError.ServeResponse(w, r)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// Serve the requests of the website endpoint of the bucket, whose objects are read by the keys of
+// the paths. The index document is served for the paths of the directories, and the error document
+// for the keys not found. The requests are redirected by the routing rules.
+// Reference: https://docs.aws.amazon.com/AmazonS3/latest/dev/WebsiteEndpoints.html
+func (o *ObjectNode) websiteHandler(w http.ResponseWriter, r *http.Request) {
+	log.LogInfof("websiteHandler: serve website: requestID(%v) remote(%v)", RequestIDFromRequest(r), r.RemoteAddr)
+	_, bucket, object, vl, err := o.parseRequestParams(r)
+	if err != nil || vl == nil {
+		log.LogErrorf("websiteHandler: parse request parameters fail: requestID(%v) err(%v)", RequestIDFromRequest(r), err)
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		_ = MethodNotAllowed.ServeResponse(w, r)
+		return
+	}
+	config := vl.loadWebsite()
+	if config == nil {
+		_ = NoSuchWebsiteConfiguration.ServeResponse(w, r)
+		return
+	}
+	if to := config.RedirectAllRequestsTo; to != nil {
+		protocol := to.Protocol
+		if protocol == "" {
+			protocol = requestScheme(r)
+		}
+		http.Redirect(w, r, protocol+"://"+to.HostName+r.URL.RequestURI(), defaultWebsiteRedirectCode)
+		return
+	}
+
+	key := object
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += config.IndexDocument.Suffix
+	}
+	if rule := config.matchRoutingRule(key, 0); rule != nil {
+		location, status := rule.redirectLocation(r, key)
+		http.Redirect(w, r, location, status)
+		return
+	}
+	var fileInfo *FSFileInfo
+	if fileInfo, err = vl.FileInfo(key); err == nil {
+		o.serveWebsiteObject(w, r, vl, fileInfo, http.StatusOK)
+		return
+	}
+	// the directory without the trailing slash is redirected to the one with it, as S3 does
+	if key == object {
+		if _, err = vl.FileInfo(key + "/" + config.IndexDocument.Suffix); err == nil {
+			http.Redirect(w, r, "/"+key+"/", http.StatusFound)
+			return
+		}
+	}
+
+	log.LogDebugf("websiteHandler: key not found: requestID(%v) bucket(%v) key(%v)", RequestIDFromRequest(r), bucket, key)
+	if rule := config.matchRoutingRule(key, http.StatusNotFound); rule != nil {
+		location, status := rule.redirectLocation(r, key)
+		http.Redirect(w, r, location, status)
+		return
+	}
+	if config.ErrorDocument != nil {
+		if fileInfo, err = vl.FileInfo(config.ErrorDocument.Key); err == nil {
+			o.serveWebsiteObject(w, r, vl, fileInfo, http.StatusNotFound)
+			return
+		}
+		log.LogWarnf("websiteHandler: error document not found: requestID(%v) bucket(%v) key(%v)",
+			RequestIDFromRequest(r), bucket, config.ErrorDocument.Key)
+	}
+	_ = NoSuchKey.ServeResponse(w, r)
+	return
+}
+
+// serveWebsiteObject writes the object with the content type of its extension, so that the
+// browsers render it.
+func (o *ObjectNode) serveWebsiteObject(w http.ResponseWriter, r *http.Request, vl *volume, fileInfo *FSFileInfo, status int) {
+	contentType := mime.TypeByExtension(path.Ext(fileInfo.Path))
+	if contentType == "" {
+		contentType = HeaderValueTypeStream
+	}
+	w.Header().Set(HeaderNameETag, fileInfo.ETag)
+	w.Header().Set(HeaderNameLastModified, formatTimeRFC1123(fileInfo.ModifyTime))
+	w.Header().Set(HeaderNameContentType, contentType)
+	w.Header().Set(HeaderNameContentLength, strconv.FormatInt(fileInfo.Size, 10))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := vl.ReadFile(fileInfo.Path, w, 0, uint64(fileInfo.Size)); err != nil {
+		log.LogErrorf("serveWebsiteObject: read from volume fail: requestId(%v) volume(%v) path(%v) err(%v)",
+			RequestIDFromRequest(r), vl.name, fileInfo.Path, err)
+	}
+	return
+}
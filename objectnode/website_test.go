@@ -0,0 +1,93 @@
+// Copyright 2018 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebsiteConfiguration_Validate(t *testing.T) {
+	cases := []struct {
+		body  string
+		valid bool
+	}{
+		{`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`, true},
+		{`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+			<ErrorDocument><Key>error.html</Key></ErrorDocument>
+			<RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+			<Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules>
+			</WebsiteConfiguration>`, true},
+		{`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`, true},
+		{`<WebsiteConfiguration></WebsiteConfiguration>`, false},
+		{`<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`, false},
+		{`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+			<RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`, false},
+		{`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>ftp</Protocol>
+			</RedirectAllRequestsTo></WebsiteConfiguration>`, false},
+		{`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+			<RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules>
+			</WebsiteConfiguration>`, false},
+		{`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+			<RoutingRules><RoutingRule><Redirect><ReplaceKeyPrefixWith>a/</ReplaceKeyPrefixWith><ReplaceKeyWith>b</ReplaceKeyWith>
+			</Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`, false},
+	}
+	for i, c := range cases {
+		config := &WebsiteConfiguration{}
+		if err := UnmarshalXMLEntity([]byte(c.body), config); err != nil {
+			t.Fatalf("case(%v): unmarshal: %v", i, err)
+		}
+		if ec := config.Validate(); (ec == nil) != c.valid {
+			t.Fatalf("case(%v): error(%v), expect valid(%v)", i, ec, c.valid)
+		}
+	}
+}
+
+func TestWebsiteConfiguration_RoutingRules(t *testing.T) {
+	config := &WebsiteConfiguration{
+		IndexDocument: &IndexDocument{Suffix: "index.html"},
+		RoutingRules: []*RoutingRule{
+			{
+				Condition: &RoutingRuleCondition{KeyPrefixEquals: "docs/"},
+				Redirect:  &RoutingRuleRedirect{ReplaceKeyPrefixWith: "documents/"},
+			},
+			{
+				Condition: &RoutingRuleCondition{HttpErrorCodeReturnedEquals: "404"},
+				Redirect:  &RoutingRuleRedirect{HostName: "example.com", Protocol: "https", ReplaceKeyWith: "missing.html", HttpRedirectCode: "302"},
+			},
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://b.website.local/docs/a.html", nil)
+
+	rule := config.matchRoutingRule("docs/a.html", 0)
+	if rule == nil {
+		t.Fatalf("no rule of prefix matched")
+	}
+	if location, status := rule.redirectLocation(r, "docs/a.html"); location != "http://b.website.local/documents/a.html" ||
+		status != http.StatusMovedPermanently {
+		t.Fatalf("prefix redirected to %v by %v", location, status)
+	}
+	if rule = config.matchRoutingRule("images/a.png", 0); rule != nil {
+		t.Fatalf("rule of error code matched before lookup")
+	}
+	if rule = config.matchRoutingRule("images/a.png", http.StatusNotFound); rule == nil {
+		t.Fatalf("no rule of error code matched")
+	}
+	if location, status := rule.redirectLocation(r, "images/a.png"); location != "https://example.com/missing.html" ||
+		status != http.StatusFound {
+		t.Fatalf("not found redirected to %v by %v", location, status)
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// The names of the extended attributes of the POSIX ACLs.
+const (
+	XAttrPosixACLAccess  = "system.posix_acl_access"
+	XAttrPosixACLDefault = "system.posix_acl_default"
+)
+
+// The tags of the ACL entries, the same as Linux.
+const (
+	ACLUserObj  uint16 = 0x01
+	ACLUser     uint16 = 0x02
+	ACLGroupObj uint16 = 0x04
+	ACLGroup    uint16 = 0x08
+	ACLMask     uint16 = 0x10
+	ACLOther    uint16 = 0x20
+)
+
+// The permissions of the ACL entries.
+const (
+	ACLRead    uint16 = 0x04
+	ACLWrite   uint16 = 0x02
+	ACLExecute uint16 = 0x01
+)
+
+const (
+	aclXAttrVersion   = 2
+	aclXAttrHeaderLen = 4
+	aclXAttrEntryLen  = 8
+	aclUndefinedID    = 0xFFFFFFFF
+)
+
+// ErrInvalidACL is returned if the ACL is malformed.
+var ErrInvalidACL = errors.New("invalid posix acl")
+
+// ACLEntry is an entry of the POSIX ACL. The ID is only meaningful for the named users and groups.
+type ACLEntry struct {
+	Tag  uint16 `json:"tag"`
+	Perm uint16 `json:"perm"`
+	ID   uint32 `json:"id"`
+}
+
+// ACL is the POSIX ACL of an inode.
+type ACL []ACLEntry
+
+// NewACLFromMode returns the minimal ACL equivalent to the permission bits of the mode.
+func NewACLFromMode(mode uint32) ACL {
+	return ACL{
+		{Tag: ACLUserObj, Perm: uint16(mode>>6) & 7},
+		{Tag: ACLGroupObj, Perm: uint16(mode>>3) & 7},
+		{Tag: ACLOther, Perm: uint16(mode) & 7},
+	}
+}
+
+// DecodeACL decodes the ACL in the format of the extended attributes of Linux, which is a
+// little endian version header followed by the entries of the tag, the permission and the ID.
+func DecodeACL(raw []byte) (acl ACL, err error) {
+	if len(raw) < aclXAttrHeaderLen || (len(raw)-aclXAttrHeaderLen)%aclXAttrEntryLen != 0 ||
+		binary.LittleEndian.Uint32(raw) != aclXAttrVersion {
+		return nil, ErrInvalidACL
+	}
+	acl = make(ACL, 0, (len(raw)-aclXAttrHeaderLen)/aclXAttrEntryLen)
+	for off := aclXAttrHeaderLen; off < len(raw); off += aclXAttrEntryLen {
+		entry := ACLEntry{
+			Tag:  binary.LittleEndian.Uint16(raw[off:]),
+			Perm: binary.LittleEndian.Uint16(raw[off+2:]),
+			ID:   binary.LittleEndian.Uint32(raw[off+4:]),
+		}
+		if entry.Tag != ACLUser && entry.Tag != ACLGroup {
+			entry.ID = 0
+		}
+		acl = append(acl, entry)
+	}
+	if err = acl.Validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Encode encodes the ACL in the format of the extended attributes of Linux, with the entries
+// sorted by the tag and the ID.
+func (acl ACL) Encode() []byte {
+	sorted := append(ACL(nil), acl...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Tag != sorted[j].Tag {
+			return sorted[i].Tag < sorted[j].Tag
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	raw := make([]byte, aclXAttrHeaderLen+len(sorted)*aclXAttrEntryLen)
+	binary.LittleEndian.PutUint32(raw, aclXAttrVersion)
+	off := aclXAttrHeaderLen
+	for _, entry := range sorted {
+		id := entry.ID
+		if entry.Tag != ACLUser && entry.Tag != ACLGroup {
+			id = aclUndefinedID
+		}
+		binary.LittleEndian.PutUint16(raw[off:], entry.Tag)
+		binary.LittleEndian.PutUint16(raw[off+2:], entry.Perm)
+		binary.LittleEndian.PutUint32(raw[off+4:], id)
+		off += aclXAttrEntryLen
+	}
+	return raw
+}
+
+// Validate checks that the ACL has exactly one entry of the owner, the owning group and the
+// others, no duplicate named users or groups, and a mask if there are named entries.
+func (acl ACL) Validate() error {
+	var counts = make(map[uint16]int)
+	var users, groups = make(map[uint32]bool), make(map[uint32]bool)
+	for _, entry := range acl {
+		if entry.Perm&^7 != 0 {
+			return ErrInvalidACL
+		}
+		switch entry.Tag {
+		case ACLUser:
+			if users[entry.ID] {
+				return ErrInvalidACL
+			}
+			users[entry.ID] = true
+		case ACLGroup:
+			if groups[entry.ID] {
+				return ErrInvalidACL
+			}
+			groups[entry.ID] = true
+		case ACLUserObj, ACLGroupObj, ACLMask, ACLOther:
+			if counts[entry.Tag] > 0 {
+				return ErrInvalidACL
+			}
+		default:
+			return ErrInvalidACL
+		}
+		counts[entry.Tag]++
+	}
+	if counts[ACLUserObj] != 1 || counts[ACLGroupObj] != 1 || counts[ACLOther] != 1 {
+		return ErrInvalidACL
+	}
+	if (counts[ACLUser] > 0 || counts[ACLGroup] > 0) && counts[ACLMask] == 0 {
+		return ErrInvalidACL
+	}
+	return nil
+}
+
+// IsMinimal tells whether the ACL is equivalent to the permission bits of the mode.
+func (acl ACL) IsMinimal() bool {
+	return len(acl) == 3
+}
+
+// Mode returns the permission bits of the mode equivalent to the ACL, whose group class is the
+// mask if any.
+func (acl ACL) Mode() (mode uint32) {
+	var group, mask uint16
+	var hasMask bool
+	for _, entry := range acl {
+		switch entry.Tag {
+		case ACLUserObj:
+			mode |= uint32(entry.Perm) << 6
+		case ACLGroupObj:
+			group = entry.Perm
+		case ACLMask:
+			mask, hasMask = entry.Perm, true
+		case ACLOther:
+			mode |= uint32(entry.Perm)
+		}
+	}
+	if hasMask {
+		group = mask
+	}
+	return mode | uint32(group)<<3
+}
+
+// Chmod returns a copy of the ACL whose owner, group class and others are set to the permission
+// bits of the mode, as chmod does. The group class is the mask if any.
+func (acl ACL) Chmod(mode uint32) ACL {
+	return acl.apply(mode, func(perm, bits uint16) uint16 { return bits })
+}
+
+// Inherit returns the ACL of the inode created with the mode under the directory of the default
+// ACL, whose owner, group class and others are limited by the permission bits of the mode.
+func (acl ACL) Inherit(mode uint32) ACL {
+	return acl.apply(mode, func(perm, bits uint16) uint16 { return perm & bits })
+}
+
+func (acl ACL) apply(mode uint32, fn func(perm, bits uint16) uint16) ACL {
+	var result = append(ACL(nil), acl...)
+	var hasMask bool
+	for _, entry := range acl {
+		if entry.Tag == ACLMask {
+			hasMask = true
+		}
+	}
+	for i := range result {
+		entry := &result[i]
+		switch {
+		case entry.Tag == ACLUserObj:
+			entry.Perm = fn(entry.Perm, uint16(mode>>6)&7)
+		case entry.Tag == ACLMask, entry.Tag == ACLGroupObj && !hasMask:
+			entry.Perm = fn(entry.Perm, uint16(mode>>3)&7)
+		case entry.Tag == ACLOther:
+			entry.Perm = fn(entry.Perm, uint16(mode)&7)
+		}
+	}
+	return result
+}
+
+// Permits tells whether the user of the groups is granted all the permissions wanted on the inode
+// of the owner and the owning group, by the access check algorithm of POSIX ACLs.
+func (acl ACL) Permits(owner, group, uid uint32, gids []uint32, want uint16) bool {
+	var mask uint16 = 7
+	for _, entry := range acl {
+		if entry.Tag == ACLMask {
+			mask = entry.Perm
+		}
+	}
+	var inGroups = func(gid uint32) bool {
+		for _, id := range gids {
+			if id == gid {
+				return true
+			}
+		}
+		return false
+	}
+	if uid == owner {
+		for _, entry := range acl {
+			if entry.Tag == ACLUserObj {
+				return entry.Perm&want == want
+			}
+		}
+		return false
+	}
+	for _, entry := range acl {
+		if entry.Tag == ACLUser && entry.ID == uid {
+			return entry.Perm&mask&want == want
+		}
+	}
+	var matched bool
+	for _, entry := range acl {
+		if entry.Tag == ACLGroupObj && inGroups(group) || entry.Tag == ACLGroup && inGroups(entry.ID) {
+			if entry.Perm&mask&want == want {
+				return true
+			}
+			matched = true
+		}
+	}
+	if matched {
+		return false
+	}
+	for _, entry := range acl {
+		if entry.Tag == ACLOther {
+			return entry.Perm&want == want
+		}
+	}
+	return false
+}
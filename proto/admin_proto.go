@@ -14,6 +14,8 @@
 
 package proto
 
+import "strings"
+
 // api
 const (
 	// Admin APIs
@@ -24,6 +26,7 @@ const (
 	AdminDecommissionDataPartition = "/dataPartition/decommission"
 	AdminDeleteDataReplica         = "/dataReplica/delete"
 	AdminAddDataReplica            = "/dataReplica/add"
+	AdminPromoteDataReplica        = "/dataReplica/promote"
 	AdminDeleteVol                 = "/vol/delete"
 	AdminUpdateVol                 = "/vol/update"
 	AdminCreateVol                 = "/admin/createVol"
@@ -32,6 +35,43 @@ const (
 	AdminGetIP                     = "/admin/getIp"
 	AdminCreateMetaPartition       = "/metaPartition/create"
 	AdminSetMetaNodeThreshold      = "/threshold/set"
+	AdminSetRateLimit              = "/ratelimit/set"
+	AdminGetRateLimit              = "/ratelimit/get"
+	AdminListEvents                = "/events/list"
+	AdminSetVolLifecycle           = "/vol/lifecycle/set"
+	AdminGetVolLifecycle           = "/vol/lifecycle/get"
+	AdminListVolLifecycles         = "/vol/lifecycle/list"
+	AdminSetVolPolicy              = "/vol/policy/set"
+	AdminGetVolPolicy              = "/vol/policy/get"
+	AdminSetVolQuota               = "/vol/quota/set"
+	AdminDeleteVolQuota            = "/vol/quota/delete"
+	AdminListVolQuotas             = "/vol/quota/list"
+	AdminSetVolTrash               = "/vol/trash/set"
+	AdminSetVolCompression         = "/vol/compression/set"
+	AdminSetVolEC                  = "/vol/ec/set"
+	AdminGetECPartition            = "/ecPartition/get"
+	AdminSetVolTiering             = "/vol/tiering/set"
+	AdminSetVolPlacement           = "/vol/placement/set"
+	AdminShrinkVol                 = "/vol/shrink"
+	AdminReleaseDataPartition      = "/dataPartition/release"
+	AdminCreateVolSnapshot         = "/vol/snapshot/create"
+	AdminDeleteVolSnapshot         = "/vol/snapshot/delete"
+	AdminListVolSnapshots          = "/vol/snapshot/list"
+	AdminCreateVolAccessKey        = "/vol/accessKey/create"
+	AdminListVolAccessKeys         = "/vol/accessKey/list"
+	AdminRevokeVolAccessKey        = "/vol/accessKey/revoke"
+	AdminGetEncryptionKey          = "/encryptionKey/get"
+	AdminRotateEncryptionKey       = "/encryptionKey/rotate"
+	AdminReverseLookupInode        = "/vol/inode/links"
+	AdminGetBalancePlan            = "/balance/plan"
+	AdminPauseBalance              = "/balance/pause"
+	AdminResumeBalance             = "/balance/resume"
+	AdminCreateDecommissionJob     = "/decommission/job/create"
+	AdminGetDecommissionJob        = "/decommission/job/get"
+	AdminListDecommissionJobs      = "/decommission/job/list"
+	AdminPauseDecommissionJob      = "/decommission/job/pause"
+	AdminResumeDecommissionJob     = "/decommission/job/resume"
+	AdminCancelDecommissionJob     = "/decommission/job/cancel"
 
 	// Client APIs
 	ClientDataPartitions = "/client/partitions"
@@ -56,10 +96,14 @@ const (
 	AdminDecommissionMetaPartition = "/metaPartition/decommission"
 	AdminAddMetaReplica            = "/metaReplica/add"
 	AdminDeleteMetaReplica         = "/metaReplica/delete"
+	AdminPromoteMetaReplica        = "/metaReplica/promote"
+	AddECNode                      = "/ecNode/add"
+	GetECNode                      = "/ecNode/get"
 
 	// Operation response
 	GetMetaNodeTaskResponse = "/metaNode/response" // Method: 'POST', ContentType: 'application/json'
 	GetDataNodeTaskResponse = "/dataNode/response" // Method: 'POST', ContentType: 'application/json'
+	GetECNodeTaskResponse   = "/ecNode/response"   // Method: 'POST', ContentType: 'application/json'
 
 	GetTopologyView = "/topo/get"
 
@@ -81,8 +125,9 @@ type RegisterMetaNodeResp struct {
 
 // ClusterInfo defines the cluster infomation.
 type ClusterInfo struct {
-	Cluster string
-	Ip      string
+	Cluster          string
+	Ip               string
+	MinClientVersion uint32 `json:",omitempty"` // the greatest minimum client version of the nodes
 }
 
 // CreateDataPartitionRequest defines the request to create a data partition.
@@ -149,6 +194,30 @@ type RemoveMetaPartitionRaftMemberRequest struct {
 	RemovePeer  Peer
 }
 
+// AddDataPartitionRaftLearnerRequest defines the request of add raftLearner a data partition.
+type AddDataPartitionRaftLearnerRequest struct {
+	PartitionId uint64
+	AddLearner  Peer
+}
+
+// PromoteDataPartitionRaftLearnerRequest defines the request of promote raftLearner a data partition.
+type PromoteDataPartitionRaftLearnerRequest struct {
+	PartitionId    uint64
+	PromoteLearner Peer
+}
+
+// AddMetaPartitionRaftLearnerRequest defines the request of add raftLearner a meta partition.
+type AddMetaPartitionRaftLearnerRequest struct {
+	PartitionId uint64
+	AddLearner  Peer
+}
+
+// PromoteMetaPartitionRaftLearnerRequest defines the request of promote raftLearner a meta partition.
+type PromoteMetaPartitionRaftLearnerRequest struct {
+	PartitionId    uint64
+	PromoteLearner Peer
+}
+
 // LoadDataPartitionRequest defines the request of loading a data partition.
 type LoadDataPartitionRequest struct {
 	PartitionId uint64
@@ -193,6 +262,291 @@ type LoadMetaPartitionMetricResponse struct {
 type HeartBeatRequest struct {
 	CurrTime   int64
 	MasterAddr string
+	RateLimits []*RateLimitRule
+	// VolSnapshots is the snapshots of the volumes indexed by the volume name, which is nil if
+	// the master does not support the snapshots, and has no entry of the volumes without them.
+	VolSnapshots map[string][]*VolSnapshot
+	// VolCompressions is the compression of the extents of the volumes indexed by the volume name,
+	// which has no entry of the volumes without compression.
+	VolCompressions map[string]string
+}
+
+// The compressions of the extents of the volumes.
+const (
+	ExtentCompressionNone = "none"
+	ExtentCompressionLZ4  = "lz4"
+	ExtentCompressionZstd = "zstd"
+)
+
+// IsValidExtentCompression tells whether the compression of the extents is supported.
+func IsValidExtentCompression(compression string) bool {
+	switch compression {
+	case ExtentCompressionNone, ExtentCompressionLZ4, ExtentCompressionZstd:
+		return true
+	}
+	return false
+}
+
+// ECSchemeNone disables the erasure coding of the volume.
+const ECSchemeNone = "none"
+
+// The media classes of the data nodes, and MediaNone disables the tiering of the volume.
+const (
+	MediaSSD  = "ssd"
+	MediaHDD  = "hdd"
+	MediaNone = "none"
+)
+
+// IsValidMedia tells whether the media class of the data nodes is supported.
+func IsValidMedia(media string) bool {
+	return media == MediaSSD || media == MediaHDD
+}
+
+// RateLimitRule limits the rate of the ops of a module, which match the volume, the op and the
+// client of the rule. An empty volume, op or client matches all, and the client "*" limits each
+// client separately instead of all of them together. Either the ops or the bytes are not limited
+// if their rate is 0.
+type RateLimitRule struct {
+	Module string  `json:"module"`
+	Vol    string  `json:"vol,omitempty"`
+	Op     string  `json:"op,omitempty"`
+	Client string  `json:"client,omitempty"`
+	Rate   float64 `json:"rate"` // ops per second
+	Burst  int     `json:"burst,omitempty"`
+	Bytes  float64 `json:"bytes,omitempty"` // bytes per second
+}
+
+// Types of the cluster events
+const (
+	EventNodeOffline          = "NodeOffline"
+	EventPartitionUnavailable = "PartitionUnavailable"
+	EventVolumeCreated        = "VolumeCreated"
+	EventVolumeDeleted        = "VolumeDeleted"
+	EventDecommissionFinished = "DecommissionFinished"
+	EventDiskFailed           = "DiskFailed"
+	EventPlacementRepaired    = "PlacementRepaired"
+	EventDecommissionJobEnded = "DecommissionJobEnded"
+)
+
+// ClusterEvent is an event of the cluster emitted by the master, whose ID is increasing.
+type ClusterEvent struct {
+	ID      uint64 `json:"id"`
+	Time    int64  `json:"time"`
+	Cluster string `json:"cluster"`
+	Type    string `json:"type"`
+	Target  string `json:"target"` // address of the node, ID of the partition or name of the volume
+	Msg     string `json:"msg"`
+}
+
+// ClusterEventsView defines the view of the listed events, and the ID to list the next events from.
+type ClusterEventsView struct {
+	Events []*ClusterEvent `json:"events"`
+	Next   uint64          `json:"next"`
+}
+
+// LifecycleRule is a rule of the lifecycle of the objects with the prefix in a volume, which
+// expires the objects and aborts the incomplete multipart uploads the days after they are
+// modified or initiated, unless the days are 0.
+type LifecycleRule struct {
+	ID                                 string `json:"id"`
+	Prefix                             string `json:"prefix,omitempty"`
+	Enabled                            bool   `json:"enabled"`
+	ExpirationDays                     int    `json:"expirationDays,omitempty"`
+	AbortIncompleteMultipartUploadDays int    `json:"abortIncompleteMultipartUploadDays,omitempty"`
+}
+
+// LifecycleConfiguration defines the lifecycle rules of a volume, which are kept by the master
+// and executed by the object nodes.
+type LifecycleConfiguration struct {
+	VolName string           `json:"volName"`
+	Rules   []*LifecycleRule `json:"rules"`
+}
+
+// BucketPolicy is the S3 bucket policy in JSON of a volume, which is kept by the master and
+// evaluated by the object nodes. The volume has no policy if it is empty.
+type BucketPolicy struct {
+	VolName string `json:"volName"`
+	Policy  string `json:"policy"`
+}
+
+// QuotaInfo is a quota of the inodes and the bytes of the subtree under a directory of a volume.
+// The limits are kept by the master, and the usage is reported by the meta partition leaders,
+// which count the inodes tagged with the quota ID. A limit of 0 is unlimited.
+type QuotaInfo struct {
+	QuotaID       uint32 `json:"quotaId"`
+	Path          string `json:"path"`
+	RootInode     uint64 `json:"rootInode"`
+	MaxFiles      uint64 `json:"maxFiles"`
+	MaxBytes      uint64 `json:"maxBytes"`
+	UsedFiles     uint64 `json:"usedFiles"`
+	UsedBytes     uint64 `json:"usedBytes"`
+	FilesExceeded bool   `json:"filesExceeded"`
+	BytesExceeded bool   `json:"bytesExceeded"`
+}
+
+// QuotaUsage is the usage of a quota in a meta partition.
+type QuotaUsage struct {
+	QuotaID   uint32
+	UsedFiles uint64
+	UsedBytes uint64
+}
+
+// The statuses of the volume snapshots.
+const (
+	VolSnapshotFreezingData = "freezingData"
+	VolSnapshotFreezingMeta = "freezingMeta"
+	VolSnapshotMarkingData  = "markingData"
+	VolSnapshotReady        = "ready"
+)
+
+// VolSnapshot is a named snapshot of a volume, which is taken in three phases. The data partitions
+// first freeze all their extents, which are copied on write since then, and the meta partitions
+// freeze their inodes and dentries next. The data partitions then mark the extents created so far
+// as frozen by a watermark, which covers the ones created before the meta partitions froze. The
+// snapshot is ready once all of them have done.
+type VolSnapshot struct {
+	ID         uint32 `json:"id"`
+	Name       string `json:"name"`
+	CreateTime int64  `json:"createTime"`
+	Status     string `json:"status"`
+}
+
+// The permissions of the access keys of the volumes.
+const (
+	AccessKeyReadWrite = "readWrite"
+	AccessKeyReadOnly  = "readOnly"
+)
+
+// VolAccessKey is an access key of a volume besides the one of the owner, so that each application
+// is given its own key, which is scoped, expired and revoked without rotating the others. The
+// requests signed by it are served as the ones of the owner, restricted by the permission and the
+// prefixes of the object keys if any.
+type VolAccessKey struct {
+	AccessKey  string   `json:"accessKey"`
+	SecretKey  string   `json:"secretKey,omitempty"`
+	Permission string   `json:"permission"`
+	Prefixes   []string `json:"prefixes,omitempty"`
+	CreateTime int64    `json:"createTime"`
+	ExpireTime int64    `json:"expireTime"` // never expires if 0
+}
+
+// Expired returns whether the access key has expired at the unix time.
+func (k *VolAccessKey) Expired(now int64) bool {
+	return k.ExpireTime > 0 && now >= k.ExpireTime
+}
+
+func (k *VolAccessKey) ReadOnly() bool {
+	return k.Permission == AccessKeyReadOnly
+}
+
+// AllowsKey returns whether the object key is under the prefixes of the access key, or the access
+// key is not scoped by any prefix.
+func (k *VolAccessKey) AllowsKey(key string) bool {
+	if len(k.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range k.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptionKey is a key encryption key of the cluster kept by the master, which encrypts the
+// data keys of the objects encrypted by the object nodes. The keys are never removed, and the
+// one of the largest version encrypts the new data keys.
+type EncryptionKey struct {
+	Version    uint32 `json:"version"`
+	Key        []byte `json:"key"`
+	CreateTime int64  `json:"createTime"`
+}
+
+// BalanceMove is a move of the replica of a data partition planned by the balancer of the master.
+type BalanceMove struct {
+	PartitionID uint64 `json:"partitionId"`
+	VolName     string `json:"vol"`
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	Size        uint64 `json:"size"` // the bytes used by the replica on the source
+	Reason      string `json:"reason"`
+}
+
+// BalanceView is the state of the balancer of the master and the moves it plans for the next round.
+type BalanceView struct {
+	Paused     bool           `json:"paused"`
+	Recovering int            `json:"recovering"` // the data partitions recovering in the cluster
+	MeanUsage  float64        `json:"meanUsage"`
+	MeanCount  float64        `json:"meanCount"` // the mean number of the partitions of the data nodes
+	Moves      []*BalanceMove `json:"moves"`
+}
+
+// Kinds of the decommission jobs, and the states of the jobs and the partitions they migrate
+const (
+	DecommissionJobDataNode = "datanode"
+	DecommissionJobMetaNode = "metanode"
+
+	DecommissionRunning   = "running"
+	DecommissionPaused    = "paused"
+	DecommissionCancelled = "cancelled"
+	DecommissionFailed    = "failed"
+	DecommissionDone      = "done"
+
+	DecommissionPending   = "pending"
+	DecommissionMigrating = "migrating"
+)
+
+// DecommissionPartition is a partition whose replica on the node is migrated by a decommission job.
+type DecommissionPartition struct {
+	PartitionID uint64 `json:"partitionId"`
+	VolName     string `json:"vol"`
+	Size        uint64 `json:"size"` // the bytes used by the replica of a data partition on the node
+	State       string `json:"state"`
+	Retries     int    `json:"retries"`
+	Error       string `json:"error,omitempty"`
+	UpdateTime  int64  `json:"updateTime"`
+}
+
+// DecommissionJob drains the partitions off a data node or a meta node, which is removed from the
+// cluster once all of them are migrated. It is run and persisted by the master, and the partitions
+// are omitted when the jobs are listed.
+type DecommissionJob struct {
+	ID          uint64                   `json:"id"`
+	Kind        string                   `json:"kind"`
+	Addr        string                   `json:"addr"`
+	State       string                   `json:"state"`
+	Concurrency int                      `json:"concurrency"` // the partitions migrated at the same time
+	Rate        uint64                   `json:"rate"`        // bytes per second of the data partitions migrated, no limit if 0
+	MaxRetries  int                      `json:"maxRetries"`
+	Total       int                      `json:"total"`
+	Done        int                      `json:"done"`
+	Failed      int                      `json:"failed"`
+	CreateTime  int64                    `json:"createTime"`
+	UpdateTime  int64                    `json:"updateTime"`
+	Partitions  []*DecommissionPartition `json:"partitions,omitempty"`
+}
+
+// Ended returns whether the job is done or cancelled. A failed job can be resumed to retry the
+// failed partitions.
+func (job *DecommissionJob) Ended() bool {
+	return job.State == DecommissionDone || job.State == DecommissionCancelled
+}
+
+// InodeLinks is the link count of an inode and the dentries referring to it in all the meta
+// partitions of the volume. They differ for a file if the link count leaks.
+type InodeLinks struct {
+	Inode uint64       `json:"ino"`
+	Nlink uint32       `json:"nlink"`
+	Links []*InodeLink `json:"links"`
+}
+
+// RaftHealth defines the health of the raft group of a partition on a node.
+type RaftHealth struct {
+	CommitLatency    int64  // moving average in microseconds, on the leader
+	ApplyLag         uint64 // entries committed but not applied yet
+	PendingProposals int
+	LeaderChanges    uint64
+	Elections        uint64
 }
 
 // PartitionReport defines the partition report.
@@ -206,6 +560,12 @@ type PartitionReport struct {
 	IsLeader        bool
 	ExtentCount     int
 	NeedCompare     bool
+	RaftHealth      *RaftHealth
+	// FrozenSnapshotID is the latest snapshot of the volume the extents of the partition are frozen for.
+	FrozenSnapshotID uint32
+	// FreezingSnapshotID is the snapshot being taken all the extents of the partition are frozen for.
+	FreezingSnapshotID uint32
+	AccessTime         int64 // the last time the partition was read or written by the clients
 }
 
 // DataNodeHeartbeatResponse defines the response to the data node heartbeat.
@@ -222,6 +582,52 @@ type DataNodeHeartbeatResponse struct {
 	Status              uint8
 	Result              string
 	BadDisks            []string
+	ProtocolVersion     uint32
+	MinClientVersion    uint32
+	MediaType           string
+	ZoneName            string
+	RackName            string
+}
+
+// ECPartitionReport defines the report of the shard of an erasure coded partition.
+type ECPartitionReport struct {
+	VolName     string
+	PartitionID uint64
+	ShardIndex  int
+	ExtentCount int
+	Used        uint64
+}
+
+// ECNodeHeartbeatResponse defines the response to the ec node heartbeat.
+type ECNodeHeartbeatResponse struct {
+	Total            uint64
+	Used             uint64
+	Available        uint64
+	PartitionReports []*ECPartitionReport
+	Status           uint8
+	Result           string
+}
+
+// ECMigrateRequest defines the request to migrate a data partition to an erasure coded partition,
+// which is sent to the first ec node of the partition coordinating the migration.
+type ECMigrateRequest struct {
+	PartitionID  uint64
+	VolName      string
+	DataHosts    []string // the replicas of the data partition
+	ECHosts      []string // the ec nodes of the shards in order
+	DataShards   int
+	ParityShards int
+	StripeUnit   uint32
+	ColdAge      int64 // the seconds the extents must have not been modified for
+}
+
+// ECMigrateResponse defines the response to the request of migrating a data partition.
+type ECMigrateResponse struct {
+	PartitionID uint64
+	ExtentCount int
+	Size        uint64 // the size of the data migrated
+	Status      uint8
+	Result      string
 }
 
 // MetaPartitionReport defines the meta partition report.
@@ -233,16 +639,24 @@ type MetaPartitionReport struct {
 	MaxInodeID  uint64
 	IsLeader    bool
 	VolName     string
+	RaftHealth  *RaftHealth
+	QuotaUsages []*QuotaUsage
+	// VolSnapshotIDs is the snapshots of the volume frozen in the partition.
+	VolSnapshotIDs []uint32
 }
 
 // MetaNodeHeartbeatResponse defines the response to the meta node heartbeat request.
 type MetaNodeHeartbeatResponse struct {
 	CellName             string
+	ZoneName             string
+	RackName             string
 	Total                uint64
 	Used                 uint64
 	MetaPartitionReports []*MetaPartitionReport
 	Status               uint8
 	Result               string
+	ProtocolVersion      uint32
+	MinClientVersion     uint32
 }
 
 // DeleteFileRequest defines the request to delete a file.
@@ -327,6 +741,10 @@ type DataPartitionResponse struct {
 	Hosts       []string
 	LeaderAddr  string
 	Epoch       uint64
+	// MigratingHosts are the replicas being filled by the tiering, which the follower reads skip.
+	MigratingHosts []string `json:",omitempty"`
+	// ErasureCoded is set for the erasure coded partitions, whose hosts are the ec nodes of the shards.
+	ErasureCoded bool `json:",omitempty"`
 }
 
 // DataPartitionsView defines the view of a data partition
@@ -363,9 +781,11 @@ type VolView struct {
 	Owner          string
 	Status         uint8
 	FollowerRead   bool
+	TrashRetention uint32 // hours, the trash is disabled if 0
 	MetaPartitions []*MetaPartitionView
 	DataPartitions []*DataPartitionResponse
 	OSSSecure      *OSSSecure
+	AccessKeys     []*VolAccessKey // the unexpired access keys besides the one of the owner
 }
 
 func (v *VolView) SetOwner(owner string) {
@@ -411,6 +831,17 @@ type SimpleVolView struct {
 	FollowerRead       bool
 	NeedToLowerReplica bool
 	Authenticate       bool
+	MetaStoreMode      string
+	TrashRetention     uint32
+	Compression        string
+	ECScheme           string // the erasure coding scheme of the cold data partitions, like 4+2
+	ECColdAge          int64  // the seconds the data must have not been modified for before encoded
+	HotMedia           string // the media of the data nodes the data partitions are created on
+	ColdMedia          string // the media of the data nodes the cold data partitions are migrated to
+	TierColdAge        int64  // the seconds the data must have not been accessed for before migrated
+	ZoneSpread         uint8  // the minimum number of the zones the replicas of a partition spread across
+	RackSpread         uint8  // the minimum number of the racks the replicas of a partition spread across
+	ReleasingDps       []uint64
 }
 
 // MasterAPIAccessResp defines the response for getting meta partition
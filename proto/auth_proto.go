@@ -53,7 +53,12 @@ const (
 // api
 const (
 	// Client APIs
-	ClientGetTicket = "/client/getticket"
+	ClientGetTicket           = "/client/getticket"
+	ClientGetTicketByIdentity = "/client/getticketbyidentity"
+
+	// Service APIs
+	ServiceGetRevocations = "/service/getrevocations"
+	ServiceGetSecretKey   = "/service/getsecretkey"
 
 	// Admin APIs
 	AdminCreateKey  = "/admin/createkey"
@@ -62,6 +67,8 @@ const (
 	AdminAddCaps    = "/admin/addcaps"
 	AdminDeleteCaps = "/admin/deletecaps"
 	AdminGetCaps    = "/admin/getcaps"
+	AdminIssueCert  = "/admin/issuecert"
+	AdminRevokeKey  = "/admin/revokekey"
 
 	//raft node APIs
 	AdminAddRaftNode    = "/admin/addraftnode"
@@ -85,12 +92,17 @@ const (
 
 	// DataServiceID defines ticket for datanode access (not supported)
 	DataServiceID = "DatanodeService"
+
+	// ObjectServiceID defines the service ID of the objectnode, which gets the secret keys of the
+	// access keys to verify the S3 signatures
+	ObjectServiceID = "ObjectnodeService"
 )
 
 const (
 	MasterNode = "master"
 	MetaNode   = "metanode"
 	DataNode   = "datanode"
+	ObjectNode = "objectnode"
 )
 
 const (
@@ -169,6 +181,36 @@ const (
 	// MsgAuthRemoveRaftNodeResp response type for authnode remove node
 	MsgAuthRemoveRaftNodeResp MsgType = MsgAuthBase + 0x58001
 
+	// MsgAuthIssueCertReq request type from admin to issue a TLS certificate
+	MsgAuthIssueCertReq MsgType = MsgAuthBase + 0x59000
+
+	// MsgAuthIssueCertResp response type from authnode with the issued TLS certificate
+	MsgAuthIssueCertResp MsgType = MsgAuthBase + 0x59001
+
+	// MsgAuthRevokeKeyReq request type from admin to rotate the key of a client and revoke its tickets
+	MsgAuthRevokeKeyReq MsgType = MsgAuthBase + 0x5a000
+
+	// MsgAuthRevokeKeyResp response type for authnode revoke key
+	MsgAuthRevokeKeyResp MsgType = MsgAuthBase + 0x5a001
+
+	// MsgAuthRevocationsReq request type from a service to get the revocation list
+	MsgAuthRevocationsReq MsgType = MsgAuthBase + 0x5b000
+
+	// MsgAuthRevocationsResp response type for authnode get revocation list
+	MsgAuthRevocationsResp MsgType = MsgAuthBase + 0x5b001
+
+	// MsgAuthIdentityTicketReq request type for a ticket with the identity of an LDAP or Kerberos principal
+	MsgAuthIdentityTicketReq MsgType = MsgAuthBase + 0x5c000
+
+	// MsgAuthIdentityTicketResp response type for a ticket with the identity of a principal
+	MsgAuthIdentityTicketResp MsgType = MsgAuthBase + 0x5c001
+
+	// MsgAuthSecretKeyReq request type from the objectnode to get the secret key of an access key
+	MsgAuthSecretKeyReq MsgType = MsgAuthBase + 0x5d000
+
+	// MsgAuthSecretKeyResp response type for authnode get secret key
+	MsgAuthSecretKeyResp MsgType = MsgAuthBase + 0x5d001
+
 	// MsgAuthOSAddCapsReq request type from ObjectNode to add caps
 	MsgAuthOSAddCapsReq MsgType = MsgAuthBase + 0x61000
 
@@ -214,6 +256,8 @@ var MsgType2ResourceMap = map[MsgType]string{
 	MsgAuthGetCapsReq:        "auth:getcaps",
 	MsgAuthAddRaftNodeReq:    "auth:addnode",
 	MsgAuthRemoveRaftNodeReq: "auth:removenode",
+	MsgAuthIssueCertReq:      "auth:issuecert",
+	MsgAuthRevokeKeyReq:      "auth:revokekey",
 	MsgAuthOSAddCapsReq:      "auth:osaddcaps",
 	MsgAuthOSDeleteCapsReq:   "auth:osdeletecaps",
 	MsgAuthOSGetCapsReq:      "auth:osgetcaps",
@@ -231,6 +275,17 @@ type AuthGetTicketReq struct {
 	Verifier  string  `json:"verifier"`
 }
 
+// AuthIdentityTicketReq defines the message from client to authnode for a ticket with the identity
+// of a principal authenticated by the password. The verifier and the response are encrypted by the
+// identity key derived from the password, while the password relies on HTTPS.
+type AuthIdentityTicketReq struct {
+	Type      MsgType `json:"type"`
+	Principal string  `json:"principal"`
+	Password  string  `json:"password"`
+	ServiceID string  `json:"service_id"`
+	Verifier  string  `json:"verifier"`
+}
+
 // AuthGetTicketResp defines the message from authnode to client
 type AuthGetTicketResp struct {
 	Type       MsgType              `json:"type"`
@@ -239,6 +294,7 @@ type AuthGetTicketResp struct {
 	Verifier   int64                `json:"verifier"`
 	Ticket     string               `json:"ticket"`
 	SessionKey cryptoutil.CryptoKey `json:"session_key"`
+	Exp        int64                `json:"exp,omitempty"`
 }
 
 // APIAccessReq defines the request for access restful api
@@ -304,9 +360,64 @@ type AuthOSAccessKeyResp struct {
 	AKCaps  keystore.AccessKeyCaps `json:"access_key_caps"`
 }
 
+// AuthIssueCertReq defines Auth API request to issue the TLS certificate of a node or client
+type AuthIssueCertReq struct {
+	APIReq APIAccessReq `json:"api_req"`
+	CSR    []byte       `json:"csr"` // PEM certificate signing request
+}
+
+// AuthIssueCertResp defines the response with the issued TLS certificate and the CA issuing it
+type AuthIssueCertResp struct {
+	APIResp APIAccessResp `json:"api_resp"`
+	Cert    []byte        `json:"cert"`
+	CACert  []byte        `json:"ca_cert"`
+}
+
+// AuthRevocationsReq defines the request from a service to get the revocation list
+// use Timestamp encrypted by the service key as verifier
+type AuthRevocationsReq struct {
+	Type      MsgType `json:"type"`
+	ServiceID string  `json:"service_id"`
+	Verifier  string  `json:"verifier"`
+}
+
+// AuthRevocationsResp defines the revocation list from authnode to service
+type AuthRevocationsResp struct {
+	Type        MsgType      `json:"type"`
+	ServiceID   string       `json:"service_id"`
+	Verifier    int64        `json:"verifier"`
+	Revocations []Revocation `json:"revocations"`
+}
+
+// Revocation revokes the tickets of a client issued no later than RevokeTs
+type Revocation struct {
+	ClientID string `json:"client_id"`
+	RevokeTs int64  `json:"revoke_ts"`
+}
+
+// AuthSecretKeyReq defines the request from the objectnode to get the secret key of an access key
+// use Timestamp encrypted by the service key as verifier
+type AuthSecretKeyReq struct {
+	Type      MsgType `json:"type"`
+	ServiceID string  `json:"service_id"`
+	Verifier  string  `json:"verifier"`
+	AccessKey string  `json:"access_key"`
+}
+
+// AuthSecretKeyResp defines the secret key and the caps of the access key from authnode to the objectnode
+type AuthSecretKeyResp struct {
+	Type      MsgType `json:"type"`
+	ServiceID string  `json:"service_id"`
+	Verifier  int64   `json:"verifier"`
+	AccessKey string  `json:"access_key"`
+	SecretKey string  `json:"secret_key"`
+	Caps      []byte  `json:"caps"`
+}
+
 // IsValidServiceID determine the validity of a serviceID
 func IsValidServiceID(serviceID string) (err error) {
-	if serviceID != AuthServiceID && serviceID != MasterServiceID && serviceID != MetaServiceID && serviceID != DataServiceID {
+	if serviceID != AuthServiceID && serviceID != MasterServiceID && serviceID != MetaServiceID && serviceID != DataServiceID &&
+		serviceID != ObjectServiceID {
 		err = fmt.Errorf("invalid service ID [%s]", serviceID)
 		return
 	}
@@ -435,6 +546,67 @@ func ParseAuthOSAKResp(body []byte, key []byte) (resp AuthOSAccessKeyResp, err e
 	return
 }
 
+// ParseAuthIssueCertResp parse and validate the auth issue cert resp
+func ParseAuthIssueCertResp(body []byte, key []byte) (resp AuthIssueCertResp, err error) {
+	var (
+		plaintext []byte
+	)
+
+	if plaintext, err = GetDataFromResp(body, key); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(plaintext, &resp); err != nil {
+		return
+	}
+
+	return
+}
+
+// ParseAuthRevocationsResp parse and validate the auth revocation list resp
+func ParseAuthRevocationsResp(body []byte, key []byte, serviceID string, ts int64) (resp AuthRevocationsResp, err error) {
+	var (
+		plaintext []byte
+	)
+
+	if plaintext, err = GetDataFromResp(body, key); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(plaintext, &resp); err != nil {
+		return
+	}
+
+	if resp.Type != MsgAuthRevocationsResp || resp.ServiceID != serviceID || resp.Verifier != ts+1 {
+		err = fmt.Errorf("revocations verification failed")
+		return
+	}
+
+	return
+}
+
+// ParseAuthSecretKeyResp parse and validate the auth secret key resp
+func ParseAuthSecretKeyResp(body []byte, key []byte, serviceID string, ts int64) (resp AuthSecretKeyResp, err error) {
+	var (
+		plaintext []byte
+	)
+
+	if plaintext, err = GetDataFromResp(body, key); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(plaintext, &resp); err != nil {
+		return
+	}
+
+	if resp.Type != MsgAuthSecretKeyResp || resp.ServiceID != serviceID || resp.Verifier != ts+1 {
+		err = fmt.Errorf("secret key verification failed")
+		return
+	}
+
+	return
+}
+
 func ExtractTicket(str string, key []byte) (ticket cryptoutil.Ticket, err error) {
 	var (
 		plaintext []byte
@@ -0,0 +1,53 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DeleteTreePrefix is the prefix of the names of the directories detached to be deleted with
+// their subtrees by the meta nodes. The dentries are kept under their parents until the subtrees
+// are deleted, named after the time of the detaching and the original names like the ones in the
+// trash.
+const DeleteTreePrefix = ".Deleting/"
+
+// DeleteTreeName returns the name of the directory detached at the time.
+func DeleteTreeName(name string, markTime int64) string {
+	return DeleteTreePrefix + strconv.FormatInt(markTime, 10) + "/" + name
+}
+
+// IsDeleteTreeName tells whether the dentry of the name is detached to be deleted.
+func IsDeleteTreeName(name string) bool {
+	return strings.HasPrefix(name, DeleteTreePrefix)
+}
+
+// ParseDeleteTreeName returns the original name and the time in unix nanoseconds of the detached
+// directory.
+func ParseDeleteTreeName(markName string) (name string, markTime int64, ok bool) {
+	if !IsDeleteTreeName(markName) {
+		return
+	}
+	parts := strings.SplitN(markName[len(DeleteTreePrefix):], "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return
+	}
+	var err error
+	if markTime, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return
+	}
+	return parts[1], markTime, true
+}
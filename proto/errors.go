@@ -63,7 +63,16 @@ var (
 	ErrAccessKeyNotExists              = errors.New("access key not exists")
 	ErrInvalidTicket                   = errors.New("invalid ticket")
 	ErrExpiredTicket                   = errors.New("expired ticket")
+	ErrRevokedTicket                   = errors.New("revoked ticket")
+	ErrIdentityAuthFailed              = errors.New("identity authentication failed")
+	ErrIdentityNotMapped               = errors.New("identity not mapped to any user")
+	ErrRateLimited                     = errors.New("rate limited, try again later")
 	ErrMasterAPIGenRespError           = errors.New("master API generate response error")
+
+	// the reasons why the completion of a multipart upload is refused
+	ErrInvalidPartOrder = errors.New("the part numbers are not in ascending order")
+	ErrInvalidPart      = errors.New("the part is not found or its entity tag is mismatched")
+	ErrEntityTooSmall   = errors.New("the part is smaller than the minimum allowed size")
 )
 
 // http response error code and error message definitions
@@ -110,6 +119,8 @@ const (
 	ErrCodeInvalidTicket
 	ErrCodeExpiredTicket
 	ErrCodeMasterAPIGenRespError
+	ErrCodeIdentityAuthFailed
+	ErrCodeRateLimited
 )
 
 // Err2CodeMap error map to code
@@ -153,5 +164,9 @@ var Err2CodeMap = map[error]int32{
 	ErrAccessKeyNotExists:              ErrCodeAccessKeyNotExists,
 	ErrInvalidTicket:                   ErrCodeInvalidTicket,
 	ErrExpiredTicket:                   ErrCodeExpiredTicket,
+	ErrRevokedTicket:                   ErrCodeInvalidTicket,
+	ErrIdentityAuthFailed:              ErrCodeIdentityAuthFailed,
+	ErrIdentityNotMapped:               ErrCodeIdentityAuthFailed,
+	ErrRateLimited:                     ErrCodeRateLimited,
 	ErrMasterAPIGenRespError:           ErrCodeMasterAPIGenRespError,
 }
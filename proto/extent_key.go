@@ -125,3 +125,13 @@ type TinyExtentDeleteRecord struct {
 	CRC                  uint32
 	TinyDeleteFileOffset int64
 }
+
+// CopyExtentRequest is the data of the packet to copy the data of an extent key to a new extent,
+// which is read by each replica of the new extent from the hosts of the source data partition.
+type CopyExtentRequest struct {
+	PartitionId  uint64   `json:"pid"`
+	ExtentId     uint64   `json:"eid"`
+	ExtentOffset uint64   `json:"eoff"`
+	Size         uint32   `json:"size"`
+	Hosts        []string `json:"hosts"`
+}
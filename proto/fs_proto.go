@@ -17,6 +17,7 @@ package proto
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -91,6 +92,33 @@ func (info XAttrInfo) String() string {
 	return fmt.Sprintf("XAttrInfo{Inode(%v), XAttrs(%v)}", info.Inode, builder.String())
 }
 
+// QuotaXAttrKey is the extended attribute tagging an inode with the IDs of the quotas it is counted in.
+const QuotaXAttrKey = "cfs.quota"
+
+// FormatQuotaIDs returns the value of the quota tag of the IDs.
+func FormatQuotaIDs(ids []uint32) string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(values, ",")
+}
+
+// ParseQuotaIDs returns the IDs of the value of the quota tag.
+func ParseQuotaIDs(value string) (ids []uint32, err error) {
+	if value == "" {
+		return
+	}
+	for _, field := range strings.Split(value, ",") {
+		var id uint64
+		if id, err = strconv.ParseUint(field, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid quota tag[%v]", value)
+		}
+		ids = append(ids, uint32(id))
+	}
+	return
+}
+
 // Dentry defines the dentry struct.
 type Dentry struct {
 	Name  string `json:"name"`
@@ -105,12 +133,13 @@ func (d Dentry) String() string {
 
 // CreateInodeRequest defines the request to create an inode.
 type CreateInodeRequest struct {
-	VolName     string `json:"vol"`
-	PartitionID uint64 `json:"pid"`
-	Mode        uint32 `json:"mode"`
-	Uid         uint32 `json:"uid"`
-	Gid         uint32 `json:"gid"`
-	Target      []byte `json:"tgt"`
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Mode        uint32   `json:"mode"`
+	Uid         uint32   `json:"uid"`
+	Gid         uint32   `json:"gid"`
+	Target      []byte   `json:"tgt"`
+	QuotaIds    []uint32 `json:"qids,omitempty"`
 }
 
 // CreateInodeResponse defines the response to the request of creating an inode.
@@ -192,6 +221,7 @@ type LookupRequest struct {
 	PartitionID uint64 `json:"pid"`
 	ParentID    uint64 `json:"pino"`
 	Name        string `json:"name"`
+	SnapshotID  uint32 `json:"snapshot,omitempty"`
 }
 
 // LookupResponse defines the response for the loopup request.
@@ -205,6 +235,7 @@ type InodeGetRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	SnapshotID  uint32 `json:"snapshot,omitempty"`
 }
 
 // InodeGetResponse defines the response to the InodeGetRequest.
@@ -217,6 +248,7 @@ type BatchInodeGetRequest struct {
 	VolName     string   `json:"vol"`
 	PartitionID uint64   `json:"pid"`
 	Inodes      []uint64 `json:"inos"`
+	SnapshotID  uint32   `json:"snapshot,omitempty"`
 }
 
 // BatchInodeGetResponse defines the response to the request of getting the inode in batch.
@@ -229,6 +261,7 @@ type ReadDirRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	ParentID    uint64 `json:"pino"`
+	SnapshotID  uint32 `json:"snapshot,omitempty"`
 }
 
 // ReadDirResponse defines the response to the request of reading dir.
@@ -249,6 +282,7 @@ type GetExtentsRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	SnapshotID  uint32 `json:"snapshot,omitempty"`
 }
 
 // GetExtentsResponse defines the response to the request of getting extents.
@@ -296,6 +330,23 @@ type AppendExtentKeysRequest struct {
 	PartitionId uint64      `json:"pid"`
 	Inode       uint64      `json:"ino"`
 	Extents     []ExtentKey `json:"eks"`
+	// the extent keys are only appended if the generation of the inode is still this one, if not 0
+	Generation uint64 `json:"gen,omitempty"`
+}
+
+// CloneExtentsRequest defines the request to create an inode with the extent keys copied from another file.
+type CloneExtentsRequest struct {
+	VolName     string      `json:"vol"`
+	PartitionID uint64      `json:"pid"`
+	Mode        uint32      `json:"mode"`
+	Uid         uint32      `json:"uid"`
+	Gid         uint32      `json:"gid"`
+	Extents     []ExtentKey `json:"eks"`
+}
+
+// CloneExtentsResponse defines the response to the request of cloning the extent keys.
+type CloneExtentsResponse struct {
+	Info *InodeInfo `json:"info"`
 }
 
 type SetXAttrRequest struct {
@@ -341,6 +392,189 @@ type ListXAttrResponse struct {
 	XAttr       map[string]string `json:"xattr"`
 }
 
+// SetACLRequest defines the request to set the access or default ACL of the inode, which is
+// removed if the ACL is empty. The access ACL sets the permission bits of the mode as well.
+type SetACLRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Default     bool   `json:"default"`
+	ACL         ACL    `json:"acl"`
+}
+
+type GetACLRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+}
+
+// GetACLResponse defines the response of the ACLs with the inode, either of which is empty if
+// it is not set.
+type GetACLResponse struct {
+	Info    *InodeInfo `json:"info"`
+	Access  ACL        `json:"access"`
+	Default ACL        `json:"default"`
+}
+
+// SetLockRequest defines the request to acquire or release a byte-range lock of the inode, which
+// fails with OpExistErr if a lock of another owner conflicts.
+type SetLockRequest struct {
+	VolName     string    `json:"vol"`
+	PartitionID uint64    `json:"pid"`
+	Inode       uint64    `json:"ino"`
+	Lock        RangeLock `json:"lock"`
+}
+
+// GetLockRequest defines the request to find a lock of another owner conflicting with the lock.
+type GetLockRequest struct {
+	VolName     string    `json:"vol"`
+	PartitionID uint64    `json:"pid"`
+	Inode       uint64    `json:"ino"`
+	Lock        RangeLock `json:"lock"`
+}
+
+// GetLockResponse defines the response of the conflicting lock, which is nil if there is none.
+type GetLockResponse struct {
+	Lock *RangeLock `json:"lock"`
+}
+
+// RenewLocksRequest defines the request to renew the leases of the locks of the client in the partition.
+type RenewLocksRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Client      uint64 `json:"client"`
+}
+
+// RenewLocksResponse defines the response of the number of the locks the client still holds in the partition.
+type RenewLocksResponse struct {
+	Count int `json:"count"`
+}
+
+// ReverseLookupRequest defines the request to find the dentries of the partition referring to the inode.
+type ReverseLookupRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+}
+
+// InodeLink defines a dentry referring to an inode, which is in the trash if the name is a trash name.
+type InodeLink struct {
+	ParentID uint64 `json:"pino"`
+	Name     string `json:"name"`
+	Type     uint32 `json:"type"`
+	Trash    bool   `json:"trash"`
+}
+
+type ReverseLookupResponse struct {
+	Links []*InodeLink `json:"links"`
+}
+
+// ListDentryRequest defines the request to list a page of the dentries of the directory in the
+// name order, which are after the marker and begin with the prefix. The names containing the
+// delimiter after the prefix are rolled up into the common prefixes ending with the delimiter.
+type ListDentryRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Prefix      string `json:"pf"`
+	Delimiter   string `json:"dm"`
+	Marker      string `json:"mk"`
+	Limit       uint64 `json:"max"`
+}
+
+// ListDentryResponse defines the response of the dentries and the common prefixes of the page,
+// both counted by the limit. The next marker is the last name or common prefix of the page.
+type ListDentryResponse struct {
+	Children       []Dentry `json:"children"`
+	CommonPrefixes []string `json:"prefixes"`
+	NextMarker     string   `json:"nmk"`
+	IsTruncated    bool     `json:"trunc"`
+}
+
+// TrashDentryRequest defines the request to move the dentry into the trash instead of deleting it.
+type TrashDentryRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Name        string `json:"name"`
+}
+
+type TrashDentryResponse struct {
+	Inode uint64 `json:"ino"`
+}
+
+type ListTrashRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+}
+
+// TrashItem defines a dentry in the trash, whose trash name is used to restore it.
+type TrashItem struct {
+	ParentID   uint64 `json:"pino"`
+	Name       string `json:"name"`
+	TrashName  string `json:"trashName"`
+	Inode      uint64 `json:"ino"`
+	Type       uint32 `json:"type"`
+	DeleteTime int64  `json:"deleteTime"`
+}
+
+type ListTrashResponse struct {
+	Items []*TrashItem `json:"items"`
+}
+
+// RestoreTrashRequest defines the request to move the dentry in the trash back under its parent,
+// named after the original name if the name is empty.
+type RestoreTrashRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	TrashName   string `json:"trashName"`
+	Name        string `json:"name"`
+}
+
+// DeleteTreeRequest defines the request to detach the directory, whose subtree is deleted by the
+// meta node of the parent in the background.
+type DeleteTreeRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	ParentID    uint64 `json:"pino"`
+	Name        string `json:"name"`
+}
+
+type DeleteTreeResponse struct {
+	Inode uint64 `json:"ino"`
+}
+
+type ListDeleteTreesRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+}
+
+// DeleteTreeJob defines a detached directory whose subtree is being deleted. The progress is
+// counted by the leader of the partition since it started the job, and the error is the last one
+// of the job, which is retried later.
+type DeleteTreeJob struct {
+	ParentID     uint64 `json:"pino"`
+	Name         string `json:"name"`
+	MarkName     string `json:"markName"`
+	Inode        uint64 `json:"ino"`
+	MarkTime     int64  `json:"markTime"`
+	DeletedFiles uint64 `json:"deletedFiles"`
+	DeletedDirs  uint64 `json:"deletedDirs"`
+	Error        string `json:"error"`
+}
+
+type ListDeleteTreesResponse struct {
+	Jobs []*DeleteTreeJob `json:"jobs"`
+}
+
+type BatchSetXAttrRequest struct {
+	VolName     string            `json:"vol"`
+	PartitionId uint64            `json:"pid"`
+	Inode       uint64            `json:"ino"`
+	Attrs       map[string]string `json:"attrs"`
+}
+
 type BatchGetXAttrRequest struct {
 	VolName     string   `json:"vol"`
 	PartitionId uint64   `json:"pid"`
@@ -359,6 +593,7 @@ type MultipartInfo struct {
 	Path     string               `json:"path"`
 	InitTime time.Time            `json:"itime"`
 	Parts    []*MultipartPartInfo `json:"parts"`
+	Extend   map[string]string    `json:"extend,omitempty"`
 }
 
 type MultipartPartInfo struct {
@@ -370,9 +605,10 @@ type MultipartPartInfo struct {
 }
 
 type CreateMultipartRequest struct {
-	VolName     string `json:"vol"`
-	PartitionId uint64 `json:"pid"`
-	Path        string `json:"path"`
+	VolName     string            `json:"vol"`
+	PartitionId uint64            `json:"pid"`
+	Path        string            `json:"path"`
+	Extend      map[string]string `json:"extend,omitempty"`
 }
 
 type CreateMultipartResponse struct {
@@ -383,10 +619,16 @@ type GetMultipartRequest struct {
 	VolName     string `json:"vol"`
 	PartitionId uint64 `json:"pid"`
 	MultipartId string `json:"mid"`
+	// MaxParts limits the parts returned after the part number marker, all the parts are
+	// returned if it is zero.
+	MaxParts         uint64 `json:"mp,omitempty"`
+	PartNumberMarker uint16 `json:"pnm,omitempty"`
 }
 
 type GetMultipartResponse struct {
-	Info *MultipartInfo `json:"info"`
+	Info                 *MultipartInfo `json:"info"`
+	IsTruncated          bool           `json:"trunc,omitempty"`
+	NextPartNumberMarker uint16         `json:"npnm,omitempty"`
 }
 
 type AddMultipartPartRequest struct {
@@ -402,6 +644,27 @@ type RemoveMultipartRequest struct {
 	MultipartId string `json:"mid"`
 }
 
+// CompleteMultipartRequest completes the multipart upload with the parts in order, whose file is
+// created with the extent keys and the extended attributes, and linked to the name in the parent.
+type CompleteMultipartRequest struct {
+	VolName     string               `json:"vol"`
+	PartitionId uint64               `json:"pid"`
+	MultipartId string               `json:"mid"`
+	ParentId    uint64               `json:"pino"`
+	Name        string               `json:"name"`
+	Mode        uint32               `json:"mode"`
+	Parts       []*MultipartPartInfo `json:"parts"`
+	Extents     []ExtentKey          `json:"eks"`
+	Extend      map[string]string    `json:"extend,omitempty"`
+	ETagKey     string               `json:"etagKey,omitempty"` // the extended attribute keeping the entity tag
+}
+
+type CompleteMultipartResponse struct {
+	Info     *InodeInfo `json:"info"`
+	ETag     string     `json:"etag"`
+	OldInode uint64     `json:"oino"` // the inode the name is linked to before, 0 if none
+}
+
 type ListMultipartRequest struct {
 	VolName           string `json:"vol"`
 	PartitionId       uint64 `json:"pid"`
@@ -415,3 +678,30 @@ type ListMultipartRequest struct {
 type ListMultipartResponse struct {
 	Multiparts []*MultipartInfo `json:"mps"`
 }
+
+// MetaBatchMaxOps is the maximum number of the ops carried by an OpMetaBatch packet.
+const MetaBatchMaxOps = 64
+
+// MetaBatchOp is an op carried by an OpMetaBatch packet, whose data is the request of the op.
+type MetaBatchOp struct {
+	Opcode uint8  `json:"op"`
+	Data   []byte `json:"data"`
+}
+
+// MetaBatchRequest defines the request carrying the independent ops on the same meta partition.
+type MetaBatchRequest struct {
+	VolName     string         `json:"vol"`
+	PartitionID uint64         `json:"pid"`
+	Ops         []*MetaBatchOp `json:"ops"`
+}
+
+// MetaBatchResult is the result code and the reply data of an op of the batch.
+type MetaBatchResult struct {
+	ResultCode uint8  `json:"code"`
+	Data       []byte `json:"data"`
+}
+
+// MetaBatchResponse defines the response to the batch, whose results are in the order of the ops.
+type MetaBatchResponse struct {
+	Results []*MetaBatchResult `json:"results"`
+}
@@ -0,0 +1,197 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"context"
+	"hash/crc32"
+	"net"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/grpc"
+)
+
+// The gRPC services defined by chubaofs.proto.
+const (
+	GRPCMetaNodeService = "chubaofs.MetaNode"
+	GRPCDataNodeService = "chubaofs.DataNode"
+)
+
+// MarshalGRPC marshals the packet as the message Packet.
+func (p *Packet) MarshalGRPC() []byte {
+	e := &grpc.Encoder{}
+	e.Uint64(1, uint64(p.Opcode))
+	e.Uint64(2, uint64(p.ResultCode))
+	e.Uint64(3, p.PartitionID)
+	e.Uint64(4, p.ExtentID)
+	e.Int64(5, p.ExtentOffset)
+	e.Uint64(6, p.KernelOffset)
+	e.Uint64(7, uint64(p.ExtentType))
+	e.Uint64(8, uint64(p.RemainingFollowers))
+	e.Uint64(9, uint64(p.CRC))
+	e.Uint64(10, uint64(p.Size))
+	e.Int64(11, p.ReqID)
+	e.Bytes(12, p.Arg[:p.ArgLen])
+	if len(p.Data) >= int(p.Size) {
+		e.Bytes(13, p.Data[:p.Size])
+	}
+	return e.Data()
+}
+
+// UnmarshalGRPC unmarshals the message Packet as a request, whose request ID and CRC are
+// generated if they are not given.
+func (p *Packet) UnmarshalGRPC(data []byte) (err error) {
+	if err = grpc.Decode(data, func(f *grpc.Field) error {
+		switch f.Number {
+		case 1:
+			p.Opcode = uint8(f.Varint)
+		case 2:
+			p.ResultCode = uint8(f.Varint)
+		case 3:
+			p.PartitionID = f.Varint
+		case 4:
+			p.ExtentID = f.Varint
+		case 5:
+			p.ExtentOffset = int64(f.Varint)
+		case 6:
+			p.KernelOffset = f.Varint
+		case 7:
+			p.ExtentType = uint8(f.Varint)
+		case 8:
+			p.RemainingFollowers = uint8(f.Varint)
+		case 9:
+			p.CRC = uint32(f.Varint)
+		case 10:
+			p.Size = uint32(f.Varint)
+		case 11:
+			p.ReqID = int64(f.Varint)
+		case 12:
+			p.Arg = f.Bytes
+		case 13:
+			p.Data = f.Bytes
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	p.ArgLen = uint32(len(p.Arg))
+	if len(p.Data) > 0 {
+		p.Size = uint32(len(p.Data))
+		if p.CRC == 0 {
+			p.CRC = crc32.ChecksumIEEE(p.Data)
+		}
+	}
+	if p.ReqID == 0 {
+		p.ReqID = GenerateRequestID()
+	}
+	return
+}
+
+func (p *Packet) isReadOperation() bool {
+	return p.Opcode == OpRead || p.Opcode == OpStreamRead || p.Opcode == OpExtentRepairRead ||
+		p.Opcode == OpStreamFollowerRead || p.Opcode == OpECReadShard
+}
+
+// GRPCStatus returns the status of the result code of the reply, or nil if it succeeds.
+func (p *Packet) GRPCStatus() error {
+	var code grpc.Code
+	switch p.ResultCode {
+	case OpOk:
+		return nil
+	case OpNotExistErr:
+		code = grpc.NotFound
+	case OpExistErr:
+		code = grpc.AlreadyExists
+	case OpArgMismatchErr:
+		code = grpc.InvalidArgument
+	case OpNotPerm:
+		code = grpc.PermissionDenied
+	case OpDiskNoSpaceErr, OpInodeFullErr:
+		code = grpc.ResourceExhausted
+	case OpAgain, OpTryOtherAddr, OpIntraGroupNetErr:
+		code = grpc.Unavailable
+	case OpNotEmtpy, OpExtentFrozenErr:
+		code = grpc.FailedPrecondition
+	default:
+		code = grpc.Internal
+	}
+	var msg string
+	if len(p.Data) >= int(p.Size) {
+		msg = string(p.Data[:p.Size])
+	}
+	return grpc.Errorf(code, "%v: %v", p.GetResultMsg(), msg)
+}
+
+// GRPCGateway forwards the gRPC calls to the packet server of the node as packets.
+type GRPCGateway struct {
+	addr string
+	pool *util.ConnectPool
+}
+
+// NewGRPCGateway returns the gateway to the packet server of the address.
+func NewGRPCGateway(addr string) *GRPCGateway {
+	return &GRPCGateway{addr: addr, pool: util.NewConnectPool()}
+}
+
+// Forward sends the request packet to the packet server, and reads the replies by the function.
+// The connection is aborted once the context is done, e.g. the call is canceled.
+func (g *GRPCGateway) Forward(ctx context.Context, p *Packet, replyFunc func(conn net.Conn) error) (err error) {
+	conn, err := g.pool.GetConnect(g.addr)
+	if err != nil {
+		return grpc.Errorf(grpc.Unavailable, "connect %v: %v", g.addr, err)
+	}
+	doneC := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-doneC:
+		}
+	}()
+	if err = p.WriteToConn(conn); err == nil {
+		err = replyFunc(conn)
+	}
+	close(doneC)
+	g.pool.PutConnect(conn, err != nil)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// Call serves the method Call, which replies the reply packet of the request packet, whatever its
+// result code is.
+func (g *GRPCGateway) Call(ctx context.Context, req []byte) (resp []byte, err error) {
+	p := NewPacket()
+	if err = p.UnmarshalGRPC(req); err != nil {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "%v", err)
+	}
+	if p.isReadOperation() {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "read operation %v unsupported by Call", p.GetOpMsg())
+	}
+	reply := NewPacket()
+	if err = g.Forward(ctx, p, func(conn net.Conn) error {
+		return reply.ReadFromConn(conn, ReadDeadlineTime)
+	}); err != nil {
+		return
+	}
+	if reply.ReqID != p.ReqID {
+		return nil, grpc.Errorf(grpc.Internal, "reply ReqID(%v) mismatches request ReqID(%v)", reply.ReqID, p.ReqID)
+	}
+	resp = reply.MarshalGRPC()
+	Buffers.Put(reply.Data)
+	return
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"time"
+)
+
+// The types of the byte-range locks.
+const (
+	LockRead uint32 = iota
+	LockWrite
+	LockUnlock
+)
+
+// LockLease is how long a byte-range lock is kept without being renewed, so that the locks of
+// the clients crashed are released. The clients renew their locks every third of the lease.
+const LockLease = 30 * time.Second
+
+// RangeLock is an advisory byte-range lock of an inode from Start to End inclusive, as fcntl,
+// which is held by a lock owner of a client until it is released or its lease expires.
+type RangeLock struct {
+	Start  uint64 `json:"start"`
+	End    uint64 `json:"end"`
+	Type   uint32 `json:"type"`
+	Client uint64 `json:"client"`
+	Owner  uint64 `json:"owner"`
+	Pid    uint32 `json:"pid"`
+	Expire int64  `json:"expire"`
+}
+
+// SameOwner tells whether the locks are of the same lock owner of the same client.
+func (l *RangeLock) SameOwner(o *RangeLock) bool {
+	return l.Client == o.Client && l.Owner == o.Owner
+}
+
+// Overlaps tells whether the ranges of the locks overlap.
+func (l *RangeLock) Overlaps(o *RangeLock) bool {
+	return l.Start <= o.End && o.Start <= l.End
+}
+
+// Conflicts tells whether the locks of different owners overlap and either of them is a write lock.
+func (l *RangeLock) Conflicts(o *RangeLock) bool {
+	return !l.SameOwner(o) && l.Overlaps(o) && (l.Type == LockWrite || o.Type == LockWrite)
+}
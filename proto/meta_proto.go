@@ -27,8 +27,9 @@ type CreateNameSpaceResponse struct {
 
 // Peer defines the peer of the node id and address.
 type Peer struct {
-	ID   uint64 `json:"id"`
-	Addr string `json:"addr"`
+	ID      uint64 `json:"id"`
+	Addr    string `json:"addr"`
+	Learner bool   `json:"learner,omitempty"` // non-voting member catching up before the promotion
 }
 
 // CreateMetaPartitionRequest defines the request to create a meta partition.
@@ -39,6 +40,19 @@ type CreateMetaPartitionRequest struct {
 	End         uint64
 	PartitionID uint64
 	Members     []Peer
+	StoreMode   string
+}
+
+// The store modes of the meta partitions.
+// In the rocksdb mode the metadata is persisted on RocksDB and the memory only caches a part of the inodes.
+const (
+	StoreModeMem     = "mem"
+	StoreModeRocksDB = "rocksdb"
+)
+
+// IsValidStoreMode returns if the given store mode is supported. The empty mode means the memory mode.
+func IsValidStoreMode(mode string) bool {
+	return mode == "" || mode == StoreModeMem || mode == StoreModeRocksDB
 }
 
 // CreateMetaPartitionResponse defines the response to the request of creating a meta partition.
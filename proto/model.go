@@ -24,6 +24,8 @@ type MetaNodeInfo struct {
 	Addr                      string
 	IsActive                  bool
 	CellName                  string `json:"Cell"`
+	ZoneName                  string `json:"Zone"`
+	RackName                  string `json:"Rack"`
 	MaxMemAvailWeight         uint64 `json:"MaxMemAvailWeight"`
 	Total                     uint64 `json:"TotalWeight"`
 	Used                      uint64 `json:"UsedWeight"`
@@ -35,6 +37,8 @@ type MetaNodeInfo struct {
 	MetaPartitionCount        int
 	NodeSetID                 uint64
 	PersistenceMetaPartitions []uint64
+	ProtocolVersion           uint32
+	MinClientVersion          uint32
 }
 
 // DataNode stores all the information about a data node
@@ -55,6 +59,36 @@ type DataNodeInfo struct {
 	NodeSetID                 uint64
 	PersistenceDataPartitions []uint64
 	BadDisks                  []string
+	ProtocolVersion           uint32
+	MinClientVersion          uint32
+	MediaType                 string
+	ZoneName                  string `json:"Zone"`
+	RackName                  string `json:"Rack"`
+}
+
+// ECNodeInfo defines the information of an ec node.
+type ECNodeInfo struct {
+	ID               uint64
+	Addr             string
+	ReportTime       time.Time
+	IsActive         bool
+	Total            uint64
+	Used             uint64
+	AvailableSpace   uint64
+	PartitionReports []*ECPartitionReport
+}
+
+// ECPartitionInfo defines the information of an erasure coded partition.
+type ECPartitionInfo struct {
+	PartitionID  uint64
+	VolName      string
+	DataShards   int
+	ParityShards int
+	StripeUnit   uint32
+	Hosts        []string
+	ExtentCount  int
+	Size         uint64
+	MigrateTime  int64
 }
 
 // MetaPartition defines the structure of a meta partition
@@ -79,6 +113,7 @@ type MetaReplicaInfo struct {
 	ReportTime int64
 	Status     int8 // unavailable, readOnly, readWrite
 	IsLeader   bool
+	RaftHealth *RaftHealth `json:",omitempty"`
 }
 
 // ClusterView provides the view of a cluster.
@@ -86,6 +121,7 @@ type ClusterView struct {
 	Name                string
 	LeaderAddr          string
 	DisableAutoAlloc    bool
+	BalancePaused       bool
 	MetaNodeThreshold   float32
 	Applied             uint64
 	MaxDataPartitionID  uint64
@@ -141,6 +177,7 @@ type DataPartitionInfo struct {
 	VolID                   uint64
 	FileInCoreMap           map[string]*FileInCore
 	FilesWithMissingReplica map[string]int64 // key: file name, value: last time when a missing replica is found
+	Releasing               bool             // read-only until deleted, once the volume is shrunk
 }
 
 //FileInCore define file in data partition
@@ -163,10 +200,11 @@ type DataReplica struct {
 	ReportTime      int64
 	FileCount       uint32
 	Status          int8
-	HasLoadResponse bool // if there is any response when loading
+	HasLoadResponse bool   // if there is any response when loading
 	Total           uint64 `json:"TotalSize"`
 	Used            uint64 `json:"UsedSize"`
 	IsLeader        bool
 	NeedsToCompare  bool
 	DiskPath        string
+	RaftHealth      *RaftHealth `json:",omitempty"`
 }
@@ -15,48 +15,82 @@ const (
 	WarnLogDir   = "warnLogDir"
 	Authenticate = "authenticate"
 	// Optional
-	LogLevel      = "logLevel"
-	ProfPort      = "profPort"
-	IcacheTimeout = "icacheTimeout"
-	LookupValid   = "lookupValid"
-	AttrValid     = "attrValid"
-	ReadRate      = "readRate"
-	WriteRate     = "writeRate"
-	EnSyncWrite   = "enSyncWrite"
-	AutoInvalData = "autoInvalData"
-	Rdonly        = "rdonly"
-	WriteCache    = "writecache"
-	KeepCache     = "keepcache"
-	FollowerRead  = "followerRead"
-	CertFile      = "certFile"
-	ClientKey     = "clientKey"
-	TicketHost    = "ticketHost"
-	EnableHTTPS   = "enableHTTPS"
+	LogLevel        = "logLevel"
+	LogFormat       = "logFormat"
+	LogRemote       = "logRemote"
+	LogRemoteBuf    = "logRemoteBufferSize"
+	ProfPort        = "profPort"
+	IcacheTimeout   = "icacheTimeout"
+	LookupValid     = "lookupValid"
+	AttrValid       = "attrValid"
+	ReadRate        = "readRate"
+	WriteRate       = "writeRate"
+	EnSyncWrite     = "enSyncWrite"
+	AutoInvalData   = "autoInvalData"
+	Rdonly          = "rdonly"
+	WriteCache      = "writecache"
+	KeepCache       = "keepcache"
+	FollowerRead    = "followerRead"
+	CompressReply   = "compressReply"
+	IntegrityDigest = "integrityDigest"
+	ReadCacheDir    = "readCacheDir"
+	ReadCacheSize   = "readCacheSize"
+	ReadCacheExpire = "readCacheExpiration"
+	WriteBack       = "writeBack"
+	WriteBackDirty  = "writeBackDirtySize"
+	WriteBackDelay  = "writeBackDelay"
+	ReadAheadMax    = "readAheadMax"
+	ReadAheadTotal  = "readAheadTotalSize"
+	CertFile        = "certFile"
+	ClientKey       = "clientKey"
+	TicketHost      = "ticketHost"
+	EnableHTTPS     = "enableHTTPS"
+	Principal       = "principal"
+	PasswordFile    = "passwordFile"
+	EnablePosixACL  = "enablePosixACL"
+	EnablePosixLock = "enablePosixLock"
+	Snapshot        = "snapshot"
 
 	ListenPort = "listen"
 )
 
 type MountOptions struct {
-	Config        *config.Config
-	MountPoint    string
-	Volname       string
-	Owner         string
-	Master        string
-	Logpath       string
-	Loglvl        string
-	Profport      string
-	IcacheTimeout int64
-	LookupValid   int64
-	AttrValid     int64
-	ReadRate      int64
-	WriteRate     int64
-	EnSyncWrite   int64
-	AutoInvalData int64
-	UmpDatadir    string
-	Rdonly        bool
-	WriteCache    bool
-	KeepCache     bool
-	FollowerRead  bool
-	Authenticate  bool
-	TicketMess    auth.TicketMess
+	Config          *config.Config
+	MountPoint      string
+	Volname         string
+	Owner           string
+	Master          string
+	Logpath         string
+	Loglvl          string
+	Logfmt          string
+	LogRemote       string
+	LogRemoteBuf    int64
+	Profport        string
+	IcacheTimeout   int64
+	LookupValid     int64
+	AttrValid       int64
+	ReadRate        int64
+	WriteRate       int64
+	EnSyncWrite     int64
+	AutoInvalData   int64
+	UmpDatadir      string
+	Rdonly          bool
+	WriteCache      bool
+	KeepCache       bool
+	FollowerRead    bool
+	CompressReply   bool
+	IntegrityDigest bool
+	ReadCacheDir    string
+	ReadCacheSize   int64 // MB
+	ReadCacheExpire int64 // seconds
+	WriteBack       bool
+	WriteBackDirty  int64 // MB
+	WriteBackDelay  int64 // milliseconds
+	ReadAheadMax    int64 // MB, negative if disabled
+	ReadAheadTotal  int64 // MB
+	Authenticate    bool
+	EnablePosixACL  bool
+	EnablePosixLock bool
+	Snapshot        string
+	TicketMess      auth.TicketMess
 }
@@ -25,8 +25,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/bkaradzic/go-lz4"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/buf"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 var (
@@ -66,6 +68,16 @@ const (
 	OpReadTinyDeleteRecord           uint8 = 0x14
 	OpTinyExtentRepairRead           uint8 = 0x15
 	OpGetMaxExtentIDAndPartitionSize uint8 = 0x16
+	OpCopyExtent                     uint8 = 0x17
+
+	// Operations: the shards of the erasure coded partitions between the ec nodes
+	OpECWriteShard      uint8 = 0x18
+	OpECReadShard       uint8 = 0x19
+	OpECCommitPartition uint8 = 0x1A
+
+	// Operations: the ec nodes -> the replicas of the data partitions being migrated
+	OpECSealDataPartition   uint8 = 0x1B
+	OpECUnsealDataPartition uint8 = 0x1C
 
 	// Operations: Client -> MetaNode.
 	OpMetaCreateInode   uint8 = 0x20
@@ -97,38 +109,73 @@ const (
 	OpMetaRemoveXAttr     uint8 = 0x37
 	OpMetaListXAttr       uint8 = 0x38
 	OpMetaBatchGetXAttr   uint8 = 0x39
+	OpMetaBatch           uint8 = 0x3A // independent ops on the same partition in one packet
+	OpMetaCloneExtents    uint8 = 0x3B // create an inode with the extents copied from another file
+	OpMetaSetACL          uint8 = 0x3C
+	OpMetaGetACL          uint8 = 0x3D
+	OpMetaBatchSetXAttr   uint8 = 0x3E // set the xattrs of an inode in one raft round-trip
 
 	// Operations: Master -> MetaNode
-	OpCreateMetaPartition           uint8 = 0x40
-	OpMetaNodeHeartbeat             uint8 = 0x41
-	OpDeleteMetaPartition           uint8 = 0x42
-	OpUpdateMetaPartition           uint8 = 0x43
-	OpLoadMetaPartition             uint8 = 0x44
-	OpDecommissionMetaPartition     uint8 = 0x45
-	OpAddMetaPartitionRaftMember    uint8 = 0x46
-	OpRemoveMetaPartitionRaftMember uint8 = 0x47
-	OpMetaPartitionTryToLeader      uint8 = 0x48
+	OpCreateMetaPartition             uint8 = 0x40
+	OpMetaNodeHeartbeat               uint8 = 0x41
+	OpDeleteMetaPartition             uint8 = 0x42
+	OpUpdateMetaPartition             uint8 = 0x43
+	OpLoadMetaPartition               uint8 = 0x44
+	OpDecommissionMetaPartition       uint8 = 0x45
+	OpAddMetaPartitionRaftMember      uint8 = 0x46
+	OpRemoveMetaPartitionRaftMember   uint8 = 0x47
+	OpMetaPartitionTryToLeader        uint8 = 0x48
+	OpAddMetaPartitionRaftLearner     uint8 = 0x49
+	OpPromoteMetaPartitionRaftLearner uint8 = 0x4A
 
 	// Operations: Master -> DataNode
-	OpCreateDataPartition           uint8 = 0x60
-	OpDeleteDataPartition           uint8 = 0x61
-	OpLoadDataPartition             uint8 = 0x62
-	OpDataNodeHeartbeat             uint8 = 0x63
-	OpReplicateFile                 uint8 = 0x64
-	OpDeleteFile                    uint8 = 0x65
-	OpDecommissionDataPartition     uint8 = 0x66
-	OpAddDataPartitionRaftMember    uint8 = 0x67
-	OpRemoveDataPartitionRaftMember uint8 = 0x68
-	OpDataPartitionTryToLeader      uint8 = 0x69
+	OpCreateDataPartition             uint8 = 0x60
+	OpDeleteDataPartition             uint8 = 0x61
+	OpLoadDataPartition               uint8 = 0x62
+	OpDataNodeHeartbeat               uint8 = 0x63
+	OpReplicateFile                   uint8 = 0x64
+	OpDeleteFile                      uint8 = 0x65
+	OpDecommissionDataPartition       uint8 = 0x66
+	OpAddDataPartitionRaftMember      uint8 = 0x67
+	OpRemoveDataPartitionRaftMember   uint8 = 0x68
+	OpDataPartitionTryToLeader        uint8 = 0x69
+	OpAddDataPartitionRaftLearner     uint8 = 0x6C
+	OpPromoteDataPartitionRaftLearner uint8 = 0x6D
+
+	// Operations: Master -> EcNode
+	OpECNodeHeartbeat        uint8 = 0x6A
+	OpECMigrateDataPartition uint8 = 0x6B
 
 	// Operations: MultipartInfo
-	OpCreateMultipart  uint8 = 0x70
-	OpGetMultipart     uint8 = 0x71
-	OpAddMultipartPart uint8 = 0x72
-	OpRemoveMultipart  uint8 = 0x73
-	OpListMultiparts   uint8 = 0x74
+	OpCreateMultipart   uint8 = 0x70
+	OpGetMultipart      uint8 = 0x71
+	OpAddMultipartPart  uint8 = 0x72
+	OpRemoveMultipart   uint8 = 0x73
+	OpListMultiparts    uint8 = 0x74
+	OpCompleteMultipart uint8 = 0x75
+
+	// Operations: Subtree deletion
+	OpMetaDeleteTree      uint8 = 0x76
+	OpMetaListDeleteTrees uint8 = 0x77
+
+	// Operations: Trash
+	OpMetaTrashDentry  uint8 = 0x78
+	OpMetaListTrash    uint8 = 0x79
+	OpMetaRestoreTrash uint8 = 0x7A
+
+	// Operations: Byte-range locks
+	OpMetaSetLock    uint8 = 0x7B
+	OpMetaGetLock    uint8 = 0x7C
+	OpMetaRenewLocks uint8 = 0x7D
+
+	// Operations: Reverse lookup
+	OpMetaReverseLookup uint8 = 0x7E
+
+	// Operations: Dentry listing
+	OpMetaListDentry uint8 = 0x7F
 
 	// Commons
+	OpExtentFrozenErr  uint8 = 0xF2
 	OpIntraGroupNetErr uint8 = 0xF3
 	OpArgMismatchErr   uint8 = 0xF4
 	OpNotExistErr      uint8 = 0xF5
@@ -144,6 +191,9 @@ const (
 	OpOk               uint8 = 0xF0
 
 	OpPing uint8 = 0xFF
+
+	// OpProtoHandshake exchanges the protocol versions and the capabilities of a client and a node.
+	OpProtoHandshake uint8 = 0xEF
 )
 
 const (
@@ -153,6 +203,7 @@ const (
 	NoReadDeadlineTime       = -1
 
 	GetAllWatermarksDeadLineTime = 60
+	CopyExtentDeadLineTime       = 120
 )
 
 const (
@@ -160,6 +211,29 @@ const (
 	NormalExtentType = 1
 )
 
+// PacketFlagTrace is set in the extent type byte of the header when the packet carries a trace context.
+// The trace context is marshaled right after the header, before the argument.
+// Tracing must only be enabled on the clients once all the nodes understand this flag.
+const (
+	PacketFlagTrace uint8 = 0x80
+)
+
+// PacketFlagCompress is set in the extent type byte of the header of a request if the client accepts
+// a compressed reply, and of a reply if its data is compressed. The compressed data starts with the
+// byte of the codec. Clients must only accept compressed replies from the metanodes announcing
+// CapCompressLZ4 in the handshake, as the former ones return this flag unchanged.
+const (
+	PacketFlagCompress uint8 = 0x40
+)
+
+// The codecs of the compressed data of the packets.
+const (
+	CompressionLZ4 uint8 = 1
+)
+
+// CompressMinSize is the size of the smallest reply data worth compressing.
+const CompressMinSize = 1024
+
 const (
 	NormalCreateDataPartition         = 0
 	DecommissionedCreateDataPartition = 1
@@ -185,6 +259,9 @@ type Packet struct {
 	StartT             int64
 	mesg               string
 	HasPrepare         bool
+	Trace              *tracing.SpanContext
+	AcceptCompression  bool // the request accepts a compressed reply
+	Compressed         bool // the data of the reply is compressed
 }
 
 // NewPacket returns a new packet.
@@ -318,10 +395,28 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpReadTinyDeleteRecord"
 	case OpPing:
 		m = "OpPing"
+	case OpProtoHandshake:
+		m = "OpProtoHandshake"
 	case OpTinyExtentRepairRead:
 		m = "OpTinyExtentRepairRead"
 	case OpGetMaxExtentIDAndPartitionSize:
 		m = "OpGetMaxExtentIDAndPartitionSize"
+	case OpCopyExtent:
+		m = "OpCopyExtent"
+	case OpECWriteShard:
+		m = "OpECWriteShard"
+	case OpECReadShard:
+		m = "OpECReadShard"
+	case OpECCommitPartition:
+		m = "OpECCommitPartition"
+	case OpECSealDataPartition:
+		m = "OpECSealDataPartition"
+	case OpECUnsealDataPartition:
+		m = "OpECUnsealDataPartition"
+	case OpECNodeHeartbeat:
+		m = "OpECNodeHeartbeat"
+	case OpECMigrateDataPartition:
+		m = "OpECMigrateDataPartition"
 	case OpBroadcastMinAppliedID:
 		m = "OpBroadcastMinAppliedID"
 	case OpRemoveDataPartitionRaftMember:
@@ -336,6 +431,14 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaPartitionTryToLeader"
 	case OpDataPartitionTryToLeader:
 		m = "OpDataPartitionTryToLeader"
+	case OpAddMetaPartitionRaftLearner:
+		m = "OpAddMetaPartitionRaftLearner"
+	case OpPromoteMetaPartitionRaftLearner:
+		m = "OpPromoteMetaPartitionRaftLearner"
+	case OpAddDataPartitionRaftLearner:
+		m = "OpAddDataPartitionRaftLearner"
+	case OpPromoteDataPartitionRaftLearner:
+		m = "OpPromoteDataPartitionRaftLearner"
 	case OpMetaDeleteInode:
 		m = "OpMetaDeleteInode"
 	case OpMetaBatchExtentsAdd:
@@ -350,6 +453,16 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaListXAttr"
 	case OpMetaBatchGetXAttr:
 		m = "OpMetaBatchGetXAttr"
+	case OpMetaBatch:
+		m = "OpMetaBatch"
+	case OpMetaCloneExtents:
+		m = "OpMetaCloneExtents"
+	case OpMetaSetACL:
+		m = "OpMetaSetACL"
+	case OpMetaGetACL:
+		m = "OpMetaGetACL"
+	case OpMetaBatchSetXAttr:
+		m = "OpMetaBatchSetXAttr"
 	case OpCreateMultipart:
 		m = "OpCreateMultipart"
 	case OpGetMultipart:
@@ -360,6 +473,28 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpRemoveMultipart"
 	case OpListMultiparts:
 		m = "OpListMultiparts"
+	case OpCompleteMultipart:
+		m = "OpCompleteMultipart"
+	case OpMetaTrashDentry:
+		m = "OpMetaTrashDentry"
+	case OpMetaListTrash:
+		m = "OpMetaListTrash"
+	case OpMetaRestoreTrash:
+		m = "OpMetaRestoreTrash"
+	case OpMetaSetLock:
+		m = "OpMetaSetLock"
+	case OpMetaGetLock:
+		m = "OpMetaGetLock"
+	case OpMetaRenewLocks:
+		m = "OpMetaRenewLocks"
+	case OpMetaReverseLookup:
+		m = "OpMetaReverseLookup"
+	case OpMetaListDentry:
+		m = "OpMetaListDentry"
+	case OpMetaDeleteTree:
+		m = "OpMetaDeleteTree"
+	case OpMetaListDeleteTrees:
+		m = "OpMetaListDeleteTrees"
 	}
 	return
 }
@@ -397,6 +532,8 @@ func (p *Packet) GetResultMsg() (m string) {
 		m = "NotPerm"
 	case OpNotEmtpy:
 		m = "DirNotEmpty"
+	case OpExtentFrozenErr:
+		m = "ExtentFrozenErr"
 	default:
 		return fmt.Sprintf("Unknown ResultCode(%v)", p.ResultCode)
 	}
@@ -411,6 +548,12 @@ func (p *Packet) GetReqID() int64 {
 func (p *Packet) MarshalHeader(out []byte) {
 	out[0] = p.Magic
 	out[1] = p.ExtentType
+	if p.Trace != nil {
+		out[1] |= PacketFlagTrace
+	}
+	if (p.ResultCode == OpInitResultCode && p.AcceptCompression) || (p.ResultCode != OpInitResultCode && p.Compressed) {
+		out[1] |= PacketFlagCompress
+	}
 	out[2] = p.Opcode
 	out[3] = p.ResultCode
 	out[4] = p.RemainingFollowers
@@ -432,9 +575,17 @@ func (p *Packet) UnmarshalHeader(in []byte) error {
 		return errors.New("Bad Magic " + strconv.Itoa(int(p.Magic)))
 	}
 
-	p.ExtentType = in[1]
+	p.ExtentType = in[1] &^ (PacketFlagTrace | PacketFlagCompress)
+	if in[1]&PacketFlagTrace != 0 {
+		p.Trace = new(tracing.SpanContext)
+	} else {
+		p.Trace = nil
+	}
 	p.Opcode = in[2]
 	p.ResultCode = in[3]
+	compress := in[1]&PacketFlagCompress != 0
+	p.AcceptCompression = compress && p.ResultCode == OpInitResultCode
+	p.Compressed = compress && p.ResultCode != OpInitResultCode
 	p.RemainingFollowers = in[4]
 	p.CRC = binary.BigEndian.Uint32(in[5:9])
 	p.Size = binary.BigEndian.Uint32(in[9:13])
@@ -473,6 +624,9 @@ func (p *Packet) WriteToNoDeadLineConn(c net.Conn) (err error) {
 
 	p.MarshalHeader(header)
 	if _, err = c.Write(header); err == nil {
+		if err = p.writeTraceContext(c); err != nil {
+			return
+		}
 		if _, err = c.Write(p.Arg[:int(p.ArgLen)]); err == nil {
 			if p.Data != nil {
 				_, err = c.Write(p.Data[:p.Size])
@@ -485,6 +639,15 @@ func (p *Packet) WriteToNoDeadLineConn(c net.Conn) (err error) {
 
 // WriteToConn writes through the given connection.
 func (p *Packet) WriteToConn(c net.Conn) (err error) {
+	if err = p.WriteHeaderToConn(c); err == nil && p.Data != nil && p.Size != 0 {
+		_, err = c.Write(p.Data[:p.Size])
+	}
+	return
+}
+
+// WriteHeaderToConn writes the packet except the data through the given connection, the data of
+// the size is written by the caller then, e.g. by sendfile(2) from a file.
+func (p *Packet) WriteHeaderToConn(c net.Conn) (err error) {
 	c.SetWriteDeadline(time.Now().Add(WriteDeadlineTime * time.Second))
 	header, err := Buffers.Get(util.PacketHeaderSize)
 	if err != nil {
@@ -494,11 +657,10 @@ func (p *Packet) WriteToConn(c net.Conn) (err error) {
 
 	p.MarshalHeader(header)
 	if _, err = c.Write(header); err == nil {
-		if _, err = c.Write(p.Arg[:int(p.ArgLen)]); err == nil {
-			if p.Data != nil && p.Size != 0 {
-				_, err = c.Write(p.Data[:p.Size])
-			}
+		if err = p.writeTraceContext(c); err != nil {
+			return
 		}
+		_, err = c.Write(p.Arg[:int(p.ArgLen)])
 	}
 
 	return
@@ -529,6 +691,9 @@ func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
 	if err = p.UnmarshalHeader(header); err != nil {
 		return
 	}
+	if err = p.ReadTraceContext(c); err != nil {
+		return
+	}
 
 	if p.ArgLen > 0 {
 		p.Arg = make([]byte, int(p.ArgLen))
@@ -541,14 +706,82 @@ func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
 		return
 	}
 	size := p.Size
-	if (p.Opcode == OpRead || p.Opcode == OpStreamRead || p.Opcode == OpExtentRepairRead || p.Opcode == OpStreamFollowerRead) && p.ResultCode == OpInitResultCode {
+	if (p.Opcode == OpRead || p.Opcode == OpStreamRead || p.Opcode == OpExtentRepairRead || p.Opcode == OpStreamFollowerRead ||
+		p.Opcode == OpECReadShard) && p.ResultCode == OpInitResultCode {
 		size = 0
 	}
-	p.Data = make([]byte, size)
+	// the data may be put back to Buffers once it is not referenced
+	if p.Data, err = Buffers.Get(int(size)); err != nil {
+		return
+	}
 	_, err = io.ReadFull(c, p.Data[:size])
 	return err
 }
 
+func (p *Packet) writeTraceContext(c io.Writer) (err error) {
+	if p.Trace == nil {
+		return
+	}
+	var buf [tracing.SpanContextSize]byte
+	p.Trace.Marshal(buf[:])
+	_, err = c.Write(buf[:])
+	return
+}
+
+// ReadTraceContext reads the trace context following the header if the header says there is one.
+// It must be called right after UnmarshalHeader.
+func (p *Packet) ReadTraceContext(c io.Reader) (err error) {
+	if p.Trace == nil {
+		return
+	}
+	var buf [tracing.SpanContextSize]byte
+	if _, err = io.ReadFull(c, buf[:]); err != nil {
+		return
+	}
+	p.Trace.Unmarshal(buf[:])
+	return
+}
+
+// CompressData compresses the data of the reply if the request accepts a compressed reply and the
+// data is large enough to be worth it. It must be called right before writing the reply.
+func (p *Packet) CompressData() {
+	accept := p.AcceptCompression
+	p.AcceptCompression = false
+	if !accept || p.Compressed || p.Size < CompressMinSize {
+		return
+	}
+	data, err := lz4.Encode(nil, p.Data[:p.Size])
+	if err != nil || len(data)+1 >= int(p.Size) {
+		return
+	}
+	p.Data = append([]byte{CompressionLZ4}, data...)
+	p.Size = uint32(len(p.Data))
+	p.Compressed = true
+}
+
+// DecompressData decompresses the data of the reply if it is compressed.
+func (p *Packet) DecompressData() (err error) {
+	if !p.Compressed {
+		return
+	}
+	if p.Size == 0 {
+		return errors.New("compressed packet without data")
+	}
+	switch p.Data[0] {
+	case CompressionLZ4:
+		var data []byte
+		if data, err = lz4.Decode(nil, p.Data[1:p.Size]); err != nil {
+			return
+		}
+		p.Data = data
+		p.Size = uint32(len(data))
+	default:
+		return fmt.Errorf("unknown packet compression %v", p.Data[0])
+	}
+	p.Compressed = false
+	return
+}
+
 // PacketOkReply sets the result code as OpOk, and sets the body as empty.
 func (p *Packet) PacketOkReply() {
 	p.ResultCode = OpOk
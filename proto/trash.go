@@ -0,0 +1,52 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TrashPrefix is the prefix of the names of the dentries moved into the trash. The dentries are
+// kept under their parents, named after the deletion time and the original names, which never
+// conflict with the names of the files since they contain the slashes.
+const TrashPrefix = ".Trash/"
+
+// TrashName returns the name of the dentry moved into the trash at the deletion time.
+func TrashName(name string, deleteTime int64) string {
+	return TrashPrefix + strconv.FormatInt(deleteTime, 10) + "/" + name
+}
+
+// IsTrashName tells whether the dentry of the name is in the trash.
+func IsTrashName(name string) bool {
+	return strings.HasPrefix(name, TrashPrefix)
+}
+
+// ParseTrashName returns the original name and the deletion time in unix nanoseconds of the
+// dentry in the trash.
+func ParseTrashName(trashName string) (name string, deleteTime int64, ok bool) {
+	if !IsTrashName(trashName) {
+		return
+	}
+	parts := strings.SplitN(trashName[len(TrashPrefix):], "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return
+	}
+	var err error
+	if deleteTime, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return
+	}
+	return parts[1], deleteTime, true
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion is the version of the protocol spoken by this build. It is raised whenever a
+// new op or encoding is introduced, so that the peers only use what both sides understand.
+//
+//	1: the nodes and clients before the handshake
+//	2: the handshake and the lz4 compressed replies
+//	3: the batched meta ops
+//	4: the batched xattr sets
+const ProtocolVersion uint32 = 4
+
+// The capabilities announced in the handshake.
+const (
+	CapCompressLZ4 uint64 = 1 << iota
+	CapMetaBatch
+	CapMetaBatchSetXAttr
+)
+
+// Capabilities is the set of the capabilities of this build.
+const Capabilities = CapCompressLZ4 | CapMetaBatch | CapMetaBatchSetXAttr
+
+// HandshakeRequest is sent by a client in the data of an OpProtoHandshake packet.
+type HandshakeRequest struct {
+	Version      uint32
+	Capabilities uint64
+}
+
+// HandshakeResponse is replied by a node to an OpProtoHandshake packet.
+type HandshakeResponse struct {
+	Version          uint32
+	Capabilities     uint64
+	MinClientVersion uint32
+}
+
+// Supports returns true if the peer announced the capability.
+func (resp *HandshakeResponse) Supports(capability uint64) bool {
+	return resp.Capabilities&capability == capability
+}
+
+// CheckClientVersion returns an error if this build is older than the minimum client version.
+func CheckClientVersion(minClientVersion uint32) error {
+	if ProtocolVersion < minClientVersion {
+		return fmt.Errorf("protocol version %v is older than the minimum client version %v, please upgrade the client",
+			ProtocolVersion, minClientVersion)
+	}
+	return nil
+}
+
+// NewHandshakePacket returns the packet announcing the version and the capabilities of this build.
+func NewHandshakePacket() *Packet {
+	p := NewPacketReqID()
+	p.Opcode = OpProtoHandshake
+	p.Data, _ = json.Marshal(&HandshakeRequest{Version: ProtocolVersion, Capabilities: Capabilities})
+	p.Size = uint32(len(p.Data))
+	return p
+}
+
+// PacketHandshakeReply replies to the handshake of a client. The clients older than the minimum
+// client version are rejected with OpNotPerm. A malformed request is taken as sent by a client of
+// the first version.
+func (p *Packet) PacketHandshakeReply(minClientVersion uint32) {
+	req := &HandshakeRequest{Version: 1}
+	if len(p.Data) > 0 {
+		if err := json.Unmarshal(p.Data[:p.Size], req); err != nil {
+			req.Version = 1
+		}
+	}
+	if req.Version < minClientVersion {
+		p.PacketErrorWithBody(OpNotPerm, []byte(fmt.Sprintf("client version %v is older than the minimum client version %v",
+			req.Version, minClientVersion)))
+		return
+	}
+	reply, _ := json.Marshal(&HandshakeResponse{
+		Version:          ProtocolVersion,
+		Capabilities:     Capabilities,
+		MinClientVersion: minClientVersion,
+	})
+	p.PacketOkWithBody(reply)
+}
+
+// UnmarshalHandshakeReply returns the version and the capabilities of the node from its reply.
+func (p *Packet) UnmarshalHandshakeReply() (resp *HandshakeResponse, err error) {
+	if p.ResultCode != OpOk {
+		return nil, fmt.Errorf("handshake failed: %v %v", p.GetResultMsg(), string(p.Data[:p.Size]))
+	}
+	resp = new(HandshakeResponse)
+	if err = json.Unmarshal(p.Data[:p.Size], resp); err != nil {
+		return nil, err
+	}
+	return
+}
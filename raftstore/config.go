@@ -47,6 +47,42 @@ type Config struct {
 	// We suggest to use ElectionTick = 10 * HeartbeatTick to avoid unnecessary leader switching.
 	// The default value is 1s.
 	ElectionTick int
+
+	// PreVote enables the pre-election before an election, so a partitioned or restarting replica
+	// does not force an election on a healthy group. It must be enabled only after all the nodes
+	// of the cluster are upgraded to support it.
+	PreVote bool
+
+	// WalDir is the directory of the wals of all the partitions, usually on a dedicated low latency
+	// device. The wal of a partition found in its former path is moved there when the partition
+	// starts. The default value is empty, i.e. keeping the wals in the path chosen by the node.
+	WalDir string
+
+	// WalCompression is the compression of the raft log entries in the wal, "lz4" or empty for none.
+	// The compressed entries, like the ones of the recycled wal files, are not readable by the nodes
+	// not upgraded to support them.
+	WalCompression string
+
+	// WalPreAllocate allocates the blocks of a wal file up to its size when creating it.
+	WalPreAllocate bool
+
+	// WalRecycleFiles is the number of the truncated wal files of a partition kept to be overwritten
+	// by the new ones instead of being removed. The default value is 0, i.e. not recycling.
+	WalRecycleFiles int
+
+	// SnapshotRate limits the MB per second of the snapshots sent by the node, and of the ones
+	// received, separately. The default value is 0, i.e. unlimited.
+	SnapshotRate int
+
+	// SnapshotResumeTimeout is the seconds a snapshot transfer broken by a network error waits to
+	// be resumed from the first chunk not received, instead of restarting from the beginning.
+	// It must be enabled only after all the nodes of the cluster are upgraded to support it.
+	SnapshotResumeTimeout int
+
+	// SendLinger is the microseconds the replication sender to a node waits for more messages of
+	// the raft groups before writing a batch that is not full, to send fewer packets for the many
+	// partitions sharing the node. The default value is 0, i.e. not waiting.
+	SendLinger int
 }
 
 // PeerAddress defines the set of addresses that will be used by the peers.
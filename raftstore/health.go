@@ -0,0 +1,107 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/metrics"
+)
+
+// The metrics of the raft groups, labeled with the partition ID.
+const (
+	MetricRaftCommitLatency    = "raft_commit_latency_seconds"
+	MetricRaftApplyLag         = "raft_apply_lag"
+	MetricRaftPendingProposals = "raft_pending_proposals"
+	MetricRaftLeaderChanges    = "raft_leader_changes_total"
+	MetricRaftElections        = "raft_elections_total"
+)
+
+const healthExportInterval = 10 * time.Second
+
+// NewRaftHealth returns the health of a raft group computed from its status.
+func NewRaftHealth(status *PartitionStatus) *proto.RaftHealth {
+	health := &proto.RaftHealth{
+		CommitLatency:    int64(status.CommitLatency / time.Microsecond),
+		PendingProposals: status.PendQueue,
+		LeaderChanges:    status.LeaderChanges,
+		Elections:        status.Elections,
+	}
+	if status.Commit > status.Applied {
+		health.ApplyLag = status.Commit - status.Applied
+	}
+	return health
+}
+
+// exportHealth exports the health of the raft groups every interval until the raft store stops.
+// The partitions map keeps the last exported health of every group, from which the counters
+// are increased, and the groups removed from the raft server are deleted from the metrics.
+func (s *raftStore) exportHealth() {
+	ticker := time.NewTicker(healthExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopC:
+			return
+		case <-ticker.C:
+		}
+		if !metrics.Enabled() {
+			continue
+		}
+		cluster := metrics.Cluster()
+		commitLatency := metrics.GaugeVec(MetricRaftCommitLatency,
+			"Moving average of the commit latency of the proposals on the leader in seconds.", metrics.LabelPartition)
+		applyLag := metrics.GaugeVec(MetricRaftApplyLag,
+			"Number of the entries committed but not applied.", metrics.LabelPartition)
+		pendingProposals := metrics.GaugeVec(MetricRaftPendingProposals,
+			"Number of the proposals waiting to be committed.", metrics.LabelPartition)
+		leaderChanges := metrics.CounterVec(MetricRaftLeaderChanges,
+			"Number of the leaders elected or changed.", metrics.LabelPartition)
+		elections := metrics.CounterVec(MetricRaftElections,
+			"Number of the elections started by the node.", metrics.LabelPartition)
+		s.partitions.Range(func(key, value interface{}) bool {
+			id := key.(uint64)
+			pid := strconv.FormatUint(id, 10)
+			status := s.raftServer.Status(id)
+			if status.Stopped {
+				s.partitions.Delete(id)
+				commitLatency.DeleteLabelValues(cluster, pid)
+				applyLag.DeleteLabelValues(cluster, pid)
+				pendingProposals.DeleteLabelValues(cluster, pid)
+				leaderChanges.DeleteLabelValues(cluster, pid)
+				elections.DeleteLabelValues(cluster, pid)
+				return true
+			}
+			health := NewRaftHealth(status)
+			last, _ := value.(*proto.RaftHealth)
+			if last == nil {
+				last = &proto.RaftHealth{}
+			}
+			commitLatency.WithLabelValues(cluster, pid).Set(status.CommitLatency.Seconds())
+			applyLag.WithLabelValues(cluster, pid).Set(float64(health.ApplyLag))
+			pendingProposals.WithLabelValues(cluster, pid).Set(float64(health.PendingProposals))
+			if health.LeaderChanges >= last.LeaderChanges {
+				leaderChanges.WithLabelValues(cluster, pid).Add(float64(health.LeaderChanges - last.LeaderChanges))
+			}
+			if health.Elections >= last.Elections {
+				elections.WithLabelValues(cluster, pid).Add(float64(health.Elections - last.Elections))
+			}
+			s.partitions.Store(id, health)
+			return true
+		})
+	}
+}
@@ -15,9 +15,20 @@
 package raftstore
 
 import (
+	"errors"
+	"os"
+
 	"github.com/tiglabs/raft"
 	"github.com/tiglabs/raft/proto"
-	"os"
+)
+
+// LearnerMaxLag is the max number of the raft log entries a learner can fall behind the commit
+// of the leader to be promoted to a voter.
+const LearnerMaxLag = 1000
+
+var (
+	ErrNotLearner         = errors.New("not a learner of the raft group")
+	ErrLearnerNotCaughtUp = errors.New("learner not caught up")
 )
 
 // PartitionStatus is a type alias of raft.Status
@@ -69,6 +80,10 @@ type Partition interface {
 	TryToLeader(nodeID uint64) error
 
 	IsOfflinePeer() bool
+
+	// CheckLearnerCaughtUp returns nil if the learner is active and replicates the raft log
+	// within the max lag of the commit, which must be checked on the leader.
+	CheckLearnerCaughtUp(nodeID uint64) error
 }
 
 // Default implementation of the Partition interface.
@@ -129,6 +144,9 @@ func (p *partition) IsOfflinePeer() bool {
 	active := 0
 	sumPeers := 0
 	for _, peer := range status.Replicas {
+		if peer.Learner {
+			continue
+		}
 		if peer.Active == true {
 			active++
 		}
@@ -138,6 +156,23 @@ func (p *partition) IsOfflinePeer() bool {
 	return active >= (int(sumPeers)/2 + 1)
 }
 
+// CheckLearnerCaughtUp returns nil if the learner is active and replicates the raft log
+// within the max lag of the commit.
+func (p *partition) CheckLearnerCaughtUp(nodeID uint64) (err error) {
+	if !p.IsRaftLeader() {
+		return raft.ErrNotLeader
+	}
+	status := p.Status()
+	replica, ok := status.Replicas[nodeID]
+	if !ok || !replica.Learner {
+		return ErrNotLearner
+	}
+	if !replica.Active || replica.Match+LearnerMaxLag < status.Commit {
+		return ErrLearnerNotCaughtUp
+	}
+	return nil
+}
+
 // IsRaftLeader returns true if this node is the leader of the raft group it belongs to.
 func (p *partition) IsRaftLeader() (isLeader bool) {
 	isLeader = p.raft != nil && p.raft.IsLeader(p.id)
@@ -16,14 +16,19 @@ package raftstore
 
 import (
 	"fmt"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/fault"
+	"github.com/chubaofs/chubaofs/util/mtls"
 	"github.com/tiglabs/raft"
 	"github.com/tiglabs/raft/logger"
 	"github.com/tiglabs/raft/proto"
 	"github.com/tiglabs/raft/storage/wal"
 	raftlog "github.com/tiglabs/raft/util/log"
+	"net"
 	"os"
 	"path"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -43,6 +48,11 @@ type raftStore struct {
 	raftConfig *raft.Config
 	raftServer *raft.RaftServer
 	raftPath   string
+	walDir     string
+	walConfig  *wal.Config
+	partitions sync.Map // the last exported health of the created partitions, by their IDs
+	stopC      chan struct{}
+	stopOnce   sync.Once
 }
 
 // RaftConfig returns the raft configuration.
@@ -66,6 +76,7 @@ func (s *raftStore) DeleteNode(nodeID uint64) {
 
 // Stop stops the raft store server.
 func (s *raftStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stopC) })
 	if s.raftServer != nil {
 		s.raftServer.Stop()
 	}
@@ -122,17 +133,45 @@ func NewRaftStore(cfg *Config) (mr RaftStore, err error) {
 	rc.RetainLogs = cfg.NumOfLogsToRetain
 	rc.TickInterval = time.Duration(cfg.TickInterval) * time.Millisecond
 	rc.ElectionTick = cfg.ElectionTick
+	rc.PreVote = cfg.PreVote
+	rc.SnapshotRate = cfg.SnapshotRate * util.MB
+	rc.SnapshotResumeTimeout = time.Duration(cfg.SnapshotResumeTimeout) * time.Second
+	rc.SendLinger = time.Duration(cfg.SendLinger) * time.Microsecond
+	if mtls.Enabled() {
+		rc.SecureConn = secureConn
+	}
+	wc := &wal.Config{
+		PreAllocate:  cfg.WalPreAllocate,
+		RecycleFiles: cfg.WalRecycleFiles,
+	}
+	switch cfg.WalCompression {
+	case "", wal.CompressionNone.String():
+	case wal.CompressionLZ4.String():
+		wc.Compression = wal.CompressionLZ4
+	default:
+		return nil, fmt.Errorf("unknown wal compression %v", cfg.WalCompression)
+	}
 	rs, err := raft.NewRaftServer(rc)
 	if err != nil {
 		return
 	}
-	mr = &raftStore{
+	if fault.Enabled {
+		raft.SetSendFilter(func(m *proto.Message) bool {
+			return fault.Inject(fault.RaftSendTo(m.To), fault.PointRaftSend) != fault.ErrDropped
+		})
+	}
+	store := &raftStore{
 		nodeID:     cfg.NodeID,
 		resolver:   resolver,
 		raftConfig: rc,
 		raftServer: rs,
 		raftPath:   cfg.RaftPath,
+		walDir:     cfg.WalDir,
+		walConfig:  wc,
+		stopC:      make(chan struct{}),
 	}
+	go store.exportHealth()
+	mr = store
 	return
 }
 
@@ -144,7 +183,6 @@ func (s *raftStore) RaftServer() *raft.RaftServer {
 func (s *raftStore) CreatePartition(cfg *PartitionConfig) (p Partition, err error) {
 	// Init WaL Storage for this partition.
 	// Variables:
-	// wc: WaL Configuration.
 	// wp: WaL Path.
 	// ws: WaL Storage.
 	var walPath string
@@ -153,9 +191,19 @@ func (s *raftStore) CreatePartition(cfg *PartitionConfig) (p Partition, err erro
 	} else {
 		walPath = path.Join(cfg.WalPath, "wal_"+strconv.FormatUint(cfg.ID, 10))
 	}
+	if s.walDir != "" {
+		newPath := WalDirPath(s.walDir, cfg.ID)
+		var migrated bool
+		if migrated, err = MigrateWal(walPath, newPath); err != nil {
+			return
+		}
+		if migrated {
+			logger.Info("raft partition[%v] moved the wal from %v to %v.", cfg.ID, walPath, newPath)
+		}
+		walPath = newPath
+	}
 
-	wc := &wal.Config{}
-	ws, err := wal.NewStorage(walPath, wc)
+	ws, err := wal.NewStorage(walPath, s.walConfig)
 	if err != nil {
 		return
 	}
@@ -181,6 +229,15 @@ func (s *raftStore) CreatePartition(cfg *PartitionConfig) (p Partition, err erro
 	if err = s.raftServer.CreateRaft(rc); err != nil {
 		return
 	}
+	s.partitions.Store(cfg.ID, nil)
 	p = newPartition(cfg, s.raftServer, walPath)
 	return
 }
+
+// secureConn secures the raft connections with mutual TLS.
+func secureConn(conn net.Conn, server bool) (net.Conn, error) {
+	if server {
+		return mtls.Server(conn)
+	}
+	return mtls.Client(conn)
+}
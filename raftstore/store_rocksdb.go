@@ -209,3 +209,60 @@ func (rs *RocksDBStore) Iterator(snapshot *gorocksdb.Snapshot) *gorocksdb.Iterat
 
 	return rs.db.NewIterator(ro)
 }
+
+// BatchWrite puts and deletes the keys in one batch.
+func (rs *RocksDBStore) BatchWrite(puts map[string][]byte, dels []string, isSync bool) error {
+	wo := gorocksdb.NewDefaultWriteOptions()
+	wo.SetSync(isSync)
+	wb := gorocksdb.NewWriteBatch()
+	defer func() {
+		wo.Destroy()
+		wb.Destroy()
+	}()
+	for key, value := range puts {
+		wb.Put([]byte(key), value)
+	}
+	for _, key := range dels {
+		wb.Delete([]byte(key))
+	}
+	if err := rs.db.Write(wo, wb); err != nil {
+		err = fmt.Errorf("action[batchWriteToRocksDB],err:%v", err)
+		return err
+	}
+	return nil
+}
+
+// RangeForPrefix calls fn on the key-value pairs with the given prefix in the snapshot until fn returns false.
+// The key and the value are only valid during the call.
+func (rs *RocksDBStore) RangeForPrefix(snapshot *gorocksdb.Snapshot, prefix []byte, fn func(key, value []byte) bool) error {
+	it := rs.Iterator(snapshot)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key, value := it.Key(), it.Value()
+		goOn := fn(key.Data(), value.Data())
+		key.Free()
+		value.Free()
+		if !goOn {
+			break
+		}
+	}
+	return it.Err()
+}
+
+// Checkpoint creates an openable snapshot of the RocksDB in the given directory, which must not exist.
+func (rs *RocksDBStore) Checkpoint(dir string) error {
+	checkpoint, err := rs.db.NewCheckpoint()
+	if err != nil {
+		return fmt.Errorf("action[checkpointRocksDB],err:%v", err)
+	}
+	defer checkpoint.Destroy()
+	if err = checkpoint.CreateCheckpoint(dir, 0); err != nil {
+		return fmt.Errorf("action[checkpointRocksDB],err:%v", err)
+	}
+	return nil
+}
+
+// Close closes the RocksDB instance.
+func (rs *RocksDBStore) Close() {
+	rs.db.Close()
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+const (
+	walMigratingSuffix = ".migrating"
+	walCopyingSuffix   = ".copying"
+)
+
+// WalDirPath returns the wal directory of the partition in the dedicated wal directory.
+func WalDirPath(walDir string, id uint64) string {
+	return path.Join(walDir, "wal_"+strconv.FormatUint(id, 10))
+}
+
+// MigrateWal moves the wal directory of a partition from the old path to the new one, and
+// returns false if there is nothing to move.
+//
+// A wal on another file system is copied beside the new path and synced. Then the old path is
+// renamed as migrating, the copy is renamed to the new path and the migrating one is removed,
+// so that a migration interrupted at any step is completed or restarted by the next call.
+// It is an error if both the old and the new paths exist.
+func MigrateWal(oldPath, newPath string) (migrated bool, err error) {
+	migratingPath := oldPath + walMigratingSuffix
+	copyingPath := newPath + walCopyingSuffix
+	oldExists, err := pathExists(oldPath)
+	if err != nil {
+		return
+	}
+	newExists, err := pathExists(newPath)
+	if err != nil {
+		return
+	}
+	migrating, err := pathExists(migratingPath)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case oldExists && newExists:
+		return false, fmt.Errorf("both the wal %v and %v exist", oldPath, newPath)
+	case migrating:
+		// the copy is complete once the old path is renamed
+		if !newExists {
+			if err = os.Rename(copyingPath, newPath); err != nil {
+				return
+			}
+		}
+		return true, os.RemoveAll(migratingPath)
+	case !oldExists:
+		return false, nil
+	}
+
+	if err = os.MkdirAll(path.Dir(newPath), 0755); err != nil {
+		return
+	}
+	if err = os.Rename(oldPath, newPath); err == nil {
+		return true, nil
+	}
+	if linkErr, ok := err.(*os.LinkError); !ok || linkErr.Err != syscall.EXDEV {
+		return
+	}
+	if err = os.RemoveAll(copyingPath); err != nil {
+		return
+	}
+	if err = copyDir(oldPath, copyingPath); err != nil {
+		return
+	}
+	if err = os.Rename(oldPath, migratingPath); err != nil {
+		return
+	}
+	if err = os.Rename(copyingPath, newPath); err != nil {
+		return
+	}
+	return true, os.RemoveAll(migratingPath)
+}
+
+func pathExists(name string) (bool, error) {
+	_, err := os.Stat(name)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// copyDir copies the directory and syncs the copied files and directories.
+func copyDir(src, dst string) error {
+	err := filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(name, target, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dst, func(name string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		return syncPath(name)
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return
+	}
+	return out.Sync()
+}
+
+func syncPath(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
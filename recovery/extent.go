@@ -0,0 +1,128 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/metanode"
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/storage"
+)
+
+var (
+	regexpDataPartitionDir = regexp.MustCompile(`^datapartition_(\d+)_(\d+)$`)
+	regexpECPartitionDir   = regexp.MustCompile(`^ecpartition_(\d+)$`)
+)
+
+// ExtentLocator finds the extent files of the data partitions on the disks.
+type ExtentLocator struct {
+	// partitions maps a data partition to its directories, one for each replica found.
+	partitions map[uint64][]string
+	// erasureCoded are the partitions whose shards are found on the disks of the ec nodes, which
+	// are not decoded.
+	erasureCoded map[uint64]bool
+}
+
+// NewExtentLocator scans the data partition directories on the given disks.
+func NewExtentLocator(disks []string) (l *ExtentLocator, err error) {
+	l = &ExtentLocator{partitions: make(map[uint64][]string), erasureCoded: make(map[uint64]bool)}
+	for _, disk := range disks {
+		var fileInfos []os.FileInfo
+		if fileInfos, err = ioutil.ReadDir(disk); err != nil {
+			return
+		}
+		for _, fileInfo := range fileInfos {
+			if matches := regexpECPartitionDir.FindStringSubmatch(fileInfo.Name()); fileInfo.IsDir() && matches != nil {
+				pid, _ := strconv.ParseUint(matches[1], 10, 64)
+				l.erasureCoded[pid] = true
+				continue
+			}
+			matches := regexpDataPartitionDir.FindStringSubmatch(fileInfo.Name())
+			if !fileInfo.IsDir() || matches == nil {
+				continue
+			}
+			pid, _ := strconv.ParseUint(matches[1], 10, 64)
+			l.partitions[pid] = append(l.partitions[pid], path.Join(disk, fileInfo.Name()))
+		}
+	}
+	return
+}
+
+// ErasureCoded returns true if the shards of the partition are found on the disks of the ec nodes.
+func (l *ExtentLocator) ErasureCoded(partitionID uint64) bool {
+	return l.erasureCoded[partitionID]
+}
+
+// ReadExtent reads the data of the extent key from the first replica which holds it entirely,
+// and the compressed blocks are decompressed. A replica whose blocks fail the CRC of the
+// compression or cannot be decompressed is skipped.
+func (l *ExtentLocator) ReadExtent(ek *proto.ExtentKey, data []byte) bool {
+	for _, dir := range l.partitions[ek.PartitionId] {
+		err := storage.ReadExtentFile(dir, ek.ExtentId, data, int64(ek.ExtentOffset))
+		if err == nil {
+			return true
+		}
+		if !os.IsNotExist(err) && err != io.EOF {
+			fmt.Fprintf(os.Stderr, "read extent %v of partition %v in %v failed: %v\n", ek.ExtentId, ek.PartitionId, dir, err)
+		}
+	}
+	return false
+}
+
+// WriteFile writes the content of the inode to the target file, and returns the extent
+// keys which cannot be found. The ranges of the missing extents are left as holes.
+func (l *ExtentLocator) WriteFile(target string, inode *metanode.Inode) (missing []proto.ExtentKey, err error) {
+	fp, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	if err = fp.Truncate(int64(inode.Size)); err != nil {
+		return
+	}
+	var data []byte
+	inode.Extents.Range(func(item metanode.BtreeItem) bool {
+		ek := item.(*proto.ExtentKey)
+		if ek.FileOffset >= inode.Size {
+			return true
+		}
+		if cap(data) < int(ek.Size) {
+			data = make([]byte, ek.Size)
+		}
+		data = data[:ek.Size]
+		if !l.ReadExtent(ek, data) {
+			missing = append(missing, *ek)
+			return true
+		}
+		if ek.FileOffset+uint64(ek.Size) > inode.Size {
+			data = data[:inode.Size-ek.FileOffset]
+		}
+		if _, err = fp.WriteAt(data, int64(ek.FileOffset)); err != nil {
+			return false
+		}
+		return true
+	})
+	if err == nil {
+		err = fp.Sync()
+	}
+	return
+}
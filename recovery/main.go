@@ -0,0 +1,206 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-recovery reconstructs the files of a volume offline from the snapshots of the meta
+// partitions and the extent files of the data partitions. It is the last resort when the
+// masters or the raft groups of a cluster are unrecoverable.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/metanode"
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+var (
+	metaDirs = flag.String("meta", "", "metadata directories of the metanodes separated by comma")
+	dataDirs = flag.String("data", "", "disk directories of the datanodes separated by comma")
+	volName  = flag.String("vol", "", "volume name")
+	subPath  = flag.String("path", "/", "path in the volume to recover")
+	output   = flag.String("output", "", "output directory")
+)
+
+func main() {
+	flag.Parse()
+	if *metaDirs == "" || *dataDirs == "" || *volName == "" || *output == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ns := NewNamespace()
+	for _, dir := range strings.Split(*metaDirs, ",") {
+		partitions, err := metanode.LoadOfflinePartitions(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load meta partitions from %v failed: %v\n", dir, err)
+			os.Exit(1)
+		}
+		for _, p := range partitions {
+			if p.Config().VolName == *volName {
+				ns.AddPartition(p)
+			}
+		}
+	}
+
+	locator, err := NewExtentLocator(strings.Split(*dataDirs, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scan data partitions failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	ino, err := ns.Resolve(*subPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve %v failed: %v\n", *subPath, err)
+		os.Exit(1)
+	}
+
+	r := &recoverer{ns: ns, locator: locator}
+	if err = r.recoverTree(ino, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "recover failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("recovered dirs: %v, files: %v, symlinks: %v, incomplete files: %v\n",
+		r.dirs, r.files, r.symlinks, len(r.incomplete))
+	if len(r.incomplete) > 0 {
+		os.Exit(2)
+	}
+}
+
+// Namespace is the namespace of a volume merged from its meta partitions.
+type Namespace struct {
+	partitions []*metanode.OfflinePartition
+}
+
+// NewNamespace returns a new Namespace.
+func NewNamespace() *Namespace {
+	return &Namespace{}
+}
+
+// AddPartition adds a meta partition. Only one replica of each partition is kept.
+func (ns *Namespace) AddPartition(p *metanode.OfflinePartition) {
+	for i, exist := range ns.partitions {
+		if exist.Config().PartitionId == p.Config().PartitionId {
+			// keep the replica with the latest snapshot
+			if p.ApplyID() > exist.ApplyID() {
+				ns.partitions[i] = p
+			}
+			return
+		}
+	}
+	ns.partitions = append(ns.partitions, p)
+}
+
+func (ns *Namespace) partition(ino uint64) *metanode.OfflinePartition {
+	for _, p := range ns.partitions {
+		if conf := p.Config(); ino >= conf.Start && ino <= conf.End {
+			return p
+		}
+	}
+	return nil
+}
+
+// GetInode returns the inode, or nil if it is not found in any partition.
+func (ns *Namespace) GetInode(ino uint64) *metanode.Inode {
+	p := ns.partition(ino)
+	if p == nil {
+		return nil
+	}
+	return p.GetInode(ino)
+}
+
+// ReadDir returns the dentries under the given directory.
+func (ns *Namespace) ReadDir(parentID uint64) []*metanode.Dentry {
+	p := ns.partition(parentID)
+	if p == nil {
+		return nil
+	}
+	return p.ReadDir(parentID)
+}
+
+// Resolve returns the inode of the given absolute path.
+func (ns *Namespace) Resolve(p string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(path.Clean("/"+p), "/") {
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, d := range ns.ReadDir(ino) {
+			if d.Name == name {
+				ino, found = d.Inode, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("%v not found", name)
+		}
+	}
+	return
+}
+
+type recoverer struct {
+	ns         *Namespace
+	locator    *ExtentLocator
+	dirs       int
+	files      int
+	symlinks   int
+	incomplete []string
+}
+
+func (r *recoverer) recoverTree(ino uint64, target string) (err error) {
+	inode := r.ns.GetInode(ino)
+	if inode == nil {
+		r.incomplete = append(r.incomplete, target)
+		fmt.Fprintf(os.Stderr, "%v: inode(%v) not found\n", target, ino)
+		return nil
+	}
+	switch {
+	case proto.IsDir(inode.Type):
+		if err = os.MkdirAll(target, 0755); err != nil {
+			return
+		}
+		r.dirs++
+		for _, d := range r.ns.ReadDir(ino) {
+			if err = r.recoverTree(d.Inode, path.Join(target, d.Name)); err != nil {
+				return
+			}
+		}
+	case proto.IsSymlink(inode.Type):
+		if err = os.Symlink(string(inode.LinkTarget), target); err != nil {
+			return
+		}
+		r.symlinks++
+	default:
+		var missing []proto.ExtentKey
+		if missing, err = r.locator.WriteFile(target, inode); err != nil {
+			return
+		}
+		r.files++
+		if len(missing) > 0 {
+			r.incomplete = append(r.incomplete, target)
+			for _, ek := range missing {
+				if r.locator.ErasureCoded(ek.PartitionId) {
+					fmt.Fprintf(os.Stderr, "%v: missing %v in erasure coded partition\n", target, ek)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%v: missing %v\n", target, ek)
+			}
+		}
+	}
+	return
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/tracing"
 	"github.com/tiglabs/raft"
 )
 
@@ -41,8 +42,12 @@ type Packet struct {
 	IsReleased      int32 // TODO what is released?
 	Object          interface{}
 	TpObject        *exporter.TimePointCount
+	Span            *tracing.Span
 	NeedReply       bool
 	OrgBuffer       []byte
+	RecvT           time.Time // the time the packet is read from the connection
+	ProcessT        time.Time // the time the packet is taken from the to-be-processed channel
+	RemoteAddr      string    // the address of the connection the packet is read from
 }
 
 type FollowerPacket struct {
@@ -81,7 +86,8 @@ func (p *FollowerPacket) identificationErrorResultCode(errLog string, errMsg str
 		p.ResultCode = proto.OpNotExistErr
 	} else if strings.Contains(errMsg, storage.NoSpaceError.Error()) {
 		p.ResultCode = proto.OpDiskNoSpaceErr
-	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) {
+	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) ||
+		strings.Contains(errMsg, proto.ErrRateLimited.Error()) {
 		p.ResultCode = proto.OpAgain
 	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
@@ -92,6 +98,14 @@ func (p *FollowerPacket) identificationErrorResultCode(errLog string, errMsg str
 
 func (p *Packet) AfterTp() (ok bool) {
 	p.TpObject.Set(nil)
+	if p.Span != nil {
+		var err error
+		if p.IsErrPacket() {
+			err = errors.New(p.GetResultMsg())
+		}
+		p.Span.Finish(err)
+		p.Span = nil
+	}
 
 	return
 }
@@ -104,7 +118,7 @@ func (p *Packet) clean() {
 	p.TpObject = nil
 	p.Data = nil
 	p.Arg = nil
-	if p.OrgBuffer != nil && len(p.OrgBuffer) == util.BlockSize && p.IsWriteOperation() {
+	if p.OrgBuffer != nil && p.IsWriteOperation() {
 		proto.Buffers.Put(p.OrgBuffer)
 		p.OrgBuffer = nil
 	}
@@ -123,11 +137,21 @@ func copyPacket(src *Packet, dst *FollowerPacket) {
 	dst.ExtentOffset = src.ExtentOffset
 	dst.ReqID = src.ReqID
 	dst.Data = src.OrgBuffer
-
+	dst.Trace = src.Trace
+	if src.Span != nil {
+		dst.Trace = src.Span.Context()
+	}
 }
 
 func (p *Packet) BeforeTp(clusterID string) (ok bool) {
 	p.TpObject = exporter.NewTPCnt(p.GetOpMsg())
+	if p.Trace != nil {
+		p.Span = tracing.StartSpan("datanode."+p.GetOpMsg(), p.Trace)
+		p.Span.SetTag("partition", p.PartitionID)
+		p.Span.SetTag("extent", p.ExtentID)
+		p.Span.SetTag("size", p.Size)
+		p.Span.SetTag("forward", p.IsForwardPkt())
+	}
 	return
 }
 
@@ -213,6 +237,21 @@ func NewExtentRepairReadPacket(partitionID uint64, extentID uint64, offset, size
 	return
 }
 
+// NewExtentCopyReadPacket returns the packet to read the data of an extent from any replica to copy it.
+func NewExtentCopyReadPacket(partitionID uint64, extentID uint64, offset, size int) (p *Packet) {
+	p = new(Packet)
+	p.ExtentID = extentID
+	p.PartitionID = partitionID
+	p.Magic = proto.ProtoMagic
+	p.ExtentOffset = int64(offset)
+	p.Size = uint32(size)
+	p.Opcode = proto.OpStreamFollowerRead
+	p.ExtentType = proto.NormalExtentType
+	p.ReqID = proto.GenerateRequestID()
+
+	return
+}
+
 func NewTinyExtentRepairReadPacket(partitionID uint64, extentID uint64, offset, size int) (p *Packet) {
 	p = new(Packet)
 	p.ExtentID = extentID
@@ -289,10 +328,13 @@ func (p *Packet) identificationErrorResultCode(errLog string, errMsg string) {
 		p.ResultCode = proto.OpNotExistErr
 	} else if strings.Contains(errMsg, storage.NoSpaceError.Error()) {
 		p.ResultCode = proto.OpDiskNoSpaceErr
-	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) {
+	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) ||
+		strings.Contains(errMsg, proto.ErrRateLimited.Error()) {
 		p.ResultCode = proto.OpAgain
 	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
+	} else if strings.Contains(errMsg, storage.ExtentFrozenError.Error()) {
+		p.ResultCode = proto.OpExtentFrozenErr
 	} else {
 		p.ResultCode = proto.OpIntraGroupNetErr
 	}
@@ -306,8 +348,11 @@ func (p *Packet) PackErrorBody(action, msg string) {
 }
 
 func (p *Packet) ReadFull(c net.Conn, opcode uint8, readSize int) (err error) {
-	if p.IsWriteOperation() && readSize == util.BlockSize {
-		p.Data, _ = proto.Buffers.Get(readSize)
+	// the data of the other ops may be kept after the reply, e.g. by raft
+	if p.IsWriteOperation() {
+		if p.Data, err = proto.Buffers.Get(readSize); err != nil {
+			return
+		}
 	} else {
 		p.Data = make([]byte, readSize)
 	}
@@ -332,6 +377,9 @@ func (p *Packet) ReadFromConnFromCli(c net.Conn, deadlineTime time.Duration) (er
 	if err = p.UnmarshalHeader(header); err != nil {
 		return
 	}
+	if err = p.ReadTraceContext(c); err != nil {
+		return
+	}
 
 	if p.ArgLen > 0 {
 		if err = proto.ReadFull(c, &p.Arg, int(p.ArgLen)); err != nil {
@@ -359,7 +407,9 @@ func (p *Packet) IsMasterCommand() bool {
 		proto.OpDecommissionDataPartition,
 		proto.OpAddDataPartitionRaftMember,
 		proto.OpRemoveDataPartitionRaftMember,
-		proto.OpDataPartitionTryToLeader:
+		proto.OpDataPartitionTryToLeader,
+		proto.OpAddDataPartitionRaftLearner,
+		proto.OpPromoteDataPartitionRaftLearner:
 		return true
 	}
 	return false
@@ -372,7 +422,8 @@ func (p *Packet) IsForwardPacket() bool {
 
 // A leader packet is the packet send to the leader and does not require packet forwarding.
 func (p *Packet) IsLeaderPacket() (ok bool) {
-	if p.IsForwardPkt() && (p.IsWriteOperation() || p.IsCreateExtentOperation() || p.IsMarkDeleteExtentOperation()) {
+	if p.IsForwardPkt() && (p.IsWriteOperation() || p.IsCreateExtentOperation() || p.IsCopyExtentOperation() ||
+		p.IsMarkDeleteExtentOperation()) {
 		ok = true
 	}
 
@@ -391,6 +442,10 @@ func (p *Packet) IsCreateExtentOperation() bool {
 	return p.Opcode == proto.OpCreateExtent
 }
 
+func (p *Packet) IsCopyExtentOperation() bool {
+	return p.Opcode == proto.OpCopyExtent
+}
+
 func (p *Packet) IsMarkDeleteExtentOperation() bool {
 	return p.Opcode == proto.OpMarkDelete
 }
@@ -45,7 +45,7 @@ type ReplProtocol struct {
 	toBeProcessedCh chan *Packet // the goroutine receives an available packet and then sends it to this channel
 	responseCh      chan *Packet // this chan is used to write response to the client
 
-	sourceConn *net.TCPConn
+	sourceConn net.Conn
 	exitC      chan bool
 	exited     int32
 	exitedMu   sync.RWMutex
@@ -53,9 +53,9 @@ type ReplProtocol struct {
 	followerConnects map[string]*FollowerTransport
 	lock             sync.RWMutex
 
-	prepareFunc  func(p *Packet) error                 // prepare packet
-	operatorFunc func(p *Packet, c *net.TCPConn) error // operator
-	postFunc     func(p *Packet) error                 // post-processing packet
+	prepareFunc  func(p *Packet) error             // prepare packet
+	operatorFunc func(p *Packet, c net.Conn) error // operator
+	postFunc     func(p *Packet) error             // post-processing packet
 
 	isError int32
 	replId  int64
@@ -159,7 +159,7 @@ func (ft *FollowerTransport) readFollowerResult(request *FollowerPacket) (err er
 		err = fmt.Errorf(string(reply.Data[:reply.Size]))
 		return
 	}
-	log.LogDebugf("action[ActionReceiveFromFollower] %v.", reply.LogMessage(ActionReceiveFromFollower,
+	log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogDebugf("action[ActionReceiveFromFollower] %v.", reply.LogMessage(ActionReceiveFromFollower,
 		ft.addr, request.StartT, err))
 	return
 }
@@ -183,8 +183,8 @@ func (ft *FollowerTransport) Write(p *FollowerPacket) {
 	ft.sendCh <- p
 }
 
-func NewReplProtocol(inConn *net.TCPConn, prepareFunc func(p *Packet) error,
-	operatorFunc func(p *Packet, c *net.TCPConn) error, postFunc func(p *Packet) error) *ReplProtocol {
+func NewReplProtocol(inConn net.Conn, prepareFunc func(p *Packet) error,
+	operatorFunc func(p *Packet, c net.Conn) error, postFunc func(p *Packet) error) *ReplProtocol {
 	rp := new(ReplProtocol)
 	rp.packetList = list.New()
 	rp.ackCh = make(chan struct{}, RequestChanSize)
@@ -264,6 +264,8 @@ func (rp *ReplProtocol) readPkgAndPrepare() (err error) {
 	if err = request.ReadFromConnFromCli(rp.sourceConn, proto.NoReadDeadlineTime); err != nil {
 		return
 	}
+	request.RecvT = time.Now()
+	request.RemoteAddr = rp.sourceConn.RemoteAddr().String()
 	log.LogDebugf("action[readPkgAndPrepare] packet(%v) from remote(%v) localAddr(%v).",
 		request.GetUniqueLogId(), rp.sourceConn.RemoteAddr().String(), rp.sourceConn.LocalAddr().String())
 	if err = request.resolveFollowersAddr(); err != nil {
@@ -306,6 +308,7 @@ func (rp *ReplProtocol) OperatorAndForwardPktGoRoutine() {
 	for {
 		select {
 		case request := <-rp.toBeProcessedCh:
+			request.ProcessT = time.Now()
 			if !request.IsForwardPacket() {
 				rp.operatorFunc(request, rp.sourceConn)
 				rp.putResponse(request)
@@ -395,7 +398,7 @@ func (rp *ReplProtocol) writeResponse(reply *Packet) {
 	if reply.IsErrPacket() {
 		err = fmt.Errorf(reply.LogMessage(ActionWriteToClient, rp.sourceConn.RemoteAddr().String(),
 			reply.StartT, fmt.Errorf(string(reply.Data[:reply.Size]))))
-		log.LogErrorf(err.Error())
+		log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogErrorf(err.Error())
 		rp.Stop()
 	}
 
@@ -408,10 +411,10 @@ func (rp *ReplProtocol) writeResponse(reply *Packet) {
 	if err = reply.WriteToConn(rp.sourceConn); err != nil {
 		err = fmt.Errorf(reply.LogMessage(ActionWriteToClient, fmt.Sprintf("local(%v)->remote(%v)", rp.sourceConn.LocalAddr().String(),
 			rp.sourceConn.RemoteAddr().String()), reply.StartT, err))
-		log.LogErrorf(err.Error())
+		log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogErrorf(err.Error())
 		rp.Stop()
 	}
-	log.LogDebugf(reply.LogMessage(ActionWriteToClient,
+	log.WithPartition(reply.PartitionID).WithReqID(reply.ReqID).LogDebugf(reply.LogMessage(ActionWriteToClient,
 		rp.sourceConn.RemoteAddr().String(), reply.StartT, err))
 }
 
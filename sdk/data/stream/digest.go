@@ -0,0 +1,83 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"hash/crc32"
+	"sync"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+)
+
+// With the integrity digest enabled, the client records the CRC32 of each write range in the CRC
+// of its extent key, and verifies it once the whole range is read. The digest 0 means that no
+// digest is recorded, e.g. the key is written by a client without the digest, or its data has been
+// overwritten in place or truncated.
+var DigestMismatchError = errors.New("extent digest mismatch")
+
+// digestVerifier verifies the digest of the extent key being read sequentially from its start.
+// The random reads of the extent keys larger than the read requests are not verified.
+type digestVerifier struct {
+	sync.Mutex
+	key  proto.ExtentKey
+	next int    // file offset of the next sequential read of the key
+	crc  uint32 // CRC32 of the data read so far
+}
+
+func (v *digestVerifier) verify(req *ExtentRequest) error {
+	ek := req.ExtentKey
+	if ek.CRC == 0 {
+		return nil
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	if req.FileOffset == int(ek.FileOffset) {
+		v.key = *ek
+		v.crc = 0
+	} else if v.key != *ek || req.FileOffset != v.next {
+		return nil
+	}
+	v.crc = crc32.Update(v.crc, crc32.IEEETable, req.Data[:req.Size])
+	v.next = req.FileOffset + req.Size
+	if v.next < int(v.key.FileOffset)+int(v.key.Size) {
+		return nil
+	}
+
+	key := v.key
+	v.key = proto.ExtentKey{}
+	// the key may be extended by the open handler meanwhile, whose digest is not verified
+	if v.crc != key.CRC && key == *ek {
+		return errors.Trace(DigestMismatchError, "ek(%v) crc(%v)", ek, v.crc)
+	}
+	return nil
+}
+
+// clearDigest clears the digest of the extent key before its data is overwritten in place, since
+// the digest of the whole write range can not be updated by a partial write.
+func (s *Streamer) clearDigest(ek *proto.ExtentKey) error {
+	// the open handler must not extend the digest of the key any more
+	if s.handler != nil && s.handler.key == ek {
+		s.closeOpenHandler()
+	}
+	key := *ek
+	key.CRC = 0
+	if err := s.client.appendExtentKey(s.inode, key); err != nil {
+		return err
+	}
+	s.extents.ClearDigest(ek)
+	return nil
+}
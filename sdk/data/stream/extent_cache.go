@@ -139,6 +139,13 @@ func (cache *ExtentCache) Append(ek *proto.ExtentKey, sync bool) {
 	//log.LogDebugf("ExtentCache Append: ino(%v) ek(%v) discard(%v)", cache.inode, ek, discard)
 }
 
+// ClearDigest clears the digest of the given extent key in the cache.
+func (cache *ExtentCache) ClearDigest(ek *proto.ExtentKey) {
+	cache.Lock()
+	defer cache.Unlock()
+	ek.CRC = 0
+}
+
 // Max returns the max extent key in the cache.
 func (cache *ExtentCache) Max() *proto.ExtentKey {
 	cache.RLock()
@@ -18,14 +18,19 @@ import (
 	"fmt"
 	"golang.org/x/time/rate"
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/sdk/data/wrapper"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
 )
 
 type AppendExtentKeyFunc func(inode uint64, key proto.ExtentKey) error
@@ -41,6 +46,11 @@ const (
 
 	defaultWriteLimitRate  = rate.Inf
 	defaultWriteLimitBurst = 128
+
+	// the copy of an extent reads the whole source, so that it is retried by fewer data partitions
+	MaxSelectDataPartitionForCopy = 3
+
+	rateLimitRefreshInterval = time.Minute
 )
 
 var (
@@ -59,18 +69,36 @@ type ExtentClient struct {
 
 	readLimiter  *rate.Limiter
 	writeLimiter *rate.Limiter
+	limiter      *ratelimit.Limiter // by the rate limits of the client module of the cluster
 
 	dataWrapper     *wrapper.Wrapper
+	volName         string
 	appendExtentKey AppendExtentKeyFunc
 	getExtents      GetExtentsFunc
 	truncate        TruncateFunc
 	followerRead    bool
+	integrityDigest bool
+	readCache       *ReadCache // nil if the read cache is disabled
+
+	writeBack      bool
+	writeBackLimit int64 // of the dirty bytes of all the files
+	writeBackDelay time.Duration
+	dirtyBytes     int64
+
+	readAheadMax   int   // zero if the readahead is disabled
+	readAheadLimit int64 // of the prefetched bytes of all the files
+	readAheadBytes int64
+
+	opStats *metrics.OpStats
+	stopC   chan struct{}
 }
 
 // NewExtentClient returns a new extent client.
 func NewExtentClient(opt *proto.MountOptions, appendExtentKey AppendExtentKeyFunc, getExtents GetExtentsFunc, truncate TruncateFunc) (client *ExtentClient, err error) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	client = new(ExtentClient)
+	client.opStats = metrics.NewOpStats()
+	client.stopC = make(chan struct{})
 
 	limit := MaxMountRetryLimit
 retry:
@@ -86,10 +114,40 @@ retry:
 	}
 
 	client.streamers = make(map[uint64]*Streamer)
+	client.volName = opt.Volname
 	client.appendExtentKey = appendExtentKey
 	client.getExtents = getExtents
 	client.truncate = truncate
 	client.followerRead = opt.FollowerRead
+	client.integrityDigest = opt.IntegrityDigest
+	if opt.ReadCacheDir != "" {
+		client.readCache, err = NewReadCache(opt.ReadCacheDir, opt.ReadCacheSize*util.MB, time.Duration(opt.ReadCacheExpire)*time.Second)
+		if err != nil {
+			return nil, errors.Trace(err, "Init read cache failed!")
+		}
+	}
+	if client.writeBack = opt.WriteBack; client.writeBack {
+		client.writeBackLimit = DefaultWriteBackDirty
+		if opt.WriteBackDirty > 0 {
+			client.writeBackLimit = opt.WriteBackDirty * util.MB
+		}
+		client.writeBackDelay = DefaultWriteBackDelay
+		if opt.WriteBackDelay > 0 {
+			client.writeBackDelay = time.Duration(opt.WriteBackDelay) * time.Millisecond
+		}
+		go client.flushWriteBacks()
+	}
+	switch {
+	case opt.ReadAheadMax < 0:
+	case opt.ReadAheadMax == 0:
+		client.readAheadMax = DefaultReadAheadMax
+	default:
+		client.readAheadMax = int(opt.ReadAheadMax) * util.MB
+	}
+	client.readAheadLimit = DefaultReadAheadTotal
+	if opt.ReadAheadTotal > 0 {
+		client.readAheadLimit = opt.ReadAheadTotal * util.MB
+	}
 
 	// Init request pools
 	openRequestPool = &sync.Pool{New: func() interface{} {
@@ -125,10 +183,34 @@ retry:
 
 	client.readLimiter = rate.NewLimiter(readLimit, defaultReadLimitBurst)
 	client.writeLimiter = rate.NewLimiter(writeLimit, defaultWriteLimitBurst)
+	client.limiter = ratelimit.NewLimiter(ratelimit.ModuleClient)
+	go client.refreshRateLimits()
 
 	return
 }
 
+// refreshRateLimits fetches the rate limits from the master periodically, the client applies the
+// ones of the client module to itself.
+func (client *ExtentClient) refreshRateLimits() {
+	ticker := time.NewTicker(rateLimitRefreshInterval)
+	defer ticker.Stop()
+	for {
+		rules, err := client.dataWrapper.GetRateLimits()
+		if err != nil {
+			log.LogWarnf("refreshRateLimits: get rate limits from master fail: err(%v)", err)
+		} else {
+			client.limiter.Update(rules)
+		}
+		<-ticker.C
+	}
+}
+
+// waitRateLimit waits until the op of the bytes is allowed by the rate limits of the client module.
+func (client *ExtentClient) waitRateLimit(opcode uint8, bytes int) {
+	p := &proto.Packet{Opcode: opcode}
+	client.limiter.Wait(client.volName, p.GetOpMsg(), "", bytes)
+}
+
 // Open request shall grab the lock until request is sent to the request channel
 func (client *ExtentClient) OpenStream(inode uint64) error {
 	client.streamerLock.Lock()
@@ -142,13 +224,20 @@ func (client *ExtentClient) OpenStream(inode uint64) error {
 
 // Release request shall grab the lock until request is sent to the request channel
 func (client *ExtentClient) CloseStream(inode uint64) error {
+	var barrierErr error
+	if s := client.GetStreamer(inode); s != nil {
+		barrierErr = s.writeBackBarrier()
+	}
 	client.streamerLock.Lock()
 	s, ok := client.streamers[inode]
 	if !ok {
 		client.streamerLock.Unlock()
-		return nil
+		return barrierErr
 	}
-	return s.IssueReleaseRequest()
+	if err := s.IssueReleaseRequest(); err != nil {
+		return err
+	}
+	return barrierErr
 }
 
 // Evict request shall grab the lock until request is sent to the request channel
@@ -185,9 +274,22 @@ func (client *ExtentClient) FileSize(inode uint64) (size int, gen uint64, valid
 	}
 	valid = true
 	size, gen = s.extents.Size()
+	// the dirty data of the write back is not written yet
+	if end := s.writeBack.end(); end > size {
+		size = end
+	}
 	return
 }
 
+// SetModifyTime records the modify time of the inode got from the metanode, the blocks of the inode
+// in the read cache are invalidated once it changes.
+func (client *ExtentClient) SetModifyTime(inode uint64, mtime time.Time) {
+	s := client.GetStreamer(inode)
+	if s != nil {
+		atomic.StoreInt64(&s.mtime, mtime.UnixNano())
+	}
+}
+
 // SetFileSize set the file size.
 func (client *ExtentClient) SetFileSize(inode uint64, size int) {
 	s := client.GetStreamer(inode)
@@ -200,6 +302,10 @@ func (client *ExtentClient) SetFileSize(inode uint64, size int) {
 // Write writes the data.
 func (client *ExtentClient) Write(inode uint64, offset int, data []byte, direct bool) (write int, err error) {
 	prefix := fmt.Sprintf("Write{ino(%v)offset(%v)size(%v)}", inode, offset, len(data))
+	start := time.Now()
+	defer func() {
+		client.opStats.Record("write", start, err)
+	}()
 
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -211,7 +317,11 @@ func (client *ExtentClient) Write(inode uint64, offset int, data []byte, direct
 		s.GetExtents()
 	})
 
-	write, err = s.IssueWriteRequest(offset, data, direct)
+	if client.writeBack && !direct {
+		write, err = s.writeBackWrite(offset, data)
+	} else if err = s.flushWriteBack(); err == nil {
+		write, err = s.IssueWriteRequest(offset, data, direct)
+	}
 	if err != nil {
 		err = errors.Trace(err, prefix)
 		log.LogError(errors.Stack(err))
@@ -227,7 +337,10 @@ func (client *ExtentClient) Truncate(inode uint64, size int) error {
 		return fmt.Errorf("Prefix(%v): stream is not opened yet", prefix)
 	}
 
-	err := s.IssueTruncRequest(size)
+	err := s.flushWriteBack()
+	if err == nil {
+		err = s.IssueTruncRequest(size)
+	}
 	if err != nil {
 		err = errors.Trace(err, prefix)
 		log.LogError(errors.Stack(err))
@@ -240,13 +353,26 @@ func (client *ExtentClient) Flush(inode uint64) error {
 	if s == nil {
 		return fmt.Errorf("Flush: stream is not opened yet, ino(%v)", inode)
 	}
+	if err := s.writeBackBarrier(); err != nil {
+		return err
+	}
 	return s.IssueFlushRequest()
 }
 
 func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int) (read int, err error) {
+	return client.ReadHandle(inode, 0, data, offset, size)
+}
+
+// ReadHandle reads the data by the open handle of the file, whose access pattern tunes the readahead.
+// The handle of zero reads without the readahead.
+func (client *ExtentClient) ReadHandle(inode, handle uint64, data []byte, offset int, size int) (read int, err error) {
 	if size == 0 {
 		return
 	}
+	start := time.Now()
+	defer func() {
+		client.opStats.Record("read", start, err)
+	}()
 
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -258,15 +384,131 @@ func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int
 		s.GetExtents()
 	})
 
+	if err = s.flushWriteBack(); err != nil {
+		return
+	}
 	err = s.IssueFlushRequest()
 	if err != nil {
 		return
 	}
 
-	read, err = s.read(data, offset, size)
+	if handle != 0 && client.readAheadMax > 0 {
+		read, err = s.readWithReadAhead(handle, data, offset, size)
+	} else {
+		read, err = s.read(data, offset, size)
+	}
 	return
 }
 
+// CopyExtents copies the data of the extent keys of the source client to the new extents of the
+// client, which is read by the data nodes from each other instead of through the client, and
+// returns the extent keys of the copies at the same file offsets.
+func (client *ExtentClient) CopyExtents(source *ExtentClient, extents []proto.ExtentKey) (copies []proto.ExtentKey, err error) {
+	start := time.Now()
+	defer func() {
+		client.opStats.Record("copy", start, err)
+	}()
+
+	copies = make([]proto.ExtentKey, 0, len(extents))
+	for _, ek := range extents {
+		var dp *wrapper.DataPartition
+		if dp, err = source.dataWrapper.GetDataPartition(ek.PartitionId); err != nil {
+			return nil, errors.Trace(err, "CopyExtents: failed to get source data partition, ek(%v)", ek)
+		}
+		req := &proto.CopyExtentRequest{
+			PartitionId:  ek.PartitionId,
+			ExtentId:     ek.ExtentId,
+			ExtentOffset: ek.ExtentOffset,
+			Size:         ek.Size,
+			Hosts:        dp.Hosts,
+		}
+		var copied proto.ExtentKey
+		if copied, err = client.copyExtent(req); err != nil {
+			return nil, err
+		}
+		copied.FileOffset = ek.FileOffset
+		copies = append(copies, copied)
+	}
+	return copies, nil
+}
+
+func (client *ExtentClient) copyExtent(source *proto.CopyExtentRequest) (ek proto.ExtentKey, err error) {
+	exclude := make(map[string]struct{})
+	for i := 0; i < MaxSelectDataPartitionForCopy; i++ {
+		var dp *wrapper.DataPartition
+		if dp, err = client.dataWrapper.GetDataPartitionForWrite(exclude); err != nil {
+			log.LogWarnf("copyExtent: failed to get write data partition, source(%v) exclude(%v)", source, exclude)
+			continue
+		}
+		var extentID uint64
+		if extentID, err = sendCopyExtent(dp, source); err != nil {
+			log.LogWarnf("copyExtent: failed to copy extent, source(%v) dp(%v) err(%v)", source, dp, err)
+			dp.CheckAllHostsIsAvail(exclude)
+			continue
+		}
+		return proto.ExtentKey{PartitionId: dp.PartitionID, ExtentId: extentID, Size: source.Size}, nil
+	}
+	return ek, errors.Trace(err, "copyExtent failed: hit max retry limit, source(%v)", source)
+}
+
+func sendCopyExtent(dp *wrapper.DataPartition, source *proto.CopyExtentRequest) (extentID uint64, err error) {
+	conn, err := StreamConnPool.GetConnect(dp.Hosts[0])
+	if err != nil {
+		err = errors.Trace(err, "sendCopyExtent: failed to create connection, datapartionHosts(%v)", dp.Hosts[0])
+		return
+	}
+
+	defer func() {
+		StreamConnPool.PutConnect(conn, err != nil)
+	}()
+
+	p, err := NewCopyExtentPacket(dp, source)
+	if err != nil {
+		return
+	}
+	if err = p.WriteToConn(conn); err != nil {
+		err = errors.Trace(err, "sendCopyExtent: failed to WriteToConn, packet(%v) datapartionHosts(%v)", p, dp.Hosts[0])
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.CopyExtentDeadLineTime); err != nil {
+		err = errors.Trace(err, "sendCopyExtent: failed to ReadFromConn, packet(%v) datapartionHosts(%v)", p, dp.Hosts[0])
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = errors.New(fmt.Sprintf("sendCopyExtent: ResultCode NOK, packet(%v) datapartionHosts(%v) ResultCode(%v)", p, dp.Hosts[0], p.GetResultMsg()))
+		return
+	}
+	if p.ExtentID == 0 {
+		err = errors.New(fmt.Sprintf("sendCopyExtent: illegal extID(%v) from (%v)", p.ExtentID, dp.Hosts[0]))
+		return
+	}
+	return p.ExtentID, nil
+}
+
+// StreamStat is the state of an open stream.
+type StreamStat struct {
+	Inode       uint64
+	RefCount    int
+	DirtyExtent int
+}
+
+// OpStats returns the counters of the read and write operations.
+func (client *ExtentClient) OpStats() []metrics.OpStat {
+	return client.opStats.Snapshot()
+}
+
+// StreamStats returns the state of the open streams.
+func (client *ExtentClient) StreamStats() []StreamStat {
+	client.streamerLock.Lock()
+	stats := make([]StreamStat, 0, len(client.streamers))
+	for ino, s := range client.streamers {
+		stats = append(stats, StreamStat{Inode: ino, RefCount: s.refcnt, DirtyExtent: s.dirtylist.Len()})
+	}
+	client.streamerLock.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Inode < stats[j].Inode })
+	return stats
+}
+
 // GetStreamer returns the streamer.
 func (client *ExtentClient) GetStreamer(inode uint64) *Streamer {
 	client.streamerLock.Lock()
@@ -308,5 +550,7 @@ func setRate(lim *rate.Limiter, val int) string {
 }
 
 func (client *ExtentClient) Close() error {
+	close(client.stopC)
+	client.readCache.close()
 	return nil
 }
@@ -16,6 +16,7 @@ package stream
 
 import (
 	"fmt"
+	"hash/crc32"
 	"net"
 	"sync/atomic"
 	"time"
@@ -26,6 +27,8 @@ import (
 	"github.com/chubaofs/chubaofs/sdk/data/wrapper"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 // State machines
@@ -75,7 +78,7 @@ type ExtentHandler struct {
 
 	// Allocated in the sender, and released in the receiver.
 	// Will not be changed.
-	conn *net.TCPConn
+	conn net.Conn
 	dp   *wrapper.DataPartition
 
 	// Issue a signal to this channel when *inflight* hits zero.
@@ -238,6 +241,14 @@ func (eh *ExtentHandler) sender() {
 
 			//log.LogDebugf("ExtentHandler sender: extent allocated, eh(%v) dp(%v) extID(%v) packet(%v)", eh, eh.dp, eh.extID, packet.GetUniqueLogId())
 
+			// The span covers the retries of the packet in the recover handlers.
+			if packet.span == nil {
+				packet.span = tracing.StartRootSpan("client.write")
+			}
+			packet.span.SetTag("partition", packet.PartitionID)
+			packet.span.SetTag("extent", packet.ExtentID)
+			packet.Trace = packet.span.Context()
+
 			if err = packet.writeToConn(eh.conn); err != nil {
 				log.LogWarnf("sender writeTo: failed, eh(%v) err(%v) packet(%v)", eh, err, packet)
 				eh.setClosed()
@@ -307,6 +318,10 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	log.LogDebugf("processReply: get reply, eh(%v) packet(%v) reply(%v)", eh, packet, reply)
 
 	if reply.ResultCode != proto.OpOk {
+		if reply.ResultCode == proto.OpAgain {
+			// the datanode is rate limited, back off before the packet is recovered
+			time.Sleep(StreamSendSleepInterval)
+		}
 		errmsg := fmt.Sprintf("reply NOK: reply(%v)", reply)
 		eh.processReplyError(packet, errmsg)
 		return
@@ -347,9 +362,14 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	} else {
 		eh.key.Size += packet.Size
 	}
+	if eh.stream.client.integrityDigest {
+		// the replies are processed in order, so the digest of the key is updated incrementally
+		eh.key.CRC = crc32.Update(eh.key.CRC, crc32.IEEETable, packet.Data[:packet.Size])
+	}
 
 	proto.Buffers.Put(packet.Data)
 	packet.Data = nil
+	packet.span.Finish(nil)
 	eh.dirty = true
 	return
 }
@@ -470,13 +490,14 @@ func (eh *ExtentHandler) recoverPacket(packet *Packet) error {
 func (eh *ExtentHandler) discardPacket(packet *Packet) {
 	proto.Buffers.Put(packet.Data)
 	packet.Data = nil
+	packet.span.Finish(errors.New("packet discarded"))
 	eh.setError()
 }
 
 func (eh *ExtentHandler) allocateExtent() (err error) {
 	var (
 		dp    *wrapper.DataPartition
-		conn  *net.TCPConn
+		conn  net.Conn
 		extID int
 	)
 
@@ -523,16 +544,8 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 	return err
 }
 
-func (eh *ExtentHandler) createConnection(dp *wrapper.DataPartition) (*net.TCPConn, error) {
-	conn, err := net.DialTimeout("tcp", dp.Hosts[0], time.Second)
-	if err != nil {
-		return nil, err
-	}
-	connect := conn.(*net.TCPConn)
-	// TODO unhandled error
-	connect.SetKeepAlive(true)
-	connect.SetNoDelay(true)
-	return connect, nil
+func (eh *ExtentHandler) createConnection(dp *wrapper.DataPartition) (net.Conn, error) {
+	return mtls.Dial(dp.Hosts[0], time.Second)
 }
 
 func (eh *ExtentHandler) createExtent(dp *wrapper.DataPartition) (extID int, err error) {
@@ -16,10 +16,12 @@ package stream
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/sdk/data/wrapper"
 	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/tracing"
 	"hash/crc32"
 	"io"
 	"net"
@@ -31,6 +33,7 @@ type Packet struct {
 	proto.Packet
 	inode    uint64
 	errCount int
+	span     *tracing.Span
 }
 
 // String returns the string format of the packet.
@@ -112,6 +115,24 @@ func NewCreateExtentPacket(dp *wrapper.DataPartition, inode uint64) *Packet {
 	return p
 }
 
+// NewCopyExtentPacket returns a new packet to copy the data of the source extent key to a new extent.
+func NewCopyExtentPacket(dp *wrapper.DataPartition, source *proto.CopyExtentRequest) (p *Packet, err error) {
+	p = new(Packet)
+	p.PartitionID = dp.PartitionID
+	p.Magic = proto.ProtoMagic
+	p.ExtentType = proto.NormalExtentType
+	p.Arg = ([]byte)(dp.GetAllAddrs())
+	p.ArgLen = uint32(len(p.Arg))
+	p.RemainingFollowers = uint8(len(dp.Hosts) - 1)
+	p.ReqID = proto.GenerateRequestID()
+	p.Opcode = proto.OpCopyExtent
+	if p.Data, err = json.Marshal(source); err != nil {
+		return nil, err
+	}
+	p.Size = uint32(len(p.Data))
+	return p, nil
+}
+
 // NewReply returns a new reply packet. TODO rename to NewReplyPacket?
 func NewReply(reqID int64, partitionID uint64, extentID uint64) *Packet {
 	p := new(Packet)
@@ -154,6 +175,9 @@ func (p *Packet) readFromConn(c net.Conn, deadlineTime time.Duration) (err error
 	if err = p.UnmarshalHeader(header); err != nil {
 		return
 	}
+	if err = p.ReadTraceContext(c); err != nil {
+		return
+	}
 
 	if p.ArgLen > 0 {
 		if err = readToBuffer(c, &p.Arg, int(p.ArgLen)); err != nil {
@@ -0,0 +1,284 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	DefaultReadAheadMax   = 8 * util.MB
+	DefaultReadAheadTotal = 256 * util.MB
+
+	readAheadInitWindow = util.MB
+	readAheadMinWindow  = 128 * util.KB
+	// the reads of the kernel readahead are issued in parallel, so a read near the end of the
+	// last one is sequential as well
+	readAheadSeqDistance = util.MB
+	readAheadMaxSegments = 2
+)
+
+// ReadAheadStat is the readahead state of an open handle of a file.
+type ReadAheadStat struct {
+	Inode      uint64
+	Handle     uint64
+	Window     int
+	SeqReads   uint64
+	RandReads  uint64
+	Prefetched uint64 // bytes prefetched
+	Hits       uint64 // bytes read from the prefetched data
+}
+
+// readAheadBuffers pools the buffers of the segments by the power of two of their capacities,
+// which are the windows mostly.
+var readAheadBuffers [bits.UintSize]sync.Pool
+
+func getReadAheadBuffer(size int) []byte {
+	class := bits.Len(uint(size - 1))
+	if buf, ok := readAheadBuffers[class].Get().([]byte); ok {
+		return buf[:size]
+	}
+	return make([]byte, size, 1<<class)
+}
+
+func putReadAheadBuffer(buf []byte) {
+	if class := bits.Len(uint(cap(buf) - 1)); cap(buf) == 1<<class {
+		readAheadBuffers[class].Put(buf[:0])
+	}
+}
+
+// readAheadSegment is the data prefetched after a sequential read. Its buffer is taken from the
+// budget of the client, and given back once it is dropped, the prefetch is done and no read is
+// copying from it, which are guarded by the readahead lock of the streamer.
+type readAheadSegment struct {
+	offset   int
+	data     []byte
+	size     int // of the data read, valid once done
+	err      error
+	done     chan struct{}
+	finished bool // the prefetch is done
+	readers  int  // the reads copying from the data
+	dropped  bool
+}
+
+func (seg *readAheadSegment) end() int {
+	return seg.offset + len(seg.data)
+}
+
+// readAhead detects the access pattern of a handle. The window is doubled by the sequential reads
+// up to the max, and halved by the random ones down to zero, which prefetches nothing.
+type readAhead struct {
+	lastEnd  int
+	window   int
+	segments []*readAheadSegment
+	stat     ReadAheadStat
+}
+
+// readWithReadAhead reads the data prefetched for the handle if any, and prefetches the window
+// after the read once the prefetched data ahead of it is less than half of the window.
+func (s *Streamer) readWithReadAhead(handle uint64, data []byte, offset, size int) (read int, err error) {
+	s.readAheadLock.Lock()
+	ra, ok := s.readAheads[handle]
+	if !ok {
+		ra = &readAhead{stat: ReadAheadStat{Inode: s.inode, Handle: handle}}
+		s.readAheads[handle] = ra
+	}
+	if offset >= ra.lastEnd-readAheadSeqDistance && offset <= ra.lastEnd+readAheadSeqDistance {
+		ra.stat.SeqReads++
+		if ra.window == 0 {
+			ra.window = util.Min(readAheadInitWindow, s.client.readAheadMax)
+		} else {
+			ra.window = util.Min(ra.window*2, s.client.readAheadMax)
+		}
+	} else {
+		ra.stat.RandReads++
+		if ra.window /= 2; ra.window < readAheadMinWindow {
+			ra.window = 0
+		}
+		s.dropSegments(ra.segments)
+		ra.segments = nil
+	}
+	if offset+size > ra.lastEnd || ra.window == 0 {
+		ra.lastEnd = offset + size
+	}
+	var found *readAheadSegment
+	for _, seg := range ra.segments {
+		if seg.offset <= offset && offset+size <= seg.end() {
+			found = seg
+			found.readers++
+			break
+		}
+	}
+	s.readAheadLock.Unlock()
+
+	var hit bool
+	if found != nil {
+		<-found.done
+		// the prefetch stops at the end of the file or an error
+		if hit = found.offset+found.size >= offset+size; hit {
+			read = copy(data[:size], found.data[offset-found.offset:])
+		}
+	}
+	if !hit {
+		read, err = s.read(data, offset, size)
+	}
+
+	s.readAheadLock.Lock()
+	if found != nil {
+		found.readers--
+		s.releaseSegment(found)
+	}
+	if hit {
+		ra.stat.Hits += uint64(read)
+	}
+	s.prefetch(ra, offset+size)
+	s.readAheadLock.Unlock()
+	return
+}
+
+// prefetch starts reading the window after the end of the read, the segments behind it are dropped.
+// Nothing is prefetched once the prefetched data of the client is over the limit.
+func (s *Streamer) prefetch(ra *readAhead, next int) {
+	var segments []*readAheadSegment
+	for _, seg := range ra.segments {
+		if seg.end() > next {
+			segments = append(segments, seg)
+		} else {
+			s.dropSegment(seg)
+		}
+	}
+	ra.segments = segments
+	if ra.window == 0 {
+		return
+	}
+	start := next
+	if n := len(ra.segments); n > 0 {
+		if start = ra.segments[n-1].end(); start-next >= ra.window/2 || n >= readAheadMaxSegments {
+			return
+		}
+	}
+	filesize, _ := s.extents.Size()
+	if start >= filesize {
+		return
+	}
+	size := util.Min(ra.window, filesize-start)
+	if atomic.AddInt64(&s.client.readAheadBytes, int64(size)) > s.client.readAheadLimit {
+		atomic.AddInt64(&s.client.readAheadBytes, -int64(size))
+		return
+	}
+	seg := &readAheadSegment{offset: start, data: getReadAheadBuffer(size), done: make(chan struct{})}
+	ra.segments = append(ra.segments, seg)
+	ra.stat.Prefetched += uint64(size)
+	go func() {
+		n, err := s.read(seg.data, seg.offset, len(seg.data))
+		log.LogDebugf("prefetch: ino(%v) offset(%v) size(%v) read(%v) err(%v)", s.inode, seg.offset, len(seg.data), n, err)
+		s.readAheadLock.Lock()
+		seg.size, seg.err, seg.finished = n, err, true
+		close(seg.done)
+		s.releaseSegment(seg)
+		s.readAheadLock.Unlock()
+	}()
+}
+
+// dropSegment drops the segment from its readahead state. The lock of the readahead is held by
+// the caller.
+func (s *Streamer) dropSegment(seg *readAheadSegment) {
+	seg.dropped = true
+	s.releaseSegment(seg)
+}
+
+func (s *Streamer) dropSegments(segments []*readAheadSegment) {
+	for _, seg := range segments {
+		s.dropSegment(seg)
+	}
+}
+
+// releaseSegment gives the buffer of the segment back to the pool and the budget of the client,
+// once it is not used any more. The lock of the readahead is held by the caller.
+func (s *Streamer) releaseSegment(seg *readAheadSegment) {
+	if !seg.dropped || !seg.finished || seg.readers > 0 || seg.data == nil {
+		return
+	}
+	atomic.AddInt64(&s.client.readAheadBytes, -int64(len(seg.data)))
+	putReadAheadBuffer(seg.data)
+	seg.data = nil
+}
+
+// dropReadAheads drops the data prefetched, which is stale once the file is written or truncated.
+func (s *Streamer) dropReadAheads() {
+	s.readAheadLock.Lock()
+	for _, ra := range s.readAheads {
+		s.dropSegments(ra.segments)
+		ra.segments = nil
+	}
+	s.readAheadLock.Unlock()
+}
+
+// closeReadAheads drops the readahead states of all the handles once the streamer is removed.
+func (s *Streamer) closeReadAheads() {
+	s.readAheadLock.Lock()
+	for handle, ra := range s.readAheads {
+		s.dropSegments(ra.segments)
+		delete(s.readAheads, handle)
+	}
+	s.readAheadLock.Unlock()
+}
+
+// CloseReadAhead drops the readahead state of the released handle.
+func (client *ExtentClient) CloseReadAhead(inode, handle uint64) {
+	s := client.GetStreamer(inode)
+	if s == nil {
+		return
+	}
+	s.readAheadLock.Lock()
+	if ra, ok := s.readAheads[handle]; ok {
+		s.dropSegments(ra.segments)
+		delete(s.readAheads, handle)
+	}
+	s.readAheadLock.Unlock()
+}
+
+// ReadAheadStats returns the readahead states of the open handles.
+func (client *ExtentClient) ReadAheadStats() (stats []ReadAheadStat) {
+	client.streamerLock.Lock()
+	streamers := make([]*Streamer, 0, len(client.streamers))
+	for _, s := range client.streamers {
+		streamers = append(streamers, s)
+	}
+	client.streamerLock.Unlock()
+
+	for _, s := range streamers {
+		s.readAheadLock.Lock()
+		for _, ra := range s.readAheads {
+			stat := ra.stat
+			stat.Window = ra.window
+			stats = append(stats, stat)
+		}
+		s.readAheadLock.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Inode != stats[j].Inode {
+			return stats[i].Inode < stats[j].Inode
+		}
+		return stats[i].Handle < stats[j].Handle
+	})
+	return
+}
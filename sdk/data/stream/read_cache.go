@@ -0,0 +1,289 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	ReadCacheBlockSize   = util.MB
+	DefaultReadCacheSize = 10 * util.GB
+
+	readCacheDirName        = "cfs_read_cache"
+	readCacheSubDirs        = 256
+	readCacheExpireInterval = time.Minute
+)
+
+// readCacheKey identifies a block of an extent. The generation of the inode is a part of the key,
+// so that the blocks read before the extents of the inode change are never hit.
+type readCacheKey struct {
+	partitionID uint64
+	extentID    uint64
+	offset      uint64 // aligned by the block size in the extent
+	gen         uint64
+}
+
+type readCacheBlock struct {
+	key   readCacheKey
+	inode uint64
+	atime time.Time
+}
+
+// readCacheInode is the blocks of an inode cached under its generation and modify time, which
+// are all invalidated once either of them changes.
+type readCacheInode struct {
+	gen    uint64
+	mtime  int64
+	blocks map[readCacheKey]*list.Element
+}
+
+// ReadCache caches the blocks of the extents read by the client in the files of a local directory,
+// usually on an NVMe SSD. The blocks are evicted in LRU order once the total size exceeds the
+// capacity, or once they are not read within the expiration if any. The index is kept in memory,
+// so the cached blocks are discarded when the client restarts.
+type ReadCache struct {
+	dir        string
+	capacity   int64
+	expiration time.Duration
+
+	sync.Mutex
+	used   int64
+	lru    *list.List // of *readCacheBlock, the most recently read first
+	blocks map[readCacheKey]*list.Element
+	inodes map[uint64]*readCacheInode
+
+	hits   uint64
+	misses uint64
+
+	stopC chan struct{}
+}
+
+// NewReadCache creates the read cache in the directory, the blocks left by the last run are removed.
+func NewReadCache(dir string, capacity int64, expiration time.Duration) (c *ReadCache, err error) {
+	if capacity <= 0 {
+		capacity = DefaultReadCacheSize
+	}
+	c = &ReadCache{
+		dir:        filepath.Join(dir, readCacheDirName),
+		capacity:   capacity,
+		expiration: expiration,
+		lru:        list.New(),
+		blocks:     make(map[readCacheKey]*list.Element),
+		inodes:     make(map[uint64]*readCacheInode),
+		stopC:      make(chan struct{}),
+	}
+	if err = os.RemoveAll(c.dir); err != nil {
+		return nil, err
+	}
+	for i := 0; i < readCacheSubDirs; i++ {
+		if err = os.MkdirAll(filepath.Join(c.dir, fmt.Sprintf("%02x", i)), 0700); err != nil {
+			return nil, err
+		}
+	}
+	if expiration > 0 {
+		go c.expireBlocks()
+	}
+	log.LogInfof("NewReadCache: dir(%v) capacity(%v) expiration(%v)", c.dir, capacity, expiration)
+	return
+}
+
+func (c *ReadCache) blockPath(key readCacheKey) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%02x", key.extentID%readCacheSubDirs),
+		fmt.Sprintf("%v_%v_%v_%v", key.partitionID, key.extentID, key.offset, key.gen))
+}
+
+// validInode returns the blocks of the inode, after the ones of another generation or modify time
+// are removed.
+func (c *ReadCache) validInode(inode, gen uint64, mtime int64, removed []string) (*readCacheInode, []string) {
+	ci, ok := c.inodes[inode]
+	if !ok {
+		return nil, removed
+	}
+	if ci.gen == gen && ci.mtime == mtime {
+		return ci, removed
+	}
+	return nil, c.removeInode(inode, removed)
+}
+
+func (c *ReadCache) removeInode(inode uint64, removed []string) []string {
+	ci, ok := c.inodes[inode]
+	if !ok {
+		return removed
+	}
+	for _, element := range ci.blocks {
+		removed = c.removeBlock(element, removed)
+	}
+	return removed
+}
+
+func (c *ReadCache) removeBlock(element *list.Element, removed []string) []string {
+	block := c.lru.Remove(element).(*readCacheBlock)
+	delete(c.blocks, block.key)
+	if ci, ok := c.inodes[block.inode]; ok {
+		delete(ci.blocks, block.key)
+		if len(ci.blocks) == 0 {
+			delete(c.inodes, block.inode)
+		}
+	}
+	c.used -= ReadCacheBlockSize
+	return append(removed, c.blockPath(block.key))
+}
+
+// removeFiles deletes the files of the removed blocks out of the lock.
+func (c *ReadCache) removeFiles(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.LogWarnf("ReadCache: remove block fail: path(%v) err(%v)", path, err)
+		}
+	}
+}
+
+// get returns the block of the key cached for the inode of the generation and modify time.
+func (c *ReadCache) get(inode, gen uint64, mtime int64, key readCacheKey) (data []byte, ok bool) {
+	c.Lock()
+	ci, removed := c.validInode(inode, gen, mtime, nil)
+	var element *list.Element
+	if ci != nil {
+		element = ci.blocks[key]
+	}
+	if element != nil {
+		element.Value.(*readCacheBlock).atime = time.Now()
+		c.lru.MoveToFront(element)
+	}
+	c.Unlock()
+	c.removeFiles(removed)
+
+	if element != nil {
+		var err error
+		if data, err = ioutil.ReadFile(c.blockPath(key)); err == nil && len(data) == ReadCacheBlockSize {
+			atomic.AddUint64(&c.hits, 1)
+			return data, true
+		}
+		log.LogWarnf("ReadCache: read block fail: ino(%v) key(%v) size(%v) err(%v)", inode, key, len(data), err)
+		c.Lock()
+		if c.blocks[key] == element {
+			removed = c.removeBlock(element, nil)
+		}
+		c.Unlock()
+		c.removeFiles(removed)
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return nil, false
+}
+
+// put caches the block read for the inode of the generation and modify time.
+func (c *ReadCache) put(inode, gen uint64, mtime int64, key readCacheKey, data []byte) {
+	var path = c.blockPath(key)
+	f, err := ioutil.TempFile(filepath.Dir(path), "tmp_")
+	if err != nil {
+		log.LogWarnf("ReadCache: create block fail: ino(%v) key(%v) err(%v)", inode, key, err)
+		return
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		log.LogWarnf("ReadCache: write block fail: ino(%v) key(%v) err(%v)", inode, key, err)
+		os.Remove(f.Name())
+		return
+	}
+
+	c.Lock()
+	ci, removed := c.validInode(inode, gen, mtime, nil)
+	if ci == nil {
+		ci = &readCacheInode{gen: gen, mtime: mtime, blocks: make(map[readCacheKey]*list.Element)}
+		c.inodes[inode] = ci
+	}
+	if element, ok := c.blocks[key]; ok {
+		// the same block is cached by another read, or for another inode sharing the extent
+		removed = c.removeBlock(element, removed)
+		// the file just written is the block itself
+		removed = removed[:len(removed)-1]
+		if _, ok = c.inodes[inode]; !ok {
+			c.inodes[inode] = ci
+		}
+	}
+	element := c.lru.PushFront(&readCacheBlock{key: key, inode: inode, atime: time.Now()})
+	c.blocks[key] = element
+	ci.blocks[key] = element
+	c.used += ReadCacheBlockSize
+	for c.used > c.capacity && c.lru.Len() > 1 {
+		removed = c.removeBlock(c.lru.Back(), removed)
+	}
+	c.Unlock()
+	c.removeFiles(removed)
+}
+
+// invalidate removes the blocks cached for the inode, which is modified by the client.
+func (c *ReadCache) invalidate(inode uint64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	removed := c.removeInode(inode, nil)
+	c.Unlock()
+	c.removeFiles(removed)
+}
+
+func (c *ReadCache) expireBlocks() {
+	ticker := time.NewTicker(readCacheExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopC:
+			return
+		case <-ticker.C:
+		}
+		var removed []string
+		c.Lock()
+		for element := c.lru.Back(); element != nil; element = c.lru.Back() {
+			if time.Since(element.Value.(*readCacheBlock).atime) < c.expiration {
+				break
+			}
+			removed = c.removeBlock(element, removed)
+		}
+		c.Unlock()
+		c.removeFiles(removed)
+		log.LogInfof("ReadCache: blocks expired(%v) hits(%v) misses(%v)", len(removed),
+			atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses))
+	}
+}
+
+func (c *ReadCache) close() {
+	if c == nil {
+		return
+	}
+	close(c.stopC)
+	c.Lock()
+	defer c.Unlock()
+	log.LogInfof("ReadCache: closed: blocks(%v) hits(%v) misses(%v)", c.lru.Len(),
+		atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses))
+}
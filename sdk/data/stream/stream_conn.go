@@ -20,10 +20,12 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/sdk/data/wrapper"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 var (
@@ -35,7 +37,7 @@ const (
 	StreamSendSleepInterval = 100 * time.Millisecond
 )
 
-type GetReplyFunc func(conn *net.TCPConn) (err error, again bool)
+type GetReplyFunc func(conn net.Conn) (err error, again bool)
 
 // StreamConn defines the struct of the stream connection.
 type StreamConn struct {
@@ -56,12 +58,23 @@ func NewStreamConn(dp *wrapper.DataPartition, follower bool) *StreamConn {
 		}
 	}
 
+	// the replicas being filled by the tiering are skipped unless no other replica is left
+	hosts := make([]string, 0, len(dp.Hosts))
+	for _, host := range dp.Hosts {
+		if !isMigratingHost(dp, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = dp.Hosts
+	}
+
 	epoch := atomic.AddUint64(&dp.Epoch, 1)
-	choice := len(dp.Hosts)
+	choice := len(hosts)
 	currAddr := dp.LeaderAddr
 	if choice > 0 {
 		index := int(epoch) % choice
-		currAddr = dp.Hosts[index]
+		currAddr = hosts[index]
 	}
 
 	return &StreamConn{
@@ -78,6 +91,14 @@ func (sc *StreamConn) String() string {
 // Send send the given packet over the network through the stream connection until success
 // or the maximum number of retries is reached.
 func (sc *StreamConn) Send(req *Packet, getReply GetReplyFunc) (err error) {
+	if span := tracing.StartRootSpan("client." + req.GetOpMsg()); span != nil {
+		span.SetTag("partition", req.PartitionID)
+		span.SetTag("extent", req.ExtentID)
+		req.Trace = span.Context()
+		defer func() {
+			span.Finish(err)
+		}()
+	}
 	for i := 0; i < StreamSendMaxRetry; i++ {
 		err = sc.sendToPartition(req, getReply)
 		if err == nil {
@@ -86,7 +107,8 @@ func (sc *StreamConn) Send(req *Packet, getReply GetReplyFunc) (err error) {
 		log.LogWarnf("StreamConn Send: err(%v)", err)
 		time.Sleep(StreamSendSleepInterval)
 	}
-	return errors.New(fmt.Sprintf("StreamConn Send: retried %v times and still failed, sc(%v) reqPacket(%v)", StreamSendMaxRetry, sc, req))
+	err = errors.New(fmt.Sprintf("StreamConn Send: retried %v times and still failed, sc(%v) reqPacket(%v)", StreamSendMaxRetry, sc, req))
+	return
 }
 
 func (sc *StreamConn) sendToPartition(req *Packet, getReply GetReplyFunc) (err error) {
@@ -105,6 +127,9 @@ func (sc *StreamConn) sendToPartition(req *Packet, getReply GetReplyFunc) (err e
 	}
 
 	for _, addr := range sc.dp.Hosts {
+		if isReadOp(req) && isMigratingHost(sc.dp, addr) {
+			continue
+		}
 		log.LogWarnf("sendToPartition: try addr(%v) reqPacket(%v)", addr, req)
 		conn, err = StreamConnPool.GetConnect(addr)
 		if err != nil {
@@ -126,7 +151,22 @@ func (sc *StreamConn) sendToPartition(req *Packet, getReply GetReplyFunc) (err e
 	return errors.New(fmt.Sprintf("sendToPatition Failed: sc(%v) reqPacket(%v)", sc, req))
 }
 
-func (sc *StreamConn) sendToConn(conn *net.TCPConn, req *Packet, getReply GetReplyFunc) (err error) {
+// isMigratingHost tells whether the replica on the host is being filled by the tiering, which
+// does not have all the extents of the partition yet.
+func isMigratingHost(dp *wrapper.DataPartition, host string) bool {
+	for _, migrating := range dp.MigratingHosts {
+		if migrating == host {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadOp(req *Packet) bool {
+	return req.Opcode == proto.OpStreamRead || req.Opcode == proto.OpStreamFollowerRead
+}
+
+func (sc *StreamConn) sendToConn(conn net.Conn, req *Packet, getReply GetReplyFunc) (err error) {
 	for i := 0; i < StreamSendMaxRetry; i++ {
 		log.LogDebugf("sendToConn: send to addr(%v), reqPacket(%v)", sc.currAddr, req)
 		err = req.WriteToConn(conn)
@@ -19,8 +19,10 @@ import (
 	"golang.org/x/net/context"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
@@ -48,6 +50,15 @@ type Streamer struct {
 	done    chan struct{}    // stream writer is being closed
 
 	writeLock sync.Mutex
+
+	digest digestVerifier // verifies the digests of the extent keys read sequentially
+
+	mtime int64 // modify time of the inode in nanoseconds, which the blocks of the read cache are valid under
+
+	writeBack writeBack // dirty data of the sequential writes, if the write back is enabled
+
+	readAheads    map[uint64]*readAhead // by the handles
+	readAheadLock sync.Mutex
 }
 
 // NewStreamer returns a new streamer.
@@ -59,6 +70,7 @@ func NewStreamer(client *ExtentClient, inode uint64) *Streamer {
 	s.request = make(chan interface{}, 1000)
 	s.done = make(chan struct{})
 	s.dirtylist = NewDirtyExtentList()
+	s.readAheads = make(map[uint64]*readAhead)
 	go s.server()
 	return s
 }
@@ -94,6 +106,11 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 
 	ctx := context.Background()
 	s.client.readLimiter.Wait(ctx)
+	if s.client.followerRead {
+		s.client.waitRateLimit(proto.OpStreamFollowerRead, size)
+	} else {
+		s.client.waitRateLimit(proto.OpStreamRead, size)
+	}
 
 	requests = s.extents.PrepareReadRequests(offset, size, data)
 	for _, req := range requests {
@@ -145,7 +162,7 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 			if err != nil {
 				break
 			}
-			readBytes, err = reader.Read(req)
+			readBytes, err = s.readExtent(reader, req)
 			log.LogDebugf("Stream read: ino(%v) req(%v) readBytes(%v) err(%v)", s.inode, req, readBytes, err)
 			total += readBytes
 			if err != nil || readBytes < req.Size {
@@ -154,6 +171,63 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 				}
 				break
 			}
+			if s.client.integrityDigest {
+				if err = s.digest.verify(req); err != nil {
+					log.LogErrorf("Stream read: ino(%v) req(%v) err(%v)", s.inode, req, err)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+// readExtent reads the request by the extent reader, through the read cache if enabled. Only the
+// blocks inside the extent key are cached, the partial ones at its ends are read directly.
+func (s *Streamer) readExtent(reader *ExtentReader, req *ExtentRequest) (readBytes int, err error) {
+	cache := s.client.readCache
+	if cache == nil {
+		return reader.Read(req)
+	}
+	var (
+		ek       = req.ExtentKey
+		_, gen   = s.extents.Size()
+		mtime    = atomic.LoadInt64(&s.mtime)
+		extStart = int(ek.ExtentOffset)
+		extEnd   = extStart + int(ek.Size)
+		offset   = req.FileOffset - int(ek.FileOffset) + extStart
+	)
+	for readBytes < req.Size {
+		pos := offset + readBytes
+		blockStart := pos / ReadCacheBlockSize * ReadCacheBlockSize
+		size := util.Min(blockStart+ReadCacheBlockSize-pos, req.Size-readBytes)
+		data := req.Data[readBytes : readBytes+size]
+
+		var block []byte
+		if blockStart >= extStart && blockStart+ReadCacheBlockSize <= extEnd {
+			key := readCacheKey{partitionID: ek.PartitionId, extentID: ek.ExtentId, offset: uint64(blockStart), gen: gen}
+			var ok bool
+			if block, ok = cache.get(s.inode, gen, mtime, key); !ok {
+				block = make([]byte, ReadCacheBlockSize)
+				blockReq := &ExtentRequest{FileOffset: int(ek.FileOffset) + blockStart - extStart, Size: ReadCacheBlockSize, Data: block, ExtentKey: ek}
+				if read, e := reader.Read(blockReq); e == nil && read == ReadCacheBlockSize {
+					cache.put(s.inode, gen, mtime, key, block)
+				} else {
+					block = nil
+				}
+			}
+		}
+		if block != nil {
+			copy(data, block[pos-blockStart:])
+			readBytes += size
+			continue
+		}
+
+		var read int
+		read, err = reader.Read(&ExtentRequest{FileOffset: req.FileOffset + readBytes, Size: size, Data: data, ExtentKey: ek})
+		readBytes += read
+		if err != nil || read < size {
+			return
 		}
 	}
 	return
@@ -47,6 +47,10 @@ const (
 	streamWriterIdleTimeoutPeriod = 10
 )
 
+// ExtentFrozenError is returned by the overwrite of an extent frozen by the snapshots of the
+// volume, whose data is written to a new extent instead.
+var ExtentFrozenError = errors.New("extent frozen by snapshot")
+
 // OpenRequest defines an open request.
 type OpenRequest struct {
 	done chan struct{}
@@ -185,6 +189,7 @@ func (s *Streamer) server() {
 				if s.idle >= streamWriterIdleTimeoutPeriod && len(s.request) == 0 {
 					delete(s.client.streamers, s.inode)
 					s.client.streamerLock.Unlock()
+					s.closeReadAheads()
 
 					// fail the remaining requests in such case
 					s.clearRequests()
@@ -261,6 +266,15 @@ func (s *Streamer) write(data []byte, offset, size int, direct bool) (total int,
 
 	ctx := context.Background()
 	s.client.writeLimiter.Wait(ctx)
+	s.client.waitRateLimit(proto.OpWrite, size)
+
+	// the blocks read concurrently are invalidated again once written
+	s.client.readCache.invalidate(s.inode)
+	s.dropReadAheads()
+	defer func() {
+		s.client.readCache.invalidate(s.inode)
+		s.dropReadAheads()
+	}()
 
 	requests := s.extents.PrepareWriteRequests(offset, size, data)
 	log.LogDebugf("Streamer write: ino(%v) prepared requests(%v)", s.inode, requests)
@@ -281,6 +295,11 @@ func (s *Streamer) write(data []byte, offset, size int, direct bool) (total int,
 		var writeSize int
 		if req.ExtentKey != nil {
 			writeSize, err = s.doOverwrite(req, direct)
+			if err == ExtentFrozenError {
+				var written int
+				written, err = s.doWrite(req.Data[writeSize:], req.FileOffset+writeSize, req.Size-writeSize, direct)
+				writeSize += written
+			}
 		} else {
 			writeSize, err = s.doWrite(req.Data, req.FileOffset, req.Size, direct)
 		}
@@ -319,6 +338,13 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		return
 	}
 
+	if req.ExtentKey.CRC != 0 {
+		if err = s.clearDigest(req.ExtentKey); err != nil {
+			err = errors.Trace(err, "doOverwrite: ino(%v) failed to clear digest, ek(%v)", s.inode, req.ExtentKey)
+			return
+		}
+	}
+
 	if dp, err = s.client.dataWrapper.GetDataPartition(req.ExtentKey.PartitionId); err != nil {
 		// TODO unhandled error
 		errors.Trace(err, "doOverwrite: ino(%v) failed to get datapartition, ek(%v)", s.inode, req.ExtentKey)
@@ -338,7 +364,7 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		reqPacket.CRC = crc32.ChecksumIEEE(reqPacket.Data[:packSize])
 
 		replyPacket := new(Packet)
-		err = sc.Send(reqPacket, func(conn *net.TCPConn) (error, bool) {
+		err = sc.Send(reqPacket, func(conn net.Conn) (error, bool) {
 			e := replyPacket.ReadFromConn(conn, proto.ReadDeadlineTime)
 			if e != nil {
 				log.LogWarnf("Stream Writer doOverwrite: ino(%v) failed to read from connect, req(%v) err(%v)", s.inode, reqPacket, e)
@@ -360,6 +386,12 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		reqPacket.Data = nil
 		log.LogDebugf("doOverwrite: ino(%v) req(%v) reqPacket(%v) err(%v) replyPacket(%v)", s.inode, req, reqPacket, err, replyPacket)
 
+		if err == nil && replyPacket.ResultCode == proto.OpExtentFrozenErr {
+			log.LogDebugf("doOverwrite: ino(%v) extent frozen, req(%v) total(%v)", s.inode, req, total)
+			err = ExtentFrozenError
+			break
+		}
+
 		if err != nil || replyPacket.ResultCode != proto.OpOk {
 			err = errors.New(fmt.Sprintf("doOverwrite: failed or reply NOK: err(%v) ino(%v) req(%v) replyPacket(%v)", err, s.inode, req, replyPacket))
 			break
@@ -529,6 +561,7 @@ func (s *Streamer) evict() error {
 	}
 	delete(s.client.streamers, s.inode)
 	s.client.streamerLock.Unlock()
+	s.closeReadAheads()
 	return nil
 }
 
@@ -546,6 +579,8 @@ func (s *Streamer) abort() {
 }
 
 func (s *Streamer) truncate(size int) error {
+	s.client.readCache.invalidate(s.inode)
+	s.dropReadAheads()
 	s.closeOpenHandler()
 	err := s.flush()
 	if err != nil {
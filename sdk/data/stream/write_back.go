@@ -0,0 +1,157 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	WriteBackBlockSize       = util.MB
+	DefaultWriteBackDirty    = 256 * util.MB
+	DefaultWriteBackDelay    = 3 * time.Second
+	writeBackFlusherInterval = 500 * time.Millisecond
+)
+
+var writeBackPool = &sync.Pool{New: func() interface{} {
+	return make([]byte, 0, WriteBackBlockSize)
+}}
+
+// writeBack buffers the dirty data of the sequential writes to a file, which is written by the
+// streamer as one request once the writes are not continuous, the buffer is full or delayed too long,
+// or a barrier such as fsync, close, read or truncate is met.
+type writeBack struct {
+	sync.Mutex
+	offset int
+	data   []byte
+	since  time.Time // when the first byte is buffered
+	err    error     // first failed flush since the last fsync or close, which is kept until reported by them
+}
+
+// end returns the end of the dirty data in the file, or zero if no data is buffered.
+func (wb *writeBack) end() int {
+	wb.Lock()
+	defer wb.Unlock()
+	if len(wb.data) == 0 {
+		return 0
+	}
+	return wb.offset + len(wb.data)
+}
+
+// writeBackWrite buffers the data if it continues the dirty data, and writes through the streamer
+// otherwise, or if it is large or the dirty data of the client is over the limit.
+func (s *Streamer) writeBackWrite(offset int, data []byte) (write int, err error) {
+	wb := &s.writeBack
+	wb.Lock()
+	defer wb.Unlock()
+
+	if len(wb.data) > 0 && (offset != wb.offset+len(wb.data) || len(wb.data)+len(data) > WriteBackBlockSize) {
+		if err = s.flushWriteBackLocked(); err != nil {
+			return
+		}
+	}
+	if len(data) >= WriteBackBlockSize || atomic.LoadInt64(&s.client.dirtyBytes)+int64(len(data)) > s.client.writeBackLimit {
+		if err = s.flushWriteBackLocked(); err != nil {
+			return
+		}
+		return s.IssueWriteRequest(offset, data, false)
+	}
+
+	if len(wb.data) == 0 {
+		wb.offset = offset
+		wb.since = time.Now()
+		wb.data = writeBackPool.Get().([]byte)
+	}
+	wb.data = append(wb.data, data...)
+	atomic.AddInt64(&s.client.dirtyBytes, int64(len(data)))
+	return len(data), nil
+}
+
+// flushWriteBackLocked writes the dirty data through the streamer. The error is also kept for the
+// next fsync or close, since the data has been acknowledged to the writes before.
+func (s *Streamer) flushWriteBackLocked() (err error) {
+	wb := &s.writeBack
+	if len(wb.data) == 0 {
+		return
+	}
+	var write int
+	write, err = s.IssueWriteRequest(wb.offset, wb.data, false)
+	if err == nil && write < len(wb.data) {
+		err = fmt.Errorf("flushWriteBack: short write, ino(%v) offset(%v) size(%v) write(%v)", s.inode, wb.offset, len(wb.data), write)
+	}
+	atomic.AddInt64(&s.client.dirtyBytes, -int64(len(wb.data)))
+	writeBackPool.Put(wb.data[:0])
+	wb.data = nil
+	if err != nil && wb.err == nil {
+		wb.err = err
+	}
+	return
+}
+
+// flushWriteBack writes the dirty data through the streamer before a read, truncate or direct
+// write, which only returns the error of this flush.
+func (s *Streamer) flushWriteBack() (err error) {
+	wb := &s.writeBack
+	wb.Lock()
+	defer wb.Unlock()
+	return s.flushWriteBackLocked()
+}
+
+// writeBackBarrier writes the dirty data through the streamer for a fsync or close, and reports
+// the first failed flush since the last one, which is then cleared.
+func (s *Streamer) writeBackBarrier() (err error) {
+	wb := &s.writeBack
+	wb.Lock()
+	defer wb.Unlock()
+	s.flushWriteBackLocked()
+	err, wb.err = wb.err, nil
+	return
+}
+
+// flushWriteBacks writes the dirty data buffered longer than the delay in the background.
+func (client *ExtentClient) flushWriteBacks() {
+	ticker := time.NewTicker(writeBackFlusherInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-client.stopC:
+			return
+		case <-ticker.C:
+		}
+		client.streamerLock.Lock()
+		streamers := make([]*Streamer, 0, len(client.streamers))
+		for _, s := range client.streamers {
+			streamers = append(streamers, s)
+		}
+		client.streamerLock.Unlock()
+
+		for _, s := range streamers {
+			wb := &s.writeBack
+			wb.Lock()
+			if len(wb.data) > 0 && time.Since(wb.since) >= client.writeBackDelay {
+				if err := s.flushWriteBackLocked(); err != nil {
+					log.LogErrorf("flushWriteBacks: ino(%v) err(%v)", s.inode, err)
+				}
+			}
+			wb.Unlock()
+		}
+	}
+}
@@ -79,7 +79,12 @@ func (w *Wrapper) updateClusterInfo() (err error) {
 		log.LogWarnf("UpdateClusterInfo: get cluster info fail: err(%v)", err)
 		return
 	}
-	log.LogInfof("UpdateClusterInfo: get cluster info: cluster(%v) localIP(%v)", info.Cluster, info.Ip)
+	log.LogInfof("UpdateClusterInfo: get cluster info: cluster(%v) localIP(%v) minClientVersion(%v)",
+		info.Cluster, info.Ip, info.MinClientVersion)
+	if err = proto.CheckClientVersion(info.MinClientVersion); err != nil {
+		log.LogErrorf("UpdateClusterInfo: %v", err)
+		return
+	}
 	w.clusterName = info.Cluster
 	LocalIP = info.Ip
 	return
@@ -98,6 +103,11 @@ func (w *Wrapper) getSimpleVolView() (err error) {
 	return nil
 }
 
+// GetRateLimits returns the rate limits of the cluster.
+func (w *Wrapper) GetRateLimits() ([]*proto.RateLimitRule, error) {
+	return w.mc.AdminAPI().GetRateLimits()
+}
+
 func (w *Wrapper) update() {
 	ticker := time.NewTicker(time.Minute)
 	for {
@@ -157,6 +167,7 @@ func (w *Wrapper) replaceOrInsertPartition(dp *DataPartition) {
 		old.Status = dp.Status
 		old.ReplicaNum = dp.ReplicaNum
 		old.Hosts = dp.Hosts
+		old.MigratingHosts = dp.MigratingHosts
 	} else {
 		dp.Metrics = NewDataPartitionMetrics()
 		w.partitions[dp.PartitionID] = dp
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/chubaofs/chubaofs/proto"
 )
@@ -64,6 +65,15 @@ func (api *AdminAPI) LoadDataPartition(volName string, partitionID uint64) (err
 	return
 }
 
+func (api *AdminAPI) LoadMetaPartition(metaPartitionID uint64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminLoadMetaPartition)
+	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) CreateDataPartition(volName string, count int) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminCreateDataPartition)
 	request.addParam("name", volName)
@@ -84,6 +94,16 @@ func (api *AdminAPI) DecommissionDataPartition(dataPartitionID uint64, nodeAddr
 	return
 }
 
+func (api *AdminAPI) DecommissionMetaPartition(metaPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminDecommissionMetaPartition)
+	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) DeleteDataReplica(dataPartitionID uint64, nodeAddr string) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteDataReplica)
 	request.addParam("id", strconv.FormatUint(dataPartitionID, 10))
@@ -104,6 +124,48 @@ func (api *AdminAPI) AddDataReplica(dataPartitionID uint64, nodeAddr string) (er
 	return
 }
 
+func (api *AdminAPI) AddDataLearner(dataPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminAddDataReplica)
+	request.addParam("id", strconv.FormatUint(dataPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	request.addParam("learner", "true")
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) PromoteDataLearner(dataPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminPromoteDataReplica)
+	request.addParam("id", strconv.FormatUint(dataPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) AddMetaLearner(metaPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminAddMetaReplica)
+	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	request.addParam("learner", "true")
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) PromoteMetaLearner(metaPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminPromoteMetaReplica)
+	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) DeleteVolume(volName, authKey string) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteVol)
 	request.addParam("name", volName)
@@ -178,3 +240,488 @@ func (api *AdminAPI) CreateMetaPartition(volName string, inodeStart uint64) (err
 	}
 	return
 }
+
+func (api *AdminAPI) SetRateLimit(rule *proto.RateLimitRule) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetRateLimit)
+	request.addParam("module", rule.Module)
+	request.addParam("vol", rule.Vol)
+	request.addParam("op", rule.Op)
+	request.addParam("client", rule.Client)
+	request.addParam("rate", strconv.FormatFloat(rule.Rate, 'f', -1, 64))
+	request.addParam("burst", strconv.Itoa(rule.Burst))
+	request.addParam("bytes", strconv.FormatFloat(rule.Bytes, 'f', -1, 64))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListEvents(from uint64, eventType string, limit int) (view *proto.ClusterEventsView, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListEvents)
+	request.addParam("from", strconv.FormatUint(from, 10))
+	request.addParam("type", eventType)
+	request.addParam("limit", strconv.Itoa(limit))
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	view = &proto.ClusterEventsView{}
+	if err = json.Unmarshal(data, view); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumeLifecycle(volName, authKey string, rules []*proto.LifecycleRule) (err error) {
+	var request = newAPIRequest(http.MethodPost, proto.AdminSetVolLifecycle)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	var body []byte
+	if body, err = json.Marshal(rules); err != nil {
+		return
+	}
+	request.addBody(body)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetVolumeLifecycle(volName string) (lc *proto.LifecycleConfiguration, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetVolLifecycle)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	lc = &proto.LifecycleConfiguration{}
+	if err = json.Unmarshal(data, lc); err != nil {
+		return
+	}
+	return
+}
+
+// SetVolumePolicy sets the bucket policy of the volume, which is removed if it is empty.
+func (api *AdminAPI) SetVolumePolicy(volName, authKey string, policy []byte) (err error) {
+	var request = newAPIRequest(http.MethodPost, proto.AdminSetVolPolicy)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addBody(policy)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetVolumePolicy(volName string) (policy *proto.BucketPolicy, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetVolPolicy)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	policy = &proto.BucketPolicy{}
+	if err = json.Unmarshal(data, policy); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListVolumeLifecycles() (lcs []*proto.LifecycleConfiguration, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListVolLifecycles)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &lcs); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumeQuota(volName, authKey, path string, rootInode, maxFiles, maxBytes uint64) (quota *proto.QuotaInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetVolQuota)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("path", path)
+	request.addParam("inode", strconv.FormatUint(rootInode, 10))
+	request.addParam("maxFiles", strconv.FormatUint(maxFiles, 10))
+	request.addParam("maxBytes", strconv.FormatUint(maxBytes, 10))
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	quota = &proto.QuotaInfo{}
+	if err = json.Unmarshal(data, quota); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) DeleteVolumeQuota(volName, authKey string, quotaID uint32) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteVolQuota)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("quotaId", strconv.FormatUint(uint64(quotaID), 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListVolumeQuotas(volName string) (quotas []*proto.QuotaInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListVolQuotas)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &quotas); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumeTrash(volName, authKey string, retention uint32) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetVolTrash)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("retention", strconv.FormatUint(uint64(retention), 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumeCompression(volName, authKey, compression string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetVolCompression)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("compression", compression)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumeEC(volName, authKey, scheme string, coldAge int64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetVolEC)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("ecScheme", scheme)
+	if coldAge > 0 {
+		request.addParam("ecColdAge", strconv.FormatInt(coldAge, 10))
+	}
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumeTiering(volName, authKey, hotMedia, coldMedia string, coldAge int64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetVolTiering)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("hotMedia", hotMedia)
+	request.addParam("coldMedia", coldMedia)
+	if coldAge > 0 {
+		request.addParam("coldAge", strconv.FormatInt(coldAge, 10))
+	}
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) SetVolumePlacement(volName, authKey string, zoneSpread, rackSpread uint8) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetVolPlacement)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("zoneSpread", strconv.Itoa(int(zoneSpread)))
+	request.addParam("rackSpread", strconv.Itoa(int(rackSpread)))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ShrinkVolume(volName, authKey string, capacity uint64) (releasing []uint64, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminShrinkVol)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("capacity", strconv.FormatUint(capacity, 10))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &releasing); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ReleaseDataPartition(volName, authKey string, partitionID uint64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminReleaseDataPartition)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("id", strconv.FormatUint(partitionID, 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetECPartition(partitionID uint64) (partition *proto.ECPartitionInfo, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetECPartition)
+	request.addParam("id", strconv.FormatUint(partitionID, 10))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	partition = &proto.ECPartitionInfo{}
+	if err = json.Unmarshal(buf, partition); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) CreateVolumeSnapshot(volName, authKey, snapName string) (snapshot *proto.VolSnapshot, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminCreateVolSnapshot)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("snapshot", snapName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	snapshot = &proto.VolSnapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) DeleteVolumeSnapshot(volName, authKey, snapName string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteVolSnapshot)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("snapshot", snapName)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListVolumeSnapshots(volName string) (snapshots []*proto.VolSnapshot, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListVolSnapshots)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &snapshots); err != nil {
+		return
+	}
+	return
+}
+
+// CreateVolumeAccessKey creates an access key of the volume, which is scoped by the prefixes of the
+// object keys if any, and expires after the seconds if not 0.
+func (api *AdminAPI) CreateVolumeAccessKey(volName, authKey, permission string, prefixes []string, expire int64) (key *proto.VolAccessKey, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminCreateVolAccessKey)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("permission", permission)
+	if len(prefixes) > 0 {
+		request.addParam("prefixes", strings.Join(prefixes, ","))
+	}
+	request.addParam("expire", strconv.FormatInt(expire, 10))
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	key = &proto.VolAccessKey{}
+	if err = json.Unmarshal(data, key); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListVolumeAccessKeys(volName, authKey string) (keys []*proto.VolAccessKey, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListVolAccessKeys)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return
+	}
+	return
+}
+
+// RevokeVolumeAccessKey revokes the access key of the volume, which expires after the seconds of
+// the grace period if not 0.
+func (api *AdminAPI) RevokeVolumeAccessKey(volName, authKey, accessKey string, grace int64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminRevokeVolAccessKey)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("accessKey", accessKey)
+	request.addParam("grace", strconv.FormatInt(grace, 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ReverseLookupInode(volName string, ino uint64) (links *proto.InodeLinks, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminReverseLookupInode)
+	request.addParam("name", volName)
+	request.addParam("inode", strconv.FormatUint(ino, 10))
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	links = &proto.InodeLinks{}
+	if err = json.Unmarshal(data, links); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetRateLimits() (rules []*proto.RateLimitRule, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetRateLimit)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetEncryptionKey(version uint32) (key *proto.EncryptionKey, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetEncryptionKey)
+	if version > 0 {
+		request.addParam("version", strconv.FormatUint(uint64(version), 10))
+	}
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	key = &proto.EncryptionKey{}
+	if err = json.Unmarshal(data, key); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetBalancePlan(limit int) (view *proto.BalanceView, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetBalancePlan)
+	if limit > 0 {
+		request.addParam("limit", strconv.Itoa(limit))
+	}
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	view = &proto.BalanceView{}
+	if err = json.Unmarshal(buf, view); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) PauseBalance() (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminPauseBalance)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ResumeBalance() (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminResumeBalance)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// CreateDecommissionJob creates a job draining the datanode or the metanode, at most concurrency
+// partitions at the same time, and the data partitions at most rate MB per second if it is positive.
+func (api *AdminAPI) CreateDecommissionJob(kind, addr string, concurrency, rate, maxRetries int) (job *proto.DecommissionJob, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminCreateDecommissionJob)
+	request.addParam("type", kind)
+	request.addParam("addr", addr)
+	request.addParam("concurrency", strconv.Itoa(concurrency))
+	request.addParam("rate", strconv.Itoa(rate))
+	request.addParam("maxRetries", strconv.Itoa(maxRetries))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	job = &proto.DecommissionJob{}
+	if err = json.Unmarshal(buf, job); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetDecommissionJob(id uint64) (job *proto.DecommissionJob, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetDecommissionJob)
+	request.addParam("id", strconv.FormatUint(id, 10))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	job = &proto.DecommissionJob{}
+	if err = json.Unmarshal(buf, job); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListDecommissionJobs() (jobs []*proto.DecommissionJob, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminListDecommissionJobs)
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	jobs = make([]*proto.DecommissionJob, 0)
+	if err = json.Unmarshal(buf, &jobs); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) PauseDecommissionJob(id uint64) (err error) {
+	return api.updateDecommissionJob(proto.AdminPauseDecommissionJob, id)
+}
+
+func (api *AdminAPI) ResumeDecommissionJob(id uint64) (err error) {
+	return api.updateDecommissionJob(proto.AdminResumeDecommissionJob, id)
+}
+
+func (api *AdminAPI) CancelDecommissionJob(id uint64) (err error) {
+	return api.updateDecommissionJob(proto.AdminCancelDecommissionJob, id)
+}
+
+func (api *AdminAPI) updateDecommissionJob(path string, id uint64) (err error) {
+	var request = newAPIRequest(http.MethodGet, path)
+	request.addParam("id", strconv.FormatUint(id, 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) RotateEncryptionKey() (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminRotateEncryptionKey)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
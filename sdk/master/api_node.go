@@ -102,3 +102,59 @@ func (api *NodeAPI) ResponseDataNodeTask(task *proto.AdminTask) (err error) {
 	}
 	return
 }
+
+func (api *NodeAPI) AddECNode(serverAddr string) (id uint64, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AddECNode)
+	request.addParam("addr", serverAddr)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	id, err = strconv.ParseUint(string(data), 10, 64)
+	return
+}
+
+func (api *NodeAPI) GetECNode(serverHost string) (node *proto.ECNodeInfo, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.GetECNode)
+	request.addParam("addr", serverHost)
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	node = &proto.ECNodeInfo{}
+	if err = json.Unmarshal(buf, node); err != nil {
+		return
+	}
+	return
+}
+
+func (api *NodeAPI) ResponseECNodeTask(task *proto.AdminTask) (err error) {
+	var encoded []byte
+	if encoded, err = json.Marshal(task); err != nil {
+		return
+	}
+	var request = newAPIRequest(http.MethodPost, proto.GetECNodeTaskResponse)
+	request.addBody(encoded)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *NodeAPI) DataNodeDecommission(nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.DecommissionDataNode)
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *NodeAPI) MetaNodeDecommission(nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.DecommissionMetaNode)
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
 )
 
 const (
@@ -97,7 +98,7 @@ func (c *MasterClient) serveRequest(r *request) (repsData []byte, err error) {
 		}
 		var resp *http.Response
 		var schema string
-		if c.useSSL {
+		if c.useSSL || mtls.Enabled() {
 			schema = "https"
 		} else {
 			schema = "http"
@@ -181,7 +182,7 @@ func (c *MasterClient) prepareRequest() (addr string, nodes []string) {
 }
 
 func (c *MasterClient) httpRequest(method, url string, param, header map[string]string, reqData []byte) (resp *http.Response, err error) {
-	client := &http.Client{}
+	client := &http.Client{Transport: mtls.HTTPTransport()}
 	reader := bytes.NewReader(reqData)
 	client.Timeout = requestTimeout
 	var req *http.Request
@@ -15,6 +15,7 @@
 package meta
 
 import (
+	"encoding/json"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -58,6 +59,12 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 		return nil, syscall.ENOENT
 	}
 
+	// the inode created inherits the quotas of the parent
+	quotaIDs, err := mw.CheckQuota(parentID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create Inode
 
 	//	mp = mw.getLatestPartition()
@@ -86,7 +93,7 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 	for i := 0; i < length; i++ {
 		index := (int(epoch) + i) % length
 		mp = rwPartitions[index]
-		status, info, err = mw.icreate(mp, mode, uid, gid, target)
+		status, info, err = mw.icreate(mp, mode, uid, gid, target, quotaIDs)
 		if err == nil && status == statusOK {
 			goto create_dentry
 		}
@@ -245,6 +252,19 @@ func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool) (*pro
 		}
 	}
 
+	// the dentry moved into the trash still links the inode, which is unlinked once it is purged
+	if atomic.LoadUint32(&mw.trashRetention) > 0 {
+		status, inode, err = mw.dtrash(parentMP, parentID, name)
+		if err != nil || status != statusOK {
+			if status == statusNoent {
+				return nil, nil
+			}
+			return nil, statusToErrno(status)
+		}
+		log.LogDebugf("Delete_ll: trashed, parentID(%v) name(%v) ino(%v)", parentID, name, inode)
+		return nil, nil
+	}
+
 	status, inode, err = mw.ddelete(parentMP, parentID, name)
 	if err != nil || status != statusOK {
 		if status == statusNoent {
@@ -278,6 +298,9 @@ func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID
 	if dstParentMP == nil {
 		return syscall.ENOENT
 	}
+	if err = mw.checkMoveQuota(srcParentID, dstParentID); err != nil {
+		return
+	}
 
 	// look up for the src ino
 	status, inode, mode, err := mw.lookup(srcParentMP, srcParentID, srcName)
@@ -358,6 +381,22 @@ func (mw *MetaWrapper) ReadDir_ll(parentID uint64) ([]proto.Dentry, error) {
 	return children, nil
 }
 
+// ListDentry_ll lists a page of at most limit dentries and common prefixes of the directory, which
+// begin with the prefix and are after the marker. The names containing the delimiter after the
+// prefix are rolled up into the common prefixes by the meta node.
+func (mw *MetaWrapper) ListDentry_ll(parentID uint64, prefix, delimiter, marker string, limit uint64) (*proto.ListDentryResponse, error) {
+	parentMP := mw.getPartitionByInode(parentID)
+	if parentMP == nil {
+		return nil, syscall.ENOENT
+	}
+
+	resp, status, err := mw.listDentry(parentMP, parentID, prefix, delimiter, marker, limit)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return resp, nil
+}
+
 func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32) error {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
@@ -409,7 +448,7 @@ func (mw *MetaWrapper) AppendExtentKeys(inode uint64, eks []proto.ExtentKey) err
 		return syscall.ENOENT
 	}
 
-	status, err := mw.appendExtentKeys(mp, inode, eks)
+	status, err := mw.appendExtentKeys(mp, inode, 0, eks)
 	if err != nil || status != statusOK {
 		log.LogErrorf("AppendExtentKeys: inode(%v) extentKeys(%v) err(%v) status(%v)", inode, eks, err, status)
 		return statusToErrno(status)
@@ -418,6 +457,27 @@ func (mw *MetaWrapper) AppendExtentKeys(inode uint64, eks []proto.ExtentKey) err
 	return nil
 }
 
+// AppendExtentKeysIfGeneration appends the extent keys into the inode only if its generation is
+// still gen, which is checked by the meta partition atomically. ESTALE is returned if not.
+func (mw *MetaWrapper) AppendExtentKeysIfGeneration(inode, gen uint64, eks []proto.ExtentKey) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return syscall.ENOENT
+	}
+
+	status, err := mw.appendExtentKeys(mp, inode, gen, eks)
+	if err == nil && status == statusInval {
+		log.LogWarnf("AppendExtentKeysIfGeneration: inode(%v) generation(%v) changed", inode, gen)
+		return syscall.ESTALE
+	}
+	if err != nil || status != statusOK {
+		log.LogErrorf("AppendExtentKeysIfGeneration: inode(%v) generation(%v) extentKeys(%v) err(%v) status(%v)", inode, gen, eks, err, status)
+		return statusToErrno(status)
+	}
+	log.LogDebugf("AppendExtentKeysIfGeneration: ino(%v) generation(%v) extentKeys(%v)", inode, gen, eks)
+	return nil
+}
+
 func (mw *MetaWrapper) GetExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
@@ -460,6 +520,9 @@ func (mw *MetaWrapper) Link(parentID uint64, name string, ino uint64) (*proto.In
 		log.LogErrorf("Link: No target inode partition, ino(%v)", ino)
 		return nil, syscall.ENOENT
 	}
+	if err := mw.checkMoveQuota(ino, parentID); err != nil {
+		return nil, err
+	}
 
 	// increase inode nlink
 	status, info, err := mw.ilink(mp, ino)
@@ -526,7 +589,32 @@ func (mw *MetaWrapper) InodeCreate_ll(mode, uid, gid uint32, target []byte) (*pr
 	for i := 0; i < length; i++ {
 		index := (int(epoch) + i) % length
 		mp = rwPartitions[index]
-		status, info, err = mw.icreate(mp, mode, uid, gid, target)
+		status, info, err = mw.icreate(mp, mode, uid, gid, target, nil)
+		if err == nil && status == statusOK {
+			return info, nil
+		}
+	}
+	return nil, syscall.ENOMEM
+}
+
+// InodeCloneExtents_ll is a low-level api that creates an inode with the extent keys copied from
+// another file, which is linked to a dentry afterwards as the inode created by InodeCreate_ll.
+func (mw *MetaWrapper) InodeCloneExtents_ll(mode, uid, gid uint32, extents []proto.ExtentKey) (*proto.InodeInfo, error) {
+	var (
+		status       int
+		err          error
+		info         *proto.InodeInfo
+		mp           *MetaPartition
+		rwPartitions []*MetaPartition
+	)
+
+	rwPartitions = mw.getRWPartitions()
+	length := len(rwPartitions)
+	epoch := atomic.AddUint64(&mw.epoch, 1)
+	for i := 0; i < length; i++ {
+		index := (int(epoch) + i) % length
+		mp = rwPartitions[index]
+		status, info, err = mw.cloneExtents(mp, mode, uid, gid, extents)
 		if err == nil && status == statusOK {
 			return info, nil
 		}
@@ -564,14 +652,14 @@ func (mw *MetaWrapper) InodeUnlink_ll(inode uint64) (*proto.InodeInfo, error) {
 	return info, nil
 }
 
-func (mw *MetaWrapper) InitMultipart_ll(path string, parentId uint64) (multipartId string, err error) {
+func (mw *MetaWrapper) InitMultipart_ll(path string, parentId uint64, extend map[string]string) (multipartId string, err error) {
 	mp := mw.getPartitionByInode(parentId)
 	if mp == nil {
 		log.LogErrorf("InitMultipart: No such partition, ino(%v)", parentId)
 		return "", syscall.EINVAL
 	}
 
-	status, sessionId, err := mw.createSession(mp, path)
+	status, sessionId, err := mw.createSession(mp, path, extend)
 	if err != nil || status != statusOK {
 		log.LogErrorf("InitMultipart: err(%v) status(%v)", err, status)
 		return "", statusToErrno(status)
@@ -586,12 +674,49 @@ func (mw *MetaWrapper) GetMultipart_ll(multipartId string, parentId uint64) (inf
 		return nil, syscall.EINVAL
 	}
 
-	status, multipartInfo, err := mw.getMultipart(mp, multipartId)
+	status, resp, err := mw.getMultipart(mp, multipartId, 0, 0)
 	if err != nil || status != statusOK {
 		log.LogErrorf("GetMultipartRequest: err(%v) status(%v)", err, status)
 		return nil, statusToErrno(status)
 	}
-	return multipartInfo, nil
+	return resp.Info, nil
+}
+
+// ListMultipartParts_ll returns the multipart upload with at most maxParts parts whose IDs are
+// greater than the part number marker, the marker of the next page and whether any part is left.
+func (mw *MetaWrapper) ListMultipartParts_ll(multipartId string, parentId uint64, maxParts uint64, partNumberMarker uint16) (info *proto.MultipartInfo, nextMarker uint16, isTruncated bool, err error) {
+	mp := mw.getPartitionByInode(parentId)
+	if mp == nil {
+		log.LogErrorf("ListMultipartParts: No such partition, ino(%v)", parentId)
+		return nil, 0, false, syscall.EINVAL
+	}
+
+	status, resp, err := mw.getMultipart(mp, multipartId, maxParts, partNumberMarker)
+	if err != nil || status != statusOK {
+		log.LogErrorf("ListMultipartParts: err(%v) status(%v)", err, status)
+		return nil, 0, false, statusToErrno(status)
+	}
+	return resp.Info, resp.NextPartNumberMarker, resp.IsTruncated, nil
+}
+
+// CompleteMultipart_ll completes the multipart upload in the partition of the parent, which creates
+// the file with the extent keys and links it to the name in one step. The reason is returned as
+// proto.ErrInvalidPartOrder, proto.ErrInvalidPart or proto.ErrEntityTooSmall if the parts are refused.
+func (mw *MetaWrapper) CompleteMultipart_ll(req *proto.CompleteMultipartRequest) (resp *proto.CompleteMultipartResponse, err error) {
+	mp := mw.getPartitionByInode(req.ParentId)
+	if mp == nil {
+		log.LogErrorf("CompleteMultipart: No such partition, ino(%v)", req.ParentId)
+		return nil, syscall.EINVAL
+	}
+	status, resp, err := mw.completeMultipart(mp, req)
+	if err != nil || status != statusOK {
+		log.LogErrorf("CompleteMultipart: multipartID(%v) err(%v) status(%v)", req.MultipartId, err, status)
+		if err == proto.ErrInvalidPartOrder || err == proto.ErrInvalidPart || err == proto.ErrEntityTooSmall {
+			return nil, err
+		}
+		return nil, statusToErrno(status)
+	}
+	return resp, nil
 }
 
 func (mw *MetaWrapper) AddMultipartPart_ll(multipartId string, parentId uint64, partId uint16, size uint64, md5 string, inode uint64) error {
@@ -627,6 +752,7 @@ func (mw *MetaWrapper) RemoveMultipart_ll(multipartID string, parentId uint64) e
 func (mw *MetaWrapper) ListMultipart_ll(prefix, delimiter, keyMarker string, multipartIdMarker string, maxUploads uint64) (sessionResponse []*proto.MultipartInfo, err error) {
 	partitions := mw.partitions
 	var wg = sync.WaitGroup{}
+	var mu sync.Mutex
 	//var prefixes = make([]string, 0)
 	var sessions = make([]*proto.MultipartInfo, 0)
 	//var allSessions = make([]*proto.ListMultipartResponse, 0)
@@ -643,7 +769,9 @@ func (mw *MetaWrapper) ListMultipart_ll(prefix, delimiter, keyMarker string, mul
 				return
 			}
 			//allSessions = append(allSessions, response)
+			mu.Lock()
 			sessions = append(sessions, response.Multiparts...)
+			mu.Unlock()
 		}(mp)
 	}
 
@@ -697,6 +825,294 @@ func (mw *MetaWrapper) XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo,
 	return xAttr, nil
 }
 
+// SetACL_ll is a low-level meta api that sets the access or default ACL of the inode, which is
+// removed if the ACL is empty.
+func (mw *MetaWrapper) SetACL_ll(inode uint64, isDefault bool, acl proto.ACL) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetACL_ll: no such partition, inode(%v)", inode)
+		return syscall.ENOENT
+	}
+	status, err := mw.setACL(mp, inode, isDefault, acl)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	log.LogDebugf("SetACL_ll: inode(%v) default(%v) acl(%v)", inode, isDefault, acl)
+	return nil
+}
+
+// GetACL_ll is a low-level meta api that returns the access and default ACLs of the inode with
+// the inode info.
+func (mw *MetaWrapper) GetACL_ll(inode uint64) (*proto.GetACLResponse, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("GetACL_ll: no such partition, inode(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+	resp, status, err := mw.getACL(mp, inode)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	log.LogDebugf("GetACL_ll: inode(%v) access(%v) default(%v)", inode, resp.Access, resp.Default)
+	return resp, nil
+}
+
+// ListTrash_ll lists the dentries in the trash of all the meta partitions, in the order of the
+// deletion time.
+func (mw *MetaWrapper) ListTrash_ll() ([]*proto.TrashItem, error) {
+	mw.RLock()
+	partitions := make([]*MetaPartition, 0, len(mw.partitions))
+	for _, mp := range mw.partitions {
+		partitions = append(partitions, mp)
+	}
+	mw.RUnlock()
+
+	var items []*proto.TrashItem
+	for _, mp := range partitions {
+		resp, status, err := mw.listTrash(mp)
+		if err != nil || status != statusOK {
+			log.LogErrorf("ListTrash_ll: partitionID(%v) err(%v) status(%v)", mp.PartitionID, err, status)
+			return nil, statusToErrno(status)
+		}
+		items = append(items, resp.Items...)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeleteTime < items[j].DeleteTime
+	})
+	return items, nil
+}
+
+// ReverseLookup_ll returns the link count of the inode and the dentries referring to it, which are
+// looked up in all the meta partitions, since the parents of the dentries may be anywhere.
+func (mw *MetaWrapper) ReverseLookup_ll(inode uint64) (*proto.InodeLinks, error) {
+	info, err := mw.InodeGet_ll(inode)
+	if err != nil {
+		return nil, err
+	}
+
+	mw.RLock()
+	partitions := make([]*MetaPartition, 0, len(mw.partitions))
+	for _, mp := range mw.partitions {
+		partitions = append(partitions, mp)
+	}
+	mw.RUnlock()
+
+	result := &proto.InodeLinks{Inode: inode, Nlink: info.Nlink}
+	for _, mp := range partitions {
+		links, status, err := mw.reverseLookup(mp, inode)
+		if err != nil || status != statusOK {
+			log.LogErrorf("ReverseLookup_ll: partitionID(%v) err(%v) status(%v)", mp.PartitionID, err, status)
+			return nil, statusToErrno(status)
+		}
+		result.Links = append(result.Links, links...)
+	}
+	return result, nil
+}
+
+// RestoreTrash_ll moves the dentry in the trash back under its parent, named after the original
+// name if the name is empty.
+func (mw *MetaWrapper) RestoreTrash_ll(parentID uint64, trashName, name string) error {
+	mp := mw.getPartitionByInode(parentID)
+	if mp == nil {
+		log.LogErrorf("RestoreTrash_ll: no such partition, parentID(%v)", parentID)
+		return syscall.ENOENT
+	}
+	status, err := mw.restoreTrash(mp, parentID, trashName, name)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	log.LogDebugf("RestoreTrash_ll: parentID(%v) trashName(%v) name(%v)", parentID, trashName, name)
+	return nil
+}
+
+// DeleteTree_ll detaches the directory, whose subtree is deleted by the meta node in the
+// background without moving into the trash. It returns the inode of the directory.
+func (mw *MetaWrapper) DeleteTree_ll(parentID uint64, name string) (uint64, error) {
+	mp := mw.getPartitionByInode(parentID)
+	if mp == nil {
+		log.LogErrorf("DeleteTree_ll: no such partition, parentID(%v)", parentID)
+		return 0, syscall.ENOENT
+	}
+	status, inode, err := mw.deleteTree(mp, parentID, name)
+	if err != nil || status != statusOK {
+		return 0, statusToErrno(status)
+	}
+	log.LogDebugf("DeleteTree_ll: parentID(%v) name(%v) inode(%v)", parentID, name, inode)
+	return inode, nil
+}
+
+// ListDeleteTrees_ll lists the subtree deletions of all the meta partitions with their progress,
+// in the order of the detaching time.
+func (mw *MetaWrapper) ListDeleteTrees_ll() ([]*proto.DeleteTreeJob, error) {
+	mw.RLock()
+	partitions := make([]*MetaPartition, 0, len(mw.partitions))
+	for _, mp := range mw.partitions {
+		partitions = append(partitions, mp)
+	}
+	mw.RUnlock()
+
+	var jobs []*proto.DeleteTreeJob
+	for _, mp := range partitions {
+		resp, status, err := mw.listDeleteTrees(mp)
+		if err != nil || status != statusOK {
+			log.LogErrorf("ListDeleteTrees_ll: partitionID(%v) err(%v) status(%v)", mp.PartitionID, err, status)
+			return nil, statusToErrno(status)
+		}
+		jobs = append(jobs, resp.Jobs...)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].MarkTime < jobs[j].MarkTime
+	})
+	return jobs, nil
+}
+
+// XAttrsSet_ll is a low-level meta api that sets the xattrs of the inode. The xattrs are set in
+// one raft round-trip if the metanodes support the batched xattr sets, or else in one request if
+// the metanodes batch the ops.
+func (mw *MetaWrapper) XAttrsSet_ll(inode uint64, attrs map[string][]byte) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("XAttrsSet_ll: no such partition, inode(%v)", inode)
+		return syscall.ENOENT
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	if mw.partitionSupports(mp, proto.CapMetaBatchSetXAttr) {
+		values := make(map[string]string, len(attrs))
+		for name, value := range attrs {
+			values[name] = string(value)
+		}
+		status, err := mw.batchSetXAttr(mp, inode, values)
+		if err != nil || status != statusOK {
+			return statusToErrno(status)
+		}
+		log.LogDebugf("XAttrsSet_ll: batch set xattrs, inode(%v) count(%v)", inode, len(attrs))
+		return nil
+	}
+	if len(attrs) > proto.MetaBatchMaxOps || !mw.partitionSupports(mp, proto.CapMetaBatch) {
+		for name, value := range attrs {
+			status, err := mw.setXAttr(mp, inode, []byte(name), value)
+			if err != nil || status != statusOK {
+				return statusToErrno(status)
+			}
+		}
+		return nil
+	}
+
+	ops := make([]*proto.MetaBatchOp, 0, len(attrs))
+	for name, value := range attrs {
+		req := &proto.SetXAttrRequest{
+			VolName:     mw.volname,
+			PartitionId: mp.PartitionID,
+			Inode:       inode,
+			Key:         name,
+			Value:       string(value),
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			return syscall.EINVAL
+		}
+		ops = append(ops, &proto.MetaBatchOp{Opcode: proto.OpMetaSetXAttr, Data: data})
+	}
+	status, results, err := mw.batch(mp, ops)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	for _, result := range results {
+		if status = parseStatus(result.ResultCode); status != statusOK {
+			log.LogErrorf("XAttrsSet_ll: set xattr fail, inode(%v) result(%v)", inode, string(result.Data))
+			return statusToErrno(status)
+		}
+	}
+	log.LogDebugf("XAttrsSet_ll: set xattrs, inode(%v) count(%v)", inode, len(attrs))
+	return nil
+}
+
+// InodeGetWithXAttrs_ll is a low-level meta api that gets the inode together with the specified
+// xattrs, in one request if the metanodes batch the ops.
+func (mw *MetaWrapper) InodeGetWithXAttrs_ll(inode uint64, names []string) (*proto.InodeInfo, *proto.XAttrInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("InodeGetWithXAttrs_ll: no such partition, inode(%v)", inode)
+		return nil, nil, syscall.ENOENT
+	}
+	xAttr := &proto.XAttrInfo{Inode: inode, XAttrs: make(map[string]string, len(names))}
+	if len(names) >= proto.MetaBatchMaxOps || !mw.partitionSupports(mp, proto.CapMetaBatch) {
+		status, info, err := mw.iget(mp, inode)
+		if err != nil || status != statusOK {
+			return nil, nil, statusToErrno(status)
+		}
+		for _, name := range names {
+			value, status, err := mw.getXAttr(mp, inode, name)
+			if err != nil || status != statusOK {
+				return nil, nil, statusToErrno(status)
+			}
+			xAttr.XAttrs[name] = string(value)
+		}
+		return info, xAttr, nil
+	}
+
+	ops := make([]*proto.MetaBatchOp, 0, len(names)+1)
+	data, err := json.Marshal(&proto.InodeGetRequest{VolName: mw.volname, PartitionID: mp.PartitionID, Inode: inode})
+	if err != nil {
+		return nil, nil, syscall.EINVAL
+	}
+	ops = append(ops, &proto.MetaBatchOp{Opcode: proto.OpMetaInodeGet, Data: data})
+	for _, name := range names {
+		req := &proto.GetXAttrRequest{VolName: mw.volname, PartitionId: mp.PartitionID, Inode: inode, Key: name}
+		if data, err = json.Marshal(req); err != nil {
+			return nil, nil, syscall.EINVAL
+		}
+		ops = append(ops, &proto.MetaBatchOp{Opcode: proto.OpMetaGetXAttr, Data: data})
+	}
+	status, results, err := mw.batch(mp, ops)
+	if err != nil || status != statusOK {
+		return nil, nil, statusToErrno(status)
+	}
+	for _, result := range results {
+		if status = parseStatus(result.ResultCode); status != statusOK {
+			log.LogErrorf("InodeGetWithXAttrs_ll: inode(%v) result(%v)", inode, string(result.Data))
+			return nil, nil, statusToErrno(status)
+		}
+	}
+
+	inodeResp := new(proto.InodeGetResponse)
+	if err = json.Unmarshal(results[0].Data, inodeResp); err != nil || inodeResp.Info == nil {
+		log.LogErrorf("InodeGetWithXAttrs_ll: inode(%v) err(%v) data(%v)", inode, err, string(results[0].Data))
+		return nil, nil, syscall.EIO
+	}
+	for i, name := range names {
+		xAttrResp := new(proto.GetXAttrResponse)
+		if err = json.Unmarshal(results[i+1].Data, xAttrResp); err != nil {
+			log.LogErrorf("InodeGetWithXAttrs_ll: inode(%v) err(%v) data(%v)", inode, err, string(results[i+1].Data))
+			return nil, nil, syscall.EIO
+		}
+		xAttr.XAttrs[name] = xAttrResp.Value
+	}
+	return inodeResp.Info, xAttr, nil
+}
+
+// XAttrsList_ll is a low-level meta api that lists all the xattrs of the inode.
+func (mw *MetaWrapper) XAttrsList_ll(inode uint64) (*proto.XAttrInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("XAttrsList_ll: no such partition, inode(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+
+	vals, status, err := mw.listXAttr(mp, inode)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+
+	xAttrValues := make(map[string]string, len(vals))
+	for name, value := range vals {
+		xAttrValues[name] = string(value)
+	}
+	return &proto.XAttrInfo{Inode: inode, XAttrs: xAttrValues}, nil
+}
+
 // XAttrDel_ll is a low-level meta api that deletes specified xattr.
 func (mw *MetaWrapper) XAttrDel_ll(inode uint64, name string) error {
 	var err error
@@ -23,16 +23,31 @@ import (
 
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/tracing"
 )
 
 const (
 	SendRetryLimit    = 100
 	SendRetryInterval = 100 * time.Millisecond
 	SendTimeLimit     = 20 * time.Second
+
+	// HandshakeTimeout is the read timeout of the handshake in seconds, the metanodes before the
+	// handshake leave it unanswered.
+	HandshakeTimeout = 1
+
+	// the capabilities of a metanode are negotiated again after the TTL, since it may be upgraded
+	// or downgraded, and a failed handshake is retried after a shorter one.
+	PeerCapabilitiesTTL       = 10 * time.Minute
+	PeerCapabilitiesFailedTTL = 30 * time.Second
 )
 
+type cachedCapabilities struct {
+	caps   uint64
+	expire time.Time
+}
+
 type MetaConn struct {
-	conn *net.TCPConn
+	conn net.Conn
 	id   uint64 //PartitionID
 	addr string //MetaNode addr
 }
@@ -62,6 +77,66 @@ func (mw *MetaWrapper) putConn(mc *MetaConn, err error) {
 	}
 }
 
+// acceptCompression returns true if the client accepts compressed replies and the metanode
+// announced that it compresses them.
+func (mw *MetaWrapper) acceptCompression(addr string) bool {
+	return mw.compressReply && mw.peerCapabilities(addr)&proto.CapCompressLZ4 != 0
+}
+
+// partitionSupports returns true if all the metanodes of the partition announced the capability,
+// so that the requests using it may be served by any of them.
+func (mw *MetaWrapper) partitionSupports(mp *MetaPartition, capability uint64) bool {
+	for _, addr := range mp.Members {
+		if mw.peerCapabilities(addr)&capability != capability {
+			return false
+		}
+	}
+	return len(mp.Members) > 0
+}
+
+// peerCapabilities returns the capabilities of the metanode, which are negotiated by a handshake
+// and cached until the TTL expires. A metanode leaving the handshake unanswered predates it and
+// has none. The failed handshakes are cached as none by a shorter TTL, so that an unreachable
+// metanode is not handshaken by every request.
+func (mw *MetaWrapper) peerCapabilities(addr string) uint64 {
+	if cached, ok := mw.peerCaps.Load(addr); ok && time.Now().Before(cached.(*cachedCapabilities).expire) {
+		return cached.(*cachedCapabilities).caps
+	}
+	var failed = func(err error) uint64 {
+		log.LogWarnf("peerCapabilities: handshake with metanode(%v) failed: err(%v)", addr, err)
+		mw.peerCaps.Store(addr, &cachedCapabilities{expire: time.Now().Add(PeerCapabilitiesFailedTTL)})
+		return 0
+	}
+	conn, err := mw.conns.GetConnect(addr)
+	if err != nil {
+		return failed(err)
+	}
+	req := proto.NewHandshakePacket()
+	if err = req.WriteToConn(conn); err != nil {
+		mw.conns.PutConnect(conn, true)
+		return failed(err)
+	}
+	resp := proto.NewPacket()
+	err = resp.ReadFromConn(conn, HandshakeTimeout)
+	mw.conns.PutConnect(conn, err != nil)
+	if netErr, ok := err.(net.Error); err != nil && !(ok && netErr.Timeout()) {
+		return failed(err)
+	}
+
+	var caps uint64
+	if err == nil {
+		var hs *proto.HandshakeResponse
+		if hs, err = resp.UnmarshalHandshakeReply(); err != nil {
+			log.LogWarnf("peerCapabilities: metanode(%v) rejected the handshake: err(%v)", addr, err)
+		} else {
+			caps = hs.Capabilities
+		}
+	}
+	log.LogInfof("peerCapabilities: metanode(%v) capabilities(%x)", addr, caps)
+	mw.peerCaps.Store(addr, &cachedCapabilities{caps: caps, expire: time.Now().Add(PeerCapabilitiesTTL)})
+	return caps
+}
+
 func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet) (*proto.Packet, error) {
 	var (
 		resp  *proto.Packet
@@ -69,8 +144,23 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 		addr  string
 		mc    *MetaConn
 		start time.Time
+		span  *tracing.Span
 	)
 
+	begin := time.Now()
+	defer func() {
+		mw.opStats.Record(req.GetOpMsg(), begin, err)
+	}()
+
+	span = tracing.StartRootSpan("client." + req.GetOpMsg())
+	if span != nil {
+		span.SetTag("partition", mp.PartitionID)
+		req.Trace = span.Context()
+		defer func() {
+			span.Finish(err)
+		}()
+	}
+
 	addr = mp.LeaderAddr
 	if addr == "" {
 		err = errors.New(fmt.Sprintf("sendToMetaPartition failed: leader addr empty, req(%v) mp(%v)", req, mp))
@@ -80,6 +170,7 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 	if err != nil {
 		goto retry
 	}
+	req.AcceptCompression = mw.acceptCompression(addr)
 	resp, err = mc.send(req)
 	mw.putConn(mc, err)
 	if err == nil && !resp.ShouldRetry() {
@@ -95,6 +186,7 @@ retry:
 			if err != nil {
 				continue
 			}
+			req.AcceptCompression = mw.acceptCompression(addr)
 			resp, err = mc.send(req)
 			mw.putConn(mc, err)
 			if err == nil && !resp.ShouldRetry() {
@@ -112,7 +204,8 @@ retry:
 
 out:
 	if err != nil || resp == nil {
-		return nil, errors.New(fmt.Sprintf("sendToMetaPartition failed: req(%v) mp(%v) err(%v) resp(%v)", req, mp, err, resp))
+		err = errors.New(fmt.Sprintf("sendToMetaPartition failed: req(%v) mp(%v) err(%v) resp(%v)", req, mp, err, resp))
+		return nil, err
 	}
 	log.LogDebugf("sendToMetaPartition successful: req(%v) mc(%v) resp(%v)", req, mc, resp)
 	return resp, nil
@@ -128,5 +221,8 @@ func (mc *MetaConn) send(req *proto.Packet) (resp *proto.Packet, err error) {
 	if err != nil {
 		return nil, errors.Trace(err, "Failed to read from conn, req(%v)", req)
 	}
+	if err = resp.DecompressData(); err != nil {
+		return nil, errors.Trace(err, "Failed to decompress the reply, req(%v)", req)
+	}
 	return resp, nil
 }
@@ -0,0 +1,114 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"syscall"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The byte-range locks are held by the lock owners of the client, which is identified by a random
+// ID of the meta wrapper. The leases of the locks are renewed in the partitions the client has set
+// any lock in, until none of its locks is left there.
+
+func newLockClientID() uint64 {
+	var raw [8]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return uint64(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint64(raw[:])
+}
+
+// SetLock_ll is a low-level meta api that acquires or releases the byte-range lock of the inode,
+// which returns EAGAIN if a lock of another owner conflicts.
+func (mw *MetaWrapper) SetLock_ll(inode uint64, lock proto.RangeLock) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetLock_ll: no such partition, inode(%v)", inode)
+		return syscall.ENOENT
+	}
+	lock.Client = mw.lockClient
+	if lock.Type != proto.LockUnlock {
+		// recorded ahead, so that the lock is renewed even if it is set during a renewal
+		mw.lockLock.Lock()
+		mw.lockPartitions[mp.PartitionID] = time.Now()
+		mw.lockLock.Unlock()
+	}
+	status, err := mw.setLock(mp, inode, &lock)
+	if err != nil {
+		return statusToErrno(status)
+	}
+	switch status {
+	case statusOK:
+	case statusExist:
+		return syscall.EAGAIN
+	default:
+		return statusToErrno(status)
+	}
+	log.LogDebugf("SetLock_ll: inode(%v) lock(%v)", inode, lock)
+	return nil
+}
+
+// GetLock_ll is a low-level meta api that returns a lock of another owner conflicting with the
+// lock, or nil if there is none.
+func (mw *MetaWrapper) GetLock_ll(inode uint64, lock proto.RangeLock) (*proto.RangeLock, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("GetLock_ll: no such partition, inode(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+	lock.Client = mw.lockClient
+	conflict, status, err := mw.getLock(mp, inode, &lock)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return conflict, nil
+}
+
+// renewLocks renews the leases of the locks of the client, and forgets the partitions where the
+// client holds no lock any more.
+func (mw *MetaWrapper) renewLocks() {
+	mw.lockLock.Lock()
+	pids := make([]uint64, 0, len(mw.lockPartitions))
+	for pid := range mw.lockPartitions {
+		pids = append(pids, pid)
+	}
+	mw.lockLock.Unlock()
+	for _, pid := range pids {
+		mp := mw.getPartitionByID(pid)
+		if mp == nil {
+			continue
+		}
+		start := time.Now()
+		count, status, err := mw.renewPartitionLocks(mp)
+		if err != nil || status != statusOK {
+			log.LogWarnf("renewLocks: partitionID(%v) status(%v) err(%v)", pid, status, err)
+			continue
+		}
+		if count > 0 {
+			continue
+		}
+		mw.lockLock.Lock()
+		if set, ok := mw.lockPartitions[pid]; ok && set.Before(start) {
+			delete(mw.lockPartitions, pid)
+		}
+		mw.lockLock.Unlock()
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/chubaofs/chubaofs/util/btree"
 	"github.com/chubaofs/chubaofs/util/errors"
 	cfslog "github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
 )
 
 const (
@@ -64,6 +65,7 @@ type MetaWrapper struct {
 	localIP         string
 	volname         string
 	ossSecure       *OSSSecure
+	accessKeys      map[string]*proto.VolAccessKey // the access keys of the volume besides the one of the owner
 	owner           string
 	ownerValidation bool
 	mc              *masterSDK.MasterClient
@@ -85,14 +87,41 @@ type MetaWrapper struct {
 	totalSize uint64
 	usedSize  uint64
 
+	// trashRetention is the hours the deleted dentries are kept in the trash, disabled if 0.
+	trashRetention uint32
+
+	// snapshotID is the snapshot of the volume mounted read-only, the live volume if 0.
+	snapshotID uint32
+
 	authenticate bool
 	Ticket       Ticket
 	accessToken  proto.APIAccessReq
 	sessionKey   string
 	ticketMess   auth.TicketMess
+	// ticketRenewTime is when the ticket is renewed ahead of its expiration, 0 if never.
+	ticketRenewTime int64
+
+	// compressReply tells the metanodes that the client accepts compressed replies.
+	compressReply bool
+	// peerCaps is the capabilities of the metanodes negotiated in the handshake, indexed by address
+	// and cached by a TTL.
+	peerCaps sync.Map
+
+	// quotas is the quotas of the volume indexed by ID, and quotaTags is the quota IDs of the inodes cached.
+	quotaLock sync.RWMutex
+	quotas    map[uint32]*proto.QuotaInfo
+	quotaTags map[uint64]*quotaTag
+
+	// lockClient identifies the client holding the byte-range locks, and lockPartitions is the
+	// partitions the locks are set in with the time of the last one, whose leases are renewed.
+	lockClient     uint64
+	lockLock       sync.Mutex
+	lockPartitions map[uint64]time.Time
 
 	closeCh   chan struct{}
 	closeOnce sync.Once
+
+	opStats *metrics.OpStats
 }
 
 //the ticket from authnode
@@ -101,11 +130,23 @@ type Ticket struct {
 	SessionKey string `json:"session_key"`
 	ServiceID  string `json:"service_id"`
 	Ticket     string `json:"ticket"`
+	Exp        int64  `json:"exp"`
+}
+
+// renewTime returns the time to renew the ticket, when 90 percent of its lifetime passed, or 0 if
+// the authnode does not tell the expiration.
+func (t *Ticket) renewTime() int64 {
+	if t.Exp == 0 {
+		return 0
+	}
+	now := time.Now().Unix()
+	return now + (t.Exp-now)*9/10
 }
 
 func NewMetaWrapper(opt *proto.MountOptions, validateOwner bool) (*MetaWrapper, error) {
 	mw := new(MetaWrapper)
 	mw.closeCh = make(chan struct{}, 1)
+	mw.opStats = metrics.NewOpStats()
 	if opt.Authenticate {
 		ticket, err := getTicketFromAuthnode(opt.Owner, opt.TicketMess)
 		if err != nil {
@@ -117,8 +158,10 @@ func NewMetaWrapper(opt *proto.MountOptions, validateOwner bool) (*MetaWrapper,
 		mw.accessToken.ServiceID = proto.MasterServiceID
 		mw.sessionKey = ticket.SessionKey
 		mw.ticketMess = opt.TicketMess
+		mw.ticketRenewTime = ticket.renewTime()
 	}
 	mw.volname = opt.Volname
+	mw.compressReply = opt.CompressReply
 	mw.owner = opt.Owner
 	mw.ownerValidation = validateOwner
 	masters := strings.Split(opt.Master, HostsSeparator)
@@ -127,8 +170,18 @@ func NewMetaWrapper(opt *proto.MountOptions, validateOwner bool) (*MetaWrapper,
 	mw.partitions = make(map[uint64]*MetaPartition)
 	mw.ranges = btree.New(32)
 	mw.rwPartitions = make([]*MetaPartition, 0)
+	mw.quotaTags = make(map[uint64]*quotaTag)
+	mw.lockClient = newLockClientID()
+	mw.lockPartitions = make(map[uint64]time.Time)
 	_ = mw.updateClusterInfo()
 	_ = mw.updateVolStatInfo()
+	_ = mw.updateQuotas()
+
+	if opt.Snapshot != "" {
+		if err := mw.resolveSnapshot(opt.Snapshot); err != nil {
+			return nil, errors.Trace(err, "Resolve snapshot failed!")
+		}
+	}
 
 	limit := MaxMountRetryLimit
 retry:
@@ -147,10 +200,39 @@ retry:
 	return mw, nil
 }
 
+// resolveSnapshot resolves the snapshot to mount by the name, which must be ready.
+func (mw *MetaWrapper) resolveSnapshot(name string) error {
+	snapshots, err := mw.mc.AdminAPI().ListVolumeSnapshots(mw.volname)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name != name {
+			continue
+		}
+		if snapshot.Status != proto.VolSnapshotReady {
+			return fmt.Errorf("snapshot(%v) of volume(%v) is not ready: %v", name, mw.volname, snapshot.Status)
+		}
+		mw.snapshotID = snapshot.ID
+		cfslog.LogInfof("resolveSnapshot: volume(%v) snapshot(%v) id(%v)", mw.volname, name, snapshot.ID)
+		return nil
+	}
+	return fmt.Errorf("snapshot(%v) of volume(%v) not found", name, mw.volname)
+}
+
 func (mw *MetaWrapper) OSSSecure() (accessKey, secretKey string) {
 	return mw.ossSecure.AccessKey, mw.ossSecure.SecretKey
 }
 
+// VolAccessKey returns the unexpired access key of the volume besides the one of the owner, or nil
+// if it does not exist.
+func (mw *MetaWrapper) VolAccessKey(accessKey string) *proto.VolAccessKey {
+	if key := mw.accessKeys[accessKey]; key != nil && !key.Expired(time.Now().Unix()) {
+		return key
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) Close() {
 	mw.closeOnce.Do(func() {
 		close(mw.closeCh)
@@ -225,21 +307,39 @@ func getTicketFromAuthnode(owner string, ticketMess auth.TicketMess) (ticket Tic
 		urlProto string
 		url      string
 		client   *http.Client
+		message  interface{}
+		msgType  proto.MsgType
+		path     string
 	)
 
-	key, err = cryptoutil.Base64Decode(ticketMess.ClientKey)
-	if err != nil {
-		return
-	}
-	// construct request body
-	message := proto.AuthGetTicketReq{
-		Type:      proto.MsgAuthTicketReq,
-		ClientID:  owner,
-		ServiceID: proto.MasterServiceID,
-	}
-
-	if message.Verifier, ts, err = cryptoutil.GenVerifier(key); err != nil {
-		return
+	if ticketMess.Principal != "" {
+		// the ticket of the user the LDAP or Kerberos principal is mapped to
+		key = cryptoutil.GenIdentityKey(ticketMess.Principal, ticketMess.Password)
+		req := proto.AuthIdentityTicketReq{
+			Type:      proto.MsgAuthIdentityTicketReq,
+			Principal: ticketMess.Principal,
+			Password:  ticketMess.Password,
+			ServiceID: proto.MasterServiceID,
+		}
+		if req.Verifier, ts, err = cryptoutil.GenVerifier(key); err != nil {
+			return
+		}
+		message, msgType, path = req, req.Type, proto.ClientGetTicketByIdentity
+	} else {
+		key, err = cryptoutil.Base64Decode(ticketMess.ClientKey)
+		if err != nil {
+			return
+		}
+		// construct request body
+		req := proto.AuthGetTicketReq{
+			Type:      proto.MsgAuthTicketReq,
+			ClientID:  owner,
+			ServiceID: proto.MasterServiceID,
+		}
+		if req.Verifier, ts, err = cryptoutil.GenVerifier(key); err != nil {
+			return
+		}
+		message, msgType, path = req, req.Type, proto.ClientGetTicket
 	}
 
 	if ticketMess.EnableHTTPS {
@@ -258,7 +358,7 @@ func getTicketFromAuthnode(owner string, ticketMess auth.TicketMess) (ticket Tic
 	//TODO don't retry if the param is wrong
 	for i := 0; i < GetTicketMaxRetry; i++ {
 		for _, ip := range authnode {
-			url = urlProto + ip + proto.ClientGetTicket
+			url = urlProto + ip + path
 			body, err = proto.SendData(client, url, message)
 
 			if err != nil {
@@ -269,14 +369,19 @@ func getTicketFromAuthnode(owner string, ticketMess auth.TicketMess) (ticket Tic
 				continue
 			}
 
-			if err = proto.VerifyTicketRespComm(&msgResp, proto.MsgAuthTicketReq, owner, "MasterService", ts); err != nil {
+			clientID := owner
+			if msgType == proto.MsgAuthIdentityTicketReq {
+				clientID = msgResp.ClientID
+			}
+			if err = proto.VerifyTicketRespComm(&msgResp, msgType, clientID, "MasterService", ts); err != nil {
 				continue
 			}
 
 			ticket.Ticket = msgResp.Ticket
 			ticket.ServiceID = msgResp.ServiceID
 			ticket.SessionKey = cryptoutil.Base64Encode(msgResp.SessionKey.Key)
-			ticket.ID = owner
+			ticket.ID = clientID
+			ticket.Exp = msgResp.Exp
 			cfslog.LogInfof("GetTicket: ok!")
 			return
 		}
@@ -295,3 +400,8 @@ func loadCertfile(path string) (caCert []byte) {
 	}
 	return
 }
+
+// OpStats returns the counters of the metadata operations sent to the metanodes.
+func (mw *MetaWrapper) OpStats() []metrics.OpStat {
+	return mw.opStats.Snapshot()
+}
@@ -28,7 +28,7 @@ import (
 // API implementations
 //
 
-func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target []byte) (status int, info *proto.InodeInfo, err error) {
+func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target []byte, quotaIDs []uint32) (status int, info *proto.InodeInfo, err error) {
 	req := &proto.CreateInodeRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
@@ -36,6 +36,7 @@ func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target
 		Uid:         uid,
 		Gid:         gid,
 		Target:      target,
+		QuotaIds:    quotaIDs,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -46,7 +47,7 @@ func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -54,6 +55,7 @@ func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target
 		log.LogErrorf("icreate: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -91,7 +93,7 @@ func (mw *MetaWrapper) iunlink(mp *MetaPartition, inode uint64) (status int, inf
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -99,6 +101,7 @@ func (mw *MetaWrapper) iunlink(mp *MetaPartition, inode uint64) (status int, inf
 		log.LogErrorf("iunlink: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -132,7 +135,7 @@ func (mw *MetaWrapper) ievict(mp *MetaPartition, inode uint64) (status int, err
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -140,6 +143,7 @@ func (mw *MetaWrapper) ievict(mp *MetaPartition, inode uint64) (status int, err
 		log.LogWarnf("ievict: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -173,7 +177,7 @@ func (mw *MetaWrapper) dcreate(mp *MetaPartition, parentID uint64, name string,
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -181,6 +185,7 @@ func (mw *MetaWrapper) dcreate(mp *MetaPartition, parentID uint64, name string,
 		log.LogErrorf("dcreate: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -211,7 +216,7 @@ func (mw *MetaWrapper) dupdate(mp *MetaPartition, parentID uint64, name string,
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -219,6 +224,7 @@ func (mw *MetaWrapper) dupdate(mp *MetaPartition, parentID uint64, name string,
 		log.LogErrorf("dupdate: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -252,7 +258,7 @@ func (mw *MetaWrapper) ddelete(mp *MetaPartition, parentID uint64, name string)
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -260,6 +266,7 @@ func (mw *MetaWrapper) ddelete(mp *MetaPartition, parentID uint64, name string)
 		log.LogErrorf("ddelete: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -283,6 +290,7 @@ func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
 		Name:        name,
+		SnapshotID:  mw.snapshotID,
 	}
 	packet := proto.NewPacketReqID()
 	packet.Opcode = proto.OpMetaLookup
@@ -294,7 +302,7 @@ func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (
 
 	log.LogDebugf("lookup enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -302,6 +310,7 @@ func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (
 		log.LogErrorf("lookup: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -328,6 +337,7 @@ func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64) (status int, info *
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inode:       inode,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -338,7 +348,7 @@ func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64) (status int, info *
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -346,6 +356,7 @@ func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64) (status int, info *
 		log.LogErrorf("iget: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -371,6 +382,7 @@ func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes [
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inodes:      inodes,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -380,7 +392,7 @@ func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes [
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -388,6 +400,7 @@ func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes [
 		log.LogErrorf("batchIget: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status := parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -417,6 +430,7 @@ func (mw *MetaWrapper) readdir(mp *MetaPartition, parentID uint64) (status int,
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -427,7 +441,7 @@ func (mw *MetaWrapper) readdir(mp *MetaPartition, parentID uint64) (status int,
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -435,6 +449,7 @@ func (mw *MetaWrapper) readdir(mp *MetaPartition, parentID uint64) (status int,
 		log.LogErrorf("readdir: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -469,7 +484,7 @@ func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent p
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -477,6 +492,7 @@ func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent p
 		log.LogErrorf("appendExtentKey: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -490,6 +506,7 @@ func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int,
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inode:       inode,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -500,7 +517,7 @@ func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int,
 		return
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -508,6 +525,7 @@ func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int,
 		log.LogErrorf("getExtents: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -543,7 +561,7 @@ func (mw *MetaWrapper) truncate(mp *MetaPartition, inode, size uint64) (status i
 
 	log.LogDebugf("truncate enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -551,6 +569,7 @@ func (mw *MetaWrapper) truncate(mp *MetaPartition, inode, size uint64) (status i
 		log.LogErrorf("truncate: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -579,7 +598,7 @@ func (mw *MetaWrapper) ilink(mp *MetaPartition, inode uint64) (status int, info
 
 	log.LogDebugf("ilink enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -587,6 +606,7 @@ func (mw *MetaWrapper) ilink(mp *MetaPartition, inode uint64) (status int, info
 		log.LogErrorf("ilink: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -630,7 +650,7 @@ func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid
 
 	log.LogDebugf("setattr enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -638,6 +658,7 @@ func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid
 		log.LogErrorf("setattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -649,11 +670,12 @@ func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid
 	return statusOK, nil
 }
 
-func (mw *MetaWrapper) createSession(mp *MetaPartition, path string) (status int, multipartId string, err error) {
+func (mw *MetaWrapper) createSession(mp *MetaPartition, path string, extend map[string]string) (status int, multipartId string, err error) {
 	req := &proto.CreateMultipartRequest{
 		PartitionId: mp.PartitionID,
 		VolName:     mw.volname,
 		Path:        path,
+		Extend:      extend,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -666,7 +688,7 @@ func (mw *MetaWrapper) createSession(mp *MetaPartition, path string) (status int
 
 	log.LogDebugf("createSession enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -674,6 +696,7 @@ func (mw *MetaWrapper) createSession(mp *MetaPartition, path string) (status int
 		log.LogErrorf("createSession: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -690,11 +713,13 @@ func (mw *MetaWrapper) createSession(mp *MetaPartition, path string) (status int
 	return statusOK, resp.Info.ID, nil
 }
 
-func (mw *MetaWrapper) getMultipart(mp *MetaPartition, multipartId string) (status int, info *proto.MultipartInfo, err error) {
+func (mw *MetaWrapper) getMultipart(mp *MetaPartition, multipartId string, maxParts uint64, partNumberMarker uint16) (status int, resp *proto.GetMultipartResponse, err error) {
 	req := &proto.GetMultipartRequest{
-		PartitionId: mp.PartitionID,
-		VolName:     mw.volname,
-		MultipartId: multipartId,
+		PartitionId:      mp.PartitionID,
+		VolName:          mw.volname,
+		MultipartId:      multipartId,
+		MaxParts:         maxParts,
+		PartNumberMarker: partNumberMarker,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -707,7 +732,7 @@ func (mw *MetaWrapper) getMultipart(mp *MetaPartition, multipartId string) (stat
 
 	log.LogDebugf("getMultipart enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -715,6 +740,7 @@ func (mw *MetaWrapper) getMultipart(mp *MetaPartition, multipartId string) (stat
 		log.LogErrorf("getMultipart: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -722,14 +748,67 @@ func (mw *MetaWrapper) getMultipart(mp *MetaPartition, multipartId string) (stat
 		return
 	}
 
-	resp := new(proto.GetMultipartResponse)
+	resp = new(proto.GetMultipartResponse)
 	err = packet.UnmarshalData(resp)
 	if err != nil {
 		log.LogErrorf("getMultipart: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
 		return
 	}
 
-	return statusOK, resp.Info, nil
+	return statusOK, resp, nil
+}
+
+func (mw *MetaWrapper) completeMultipart(mp *MetaPartition, req *proto.CompleteMultipartRequest) (status int, resp *proto.CompleteMultipartResponse, err error) {
+	req.PartitionId = mp.PartitionID
+	req.VolName = mw.volname
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpCompleteMultipart
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("completeMultipart: err(%v)", err)
+		return
+	}
+
+	log.LogDebugf("completeMultipart enter: packet(%v) mp(%v) multipartID(%v) parentID(%v) name(%v)",
+		packet, mp, req.MultipartId, req.ParentId, req.Name)
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("completeMultipart: packet(%v) mp(%v) multipartID(%v) err(%v)", packet, mp, req.MultipartId, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("completeMultipart: packet(%v) mp(%v) multipartID(%v) result(%v) msg(%v)",
+			packet, mp, req.MultipartId, packet.GetResultMsg(), string(packet.Data))
+		// the parts refused are replied with the reason
+		if status == statusInval {
+			switch string(packet.Data) {
+			case proto.ErrInvalidPartOrder.Error():
+				err = proto.ErrInvalidPartOrder
+			case proto.ErrInvalidPart.Error():
+				err = proto.ErrInvalidPart
+			case proto.ErrEntityTooSmall.Error():
+				err = proto.ErrEntityTooSmall
+			}
+		}
+		return
+	}
+
+	resp = new(proto.CompleteMultipartResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("completeMultipart: packet(%v) mp(%v) multipartID(%v) err(%v) PacketData(%v)",
+			packet, mp, req.MultipartId, err, string(packet.Data))
+		return
+	}
+	return statusOK, resp, nil
 }
 
 func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, multipartId string, partId uint16, size uint64, md5 string, indoe uint64) (status int, err error) {
@@ -756,7 +835,7 @@ func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, multipartId string, p
 	}
 
 	log.LogDebugf("addMultipartPart entry: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -764,6 +843,7 @@ func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, multipartId string, p
 		log.LogErrorf("addMultipartPart: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -788,7 +868,7 @@ func (mw *MetaWrapper) idelete(mp *MetaPartition, inode uint64) (status int, err
 	}
 	log.LogDebugf("delete inode: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -796,6 +876,7 @@ func (mw *MetaWrapper) idelete(mp *MetaPartition, inode uint64) (status int, err
 		log.LogErrorf("delete inode: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -821,7 +902,7 @@ func (mw *MetaWrapper) removeMultipart(mp *MetaPartition, multipartId string) (s
 	}
 	log.LogDebugf("delete session: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -829,6 +910,7 @@ func (mw *MetaWrapper) removeMultipart(mp *MetaPartition, multipartId string) (s
 		log.LogErrorf("delete session: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -839,12 +921,13 @@ func (mw *MetaWrapper) removeMultipart(mp *MetaPartition, multipartId string) (s
 	return statusOK, nil
 }
 
-func (mw *MetaWrapper) appendExtentKeys(mp *MetaPartition, inode uint64, extents []proto.ExtentKey) (status int, err error) {
+func (mw *MetaWrapper) appendExtentKeys(mp *MetaPartition, inode, gen uint64, extents []proto.ExtentKey) (status int, err error) {
 	req := &proto.AppendExtentKeysRequest{
 		VolName:     mw.volname,
 		PartitionId: mp.PartitionID,
 		Inode:       inode,
 		Extents:     extents,
+		Generation:  gen,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -856,7 +939,7 @@ func (mw *MetaWrapper) appendExtentKeys(mp *MetaPartition, inode uint64, extents
 	}
 	log.LogDebugf("appendExtentKeys: batch append extent: packet(%v) mp(%v) req(%v)", packet, mp, *req)
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -864,6 +947,7 @@ func (mw *MetaWrapper) appendExtentKeys(mp *MetaPartition, inode uint64, extents
 		log.LogErrorf("batch append extent: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -875,6 +959,55 @@ func (mw *MetaWrapper) appendExtentKeys(mp *MetaPartition, inode uint64, extents
 	return
 }
 
+func (mw *MetaWrapper) cloneExtents(mp *MetaPartition, mode, uid, gid uint32, extents []proto.ExtentKey) (status int, info *proto.InodeInfo, err error) {
+	req := &proto.CloneExtentsRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Mode:        mode,
+		Uid:         uid,
+		Gid:         gid,
+		Extents:     extents,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaCloneExtents
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("cloneExtents: err(%v)", err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("cloneExtents: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("cloneExtents: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.CloneExtentsResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("cloneExtents: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	if resp.Info == nil {
+		err = errors.New(fmt.Sprintf("cloneExtents: info is nil, packet(%v) mp(%v) req(%v) PacketData(%v)", packet, mp, *req, string(packet.Data)))
+		log.LogWarn(err)
+		return
+	}
+	log.LogDebugf("cloneExtents: packet(%v) mp(%v) req(%v) info(%v)", packet, mp, *req, resp.Info)
+	return statusOK, resp.Info, nil
+}
+
 func (mw *MetaWrapper) setXAttr(mp *MetaPartition, inode uint64, name []byte, value []byte) (status int, err error) {
 	req := &proto.SetXAttrRequest{
 		VolName:     mw.volname,
@@ -893,7 +1026,7 @@ func (mw *MetaWrapper) setXAttr(mp *MetaPartition, inode uint64, name []byte, va
 	}
 	log.LogDebugf("setXAttr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -902,6 +1035,7 @@ func (mw *MetaWrapper) setXAttr(mp *MetaPartition, inode uint64, name []byte, va
 			packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -913,6 +1047,41 @@ func (mw *MetaWrapper) setXAttr(mp *MetaPartition, inode uint64, name []byte, va
 	return
 }
 
+func (mw *MetaWrapper) batchSetXAttr(mp *MetaPartition, inode uint64, attrs map[string]string) (status int, err error) {
+	req := &proto.BatchSetXAttrRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		Inode:       inode,
+		Attrs:       attrs,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaBatchSetXAttr
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("batchSetXAttr: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("batchSetXAttr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("batchSetXAttr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("batchSetXAttr: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
 func (mw *MetaWrapper) getXAttr(mp *MetaPartition, inode uint64, name string) (value []byte, status int, err error) {
 	req := &proto.GetXAttrRequest{
 		VolName:     mw.volname,
@@ -930,7 +1099,7 @@ func (mw *MetaWrapper) getXAttr(mp *MetaPartition, inode uint64, name string) (v
 	}
 	log.LogDebugf("get xattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -938,6 +1107,7 @@ func (mw *MetaWrapper) getXAttr(mp *MetaPartition, inode uint64, name string) (v
 		log.LogErrorf("get xattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -956,6 +1126,211 @@ func (mw *MetaWrapper) getXAttr(mp *MetaPartition, inode uint64, name string) (v
 	return
 }
 
+func (mw *MetaWrapper) setACL(mp *MetaPartition, inode uint64, isDefault bool, acl proto.ACL) (status int, err error) {
+	req := &proto.SetACLRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		Inode:       inode,
+		Default:     isDefault,
+		ACL:         acl,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetACL
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("setACL: req(%v) err(%v)", *req, err)
+		return
+	}
+	log.LogDebugf("setACL: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("setACL: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("setACL: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("setACL: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) getACL(mp *MetaPartition, inode uint64) (resp *proto.GetACLResponse, status int, err error) {
+	req := &proto.GetACLRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		Inode:       inode,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetACL
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("getACL: req(%v) err(%v)", *req, err)
+		return
+	}
+	log.LogDebugf("getACL: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getACL: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("getACL: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.GetACLResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("getACL: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+
+	log.LogDebugf("getACL: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) setLock(mp *MetaPartition, inode uint64, lock *proto.RangeLock) (status int, err error) {
+	req := &proto.SetLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Lock:        *lock,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetLock
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("setLock: req(%v) err(%v)", *req, err)
+		return
+	}
+	log.LogDebugf("setLock: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("setLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	// the conflicts are expected by the clients waiting for the locks
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK && status != statusExist {
+		log.LogErrorf("setLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("setLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) getLock(mp *MetaPartition, inode uint64, lock *proto.RangeLock) (conflict *proto.RangeLock, status int, err error) {
+	req := &proto.GetLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Lock:        *lock,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetLock
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("getLock: req(%v) err(%v)", *req, err)
+		return
+	}
+	log.LogDebugf("getLock: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("getLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.GetLockResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("getLock: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	conflict = resp.Lock
+
+	log.LogDebugf("getLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) renewPartitionLocks(mp *MetaPartition) (count int, status int, err error) {
+	req := &proto.RenewLocksRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Client:      mw.lockClient,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRenewLocks
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("renewPartitionLocks: req(%v) err(%v)", *req, err)
+		return
+	}
+	log.LogDebugf("renewPartitionLocks: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("renewPartitionLocks: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("renewPartitionLocks: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.RenewLocksResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("renewPartitionLocks: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	count = resp.Count
+
+	log.LogDebugf("renewPartitionLocks: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
 func (mw *MetaWrapper) removeXAttr(mp *MetaPartition, inode uint64, name string) (status int, err error) {
 	req := &proto.RemoveXAttrRequest{
 		VolName:     mw.volname,
@@ -972,13 +1347,14 @@ func (mw *MetaWrapper) removeXAttr(mp *MetaPartition, inode uint64, name string)
 	}
 	log.LogErrorf("remove xattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
 		log.LogErrorf("remove xattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -1005,13 +1381,14 @@ func (mw *MetaWrapper) listXAttr(mp *MetaPartition, inode uint64) (vals map[stri
 	}
 	log.LogErrorf("list xattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	if packet, err = mw.sendToMetaPartition(mp, packet); err != nil {
 		log.LogErrorf("list xattr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -1054,7 +1431,7 @@ func (mw *MetaWrapper) listMultiparts(mp *MetaPartition, prefix, delimiter, keyM
 	}
 
 	log.LogDebugf("listMultiparts enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -1062,6 +1439,7 @@ func (mw *MetaWrapper) listMultiparts(mp *MetaPartition, prefix, delimiter, keyM
 		log.LogErrorf("listMultiparts: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status = parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -1096,7 +1474,7 @@ func (mw *MetaWrapper) batchGetXAttr(mp *MetaPartition, inodes []uint64, keys []
 		return nil, err
 	}
 
-	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
 	defer metric.Set(err)
 
 	packet, err = mw.sendToMetaPartition(mp, packet)
@@ -1104,6 +1482,7 @@ func (mw *MetaWrapper) batchGetXAttr(mp *MetaPartition, inodes []uint64, keys []
 		log.LogErrorf("batchGetXAttr: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
 		return nil, err
 	}
+	defer proto.Buffers.Put(packet.Data)
 
 	status := parseStatus(packet.ResultCode)
 	if status != statusOK {
@@ -1120,3 +1499,332 @@ func (mw *MetaWrapper) batchGetXAttr(mp *MetaPartition, inodes []uint64, keys []
 
 	return resp.XAttrs, nil
 }
+
+// batch sends the independent ops on the same partition in one packet, and returns their results
+// in the order of the ops.
+func (mw *MetaWrapper) batch(mp *MetaPartition, ops []*proto.MetaBatchOp) (status int, results []*proto.MetaBatchResult, err error) {
+	req := &proto.MetaBatchRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Ops:         ops,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaBatch
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("batch: mp(%v) ops(%v) err(%v)", mp, len(ops), err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("batch: packet(%v) mp(%v) ops(%v) err(%v)", packet, mp, len(ops), err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("batch: packet(%v) mp(%v) ops(%v) result(%v)", packet, mp, len(ops), packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.MetaBatchResponse)
+	if err = packet.UnmarshalData(resp); err != nil || len(resp.Results) != len(ops) {
+		log.LogErrorf("batch: packet(%v) mp(%v) ops(%v) err(%v) PacketData(%v)", packet, mp, len(ops), err, string(packet.Data))
+		if err == nil {
+			err = fmt.Errorf("batch: %v results of %v ops", len(resp.Results), len(ops))
+		}
+		return
+	}
+	log.LogDebugf("batch: packet(%v) mp(%v) ops(%v) result(%v)", packet, mp, len(ops), packet.GetResultMsg())
+	return statusOK, resp.Results, nil
+}
+
+func (mw *MetaWrapper) dtrash(mp *MetaPartition, parentID uint64, name string) (status int, inode uint64, err error) {
+	req := &proto.TrashDentryRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		ParentID:    parentID,
+		Name:        name,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaTrashDentry
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("dtrash: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("dtrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("dtrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.TrashDentryResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("dtrash: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("dtrash: packet(%v) mp(%v) req(%v) ino(%v)", packet, mp, *req, resp.Inode)
+	return statusOK, resp.Inode, nil
+}
+
+func (mw *MetaWrapper) listTrash(mp *MetaPartition) (resp *proto.ListTrashResponse, status int, err error) {
+	req := &proto.ListTrashRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaListTrash
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("listTrash: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("listTrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("listTrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.ListTrashResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("listTrash: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("listTrash: packet(%v) mp(%v) req(%v) items(%v)", packet, mp, *req, len(resp.Items))
+	return
+}
+
+func (mw *MetaWrapper) deleteTree(mp *MetaPartition, parentID uint64, name string) (status int, inode uint64, err error) {
+	req := &proto.DeleteTreeRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		ParentID:    parentID,
+		Name:        name,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaDeleteTree
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("deleteTree: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("deleteTree: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("deleteTree: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.DeleteTreeResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("deleteTree: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("deleteTree: packet(%v) mp(%v) req(%v) ino(%v)", packet, mp, *req, resp.Inode)
+	return statusOK, resp.Inode, nil
+}
+
+func (mw *MetaWrapper) listDeleteTrees(mp *MetaPartition) (resp *proto.ListDeleteTreesResponse, status int, err error) {
+	req := &proto.ListDeleteTreesRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaListDeleteTrees
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("listDeleteTrees: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("listDeleteTrees: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("listDeleteTrees: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.ListDeleteTreesResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("listDeleteTrees: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("listDeleteTrees: packet(%v) mp(%v) req(%v) jobs(%v)", packet, mp, *req, len(resp.Jobs))
+	return
+}
+
+func (mw *MetaWrapper) reverseLookup(mp *MetaPartition, inode uint64) (links []*proto.InodeLink, status int, err error) {
+	req := &proto.ReverseLookupRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaReverseLookup
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("reverseLookup: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("reverseLookup: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("reverseLookup: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.ReverseLookupResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("reverseLookup: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	links = resp.Links
+	log.LogDebugf("reverseLookup: packet(%v) mp(%v) req(%v) links(%v)", packet, mp, *req, len(links))
+	return
+}
+
+func (mw *MetaWrapper) listDentry(mp *MetaPartition, parentID uint64, prefix, delimiter, marker string, limit uint64) (resp *proto.ListDentryResponse, status int, err error) {
+	req := &proto.ListDentryRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		ParentID:    parentID,
+		Prefix:      prefix,
+		Delimiter:   delimiter,
+		Marker:      marker,
+		Limit:       limit,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaListDentry
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("listDentry: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("listDentry: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("listDentry: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.ListDentryResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("listDentry: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("listDentry: packet(%v) mp(%v) req(%v) children(%v) prefixes(%v)",
+		packet, mp, *req, len(resp.Children), len(resp.CommonPrefixes))
+	return
+}
+
+func (mw *MetaWrapper) restoreTrash(mp *MetaPartition, parentID uint64, trashName, name string) (status int, err error) {
+	req := &proto.RestoreTrashRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		ParentID:    parentID,
+		TrashName:   trashName,
+		Name:        name,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRestoreTrash
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("restoreTrash: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewVolumeTPCnt(packet.GetOpMsg(), mw.volname)
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("restoreTrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+	defer proto.Buffers.Put(packet.Data)
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("restoreTrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("restoreTrash: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	// RefreshQuotasInterval is the interval to refresh the quotas of the volume from the master,
+	// and the time the quota tags of the inodes are cached.
+	RefreshQuotasInterval = 30 * time.Second
+)
+
+// quotaTag is the quota IDs an inode is tagged with, cached until it expires.
+type quotaTag struct {
+	ids    []uint32
+	expire time.Time
+}
+
+// updateQuotas refreshes the quotas of the volume with the usage summed up by the master, and
+// drops the expired quota tags.
+func (mw *MetaWrapper) updateQuotas() (err error) {
+	var infos []*proto.QuotaInfo
+	if infos, err = mw.mc.AdminAPI().ListVolumeQuotas(mw.volname); err != nil {
+		log.LogWarnf("updateQuotas: list quotas fail: volume(%v) err(%v)", mw.volname, err)
+		return
+	}
+	quotas := make(map[uint32]*proto.QuotaInfo, len(infos))
+	for _, info := range infos {
+		quotas[info.QuotaID] = info
+	}
+	now := time.Now()
+	mw.quotaLock.Lock()
+	mw.quotas = quotas
+	for ino, tag := range mw.quotaTags {
+		if now.After(tag.expire) {
+			delete(mw.quotaTags, ino)
+		}
+	}
+	mw.quotaLock.Unlock()
+	log.LogDebugf("updateQuotas: volume(%v) quotas(%v)", mw.volname, len(quotas))
+	return
+}
+
+func (mw *MetaWrapper) hasQuotas() bool {
+	mw.quotaLock.RLock()
+	defer mw.quotaLock.RUnlock()
+	return len(mw.quotas) > 0
+}
+
+// quotaIDs returns the quota IDs the inode is tagged with, which are cached for a while.
+func (mw *MetaWrapper) quotaIDs(ino uint64) (ids []uint32, err error) {
+	mw.quotaLock.RLock()
+	tag, ok := mw.quotaTags[ino]
+	mw.quotaLock.RUnlock()
+	if ok && time.Now().Before(tag.expire) {
+		return tag.ids, nil
+	}
+	var info *proto.XAttrInfo
+	if info, err = mw.XAttrGet_ll(ino, proto.QuotaXAttrKey); err != nil {
+		return
+	}
+	if ids, err = proto.ParseQuotaIDs(info.XAttrs[proto.QuotaXAttrKey]); err != nil {
+		log.LogWarnf("quotaIDs: inode(%v) err(%v)", ino, err)
+		return nil, syscall.EIO
+	}
+	mw.quotaLock.Lock()
+	mw.quotaTags[ino] = &quotaTag{ids: ids, expire: time.Now().Add(RefreshQuotasInterval)}
+	mw.quotaLock.Unlock()
+	return
+}
+
+// exceeded tells whether any of the quotas is exceeded, in bytes only unless files is true.
+// The IDs of the deleted quotas are ignored.
+func (mw *MetaWrapper) exceeded(ids []uint32, files bool) bool {
+	mw.quotaLock.RLock()
+	defer mw.quotaLock.RUnlock()
+	for _, id := range ids {
+		if quota, ok := mw.quotas[id]; ok && (quota.BytesExceeded || files && quota.FilesExceeded) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckQuota returns the quota IDs of the directory, which are inherited by the inodes created
+// in it, or EDQUOT if any of the quotas is exceeded.
+func (mw *MetaWrapper) CheckQuota(parentID uint64) (ids []uint32, err error) {
+	if !mw.hasQuotas() {
+		return
+	}
+	if ids, err = mw.quotaIDs(parentID); err != nil {
+		return
+	}
+	if mw.exceeded(ids, true) {
+		return nil, syscall.EDQUOT
+	}
+	return
+}
+
+// CheckWriteQuota returns EDQUOT if the bytes of any quota of the inode are exceeded.
+func (mw *MetaWrapper) CheckWriteQuota(ino uint64) (err error) {
+	if !mw.hasQuotas() {
+		return
+	}
+	var ids []uint32
+	if ids, err = mw.quotaIDs(ino); err != nil {
+		return
+	}
+	if mw.exceeded(ids, false) {
+		return syscall.EDQUOT
+	}
+	return
+}
+
+// checkMoveQuota returns EXDEV if an inode is moved or linked to the directory of different
+// quotas from the source, which is the parent directory moved from or the inode linked. The
+// inode would be counted in the quotas of the source otherwise, so it is copied instead.
+func (mw *MetaWrapper) checkMoveQuota(src, dstParentID uint64) (err error) {
+	if src == dstParentID || !mw.hasQuotas() {
+		return
+	}
+	var srcIDs, dstIDs []uint32
+	if srcIDs, err = mw.quotaIDs(src); err != nil {
+		return
+	}
+	if dstIDs, err = mw.quotaIDs(dstParentID); err != nil {
+		return
+	}
+	if proto.FormatQuotaIDs(srcIDs) != proto.FormatQuotaIDs(dstIDs) {
+		return syscall.EXDEV
+	}
+	return
+}
@@ -42,6 +42,8 @@ type VolumeView struct {
 	Owner          string
 	MetaPartitions []*MetaPartition
 	OSSSecure      *OSSSecure
+	AccessKeys     map[string]*proto.VolAccessKey
+	TrashRetention uint32
 }
 
 type OSSSecure struct {
@@ -90,6 +92,11 @@ func (mw *MetaWrapper) fetchVolumeView() (view *VolumeView, err error) {
 			Owner:          volView.Owner,
 			MetaPartitions: make([]*MetaPartition, len(volView.MetaPartitions)),
 			OSSSecure:      &OSSSecure{},
+			AccessKeys:     make(map[string]*proto.VolAccessKey, len(volView.AccessKeys)),
+			TrashRetention: volView.TrashRetention,
+		}
+		for _, key := range volView.AccessKeys {
+			result.AccessKeys[key.AccessKey] = key
 		}
 		if volView.OSSSecure != nil {
 			result.OSSSecure.AccessKey = volView.OSSSecure.AccessKey
@@ -118,8 +125,12 @@ func (mw *MetaWrapper) updateClusterInfo() (err error) {
 		log.LogWarnf("updateClusterInfo: get cluster info fail: err(%v)", err)
 		return
 	}
-	log.LogInfof("updateClusterInfo: get cluster info: cluster(%v) localIP(%v)",
-		info.Cluster, info.Ip)
+	log.LogInfof("updateClusterInfo: get cluster info: cluster(%v) localIP(%v) minClientVersion(%v)",
+		info.Cluster, info.Ip, info.MinClientVersion)
+	if err = proto.CheckClientVersion(info.MinClientVersion); err != nil {
+		log.LogErrorf("updateClusterInfo: %v", err)
+		return
+	}
 	mw.cluster = info.Cluster
 	mw.localIP = info.Ip
 	return
@@ -169,6 +180,8 @@ func (mw *MetaWrapper) updateMetaPartitions() error {
 		}
 	}
 	mw.ossSecure = view.OSSSecure
+	mw.accessKeys = view.AccessKeys
+	atomic.StoreUint32(&mw.trashRetention, view.TrashRetention)
 
 	if len(rwPartitions) == 0 {
 		log.LogInfof("updateMetaPartition: no valid partitions")
@@ -184,10 +197,24 @@ func (mw *MetaWrapper) updateMetaPartitions() error {
 func (mw *MetaWrapper) refresh() {
 	t := time.NewTicker(RefreshMetaPartitionsInterval)
 	defer t.Stop()
+	qt := time.NewTicker(RefreshQuotasInterval)
+	defer qt.Stop()
+	lt := time.NewTicker(proto.LockLease / 3)
+	defer lt.Stop()
 	for {
 		select {
+		case <-qt.C:
+			_ = mw.updateQuotas()
+		case <-lt.C:
+			mw.renewLocks()
 		case <-t.C:
 			var err error
+			// renew the ticket ahead of its expiration, so that the view is never refused for it
+			if mw.authenticate && mw.ticketRenewTime > 0 && time.Now().Unix() >= mw.ticketRenewTime {
+				if err = mw.updateTicket(); err != nil {
+					log.LogWarnf("renew ticket fail cause: %v", err)
+				}
+			}
 			if err = mw.updateMetaPartitions(); err != nil {
 				log.LogErrorf("updateMetaPartition fail cause: %v", err)
 			}
@@ -240,6 +267,7 @@ func (mw *MetaWrapper) updateTicket() error {
 	}
 	mw.accessToken.Ticket = ticket.Ticket
 	mw.sessionKey = ticket.SessionKey
+	mw.ticketRenewTime = ticket.renewTime()
 	return nil
 }
 
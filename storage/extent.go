@@ -59,14 +59,16 @@ func (ei *ExtentInfo) String() (m string) {
 // This extent implementation manages all header info and data body in one single entry file.
 // Header of extent include inode value of this extent block and Crc blocks of data blocks.
 type Extent struct {
-	file       *os.File
-	filePath   string
-	extentID   uint64
-	modifyTime int64
-	dataSize   int64
-	hasClose   int32
-	header     []byte
-	sync.Mutex
+	file           *os.File
+	filePath       string
+	extentID       uint64
+	modifyTime     int64
+	dataSize       int64
+	hasClose       int32
+	header         []byte
+	compressHeader []byte // compression headers of the blocks, nil for the tiny extents
+	io             IOEngine
+	sync.RWMutex
 }
 
 // NewExtentInCore create and returns a new extent instance.
@@ -78,6 +80,21 @@ func NewExtentInCore(name string, extentID uint64) *Extent {
 	return e
 }
 
+// readAt reads the extent file by the io engine of the store, or the file itself if there is none.
+func (e *Extent) readAt(b []byte, offset int64) (int, error) {
+	if e.io == nil {
+		return e.file.ReadAt(b, offset)
+	}
+	return e.io.ReadAt(e.file, b, offset)
+}
+
+func (e *Extent) writeAt(b []byte, offset int64) (int, error) {
+	if e.io == nil {
+		return e.file.WriteAt(b, offset)
+	}
+	return e.io.WriteAt(e.file, b, offset)
+}
+
 func (e *Extent) HasClosed() bool {
 	return atomic.LoadInt32(&e.hasClose) == ExtentHasClose
 }
@@ -186,7 +203,7 @@ func (e *Extent) WriteTiny(data []byte, offset, size int64, crc uint32, writeTyp
 		return ParameterMismatchError
 	}
 
-	if _, err = e.file.WriteAt(data[:size], int64(offset)); err != nil {
+	if _, err = e.writeAt(data[:size], int64(offset)); err != nil {
 		return
 	}
 	if isSync {
@@ -216,7 +233,7 @@ func (e *Extent) Write(data []byte, offset, size int64, crc uint32, writeType in
 	if err = e.checkOffsetAndSize(offset, size); err != nil {
 		return
 	}
-	if _, err = e.file.WriteAt(data[:size], int64(offset)); err != nil {
+	if _, err = e.writeAt(data[:size], int64(offset)); err != nil {
 		return
 	}
 	blockNo := offset / util.BlockSize
@@ -255,16 +272,42 @@ func (e *Extent) Read(data []byte, offset, size int64, isRepairRead bool) (crc u
 	if err = e.checkOffsetAndSize(offset, size); err != nil {
 		return
 	}
-	if _, err = e.file.ReadAt(data[:size], offset); err != nil {
+	e.RLock()
+	defer e.RUnlock()
+	if e.hasCompressedBlocks(offset, size) {
+		err = e.readCompressed(data[:size], offset, size)
+	} else {
+		_, err = e.readAt(data[:size], offset)
+	}
+	if err != nil {
 		return
 	}
 	crc = crc32.ChecksumIEEE(data)
 	return
 }
 
+// sendableBlock returns the file and the CRC of the block at the offset, so that the block can be
+// sent without being read. It is only possible for a whole block of a normal extent, which is not
+// compressed and whose CRC is recorded by the write of the whole block.
+func (e *Extent) sendableBlock(offset, size int64) (file *os.File, crc uint32, ok bool) {
+	if IsTinyExtent(e.extentID) || offset%util.BlockSize != 0 || size != util.BlockSize {
+		return
+	}
+	e.RLock()
+	defer e.RUnlock()
+	if offset+size > e.dataSize || e.hasCompressedBlocks(offset, size) || len(e.header) == 0 {
+		return
+	}
+	blockNo := offset / util.BlockSize
+	if crc = binary.BigEndian.Uint32(e.header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize]); crc == 0 {
+		return
+	}
+	return e.file, crc, true
+}
+
 // ReadTiny read data from a tiny extent.
 func (e *Extent) ReadTiny(data []byte, offset, size int64, isRepairRead bool) (crc uint32, err error) {
-	_, err = e.file.ReadAt(data[:size], offset)
+	_, err = e.readAt(data[:size], offset)
 	if isRepairRead && err == io.EOF {
 		err = nil
 	}
@@ -307,8 +350,9 @@ func (e *Extent) autoComputeExtentCrc(crcFunc UpdateCrcFunc) (crc uint32, err er
 			continue
 		}
 		bdata := make([]byte, util.BlockSize)
-		offset := int64(blockNo * util.BlockSize)
-		readN, err := e.file.ReadAt(bdata[:util.BlockSize], offset)
+		e.RLock()
+		readN, err := e.readBlock(blockNo, bdata)
+		e.RUnlock()
 		if readN == 0 && err != nil {
 			break
 		}
@@ -390,7 +434,7 @@ func (e *Extent) TinyExtentRecover(data []byte, offset, size int64, crc uint32,
 		}
 		err = fallocate(int(e.file.Fd()), FallocFLPunchHole|FallocFLKeepSize, offset, size)
 	} else {
-		_, err = e.file.WriteAt(data[:size], int64(offset))
+		_, err = e.writeAt(data[:size], int64(offset))
 	}
 	if err != nil {
 		return
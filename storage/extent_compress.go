@@ -0,0 +1,367 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/zstd"
+	"github.com/bkaradzic/go-lz4"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+// The blocks of the normal extents are compressed one by one once the compression of the volume is
+// enabled. A compressed block is stored at the beginning of its place in the extent file and the
+// rest of the place is punched, so that the offsets of the blocks and the size of the extent are
+// kept. The compression headers of the blocks are persisted in a file of the extent store at the
+// offsets of the extents, as the block CRCs are. The block of an empty header is stored as it is,
+// which is the case of the blocks written before the compression is enabled, and the ones not
+// worth compressing.
+
+const (
+	ExtCompressHeaderFileName = "EXTENT_COMPRESS"
+	BlockCompressHeaderSize   = 16
+	CompressHeaderSize        = BlockCompressHeaderSize * util.BlockCount
+)
+
+// The codecs of the compressed blocks.
+const (
+	CompressNone uint8 = iota
+	CompressLZ4
+	CompressZstd
+)
+
+var CompressCrcMismatchError = errors.New("compressed block crc mismatch")
+
+type UpdateCompressHeaderFunc func(e *Extent, blockNo int) (err error)
+
+// ParseCompression returns the codec of the compression of the volumes, which is none if empty.
+func ParseCompression(compression string) (codec uint8, err error) {
+	switch compression {
+	case "", proto.ExtentCompressionNone:
+		return CompressNone, nil
+	case proto.ExtentCompressionLZ4:
+		return CompressLZ4, nil
+	case proto.ExtentCompressionZstd:
+		return CompressZstd, nil
+	}
+	return CompressNone, fmt.Errorf("unsupported compression %v", compression)
+}
+
+// blockCompressHeader is the compression header of a block, whose CRC covers the compressed payload.
+type blockCompressHeader struct {
+	codec   uint8
+	size    uint32 // size of the compressed payload
+	rawSize uint32
+	crc     uint32
+}
+
+func (h *blockCompressHeader) marshal(b []byte) {
+	b[0] = h.codec
+	b[1], b[2], b[3] = 0, 0, 0
+	binary.BigEndian.PutUint32(b[4:8], h.size)
+	binary.BigEndian.PutUint32(b[8:12], h.rawSize)
+	binary.BigEndian.PutUint32(b[12:16], h.crc)
+}
+
+func (h *blockCompressHeader) unmarshal(b []byte) {
+	h.codec = b[0]
+	h.size = binary.BigEndian.Uint32(b[4:8])
+	h.rawSize = binary.BigEndian.Uint32(b[8:12])
+	h.crc = binary.BigEndian.Uint32(b[12:16])
+}
+
+func compressBlock(codec uint8, raw []byte) ([]byte, error) {
+	switch codec {
+	case CompressLZ4:
+		return lz4.Encode(nil, raw)
+	case CompressZstd:
+		return zstd.Compress(nil, raw)
+	}
+	return nil, fmt.Errorf("unknown codec %v", codec)
+}
+
+func decompressBlock(codec uint8, payload []byte, rawSize int) ([]byte, error) {
+	switch codec {
+	case CompressLZ4:
+		return lz4.Decode(make([]byte, rawSize), payload)
+	case CompressZstd:
+		return zstd.Decompress(make([]byte, rawSize), payload)
+	}
+	return nil, fmt.Errorf("unknown codec %v", codec)
+}
+
+func (e *Extent) getBlockCompressHeader(blockNo int) (h blockCompressHeader) {
+	if e.compressHeader == nil {
+		return
+	}
+	h.unmarshal(e.compressHeader[blockNo*BlockCompressHeaderSize : (blockNo+1)*BlockCompressHeaderSize])
+	return
+}
+
+func (e *Extent) setBlockCompressHeader(blockNo int, h *blockCompressHeader) {
+	h.marshal(e.compressHeader[blockNo*BlockCompressHeaderSize : (blockNo+1)*BlockCompressHeaderSize])
+}
+
+// hasCompressedBlocks tells whether any of the blocks in the range is compressed.
+func (e *Extent) hasCompressedBlocks(offset, size int64) bool {
+	if e.compressHeader == nil {
+		return false
+	}
+	for blockNo := offset / util.BlockSize; blockNo*util.BlockSize < offset+size; blockNo++ {
+		if e.compressHeader[blockNo*BlockCompressHeaderSize] != CompressNone {
+			return true
+		}
+	}
+	return false
+}
+
+// readBlock reads the data of the block into the buffer, and returns the size of the data.
+// The lock of the extent is held by the caller.
+func (e *Extent) readBlock(blockNo int, buf []byte) (n int, err error) {
+	blockOffset := int64(blockNo) * util.BlockSize
+	n = int(math.Min(float64(e.dataSize-blockOffset), util.BlockSize))
+	if n <= 0 {
+		return 0, nil
+	}
+	h := e.getBlockCompressHeader(blockNo)
+	if h.codec == CompressNone {
+		var readN int
+		if readN, err = e.readAt(buf[:n], blockOffset); err == io.EOF {
+			err = nil
+		}
+		return readN, err
+	}
+	payload := make([]byte, h.size)
+	if _, err = e.readAt(payload, blockOffset); err != nil {
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != h.crc {
+		return 0, CompressCrcMismatchError
+	}
+	var raw []byte
+	if raw, err = decompressBlock(h.codec, payload, int(h.rawSize)); err != nil {
+		return 0, fmt.Errorf("decompress block %v of extent %v: %v", blockNo, e.extentID, err)
+	}
+	copied := copy(buf[:n], raw)
+	for i := copied; i < n; i++ {
+		buf[i] = 0
+	}
+	return n, nil
+}
+
+// writeBlock writes the data of the whole block, which is compressed if it is worth it, and
+// returns the size stored. The header of a compressed block is persisted before the payload, and
+// the one of a block stored as it is after the payload, so that a block torn by a crash fails the
+// CRC of the payload rather than being read as the other one.
+func (e *Extent) writeBlock(codec uint8, blockNo int, raw []byte, headerFunc UpdateCompressHeaderFunc) (stored int, err error) {
+	blockOffset := int64(blockNo) * util.BlockSize
+	oldHeader := e.getBlockCompressHeader(blockNo)
+	var payload []byte
+	if codec != CompressNone {
+		// the compressed block must save a page at least, which is the unit of the punched holes
+		if compressed, compressErr := compressBlock(codec, raw); compressErr == nil && len(compressed)+PageSize <= len(raw) {
+			payload = compressed
+		}
+	}
+	if payload == nil {
+		if _, err = e.writeAt(raw, blockOffset); err != nil {
+			return
+		}
+		if oldHeader.codec != CompressNone {
+			e.setBlockCompressHeader(blockNo, &blockCompressHeader{})
+			err = headerFunc(e, blockNo)
+		}
+		return len(raw), err
+	}
+	e.setBlockCompressHeader(blockNo, &blockCompressHeader{
+		codec:   codec,
+		size:    uint32(len(payload)),
+		rawSize: uint32(len(raw)),
+		crc:     crc32.ChecksumIEEE(payload),
+	})
+	if err = headerFunc(e, blockNo); err != nil {
+		return
+	}
+	if _, err = e.writeAt(payload, blockOffset); err != nil {
+		return
+	}
+	holeOffset := int64(len(payload))
+	if holeOffset%PageSize != 0 {
+		holeOffset += PageSize - holeOffset%PageSize
+	}
+	if holeOffset < util.BlockSize {
+		err = fallocate(int(e.file.Fd()), FallocFLPunchHole|FallocFLKeepSize, blockOffset+holeOffset, util.BlockSize-holeOffset)
+	}
+	return len(payload), err
+}
+
+// WriteCompressed writes the data to a normal extent by the blocks, which are compressed by the
+// codec. The blocks written partially are read and written as a whole.
+func (e *Extent) WriteCompressed(data []byte, offset, size int64, crc uint32, writeType int, isSync bool, codec uint8,
+	crcFunc UpdateCrcFunc, headerFunc UpdateCompressHeaderFunc) (rawSize, storedSize int64, err error) {
+	if err = e.checkOffsetAndSize(offset, size); err != nil {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	end := offset + size
+	newSize := int64(math.Max(float64(e.dataSize), float64(end)))
+	buf := make([]byte, util.BlockSize)
+	for blockNo := int(offset / util.BlockSize); int64(blockNo)*util.BlockSize < end; blockNo++ {
+		blockOffset := int64(blockNo) * util.BlockSize
+		blockSize := int64(math.Min(float64(newSize-blockOffset), util.BlockSize))
+		from := int64(math.Max(float64(offset), float64(blockOffset)))
+		to := int64(math.Min(float64(end), float64(blockOffset+util.BlockSize)))
+		if from > blockOffset || to < blockOffset+blockSize {
+			var n int
+			if n, err = e.readBlock(blockNo, buf); err != nil {
+				return
+			}
+			for i := n; i < int(blockSize); i++ {
+				buf[i] = 0
+			}
+		}
+		copy(buf[from-blockOffset:], data[from-offset:to-offset])
+		var stored int
+		if stored, err = e.writeBlock(codec, blockNo, buf[:blockSize], headerFunc); err != nil {
+			return
+		}
+		rawSize += blockSize
+		storedSize += int64(stored)
+		blockCrc := uint32(0)
+		if from == blockOffset && to-from == util.BlockSize {
+			blockCrc = crc
+		}
+		if err = crcFunc(e, blockNo, blockCrc); err != nil {
+			return
+		}
+	}
+	if end > e.dataSize {
+		// the compressed payload of the last block may end before the extent
+		if err = e.file.Truncate(end); err != nil {
+			return
+		}
+	}
+	if isSync {
+		if err = e.file.Sync(); err != nil {
+			return
+		}
+	}
+	if IsAppendWrite(writeType) {
+		atomic.StoreInt64(&e.modifyTime, time.Now().Unix())
+		e.dataSize = newSize
+	}
+	return
+}
+
+// readCompressed reads the data of a normal extent by the blocks, some of which are compressed.
+// The lock of the extent is held by the caller.
+func (e *Extent) readCompressed(data []byte, offset, size int64) (err error) {
+	if offset+size > e.dataSize {
+		return io.EOF
+	}
+	end := offset + size
+	buf := make([]byte, util.BlockSize)
+	for blockNo := int(offset / util.BlockSize); int64(blockNo)*util.BlockSize < end; blockNo++ {
+		blockOffset := int64(blockNo) * util.BlockSize
+		if _, err = e.readBlock(blockNo, buf); err != nil {
+			return
+		}
+		from := int64(math.Max(float64(offset), float64(blockOffset)))
+		to := int64(math.Min(float64(end), float64(blockOffset+util.BlockSize)))
+		copy(data[from-offset:to-offset], buf[from-blockOffset:to-blockOffset])
+	}
+	return
+}
+
+// ReadExtentFile reads the data of an extent from the directory of a data partition offline, which
+// is used by the tools without the datanode. The compressed blocks of a normal extent are
+// decompressed by the headers in the EXTENT_COMPRESS file, and the blocks are read as they are
+// if there is no such file, as in the partitions created before the compression.
+func ReadExtentFile(dataPath string, extentID uint64, data []byte, offset int64) (err error) {
+	e := NewExtentInCore(path.Join(dataPath, strconv.FormatUint(extentID, 10)), extentID)
+	if e.file, err = os.Open(e.filePath); err != nil {
+		return
+	}
+	defer e.file.Close()
+	var info os.FileInfo
+	if info, err = e.file.Stat(); err != nil {
+		return
+	}
+	e.dataSize = info.Size()
+	if !IsTinyExtent(extentID) {
+		var fp *os.File
+		if fp, err = os.Open(path.Join(dataPath, ExtCompressHeaderFileName)); err == nil {
+			e.compressHeader = make([]byte, CompressHeaderSize)
+			_, err = fp.ReadAt(e.compressHeader, int64(extentID*CompressHeaderSize))
+			fp.Close()
+		}
+		if err != nil && err != io.EOF && !os.IsNotExist(err) {
+			return fmt.Errorf("read compression headers of extent %v: %v", extentID, err)
+		}
+	}
+	size := int64(len(data))
+	if offset < 0 || offset+size > e.dataSize {
+		return io.EOF
+	}
+	if e.hasCompressedBlocks(offset, size) {
+		return e.readCompressed(data, offset, size)
+	}
+	_, err = e.readAt(data, offset)
+	return
+}
+
+// PersistenceCompressHeader persists the compression header of the block of the extent.
+func (s *ExtentStore) PersistenceCompressHeader(e *Extent, blockNo int) (err error) {
+	startIdx := blockNo * BlockCompressHeaderSize
+	endIdx := startIdx + BlockCompressHeaderSize
+	headerStart := startIdx + int(CompressHeaderSize*e.extentID)
+	_, err = s.compressHeaderFp.WriteAt(e.compressHeader[startIdx:endIdx], int64(headerStart))
+	return
+}
+
+func (s *ExtentStore) DeleteCompressHeader(extentID uint64) (err error) {
+	err = fallocate(int(s.compressHeaderFp.Fd()), FallocFLPunchHole|FallocFLKeepSize,
+		int64(CompressHeaderSize*extentID), CompressHeaderSize)
+	return
+}
+
+// SetCompression sets the codec of the blocks written afterwards.
+func (s *ExtentStore) SetCompression(codec uint8) {
+	atomic.StoreUint32(&s.compression, uint32(codec))
+}
+
+func (s *ExtentStore) Compression() uint8 {
+	return uint8(atomic.LoadUint32(&s.compression))
+}
+
+// CompressionStat returns the sizes of the data and the stored ones of the blocks written with
+// the compression enabled since the extent store is loaded.
+func (s *ExtentStore) CompressionStat() (rawSize, storedSize int64) {
+	return atomic.LoadInt64(&s.compressRawSize), atomic.LoadInt64(&s.compressStoredSize)
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/fault"
 	"github.com/chubaofs/chubaofs/util/log"
 	"hash/crc32"
 	"io"
@@ -123,16 +124,22 @@ type ExtentStore struct {
 	partitionID                       uint64
 	verifyExtentFp                    *os.File
 	hasAllocSpaceExtentIDOnVerfiyFile uint64
+	compressHeaderFp                  *os.File
+	compression                       uint32 // codec of the blocks written
+	compressRawSize                   int64
+	compressStoredSize                int64
+	ioEngine                          IOEngine // shared by the stores on the disk
 }
 
 func MkdirAll(name string) (err error) {
 	return os.MkdirAll(name, 0755)
 }
 
-func NewExtentStore(dataDir string, partitionID uint64, storeSize int) (s *ExtentStore, err error) {
+func NewExtentStore(dataDir string, partitionID uint64, storeSize int, ioEngine IOEngine) (s *ExtentStore, err error) {
 	s = new(ExtentStore)
 	s.dataPath = dataDir
 	s.partitionID = partitionID
+	s.ioEngine = ioEngine
 	if err = MkdirAll(dataDir); err != nil {
 		return nil, fmt.Errorf("NewExtentStore [%v] err[%v]", dataDir, err)
 	}
@@ -147,6 +154,9 @@ func NewExtentStore(dataDir string, partitionID uint64, storeSize int) (s *Exten
 	if s.verifyExtentFp, err = os.OpenFile(path.Join(s.dataPath, ExtCrcHeaderFileName), os.O_CREATE|os.O_RDWR, 0666); err != nil {
 		return
 	}
+	if s.compressHeaderFp, err = os.OpenFile(path.Join(s.dataPath, ExtCompressHeaderFileName), os.O_CREATE|os.O_RDWR, 0666); err != nil {
+		return
+	}
 	if s.metadataFp, err = os.OpenFile(path.Join(s.dataPath, ExtBaseExtentIDFileName), os.O_CREATE|os.O_RDWR, 0666); err != nil {
 		return
 	}
@@ -226,7 +236,11 @@ func (s *ExtentStore) Create(extentID uint64) (err error) {
 		return err
 	}
 	e = NewExtentInCore(name, extentID)
+	e.io = s.ioEngine
 	e.header = make([]byte, util.BlockHeaderSize)
+	if !IsTinyExtent(extentID) {
+		e.compressHeader = make([]byte, CompressHeaderSize)
+	}
 	err = e.InitToFS()
 	if err != nil {
 		return err
@@ -302,8 +316,27 @@ func (s *ExtentStore) Write(extentID uint64, offset, size int64, data []byte, cr
 	if err = s.checkOffsetAndSize(extentID, offset, size); err != nil {
 		return err
 	}
-	err = e.Write(data, offset, size, crc, writeType, isSync, s.PersistenceBlockCrc, ei)
-	if err != nil {
+	if fault.Enabled {
+		if err = fault.Inject(fault.PointDiskWrite); err != nil {
+			// a dropped write is lost silently, an injected error goes through the
+			// handling of the disk errors
+			if err == fault.ErrDropped {
+				return nil
+			}
+			return fmt.Errorf("%v: %v", err, syscall.EIO)
+		}
+	}
+	// the blocks compressed are rewritten as a whole even if the compression is disabled
+	if codec := s.Compression(); !IsTinyExtent(extentID) && (codec != CompressNone || e.hasCompressedBlocks(offset, size)) {
+		var rawSize, storedSize int64
+		rawSize, storedSize, err = e.WriteCompressed(data, offset, size, crc, writeType, isSync, codec,
+			s.PersistenceBlockCrc, s.PersistenceCompressHeader)
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.compressRawSize, rawSize)
+		atomic.AddInt64(&s.compressStoredSize, storedSize)
+	} else if err = e.Write(data, offset, size, crc, writeType, isSync, s.PersistenceBlockCrc, ei); err != nil {
 		return err
 	}
 	ei.UpdateExtentInfo(e, 0)
@@ -350,6 +383,20 @@ func (s *ExtentStore) Read(extentID uint64, offset, size int64, nbuf []byte, isR
 	return
 }
 
+// SendableBlock returns the file of the extent and the CRC of the block at the offset, so that the
+// block can be sent by sendfile(2) instead of being read through the user space. The other blocks,
+// e.g. the compressed or partial ones, or the ones whose CRCs are not recorded, must be read.
+func (s *ExtentStore) SendableBlock(extentID uint64, offset, size int64) (file *os.File, crc uint32, ok bool) {
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	e, err := s.extentWithHeader(ei)
+	if err != nil || s.checkOffsetAndSize(extentID, offset, size) != nil {
+		return
+	}
+	return e.sendableBlock(offset, size)
+}
+
 func (s *ExtentStore) tinyDelete(e *Extent, offset, size, tinyDeleteFileOffset int64) (err error) {
 	if offset+size > e.dataSize {
 		return
@@ -397,6 +444,7 @@ func (s *ExtentStore) MarkDelete(extentID uint64, offset, size, tinyDeleteFileOf
 	ei.ModifyTime = time.Now().Unix()
 	s.cache.Del(e.extentID)
 	s.DeleteBlockCrc(extentID)
+	s.DeleteCompressHeader(extentID)
 
 	return
 }
@@ -418,6 +466,8 @@ func (s *ExtentStore) Close() {
 	s.normalExtentDeleteFp.Close()
 	s.verifyExtentFp.Sync()
 	s.verifyExtentFp.Close()
+	s.compressHeaderFp.Sync()
+	s.compressHeaderFp.Close()
 	s.closed = true
 }
 
@@ -681,6 +731,11 @@ func (s *ExtentStore) getExtentKey(extent uint64) string {
 	return fmt.Sprintf("extent %v_%v", s.partitionID, extent)
 }
 
+// BaseExtentID returns the largest extent ID allocated, which is never less than MinExtentID.
+func (s *ExtentStore) BaseExtentID() uint64 {
+	return atomic.LoadUint64(&s.baseExtentID)
+}
+
 // UpdateBaseExtentID updates the base extent ID.
 func (s *ExtentStore) UpdateBaseExtentID(id uint64) (err error) {
 	if IsTinyExtent(id) {
@@ -747,6 +802,7 @@ func (s *ExtentStore) GetExtentCount() (count int) {
 func (s *ExtentStore) loadExtentFromDisk(extentID uint64, putCache bool) (e *Extent, err error) {
 	name := path.Join(s.dataPath, strconv.Itoa(int(extentID)))
 	e = NewExtentInCore(name, extentID)
+	e.io = s.ioEngine
 	if err = e.RestoreFromFS(); err != nil {
 		err = fmt.Errorf("restore from file %v putCache %v system: %v", name, putCache, err)
 		return
@@ -759,6 +815,10 @@ func (s *ExtentStore) loadExtentFromDisk(extentID uint64, putCache bool) (e *Ext
 		if _, err = s.verifyExtentFp.ReadAt(e.header, int64(extentID*util.BlockHeaderSize)); err != nil && err != io.EOF {
 			return
 		}
+		e.compressHeader = make([]byte, CompressHeaderSize)
+		if _, err = s.compressHeaderFp.ReadAt(e.compressHeader, int64(extentID*CompressHeaderSize)); err != nil && err != io.EOF {
+			return
+		}
 	}
 	err = nil
 	s.cache.Put(e)
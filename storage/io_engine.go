@@ -0,0 +1,110 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chubaofs/chubaofs/util/iouring"
+)
+
+const (
+	IOEngineSync    = "sync"
+	IOEngineIOUring = "io_uring"
+)
+
+// IOEngine performs the positional reads and writes of the extent files on a disk.
+type IOEngine interface {
+	Name() string
+	ReadAt(f *os.File, b []byte, offset int64) (int, error)
+	WriteAt(f *os.File, b []byte, offset int64) (int, error)
+	Close() error
+}
+
+// NewIOEngine creates the engine of the name, the sync one if the name is empty.
+func NewIOEngine(name string) (IOEngine, error) {
+	switch name {
+	case "", IOEngineSync:
+		return syncIOEngine{}, nil
+	case IOEngineIOUring:
+		ring, err := iouring.New(iouring.DefaultEntries)
+		if err != nil {
+			return nil, fmt.Errorf("NewIOEngine: %v: %v", name, err)
+		}
+		return &ioUringEngine{ring: ring}, nil
+	default:
+		return nil, fmt.Errorf("NewIOEngine: unknown io engine %v", name)
+	}
+}
+
+// syncIOEngine blocks a thread by pread(2) or pwrite(2) per request.
+type syncIOEngine struct{}
+
+func (syncIOEngine) Name() string {
+	return IOEngineSync
+}
+
+func (syncIOEngine) ReadAt(f *os.File, b []byte, offset int64) (int, error) {
+	return f.ReadAt(b, offset)
+}
+
+func (syncIOEngine) WriteAt(f *os.File, b []byte, offset int64) (int, error) {
+	return f.WriteAt(b, offset)
+}
+
+func (syncIOEngine) Close() error {
+	return nil
+}
+
+// ioUringEngine submits the requests of all the extent stores on a disk to an io_uring.
+type ioUringEngine struct {
+	ring *iouring.Ring
+}
+
+func (e *ioUringEngine) Name() string {
+	return IOEngineIOUring
+}
+
+// do runs the request on the descriptor of the file, which is not closed until it is done.
+func (e *ioUringEngine) do(f *os.File, op func(fd int) (int, error)) (n int, err error) {
+	rawConn, err := f.SyscallConn()
+	if err != nil {
+		return
+	}
+	var opErr error
+	if err = rawConn.Control(func(fd uintptr) {
+		n, opErr = op(int(fd))
+	}); err != nil {
+		return
+	}
+	return n, opErr
+}
+
+func (e *ioUringEngine) ReadAt(f *os.File, b []byte, offset int64) (int, error) {
+	return e.do(f, func(fd int) (int, error) {
+		return e.ring.ReadAt(fd, b, offset)
+	})
+}
+
+func (e *ioUringEngine) WriteAt(f *os.File, b []byte, offset int64) (int, error) {
+	return e.do(f, func(fd int) (int, error) {
+		return e.ring.WriteAt(fd, b, offset)
+	})
+}
+
+func (e *ioUringEngine) Close() error {
+	return e.ring.Close()
+}
@@ -0,0 +1,200 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package audit records the operations changing the namespace, e.g. who deleted a directory, and
+// ships them to the sinks in the background. A slow or broken sink never blocks the operations:
+// the entries are dropped once its buffer is full, and an entry counting them is written when the
+// sink recovers, so that the gaps in the audit log are visible.
+package audit
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	DefaultBufferSize = 64 * 1024 // number of the entries buffered in memory for each sink
+
+	OpDropped = "AuditDropped"
+
+	batchSize     = 256
+	flushInterval = time.Second
+	maxBackoff    = 30 * time.Second
+)
+
+// Entry is an operation recorded by the audit log.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Op        string    `json:"op"`
+	Vol       string    `json:"vol"`
+	Partition uint64    `json:"pid"`
+	Client    string    `json:"client"`
+	ReqID     int64     `json:"reqid"`
+	Parent    uint64    `json:"pino,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Inode     uint64    `json:"ino,omitempty"`
+	Result    string    `json:"result"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Sink writes the entries to a file or a remote service.
+type Sink interface {
+	// Write writes a batch of entries, the batch is retried if an error is returned.
+	Write(entries []*Entry) error
+	Close() error
+}
+
+// Logger ships the entries to each of the sinks. A nil Logger discards the entries.
+type Logger struct {
+	writers []*writer
+}
+
+// NewLogger returns a logger buffering at most bufferSize entries for each of the sinks.
+func NewLogger(bufferSize int, sinks ...Sink) *Logger {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	l := new(Logger)
+	for _, sink := range sinks {
+		l.writers = append(l.writers, newWriter(sink, bufferSize))
+	}
+	return l
+}
+
+// Log records the entry, the time of which is set if not given.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for _, w := range l.writers {
+		w.add(e)
+	}
+}
+
+// Close writes the entries buffered and closes the sinks.
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+	for _, w := range l.writers {
+		w.close()
+	}
+}
+
+type writer struct {
+	sink    Sink
+	queue   chan *Entry
+	dropped uint64
+	stopC   chan struct{}
+	doneC   chan struct{}
+}
+
+func newWriter(sink Sink, bufferSize int) *writer {
+	w := &writer{
+		sink:  sink,
+		queue: make(chan *Entry, bufferSize),
+		stopC: make(chan struct{}),
+		doneC: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *writer) add(e *Entry) {
+	select {
+	case w.queue <- e:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
+
+func (w *writer) run() {
+	defer close(w.doneC)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Entry, 0, batchSize)
+	for {
+		select {
+		case e := <-w.queue:
+			batch = append(batch, e)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 && atomic.LoadUint64(&w.dropped) == 0 {
+				continue
+			}
+		case <-w.stopC:
+			for len(w.queue) > 0 {
+				batch = append(batch, <-w.queue)
+			}
+			if batch = w.withDropped(batch); len(batch) > 0 {
+				if err := w.sink.Write(batch); err != nil {
+					log.LogErrorf("audit: write %v entries on close failed: %v", len(batch), err)
+				}
+			}
+			return
+		}
+		if !w.write(batch) {
+			return
+		}
+		batch = batch[:0]
+	}
+}
+
+// write retries the batch with backoff until it succeeds or the writer is closed.
+func (w *writer) write(batch []*Entry) bool {
+	batch = w.withDropped(batch)
+	backoff := flushInterval
+	for {
+		err := w.sink.Write(batch)
+		if err == nil {
+			return true
+		}
+		log.LogWarnf("audit: write %v entries failed: %v", len(batch), err)
+		select {
+		case <-w.stopC:
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (w *writer) withDropped(batch []*Entry) []*Entry {
+	dropped := atomic.SwapUint64(&w.dropped, 0)
+	if dropped == 0 {
+		return batch
+	}
+	log.LogWarnf("audit: buffer is full, %v entries dropped", dropped)
+	return append(batch, &Entry{
+		Time:   time.Now(),
+		Op:     OpDropped,
+		Result: fmt.Sprintf("%v entries dropped", dropped),
+	})
+}
+
+func (w *writer) close() {
+	close(w.stopC)
+	<-w.doneC
+	w.sink.Close()
+}
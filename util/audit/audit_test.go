@@ -0,0 +1,108 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type memorySink struct {
+	sync.Mutex
+	entries []*Entry
+}
+
+func (s *memorySink) Write(entries []*Entry) error {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	sink := new(memorySink)
+	l := NewLogger(2, sink)
+	for ino := uint64(1); ino <= 4; ino++ {
+		l.Log(&Entry{Op: "OpMetaDeleteDentry", Vol: "vol", Inode: ino})
+	}
+	l.Close()
+
+	if len(sink.entries) == 0 || sink.entries[0].Inode != 1 || sink.entries[0].Time.IsZero() {
+		t.Fatalf("unexpected entries %v", sink.entries)
+	}
+	total := len(sink.entries)
+	if last := sink.entries[total-1]; last.Op == OpDropped {
+		total--
+	}
+	if total > 4 {
+		t.Fatalf("too many entries %v", sink.entries)
+	}
+
+	// a nil logger discards the entries
+	var nilLogger *Logger
+	nilLogger.Log(&Entry{Op: "OpMetaCreateInode"})
+	nilLogger.Close()
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	line, _ := encodeLines([]*Entry{{Op: "OpMetaCreateDentry", Name: "a"}})
+	// each write of two entries fills a file, and the third write rotates the second file away
+	sink, err := NewFileSink(dir, int64(len(line)*2), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err = sink.Write([]*Entry{{Op: "OpMetaCreateDentry", Name: name}, {Op: "OpMetaCreateDentry", Name: name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, fileName+".*"))
+	if len(rotated) != 1 {
+		t.Fatalf("rotated files %v, expect 1 backup", rotated)
+	}
+	f, err := os.Open(filepath.Join(dir, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := new(Entry)
+		if err = json.Unmarshal(scanner.Bytes(), e); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, e.Name)
+	}
+	if len(names) != 2 || names[0] != "c" {
+		t.Fatalf("entries of the current file %v, expect [c c]", names)
+	}
+}
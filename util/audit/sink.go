@@ -0,0 +1,159 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	DefaultFileSize    = 256 * 1024 * 1024
+	DefaultFileBackups = 10
+
+	fileName       = "audit.log"
+	fileTimeFormat = "20060102150405.000"
+)
+
+// FileSink writes the entries as the lines of json to the audit.log in the directory, which is
+// rotated once larger than the max size, and only the latest backups of the rotated files are kept.
+type FileSink struct {
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+// NewFileSink creates the sink writing to the directory.
+func NewFileSink(dir string, maxSize int64, backups int) (s *FileSink, err error) {
+	if maxSize <= 0 {
+		maxSize = DefaultFileSize
+	}
+	if backups <= 0 {
+		backups = DefaultFileBackups
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	s = &FileSink{path: filepath.Join(dir, fileName), maxSize: maxSize, backups: backups}
+	if err = s.open(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (s *FileSink) open() (err error) {
+	if s.file, err = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		s.file.Close()
+		return
+	}
+	s.size = info.Size()
+	return
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(entries []*Entry) (err error) {
+	data, err := encodeLines(entries)
+	if err != nil {
+		return
+	}
+	if s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return
+		}
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return
+}
+
+// rotate renames the file by the current time, and removes the rotated files beyond the backups.
+func (s *FileSink) rotate() (err error) {
+	s.file.Close()
+	if err = os.Rename(s.path, s.path+"."+time.Now().Format(fileTimeFormat)); err != nil {
+		log.LogWarnf("audit: rotate %v failed: %v", s.path, err)
+	}
+	if err = s.open(); err != nil {
+		return
+	}
+	rotated, _ := filepath.Glob(s.path + ".*")
+	sort.Strings(rotated)
+	for len(rotated) > s.backups {
+		os.Remove(rotated[0])
+		rotated = rotated[1:]
+	}
+	return
+}
+
+// Close implements Sink.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// remoteSink ships the entries to a remote sink of the log, one line of json for each entry.
+type remoteSink struct {
+	sink log.RemoteSink
+}
+
+// NewRemoteSink creates the sink shipping to the address, which has the format of the remote
+// log address, e.g. kafka://broker1:port,broker2:port/topic.
+func NewRemoteSink(addr string) (Sink, error) {
+	sink, err := log.NewRemoteSink(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteSink{sink: sink}, nil
+}
+
+// Write implements Sink.
+func (s *remoteSink) Write(entries []*Entry) error {
+	records := make([]*log.RemoteRecord, 0, len(entries))
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encode audit entry: %v", err)
+		}
+		records = append(records, &log.RemoteRecord{Time: e.Time, Level: log.InfoLevel, Module: "audit", Line: line})
+	}
+	return s.sink.Send(records)
+}
+
+// Close implements Sink.
+func (s *remoteSink) Close() error {
+	return s.sink.Close()
+}
+
+func encodeLines(entries []*Entry) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, fmt.Errorf("encode audit entry: %v", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
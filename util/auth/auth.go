@@ -5,4 +5,6 @@ type TicketMess struct {
 	TicketHost  string
 	EnableHTTPS bool
 	CertFile    string
+	Principal   string
+	Password    string
 }
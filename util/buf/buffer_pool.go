@@ -2,6 +2,9 @@ package buf
 
 import (
 	"fmt"
+	"math/bits"
+	"sync"
+
 	"github.com/chubaofs/chubaofs/util"
 )
 
@@ -9,70 +12,90 @@ var (
 	Buffers = NewBufferPool()
 )
 
+// The buffers of the sizes between the packet header and the block are taken from the size classes
+// of the powers of two, so that the payloads of the small IOs and the metadata ops are reused too.
 const (
-	HeaderBufferPoolSize = 8192
-	BlockBufferPoolSize  = 1024
-	TinyBufferPoolSize   = 100
+	minClassShift = 6  // 64 bytes
+	maxClassShift = 17 // 128KB, util.BlockSize
+	classCount    = maxClassShift - minClassShift + 1
 )
 
-// BufferPool defines the struct of a buffered pool with 4 objects.
+// BufferPool defines the buffer pool of the packet headers, the tiny extents and the size classes,
+// backed by sync.Pool so that the idle buffers are released by the GC.
 type BufferPool struct {
-	pools [3]chan []byte
+	header  sync.Pool
+	tiny    sync.Pool
+	classes [classCount]sync.Pool
 }
 
 // NewBufferPool returns a new buffered pool.
 func NewBufferPool() (bufferP *BufferPool) {
 	bufferP = &BufferPool{}
-	bufferP.pools[0] = make(chan []byte, HeaderBufferPoolSize)
-	bufferP.pools[1] = make(chan []byte, HeaderBufferPoolSize)
-	bufferP.pools[2] = make(chan []byte, HeaderBufferPoolSize)
-
+	bufferP.header.New = func() interface{} {
+		return make([]byte, util.PacketHeaderSize)
+	}
+	bufferP.tiny.New = func() interface{} {
+		return make([]byte, util.DefaultTinySizeLimit)
+	}
+	for i := range bufferP.classes {
+		size := 1 << uint(minClassShift+i)
+		bufferP.classes[i].New = func() interface{} {
+			return make([]byte, size)
+		}
+	}
 	return bufferP
 }
 
-func (bufferP *BufferPool) get(index int, size int) (data []byte) {
-	select {
-	case data = <-bufferP.pools[index]:
-		return
-	default:
-		return make([]byte, size)
+// classOf returns the smallest size class which holds the size, or -1 if the size is too large.
+func classOf(size int) int {
+	if size <= 1<<minClassShift {
+		return 0
 	}
+	class := bits.Len(uint(size-1)) - minClassShift
+	if class >= classCount {
+		return -1
+	}
+	return class
 }
 
-// Get returns the data based on the given size. Different size corresponds to different object in the pool.
+// Get returns a buffer of the given size, whose capacity may be larger. The buffers larger than
+// the block and other than the tiny extent are allocated, as they are not reused.
 func (bufferP *BufferPool) Get(size int) (data []byte, err error) {
-	if size == util.PacketHeaderSize {
-		return bufferP.get(0, size), nil
-	} else if size == util.BlockSize {
-		return bufferP.get(1, size), nil
-	} else if size == util.DefaultTinySizeLimit {
-		return bufferP.get(2, size), nil
+	if size < 0 {
+		return nil, fmt.Errorf("invalid buffer size %v", size)
 	}
-	return nil, fmt.Errorf("can only support 45 or 65536 bytes")
-}
-
-func (bufferP *BufferPool) put(index int, data []byte) {
-	select {
-	case bufferP.pools[index] <- data:
-		return
-	default:
-		return
+	switch size {
+	case 0:
+		return []byte{}, nil
+	case util.PacketHeaderSize:
+		return bufferP.header.Get().([]byte), nil
+	case util.DefaultTinySizeLimit:
+		return bufferP.tiny.Get().([]byte), nil
+	}
+	if class := classOf(size); class >= 0 {
+		data = bufferP.classes[class].Get().([]byte)
+		return data[:size], nil
 	}
+	return make([]byte, size), nil
 }
 
-// Put puts the given data into the buffer pool.
+// Put puts the given data into the buffer pool. The data must not be referenced after it is put,
+// and the buffers which are not taken from the pool are ignored unless they match a size class.
 func (bufferP *BufferPool) Put(data []byte) {
-	if data == nil {
+	size := cap(data)
+	data = data[:size]
+	switch size {
+	case 0:
+		return
+	case util.PacketHeaderSize:
+		bufferP.header.Put(data)
+		return
+	case util.DefaultTinySizeLimit:
+		bufferP.tiny.Put(data)
 		return
 	}
-	size := len(data)
-	if size == util.PacketHeaderSize {
-		bufferP.put(0, data)
-	} else if size == util.BlockSize {
-		bufferP.put(1, data)
-	} else if size == util.DefaultTinySizeLimit {
-		bufferP.put(2, data)
+	if class := classOf(size); class >= 0 && size == 1<<uint(minClassShift+class) {
+		bufferP.classes[class].Put(data)
 	}
-
 	return
 }
@@ -0,0 +1,65 @@
+package buf
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/util"
+)
+
+func TestBufferPoolSizeClasses(t *testing.T) {
+	bp := NewBufferPool()
+	for _, c := range []struct {
+		size, cap int
+	}{
+		{0, 0},
+		{1, 64},
+		{64, 64},
+		{65, 128},
+		{util.PacketHeaderSize, util.PacketHeaderSize},
+		{4096, 4096},
+		{4097, 8192},
+		{util.BlockSize, util.BlockSize},
+		{util.BlockSize + 1, util.BlockSize + 1},
+		{util.DefaultTinySizeLimit, util.DefaultTinySizeLimit},
+	} {
+		data, err := bp.Get(c.size)
+		if err != nil {
+			t.Fatalf("get %v: %v", c.size, err)
+		}
+		if len(data) != c.size || cap(data) != c.cap {
+			t.Fatalf("get %v: len %v cap %v, expect cap %v", c.size, len(data), cap(data), c.cap)
+		}
+		bp.Put(data)
+	}
+	if _, err := bp.Get(-1); err == nil {
+		t.Fatalf("get a negative size")
+	}
+}
+
+func TestBufferPoolReuse(t *testing.T) {
+	bp := NewBufferPool()
+	data, _ := bp.Get(1000)
+	data[0] = 'x'
+	bp.Put(data[:10])
+	// sync.Pool may drop the buffer, but a reused one must be of the full class
+	again, _ := bp.Get(1024)
+	if len(again) != 1024 || cap(again) != 1024 {
+		t.Fatalf("reused buffer: len %v cap %v", len(again), cap(again))
+	}
+	// the buffers of the other capacities are not pooled
+	bp.Put(make([]byte, 1000))
+	for i := 0; i < 10; i++ {
+		if data, _ = bp.Get(1000); cap(data) != 1024 {
+			t.Fatalf("foreign buffer reused: cap %v", cap(data))
+		}
+	}
+}
+
+func BenchmarkBufferPool(b *testing.B) {
+	bp := NewBufferPool()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, _ := bp.Get(4096)
+		bp.Put(data)
+	}
+}
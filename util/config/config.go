@@ -144,3 +144,15 @@ func (c *Config) GetArray(key string) []interface{} {
 	}
 	return result.([]interface{})
 }
+
+// GetMap returns a map for the config key.
+func (c *Config) GetMap(key string) map[string]interface{} {
+	x, present := c.data[key]
+	if !present {
+		return nil
+	}
+	if result, isMap := x.(map[string]interface{}); isMap {
+		return result
+	}
+	return nil
+}
@@ -18,10 +18,12 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/chubaofs/chubaofs/util/mtls"
 )
 
 type Object struct {
-	conn *net.TCPConn
+	conn net.Conn
 	idle int64
 }
 
@@ -44,19 +46,11 @@ func NewConnectPool() (cp *ConnectPool) {
 	return cp
 }
 
-func DailTimeOut(target string, timeout time.Duration) (c *net.TCPConn, err error) {
-	var connect net.Conn
-	connect, err = net.DialTimeout("tcp", target, timeout)
-	if err == nil {
-		conn := connect.(*net.TCPConn)
-		conn.SetKeepAlive(true)
-		conn.SetNoDelay(true)
-		c = conn
-	}
-	return
+func DailTimeOut(target string, timeout time.Duration) (c net.Conn, err error) {
+	return mtls.Dial(target, timeout)
 }
 
-func (cp *ConnectPool) GetConnect(targetAddr string) (c *net.TCPConn, err error) {
+func (cp *ConnectPool) GetConnect(targetAddr string) (c net.Conn, err error) {
 	cp.RLock()
 	pool, ok := cp.pools[targetAddr]
 	cp.RUnlock()
@@ -70,7 +64,7 @@ func (cp *ConnectPool) GetConnect(targetAddr string) (c *net.TCPConn, err error)
 	return pool.GetConnectFromPool()
 }
 
-func (cp *ConnectPool) PutConnect(c *net.TCPConn, forceClose bool) {
+func (cp *ConnectPool) PutConnect(c net.Conn, forceClose bool) {
 	if c == nil {
 		return
 	}
@@ -129,11 +123,8 @@ func NewPool(min, max int, timeout int64, target string) (p *Pool) {
 
 func (p *Pool) initAllConnect() {
 	for i := 0; i < p.mincap; i++ {
-		c, err := net.Dial("tcp", p.target)
+		conn, err := mtls.Dial(p.target, 0)
 		if err == nil {
-			conn := c.(*net.TCPConn)
-			conn.SetKeepAlive(true)
-			conn.SetNoDelay(true)
 			o := &Object{conn: conn, idle: time.Now().UnixNano()}
 			p.PutConnectObjectToPool(o)
 		}
@@ -168,19 +159,11 @@ func (p *Pool) autoRelease() {
 	}
 }
 
-func (p *Pool) NewConnect(target string) (c *net.TCPConn, err error) {
-	var connect net.Conn
-	connect, err = net.Dial("tcp", p.target)
-	if err == nil {
-		conn := connect.(*net.TCPConn)
-		conn.SetKeepAlive(true)
-		conn.SetNoDelay(true)
-		c = conn
-	}
-	return
+func (p *Pool) NewConnect(target string) (c net.Conn, err error) {
+	return mtls.Dial(p.target, 0)
 }
 
-func (p *Pool) GetConnectFromPool() (c *net.TCPConn, err error) {
+func (p *Pool) GetConnectFromPool() (c net.Conn, err error) {
 	var (
 		o *Object
 	)
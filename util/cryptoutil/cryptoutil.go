@@ -122,6 +122,11 @@ func genKey(key []byte, data []byte) (sessionKey []byte) {
 	return
 }
 
+// GenIdentityKey derives the key of a principal from its password
+func GenIdentityKey(principal string, password string) (key []byte) {
+	return genKey([]byte(password), []byte(principal))
+}
+
 // AuthGenSessionKeyTS authnode generates a session key according to its master key and current timestamp
 func AuthGenSessionKeyTS(key []byte) (sessionKey []byte) {
 	data := []byte(strconv.FormatInt(int64(time.Now().Unix()), 10))
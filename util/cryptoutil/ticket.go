@@ -42,4 +42,5 @@ type Ticket struct {
 	Exp        int64     `json:"exp"`
 	IP         string    `json:"ip"`
 	Caps       []byte    `json:"caps"`
+	ClientID   string    `json:"client_id,omitempty"`
 }
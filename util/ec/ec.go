@@ -0,0 +1,320 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ec implements the systematic Reed-Solomon erasure code over GF(2^8) used by the
+// erasure coded data partitions.
+//
+// The k data shards are kept as they are and m parity shards are computed from them, any k of
+// the k+m shards are enough to rebuild the others. The coding matrix is derived from a
+// Vandermonde matrix, so that every k rows of it are linearly independent.
+package ec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1 of the field
+	polynomial = 0x11d
+
+	MaxShards = 32
+)
+
+var (
+	ErrInvalidScheme  = errors.New("invalid erasure coding scheme")
+	ErrShardNum       = errors.New("wrong number of shards")
+	ErrShardSize      = errors.New("shards of different sizes")
+	ErrTooFewShards   = errors.New("too few shards to reconstruct")
+	ErrSingularMatrix = errors.New("matrix is singular")
+)
+
+var (
+	expTable [510]byte
+	logTable [256]byte
+	mulTable [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		expTable[i+255] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= polynomial
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			mulTable[a][b] = expTable[int(logTable[a])+int(logTable[b])]
+		}
+	}
+}
+
+func galMul(a, b byte) byte {
+	return mulTable[a][b]
+}
+
+// galInv returns the multiplicative inverse of a, which must not be zero.
+func galInv(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+func galExp(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])*n)%255]
+}
+
+// mulAdd adds c*in to out.
+func mulAdd(c byte, in, out []byte) {
+	if c == 0 {
+		return
+	}
+	table := &mulTable[c]
+	for i, b := range in {
+		out[i] ^= table[b]
+	}
+}
+
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for r := range m {
+		m[r] = make([]byte, cols)
+	}
+	return m
+}
+
+func (m matrix) multiply(o matrix) matrix {
+	result := newMatrix(len(m), len(o[0]))
+	for r := range m {
+		for c := range o[0] {
+			var v byte
+			for i := range o {
+				v ^= galMul(m[r][i], o[i][c])
+			}
+			result[r][c] = v
+		}
+	}
+	return result
+}
+
+// invert inverts the square matrix by the Gauss-Jordan elimination.
+func (m matrix) invert() (matrix, error) {
+	n := len(m)
+	work := newMatrix(n, 2*n)
+	for r := range m {
+		copy(work[r], m[r])
+		work[r][n+r] = 1
+	}
+	for c := 0; c < n; c++ {
+		for r := c + 1; work[c][c] == 0 && r < n; r++ {
+			if work[r][c] != 0 {
+				work[c], work[r] = work[r], work[c]
+			}
+		}
+		if work[c][c] == 0 {
+			return nil, ErrSingularMatrix
+		}
+		if inv := galInv(work[c][c]); inv != 1 {
+			for j := range work[c] {
+				work[c][j] = galMul(work[c][j], inv)
+			}
+		}
+		for r := 0; r < n; r++ {
+			if r != c && work[r][c] != 0 {
+				f := work[r][c]
+				for j := range work[r] {
+					work[r][j] ^= galMul(f, work[c][j])
+				}
+			}
+		}
+	}
+	inv := make(matrix, n)
+	for r := range work {
+		inv[r] = work[r][n:]
+	}
+	return inv, nil
+}
+
+// Encoder encodes and reconstructs the shards of a scheme.
+type Encoder struct {
+	dataShards   int
+	parityShards int
+	matrix       matrix // the (k+m)*k coding matrix whose top k rows are the identity
+}
+
+// NewEncoder returns the encoder of k data shards and m parity shards.
+func NewEncoder(dataShards, parityShards int) (*Encoder, error) {
+	if dataShards <= 0 || parityShards <= 0 || dataShards+parityShards > MaxShards {
+		return nil, ErrInvalidScheme
+	}
+	total := dataShards + parityShards
+	vandermonde := newMatrix(total, dataShards)
+	for r := 0; r < total; r++ {
+		for c := 0; c < dataShards; c++ {
+			vandermonde[r][c] = galExp(byte(r), c)
+		}
+	}
+	top, err := vandermonde[:dataShards].invert()
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		matrix:       vandermonde.multiply(top),
+	}, nil
+}
+
+func (e *Encoder) DataShards() int {
+	return e.dataShards
+}
+
+func (e *Encoder) ParityShards() int {
+	return e.parityShards
+}
+
+func (e *Encoder) TotalShards() int {
+	return e.dataShards + e.parityShards
+}
+
+// shardSize returns the size of the shards present, which must be the same.
+func (e *Encoder) shardSize(shards [][]byte) (size int, err error) {
+	if len(shards) != e.TotalShards() {
+		return 0, ErrShardNum
+	}
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		if size != 0 && len(shard) != size {
+			return 0, ErrShardSize
+		}
+		size = len(shard)
+	}
+	return
+}
+
+// Encode computes the parity shards from the data shards, the parity shards are allocated if
+// they are not of the size of the data shards.
+func (e *Encoder) Encode(shards [][]byte) error {
+	if len(shards) != e.TotalShards() {
+		return ErrShardNum
+	}
+	size := len(shards[0])
+	for _, shard := range shards[:e.dataShards] {
+		if size == 0 || len(shard) != size {
+			return ErrShardSize
+		}
+	}
+	for i := e.dataShards; i < len(shards); i++ {
+		if len(shards[i]) != size {
+			shards[i] = make([]byte, size)
+		}
+	}
+	codeShards(e.matrix[e.dataShards:], shards[:e.dataShards], shards[e.dataShards:])
+	return nil
+}
+
+// Reconstruct rebuilds the shards which are empty, from any k shards present.
+func (e *Encoder) Reconstruct(shards [][]byte) error {
+	size, err := e.shardSize(shards)
+	if err != nil {
+		return err
+	}
+	var (
+		rows   = make(matrix, 0, e.dataShards)
+		inputs = make([][]byte, 0, e.dataShards)
+	)
+	for i, shard := range shards {
+		if len(shard) > 0 && len(rows) < e.dataShards {
+			rows = append(rows, e.matrix[i])
+			inputs = append(inputs, shard)
+		}
+	}
+	if len(rows) < e.dataShards {
+		return ErrTooFewShards
+	}
+	decode, err := rows.invert()
+	if err != nil {
+		return err
+	}
+	var (
+		missingRows matrix
+		outputs     [][]byte
+	)
+	for i := 0; i < e.dataShards; i++ {
+		if len(shards[i]) == 0 {
+			shards[i] = make([]byte, size)
+			missingRows = append(missingRows, decode[i])
+			outputs = append(outputs, shards[i])
+		}
+	}
+	codeShards(missingRows, inputs, outputs)
+	missingRows, outputs = nil, nil
+	for i := e.dataShards; i < len(shards); i++ {
+		if len(shards[i]) == 0 {
+			shards[i] = make([]byte, size)
+			missingRows = append(missingRows, e.matrix[i])
+			outputs = append(outputs, shards[i])
+		}
+	}
+	codeShards(missingRows, shards[:e.dataShards], outputs)
+	return nil
+}
+
+func codeShards(rows matrix, inputs, outputs [][]byte) {
+	for i, out := range outputs {
+		for j := range out {
+			out[j] = 0
+		}
+		for j, in := range inputs {
+			mulAdd(rows[i][j], in, out)
+		}
+	}
+}
+
+// ParseScheme parses the scheme like "4+2" into the numbers of the data and the parity shards.
+func ParseScheme(scheme string) (dataShards, parityShards int, err error) {
+	parts := strings.Split(scheme, "+")
+	if len(parts) != 2 {
+		return 0, 0, ErrInvalidScheme
+	}
+	if dataShards, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, ErrInvalidScheme
+	}
+	if parityShards, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, ErrInvalidScheme
+	}
+	if dataShards <= 1 || parityShards <= 0 || dataShards+parityShards > MaxShards {
+		return 0, 0, ErrInvalidScheme
+	}
+	return
+}
+
+// Scheme returns the scheme of k data shards and m parity shards.
+func Scheme(dataShards, parityShards int) string {
+	return fmt.Sprintf("%v+%v", dataShards, parityShards)
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ec
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeAndReconstruct(t *testing.T) {
+	for _, scheme := range []string{"4+2", "6+3", "10+4"} {
+		k, m, err := ParseScheme(scheme)
+		if err != nil {
+			t.Fatalf("parse scheme %v: %v", scheme, err)
+		}
+		e, err := NewEncoder(k, m)
+		if err != nil {
+			t.Fatalf("new encoder %v: %v", scheme, err)
+		}
+		shards := make([][]byte, k+m)
+		for i := 0; i < k; i++ {
+			shards[i] = make([]byte, 1000)
+			rand.Read(shards[i])
+		}
+		if err = e.Encode(shards); err != nil {
+			t.Fatalf("encode %v: %v", scheme, err)
+		}
+		origin := make([][]byte, len(shards))
+		for i := range shards {
+			origin[i] = append([]byte(nil), shards[i]...)
+		}
+
+		// lose every combination of m shards at the head, the tail and spread
+		for _, lost := range [][]int{rangeOf(0, m), rangeOf(k, m), rangeOf(k-m/2-1, m)} {
+			for _, i := range lost {
+				shards[i] = nil
+			}
+			if err = e.Reconstruct(shards); err != nil {
+				t.Fatalf("reconstruct %v lost %v: %v", scheme, lost, err)
+			}
+			for i := range shards {
+				if !bytes.Equal(shards[i], origin[i]) {
+					t.Fatalf("reconstruct %v lost %v: shard %v mismatch", scheme, lost, i)
+				}
+			}
+		}
+
+		for _, i := range rangeOf(0, m+1) {
+			shards[i] = nil
+		}
+		if err = e.Reconstruct(shards); err != ErrTooFewShards {
+			t.Fatalf("reconstruct %v with %v shards lost: %v", scheme, m+1, err)
+		}
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	for _, scheme := range []string{"", "4", "4+", "1+2", "4+0", "30+3", "a+b"} {
+		if _, _, err := ParseScheme(scheme); err != ErrInvalidScheme {
+			t.Fatalf("scheme %q is parsed: %v", scheme, err)
+		}
+	}
+	if k, m, err := ParseScheme("6+3"); err != nil || Scheme(k, m) != "6+3" {
+		t.Fatalf("parse scheme 6+3: %v %v %v", k, m, err)
+	}
+}
+
+func rangeOf(start, n int) []int {
+	r := make([]int, n)
+	for i := range r {
+		r[i] = start + i
+	}
+	return r
+}
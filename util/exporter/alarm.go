@@ -49,7 +49,8 @@ func Warning(detail string) (a *Alarm) {
 		return
 	}
 	a = AlarmPool.Get().(*Alarm)
-	a.name = metricsName(key)
+	a.name = key
+	a.labels = nil
 	a.Add(1)
 	return
 }
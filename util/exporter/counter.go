@@ -17,13 +17,12 @@ package exporter
 import (
 	"sync"
 
-	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	CounterGroup sync.Map
-	CounterPool  = &sync.Pool{New: func() interface{} {
+	CounterPool = &sync.Pool{New: func() interface{} {
 		return new(Counter)
 	}}
 	CounterCh chan *Counter
@@ -48,7 +47,8 @@ func NewCounter(name string) (c *Counter) {
 		return
 	}
 	c = CounterPool.Get().(*Counter)
-	c.name = metricsName(name)
+	c.name = name
+	c.labels = nil
 	return
 }
 
@@ -76,19 +76,6 @@ func (c *Counter) AddWithLabels(val int64, labels map[string]string) {
 }
 
 func (c *Counter) Metric() prometheus.Counter {
-	metric := prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Name:        c.name,
-			ConstLabels: c.labels,
-		})
-	key := c.Key()
-	actualMetric, load := CounterGroup.LoadOrStore(key, metric)
-	if !load {
-		err := prometheus.Register(actualMetric.(prometheus.Collector))
-		if err == nil {
-			log.LogInfo("register metric ", c.name)
-		}
-	}
-
-	return actualMetric.(prometheus.Counter)
+	names, values := c.labelPairs()
+	return metrics.LegacyCounterVec(c.name, "", names...).WithLabelValues(values...)
 }
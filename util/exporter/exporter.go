@@ -22,6 +22,7 @@ import (
 
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -35,17 +36,12 @@ const (
 )
 
 var (
-	namespace         string
 	clustername       string
 	modulename        string
 	enabledPrometheus = false
 	replacer          = strings.NewReplacer("-", "_", ".", "_", " ", "_", ",", "_")
 )
 
-func metricsName(name string) string {
-	return replacer.Replace(fmt.Sprintf("%s_%s", namespace, name))
-}
-
 // Init initializes the exporter.
 func Init(role string, cfg *config.Config) {
 	modulename = role
@@ -58,7 +54,7 @@ func Init(role string, cfg *config.Config) {
 	http.Handle(PromHandlerPattern, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
 		Timeout: 5 * time.Second,
 	}))
-	namespace = AppName + "_" + role
+	metrics.Init(role)
 	addr := fmt.Sprintf(":%d", port)
 	go func() {
 		err := http.ListenAndServe(addr, nil)
@@ -77,6 +73,7 @@ func Init(role string, cfg *config.Config) {
 
 func RegistConsul(cluster string, role string, cfg *config.Config) {
 	clustername = replacer.Replace(cluster)
+	metrics.SetCluster(cluster)
 	consulAddr := cfg.GetString(ConfigKeyConsulAddr)
 	port := cfg.GetInt64(ConfigKeyExporterPort)
 	if len(consulAddr) > 0 {
@@ -15,10 +15,10 @@
 package exporter
 
 import (
-	"fmt"
+	"sort"
 	"sync"
 
-	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -27,8 +27,7 @@ var (
 		return new(Gauge)
 	}}
 
-	GaugeGroup sync.Map
-	GaugeCh    chan *Gauge
+	GaugeCh chan *Gauge
 )
 
 func collectGauge() {
@@ -53,35 +52,32 @@ func NewGauge(name string) (g *Gauge) {
 		return
 	}
 	g = GaugePool.Get().(*Gauge)
-	g.name = metricsName(name)
+	g.name = name
+	g.labels = nil
 	return
 }
 
-func (c *Gauge) Key() (key string) {
-	str := c.name
-	if len(c.labels) > 0 {
-		str = fmt.Sprintf("%s-%s", c.name, stringMapToString(c.labels))
+// labelPairs returns the label names sorted and their values, the cluster label
+// is left out as it is added by the metrics registry.
+func (c *Gauge) labelPairs() (names, values []string) {
+	names = make([]string, 0, len(c.labels))
+	for name := range c.labels {
+		if name != metrics.LabelCluster {
+			names = append(names, name)
+		}
 	}
-
-	return stringMD5(str)
+	sort.Strings(names)
+	values = make([]string, 0, len(names)+1)
+	values = append(values, metrics.Cluster())
+	for _, name := range names {
+		values = append(values, c.labels[name])
+	}
+	return
 }
 
 func (c *Gauge) Metric() prometheus.Gauge {
-	metric := prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name:        c.name,
-			ConstLabels: c.labels,
-		})
-	key := c.Key()
-	actualMetric, load := GaugeGroup.LoadOrStore(key, metric)
-	if !load {
-		err := prometheus.Register(actualMetric.(prometheus.Collector))
-		if err == nil {
-			log.LogInfo("register metric ", c.name)
-		}
-	}
-
-	return actualMetric.(prometheus.Gauge)
+	names, values := c.labelPairs()
+	return metrics.LegacyGaugeVec(c.name, "", names...).WithLabelValues(values...)
 }
 
 func (g *Gauge) Set(val int64) {
@@ -19,6 +19,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/chubaofs/chubaofs/util/metrics"
 	"github.com/chubaofs/chubaofs/util/ump"
 )
 
@@ -45,9 +46,12 @@ type TimePoint struct {
 }
 
 type TimePointCount struct {
-	tp  *TimePoint
-	cnt *Counter
-	to  *ump.TpObject
+	name   string
+	volume string
+	start  time.Time
+	tp     *TimePoint
+	cnt    *Counter
+	to     *ump.TpObject
 }
 
 func NewTP(name string) (tp *TimePoint) {
@@ -55,7 +59,8 @@ func NewTP(name string) (tp *TimePoint) {
 		return
 	}
 	tp = TPPool.Get().(*TimePoint)
-	tp.name = metricsName(name)
+	tp.name = name
+	tp.labels = nil
 	tp.startTime = time.Now()
 	return
 }
@@ -71,16 +76,32 @@ func (tp *TimePoint) Set() {
 
 func NewTPCnt(name string) (tpc *TimePointCount) {
 	tpc = new(TimePointCount)
+	tpc.name = name
+	tpc.start = time.Now()
 	tpc.to = ump.BeforeTP(fmt.Sprintf("%v_%v_%v", clustername, modulename, name))
 	tpc.tp = NewTP(name)
 	tpc.cnt = NewCounter(fmt.Sprintf("%s_count", name))
 	return
 }
 
+// NewVolumeTPCnt is NewTPCnt of an operation on the volume, the metrics of which are
+// labeled with the volume.
+func NewVolumeTPCnt(name, volume string) (tpc *TimePointCount) {
+	tpc = NewTPCnt(name)
+	tpc.volume = volume
+	if tpc.tp != nil {
+		labels := map[string]string{metrics.LabelVolume: volume}
+		tpc.tp.labels = labels
+		tpc.cnt.labels = labels
+	}
+	return
+}
+
 func (tpc *TimePointCount) Set(err error) {
 	ump.AfterTP(tpc.to, err)
 	tpc.tp.Set()
 	tpc.cnt.Add(1)
+	metrics.ObserveOp(tpc.name, tpc.volume, tpc.start, err)
 }
 
 func (tp *TimePoint) publish() {
@@ -0,0 +1,220 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fault provides the fault injection hooks used by the chaos tests of the repair
+// and failover paths.
+//
+// The hooks are compiled in only with the faultinject build tag,
+//
+//	go build -tags faultinject
+//
+// otherwise Enabled is false and the hooks are removed by the compiler. A fault is set on a
+// point, the name of the place where a hook is called:
+//
+//	meta/<op>       a packet handled by the metanode, e.g. meta/OpMetaCreateInode
+//	data/<op>       a packet handled by the datanode, e.g. data/OpWrite
+//	disk/write      a write to an extent of the datanode
+//	raft/send       a raft message sent to any peer
+//	raft/send/<id>  a raft message sent to the peer with the given node id
+//
+// and is controlled at runtime by the endpoints registered by RegisterHTTP:
+//
+//	GET /fault/set?point=data/OpWrite&action=error&probability=0.5&times=10
+//	GET /fault/set?point=raft/send/3&action=drop
+//	GET /fault/set?point=meta/OpMetaLookup&action=delay&delay=2s
+//	GET /fault/clear?point=data/OpWrite
+//	GET /fault/list
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Action is what a fault does when it is hit.
+type Action string
+
+// Fault actions
+const (
+	// ActionDelay sleeps for the delay of the fault, then the operation goes on.
+	ActionDelay Action = "delay"
+	// ActionDrop drops the request or the message without any reply.
+	ActionDrop Action = "drop"
+	// ActionError fails the operation.
+	ActionError Action = "error"
+)
+
+// Fault points
+const (
+	PointDiskWrite = "disk/write"
+	PointRaftSend  = "raft/send"
+)
+
+// Errors returned by Inject.
+var (
+	ErrDropped  = errors.New("fault injection: dropped")
+	ErrInjected = errors.New("fault injection: injected error")
+)
+
+// MetaOp returns the point of the packets of the given op handled by the metanode.
+func MetaOp(op string) string {
+	return "meta/" + op
+}
+
+// DataOp returns the point of the packets of the given op handled by the datanode.
+func DataOp(op string) string {
+	return "data/" + op
+}
+
+// RaftSendTo returns the point of the raft messages sent to the given peer.
+func RaftSendTo(nodeID uint64) string {
+	return fmt.Sprintf("%v/%v", PointRaftSend, nodeID)
+}
+
+// Fault is a fault set on a point.
+type Fault struct {
+	Point  string        `json:"point"`
+	Action Action        `json:"action"`
+	Delay  time.Duration `json:"delay,omitempty"`
+	// Probability is the chance the fault is hit by each call of the hook, in (0, 1].
+	Probability float64 `json:"probability"`
+	// Times is the number of the hits left before the fault is removed, 0 for unlimited.
+	Times int64  `json:"times"`
+	Hits  uint64 `json:"hits"`
+}
+
+func (f *Fault) validate() error {
+	if f.Point == "" {
+		return errors.New("point is required")
+	}
+	switch f.Action {
+	case ActionDelay:
+		if f.Delay <= 0 {
+			return errors.New("delay is required")
+		}
+	case ActionDrop, ActionError:
+	default:
+		return fmt.Errorf("unknown action %v", f.Action)
+	}
+	if f.Probability == 0 {
+		f.Probability = 1
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("invalid probability %v", f.Probability)
+	}
+	if f.Times < 0 {
+		return fmt.Errorf("invalid times %v", f.Times)
+	}
+	return nil
+}
+
+// Injector holds the faults of the points.
+type Injector struct {
+	mu     sync.Mutex
+	faults map[string]*Fault
+}
+
+// NewInjector returns a new Injector.
+func NewInjector() *Injector {
+	return &Injector{faults: make(map[string]*Fault)}
+}
+
+// Set sets a fault on its point, the fault already set on the point is replaced.
+func (i *Injector) Set(f Fault) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	f.Hits = 0
+	i.mu.Lock()
+	i.faults[f.Point] = &f
+	i.mu.Unlock()
+	return nil
+}
+
+// Clear removes the fault on the given point, or all the faults if the point is empty.
+func (i *Injector) Clear(point string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if point == "" {
+		i.faults = make(map[string]*Fault)
+		return
+	}
+	delete(i.faults, point)
+}
+
+// List returns the faults sorted by point.
+func (i *Injector) List() []Fault {
+	i.mu.Lock()
+	faults := make([]Fault, 0, len(i.faults))
+	for _, f := range i.faults {
+		faults = append(faults, *f)
+	}
+	i.mu.Unlock()
+	sort.Slice(faults, func(a, b int) bool { return faults[a].Point < faults[b].Point })
+	return faults
+}
+
+// Inject applies the fault of the first given point which has one. It sleeps for a delay
+// fault and returns nil, or returns ErrDropped or ErrInjected for a drop or an error fault.
+func (i *Injector) Inject(points ...string) error {
+	i.mu.Lock()
+	var f *Fault
+	for _, point := range points {
+		if f = i.faults[point]; f != nil {
+			break
+		}
+	}
+	if f == nil || (f.Probability < 1 && rand.Float64() >= f.Probability) {
+		i.mu.Unlock()
+		return nil
+	}
+	f.Hits++
+	if f.Times > 0 {
+		if f.Times--; f.Times == 0 {
+			delete(i.faults, f.Point)
+		}
+	}
+	action, delay := f.Action, f.Delay
+	i.mu.Unlock()
+
+	switch action {
+	case ActionDelay:
+		time.Sleep(delay)
+	case ActionDrop:
+		return ErrDropped
+	case ActionError:
+		return ErrInjected
+	}
+	return nil
+}
+
+var defaultInjector = NewInjector()
+
+// Default returns the injector of the process.
+func Default() *Injector {
+	return defaultInjector
+}
+
+// Inject applies the fault of the first given point with the injector of the process.
+// It always returns nil if the hooks are not compiled in.
+func Inject(points ...string) error {
+	if !Enabled {
+		return nil
+	}
+	return defaultInjector.Inject(points...)
+}
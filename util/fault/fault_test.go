@@ -0,0 +1,115 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInject(t *testing.T) {
+	i := NewInjector()
+	if err := i.Inject(DataOp("OpWrite")); err != nil {
+		t.Fatalf("inject without fault: %v", err)
+	}
+	if err := i.Set(Fault{Point: DataOp("OpWrite"), Action: ActionError, Times: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Set(Fault{Point: PointRaftSend, Action: ActionDrop}); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := i.Inject(DataOp("OpWrite")); err != ErrInjected {
+			t.Fatalf("hit %v: expected ErrInjected, got %v", n, err)
+		}
+	}
+	if err := i.Inject(DataOp("OpWrite")); err != nil {
+		t.Fatalf("fault should be removed after its times, got %v", err)
+	}
+	if err := i.Inject(RaftSendTo(3), PointRaftSend); err != ErrDropped {
+		t.Fatalf("expected ErrDropped, got %v", err)
+	}
+
+	if err := i.Set(Fault{Point: RaftSendTo(3), Action: ActionDelay, Delay: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := i.Inject(RaftSendTo(3), PointRaftSend); err != nil {
+		t.Fatalf("expected the delay of the first point, got %v", err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatalf("delay not applied")
+	}
+
+	faults := i.List()
+	if len(faults) != 2 || faults[0].Point != PointRaftSend || faults[0].Hits != 1 || faults[1].Hits != 1 {
+		t.Fatalf("unexpected faults %+v", faults)
+	}
+	i.Clear("")
+	if len(i.List()) != 0 {
+		t.Fatalf("faults not cleared")
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	i := NewInjector()
+	for _, f := range []Fault{
+		{Action: ActionDrop},
+		{Point: PointDiskWrite, Action: "crash"},
+		{Point: PointDiskWrite, Action: ActionDelay},
+		{Point: PointDiskWrite, Action: ActionError, Probability: 2},
+		{Point: PointDiskWrite, Action: ActionError, Times: -1},
+	} {
+		if err := i.Set(f); err == nil {
+			t.Fatalf("fault %+v should be invalid", f)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	i := NewInjector()
+	w := httptest.NewRecorder()
+	i.SetHandler(w, httptest.NewRequest(http.MethodGet, SetPath+"?point=disk/write&action=error&probability=0.5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set: %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	i.SetHandler(w, httptest.NewRequest(http.MethodGet, SetPath+"?point=disk/write&action=delay&delay=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("set invalid delay: %v", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	i.ListHandler(w, httptest.NewRequest(http.MethodGet, ListPath, nil))
+	reply := &struct {
+		Code int     `json:"code"`
+		Data []Fault `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Code != http.StatusOK || len(reply.Data) != 1 || reply.Data[0].Probability != 0.5 {
+		t.Fatalf("unexpected list %+v", reply)
+	}
+
+	w = httptest.NewRecorder()
+	i.ClearHandler(w, httptest.NewRequest(http.MethodGet, ClearPath+"?point=disk/write", nil))
+	if w.Code != http.StatusOK || len(i.List()) != 0 {
+		t.Fatalf("clear: %v %v", w.Code, i.List())
+	}
+}
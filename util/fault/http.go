@@ -0,0 +1,109 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fault
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// Admin API paths
+const (
+	SetPath   = "/fault/set"
+	ClearPath = "/fault/clear"
+	ListPath  = "/fault/list"
+)
+
+var registerDefault sync.Once
+
+// RegisterHTTP registers the admin endpoints of the process injector in http.DefaultServeMux
+// if the hooks are compiled in. It can be called more than once.
+func RegisterHTTP() {
+	if !Enabled {
+		return
+	}
+	registerDefault.Do(func() {
+		http.HandleFunc(SetPath, defaultInjector.SetHandler)
+		http.HandleFunc(ClearPath, defaultInjector.ClearHandler)
+		http.HandleFunc(ListPath, defaultInjector.ListHandler)
+	})
+}
+
+// SetHandler sets a fault from the parameters point, action, delay, probability and times.
+func (i *Injector) SetHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sendReply(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	f := Fault{
+		Point:  r.FormValue("point"),
+		Action: Action(r.FormValue("action")),
+	}
+	var err error
+	if value := r.FormValue("delay"); value != "" {
+		if f.Delay, err = time.ParseDuration(value); err != nil {
+			sendReply(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+	if value := r.FormValue("probability"); value != "" {
+		if f.Probability, err = strconv.ParseFloat(value, 64); err != nil {
+			sendReply(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+	if value := r.FormValue("times"); value != "" {
+		if f.Times, err = strconv.ParseInt(value, 10, 64); err != nil {
+			sendReply(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+	if err = i.Set(f); err != nil {
+		sendReply(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	sendReply(w, http.StatusOK, "success", nil)
+}
+
+// ClearHandler removes the fault on the parameter point, or all the faults if it is empty.
+func (i *Injector) ClearHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sendReply(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	i.Clear(r.FormValue("point"))
+	sendReply(w, http.StatusOK, "success", nil)
+}
+
+// ListHandler returns the faults.
+func (i *Injector) ListHandler(w http.ResponseWriter, r *http.Request) {
+	sendReply(w, http.StatusOK, "success", i.List())
+}
+
+func sendReply(w http.ResponseWriter, code int, msg string, data interface{}) {
+	body, err := json.Marshal(&proto.HTTPReply{Code: int32(code), Msg: msg, Data: data})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
@@ -0,0 +1,304 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package grpc serves the gRPC services over HTTP/2 by golang.org/x/net/http2, so that the clients
+// in other languages and the service meshes reach the nodes without the binary packet format.
+//
+// The messages are the raw bytes of the protobuf messages, which are encoded and decoded by
+// Encoder and Decode. Neither the compression of the messages nor the reflection is supported.
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const (
+	// MaxMessageSize is the size limit of a message received.
+	MaxMessageSize = 16 * util.MB
+
+	messagePrefixSize = 5
+	contentType       = "application/grpc"
+)
+
+// Code is the status code of a call.
+type Code uint32
+
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+// Status is the error of a call, which is replied by the trailers.
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("grpc: code(%v) message(%v)", s.Code, s.Message)
+}
+
+// Errorf returns the status of the code and the message.
+func Errorf(code Code, format string, a ...interface{}) error {
+	return &Status{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func statusOf(err error) *Status {
+	switch e := err.(type) {
+	case nil:
+		return &Status{Code: OK}
+	case *Status:
+		return e
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		return &Status{Code: DeadlineExceeded, Message: err.Error()}
+	case context.Canceled:
+		return &Status{Code: Canceled, Message: err.Error()}
+	}
+	return &Status{Code: Unknown, Message: err.Error()}
+}
+
+// encodeMessage percent-encodes the status message as the header grpc-message.
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseTimeout parses the header grpc-timeout, e.g. 100m for 100 milliseconds.
+func parseTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	unit, ok := units[s[len(s)-1]]
+	return time.Duration(n) * unit, ok
+}
+
+// Stream is a call, whose messages are received and sent by the handler.
+type Stream struct {
+	ctx        context.Context
+	r          *http.Request
+	w          http.ResponseWriter
+	headerSent bool
+}
+
+// Context returns the context of the call, which is done once the call is canceled or timed out.
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Recv receives the next message of the request, io.EOF is returned after the last one.
+func (s *Stream) Recv() (msg []byte, err error) {
+	var prefix [messagePrefixSize]byte
+	if _, err = io.ReadFull(s.r.Body, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = Errorf(Internal, "truncated message prefix")
+		}
+		return
+	}
+	if prefix[0] != 0 {
+		return nil, Errorf(Unimplemented, "compressed message unsupported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > MaxMessageSize {
+		return nil, Errorf(ResourceExhausted, "message size %v exceeds %v", size, MaxMessageSize)
+	}
+	msg = make([]byte, size)
+	if _, err = io.ReadFull(s.r.Body, msg); err != nil {
+		return nil, Errorf(Internal, "truncated message: %v", err)
+	}
+	return
+}
+
+// Send sends a message of the response.
+func (s *Stream) Send(msg []byte) (err error) {
+	if !s.headerSent {
+		s.w.Header().Set("Content-Type", contentType)
+		s.w.WriteHeader(http.StatusOK)
+		s.headerSent = true
+	}
+	var prefix [messagePrefixSize]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err = s.w.Write(prefix[:]); err != nil {
+		return
+	}
+	if _, err = s.w.Write(msg); err != nil {
+		return
+	}
+	s.w.(http.Flusher).Flush()
+	return
+}
+
+// Handler serves a call, whose status is the error returned.
+type Handler func(stream *Stream) error
+
+// UnaryHandler serves a call of one request message and one response message.
+type UnaryHandler func(ctx context.Context, req []byte) (resp []byte, err error)
+
+// Unary returns the handler of the unary call.
+func Unary(h UnaryHandler) Handler {
+	return func(stream *Stream) (err error) {
+		var req, resp []byte
+		if req, err = stream.Recv(); err != nil {
+			if err == io.EOF {
+				err = Errorf(InvalidArgument, "no request message")
+			}
+			return
+		}
+		if resp, err = h(stream.Context(), req); err != nil {
+			return
+		}
+		return stream.Send(resp)
+	}
+}
+
+// Server dispatches the calls to the handlers of the methods.
+type Server struct {
+	handlers map[string]Handler // by the path /package.Service/Method
+	server   *http.Server
+}
+
+func NewServer() *Server {
+	s := &Server{handlers: make(map[string]Handler)}
+	s.server = &http.Server{Handler: s}
+	return s
+}
+
+// Handle registers the handler of the method of the service, e.g. chubaofs.DataNode and Read.
+func (s *Server) Handle(service, method string, h Handler) {
+	s.handlers["/"+service+"/"+method] = h
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires POST over HTTP/2", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), contentType) {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	ctx := r.Context()
+	if timeout, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stream := &Stream{ctx: ctx, r: r, w: w}
+	var err error
+	if h, ok := s.handlers[r.URL.Path]; ok {
+		err = h(stream)
+	} else {
+		err = Errorf(Unimplemented, "unknown method %v", r.URL.Path)
+	}
+
+	status := statusOf(err)
+	if status.Code != OK {
+		log.LogWarnf("grpc: method(%v) remote(%v) code(%v) message(%v)", r.URL.Path, r.RemoteAddr, status.Code, status.Message)
+	}
+	header := w.Header()
+	if !stream.headerSent {
+		// trailers-only response
+		header.Set("Content-Type", contentType)
+		header.Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+		if status.Message != "" {
+			header.Set("Grpc-Message", encodeMessage(status.Message))
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	header.Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		header.Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(status.Message))
+	}
+}
+
+// Serve serves the calls on the listener, over TLS if mutual TLS is enabled, and otherwise over
+// HTTP/2 without TLS, i.e. h2c with prior knowledge.
+func (s *Server) Serve(ln net.Listener) error {
+	if mtls.Enabled() {
+		config := mtls.ServerConfig()
+		config.NextProtos = []string{http2.NextProtoTLS}
+		if err := http2.ConfigureServer(s.server, new(http2.Server)); err != nil {
+			return err
+		}
+		ln = tls.NewListener(ln, config)
+	} else {
+		s.server.Handler = h2c.NewHandler(s, new(http2.Server))
+	}
+	return s.server.Serve(ln)
+}
+
+// Start listens on the port and serves the calls in the background.
+func (s *Server) Start(port string) (err error) {
+	var ln net.Listener
+	if ln, err = net.Listen("tcp", ":"+port); err != nil {
+		return
+	}
+	go func() {
+		if err := s.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.LogErrorf("grpc: serve on port(%v) err(%v)", port, err)
+		}
+	}()
+	log.LogInfof("grpc: serve on port(%v)", port)
+	return
+}
+
+// Stop closes the listener and the connections.
+func (s *Server) Stop() {
+	s.server.Close()
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func testServer(t *testing.T) (*Server, string) {
+	s := NewServer()
+	s.Handle("test.Echo", "Unary", Unary(func(ctx context.Context, req []byte) ([]byte, error) {
+		if len(req) == 0 {
+			return nil, Errorf(InvalidArgument, "empty %v%%", 100)
+		}
+		return req, nil
+	}))
+	// sends the fields of the request one by one
+	s.Handle("test.Echo", "Split", func(stream *Stream) error {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		return Decode(req, func(f *Field) error {
+			e := &Encoder{}
+			e.Bytes(f.Number, f.Bytes)
+			return stream.Send(e.Data())
+		})
+	})
+	// joins the messages of the request
+	s.Handle("test.Echo", "Join", func(stream *Stream) error {
+		var joined []byte
+		for {
+			msg, err := stream.Recv()
+			if err == io.EOF {
+				return stream.Send(joined)
+			}
+			if err != nil {
+				return err
+			}
+			joined = append(joined, msg...)
+		}
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	return s, "http://" + ln.Addr().String()
+}
+
+func call(t *testing.T, url string, msgs ...[]byte) (resp [][]byte, code, message string) {
+	var body bytes.Buffer
+	for _, msg := range msgs {
+		var prefix [messagePrefixSize]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+		body.Write(prefix[:])
+		body.Write(msg)
+	}
+	// h2c with prior knowledge
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+	req, _ := http.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", "application/grpc")
+	r, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(data) >= messagePrefixSize {
+		size := int(binary.BigEndian.Uint32(data[1:messagePrefixSize]))
+		resp = append(resp, data[messagePrefixSize:messagePrefixSize+size])
+		data = data[messagePrefixSize+size:]
+	}
+	if code = r.Trailer.Get("Grpc-Status"); code == "" {
+		code = r.Header.Get("Grpc-Status")
+		message = r.Header.Get("Grpc-Message")
+	} else {
+		message = r.Trailer.Get("Grpc-Message")
+	}
+	return
+}
+
+func TestServer(t *testing.T) {
+	s, addr := testServer(t)
+	defer s.Stop()
+
+	resp, code, _ := call(t, addr+"/test.Echo/Unary", []byte("hello"))
+	if code != "0" || len(resp) != 1 || string(resp[0]) != "hello" {
+		t.Fatalf("unary: code(%v) resp(%q)", code, resp)
+	}
+	if resp, code, message := call(t, addr+"/test.Echo/Unary", []byte{}); code != "3" || message != "empty 100%25" || len(resp) != 0 {
+		t.Fatalf("unary error: code(%v) message(%v) resp(%q)", code, message, resp)
+	}
+	if _, code, _ = call(t, addr+"/test.Echo/Unknown", []byte("hello")); code != "12" {
+		t.Fatalf("unknown method: code(%v)", code)
+	}
+
+	e := &Encoder{}
+	e.String(1, "a")
+	e.String(2, "bc")
+	if resp, code, _ = call(t, addr+"/test.Echo/Split", e.Data()); code != "0" || len(resp) != 2 {
+		t.Fatalf("server stream: code(%v) resp(%q)", code, resp)
+	}
+	if resp, code, _ = call(t, addr+"/test.Echo/Join", []byte("a"), []byte("bc"), []byte("d")); code != "0" || len(resp) != 1 || string(resp[0]) != "abcd" {
+		t.Fatalf("client stream: code(%v) resp(%q)", code, resp)
+	}
+}
+
+func TestWire(t *testing.T) {
+	e := &Encoder{}
+	e.Uint64(1, 300)
+	e.Int64(2, -1)
+	e.Uint64(3, 0)
+	e.Bool(4, true)
+	e.Bytes(5, []byte("data"))
+	e.String(6, "a")
+	e.String(6, "b")
+	var strs []string
+	var fields []int
+	err := Decode(e.Data(), func(f *Field) error {
+		fields = append(fields, f.Number)
+		switch f.Number {
+		case 1:
+			if f.Varint != 300 {
+				t.Errorf("field 1: %v", f.Varint)
+			}
+		case 2:
+			if int64(f.Varint) != -1 {
+				t.Errorf("field 2: %v", f.Varint)
+			}
+		case 5:
+			if string(f.Bytes) != "data" {
+				t.Errorf("field 5: %q", f.Bytes)
+			}
+		case 6:
+			strs = append(strs, string(f.Bytes))
+		}
+		return nil
+	})
+	if err != nil || len(fields) != 6 || len(strs) != 2 || strs[1] != "b" {
+		t.Fatalf("err(%v) fields(%v) strs(%v)", err, fields, strs)
+	}
+	// the length of field 5 exceeds the message
+	if err = Decode([]byte{0x2a, 0x05, 'a'}, func(*Field) error { return nil }); err != ErrInvalidMessage {
+		t.Fatalf("truncated message: err(%v)", err)
+	}
+}
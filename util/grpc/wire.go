@@ -0,0 +1,144 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// The wire types of the protobuf fields.
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+var ErrInvalidMessage = errors.New("invalid protobuf message")
+
+// Encoder appends the fields of a protobuf message. The fields of the default values are omitted
+// as proto3 does.
+type Encoder struct {
+	buf []byte
+}
+
+func (e *Encoder) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	e.buf = append(e.buf, b[:n]...)
+}
+
+func (e *Encoder) key(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *Encoder) Uint64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.key(field, WireVarint)
+	e.varint(v)
+}
+
+// Int64 appends the field of int64, which is not zigzag encoded as sint64.
+func (e *Encoder) Int64(field int, v int64) {
+	e.Uint64(field, uint64(v))
+}
+
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.Uint64(field, 1)
+	}
+}
+
+// Bytes appends the field of bytes, or an embedded message.
+func (e *Encoder) Bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	e.key(field, WireBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *Encoder) String(field int, v string) {
+	if len(v) == 0 {
+		return
+	}
+	e.key(field, WireBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// Data returns the message encoded.
+func (e *Encoder) Data() []byte {
+	return e.buf
+}
+
+// Field is a field decoded, the value of a fixed one is in Varint.
+type Field struct {
+	Number   int
+	WireType int
+	Varint   uint64
+	Bytes    []byte // refers to the message
+}
+
+// Decode calls the function for the fields of the message in order, including each of the
+// repeated ones.
+func Decode(data []byte, fn func(f *Field) error) (err error) {
+	var f Field
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrInvalidMessage
+		}
+		data = data[n:]
+		f = Field{Number: int(key >> 3), WireType: int(key & 7)}
+		if f.Number == 0 {
+			return ErrInvalidMessage
+		}
+		switch f.WireType {
+		case WireVarint:
+			if f.Varint, n = binary.Uvarint(data); n <= 0 {
+				return ErrInvalidMessage
+			}
+			data = data[n:]
+		case WireFixed64:
+			if len(data) < 8 {
+				return ErrInvalidMessage
+			}
+			f.Varint, data = binary.LittleEndian.Uint64(data), data[8:]
+		case WireFixed32:
+			if len(data) < 4 {
+				return ErrInvalidMessage
+			}
+			f.Varint, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case WireBytes:
+			var size uint64
+			if size, n = binary.Uvarint(data); n <= 0 || size > uint64(len(data)-n) {
+				return ErrInvalidMessage
+			}
+			f.Bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			// the groups are deprecated
+			return ErrInvalidMessage
+		}
+		if err = fn(&f); err != nil {
+			return
+		}
+	}
+	return
+}
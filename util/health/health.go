@@ -0,0 +1,149 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package health provides the liveness and readiness endpoints shared by all the daemons.
+//
+// GET /healthz returns 200 as long as the process is able to serve HTTP requests.
+// GET /readyz runs the readiness checks registered by the daemon, and returns 200 if
+// all of them pass or 503 otherwise. Both endpoints reply with a JSON body like
+//
+//	{"status":"ok","checks":{"raft":"ok","disk":"ok"}}
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc returns nil if the daemon is ready from the view of the check.
+type CheckFunc func() error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker holds the readiness checks of a daemon.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []check
+}
+
+// Result is the body of the health responses.
+type Result struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// NewChecker returns a new Checker.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddCheck adds a readiness check, a check with the same name is replaced. The checks are
+// copied on write, since Ready runs the ones it got without the lock.
+func (c *Checker) AddCheck(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	checks := make([]check, 0, len(c.checks)+1)
+	for _, ck := range c.checks {
+		if ck.name != name {
+			checks = append(checks, ck)
+		}
+	}
+	checks = append(checks, check{name: name, fn: fn})
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+	c.checks = checks
+}
+
+// Ready runs all the checks.
+func (c *Checker) Ready() (result *Result, ready bool) {
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+	ready = true
+	result = &Result{Status: StatusOK, Checks: make(map[string]string, len(checks))}
+	for _, ck := range checks {
+		if err := ck.fn(); err != nil {
+			result.Checks[ck.name] = err.Error()
+			ready = false
+			continue
+		}
+		result.Checks[ck.name] = StatusOK
+	}
+	if !ready {
+		result.Status = StatusFail
+	}
+	return
+}
+
+// LivenessHandler handles the liveness probes.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	reply(w, http.StatusOK, &Result{Status: StatusOK})
+}
+
+// ReadinessHandler handles the readiness probes.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	result, ready := c.Ready()
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	reply(w, code, result)
+}
+
+func reply(w http.ResponseWriter, code int, result *Result) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+var (
+	defaultChecker  = NewChecker()
+	registerDefault sync.Once
+)
+
+// AddCheck adds a readiness check to the checker of the process.
+func AddCheck(name string, fn CheckFunc) {
+	defaultChecker.AddCheck(name, fn)
+}
+
+// Default returns the checker of the process.
+func Default() *Checker {
+	return defaultChecker
+}
+
+// RegisterHTTP registers the endpoints of the process checker in http.DefaultServeMux.
+// It can be called more than once.
+func RegisterHTTP() {
+	registerDefault.Do(func() {
+		http.HandleFunc(LivenessPath, defaultChecker.LivenessHandler)
+		http.HandleFunc(ReadinessPath, defaultChecker.ReadinessHandler)
+	})
+}
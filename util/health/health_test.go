@@ -0,0 +1,52 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	c := NewChecker()
+	var diskErr error
+	c.AddCheck("raft", func() error { return nil })
+	c.AddCheck("disk", func() error { return diskErr })
+
+	get := func(handler http.HandlerFunc) (int, *Result) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+		result := new(Result)
+		if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+			t.Fatalf("invalid body %v: %v", w.Body.String(), err)
+		}
+		return w.Code, result
+	}
+
+	if code, result := get(c.ReadinessHandler); code != http.StatusOK || result.Status != StatusOK || len(result.Checks) != 2 {
+		t.Fatalf("unexpected readiness code(%v) result(%v)", code, result)
+	}
+	diskErr = errors.New("no disk is available")
+	code, result := get(c.ReadinessHandler)
+	if code != http.StatusServiceUnavailable || result.Status != StatusFail || result.Checks["disk"] != diskErr.Error() || result.Checks["raft"] != StatusOK {
+		t.Fatalf("unexpected readiness code(%v) result(%v)", code, result)
+	}
+	if code, _ := get(c.LivenessHandler); code != http.StatusOK {
+		t.Fatalf("unexpected liveness code(%v)", code)
+	}
+}
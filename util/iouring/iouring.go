@@ -0,0 +1,27 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iouring implements the positional reads and writes of the files by io_uring(7), which
+// requires Linux 5.6 or later. The requests of the goroutines are submitted to a ring shared by
+// them, and completed by a reaper goroutine, so that no thread is blocked per request.
+package iouring
+
+import "errors"
+
+const DefaultEntries = 256
+
+var (
+	ErrClosed      = errors.New("io_uring closed")
+	ErrUnsupported = errors.New("io_uring unsupported")
+)
@@ -0,0 +1,311 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iouring
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysIOURingSetup = 425
+	sysIOURingEnter = 426
+
+	opNop   = 0
+	opRead  = 22
+	opWrite = 23
+
+	enterGetEvents = 1
+
+	offSQRing = 0
+	offCQRing = 0x8000000
+	offSQEs   = 0x10000000
+
+	maxRingEntries = 1 << 15
+)
+
+type sqRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type cqRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        sqRingOffsets
+	cqOff        cqRingOffsets
+}
+
+// submissionEntry is struct io_uring_sqe.
+type submissionEntry struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	pad         [2]uint64
+}
+
+// completionEntry is struct io_uring_cqe.
+type completionEntry struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// Ring is an io_uring instance shared by the goroutines.
+type Ring struct {
+	fd      int
+	sqRing  []byte
+	cqRing  []byte
+	sqeMem  []byte
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []submissionEntry
+	cqHead  *uint32
+	cqTail  *uint32
+	cqMask  uint32
+	cqes    []completionEntry
+
+	submitLock  sync.Mutex
+	pendingLock sync.Mutex
+	pending     map[uint64]chan int32
+	nextID      uint64
+	closed      bool
+	inflight    chan struct{} // bounds the requests in flight by the size of the completion queue
+	doneC       chan struct{}
+}
+
+// New creates the ring of the entries, an error is returned if io_uring is not supported.
+func New(entries uint32) (r *Ring, err error) {
+	if entries == 0 {
+		entries = DefaultEntries
+	}
+	var p ringParams
+	fd, _, errno := syscall.Syscall(sysIOURingSetup, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	r = &Ring{fd: int(fd), pending: make(map[uint64]chan int32), doneC: make(chan struct{})}
+	defer func() {
+		if err != nil {
+			r.unmap()
+			syscall.Close(r.fd)
+		}
+	}()
+	if p.sqEntries > maxRingEntries || p.cqEntries > maxRingEntries {
+		return nil, syscall.EINVAL
+	}
+	if r.sqRing, err = r.mmap(offSQRing, int(p.sqOff.array+p.sqEntries*4)); err != nil {
+		return
+	}
+	if r.cqRing, err = r.mmap(offCQRing, int(p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(completionEntry{})))); err != nil {
+		return
+	}
+	if r.sqeMem, err = r.mmap(offSQEs, int(p.sqEntries*uint32(unsafe.Sizeof(submissionEntry{})))); err != nil {
+		return
+	}
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.sqArray = (*[maxRingEntries]uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.array]))[:p.sqEntries:p.sqEntries]
+	r.sqes = (*[maxRingEntries]submissionEntry)(unsafe.Pointer(&r.sqeMem[0]))[:p.sqEntries:p.sqEntries]
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.cqes = (*[maxRingEntries]completionEntry)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes]))[:p.cqEntries:p.cqEntries]
+	r.inflight = make(chan struct{}, p.cqEntries)
+	go r.reap()
+	return
+}
+
+func (r *Ring) mmap(offset int64, size int) ([]byte, error) {
+	return syscall.Mmap(r.fd, offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+}
+
+func (r *Ring) unmap() {
+	for _, mem := range [][]byte{r.sqRing, r.cqRing, r.sqeMem} {
+		if mem != nil {
+			syscall.Munmap(mem)
+		}
+	}
+}
+
+func (r *Ring) enter(toSubmit, minComplete, flags uint32) (err error) {
+	for {
+		_, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(r.fd), uintptr(toSubmit), uintptr(minComplete),
+			uintptr(flags), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
+
+// submit submits the request and waits for its result, the buffer is referred by the kernel until then.
+// The ring is closed by the request of closing, which is the last one submitted.
+func (r *Ring) submit(opcode uint8, fd int, b []byte, offset int64, closing bool) (res int32, err error) {
+	r.inflight <- struct{}{}
+	defer func() { <-r.inflight }()
+
+	resultC := make(chan int32, 1)
+	r.pendingLock.Lock()
+	if r.closed {
+		r.pendingLock.Unlock()
+		return 0, ErrClosed
+	}
+	r.closed = closing
+	r.nextID++
+	id := r.nextID
+	r.pending[id] = resultC
+	r.pendingLock.Unlock()
+
+	r.submitLock.Lock()
+	// the entries are consumed by the kernel once entered, so the queue is never full
+	tail := *r.sqTail
+	idx := tail & r.sqMask
+	r.sqes[idx] = submissionEntry{opcode: opcode, fd: int32(fd), off: uint64(offset), len: uint32(len(b)), userData: id}
+	if len(b) > 0 {
+		r.sqes[idx].addr = uint64(uintptr(unsafe.Pointer(&b[0])))
+	}
+	r.sqArray[idx] = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+	err = r.enter(1, 0, 0)
+	r.submitLock.Unlock()
+	if err != nil {
+		r.pendingLock.Lock()
+		delete(r.pending, id)
+		r.pendingLock.Unlock()
+		return
+	}
+
+	res = <-resultC
+	runtime.KeepAlive(b)
+	return
+}
+
+// reap dispatches the results of the completed requests to their submitters.
+func (r *Ring) reap() {
+	defer close(r.doneC)
+	for {
+		head := atomic.LoadUint32(r.cqHead)
+		tail := atomic.LoadUint32(r.cqTail)
+		if head == tail {
+			r.pendingLock.Lock()
+			done := r.closed && len(r.pending) == 0
+			r.pendingLock.Unlock()
+			if done {
+				return
+			}
+			_ = r.enter(0, 1, enterGetEvents)
+			continue
+		}
+		for ; head != tail; head++ {
+			cqe := r.cqes[head&r.cqMask]
+			r.pendingLock.Lock()
+			resultC := r.pending[cqe.userData]
+			delete(r.pending, cqe.userData)
+			r.pendingLock.Unlock()
+			if resultC != nil {
+				resultC <- cqe.res
+			}
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+}
+
+func (r *Ring) rw(opcode uint8, fd int, b []byte, offset int64) (n int, err error) {
+	for n < len(b) {
+		var res int32
+		if res, err = r.submit(opcode, fd, b[n:], offset+int64(n), false); err != nil {
+			return
+		}
+		if res < 0 {
+			return n, syscall.Errno(-res)
+		}
+		if res == 0 {
+			if opcode == opRead {
+				return n, io.EOF
+			}
+			return n, io.ErrShortWrite
+		}
+		n += int(res)
+	}
+	return
+}
+
+// ReadAt reads the file of the descriptor at the offset as pread(2), io.EOF is returned if the
+// data read is less than the buffer.
+func (r *Ring) ReadAt(fd int, b []byte, offset int64) (int, error) {
+	return r.rw(opRead, fd, b, offset)
+}
+
+// WriteAt writes the file of the descriptor at the offset as pwrite(2).
+func (r *Ring) WriteAt(fd int, b []byte, offset int64) (int, error) {
+	return r.rw(opWrite, fd, b, offset)
+}
+
+// Close waits for the requests in flight and releases the ring.
+func (r *Ring) Close() error {
+	// the reaper exits once the nop is completed
+	if _, err := r.submit(opNop, -1, nil, 0, true); err != nil {
+		if err == ErrClosed {
+			return nil
+		}
+		return err
+	}
+	<-r.doneC
+	r.unmap()
+	return syscall.Close(r.fd)
+}
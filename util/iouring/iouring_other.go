@@ -0,0 +1,37 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package iouring
+
+// Ring is unsupported out of Linux.
+type Ring struct{}
+
+func New(entries uint32) (*Ring, error) {
+	return nil, ErrUnsupported
+}
+
+func (r *Ring) ReadAt(fd int, b []byte, offset int64) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (r *Ring) WriteAt(fd int, b []byte, offset int64) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (r *Ring) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iouring
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestRingReadWrite(t *testing.T) {
+	r, err := New(8)
+	if err != nil {
+		t.Skipf("io_uring unavailable: %v", err)
+	}
+	f, err := ioutil.TempFile("", "iouring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// more requests than the entries of the ring
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{byte(i)}, 4096)
+			if n, err := r.WriteAt(int(f.Fd()), data, int64(i*4096)); err != nil || n != len(data) {
+				t.Errorf("write %v: n(%v) err(%v)", i, n, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 64; i++ {
+		data := make([]byte, 4096)
+		if n, err := r.ReadAt(int(f.Fd()), data, int64(i*4096)); err != nil || n != len(data) {
+			t.Fatalf("read %v: n(%v) err(%v)", i, n, err)
+		}
+		if !bytes.Equal(data, bytes.Repeat([]byte{byte(i)}, 4096)) {
+			t.Fatalf("read %v: unexpected data", i)
+		}
+	}
+	data := make([]byte, 8192)
+	if n, err := r.ReadAt(int(f.Fd()), data, 63*4096); err != io.EOF || n != 4096 {
+		t.Fatalf("read past the end: n(%v) err(%v)", n, err)
+	}
+
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.ReadAt(int(f.Fd()), data, 0); err != ErrClosed {
+		t.Fatalf("read after close: err(%v)", err)
+	}
+}
@@ -24,6 +24,7 @@ type KeyInfo struct {
 	Ts        int64  `json:"create_ts"`
 	Role      string `json:"role"`
 	Caps      []byte `json:"caps"`
+	RevokeTs  int64  `json:"revoke_ts,omitempty"` // the tickets issued no later than it are revoked
 }
 
 // DumpJSONFile dump KeyInfo to file in json format
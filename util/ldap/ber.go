@@ -0,0 +1,167 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// The BER tags used by the LDAP messages.
+const (
+	tagBoolean     byte = 0x01
+	tagInteger     byte = 0x02
+	tagOctetString byte = 0x04
+	tagEnumerated  byte = 0x0a
+	tagSequence    byte = 0x30
+	tagSet         byte = 0x31
+
+	classApplication byte = 0x40
+	classContext     byte = 0x80
+	constructed      byte = 0x20
+)
+
+// maxMessageSize limits the size of a message from the server.
+const maxMessageSize = 16 * 1024 * 1024
+
+var errMalformed = errors.New("ldap: malformed message")
+
+// element is a decoded BER element, whose content holds the children if it is constructed.
+type element struct {
+	tag     byte
+	content []byte
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func tlv(tag byte, contents ...[]byte) []byte {
+	var n int
+	for _, c := range contents {
+		n += len(c)
+	}
+	b := append([]byte{tag}, encodeLength(n)...)
+	for _, c := range contents {
+		b = append(b, c...)
+	}
+	return b
+}
+
+func berInt(tag byte, v int64) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; !(v == 0 && b[0] < 0x80) && !(v == -1 && b[0] >= 0x80); v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return tlv(tag, b)
+}
+
+func berString(tag byte, s string) []byte {
+	return tlv(tag, []byte(s))
+}
+
+func berBool(v bool) []byte {
+	if v {
+		return tlv(tagBoolean, []byte{0xff})
+	}
+	return tlv(tagBoolean, []byte{0})
+}
+
+// parseElement splits the first element from b.
+func parseElement(b []byte) (e element, rest []byte, err error) {
+	if len(b) < 2 {
+		return e, nil, errMalformed
+	}
+	e.tag = b[0]
+	n, l := int(b[1]), 2
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < 2+size {
+			return e, nil, errMalformed
+		}
+		n = 0
+		for _, c := range b[2 : 2+size] {
+			n = n<<8 | int(c)
+		}
+		l += size
+	}
+	if n < 0 || len(b)-l < n {
+		return e, nil, errMalformed
+	}
+	e.content = b[l : l+n]
+	return e, b[l+n:], nil
+}
+
+// children returns the elements in the content of a constructed element.
+func (e element) children() (elements []element, err error) {
+	for b := e.content; len(b) > 0; {
+		var c element
+		if c, b, err = parseElement(b); err != nil {
+			return
+		}
+		elements = append(elements, c)
+	}
+	return
+}
+
+func (e element) int() (v int64) {
+	for i, c := range e.content {
+		if i == 0 && c >= 0x80 {
+			v = -1
+		}
+		v = v<<8 | int64(c)
+	}
+	return
+}
+
+// readElement reads an element from the connection.
+func readElement(r *bufio.Reader) (e element, err error) {
+	header := make([]byte, 2, 6)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	n := int(header[1])
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 {
+			return e, errMalformed
+		}
+		lb := make([]byte, size)
+		if _, err = io.ReadFull(r, lb); err != nil {
+			return
+		}
+		header = append(header, lb...)
+		n = 0
+		for _, c := range lb {
+			n = n<<8 | int(c)
+		}
+	}
+	if n < 0 || n > maxMessageSize {
+		return e, errMalformed
+	}
+	content := make([]byte, n)
+	if _, err = io.ReadFull(r, content); err != nil {
+		return
+	}
+	return element{tag: header[0], content: content}, nil
+}
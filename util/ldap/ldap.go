@@ -0,0 +1,273 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ldap is a minimal LDAPv3 client, which binds with a password and searches the entries
+// by an attribute, as much as the authnode needs to authenticate the users against an LDAP server
+// or Active Directory.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The result codes of the LDAP operations.
+const (
+	ResultSuccess            = 0
+	ResultInvalidCredentials = 49
+)
+
+// The protocol ops of the LDAP messages.
+const (
+	opBindRequest       = classApplication | constructed | 0
+	opBindResponse      = classApplication | constructed | 1
+	opUnbindRequest     = classApplication | 2
+	opSearchRequest     = classApplication | constructed | 3
+	opSearchResultEntry = classApplication | constructed | 4
+	opSearchResultDone  = classApplication | constructed | 5
+	opSearchResultRef   = classApplication | constructed | 19
+)
+
+const (
+	scopeWholeSubtree = 2
+	derefNever        = 0
+	filterEquality    = classContext | constructed | 3
+	authSimple        = classContext | 0
+)
+
+// ErrEmptyPassword is returned by Bind with an empty password, which the servers take as an
+// unauthenticated bind instead of refusing it.
+var ErrEmptyPassword = errors.New("ldap: empty password")
+
+// Error is the result of a failed LDAP operation.
+type Error struct {
+	Code    int64
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ldap: result code %v: %v", e.Code, e.Message)
+}
+
+// Entry is an entry found by Search.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Values returns the values of the attribute, whose name is case insensitive.
+func (e *Entry) Values(name string) []string {
+	for n, values := range e.Attributes {
+		if strings.EqualFold(n, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Conn is a connection to an LDAP server, which must not be used concurrently.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	msgID   int64
+	timeout time.Duration
+}
+
+// Dial connects to the server of the URL, ldap://host[:389] or ldaps://host[:636]. The
+// connection to ldaps is secured by tlsConfig, or the default config if it is nil.
+func Dial(rawURL string, tlsConfig *tls.Config, timeout time.Duration) (c *Conn, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		conn, err = net.DialTimeout("tcp", host, timeout)
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = u.Hostname()
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", host, tlsConfig)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported scheme %v", u.Scheme)
+	}
+	if err != nil {
+		return
+	}
+	return &Conn{conn: conn, r: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// Close unbinds and closes the connection.
+func (c *Conn) Close() error {
+	c.send(tlv(opUnbindRequest))
+	return c.conn.Close()
+}
+
+// Bind authenticates the connection as the DN with the password.
+func (c *Conn) Bind(dn, password string) (err error) {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	id, err := c.send(tlv(opBindRequest,
+		berInt(tagInteger, 3),
+		berString(tagOctetString, dn),
+		berString(authSimple, password)))
+	if err != nil {
+		return
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return
+	}
+	if op.tag != opBindResponse {
+		return errMalformed
+	}
+	return parseResult(op)
+}
+
+// Search returns the entries under the base DN whose attribute equals the value, with the
+// attributes requested.
+func (c *Conn) Search(baseDN, attribute, value string, attributes []string) (entries []*Entry, err error) {
+	attrs := make([][]byte, 0, len(attributes))
+	for _, a := range attributes {
+		attrs = append(attrs, berString(tagOctetString, a))
+	}
+	id, err := c.send(tlv(opSearchRequest,
+		berString(tagOctetString, baseDN),
+		berInt(tagEnumerated, scopeWholeSubtree),
+		berInt(tagEnumerated, derefNever),
+		berInt(tagInteger, 0),
+		berInt(tagInteger, int64(c.timeout/time.Second)),
+		berBool(false),
+		tlv(filterEquality, berString(tagOctetString, attribute), berString(tagOctetString, value)),
+		tlv(tagSequence, attrs...)))
+	if err != nil {
+		return
+	}
+	for {
+		var op element
+		if op, err = c.receive(id); err != nil {
+			return
+		}
+		switch op.tag {
+		case opSearchResultEntry:
+			var entry *Entry
+			if entry, err = parseEntry(op); err != nil {
+				return
+			}
+			entries = append(entries, entry)
+		case opSearchResultRef:
+			// the referrals to the other servers are not followed
+		case opSearchResultDone:
+			err = parseResult(op)
+			return
+		default:
+			return nil, errMalformed
+		}
+	}
+}
+
+// send sends the protocol op in a new message, and returns the ID of the message.
+func (c *Conn) send(op []byte) (id int64, err error) {
+	c.msgID++
+	id = c.msgID
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err = c.conn.Write(tlv(tagSequence, berInt(tagInteger, id), op))
+	return
+}
+
+// receive returns the protocol op of the next message, which must reply to the message ID.
+func (c *Conn) receive(id int64) (op element, err error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	msg, err := readElement(c.r)
+	if err != nil {
+		return
+	}
+	children, err := msg.children()
+	if err != nil {
+		return
+	}
+	if msg.tag != tagSequence || len(children) < 2 || children[0].tag != tagInteger {
+		return op, errMalformed
+	}
+	if msgID := children[0].int(); msgID != id {
+		// an unsolicited notification, e.g. the notice of disconnection, has the ID 0
+		return op, fmt.Errorf("ldap: unexpected message %v, expecting %v", msgID, id)
+	}
+	return children[1], nil
+}
+
+func parseResult(op element) error {
+	children, err := op.children()
+	if err != nil {
+		return err
+	}
+	if len(children) < 3 || children[0].tag != tagEnumerated {
+		return errMalformed
+	}
+	if code := children[0].int(); code != ResultSuccess {
+		return &Error{Code: code, Message: string(children[2].content)}
+	}
+	return nil
+}
+
+func parseEntry(op element) (entry *Entry, err error) {
+	children, err := op.children()
+	if err != nil {
+		return
+	}
+	if len(children) < 2 {
+		return nil, errMalformed
+	}
+	entry = &Entry{DN: string(children[0].content), Attributes: make(map[string][]string)}
+	attributes, err := children[1].children()
+	if err != nil {
+		return
+	}
+	for _, a := range attributes {
+		var typeAndValues, values []element
+		if typeAndValues, err = a.children(); err != nil {
+			return
+		}
+		if len(typeAndValues) < 2 {
+			return nil, errMalformed
+		}
+		if values, err = typeAndValues[1].children(); err != nil {
+			return
+		}
+		name := string(typeAndValues[0].content)
+		for _, v := range values {
+			entry.Attributes[name] = append(entry.Attributes[name], string(v.content))
+		}
+	}
+	return
+}
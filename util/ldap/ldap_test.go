@@ -0,0 +1,140 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+const (
+	testDN       = "uid=alice,ou=people,dc=example,dc=com"
+	testPassword = "secret"
+	testGroup    = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+func result(tag byte, code int64, message string) []byte {
+	return tlv(tag, berInt(tagEnumerated, code), berString(tagOctetString, ""), berString(tagOctetString, message))
+}
+
+// serveFake replies to the binds and the searches of a connection like a directory with a user.
+func serveFake(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readElement(r)
+		if err != nil {
+			return
+		}
+		children, err := msg.children()
+		if err != nil || len(children) < 2 {
+			t.Errorf("malformed request: %v", err)
+			return
+		}
+		id := berInt(tagInteger, children[0].int())
+		op := children[1]
+		fields, _ := op.children()
+		var replies [][]byte
+		switch op.tag {
+		case opBindRequest:
+			code := int64(ResultInvalidCredentials)
+			if fields[0].int() == 3 && string(fields[1].content) == testDN && fields[2].tag == authSimple &&
+				string(fields[2].content) == testPassword {
+				code = ResultSuccess
+			}
+			replies = append(replies, result(opBindResponse, code, ""))
+		case opSearchRequest:
+			filter, _ := fields[6].children()
+			if fields[6].tag == filterEquality && string(filter[0].content) == "uid" && string(filter[1].content) == "alice" {
+				replies = append(replies, tlv(opSearchResultEntry,
+					berString(tagOctetString, testDN),
+					tlv(tagSequence, tlv(tagSequence,
+						berString(tagOctetString, "memberOf"),
+						tlv(tagSet, berString(tagOctetString, testGroup))))))
+			}
+			replies = append(replies, result(opSearchResultDone, ResultSuccess, ""))
+		case opUnbindRequest:
+			return
+		}
+		for _, reply := range replies {
+			conn.Write(tlv(tagSequence, id, reply))
+		}
+	}
+}
+
+func TestBindAndSearch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(t, conn)
+		}
+	}()
+
+	c, err := Dial("ldap://"+ln.Addr().String(), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err = c.Bind(testDN, ""); err != ErrEmptyPassword {
+		t.Fatalf("bind with an empty password: %v", err)
+	}
+	err = c.Bind(testDN, "wrong")
+	if e, ok := err.(*Error); !ok || e.Code != ResultInvalidCredentials {
+		t.Fatalf("bind with a wrong password: %v", err)
+	}
+	if err = c.Bind(testDN, testPassword); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+
+	entries, err := c.Search("dc=example,dc=com", "uid", "alice", []string{"memberOf"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(entries) != 1 || entries[0].DN != testDN {
+		t.Fatalf("search: unexpected entries %v", entries)
+	}
+	if groups := entries[0].Values("memberof"); len(groups) != 1 || groups[0] != testGroup {
+		t.Fatalf("search: unexpected groups %v", groups)
+	}
+
+	if entries, err = c.Search("dc=example,dc=com", "uid", "bob", nil); err != nil || len(entries) != 0 {
+		t.Fatalf("search for nobody: %v %v", entries, err)
+	}
+}
+
+func TestBerInt(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, 65535, 1 << 31, -1, -128, -129} {
+		e, rest, err := parseElement(berInt(tagInteger, v))
+		if err != nil || len(rest) != 0 || e.int() != v {
+			t.Fatalf("integer %v: decoded %v, err %v", v, e.int(), err)
+		}
+	}
+	long := make([]byte, 300)
+	e, _, err := parseElement(tlv(tagOctetString, long))
+	if err != nil || len(e.content) != len(long) {
+		t.Fatalf("long element: %v %v", len(e.content), err)
+	}
+}
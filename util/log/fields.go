@@ -0,0 +1,123 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"fmt"
+)
+
+// Entry carries the fields of the log lines, the partition and the request id,
+// which are printed before the message in the text format and as the fields of
+// the record in the json format. A zero field is left out.
+//
+//	log.WithPartition(p.PartitionID).WithReqID(p.ReqID).LogWritef("action[OperatePacket] %v", msg)
+type Entry struct {
+	partition uint64
+	reqID     int64
+}
+
+// WithPartition returns an entry carrying the partition id.
+func WithPartition(id uint64) *Entry {
+	return &Entry{partition: id}
+}
+
+// WithReqID returns an entry carrying the request id.
+func WithReqID(id int64) *Entry {
+	return &Entry{reqID: id}
+}
+
+// WithPartition returns a copy of the entry carrying the partition id.
+func (e *Entry) WithPartition(id uint64) *Entry {
+	c := *e
+	c.partition = id
+	return &c
+}
+
+// WithReqID returns a copy of the entry carrying the request id.
+func (e *Entry) WithReqID(id int64) *Entry {
+	c := *e
+	c.reqID = id
+	return &c
+}
+
+func (e *Entry) String() string {
+	if e == nil {
+		return ""
+	}
+	var s string
+	if e.partition != 0 {
+		s += fmt.Sprintf("partition(%v) ", e.partition)
+	}
+	if e.reqID != 0 {
+		s += fmt.Sprintf("reqid(%v) ", e.reqID)
+	}
+	return s
+}
+
+func (e *Entry) output(level Level, index int, format string, v []interface{}) {
+	if gLog == nil {
+		return
+	}
+	if level&gLog.level != gLog.level {
+		return
+	}
+	var logger *LogObject
+	switch index {
+	case 0:
+		logger = gLog.debugLogger
+	case 1:
+		logger = gLog.infoLogger
+	case 2:
+		logger = gLog.warnLogger
+	case 3:
+		logger = gLog.errorLogger
+	case 5:
+		logger = gLog.readLogger
+	case 6:
+		logger = gLog.updateLogger
+	}
+	s := gLog.prefix(fmt.Sprintf(format, v...), levelPrefixes[index], e, 3)
+	logger.Output(3, s)
+}
+
+// LogDebugf writes the debug log with the fields of the entry.
+func (e *Entry) LogDebugf(format string, v ...interface{}) {
+	e.output(DebugLevel, 0, format, v)
+}
+
+// LogInfof writes the info log with the fields of the entry.
+func (e *Entry) LogInfof(format string, v ...interface{}) {
+	e.output(InfoLevel, 1, format, v)
+}
+
+// LogWarnf writes the warn log with the fields of the entry.
+func (e *Entry) LogWarnf(format string, v ...interface{}) {
+	e.output(WarnLevel, 2, format, v)
+}
+
+// LogErrorf writes the error log with the fields of the entry.
+func (e *Entry) LogErrorf(format string, v ...interface{}) {
+	e.output(ErrorLevel, 3, format, v)
+}
+
+// LogReadf writes the read log with the fields of the entry.
+func (e *Entry) LogReadf(format string, v ...interface{}) {
+	e.output(ReadLevel, 5, format, v)
+}
+
+// LogWritef writes the write log with the fields of the entry.
+func (e *Entry) LogWritef(format string, v ...interface{}) {
+	e.output(UpdateLevel, 6, format, v)
+}
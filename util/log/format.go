@@ -0,0 +1,117 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format defines the format of the log lines.
+type Format uint8
+
+const (
+	TextFormat Format = iota
+	JSONFormat
+)
+
+const (
+	TextFormatName = "text"
+	JSONFormatName = "json"
+
+	jsonTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// ParseFormat parses the format name, an empty name means the text format.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", TextFormatName:
+		return TextFormat, nil
+	case JSONFormatName:
+		return JSONFormat, nil
+	default:
+		return TextFormat, fmt.Errorf("unknown log format(%v), only %v and %v are supported", name, TextFormatName, JSONFormatName)
+	}
+}
+
+func (f Format) String() string {
+	if f == JSONFormat {
+		return JSONFormatName
+	}
+	return TextFormatName
+}
+
+type jsonRecord struct {
+	Ts        string `json:"ts"`
+	Level     string `json:"level"`
+	Module    string `json:"module"`
+	Partition string `json:"partition,omitempty"`
+	ReqID     string `json:"reqid,omitempty"`
+	File      string `json:"file"`
+	Msg       string `json:"msg"`
+}
+
+// SetFormat sets the format of the log lines.
+func (l *Log) SetFormat(format Format) {
+	flag := 0
+	if format == TextFormat {
+		flag = logFlags
+	}
+	for _, logger := range l.loggers() {
+		if logger != nil {
+			logger.SetFlags(flag)
+		}
+	}
+	l.format = format
+}
+
+// SetLogFormat sets the format of the global log by its name, "text" or "json".
+func SetLogFormat(name string) error {
+	format, err := ParseFormat(name)
+	if err != nil {
+		return err
+	}
+	if gLog != nil {
+		gLog.SetFormat(format)
+	}
+	return nil
+}
+
+func (l *Log) formatJSON(s, level, file string, e *Entry) string {
+	s = strings.TrimSuffix(s, "\n")
+	r := jsonRecord{
+		Ts:     time.Now().Format(jsonTimeFormat),
+		Level:  strings.ToLower(strings.Trim(level, "[] ")),
+		Module: l.module,
+		File:   file,
+		Msg:    s,
+	}
+	if e != nil {
+		if e.partition != 0 {
+			r.Partition = strconv.FormatUint(e.partition, 10)
+		}
+		if e.reqID != 0 {
+			r.ReqID = strconv.FormatInt(e.reqID, 10)
+		}
+	}
+	data, err := json.Marshal(&r)
+	if err != nil {
+		return s
+	}
+	return string(data)
+}
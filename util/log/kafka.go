@@ -0,0 +1,341 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A minimal Kafka producer which is just enough to append the log lines to a topic:
+// the leader of the partition is looked up with Metadata v1, and the lines are sent
+// with Produce v3 in a record batch of magic v2, which are supported by Kafka 0.11 and later.
+
+const (
+	kafkaAPIProduce     int16 = 0
+	kafkaAPIMetadata    int16 = 3
+	kafkaProduceVersion int16 = 3
+	kafkaMetaVersion    int16 = 1
+	kafkaClientID             = "chubaofs-log"
+	kafkaAcks           int16 = 1
+	kafkaTimeout              = 10 * time.Second
+	kafkaMaxResponse          = 64 * 1024 * 1024
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type kafkaSink struct {
+	brokers       []string
+	topic         string
+	key           []byte
+	partition     int32
+	conn          net.Conn
+	correlationID int32
+}
+
+func newKafkaSink(brokers []string, topic string) (*kafkaSink, error) {
+	if len(brokers) == 0 || brokers[0] == "" {
+		return nil, errors.New("kafka brokers are not specified")
+	}
+	hostname, _ := os.Hostname()
+	return &kafkaSink{
+		brokers: brokers,
+		topic:   topic,
+		key:     []byte(hostname),
+	}, nil
+}
+
+// Send implements RemoteSink.
+func (k *kafkaSink) Send(records []*RemoteRecord) (err error) {
+	if k.conn == nil {
+		if err = k.connectLeader(); err != nil {
+			return
+		}
+	}
+	if err = k.produce(records); err != nil {
+		k.conn.Close()
+		k.conn = nil
+	}
+	return
+}
+
+// Close implements RemoteSink.
+func (k *kafkaSink) Close() error {
+	if k.conn == nil {
+		return nil
+	}
+	return k.conn.Close()
+}
+
+// connectLeader connects to the leader of the partition chosen by the hash of the host name,
+// so that the lines of a node are kept in order.
+func (k *kafkaSink) connectLeader() (err error) {
+	for _, broker := range k.brokers {
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", broker, kafkaTimeout); err != nil {
+			continue
+		}
+		var leader string
+		leader, err = k.lookupLeader(conn)
+		conn.Close()
+		if err != nil {
+			continue
+		}
+		if k.conn, err = net.DialTimeout("tcp", leader, kafkaTimeout); err != nil {
+			k.conn = nil
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("kafka: no leader is available for topic(%v): %v", k.topic, err)
+}
+
+func (k *kafkaSink) lookupLeader(conn net.Conn) (leader string, err error) {
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.BigEndian, int32(1))
+	writeKafkaString(body, k.topic)
+	resp, err := k.roundTrip(conn, kafkaAPIMetadata, kafkaMetaVersion, body.Bytes())
+	if err != nil {
+		return
+	}
+	r := &kafkaReader{buf: resp}
+	brokers := make(map[int32]string)
+	for n := r.int32(); n > 0; n-- {
+		id := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller id
+	for n := r.int32(); n > 0; n-- {
+		code := r.int16()
+		name := r.string()
+		r.int8() // is internal
+		var leaders []int32
+		for p := r.int32(); p > 0; p-- {
+			r.int16() // partition error code
+			id := r.int32()
+			leaderID := r.int32()
+			for rs := r.int32(); rs > 0; rs-- {
+				r.int32()
+			}
+			for is := r.int32(); is > 0; is-- {
+				r.int32()
+			}
+			for int32(len(leaders)) <= id {
+				leaders = append(leaders, -1)
+			}
+			leaders[id] = leaderID
+		}
+		if name != k.topic {
+			continue
+		}
+		if code != 0 || len(leaders) == 0 {
+			return "", fmt.Errorf("kafka: metadata of topic(%v) error code(%v)", k.topic, code)
+		}
+		h := fnv.New32a()
+		h.Write(k.key)
+		k.partition = int32(h.Sum32() % uint32(len(leaders)))
+		if addr, ok := brokers[leaders[k.partition]]; ok {
+			return addr, r.err
+		}
+		return "", fmt.Errorf("kafka: partition(%v) of topic(%v) has no leader", k.partition, k.topic)
+	}
+	if r.err != nil {
+		return "", r.err
+	}
+	return "", fmt.Errorf("kafka: topic(%v) not found", k.topic)
+}
+
+func (k *kafkaSink) produce(records []*RemoteRecord) (err error) {
+	batch := encodeRecordBatch(k.key, records)
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.BigEndian, int16(-1)) // transactional id
+	binary.Write(body, binary.BigEndian, kafkaAcks)
+	binary.Write(body, binary.BigEndian, int32(kafkaTimeout/time.Millisecond))
+	binary.Write(body, binary.BigEndian, int32(1))
+	writeKafkaString(body, k.topic)
+	binary.Write(body, binary.BigEndian, int32(1))
+	binary.Write(body, binary.BigEndian, k.partition)
+	binary.Write(body, binary.BigEndian, int32(len(batch)))
+	body.Write(batch)
+	resp, err := k.roundTrip(k.conn, kafkaAPIProduce, kafkaProduceVersion, body.Bytes())
+	if err != nil {
+		return
+	}
+	r := &kafkaReader{buf: resp}
+	for n := r.int32(); n > 0; n-- {
+		r.string()
+		for p := r.int32(); p > 0; p-- {
+			r.int32()
+			if code := r.int16(); code != 0 && r.err == nil {
+				return fmt.Errorf("kafka: produce to topic(%v) partition(%v) error code(%v)", k.topic, k.partition, code)
+			}
+			r.int64()
+			r.int64()
+		}
+	}
+	return r.err
+}
+
+func (k *kafkaSink) roundTrip(conn net.Conn, apiKey, apiVersion int16, body []byte) (resp []byte, err error) {
+	k.correlationID++
+	req := new(bytes.Buffer)
+	binary.Write(req, binary.BigEndian, int32(0))
+	binary.Write(req, binary.BigEndian, apiKey)
+	binary.Write(req, binary.BigEndian, apiVersion)
+	binary.Write(req, binary.BigEndian, k.correlationID)
+	writeKafkaString(req, kafkaClientID)
+	req.Write(body)
+	data := req.Bytes()
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(data)-4))
+
+	conn.SetDeadline(time.Now().Add(kafkaTimeout))
+	if _, err = conn.Write(data); err != nil {
+		return
+	}
+	var header [8]byte
+	if _, err = io.ReadFull(conn, header[:]); err != nil {
+		return
+	}
+	size := int32(binary.BigEndian.Uint32(header[0:4]))
+	if size < 4 || size > kafkaMaxResponse {
+		return nil, fmt.Errorf("kafka: invalid response size(%v)", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(header[4:8])); id != k.correlationID {
+		return nil, fmt.Errorf("kafka: correlation id mismatch, expect(%v) got(%v)", k.correlationID, id)
+	}
+	resp = make([]byte, size-4)
+	_, err = io.ReadFull(conn, resp)
+	return
+}
+
+// encodeRecordBatch encodes the records in a record batch of magic v2.
+func encodeRecordBatch(key []byte, records []*RemoteRecord) []byte {
+	first := records[0].Time.UnixNano() / int64(time.Millisecond)
+	last := records[len(records)-1].Time.UnixNano() / int64(time.Millisecond)
+
+	recs := new(bytes.Buffer)
+	for i, r := range records {
+		rec := new(bytes.Buffer)
+		rec.WriteByte(0) // attributes
+		writeVarint(rec, r.Time.UnixNano()/int64(time.Millisecond)-first)
+		writeVarint(rec, int64(i))
+		writeVarint(rec, int64(len(key)))
+		rec.Write(key)
+		writeVarint(rec, int64(len(r.Line)))
+		rec.Write(r.Line)
+		writeVarint(rec, 0) // headers
+		writeVarint(recs, int64(rec.Len()))
+		recs.Write(rec.Bytes())
+	}
+
+	// the part covered by the crc, from the attributes to the end
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.BigEndian, int16(0))              // attributes
+	binary.Write(body, binary.BigEndian, int32(len(records)-1)) // last offset delta
+	binary.Write(body, binary.BigEndian, first)
+	binary.Write(body, binary.BigEndian, last)
+	binary.Write(body, binary.BigEndian, int64(-1)) // producer id
+	binary.Write(body, binary.BigEndian, int16(-1)) // producer epoch
+	binary.Write(body, binary.BigEndian, int32(-1)) // base sequence
+	binary.Write(body, binary.BigEndian, int32(len(records)))
+	body.Write(recs.Bytes())
+
+	batch := new(bytes.Buffer)
+	binary.Write(batch, binary.BigEndian, int64(0))                // base offset
+	binary.Write(batch, binary.BigEndian, int32(body.Len()+4+1+4)) // batch length
+	binary.Write(batch, binary.BigEndian, int32(-1))               // partition leader epoch
+	batch.WriteByte(2)                                             // magic
+	binary.Write(batch, binary.BigEndian, crc32.Checksum(body.Bytes(), crc32c))
+	batch.Write(body.Bytes())
+	return batch.Bytes()
+}
+
+func writeVarint(w *bytes.Buffer, v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	w.Write(buf[:n])
+}
+
+func writeKafkaString(w *bytes.Buffer, s string) {
+	binary.Write(w, binary.BigEndian, int16(len(s)))
+	w.WriteString(s)
+}
+
+// kafkaReader decodes a response, the first error is kept and the later reads return zero values.
+type kafkaReader struct {
+	buf []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = errors.New("kafka: response is too short")
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
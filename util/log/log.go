@@ -53,6 +53,8 @@ const (
 	WriterBufferLenLimit   = 4 * 1024 * 1024
 	DefaultRollingInterval = 1 * time.Second
 	RolledExtension        = ".old"
+
+	logFlags = log.LstdFlags | log.Lmicroseconds
 )
 
 var levelPrefixes = []string{
@@ -64,6 +66,7 @@ var levelPrefixes = []string{
 	"[READ ]",
 	"[WRITE]",
 	"[Critical]",
+	"[SLOW ]",
 }
 
 type RolledFile []os.FileInfo
@@ -228,6 +231,8 @@ func newLogObject(writer *asyncWriter, prefix string, flag int) *LogObject {
 // Log defines the log struct.
 type Log struct {
 	dir            string
+	module         string
+	format         Format
 	errorLogger    *LogObject
 	warnLogger     *LogObject
 	debugLogger    *LogObject
@@ -235,9 +240,11 @@ type Log struct {
 	readLogger     *LogObject
 	updateLogger   *LogObject
 	criticalLogger *LogObject
+	slowLogger     *LogObject
 	level          Level
 	msgC           chan string
 	rotate         *LogRotate
+	remote         *remoteWriter
 	lastRolledTime time.Time
 }
 
@@ -249,6 +256,7 @@ var (
 	ReadLogFileName     = "_read.log"
 	UpdateLogFileName   = "_write.log"
 	CriticalLogFileName = "_critical.log"
+	SlowLogFileName     = "_slow.log"
 )
 
 var gLog *Log = nil
@@ -258,6 +266,7 @@ func InitLog(dir, module string, level Level, rotate *LogRotate) (*Log, error) {
 	l := new(Log)
 	dir = path.Join(dir, module)
 	l.dir = dir
+	l.module = module
 	fi, err := os.Stat(dir)
 	if err != nil {
 		os.MkdirAll(dir, 0755)
@@ -291,20 +300,18 @@ func InitLog(dir, module string, level Level, rotate *LogRotate) (*Log, error) {
 }
 
 func (l *Log) initLog(logDir, module string, level Level) error {
-	logOpt := log.LstdFlags | log.Lmicroseconds
-
 	newLog := func(logFileName string) (newLogger *LogObject, err error) {
 		logName := path.Join(logDir, module+logFileName)
 		w, err := newAsyncWriter(logName, l.rotate.rollingSize)
 		if err != nil {
 			return
 		}
-		newLogger = newLogObject(w, "", logOpt)
+		newLogger = newLogObject(w, "", logFlags)
 		return
 	}
 	var err error
-	logHandles := [...]**LogObject{&l.debugLogger, &l.infoLogger, &l.warnLogger, &l.errorLogger, &l.readLogger, &l.updateLogger, &l.criticalLogger}
-	logNames := [...]string{DebugLogFileName, InfoLogFileName, WarnLogFileName, ErrLogFileName, ReadLogFileName, UpdateLogFileName, CriticalLogFileName}
+	logHandles := [...]**LogObject{&l.debugLogger, &l.infoLogger, &l.warnLogger, &l.errorLogger, &l.readLogger, &l.updateLogger, &l.criticalLogger, &l.slowLogger}
+	logNames := [...]string{DebugLogFileName, InfoLogFileName, WarnLogFileName, ErrLogFileName, ReadLogFileName, UpdateLogFileName, CriticalLogFileName, SlowLogFileName}
 	for i := range logHandles {
 		if *logHandles[i], err = newLog(logNames[i]); err != nil {
 			return err
@@ -316,7 +323,13 @@ func (l *Log) initLog(logDir, module string, level Level) error {
 
 // SetPrefix sets the log prefix.
 func (l *Log) SetPrefix(s, level string) string {
-	_, file, line, ok := runtime.Caller(2)
+	return l.prefix(s, level, nil, 3)
+}
+
+// prefix adds the level, the file of the caller and the fields of the entry to the
+// line, calldepth is the count of the frames to skip like the one of log.Output.
+func (l *Log) prefix(s, level string, e *Entry, calldepth int) string {
+	_, file, line, ok := runtime.Caller(calldepth)
 	if !ok {
 		line = 0
 	}
@@ -327,13 +340,15 @@ func (l *Log) SetPrefix(s, level string) string {
 			break
 		}
 	}
-	file = short
-	return level + " " + file + ":" + strconv.Itoa(line) + ": " + s
+	file = short + ":" + strconv.Itoa(line)
+	if l.format == JSONFormat {
+		return l.formatJSON(s, level, file, e)
+	}
+	return level + " " + file + ": " + e.String() + s
 }
 
-// Flush flushes the log.
-func (l *Log) Flush() {
-	loggers := []*LogObject{
+func (l *Log) loggers() []*LogObject {
+	return []*LogObject{
 		l.debugLogger,
 		l.infoLogger,
 		l.warnLogger,
@@ -341,8 +356,13 @@ func (l *Log) Flush() {
 		l.readLogger,
 		l.updateLogger,
 		l.criticalLogger,
+		l.slowLogger,
 	}
-	for _, logger := range loggers {
+}
+
+// Flush flushes the log.
+func (l *Log) Flush() {
+	for _, logger := range l.loggers() {
 		if logger != nil {
 			logger.Flush()
 		}
@@ -679,6 +699,7 @@ func (l *Log) checkLogRotation(logDir, module string) {
 		l.errorLogger.SetRotation()
 		l.readLogger.SetRotation()
 		l.updateLogger.SetRotation()
+		l.slowLogger.SetRotation()
 
 		l.lastRolledTime = now
 	}
@@ -17,8 +17,10 @@ package log
 // These tests are too simple.
 
 import (
+	"encoding/json"
 	"net/http"
 	_ "net/http/pprof"
+	"strings"
 	"testing"
 	"time"
 )
@@ -33,3 +35,39 @@ func TestLog(t *testing.T) {
 		time.Sleep(200 * time.Millisecond)
 	}
 }
+
+func TestJSONFormat(t *testing.T) {
+	l := &Log{module: "datanode", format: JSONFormat}
+	s := l.formatJSON("Op(OpWrite)ResultCode(OpOk)\n", levelPrefixes[1], "repl.go:10", WithPartition(34).WithReqID(12))
+	r := new(jsonRecord)
+	if err := json.Unmarshal([]byte(s), r); err != nil {
+		t.Fatalf("invalid json line %v: %v", s, err)
+	}
+	if r.Level != "info" || r.Module != "datanode" || r.ReqID != "12" || r.Partition != "34" || r.File != "repl.go:10" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if s = l.formatJSON("ReqID(12)PartitionID(34)", levelPrefixes[1], "repl.go:10", nil); strings.Contains(s, "reqid") || strings.Contains(s, "partition") {
+		t.Fatalf("fields of a line without an entry %v", s)
+	}
+	l.format = TextFormat
+	if s = l.SetPrefix("Op(OpWrite)", levelPrefixes[1]); !strings.HasSuffix(s, ": Op(OpWrite)") {
+		t.Fatalf("unexpected text line %v", s)
+	}
+	if s = l.prefix("Op(OpWrite)", levelPrefixes[1], WithReqID(12).WithPartition(34), 1); !strings.HasSuffix(s, ": partition(34) reqid(12) Op(OpWrite)") {
+		t.Fatalf("unexpected text line %v", s)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatalf("xml format should not be supported")
+	}
+}
+
+func TestSlowOpThreshold(t *testing.T) {
+	defer SetSlowOpThresholds(DefaultSlowOpThreshold, nil)
+	SetSlowOpThresholds(500*time.Millisecond, map[string]time.Duration{"OpWrite": 100 * time.Millisecond})
+	if SlowOpThreshold("OpWrite") != 100*time.Millisecond {
+		t.Fatalf("unexpected threshold of OpWrite %v", SlowOpThreshold("OpWrite"))
+	}
+	if SlowOpThreshold("OpRead") != 500*time.Millisecond {
+		t.Fatalf("unexpected threshold of OpRead %v", SlowOpThreshold("OpRead"))
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultRemoteBufferSize = 64 * 1024 // number of the lines buffered in memory
+
+	remoteBatchSize     = 512
+	remoteFlushInterval = time.Second
+	remoteMaxBackoff    = 30 * time.Second
+)
+
+// RemoteRecord is a log line to be shipped to the remote sink.
+type RemoteRecord struct {
+	Time   time.Time
+	Level  Level
+	Module string
+	Line   []byte
+}
+
+// RemoteSink sends the log lines to a remote log service.
+type RemoteSink interface {
+	// Send sends a batch of records, the batch is retried if an error is returned.
+	Send(records []*RemoteRecord) error
+	Close() error
+}
+
+// remoteWriter buffers the log lines in memory and ships them to the sink in the background.
+// When the buffer is full the new lines are dropped, so that a slow or broken sink never
+// blocks the caller; the number of the dropped lines is reported to the sink once it recovers.
+type remoteWriter struct {
+	sink    RemoteSink
+	module  string
+	queue   chan *RemoteRecord
+	dropped uint64
+	stopC   chan struct{}
+	doneC   chan struct{}
+}
+
+func newRemoteWriter(sink RemoteSink, module string, bufferSize int) *remoteWriter {
+	if bufferSize <= 0 {
+		bufferSize = DefaultRemoteBufferSize
+	}
+	rw := &remoteWriter{
+		sink:   sink,
+		module: module,
+		queue:  make(chan *RemoteRecord, bufferSize),
+		stopC:  make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+	go rw.run()
+	return rw
+}
+
+func (rw *remoteWriter) add(level Level, p []byte) {
+	line := make([]byte, len(p))
+	copy(line, p)
+	r := &RemoteRecord{Time: time.Now(), Level: level, Module: rw.module, Line: line}
+	select {
+	case rw.queue <- r:
+	default:
+		atomic.AddUint64(&rw.dropped, 1)
+	}
+}
+
+func (rw *remoteWriter) run() {
+	defer close(rw.doneC)
+	ticker := time.NewTicker(remoteFlushInterval)
+	defer ticker.Stop()
+	batch := make([]*RemoteRecord, 0, remoteBatchSize)
+	for {
+		select {
+		case r := <-rw.queue:
+			batch = append(batch, r)
+			if len(batch) < remoteBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-rw.stopC:
+			for len(rw.queue) > 0 && len(batch) < cap(batch) {
+				batch = append(batch, <-rw.queue)
+			}
+			if len(batch) > 0 {
+				rw.sendBatch(batch)
+			}
+			return
+		}
+		if !rw.send(batch) {
+			return
+		}
+		batch = batch[:0]
+	}
+}
+
+// send retries the batch with backoff until it succeeds or the writer is closed.
+func (rw *remoteWriter) send(batch []*RemoteRecord) bool {
+	backoff := remoteFlushInterval
+	for {
+		err := rw.sendBatch(batch)
+		if err == nil {
+			return true
+		}
+		fmt.Fprintf(os.Stderr, "log: ship to remote sink failed: %v\n", err)
+		select {
+		case <-rw.stopC:
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > remoteMaxBackoff {
+			backoff = remoteMaxBackoff
+		}
+	}
+}
+
+// sendBatch sends the batch with the count of the dropped lines, which is only
+// taken off once the sink accepts it, so a failed send reports it again.
+func (rw *remoteWriter) sendBatch(batch []*RemoteRecord) (err error) {
+	dropped := atomic.LoadUint64(&rw.dropped)
+	records := batch
+	if dropped > 0 {
+		line := fmt.Sprintf("%v remote log buffer is full, %v lines dropped\n", levelPrefixes[2], dropped)
+		r := &RemoteRecord{Time: time.Now(), Level: WarnLevel, Module: rw.module, Line: []byte(line)}
+		records = append(batch[:len(batch):len(batch)], r)
+	}
+	if err = rw.sink.Send(records); err != nil {
+		return
+	}
+	if dropped > 0 {
+		atomic.AddUint64(&rw.dropped, ^(dropped - 1))
+	}
+	return
+}
+
+func (rw *remoteWriter) close() {
+	close(rw.stopC)
+	<-rw.doneC
+	rw.sink.Close()
+}
+
+// levelWriter writes the lines of a logger to the local file and the remote sink.
+type levelWriter struct {
+	local  *asyncWriter
+	remote *remoteWriter
+	level  Level
+}
+
+func (w *levelWriter) Write(p []byte) (n int, err error) {
+	w.remote.add(w.level, p)
+	return w.local.Write(p)
+}
+
+// NewRemoteSink creates the sink from the address, which can be
+//
+//	syslog+udp://host:port, syslog+tcp://host:port or
+//	kafka://broker1:port,broker2:port/topic
+func NewRemoteSink(addr string) (RemoteSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "syslog", "syslog+udp":
+		return newSyslogSink("udp", u.Host)
+	case "syslog+tcp":
+		return newSyslogSink("tcp", u.Host)
+	case "kafka":
+		topic := strings.Trim(u.Path, "/")
+		if topic == "" {
+			return nil, fmt.Errorf("kafka topic is not specified in %v", addr)
+		}
+		return newKafkaSink(strings.Split(u.Host, ","), topic)
+	default:
+		return nil, fmt.Errorf("unsupported remote log address %v", addr)
+	}
+}
+
+// SetRemote ships the log lines of all the levels above the log level to the remote sink.
+func (l *Log) SetRemote(sink RemoteSink, bufferSize int) {
+	if l.remote != nil {
+		l.remote.close()
+	}
+	l.remote = newRemoteWriter(sink, l.module, bufferSize)
+	levels := [...]Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, ReadLevel, UpdateLevel, CriticalLevel, WarnLevel}
+	for i, logger := range l.loggers() {
+		logger.SetOutput(&levelWriter{local: logger.object, remote: l.remote, level: levels[i]})
+	}
+}
+
+// SetLogRemote ships the global log to the remote address, see NewRemoteSink for the address format.
+// An empty address disables the remote shipping.
+func SetLogRemote(addr string, bufferSize int) error {
+	if addr == "" || gLog == nil {
+		return nil
+	}
+	sink, err := NewRemoteSink(addr)
+	if err != nil {
+		return err
+	}
+	gLog.SetRemote(sink, bufferSize)
+	return nil
+}
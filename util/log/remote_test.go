@@ -0,0 +1,160 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	sync.Mutex
+	lines []string
+}
+
+func (s *memorySink) Send(records []*RemoteRecord) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, r := range records {
+		s.lines = append(s.lines, string(r.Line))
+	}
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+type failingSink struct {
+	memorySink
+	failures int
+}
+
+func (s *failingSink) Send(records []*RemoteRecord) error {
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("sink is down")
+	}
+	return s.memorySink.Send(records)
+}
+
+func TestRemoteWriterDropped(t *testing.T) {
+	sink := &failingSink{failures: 1}
+	rw := &remoteWriter{sink: sink, module: "test"}
+	rw.dropped = 3
+	batch := []*RemoteRecord{{Line: []byte("line1\n")}}
+	if err := rw.sendBatch(batch); err == nil || rw.dropped != 3 {
+		t.Fatalf("dropped count %v after a failed send %v", rw.dropped, err)
+	}
+	if err := rw.sendBatch(batch); err != nil || rw.dropped != 0 {
+		t.Fatalf("dropped count %v after a send %v", rw.dropped, err)
+	}
+	if len(sink.lines) != 2 || !strings.Contains(sink.lines[1], "3 lines dropped") {
+		t.Fatalf("unexpected lines %v", sink.lines)
+	}
+}
+
+func TestRemoteWriter(t *testing.T) {
+	sink := new(memorySink)
+	rw := newRemoteWriter(sink, "test", 2)
+	rw.add(InfoLevel, []byte("line1\n"))
+	rw.add(InfoLevel, []byte("line2\n"))
+	rw.add(InfoLevel, []byte("line3\n"))
+	rw.add(InfoLevel, []byte("line4\n"))
+	rw.close()
+
+	if len(sink.lines) == 0 || sink.lines[0] != "line1\n" {
+		t.Fatalf("unexpected lines %v", sink.lines)
+	}
+	total := len(sink.lines)
+	if last := sink.lines[total-1]; strings.Contains(last, "dropped") {
+		total--
+	}
+	if total > 4 {
+		t.Fatalf("too many lines %v", sink.lines)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgC := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var n int
+		if _, err = fmt.Fscan(r, &n); err != nil {
+			return
+		}
+		buf := make([]byte, n+1)
+		if _, err = io.ReadFull(r, buf); err == nil {
+			msgC <- string(buf[1:])
+		}
+	}()
+
+	sink, err := NewRemoteSink("syslog+tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	r := &RemoteRecord{Time: time.Now(), Level: ErrorLevel, Module: "datanode", Line: []byte("[ERROR] disk broken\n")}
+	if err = sink.Send([]*RemoteRecord{r}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgC:
+		if !strings.HasPrefix(msg, "<131>1 ") || !strings.Contains(msg, " datanode ") || !strings.HasSuffix(msg, " - - [ERROR] disk broken") {
+			t.Fatalf("unexpected message %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received")
+	}
+}
+
+func TestKafkaRecordBatch(t *testing.T) {
+	now := time.Now()
+	records := []*RemoteRecord{
+		{Time: now, Level: InfoLevel, Line: []byte("line1\n")},
+		{Time: now.Add(time.Millisecond), Level: InfoLevel, Line: []byte("line2\n")},
+	}
+	batch := encodeRecordBatch([]byte("host"), records)
+	if length := int(binary.BigEndian.Uint32(batch[8:12])); length != len(batch)-12 {
+		t.Fatalf("batch length %v mismatch with %v", length, len(batch)-12)
+	}
+	if batch[16] != 2 {
+		t.Fatalf("unexpected magic %v", batch[16])
+	}
+	if crc := binary.BigEndian.Uint32(batch[17:21]); crc != crc32.Checksum(batch[21:], crc32c) {
+		t.Fatalf("crc mismatch")
+	}
+	if count := binary.BigEndian.Uint32(batch[57:61]); count != 2 {
+		t.Fatalf("unexpected record count %v", count)
+	}
+}
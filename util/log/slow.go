@@ -0,0 +1,89 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSlowOpThreshold = time.Second
+)
+
+var (
+	slowThreshold    = DefaultSlowOpThreshold
+	slowOpThresholds map[string]time.Duration
+	slowMu           sync.RWMutex
+)
+
+// SetSlowOpThresholds sets the latency thresholds of the slow operations. The default threshold
+// applies to the operations not in the perOp map, and a negative threshold disables the slow log.
+func SetSlowOpThresholds(defaultThreshold time.Duration, perOp map[string]time.Duration) {
+	slowMu.Lock()
+	defer slowMu.Unlock()
+	slowThreshold = defaultThreshold
+	slowOpThresholds = perOp
+}
+
+// SlowOpThreshold returns the latency threshold of the operation.
+func SlowOpThreshold(op string) time.Duration {
+	slowMu.RLock()
+	defer slowMu.RUnlock()
+	if t, ok := slowOpThresholds[op]; ok {
+		return t
+	}
+	return slowThreshold
+}
+
+// SlowOp describes an operation which is checked against the slow log threshold.
+// Wait is the time spent queuing before the operation is executed, and Exec is
+// the time spent executing it; Detail breaks down the execution further.
+type SlowOp struct {
+	Op        string
+	Partition uint64
+	ReqID     int64
+	Remote    string
+	Wait      time.Duration
+	Exec      time.Duration
+	Detail    string
+	Err       error
+}
+
+// LogSlowOp writes the operation into the slow log if its latency exceeds the threshold.
+func LogSlowOp(op *SlowOp) {
+	if gLog == nil {
+		return
+	}
+	total := op.Wait + op.Exec
+	threshold := SlowOpThreshold(op.Op)
+	if threshold < 0 || total < threshold {
+		return
+	}
+	s := fmt.Sprintf("Op(%v) Total(%v) Wait(%v) Exec(%v) Threshold(%v)",
+		op.Op, total, op.Wait, op.Exec, threshold)
+	if op.Remote != "" {
+		s += fmt.Sprintf(" Remote(%v)", op.Remote)
+	}
+	if op.Detail != "" {
+		s += " " + op.Detail
+	}
+	if op.Err != nil {
+		s += fmt.Sprintf(" Err(%v)", op.Err)
+	}
+	s = gLog.prefix(s, levelPrefixes[8], &Entry{partition: op.Partition, reqID: op.ReqID}, 2)
+	gLog.slowLogger.Output(2, s)
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	syslogFacilityLocal0 = 16
+	syslogDialTimeout    = 5 * time.Second
+	syslogWriteTimeout   = 5 * time.Second
+	syslogTimeFormat     = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// syslogSink sends the log lines in RFC5424 format, using the octet counting
+// framing of RFC6587 on TCP and one message per datagram on UDP.
+type syslogSink struct {
+	network  string
+	addr     string
+	hostname string
+	pid      string
+	conn     net.Conn
+}
+
+func newSyslogSink(network, addr string) (*syslogSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &syslogSink{
+		network:  network,
+		addr:     addr,
+		hostname: hostname,
+		pid:      strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+func syslogSeverity(level Level) int {
+	switch level {
+	case DebugLevel:
+		return 7
+	case InfoLevel:
+		return 6
+	case WarnLevel:
+		return 4
+	case ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (s *syslogSink) format(r *RemoteRecord) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("<")
+	buf.WriteString(strconv.Itoa(syslogFacilityLocal0*8 + syslogSeverity(r.Level)))
+	buf.WriteString(">1 ")
+	buf.WriteString(r.Time.Format(syslogTimeFormat))
+	buf.WriteString(" ")
+	buf.WriteString(s.hostname)
+	buf.WriteString(" ")
+	if r.Module == "" {
+		buf.WriteString("-")
+	} else {
+		buf.WriteString(r.Module)
+	}
+	buf.WriteString(" ")
+	buf.WriteString(s.pid)
+	buf.WriteString(" - - ")
+	buf.Write(bytes.TrimRight(r.Line, "\n"))
+	return buf.Bytes()
+}
+
+// Send implements RemoteSink.
+func (s *syslogSink) Send(records []*RemoteRecord) (err error) {
+	if s.conn == nil {
+		if s.conn, err = net.DialTimeout(s.network, s.addr, syslogDialTimeout); err != nil {
+			s.conn = nil
+			return
+		}
+	}
+	for _, r := range records {
+		msg := s.format(r)
+		if s.network == "tcp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+		if _, err = s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return
+		}
+	}
+	return
+}
+
+// Close implements RemoteSink.
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/mtls"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -89,7 +90,7 @@ func (helper *masterHelper) request(method, path string, param, header map[strin
 			host = nodes[i]
 		}
 		var resp *http.Response
-		resp, err = helper.httpRequest(method, fmt.Sprintf("http://%s%s", host,
+		resp, err = helper.httpRequest(method, fmt.Sprintf("%s://%s%s", mtls.Scheme(), host,
 			path), param, header, reqData)
 		if err != nil {
 			log.LogErrorf("[masterHelper] %s", err)
@@ -160,7 +161,7 @@ func (helper *masterHelper) prepareRequest() (addr string, nodes []string) {
 }
 
 func (helper *masterHelper) httpRequest(method, url string, param, header map[string]string, reqData []byte) (resp *http.Response, err error) {
-	client := &http.Client{}
+	client := &http.Client{Transport: mtls.HTTPTransport()}
 	reader := bytes.NewReader(reqData)
 	client.Timeout = requestTimeout
 	var req *http.Request
@@ -0,0 +1,256 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics provides the metric registry shared by all the ChubaoFS daemons.
+//
+// Every metric is named "cfs_<module>_<name>" and uses the same label names
+// (cluster, volume, partition, op), so that dashboards can be built once and
+// reused for master, metanode, datanode, objectnode and the client.
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	Namespace = "cfs"
+)
+
+// Label names shared by all the modules.
+const (
+	LabelCluster   = "cluster"
+	LabelVolume    = "volume"
+	LabelPartition = "partition"
+	LabelOp        = "op"
+)
+
+// Metric names shared by all the modules.
+const (
+	MetricOpDuration = "op_duration_seconds"
+	MetricOpTotal    = "op_total"
+	MetricOpErrors   = "op_errors_total"
+)
+
+var (
+	// DefaultLatencyBuckets covers latencies from 100us to about 13s.
+	DefaultLatencyBuckets = prometheus.ExponentialBuckets(0.0001, 2, 18)
+)
+
+var (
+	module   string
+	role     string
+	cluster  string
+	enabled  bool
+	mu       sync.RWMutex
+	vecs     sync.Map
+	replacer = strings.NewReplacer("-", "_", ".", "_", " ", "_", ",", "_")
+)
+
+// Init sets the module name used as the subsystem of every metric and enables the registry.
+func Init(moduleName string) {
+	mu.Lock()
+	defer mu.Unlock()
+	module = normalize(moduleName)
+	role = moduleName
+	enabled = true
+}
+
+// SetCluster sets the value of the cluster label. It is usually called after
+// the node has registered itself to the master.
+func SetCluster(clusterName string) {
+	mu.Lock()
+	defer mu.Unlock()
+	cluster = clusterName
+}
+
+// Enabled returns whether the metrics have been initialized.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled
+}
+
+// Cluster returns the value of the cluster label.
+func Cluster() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cluster
+}
+
+// Name returns the full metric name "cfs_<module>_<name>".
+func Name(name string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return fullName(module, name)
+}
+
+// LegacyName returns the name "cfs_<module>_<name>" keeping the case of the module and
+// the name, which is the name of the metrics of the exporter the dashboards are built on.
+func LegacyName(name string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return replacer.Replace(Namespace + "_" + role + "_" + name)
+}
+
+func fullName(module, name string) string {
+	if module == "" {
+		return Namespace + "_" + normalize(name)
+	}
+	return Namespace + "_" + module + "_" + normalize(name)
+}
+
+func normalize(name string) string {
+	return strings.ToLower(replacer.Replace(name))
+}
+
+// CounterVec returns the registered counter vector with the given name, creating it if needed.
+// The cluster label is always added in front of the given labels.
+func CounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return counterVec(Name(name), help, labels)
+}
+
+// LegacyCounterVec is CounterVec named by LegacyName.
+func LegacyCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return counterVec(LegacyName(name), help, labels)
+}
+
+func counterVec(fn, help string, labels []string) *prometheus.CounterVec {
+	if v, ok := vecs.Load(vecKey(fn, labels)); ok {
+		return v.(*prometheus.CounterVec)
+	}
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: fn, Help: help}, withCluster(labels))
+	return register(vecKey(fn, labels), vec).(*prometheus.CounterVec)
+}
+
+// GaugeVec returns the registered gauge vector with the given name, creating it if needed.
+// The cluster label is always added in front of the given labels.
+func GaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	return gaugeVec(Name(name), help, labels)
+}
+
+// LegacyGaugeVec is GaugeVec named by LegacyName.
+func LegacyGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	return gaugeVec(LegacyName(name), help, labels)
+}
+
+func gaugeVec(fn, help string, labels []string) *prometheus.GaugeVec {
+	if v, ok := vecs.Load(vecKey(fn, labels)); ok {
+		return v.(*prometheus.GaugeVec)
+	}
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: fn, Help: help}, withCluster(labels))
+	return register(vecKey(fn, labels), vec).(*prometheus.GaugeVec)
+}
+
+// HistogramVec returns the registered histogram vector with the given name, creating it if needed.
+// The cluster label is always added in front of the given labels.
+func HistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	fn := Name(name)
+	if v, ok := vecs.Load(vecKey(fn, labels)); ok {
+		return v.(*prometheus.HistogramVec)
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: fn, Help: help, Buckets: buckets}, withCluster(labels))
+	return register(vecKey(fn, labels), vec).(*prometheus.HistogramVec)
+}
+
+// vecKey keys the vectors by the name and the label names, so that a name reused with
+// other labels never hands out a vector of the wrong labels.
+func vecKey(name string, labels []string) string {
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+func withCluster(labels []string) []string {
+	return append([]string{LabelCluster}, labels...)
+}
+
+func register(key string, c prometheus.Collector) prometheus.Collector {
+	actual, loaded := vecs.LoadOrStore(key, c)
+	if loaded {
+		return actual.(prometheus.Collector)
+	}
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			vecs.Store(key, are.ExistingCollector)
+			return are.ExistingCollector
+		}
+	}
+	return c
+}
+
+// ObserveOp records the latency, the count and the failure of an operation.
+// The volume may be empty when the operation is not bound to a volume.
+func ObserveOp(op, volume string, start time.Time, err error) {
+	if !Enabled() {
+		return
+	}
+	c := Cluster()
+	HistogramVec(MetricOpDuration, "Latency of the operations in seconds.", nil, LabelVolume, LabelOp).
+		WithLabelValues(c, volume, op).Observe(time.Since(start).Seconds())
+	CounterVec(MetricOpTotal, "Number of the operations.", LabelVolume, LabelOp).
+		WithLabelValues(c, volume, op).Inc()
+	if err != nil {
+		CounterVec(MetricOpErrors, "Number of the failed operations.", LabelVolume, LabelOp).
+			WithLabelValues(c, volume, op).Inc()
+	}
+}
+
+// metricVec is implemented by all the vectors.
+type metricVec interface {
+	prometheus.Collector
+	Delete(labels prometheus.Labels) bool
+}
+
+// DeleteVolume deletes the series of the volume from all the metrics labeled with
+// the volume, it is called when the volume is removed.
+func DeleteVolume(volume string) {
+	vecs.Range(func(_, v interface{}) bool {
+		if vec, ok := v.(metricVec); ok {
+			deleteVolume(vec, volume)
+		}
+		return true
+	})
+}
+
+func deleteVolume(vec metricVec, volume string) {
+	ch := make(chan prometheus.Metric, 64)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+	// the vector is locked while collecting, so the series are deleted afterwards
+	matched := make([]prometheus.Labels, 0)
+	for m := range ch {
+		pb := new(dto.Metric)
+		if err := m.Write(pb); err != nil {
+			continue
+		}
+		labels := make(prometheus.Labels, len(pb.GetLabel()))
+		for _, lp := range pb.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		if v, ok := labels[LabelVolume]; ok && v == volume {
+			matched = append(matched, labels)
+		}
+	}
+	for _, labels := range matched {
+		vec.Delete(labels)
+	}
+}
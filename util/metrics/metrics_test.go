@@ -0,0 +1,124 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestName(t *testing.T) {
+	Init("metaNode")
+	if name := Name("Op-Duration.seconds"); name != "cfs_metanode_op_duration_seconds" {
+		t.Fatalf("unexpected metric name %v", name)
+	}
+}
+
+func TestObserveOp(t *testing.T) {
+	Init("test")
+	SetCluster("cfs")
+	ObserveOp("OpMetaLookup", "vol", time.Now(), nil)
+	ObserveOp("OpMetaLookup", "vol", time.Now(), errors.New("failed"))
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found = make(map[string]uint64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch {
+			case m.GetHistogram() != nil:
+				found[mf.GetName()] = m.GetHistogram().GetSampleCount()
+			case m.GetCounter() != nil:
+				found[mf.GetName()] = uint64(m.GetCounter().GetValue())
+			}
+		}
+	}
+	if found["cfs_test_op_duration_seconds"] != 2 {
+		t.Fatalf("unexpected histogram samples %v", found)
+	}
+	if found["cfs_test_op_total"] != 2 || found["cfs_test_op_errors_total"] != 1 {
+		t.Fatalf("unexpected counters %v", found)
+	}
+}
+
+func TestVecReuse(t *testing.T) {
+	Init("test")
+	a := GaugeVec("reuse", "help", LabelVolume)
+	b := GaugeVec("reuse", "help", LabelVolume)
+	if a != b {
+		t.Fatalf("expect the same vector for the same name")
+	}
+}
+
+func TestOpStats(t *testing.T) {
+	s := NewOpStats()
+	s.Record("write", time.Now().Add(-2*time.Millisecond), nil)
+	s.Record("write", time.Now(), errors.New("failed"))
+	s.Record("read", time.Now(), nil)
+	stats := s.Snapshot()
+	if len(stats) != 2 || stats[0].Op != "read" || stats[1].Op != "write" {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+	if w := stats[1]; w.Count != 2 || w.Errors != 1 || w.MaxLatency < 2*time.Millisecond || w.AvgLatency() < time.Millisecond {
+		t.Fatalf("unexpected write stat %+v", w)
+	}
+}
+
+func TestSubOpStats(t *testing.T) {
+	before := []OpStat{{Op: "read", Count: 2, TotalTime: 2 * time.Millisecond}, {Op: "write", Count: 1}}
+	after := []OpStat{{Op: "create", Count: 1}, {Op: "read", Count: 5, Errors: 1, TotalTime: 8 * time.Millisecond}, {Op: "write", Count: 1}}
+	stats := Sub(after, before)
+	if len(stats) != 2 || stats[0].Op != "create" || stats[1].Op != "read" {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+	if r := stats[1]; r.Count != 3 || r.Errors != 1 || r.AvgLatency() != 2*time.Millisecond {
+		t.Fatalf("unexpected read stat %+v", r)
+	}
+}
+
+func TestDeleteVolume(t *testing.T) {
+	Init("test")
+	SetCluster("cfs")
+	ObserveOp("OpMetaLookup", "vol1", time.Now(), nil)
+	ObserveOp("OpMetaLookup", "vol2", time.Now(), nil)
+	LegacyGaugeVec("vol_used_GB", "", LabelVolume).WithLabelValues("cfs", "vol1").Set(1)
+	DeleteVolume("vol1")
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := make(map[string]bool)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == LabelVolume {
+					volumes[lp.GetValue()] = true
+				}
+			}
+		}
+	}
+	if volumes["vol1"] || !volumes["vol2"] {
+		t.Fatalf("unexpected volumes %v", volumes)
+	}
+	if name := LegacyName("OpMetaLookup_count"); name != "cfs_test_OpMetaLookup_count" {
+		t.Fatalf("unexpected legacy name %v", name)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// OpStat is the snapshot of the counters of an operation.
+type OpStat struct {
+	Op         string
+	Count      uint64
+	Errors     uint64
+	TotalTime  time.Duration
+	MaxLatency time.Duration
+}
+
+// AvgLatency returns the average latency of the operation.
+func (s *OpStat) AvgLatency() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalTime / time.Duration(s.Count)
+}
+
+// OpStats keeps the in-process counters of the operations, which are not exported to prometheus
+// but reported by the process itself, e.g. through the control files of the client.
+type OpStats struct {
+	mu  sync.Mutex
+	ops map[string]*OpStat
+}
+
+// NewOpStats returns a new OpStats.
+func NewOpStats() *OpStats {
+	return &OpStats{ops: make(map[string]*OpStat)}
+}
+
+// Record records an operation started at the given time.
+func (s *OpStats) Record(op string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stat, ok := s.ops[op]
+	if !ok {
+		stat = &OpStat{Op: op}
+		s.ops[op] = stat
+	}
+	stat.Count++
+	if err != nil {
+		stat.Errors++
+	}
+	stat.TotalTime += elapsed
+	if elapsed > stat.MaxLatency {
+		stat.MaxLatency = elapsed
+	}
+}
+
+// Sub returns the counters of the operations done between the two snapshots. The max
+// latency is taken from the later snapshot as it cannot be subtracted.
+func Sub(after, before []OpStat) []OpStat {
+	prev := make(map[string]OpStat, len(before))
+	for _, stat := range before {
+		prev[stat.Op] = stat
+	}
+	stats := make([]OpStat, 0, len(after))
+	for _, stat := range after {
+		if p, ok := prev[stat.Op]; ok {
+			stat.Count -= p.Count
+			stat.Errors -= p.Errors
+			stat.TotalTime -= p.TotalTime
+		}
+		if stat.Count > 0 {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// Snapshot returns the counters sorted by the operation name.
+func (s *OpStats) Snapshot() []OpStat {
+	s.mu.Lock()
+	stats := make([]OpStat, 0, len(s.ops))
+	for _, stat := range s.ops {
+		stats = append(stats, *stat)
+	}
+	s.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Op < stats[j].Op })
+	return stats
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package mtls secures the TCP connections between the clients, metanodes, datanodes and master
+// with mutual TLS.
+//
+// Every process presents the certificate configured by tlsCertFile and accepts the peers whose
+// certificates are issued by the CAs in tlsCAFile, so that only the members of the cluster are
+// able to connect. The peers are addressed by IP, hence their host names are not verified. The
+// files are reloaded once they change, so that the certificates and the CAs are rotated without
+// restarting the processes, the connections established before keep their former certificates.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	ConfigKeyCertFile = "tlsCertFile" // PEM certificate of the process
+	ConfigKeyKeyFile  = "tlsKeyFile"  // PEM private key of the certificate
+	ConfigKeyCAFile   = "tlsCAFile"   // PEM CAs issuing the certificates of the peers
+)
+
+const (
+	// HandshakeTimeout is the time limit of the TLS handshake of a connection.
+	HandshakeTimeout = 5 * time.Second
+
+	reloadInterval = time.Minute
+)
+
+var (
+	enabled  bool
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	modTimes [3]time.Time
+
+	transportOnce sync.Once
+	transport     *http.Transport
+)
+
+// Init enables mutual TLS if the certificate is configured, and starts reloading the files once
+// they change.
+func Init(cfg *config.Config) (err error) {
+	return InitFiles(cfg.GetString(ConfigKeyCertFile), cfg.GetString(ConfigKeyKeyFile), cfg.GetString(ConfigKeyCAFile))
+}
+
+// InitFiles enables mutual TLS by the files of the certificate, the private key and the CAs, e.g.
+// given by the flags of a tool.
+func InitFiles(cert, key, ca string) (err error) {
+	certFile, keyFile, caFile = cert, key, ca
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return
+	}
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return fmt.Errorf("%v, %v and %v must be configured together", ConfigKeyCertFile, ConfigKeyKeyFile, ConfigKeyCAFile)
+	}
+	if _, err = Reload(); err != nil {
+		return
+	}
+	enabled = true
+	go reloadLoop()
+	log.LogInfof("mtls enabled: cert(%v) key(%v) ca(%v)", certFile, keyFile, caFile)
+	return
+}
+
+// Enabled returns whether the TCP connections are secured by mutual TLS.
+func Enabled() bool {
+	return enabled
+}
+
+// Reload loads the certificate and the CAs again if any of the files changed, and returns
+// whether they are reloaded. The former ones are kept if the new files are invalid.
+func Reload() (reloaded bool, err error) {
+	var times [3]time.Time
+	for i, name := range []string{certFile, keyFile, caFile} {
+		var info os.FileInfo
+		if info, err = os.Stat(name); err != nil {
+			return
+		}
+		times[i] = info.ModTime()
+	}
+	mu.RLock()
+	unchanged := times == modTimes
+	mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	newCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return
+	}
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return
+	}
+	newPool := x509.NewCertPool()
+	if !newPool.AppendCertsFromPEM(caPEM) {
+		return false, fmt.Errorf("no CA certificate in %v", caFile)
+	}
+
+	mu.Lock()
+	cert = &newCert
+	caPool = newPool
+	modTimes = times
+	mu.Unlock()
+	return true, nil
+}
+
+func reloadLoop() {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reloaded, err := Reload()
+		if err != nil {
+			log.LogErrorf("mtls: reload the certificate failed: %v", err)
+		} else if reloaded {
+			log.LogInfof("mtls: the certificate and the CAs are reloaded")
+		}
+	}
+}
+
+func credentials() (*tls.Certificate, *x509.CertPool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cert, caPool
+}
+
+// verifyPeer verifies the certificate chain of the peer against the current CAs.
+func verifyPeer(rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return errors.New("mtls: no certificate from the peer")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, c)
+	}
+	_, pool := credentials()
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+func tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the chain is verified against the current CAs by verifyPeer, without the host name
+		InsecureSkipVerify: true,
+		ClientAuth:         tls.RequireAnyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c, _ := credentials()
+			return c, nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c, _ := credentials()
+			return c, nil
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPeer(rawCerts)
+		},
+	}
+}
+
+// ServerConfig returns the TLS config of the servers accepting the connections by themselves, e.g.
+// the HTTP ones.
+func ServerConfig() *tls.Config {
+	return tlsConfig()
+}
+
+// ClientConfig returns the TLS config of the clients dialing the connections by themselves.
+func ClientConfig() *tls.Config {
+	return tlsConfig()
+}
+
+// Scheme returns the scheme of the HTTP APIs of the cluster, which are served over TLS if mutual
+// TLS is enabled.
+func Scheme() string {
+	if enabled {
+		return "https"
+	}
+	return "http"
+}
+
+// HTTPTransport returns the transport of the HTTP clients to the APIs of the cluster, which
+// presents the certificate of the process and verifies the servers by the CAs. It is nil if
+// mutual TLS is not enabled, i.e. the default transport is used.
+func HTTPTransport() http.RoundTripper {
+	if !enabled {
+		return nil
+	}
+	transportOnce.Do(func() {
+		// the same settings as the default transport
+		transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			TLSClientConfig:       tlsConfig(),
+		}
+	})
+	return transport
+}
+
+// Server secures a connection accepted by a server, and returns it as it is if mutual TLS is
+// not enabled.
+func Server(conn net.Conn) (net.Conn, error) {
+	if !enabled {
+		return conn, nil
+	}
+	return handshake(tls.Server(conn, tlsConfig()))
+}
+
+// Client secures a connection dialed by a client, and returns it as it is if mutual TLS is not
+// enabled.
+func Client(conn net.Conn) (net.Conn, error) {
+	if !enabled {
+		return conn, nil
+	}
+	return handshake(tls.Client(conn, tlsConfig()))
+}
+
+func handshake(conn *tls.Conn) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// Dial connects to the address with the TCP options of the cluster, and secures the connection
+// if mutual TLS is enabled.
+func Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := conn.(*net.TCPConn)
+	c.SetKeepAlive(true)
+	c.SetNoDelay(true)
+	return Client(c)
+}
@@ -0,0 +1,344 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ratelimit limits the rate of the ops of the master, the metanodes, the datanodes and
+// the objectnodes by the rules configured on the master, which are pushed to the nodes by the
+// heartbeats and applied at runtime. A rule limits the ops per second, the bytes per second, or
+// both. The rules of the client module are fetched by the clients, which wait for the tokens
+// instead of being rejected by the nodes.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/metrics"
+	"golang.org/x/time/rate"
+)
+
+// The modules limited by the rules.
+const (
+	ModuleMaster     = "master"
+	ModuleMetaNode   = "metanode"
+	ModuleDataNode   = "datanode"
+	ModuleObjectNode = "objectnode"
+	ModuleClient     = "client"
+)
+
+const (
+	// EachClient is the client of the rules which limit each client separately.
+	EachClient = "*"
+
+	// the buckets of the clients are dropped when there are more, and refilled on their next ops
+	maxClientBuckets = 100000
+
+	// the bytes allowed at once are at least the largest packet
+	minBytesBurst = 16 * 1024 * 1024
+
+	MetricRateLimited = "rate_limited"
+)
+
+// Check returns an error if the rule is invalid.
+func Check(rule *proto.RateLimitRule) error {
+	switch rule.Module {
+	case ModuleMaster, ModuleMetaNode, ModuleDataNode, ModuleObjectNode:
+	case ModuleClient:
+		if rule.Client != "" {
+			return fmt.Errorf("the rules of the client module limit each client by itself")
+		}
+	default:
+		return fmt.Errorf("unknown module %v", rule.Module)
+	}
+	if rule.Rate < 0 || math.IsInf(rule.Rate, 0) || math.IsNaN(rule.Rate) {
+		return fmt.Errorf("invalid rate %v", rule.Rate)
+	}
+	if rule.Bytes < 0 || math.IsInf(rule.Bytes, 0) || math.IsNaN(rule.Bytes) {
+		return fmt.Errorf("invalid bytes %v", rule.Bytes)
+	}
+	if rule.Burst < 0 {
+		return fmt.Errorf("invalid burst %v", rule.Burst)
+	}
+	return nil
+}
+
+// SameTarget returns true if both rules limit the same ops.
+func SameTarget(a, b *proto.RateLimitRule) bool {
+	return a.Module == b.Module && a.Vol == b.Vol && a.Op == b.Op && a.Client == b.Client
+}
+
+func ruleKey(rule *proto.RateLimitRule) string {
+	return rule.Vol + "/" + rule.Op + "/" + rule.Client
+}
+
+func burstOf(rule *proto.RateLimitRule) int {
+	if rule.Burst > 0 {
+		return rule.Burst
+	}
+	if burst := int(math.Ceil(rule.Rate)); burst > 0 {
+		return burst
+	}
+	return 1
+}
+
+func bytesBurstOf(rule *proto.RateLimitRule) int {
+	if burst := int(math.Ceil(rule.Bytes)); burst > minBytesBurst {
+		return burst
+	}
+	return minBytesBurst
+}
+
+// bucket keeps the tokens of the ops and the bytes, either is nil if not limited.
+type bucket struct {
+	ops   *rate.Limiter
+	bytes *rate.Limiter
+}
+
+func newBucket(rule *proto.RateLimitRule) (b *bucket) {
+	b = new(bucket)
+	if rule.Rate > 0 {
+		b.ops = rate.NewLimiter(rate.Limit(rule.Rate), burstOf(rule))
+	}
+	if rule.Bytes > 0 {
+		b.bytes = rate.NewLimiter(rate.Limit(rule.Bytes), bytesBurstOf(rule))
+	}
+	return
+}
+
+func (b *bucket) setLimit(rule *proto.RateLimitRule) {
+	if b.ops != nil {
+		b.ops.SetLimit(rate.Limit(rule.Rate))
+	}
+	if b.bytes != nil {
+		b.bytes.SetLimit(rate.Limit(rule.Bytes))
+	}
+}
+
+// allow takes the tokens of an op of the bytes if there are enough of both.
+func (b *bucket) allow(now time.Time, bytes int) bool {
+	var ops, data *rate.Reservation
+	if b.ops != nil {
+		if ops = b.ops.ReserveN(now, 1); !ops.OK() || ops.DelayFrom(now) > 0 {
+			ops.CancelAt(now)
+			return false
+		}
+	}
+	if b.bytes != nil && bytes > 0 {
+		if data = b.bytes.ReserveN(now, clamp(bytes, b.bytes.Burst())); !data.OK() || data.DelayFrom(now) > 0 {
+			data.CancelAt(now)
+			if ops != nil {
+				ops.CancelAt(now)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// reserve takes the tokens of an op of the bytes, and returns the time to wait for them.
+func (b *bucket) reserve(now time.Time, bytes int) (delay time.Duration) {
+	if b.ops != nil {
+		delay = b.ops.ReserveN(now, 1).DelayFrom(now)
+	}
+	if b.bytes != nil && bytes > 0 {
+		if d := b.bytes.ReserveN(now, clamp(bytes, b.bytes.Burst())).DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	return
+}
+
+func clamp(n, max int) int {
+	if n > max {
+		return max
+	}
+	return n
+}
+
+type limitedRule struct {
+	rule    *proto.RateLimitRule
+	bucket  *bucket            // shared by all the clients
+	clients map[string]*bucket // per client, with the client EachClient
+}
+
+func newLimitedRule(rule *proto.RateLimitRule) (lr *limitedRule) {
+	lr = &limitedRule{rule: rule}
+	if rule.Client == EachClient {
+		lr.clients = make(map[string]*bucket)
+	} else {
+		lr.bucket = newBucket(rule)
+	}
+	return
+}
+
+// sameBuckets tells whether the buckets of the old rule can be kept for the new one, the burst of
+// a rate.Limiter can not be changed, nor can a limiter be added or removed.
+func sameBuckets(old, rule *proto.RateLimitRule) bool {
+	return burstOf(old) == burstOf(rule) && bytesBurstOf(old) == bytesBurstOf(rule) &&
+		(old.Rate > 0) == (rule.Rate > 0) && (old.Bytes > 0) == (rule.Bytes > 0)
+}
+
+func (lr *limitedRule) match(vol, op, client string) bool {
+	r := lr.rule
+	return (r.Vol == "" || r.Vol == vol) && (r.Op == "" || r.Op == op) &&
+		(r.Client == "" || r.Client == EachClient || r.Client == client)
+}
+
+// Limiter limits the ops of a module by the rules of the module.
+type Limiter struct {
+	module  string
+	mu      sync.Mutex
+	rules   []*limitedRule
+	clients int // the number of the buckets of the clients
+}
+
+// NewLimiter returns a limiter of the module without any rule.
+func NewLimiter(module string) *Limiter {
+	return &Limiter{module: module}
+}
+
+// Update replaces the rules of the limiter by the rules of its module. The rules which are not
+// changed keep their tokens, and the others take effect immediately.
+func (l *Limiter) Update(rules []*proto.RateLimitRule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old := make(map[string]*limitedRule, len(l.rules))
+	for _, lr := range l.rules {
+		old[ruleKey(lr.rule)] = lr
+	}
+	limitedRules := make([]*limitedRule, 0, len(rules))
+	l.clients = 0
+	for _, rule := range rules {
+		if rule.Module != l.module || Check(rule) != nil || (rule.Rate == 0 && rule.Bytes == 0) {
+			continue
+		}
+		lr, ok := old[ruleKey(rule)]
+		switch {
+		case !ok:
+			log.LogInfof("action[ratelimit.Update] module(%v) add rule %+v", l.module, *rule)
+			lr = newLimitedRule(rule)
+		case !sameBuckets(lr.rule, rule):
+			log.LogInfof("action[ratelimit.Update] module(%v) update rule %+v", l.module, *rule)
+			lr = newLimitedRule(rule)
+		case *lr.rule != *rule:
+			log.LogInfof("action[ratelimit.Update] module(%v) update rule %+v", l.module, *rule)
+			lr.rule = rule
+			if lr.bucket != nil {
+				lr.bucket.setLimit(rule)
+			}
+			for _, b := range lr.clients {
+				b.setLimit(rule)
+			}
+		}
+		delete(old, ruleKey(rule))
+		limitedRules = append(limitedRules, lr)
+		l.clients += len(lr.clients)
+	}
+	for _, lr := range old {
+		log.LogInfof("action[ratelimit.Update] module(%v) remove rule %+v", l.module, *lr.rule)
+	}
+	l.rules = limitedRules
+}
+
+// Rules returns the rules of the limiter.
+func (l *Limiter) Rules() (rules []*proto.RateLimitRule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, lr := range l.rules {
+		rule := *lr.rule
+		rules = append(rules, &rule)
+	}
+	return
+}
+
+// Allow takes a token of every rule which matches the op of the volume from the client, and
+// returns proto.ErrRateLimited if any of them is exhausted.
+func (l *Limiter) Allow(vol, op, client string) (err error) {
+	return l.AllowBytes(vol, op, client, 0)
+}
+
+// AllowBytes takes a token and the bytes of every rule which matches the op of the volume from the
+// client, and returns proto.ErrRateLimited if any of them is exhausted.
+func (l *Limiter) AllowBytes(vol, op, client string, bytes int) (err error) {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, lr := range l.rules {
+		if !lr.match(vol, op, client) {
+			continue
+		}
+		if !l.bucketOf(lr, client).allow(now, bytes) {
+			exporter.NewCounter(MetricRateLimited).AddWithLabels(1,
+				map[string]string{"module": l.module, metrics.LabelVolume: vol, metrics.LabelOp: op})
+			return proto.ErrRateLimited
+		}
+	}
+	return
+}
+
+// Wait takes a token and the bytes of every rule which matches the op of the volume from the
+// client, and waits until all of them are available instead of failing.
+func (l *Limiter) Wait(vol, op, client string, bytes int) {
+	if l == nil {
+		return
+	}
+	var delay time.Duration
+	now := time.Now()
+	l.mu.Lock()
+	for _, lr := range l.rules {
+		if !lr.match(vol, op, client) {
+			continue
+		}
+		if d := l.bucketOf(lr, client).reserve(now, bytes); d > delay {
+			delay = d
+		}
+	}
+	l.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// bucketOf returns the bucket of the rule for the client. The lock of the limiter must be held.
+func (l *Limiter) bucketOf(lr *limitedRule, client string) *bucket {
+	if lr.clients == nil {
+		return lr.bucket
+	}
+	b := lr.clients[client]
+	if b == nil {
+		if l.clients >= maxClientBuckets {
+			l.resetClients()
+		}
+		b = newBucket(lr.rule)
+		lr.clients[client] = b
+		l.clients++
+	}
+	return b
+}
+
+func (l *Limiter) resetClients() {
+	for _, lr := range l.rules {
+		if lr.clients != nil {
+			lr.clients = make(map[string]*bucket)
+		}
+	}
+	l.clients = 0
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func allowed(l *Limiter, n int, vol, op, client string) (count int) {
+	for i := 0; i < n; i++ {
+		if l.Allow(vol, op, client) == nil {
+			count++
+		}
+	}
+	return
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter("metanode")
+	if n := allowed(l, 100, "vol", "OpMetaCreateInode", "1.1.1.1"); n != 100 {
+		t.Fatalf("no rule: allowed %v", n)
+	}
+
+	l.Update([]*proto.RateLimitRule{
+		{Module: "datanode", Rate: 0.001, Burst: 1},
+		{Module: "metanode", Vol: "vol", Op: "OpMetaCreateInode", Rate: 0.001, Burst: 5},
+		{Module: "metanode", Vol: "other", Client: EachClient, Rate: 0.001, Burst: 2},
+	})
+	if n := allowed(l, 10, "vol", "OpMetaCreateInode", "1.1.1.1"); n != 5 {
+		t.Fatalf("op of the volume: allowed %v", n)
+	}
+	if n := allowed(l, 10, "vol", "OpMetaInodeGet", "1.1.1.1"); n != 10 {
+		t.Fatalf("other op: allowed %v", n)
+	}
+	if n := allowed(l, 10, "other", "OpMetaInodeGet", "1.1.1.1"); n != 2 {
+		t.Fatalf("first client: allowed %v", n)
+	}
+	if n := allowed(l, 10, "other", "OpMetaInodeGet", "2.2.2.2"); n != 2 {
+		t.Fatalf("second client: allowed %v", n)
+	}
+	if err := l.Allow("vol", "OpMetaCreateInode", "1.1.1.1"); err != proto.ErrRateLimited {
+		t.Fatalf("limited: %v", err)
+	}
+
+	// the unchanged rule keeps its exhausted bucket, and the larger burst takes effect at once
+	l.Update([]*proto.RateLimitRule{
+		{Module: "metanode", Vol: "vol", Op: "OpMetaCreateInode", Rate: 0.001, Burst: 5},
+		{Module: "metanode", Vol: "other", Client: EachClient, Rate: 0.001, Burst: 3},
+	})
+	if n := allowed(l, 10, "vol", "OpMetaCreateInode", "1.1.1.1"); n != 0 {
+		t.Fatalf("unchanged rule: allowed %v", n)
+	}
+	if n := allowed(l, 10, "other", "OpMetaInodeGet", "1.1.1.1"); n != 3 {
+		t.Fatalf("updated rule: allowed %v", n)
+	}
+	if rules := l.Rules(); len(rules) != 2 {
+		t.Fatalf("rules: %v", rules)
+	}
+
+	l.Update(nil)
+	if n := allowed(l, 10, "vol", "OpMetaCreateInode", "1.1.1.1"); n != 10 {
+		t.Fatalf("removed rule: allowed %v", n)
+	}
+}
+
+func TestLimiterBytes(t *testing.T) {
+	l := NewLimiter("datanode")
+	l.Update([]*proto.RateLimitRule{
+		{Module: "datanode", Vol: "vol", Bytes: 1},
+		{Module: "datanode", Vol: "other", Client: EachClient, Rate: 0.001, Burst: 10, Bytes: 1},
+	})
+	// the bytes are limited without the ops, and a packet larger than the burst takes all of it
+	if n := allowed(l, 10, "vol", "OpStreamRead", "1.1.1.1"); n != 10 {
+		t.Fatalf("ops without bytes: allowed %v", n)
+	}
+	if err := l.AllowBytes("vol", "OpWrite", "1.1.1.1", minBytesBurst/2); err != nil {
+		t.Fatalf("first half: %v", err)
+	}
+	if err := l.AllowBytes("vol", "OpWrite", "1.1.1.1", minBytesBurst); err != proto.ErrRateLimited {
+		t.Fatalf("over burst: %v", err)
+	}
+	if err := l.AllowBytes("vol", "OpWrite", "1.1.1.1", minBytesBurst/2); err != nil {
+		t.Fatalf("second half: %v", err)
+	}
+	// the op is not taken if the bytes are exhausted
+	if err := l.AllowBytes("other", "OpWrite", "1.1.1.1", 2*minBytesBurst); err != nil {
+		t.Fatalf("other: %v", err)
+	}
+	if err := l.AllowBytes("other", "OpWrite", "1.1.1.1", 1); err != proto.ErrRateLimited {
+		t.Fatalf("other exhausted: %v", err)
+	}
+	if n := allowed(l, 20, "other", "OpStreamRead", "1.1.1.1"); n != 9 {
+		t.Fatalf("other ops: allowed %v", n)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter("client")
+	l.Update([]*proto.RateLimitRule{{Module: "client", Vol: "vol", Rate: 100, Burst: 1}})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.Wait("vol", "OpWrite", "", 0)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("waited %v", elapsed)
+	}
+	start = time.Now()
+	l.Wait("other", "OpWrite", "", 0)
+	if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+		t.Fatalf("other volume waited %v", elapsed)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, rule := range []*proto.RateLimitRule{
+		{Rate: 1},
+		{Module: "master", Rate: -1},
+		{Module: "master", Rate: 1, Burst: -1},
+		{Module: "datanode", Bytes: -1},
+		{Module: "client", Client: "1.1.1.1", Rate: 1},
+	} {
+		if Check(rule) == nil {
+			t.Fatalf("invalid rule %+v passed", *rule)
+		}
+	}
+	if err := Check(&proto.RateLimitRule{Module: "master", Rate: 10}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package util
+
+import (
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// CanSendFile tells whether the data can be written to the connection by SendFile, which is
+// only possible for the plain TCP connections.
+func CanSendFile(conn net.Conn) bool {
+	_, ok := conn.(*net.TCPConn)
+	return ok
+}
+
+// SendFile writes the range of the file to the connection by sendfile(2), so that the data is
+// never copied through the user space. The offset of the file is not changed, so the file can
+// be read and sent concurrently. The write deadline of the connection applies.
+func SendFile(conn net.Conn, file *os.File, offset int64, size int) (written int, err error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return 0, syscall.EOPNOTSUPP
+	}
+	dst, err := tcpConn.SyscallConn()
+	if err != nil {
+		return
+	}
+	src, err := file.SyscallConn()
+	if err != nil {
+		return
+	}
+	var sendErr, waitErr error
+	// the file is referred but not locked by Control, so that the concurrent reads are not blocked
+	// by a slow connection
+	err = src.Control(func(srcFd uintptr) {
+		waitErr = dst.Write(func(dstFd uintptr) bool {
+			for written < size {
+				n, e := syscall.Sendfile(int(dstFd), int(srcFd), &offset, size-written)
+				if n > 0 {
+					written += n
+				}
+				switch {
+				case e == syscall.EAGAIN:
+					// wait until the socket is writable
+					return false
+				case e == syscall.EINTR:
+					continue
+				case e != nil:
+					sendErr = os.NewSyscallError("sendfile", e)
+					return true
+				case n == 0:
+					sendErr = io.ErrUnexpectedEOF
+					return true
+				}
+			}
+			return true
+		})
+	})
+	if err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		err = sendErr
+	}
+	return
+}
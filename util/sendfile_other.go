@@ -0,0 +1,35 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package util
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// CanSendFile tells whether the data can be written to the connection by SendFile, which is
+// only supported on Linux.
+func CanSendFile(conn net.Conn) bool {
+	return false
+}
+
+// SendFile is not supported on the platform.
+func SendFile(conn net.Conn, file *os.File, offset int64, size int) (written int, err error) {
+	return 0, syscall.EOPNOTSUPP
+}
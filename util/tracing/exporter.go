@@ -0,0 +1,191 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	otlpTracesPath    = "/v1/traces"
+	exportQueueSize   = 8192
+	exportBatchSize   = 512
+	exportInterval    = time.Second
+	exportHTTPTimeout = 5 * time.Second
+
+	otlpSpanKindServer = 2
+	otlpStatusOk       = 1
+	otlpStatusError    = 2
+)
+
+// exporter sends the finished spans to an OpenTelemetry collector in batches,
+// using the JSON encoding of OTLP over HTTP.
+type exporter struct {
+	url     string
+	service string
+	queue   chan *Span
+	client  *http.Client
+}
+
+func newExporter(endpoint, service string) *exporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, otlpTracesPath) {
+		url += otlpTracesPath
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+	return &exporter{
+		url:     url,
+		service: service,
+		queue:   make(chan *Span, exportQueueSize),
+		client:  &http.Client{Timeout: exportHTTPTimeout},
+	}
+}
+
+// add queues the span without blocking; the span is dropped if the queue is full.
+func (e *exporter) add(s *Span) {
+	if e == nil {
+		return
+	}
+	select {
+	case e.queue <- s:
+	default:
+	}
+}
+
+func (e *exporter) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) < exportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		e.export(batch)
+		batch = batch[:0]
+	}
+}
+
+func (e *exporter) export(spans []*Span) {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		log.LogWarnf("tracing export: marshal spans failed: %v", err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.LogWarnf("tracing export: post to %v failed: %v", e.url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.LogWarnf("tracing export: post to %v status(%v)", e.url, resp.StatusCode)
+	}
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func (e *exporter) encode(spans []*Span) *otlpTraces {
+	var scope otlpScopeSpans
+	scope.Scope.Name = "chubaofs"
+	scope.Spans = make([]otlpSpan, 0, len(spans))
+	var zero [8]byte
+	for _, s := range spans {
+		os := otlpSpan{
+			TraceID:           hex.EncodeToString(s.ctx.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.ctx.SpanID[:]),
+			Name:              s.name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusOk},
+		}
+		if s.parentID != zero {
+			os.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		s.mu.Lock()
+		for k, v := range s.tags {
+			os.Attributes = append(os.Attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: v}})
+		}
+		s.mu.Unlock()
+		if s.err != "" {
+			os.Status = otlpStatus{Code: otlpStatusError, Message: s.err}
+		}
+		scope.Spans = append(scope.Spans, os)
+	}
+	var rs otlpResourceSpans
+	rs.Resource.Attributes = []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: e.service}}}
+	rs.ScopeSpans = []otlpScopeSpans{scope}
+	return &otlpTraces{ResourceSpans: []otlpResourceSpans{rs}}
+}
@@ -0,0 +1,211 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package tracing implements a light-weight distributed tracing for the IO path.
+//
+// A trace is started by the client for a sampled request, its context is carried
+// in proto.Packet to metanode and datanode, and every hop reports its spans to an
+// OpenTelemetry collector through OTLP/HTTP, so that a slow request can be broken
+// down across the hops in Jaeger or Tempo.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	ConfigKeyEndpoint   = "traceEndpoint"   // OTLP/HTTP collector address, e.g. "http://127.0.0.1:4318"
+	ConfigKeySampleRate = "traceSampleRate" // ratio of the requests traced by the client, from 0 to 1
+)
+
+const (
+	// SpanContextSize is the size of the marshaled span context on the wire.
+	SpanContextSize = 25
+
+	FlagSampled uint8 = 0x01
+
+	DefaultSampleRate = 0.001
+)
+
+// SpanContext identifies a span across the process boundaries.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   uint8
+}
+
+// Marshal writes the span context into the given buffer which must be at least SpanContextSize long.
+func (sc *SpanContext) Marshal(out []byte) {
+	copy(out[0:16], sc.TraceID[:])
+	copy(out[16:24], sc.SpanID[:])
+	out[24] = sc.Flags
+}
+
+// Unmarshal reads the span context from the given buffer.
+func (sc *SpanContext) Unmarshal(in []byte) {
+	copy(sc.TraceID[:], in[0:16])
+	copy(sc.SpanID[:], in[16:24])
+	sc.Flags = in[24]
+}
+
+// IsSampled returns whether the trace is reported.
+func (sc *SpanContext) IsSampled() bool {
+	return sc != nil && sc.Flags&FlagSampled != 0
+}
+
+func (sc *SpanContext) String() string {
+	if sc == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]))
+}
+
+// Span represents a single operation within a trace.
+// All the methods can be called on a nil span, which means the operation is not traced.
+type Span struct {
+	name     string
+	ctx      SpanContext
+	parentID [8]byte
+	start    time.Time
+	end      time.Time
+	tags     map[string]string
+	err      string
+	mu       sync.Mutex
+}
+
+var (
+	service    string
+	sampleRate float64
+	enabled    bool
+	exp        *exporter
+	rnd        = mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	rndMu      sync.Mutex
+)
+
+// Init enables the tracing if the collector endpoint is configured.
+func Init(serviceName string, cfg *config.Config) {
+	endpoint := cfg.GetString(ConfigKeyEndpoint)
+	if endpoint == "" {
+		return
+	}
+	sampleRate = cfg.GetFloat(ConfigKeySampleRate)
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = DefaultSampleRate
+	}
+	service = serviceName
+	exp = newExporter(endpoint, serviceName)
+	go exp.run()
+	enabled = true
+	log.LogInfof("tracing enabled: service(%v) endpoint(%v) sampleRate(%v)", serviceName, endpoint, sampleRate)
+}
+
+// Enabled returns whether the spans are reported by this process.
+func Enabled() bool {
+	return enabled
+}
+
+// StartRootSpan starts a new trace if the request is sampled, otherwise it returns nil.
+func StartRootSpan(name string) *Span {
+	if !enabled || !sample() {
+		return nil
+	}
+	s := newSpan(name)
+	randomBytes(s.ctx.TraceID[:])
+	s.ctx.Flags = FlagSampled
+	return s
+}
+
+// StartSpan starts a child span of the given remote or local parent.
+// It returns nil if the parent is not sampled or the tracing is not enabled.
+func StartSpan(name string, parent *SpanContext) *Span {
+	if !enabled || !parent.IsSampled() {
+		return nil
+	}
+	s := newSpan(name)
+	s.ctx.TraceID = parent.TraceID
+	s.ctx.Flags = parent.Flags
+	s.parentID = parent.SpanID
+	return s
+}
+
+func newSpan(name string) *Span {
+	s := &Span{name: name, start: time.Now()}
+	randomBytes(s.ctx.SpanID[:])
+	return s
+}
+
+// Context returns the span context to be propagated to the next hop.
+func (s *Span) Context() *SpanContext {
+	if s == nil {
+		return nil
+	}
+	ctx := s.ctx
+	return &ctx
+}
+
+// SetTag attaches an attribute to the span.
+func (s *Span) SetTag(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.tags == nil {
+		s.tags = make(map[string]string)
+	}
+	s.tags[key] = fmt.Sprintf("%v", value)
+	s.mu.Unlock()
+}
+
+// Finish ends the span and queues it to be reported.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	exp.add(s)
+}
+
+func sample() bool {
+	if sampleRate >= 1 {
+		return true
+	}
+	rndMu.Lock()
+	defer rndMu.Unlock()
+	return rnd.Float64() < sampleRate
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err == nil {
+		return
+	}
+	rndMu.Lock()
+	defer rndMu.Unlock()
+	for i := 0; i < len(b); i += 8 {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], rnd.Uint64())
+		copy(b[i:], buf[:])
+	}
+}
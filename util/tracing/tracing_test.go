@@ -0,0 +1,75 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSpanContextMarshal(t *testing.T) {
+	sc := &SpanContext{Flags: FlagSampled}
+	randomBytes(sc.TraceID[:])
+	randomBytes(sc.SpanID[:])
+
+	buf := make([]byte, SpanContextSize)
+	sc.Marshal(buf)
+	got := new(SpanContext)
+	got.Unmarshal(buf)
+	if *got != *sc {
+		t.Fatalf("unmarshal mismatch: expect %v got %v", sc, got)
+	}
+	if !got.IsSampled() {
+		t.Fatalf("span context should be sampled")
+	}
+}
+
+func TestNilSpan(t *testing.T) {
+	var s *Span
+	s.SetTag("key", "value")
+	s.Finish(errors.New("error"))
+	if s.Context() != nil {
+		t.Fatalf("nil span should have nil context")
+	}
+	if StartSpan("child", nil) != nil {
+		t.Fatalf("span without parent should not be started")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	enabled = true
+	defer func() { enabled = false }()
+
+	parent := &SpanContext{Flags: FlagSampled}
+	randomBytes(parent.TraceID[:])
+	randomBytes(parent.SpanID[:])
+	s := StartSpan("test", parent)
+	s.SetTag("partition", 1)
+	s.Finish(errors.New("failed"))
+
+	e := newExporter("127.0.0.1:4318", "test")
+	if e.url != "http://127.0.0.1:4318/v1/traces" {
+		t.Fatalf("unexpected url %v", e.url)
+	}
+	traces := e.encode([]*Span{s})
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("expect 1 span got %v", len(spans))
+	}
+	os := spans[0]
+	if os.ParentSpanID == "" || os.Status.Code != otlpStatusError || len(os.Attributes) != 1 {
+		t.Fatalf("unexpected span %+v", os)
+	}
+}
@@ -14,7 +14,10 @@
 
 package util
 
-import "regexp"
+import (
+	"net"
+	"regexp"
+)
 
 const (
 	_  = iota
@@ -62,6 +65,16 @@ func IsIPV4(val interface{}) bool {
 	return isMatch(ip4, val)
 }
 
+// ProfAddr replaces the port of the node address with the prof port, the address without a
+// port is taken as the host.
+func ProfAddr(addr, port string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.JoinHostPort(host, port)
+}
+
 func regexpCompile(str string) *regexp.Regexp {
 	return regexp.MustCompile("^" + str + "$")
 }
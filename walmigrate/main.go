@@ -0,0 +1,142 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// cfs-walmigrate moves the raft wals of the partitions of a stopped metanode or datanode to the
+// directory configured as raftWalDir, or back to their former paths with -reverse. The nodes
+// move the wals of their partitions when starting too, the tool only saves the start time.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/raftstore"
+)
+
+var (
+	nodeType = flag.String("type", "", "type of the node, meta or data")
+	raftDir  = flag.String("raftDir", "", "raftDir of the metanode")
+	disks    = flag.String("disks", "", "disk directories of the datanode separated by comma")
+	walDir   = flag.String("walDir", "", "raftWalDir of the node")
+	reverse  = flag.Bool("reverse", false, "move the wals from walDir back to their former paths")
+	dryRun   = flag.Bool("dryRun", false, "print the moves without doing them")
+)
+
+var regexpDataPartitionDir = regexp.MustCompile(`^datapartition_(\d+)_\d+$`)
+
+// move is the former and the dedicated wal paths of a partition.
+type move struct {
+	id      uint64
+	oldPath string
+	newPath string
+}
+
+func main() {
+	flag.Parse()
+	if *walDir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var (
+		moves []*move
+		err   error
+	)
+	switch *nodeType {
+	case "meta":
+		moves, err = metaMoves(*raftDir, *walDir)
+	case "data":
+		moves, err = dataMoves(*disks, *walDir)
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list partitions failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	var failed int
+	for _, m := range moves {
+		from, to := m.oldPath, m.newPath
+		if *reverse {
+			from, to = to, from
+		}
+		if *dryRun {
+			fmt.Printf("partition %v: %v -> %v\n", m.id, from, to)
+			continue
+		}
+		migrated, err := raftstore.MigrateWal(from, to)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "partition %v: move %v to %v failed: %v\n", m.id, from, to, err)
+		case migrated:
+			fmt.Printf("partition %v: moved %v to %v\n", m.id, from, to)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// metaMoves lists the meta partitions, whose wals are named by their IDs in the raftDir.
+func metaMoves(raftDir, walDir string) (moves []*move, err error) {
+	if raftDir == "" {
+		return nil, fmt.Errorf("raftDir is required")
+	}
+	fileInfos, err := ioutil.ReadDir(raftDir)
+	if err != nil {
+		return
+	}
+	for _, fi := range fileInfos {
+		id, e := strconv.ParseUint(fi.Name(), 10, 64)
+		if e != nil || !fi.IsDir() {
+			continue
+		}
+		moves = append(moves, &move{id: id, oldPath: path.Join(raftDir, fi.Name()), newPath: raftstore.WalDirPath(walDir, id)})
+	}
+	return
+}
+
+// dataMoves lists the data partitions, whose wals are in the directories of the partitions.
+func dataMoves(disks, walDir string) (moves []*move, err error) {
+	if disks == "" {
+		return nil, fmt.Errorf("disks are required")
+	}
+	for _, disk := range strings.Split(disks, ",") {
+		var fileInfos []os.FileInfo
+		if fileInfos, err = ioutil.ReadDir(disk); err != nil {
+			return
+		}
+		for _, fi := range fileInfos {
+			match := regexpDataPartitionDir.FindStringSubmatch(fi.Name())
+			if match == nil || !fi.IsDir() {
+				continue
+			}
+			id, _ := strconv.ParseUint(match[1], 10, 64)
+			moves = append(moves, &move{
+				id:      id,
+				oldPath: path.Join(disk, fi.Name(), "wal_"+match[1]),
+				newPath: raftstore.WalDirPath(walDir, id),
+			})
+		}
+	}
+	return
+}